@@ -16,10 +16,20 @@ import (
 	"github.com/blakestevenson/nimbus/internal/configstore"
 	"github.com/blakestevenson/nimbus/internal/db"
 	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/downloader"
+	"github.com/blakestevenson/nimbus/internal/history"
 	httpserver "github.com/blakestevenson/nimbus/internal/http"
+	"github.com/blakestevenson/nimbus/internal/indexer"
+	"github.com/blakestevenson/nimbus/internal/library"
 	"github.com/blakestevenson/nimbus/internal/logging"
 	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/notifications"
 	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"github.com/blakestevenson/nimbus/internal/realtime"
+	"github.com/blakestevenson/nimbus/internal/rootfolders"
+	"github.com/blakestevenson/nimbus/internal/tags"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
@@ -65,6 +75,27 @@ func main() {
 	mediaService := media.NewService(queries, logger)
 	configStore := configstore.New(queries)
 
+	// Secrets in the config table are encrypted with a key derived from NIMBUS_SECRET_KEY
+	secretKey := cfg.SecretKey
+	if secretKey == "" {
+		logger.Warn("NIMBUS_SECRET_KEY is not set, encrypting config secrets with JWT_SECRET")
+		secretKey = cfg.JWTSecret
+	}
+	keyring, err := configstore.NewKeyring(secretKey, cfg.PreviousSecretKeys...)
+	if err != nil {
+		logger.Fatal("Failed to set up secret encryption", zap.Error(err))
+	}
+	configStore.SetKeyring(keyring)
+
+	// Move secrets written with a previous key to the current one
+	rotated, err := configStore.RotateSecrets(context.Background())
+	if err != nil {
+		logger.Warn("Failed to re-encrypt some config secrets", zap.Error(err))
+	}
+	if rotated > 0 {
+		logger.Info("Re-encrypted config secrets with the current key", zap.Int("count", rotated))
+	}
+
 	// Initialize JWT manager
 	jwtManager := auth.NewJWTManager(cfg.JWTSecret, 0, 0) // Use default expiry times
 
@@ -88,6 +119,14 @@ func main() {
 		}
 
 		pm := plugins.NewPluginManager(queries, configStore, logger, pluginsDir)
+		// Plugins call back into the API on the internal URL, authenticated by the internal token
+		if err := pm.SetInternalAPI(cfg.InternalURL, cfg.InternalToken); err != nil {
+			logger.Error("Invalid internal API URL, plugin callbacks will fail", zap.Error(err))
+		}
+		if os.Getenv("NIMBUS_INTERNAL_URL") == "" {
+			logger.Info("NIMBUS_INTERNAL_URL is not set, plugins will call back on localhost",
+				zap.String("internal_url", cfg.InternalURL))
+		}
 		if err := pm.Initialize(context.Background()); err != nil {
 			logger.Error("Failed to initialize plugin manager", zap.Error(err))
 			// Continue without plugins rather than failing entirely
@@ -100,6 +139,62 @@ func main() {
 		}
 	}
 
+	// Existing library paths become the default root folders
+	if err := rootfolders.NewService(queries, configStore, logger).EnsureDefaults(context.Background()); err != nil {
+		logger.Warn("Failed to create default root folders", zap.Error(err))
+	}
+
+	// Tags route media to the indexers, downloaders and notifiers sharing one
+	tagService := tags.NewService(dbPool)
+
+	// Notifications for download, import and health events
+	notificationService := notifications.NewService(queries, logger)
+	notificationService.SetTags(tagService)
+
+	// Activity history of grabs, downloads, imports, deletions and searches
+	historyService := history.NewService(queries, logger)
+
+	// Real-time updates pushed to the UI over /api/ws
+	realtimeHub := realtime.NewHub(logger)
+	realtimeHub.SetAllowedOrigins(cfg.AllowedOrigins)
+	historyService.SetHub(realtimeHub)
+
+	// Start the automatic search for monitoring rules (requires indexer and downloader plugins)
+	var autoSearcher *monitoring.AutoSearcher
+	if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+		searchDownloader := downloader.NewService(pm, dbPool, logger)
+		searchDownloader.SetNotifier(notificationService)
+		searchDownloader.SetHistory(historyService)
+		searchDownloader.SetHub(realtimeHub)
+		searchIndexer := indexer.NewService(pm, logger)
+		searchIndexer.SetTags(tagService)
+
+		searchMonitoring := monitoring.NewService(dbPool)
+		searchMonitoring.SetConfigStore(configStore)
+		autoSearcher = monitoring.NewAutoSearcher(
+			searchMonitoring,
+			quality.NewService(dbPool),
+			searchIndexer,
+			searchDownloader,
+			queries,
+			logger,
+		)
+		autoSearcher.SetConcurrency(configStore.GetIntOrDefault(context.Background(), "monitoring.search_concurrency", 2))
+		autoSearcher.SetTags(tagService)
+		autoSearcher.SetBlocklistExpiry(time.Duration(configStore.GetIntOrDefault(context.Background(), "monitoring.blocklist_expiry_hours", 168)) * time.Hour)
+		autoSearcher.SetReleaseLimits(monitoring.ReleaseLimits{
+			MinSizeMB:      int64(configStore.GetIntOrDefault(context.Background(), "monitoring.release_min_size_mb", 0)),
+			MaxSizeMB:      int64(configStore.GetIntOrDefault(context.Background(), "monitoring.release_max_size_mb", 0)),
+			MinMBPerMinute: configStore.GetFloatOrDefault(context.Background(), "monitoring.release_min_mb_per_minute", 0),
+			MaxMBPerMinute: configStore.GetFloatOrDefault(context.Background(), "monitoring.release_max_mb_per_minute", 0),
+			MaxAge:         time.Duration(configStore.GetIntOrDefault(context.Background(), "monitoring.release_max_age_days", 0)) * 24 * time.Hour,
+		})
+		autoSearcher.Start(context.Background())
+		defer autoSearcher.Stop()
+
+		logger.Info("Automatic search started")
+	}
+
 	// Get library root path from config
 	libraryRootPath := "/media" // Default path
 	if rootPath, err := configStore.Get(context.Background(), "library.root_path"); err == nil {
@@ -110,8 +205,25 @@ func main() {
 		}
 	}
 
+	// Scan the library on startup and keep it in sync with the filesystem
+	if configStore.GetBoolOrDefault(context.Background(), "library.watch_enabled", true) {
+		libraryScanner := library.NewScanner(queries, logger, libraryRootPath)
+		libraryScanner.SetMediaPath("movie", configStore.GetOrDefault(context.Background(), "library.movie_path", ""))
+		libraryScanner.SetMediaPath("tv", configStore.GetOrDefault(context.Background(), "library.tv_path", ""))
+		if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+			libraryScanner.SetEventBus(pm.Events())
+			libraryScanner.SetMetadataEnricher(library.NewMetadataEnricher(pm, queries, configStore, logger))
+		}
+
+		libraryWatcher := library.NewWatcher(libraryScanner, logger)
+		libraryWatcher.Start(context.Background())
+		defer libraryWatcher.Stop()
+
+		logger.Info("Library watcher started")
+	}
+
 	// Initialize HTTP router
-	router := httpserver.NewRouter(mediaService, authService, configStore, queries, dbPool, libraryRootPath, pluginManager, logger)
+	router := httpserver.NewRouter(mediaService, authService, configStore, queries, dbPool, libraryRootPath, pluginManager, autoSearcher, notificationService, historyService, realtimeHub, logger)
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
@@ -154,6 +266,11 @@ func main() {
 			}
 		}
 
+		// Plugins finish their work in the same window, before they are killed
+		if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+			pm.Events().PublishAndWait(ctx, plugins.EventHostShutdown, nil)
+		}
+
 		logger.Info("Server stopped")
 	}
 }
@@ -595,6 +595,17 @@ export function InteractiveSearchDialog({
                         <Badge variant="outline" className="text-xs">
                           {release.indexer_name}
                         </Badge>
+                        {release.also_available_on &&
+                          release.also_available_on.length > 0 && (
+                            <div
+                              className="mt-1 text-xs text-muted-foreground"
+                              title={release.also_available_on
+                                .map((source) => source.indexer_name)
+                                .join(", ")}
+                            >
+                              +{release.also_available_on.length} more
+                            </div>
+                          )}
                       </TableCell>
                       <TableCell className="text-right">
                         <div className="flex items-center justify-end gap-2">
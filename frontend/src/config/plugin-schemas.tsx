@@ -73,6 +73,14 @@ export const nzbDownloaderServerSchema: PluginObjectListConfig = {
       type: "number",
       description: "Lower numbers = higher priority",
     },
+    {
+      key: "retention_days",
+      label: "Retention (days)",
+      type: "number",
+      description:
+        "How long the server keeps articles; older releases are skipped on it. 0 = unknown",
+      min: 0,
+    },
     {
       key: "use_ssl",
       label: "Use SSL/TLS",
@@ -97,6 +105,7 @@ export const nzbDownloaderServerSchema: PluginObjectListConfig = {
     enabled: true,
     connections: 10,
     priority: 0,
+    retention_days: 0,
   },
   renderBadges: (server) => (
     <>
@@ -113,7 +122,11 @@ export const nzbDownloaderServerSchema: PluginObjectListConfig = {
     </>
   ),
   renderSummary: (server) =>
-    `${server.host}:${server.port} • ${server.connections} connections • Priority: ${server.priority}`,
+    `${server.host}:${server.port} • ${server.connections} connections • Priority: ${server.priority} • ${
+      server.retention_days
+        ? `${server.retention_days} days retention`
+        : "Retention not set"
+    }`,
 };
 
 // Usenet Indexer Schema
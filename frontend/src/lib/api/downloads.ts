@@ -1,5 +1,11 @@
 import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
 
+export interface DownloadPhase {
+  phase: string;
+  started_at: string;
+  finished_at?: string;
+}
+
 export interface Download {
   id: string;
   plugin_id: string;
@@ -8,6 +14,8 @@ export interface Download {
     | "queued"
     | "downloading"
     | "processing"
+    | "waiting_import"
+    | "interrupted"
     | "paused"
     | "completed"
     | "failed"
@@ -21,6 +29,11 @@ export interface Download {
   destination_path?: string;
   error_message?: string;
   queue_position?: number;
+  estimated_start_at?: string; // Estimate from recent throughput
+  estimated_completion_at?: string; // Estimate from recent throughput
+  phase?: "verifying" | "repairing" | "extracting" | "importing"; // Post-processing phase of a processing download
+  phase_progress?: number; // Percent of the phase done, when the downloader tells
+  phases?: DownloadPhase[]; // When each post-processing phase started and finished
   priority: number;
   created_at?: string;
   added_at?: string;
@@ -64,9 +77,19 @@ export function useDownloaders() {
   });
 }
 
+// A page of the downloads list, newest first unless sorted otherwise
+export interface DownloadsPage {
+  downloads: Download[];
+  total: number; // Downloads matching the filter, on every page
+  limit: number;
+  offset: number;
+  has_more: boolean;
+  estimated_completion_at?: string;
+}
+
 // Fetch downloads list
 export function useDownloads(pluginId?: string, status?: string) {
-  return useQuery<{ downloads: Download[]; total: number }>({
+  return useQuery<DownloadsPage>({
     queryKey: ["downloads", pluginId, status],
     queryFn: async () => {
       const params = new URLSearchParams();
@@ -32,6 +32,8 @@ export interface ScanStatus {
   files_scanned: number;
   items_created: number;
   items_updated: number;
+  items_removed: number;
+  directories_scanned: number;
   errors: LogEntry[];
   log: LogEntry[];
 }
@@ -1,5 +1,5 @@
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
-import { apiGet, apiPut, apiDelete } from "../api-client";
+import { apiGet, apiPost, apiPut, apiDelete } from "../api-client";
 import type {
   MediaItem,
   MediaListResponse,
@@ -20,6 +20,14 @@ export interface IndexerRelease {
   attributes?: Record<string, string>;
   indexer_id: string;
   indexer_name: string;
+  also_available_on?: ReleaseSource[];
+}
+
+export interface ReleaseSource {
+  indexer_id: string;
+  indexer_name: string;
+  guid: string;
+  download_url: string;
 }
 
 export interface InteractiveSearchResponse {
@@ -157,10 +165,23 @@ export interface MediaFile {
   path: string;
   size: number | null;
   hash: string | null;
+  quality: string | null;
+  release_group: string | null;
+  release_title: string | null;
   created_at: string;
   updated_at: string;
 }
 
+/**
+ * Describe the release a file was imported from, e.g. "WEB-DL 1080p (GROUP)"
+ */
+export function formatRelease(file: MediaFile): string {
+  if (!file.quality) return file.release_group ?? "";
+  return file.release_group
+    ? `${file.quality} (${file.release_group})`
+    : file.quality;
+}
+
 /**
  * Fetch all files associated with a media item
  */
@@ -200,7 +221,7 @@ export function useDeleteMediaFile() {
 }
 
 /**
- * Delete a media item with optional file deletion
+ * Delete a media item into the recycle bin, with its files when deleteFiles is set
  */
 export function useDeleteMediaItem() {
   const queryClient = useQueryClient();
@@ -217,7 +238,7 @@ export function useDeleteMediaItem() {
       if (deleteFiles) {
         params.append("delete_files", "true");
       }
-      return apiDelete(`/api/media/${mediaId}/with-files?${params.toString()}`);
+      return apiDelete(`/api/media/${mediaId}?${params.toString()}`);
     },
     onSuccess: () => {
       // Invalidate all media queries
@@ -226,6 +247,21 @@ export function useDeleteMediaItem() {
   });
 }
 
+/**
+ * Restore a deleted media item, and its files, from the recycle bin
+ */
+export function useRestoreMediaItem() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (mediaId: number) =>
+      apiPost(`/api/media/${mediaId}/restore`, {}),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["media"] });
+    },
+  });
+}
+
 // =============================================================================
 // Interactive Search API
 // =============================================================================
@@ -1,5 +1,5 @@
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
-import { apiGet, apiPost } from "../api-client";
+import { apiDelete, apiGet, apiPost } from "../api-client";
 
 // ============================================================================
 // Types
@@ -14,8 +14,20 @@ export interface PluginMeta {
   capabilities: string[];
   created_at?: string;
   updated_at?: string;
+  status: PluginStatus;
+  last_error?: string;
+  restarts?: number;
+  uptime_seconds?: number;
+  started_at?: string;
 }
 
+export type PluginStatus =
+  | "healthy"
+  | "unhealthy"
+  | "crashed"
+  | "restarting"
+  | "stopped";
+
 export interface PluginUINavItem {
   label: string;
   path: string;
@@ -87,6 +99,27 @@ export async function disablePlugin(
   return apiPost(`/api/plugins/${id}/disable`, {});
 }
 
+export async function restartPlugin(
+  id: string,
+): Promise<{ message: string; id: string; status: PluginStatus }> {
+  return apiPost(`/api/plugins/${id}/restart`, {});
+}
+
+export interface PluginReloadResult {
+  loaded: string[];
+  unloaded: string[];
+  restarted: string[];
+  errors?: Record<string, string>;
+}
+
+export async function reloadPlugins(): Promise<PluginReloadResult> {
+  return apiPost("/api/plugins/reload", {});
+}
+
+export async function unloadPlugin(id: string): Promise<void> {
+  return apiDelete(`/api/plugins/${id}`);
+}
+
 // ============================================================================
 // React Query Hooks
 // ============================================================================
@@ -141,6 +174,42 @@ export function useDisablePlugin() {
   });
 }
 
+export function useRestartPlugin() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: restartPlugin,
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["plugins"] });
+      queryClient.invalidateQueries({ queryKey: ["plugin-ui-manifests"] });
+    },
+  });
+}
+
+export function useReloadPlugins() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: reloadPlugins,
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["plugins"] });
+      queryClient.invalidateQueries({ queryKey: ["plugin-ui-manifests"] });
+    },
+  });
+}
+
+export function useUnloadPlugin() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: unloadPlugin,
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["plugins"] });
+      queryClient.invalidateQueries({ queryKey: ["plugin-ui-manifests"] });
+    },
+  });
+}
+
 /**
  * Hook to get all plugin navigation items for the sidebar
  */
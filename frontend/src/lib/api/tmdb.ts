@@ -1,5 +1,5 @@
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
-import { apiGet, apiPost, apiPut } from "../api-client";
+import { apiGet, apiPost } from "../api-client";
 
 export interface TMDBSearchResult {
   id: number;
@@ -66,6 +66,35 @@ export async function getTVSeasonDetails(
   return apiGet(`/api/plugins/tmdb/tv/${tvId}/season/${seasonNumber}`);
 }
 
+export interface MatchMediaPayload {
+  tmdb_id: string;
+  type: "movie" | "tv";
+}
+
+export interface MatchMediaResponse {
+  item: {
+    id: number;
+    metadata: Record<string, any>;
+    external_ids?: Record<string, any>;
+  };
+  previous_tmdb_id?: string;
+  tmdb_id: string;
+  metadata_changes: Record<string, { old: any; new: any }>;
+  external_id_changes: Record<string, { old: any; new: any }>;
+  seasons_updated: number;
+  episodes_updated: number;
+  warnings?: string[];
+}
+
+// Match a movie or series to a TMDB entry, replacing its metadata. Series
+// seasons and episodes are updated too.
+export async function matchMediaItem(
+  id: number,
+  payload: MatchMediaPayload,
+): Promise<MatchMediaResponse> {
+  return apiPost<MatchMediaResponse>(`/api/media/${id}/match`, payload);
+}
+
 export async function addToLibrary(
   payload: AddToLibraryPayload,
 ): Promise<{ id: number }> {
@@ -85,28 +114,13 @@ export async function addToLibrary(
     metadata,
   });
 
-  // Fetch enriched metadata from TMDB plugin
-  const enrichResponse = await apiPost<{
-    metadata: Record<string, any>;
-    external_ids?: Record<string, any>;
-  }>(`/api/plugins/tmdb/enrich/${mediaItem.id}`, {
+  // Match the new item to the TMDB entry, which stores its metadata and
+  // external IDs
+  const match = await matchMediaItem(mediaItem.id, {
     tmdb_id: payload.tmdb_id.toString(),
     type: payload.media_type,
   });
-
-  // Update the media item with the enriched metadata and external IDs
-  if (enrichResponse.metadata) {
-    const updatePayload: Record<string, any> = {
-      metadata: enrichResponse.metadata,
-    };
-
-    // Add external_ids if provided
-    if (enrichResponse.external_ids) {
-      updatePayload.external_ids = enrichResponse.external_ids;
-    }
-
-    await apiPut(`/api/media/${mediaItem.id}`, updatePayload);
-  }
+  const enrichResponse = { external_ids: match.item.external_ids };
 
   // If TV series with selected seasons, create season records
   if (
@@ -20,8 +20,12 @@ export interface MediaItem {
   metadata?: Record<string, unknown>;
   created_at: string;
   updated_at: string;
+  deleted_at?: string | null; // Set while the item is in the recycle bin
+  availability?: MovieAvailability; // Of movies
 }
 
+export type MovieAvailability = "announced" | "in_cinemas" | "released";
+
 export interface MediaListResponse {
   items: MediaItem[];
   total: number;
@@ -18,6 +18,8 @@ interface Download {
     | "queued"
     | "downloading"
     | "processing"
+    | "waiting_import"
+    | "interrupted"
     | "paused"
     | "completed"
     | "failed"
@@ -29,6 +31,11 @@ interface Download {
   destination_path?: string;
   error_message?: string;
   queue_position?: number;
+  estimated_start_at?: string;
+  estimated_completion_at?: string;
+  phase?: string;
+  phase_progress?: number;
+  phases?: { phase: string; started_at: string; finished_at?: string }[];
   priority: number;
   created_at?: string;
   added_at?: string;
@@ -118,14 +125,14 @@ export default function DownloadsPage() {
       const params = new URLSearchParams();
       if (filterStatus === "active") {
         // Fetch active statuses: queued, downloading, processing
-        const response = await fetch(`/api/downloads`, {
+        params.append("status", "queued,downloading,processing");
+        params.append("limit", "500");
+        const response = await fetch(`/api/downloads?${params}`, {
           credentials: "include",
         });
         if (!response.ok) throw new Error("Failed to fetch downloads");
         const data = await response.json();
-        const activeDownloads = (data.downloads || []).filter((d: Download) =>
-          ["queued", "downloading", "processing"].includes(d.status),
-        );
+        const activeDownloads: Download[] = data.downloads || [];
         setDownloads(activeDownloads);
 
         // Fetch media items for downloads with media_id
@@ -290,6 +297,35 @@ export default function DownloadsPage() {
     return new Date(dateString).toLocaleString();
   };
 
+  // Formats an estimated time as how far away it is, e.g. "~25m"
+  const formatEstimate = (dateString?: string) => {
+    if (!dateString) return null;
+    const minutes = Math.max(
+      0,
+      Math.round((new Date(dateString).getTime() - Date.now()) / 60000),
+    );
+    if (minutes < 60) return `~${minutes}m`;
+    const hours = Math.floor(minutes / 60);
+    return `~${hours}h ${minutes % 60}m`;
+  };
+
+  // Post-processing phase as shown, e.g. "Extracting"
+  const phaseLabel = (phase: string) =>
+    phase.charAt(0).toUpperCase() + phase.slice(1);
+
+  // Formats how long a phase took, e.g. "4m 12s"
+  const formatDuration = (startedAt: string, finishedAt: string) => {
+    const seconds = Math.max(
+      0,
+      Math.round(
+        (new Date(finishedAt).getTime() - new Date(startedAt).getTime()) / 1000,
+      ),
+    );
+    if (seconds < 60) return `${seconds}s`;
+    if (seconds < 3600) return `${Math.floor(seconds / 60)}m ${seconds % 60}s`;
+    return `${Math.floor(seconds / 3600)}h ${Math.floor((seconds % 3600) / 60)}m`;
+  };
+
   const getStatusColor = (status: Download["status"]) => {
     switch (status) {
       case "completed":
@@ -304,6 +340,10 @@ export default function DownloadsPage() {
         return "text-gray-700 dark:text-gray-400 bg-gray-100 dark:bg-gray-800";
       case "processing":
         return "text-purple-700 dark:text-purple-400 bg-purple-100 dark:bg-purple-950";
+      case "waiting_import":
+        return "text-amber-700 dark:text-amber-400 bg-amber-100 dark:bg-amber-950";
+      case "interrupted":
+        return "text-red-700 dark:text-red-400 bg-red-100 dark:bg-red-950";
       case "cancelled":
         return "text-orange-700 dark:text-orange-400 bg-orange-100 dark:bg-orange-950";
       default:
@@ -439,6 +479,8 @@ export default function DownloadsPage() {
               <option value="queued">Queued</option>
               <option value="downloading">Downloading</option>
               <option value="processing">Processing</option>
+              <option value="waiting_import">Waiting for Import</option>
+              <option value="interrupted">Interrupted</option>
               <option value="paused">Paused</option>
               <option value="completed">Completed</option>
               <option value="failed">Failed</option>
@@ -575,7 +617,7 @@ export default function DownloadsPage() {
                             download.status,
                           )}`}
                         >
-                          {download.status.toUpperCase()}
+                          {download.status.replace("_", " ").toUpperCase()}
                         </span>
                         <span className="text-xs text-muted-foreground">
                           {downloaders.find((d) => d.id === download.plugin_id)
@@ -587,6 +629,25 @@ export default function DownloadsPage() {
                               Queue: #{download.queue_position}
                             </span>
                           )}
+                        {download.status === "queued" &&
+                          download.estimated_start_at && (
+                            <span
+                              className="text-xs text-muted-foreground"
+                              title="Estimated from recent download speed"
+                            >
+                              Starts in{" "}
+                              {formatEstimate(download.estimated_start_at)}
+                            </span>
+                          )}
+                        {download.estimated_completion_at && (
+                          <span
+                            className="text-xs text-muted-foreground"
+                            title="Estimated from recent download speed"
+                          >
+                            Done in{" "}
+                            {formatEstimate(download.estimated_completion_at)}
+                          </span>
+                        )}
                         {download.started_at && (
                           <span className="text-xs text-muted-foreground">
                             {formatDate(download.started_at)}
@@ -640,7 +701,15 @@ export default function DownloadsPage() {
                     <div className="mb-2">
                       <div className="flex items-center justify-between text-xs text-muted-foreground mb-1">
                         <div className="flex items-center gap-2">
-                          <span>{download.progress.toFixed(1)}%</span>
+                          {download.status === "processing" && download.phase ? (
+                            <span>
+                              {phaseLabel(download.phase)}
+                              {download.phase_progress !== undefined &&
+                                ` ${download.phase_progress.toFixed(1)}%`}
+                            </span>
+                          ) : (
+                            <span>{download.progress.toFixed(1)}%</span>
+                          )}
                           {download.speed && download.speed > 0 && (
                             <span>{formatBytes(download.speed)}/s</span>
                           )}
@@ -654,12 +723,36 @@ export default function DownloadsPage() {
                       <div className="w-full bg-secondary rounded-full h-1.5">
                         <div
                           className="bg-primary h-1.5 rounded-full transition-all duration-300"
-                          style={{ width: `${download.progress}%` }}
+                          style={{
+                            width: `${
+                              download.status === "processing" &&
+                              download.phase_progress !== undefined
+                                ? download.phase_progress
+                                : download.progress
+                            }%`,
+                          }}
                         />
                       </div>
                     </div>
                   )}
 
+                  {/* Post-processing phases of a finished download */}
+                  {!["downloading", "processing", "queued"].includes(
+                    download.status,
+                  ) &&
+                    download.phases &&
+                    download.phases.some((p) => p.finished_at) && (
+                      <div className="mb-2 text-xs text-muted-foreground">
+                        {download.phases
+                          .filter((p) => p.finished_at)
+                          .map(
+                            (p) =>
+                              `${phaseLabel(p.phase)} ${formatDuration(p.started_at, p.finished_at!)}`,
+                          )
+                          .join(" • ")}
+                      </div>
+                    )}
+
                   {/* Error Message */}
                   {download.error_message && (
                     <div className="mb-2 p-2 bg-red-100 dark:bg-red-950 border border-red-300 dark:border-red-800 rounded flex items-start gap-2">
@@ -30,7 +30,9 @@ import {
   Clock,
   FileText,
   Database,
-  Loader2
+  Folder,
+  Loader2,
+  Trash2
 } from 'lucide-react';
 
 export default function LibraryPage() {
@@ -174,7 +176,18 @@ export default function LibraryPage() {
           </div>
         </CardHeader>
         <CardContent>
-          <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+          <div className="grid grid-cols-1 md:grid-cols-3 lg:grid-cols-5 gap-4">
+            {/* Directories Scanned */}
+            <div className="flex items-center space-x-3 p-4 bg-muted rounded-lg">
+              <div className="p-2 bg-background rounded-md">
+                <Folder className="h-5 w-5 text-purple-500" />
+              </div>
+              <div>
+                <p className="text-sm text-muted-foreground">Folders Scanned</p>
+                <p className="text-2xl font-bold">{status?.directories_scanned ?? 0}</p>
+              </div>
+            </div>
+
             {/* Files Scanned */}
             <div className="flex items-center space-x-3 p-4 bg-muted rounded-lg">
               <div className="p-2 bg-background rounded-md">
@@ -207,6 +220,17 @@ export default function LibraryPage() {
                 <p className="text-2xl font-bold">{status?.items_updated ?? 0}</p>
               </div>
             </div>
+
+            {/* Items Removed */}
+            <div className="flex items-center space-x-3 p-4 bg-muted rounded-lg">
+              <div className="p-2 bg-background rounded-md">
+                <Trash2 className="h-5 w-5 text-red-500" />
+              </div>
+              <div>
+                <p className="text-sm text-muted-foreground">Items Removed</p>
+                <p className="text-2xl font-bold">{status?.items_removed ?? 0}</p>
+              </div>
+            </div>
           </div>
 
           {/* Timestamps */}
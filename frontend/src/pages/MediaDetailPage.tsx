@@ -6,7 +6,9 @@ import {
   useMediaFiles,
   useDeleteMediaFile,
   useDeleteMediaItem,
+  useRestoreMediaItem,
   useMediaList,
+  formatRelease,
   type IndexerRelease,
 } from "@/lib/api/media";
 import { useTVSeasonDetails } from "@/lib/api/tmdb";
@@ -125,6 +127,7 @@ export function MediaDetailPage() {
   const updateMedia = useUpdateMedia(id!);
   const deleteFile = useDeleteMediaFile();
   const deleteMediaItem = useDeleteMediaItem();
+  const restoreMediaItem = useRestoreMediaItem();
 
   // Quality profile management
   const mediaIdNum = id ? parseInt(id) : 0;
@@ -191,10 +194,11 @@ export function MediaDetailPage() {
 
     return (tmdbSeasonData.episodes as any[]).map((ep: any) => {
       const existingEpisode = existingEpisodes.get(ep.episode_number);
-      const hasFiles =
+      const episodeFile =
         existingEpisode && files
-          ? files.some((f) => f.media_item_id === existingEpisode.id)
-          : false;
+          ? files.find((f) => f.media_item_id === existingEpisode.id)
+          : undefined;
+      const hasFiles = episodeFile !== undefined;
       const isDownloading =
         existingEpisode &&
         downloadingMediaIds.has(existingEpisode.id as number);
@@ -208,6 +212,7 @@ export function MediaDetailPage() {
         still_path: ep.still_path,
         vote_average: ep.vote_average,
         existingEpisode,
+        release: episodeFile ? formatRelease(episodeFile) : "",
         status: existingEpisode
           ? isDownloading
             ? "downloading"
@@ -309,6 +314,15 @@ export function MediaDetailPage() {
     }
   };
 
+  const handleRestoreMedia = async () => {
+    try {
+      await restoreMediaItem.mutateAsync(Number(id));
+    } catch (error) {
+      console.error("Failed to restore media item:", error);
+      alert("Failed to restore media item");
+    }
+  };
+
   const handleSelectRelease = (release: IndexerRelease) => {
     // Download is now handled by InteractiveSearchDialog
     // This callback is optional and just logs the selection
@@ -524,6 +538,26 @@ export function MediaDetailPage() {
 
   return (
     <div className="space-y-6">
+      {media.deleted_at && (
+        <div className="flex items-center justify-between rounded-lg border border-destructive/50 bg-destructive/10 p-4">
+          <p className="text-sm">
+            This item was deleted on{" "}
+            {new Date(media.deleted_at).toLocaleString()} and is in the recycle
+            bin.
+          </p>
+          <Button
+            variant="outline"
+            onClick={handleRestoreMedia}
+            disabled={restoreMediaItem.isPending}
+          >
+            {restoreMediaItem.isPending && (
+              <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+            )}
+            Restore
+          </Button>
+        </div>
+      )}
+
       {/* Backdrop Header */}
       {backdropUrl ? (
         <div className="relative -mx-6 -mt-6">
@@ -628,6 +662,11 @@ export function MediaDetailPage() {
                       </span>
                     </h1>
                     <MediaKindBadge kind={media.kind} />
+                    {media.availability && media.availability !== "released" && (
+                      <Badge variant="outline">
+                        {media.availability === "in_cinemas" ? "In Cinemas" : "Announced"}
+                      </Badge>
+                    )}
                   </div>
 
                   {/* Metadata Row */}
@@ -837,10 +876,17 @@ export function MediaDetailPage() {
                     </TableCell>
                     <TableCell>
                       {episode.status === "available" ? (
-                        <Badge variant="default" className="gap-1">
-                          <CheckCircle className="h-3 w-3" />
-                          Available
-                        </Badge>
+                        <div className="flex items-center gap-2">
+                          <Badge variant="default" className="gap-1">
+                            <CheckCircle className="h-3 w-3" />
+                            Available
+                          </Badge>
+                          {episode.release && (
+                            <span className="text-xs text-muted-foreground whitespace-nowrap">
+                              {episode.release}
+                            </span>
+                          )}
+                        </div>
                       ) : episode.status === "missing" ? (
                         <Badge variant="secondary" className="gap-1">
                           <XCircle className="h-3 w-3" />
@@ -1014,6 +1060,17 @@ export function MediaDetailPage() {
                       </div>
                       <div className="flex items-center gap-3 text-xs text-muted-foreground">
                         <span>{formatFileSize(file.size)}</span>
+                        {formatRelease(file) && (
+                          <>
+                            <span>•</span>
+                            <span
+                              className="whitespace-nowrap"
+                              title={file.release_title ?? undefined}
+                            >
+                              {formatRelease(file)}
+                            </span>
+                          </>
+                        )}
                         <span>•</span>
                         <span className="font-mono truncate" title={file.path}>
                           {file.path}
@@ -1513,8 +1570,9 @@ export function MediaDetailPage() {
           <DialogHeader>
             <DialogTitle>Delete Media Item</DialogTitle>
             <DialogDescription>
-              This action cannot be undone. This will permanently delete the
-              media item from the database.
+              The media item goes to the recycle bin, where it can be restored
+              until the recycle bin retention period ends. Deleted files are
+              moved to the recycle bin when one is configured.
             </DialogDescription>
           </DialogHeader>
           <div className="space-y-4 py-4">
@@ -1530,7 +1588,7 @@ export function MediaDetailPage() {
                 htmlFor="confirm-delete"
                 className="text-sm font-medium leading-none peer-disabled:cursor-not-allowed peer-disabled:opacity-70"
               >
-                I understand this action cannot be undone
+                I understand the item will stop being monitored
               </label>
             </div>
             {files && files.length > 0 && (
@@ -0,0 +1,269 @@
+package arrimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// App is an application settings are imported from
+type App string
+
+// Supported applications
+const (
+	AppSonarr App = "sonarr"
+	AppRadarr App = "radarr"
+)
+
+// Title is the name of the application as it calls itself
+func (a App) Title() string {
+	switch a {
+	case AppSonarr:
+		return "Sonarr"
+	case AppRadarr:
+		return "Radarr"
+	}
+	return string(a)
+}
+
+// maskedValue is what Sonarr and Radarr return in place of passwords and API
+// keys
+const maskedValue = "********"
+
+var (
+	// ErrUnknownApp is returned for applications that can't be imported from
+	ErrUnknownApp = errors.New("unknown application, must be sonarr or radarr")
+
+	// ErrSource is returned when the application can't be read
+	ErrSource = errors.New("failed to read from application")
+)
+
+// arrClient reads the settings of a Sonarr or Radarr instance through its v3
+// API
+type arrClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newArrClient(baseURL, apiKey string) *arrClient {
+	return &arrClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get decodes the response of a v3 API endpoint into out
+func (c *arrClient) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v3"+path, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSource, err)
+	}
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSource, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: API key was rejected", ErrSource)
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: GET %s returned %d: %s", ErrSource, path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: invalid response from %s: %v", ErrSource, path, err)
+	}
+	return nil
+}
+
+// arrField is a setting of an indexer or download client, which Sonarr and
+// Radarr describe as a list of named fields
+type arrField struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// arrFields looks up fields by name
+type arrFields []arrField
+
+func (f arrFields) raw(name string) json.RawMessage {
+	for _, field := range f {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+	return nil
+}
+
+// String returns a text field, or "" when it is missing
+func (f arrFields) String(name string) string {
+	var s string
+	json.Unmarshal(f.raw(name), &s)
+	return s
+}
+
+// Int returns a number field, or 0 when it is missing
+func (f arrFields) Int(name string) int {
+	var n int
+	json.Unmarshal(f.raw(name), &n)
+	return n
+}
+
+// Bool returns a checkbox field, or false when it is missing
+func (f arrFields) Bool(name string) bool {
+	var b bool
+	json.Unmarshal(f.raw(name), &b)
+	return b
+}
+
+// Strings returns a list field, like categories, with numbers as text
+func (f arrFields) Strings(name string) []string {
+	var values []json.Number
+	if err := json.Unmarshal(f.raw(name), &values); err != nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = v.String()
+	}
+	return result
+}
+
+type arrIndexer struct {
+	Name                    string    `json:"name"`
+	Implementation          string    `json:"implementation"`
+	Protocol                string    `json:"protocol"` // usenet, torrent
+	EnableRss               bool      `json:"enableRss"`
+	EnableAutomaticSearch   bool      `json:"enableAutomaticSearch"`
+	EnableInteractiveSearch bool      `json:"enableInteractiveSearch"`
+	Priority                int       `json:"priority"` // 1 to 50, lower is preferred
+	Fields                  arrFields `json:"fields"`
+}
+
+type arrDownloadClient struct {
+	Name           string    `json:"name"`
+	Implementation string    `json:"implementation"`
+	Protocol       string    `json:"protocol"`
+	Enable         bool      `json:"enable"`
+	Fields         arrFields `json:"fields"`
+}
+
+type arrQuality struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// arrProfileItem is a quality of a profile, or a group of qualities that
+// rank the same
+type arrProfileItem struct {
+	ID      int              `json:"id"` // Of a group
+	Name    string           `json:"name"`
+	Quality *arrQuality      `json:"quality"`
+	Items   []arrProfileItem `json:"items"`
+	Allowed bool             `json:"allowed"`
+}
+
+type arrQualityProfile struct {
+	ID             int              `json:"id"`
+	Name           string           `json:"name"`
+	UpgradeAllowed bool             `json:"upgradeAllowed"`
+	Cutoff         int              `json:"cutoff"` // ID of a quality or group
+	Items          []arrProfileItem `json:"items"`  // Least preferred first
+}
+
+type arrRootFolder struct {
+	Path string `json:"path"`
+}
+
+// arrNaming is the naming config of Sonarr or Radarr. The colon replacement
+// is a number in Sonarr and a name in Radarr.
+type arrNaming struct {
+	RenameEpisodes           bool            `json:"renameEpisodes"`
+	RenameMovies             bool            `json:"renameMovies"`
+	ReplaceIllegalCharacters bool            `json:"replaceIllegalCharacters"`
+	ColonReplacementFormat   json.RawMessage `json:"colonReplacementFormat"`
+	StandardEpisodeFormat    string          `json:"standardEpisodeFormat"`
+	DailyEpisodeFormat       string          `json:"dailyEpisodeFormat"`
+	AnimeEpisodeFormat       string          `json:"animeEpisodeFormat"`
+	SeriesFolderFormat       string          `json:"seriesFolderFormat"`
+	SeasonFolderFormat       string          `json:"seasonFolderFormat"`
+	StandardMovieFormat      string          `json:"standardMovieFormat"`
+	MovieFolderFormat        string          `json:"movieFolderFormat"`
+}
+
+// arrMedia is a series of Sonarr or a movie of Radarr
+type arrMedia struct {
+	Title            string `json:"title"`
+	Year             int    `json:"year"`
+	TVDBID           int    `json:"tvdbId"`
+	TMDBID           int    `json:"tmdbId"`
+	IMDBID           string `json:"imdbId"`
+	Monitored        bool   `json:"monitored"`
+	QualityProfileID int    `json:"qualityProfileId"`
+	RootFolderPath   string `json:"rootFolderPath"`
+	SeriesType       string `json:"seriesType"` // standard, daily, anime
+}
+
+// label names the media in the report, e.g. "Severance (2022)"
+func (m arrMedia) label() string {
+	if m.Year > 0 {
+		return m.Title + " (" + strconv.Itoa(m.Year) + ")"
+	}
+	return m.Title
+}
+
+// source is everything read from an application
+type source struct {
+	indexers  []arrIndexer
+	clients   []arrDownloadClient
+	profiles  []arrQualityProfile
+	folders   []arrRootFolder
+	naming    arrNaming
+	media     []arrMedia
+	withMedia bool
+}
+
+// read fetches the settings to import, and the series or movies when
+// withMedia is set
+func (c *arrClient) read(ctx context.Context, app App, withMedia bool) (*source, error) {
+	src := &source{withMedia: withMedia}
+	endpoints := []struct {
+		path string
+		out  interface{}
+	}{
+		{"/indexer", &src.indexers},
+		{"/downloadclient", &src.clients},
+		{"/qualityprofile", &src.profiles},
+		{"/rootfolder", &src.folders},
+		{"/config/naming", &src.naming},
+	}
+	if withMedia {
+		path := "/series"
+		if app == AppRadarr {
+			path = "/movie"
+		}
+		endpoints = append(endpoints, struct {
+			path string
+			out  interface{}
+		}{path, &src.media})
+	}
+
+	for _, e := range endpoints {
+		if err := c.get(ctx, e.path, e.out); err != nil {
+			return nil, err
+		}
+	}
+	return src, nil
+}
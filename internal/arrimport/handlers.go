@@ -0,0 +1,73 @@
+package arrimport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles import HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new import handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "arr-import-handler")),
+	}
+}
+
+// SetupRoutes registers the import routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Post("/system/import/{app}", h.Import)
+}
+
+// importRequest is the application to import from
+type importRequest struct {
+	URL    string `json:"url"`
+	APIKey string `json:"api_key"`
+}
+
+// Import imports the settings of a Sonarr or Radarr instance. It is a dry run
+// unless confirm is set, and include_media also monitors the series or movies
+// that are in the library.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	var req importRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" || req.APIKey == "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "url and api_key are required")
+		return
+	}
+
+	app := App(strings.ToLower(chi.URLParam(r, "app")))
+	opts := Options{
+		URL:          req.URL,
+		APIKey:       req.APIKey,
+		Confirm:      r.URL.Query().Get("confirm") == "true",
+		IncludeMedia: r.URL.Query().Get("include_media") == "true",
+	}
+	report, err := h.service.Import(r.Context(), app, opts)
+	switch {
+	case errors.Is(err, ErrUnknownApp):
+		httputil.RespondError(w, http.StatusNotFound, err, "Unknown application")
+	case errors.Is(err, ErrSource):
+		httputil.RespondError(w, http.StatusBadGateway, err, "Failed to read settings from "+app.Title())
+	case err != nil:
+		h.logger.Error("Failed to import settings", zap.String("app", string(app)), zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to import settings")
+	default:
+		httputil.RespondJSON(w, http.StatusOK, report)
+	}
+}
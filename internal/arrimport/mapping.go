@@ -0,0 +1,409 @@
+package arrimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/importer"
+)
+
+// indexerConfig is an indexer of the usenet-indexer plugin, as stored in its
+// config
+type indexerConfig struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	URL             string   `json:"url"`
+	APIKey          string   `json:"api_key"`
+	Enabled         bool     `json:"enabled"`
+	Priority        int      `json:"priority"`
+	TVCategories    []string `json:"tv_categories"`
+	MovieCategories []string `json:"movie_categories"`
+	APILimit        int      `json:"api_limit,omitempty"`
+	Protocol        string   `json:"protocol,omitempty"`
+
+	// Kept as stored when the indexer list is written back
+	APIPath      string                     `json:"api_path,omitempty"`
+	ExtraParams  map[string]json.RawMessage `json:"extra_params,omitempty"`
+	ExtraHeaders map[string]json.RawMessage `json:"extra_headers,omitempty"`
+}
+
+// clientConfig is a torrent client of the remote-torrent plugin, as stored in
+// its config
+type clientConfig struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Category string `json:"category"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// qualityProfile is a Nimbus quality profile, with its qualities most
+// preferred first
+type qualityProfile struct {
+	Name           string
+	UpgradeAllowed bool
+	Cutoff         *string
+	Items          []qualityItem
+}
+
+type qualityItem struct {
+	Quality string
+	Allowed bool
+}
+
+// mapIndexer translates a Newznab or Torznab indexer. Attention explains what
+// the user has to do before the indexer works, and is empty when it is ready;
+// an error means the indexer can't be imported at all.
+func mapIndexer(app App, idx arrIndexer) (cfg indexerConfig, attention string, err error) {
+	switch strings.ToLower(idx.Implementation) {
+	case "newznab":
+		cfg.Protocol = "newznab"
+	case "torznab":
+		cfg.Protocol = "torznab"
+	default:
+		return cfg, "", fmt.Errorf("%s indexers aren't supported, only Newznab and Torznab", idx.Implementation)
+	}
+
+	cfg.URL, err = indexerURL(idx.Fields.String("baseUrl"), idx.Fields.String("apiPath"))
+	if err != nil {
+		return cfg, "", err
+	}
+	cfg.Name = idx.Name
+	cfg.Priority = idx.Priority
+	cfg.Enabled = idx.EnableRss || idx.EnableAutomaticSearch || idx.EnableInteractiveSearch
+
+	categories := idx.Fields.Strings("categories")
+	if app == AppSonarr {
+		cfg.TVCategories = append(categories, idx.Fields.Strings("animeCategories")...)
+	} else {
+		cfg.MovieCategories = categories
+	}
+
+	switch key := idx.Fields.String("apiKey"); key {
+	case maskedValue:
+		cfg.Enabled = false
+		attention = app.Title() + " hides API keys; enter the API key and enable the indexer"
+	default:
+		cfg.APIKey = key
+	}
+	return cfg, attention, nil
+}
+
+// indexerURL builds the base URL Nimbus adds /api to from the base URL and API
+// path of an indexer
+func indexerURL(baseURL, apiPath string) (string, error) {
+	base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if u, err := url.Parse(base); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid base URL %q", baseURL)
+	}
+
+	path := strings.Trim(strings.TrimSpace(apiPath), "/")
+	switch {
+	case path == "" || path == "api":
+		return base, nil
+	case strings.HasSuffix(path, "/api"):
+		return base + "/" + strings.TrimSuffix(path, "/api"), nil
+	}
+	return "", fmt.Errorf("API path %q doesn't end in /api, which Nimbus requires", apiPath)
+}
+
+// mapDownloadClient translates a torrent client. Usenet clients can't be
+// imported, since Nimbus downloads from Usenet itself.
+func mapDownloadClient(app App, dc arrDownloadClient) (cfg clientConfig, attention string, err error) {
+	implementation := strings.ToLower(dc.Implementation)
+	switch implementation {
+	case "qbittorrent", "transmission", "deluge":
+		cfg.Type = implementation
+	case "sabnzbd", "nzbget":
+		return cfg, "", fmt.Errorf("%s isn't needed, Nimbus downloads from Usenet itself; add your news servers to the nzb-downloader plugin", dc.Implementation)
+	default:
+		return cfg, "", fmt.Errorf("%s download clients aren't supported, only qBittorrent, Transmission and Deluge", dc.Implementation)
+	}
+
+	host := strings.TrimSpace(dc.Fields.String("host"))
+	if host == "" {
+		return cfg, "", fmt.Errorf("client has no host")
+	}
+	scheme := "http"
+	if dc.Fields.Bool("useSsl") {
+		scheme = "https"
+	}
+	address := host
+	if port := dc.Fields.Int("port"); port > 0 {
+		address = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	urlBase := strings.Trim(dc.Fields.String("urlBase"), "/")
+	cfg.URL = scheme + "://" + address
+	if urlBase != "" {
+		cfg.URL += "/" + urlBase
+	}
+	// Sonarr and Radarr add rpc to the URL base of Transmission, where Nimbus
+	// takes the web address or the full RPC URL
+	if implementation == "transmission" && urlBase != "" {
+		cfg.URL += "/rpc"
+	}
+
+	cfg.Name = dc.Name
+	cfg.Username = dc.Fields.String("username")
+	cfg.Enabled = dc.Enable
+	if app == AppSonarr {
+		cfg.Category = dc.Fields.String("tvCategory")
+	} else {
+		cfg.Category = dc.Fields.String("movieCategory")
+	}
+
+	switch password := dc.Fields.String("password"); password {
+	case maskedValue:
+		cfg.Enabled = false
+		attention = app.Title() + " hides passwords; enter the password and enable the client"
+	default:
+		cfg.Password = password
+	}
+	return cfg, attention, nil
+}
+
+// qualityAliases are Sonarr and Radarr qualities that Nimbus names
+// differently, or doesn't have and are closest to a Nimbus quality
+var qualityAliases = map[string]string{
+	"Bluray-1080p Remux": "Remux-1080p",
+	"Bluray-2160p Remux": "Remux-2160p",
+	"Bluray-576p":        "Bluray-480p",
+	"DVD-R":              "DVD",
+	"Raw-HD":             "HDTV-1080p",
+}
+
+// mapQualityProfile translates a profile onto the qualities Nimbus defines.
+// Sonarr and Radarr list the least preferred quality first, Nimbus the most
+// preferred, and groups are flattened since every Nimbus quality ranks on its
+// own. The notes list what didn't translate.
+func mapQualityProfile(p arrQualityProfile, defined map[string]bool) (qualityProfile, []string) {
+	profile := qualityProfile{Name: p.Name, UpgradeAllowed: p.UpgradeAllowed}
+	var notes []string
+
+	index := make(map[string]int) // Quality -> position in profile.Items
+	var cutoff *string
+	add := func(q *arrQuality, allowed bool) string {
+		name := q.Name
+		if alias, ok := qualityAliases[name]; ok {
+			name = alias
+		}
+		if !defined[name] {
+			if allowed {
+				notes = append(notes, fmt.Sprintf("quality %s has no Nimbus equivalent and was left out", q.Name))
+			}
+			return ""
+		}
+		if i, ok := index[name]; ok {
+			// Two qualities mapped onto one, which is allowed if either was
+			profile.Items[i].Allowed = profile.Items[i].Allowed || allowed
+			return name
+		}
+		index[name] = len(profile.Items)
+		profile.Items = append(profile.Items, qualityItem{Quality: name, Allowed: allowed})
+		return name
+	}
+
+	for i := len(p.Items) - 1; i >= 0; i-- {
+		item := p.Items[i]
+		if item.Quality != nil {
+			name := add(item.Quality, item.Allowed)
+			if item.Quality.ID == p.Cutoff && name != "" {
+				cutoff = &name
+			}
+			continue
+		}
+
+		// The cutoff of a group is met by any of its qualities, so it becomes
+		// the least preferred of them
+		var least string
+		for j := len(item.Items) - 1; j >= 0; j-- {
+			if child := item.Items[j]; child.Quality != nil {
+				if name := add(child.Quality, item.Allowed); name != "" {
+					least = name
+				}
+			}
+		}
+		if item.ID == p.Cutoff && least != "" {
+			cutoff = &least
+		}
+	}
+
+	profile.Cutoff = cutoff
+	if cutoff == nil && p.UpgradeAllowed {
+		notes = append(notes, "the cutoff has no Nimbus equivalent, so upgrades continue to the best allowed quality")
+	}
+	return profile, notes
+}
+
+// allowsAny reports whether a profile allows any quality
+func (p qualityProfile) allowsAny() bool {
+	for _, item := range p.Items {
+		if item.Allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// arrToken matches a Sonarr or Radarr naming token, which may carry text
+// inside its braces that is only written when the token has a value, like
+// {[Quality Full]} or {-Release Group}
+var arrToken = regexp.MustCompile(`(?i)\{([- ._\[(]*)([a-z0-9]+(?:[- ._][a-z0-9]+)*)(?::([^{}]*))?([- ._)\]]*)\}`)
+
+// tokenTranslations are Sonarr and Radarr tokens Nimbus has no token of the
+// same name for, and what to write instead. Keys are lowercase.
+var tokenTranslations = map[string]string{
+	"series titleyear":                "{Series Title} ({Year})",
+	"series titlewithoutyear":         "{Series Title}",
+	"series cleantitle":               "{Series Title}",
+	"series cleantitleyear":           "{Series Title} {Year}",
+	"series titlethe":                 "{Series Title}",
+	"series year":                     "{Year}",
+	"movie titleyear":                 "{Movie Title} ({Release Year})",
+	"movie cleantitle":                "{Movie Title}",
+	"movie cleantitleyear":            "{Movie Title} {Release Year}",
+	"movie titlethe":                  "{Movie Title}",
+	"episode cleantitle":              "{Episode Title}",
+	"quality full":                    "{Quality}",
+	"quality title":                   "{Quality}",
+	"mediainfo simple":                "{MediaInfo VideoCodec} {MediaInfo AudioCodec}",
+	"mediainfo videodynamicrangetype": "{MediaInfo VideoDynamicRange}",
+}
+
+// translateFormat rewrites a Sonarr or Radarr naming format with Nimbus
+// tokens and checks it against the Nimbus template. Tokens without an
+// equivalent are kept, so the validation error names them.
+func translateFormat(format, template string) (string, []string, error) {
+	var notes []string
+	noted := make(map[string]bool)
+	note := func(s string) {
+		if !noted[s] {
+			noted[s] = true
+			notes = append(notes, s)
+		}
+	}
+
+	translated := arrToken.ReplaceAllStringFunc(format, func(token string) string {
+		m := arrToken.FindStringSubmatch(token)
+		prefix, name, numberFormat, suffix := m[1], m[2], m[3], m[4]
+
+		// Words separated by dots, dashes or underscores set the separator of
+		// the value, which Nimbus takes from the space replacement setting
+		words := strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '_' || r == '-' || r == ' ' })
+		normalized := strings.Join(words, " ")
+		if normalized != name && !strings.Contains(name, " ") {
+			note(fmt.Sprintf("separators in %s were dropped; set the space replacement instead", token))
+		}
+
+		replacement := "{" + normalized + "}"
+		if numberFormat != "" {
+			replacement = "{" + normalized + ":" + numberFormat + "}"
+		}
+		if t, ok := tokenTranslations[strings.ToLower(normalized)]; ok {
+			replacement = t
+		}
+		if prefix != "" || suffix != "" {
+			note(fmt.Sprintf("the text around %s is always written, even when it has no value", token))
+		}
+		return prefix + replacement + suffix
+	})
+
+	if err := importer.ValidateTemplate(template, translated); err != nil {
+		return translated, notes, err
+	}
+	return translated, notes, nil
+}
+
+// colonReplacement translates the colon replacement setting, a number in
+// Sonarr and a name in Radarr. Smart replacement has no equivalent and
+// becomes a dash.
+func colonReplacement(raw []byte) (string, string) {
+	var value string
+	if n, err := strconv.Atoi(string(raw)); err == nil {
+		// Sonarr's ColonReplacementFormat enum
+		value = [...]string{"delete", "dash", "spaceDash", "spaceDashSpace", "smart"}[min(max(n, 0), 4)]
+	} else {
+		value = strings.Trim(string(raw), `"`)
+	}
+
+	switch strings.ToLower(value) {
+	case "delete":
+		return "delete", ""
+	case "dash":
+		return "dash", ""
+	case "spacedash":
+		return "spacedash", "space dash colon replacement became space dash space"
+	case "spacedashspace":
+		return "spacedash", ""
+	case "smart":
+		return "dash", "smart colon replacement became dash"
+	}
+	return "", ""
+}
+
+// namingSetting is a naming config value to import. Settings with an error
+// are reported but not applied, and their key may only name the setting.
+type namingSetting struct {
+	Key   string
+	Value any
+	Notes []string
+	Err   error
+}
+
+// mapNaming translates the naming config into the importer settings it
+// corresponds to
+func mapNaming(app App, n arrNaming) []namingSetting {
+	var settings []namingSetting
+	format := func(key, format, template string) {
+		if strings.TrimSpace(format) == "" {
+			return
+		}
+		translated, notes, err := translateFormat(format, template)
+		if err != nil {
+			err = fmt.Errorf("%q translates to %q: %w", format, translated, err)
+		}
+		settings = append(settings, namingSetting{Key: key, Value: translated, Notes: notes, Err: err})
+	}
+
+	if app == AppSonarr {
+		settings = append(settings, namingSetting{Key: "downloads.rename_episodes", Value: n.RenameEpisodes})
+		format("downloads.tv_naming_format", n.StandardEpisodeFormat, importer.TemplateEpisodeFile)
+		format("downloads.tv_folder_format", n.SeriesFolderFormat, importer.TemplateSeriesFolder)
+		format("downloads.tv_season_folder_format", n.SeasonFolderFormat, importer.TemplateSeasonFolder)
+		for _, other := range []struct{ kind, format string }{{"daily", n.DailyEpisodeFormat}, {"anime", n.AnimeEpisodeFormat}} {
+			if strings.TrimSpace(other.format) != "" && other.format != n.StandardEpisodeFormat {
+				settings = append(settings, namingSetting{
+					Key: other.kind + " episode format",
+					Err: fmt.Errorf("%q wasn't imported, Nimbus names every episode with the standard format", other.format),
+				})
+			}
+		}
+	} else {
+		settings = append(settings, namingSetting{Key: "downloads.rename_movies", Value: n.RenameMovies})
+		format("downloads.movie_naming_format", n.StandardMovieFormat, importer.TemplateMovieFile)
+		format("downloads.movie_folder_format", n.MovieFolderFormat, importer.TemplateMovieFolder)
+	}
+
+	settings = append(settings, namingSetting{Key: "downloads.replace_illegal_characters", Value: n.ReplaceIllegalCharacters})
+	if len(n.ColonReplacementFormat) > 0 {
+		value, note := colonReplacement(n.ColonReplacementFormat)
+		setting := namingSetting{Key: "downloads.colon_replacement", Value: value}
+		if note != "" {
+			setting.Notes = []string{note}
+		}
+		if value == "" {
+			setting.Err = fmt.Errorf("unknown colon replacement %s", n.ColonReplacementFormat)
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
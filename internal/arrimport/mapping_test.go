@@ -0,0 +1,279 @@
+package arrimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/importer"
+)
+
+func fields(values map[string]interface{}) arrFields {
+	var f arrFields
+	for name, value := range values {
+		raw, _ := json.Marshal(value)
+		f = append(f, arrField{Name: name, Value: raw})
+	}
+	return f
+}
+
+func TestMapIndexer(t *testing.T) {
+	idx := arrIndexer{
+		Name:           "NZBgeek",
+		Implementation: "Newznab",
+		EnableRss:      true,
+		Priority:       10,
+		Fields: fields(map[string]interface{}{
+			"baseUrl":         "https://api.nzbgeek.info/",
+			"apiPath":         "/api",
+			"apiKey":          "secret",
+			"categories":      []int{5030, 5040},
+			"animeCategories": []int{5070},
+		}),
+	}
+	cfg, attention, err := mapIndexer(AppSonarr, idx)
+	if err != nil || attention != "" {
+		t.Fatalf("mapIndexer() = %q, %v", attention, err)
+	}
+	want := indexerConfig{
+		Name: "NZBgeek", URL: "https://api.nzbgeek.info", APIKey: "secret", Enabled: true, Priority: 10,
+		TVCategories: []string{"5030", "5040", "5070"}, Protocol: "newznab",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("mapIndexer() = %+v, want %+v", cfg, want)
+	}
+
+	// Radarr imports movie categories, and a masked key leaves it disabled
+	idx.Fields = fields(map[string]interface{}{"baseUrl": "https://indexer.test", "apiKey": maskedValue, "categories": []int{2000}})
+	cfg, attention, err = mapIndexer(AppRadarr, idx)
+	if err != nil || attention == "" || cfg.Enabled || cfg.APIKey != "" {
+		t.Errorf("mapIndexer() with a masked key = %+v, %q, %v, want disabled with attention", cfg, attention, err)
+	}
+	if len(cfg.MovieCategories) != 1 || cfg.TVCategories != nil {
+		t.Errorf("categories = %v, %v, want movie categories", cfg.TVCategories, cfg.MovieCategories)
+	}
+
+	idx.Implementation = "Rarbg"
+	if _, _, err := mapIndexer(AppSonarr, idx); err == nil {
+		t.Error("mapIndexer() of an unsupported indexer succeeded")
+	}
+}
+
+func TestIndexerURL(t *testing.T) {
+	tests := []struct {
+		base, apiPath, want string
+		wantErr             bool
+	}{
+		{"https://indexer.test/", "/api", "https://indexer.test", false},
+		{"https://indexer.test", "", "https://indexer.test", false},
+		{"http://prowlarr:9696/1", "/api", "http://prowlarr:9696/1", false},
+		{"https://indexer.test", "/nzb/api", "https://indexer.test/nzb", false},
+		{"https://indexer.test", "/search", "", true},
+		{"indexer.test", "/api", "", true},
+	}
+	for _, tt := range tests {
+		got, err := indexerURL(tt.base, tt.apiPath)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("indexerURL(%q, %q) = %q, %v, want %q", tt.base, tt.apiPath, got, err, tt.want)
+		}
+	}
+}
+
+func TestMapDownloadClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  arrDownloadClient
+		wantURL string
+	}{
+		{"qbittorrent", arrDownloadClient{Implementation: "QBittorrent", Fields: fields(map[string]interface{}{
+			"host": "qbit", "port": 8080, "useSsl": false, "username": "admin", "password": "pass", "tvCategory": "tv-sonarr",
+		})}, "http://qbit:8080"},
+		{"transmission", arrDownloadClient{Implementation: "Transmission", Fields: fields(map[string]interface{}{
+			"host": "transmission", "port": 9091, "urlBase": "/transmission/", "password": "pass",
+		})}, "http://transmission:9091/transmission/rpc"},
+		{"deluge over TLS", arrDownloadClient{Implementation: "Deluge", Fields: fields(map[string]interface{}{
+			"host": "deluge.test", "port": 443, "useSsl": true, "urlBase": "deluge", "password": "pass",
+		})}, "https://deluge.test:443/deluge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.Enable = true
+			cfg, attention, err := mapDownloadClient(AppSonarr, tt.client)
+			if err != nil || attention != "" {
+				t.Fatalf("mapDownloadClient() = %q, %v", attention, err)
+			}
+			if cfg.URL != tt.wantURL || !cfg.Enabled || cfg.Type != strings.ToLower(tt.client.Implementation) {
+				t.Errorf("mapDownloadClient() = %+v, want URL %s", cfg, tt.wantURL)
+			}
+		})
+	}
+
+	cfg, _, _ := mapDownloadClient(AppSonarr, tests[0].client)
+	if cfg.Category != "tv-sonarr" || cfg.Username != "admin" {
+		t.Errorf("mapDownloadClient() = %+v, want the TV category and username", cfg)
+	}
+
+	masked := arrDownloadClient{Implementation: "QBittorrent", Enable: true, Fields: fields(map[string]interface{}{"host": "qbit", "password": maskedValue})}
+	if cfg, attention, err := mapDownloadClient(AppRadarr, masked); err != nil || attention == "" || cfg.Enabled {
+		t.Errorf("mapDownloadClient() with a masked password = %+v, %q, %v, want disabled with attention", cfg, attention, err)
+	}
+
+	if _, _, err := mapDownloadClient(AppSonarr, arrDownloadClient{Implementation: "Sabnzbd"}); err == nil {
+		t.Error("mapDownloadClient() of SABnzbd succeeded")
+	}
+}
+
+func TestMapQualityProfile(t *testing.T) {
+	defined := map[string]bool{"SDTV": true, "HDTV-720p": true, "WEBDL-1080p": true, "WEBRip-1080p": true, "Bluray-1080p": true, "Remux-1080p": true}
+	quality := func(id int, name string, allowed bool) arrProfileItem {
+		return arrProfileItem{Quality: &arrQuality{ID: id, Name: name}, Allowed: allowed}
+	}
+	p := arrQualityProfile{
+		Name:           "HD-1080p",
+		UpgradeAllowed: true,
+		Cutoff:         1001,
+		Items: []arrProfileItem{
+			quality(1, "SDTV", false),
+			quality(4, "HDTV-720p", true),
+			quality(99, "Raw-HD", false),
+			{ID: 1001, Name: "WEB 1080p", Allowed: true, Items: []arrProfileItem{
+				quality(15, "WEBRip-1080p", true),
+				quality(3, "WEBDL-1080p", true),
+			}},
+			quality(7, "Bluray-1080p", true),
+			quality(20, "Bluray-1080p Remux", true),
+			quality(21, "Bluray-1080p Dolby", true),
+		},
+	}
+
+	profile, notes := mapQualityProfile(p, defined)
+	want := []qualityItem{
+		{"Remux-1080p", true},
+		{"Bluray-1080p", true},
+		{"WEBDL-1080p", true},
+		{"WEBRip-1080p", true},
+		{"HDTV-720p", true},
+		{"SDTV", false},
+	}
+	if !reflect.DeepEqual(profile.Items, want) {
+		t.Errorf("items = %v, want %v", profile.Items, want)
+	}
+	if profile.Cutoff == nil || *profile.Cutoff != "WEBRip-1080p" {
+		t.Errorf("cutoff = %v, want the least preferred quality of the group", profile.Cutoff)
+	}
+	// Raw-HD isn't defined here, but isn't allowed either
+	if len(notes) != 1 || !strings.Contains(notes[0], "Bluray-1080p Dolby") {
+		t.Errorf("notes = %v, want the unmapped allowed quality", notes)
+	}
+	if !profile.allowsAny() {
+		t.Error("allowsAny() = false")
+	}
+
+	p.Cutoff = 21
+	if profile, notes := mapQualityProfile(p, defined); profile.Cutoff != nil || len(notes) != 2 {
+		t.Errorf("unmapped cutoff = %v, notes %v, want no cutoff and a note", profile.Cutoff, notes)
+	}
+}
+
+func TestTranslateFormat(t *testing.T) {
+	tests := []struct {
+		format, template, want string
+		wantNotes              int
+		wantErr                bool
+	}{
+		{
+			"{Series Title} - S{season:00}E{episode:00} - {Episode Title} {Quality Full}",
+			importer.TemplateEpisodeFile,
+			"{Series Title} - S{season:00}E{episode:00} - {Episode Title} {Quality}", 0, false,
+		},
+		{
+			"{Series TitleYear} - S{season:00}E{episode:00} - {Episode CleanTitle} [{Quality Full}]{-Release Group}",
+			importer.TemplateEpisodeFile,
+			"{Series Title} ({Year}) - S{season:00}E{episode:00} - {Episode Title} [{Quality}]-{Release Group}", 1, false,
+		},
+		{"{Movie.Title}.{Release.Year}", importer.TemplateMovieFile, "{Movie Title}.{Release Year}", 2, false},
+		{"{Movie CleanTitle} ({Release Year})", importer.TemplateMovieFolder, "{Movie Title} ({Release Year})", 0, false},
+		{"Season {season:00}", importer.TemplateSeasonFolder, "Season {season:00}", 0, false},
+		{"{Original Title}", importer.TemplateMovieFile, "{Original Title}", 0, true},
+	}
+	for _, tt := range tests {
+		got, notes, err := translateFormat(tt.format, tt.template)
+		if got != tt.want || len(notes) != tt.wantNotes || (err != nil) != tt.wantErr {
+			t.Errorf("translateFormat(%q) = %q, %v, %v, want %q with %d notes", tt.format, got, notes, err, tt.want, tt.wantNotes)
+		}
+	}
+}
+
+func TestMapNaming(t *testing.T) {
+	settings := mapNaming(AppSonarr, arrNaming{
+		RenameEpisodes:         true,
+		ColonReplacementFormat: json.RawMessage(`4`),
+		StandardEpisodeFormat:  "{Series Title} - S{season:00}E{episode:00}",
+		DailyEpisodeFormat:     "{Series Title} - {Air-Date}",
+		SeriesFolderFormat:     "{Series Title}",
+	})
+	byKey := make(map[string]namingSetting)
+	for _, s := range settings {
+		byKey[s.Key] = s
+	}
+	if s := byKey["downloads.colon_replacement"]; s.Value != "dash" || len(s.Notes) != 1 {
+		t.Errorf("colon replacement = %+v, want smart as dash", s)
+	}
+	if s := byKey["daily episode format"]; s.Err == nil {
+		t.Errorf("daily format = %+v, want it reported", s)
+	}
+	if s := byKey["downloads.tv_naming_format"]; s.Err != nil || s.Value != "{Series Title} - S{season:00}E{episode:00}" {
+		t.Errorf("episode format = %+v", s)
+	}
+	if _, ok := byKey["downloads.tv_season_folder_format"]; ok {
+		t.Error("empty season folder format was imported")
+	}
+
+	settings = mapNaming(AppRadarr, arrNaming{RenameMovies: true, ColonReplacementFormat: json.RawMessage(`"spaceDashSpace"`)})
+	for _, s := range settings {
+		if s.Key == "downloads.colon_replacement" && s.Value != "spacedash" {
+			t.Errorf("Radarr colon replacement = %v, want spacedash", s.Value)
+		}
+	}
+}
+
+func TestClientRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/sonarr/api/v3/indexer", "/sonarr/api/v3/downloadclient", "/sonarr/api/v3/qualityprofile":
+			fmt.Fprint(w, `[]`)
+		case "/sonarr/api/v3/rootfolder":
+			fmt.Fprint(w, `[{"path":"/tv"}]`)
+		case "/sonarr/api/v3/config/naming":
+			fmt.Fprint(w, `{"renameEpisodes":true,"colonReplacementFormat":1}`)
+		case "/sonarr/api/v3/series":
+			fmt.Fprint(w, `[{"title":"Severance","year":2022,"tvdbId":371980,"monitored":true}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	src, err := newArrClient(server.URL+"/sonarr/", "key").read(context.Background(), AppSonarr, true)
+	if err != nil {
+		t.Fatalf("read() = %v", err)
+	}
+	if len(src.folders) != 1 || !src.naming.RenameEpisodes || len(src.media) != 1 || src.media[0].label() != "Severance (2022)" {
+		t.Errorf("read() = %+v", src)
+	}
+
+	_, err = newArrClient(server.URL+"/sonarr", "wrong").read(context.Background(), AppSonarr, false)
+	if !errors.Is(err, ErrSource) || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("read() with a wrong key = %v, want the key rejected", err)
+	}
+}
@@ -0,0 +1,602 @@
+package arrimport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/rootfolders"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Config keys of the plugins imported indexers and download clients belong to
+const (
+	configIndexers = "plugins.usenet-indexer.indexers"
+	configAPIKeys  = "plugins.usenet-indexer.api_keys" // Secret: indexer ID -> API key
+	configClients  = "plugins.remote-torrent.clients"
+)
+
+// Statuses of imported entities. Entities that need attention were either
+// created in a state the user has to finish, like an indexer without its API
+// key, or couldn't be imported; their notes say which.
+const (
+	StatusCreated        = "created"
+	StatusUpdated        = "updated"
+	StatusSkipped        = "skipped"
+	StatusNeedsAttention = "needs_attention"
+)
+
+// Kinds of imported entities
+const (
+	KindQualityProfile = "quality_profile"
+	KindRootFolder     = "root_folder"
+	KindIndexer        = "indexer"
+	KindDownloadClient = "download_client"
+	KindNaming         = "naming"
+	KindSeries         = "series"
+	KindMovie          = "movie"
+)
+
+// Entity is what happened to one imported setting
+type Entity struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Status string   `json:"status"`
+	Notes  []string `json:"notes,omitempty"`
+}
+
+// Report is the outcome of an import. A dry run reports what would happen and
+// changes nothing.
+type Report struct {
+	App            App      `json:"app"`
+	DryRun         bool     `json:"dry_run"`
+	Created        int      `json:"created"`
+	Updated        int      `json:"updated"`
+	Skipped        int      `json:"skipped"`
+	NeedsAttention int      `json:"needs_attention"`
+	Entities       []Entity `json:"entities"`
+}
+
+// Options control an import
+type Options struct {
+	URL          string // Of the Sonarr or Radarr web UI
+	APIKey       string
+	Confirm      bool // Apply the import, which is otherwise a dry run
+	IncludeMedia bool // Also monitor the series or movies that are in the library
+}
+
+// Service imports settings from Sonarr and Radarr
+type Service struct {
+	db          *pgxpool.Pool
+	configStore *configstore.Store
+	logger      *zap.Logger
+}
+
+// NewService creates a new import service
+func NewService(db *pgxpool.Pool, configStore *configstore.Store, logger *zap.Logger) *Service {
+	return &Service{
+		db:          db,
+		configStore: configStore,
+		logger:      logger.With(zap.String("component", "arr-import")),
+	}
+}
+
+// Import reads the settings of an application and creates the Nimbus
+// equivalents in one transaction, which is rolled back unless the import is
+// confirmed. Existing entities are never overwritten, so running an import
+// again only adds what is new.
+func (s *Service) Import(ctx context.Context, app App, opts Options) (*Report, error) {
+	if app != AppSonarr && app != AppRadarr {
+		return nil, ErrUnknownApp
+	}
+
+	src, err := newArrClient(opts.URL, opts.APIKey).read(ctx, app, opts.IncludeMedia)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	r := &run{Service: s, tx: tx, app: app, report: &Report{App: app, DryRun: !opts.Confirm, Entities: []Entity{}}}
+	if err := r.qualityProfiles(ctx, src.profiles); err != nil {
+		return nil, err
+	}
+	r.rootFolders(ctx, src.folders)
+	r.indexers(ctx, src.indexers)
+	r.downloadClients(ctx, src.clients)
+	r.naming(ctx, src.naming)
+	if src.withMedia {
+		r.media(ctx, src.media, src.profiles)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	report := r.report
+	for _, e := range report.Entities {
+		switch e.Status {
+		case StatusCreated:
+			report.Created++
+		case StatusUpdated:
+			report.Updated++
+		case StatusSkipped:
+			report.Skipped++
+		case StatusNeedsAttention:
+			report.NeedsAttention++
+		}
+	}
+
+	if !opts.Confirm {
+		return report, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	s.logger.Info("Imported settings",
+		zap.String("app", string(app)),
+		zap.Int("created", report.Created),
+		zap.Int("updated", report.Updated),
+		zap.Int("skipped", report.Skipped),
+		zap.Int("needs_attention", report.NeedsAttention))
+	return report, nil
+}
+
+// run applies one import within its transaction
+type run struct {
+	*Service
+	tx     pgx.Tx
+	app    App
+	report *Report
+}
+
+// apply imports one entity in a savepoint of its own, so an entity that fails
+// is rolled back without the rest and reported as needing attention
+func (r *run) apply(ctx context.Context, kind, name string, fn func(tx pgx.Tx) (string, []string, error)) {
+	if ctx.Err() != nil {
+		return
+	}
+	entity := Entity{Kind: kind, Name: name}
+	status, notes, err := r.savepoint(ctx, fn)
+	if err != nil {
+		entity.Status = StatusNeedsAttention
+		entity.Notes = append(notes, err.Error())
+	} else {
+		entity.Status = status
+		entity.Notes = notes
+	}
+	r.report.Entities = append(r.report.Entities, entity)
+}
+
+// savepoint runs fn in a savepoint that is rolled back when it fails
+func (r *run) savepoint(ctx context.Context, fn func(tx pgx.Tx) (string, []string, error)) (string, []string, error) {
+	sp, err := r.tx.Begin(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer sp.Rollback(ctx)
+
+	status, notes, err := fn(sp)
+	if err != nil {
+		return "", notes, err
+	}
+	return status, notes, sp.Commit(ctx)
+}
+
+// qualityProfiles creates the profiles whose names are new
+func (r *run) qualityProfiles(ctx context.Context, profiles []arrQualityProfile) error {
+	rows, err := r.tx.Query(ctx, `SELECT name FROM quality_definitions`)
+	if err != nil {
+		return fmt.Errorf("failed to list quality definitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to list quality definitions: %w", err)
+	}
+	defined := make(map[string]bool, len(names))
+	for _, name := range names {
+		defined[name] = true
+	}
+
+	for _, p := range profiles {
+		r.apply(ctx, KindQualityProfile, p.Name, func(tx pgx.Tx) (string, []string, error) {
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM quality_profiles WHERE name = $1)`, p.Name).Scan(&exists); err != nil {
+				return "", nil, err
+			}
+			if exists {
+				return StatusSkipped, []string{"a profile with this name already exists"}, nil
+			}
+
+			profile, notes := mapQualityProfile(p, defined)
+			if !profile.allowsAny() {
+				return "", notes, errors.New("none of the allowed qualities has a Nimbus equivalent")
+			}
+
+			var profileID int32
+			if err := tx.QueryRow(ctx, `
+				INSERT INTO quality_profiles (name, description, cutoff_quality_id, upgrade_allowed)
+				VALUES ($1, $2, (SELECT id FROM quality_definitions WHERE name = $3), $4)
+				RETURNING id
+			`, profile.Name, "Imported from "+r.app.Title(), profile.Cutoff, profile.UpgradeAllowed).Scan(&profileID); err != nil {
+				return "", notes, err
+			}
+			for i, item := range profile.Items {
+				if _, err := tx.Exec(ctx, `
+					INSERT INTO quality_profile_items (profile_id, quality_id, allowed, sort_order)
+					SELECT $1, id, $3, $4 FROM quality_definitions WHERE name = $2
+				`, profileID, item.Quality, item.Allowed, i+1); err != nil {
+					return "", notes, err
+				}
+			}
+			return StatusCreated, notes, nil
+		})
+	}
+	return nil
+}
+
+// rootFolders creates the root folders whose paths are new. The first folder
+// of a kind becomes its default.
+func (r *run) rootFolders(ctx context.Context, folders []arrRootFolder) {
+	kind := rootfolders.KindTV
+	if r.app == AppRadarr {
+		kind = rootfolders.KindMovie
+	}
+
+	for _, f := range folders {
+		path := strings.TrimRight(f.Path, "/\\")
+		if path == "" {
+			path = f.Path
+		}
+		r.apply(ctx, KindRootFolder, path, func(tx pgx.Tx) (string, []string, error) {
+			result, err := tx.Exec(ctx, `
+				INSERT INTO root_folders (path, media_kind, is_default)
+				VALUES ($1, $2, NOT EXISTS (SELECT 1 FROM root_folders WHERE media_kind = $2 AND is_default))
+				ON CONFLICT (path) DO NOTHING
+			`, path, kind)
+			if err != nil {
+				return "", nil, err
+			}
+			if result.RowsAffected() == 0 {
+				return StatusSkipped, []string{"root folder already exists"}, nil
+			}
+
+			// Paths are those Sonarr and Radarr see, which may not be where this
+			// server has the folder mounted
+			if described := rootfolders.Describe(generated.RootFolder{Path: path}); described.Error != "" {
+				return StatusNeedsAttention, []string{fmt.Sprintf("created, but this server can't use it: %s; change it to where the folder is mounted here", described.Error)}, nil
+			}
+			return StatusCreated, nil, nil
+		})
+	}
+}
+
+// indexers adds Newznab and Torznab indexers to the usenet-indexer plugin.
+// Indexers already configured, like those shared by Sonarr and Radarr, get
+// the categories of the application when they have none for it.
+func (r *run) indexers(ctx context.Context, indexers []arrIndexer) {
+	for _, idx := range indexers {
+		r.apply(ctx, KindIndexer, idx.Name, func(tx pgx.Tx) (string, []string, error) {
+			cfg, attention, err := mapIndexer(r.app, idx)
+			if err != nil {
+				return "", nil, err
+			}
+
+			var configs []indexerConfig
+			if err := readConfig(ctx, tx, configIndexers, &configs); err != nil {
+				return "", nil, err
+			}
+			ids := make(map[string]bool, len(configs))
+			for i, existing := range configs {
+				ids[existing.ID] = true
+				if !sameURL(existing.URL, cfg.URL) && !strings.EqualFold(existing.Name, cfg.Name) {
+					continue
+				}
+
+				var added string
+				if len(existing.TVCategories) == 0 && len(cfg.TVCategories) > 0 {
+					configs[i].TVCategories, added = cfg.TVCategories, "TV"
+				}
+				if len(existing.MovieCategories) == 0 && len(cfg.MovieCategories) > 0 {
+					configs[i].MovieCategories, added = cfg.MovieCategories, "movie"
+				}
+				if added == "" {
+					return StatusSkipped, []string{fmt.Sprintf("indexer %s already exists", existing.Name)}, nil
+				}
+				if err := writeConfig(ctx, tx, configIndexers, configs); err != nil {
+					return "", nil, err
+				}
+				return StatusUpdated, []string{fmt.Sprintf("added the %s categories to indexer %s", added, existing.Name)}, nil
+			}
+
+			cfg.ID = uniqueID(slug(cfg.Name, "indexer"), ids)
+			if cfg.APIKey != "" {
+				keys := make(map[string]string)
+				if err := r.readSecret(ctx, tx, configAPIKeys, &keys); err != nil {
+					return "", nil, err
+				}
+				keys[cfg.ID] = cfg.APIKey
+				sealed, err := r.configStore.Seal(keys)
+				if err != nil {
+					return "", nil, fmt.Errorf("failed to store API key: %w", err)
+				}
+				if err := writeConfig(ctx, tx, configAPIKeys, sealed); err != nil {
+					return "", nil, err
+				}
+				cfg.APIKey = ""
+			}
+			if err := writeConfig(ctx, tx, configIndexers, append(configs, cfg)); err != nil {
+				return "", nil, err
+			}
+
+			if attention != "" {
+				return StatusNeedsAttention, []string{"created disabled: " + attention}, nil
+			}
+			return StatusCreated, nil, nil
+		})
+	}
+}
+
+// downloadClients adds torrent clients to the remote-torrent plugin
+func (r *run) downloadClients(ctx context.Context, clients []arrDownloadClient) {
+	for _, dc := range clients {
+		r.apply(ctx, KindDownloadClient, dc.Name, func(tx pgx.Tx) (string, []string, error) {
+			cfg, attention, err := mapDownloadClient(r.app, dc)
+			if err != nil {
+				return "", nil, err
+			}
+
+			var configs []clientConfig
+			if err := readConfig(ctx, tx, configClients, &configs); err != nil {
+				return "", nil, err
+			}
+			for _, existing := range configs {
+				if sameURL(existing.URL, cfg.URL) || strings.EqualFold(existing.Name, cfg.Name) {
+					return StatusSkipped, []string{fmt.Sprintf("client %s already exists", existing.Name)}, nil
+				}
+			}
+
+			cfg.ID = randomID()
+			if err := writeConfig(ctx, tx, configClients, append(configs, cfg)); err != nil {
+				return "", nil, err
+			}
+			if attention != "" {
+				return StatusNeedsAttention, []string{"created disabled: " + attention}, nil
+			}
+			return StatusCreated, nil, nil
+		})
+	}
+}
+
+// naming applies the naming settings that translate onto Nimbus templates
+func (r *run) naming(ctx context.Context, n arrNaming) {
+	for _, setting := range mapNaming(r.app, n) {
+		r.apply(ctx, KindNaming, setting.Key, func(tx pgx.Tx) (string, []string, error) {
+			if setting.Err != nil {
+				return "", setting.Notes, setting.Err
+			}
+			value, err := json.Marshal(setting.Value)
+			if err != nil {
+				return "", nil, err
+			}
+
+			var current json.RawMessage
+			err = tx.QueryRow(ctx, `SELECT value FROM config WHERE key = $1`, setting.Key).Scan(&current)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				if err := writeConfig(ctx, tx, setting.Key, json.RawMessage(value)); err != nil {
+					return "", nil, err
+				}
+				return StatusCreated, setting.Notes, nil
+			case err != nil:
+				return "", nil, err
+			}
+
+			// Compare the values as re-encoded, ignoring how they were written
+			var currentValue interface{}
+			json.Unmarshal(current, &currentValue)
+			if normalized, _ := json.Marshal(currentValue); string(normalized) == string(value) {
+				return StatusSkipped, append(setting.Notes, "already set"), nil
+			}
+			if err := writeConfig(ctx, tx, setting.Key, json.RawMessage(value)); err != nil {
+				return "", nil, err
+			}
+			return StatusUpdated, append(setting.Notes, "was "+string(current)), nil
+		})
+	}
+}
+
+// media creates monitoring rules for the series or movies that are already
+// in the library, matched by their external IDs. Rules use the imported
+// profile and root folder of the same names; media that isn't in the library
+// is reported for the user to add first. Rules don't search on creation, so an
+// import doesn't start a search of the whole library.
+func (r *run) media(ctx context.Context, items []arrMedia, profiles []arrQualityProfile) {
+	profileNames := make(map[int]string, len(profiles))
+	for _, p := range profiles {
+		profileNames[p.ID] = p.Name
+	}
+	kind, mediaKind := KindSeries, "tv_series"
+	if r.app == AppRadarr {
+		kind, mediaKind = KindMovie, "movie"
+	}
+
+	for _, m := range items {
+		r.apply(ctx, kind, m.label(), func(tx pgx.Tx) (string, []string, error) {
+			var mediaID int64
+			err := tx.QueryRow(ctx, `
+				SELECT id FROM media_items
+				WHERE kind = $1 AND deleted_at IS NULL
+				  AND (($2 <> '' AND external_ids->>'tvdb_id' = $2)
+				    OR ($3 <> '' AND external_ids->>'tmdb_id' = $3)
+				    OR ($4 <> '' AND external_ids->>'imdb_id' = $4))
+				ORDER BY id
+				LIMIT 1
+			`, mediaKind, idString(m.TVDBID), idString(m.TMDBID), m.IMDBID).Scan(&mediaID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return StatusNeedsAttention, []string{"not in the library; add it, then import again to monitor it"}, nil
+			}
+			if err != nil {
+				return "", nil, err
+			}
+
+			var hasRule bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM monitoring_rules WHERE media_item_id = $1)`, mediaID).Scan(&hasRule); err != nil {
+				return "", nil, err
+			}
+			if hasRule {
+				return StatusSkipped, []string{"already has a monitoring rule"}, nil
+			}
+
+			var notes []string
+			var profileID *int32
+			if name, ok := profileNames[m.QualityProfileID]; ok {
+				err := tx.QueryRow(ctx, `SELECT id FROM quality_profiles WHERE name = $1`, name).Scan(&profileID)
+				if errors.Is(err, pgx.ErrNoRows) {
+					notes = append(notes, fmt.Sprintf("quality profile %s wasn't imported, so the default profile is used", name))
+				} else if err != nil {
+					return "", nil, err
+				}
+			}
+			var rootFolderID *int64
+			if m.RootFolderPath != "" {
+				err := tx.QueryRow(ctx, `SELECT id FROM root_folders WHERE path = $1`, strings.TrimRight(m.RootFolderPath, "/\\")).Scan(&rootFolderID)
+				if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+					return "", nil, err
+				}
+			}
+			seriesType := "standard"
+			switch m.SeriesType {
+			case "anime":
+				seriesType = "anime"
+			case "daily":
+				notes = append(notes, "daily series are searched like standard series")
+			}
+
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO monitoring_rules (media_item_id, enabled, quality_profile_id, root_folder_id, series_type, search_on_add)
+				VALUES ($1, $2, $3, $4, $5, false)
+			`, mediaID, m.Monitored, profileID, rootFolderID, seriesType); err != nil {
+				return "", nil, err
+			}
+			return StatusCreated, notes, nil
+		})
+	}
+}
+
+// readConfig decodes a config value read in the transaction, leaving out
+// unchanged when the key isn't set
+func readConfig(ctx context.Context, tx pgx.Tx, key string, out interface{}) error {
+	var raw json.RawMessage
+	err := tx.QueryRow(ctx, `SELECT value FROM config WHERE key = $1`, key).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("invalid config %s: %w", key, err)
+	}
+	return nil
+}
+
+// readSecret decodes a secret config value read in the transaction. Values
+// stored before they became secrets are read as they are.
+func (r *run) readSecret(ctx context.Context, tx pgx.Tx, key string, out interface{}) error {
+	var raw json.RawMessage
+	err := tx.QueryRow(ctx, `SELECT value FROM config WHERE key = $1`, key).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if configstore.IsSecret(raw) {
+		if raw, err = r.configStore.Open(raw); err != nil {
+			return fmt.Errorf("failed to decrypt config %s: %w", key, err)
+		}
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("invalid config %s: %w", key, err)
+	}
+	return nil
+}
+
+// writeConfig stores a config value in the transaction
+func writeConfig(ctx context.Context, tx pgx.Tx, key string, value interface{}) error {
+	raw, ok := value.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("failed to marshal config %s: %w", key, err)
+		}
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO config (key, value)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, updated_at = NOW()
+	`, key, raw)
+	return err
+}
+
+// sameURL reports whether two URLs point to the same place, ignoring case and
+// trailing slashes
+func sameURL(a, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, "/"), strings.TrimRight(b, "/"))
+}
+
+// slug turns a name into an ID like the usenet-indexer plugin generates
+func slug(name, fallback string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-':
+			b.WriteRune(c)
+		case c == ' ' || c == '_':
+			b.WriteRune('-')
+		}
+	}
+	if b.Len() == 0 {
+		return fallback
+	}
+	return b.String()
+}
+
+// uniqueID returns id, numbered when it is already taken
+func uniqueID(id string, taken map[string]bool) string {
+	candidate := id
+	for n := 2; taken[candidate]; n++ {
+		candidate = id + "-" + strconv.Itoa(n)
+	}
+	return candidate
+}
+
+// randomID returns an ID like the remote-torrent plugin generates
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// idString returns an external ID as text, or "" when it isn't set
+func idString(id int) string {
+	if id <= 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
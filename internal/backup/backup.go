@@ -0,0 +1,438 @@
+// Package backup exports and restores the configuration of a Nimbus server.
+//
+// A backup holds what is needed to rebuild a setup: the config table,
+// including plugin config such as indexers and NNTP servers, quality
+// definitions and profiles, custom formats, root folders, tags, plugin
+// settings, server-wide notifiers and monitoring rules. Media items and
+// downloads are left out; monitoring rules refer to their media by kind, title,
+// year and parent, and are skipped on restore when no such media exists.
+//
+// Secrets stay encrypted with the server master key: config secrets as they
+// are stored, and notifier settings, which hold tokens and webhook URLs, are
+// sealed on export. A backup can only be restored by a server with the same
+// master key, current or previous.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// Format identifies a Nimbus backup document
+	Format = "nimbus-backup"
+
+	// Version is the version of the backup document written. Restore accepts
+	// documents up to this version.
+	Version = 1
+)
+
+// Document is a backup of a server's configuration
+type Document struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Sections  Sections  `json:"sections"`
+}
+
+// Sections are the parts of a backup, in the order they are restored
+type Sections struct {
+	Tags               []string            `json:"tags"`
+	QualityDefinitions []QualityDefinition `json:"quality_definitions"`
+	QualityProfiles    []QualityProfile    `json:"quality_profiles"`
+	CustomFormats      []CustomFormat      `json:"custom_formats"`
+	RootFolders        []RootFolder        `json:"root_folders"`
+	Config             []ConfigEntry       `json:"config"`
+	Plugins            []PluginSettings    `json:"plugins"`
+	Notifiers          []Notifier          `json:"notifiers"`
+	MonitoringRules    []MonitoringRule    `json:"monitoring_rules"`
+}
+
+// ConfigEntry is a row of the config table. Secrets are kept encrypted.
+type ConfigEntry struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// QualityDefinition is a quality level, keyed by name
+type QualityDefinition struct {
+	Name           string   `json:"name"`
+	Title          string   `json:"title"`
+	Resolution     *int32   `json:"resolution,omitempty"`
+	Source         *string  `json:"source,omitempty"`
+	Modifier       *string  `json:"modifier,omitempty"`
+	MinSize        *int64   `json:"min_size,omitempty"`
+	MaxSize        *int64   `json:"max_size,omitempty"`
+	MinMBPerMinute *float64 `json:"min_mb_per_minute,omitempty"`
+	MaxMBPerMinute *float64 `json:"max_mb_per_minute,omitempty"`
+	Weight         int32    `json:"weight"`
+}
+
+// QualityProfile is a quality profile, keyed by name, with qualities referred
+// to by name
+type QualityProfile struct {
+	Name           string               `json:"name"`
+	Description    *string              `json:"description,omitempty"`
+	Cutoff         *string              `json:"cutoff,omitempty"`
+	UpgradeAllowed bool                 `json:"upgrade_allowed"`
+	Items          []QualityProfileItem `json:"items"`
+}
+
+// QualityProfileItem is a quality of a profile
+type QualityProfileItem struct {
+	Quality   string `json:"quality"`
+	Allowed   bool   `json:"allowed"`
+	SortOrder int32  `json:"sort_order"`
+}
+
+// CustomFormat is a custom format, keyed by name
+type CustomFormat struct {
+	Name      string   `json:"name"`
+	MatchType string   `json:"match_type"`
+	Field     string   `json:"field"`
+	Pattern   *string  `json:"pattern,omitempty"`
+	Terms     []string `json:"terms"`
+	Score     int32    `json:"score"`
+	Enabled   bool     `json:"enabled"`
+}
+
+// RootFolder is a library directory, keyed by path
+type RootFolder struct {
+	Path      string `json:"path"`
+	MediaKind string `json:"media_kind"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// PluginSettings are the settings of an installed plugin, keyed by ID
+type PluginSettings struct {
+	ID      string   `json:"id"`
+	Enabled bool     `json:"enabled"`
+	Tags    []string `json:"tags"`
+}
+
+// Notifier is a server-wide notifier, keyed by name and type. Its settings are
+// sealed with the master key.
+type Notifier struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Enabled  bool            `json:"enabled"`
+	Events   []string        `json:"events"`
+	Settings json.RawMessage `json:"settings"`
+	Tags     []string        `json:"tags"`
+}
+
+// MediaRef refers to a media item by the natural key of the media_items table
+type MediaRef struct {
+	Kind   string    `json:"kind"`
+	Title  string    `json:"title"`
+	Year   *int32    `json:"year,omitempty"`
+	Parent *MediaRef `json:"parent,omitempty"`
+}
+
+// String describes the media referred to, e.g. "Season 1 of Show (2020)"
+func (m *MediaRef) String() string {
+	s := m.Title
+	if m.Year != nil {
+		s = fmt.Sprintf("%s (%d)", s, *m.Year)
+	}
+	if m.Parent != nil {
+		s += " of " + m.Parent.String()
+	}
+	return s
+}
+
+// MonitoringRule is a monitoring rule, keyed by its media
+type MonitoringRule struct {
+	Media                 MediaRef `json:"media"`
+	Enabled               bool     `json:"enabled"`
+	QualityProfile        *string  `json:"quality_profile,omitempty"`
+	RootFolder            *string  `json:"root_folder,omitempty"`
+	MonitorMode           string   `json:"monitor_mode"`
+	SeriesType            string   `json:"series_type"`
+	SearchUpgrades        bool     `json:"search_upgrades"`
+	SearchOnAdd           bool     `json:"search_on_add"`
+	AutomaticSearch       bool     `json:"automatic_search"`
+	BacklogSearch         bool     `json:"backlog_search"`
+	PreferSeasonPacks     bool     `json:"prefer_season_packs"`
+	MinimumSeeders        *int32   `json:"minimum_seeders,omitempty"`
+	Tags                  []string `json:"tags"`
+	SearchIntervalMinutes *int32   `json:"search_interval_minutes,omitempty"`
+}
+
+// Service exports and restores backups
+type Service struct {
+	db          *pgxpool.Pool
+	configStore *configstore.Store
+	logger      *zap.Logger
+}
+
+// NewService creates a new backup service
+func NewService(db *pgxpool.Pool, configStore *configstore.Store, logger *zap.Logger) *Service {
+	return &Service{
+		db:          db,
+		configStore: configStore,
+		logger:      logger.With(zap.String("component", "backup")),
+	}
+}
+
+// Export builds a backup of the current configuration
+func (s *Service) Export(ctx context.Context) (*Document, error) {
+	doc := &Document{
+		Format:    Format,
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	exports := []struct {
+		name   string
+		export func(ctx context.Context, doc *Document) error
+	}{
+		{"tags", s.exportTags},
+		{"quality definitions", s.exportQualityDefinitions},
+		{"quality profiles", s.exportQualityProfiles},
+		{"custom formats", s.exportCustomFormats},
+		{"root folders", s.exportRootFolders},
+		{"config", s.exportConfig},
+		{"plugins", s.exportPlugins},
+		{"notifiers", s.exportNotifiers},
+		{"monitoring rules", s.exportMonitoringRules},
+	}
+	for _, e := range exports {
+		if err := e.export(ctx, doc); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", e.name, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func (s *Service) exportTags(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `SELECT name FROM tags ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.Tags, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	return err
+}
+
+func (s *Service) exportQualityDefinitions(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT name, title, resolution, source, modifier, min_size, max_size,
+		       min_mb_per_minute, max_mb_per_minute, weight
+		FROM quality_definitions
+		ORDER BY weight, name
+	`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.QualityDefinitions, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (QualityDefinition, error) {
+		var d QualityDefinition
+		err := row.Scan(&d.Name, &d.Title, &d.Resolution, &d.Source, &d.Modifier, &d.MinSize, &d.MaxSize,
+			&d.MinMBPerMinute, &d.MaxMBPerMinute, &d.Weight)
+		return d, err
+	})
+	return err
+}
+
+func (s *Service) exportQualityProfiles(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT p.name, p.description, cutoff.name, p.upgrade_allowed,
+		       COALESCE((
+		           SELECT jsonb_agg(jsonb_build_object('quality', d.name, 'allowed', i.allowed, 'sort_order', i.sort_order)
+		                            ORDER BY i.sort_order)
+		           FROM quality_profile_items i
+		           JOIN quality_definitions d ON d.id = i.quality_id
+		           WHERE i.profile_id = p.id
+		       ), '[]'::jsonb)
+		FROM quality_profiles p
+		LEFT JOIN quality_definitions cutoff ON cutoff.id = p.cutoff_quality_id
+		ORDER BY p.name
+	`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.QualityProfiles, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (QualityProfile, error) {
+		var p QualityProfile
+		var items []byte
+		if err := row.Scan(&p.Name, &p.Description, &p.Cutoff, &p.UpgradeAllowed, &items); err != nil {
+			return p, err
+		}
+		return p, json.Unmarshal(items, &p.Items)
+	})
+	return err
+}
+
+func (s *Service) exportCustomFormats(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT name, match_type, field, pattern, terms, score, enabled
+		FROM custom_formats
+		ORDER BY name
+	`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.CustomFormats, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (CustomFormat, error) {
+		var f CustomFormat
+		err := row.Scan(&f.Name, &f.MatchType, &f.Field, &f.Pattern, &f.Terms, &f.Score, &f.Enabled)
+		return f, err
+	})
+	return err
+}
+
+func (s *Service) exportRootFolders(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `SELECT path, media_kind, is_default FROM root_folders ORDER BY path`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.RootFolders, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (RootFolder, error) {
+		var f RootFolder
+		err := row.Scan(&f.Path, &f.MediaKind, &f.IsDefault)
+		return f, err
+	})
+	return err
+}
+
+func (s *Service) exportConfig(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `SELECT key, value, metadata FROM config ORDER BY key`)
+	if err != nil {
+		return err
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ConfigEntry, error) {
+		var e ConfigEntry
+		err := row.Scan(&e.Key, &e.Value, &e.Metadata)
+		return e, err
+	})
+	if err != nil {
+		return err
+	}
+
+	doc.Sections.Config = make([]ConfigEntry, 0, len(entries))
+	for _, e := range entries {
+		if configstore.IsStateKey(e.Key) {
+			continue
+		}
+		doc.Sections.Config = append(doc.Sections.Config, e)
+	}
+	return nil
+}
+
+func (s *Service) exportPlugins(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `SELECT id, enabled, tags FROM plugins ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	doc.Sections.Plugins, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (PluginSettings, error) {
+		var p PluginSettings
+		err := row.Scan(&p.ID, &p.Enabled, &p.Tags)
+		return p, err
+	})
+	return err
+}
+
+// exportNotifiers exports the server-wide notifiers. Personal notifiers belong
+// to users, which aren't backed up.
+func (s *Service) exportNotifiers(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT name, type, enabled, events, settings, tags
+		FROM notifiers
+		WHERE user_id IS NULL
+		ORDER BY name, id
+	`)
+	if err != nil {
+		return err
+	}
+	notifiers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Notifier, error) {
+		var n Notifier
+		err := row.Scan(&n.Name, &n.Type, &n.Enabled, &n.Events, &n.Settings, &n.Tags)
+		return n, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range notifiers {
+		sealed, err := s.configStore.Seal(notifiers[i].Settings)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt settings of notifier %s: %w", notifiers[i].Name, err)
+		}
+		notifiers[i].Settings = sealed
+	}
+	doc.Sections.Notifiers = notifiers
+	return nil
+}
+
+func (s *Service) exportMonitoringRules(ctx context.Context, doc *Document) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT mr.media_item_id, mr.enabled, qp.name, rf.path, mr.monitor_mode, mr.series_type,
+		       mr.search_upgrades, mr.search_on_add, mr.automatic_search, mr.backlog_search,
+		       mr.prefer_season_packs, mr.minimum_seeders, COALESCE(mr.tags, '{}'), mr.search_interval_minutes
+		FROM monitoring_rules mr
+		LEFT JOIN quality_profiles qp ON qp.id = mr.quality_profile_id
+		LEFT JOIN root_folders rf ON rf.id = mr.root_folder_id
+		ORDER BY mr.id
+	`)
+	if err != nil {
+		return err
+	}
+	type exportedRule struct {
+		mediaItemID int64
+		rule        MonitoringRule
+	}
+	exported, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (exportedRule, error) {
+		var e exportedRule
+		r := &e.rule
+		err := row.Scan(&e.mediaItemID, &r.Enabled, &r.QualityProfile, &r.RootFolder, &r.MonitorMode, &r.SeriesType,
+			&r.SearchUpgrades, &r.SearchOnAdd, &r.AutomaticSearch, &r.BacklogSearch,
+			&r.PreferSeasonPacks, &r.MinimumSeeders, &r.Tags, &r.SearchIntervalMinutes)
+		return e, err
+	})
+	if err != nil {
+		return err
+	}
+
+	refs := make(map[int64]*MediaRef)
+	doc.Sections.MonitoringRules = make([]MonitoringRule, 0, len(exported))
+	for _, e := range exported {
+		ref, err := s.mediaRef(ctx, e.mediaItemID, refs)
+		if err != nil {
+			return err
+		}
+		e.rule.Media = *ref
+		doc.Sections.MonitoringRules = append(doc.Sections.MonitoringRules, e.rule)
+	}
+	return nil
+}
+
+// mediaRef builds the reference to a media item and its parents, reusing the
+// references already built
+func (s *Service) mediaRef(ctx context.Context, id int64, refs map[int64]*MediaRef) (*MediaRef, error) {
+	if ref, ok := refs[id]; ok {
+		return ref, nil
+	}
+
+	ref := &MediaRef{}
+	var parentID *int64
+	if err := s.db.QueryRow(ctx, `SELECT kind, title, year, parent_id FROM media_items WHERE id = $1`, id).
+		Scan(&ref.Kind, &ref.Title, &ref.Year, &parentID); err != nil {
+		return nil, fmt.Errorf("failed to get media item %d: %w", id, err)
+	}
+	// Movies are found without their collection, like the natural key does
+	if parentID != nil && ref.Kind != "movie" {
+		parent, err := s.mediaRef(ctx, *parentID, refs)
+		if err != nil {
+			return nil, err
+		}
+		ref.Parent = parent
+	}
+
+	refs[id] = ref
+	return ref, nil
+}
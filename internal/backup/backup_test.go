@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+)
+
+func TestDocumentValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     Document
+		wantErr bool
+	}{
+		{"current version", Document{Format: Format, Version: Version}, false},
+		{"other format", Document{Format: "sonarr-backup", Version: 1}, true},
+		{"missing version", Document{Format: Format}, true},
+		{"newer version", Document{Format: Format, Version: Version + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.doc.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnsupportedBackup) {
+				t.Errorf("Validate() error = %v, want ErrUnsupportedBackup", err)
+			}
+		})
+	}
+}
+
+func TestMediaRefString(t *testing.T) {
+	year := int32(2008)
+	ref := MediaRef{
+		Kind:  "tv_season",
+		Title: "Season 2",
+		Parent: &MediaRef{
+			Kind:  "tv_series",
+			Title: "Breaking Bad",
+			Year:  &year,
+		},
+	}
+	if got, want := ref.String(), "Season 2 of Breaking Bad (2008)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSameItems(t *testing.T) {
+	current := []QualityProfileItem{
+		{Quality: "WEBDL-1080p", Allowed: true, SortOrder: 1},
+		{Quality: "HDTV-720p", Allowed: false, SortOrder: 2},
+	}
+
+	reordered := []QualityProfileItem{current[1], current[0]}
+	if !sameItems(current, reordered) {
+		t.Error("sameItems() = false for the same items listed in another order")
+	}
+
+	changed := slices.Clone(current)
+	changed[1].Allowed = true
+	if sameItems(current, changed) {
+		t.Error("sameItems() = true for an item allowed in the backup only")
+	}
+	if sameItems(current, current[:1]) {
+		t.Error("sameItems() = true for a backup with fewer items")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	if got, want := FileName(at), "nimbus-backup-20260304-050607.json"; got != want {
+		t.Errorf("FileName() = %q, want %q", got, want)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 5 {
+		name := FileName(start.Add(time.Duration(i) * 24 * time.Hour))
+		names = append(names, name)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Files that aren't backups are never pruned
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := pruneBackups(dir, 2)
+	if err != nil {
+		t.Fatalf("pruneBackups() error = %v", err)
+	}
+	if !slices.Equal(removed, names[:3]) {
+		t.Errorf("pruneBackups() removed %v, want %v", removed, names[:3])
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := []string{names[3], names[4], "notes.txt"}
+	if !slices.Equal(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
+
+func TestSealedSettingsRoundTrip(t *testing.T) {
+	store := configstore.New(nil)
+	if _, err := store.Seal(map[string]string{"url": "https://example.com"}); !errors.Is(err, configstore.ErrNoKeyring) {
+		t.Fatalf("Seal() without a keyring error = %v, want ErrNoKeyring", err)
+	}
+
+	keyring, err := configstore.NewKeyring("current-master-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetKeyring(keyring)
+
+	settings := json.RawMessage(`{"webhook_url":"https://discord.example/hook"}`)
+	sealed, err := store.Seal(settings)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !configstore.IsSecret(sealed) {
+		t.Fatalf("Seal() = %s, want a secret", sealed)
+	}
+
+	opened, err := store.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(opened) != string(settings) {
+		t.Errorf("Open() = %s, want %s", opened, settings)
+	}
+
+	// A backup from a server with another master key can't be restored
+	other, err := configstore.NewKeyring("other-master-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetKeyring(other)
+	if _, err := store.Open(sealed); err == nil {
+		t.Error("Open() with another master key succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// maxRestoreSize limits the size of an uploaded backup
+const maxRestoreSize = 32 << 20
+
+// Handler handles backup HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new backup handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "backup-handler")),
+	}
+}
+
+// SetupRoutes registers the backup routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/system/backup", h.GetBackup)
+	r.Post("/system/restore", h.Restore)
+}
+
+// GetBackup downloads a backup of the current configuration
+func (h *Handler) GetBackup(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.service.Export(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to export backup", zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create backup")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="`+FileName(doc.CreatedAt)+`"`)
+	httputil.RespondJSON(w, http.StatusOK, doc)
+}
+
+// Restore applies an uploaded backup. Restoring while downloads are active is
+// refused unless force is set, and dry_run reports without applying anything.
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	var doc Document
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRestoreSize)).Decode(&doc); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid backup")
+		return
+	}
+
+	opts := RestoreOptions{
+		Force:  r.URL.Query().Get("force") == "true",
+		DryRun: r.URL.Query().Get("dry_run") == "true",
+	}
+	result, err := h.service.Restore(r.Context(), &doc, opts)
+	switch {
+	case errors.Is(err, ErrUnsupportedBackup):
+		httputil.RespondError(w, http.StatusBadRequest, err, "Unsupported backup")
+	case errors.Is(err, ErrDownloadsActive):
+		httputil.RespondError(w, http.StatusConflict, err, "Downloads are active; wait for them to finish or restore with force=true")
+	case err != nil:
+		h.logger.Error("Failed to restore backup", zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to restore backup")
+	default:
+		httputil.RespondJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,588 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrUnsupportedBackup is returned when a document isn't a backup this
+	// version of Nimbus can restore
+	ErrUnsupportedBackup = errors.New("unsupported backup")
+
+	// ErrDownloadsActive is returned when a restore is attempted while
+	// downloads are in progress and it isn't forced
+	ErrDownloadsActive = errors.New("downloads are active")
+
+	// errNotFound skips an entry that refers to something missing on this
+	// server, like the media of a monitoring rule
+	errNotFound = errors.New("not found")
+)
+
+// RestoreOptions control how a backup is restored
+type RestoreOptions struct {
+	Force  bool // Restore even while downloads are active
+	DryRun bool // Apply everything, report, then roll back
+}
+
+// SectionResult counts what happened to the entries of a backup section.
+// Applied entries were created or changed, skipped ones were already up to date
+// or refer to something missing, and failed ones were rolled back.
+type SectionResult struct {
+	Name    string   `json:"name"`
+	Applied int      `json:"applied"`
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+	Notes   []string `json:"notes,omitempty"`
+}
+
+// RestoreResult is the outcome of a restore, per section in restore order
+type RestoreResult struct {
+	DryRun   bool            `json:"dry_run"`
+	Sections []SectionResult `json:"sections"`
+}
+
+// Validate checks that a document is a backup that can be restored
+func (d *Document) Validate() error {
+	if d.Format != Format {
+		return fmt.Errorf("%w: format is %q, not %q", ErrUnsupportedBackup, d.Format, Format)
+	}
+	if d.Version < 1 || d.Version > Version {
+		return fmt.Errorf("%w: version %d, this server restores versions 1 to %d", ErrUnsupportedBackup, d.Version, Version)
+	}
+	return nil
+}
+
+// Restore applies a backup in one transaction. Entries are upserted by their
+// natural keys and are applied or fail one by one, so a bad entry doesn't undo
+// the rest. Nothing in the current configuration is deleted.
+func (s *Service) Restore(ctx context.Context, doc *Document, opts RestoreOptions) (*RestoreResult, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !opts.Force {
+		var active int
+		if err := s.db.QueryRow(ctx, `
+			SELECT COUNT(*) FROM downloads
+			WHERE status IN ('queued', 'downloading', 'processing')
+		`).Scan(&active); err != nil {
+			return nil, fmt.Errorf("failed to count active downloads: %w", err)
+		}
+		if active > 0 {
+			return nil, fmt.Errorf("%w: %d in progress", ErrDownloadsActive, active)
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	r := &restorer{Service: s, tx: tx}
+	result := &RestoreResult{DryRun: opts.DryRun}
+	sections := []struct {
+		name    string
+		restore func(ctx context.Context, res *SectionResult)
+	}{
+		{"tags", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.Tags, func(t string) string { return t }, r.tag)
+		}},
+		{"quality_definitions", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.QualityDefinitions, func(d QualityDefinition) string { return d.Name }, r.qualityDefinition)
+		}},
+		{"quality_profiles", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.QualityProfiles, func(p QualityProfile) string { return p.Name }, r.qualityProfile)
+		}},
+		{"custom_formats", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.CustomFormats, func(f CustomFormat) string { return f.Name }, r.customFormat)
+		}},
+		{"root_folders", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.RootFolders, func(f RootFolder) string { return f.Path }, r.rootFolder)
+		}},
+		{"config", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.Config, func(e ConfigEntry) string { return e.Key }, r.config)
+		}},
+		{"plugins", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.Plugins, func(p PluginSettings) string { return p.ID }, r.plugin)
+		}},
+		{"notifiers", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.Notifiers, func(n Notifier) string { return n.Name + " (" + n.Type + ")" }, r.notifier)
+		}},
+		{"monitoring_rules", func(ctx context.Context, res *SectionResult) {
+			restoreEach(ctx, r, res, doc.Sections.MonitoringRules, func(m MonitoringRule) string { return m.Media.String() }, r.monitoringRule)
+		}},
+	}
+	for _, section := range sections {
+		res := SectionResult{Name: section.name}
+		section.restore(ctx, &res)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.Sections = append(result.Sections, res)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	s.logger.Info("Restored backup",
+		zap.Time("created_at", doc.CreatedAt),
+		zap.Any("sections", result.Sections))
+	return result, nil
+}
+
+// restorer applies the entries of a backup within the restore transaction
+type restorer struct {
+	*Service
+	tx pgx.Tx
+}
+
+// restoreEach applies every entry of a section in a savepoint of its own,
+// naming failed entries by their label. apply reports whether the entry changed
+// anything.
+func restoreEach[E any](ctx context.Context, r *restorer, res *SectionResult, entries []E, label func(E) string, apply func(ctx context.Context, tx pgx.Tx, e E) (bool, error)) {
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		changed, err := r.savepoint(ctx, func(tx pgx.Tx) (bool, error) { return apply(ctx, tx, e) })
+		switch {
+		case errors.Is(err, errNotFound):
+			res.Skipped++
+			res.Notes = append(res.Notes, fmt.Sprintf("%s: %v", label(e), err))
+		case err != nil:
+			res.Failed++
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", label(e), err))
+		case changed:
+			res.Applied++
+		default:
+			res.Skipped++
+		}
+	}
+}
+
+// savepoint runs fn in a savepoint that is rolled back when it fails
+func (r *restorer) savepoint(ctx context.Context, fn func(tx pgx.Tx) (bool, error)) (bool, error) {
+	sp, err := r.tx.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer sp.Rollback(ctx)
+
+	changed, err := fn(sp)
+	if err != nil {
+		return false, err
+	}
+	return changed, sp.Commit(ctx)
+}
+
+func (r *restorer) tag(ctx context.Context, tx pgx.Tx, name string) (bool, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return false, errors.New("tag name is empty")
+	}
+	result, err := tx.Exec(ctx, `INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, normalized)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *restorer) qualityDefinition(ctx context.Context, tx pgx.Tx, d QualityDefinition) (bool, error) {
+	result, err := tx.Exec(ctx, `
+		INSERT INTO quality_definitions (name, title, resolution, source, modifier, min_size, max_size,
+		                                 min_mb_per_minute, max_mb_per_minute, weight)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (name) DO UPDATE
+		SET title = EXCLUDED.title,
+		    resolution = EXCLUDED.resolution,
+		    source = EXCLUDED.source,
+		    modifier = EXCLUDED.modifier,
+		    min_size = EXCLUDED.min_size,
+		    max_size = EXCLUDED.max_size,
+		    min_mb_per_minute = EXCLUDED.min_mb_per_minute,
+		    max_mb_per_minute = EXCLUDED.max_mb_per_minute,
+		    weight = EXCLUDED.weight,
+		    updated_at = NOW()
+		WHERE (quality_definitions.title, quality_definitions.resolution, quality_definitions.source,
+		       quality_definitions.modifier, quality_definitions.min_size, quality_definitions.max_size,
+		       quality_definitions.min_mb_per_minute, quality_definitions.max_mb_per_minute, quality_definitions.weight)
+		      IS DISTINCT FROM
+		      (EXCLUDED.title, EXCLUDED.resolution, EXCLUDED.source, EXCLUDED.modifier, EXCLUDED.min_size,
+		       EXCLUDED.max_size, EXCLUDED.min_mb_per_minute, EXCLUDED.max_mb_per_minute, EXCLUDED.weight)
+	`, d.Name, d.Title, d.Resolution, d.Source, d.Modifier, d.MinSize, d.MaxSize,
+		d.MinMBPerMinute, d.MaxMBPerMinute, d.Weight)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// qualityProfile upserts a profile and replaces its qualities, which must all
+// be defined
+func (r *restorer) qualityProfile(ctx context.Context, tx pgx.Tx, p QualityProfile) (bool, error) {
+	var cutoffID *int32
+	if p.Cutoff != nil {
+		id, err := qualityID(ctx, tx, *p.Cutoff)
+		if err != nil {
+			return false, fmt.Errorf("cutoff: %w", err)
+		}
+		cutoffID = &id
+	}
+
+	var profileID int32
+	changed := true
+	err := tx.QueryRow(ctx, `
+		INSERT INTO quality_profiles (name, description, cutoff_quality_id, upgrade_allowed)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE
+		SET description = EXCLUDED.description,
+		    cutoff_quality_id = EXCLUDED.cutoff_quality_id,
+		    upgrade_allowed = EXCLUDED.upgrade_allowed,
+		    updated_at = NOW()
+		WHERE (quality_profiles.description, quality_profiles.cutoff_quality_id, quality_profiles.upgrade_allowed)
+		      IS DISTINCT FROM (EXCLUDED.description, EXCLUDED.cutoff_quality_id, EXCLUDED.upgrade_allowed)
+		RETURNING id
+	`, p.Name, p.Description, cutoffID, p.UpgradeAllowed).Scan(&profileID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unchanged, so the upsert returned nothing
+		changed = false
+		err = tx.QueryRow(ctx, `SELECT id FROM quality_profiles WHERE name = $1`, p.Name).Scan(&profileID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT d.name, i.allowed, i.sort_order
+		FROM quality_profile_items i
+		JOIN quality_definitions d ON d.id = i.quality_id
+		WHERE i.profile_id = $1
+		ORDER BY i.sort_order, d.name
+	`, profileID)
+	if err != nil {
+		return false, err
+	}
+	current, err := pgx.CollectRows(rows, pgx.RowToStructByPos[QualityProfileItem])
+	if err != nil {
+		return false, err
+	}
+	if sameItems(current, p.Items) {
+		return changed, nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM quality_profile_items WHERE profile_id = $1`, profileID); err != nil {
+		return false, err
+	}
+	for _, item := range p.Items {
+		qualityID, err := qualityID(ctx, tx, item.Quality)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO quality_profile_items (profile_id, quality_id, allowed, sort_order)
+			VALUES ($1, $2, $3, $4)
+		`, profileID, qualityID, item.Allowed, item.SortOrder); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// sameItems reports whether a profile already has the qualities of a backup,
+// with the same settings and sort order
+func sameItems(current, restored []QualityProfileItem) bool {
+	if len(current) != len(restored) {
+		return false
+	}
+	byName := make(map[string]QualityProfileItem, len(current))
+	for _, item := range current {
+		byName[item.Quality] = item
+	}
+	for _, item := range restored {
+		if byName[item.Quality] != item {
+			return false
+		}
+	}
+	return true
+}
+
+// qualityID looks up a quality definition by name
+func qualityID(ctx context.Context, tx pgx.Tx, name string) (int32, error) {
+	var id int32
+	err := tx.QueryRow(ctx, `SELECT id FROM quality_definitions WHERE name = $1`, name).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("quality %s is not defined", name)
+	}
+	return id, err
+}
+
+func (r *restorer) customFormat(ctx context.Context, tx pgx.Tx, f CustomFormat) (bool, error) {
+	if f.Terms == nil {
+		f.Terms = []string{}
+	}
+	result, err := tx.Exec(ctx, `
+		INSERT INTO custom_formats (name, match_type, field, pattern, terms, score, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (name) DO UPDATE
+		SET match_type = EXCLUDED.match_type,
+		    field = EXCLUDED.field,
+		    pattern = EXCLUDED.pattern,
+		    terms = EXCLUDED.terms,
+		    score = EXCLUDED.score,
+		    enabled = EXCLUDED.enabled,
+		    updated_at = NOW()
+		WHERE (custom_formats.match_type, custom_formats.field, custom_formats.pattern,
+		       custom_formats.terms, custom_formats.score, custom_formats.enabled)
+		      IS DISTINCT FROM
+		      (EXCLUDED.match_type, EXCLUDED.field, EXCLUDED.pattern, EXCLUDED.terms, EXCLUDED.score, EXCLUDED.enabled)
+	`, f.Name, f.MatchType, f.Field, f.Pattern, f.Terms, f.Score, f.Enabled)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// rootFolder upserts a root folder. A restored default replaces the current
+// default of its kind.
+func (r *restorer) rootFolder(ctx context.Context, tx pgx.Tx, f RootFolder) (bool, error) {
+	if f.IsDefault {
+		if _, err := tx.Exec(ctx, `
+			UPDATE root_folders SET is_default = false, updated_at = NOW()
+			WHERE media_kind = $1 AND is_default AND path <> $2
+		`, f.MediaKind, f.Path); err != nil {
+			return false, err
+		}
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO root_folders (path, media_kind, is_default)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (path) DO UPDATE
+		SET media_kind = EXCLUDED.media_kind,
+		    is_default = EXCLUDED.is_default,
+		    updated_at = NOW()
+		WHERE (root_folders.media_kind, root_folders.is_default) IS DISTINCT FROM (EXCLUDED.media_kind, EXCLUDED.is_default)
+	`, f.Path, f.MediaKind, f.IsDefault)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// config upserts a config value. Secrets are restored as they were exported,
+// once they are known to decrypt with this server's master key.
+func (r *restorer) config(ctx context.Context, tx pgx.Tx, e ConfigEntry) (bool, error) {
+	if configstore.IsStateKey(e.Key) {
+		return false, nil
+	}
+	if !json.Valid(e.Value) {
+		return false, errors.New("value is not valid JSON")
+	}
+	if configstore.IsSecret(e.Value) {
+		if _, err := r.configStore.Open(e.Value); err != nil {
+			return false, fmt.Errorf("secret cannot be decrypted: %w", err)
+		}
+	}
+
+	metadata := e.Metadata
+	if len(metadata) == 0 {
+		metadata = json.RawMessage(`{}`)
+	}
+	result, err := tx.Exec(ctx, `
+		INSERT INTO config (key, value, metadata)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value,
+		    metadata = EXCLUDED.metadata,
+		    updated_at = NOW()
+		WHERE (config.value, config.metadata) IS DISTINCT FROM (EXCLUDED.value, EXCLUDED.metadata)
+	`, e.Key, e.Value, metadata)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// plugin restores the settings of an installed plugin. Plugins aren't
+// installed by a restore, and changes take effect when they are next loaded.
+func (r *restorer) plugin(ctx context.Context, tx pgx.Tx, p PluginSettings) (bool, error) {
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM plugins WHERE id = $1)`, p.ID).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, fmt.Errorf("plugin is not installed: %w", errNotFound)
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE plugins
+		SET enabled = $2, tags = $3, updated_at = NOW()
+		WHERE id = $1 AND (enabled, tags) IS DISTINCT FROM ($2, $3::text[])
+	`, p.ID, p.Enabled, p.Tags)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// notifier upserts a server-wide notifier, matched by name and type
+func (r *restorer) notifier(ctx context.Context, tx pgx.Tx, n Notifier) (bool, error) {
+	settings, err := r.configStore.Open(n.Settings)
+	if err != nil {
+		return false, fmt.Errorf("settings cannot be decrypted: %w", err)
+	}
+	if n.Events == nil {
+		n.Events = []string{}
+	}
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE notifiers
+		SET enabled = $3, events = $4, settings = $5, tags = $6, updated_at = NOW()
+		WHERE id = (
+		    SELECT id FROM notifiers
+		    WHERE user_id IS NULL AND name = $1 AND type = $2
+		    ORDER BY id
+		    LIMIT 1
+		)
+		AND (enabled, events, settings, tags) IS DISTINCT FROM ($3, $4::text[], $5::jsonb, $6::text[])
+	`, n.Name, n.Type, n.Enabled, n.Events, settings, n.Tags)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	result, err = tx.Exec(ctx, `
+		INSERT INTO notifiers (name, type, enabled, events, settings, tags)
+		SELECT $1::text, $2::text, $3::boolean, $4::text[], $5::jsonb, $6::text[]
+		WHERE NOT EXISTS (
+		    SELECT 1 FROM notifiers WHERE user_id IS NULL AND name = $1::text AND type = $2::text
+		)
+	`, n.Name, n.Type, n.Enabled, n.Events, settings, n.Tags)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// monitoringRule upserts the rule of a media item that exists on this server
+func (r *restorer) monitoringRule(ctx context.Context, tx pgx.Tx, m MonitoringRule) (bool, error) {
+	mediaID, err := resolveMedia(ctx, tx, &m.Media)
+	if err != nil {
+		return false, err
+	}
+
+	var profileID *int32
+	if m.QualityProfile != nil {
+		var id int32
+		err := tx.QueryRow(ctx, `SELECT id FROM quality_profiles WHERE name = $1`, *m.QualityProfile).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, fmt.Errorf("quality profile %s does not exist", *m.QualityProfile)
+		}
+		if err != nil {
+			return false, err
+		}
+		profileID = &id
+	}
+
+	var rootFolderID *int64
+	if m.RootFolder != nil {
+		var id int64
+		err := tx.QueryRow(ctx, `SELECT id FROM root_folders WHERE path = $1`, *m.RootFolder).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, fmt.Errorf("root folder %s does not exist", *m.RootFolder)
+		}
+		if err != nil {
+			return false, err
+		}
+		rootFolderID = &id
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO monitoring_rules (
+		    media_item_id, enabled, quality_profile_id, root_folder_id, monitor_mode, series_type,
+		    search_upgrades, search_on_add, automatic_search, backlog_search, prefer_season_packs,
+		    minimum_seeders, tags, search_interval_minutes
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (media_item_id) DO UPDATE
+		SET enabled = EXCLUDED.enabled,
+		    quality_profile_id = EXCLUDED.quality_profile_id,
+		    root_folder_id = EXCLUDED.root_folder_id,
+		    monitor_mode = EXCLUDED.monitor_mode,
+		    series_type = EXCLUDED.series_type,
+		    search_upgrades = EXCLUDED.search_upgrades,
+		    search_on_add = EXCLUDED.search_on_add,
+		    automatic_search = EXCLUDED.automatic_search,
+		    backlog_search = EXCLUDED.backlog_search,
+		    prefer_season_packs = EXCLUDED.prefer_season_packs,
+		    minimum_seeders = EXCLUDED.minimum_seeders,
+		    tags = EXCLUDED.tags,
+		    search_interval_minutes = EXCLUDED.search_interval_minutes,
+		    updated_at = NOW()
+		WHERE (monitoring_rules.enabled, monitoring_rules.quality_profile_id, monitoring_rules.root_folder_id,
+		       monitoring_rules.monitor_mode, monitoring_rules.series_type, monitoring_rules.search_upgrades,
+		       monitoring_rules.search_on_add, monitoring_rules.automatic_search, monitoring_rules.backlog_search,
+		       monitoring_rules.prefer_season_packs, monitoring_rules.minimum_seeders, monitoring_rules.tags,
+		       monitoring_rules.search_interval_minutes)
+		      IS DISTINCT FROM
+		      (EXCLUDED.enabled, EXCLUDED.quality_profile_id, EXCLUDED.root_folder_id, EXCLUDED.monitor_mode,
+		       EXCLUDED.series_type, EXCLUDED.search_upgrades, EXCLUDED.search_on_add, EXCLUDED.automatic_search,
+		       EXCLUDED.backlog_search, EXCLUDED.prefer_season_packs, EXCLUDED.minimum_seeders, EXCLUDED.tags,
+		       EXCLUDED.search_interval_minutes)
+	`, mediaID, m.Enabled, profileID, rootFolderID, m.MonitorMode, m.SeriesType,
+		m.SearchUpgrades, m.SearchOnAdd, m.AutomaticSearch, m.BacklogSearch, m.PreferSeasonPacks,
+		m.MinimumSeeders, m.Tags, m.SearchIntervalMinutes)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// resolveMedia finds the media item a reference points to, through its parents
+func resolveMedia(ctx context.Context, tx pgx.Tx, ref *MediaRef) (int64, error) {
+	var parentID *int64
+	if ref.Parent != nil {
+		id, err := resolveMedia(ctx, tx, ref.Parent)
+		if err != nil {
+			return 0, err
+		}
+		parentID = &id
+	}
+
+	var id int64
+	err := tx.QueryRow(ctx, `
+		SELECT id FROM media_items
+		WHERE kind = $1 AND title = $2
+		  AND COALESCE(year, -1) = COALESCE($3::INTEGER, -1)
+		  AND (kind = 'movie' OR COALESCE(parent_id, -1) = COALESCE($4::BIGINT, -1))
+	`, ref.Kind, ref.Title, ref.Year, parentID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("media %s is not in the library: %w", ref, errNotFound)
+	}
+	return id, err
+}
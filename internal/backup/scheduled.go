@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// filePrefix and fileSuffix name backup files, so that pruning only
+	// touches files written by Nimbus
+	filePrefix = "nimbus-backup-"
+	fileSuffix = ".json"
+)
+
+// FileName is the name of a backup created at a time
+func FileName(at time.Time) string {
+	return filePrefix + at.UTC().Format("20060102-150405") + fileSuffix
+}
+
+// WriteBackup exports a backup into a directory and keeps only the newest keep
+// backups there. Backups hold encrypted secrets, but are still only readable by
+// the server's user. keep below 1 keeps every backup.
+func (s *Service) WriteBackup(ctx context.Context, dir string, keep int) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("backup directory is not configured")
+	}
+
+	doc, err := s.Export(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Written to a temporary file first, so an interrupted backup never looks
+	// like a complete one
+	path := filepath.Join(dir, FileName(doc.CreatedAt))
+	tmp, err := os.CreateTemp(dir, ".nimbus-backup-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	s.logger.Info("Wrote backup", zap.String("path", path))
+
+	if keep > 0 {
+		removed, err := pruneBackups(dir, keep)
+		if err != nil {
+			s.logger.Warn("Failed to prune old backups", zap.String("dir", dir), zap.Error(err))
+		} else if len(removed) > 0 {
+			s.logger.Info("Pruned old backups", zap.Strings("removed", removed))
+		}
+	}
+
+	return path, nil
+}
+
+// pruneBackups removes all but the newest keep backups from a directory and
+// returns the names of those removed. Backup names sort by creation time.
+func pruneBackups(dir string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) <= keep {
+		return nil, nil
+	}
+
+	sort.Strings(names)
+	var removed []string
+	for _, name := range names[:len(names)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,552 @@
+// Package collections groups movies into their TMDB collections.
+//
+// When a movie is matched on TMDB and belongs to a collection, the TMDB plugin
+// stores the collection in the movie's metadata under "collection". The
+// service then finds or creates a movie_collection media item for it, keyed by
+// its TMDB collection ID, keeps its poster, description and list of movies up
+// to date, and makes it the movie's parent. The movies of a collection that
+// aren't in the library are only known from that list, and can be added and
+// monitored all at once.
+package collections
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/requests"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// externalIDKey is the external ID a collection is found by
+const externalIDKey = "tmdb_collection"
+
+// collectionSource says a collection added a media item
+const collectionSource = "collection"
+
+// Member statuses
+const (
+	StatusHaveFile  = "have_file" // In the library with a file
+	StatusMonitored = "monitored" // Monitored but without a file yet
+	StatusMissing   = "missing"   // Not in the library, or neither monitored nor with a file
+)
+
+var (
+	// ErrNotFound is returned when a collection doesn't exist
+	ErrNotFound = errors.New("collection not found")
+
+	// ErrQualityProfileNotFound is returned when monitoring with a quality
+	// profile that doesn't exist
+	ErrQualityProfileNotFound = errors.New("quality profile not found")
+
+	// ErrRootFolderNotFound is returned when monitoring with a root folder
+	// that doesn't exist
+	ErrRootFolderNotFound = errors.New("root folder not found")
+
+	// ErrMonitoringUnavailable is returned when monitoring without the
+	// monitoring service
+	ErrMonitoringUnavailable = errors.New("monitoring is not available")
+)
+
+// Collection is a TMDB collection of movies
+type Collection struct {
+	ID          int64     `json:"id"`
+	TMDBID      string    `json:"tmdb_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	PosterURL   string    `json:"poster_url,omitempty"`
+	BackdropURL string    `json:"backdrop_url,omitempty"`
+	Total       int       `json:"total"`
+	HaveFile    int       `json:"have_file"`
+	Monitored   int       `json:"monitored"`
+	Missing     int       `json:"missing"`
+	Members     []Member  `json:"members,omitempty"` // Only when getting a single collection
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Member is a movie of a collection
+type Member struct {
+	TMDBID      string `json:"tmdb_id,omitempty"`
+	Title       string `json:"title"`
+	Year        *int32 `json:"year,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	PosterURL   string `json:"poster_url,omitempty"`
+	MediaItemID *int64 `json:"media_item_id,omitempty"` // Nil when the movie isn't in the library
+	Status      string `json:"status"`
+}
+
+// MonitorParams are the settings the missing movies of a collection are
+// monitored with. Settings left out are taken from the template or, without
+// one, from the movie monitoring defaults.
+type MonitorParams struct {
+	TemplateID       *int64 `json:"template_id,omitempty"`
+	QualityProfileID *int   `json:"quality_profile_id,omitempty"`
+	RootFolderID     *int64 `json:"root_folder_id,omitempty"`
+	SearchOnAdd      *bool  `json:"search_on_add,omitempty"`
+}
+
+// MonitorResult is what monitoring a collection did with its missing movies
+type MonitorResult struct {
+	Monitored []Member        `json:"monitored"`
+	Failed    []MonitorFailed `json:"failed"`
+}
+
+// MonitorFailed is a missing movie that couldn't be added or monitored
+type MonitorFailed struct {
+	Member
+	Error string `json:"error"`
+}
+
+// Service groups movies into collections and monitors their missing movies
+type Service struct {
+	db         *pgxpool.Pool
+	queries    *generated.Queries
+	titles     *requests.Service
+	monitoring *monitoring.Service      // nil until SetMonitoring
+	searcher   *monitoring.AutoSearcher // nil when plugins are unavailable
+	logger     *zap.Logger
+}
+
+// NewService creates a new collection service. Missing movies are added to
+// the library the way approved requests are.
+func NewService(db *pgxpool.Pool, queries *generated.Queries, titles *requests.Service, logger *zap.Logger) *Service {
+	return &Service{
+		db:      db,
+		queries: queries,
+		titles:  titles,
+		logger:  logger.With(zap.String("component", "collections")),
+	}
+}
+
+// SetMonitoring enables monitoring the missing movies of collections, and the
+// initial search for them when searcher isn't nil
+func (s *Service) SetMonitoring(monitoringSvc *monitoring.Service, searcher *monitoring.AutoSearcher) {
+	s.monitoring = monitoringSvc
+	s.searcher = searcher
+}
+
+// MetadataUpdated attaches a movie to the collection in its metadata, creating
+// the collection when it isn't in the library yet. A movie whose metadata has
+// no collection is detached from the one it was in. A collection in the
+// recycle bin isn't brought back by its movies.
+func (s *Service) MetadataUpdated(ctx context.Context, item generated.MediaItem) error {
+	if item.Kind != string(media.MediaKindMovie) {
+		return nil
+	}
+
+	var metadata struct {
+		Collection *collectionMetadata `json:"collection"`
+	}
+	if err := json.Unmarshal(item.Metadata, &metadata); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	if metadata.Collection == nil || metadata.Collection.TMDBID == "" {
+		if item.ParentID == nil {
+			return nil
+		}
+		return s.queries.SetMediaItemParent(ctx, generated.SetMediaItemParentParams{ID: item.ID})
+	}
+
+	collectionID, err := s.ensureCollection(ctx, metadata.Collection)
+	if err != nil || collectionID == 0 {
+		return err
+	}
+	if item.ParentID != nil && *item.ParentID == collectionID {
+		return nil
+	}
+	if err := s.queries.SetMediaItemParent(ctx, generated.SetMediaItemParentParams{ID: item.ID, ParentID: &collectionID}); err != nil {
+		return fmt.Errorf("failed to attach movie to collection: %w", err)
+	}
+	s.logger.Debug("attached movie to collection",
+		zap.Int64("media_item_id", item.ID),
+		zap.Int64("collection_id", collectionID),
+		zap.String("collection", metadata.Collection.Name))
+	return nil
+}
+
+// collectionMetadata is the collection of a movie's metadata, as stored by the
+// TMDB plugin
+type collectionMetadata struct {
+	TMDBID      string                   `json:"tmdb_id"`
+	Name        string                   `json:"name"`
+	Overview    string                   `json:"overview"`
+	PosterURL   string                   `json:"poster_url"`
+	BackdropURL string                   `json:"backdrop_url"`
+	Parts       []map[string]interface{} `json:"parts"`
+}
+
+// ensureCollection returns the ID of a collection's media item, creating it or
+// updating its metadata. It returns 0 when the collection is in the recycle bin.
+func (s *Service) ensureCollection(ctx context.Context, c *collectionMetadata) (int64, error) {
+	var id int64
+	var deletedAt *time.Time
+	err := s.db.QueryRow(ctx, `
+		SELECT id, deleted_at FROM media_items
+		WHERE kind = $1 AND external_ids->>$2 = $3
+		ORDER BY deleted_at NULLS FIRST, id
+		LIMIT 1
+	`, string(media.MediaKindMovieCollection), externalIDKey, c.TMDBID).Scan(&id, &deletedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("failed to look up collection: %w", err)
+	}
+	if deletedAt != nil {
+		return 0, nil
+	}
+
+	patch := map[string]interface{}{"tmdb_id": c.TMDBID}
+	if c.Overview != "" {
+		patch["description"] = c.Overview
+	}
+	if c.PosterURL != "" {
+		patch["poster_url"] = c.PosterURL
+	}
+	if c.BackdropURL != "" {
+		patch["backdrop_url"] = c.BackdropURL
+	}
+	// The movies are only listed when the plugin could fetch the collection
+	if c.Parts != nil {
+		patch["parts"] = c.Parts
+	}
+	metadataJSON, err := json.Marshal(patch)
+	if err != nil {
+		return 0, err
+	}
+
+	if id != 0 {
+		if _, err := s.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{ID: id, Metadata: metadataJSON}); err != nil {
+			return 0, fmt.Errorf("failed to update collection: %w", err)
+		}
+		return id, nil
+	}
+
+	name := c.Name
+	if name == "" {
+		name = "Collection " + c.TMDBID
+	}
+	externalIDs, _ := json.Marshal(map[string]interface{}{externalIDKey: c.TMDBID})
+	created, err := s.queries.UpsertMediaItem(ctx, generated.UpsertMediaItemParams{
+		Kind:        string(media.MediaKindMovieCollection),
+		Title:       name,
+		SortTitle:   name,
+		ExternalIds: externalIDs,
+		Metadata:    metadataJSON,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create collection: %w", err)
+	}
+	s.logger.Info("created collection", zap.Int64("collection_id", created.ID), zap.String("title", name))
+	return created.ID, nil
+}
+
+// List returns all collections with the number of their movies in each status
+func (s *Service) List(ctx context.Context) ([]Collection, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, title, metadata, updated_at FROM media_items
+		WHERE kind = $1 AND deleted_at IS NULL
+		ORDER BY sort_title, id
+	`, string(media.MediaKindMovieCollection))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (generated.MediaItem, error) {
+		var item generated.MediaItem
+		err := row.Scan(&item.ID, &item.Title, &item.Metadata, &item.UpdatedAt)
+		return item, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	collections := make([]Collection, 0, len(items))
+	for _, item := range items {
+		c, err := s.load(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		c.Members = nil
+		collections = append(collections, *c)
+	}
+	return collections, nil
+}
+
+// Get returns a collection with its movies
+func (s *Service) Get(ctx context.Context, id int64) (*Collection, error) {
+	item, err := s.queries.GetMediaItem(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if item.Kind != string(media.MediaKindMovieCollection) || item.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return s.load(ctx, item)
+}
+
+// load returns a collection and the status of its movies
+func (s *Service) load(ctx context.Context, item generated.MediaItem) (*Collection, error) {
+	var metadata struct {
+		TMDBID      string     `json:"tmdb_id"`
+		Description string     `json:"description"`
+		PosterURL   string     `json:"poster_url"`
+		BackdropURL string     `json:"backdrop_url"`
+		Parts       []tmdbPart `json:"parts"`
+	}
+	_ = json.Unmarshal(item.Metadata, &metadata)
+
+	tmdbIDs := make([]string, 0, len(metadata.Parts))
+	for _, part := range metadata.Parts {
+		tmdbIDs = append(tmdbIDs, part.TMDBID)
+	}
+	movies, err := s.libraryMovies(ctx, item.ID, tmdbIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collection{
+		ID:          item.ID,
+		TMDBID:      metadata.TMDBID,
+		Title:       item.Title,
+		Description: metadata.Description,
+		PosterURL:   metadata.PosterURL,
+		BackdropURL: metadata.BackdropURL,
+		Members:     members(metadata.Parts, movies),
+		UpdatedAt:   item.UpdatedAt.Time,
+	}
+	for _, m := range c.Members {
+		c.Total++
+		switch m.Status {
+		case StatusHaveFile:
+			c.HaveFile++
+		case StatusMonitored:
+			c.Monitored++
+		default:
+			c.Missing++
+		}
+	}
+	return c, nil
+}
+
+// tmdbPart is a movie of a collection's metadata
+type tmdbPart struct {
+	TMDBID      string `json:"tmdb_id"`
+	Title       string `json:"title"`
+	Year        int32  `json:"year"`
+	ReleaseDate string `json:"release_date"`
+	PosterURL   string `json:"poster_url"`
+}
+
+// libraryMovie is a movie of the library that is in a collection
+type libraryMovie struct {
+	ID        int64
+	Title     string
+	Year      *int32
+	TMDBID    string
+	PosterURL string
+	HasFile   bool
+	Monitored bool
+}
+
+// libraryMovies returns the movies of a collection that are in the library:
+// those attached to it and those with the TMDB ID of one of its movies
+func (s *Service) libraryMovies(ctx context.Context, collectionID int64, tmdbIDs []string) ([]libraryMovie, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT m.id, m.title, m.year,
+		       COALESCE(m.external_ids->>'tmdb', ''),
+		       COALESCE(m.metadata->>'poster_url', ''),
+		       EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = m.id AND f.kind = 'media'),
+		       EXISTS (SELECT 1 FROM monitoring_rules r WHERE r.media_item_id = m.id AND r.enabled)
+		FROM media_items m
+		WHERE m.kind = $1 AND m.deleted_at IS NULL
+		  AND (m.parent_id = $2 OR m.external_ids->>'tmdb' = ANY($3))
+		ORDER BY m.year NULLS LAST, m.sort_title
+	`, string(media.MediaKindMovie), collectionID, tmdbIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection movies: %w", err)
+	}
+	movies, err := pgx.CollectRows(rows, pgx.RowToStructByPos[libraryMovie])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection movies: %w", err)
+	}
+	return movies, nil
+}
+
+// members lists the movies of a collection in the order TMDB lists them, with
+// the movies of the library that TMDB doesn't list after them
+func members(parts []tmdbPart, movies []libraryMovie) []Member {
+	byTMDBID := make(map[string]int, len(movies))
+	for i, movie := range movies {
+		if movie.TMDBID != "" {
+			byTMDBID[movie.TMDBID] = i
+		}
+	}
+
+	listed := make([]Member, 0, len(parts)+len(movies))
+	used := make([]bool, len(movies))
+	for _, part := range parts {
+		m := Member{
+			TMDBID:      part.TMDBID,
+			Title:       part.Title,
+			ReleaseDate: part.ReleaseDate,
+			PosterURL:   part.PosterURL,
+			Status:      StatusMissing,
+		}
+		if part.Year > 0 {
+			year := part.Year
+			m.Year = &year
+		}
+		if i, ok := byTMDBID[part.TMDBID]; ok && !used[i] {
+			used[i] = true
+			m.MediaItemID = &movies[i].ID
+			m.Status = movies[i].status()
+		}
+		listed = append(listed, m)
+	}
+	for i, movie := range movies {
+		if used[i] {
+			continue
+		}
+		id := movie.ID
+		listed = append(listed, Member{
+			TMDBID:      movie.TMDBID,
+			Title:       movie.Title,
+			Year:        movie.Year,
+			PosterURL:   movie.PosterURL,
+			MediaItemID: &id,
+			Status:      movie.status(),
+		})
+	}
+	return listed
+}
+
+// status returns the member status of a movie in the library
+func (m libraryMovie) status() string {
+	switch {
+	case m.HasFile:
+		return StatusHaveFile
+	case m.Monitored:
+		return StatusMonitored
+	default:
+		return StatusMissing
+	}
+}
+
+// Monitor monitors the missing movies of a collection, adding the ones that
+// aren't in the library. Movies that fail are reported and the others are
+// still monitored.
+func (s *Service) Monitor(ctx context.Context, id int64, params MonitorParams) (*MonitorResult, error) {
+	if s.monitoring == nil {
+		return nil, ErrMonitoringUnavailable
+	}
+	if params.TemplateID != nil {
+		template, err := s.monitoring.GetTemplate(ctx, *params.TemplateID)
+		if err != nil {
+			return nil, err
+		}
+		if template.Kind != "" && template.Kind != "movie" {
+			return nil, &monitoring.SettingsError{Message: fmt.Sprintf("template %q is for %s, not movies", template.Name, template.Kind)}
+		}
+	}
+	if params.QualityProfileID != nil {
+		exists, err := s.monitoring.QualityProfileExists(ctx, *params.QualityProfileID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrQualityProfileNotFound
+		}
+	}
+	if params.RootFolderID != nil {
+		exists, err := s.monitoring.RootFolderExists(ctx, *params.RootFolderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up root folder: %w", err)
+		}
+		if !exists {
+			return nil, ErrRootFolderNotFound
+		}
+	}
+
+	collection, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MonitorResult{Monitored: []Member{}, Failed: []MonitorFailed{}}
+	for _, member := range collection.Members {
+		if member.Status != StatusMissing {
+			continue
+		}
+		rule, err := s.monitorMember(ctx, &member, params)
+		if err != nil {
+			s.logger.Warn("failed to monitor collection movie",
+				zap.Int64("collection_id", id), zap.String("title", member.Title), zap.Error(err))
+			result.Failed = append(result.Failed, MonitorFailed{Member: member, Error: err.Error()})
+			continue
+		}
+		member.Status = StatusMonitored
+		result.Monitored = append(result.Monitored, member)
+
+		if rule.SearchOnAdd && s.searcher != nil {
+			go func(mediaItemID int64) {
+				if err := s.searcher.SearchRule(context.Background(), rule, monitoring.SearchTypeAutomatic, monitoring.TriggerSourceUser); err != nil {
+					s.logger.Warn("initial search for collection movie failed", zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+				}
+			}(*member.MediaItemID)
+		}
+	}
+	return result, nil
+}
+
+// monitorMember adds a missing movie to the library when it isn't there, and
+// creates or enables its monitoring rule
+func (s *Service) monitorMember(ctx context.Context, member *Member, params MonitorParams) (*monitoring.MonitoringRule, error) {
+	if member.MediaItemID == nil {
+		if member.TMDBID == "" {
+			return nil, errors.New("movie has no TMDB ID")
+		}
+		item, _, err := s.titles.FindOrAddTitle(ctx, requests.Title{
+			MediaType: requests.TypeMovie,
+			TMDBID:    member.TMDBID,
+			Title:     member.Title,
+			Year:      member.Year,
+		}, collectionSource)
+		if err != nil {
+			return nil, err
+		}
+		member.MediaItemID = &item.ID
+	}
+
+	// A movie that was unmonitored keeps its rule, enabled with the new settings
+	if rule, err := s.monitoring.GetMonitoringRuleByMediaItem(ctx, *member.MediaItemID); err == nil {
+		enabled := true
+		return s.monitoring.UpdateMonitoringRule(ctx, rule.ID, monitoring.UpdateMonitoringRuleParams{
+			Enabled:          &enabled,
+			QualityProfileID: params.QualityProfileID,
+			RootFolderID:     params.RootFolderID,
+			SearchOnAdd:      params.SearchOnAdd,
+		})
+	}
+
+	rule, err := s.monitoring.NewRuleParams(ctx, *member.MediaItemID, params.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if params.QualityProfileID != nil {
+		rule.QualityProfileID = params.QualityProfileID
+	}
+	if params.RootFolderID != nil {
+		rule.RootFolderID = params.RootFolderID
+	}
+	if params.SearchOnAdd != nil {
+		rule.SearchOnAdd = *params.SearchOnAdd
+	}
+	return s.monitoring.CreateMonitoringRule(ctx, rule)
+}
@@ -0,0 +1,57 @@
+package collections
+
+import (
+	"testing"
+)
+
+func TestMembers(t *testing.T) {
+	year := int32(1997)
+	parts := []tmdbPart{
+		{TMDBID: "348", Title: "Alien", Year: 1979, ReleaseDate: "1979-05-25"},
+		{TMDBID: "679", Title: "Aliens", Year: 1986},
+		{TMDBID: "8077", Title: "Alien³", Year: 1992},
+		{TMDBID: "8078", Title: "Alien Resurrection", Year: 1997},
+	}
+	movies := []libraryMovie{
+		{ID: 1, Title: "Alien", TMDBID: "348", HasFile: true, Monitored: true},
+		{ID: 2, Title: "Aliens", TMDBID: "679", Monitored: true},
+		{ID: 3, Title: "Alien³", TMDBID: "8077"},
+		{ID: 4, Title: "Alien: Director's Cut", Year: &year},
+	}
+
+	got := members(parts, movies)
+	want := []struct {
+		title  string
+		itemID int64
+		status string
+	}{
+		{"Alien", 1, StatusHaveFile},
+		{"Aliens", 2, StatusMonitored},
+		{"Alien³", 3, StatusMissing},
+		{"Alien Resurrection", 0, StatusMissing},
+		{"Alien: Director's Cut", 4, StatusMissing},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("members() = %+v, want %d members", got, len(want))
+	}
+	for i, w := range want {
+		m := got[i]
+		var itemID int64
+		if m.MediaItemID != nil {
+			itemID = *m.MediaItemID
+		}
+		if m.Title != w.title || itemID != w.itemID || m.Status != w.status {
+			t.Errorf("members()[%d] = %q item %d %s, want %q item %d %s", i, m.Title, itemID, m.Status, w.title, w.itemID, w.status)
+		}
+	}
+	if got[0].ReleaseDate != "1979-05-25" || got[0].Year == nil || *got[0].Year != 1979 {
+		t.Errorf("members()[0] = %+v, want the TMDB release date and year", got[0])
+	}
+}
+
+func TestMembersWithoutParts(t *testing.T) {
+	got := members(nil, []libraryMovie{{ID: 7, Title: "Alien", TMDBID: "348", HasFile: true}})
+	if len(got) != 1 || got[0].TMDBID != "348" || got[0].Status != StatusHaveFile {
+		t.Errorf("members() = %+v, want the library movie", got)
+	}
+}
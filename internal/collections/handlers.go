@@ -0,0 +1,114 @@
+package collections
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles collection HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new collection handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "collections-handler")),
+	}
+}
+
+// SetupRoutes registers the routes any user may call
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/collections", h.ListCollections)
+	r.Get("/collections/{id}", h.GetCollection)
+}
+
+// SetupAdminRoutes registers the routes that add and monitor movies, which
+// must be mounted behind the admin middleware
+func SetupAdminRoutes(r chi.Router, h *Handler) {
+	r.Post("/collections/{id}/monitor", h.MonitorCollection)
+}
+
+func parseCollectionID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError writes the response for an error of the service
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string) {
+	var settingsErr *monitoring.SettingsError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Collection not found")
+	case errors.Is(err, ErrQualityProfileNotFound), errors.Is(err, ErrRootFolderNotFound),
+		errors.Is(err, monitoring.ErrTemplateNotFound), errors.As(err, &settingsErr):
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrMonitoringUnavailable):
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		httputil.LogError(h.logger, err, message)
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+	}
+}
+
+// ListCollections returns all collections with how many of their movies are
+// in the library
+// GET /api/collections
+func (h *Handler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.service.List(r.Context())
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to list collections")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"collections": collections})
+}
+
+// GetCollection returns a collection with its movies and whether each has a
+// file, is monitored or is missing
+// GET /api/collections/{id}
+func (h *Handler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseCollectionID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	collection, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get collection")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, collection)
+}
+
+// MonitorCollection monitors the missing movies of a collection, adding the
+// ones that aren't in the library
+// POST /api/collections/{id}/monitor
+func (h *Handler) MonitorCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseCollectionID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	var params MonitorParams
+	if err := httputil.DecodeJSON(r, &params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.service.Monitor(r.Context(), id, params)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to monitor collection")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, result)
+}
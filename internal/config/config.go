@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds the application configuration
@@ -18,6 +22,18 @@ type Config struct {
 	// Authentication
 	JWTSecret string
 
+	// Secrets
+	SecretKey          string   // Master key that encrypts secrets in the config table
+	PreviousSecretKeys []string // Former master keys that secrets may still be encrypted with
+
+	// Internal API, for plugins calling back into the server
+	InternalURL   string // Base URL plugins reach the API on (default: http://localhost:PORT)
+	InternalToken string // Token plugins authenticate with, generated on each start when unset
+
+	// Origins besides the server's own that browsers may open WebSocket
+	// connections from, e.g. when the UI is served on another host
+	AllowedOrigins []string
+
 	// Environment
 	Environment string
 }
@@ -30,6 +46,25 @@ func Load() (*Config, error) {
 		Host:        getEnv("HOST", "0.0.0.0"),
 		JWTSecret:   getEnv("JWT_SECRET", ""),
 		Environment: getEnv("ENVIRONMENT", "development"),
+
+		SecretKey:          getEnv("NIMBUS_SECRET_KEY", ""),
+		PreviousSecretKeys: getEnvAsList("NIMBUS_SECRET_KEY_PREVIOUS"),
+
+		InternalURL:   strings.TrimRight(getEnv("NIMBUS_INTERNAL_URL", ""), "/"),
+		InternalToken: getEnv("NIMBUS_INTERNAL_TOKEN", ""),
+
+		AllowedOrigins: getEnvAsList("NIMBUS_ALLOWED_ORIGINS"),
+	}
+
+	if cfg.InternalURL == "" {
+		cfg.InternalURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+	if cfg.InternalToken == "" {
+		token, err := generateToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate internal API token: %w", err)
+		}
+		cfg.InternalToken = token
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -57,6 +92,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("JWT_SECRET must be at least 32 characters long")
 	}
 
+	if c.SecretKey != "" && len(c.SecretKey) < 32 {
+		return fmt.Errorf("NIMBUS_SECRET_KEY must be at least 32 characters long")
+	}
+
+	if u, err := url.Parse(c.InternalURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("NIMBUS_INTERNAL_URL must be an http or https URL, got %q", c.InternalURL)
+	}
+
+	if len(c.InternalToken) < 32 {
+		return fmt.Errorf("NIMBUS_INTERNAL_TOKEN must be at least 32 characters long")
+	}
+
 	return nil
 }
 
@@ -92,3 +139,23 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return value
 }
+
+// getEnvAsList gets a comma-separated environment variable as a list
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// generateToken returns a random hex token
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,70 @@
+package configstore
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// stateKeySuffixes are the endings of plugin config keys that hold state
+// rather than settings, like the downloads a plugin persists
+var stateKeySuffixes = []string{".downloads", ".downloads_alt"}
+
+// IsStateKey reports whether a config key holds state a plugin persists
+// rather than settings. State is rewritten constantly, so it isn't backed up
+// and doesn't notify subscribers.
+func IsStateKey(key string) bool {
+	if !strings.HasPrefix(key, "plugins.") {
+		return false
+	}
+	for _, suffix := range stateKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Change describes a config value that was written or deleted
+type Change struct {
+	Key     string
+	Value   json.RawMessage // Nil when the key was deleted or holds a secret
+	Secret  bool
+	Deleted bool
+}
+
+// ChangeHandler is called after a config value changes
+type ChangeHandler func(Change)
+
+type subscription struct {
+	prefix  string
+	handler ChangeHandler
+}
+
+// Subscribe calls handler after every write or delete of a key that starts
+// with prefix, except for state keys. Handlers run on the goroutine that wrote
+// the value, so they must not block.
+func (s *Store) Subscribe(prefix string, handler ChangeHandler) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	s.subs = append(s.subs, subscription{prefix: prefix, handler: handler})
+}
+
+// notify tells the subscribers of a key about a change
+func (s *Store) notify(change Change) {
+	if IsStateKey(change.Key) {
+		return
+	}
+
+	s.subsMu.RLock()
+	var handlers []ChangeHandler
+	for _, sub := range s.subs {
+		if strings.HasPrefix(change.Key, sub.prefix) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	s.subsMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(change)
+	}
+}
@@ -0,0 +1,36 @@
+package configstore
+
+import "testing"
+
+func TestIsStateKey(t *testing.T) {
+	tests := map[string]bool{
+		"plugins.nzb-downloader.downloads":     true,
+		"plugins.nzb-downloader.downloads_alt": true,
+		"plugins.nzb-downloader.servers":       false,
+		"downloads.completed_path":             false,
+		"library.downloads":                    false,
+	}
+	for key, want := range tests {
+		if got := IsStateKey(key); got != want {
+			t.Errorf("IsStateKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	store := New(nil)
+	var plugin, all []Change
+	store.Subscribe("plugins.", func(c Change) { plugin = append(plugin, c) })
+	store.Subscribe("", func(c Change) { all = append(all, c) })
+
+	store.notify(Change{Key: "plugins.nzb-downloader.servers", Value: []byte(`[]`)})
+	store.notify(Change{Key: "plugins.nzb-downloader.downloads", Value: []byte(`[]`)})
+	store.notify(Change{Key: "downloads.rename_movies", Value: []byte(`true`)})
+
+	if len(plugin) != 1 || plugin[0].Key != "plugins.nzb-downloader.servers" {
+		t.Errorf("plugin subscriber got %+v, want the servers change only", plugin)
+	}
+	if len(all) != 2 {
+		t.Errorf("subscriber of every key got %+v, want every change but state", all)
+	}
+}
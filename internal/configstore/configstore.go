@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
 )
@@ -12,6 +13,10 @@ import (
 // Store provides type-safe access to the config table
 type Store struct {
 	queries *generated.Queries
+	keyring *Keyring // Encrypts secrets, nil until configured
+
+	subsMu sync.RWMutex
+	subs   []subscription // Notified of changes, see Subscribe
 }
 
 // New creates a new config store
@@ -55,6 +60,7 @@ func (s *Store) Set(ctx context.Context, key string, value any) error {
 		return fmt.Errorf("failed to set config %s: %w", key, err)
 	}
 
+	s.notify(Change{Key: key, Value: jsonValue})
 	return nil
 }
 
@@ -82,6 +88,7 @@ func (s *Store) SetWithMetadata(ctx context.Context, key string, value any, meta
 		return fmt.Errorf("failed to set config %s: %w", key, err)
 	}
 
+	s.notify(Change{Key: key, Value: jsonValue})
 	return nil
 }
 
@@ -90,6 +97,7 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 	if err := s.queries.DeleteConfig(ctx, key); err != nil {
 		return fmt.Errorf("failed to delete config %s: %w", key, err)
 	}
+	s.notify(Change{Key: key, Deleted: true})
 	return nil
 }
 
@@ -123,6 +131,21 @@ func (s *Store) GetInt(ctx context.Context, key string) (int, error) {
 	return value, nil
 }
 
+// GetFloat retrieves a floating point configuration value
+func (s *Store) GetFloat(ctx context.Context, key string) (float64, error) {
+	raw, err := s.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal float config %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
 // GetBool retrieves a boolean configuration value
 func (s *Store) GetBool(ctx context.Context, key string) (bool, error) {
 	raw, err := s.Get(ctx, key)
@@ -230,6 +253,15 @@ func (s *Store) GetIntOrDefault(ctx context.Context, key string, defaultValue in
 	return value
 }
 
+// GetFloatOrDefault retrieves a floating point value or returns a default
+func (s *Store) GetFloatOrDefault(ctx context.Context, key string, defaultValue float64) float64 {
+	value, err := s.GetFloat(ctx, key)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 // GetBoolOrDefault retrieves a boolean value or returns a default
 func (s *Store) GetBoolOrDefault(ctx context.Context, key string, defaultValue bool) bool {
 	value, err := s.GetBool(ctx, key)
@@ -0,0 +1,282 @@
+package configstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+// RedactedValue replaces secrets wherever config values are shown
+const RedactedValue = "[redacted]"
+
+// secretKeyInfo binds derived keys to their use, so the master key can safely
+// be shared with other derivations
+const secretKeyInfo = "nimbus config secrets v1"
+
+var (
+	// ErrNoKeyring is returned when secrets are used before a keyring is set
+	ErrNoKeyring = errors.New("secret storage is not configured")
+
+	// ErrUnknownSecretKey is returned when a secret was encrypted with a master
+	// key that is neither the current nor a previous key
+	ErrUnknownSecretKey = errors.New("secret was encrypted with an unknown key")
+)
+
+// secretEnvelope is how an encrypted secret is stored in the config table
+type secretEnvelope struct {
+	KeyID  string `json:"$secret_key"` // Identifies the master key used
+	Cipher string `json:"$secret"`     // Base64 nonce followed by the AES-GCM ciphertext
+}
+
+// IsSecret reports whether a raw config value is an encrypted secret
+func IsSecret(raw json.RawMessage) bool {
+	var envelope secretEnvelope
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return false
+	}
+	return envelope.KeyID != "" && envelope.Cipher != ""
+}
+
+type secretKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// newSecretKey derives an AES-256 key from a master key
+func newSecretKey(master string) (*secretKey, error) {
+	if master == "" {
+		return nil, errors.New("master key is empty")
+	}
+
+	derived := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(master), nil, []byte(secretKeyInfo)), derived); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID is a hash of the derived key, never of the master key itself
+	sum := sha256.Sum256(derived)
+	return &secretKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// Keyring encrypts secrets with the current master key and decrypts secrets
+// written with the current or any previous master key
+type Keyring struct {
+	current  *secretKey
+	previous map[string]*secretKey
+}
+
+// NewKeyring creates a keyring from the current master key and any previous
+// master keys that secrets may still be encrypted with
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	currentKey, err := newSecretKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+
+	k := &Keyring{current: currentKey, previous: make(map[string]*secretKey)}
+	for _, master := range previous {
+		if master == "" {
+			continue
+		}
+		key, err := newSecretKey(master)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous secret key: %w", err)
+		}
+		if key.id != currentKey.id {
+			k.previous[key.id] = key
+		}
+	}
+	return k, nil
+}
+
+// Encrypt seals a plaintext with the current key and returns the stored form
+func (k *Keyring) Encrypt(plaintext []byte) (json.RawMessage, error) {
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := k.current.aead.Seal(nonce, nonce, plaintext, []byte(k.current.id))
+	return json.Marshal(secretEnvelope{
+		KeyID:  k.current.id,
+		Cipher: base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// Decrypt opens a stored secret. stale is true when the secret was encrypted
+// with a previous key and should be re-encrypted.
+func (k *Keyring) Decrypt(raw json.RawMessage) (plaintext []byte, stale bool, err error) {
+	var envelope secretEnvelope
+	if err := json.Unmarshal(raw, &envelope); err != nil || envelope.KeyID == "" {
+		return nil, false, errors.New("value is not an encrypted secret")
+	}
+
+	key := k.current
+	if envelope.KeyID != key.id {
+		var ok bool
+		if key, ok = k.previous[envelope.KeyID]; !ok {
+			return nil, false, ErrUnknownSecretKey
+		}
+		stale = true
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Cipher)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return nil, false, errors.New("secret is corrupted")
+	}
+
+	nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+	plaintext, err = key.aead.Open(nil, nonce, ciphertext, []byte(envelope.KeyID))
+	if err != nil {
+		return nil, false, errors.New("secret could not be decrypted")
+	}
+	return plaintext, stale, nil
+}
+
+// SetKeyring sets the keyring used to encrypt and decrypt secrets
+func (s *Store) SetKeyring(keyring *Keyring) {
+	s.keyring = keyring
+}
+
+// Seal encrypts a value with the current master key, in the form secrets are
+// stored in
+func (s *Store) Seal(value any) (json.RawMessage, error) {
+	if s.keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %w", err)
+	}
+	return s.keyring.Encrypt(plaintext)
+}
+
+// Open decrypts a value sealed with the current or a previous master key
+func (s *Store) Open(raw json.RawMessage) (json.RawMessage, error) {
+	if s.keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	plaintext, _, err := s.keyring.Decrypt(raw)
+	return plaintext, err
+}
+
+// SetSecret encrypts a configuration value before storing it
+func (s *Store) SetSecret(ctx context.Context, key string, value any) error {
+	if err := s.storeSecret(ctx, key, value); err != nil {
+		return err
+	}
+	s.notify(Change{Key: key, Secret: true})
+	return nil
+}
+
+// storeSecret encrypts and stores a value without notifying subscribers, for
+// re-encrypting a secret that didn't change
+func (s *Store) storeSecret(ctx context.Context, key string, value any) error {
+	if s.keyring == nil {
+		return ErrNoKeyring
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config value: %w", err)
+	}
+
+	sealed, err := s.keyring.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt config %s: %w", key, err)
+	}
+
+	if _, err := s.queries.SetConfig(ctx, generated.SetConfigParams{Key: key, Value: sealed}); err != nil {
+		return fmt.Errorf("failed to set config %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetSecret retrieves and decrypts a secret as raw JSON. Secrets written with a
+// previous key are re-encrypted with the current one. Values that were stored
+// before they became secrets are returned as they are.
+func (s *Store) GetSecret(ctx context.Context, key string) (json.RawMessage, error) {
+	raw, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSecret(raw) {
+		return raw, nil
+	}
+	if s.keyring == nil {
+		return nil, ErrNoKeyring
+	}
+
+	plaintext, stale, err := s.keyring.Decrypt(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config %s: %w", key, err)
+	}
+
+	if stale {
+		// Failing to re-encrypt only delays the rotation to the next read
+		_ = s.storeSecret(ctx, key, json.RawMessage(plaintext))
+	}
+	return plaintext, nil
+}
+
+// RotateSecrets re-encrypts every secret written with a previous key using the
+// current key, returning how many were rewritten. Secrets encrypted with an
+// unknown key are left untouched.
+func (s *Store) RotateSecrets(ctx context.Context) (int, error) {
+	if s.keyring == nil {
+		return 0, ErrNoKeyring
+	}
+
+	configs, err := s.queries.GetAllConfig(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get all config: %w", err)
+	}
+
+	rotated := 0
+	var errs []error
+	for _, cfg := range configs {
+		if !IsSecret(cfg.Value) {
+			continue
+		}
+		plaintext, stale, err := s.keyring.Decrypt(cfg.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Key, err))
+			continue
+		}
+		if !stale {
+			continue
+		}
+		if err := s.storeSecret(ctx, cfg.Key, json.RawMessage(plaintext)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rotated++
+	}
+	return rotated, errors.Join(errs...)
+}
+
+// IsNotFound reports whether an error means the config key does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
@@ -0,0 +1,119 @@
+package configstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	keyring, err := NewKeyring("current-master-key")
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	plaintext := []byte(`"hunter2"`)
+	sealed, err := keyring.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsSecret(sealed) {
+		t.Fatalf("IsSecret(%s) = false, want true", sealed)
+	}
+	if strings.Contains(string(sealed), "hunter2") {
+		t.Fatalf("encrypted value contains the plaintext: %s", sealed)
+	}
+
+	got, stale, err := keyring.Decrypt(sealed)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if stale {
+		t.Error("Decrypt() reported a secret written with the current key as stale")
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %s, want %s", got, plaintext)
+	}
+
+	for _, raw := range []string{`"hunter2"`, `{"password": "hunter2"}`, `null`} {
+		if IsSecret(json.RawMessage(raw)) {
+			t.Errorf("IsSecret(%s) = true, want false", raw)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKeyring, err := NewKeyring("old-master-key")
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	sealed, err := oldKeyring.Encrypt([]byte(`"api-key"`))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	// The old key is still accepted as a previous key, and flags the secret for
+	// re-encryption
+	rotated, err := NewKeyring("new-master-key", "old-master-key")
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	got, stale, err := rotated.Decrypt(sealed)
+	if err != nil {
+		t.Fatalf("Decrypt() with previous key error = %v", err)
+	}
+	if !stale {
+		t.Error("Decrypt() did not report a secret written with a previous key as stale")
+	}
+	if string(got) != `"api-key"` {
+		t.Errorf("Decrypt() = %s, want \"api-key\"", got)
+	}
+
+	// Re-encrypting moves the secret to the new key, so the old one can be dropped
+	resealed, err := rotated.Encrypt(got)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	newOnly, err := NewKeyring("new-master-key")
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	if _, stale, err := newOnly.Decrypt(resealed); err != nil || stale {
+		t.Errorf("Decrypt() of re-encrypted secret = stale %v, error %v", stale, err)
+	}
+
+	// Without the old key the original secret can't be read
+	if _, _, err := newOnly.Decrypt(sealed); !errors.Is(err, ErrUnknownSecretKey) {
+		t.Errorf("Decrypt() without previous key error = %v, want ErrUnknownSecretKey", err)
+	}
+}
+
+func TestKeyringRejectsTampering(t *testing.T) {
+	keyring, err := NewKeyring("current-master-key")
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	sealed, err := keyring.Encrypt([]byte(`"hunter2"`))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	var envelope secretEnvelope
+	if err := json.Unmarshal(sealed, &envelope); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	cipher := []byte(envelope.Cipher)
+	cipher[len(cipher)-2] ^= 1
+	envelope.Cipher = string(cipher)
+	tampered, _ := json.Marshal(envelope)
+
+	if _, _, err := keyring.Decrypt(tampered); err == nil {
+		t.Error("Decrypt() accepted a tampered secret")
+	}
+
+	if _, err := NewKeyring(""); err == nil {
+		t.Error("NewKeyring(\"\") succeeded, want error")
+	}
+}
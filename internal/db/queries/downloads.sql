@@ -110,6 +110,15 @@ SET
     updated_at = CURRENT_TIMESTAMP
 WHERE id = $2;
 
+-- name: MarkPluginDownloadsInterrupted :execrows
+UPDATE downloads
+SET
+    status = 'interrupted',
+    error_message = $1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE plugin_id = $2
+AND status IN ('queued', 'downloading', 'processing');
+
 -- name: DeleteDownload :exec
 DELETE FROM downloads WHERE id = $1;
 
@@ -0,0 +1,38 @@
+-- history.sql
+-- SQLC queries for the activity history
+
+-- =============================================================================
+-- CreateHistoryEvent - Record something Nimbus did
+-- =============================================================================
+-- name: CreateHistoryEvent :one
+INSERT INTO history_events (
+    event_type,
+    media_item_id,
+    download_id,
+    title,
+    data
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- =============================================================================
+-- ListHistoryEvents - List events, newest first, optionally filtered
+-- =============================================================================
+-- A media item filter also matches the item's seasons and episodes. Pages are
+-- continued with before_id, the ID of the last event of the previous page.
+-- name: ListHistoryEvents :many
+SELECT * FROM history_events
+WHERE (sqlc.narg('event_type')::text IS NULL OR event_type = sqlc.narg('event_type'))
+  AND (sqlc.narg('media_item_id')::bigint IS NULL
+       OR media_item_id = sqlc.narg('media_item_id')
+       OR media_item_id IN (
+           SELECT mi.id FROM media_items mi
+           WHERE mi.parent_id = sqlc.narg('media_item_id')
+              OR mi.parent_id IN (SELECT p.id FROM media_items p WHERE p.parent_id = sqlc.narg('media_item_id'))
+       ))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR created_at < sqlc.narg('until'))
+  AND (sqlc.narg('before_id')::bigint IS NULL OR id < sqlc.narg('before_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
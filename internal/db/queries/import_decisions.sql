@@ -0,0 +1,67 @@
+-- import_decisions.sql
+-- SQLC queries for failed imports awaiting manual resolution
+
+-- =============================================================================
+-- UpsertPendingImportDecision - Record a failed import
+-- =============================================================================
+-- A path that fails again while still pending updates the existing decision
+-- name: UpsertPendingImportDecision :one
+INSERT INTO import_decisions (
+    download_id,
+    source_path,
+    media_item_id,
+    detected,
+    reason
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (source_path) WHERE status = 'pending' DO UPDATE SET
+    download_id = COALESCE(EXCLUDED.download_id, import_decisions.download_id),
+    media_item_id = COALESCE(EXCLUDED.media_item_id, import_decisions.media_item_id),
+    detected = EXCLUDED.detected,
+    reason = EXCLUDED.reason
+RETURNING *;
+
+-- =============================================================================
+-- GetImportDecision - Retrieve an import decision by ID
+-- =============================================================================
+-- name: GetImportDecision :one
+SELECT * FROM import_decisions
+WHERE id = $1;
+
+-- =============================================================================
+-- ListPendingImportDecisions - Failed imports awaiting manual resolution
+-- =============================================================================
+-- name: ListPendingImportDecisions :many
+SELECT * FROM import_decisions
+WHERE status = 'pending'
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- =============================================================================
+-- CountPendingImportDecisions - Count failed imports awaiting resolution
+-- =============================================================================
+-- name: CountPendingImportDecisions :one
+SELECT COUNT(*) FROM import_decisions
+WHERE status = 'pending';
+
+-- =============================================================================
+-- CountPendingImportDecisionsByDownload - Pending imports left for a download
+-- =============================================================================
+-- Used to decide whether a download waiting for import can be completed
+-- name: CountPendingImportDecisionsByDownload :one
+SELECT COUNT(*) FROM import_decisions
+WHERE download_id = $1 AND status = 'pending';
+
+-- =============================================================================
+-- ResolveImportDecision - Mark a decision as resolved after a successful import
+-- =============================================================================
+-- name: ResolveImportDecision :one
+UPDATE import_decisions
+SET
+    status = 'resolved',
+    resolved_media_item_id = $2,
+    final_path = $3,
+    resolved_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,178 @@
+-- import_lists.sql
+-- SQLC queries for import lists
+
+-- =============================================================================
+-- CreateImportList - Add an import list
+-- =============================================================================
+-- name: CreateImportList :one
+INSERT INTO import_lists (
+    name,
+    type,
+    enabled,
+    settings,
+    quality_profile_id,
+    root_folder_id,
+    search_on_add,
+    removal_action,
+    sync_interval_minutes
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- =============================================================================
+-- GetImportList - Get an import list by ID
+-- =============================================================================
+-- name: GetImportList :one
+SELECT * FROM import_lists
+WHERE id = $1;
+
+-- =============================================================================
+-- ListImportLists - List all import lists
+-- =============================================================================
+-- name: ListImportLists :many
+SELECT * FROM import_lists
+ORDER BY name, id;
+
+-- =============================================================================
+-- ListImportListsDueForSync - Enabled lists whose sync interval has passed
+-- =============================================================================
+-- name: ListImportListsDueForSync :many
+SELECT * FROM import_lists
+WHERE enabled = TRUE
+  AND (last_sync_at IS NULL
+       OR last_sync_at + make_interval(mins => sync_interval_minutes) <= NOW())
+ORDER BY id;
+
+-- =============================================================================
+-- UpdateImportList - Replace the settings of an import list
+-- =============================================================================
+-- name: UpdateImportList :one
+UPDATE import_lists
+SET
+    name = $2,
+    enabled = $3,
+    settings = $4,
+    quality_profile_id = $5,
+    root_folder_id = $6,
+    search_on_add = $7,
+    removal_action = $8,
+    sync_interval_minutes = $9
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- SetImportListSynced - Record that a list was synced
+-- =============================================================================
+-- name: SetImportListSynced :exec
+UPDATE import_lists
+SET last_sync_at = NOW()
+WHERE id = $1;
+
+-- =============================================================================
+-- DeleteImportList - Remove an import list with its entries and runs
+-- =============================================================================
+-- name: DeleteImportList :execrows
+DELETE FROM import_lists
+WHERE id = $1;
+
+-- =============================================================================
+-- ListImportListEntries - The entries of a list seen by the last sync
+-- =============================================================================
+-- name: ListImportListEntries :many
+SELECT * FROM import_list_entries
+WHERE list_id = $1;
+
+-- =============================================================================
+-- UpsertImportListEntry - Remember an entry of a list
+-- =============================================================================
+-- name: UpsertImportListEntry :exec
+INSERT INTO import_list_entries (
+    list_id,
+    media_type,
+    tmdb_id,
+    title,
+    media_item_id,
+    added_by_list
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (list_id, media_type, tmdb_id) DO UPDATE
+SET
+    title = EXCLUDED.title,
+    media_item_id = EXCLUDED.media_item_id;
+
+-- =============================================================================
+-- DeleteImportListEntry - Forget an entry removed from a list
+-- =============================================================================
+-- name: DeleteImportListEntry :exec
+DELETE FROM import_list_entries
+WHERE list_id = $1
+  AND media_type = $2
+  AND tmdb_id = $3;
+
+-- =============================================================================
+-- CreateImportListRun - Start a sync run
+-- =============================================================================
+-- name: CreateImportListRun :one
+INSERT INTO import_list_runs (list_id)
+VALUES ($1)
+RETURNING *;
+
+-- =============================================================================
+-- FinishImportListRun - Record the outcome of a sync run
+-- =============================================================================
+-- name: FinishImportListRun :one
+UPDATE import_list_runs
+SET
+    status = $2,
+    added = $3,
+    existing = $4,
+    failed = $5,
+    removed = $6,
+    error_message = $7,
+    finished_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- GetImportListRun - Get a sync run by ID
+-- =============================================================================
+-- name: GetImportListRun :one
+SELECT * FROM import_list_runs
+WHERE id = $1;
+
+-- =============================================================================
+-- ListImportListRuns - The latest sync runs of a list, newest first
+-- =============================================================================
+-- name: ListImportListRuns :many
+SELECT * FROM import_list_runs
+WHERE list_id = $1
+ORDER BY id DESC
+LIMIT $2;
+
+-- =============================================================================
+-- CreateImportListRunItem - Record what a sync did with an entry
+-- =============================================================================
+-- name: CreateImportListRunItem :exec
+INSERT INTO import_list_run_items (
+    run_id,
+    status,
+    media_type,
+    tmdb_id,
+    title,
+    year,
+    media_item_id,
+    message
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+);
+
+-- =============================================================================
+-- ListImportListRunItems - What a sync run did, optionally with one status
+-- =============================================================================
+-- name: ListImportListRunItems :many
+SELECT * FROM import_list_run_items
+WHERE run_id = $1
+  AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY id;
@@ -0,0 +1,60 @@
+-- library_imports.sql
+-- SQLC queries for importing organized libraries in place
+
+-- =============================================================================
+-- CreateLibraryImport - Start a new in-place import run
+-- =============================================================================
+-- name: CreateLibraryImport :one
+INSERT INTO library_imports (
+    path,
+    kind
+) VALUES (
+    $1, $2
+)
+RETURNING *;
+
+-- =============================================================================
+-- GetLatestLibraryImport - Get the most recent import run
+-- =============================================================================
+-- name: GetLatestLibraryImport :one
+SELECT * FROM library_imports
+ORDER BY id DESC
+LIMIT 1;
+
+-- =============================================================================
+-- ResumeLibraryImport - Mark a cancelled or interrupted run as running again
+-- =============================================================================
+-- name: ResumeLibraryImport :one
+UPDATE library_imports
+SET
+    status = 'running',
+    error_message = NULL,
+    finished_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- UpdateLibraryImportProgress - Save the position and counters of a run
+-- =============================================================================
+-- Conflicts found since the last save are appended to the report.
+-- name: UpdateLibraryImportProgress :exec
+UPDATE library_imports
+SET
+    last_path = sqlc.arg('last_path'),
+    files_found = sqlc.arg('files_found'),
+    files_imported = sqlc.arg('files_imported'),
+    items_created = sqlc.arg('items_created'),
+    conflicts = conflicts || sqlc.arg('conflicts')::jsonb
+WHERE id = sqlc.arg('id');
+
+-- =============================================================================
+-- FinishLibraryImport - Mark a run as completed, cancelled or failed
+-- =============================================================================
+-- name: FinishLibraryImport :one
+UPDATE library_imports
+SET
+    status = $2,
+    error_message = $3,
+    finished_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -33,6 +33,15 @@ SELECT * FROM media_files
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2;
 
+-- =============================================================================
+-- ListMediaFilesUnderPath - Get all files whose path matches a LIKE pattern
+-- =============================================================================
+-- Used by the library scanner to find files under a folder, e.g. '/media/tv/%'
+-- name: ListMediaFilesUnderPath :many
+SELECT * FROM media_files
+WHERE path LIKE $1
+ORDER BY path;
+
 -- =============================================================================
 -- CountMediaFiles - Count total media files
 -- =============================================================================
@@ -97,9 +106,53 @@ ON CONFLICT (path) DO UPDATE SET
     media_item_id = EXCLUDED.media_item_id,
     size = EXCLUDED.size,
     hash = EXCLUDED.hash,
+    -- A file flagged missing by verification is back
+    status = CASE WHEN media_files.status = 'missing' THEN 'ok' ELSE media_files.status END,
     updated_at = NOW()
 RETURNING *;
 
+-- =============================================================================
+-- UpsertExtraMediaFile - Record an extra file such as a subtitle by path
+-- =============================================================================
+-- name: UpsertExtraMediaFile :one
+INSERT INTO media_files (
+    media_item_id,
+    path,
+    size,
+    kind
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (path) DO UPDATE SET
+    media_item_id = EXCLUDED.media_item_id,
+    size = EXCLUDED.size,
+    kind = EXCLUDED.kind,
+    status = 'ok',
+    updated_at = NOW()
+RETURNING *;
+
+-- =============================================================================
+-- SetMediaFilePath - Point a media file at the path it was renamed to
+-- =============================================================================
+-- name: SetMediaFilePath :exec
+UPDATE media_files
+SET
+    path = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- =============================================================================
+-- SetMediaFileRelease - Record the release an imported file came from
+-- =============================================================================
+-- name: SetMediaFileRelease :exec
+UPDATE media_files
+SET
+    quality = sqlc.narg('quality'),
+    release_group = sqlc.narg('release_group'),
+    release_title = sqlc.narg('release_title'),
+    updated_at = NOW()
+WHERE path = sqlc.arg('path');
+
 -- =============================================================================
 -- DeleteMediaFile - Delete a media file by ID
 -- =============================================================================
@@ -129,3 +182,94 @@ WHERE media_item_id = $1;
 SELECT * FROM media_files
 WHERE media_item_id IS NULL
 ORDER BY path;
+
+-- =============================================================================
+-- SetMediaFileMediaInfo - Store the media info probed from a file
+-- =============================================================================
+-- name: SetMediaFileMediaInfo :exec
+UPDATE media_files
+SET mediainfo = $2
+WHERE id = $1;
+
+-- =============================================================================
+-- ListMediaFilesForMediaInfo - Page through files to probe in ID order
+-- =============================================================================
+-- Only files without media info unless all files are requested; files that
+-- failed verification and extra files are skipped
+-- name: ListMediaFilesForMediaInfo :many
+SELECT * FROM media_files
+WHERE id > sqlc.arg('after_id')::bigint
+  AND status = 'ok'
+  AND kind = 'media'
+  AND (sqlc.arg('include_all')::boolean OR mediainfo IS NULL)
+ORDER BY id
+LIMIT sqlc.arg('batch_size')::int;
+
+-- =============================================================================
+-- CountMediaFilesForMediaInfo - Count the files a media info refresh will probe
+-- =============================================================================
+-- name: CountMediaFilesForMediaInfo :one
+SELECT COUNT(*) FROM media_files
+WHERE status = 'ok'
+  AND kind = 'media'
+  AND (sqlc.arg('include_all')::boolean OR mediainfo IS NULL);
+
+-- =============================================================================
+-- LinkMediaFileEpisode - Record a further episode a multi-episode file covers
+-- =============================================================================
+-- name: LinkMediaFileEpisode :exec
+INSERT INTO media_file_episodes (media_file_id, media_item_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- =============================================================================
+-- ListMediaFileEpisodes - The further episodes a multi-episode file covers
+-- =============================================================================
+-- name: ListMediaFileEpisodes :many
+SELECT media_item_id FROM media_file_episodes
+WHERE media_file_id = $1
+ORDER BY media_item_id;
+
+-- =============================================================================
+-- SetEpisodesHasFile - Mark the episodes a file covers as having it
+-- =============================================================================
+-- name: SetEpisodesHasFile :exec
+UPDATE episode_monitoring
+SET
+    has_file = true,
+    file_id = sqlc.arg('file_id')::BIGINT,
+    updated_at = NOW()
+WHERE media_item_id = ANY(sqlc.arg('media_item_ids')::BIGINT[]);
+
+-- =============================================================================
+-- SetEpisodeFileCutoff - Record an episode's file and whether it meets the cutoff
+-- =============================================================================
+-- name: SetEpisodeFileCutoff :exec
+INSERT INTO episode_monitoring (media_item_id, has_file, file_id, cutoff_met)
+VALUES (sqlc.arg('media_item_id')::BIGINT, true, sqlc.arg('file_id')::BIGINT, sqlc.arg('cutoff_met')::BOOLEAN)
+ON CONFLICT (media_item_id) DO UPDATE
+SET
+    has_file = true,
+    file_id = EXCLUDED.file_id,
+    cutoff_met = EXCLUDED.cutoff_met,
+    updated_at = NOW();
+
+-- =============================================================================
+-- ListEpisodeFileCutoffs - Episodes with a file, for recomputing their cutoff
+-- =============================================================================
+-- name: ListEpisodeFileCutoffs :many
+SELECT em.media_item_id, em.cutoff_met, f.path, f.release_title, f.quality
+FROM episode_monitoring em
+JOIN media_files f ON f.id = em.file_id
+WHERE em.has_file = true
+ORDER BY em.media_item_id;
+
+-- =============================================================================
+-- SetEpisodeCutoffMet - Update whether an episode's file meets the cutoff
+-- =============================================================================
+-- name: SetEpisodeCutoffMet :exec
+UPDATE episode_monitoring
+SET
+    cutoff_met = $2,
+    updated_at = NOW()
+WHERE media_item_id = $1;
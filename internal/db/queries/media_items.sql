@@ -5,13 +5,15 @@ WHERE id = $1;
 -- name: ListMediaItems :many
 SELECT * FROM media_items
 WHERE
-    (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
+    deleted_at IS NULL
+    AND (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
     AND (
         -- If parent_id is explicitly provided, use it (even if it's NULL to find top-level items)
         (sqlc.narg('parent_id')::bigint IS NOT NULL AND parent_id = sqlc.narg('parent_id'))
         OR
-        -- Otherwise, apply top_level_only filter if set
-        (sqlc.narg('parent_id')::bigint IS NULL AND (NOT sqlc.narg('top_level_only')::boolean OR parent_id IS NULL))
+        -- Otherwise, apply top_level_only filter if set. Movies in a collection
+        -- are still listed on their own.
+        (sqlc.narg('parent_id')::bigint IS NULL AND (NOT sqlc.narg('top_level_only')::boolean OR parent_id IS NULL OR kind = 'movie'))
     )
     AND (
         sqlc.narg('search')::text IS NULL
@@ -25,13 +27,15 @@ OFFSET sqlc.narg('offset');
 -- name: CountMediaItems :one
 SELECT COUNT(*) FROM media_items
 WHERE
-    (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
+    deleted_at IS NULL
+    AND (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
     AND (
         -- If parent_id is explicitly provided, use it (even if it's NULL to find top-level items)
         (sqlc.narg('parent_id')::bigint IS NOT NULL AND parent_id = sqlc.narg('parent_id'))
         OR
-        -- Otherwise, apply top_level_only filter if set
-        (sqlc.narg('parent_id')::bigint IS NULL AND (NOT sqlc.narg('top_level_only')::boolean OR parent_id IS NULL))
+        -- Otherwise, apply top_level_only filter if set. Movies in a collection
+        -- are still listed on their own.
+        (sqlc.narg('parent_id')::bigint IS NULL AND (NOT sqlc.narg('top_level_only')::boolean OR parent_id IS NULL OR kind = 'movie'))
     )
     AND (
         sqlc.narg('search')::text IS NULL
@@ -69,21 +73,35 @@ RETURNING *;
 DELETE FROM media_items
 WHERE id = $1;
 
+-- name: SetMediaItemParent :exec
+UPDATE media_items
+SET
+    parent_id = sqlc.narg('parent_id'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: DetachChildMediaItems :exec
+UPDATE media_items
+SET
+    parent_id = NULL,
+    updated_at = NOW()
+WHERE parent_id = $1;
+
 -- name: ListMediaItemsByKind :many
 SELECT * FROM media_items
-WHERE kind = $1
+WHERE kind = $1 AND deleted_at IS NULL
 ORDER BY sort_title, created_at DESC
 LIMIT $2
 OFFSET $3;
 
 -- name: ListChildMediaItems :many
 SELECT * FROM media_items
-WHERE parent_id = $1
+WHERE parent_id = $1 AND deleted_at IS NULL
 ORDER BY sort_title, created_at DESC;
 
 -- name: GetMediaItemsByExternalID :many
 SELECT * FROM media_items
-WHERE external_ids @> sqlc.arg('external_id')::jsonb;
+WHERE external_ids @> sqlc.arg('external_id')::jsonb AND deleted_at IS NULL;
 
 -- =============================================================================
 -- Scanner-specific queries
@@ -99,7 +117,7 @@ SELECT * FROM media_items
 WHERE title = $1
   AND (year = $2 OR (year IS NULL AND $2::int IS NULL))
   AND kind = $3
-  AND (parent_id = $4 OR (parent_id IS NULL AND $4::bigint IS NULL))
+  AND (kind = 'movie' OR parent_id = $4 OR (parent_id IS NULL AND $4::bigint IS NULL))
 LIMIT 1;
 
 -- name: UpsertMediaItem :one
@@ -114,11 +132,12 @@ INSERT INTO media_items (
 ) VALUES (
     $1, $2, $3, $4, $5, $6, $7
 )
-ON CONFLICT (kind, title, COALESCE(year, -1), COALESCE(parent_id, -1))
+ON CONFLICT (kind, title, COALESCE(year, -1), COALESCE(CASE WHEN kind = 'movie' THEN NULL ELSE parent_id END, -1))
 DO UPDATE SET
     sort_title = EXCLUDED.sort_title,
     external_ids = media_items.external_ids || EXCLUDED.external_ids,
     metadata = media_items.metadata || EXCLUDED.metadata,
+    deleted_at = NULL, -- Files found again bring a deleted item back
     updated_at = NOW()
 RETURNING *;
 
@@ -0,0 +1,101 @@
+-- notifications.sql
+-- SQLC queries for notifiers and their delivery log
+
+-- =============================================================================
+-- ListNotifiers - Get all notifiers
+-- =============================================================================
+-- name: ListNotifiers :many
+SELECT * FROM notifiers
+ORDER BY name, id;
+
+-- =============================================================================
+-- ListNotifiersForEvent - Get the enabled server-wide notifiers subscribed to an event
+-- =============================================================================
+-- name: ListNotifiersForEvent :many
+SELECT * FROM notifiers
+WHERE enabled = true
+  AND user_id IS NULL
+  AND sqlc.arg('event_type')::text = ANY(events)
+ORDER BY id;
+
+-- =============================================================================
+-- ListUserNotifiersForEvent - Get a user's enabled notifiers subscribed to an event
+-- =============================================================================
+-- name: ListUserNotifiersForEvent :many
+SELECT * FROM notifiers
+WHERE enabled = true
+  AND user_id = sqlc.arg('user_id')::bigint
+  AND sqlc.arg('event_type')::text = ANY(events)
+ORDER BY id;
+
+-- =============================================================================
+-- GetNotifier - Get a notifier by ID
+-- =============================================================================
+-- name: GetNotifier :one
+SELECT * FROM notifiers
+WHERE id = $1;
+
+-- =============================================================================
+-- CreateNotifier - Add a notifier
+-- =============================================================================
+-- name: CreateNotifier :one
+INSERT INTO notifiers (
+    name,
+    type,
+    enabled,
+    events,
+    settings,
+    user_id,
+    tags
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- =============================================================================
+-- UpdateNotifier - Replace a notifier's settings
+-- =============================================================================
+-- name: UpdateNotifier :one
+UPDATE notifiers
+SET
+    name = $2,
+    type = $3,
+    enabled = $4,
+    events = $5,
+    settings = $6,
+    user_id = $7,
+    tags = $8
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- DeleteNotifier - Remove a notifier and its delivery log
+-- =============================================================================
+-- name: DeleteNotifier :execrows
+DELETE FROM notifiers
+WHERE id = $1;
+
+-- =============================================================================
+-- CreateNotificationDelivery - Record a delivery attempt
+-- =============================================================================
+-- name: CreateNotificationDelivery :one
+INSERT INTO notification_deliveries (
+    notifier_id,
+    event_type,
+    status,
+    attempts,
+    error_message,
+    payload
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- =============================================================================
+-- ListNotificationDeliveries - Get the most recent deliveries of a notifier
+-- =============================================================================
+-- name: ListNotificationDeliveries :many
+SELECT * FROM notification_deliveries
+WHERE notifier_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
@@ -39,3 +39,12 @@ WHERE id = $1;
 
 -- name: PluginExists :one
 SELECT EXISTS(SELECT 1 FROM plugins WHERE id = $1);
+
+-- name: GetPluginConfigVersion :one
+SELECT config_version FROM plugins
+WHERE id = $1;
+
+-- name: SetPluginConfigVersion :exec
+UPDATE plugins
+SET config_version = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,41 @@
+-- quality.sql
+-- SQLC queries for quality profiles and definitions used outside the quality service
+
+-- =============================================================================
+-- GetEffectiveQualityProfileID - Get the quality profile that applies to a media item
+-- =============================================================================
+-- Episodes and seasons inherit the profile of the nearest monitored ancestor
+-- name: GetEffectiveQualityProfileID :one
+SELECT mr.quality_profile_id
+FROM monitoring_rules mr
+JOIN media_items m ON m.id = $1
+LEFT JOIN media_items parent ON parent.id = m.parent_id
+WHERE mr.media_item_id IN (m.id, m.parent_id, parent.parent_id)
+  AND mr.quality_profile_id IS NOT NULL
+ORDER BY CASE mr.media_item_id
+    WHEN m.id THEN 0
+    WHEN m.parent_id THEN 1
+    ELSE 2
+END
+LIMIT 1;
+
+-- =============================================================================
+-- GetQualityProfile - Get a quality profile by ID
+-- =============================================================================
+-- name: GetQualityProfile :one
+SELECT * FROM quality_profiles
+WHERE id = $1;
+
+-- =============================================================================
+-- GetQualityDefinition - Get a quality definition by ID
+-- =============================================================================
+-- name: GetQualityDefinition :one
+SELECT * FROM quality_definitions
+WHERE id = $1;
+
+-- =============================================================================
+-- GetQualityProfileIDByName - Get the ID of a quality profile by name
+-- =============================================================================
+-- name: GetQualityProfileIDByName :one
+SELECT id FROM quality_profiles
+WHERE name = $1;
@@ -0,0 +1,115 @@
+-- requests.sql
+-- SQLC queries for media requests
+
+-- =============================================================================
+-- CreateMediaRequest - Submit a request for a movie or series
+-- =============================================================================
+-- name: CreateMediaRequest :one
+INSERT INTO media_requests (
+    user_id,
+    media_type,
+    tmdb_id,
+    title,
+    year,
+    poster_url
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- =============================================================================
+-- GetMediaRequest - Get a request by ID
+-- =============================================================================
+-- name: GetMediaRequest :one
+SELECT * FROM media_requests
+WHERE id = $1;
+
+-- =============================================================================
+-- GetOpenMediaRequest - Get the pending or approved request for a title
+-- =============================================================================
+-- name: GetOpenMediaRequest :one
+SELECT * FROM media_requests
+WHERE media_type = $1
+  AND tmdb_id = $2
+  AND status IN ('pending', 'approved');
+
+-- =============================================================================
+-- ListMediaRequests - List requests, newest first, optionally filtered
+-- =============================================================================
+-- name: ListMediaRequests :many
+SELECT * FROM media_requests
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND (sqlc.narg('user_id')::bigint IS NULL OR user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('media_type')::text IS NULL OR media_type = sqlc.narg('media_type'))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- =============================================================================
+-- CountMediaRequests - Count the requests ListMediaRequests filters to
+-- =============================================================================
+-- name: CountMediaRequests :one
+SELECT COUNT(*) FROM media_requests
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND (sqlc.narg('user_id')::bigint IS NULL OR user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('media_type')::text IS NULL OR media_type = sqlc.narg('media_type'));
+
+-- =============================================================================
+-- ApproveMediaRequest - Approve a pending request for the media item it added
+-- =============================================================================
+-- name: ApproveMediaRequest :one
+UPDATE media_requests
+SET
+    status = 'approved',
+    media_item_id = $2,
+    reviewed_by_user_id = $3,
+    reviewed_at = NOW()
+WHERE id = $1
+  AND status = 'pending'
+RETURNING *;
+
+-- =============================================================================
+-- DenyMediaRequest - Deny a pending request with a reason for the requester
+-- =============================================================================
+-- name: DenyMediaRequest :one
+UPDATE media_requests
+SET
+    status = 'denied',
+    denial_reason = $2,
+    reviewed_by_user_id = $3,
+    reviewed_at = NOW()
+WHERE id = $1
+  AND status = 'pending'
+RETURNING *;
+
+-- =============================================================================
+-- MarkMediaRequestsAvailable - Mark the approved requests of media items available
+-- =============================================================================
+-- name: MarkMediaRequestsAvailable :many
+UPDATE media_requests
+SET
+    status = 'available',
+    available_at = NOW()
+WHERE status = 'approved'
+  AND media_item_id = ANY(sqlc.arg('media_item_ids')::bigint[])
+RETURNING *;
+
+-- =============================================================================
+-- DeleteMediaRequest - Withdraw a request
+-- =============================================================================
+-- name: DeleteMediaRequest :execrows
+DELETE FROM media_requests
+WHERE id = $1;
+
+-- =============================================================================
+-- ListMediaRequestDownloads - Get the active downloads of a requested item,
+-- including those of its seasons and episodes
+-- =============================================================================
+-- name: ListMediaRequestDownloads :many
+SELECT d.id, d.name, d.status, d.progress, d.total_bytes, d.downloaded_bytes
+FROM downloads d
+JOIN media_items m ON m.id = d.media_item_id
+LEFT JOIN media_items p ON p.id = m.parent_id
+WHERE sqlc.arg('media_item_id')::bigint IN (m.id, m.parent_id, p.parent_id)
+  AND d.status IN ('queued', 'downloading', 'paused', 'processing')
+ORDER BY d.created_at;
@@ -0,0 +1,92 @@
+-- root_folders.sql
+-- SQLC queries for library root folders
+
+-- =============================================================================
+-- ListRootFolders - Get all root folders
+-- =============================================================================
+-- name: ListRootFolders :many
+SELECT * FROM root_folders
+ORDER BY media_kind, path;
+
+-- =============================================================================
+-- GetRootFolder - Get a root folder by ID
+-- =============================================================================
+-- name: GetRootFolder :one
+SELECT * FROM root_folders
+WHERE id = $1;
+
+-- =============================================================================
+-- GetDefaultRootFolder - Get the default root folder of a media kind
+-- =============================================================================
+-- name: GetDefaultRootFolder :one
+SELECT * FROM root_folders
+WHERE media_kind = $1 AND is_default = true;
+
+-- =============================================================================
+-- CountRootFoldersByKind - Count the root folders of a media kind
+-- =============================================================================
+-- name: CountRootFoldersByKind :one
+SELECT COUNT(*) FROM root_folders
+WHERE media_kind = $1;
+
+-- =============================================================================
+-- CreateRootFolder - Add a root folder
+-- =============================================================================
+-- name: CreateRootFolder :one
+INSERT INTO root_folders (
+    path,
+    media_kind,
+    is_default
+) VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+-- =============================================================================
+-- UpdateRootFolder - Replace a root folder's settings
+-- =============================================================================
+-- name: UpdateRootFolder :one
+UPDATE root_folders
+SET
+    path = $1,
+    media_kind = $2,
+    is_default = $3
+WHERE id = $4
+RETURNING *;
+
+-- =============================================================================
+-- ClearDefaultRootFolder - Unset the default root folder of a media kind
+-- =============================================================================
+-- name: ClearDefaultRootFolder :exec
+UPDATE root_folders
+SET is_default = false
+WHERE media_kind = $1 AND is_default = true;
+
+-- =============================================================================
+-- DeleteRootFolder - Remove a root folder (media items keep their files)
+-- =============================================================================
+-- name: DeleteRootFolder :exec
+DELETE FROM root_folders
+WHERE id = $1;
+
+-- =============================================================================
+-- CountMediaItemsInRootFolder - Count the media items living in a root folder
+-- =============================================================================
+-- name: CountMediaItemsInRootFolder :one
+SELECT COUNT(*) FROM media_items
+WHERE root_folder_id = $1;
+
+-- =============================================================================
+-- SetMediaItemRootFolder - Record the root folder a media item lives in
+-- =============================================================================
+-- name: SetMediaItemRootFolder :exec
+UPDATE media_items
+SET root_folder_id = $1
+WHERE id = $2;
+
+-- =============================================================================
+-- GetMonitoringRuleRootFolder - Get the root folder a monitoring rule imports to
+-- =============================================================================
+-- name: GetMonitoringRuleRootFolder :one
+SELECT root_folder_id FROM monitoring_rules
+WHERE media_item_id = $1;
@@ -22,6 +22,8 @@ SET
     files_scanned = 0,
     items_created = 0,
     items_updated = 0,
+    items_removed = 0,
+    directories_scanned = 0,
     errors = '[]'::jsonb,
     log = '[]'::jsonb
 WHERE id = 1
@@ -75,7 +77,9 @@ UPDATE scanner_state
 SET
     files_scanned = files_scanned + $1,
     items_created = items_created + $2,
-    items_updated = items_updated + $3
+    items_updated = items_updated + $3,
+    items_removed = items_removed + $4,
+    directories_scanned = directories_scanned + $5
 WHERE id = 1
 RETURNING *;
 
@@ -122,6 +126,8 @@ SET
     files_scanned = 0,
     items_created = 0,
     items_updated = 0,
+    items_removed = 0,
+    directories_scanned = 0,
     errors = '[]'::jsonb,
     log = '[]'::jsonb
 WHERE id = 1
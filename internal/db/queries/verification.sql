@@ -0,0 +1,116 @@
+-- verification.sql
+-- SQLC queries for media file integrity verification
+
+-- =============================================================================
+-- CreateVerificationRun - Start a new verification run
+-- =============================================================================
+-- name: CreateVerificationRun :one
+INSERT INTO verification_runs (
+    compute_hashes,
+    total_files
+) VALUES (
+    $1, $2
+)
+RETURNING *;
+
+-- =============================================================================
+-- GetLatestVerificationRun - Get the most recent verification run
+-- =============================================================================
+-- name: GetLatestVerificationRun :one
+SELECT * FROM verification_runs
+ORDER BY id DESC
+LIMIT 1;
+
+-- =============================================================================
+-- ResumeVerificationRun - Mark a cancelled or interrupted run as running again
+-- =============================================================================
+-- name: ResumeVerificationRun :one
+UPDATE verification_runs
+SET
+    status = 'running',
+    error_message = NULL,
+    finished_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- UpdateVerificationProgress - Save the position and counters of a run
+-- =============================================================================
+-- name: UpdateVerificationProgress :exec
+UPDATE verification_runs
+SET
+    last_file_id = $2,
+    files_checked = $3,
+    files_ok = $4,
+    files_missing = $5,
+    size_mismatches = $6,
+    hash_mismatches = $7,
+    hashes_computed = $8
+WHERE id = $1;
+
+-- =============================================================================
+-- FinishVerificationRun - Mark a run as completed, cancelled or failed
+-- =============================================================================
+-- name: FinishVerificationRun :one
+UPDATE verification_runs
+SET
+    status = $2,
+    error_message = $3,
+    finished_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- =============================================================================
+-- ListMediaFilesAfterID - Page through media files in ID order
+-- =============================================================================
+-- name: ListMediaFilesAfterID :many
+SELECT * FROM media_files
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- =============================================================================
+-- SetMediaFileVerification - Record the outcome of verifying a media file
+-- =============================================================================
+-- name: SetMediaFileVerification :exec
+UPDATE media_files
+SET
+    status = $2,
+    hash = $3,
+    verified_at = NOW()
+WHERE id = $1;
+
+-- =============================================================================
+-- CountMediaFilesByStatus - Count media files with a verification status
+-- =============================================================================
+-- name: CountMediaFilesByStatus :one
+SELECT COUNT(*) FROM media_files
+WHERE status = $1;
+
+-- =============================================================================
+-- ListUnhealthyMediaFiles - List media files that failed verification
+-- =============================================================================
+-- name: ListUnhealthyMediaFiles :many
+SELECT * FROM media_files
+WHERE status <> 'ok'
+ORDER BY status, path
+LIMIT $1 OFFSET $2;
+
+-- =============================================================================
+-- ClearEpisodeHasFile - Let the automatic search re-grab an episode
+-- =============================================================================
+-- Only when the episode has no other file that passed verification
+-- name: ClearEpisodeHasFile :exec
+UPDATE episode_monitoring
+SET
+    has_file = false,
+    file_id = NULL,
+    cutoff_met = false
+WHERE media_item_id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM media_files
+      LEFT JOIN media_file_episodes ON media_file_episodes.media_file_id = media_files.id
+      WHERE (media_files.media_item_id = $1 OR media_file_episodes.media_item_id = $1)
+        AND media_files.status = 'ok'
+        AND media_files.kind = 'media'
+  );
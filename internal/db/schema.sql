@@ -1,3 +1,16 @@
+-- Root folders - Library directories media is placed in, chosen per series or movie
+CREATE TABLE root_folders (
+    id BIGSERIAL PRIMARY KEY,
+    path TEXT NOT NULL UNIQUE,
+    media_kind TEXT NOT NULL,                             -- movie, tv, music, book
+    is_default BOOLEAN NOT NULL DEFAULT false,            -- Used when nothing else picks a root folder
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one default root folder per media kind
+CREATE UNIQUE INDEX idx_root_folders_default ON root_folders(media_kind) WHERE is_default;
+
 CREATE TABLE media_items (
     id              BIGSERIAL PRIMARY KEY,
     kind            TEXT NOT NULL,
@@ -7,8 +20,10 @@ CREATE TABLE media_items (
     external_ids    JSONB DEFAULT '{}'::jsonb,
     metadata        JSONB DEFAULT '{}'::jsonb,
     parent_id       BIGINT REFERENCES media_items(id) ON DELETE CASCADE,
+    root_folder_id  BIGINT REFERENCES root_folders(id) ON DELETE SET NULL, -- Where the item's files live
     created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at      TIMESTAMPTZ                           -- Set while the item is in the recycle bin (see media_deletions)
 );
 
 -- Indexes for performance
@@ -17,12 +32,15 @@ CREATE INDEX idx_media_items_title ON media_items(title);
 CREATE INDEX idx_media_items_sort_title ON media_items(sort_title);
 CREATE INDEX idx_media_items_parent_id ON media_items(parent_id) WHERE parent_id IS NOT NULL;
 CREATE INDEX idx_media_items_year ON media_items(year) WHERE year IS NOT NULL;
+CREATE INDEX idx_media_items_root_folder_id ON media_items(root_folder_id) WHERE root_folder_id IS NOT NULL;
 CREATE INDEX idx_media_items_external_ids ON media_items USING GIN(external_ids);
 CREATE INDEX idx_media_items_metadata ON media_items USING GIN(metadata);
+CREATE INDEX idx_media_items_deleted_at ON media_items(deleted_at) WHERE deleted_at IS NOT NULL;
 
--- Unique constraint for upsert operations
+-- Unique constraint for upsert operations. A movie is the same movie whichever
+-- collection it is in, so its parent isn't part of its key.
 CREATE UNIQUE INDEX media_items_natural_key_idx
-ON media_items(kind, title, COALESCE(year, -1), COALESCE(parent_id, -1));
+ON media_items(kind, title, COALESCE(year, -1), COALESCE(CASE WHEN kind = 'movie' THEN NULL ELSE parent_id END, -1));
 
 -- Global configuration table
 CREATE TABLE config (
@@ -119,12 +137,93 @@ CREATE TABLE media_files (
     path TEXT NOT NULL UNIQUE,
     size BIGINT,
     hash TEXT,
+    status TEXT NOT NULL DEFAULT 'ok', -- ok, missing, size_mismatch, hash_mismatch (set by verification)
+    verified_at TIMESTAMPTZ,
+    mediainfo JSONB, -- Streams and container details from ffprobe, NULL until probed
+    quality TEXT, -- e.g. "WEB-DL 1080p", from the release the file was imported from
+    release_group TEXT,
+    release_title TEXT, -- Full release name, e.g. Show.S01E05.1080p.WEB.H264-GROUP
+    kind TEXT NOT NULL DEFAULT 'media', -- media, or an extra file next to it: subtitle
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE INDEX media_files_item_idx ON media_files(media_item_id);
 CREATE INDEX media_files_path_idx ON media_files(path text_pattern_ops);
+CREATE INDEX media_files_status_idx ON media_files(status) WHERE status <> 'ok';
+
+-- Media file episodes - Further episodes a multi-episode file covers
+-- The file's media_item_id is its first episode; every other episode is linked here
+CREATE TABLE media_file_episodes (
+    media_file_id BIGINT NOT NULL REFERENCES media_files(id) ON DELETE CASCADE,
+    media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (media_file_id, media_item_id)
+);
+
+CREATE INDEX media_file_episodes_item_idx ON media_file_episodes(media_item_id);
+
+-- Media deletions - Manifest of a media item in the recycle bin, until it is
+-- restored or purged. The item and its descendants are soft-deleted. An item
+-- brought back by the scanner keeps its manifest until the recycled files are
+-- purged.
+CREATE TABLE media_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    item_ids BIGINT[] NOT NULL,                           -- The item and the descendants deleted with it
+    disabled_rule_ids BIGINT[] NOT NULL DEFAULT '{}',     -- Monitoring rules the deletion disabled
+    files JSONB NOT NULL DEFAULT '[]'::jsonb,             -- [{media_file_id, path, recycled_path, size}]; recycled_path is empty for files deleted outright
+    files_deleted BOOLEAN NOT NULL DEFAULT false,         -- Whether the files were moved out of the library
+    recycle_path TEXT,                                    -- Directory in the recycle bin holding the files
+    deleted_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX media_deletions_item_idx ON media_deletions(media_item_id);
+CREATE INDEX media_deletions_deleted_at_idx ON media_deletions(deleted_at);
+
+-- Media verification runs - Progress of integrity checks over media_files
+-- A cancelled or interrupted run resumes after last_file_id
+CREATE TABLE verification_runs (
+    id BIGSERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'running', -- running, completed, cancelled, failed
+    compute_hashes BOOLEAN NOT NULL DEFAULT FALSE,
+    last_file_id BIGINT NOT NULL DEFAULT 0,
+    total_files BIGINT NOT NULL DEFAULT 0,
+    files_checked INT NOT NULL DEFAULT 0,
+    files_ok INT NOT NULL DEFAULT 0,
+    files_missing INT NOT NULL DEFAULT 0,
+    size_mismatches INT NOT NULL DEFAULT 0,
+    hash_mismatches INT NOT NULL DEFAULT 0,
+    hashes_computed INT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_verification_runs_started_at ON verification_runs(started_at DESC);
+
+-- Library imports - Runs importing an already organized folder in place. Files
+-- are registered where they are, never moved or renamed. The walk is in path
+-- order, so a cancelled or interrupted run resumes after last_path.
+CREATE TABLE library_imports (
+    id BIGSERIAL PRIMARY KEY,
+    path TEXT NOT NULL,
+    kind TEXT NOT NULL,                     -- movie, tv, music, book
+    status TEXT NOT NULL DEFAULT 'running', -- running, completed, cancelled, failed
+    last_path TEXT NOT NULL DEFAULT '',
+    files_found INT NOT NULL DEFAULT 0,
+    files_imported INT NOT NULL DEFAULT 0,
+    items_created INT NOT NULL DEFAULT 0,
+    conflicts JSONB NOT NULL DEFAULT '[]'::jsonb, -- [{path, reason, media_item_id}] of files that weren't imported
+    error_message TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_library_imports_started_at ON library_imports(started_at DESC);
 
 -- Scanner state - Track library scanner status and progress
 CREATE TABLE scanner_state (
@@ -135,6 +234,8 @@ CREATE TABLE scanner_state (
     files_scanned INT NOT NULL DEFAULT 0,
     items_created INT NOT NULL DEFAULT 0,
     items_updated INT NOT NULL DEFAULT 0,
+    items_removed INT NOT NULL DEFAULT 0,
+    directories_scanned INT NOT NULL DEFAULT 0,
     errors JSONB NOT NULL DEFAULT '[]'::jsonb,
     log JSONB NOT NULL DEFAULT '[]'::jsonb,
     CONSTRAINT single_row_check CHECK (id = 1)
@@ -159,6 +260,8 @@ CREATE TABLE plugins (
     version TEXT NOT NULL DEFAULT '0.1.0',
     enabled BOOLEAN NOT NULL DEFAULT TRUE,
     capabilities JSONB NOT NULL DEFAULT '[]'::jsonb,
+    tags TEXT[] NOT NULL DEFAULT '{}',                    -- Indexers and downloaders with tags only serve media with a shared tag
+    config_version INTEGER NOT NULL DEFAULT 0,            -- Schema version the plugin's stored config was last migrated to
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -207,6 +310,13 @@ CREATE TABLE downloads (
     completed_at TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 
+    -- Post-processing: the phase a processing download is in (verifying,
+    -- repairing, extracting, importing), how far along it is, and when each
+    -- phase started and finished as [{phase, started_at, finished_at}]
+    phase TEXT,
+    phase_progress REAL,
+    phases JSONB,
+
     -- Plugin-specific metadata
     metadata JSONB DEFAULT '{}',
 
@@ -234,6 +344,55 @@ CREATE TABLE download_logs (
 CREATE INDEX idx_download_logs_download_id ON download_logs(download_id);
 CREATE INDEX idx_download_logs_created_at ON download_logs(created_at DESC);
 
+-- Import decisions - Failed imports awaiting manual resolution
+CREATE TABLE import_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    download_id TEXT REFERENCES downloads(id) ON DELETE SET NULL,
+    source_path TEXT NOT NULL,
+    media_item_id BIGINT REFERENCES media_items(id) ON DELETE SET NULL,
+
+    -- Attributes detected from the download (title, media_type, season, episode, quality, ...)
+    detected JSONB NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL,
+
+    -- 'pending' until the correct media item is supplied and the import succeeds
+    status TEXT NOT NULL DEFAULT 'pending',
+    resolved_media_item_id BIGINT REFERENCES media_items(id) ON DELETE SET NULL,
+    final_path TEXT,
+    resolved_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_import_decisions_pending_path ON import_decisions(source_path) WHERE status = 'pending';
+CREATE INDEX idx_import_decisions_download_id ON import_decisions(download_id);
+CREATE INDEX idx_import_decisions_status ON import_decisions(status, created_at DESC);
+
+-- Import jobs - Imports queued for the import workers, kept so they survive restarts
+CREATE TABLE import_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    download_id TEXT REFERENCES downloads(id) ON DELETE SET NULL,
+    source_path TEXT NOT NULL,
+    media_item_id BIGINT REFERENCES media_items(id) ON DELETE SET NULL,
+    request JSONB NOT NULL,                               -- The import request the job runs
+
+    status TEXT NOT NULL DEFAULT 'queued',                -- queued, running, retrying, completed, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),   -- When a queued or retrying job may run
+    last_error TEXT,
+    result JSONB,                                         -- The import result of a completed job
+
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_jobs_pending ON import_jobs(next_attempt_at, id) WHERE status IN ('queued', 'retrying');
+CREATE INDEX idx_import_jobs_download_id ON import_jobs(download_id);
+CREATE INDEX idx_import_jobs_finished_at ON import_jobs(finished_at) WHERE finished_at IS NOT NULL;
+
 -- =============================================================================
 -- Triggers
 -- =============================================================================
@@ -253,6 +412,11 @@ CREATE TRIGGER update_media_items_updated_at
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_root_folders_updated_at
+    BEFORE UPDATE ON root_folders
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_config_updated_at
     BEFORE UPDATE ON config
     FOR EACH ROW
@@ -278,11 +442,31 @@ CREATE TRIGGER update_media_files_updated_at
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_verification_runs_updated_at
+    BEFORE UPDATE ON verification_runs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_library_imports_updated_at
+    BEFORE UPDATE ON library_imports
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_plugins_updated_at
     BEFORE UPDATE ON plugins
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_import_decisions_updated_at
+    BEFORE UPDATE ON import_decisions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_import_jobs_updated_at
+    BEFORE UPDATE ON import_jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_quality_definitions_updated_at
     BEFORE UPDATE ON quality_definitions
     FOR EACH ROW
@@ -298,6 +482,16 @@ CREATE TRIGGER update_media_quality_updated_at
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_custom_formats_updated_at
+    BEFORE UPDATE ON custom_formats
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_monitoring_templates_updated_at
+    BEFORE UPDATE ON monitoring_templates
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_monitoring_rules_updated_at
     BEFORE UPDATE ON monitoring_rules
     FOR EACH ROW
@@ -337,6 +531,8 @@ CREATE TABLE quality_definitions (
     modifier TEXT,       -- Additional modifier (Remux, Proper, Repack, etc.)
     min_size BIGINT,     -- Minimum file size in bytes (for preferred size ranges)
     max_size BIGINT,     -- Maximum file size in bytes (for preferred size ranges)
+    min_mb_per_minute DOUBLE PRECISION, -- Minimum size per minute of runtime, used over min_size when the runtime is known
+    max_mb_per_minute DOUBLE PRECISION, -- Maximum size per minute of runtime, used over max_size when the runtime is known
     weight INTEGER NOT NULL DEFAULT 0, -- Weight for sorting (higher = better quality)
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
@@ -438,10 +634,53 @@ CREATE INDEX idx_quality_upgrade_history_media ON quality_upgrade_history(media_
 CREATE INDEX idx_quality_upgrade_history_download ON quality_upgrade_history(download_id);
 CREATE INDEX idx_quality_upgrade_history_created_at ON quality_upgrade_history(created_at DESC);
 
+-- Custom formats - Soft release preferences scored on top of the quality tier
+CREATE TABLE custom_formats (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    match_type TEXT NOT NULL DEFAULT 'terms', -- terms, regex
+    field TEXT NOT NULL DEFAULT 'title',       -- title, release_group, source, codec_video, codec_audio, or a release attribute name
+    pattern TEXT,                              -- Regular expression (match_type = regex)
+    terms TEXT[] NOT NULL DEFAULT '{}',        -- Case-insensitive terms, any of which matches (match_type = terms)
+    score INTEGER NOT NULL DEFAULT 0,          -- Added to the release score when matched (negative to penalize)
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for custom formats
+CREATE INDEX idx_custom_formats_enabled ON custom_formats(enabled);
+
 -- =============================================================================
 -- Monitoring & Automation Tables
 -- =============================================================================
 
+-- Monitoring templates - Named sets of rule settings picked when adding media, like "4K movies"
+CREATE TABLE monitoring_templates (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL DEFAULT '',                        -- movie or tv_series ('' = any kind)
+
+    -- The settings of the rules created from the template, as in monitoring_rules
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    quality_profile_id INTEGER REFERENCES quality_profiles(id) ON DELETE SET NULL,
+    root_folder_id BIGINT REFERENCES root_folders(id) ON DELETE SET NULL,
+    monitor_mode TEXT NOT NULL DEFAULT 'all',
+    series_type TEXT NOT NULL DEFAULT 'standard',
+    search_upgrades BOOLEAN NOT NULL DEFAULT false,
+    search_on_add BOOLEAN NOT NULL DEFAULT true,
+    automatic_search BOOLEAN NOT NULL DEFAULT true,
+    backlog_search BOOLEAN NOT NULL DEFAULT true,
+    prefer_season_packs BOOLEAN NOT NULL DEFAULT false,
+    minimum_seeders INTEGER NOT NULL DEFAULT 1,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    search_interval_minutes INTEGER NOT NULL DEFAULT 60,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 -- Monitoring rules - Define what media items to monitor and automatically search for
 CREATE TABLE monitoring_rules (
     id BIGSERIAL PRIMARY KEY,
@@ -450,9 +689,12 @@ CREATE TABLE monitoring_rules (
     -- Monitoring settings
     enabled BOOLEAN NOT NULL DEFAULT true,
     quality_profile_id INTEGER REFERENCES quality_profiles(id) ON DELETE SET NULL,
+    root_folder_id BIGINT REFERENCES root_folders(id) ON DELETE SET NULL, -- Where imports go (NULL = kind default)
 
     -- Monitoring mode for series/seasons
     monitor_mode TEXT NOT NULL DEFAULT 'all', -- all, future, missing, existing, first_season, latest_season, pilot, none
+    series_type TEXT NOT NULL DEFAULT 'standard', -- standard, anime (searched and matched by absolute episode number)
+    search_upgrades BOOLEAN NOT NULL DEFAULT false, -- Keep searching items whose file already meets the cutoff
 
     -- Search settings
     search_on_add BOOLEAN NOT NULL DEFAULT true,          -- Search immediately when monitoring is enabled
@@ -477,6 +719,7 @@ CREATE TABLE monitoring_rules (
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     created_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    template_id BIGINT REFERENCES monitoring_templates(id) ON DELETE SET NULL, -- Template the rule was created from
 
     UNIQUE(media_item_id)
 );
@@ -487,6 +730,7 @@ CREATE INDEX idx_monitoring_rules_next_search ON monitoring_rules(next_search_at
 CREATE INDEX idx_monitoring_rules_media_item ON monitoring_rules(media_item_id);
 CREATE INDEX idx_monitoring_rules_quality_profile ON monitoring_rules(quality_profile_id);
 CREATE INDEX idx_monitoring_rules_tags ON monitoring_rules USING GIN(tags);
+CREATE INDEX idx_monitoring_rules_template ON monitoring_rules(template_id) WHERE template_id IS NOT NULL;
 
 -- Episode monitoring - Fine-grained episode-level monitoring for TV series
 CREATE TABLE episode_monitoring (
@@ -497,6 +741,7 @@ CREATE TABLE episode_monitoring (
     -- Episode status
     has_file BOOLEAN NOT NULL DEFAULT false,              -- Does this episode have a file?
     file_id BIGINT REFERENCES media_files(id) ON DELETE SET NULL,
+    cutoff_met BOOLEAN NOT NULL DEFAULT false,            -- Does the file meet the quality profile's cutoff?
 
     -- Air date tracking
     air_date DATE,
@@ -526,7 +771,7 @@ CREATE TABLE search_history (
 
     -- Search details
     search_type TEXT NOT NULL,                            -- automatic, manual, rss, backlog
-    trigger_source TEXT,                                  -- user, scheduler, rss_sync, missing_check
+    trigger_source TEXT,                                  -- user, scheduler, rss_sync, missing_check, download_failed, manual
     query TEXT,                                           -- Search query used
 
     -- Results
@@ -567,7 +812,7 @@ CREATE TABLE blocklist (
     indexer_id TEXT,                                      -- Which indexer it came from
 
     -- Block reason
-    reason TEXT NOT NULL,                                 -- quality, fake, corrupted, failed_download, manual, etc.
+    reason TEXT NOT NULL,                                 -- quality, fake, corrupted, download_failed, manual, etc.
     message TEXT,                                         -- Additional details
 
     -- Block type
@@ -593,7 +838,9 @@ CREATE INDEX idx_blocklist_created_at ON blocklist(created_at DESC);
 -- RSS sync state - Track RSS feed synchronization for automatic detection
 CREATE TABLE rss_sync_state (
     id BIGSERIAL PRIMARY KEY,
-    indexer_id TEXT NOT NULL,                             -- Plugin ID of the indexer
+    indexer_id TEXT NOT NULL,                             -- Indexer the feed belongs to, or the plugin ID when it has one feed
+    plugin_id TEXT NOT NULL DEFAULT '',                   -- Indexer plugin serving the feed
+    indexer_name TEXT NOT NULL DEFAULT '',
 
     -- Sync tracking
     last_sync_at TIMESTAMPTZ,
@@ -604,9 +851,15 @@ CREATE TABLE rss_sync_state (
     total_syncs INTEGER DEFAULT 0,
     total_items_found INTEGER DEFAULT 0,
     total_items_grabbed INTEGER DEFAULT 0,
+    last_items_found INTEGER NOT NULL DEFAULT 0,          -- New releases in the last sync
+    last_items_grabbed INTEGER NOT NULL DEFAULT 0,
     consecutive_failures INTEGER DEFAULT 0,
     last_error TEXT,
 
+    -- High-water mark: the newest publish date and the GUIDs of the last feed
+    last_release_at TIMESTAMPTZ,
+    seen_guids TEXT[] NOT NULL DEFAULT '{}',
+
     -- State
     enabled BOOLEAN NOT NULL DEFAULT true,
 
@@ -626,7 +879,8 @@ CREATE TABLE calendar_events (
     media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
 
     -- Event details
-    event_type TEXT NOT NULL,                             -- air_date, digital_release, physical_release
+    event_type TEXT NOT NULL,                             -- episode_air, digital_release, physical_release
+    event_key TEXT NOT NULL DEFAULT '',                   -- Distinguishes events of one item, e.g. S01E05 on a series
     event_date DATE NOT NULL,
     event_datetime_utc TIMESTAMPTZ,
 
@@ -642,7 +896,9 @@ CREATE TABLE calendar_events (
     metadata JSONB DEFAULT '{}'::jsonb,                   -- Additional event data
 
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE(media_item_id, event_type, event_key)
 );
 
 -- Indexes for calendar events
@@ -651,6 +907,38 @@ CREATE INDEX idx_calendar_events_date ON calendar_events(event_date);
 CREATE INDEX idx_calendar_events_monitored ON calendar_events(monitored, event_date) WHERE monitored = true;
 CREATE INDEX idx_calendar_events_missing ON calendar_events(monitored, has_file, event_date) WHERE monitored = true AND has_file = false;
 
+-- Keep calendar file flags in sync as files are imported or removed
+CREATE OR REPLACE FUNCTION update_calendar_event_has_file()
+RETURNS TRIGGER AS $$
+DECLARE
+    item_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        item_id := OLD.media_item_id;
+    ELSE
+        item_id := NEW.media_item_id;
+    END IF;
+
+    UPDATE calendar_events
+    SET has_file = EXISTS (SELECT 1 FROM media_files WHERE media_item_id = item_id)
+            OR EXISTS (SELECT 1 FROM media_file_episodes WHERE media_item_id = item_id),
+        downloaded = downloaded OR (TG_OP = 'INSERT')
+    WHERE media_item_id = item_id;
+
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_calendar_events_on_media_file
+    AFTER INSERT OR DELETE ON media_files
+    FOR EACH ROW
+    EXECUTE FUNCTION update_calendar_event_has_file();
+
+CREATE TRIGGER update_calendar_events_on_media_file_episode
+    AFTER INSERT OR DELETE ON media_file_episodes
+    FOR EACH ROW
+    EXECUTE FUNCTION update_calendar_event_has_file();
+
 -- Scheduler jobs - Track background job execution
 CREATE TABLE scheduler_jobs (
     id BIGSERIAL PRIMARY KEY,
@@ -714,10 +1002,278 @@ CREATE TABLE scheduler_job_history (
 CREATE INDEX idx_scheduler_job_history_job ON scheduler_job_history(job_id, created_at DESC);
 CREATE INDEX idx_scheduler_job_history_created_at ON scheduler_job_history(created_at DESC);
 
+-- =============================================================================
+-- Notification Tables
+-- =============================================================================
+
+-- Notifiers - Webhook, Discord and Telegram targets for event notifications
+CREATE TABLE notifiers (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,                                   -- webhook, discord, telegram
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    events TEXT[] NOT NULL DEFAULT '{}',                  -- Subscribed event types
+    settings JSONB NOT NULL DEFAULT '{}'::jsonb,          -- Type-specific settings (URLs, tokens)
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE, -- Personal notifier of a user (NULL = server-wide)
+    tags TEXT[] NOT NULL DEFAULT '{}',                    -- Only fire for events on media with a shared tag (empty = all)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notifiers_user ON notifiers(user_id) WHERE user_id IS NOT NULL;
+
+CREATE TRIGGER update_notifiers_updated_at
+    BEFORE UPDATE ON notifiers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Notification deliveries - Log of every notification sent, including retries
+CREATE TABLE notification_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    notifier_id BIGINT NOT NULL REFERENCES notifiers(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    status TEXT NOT NULL,                                 -- delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 1,
+    error_message TEXT,
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for notification deliveries
+CREATE INDEX idx_notification_deliveries_notifier ON notification_deliveries(notifier_id, created_at DESC);
+
+-- =============================================================================
+-- Media Servers
+-- =============================================================================
+
+-- Media servers - Plex and Jellyfin servers told to scan the folders files are imported into
+CREATE TABLE media_servers (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('plex', 'jellyfin')),
+    url TEXT NOT NULL,
+    token TEXT NOT NULL,                                 -- Encrypted with the config secrets' keyring
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    sections JSONB NOT NULL DEFAULT '[]'::jsonb,         -- [{section_id, media_kind, path_prefix}] library sections to scan
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_media_servers_updated_at
+    BEFORE UPDATE ON media_servers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- =============================================================================
+-- Media Requests
+-- =============================================================================
+
+-- Media requests - Movies and series users asked for, approved or denied by an admin
+CREATE TABLE media_requests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    media_type TEXT NOT NULL,                             -- movie, tv
+    tmdb_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    year INTEGER,
+    poster_url TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',               -- pending, approved, denied, available
+    denial_reason TEXT,                                   -- Shown to the requester
+    media_item_id BIGINT REFERENCES media_items(id) ON DELETE SET NULL, -- Set on approval
+    reviewed_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    available_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for media requests
+CREATE INDEX idx_media_requests_user ON media_requests(user_id, created_at DESC);
+CREATE INDEX idx_media_requests_status ON media_requests(status, created_at DESC);
+CREATE INDEX idx_media_requests_media_item ON media_requests(media_item_id) WHERE media_item_id IS NOT NULL;
+-- A title can only be requested again once its request was denied
+CREATE UNIQUE INDEX idx_media_requests_open ON media_requests(media_type, tmdb_id) WHERE status IN ('pending', 'approved');
+
+CREATE TRIGGER update_media_requests_updated_at
+    BEFORE UPDATE ON media_requests
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- =============================================================================
+-- History
+-- =============================================================================
+
+-- History events - What Nimbus did: grabs, downloads, imports, upgrades,
+-- deletions and monitoring searches
+CREATE TABLE history_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,                             -- grabbed, download_completed, download_failed, imported, import_failed, upgraded, deleted, restored, searched, metadata_refreshed, renamed
+    media_item_id BIGINT,                                 -- Not a foreign key, so the history of deleted items is kept
+    download_id TEXT,
+    title TEXT NOT NULL,                                  -- Release, file or media title the event is about
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,              -- Event details, e.g. source and destination of an import
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for history events
+CREATE INDEX idx_history_events_created_at ON history_events(created_at DESC);
+CREATE INDEX idx_history_events_media_item ON history_events(media_item_id, id DESC) WHERE media_item_id IS NOT NULL;
+CREATE INDEX idx_history_events_type ON history_events(event_type, id DESC);
+
+-- =============================================================================
+-- Import Lists
+-- =============================================================================
+
+-- Import lists - External lists (e.g. a Trakt watchlist) whose entries are added
+-- to the library and monitored
+CREATE TABLE import_lists (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,                                   -- trakt
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    settings JSONB NOT NULL DEFAULT '{}'::jsonb,          -- Source-specific, e.g. the Trakt client ID and list; credentials are config secrets
+    quality_profile_id INTEGER REFERENCES quality_profiles(id) ON DELETE SET NULL, -- Of the monitoring rules created for entries
+    root_folder_id BIGINT REFERENCES root_folders(id) ON DELETE SET NULL,
+    search_on_add BOOLEAN NOT NULL DEFAULT TRUE,          -- Search for new entries right away
+    removal_action TEXT NOT NULL DEFAULT 'ignore',        -- ignore, unmonitor, delete: what happens to items removed from the list
+    sync_interval_minutes INTEGER NOT NULL DEFAULT 360,
+    last_sync_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT import_lists_removal_action_check CHECK (removal_action IN ('ignore', 'unmonitor', 'delete'))
+);
+
+CREATE TRIGGER update_import_lists_updated_at
+    BEFORE UPDATE ON import_lists
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Import list entries - The entries of a list seen by the last sync, so new and
+-- removed entries can be told apart
+CREATE TABLE import_list_entries (
+    list_id BIGINT NOT NULL REFERENCES import_lists(id) ON DELETE CASCADE,
+    media_type TEXT NOT NULL,                             -- movie, tv
+    tmdb_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    media_item_id BIGINT REFERENCES media_items(id) ON DELETE SET NULL,
+    added_by_list BOOLEAN NOT NULL DEFAULT FALSE,         -- The media item was created for this entry
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, media_type, tmdb_id)
+);
+
+-- Import list runs - One sync of a list and its counts
+CREATE TABLE import_list_runs (
+    id BIGSERIAL PRIMARY KEY,
+    list_id BIGINT NOT NULL REFERENCES import_lists(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'running',               -- running, success, failed
+    added INTEGER NOT NULL DEFAULT 0,
+    existing INTEGER NOT NULL DEFAULT 0,                  -- Skipped, already in the library
+    failed INTEGER NOT NULL DEFAULT 0,                    -- Entries that couldn't be looked up or added
+    removed INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,                                   -- Why the whole sync failed
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_list_runs_list ON import_list_runs(list_id, id DESC);
+
+-- Import list run items - What a sync did with each new or removed entry
+CREATE TABLE import_list_run_items (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES import_list_runs(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,                                 -- added, existing, failed, removed
+    media_type TEXT NOT NULL,
+    tmdb_id TEXT,                                         -- NULL when the entry has none
+    title TEXT NOT NULL,
+    year INTEGER,
+    media_item_id BIGINT,                                 -- Not a foreign key, so runs keep items deleted since
+    message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_list_run_items_run ON import_list_run_items(run_id, id);
+
+-- =============================================================================
+-- Tags
+-- =============================================================================
+
+-- Tags - Labels shared by monitoring rules, indexers, downloaders and notifiers.
+-- They are referenced by name from the tags columns of those tables.
+CREATE TABLE tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,                            -- Lowercase
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notifiers_tags ON notifiers USING GIN(tags);
+CREATE INDEX idx_plugins_tags ON plugins USING GIN(tags);
+
+-- =============================================================================
+-- Watch State
+-- =============================================================================
+
+-- Watched media - The movies and episodes each user has watched. Series and
+-- seasons are watched when all their episodes are.
+CREATE TABLE watched_media (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    watched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, media_item_id)
+);
+
+CREATE INDEX idx_watched_media_item ON watched_media(media_item_id);
+
+-- Playback progress - Where each user stopped playing a movie or episode, as
+-- reported by players. Cleared once the item is watched.
+CREATE TABLE playback_progress (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    position_seconds INTEGER NOT NULL,
+    duration_seconds INTEGER,                             -- NULL when neither the player nor the metadata know it
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, media_item_id)
+);
+
+CREATE INDEX idx_playback_progress_recent ON playback_progress(user_id, updated_at DESC);
+
+-- =============================================================================
+-- Duplicates
+-- =============================================================================
+
+-- Media duplicates - Pairs of media items of the same kind found to be the same
+-- by the duplicate_detection job, replaced on each run. A merge removes the
+-- pairs of the removed item with it.
+CREATE TABLE media_duplicates (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    media_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,       -- The older item, by ID
+    duplicate_item_id BIGINT NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,                                 -- external_id or title
+    detail TEXT,                                          -- The shared external ID, e.g. tmdb:603
+    similarity REAL NOT NULL,                             -- Of the normalized titles, 1 when the same
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (media_item_id, duplicate_item_id)
+);
+
+CREATE INDEX idx_media_duplicates_duplicate ON media_duplicates(duplicate_item_id);
+
 -- =============================================================================
 -- Helper Functions
 -- =============================================================================
 
+-- Parse a YYYY-MM-DD day of media metadata, NULL when it isn't one
+CREATE OR REPLACE FUNCTION metadata_date(value TEXT)
+RETURNS DATE AS $$
+BEGIN
+    IF value !~ '^\d{4}-\d{2}-\d{2}$' THEN
+        RETURN NULL;
+    END IF;
+    RETURN value::date;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
 -- Get or create scanner state
 CREATE OR REPLACE FUNCTION get_scanner_state()
 RETURNS scanner_state AS $$
@@ -781,6 +1337,51 @@ INSERT INTO config (key, value, metadata) VALUES
         'type', 'multi',
         'values', jsonb_build_array('tv', 'movie', 'music', 'book')
     )),
+    ('library.watch_enabled', 'true', jsonb_build_object(
+        'title', 'Watch Library Folders',
+        'description', 'Scan the movie and TV folders on startup and pick up files added, renamed or deleted in them',
+        'type', 'boolean'
+    )),
+    ('library.recycle_bin', '""', jsonb_build_object(
+        'title', 'Recycle Bin Path',
+        'description', 'Files of deleted media and files replaced by quality upgrades are moved here instead of being deleted (empty = delete)',
+        'type', 'text'
+    )),
+    ('library.recycle_bin_retention_days', '7', jsonb_build_object(
+        'title', 'Recycle Bin Retention',
+        'description', 'Days deleted media can be restored before it and its recycled files are purged (0 = keep forever)',
+        'type', 'number'
+    )),
+    ('library.ffprobe_path', '"ffprobe"', jsonb_build_object(
+        'title', 'FFprobe Path',
+        'description', 'Path to the ffprobe binary used to read codecs, resolution and audio tracks of media files (media info is skipped if it is not found)',
+        'type', 'text'
+    )),
+    ('library.mediainfo_concurrency', '2', jsonb_build_object(
+        'title', 'Media Info Concurrency',
+        'description', 'Number of files probed at once when refreshing media info',
+        'type', 'number'
+    )),
+
+    -- Images
+    ('images.cache_path', '"/var/lib/nimbus/images"', jsonb_build_object(
+        'title', 'Image Cache Path',
+        'description', 'Directory posters, backdrops and episode stills are cached in, with resized variants',
+        'type', 'text'
+    )),
+    ('images.proxy_enabled', 'true', jsonb_build_object(
+        'title', 'Serve Artwork From Cache',
+        'description', 'Point artwork URLs in the media API at the local image cache instead of the metadata provider (takes effect after a restart)',
+        'type', 'boolean'
+    )),
+
+    -- Metadata
+    ('metadata.preferred_provider', '"tmdb"', jsonb_build_object(
+        'title', 'Preferred Metadata Provider',
+        'description', 'Provider new movies and TV items are looked up with first; the other is tried when it finds nothing',
+        'type', 'select',
+        'values', jsonb_build_array('tmdb', 'tvdb')
+    )),
 
     -- Downloads
     ('download.tmp_path', '"/tmp/downloads"', jsonb_build_object(
@@ -808,6 +1409,46 @@ INSERT INTO config (key, value, metadata) VALUES
         'type', 'text'
     )),
 
+    -- Monitoring
+    ('monitoring.search_concurrency', '2', jsonb_build_object(
+        'title', 'Search Concurrency',
+        'description', 'Maximum number of automatic indexer searches running at the same time',
+        'type', 'number',
+        'category', 'monitoring',
+        'section', 'Automatic Search'
+    )),
+    ('monitoring.movie_availability', '"digital"', jsonb_build_object(
+        'title', 'Movie Availability',
+        'description', 'Release a monitored movie is searched from: cinema, digital or physical. Movies are skipped by the automatic search until then.',
+        'type', 'select',
+        'values', jsonb_build_array('cinema', 'digital', 'physical'),
+        'category', 'monitoring',
+        'section', 'Automatic Search'
+    )),
+    ('monitoring.movie_prerelease_days', '1', jsonb_build_object(
+        'title', 'Movie Pre-release Window (days)',
+        'description', 'How many days before its release date a movie starts being searched',
+        'type', 'number',
+        'category', 'monitoring',
+        'section', 'Automatic Search'
+    )),
+    ('monitoring.blocklist_expiry_hours', '168', jsonb_build_object(
+        'title', 'Failed Download Blocklist Expiry (hours)',
+        'description', 'How long the release of a failed download stays blocked before it may be grabbed again',
+        'type', 'number',
+        'category', 'monitoring',
+        'section', 'Failed Downloads'
+    )),
+
+    -- Requests
+    ('requests.quality_profile', '"HD-1080p"', jsonb_build_object(
+        'title', 'Request Quality Profile',
+        'description', 'Name of the quality profile approved requests are monitored with (empty = no profile)',
+        'type', 'text',
+        'category', 'monitoring',
+        'section', 'Requests'
+    )),
+
     -- Download naming - Movies
     ('downloads.movie_naming_format', '"{Movie Title} ({Release Year})"', jsonb_build_object(
         'title', 'Movie File Naming Format',
@@ -854,6 +1495,29 @@ INSERT INTO config (key, value, metadata) VALUES
         'section', 'TV Naming'
     )),
 
+    -- Music naming
+    ('downloads.music_naming_format', '"{track:00} - {Track Title}"', jsonb_build_object(
+        'title', 'Music Track Naming Format',
+        'description', 'Template for music track files. Available tokens: {Track Title}, {Track}, {track:00}, {Artist Name}, {Album Title}, {Release Year}, {Quality}',
+        'type', 'text',
+        'category', 'downloads',
+        'section', 'Music Naming'
+    )),
+    ('downloads.music_artist_folder_format', '"{Artist Name}"', jsonb_build_object(
+        'title', 'Music Artist Folder Format',
+        'description', 'Template for artist folder names. Available tokens: {Artist Name}',
+        'type', 'text',
+        'category', 'downloads',
+        'section', 'Music Naming'
+    )),
+    ('downloads.music_album_folder_format', '"{Album Title} ({Release Year})"', jsonb_build_object(
+        'title', 'Music Album Folder Format',
+        'description', 'Template for album folder names within the artist folder. Available tokens: {Album Title}, {Artist Name}, {Release Year}',
+        'type', 'text',
+        'category', 'downloads',
+        'section', 'Music Naming'
+    )),
+
     -- Folder structure
     ('downloads.create_series_folder', 'true', jsonb_build_object(
         'title', 'Create Series Folder',
@@ -885,6 +1549,13 @@ INSERT INTO config (key, value, metadata) VALUES
         'category', 'downloads',
         'section', 'File Management'
     )),
+    ('downloads.rename_tracks', 'true', jsonb_build_object(
+        'title', 'Rename Tracks',
+        'description', 'Automatically rename downloaded music tracks to match naming format',
+        'type', 'boolean',
+        'category', 'downloads',
+        'section', 'File Management'
+    )),
     ('downloads.replace_illegal_characters', 'true', jsonb_build_object(
         'title', 'Replace Illegal Characters',
         'description', 'Replace characters that are illegal in filenames with legal alternatives',
@@ -992,6 +1663,27 @@ INSERT INTO config (key, value, metadata) VALUES
         'category', 'downloads',
         'section', 'Importing'
     )),
+    ('downloads.stability_check_interval', '10', jsonb_build_object(
+        'title', 'Stability Check Interval (seconds)',
+        'description', 'How long a file must keep the same size before it is imported, so files still being written are not imported truncated (0 to skip)',
+        'type', 'number',
+        'category', 'downloads',
+        'section', 'Importing'
+    )),
+    ('downloads.import_concurrency', '2', jsonb_build_object(
+        'title', 'Concurrent Imports',
+        'description', 'How many imports run at the same time',
+        'type', 'number',
+        'category', 'downloads',
+        'section', 'Importing'
+    )),
+    ('downloads.import_max_attempts', '5', jsonb_build_object(
+        'title', 'Import Attempts',
+        'description', 'How often an import failing on a transient filesystem error (I/O error, stale NFS handle, resource temporarily unavailable) or a file still being written is tried before it is left for manual import',
+        'type', 'number',
+        'category', 'downloads',
+        'section', 'Importing'
+    )),
 
     -- Advanced
     ('downloads.set_permissions', 'false', jsonb_build_object(
@@ -1191,7 +1883,8 @@ INSERT INTO scheduler_jobs (job_name, job_type, interval_minutes, enabled, confi
     -- RSS sync job - Check RSS feeds for new releases every 15 minutes
     ('rss_sync', 'recurring', 15, true, jsonb_build_object(
         'description', 'Synchronize RSS feeds from all enabled indexers',
-        'max_items_per_sync', 100
+        'max_items_per_sync', 100,
+        'jitter_seconds', 60
     )),
 
     -- Backlog search job - Search for missing/wanted items hourly
@@ -1214,6 +1907,11 @@ INSERT INTO scheduler_jobs (job_name, job_type, interval_minutes, enabled, confi
         'use_metadata_apis', true
     )),
 
+    -- Metadata refresh job - Add new seasons and episodes of series from TMDB
+    ('metadata_refresh', 'recurring', 720, true, jsonb_build_object(
+        'description', 'Refresh series from TMDB, adding new seasons and episodes'
+    )),
+
     -- Download cleanup job - Clean up old completed/failed downloads
     ('download_cleanup', 'recurring', 1440, true, jsonb_build_object(
         'description', 'Remove old download records and clean up temporary files',
@@ -1227,10 +1925,53 @@ INSERT INTO scheduler_jobs (job_name, job_type, interval_minutes, enabled, confi
         'cleanup_threshold_days', 30
     )),
 
+    -- Image cache cleanup job - Remove cached artwork of deleted media items
+    ('image_cache_cleanup', 'recurring', 1440, true, jsonb_build_object(
+        'description', 'Remove cached images of deleted media items and replaced artwork'
+    )),
+
+    -- Library scan job - Scan the library folders for new and removed files
+    ('library_scan', 'recurring', 1440, false, jsonb_build_object(
+        'description', 'Scan the library folders for new, changed and removed files'
+    )),
+
+    -- Media verification job - Check library files still exist and are intact
+    ('media_verification', 'recurring', 10080, false, jsonb_build_object(
+        'description', 'Verify media files still exist and match their recorded size and hash',
+        'compute_hashes', false
+    )),
+
     -- Quality upgrade search - Search for quality upgrades for existing media
     ('quality_upgrade_search', 'recurring', 720, true, jsonb_build_object(
         'description', 'Search for quality upgrades for media below cutoff',
         'max_items_per_run', 25,
         'min_age_days', 7
+    )),
+
+    -- Cutoff recompute - Work out again which episode files meet their profile's cutoff
+    ('cutoff_recompute', 'recurring', 1440, true, jsonb_build_object(
+        'description', 'Recompute which episodes meet their quality profile cutoff, also run when a profile changes'
+    )),
+
+    -- Import list sync - Add and monitor new entries of import lists that are due
+    ('import_list_sync', 'recurring', 15, true, jsonb_build_object(
+        'description', 'Sync enabled import lists whose sync interval has passed'
+    )),
+
+    -- Recycle bin cleanup - Purge deleted media past the retention window
+    ('recycle_bin_cleanup', 'recurring', 1440, true, jsonb_build_object(
+        'description', 'Purge deleted media and its recycled files older than library.recycle_bin_retention_days'
+    )),
+
+    -- Duplicate detection - Flag media items that are in the library twice
+    ('duplicate_detection', 'recurring', 1440, true, jsonb_build_object(
+        'description', 'Flag media items of the same kind with the same external IDs or similar titles and year'
+    )),
+
+    -- Configuration backup - Write a backup to a directory, keeping the newest ones
+    ('config_backup', 'recurring', 1440, false, jsonb_build_object(
+        'description', 'Write a backup of the configuration to a directory and prune old backups',
+        'directory', '/var/lib/nimbus/backups',
+        'keep', 7
     ))
 ON CONFLICT (job_name) DO NOTHING;
@@ -0,0 +1,199 @@
+package downloader
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// throughputWindow is how far back the average throughput of a downloader
+// looks
+const throughputWindow = 5 * time.Minute
+
+// progressSample is how much of a download was downloaded at a point in time
+type progressSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// transfer is the number of bytes downloaded between two progress samples
+type transfer struct {
+	from, to time.Time
+	bytes    int64
+}
+
+// throughputTracker averages how fast each downloader plugin has been
+// downloading, from the successive progress samples of its downloads
+type throughputTracker struct {
+	mu        sync.Mutex
+	last      map[string]progressSample // Download ID -> latest sample
+	transfers map[string][]transfer     // Plugin ID -> transfers within the window
+}
+
+func newThroughputTracker() *throughputTracker {
+	return &throughputTracker{
+		last:      make(map[string]progressSample),
+		transfers: make(map[string][]transfer),
+	}
+}
+
+// record takes a progress sample of a download. Only downloads that are
+// downloading count towards the throughput.
+func (t *throughputTracker) record(pluginID, downloadID, status string, downloaded int64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if status != "downloading" {
+		delete(t.last, downloadID)
+		return
+	}
+
+	prev, ok := t.last[downloadID]
+	t.last[downloadID] = progressSample{at: at, bytes: downloaded}
+	if !ok || !at.After(prev.at) || downloaded <= prev.bytes {
+		return
+	}
+	t.transfers[pluginID] = append(t.prune(pluginID, at), transfer{from: prev.at, to: at, bytes: downloaded - prev.bytes})
+}
+
+// prune drops the transfers of a plugin that ended before the window
+func (t *throughputTracker) prune(pluginID string, now time.Time) []transfer {
+	transfers := t.transfers[pluginID]
+	cutoff := now.Add(-throughputWindow)
+	i := 0
+	for i < len(transfers) && transfers[i].to.Before(cutoff) {
+		i++
+	}
+	return transfers[i:]
+}
+
+// rate returns the average bytes per second a plugin downloaded within the
+// window, or 0 when it downloaded nothing
+func (t *throughputTracker) rate(pluginID string, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	transfers := t.prune(pluginID, now)
+	t.transfers[pluginID] = transfers
+	if len(transfers) == 0 {
+		return 0
+	}
+
+	start := transfers[0].from
+	var bytes int64
+	for _, tr := range transfers {
+		bytes += tr.bytes
+		if tr.from.Before(start) {
+			start = tr.from
+		}
+	}
+	elapsed := now.Sub(start).Seconds()
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	return float64(bytes) / elapsed
+}
+
+// remainingBytes returns how much of a download is left, and false when its
+// size isn't known
+func remainingBytes(d *Download) (int64, bool) {
+	if d.TotalBytes == nil || *d.TotalBytes <= 0 {
+		return 0, false
+	}
+	return max(*d.TotalBytes-d.DownloadedBytes, 0), true
+}
+
+// estimateQueue estimates when the queued downloads of each plugin start and
+// when they and the downloads in progress complete. Downloads ahead in a
+// plugin's queue are assumed to take its recent average throughput, or the
+// current speed of its downloads when it has none yet. A queued download gets
+// no estimate when the size of one ahead of it is unknown. It returns when the
+// last download is estimated to complete, if any.
+func estimateQueue(downloads []Download, rates map[string]float64, now time.Time) *time.Time {
+	byPlugin := make(map[string][]int)
+	for i := range downloads {
+		downloads[i].EstimatedStartAt = nil
+		downloads[i].EstimatedCompletionAt = nil
+		switch downloads[i].Status {
+		case "downloading", "queued":
+			byPlugin[downloads[i].PluginID] = append(byPlugin[downloads[i].PluginID], i)
+		}
+	}
+
+	var last *time.Time
+	later := func(t time.Time) {
+		if last == nil || t.After(*last) {
+			last = &t
+		}
+	}
+
+	for pluginID, indices := range byPlugin {
+		rate := rates[pluginID]
+		var speed int64
+		for _, i := range indices {
+			if downloads[i].Status == "downloading" {
+				speed += downloads[i].Speed
+			}
+		}
+		if rate <= 0 {
+			rate = float64(speed)
+		}
+
+		// Downloads in progress first, then the queue in order
+		sort.SliceStable(indices, func(a, b int) bool {
+			return queueRank(&downloads[indices[a]]) < queueRank(&downloads[indices[b]])
+		})
+
+		var ahead int64
+		known := true
+		for _, i := range indices {
+			d := &downloads[i]
+			remaining, ok := remainingBytes(d)
+
+			if d.Status == "downloading" {
+				if !ok {
+					known = false
+					continue
+				}
+				ahead += remaining
+				if d.Speed > 0 {
+					done := now.Add(bytesDuration(remaining, float64(d.Speed)))
+					d.EstimatedCompletionAt = &done
+					later(done)
+				}
+				continue
+			}
+
+			if !known || !ok || rate <= 0 {
+				known = false
+				continue
+			}
+			start := now.Add(bytesDuration(ahead, rate))
+			ahead += remaining
+			done := now.Add(bytesDuration(ahead, rate))
+			d.EstimatedStartAt = &start
+			d.EstimatedCompletionAt = &done
+			later(done)
+		}
+	}
+
+	return last
+}
+
+// queueRank orders downloads in progress before queued ones, and queued ones
+// by their position. Queued downloads without a position go last.
+func queueRank(d *Download) int {
+	if d.Status == "downloading" {
+		return 0
+	}
+	if d.QueuePosition != nil {
+		return *d.QueuePosition
+	}
+	return int(^uint(0) >> 1)
+}
+
+// bytesDuration returns how long downloading a number of bytes takes at a rate
+// in bytes per second
+func bytesDuration(bytes int64, rate float64) time.Duration {
+	return time.Duration(float64(bytes) / rate * float64(time.Second)).Round(time.Second)
+}
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughputTracker(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newThroughputTracker()
+
+	// Two downloads at once, 1 MB/s each over 10 seconds
+	for i := 0; i <= 10; i++ {
+		at := now.Add(time.Duration(i) * time.Second)
+		tracker.record("nzb", "a", "downloading", int64(i)<<20, at)
+		tracker.record("nzb", "b", "downloading", int64(i)<<20, at)
+	}
+	if got, want := tracker.rate("nzb", now.Add(10*time.Second)), float64(2<<20); got != want {
+		t.Errorf("rate = %v, want %v", got, want)
+	}
+
+	if got := tracker.rate("torrent", now); got != 0 {
+		t.Errorf("rate of a plugin without samples = %v, want 0", got)
+	}
+
+	// Samples drop out of the window
+	if got := tracker.rate("nzb", now.Add(10*time.Second+throughputWindow+time.Second)); got != 0 {
+		t.Errorf("rate after the window = %v, want 0", got)
+	}
+}
+
+func TestEstimateQueue(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	size := func(n int64) *int64 { return &n }
+	position := func(n int) *int { return &n }
+
+	downloads := []Download{
+		{ID: "second", PluginID: "nzb", Status: "queued", TotalBytes: size(600), QueuePosition: position(2)},
+		{ID: "active", PluginID: "nzb", Status: "downloading", TotalBytes: size(1000), DownloadedBytes: 400, Speed: 20},
+		{ID: "first", PluginID: "nzb", Status: "queued", TotalBytes: size(300), QueuePosition: position(1)},
+		{ID: "done", PluginID: "nzb", Status: "completed", TotalBytes: size(100), DownloadedBytes: 100},
+		{ID: "unknown", PluginID: "torrent", Status: "downloading", Speed: 10},
+		{ID: "after-unknown", PluginID: "torrent", Status: "queued", TotalBytes: size(100), QueuePosition: position(1)},
+	}
+
+	last := estimateQueue(downloads, map[string]float64{"nzb": 10}, now)
+
+	at := func(seconds int) *time.Time {
+		t := now.Add(time.Duration(seconds) * time.Second)
+		return &t
+	}
+	want := map[string][2]*time.Time{
+		"active":        {nil, at(30)},    // 600 bytes left at its own 20 B/s
+		"first":         {at(60), at(90)}, // After the 600 bytes left of active, at 10 B/s
+		"second":        {at(90), at(150)},
+		"done":          {nil, nil},
+		"unknown":       {nil, nil},
+		"after-unknown": {nil, nil},
+	}
+	for _, d := range downloads {
+		w := want[d.ID]
+		if !equalTime(d.EstimatedStartAt, w[0]) || !equalTime(d.EstimatedCompletionAt, w[1]) {
+			t.Errorf("%s: estimated %v to %v, want %v to %v", d.ID, d.EstimatedStartAt, d.EstimatedCompletionAt, w[0], w[1])
+		}
+	}
+	if !equalTime(last, at(150)) {
+		t.Errorf("queue completes at %v, want %v", last, at(150))
+	}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -3,10 +3,12 @@ package downloader
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/blakestevenson/nimbus/internal/configstore"
@@ -14,6 +16,7 @@ import (
 	"github.com/blakestevenson/nimbus/internal/httputil"
 	"github.com/blakestevenson/nimbus/internal/importer"
 	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -38,117 +41,160 @@ func NewHandler(service *Service, queries *generated.Queries, configStore *confi
 	}
 }
 
-// ImportCompletedDownload handles importing a completed download into the library
-// POST /api/downloads/{id}/import
-func (h *Handler) ImportCompletedDownload(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// newImporter creates an importer that sends its notifications and plugin events
+// through the downloader service
+func (h *Handler) newImporter() *importer.Service {
+	importerService := importer.NewService(h.queries, h.configStore, h.logger)
+	if h.service != nil {
+		importerService.SetNotifier(h.service.Notifier())
+		importerService.SetHistory(h.service.History())
+		importerService.SetHub(h.service.Hub())
+		importerService.SetEventBus(h.service.EventBus())
+		importerService.SetMediaServers(h.service.MediaServers())
+		for _, handler := range h.service.importedHandlers {
+			importerService.OnImported(handler)
+		}
+	}
+	return importerService
+}
 
-	// Parse request body
-	var req struct {
-		DownloadID   string  `json:"download_id"`
-		SourcePath   string  `json:"source_path"`
-		MediaType    string  `json:"media_type"` // "movie" or "tv"
-		Title        string  `json:"title"`
-		Year         *int    `json:"year,omitempty"`
-		Season       *int    `json:"season,omitempty"`
-		Episode      *int    `json:"episode,omitempty"`
-		EpisodeTitle *string `json:"episode_title,omitempty"`
-		Quality      *string `json:"quality,omitempty"`
-		MediaItemID  *int64  `json:"media_item_id,omitempty"`
+// importDownloadRequest is the body of an import request sent by a downloader plugin
+type importDownloadRequest struct {
+	DownloadID   string  `json:"download_id"`
+	SourcePath   string  `json:"source_path"`
+	MediaType    string  `json:"media_type"` // "movie", "tv" or "music"
+	Title        string  `json:"title"`
+	Year         *int    `json:"year,omitempty"`
+	Season       *int    `json:"season,omitempty"`
+	Episode      *int    `json:"episode,omitempty"`
+	LastEpisode  *int    `json:"last_episode,omitempty"` // Of a multi-episode file
+	EpisodeTitle *string `json:"episode_title,omitempty"`
+	Artist       *string `json:"artist,omitempty"`
+	Album        *string `json:"album,omitempty"`
+	Track        *int    `json:"track,omitempty"`
+	Quality      *string `json:"quality,omitempty"`
+	ReleaseGroup *string `json:"release_group,omitempty"`
+	ReleaseTitle *string `json:"release_title,omitempty"` // Quality and release group are parsed from it when missing
+	MediaItemID  *int64  `json:"media_item_id,omitempty"`
+	RootFolderID *int64  `json:"root_folder_id,omitempty"` // Overrides the media item's root folder
+	Force        bool    `json:"force,omitempty"`          // Replace existing files even if this isn't an upgrade
+	DryRun       bool    `json:"dry_run,omitempty"`        // Preview the import without touching files
+	Host         string  `json:"host,omitempty"`           // Download client host, for remote path mappings
+	TransferMode string  `json:"transfer_mode,omitempty"`  // "move", "copy", "hardlink" or "auto"; moves by default
+	Disposable   bool    `json:"disposable,omitempty"`     // The source may be moved in auto mode, e.g. NZB downloads
+	Sync         bool    `json:"sync,omitempty"`           // Wait for the import to finish instead of returning its queued job
+
+	// ExtraMediaItemIDs are the further episodes of a multi-episode file; they
+	// are worked out from the file name when left out
+	ExtraMediaItemIDs []int64 `json:"extra_media_item_ids,omitempty"`
+}
+
+// importRequest converts the body to an importer request
+func (req *importDownloadRequest) importRequest() *importer.ImportRequest {
+	metadata := make(map[string]interface{})
+	if req.DownloadID != "" {
+		metadata["download_id"] = req.DownloadID
 	}
+	return &importer.ImportRequest{
+		SourcePath:        req.SourcePath,
+		MediaType:         req.MediaType,
+		MediaItemID:       req.MediaItemID,
+		Title:             req.Title,
+		Year:              req.Year,
+		Season:            req.Season,
+		Episode:           req.Episode,
+		LastEpisode:       req.LastEpisode,
+		EpisodeTitle:      req.EpisodeTitle,
+		Artist:            req.Artist,
+		Album:             req.Album,
+		Track:             req.Track,
+		Quality:           req.Quality,
+		ReleaseGroup:      req.ReleaseGroup,
+		ReleaseTitle:      req.ReleaseTitle,
+		Metadata:          metadata,
+		Force:             req.Force,
+		RootFolderID:      req.RootFolderID,
+		ExtraMediaItemIDs: req.ExtraMediaItemIDs,
+		TransferMode:      req.TransferMode,
+		Disposable:        req.Disposable,
+	}
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+// importError is an import failure along with the HTTP status and message that
+// describe it to API clients
+type importError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *importError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *importError) Unwrap() error {
+	return e.err
+}
+
+// respondImportError writes an import failure to the response
+func respondImportError(w http.ResponseWriter, err error) {
+	var importErr *importError
+	if !errors.As(err, &importErr) {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Import failed")
 		return
 	}
+	if importErr.err == nil {
+		httputil.RespondErrorMessage(w, importErr.status, importErr.message)
+		return
+	}
+	httputil.RespondError(w, importErr.status, importErr.err, importErr.message)
+}
 
+// prepareImport resolves the source path of an import request and fills in its
+// media details. Unless the request is a dry run, failures are recorded for the
+// manual import queue.
+func (h *Handler) prepareImport(ctx context.Context, req *importDownloadRequest) error {
 	// Validate required fields
 	if req.SourcePath == "" {
-		httputil.RespondErrorMessage(w, http.StatusBadRequest, "source_path is required")
-		return
+		return &importError{status: http.StatusBadRequest, message: "source_path is required"}
+	}
+	if !importer.ValidTransferMode(req.TransferMode) {
+		return &importError{status: http.StatusBadRequest, message: "transfer_mode must be move, copy, hardlink or auto"}
 	}
 
-	// If media_item_id is provided, look up the media item and populate required fields
-	if req.MediaItemID != nil && *req.MediaItemID > 0 {
-		mediaItem, err := h.queries.GetMediaItem(ctx, *req.MediaItemID)
-		if err != nil {
-			h.logger.Error("failed to look up media item", zap.Int64("media_item_id", *req.MediaItemID), zap.Error(err))
-			httputil.RespondError(w, http.StatusNotFound, err, "Media item not found")
-			return
+	// Download clients on another host report paths as they see them
+	sourcePath, err := h.resolveSourcePath(ctx, req.DownloadID, "", req.Host, req.SourcePath)
+	if err != nil {
+		h.logger.Error("import source path not found",
+			zap.String("download_id", req.DownloadID),
+			zap.String("source", req.SourcePath),
+			zap.Error(err))
+		if !req.DryRun {
+			h.recordFailedImportRequest(ctx, req, err.Error())
 		}
+		return &importError{status: http.StatusUnprocessableEntity, message: "Import source path not found", err: err}
+	}
+	req.SourcePath = sourcePath
 
-		// Map Kind to MediaType and populate fields
-		switch mediaItem.Kind {
-		case "movie":
-			req.MediaType = "movie"
-			req.Title = mediaItem.Title
-			if mediaItem.Year != nil {
-				year := int(*mediaItem.Year)
-				req.Year = &year
-			}
-
-		case "series":
-			req.MediaType = "tv"
-			req.Title = mediaItem.Title
-			if mediaItem.Year != nil {
-				year := int(*mediaItem.Year)
-				req.Year = &year
-			}
-
-		case "season":
-			req.MediaType = "tv"
-			req.Title = mediaItem.Title
-			if mediaItem.Year != nil {
-				year := int(*mediaItem.Year)
-				req.Year = &year
-			}
-
-		case "episode", "tv_episode":
-			req.MediaType = "tv_episode"
-			// For episodes, store the episode title separately
-			episodeTitle := mediaItem.Title
-			req.EpisodeTitle = &episodeTitle
-
-			// Walk up the parent chain to find the series title
-			// Episode -> Season -> Series
-			if mediaItem.ParentID != nil {
-				// Get the season
-				season, err := h.queries.GetMediaItem(ctx, *mediaItem.ParentID)
-				if err == nil && season.ParentID != nil {
-					// Get the series
-					series, err := h.queries.GetMediaItem(ctx, *season.ParentID)
-					if err == nil {
-						req.Title = series.Title
-						if series.Year != nil {
-							year := int(*series.Year)
-							req.Year = &year
-						}
-					} else {
-						h.logger.Warn("failed to get series from season parent", zap.Error(err))
-					}
-				} else {
-					h.logger.Warn("failed to get season parent", zap.Error(err))
-				}
-			}
+	// Downloads are named after their release, which the quality and release
+	// group are parsed from
+	if req.ReleaseTitle == nil && req.DownloadID != "" {
+		if download, err := h.queries.GetDownload(ctx, req.DownloadID); err == nil {
+			req.ReleaseTitle = &download.Name
+		}
+	}
 
-			// Parse metadata to get season/episode numbers
-			if len(mediaItem.Metadata) > 0 {
-				var metadata map[string]interface{}
-				if err := json.Unmarshal(mediaItem.Metadata, &metadata); err == nil {
-					if seasonNum, ok := metadata["season"].(float64); ok {
-						season := int(seasonNum)
-						req.Season = &season
-					}
-					if episodeNum, ok := metadata["episode"].(float64); ok {
-						episode := int(episodeNum)
-						req.Episode = &episode
-					}
-				}
+	// If media_item_id is provided, look up the media item and populate required fields
+	if req.MediaItemID != nil && *req.MediaItemID > 0 {
+		if err := h.applyMediaItem(ctx, req); err != nil {
+			h.logger.Error("failed to look up media item", zap.Int64("media_item_id", *req.MediaItemID), zap.Error(err))
+			if !req.DryRun {
+				h.recordFailedImportRequest(ctx, req, "media item not found")
 			}
-
-		default:
-			req.MediaType = mediaItem.Kind
-			req.Title = mediaItem.Title
+			return &importError{status: http.StatusNotFound, message: "Media item not found", err: err}
 		}
 
 		h.logger.Info("importing completed download with media_item_id",
@@ -157,15 +203,16 @@ func (h *Handler) ImportCompletedDownload(w http.ResponseWriter, r *http.Request
 			zap.String("source", req.SourcePath),
 			zap.String("title", req.Title),
 			zap.String("type", req.MediaType))
-	} else {
-		// No media_item_id, so validate required fields
+	} else if !req.DryRun {
+		// No media_item_id, so validate required fields. A dry run parses them
+		// from the file names instead.
 		if req.Title == "" {
-			httputil.RespondErrorMessage(w, http.StatusBadRequest, "title is required")
-			return
+			h.recordFailedImportRequest(ctx, req, "title is required")
+			return &importError{status: http.StatusBadRequest, message: "title is required"}
 		}
 		if req.MediaType == "" {
-			httputil.RespondErrorMessage(w, http.StatusBadRequest, "media_type is required")
-			return
+			h.recordFailedImportRequest(ctx, req, "media_type is required")
+			return &importError{status: http.StatusBadRequest, message: "media_type is required"}
 		}
 
 		h.logger.Info("importing completed download",
@@ -175,54 +222,455 @@ func (h *Handler) ImportCompletedDownload(w http.ResponseWriter, r *http.Request
 			zap.String("title", req.Title))
 	}
 
-	// Create importer service
-	importerService := importer.NewService(h.queries, h.configStore, h.logger)
+	return nil
+}
+
+// importDownload imports the files of a prepared import request and waits for
+// the import to finish. Imports go through the import queue when it runs, which
+// retries transient failures before the files are left for a manual import.
+func (h *Handler) importDownload(ctx context.Context, req *importDownloadRequest) (*importer.ImportResult, error) {
+	importReq := req.importRequest()
+
+	var result *importer.ImportResult
+	var err error
+	if queue := h.importQueue(); queue != nil {
+		result, err = queue.Run(ctx, req.DownloadID, importReq)
+	} else {
+		result, err = h.runImport(ctx, req.DownloadID, importReq)
+		if err != nil {
+			h.importFailed(ctx, req.DownloadID, importReq, err)
+		}
+	}
 
-	// Build import request
-	importReq := &importer.ImportRequest{
-		SourcePath:   req.SourcePath,
-		MediaType:    req.MediaType,
-		MediaItemID:  req.MediaItemID,
-		Title:        req.Title,
-		Year:         req.Year,
-		Season:       req.Season,
-		Episode:      req.Episode,
-		EpisodeTitle: req.EpisodeTitle,
-		Quality:      req.Quality,
-		Metadata:     make(map[string]interface{}),
-	}
-
-	// Perform import
-	result, err := importerService.Import(ctx, importReq)
+	if errors.Is(err, importer.ErrFileNotStable) {
+		// Not a failure: the import can be tried again once the file is complete
+		return nil, &importError{status: http.StatusLocked, message: "File is still being written, try again later", err: err}
+	}
+	if errors.Is(err, importer.ErrNotUpgrade) {
+		return nil, &importError{status: http.StatusConflict, message: "Import is not an upgrade", err: err}
+	}
 	if err != nil {
-		h.logger.Error("import failed",
-			zap.String("download_id", req.DownloadID),
-			zap.Error(err))
-		httputil.RespondError(w, http.StatusInternalServerError, err, "Import failed")
-		return
+		return nil, &importError{status: http.StatusInternalServerError, message: "Import failed", err: err}
 	}
+	return result, nil
+}
 
-	// Update download record in database if download_id provided
-	if req.DownloadID != "" {
+// runImport imports the files of a download and marks the download completed
+func (h *Handler) runImport(ctx context.Context, downloadID string, req *importer.ImportRequest) (*importer.ImportResult, error) {
+	result, err := h.newImporter().Import(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update download record in database if download_id provided. Other files of
+	// the download may still be waiting for a manual import.
+	if downloadID != "" {
 		updateQuery := `
 			UPDATE downloads
 			SET status = 'completed',
 			    destination_path = $1,
 			    completed_at = NOW()
 			WHERE id = $2
+			  AND NOT EXISTS (
+			      SELECT 1 FROM import_decisions
+			      WHERE download_id = $2 AND status = 'pending'
+			  )
 		`
-		if _, err := h.db.Exec(ctx, updateQuery, result.FinalPath, req.DownloadID); err != nil {
+		if _, err := h.db.Exec(ctx, updateQuery, result.FinalPath, downloadID); err != nil {
 			h.logger.Warn("failed to update download record", zap.Error(err))
 		}
 	}
 
 	h.logger.Info("import completed successfully",
-		zap.String("download_id", req.DownloadID),
+		zap.String("download_id", downloadID),
 		zap.String("final_path", result.FinalPath))
 
+	return result, nil
+}
+
+// importFailed leaves the files of a failed import for a manual import. Files
+// still being written aren't, since the import is tried again once they are
+// complete.
+func (h *Handler) importFailed(ctx context.Context, downloadID string, req *importer.ImportRequest, err error) {
+	if errors.Is(err, importer.ErrFileNotStable) {
+		h.logger.Info("import source is still being written",
+			zap.String("download_id", downloadID),
+			zap.Error(err))
+		return
+	}
+
+	h.logger.Error("import failed",
+		zap.String("download_id", downloadID),
+		zap.Error(err))
+	_, recordErr := h.recordFailedImport(ctx, importer.FailedImport{
+		DownloadID:  downloadID,
+		SourcePath:  req.SourcePath,
+		MediaItemID: req.MediaItemID,
+		Detected:    req.DetectedAttributes(),
+		Reason:      err.Error(),
+	})
+	if recordErr != nil {
+		h.logger.Error("failed to record failed import",
+			zap.String("download_id", downloadID),
+			zap.String("source", req.SourcePath),
+			zap.Error(recordErr))
+	}
+}
+
+// ImportFile imports a file of a completed download for a downloader plugin
+func (h *Handler) ImportFile(ctx context.Context, req plugins.ImportFileRequest) (*plugins.ImportFileResult, error) {
+	mediaItemID := req.MediaItemID
+	importReq := &importDownloadRequest{
+		DownloadID:        req.DownloadID,
+		SourcePath:        req.SourcePath,
+		MediaItemID:       &mediaItemID,
+		Quality:           optionalString(req.Quality),
+		ReleaseGroup:      optionalString(req.ReleaseGroup),
+		ReleaseTitle:      optionalString(req.ReleaseTitle),
+		ExtraMediaItemIDs: req.ExtraMediaItemIDs,
+		TransferMode:      req.TransferMode,
+		Disposable:        req.Disposable,
+	}
+	if err := h.prepareImport(ctx, importReq); err != nil {
+		return nil, err
+	}
+
+	result, err := h.importDownload(ctx, importReq)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.ImportFileResult{FinalPath: result.FinalPath}, nil
+}
+
+// ImportCompletedDownload handles importing a completed download into the library
+// POST /api/downloads/import
+func (h *Handler) ImportCompletedDownload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse request body
+	var req importDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := h.prepareImport(ctx, &req); err != nil {
+		respondImportError(w, err)
+		return
+	}
+
+	// A dry run only reports what the import would do
+	if req.DryRun {
+		preview, err := h.newImporter().Preview(ctx, req.importRequest())
+		if err != nil {
+			httputil.RespondError(w, http.StatusUnprocessableEntity, err, "Import preview failed")
+			return
+		}
+		httputil.RespondJSON(w, http.StatusOK, preview)
+		return
+	}
+
+	// Imports are queued unless the caller waits for the result
+	if queue := h.importQueue(); queue != nil && !req.Sync {
+		job, err := queue.Enqueue(ctx, req.DownloadID, req.importRequest())
+		if err != nil {
+			httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to queue import")
+			return
+		}
+		httputil.RespondJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	result, err := h.importDownload(ctx, &req)
+	if err != nil {
+		respondImportError(w, err)
+		return
+	}
+
 	httputil.RespondJSON(w, http.StatusOK, result)
 }
 
+// ConfirmImport executes the decisions of an import preview, possibly edited by
+// the user, moving each file to exactly the previewed destination
+// POST /api/downloads/import/confirm
+func (h *Handler) ConfirmImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		DownloadID string                     `json:"download_id"`
+		Decisions  []importer.PreviewDecision `json:"decisions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+	if len(req.Decisions) == 0 {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "decisions are required")
+		return
+	}
+
+	for _, decision := range req.Decisions {
+		if decision.Action != importer.ImportActionSkip && (decision.SourcePath == "" || decision.DestinationPath == "") {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "source_path and destination_path are required for every decision")
+			return
+		}
+	}
+
+	importerService := h.newImporter()
+	results := make([]*importer.ImportResult, 0, len(req.Decisions))
+	imported, failed := 0, 0
+	var lastPath string
+
+	for _, decision := range req.Decisions {
+		if decision.Action == importer.ImportActionSkip {
+			continue
+		}
+
+		importReq := decision.ImportRequest()
+		result, err := importerService.Import(ctx, importReq)
+		if errors.Is(err, importer.ErrFileNotStable) {
+			// Left out of the manual import queue, the file can be confirmed again
+			h.logger.Info("confirmed import source is still being written",
+				zap.String("download_id", req.DownloadID),
+				zap.String("source", decision.SourcePath),
+				zap.Error(err))
+			failed++
+		} else if err != nil {
+			h.logger.Error("confirmed import failed",
+				zap.String("download_id", req.DownloadID),
+				zap.String("source", decision.SourcePath),
+				zap.Error(err))
+			h.recordFailedImportRequest(ctx, &importDownloadRequest{
+				DownloadID:   req.DownloadID,
+				SourcePath:   decision.SourcePath,
+				MediaType:    decision.MediaType,
+				Title:        decision.Title,
+				Year:         decision.Year,
+				Season:       decision.Season,
+				Episode:      decision.Episode,
+				EpisodeTitle: decision.EpisodeTitle,
+				Quality:      decision.Quality,
+				MediaItemID:  decision.MediaItemID,
+			}, err.Error())
+			failed++
+		} else {
+			imported++
+			lastPath = result.FinalPath
+		}
+		results = append(results, result)
+	}
+
+	if req.DownloadID != "" && failed == 0 && imported > 0 {
+		updateQuery := `
+			UPDATE downloads
+			SET status = 'completed',
+			    destination_path = $1,
+			    completed_at = COALESCE(completed_at, NOW())
+			WHERE id = $2
+			  AND NOT EXISTS (
+			      SELECT 1 FROM import_decisions
+			      WHERE download_id = $2 AND status = 'pending'
+			  )
+		`
+		if _, err := h.db.Exec(ctx, updateQuery, lastPath, req.DownloadID); err != nil {
+			h.logger.Warn("failed to update download record", zap.Error(err))
+		}
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"results":  results,
+		"imported": imported,
+		"failed":   failed,
+	})
+}
+
+// applyMediaItem fills in the media type, title, year and episode numbers of an
+// import request from its media item
+func (h *Handler) applyMediaItem(ctx context.Context, req *importDownloadRequest) error {
+	mediaItem, err := h.queries.GetMediaItem(ctx, *req.MediaItemID)
+	if err != nil {
+		return err
+	}
+
+	// Map Kind to MediaType and populate fields
+	switch mediaItem.Kind {
+	case "movie":
+		req.MediaType = "movie"
+		req.Title = mediaItem.Title
+		if mediaItem.Year != nil {
+			year := int(*mediaItem.Year)
+			req.Year = &year
+		}
+
+	case "series":
+		req.MediaType = "tv"
+		req.Title = mediaItem.Title
+		if mediaItem.Year != nil {
+			year := int(*mediaItem.Year)
+			req.Year = &year
+		}
+
+	case "season":
+		req.MediaType = "tv"
+		req.Title = mediaItem.Title
+		if mediaItem.Year != nil {
+			year := int(*mediaItem.Year)
+			req.Year = &year
+		}
+
+	case "episode", "tv_episode":
+		req.MediaType = "tv_episode"
+		// For episodes, store the episode title separately
+		episodeTitle := mediaItem.Title
+		req.EpisodeTitle = &episodeTitle
+
+		// Walk up the parent chain to find the series title
+		// Episode -> Season -> Series
+		if mediaItem.ParentID != nil {
+			// Get the season
+			season, err := h.queries.GetMediaItem(ctx, *mediaItem.ParentID)
+			if err == nil && season.ParentID != nil {
+				// Get the series
+				series, err := h.queries.GetMediaItem(ctx, *season.ParentID)
+				if err == nil {
+					req.Title = series.Title
+					if series.Year != nil {
+						year := int(*series.Year)
+						req.Year = &year
+					}
+				} else {
+					h.logger.Warn("failed to get series from season parent", zap.Error(err))
+				}
+			} else {
+				h.logger.Warn("failed to get season parent", zap.Error(err))
+			}
+		}
+
+		// Parse metadata to get season/episode numbers
+		if len(mediaItem.Metadata) > 0 {
+			var metadata map[string]interface{}
+			if err := json.Unmarshal(mediaItem.Metadata, &metadata); err == nil {
+				if seasonNum, ok := metadata["season"].(float64); ok {
+					season := int(seasonNum)
+					req.Season = &season
+				}
+				if episodeNum, ok := metadata["episode"].(float64); ok {
+					episode := int(episodeNum)
+					req.Episode = &episode
+				}
+			}
+		}
+		h.applyMultiEpisode(ctx, req, mediaItem)
+
+	case "music_album":
+		// The importer matches each file to a track of the album
+		req.MediaType = "music_album"
+		req.Title = mediaItem.Title
+		album := mediaItem.Title
+		req.Album = &album
+		if mediaItem.Year != nil {
+			year := int(*mediaItem.Year)
+			req.Year = &year
+		}
+		if mediaItem.ParentID != nil {
+			if artist, err := h.queries.GetMediaItem(ctx, *mediaItem.ParentID); err == nil {
+				req.Artist = &artist.Title
+			}
+		}
+
+	case "music_track":
+		req.MediaType = "music_track"
+		req.Title = mediaItem.Title
+
+		// Walk up the parent chain: Track -> Album -> Artist
+		if mediaItem.ParentID != nil {
+			if album, err := h.queries.GetMediaItem(ctx, *mediaItem.ParentID); err == nil {
+				req.Album = &album.Title
+				if album.Year != nil {
+					year := int(*album.Year)
+					req.Year = &year
+				}
+				if album.ParentID != nil {
+					if artist, err := h.queries.GetMediaItem(ctx, *album.ParentID); err == nil {
+						req.Artist = &artist.Title
+					}
+				}
+			} else {
+				h.logger.Warn("failed to get album of track", zap.Error(err))
+			}
+		}
+
+		if len(mediaItem.Metadata) > 0 {
+			var metadata map[string]interface{}
+			if err := json.Unmarshal(mediaItem.Metadata, &metadata); err == nil {
+				if trackNum, ok := metadata["track_number"].(float64); ok && trackNum > 0 {
+					track := int(trackNum)
+					req.Track = &track
+				}
+			}
+		}
+
+	default:
+		req.MediaType = mediaItem.Kind
+		req.Title = mediaItem.Title
+	}
+
+	return nil
+}
+
+// applyMultiEpisode finds the further episodes a multi-episode file covers:
+// the ones the plugin named, else the episodes of the same season up to the
+// last one in the file name. The last of them is named in the file name.
+func (h *Handler) applyMultiEpisode(ctx context.Context, req *importDownloadRequest, episode generated.MediaItem) {
+	if req.Season == nil || req.Episode == nil || episode.ParentID == nil {
+		return
+	}
+	fileLast := 0
+	if len(req.ExtraMediaItemIDs) == 0 {
+		parsed := library.ParseFilename(req.SourcePath)
+		if parsed == nil || parsed.LastEpisode == 0 || parsed.Season != *req.Season || parsed.Episode != *req.Episode {
+			return
+		}
+		fileLast = parsed.LastEpisode
+	}
+
+	siblings, err := h.queries.ListChildMediaItems(ctx, episode.ParentID)
+	if err != nil {
+		h.logger.Warn("failed to list episodes of season", zap.Int64("season_id", *episode.ParentID), zap.Error(err))
+		return
+	}
+	numbers := make(map[int64]int, len(siblings))
+	for _, sibling := range siblings {
+		var metadata struct {
+			Episode int `json:"episode"`
+		}
+		if json.Unmarshal(sibling.Metadata, &metadata) == nil && metadata.Episode > 0 {
+			numbers[sibling.ID] = metadata.Episode
+		}
+	}
+
+	if len(req.ExtraMediaItemIDs) == 0 {
+		for id, number := range numbers {
+			if number > *req.Episode && number <= fileLast {
+				req.ExtraMediaItemIDs = append(req.ExtraMediaItemIDs, id)
+			}
+		}
+		sort.Slice(req.ExtraMediaItemIDs, func(i, j int) bool {
+			return numbers[req.ExtraMediaItemIDs[i]] < numbers[req.ExtraMediaItemIDs[j]]
+		})
+	}
+
+	last := *req.Episode
+	for _, id := range req.ExtraMediaItemIDs {
+		if numbers[id] > last {
+			last = numbers[id]
+		}
+	}
+	if last > *req.Episode {
+		req.LastEpisode = &last
+	}
+}
+
 // AutoImportHandler monitors completed downloads and automatically imports them
 // This is called periodically by a background worker
 func (h *Handler) AutoImportCompletedDownloads(ctx context.Context) error {
@@ -230,7 +678,7 @@ func (h *Handler) AutoImportCompletedDownloads(ctx context.Context) error {
 
 	// Query for completed downloads that haven't been imported yet
 	query := `
-		SELECT d.id, d.name, d.destination_path, d.metadata, d.media_item_id
+		SELECT d.id, d.plugin_id, d.name, d.destination_path, d.metadata, d.media_item_id
 		FROM downloads d
 		WHERE d.status = 'completed'
 		  AND d.destination_path IS NOT NULL
@@ -251,12 +699,13 @@ func (h *Handler) AutoImportCompletedDownloads(ctx context.Context) error {
 	count := 0
 	for rows.Next() {
 		var downloadID string
+		var pluginID string
 		var name string
 		var destinationPath *string
 		var metadataJSON []byte
 		var mediaItemID *int64
 
-		if err := rows.Scan(&downloadID, &name, &destinationPath, &metadataJSON, &mediaItemID); err != nil {
+		if err := rows.Scan(&downloadID, &pluginID, &name, &destinationPath, &metadataJSON, &mediaItemID); err != nil {
 			h.logger.Error("failed to scan download", zap.Error(err))
 			continue
 		}
@@ -276,8 +725,22 @@ func (h *Handler) AutoImportCompletedDownloads(ctx context.Context) error {
 			metadata = make(map[string]interface{})
 		}
 
+		host, _ := metadata["host"].(string)
+		sourcePath, err := h.resolveSourcePath(ctx, downloadID, pluginID, host, *destinationPath)
+		if err != nil {
+			h.logger.Warn("auto-import source path not found",
+				zap.String("download_id", downloadID),
+				zap.String("destination_path", *destinationPath),
+				zap.Error(err))
+			continue
+		}
+
 		// Try to determine media info from metadata or filename
-		importReq := h.buildImportRequest(*destinationPath, name, metadata, mediaItemID)
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		metadata["download_id"] = downloadID
+		importReq := h.buildImportRequest(sourcePath, name, metadata, mediaItemID)
 		if importReq == nil {
 			h.logger.Warn("could not determine media info for download",
 				zap.String("download_id", downloadID),
@@ -286,8 +749,14 @@ func (h *Handler) AutoImportCompletedDownloads(ctx context.Context) error {
 		}
 
 		// Create importer and perform import
-		importerService := importer.NewService(h.queries, h.configStore, h.logger)
+		importerService := h.newImporter()
 		result, err := importerService.Import(ctx, importReq)
+		if errors.Is(err, importer.ErrFileNotStable) {
+			h.logger.Info("auto-import source is still being written, retrying later",
+				zap.String("download_id", downloadID),
+				zap.Error(err))
+			continue
+		}
 		if err != nil {
 			h.logger.Error("auto-import failed",
 				zap.String("download_id", downloadID),
@@ -364,6 +833,11 @@ func (h *Handler) buildImportRequest(sourcePath, name string, metadata map[strin
 		req.Quality = &quality
 	}
 
+	// The download is named after the release
+	if name != "" {
+		req.ReleaseTitle = &name
+	}
+
 	// If we don't have media type, try to guess from filename or metadata
 	if req.MediaType == "" {
 		// Check if it looks like a TV show
@@ -398,6 +872,14 @@ func (h *Handler) buildImportRequest(sourcePath, name string, metadata map[strin
 	return req
 }
 
+// optionalString returns nil for an empty string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 // FindMainMediaFile finds the largest media file in a directory (for multi-file downloads)
 func FindMainMediaFile(dir string) (string, error) {
 	entries, err := filepath.Glob(filepath.Join(dir, "*"))
@@ -408,7 +890,7 @@ func FindMainMediaFile(dir string) (string, error) {
 	var largestFile string
 	var largestSize int64
 
-	mediaExtensions := []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts"}
+	mediaExtensions := []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts", ".flac", ".mp3", ".m4a"}
 
 	for _, entry := range entries {
 		info, err := os.Stat(entry)
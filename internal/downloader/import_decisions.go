@@ -0,0 +1,264 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/importer"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// recordFailedImport stores a failed import so it shows up in the manual import
+// queue and marks its download as waiting for import
+func (h *Handler) recordFailedImport(ctx context.Context, failure importer.FailedImport) (*importer.ImportDecision, error) {
+	importerService := h.newImporter()
+	decision, err := importerService.RecordFailure(ctx, failure)
+	if err != nil {
+		return nil, err
+	}
+
+	if failure.DownloadID != "" {
+		updateQuery := `
+			UPDATE downloads
+			SET status = 'waiting_import',
+			    error_message = $2
+			WHERE id = $1
+		`
+		if _, err := h.db.Exec(ctx, updateQuery, failure.DownloadID, failure.Reason); err != nil {
+			h.logger.Warn("failed to update download record", zap.Error(err))
+		}
+	}
+
+	return decision, nil
+}
+
+// recordFailedImportRequest records a failed import request, logging rather than
+// returning errors since the import failure is what gets reported
+func (h *Handler) recordFailedImportRequest(ctx context.Context, req *importDownloadRequest, reason string) {
+	_, err := h.recordFailedImport(ctx, importer.FailedImport{
+		DownloadID:  req.DownloadID,
+		SourcePath:  req.SourcePath,
+		MediaItemID: req.MediaItemID,
+		Detected:    req.importRequest().DetectedAttributes(),
+		Reason:      reason,
+	})
+	if err != nil {
+		h.logger.Error("failed to record failed import",
+			zap.String("download_id", req.DownloadID),
+			zap.String("source", req.SourcePath),
+			zap.Error(err))
+	}
+}
+
+// RecordFailedImport records an import a downloader plugin could not attempt,
+// e.g. because the download has no media item or the episode could not be
+// identified
+func (h *Handler) RecordFailedImport(ctx context.Context, req plugins.FailedImportRequest) error {
+	_, err := h.recordFailedImport(ctx, importer.FailedImport{
+		DownloadID:  req.DownloadID,
+		SourcePath:  req.SourcePath,
+		MediaItemID: req.MediaItemID,
+		Detected:    req.Detected,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		h.logger.Error("failed to record failed import", zap.String("download_id", req.DownloadID), zap.Error(err))
+		return fmt.Errorf("failed to record failed import: %w", err)
+	}
+	return nil
+}
+
+// ListPendingImports lists failed imports awaiting manual resolution
+// GET /api/imports/pending
+func (h *Handler) ListPendingImports(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	importerService := h.newImporter()
+	page, err := importerService.ListPendingDecisions(r.Context(), int32(limit), int32(offset))
+	if err != nil {
+		h.logger.Error("failed to list pending imports", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list pending imports")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, page)
+}
+
+// ResolveImport re-runs a failed import against the media item supplied by the
+// user, given either directly or as a movie, or a series with season and episode
+// POST /api/imports/{id}/resolve
+func (h *Handler) ResolveImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	var body struct {
+		MediaItemID *int64 `json:"media_item_id,omitempty"`
+		MovieID     *int64 `json:"movie_id,omitempty"`
+		SeriesID    *int64 `json:"series_id,omitempty"`
+		Season      *int   `json:"season,omitempty"`
+		Episode     *int   `json:"episode,omitempty"`
+		Force       bool   `json:"force,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	importerService := h.newImporter()
+	decision, err := importerService.GetDecision(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Import not found")
+			return
+		}
+		h.logger.Error("failed to get import decision", zap.Int64("id", id), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get import")
+		return
+	}
+	if decision.Status != importer.DecisionStatusPending {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "Import is already resolved")
+		return
+	}
+
+	// Work out which media item the file belongs to
+	var mediaItemID int64
+	switch {
+	case body.MediaItemID != nil:
+		mediaItemID = *body.MediaItemID
+	case body.MovieID != nil:
+		mediaItemID = *body.MovieID
+	case body.SeriesID != nil && body.Season != nil && body.Episode != nil:
+		episodeID, err := h.findEpisode(ctx, *body.SeriesID, *body.Season, *body.Episode)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				httputil.RespondErrorMessage(w, http.StatusNotFound, "Episode not found")
+				return
+			}
+			h.logger.Error("failed to find episode", zap.Int64("series_id", *body.SeriesID), zap.Error(err))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to find episode")
+			return
+		}
+		mediaItemID = episodeID
+	default:
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "media_item_id, movie_id, or series_id with season and episode is required")
+		return
+	}
+
+	req := importDownloadRequest{
+		SourcePath:  decision.SourcePath,
+		MediaItemID: &mediaItemID,
+		Force:       body.Force,
+	}
+	if decision.DownloadID != nil {
+		req.DownloadID = *decision.DownloadID
+	}
+	if quality, ok := decision.Detected["quality"].(string); ok && quality != "" {
+		req.Quality = &quality
+	}
+
+	if err := h.applyMediaItem(ctx, &req); err != nil {
+		httputil.RespondError(w, http.StatusNotFound, err, "Media item not found")
+		return
+	}
+
+	h.logger.Info("resolving failed import",
+		zap.Int64("id", id),
+		zap.Int64("media_item_id", mediaItemID),
+		zap.String("source", req.SourcePath))
+
+	result, err := importerService.Import(ctx, req.importRequest())
+	if errors.Is(err, importer.ErrFileNotStable) {
+		httputil.RespondError(w, http.StatusLocked, err, "File is still being written, try again later")
+		return
+	}
+	if err != nil {
+		h.logger.Error("manual import failed", zap.Int64("id", id), zap.Error(err))
+		// Keep the decision pending with the latest reason so it can be retried
+		if _, recordErr := importerService.RecordFailure(ctx, importer.FailedImport{
+			DownloadID:  req.DownloadID,
+			SourcePath:  decision.SourcePath,
+			MediaItemID: decision.MediaItemID,
+			Detected:    decision.Detected,
+			Reason:      err.Error(),
+		}); recordErr != nil {
+			h.logger.Warn("failed to update import decision", zap.Int64("id", id), zap.Error(recordErr))
+		}
+		httputil.RespondError(w, http.StatusUnprocessableEntity, err, "Import failed")
+		return
+	}
+
+	decision, err = importerService.ResolveDecision(ctx, id, result)
+	if err != nil {
+		h.logger.Error("failed to resolve import decision", zap.Int64("id", id), zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to resolve import")
+		return
+	}
+
+	// The download is done once none of its files are waiting for an import
+	if req.DownloadID != "" {
+		updateQuery := `
+			UPDATE downloads
+			SET status = 'completed',
+			    error_message = NULL,
+			    media_item_id = COALESCE(media_item_id, $2),
+			    completed_at = COALESCE(completed_at, NOW())
+			WHERE id = $1
+			  AND status = 'waiting_import'
+			  AND NOT EXISTS (
+			      SELECT 1 FROM import_decisions
+			      WHERE download_id = $1 AND status = 'pending'
+			  )
+		`
+		if _, err := h.db.Exec(ctx, updateQuery, req.DownloadID, mediaItemID); err != nil {
+			h.logger.Warn("failed to update download record", zap.Error(err))
+		}
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"decision": decision,
+		"result":   result,
+	})
+}
+
+// findEpisode looks up an episode of a series by its season and episode numbers
+func (h *Handler) findEpisode(ctx context.Context, seriesID int64, season, episode int) (int64, error) {
+	query := `
+		SELECT e.id
+		FROM media_items e
+		JOIN media_items season ON season.id = e.parent_id
+		WHERE e.kind = 'tv_episode'
+		  AND season.parent_id = $1
+		  AND COALESCE(e.metadata->>'season', e.metadata->>'season_number') = $2::TEXT
+		  AND COALESCE(e.metadata->>'episode', e.metadata->>'episode_number') = $3::TEXT
+		LIMIT 1
+	`
+
+	var id int64
+	err := h.db.QueryRow(ctx, query, seriesID, season, episode).Scan(&id)
+	return id, err
+}
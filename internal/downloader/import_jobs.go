@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/importer"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// importQueue returns the queue imports run through, or nil when they run
+// inline
+func (h *Handler) importQueue() *importer.Queue {
+	if h.service == nil {
+		return nil
+	}
+	return h.service.ImportQueue()
+}
+
+// RunImport runs a job of the import queue
+func (h *Handler) RunImport(ctx context.Context, job *importer.ImportJob) (*importer.ImportResult, error) {
+	return h.runImport(ctx, jobDownloadID(job), job.Request)
+}
+
+// ImportFailed leaves the files of an import job that failed for good for a
+// manual import
+func (h *Handler) ImportFailed(ctx context.Context, job *importer.ImportJob, err error) {
+	h.importFailed(ctx, jobDownloadID(job), job.Request, err)
+}
+
+// jobDownloadID returns the ID of the download an import job belongs to, if any
+func jobDownloadID(job *importer.ImportJob) string {
+	if job.DownloadID == nil {
+		return ""
+	}
+	return *job.DownloadID
+}
+
+// ListImportJobs lists the jobs of the import queue, newest first, optionally
+// only those of a status or download
+// GET /api/downloads/import/jobs
+func (h *Handler) ListImportJobs(w http.ResponseWriter, r *http.Request) {
+	queue := h.importQueue()
+	if queue == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Import queue is not running")
+		return
+	}
+
+	filter := importer.ImportJobFilter{
+		Status:     importer.ImportJobStatus(r.URL.Query().Get("status")),
+		DownloadID: r.URL.Query().Get("download_id"),
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			filter.Limit = parsedLimit
+		}
+	}
+
+	jobs, err := queue.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("failed to list import jobs", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list import jobs")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, jobs)
+}
+
+// GetImportJob returns the status of a job of the import queue, and its result
+// once it completed
+// GET /api/downloads/import/jobs/{id}
+func (h *Handler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	queue := h.importQueue()
+	if queue == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Import queue is not running")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid import job ID")
+		return
+	}
+
+	job, err := queue.Get(r.Context(), id)
+	if errors.Is(err, importer.ErrImportJobNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Import job not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get import job", zap.Int64("id", id), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get import job")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, job)
+}
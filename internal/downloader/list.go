@@ -0,0 +1,168 @@
+package downloader
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageSize is how many downloads are listed when no limit is given
+	defaultPageSize = 50
+
+	// maxPageSize caps the downloads listed at once
+	maxPageSize = 500
+)
+
+// sortColumns maps the sort keys of the downloads list onto their columns
+var sortColumns = map[string]string{
+	"created_at": "created_at",
+	"name":       "lower(name)",
+	"progress":   "progress",
+	"size":       "total_bytes",
+	"status":     "status",
+}
+
+// ListFilter selects a page of downloads
+type ListFilter struct {
+	PluginID string
+	Statuses []string   // Any of these
+	Search   string     // Part of the name, ignoring case
+	Since    *time.Time // Created at or after
+	Until    *time.Time // Created before
+	Sort     string     // A key of sortColumns
+	Desc     bool
+	Limit    int
+	Offset   int
+}
+
+// ParseListFilter reads a downloads list filter from query parameters. It
+// lists the newest downloads first, defaultPageSize at a time.
+func ParseListFilter(query url.Values) (ListFilter, error) {
+	filter := ListFilter{
+		PluginID: query.Get("plugin_id"),
+		Search:   strings.TrimSpace(query.Get("q")),
+		Sort:     "created_at",
+		Desc:     true,
+		Limit:    defaultPageSize,
+	}
+
+	// Several statuses are given separated by commas, like
+	// queued,downloading,processing for the active downloads
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		filter.Limit = limit
+	}
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("offset must not be negative")
+		}
+		filter.Offset = offset
+	}
+
+	if raw := query.Get("sort"); raw != "" {
+		if _, ok := sortColumns[raw]; !ok {
+			return filter, fmt.Errorf("sort must be one of created_at, name, progress, size or status")
+		}
+		filter.Sort = raw
+		// Names and statuses read best from A to Z, the rest biggest first
+		filter.Desc = raw != "name" && raw != "status"
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("order must be asc or desc")
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a date (YYYY-MM-DD) or RFC 3339 time", param.name)
+		}
+		*param.dest = &t
+	}
+
+	return filter, nil
+}
+
+// parseTime parses an RFC 3339 time or a date, which means midnight UTC
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// where returns the conditions of the filter and their arguments
+func (f ListFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.PluginID != "" {
+		add("plugin_id = $%d", f.PluginID)
+	}
+	if len(f.Statuses) > 0 {
+		add("status = ANY($%d)", f.Statuses)
+	}
+	if f.Search != "" {
+		add("name ILIKE $%d", "%"+escapeLike(f.Search)+"%")
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause of the filter. The ID breaks ties, so
+// pages don't overlap.
+func (f ListFilter) orderBy() string {
+	column, ok := sortColumns[f.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC"
+	if f.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id %s", column, direction, direction)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, using the default
+// escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
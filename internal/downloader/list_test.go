@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseListFilterDefaults(t *testing.T) {
+	filter, err := ParseListFilter(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Limit != defaultPageSize || filter.Offset != 0 || filter.Sort != "created_at" || !filter.Desc {
+		t.Errorf("filter = %+v, want the newest page", filter)
+	}
+	if where, args := filter.where(); where != "" || args != nil {
+		t.Errorf("where = %q %v, want none", where, args)
+	}
+	if got := filter.orderBy(); got != " ORDER BY created_at DESC NULLS LAST, id DESC" {
+		t.Errorf("orderBy = %q", got)
+	}
+}
+
+func TestParseListFilter(t *testing.T) {
+	filter, err := ParseListFilter(url.Values{
+		"plugin_id": {"nzb-downloader"},
+		"status":    {"queued, downloading"},
+		"q":         {" 100%_done "},
+		"since":     {"2026-01-01"},
+		"until":     {"2026-02-01T00:00:00Z"},
+		"sort":      {"name"},
+		"limit":     {"20"},
+		"offset":    {"40"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Limit != 20 || filter.Offset != 40 || filter.Desc {
+		t.Errorf("filter = %+v, want names from A to Z at 40", filter)
+	}
+
+	where, args := filter.where()
+	if want := " WHERE plugin_id = $1 AND status = ANY($2) AND name ILIKE $3 AND created_at >= $4 AND created_at < $5"; where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+	want := []interface{}{
+		"nzb-downloader",
+		[]string{"queued", "downloading"},
+		`%100\%\_done%`,
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+	if got := filter.orderBy(); got != " ORDER BY lower(name) ASC NULLS LAST, id ASC" {
+		t.Errorf("orderBy = %q", got)
+	}
+
+	filter, _ = ParseListFilter(url.Values{"sort": {"size"}, "order": {"asc"}})
+	if got := filter.orderBy(); got != " ORDER BY total_bytes ASC NULLS LAST, id ASC" {
+		t.Errorf("orderBy = %q", got)
+	}
+}
+
+func TestParseListFilterInvalid(t *testing.T) {
+	for _, query := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"501"}},
+		{"offset": {"-1"}},
+		{"sort": {"id; DROP TABLE downloads"}},
+		{"order": {"up"}},
+		{"since": {"yesterday"}},
+	} {
+		if _, err := ParseListFilter(query); err == nil {
+			t.Errorf("ParseListFilter(%v) succeeded, want an error", query)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package downloader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Download clients running in another container or on another machine report
+// paths as they see them ("/downloads/complete/..."), which don't exist on this
+// host ("/mnt/media/downloads/complete/..."). Remote path mappings rewrite the
+// remote prefix of those paths to the local one before anything touches them.
+
+const configRemotePathMappings = "downloads.remote_path_mappings"
+
+// ErrPathMappingMissing is returned when a reported path doesn't exist locally
+// and looks like it was reported from another host
+var ErrPathMappingMissing = errors.New("path does not exist on this host, is a remote path mapping missing?")
+
+// RemotePathMapping rewrites paths reported by a download client
+type RemotePathMapping struct {
+	ID         string `json:"id"`
+	PluginID   string `json:"plugin_id,omitempty"` // Downloader plugin the mapping applies to; empty for all
+	Host       string `json:"host,omitempty"`      // Download client host the mapping applies to; empty for all
+	RemotePath string `json:"remote_path"`
+	LocalPath  string `json:"local_path"`
+}
+
+// LoadPathMappings reads the remote path mappings from the config store
+func LoadPathMappings(ctx context.Context, store *configstore.Store) ([]RemotePathMapping, error) {
+	raw, err := store.Get(ctx, configRemotePathMappings)
+	if err != nil || len(raw) == 0 || string(raw) == "null" {
+		// Not configured yet
+		return []RemotePathMapping{}, nil
+	}
+
+	var mappings []RemotePathMapping
+	if err := json.Unmarshal(raw, &mappings); err != nil {
+		return nil, fmt.Errorf("invalid remote path mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+// SavePathMappings stores the remote path mappings in the config store
+func SavePathMappings(ctx context.Context, store *configstore.Store, mappings []RemotePathMapping) error {
+	return store.Set(ctx, configRemotePathMappings, mappings)
+}
+
+// MapRemotePath rewrites a path reported by a download client using the most
+// specific matching mapping. It returns the path unchanged and false when no
+// mapping applies.
+func MapRemotePath(mappings []RemotePathMapping, pluginID, host, path string) (string, bool) {
+	var best *RemotePathMapping
+	for i := range mappings {
+		m := &mappings[i]
+		if m.PluginID != "" && m.PluginID != pluginID {
+			continue
+		}
+		if m.Host != "" && !strings.EqualFold(m.Host, host) {
+			continue
+		}
+		if !hasPathPrefix(path, m.RemotePath) {
+			continue
+		}
+		if best == nil || len(cleanRemotePath(m.RemotePath)) > len(cleanRemotePath(best.RemotePath)) {
+			best = m
+		}
+	}
+	if best == nil {
+		return path, false
+	}
+
+	rest := strings.TrimPrefix(remoteSlashes(path), cleanRemotePath(best.RemotePath))
+	return filepath.Join(best.LocalPath, filepath.FromSlash(rest)), true
+}
+
+// hasPathPrefix reports whether path is prefix or below it, comparing whole
+// path components so "/downloads" doesn't match "/downloads-old"
+func hasPathPrefix(path, prefix string) bool {
+	prefix = cleanRemotePath(prefix)
+	if prefix == "" {
+		return false
+	}
+	path = remoteSlashes(path)
+	if prefix == "/" {
+		return strings.HasPrefix(path, "/")
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// remoteSlashes converts a remote path to forward slashes, which is also what
+// paths reported by download clients running on Windows are compared with
+func remoteSlashes(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
+
+// cleanRemotePath normalizes a remote prefix
+func cleanRemotePath(prefix string) string {
+	prefix = remoteSlashes(strings.TrimSpace(prefix))
+	if len(prefix) > 1 {
+		prefix = strings.TrimRight(prefix, "/")
+	}
+	return prefix
+}
+
+// resolveSourcePath applies the remote path mappings to a path reported by a
+// download client and checks that the result exists. pluginID is looked up
+// from the download when empty.
+func (h *Handler) resolveSourcePath(ctx context.Context, downloadID, pluginID, host, path string) (string, error) {
+	if pluginID == "" && downloadID != "" && h.db != nil {
+		// Unknown downloads simply match only the mappings for every plugin
+		_ = h.db.QueryRow(ctx, `SELECT plugin_id FROM downloads WHERE id = $1`, downloadID).Scan(&pluginID)
+	}
+
+	mappings, err := LoadPathMappings(ctx, h.configStore)
+	if err != nil {
+		h.logger.Warn("failed to load remote path mappings", zap.Error(err))
+		mappings = nil
+	}
+
+	local, mapped := MapRemotePath(mappings, pluginID, host, path)
+	if mapped {
+		h.logger.Info("mapped remote download path",
+			zap.String("download_id", downloadID),
+			zap.String("plugin_id", pluginID),
+			zap.String("remote_path", path),
+			zap.String("local_path", local))
+	}
+
+	if _, err := os.Stat(local); err != nil {
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("cannot access %s: %w", local, err)
+		}
+		if mapped {
+			return "", fmt.Errorf("mapped path %s (reported as %s) does not exist", local, path)
+		}
+		if looksRemote(path) {
+			return "", fmt.Errorf("%s: %w", path, ErrPathMappingMissing)
+		}
+		return "", fmt.Errorf("source path %s does not exist", path)
+	}
+
+	return local, nil
+}
+
+// looksRemote reports whether a path that doesn't exist was likely reported
+// from another host: it's absolute but its top-level directory isn't here, or
+// it's a Windows or UNC path on a Unix host
+func looksRemote(path string) bool {
+	if strings.HasPrefix(path, `\\`) || (len(path) > 2 && path[1] == ':' && (path[2] == '\\' || path[2] == '/')) {
+		return filepath.Separator == '/'
+	}
+	if !filepath.IsAbs(path) {
+		return false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(filepath.ToSlash(path), "/"), "/", 2)
+	if parts[0] == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(string(filepath.Separator), parts[0]))
+	return os.IsNotExist(err)
+}
+
+// ========================
+// Path mapping endpoints
+// ========================
+
+// ListPathMappings returns the remote path mappings
+// GET /api/downloads/path-mappings
+func (h *Handler) ListPathMappings(w http.ResponseWriter, r *http.Request) {
+	mappings, err := LoadPathMappings(r.Context(), h.configStore)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to load path mappings")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"mappings": mappings})
+}
+
+// CreatePathMapping adds a remote path mapping
+// POST /api/downloads/path-mappings
+func (h *Handler) CreatePathMapping(w http.ResponseWriter, r *http.Request) {
+	var mapping RemotePathMapping
+	if err := httputil.DecodeJSON(r, &mapping); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validatePathMapping(&mapping); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	mappings, err := LoadPathMappings(r.Context(), h.configStore)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to load path mappings")
+		return
+	}
+
+	mapping.ID = newPathMappingID()
+	mappings = append(mappings, mapping)
+	if err := SavePathMappings(r.Context(), h.configStore, mappings); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to save path mappings")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusCreated, mapping)
+}
+
+// UpdatePathMapping replaces a remote path mapping
+// PUT /api/downloads/path-mappings/{id}
+func (h *Handler) UpdatePathMapping(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var mapping RemotePathMapping
+	if err := httputil.DecodeJSON(r, &mapping); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validatePathMapping(&mapping); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	mappings, err := LoadPathMappings(r.Context(), h.configStore)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to load path mappings")
+		return
+	}
+
+	found := false
+	for i := range mappings {
+		if mappings[i].ID == id {
+			mapping.ID = id
+			mappings[i] = mapping
+			found = true
+			break
+		}
+	}
+	if !found {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Path mapping not found")
+		return
+	}
+
+	if err := SavePathMappings(r.Context(), h.configStore, mappings); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to save path mappings")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, mapping)
+}
+
+// DeletePathMapping removes a remote path mapping
+// DELETE /api/downloads/path-mappings/{id}
+func (h *Handler) DeletePathMapping(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	mappings, err := LoadPathMappings(r.Context(), h.configStore)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to load path mappings")
+		return
+	}
+
+	remaining := make([]RemotePathMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.ID != id {
+			remaining = append(remaining, mapping)
+		}
+	}
+	if len(remaining) == len(mappings) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Path mapping not found")
+		return
+	}
+
+	if err := SavePathMappings(r.Context(), h.configStore, remaining); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to save path mappings")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validatePathMapping normalizes a mapping and returns a message when it is invalid
+func validatePathMapping(mapping *RemotePathMapping) string {
+	mapping.PluginID = strings.TrimSpace(mapping.PluginID)
+	mapping.Host = strings.TrimSpace(mapping.Host)
+	mapping.RemotePath = cleanRemotePath(mapping.RemotePath)
+	mapping.LocalPath = strings.TrimSpace(mapping.LocalPath)
+
+	if mapping.RemotePath == "" {
+		return "remote_path is required"
+	}
+	if mapping.LocalPath == "" {
+		return "local_path is required"
+	}
+	if !filepath.IsAbs(mapping.LocalPath) {
+		return "local_path must be an absolute path"
+	}
+	mapping.LocalPath = filepath.Clean(mapping.LocalPath)
+	return ""
+}
+
+func newPathMappingID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
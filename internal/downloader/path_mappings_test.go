@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMapRemotePath(t *testing.T) {
+	mappings := []RemotePathMapping{
+		{ID: "all", RemotePath: "/downloads", LocalPath: "/mnt/media/downloads"},
+		{ID: "complete", RemotePath: "/downloads/complete/", LocalPath: "/mnt/fast/complete"},
+		{ID: "torrent", PluginID: "remote-torrent", RemotePath: "/data", LocalPath: "/mnt/torrents"},
+		{ID: "windows", Host: "seedbox", RemotePath: `D:\Downloads`, LocalPath: "/mnt/seedbox"},
+	}
+
+	tests := []struct {
+		name     string
+		pluginID string
+		host     string
+		path     string
+		want     string
+		mapped   bool
+	}{
+		{"prefix", "nzb-downloader", "", "/downloads/incomplete/Show", "/mnt/media/downloads/incomplete/Show", true},
+		{"most specific wins", "nzb-downloader", "", "/downloads/complete/Show/file.mkv", "/mnt/fast/complete/Show/file.mkv", true},
+		{"exact prefix", "nzb-downloader", "", "/downloads", "/mnt/media/downloads", true},
+		{"whole components only", "nzb-downloader", "", "/downloads-old/Show", "/downloads-old/Show", false},
+		{"plugin specific", "remote-torrent", "", "/data/Movie", "/mnt/torrents/Movie", true},
+		{"other plugin", "nzb-downloader", "", "/data/Movie", "/data/Movie", false},
+		{"windows host", "remote-torrent", "SEEDBOX", `D:\Downloads\Movie\movie.mkv`, "/mnt/seedbox/Movie/movie.mkv", true},
+		{"windows other host", "remote-torrent", "nas", `D:\Downloads\Movie`, `D:\Downloads\Movie`, false},
+	}
+
+	for _, tt := range tests {
+		got, mapped := MapRemotePath(mappings, tt.pluginID, tt.host, tt.path)
+		if got != tt.want || mapped != tt.mapped {
+			t.Errorf("%s: MapRemotePath(%q) = %q, %v, want %q, %v", tt.name, tt.path, got, mapped, tt.want, tt.mapped)
+		}
+	}
+}
+
+func TestLooksRemote(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		// The top-level directory exists here, so the path is a local one that's missing
+		{filepath.Join(dir, "missing", "file.mkv"), false},
+		{"/nimbus-no-such-root/complete/file.mkv", true},
+		{`\\nas\downloads\file.mkv`, true},
+		{`C:\Downloads\file.mkv`, true},
+		{"relative/file.mkv", false},
+	}
+
+	for _, tt := range tests {
+		if got := looksRemote(tt.path); got != tt.want {
+			t.Errorf("looksRemote(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Downloaders report the post-processing phase of a processing download
+// (verifying, repairing, extracting, importing), how far along it is and when
+// each phase started and finished, so clients can show what a download is
+// doing and the history how long each stage took.
+
+// DownloadPhase records when a download started and finished a
+// post-processing phase
+type DownloadPhase struct {
+	Phase      string     `json:"phase"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// phaseDurations returns how many seconds a download spent in each finished
+// phase, adding up a phase it went through more than once
+func phaseDurations(phases []DownloadPhase) map[string]float64 {
+	durations := make(map[string]float64)
+	for _, phase := range phases {
+		if phase.FinishedAt == nil {
+			continue
+		}
+		durations[phase.Phase] += phase.FinishedAt.Sub(phase.StartedAt).Seconds()
+	}
+	return durations
+}
+
+// recordPhases adds the post-processing phases of a finished download and
+// how many seconds each took to the data of its history event
+func recordPhases(data map[string]interface{}, download *Download) {
+	if len(download.Phases) == 0 {
+		return
+	}
+	data["phases"] = download.Phases
+	data["phase_durations"] = phaseDurations(download.Phases)
+}
+
+// unmarshalPhases decodes the phases of a download as stored, nil when it
+// has none or they can't be read
+func unmarshalPhases(data []byte) []DownloadPhase {
+	if len(data) == 0 {
+		return nil
+	}
+	var phases []DownloadPhase
+	if err := json.Unmarshal(data, &phases); err != nil {
+		return nil
+	}
+	return phases
+}
+
+// marshalPhases encodes the phases of a download for storage, nil when it
+// has none
+func marshalPhases(phases []DownloadPhase) []byte {
+	if len(phases) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(phases)
+	return data
+}
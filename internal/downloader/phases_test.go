@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhaseDurations(t *testing.T) {
+	start := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) *time.Time {
+		t := start.Add(time.Duration(seconds) * time.Second)
+		return &t
+	}
+	phases := []DownloadPhase{
+		{Phase: "verifying", StartedAt: start, FinishedAt: at(30)},
+		{Phase: "extracting", StartedAt: *at(30), FinishedAt: at(630)},
+		{Phase: "importing", StartedAt: *at(630), FinishedAt: at(640)},
+		{Phase: "importing", StartedAt: *at(700), FinishedAt: at(705)},
+		{Phase: "repairing", StartedAt: *at(705)},
+	}
+
+	durations := phaseDurations(phases)
+	want := map[string]float64{"verifying": 30, "extracting": 600, "importing": 15}
+	if len(durations) != len(want) {
+		t.Errorf("durations = %v, want %v", durations, want)
+	}
+	for phase, seconds := range want {
+		if durations[phase] != seconds {
+			t.Errorf("%s took %v seconds, want %v", phase, durations[phase], seconds)
+		}
+	}
+}
+
+func TestMarshalPhases(t *testing.T) {
+	if data := marshalPhases(nil); data != nil {
+		t.Errorf("marshalPhases(nil) = %s, want nil", data)
+	}
+
+	finished := time.Date(2026, 1, 10, 12, 5, 0, 0, time.UTC)
+	phases := []DownloadPhase{{Phase: "extracting", StartedAt: finished.Add(-5 * time.Minute), FinishedAt: &finished}}
+	got := unmarshalPhases(marshalPhases(phases))
+	if len(got) != 1 || got[0].Phase != "extracting" || !got[0].FinishedAt.Equal(finished) {
+		t.Errorf("phases after a round trip = %+v", got)
+	}
+	if got := unmarshalPhases([]byte("not json")); got != nil {
+		t.Errorf("unmarshalPhases(invalid) = %+v, want nil", got)
+	}
+}
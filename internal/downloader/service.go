@@ -7,7 +7,12 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/importer"
+	"github.com/blakestevenson/nimbus/internal/mediaservers"
+	"github.com/blakestevenson/nimbus/internal/notifications"
 	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/realtime"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -18,7 +23,34 @@ type Service struct {
 	db            *pgxpool.Pool
 	logger        *zap.Logger
 	httpClient    *http.Client
-	baseURL       string // Base URL for internal API calls (e.g., "http://localhost:8080")
+	baseURL       string // Base URL for internal API calls, from NIMBUS_INTERNAL_URL
+
+	failedHandlers   []FailedDownloadHandler
+	blocklister      Blocklister
+	importedHandlers []importer.ImportedHandler
+	notifier         *notifications.Service
+	history          *history.Service
+	hub              *realtime.Hub
+	mediaServers     *mediaservers.Service
+	importQueue      *importer.Queue
+
+	throughput *throughputTracker
+}
+
+// progressInterval is the least time between two progress updates of a
+// download pushed to clients
+const progressInterval = time.Second
+
+// FailedDownloadHandler is called when a download transitions to the failed status
+type FailedDownloadHandler func(ctx context.Context, download *Download)
+
+// Blocklister blocklists the release of a download so it isn't grabbed again
+type Blocklister func(ctx context.Context, download *Download) error
+
+// DeleteOptions chooses what is removed along with a download
+type DeleteOptions struct {
+	DeleteFiles    bool // Remove the download's files from disk
+	AddToBlocklist bool // Blocklist the release before the download is deleted
 }
 
 // NewService creates a new downloader service
@@ -30,7 +62,8 @@ func NewService(pluginManager *plugins.PluginManager, db *pgxpool.Pool, logger *
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		baseURL: "http://localhost:8080", // Default, should be configurable
+		baseURL:    pluginManager.InternalAPI().BaseURL,
+		throughput: newThroughputTracker(),
 	}
 }
 
@@ -39,6 +72,319 @@ func (s *Service) SetBaseURL(baseURL string) {
 	s.baseURL = baseURL
 }
 
+// OnDownloadFailed registers a handler that is called, in the background, whenever
+// a download transitions to the failed status. Handlers must be registered at startup.
+func (s *Service) OnDownloadFailed(handler FailedDownloadHandler) {
+	s.failedHandlers = append(s.failedHandlers, handler)
+}
+
+// SetBlocklister sets how releases of deleted downloads are blocklisted
+func (s *Service) SetBlocklister(blocklister Blocklister) {
+	s.blocklister = blocklister
+}
+
+// OnImportCompleted registers a handler that is called, in the background,
+// after a download was imported for a media item. Handlers must be registered
+// at startup.
+func (s *Service) OnImportCompleted(handler importer.ImportedHandler) {
+	s.importedHandlers = append(s.importedHandlers, handler)
+}
+
+// SetNotifier sets the service download events are sent to
+func (s *Service) SetNotifier(notifier *notifications.Service) {
+	s.notifier = notifier
+}
+
+// Notifier returns the service download and import events are sent to, if any
+func (s *Service) Notifier() *notifications.Service {
+	return s.notifier
+}
+
+// SetHistory sets the service download and import events are recorded in
+func (s *Service) SetHistory(historySvc *history.Service) {
+	s.history = historySvc
+}
+
+// History returns the service download and import events are recorded in, if any
+func (s *Service) History() *history.Service {
+	return s.history
+}
+
+// SetHub sets the hub download progress and status changes are pushed to clients through
+func (s *Service) SetHub(hub *realtime.Hub) {
+	s.hub = hub
+}
+
+// Hub returns the hub download and import events are pushed to clients through, if any
+func (s *Service) Hub() *realtime.Hub {
+	return s.hub
+}
+
+// SetMediaServers sets the service that asks media servers to scan imported
+// folders
+func (s *Service) SetMediaServers(servers *mediaservers.Service) {
+	s.mediaServers = servers
+}
+
+// MediaServers returns the service that asks media servers to scan imported
+// folders, if any
+func (s *Service) MediaServers() *mediaservers.Service {
+	return s.mediaServers
+}
+
+// SetImportQueue sets the queue imports of completed downloads run through
+func (s *Service) SetImportQueue(queue *importer.Queue) {
+	s.importQueue = queue
+}
+
+// ImportQueue returns the queue imports of completed downloads run through, if any
+func (s *Service) ImportQueue() *importer.Queue {
+	return s.importQueue
+}
+
+// downloadUpdate is the state of a download pushed to clients
+type downloadUpdate struct {
+	ID              string   `json:"id"`
+	PluginID        string   `json:"plugin_id"`
+	Name            string   `json:"name"`
+	Status          string   `json:"status"`
+	PreviousStatus  *string  `json:"previous_status,omitempty"`
+	Progress        float64  `json:"progress"`
+	DownloadedBytes int64    `json:"downloaded_bytes"`
+	TotalBytes      *int64   `json:"total_bytes,omitempty"`
+	Speed           int64    `json:"speed,omitempty"`
+	ErrorMessage    string   `json:"error_message,omitempty"`
+	MediaItemID     *int64   `json:"media_item_id,omitempty"`
+	Phase           string   `json:"phase,omitempty"`
+	PhaseProgress   *float64 `json:"phase_progress,omitempty"`
+}
+
+// publishUpdate pushes the new state of a download to clients. Status changes
+// are always sent, progress at most once per progressInterval.
+func (s *Service) publishUpdate(update downloadUpdate, previousStatus *string) {
+	topics := []string{realtime.TopicDownloads}
+	if update.MediaItemID != nil {
+		topics = append(topics, realtime.MediaTopic(*update.MediaItemID))
+	}
+
+	if previousStatus == nil || *previousStatus != update.Status {
+		update.PreviousStatus = previousStatus
+		s.hub.Publish(realtime.Event{Type: "download.status", Data: update, Topics: topics})
+		return
+	}
+	s.hub.PublishThrottled(realtime.Event{
+		Type:   "download.progress",
+		Data:   update,
+		Topics: topics,
+		Key:    "download.progress:" + update.ID,
+	}, progressInterval)
+}
+
+// handleStatusChange sends notifications and runs the failed download handlers
+// when a download just completed or failed
+func (s *Service) handleStatusChange(downloadID string, previous *string, current string) {
+	if previous != nil && *previous == current {
+		return
+	}
+
+	var eventType notifications.EventType
+	switch {
+	case current == "failed":
+		eventType = notifications.EventDownloadFailed
+	case isDownloadFinished(current) && (previous == nil || !isDownloadFinished(*previous)):
+		eventType = notifications.EventDownloadCompleted
+	default:
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		download, err := s.getStoredDownload(ctx, downloadID)
+		if err != nil {
+			s.logger.Error("Failed to load download", zap.String("download_id", downloadID), zap.Error(err))
+			return
+		}
+
+		s.notify(eventType, download)
+		s.recordHistory(ctx, eventType, download)
+		s.publishPluginEvent(eventType, download)
+		if eventType == notifications.EventDownloadFailed {
+			for _, handler := range s.failedHandlers {
+				handler(ctx, download)
+			}
+		}
+	}()
+}
+
+// recordHistory adds a download event to the history
+func (s *Service) recordHistory(ctx context.Context, eventType notifications.EventType, download *Download) {
+	data := map[string]interface{}{
+		"plugin_id": download.PluginID,
+	}
+	if download.TotalBytes != nil {
+		data["size"] = *download.TotalBytes
+	}
+	for _, key := range []string{"media_title", "quality", "indexer_name", "release_score", "grabbed_by"} {
+		if v, ok := download.Metadata[key]; ok {
+			data[key] = v
+		}
+	}
+
+	var historyType history.EventType
+	switch eventType {
+	case notifications.EventDownloadAdded:
+		historyType = history.EventGrabbed
+		if download.URL != "" {
+			data["url"] = download.URL
+		}
+	case notifications.EventDownloadCompleted:
+		historyType = history.EventDownloadCompleted
+		if download.DestinationPath != "" {
+			data["path"] = download.DestinationPath
+		}
+		recordPhases(data, download)
+	case notifications.EventDownloadFailed:
+		historyType = history.EventDownloadFailed
+		if download.ErrorMessage != "" {
+			data["error"] = download.ErrorMessage
+		}
+		recordPhases(data, download)
+	default:
+		return
+	}
+
+	downloadID := download.ID
+	s.history.Record(ctx, history.Event{
+		Type:        historyType,
+		MediaItemID: download.MediaItemID,
+		DownloadID:  &downloadID,
+		Title:       download.Name,
+		Data:        data,
+	})
+}
+
+// publishPluginEvent tells plugins that a download completed or failed
+func (s *Service) publishPluginEvent(eventType notifications.EventType, download *Download) {
+	pluginEvent := plugins.EventDownloadCompleted
+	if eventType == notifications.EventDownloadFailed {
+		pluginEvent = plugins.EventDownloadFailed
+	}
+
+	data := map[string]interface{}{
+		"download_id":      download.ID,
+		"plugin_id":        download.PluginID,
+		"name":             download.Name,
+		"status":           download.Status,
+		"destination_path": download.DestinationPath,
+		"error_message":    download.ErrorMessage,
+	}
+	if download.MediaItemID != nil {
+		data["media_item_id"] = *download.MediaItemID
+	}
+	s.pluginManager.Events().Publish(pluginEvent, data)
+}
+
+// EventBus returns the bus download and import events are published to plugins on
+func (s *Service) EventBus() *plugins.EventBus {
+	return s.pluginManager.Events()
+}
+
+// isDownloadFinished reports whether a status means the download itself is done
+func isDownloadFinished(status string) bool {
+	return status == "completed" || status == "waiting_import"
+}
+
+// notify sends a download event
+func (s *Service) notify(eventType notifications.EventType, download *Download) {
+	if s.notifier == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"download_id": download.ID,
+		"plugin_id":   download.PluginID,
+		"name":        download.Name,
+		"status":      download.Status,
+	}
+	if download.TotalBytes != nil {
+		data["size"] = *download.TotalBytes
+	}
+	if download.MediaItemID != nil {
+		data["media_item_id"] = *download.MediaItemID
+	}
+	for _, key := range []string{"media_title", "quality", "indexer_name"} {
+		if v, ok := download.Metadata[key]; ok {
+			data[key] = v
+		}
+	}
+
+	var title, message string
+	switch eventType {
+	case notifications.EventDownloadAdded:
+		title, message = "Download added", download.Name
+	case notifications.EventDownloadCompleted:
+		title, message = "Download completed", download.Name
+	case notifications.EventDownloadFailed:
+		title, message = "Download failed", download.Name
+		if download.ErrorMessage != "" {
+			message += ": " + download.ErrorMessage
+			data["error"] = download.ErrorMessage
+		}
+	}
+
+	s.notifier.Notify(notifications.NewEvent(eventType, title, message, data))
+}
+
+// getStoredDownload loads a download from the database without syncing with its plugin
+func (s *Service) getStoredDownload(ctx context.Context, downloadID string) (*Download, error) {
+	var download Download
+	var metadataJSON, phasesJSON []byte
+	var progress int
+
+	err := s.db.QueryRow(ctx, `
+		SELECT id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
+		       url, file_name, destination_path, error_message, priority,
+		       created_at, started_at, completed_at, metadata, media_item_id,
+		       COALESCE(phase, ''), phase_progress, phases
+		FROM downloads
+		WHERE id = $1
+	`, downloadID).Scan(
+		&download.ID,
+		&download.PluginID,
+		&download.Name,
+		&download.Status,
+		&progress,
+		&download.TotalBytes,
+		&download.DownloadedBytes,
+		&download.URL,
+		&download.FileName,
+		&download.DestinationPath,
+		&download.ErrorMessage,
+		&download.Priority,
+		&download.CreatedAt,
+		&download.StartedAt,
+		&download.CompletedAt,
+		&metadataJSON,
+		&download.MediaItemID,
+		&download.Phase,
+		&download.PhaseProgress,
+		&phasesJSON,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("download not found: %w", err)
+	}
+
+	download.Progress = float64(progress)
+	download.Phases = unmarshalPhases(phasesJSON)
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &download.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return &download, nil
+}
+
 // Initialize synchronizes pending downloads from the database to their respective plugin queues
 func (s *Service) Initialize(ctx context.Context) error {
 	s.logger.Info("Initializing downloader service and syncing queued downloads")
@@ -98,21 +444,57 @@ func (s *Service) Initialize(ctx context.Context) error {
 			}
 		}
 
+		// Get the plugin
+		plugin, exists := s.pluginManager.GetPlugin(download.PluginID)
+		if !exists {
+			s.logger.Warn("Plugin not found for download during sync, will retry later",
+				zap.String("download_id", download.ID),
+				zap.String("plugin_id", download.PluginID))
+			continue
+		}
+
+		// Plugins that keep their queue across restarts still have the
+		// download, and only its state needs syncing
+		restored, err := s.restoreFromPlugin(ctx, plugin, &download)
+		if err != nil {
+			s.logger.Error("Failed to check download in plugin queue",
+				zap.String("download_id", download.ID),
+				zap.String("plugin_id", download.PluginID),
+				zap.Error(err))
+			continue
+		}
+		if restored {
+			syncCount++
+			continue
+		}
+
+		// Uploaded files were only kept by the plugin, so without a URL there
+		// is nothing to add the download again from
+		if download.URL == "" {
+			s.logger.Warn("Download was lost by its plugin and has no URL, marking as failed",
+				zap.String("download_id", download.ID),
+				zap.String("plugin_id", download.PluginID))
+			s.markRestoreFailed(ctx, download.ID, "Failed to restore download on server restart: the plugin no longer has it and it has no URL to add it again")
+			continue
+		}
+
 		// Recreate the download in the plugin's queue
 		s.logger.Info("Syncing download to plugin queue",
 			zap.String("download_id", download.ID),
 			zap.String("plugin_id", download.PluginID),
 			zap.String("name", download.Name))
 
-		// Prepare request to recreate the download in the plugin
+		// Prepare request to recreate the download in the plugin, under the
+		// same ID where the plugin supports it
 		reqBody := map[string]interface{}{
+			"id":       download.ID,
 			"name":     download.Name,
 			"priority": download.Priority,
 			"metadata": download.Metadata,
+			"url":      download.URL,
 		}
-
-		if download.URL != "" {
-			reqBody["url"] = download.URL
+		if wanted, ok := download.Metadata["wanted_episodes"]; ok {
+			reqBody["wanted_episodes"] = wanted
 		}
 
 		bodyJSON, err := json.Marshal(reqBody)
@@ -123,15 +505,6 @@ func (s *Service) Initialize(ctx context.Context) error {
 			continue
 		}
 
-		// Get the plugin
-		plugin, exists := s.pluginManager.GetPlugin(download.PluginID)
-		if !exists {
-			s.logger.Warn("Plugin not found for download during sync, will retry later",
-				zap.String("download_id", download.ID),
-				zap.String("plugin_id", download.PluginID))
-			continue
-		}
-
 		// Call plugin to recreate the download
 		pluginReq := &plugins.PluginHTTPRequest{
 			Method:  "POST",
@@ -158,16 +531,7 @@ func (s *Service) Initialize(ctx context.Context) error {
 				zap.String("response", string(pluginResp.Body)))
 
 			// Mark the download as failed in the database since we can't sync it
-			_, err = s.db.Exec(ctx, `
-				UPDATE downloads
-				SET status = 'failed',
-				    error_message = 'Failed to restore download on server restart: NZB data not available',
-				    updated_at = CURRENT_TIMESTAMP
-				WHERE id = $1
-			`, download.ID)
-			if err != nil {
-				s.logger.Error("Failed to mark download as failed", zap.String("download_id", download.ID), zap.Error(err))
-			}
+			s.markRestoreFailed(ctx, download.ID, fmt.Sprintf("Failed to restore download on server restart: plugin returned HTTP %d", pluginResp.StatusCode))
 			continue
 		}
 
@@ -184,6 +548,57 @@ func (s *Service) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// restoreFromPlugin asks the plugin of a download whether it still has it
+// after a restart, and if so saves the state the plugin reports
+func (s *Service) restoreFromPlugin(ctx context.Context, plugin *plugins.LoadedPlugin, download *Download) (bool, error) {
+	pluginResp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/api/plugins/%s/downloads/%s", download.PluginID, download.ID),
+		Headers: map[string][]string{},
+		Query:   map[string][]string{},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch pluginResp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return false, nil
+	default:
+		return false, fmt.Errorf("plugin returned HTTP %d", pluginResp.StatusCode)
+	}
+
+	var live Download
+	if err := json.Unmarshal(pluginResp.Body, &live); err != nil || live.ID != download.ID {
+		// Not a download, so the plugin can't be asked for one
+		return false, nil
+	}
+	live.PluginID = download.PluginID
+	if err := s.saveDownloadToDB(ctx, &live, nil); err != nil {
+		return false, err
+	}
+	s.logger.Info("Download restored by its plugin",
+		zap.String("download_id", download.ID),
+		zap.String("plugin_id", download.PluginID),
+		zap.String("status", live.Status))
+	return true, nil
+}
+
+// markRestoreFailed fails a download that couldn't be restored after a restart
+func (s *Service) markRestoreFailed(ctx context.Context, downloadID, message string) {
+	_, err := s.db.Exec(ctx, `
+		UPDATE downloads
+		SET status = 'failed',
+		    error_message = $2,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, downloadID, message)
+	if err != nil {
+		s.logger.Error("Failed to mark download as failed", zap.String("download_id", downloadID), zap.Error(err))
+	}
+}
+
 // DownloadRequest represents a unified download request
 type DownloadRequest struct {
 	PluginID    string                 `json:"plugin_id"`    // Which downloader plugin to use (e.g., "nzb-downloader")
@@ -193,6 +608,10 @@ type DownloadRequest struct {
 	FileName    string                 `json:"file_name"`    // Original filename
 	Priority    int                    `json:"priority"`     // Download priority (higher = more important)
 	Metadata    map[string]interface{} `json:"metadata"`     // Plugin-specific metadata
+
+	// WantedEpisodes are the episodes a season pack is grabbed for, so the
+	// plugin can skip the files of the others
+	WantedEpisodes []plugins.WantedEpisode `json:"wanted_episodes,omitempty"`
 }
 
 // Download represents a download in the system
@@ -216,12 +635,31 @@ type Download struct {
 	StartedAt       *time.Time             `json:"started_at,omitempty"`
 	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	MediaItemID     *int64                 `json:"media_item_id,omitempty"`
+
+	// Post-processing phase of a processing download, how far along it is in
+	// percent when the downloader tells, and the phases it went through
+	Phase         string          `json:"phase,omitempty"`
+	PhaseProgress *float64        `json:"phase_progress,omitempty"`
+	Phases        []DownloadPhase `json:"phases,omitempty"`
+
+	// Estimates from the downloader's recent throughput, worked out again on
+	// every list
+	EstimatedStartAt      *time.Time `json:"estimated_start_at,omitempty"`
+	EstimatedCompletionAt *time.Time `json:"estimated_completion_at,omitempty"`
 }
 
 // DownloadResponse represents aggregated download information
 type DownloadResponse struct {
 	Downloads []Download
-	Total     int
+	Total     int // Downloads matching the filter, on every page
+	Limit     int
+	Offset    int
+	HasMore   bool
+
+	// EstimatedCompletionAt is when the last listed download is estimated to
+	// complete
+	EstimatedCompletionAt *time.Time
 }
 
 // DownloaderInfo contains information about an available downloader plugin
@@ -235,20 +673,30 @@ type DownloaderInfo struct {
 // saveDownloadToDB persists a download to the database
 func (s *Service) saveDownloadToDB(ctx context.Context, download *Download, userID *int) error {
 	query := `
+		WITH previous AS (SELECT status FROM downloads WHERE id = $1)
 		INSERT INTO downloads (
 			id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
 			url, file_name, destination_path, error_message, priority,
-			created_at, started_at, completed_at, metadata, created_by_user_id, media_item_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+			created_at, started_at, completed_at, metadata, created_by_user_id, media_item_id, queue_position,
+			phase, phase_progress, phases
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+		          NULLIF($20, ''), $21, $22)
 		ON CONFLICT (id) DO UPDATE SET
 			status = EXCLUDED.status,
+			-- Only the list of a plugin's downloads tells their queue positions
+			queue_position = CASE WHEN EXCLUDED.status = 'queued'
+			                      THEN COALESCE(EXCLUDED.queue_position, downloads.queue_position) END,
 			progress = EXCLUDED.progress,
 			downloaded_bytes = EXCLUDED.downloaded_bytes,
 			error_message = EXCLUDED.error_message,
 			started_at = EXCLUDED.started_at,
 			completed_at = EXCLUDED.completed_at,
 			media_item_id = EXCLUDED.media_item_id,
+			phase = EXCLUDED.phase,
+			phase_progress = EXCLUDED.phase_progress,
+			phases = EXCLUDED.phases,
 			updated_at = CURRENT_TIMESTAMP
+		RETURNING (SELECT status FROM previous)
 	`
 
 	metadataJSON, err := json.Marshal(download.Metadata)
@@ -261,46 +709,17 @@ func (s *Service) saveDownloadToDB(ctx context.Context, download *Download, user
 		createdAt = download.AddedAt
 	}
 
-	// Extract media_item_id from metadata if present
-	var mediaItemID *int64
-	if download.Metadata != nil {
-		s.logger.Debug("Download metadata", zap.Any("metadata", download.Metadata))
-		if mediaIDRaw, ok := download.Metadata["media_id"]; ok {
-			s.logger.Debug("Found media_id in metadata", zap.Any("media_id_raw", mediaIDRaw), zap.String("type", fmt.Sprintf("%T", mediaIDRaw)))
-			// Handle both string and numeric types
-			switch v := mediaIDRaw.(type) {
-			case string:
-				if v != "" {
-					var id int64
-					if _, err := fmt.Sscanf(v, "%d", &id); err == nil {
-						mediaItemID = &id
-						s.logger.Debug("Parsed media_id from string", zap.Int64("media_item_id", id))
-					}
-				}
-			case float64:
-				id := int64(v)
-				mediaItemID = &id
-				s.logger.Debug("Converted media_id from float64", zap.Int64("media_item_id", id))
-			case int:
-				id := int64(v)
-				mediaItemID = &id
-				s.logger.Debug("Converted media_id from int", zap.Int64("media_item_id", id))
-			case int64:
-				mediaItemID = &v
-				s.logger.Debug("Using media_id as int64", zap.Int64("media_item_id", v))
-			}
-		} else {
-			s.logger.Debug("No media_id found in metadata")
-		}
-	} else {
-		s.logger.Debug("No metadata provided")
+	mediaItemID := mediaIDFromMetadata(download.Metadata)
+	if download.MediaItemID == nil {
+		download.MediaItemID = mediaItemID
 	}
 
 	if mediaItemID != nil {
 		s.logger.Info("Saving download with media_item_id", zap.String("download_id", download.ID), zap.Int64("media_item_id", *mediaItemID))
 	}
 
-	_, err = s.db.Exec(ctx, query,
+	var previousStatus *string
+	err = s.db.QueryRow(ctx, query,
 		download.ID,
 		download.PluginID,
 		download.Name,
@@ -319,9 +738,53 @@ func (s *Service) saveDownloadToDB(ctx context.Context, download *Download, user
 		metadataJSON,
 		userID,
 		mediaItemID,
-	)
+		download.QueuePosition,
+		download.Phase,
+		download.PhaseProgress,
+		marshalPhases(download.Phases),
+	).Scan(&previousStatus)
+	if err != nil {
+		return err
+	}
 
-	return err
+	s.throughput.record(download.PluginID, download.ID, download.Status, download.DownloadedBytes, time.Now())
+	s.handleStatusChange(download.ID, previousStatus, download.Status)
+	s.publishUpdate(downloadUpdate{
+		ID:              download.ID,
+		PluginID:        download.PluginID,
+		Name:            download.Name,
+		Status:          download.Status,
+		Progress:        download.Progress,
+		DownloadedBytes: download.DownloadedBytes,
+		TotalBytes:      download.TotalBytes,
+		Speed:           download.Speed,
+		ErrorMessage:    download.ErrorMessage,
+		MediaItemID:     download.MediaItemID,
+		Phase:           download.Phase,
+		PhaseProgress:   download.PhaseProgress,
+	}, previousStatus)
+	return nil
+}
+
+// mediaIDFromMetadata reads the media item ID a download was grabbed for from
+// its metadata, where it may be stored as a number or a string
+func mediaIDFromMetadata(metadata map[string]interface{}) *int64 {
+	var id int64
+	switch v := metadata["media_id"].(type) {
+	case string:
+		if _, err := fmt.Sscanf(v, "%d", &id); err != nil {
+			return nil
+		}
+	case float64:
+		id = int64(v)
+	case int:
+		id = int64(v)
+	case int64:
+		id = v
+	default:
+		return nil
+	}
+	return &id
 }
 
 // CreateDownload creates a new download via the appropriate plugin
@@ -351,33 +814,17 @@ func (s *Service) CreateDownload(ctx context.Context, req DownloadRequest) (*Dow
 		return nil, fmt.Errorf("plugin %s is not a downloader", req.PluginID)
 	}
 
-	// Prepare request body
-	reqBody := map[string]interface{}{
-		"name":     req.Name,
-		"priority": req.Priority,
-		"metadata": req.Metadata,
-	}
-
-	if req.URL != "" {
-		reqBody["url"] = req.URL
-	}
-
-	if len(req.FileContent) > 0 {
-		reqBody["file_content"] = req.FileContent
-		reqBody["file_name"] = req.FileName
-	}
-
-	bodyJSON, err := json.Marshal(reqBody)
+	body, contentType, err := encodeDownloadRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to encode request: %w", err)
 	}
 
 	// Call plugin directly via RPC instead of HTTP to avoid auth issues
 	pluginReq := &plugins.PluginHTTPRequest{
 		Method:  "POST",
 		Path:    fmt.Sprintf("/api/plugins/%s/downloads", req.PluginID),
-		Headers: map[string][]string{"Content-Type": {"application/json"}},
-		Body:    bodyJSON,
+		Headers: map[string][]string{"Content-Type": {contentType}},
+		Body:    body,
 		Query:   map[string][]string{},
 	}
 
@@ -406,6 +853,9 @@ func (s *Service) CreateDownload(ctx context.Context, req DownloadRequest) (*Dow
 		// Don't fail the request, download is still created in plugin
 	}
 
+	s.notify(notifications.EventDownloadAdded, &download)
+	s.recordHistory(ctx, notifications.EventDownloadAdded, &download)
+
 	s.logger.Info("Download created and persisted",
 		zap.String("download_id", download.ID),
 		zap.String("plugin_id", req.PluginID),
@@ -460,29 +910,50 @@ func (s *Service) UpsertDownload(ctx context.Context, downloadID string, payload
 	fileName, _ := payload["file_name"].(string)
 	errorMessage, _ := payload["error_message"].(string)
 	priority, _ := payload["priority"].(float64)
+	phase, _ := payload["phase"].(string)
+	var phaseProgress *float64
+	if p, ok := payload["phase_progress"].(float64); ok {
+		phaseProgress = &p
+	}
 
 	// Convert metadata to JSON
 	var metadataJSON []byte
 	if metadata, ok := payload["metadata"].(map[string]interface{}); ok {
 		metadataJSON, _ = json.Marshal(metadata)
 	}
+	var phasesJSON []byte
+	if phases, ok := payload["phases"].([]interface{}); ok && len(phases) > 0 {
+		phasesJSON, _ = json.Marshal(phases)
+	}
 
 	// Upsert query
 	query := `
+		WITH previous AS (SELECT status FROM downloads WHERE id = $1)
 		INSERT INTO downloads (
 			id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
-			url, file_name, error_message, priority, metadata, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE($13, NOW()), NOW())
+			url, file_name, error_message, priority, metadata, created_at, updated_at,
+			phase, phase_progress, phases
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE($13, NOW()), NOW(),
+		          NULLIF($15, ''), $16, $17)
 		ON CONFLICT (id) DO UPDATE SET
-			status = EXCLUDED.status,
+			-- A plugin only learns that its files were imported manually on its next
+			-- state change, so a stale waiting_import must not undo the completion
+			status = CASE WHEN downloads.status = 'completed' AND EXCLUDED.status = 'waiting_import'
+			              THEN downloads.status ELSE EXCLUDED.status END,
 			progress = EXCLUDED.progress,
 			downloaded_bytes = EXCLUDED.downloaded_bytes,
-			error_message = EXCLUDED.error_message,
+			queue_position = CASE WHEN EXCLUDED.status = 'queued' THEN downloads.queue_position END,
+			error_message = CASE WHEN downloads.status = 'completed' AND EXCLUDED.status = 'waiting_import'
+			                     THEN downloads.error_message ELSE EXCLUDED.error_message END,
 			updated_at = NOW(),
 			started_at = CASE WHEN downloads.started_at IS NULL AND EXCLUDED.status = 'downloading'
 			                  THEN NOW() ELSE downloads.started_at END,
 			completed_at = CASE WHEN EXCLUDED.status IN ('completed', 'failed')
-			                    THEN COALESCE($14, NOW()) ELSE downloads.completed_at END
+			                    THEN COALESCE($14, NOW()) ELSE downloads.completed_at END,
+			phase = EXCLUDED.phase,
+			phase_progress = EXCLUDED.phase_progress,
+			phases = EXCLUDED.phases
+		RETURNING (SELECT status FROM previous)
 	`
 
 	var createdAt, completedAt interface{}
@@ -493,40 +964,97 @@ func (s *Service) UpsertDownload(ctx context.Context, downloadID string, payload
 		completedAt = ca
 	}
 
-	_, err := s.db.Exec(ctx, query,
+	var previousStatus *string
+	err := s.db.QueryRow(ctx, query,
 		downloadID, pluginID, name, status, progress, int64(totalBytes), int64(downloadedBytes),
 		url, fileName, errorMessage, int(priority), metadataJSON, createdAt, completedAt,
-	)
+		phase, phaseProgress, phasesJSON,
+	).Scan(&previousStatus)
+	if err != nil {
+		return err
+	}
 
-	return err
+	s.throughput.record(pluginID, downloadID, status, int64(downloadedBytes), time.Now())
+	s.handleStatusChange(downloadID, previousStatus, status)
+	metadata, _ := payload["metadata"].(map[string]interface{})
+	speed, _ := payload["speed"].(float64)
+	update := downloadUpdate{
+		ID:              downloadID,
+		PluginID:        pluginID,
+		Name:            name,
+		Status:          status,
+		Progress:        progress,
+		DownloadedBytes: int64(downloadedBytes),
+		Speed:           int64(speed),
+		ErrorMessage:    errorMessage,
+		MediaItemID:     mediaIDFromMetadata(metadata),
+		Phase:           phase,
+		PhaseProgress:   phaseProgress,
+	}
+	if totalBytes > 0 {
+		total := int64(totalBytes)
+		update.TotalBytes = &total
+	}
+	s.publishUpdate(update, previousStatus)
+
+	// Plugins attach a warning when a download needs attention, e.g. it is
+	// waiting for disk space
+	if warning, _ := payload["warning"].(string); warning != "" {
+		s.notifyWarning(downloadID, pluginID, name, warning)
+	}
+	return nil
 }
 
-// ListDownloads retrieves all downloads from the database, syncing with plugins for active downloads
-func (s *Service) ListDownloads(ctx context.Context, pluginID string, status string) (*DownloadResponse, error) {
-	// Build query with optional filters
-	query := `
-		SELECT id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
-		       url, file_name, destination_path, error_message, queue_position, priority,
-		       created_at, started_at, completed_at, metadata, media_item_id
-		FROM downloads
-		WHERE 1=1
-	`
-	args := []interface{}{}
-	argNum := 1
+// notifyWarning sends a health notification about a download and pushes it
+// to clients
+func (s *Service) notifyWarning(downloadID, pluginID, name, warning string) {
+	s.logger.Warn("Download needs attention",
+		zap.String("download_id", downloadID),
+		zap.String("plugin_id", pluginID),
+		zap.String("warning", warning))
+
+	s.hub.Publish(realtime.Event{
+		Type: "download.warning",
+		Data: map[string]interface{}{
+			"download_id": downloadID,
+			"plugin_id":   pluginID,
+			"name":        name,
+			"warning":     warning,
+		},
+		Topics: []string{realtime.TopicHealth, realtime.TopicDownloads},
+	})
 
-	if pluginID != "" {
-		query += fmt.Sprintf(" AND plugin_id = $%d", argNum)
-		args = append(args, pluginID)
-		argNum++
+	if s.notifier == nil {
+		return
 	}
+	s.notifier.Notify(notifications.NewEvent(notifications.EventHealthIssue, "Download needs attention",
+		fmt.Sprintf("%s: %s", name, warning),
+		map[string]interface{}{
+			"download_id": downloadID,
+			"plugin_id":   pluginID,
+			"name":        name,
+			"warning":     warning,
+		}))
+}
 
-	if status != "" {
-		query += fmt.Sprintf(" AND status = $%d", argNum)
-		args = append(args, status)
-		argNum++
+// ListDownloads retrieves a page of downloads from the database, syncing the
+// active downloads of the page with their plugins
+func (s *Service) ListDownloads(ctx context.Context, filter ListFilter) (*DownloadResponse, error) {
+	where, args := filter.where()
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM downloads"+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count downloads: %w", err)
 	}
 
-	query += " ORDER BY created_at DESC"
+	query := `
+		SELECT id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
+		       url, file_name, destination_path, error_message, queue_position, priority,
+		       created_at, started_at, completed_at, metadata, media_item_id,
+		       COALESCE(phase, ''), phase_progress, phases
+		FROM downloads` + where + filter.orderBy() +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, filter.Offset)
 
 	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
@@ -538,7 +1066,7 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 
 	for rows.Next() {
 		var download Download
-		var metadataJSON []byte
+		var metadataJSON, phasesJSON []byte
 		var progress int
 		var mediaItemID *int64
 
@@ -561,6 +1089,9 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 			&download.CompletedAt,
 			&metadataJSON,
 			&mediaItemID,
+			&download.Phase,
+			&download.PhaseProgress,
+			&phasesJSON,
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan download row", zap.Error(err))
@@ -568,6 +1099,7 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 		}
 
 		download.Progress = float64(progress)
+		download.Phases = unmarshalPhases(phasesJSON)
 
 		if len(metadataJSON) > 0 {
 			if err := json.Unmarshal(metadataJSON, &download.Metadata); err != nil {
@@ -583,7 +1115,7 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 		return nil, fmt.Errorf("error iterating downloads: %w", err)
 	}
 
-	// Fetch live data from plugins for active downloads
+	// Fetch live data from plugins for the active downloads of the page
 	// Group downloads by plugin for efficiency
 	pluginDownloads := make(map[string][]int) // plugin_id -> indices in allDownloads
 	for i, download := range allDownloads {
@@ -619,10 +1151,23 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 			continue
 		}
 
-		// Create a map of plugin downloads by ID for quick lookup
+		// Create a map of plugin downloads by ID for quick lookup. Plugins list
+		// downloads in queue order, which gives the queue positions of those
+		// that don't report one.
 		liveDownloadMap := make(map[string]*Download)
+		position := 0
 		for i := range pluginDownloadsList.Downloads {
-			liveDownloadMap[pluginDownloadsList.Downloads[i].ID] = &pluginDownloadsList.Downloads[i]
+			live := &pluginDownloadsList.Downloads[i]
+			liveDownloadMap[live.ID] = live
+			if live.Status != "queued" {
+				live.QueuePosition = nil
+				continue
+			}
+			position++
+			if live.QueuePosition == nil {
+				pos := position
+				live.QueuePosition = &pos
+			}
 		}
 
 		// Update downloads with live data
@@ -635,6 +1180,13 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 				allDownloads[idx].ErrorMessage = liveDownload.ErrorMessage
 				allDownloads[idx].StartedAt = liveDownload.StartedAt
 				allDownloads[idx].CompletedAt = liveDownload.CompletedAt
+				allDownloads[idx].QueuePosition = liveDownload.QueuePosition
+				allDownloads[idx].Phase = liveDownload.Phase
+				allDownloads[idx].PhaseProgress = liveDownload.PhaseProgress
+				allDownloads[idx].Phases = liveDownload.Phases
+				if liveDownload.TotalBytes != nil {
+					allDownloads[idx].TotalBytes = liveDownload.TotalBytes
+				}
 
 				// Persist updated status to database
 				if err := s.saveDownloadToDB(ctx, &allDownloads[idx], nil); err != nil {
@@ -646,9 +1198,21 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 		}
 	}
 
+	now := time.Now()
+	rates := make(map[string]float64)
+	for _, download := range allDownloads {
+		if _, ok := rates[download.PluginID]; !ok {
+			rates[download.PluginID] = s.throughput.rate(download.PluginID, now)
+		}
+	}
+
 	return &DownloadResponse{
-		Downloads: allDownloads,
-		Total:     len(allDownloads),
+		Downloads:             allDownloads,
+		Total:                 total,
+		Limit:                 filter.Limit,
+		Offset:                filter.Offset,
+		HasMore:               filter.Offset+len(allDownloads) < total,
+		EstimatedCompletionAt: estimateQueue(allDownloads, rates, now),
 	}, nil
 }
 
@@ -656,14 +1220,15 @@ func (s *Service) ListDownloads(ctx context.Context, pluginID string, status str
 func (s *Service) GetDownload(ctx context.Context, downloadID string, pluginID string) (*Download, error) {
 	// First, try to get from database
 	var download Download
-	var metadataJSON []byte
+	var metadataJSON, phasesJSON []byte
 	var progress int
 	var mediaItemID *int64
 
 	err := s.db.QueryRow(ctx, `
 		SELECT id, plugin_id, name, status, progress, total_bytes, downloaded_bytes,
 		       url, file_name, destination_path, error_message, queue_position, priority,
-		       created_at, started_at, completed_at, metadata, media_item_id
+		       created_at, started_at, completed_at, metadata, media_item_id,
+		       COALESCE(phase, ''), phase_progress, phases
 		FROM downloads
 		WHERE id = $1 AND plugin_id = $2
 	`, downloadID, pluginID).Scan(
@@ -685,6 +1250,9 @@ func (s *Service) GetDownload(ctx context.Context, downloadID string, pluginID s
 		&download.CompletedAt,
 		&metadataJSON,
 		&mediaItemID,
+		&download.Phase,
+		&download.PhaseProgress,
+		&phasesJSON,
 	)
 
 	if err != nil {
@@ -692,6 +1260,7 @@ func (s *Service) GetDownload(ctx context.Context, downloadID string, pluginID s
 	}
 
 	download.Progress = float64(progress)
+	download.Phases = unmarshalPhases(phasesJSON)
 
 	if len(metadataJSON) > 0 {
 		if err := json.Unmarshal(metadataJSON, &download.Metadata); err != nil {
@@ -730,13 +1299,13 @@ func (s *Service) GetDownload(ctx context.Context, downloadID string, pluginID s
 
 // PauseDownload pauses a download
 func (s *Service) PauseDownload(ctx context.Context, downloadID string, pluginID string) error {
-	return s.makeControlRequest(ctx, downloadID, pluginID, "pause", "POST")
+	return s.makeControlRequest(ctx, downloadID, pluginID, "pause", "POST", nil)
 }
 
 // ResumeDownload resumes a paused download
 func (s *Service) ResumeDownload(ctx context.Context, downloadID string, pluginID string) error {
 	// First try to resume the download in the plugin
-	err := s.makeControlRequest(ctx, downloadID, pluginID, "resume", "POST")
+	err := s.makeControlRequest(ctx, downloadID, pluginID, "resume", "POST", nil)
 
 	// If the plugin returns an error (like download not found or not paused),
 	// it might be because the server restarted and the download wasn't synced.
@@ -849,9 +1418,28 @@ func (s *Service) ResumeDownload(ctx context.Context, downloadID string, pluginI
 	return nil
 }
 
-// CancelDownload cancels a download
-func (s *Service) CancelDownload(ctx context.Context, downloadID string, pluginID string) error {
-	err := s.makeControlRequest(ctx, downloadID, pluginID, "", "DELETE")
+// CancelDownload cancels a download and deletes it, optionally with its files
+// and blocklisting its release
+func (s *Service) CancelDownload(ctx context.Context, downloadID string, pluginID string, opts DeleteOptions) error {
+	if opts.AddToBlocklist {
+		if s.blocklister == nil {
+			return fmt.Errorf("blocklist is not available")
+		}
+		download, err := s.GetDownload(ctx, downloadID, pluginID)
+		if err != nil {
+			return err
+		}
+		if err := s.blocklister(ctx, download); err != nil {
+			return fmt.Errorf("failed to blocklist release: %w", err)
+		}
+	}
+
+	query := map[string][]string{}
+	if opts.DeleteFiles {
+		query["delete_files"] = []string{"true"}
+	}
+
+	err := s.makeControlRequest(ctx, downloadID, pluginID, "", "DELETE", query)
 	if err == nil {
 		// Also delete from database
 		_, err = s.db.Exec(ctx, "DELETE FROM downloads WHERE id = $1 AND plugin_id = $2", downloadID, pluginID)
@@ -861,11 +1449,11 @@ func (s *Service) CancelDownload(ctx context.Context, downloadID string, pluginI
 
 // RetryDownload retries a failed download
 func (s *Service) RetryDownload(ctx context.Context, downloadID string, pluginID string) error {
-	return s.makeControlRequest(ctx, downloadID, pluginID, "retry", "POST")
+	return s.makeControlRequest(ctx, downloadID, pluginID, "retry", "POST", nil)
 }
 
 // makeControlRequest is a helper for making control requests via RPC (pause/resume/cancel/retry)
-func (s *Service) makeControlRequest(ctx context.Context, downloadID string, pluginID string, action string, method string) error {
+func (s *Service) makeControlRequest(ctx context.Context, downloadID string, pluginID string, action string, method string, query map[string][]string) error {
 	plugin, exists := s.pluginManager.GetPlugin(pluginID)
 	if !exists {
 		return fmt.Errorf("plugin %s not found", pluginID)
@@ -883,6 +1471,9 @@ func (s *Service) makeControlRequest(ctx context.Context, downloadID string, plu
 		Body:    nil,
 		Query:   map[string][]string{},
 	}
+	for key, values := range query {
+		pluginReq.Query[key] = values
+	}
 
 	pluginResp, err := plugin.Client.HandleAPI(ctx, pluginReq)
 	if err != nil {
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+)
+
+// encodeDownloadRequest encodes a download request for a downloader plugin's
+// add endpoint. Requests with a file are sent as a multipart form, so the file
+// isn't embedded in JSON; others are sent as JSON.
+func encodeDownloadRequest(req DownloadRequest) ([]byte, string, error) {
+	if len(req.FileContent) == 0 {
+		reqBody := map[string]interface{}{
+			"name":     req.Name,
+			"priority": req.Priority,
+			"metadata": req.Metadata,
+		}
+		if req.URL != "" {
+			reqBody["url"] = req.URL
+		}
+		if len(req.WantedEpisodes) > 0 {
+			reqBody["wanted_episodes"] = req.WantedEpisodes
+		}
+		body, err := json.Marshal(reqBody)
+		return body, "application/json", err
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fields := map[string]string{
+		"name":     req.Name,
+		"priority": strconv.Itoa(req.Priority),
+	}
+	if req.URL != "" {
+		fields["url"] = req.URL
+	}
+	if req.Metadata != nil {
+		metadata, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		fields["metadata"] = string(metadata)
+	}
+	if len(req.WantedEpisodes) > 0 {
+		wanted, err := json.Marshal(req.WantedEpisodes)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode wanted episodes: %w", err)
+		}
+		fields["wanted_episodes"] = string(wanted)
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+
+	fileName := req.FileName
+	if fileName == "" {
+		fileName = req.Name
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(req.FileContent); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func TestEncodeDownloadRequestMultipart(t *testing.T) {
+	nzb := []byte(`<?xml version="1.0"?><nzb xmlns="http://www.newzbin.com/DTD/2003/nzb"></nzb>`)
+	body, contentType, err := encodeDownloadRequest(DownloadRequest{
+		Name:        "Show.S01E01",
+		FileContent: nzb,
+		FileName:    "Show.S01E01.nzb",
+		Priority:    2,
+		Metadata:    map[string]interface{}{"media_item_id": 7},
+	})
+	if err != nil {
+		t.Fatalf("encodeDownloadRequest() error = %v", err)
+	}
+
+	req := &plugins.PluginHTTPRequest{Headers: map[string][]string{"Content-Type": {contentType}}, Body: body}
+	form, err := req.MultipartForm(1 << 20)
+	if err != nil || form == nil {
+		t.Fatalf("MultipartForm() = %v, %v", form, err)
+	}
+	defer form.RemoveAll()
+
+	if got := form.Value["name"]; len(got) != 1 || got[0] != "Show.S01E01" {
+		t.Errorf("name = %v", got)
+	}
+	if got := form.Value["priority"]; len(got) != 1 || got[0] != "2" {
+		t.Errorf("priority = %v", got)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(form.Value["metadata"][0]), &metadata); err != nil || metadata["media_item_id"] != float64(7) {
+		t.Errorf("metadata = %v, %v", metadata, err)
+	}
+
+	files := form.File["file"]
+	if len(files) != 1 || files[0].Filename != "Show.S01E01.nzb" {
+		t.Fatalf("file = %v", files)
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != string(nzb) {
+		t.Errorf("file content = %q", data)
+	}
+}
+
+func TestEncodeDownloadRequestJSON(t *testing.T) {
+	body, contentType, err := encodeDownloadRequest(DownloadRequest{Name: "Movie", URL: "https://example.com/movie.nzb"})
+	if err != nil || contentType != "application/json" {
+		t.Fatalf("encodeDownloadRequest() = %s, %v", contentType, err)
+	}
+
+	req := &plugins.PluginHTTPRequest{Headers: map[string][]string{"content-type": {contentType}}, Body: body}
+	if form, err := req.MultipartForm(1 << 20); form != nil || err != nil {
+		t.Errorf("MultipartForm() of JSON = %v, %v, want nil", form, err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded["url"] != "https://example.com/movie.nzb" {
+		t.Errorf("body = %s, %v", body, err)
+	}
+}
+
+func TestEncodeDownloadRequestWantedEpisodes(t *testing.T) {
+	wanted := []plugins.WantedEpisode{{Season: 1, Episode: 3}, {Season: 1, Episode: 5}}
+	body, _, err := encodeDownloadRequest(DownloadRequest{Name: "Show.S01", URL: "https://example.com/pack.nzb", WantedEpisodes: wanted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		WantedEpisodes []plugins.WantedEpisode `json:"wanted_episodes"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil || len(decoded.WantedEpisodes) != 2 || decoded.WantedEpisodes[1] != wanted[1] {
+		t.Errorf("body = %s, %v", body, err)
+	}
+
+	// Nothing is sent for a download of every episode
+	body, _, _ = encodeDownloadRequest(DownloadRequest{Name: "Show.S01E01", URL: "https://example.com/ep.nzb"})
+	var plain map[string]interface{}
+	if json.Unmarshal(body, &plain); plain["wanted_episodes"] != nil {
+		t.Errorf("body = %s, want no wanted_episodes", body)
+	}
+}
@@ -0,0 +1,377 @@
+// Package duplicates finds media items that are in the library twice and
+// merges them.
+//
+// Two items of the same kind are duplicates when they share an external ID,
+// like the same TMDB ID, or when their titles are the same or nearly so once
+// normalized and they are from the same year. Items whose IDs of the same
+// provider differ are never duplicates. The detection task records the pairs
+// it finds in media_duplicates, replacing those of its last run, and merging
+// moves everything referring to one item of a pair onto the other.
+package duplicates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// minTitleSimilarity is how similar two normalized titles of the same year
+// must be for their items to be duplicates, 1 being the same title
+const minTitleSimilarity = 0.9
+
+// minFuzzyTitleLength is the shortest normalized title matched by similarity.
+// Shorter titles must be the same, as one letter already changes them a lot.
+const minFuzzyTitleLength = 6
+
+// detectedKinds are the kinds of media items checked for duplicates. Seasons,
+// episodes, albums and tracks are merged along with their parents.
+var detectedKinds = []string{
+	string(media.MediaKindMovie),
+	string(media.MediaKindTVSeries),
+	string(media.MediaKindMusicArtist),
+	string(media.MediaKindBook),
+	string(media.MediaKindBookSeries),
+	string(media.MediaKindMovieCollection),
+}
+
+// Reason is why two media items were taken for duplicates
+type Reason string
+
+const (
+	ReasonExternalID Reason = "external_id" // They share an external ID
+	ReasonTitle      Reason = "title"       // Their titles and years match
+)
+
+// Item is a media item of a duplicate pair
+type Item struct {
+	ID          int64                  `json:"id"`
+	Title       string                 `json:"title"`
+	Year        *int                   `json:"year,omitempty"`
+	ExternalIDs map[string]interface{} `json:"external_ids"`
+	FileCount   int                    `json:"file_count"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// Duplicate is a pair of media items flagged as the same
+type Duplicate struct {
+	ID         int64     `json:"id"`
+	Kind       string    `json:"kind"`
+	Reason     Reason    `json:"reason"`
+	Detail     string    `json:"detail,omitempty"` // The shared external ID, e.g. "tmdb:603"
+	Similarity float64   `json:"similarity"`       // Of the normalized titles, 1 when the same
+	Items      []Item    `json:"items"`            // The older item first
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// candidate is a media item checked for duplicates
+type candidate struct {
+	ID          int64
+	Kind        string
+	Title       string
+	Year        *int
+	ExternalIDs map[string]interface{}
+}
+
+// pair is two duplicate media items, the lower ID first
+type pair struct {
+	first, second int64
+	kind          string
+	reason        Reason
+	detail        string
+	similarity    float64
+}
+
+// Service detects and merges duplicate media items
+type Service struct {
+	db      *pgxpool.Pool
+	history *history.Service
+	logger  *zap.Logger
+}
+
+// NewService creates a new duplicates service
+func NewService(db *pgxpool.Pool, historySvc *history.Service, logger *zap.Logger) *Service {
+	return &Service{
+		db:      db,
+		history: historySvc,
+		logger:  logger.With(zap.String("component", "duplicates")),
+	}
+}
+
+// normalizeTitle lowercases a title, drops its punctuation and a leading
+// article, and the year it ends with when that is the item's year
+func normalizeTitle(title string, year *int) string {
+	words := strings.FieldsFunc(strings.ToLower(strings.ReplaceAll(title, "&", " and ")), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 1 {
+		switch words[0] {
+		case "the", "a", "an":
+			words = words[1:]
+		}
+	}
+	if year != nil && len(words) > 1 && words[len(words)-1] == fmt.Sprint(*year) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// titleSimilarity returns how similar two normalized titles are, from 0 for
+// nothing in common to 1 for the same title
+func titleSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance of two strings
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// externalIDs returns the external IDs of an item by provider, like "tmdb"
+// for both tmdb and tmdb_id, leaving out empty ones
+func externalIDs(ids map[string]interface{}) map[string]string {
+	normalized := make(map[string]string, len(ids))
+	for key, value := range ids {
+		var id string
+		switch v := value.(type) {
+		case string:
+			id = strings.TrimSpace(v)
+		case float64:
+			id = fmt.Sprintf("%.0f", v)
+		case int, int64:
+			id = fmt.Sprint(v)
+		}
+		if id == "" || id == "0" {
+			continue
+		}
+		normalized[strings.TrimSuffix(strings.ToLower(key), "_id")] = strings.ToLower(id)
+	}
+	return normalized
+}
+
+// conflictingIDs lists the providers two items have different IDs of, like
+// "tmdb: 603 != 604"
+func conflictingIDs(a, b map[string]interface{}) []string {
+	idsA, idsB := externalIDs(a), externalIDs(b)
+	var conflicts []string
+	for provider, id := range idsA {
+		if other, ok := idsB[provider]; ok && other != id {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s != %s", provider, id, other))
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// findDuplicates pairs up the candidates that are the same item. A candidate
+// can be in several pairs.
+func findDuplicates(candidates []candidate) []pair {
+	seen := make(map[[2]int64]bool)
+	var pairs []pair
+	add := func(a, b candidate, reason Reason, detail string, similarity float64) {
+		key := [2]int64{min(a.ID, b.ID), max(a.ID, b.ID)}
+		if seen[key] || len(conflictingIDs(a.ExternalIDs, b.ExternalIDs)) > 0 {
+			return
+		}
+		seen[key] = true
+		pairs = append(pairs, pair{first: key[0], second: key[1], kind: a.Kind, reason: reason, detail: detail, similarity: similarity})
+	}
+
+	byKind := make(map[string][]candidate)
+	for _, c := range candidates {
+		byKind[c.Kind] = append(byKind[c.Kind], c)
+	}
+	kinds := make([]string, 0, len(byKind))
+	for kind := range byKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		items := byKind[kind]
+		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+		titles := make([]string, len(items))
+		for i, item := range items {
+			titles[i] = normalizeTitle(item.Title, item.Year)
+		}
+
+		// The same ID of the same provider
+		byID := make(map[string][]int)
+		for i, item := range items {
+			for provider, id := range externalIDs(item.ExternalIDs) {
+				key := provider + ":" + id
+				byID[key] = append(byID[key], i)
+			}
+		}
+		keys := make([]string, 0, len(byID))
+		for key := range byID {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			indexes := byID[key]
+			for _, j := range indexes[1:] {
+				i := indexes[0]
+				add(items[i], items[j], ReasonExternalID, key, titleSimilarity(titles[i], titles[j]))
+			}
+		}
+
+		// Similar titles of the same year. An item without a year only matches
+		// the same title.
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				a, b := items[i], items[j]
+				if a.Year != nil && b.Year != nil {
+					if *a.Year != *b.Year {
+						continue
+					}
+				} else if titles[i] != titles[j] {
+					continue
+				}
+				if titles[i] == "" || titles[j] == "" {
+					continue
+				}
+				if titles[i] == titles[j] {
+					add(a, b, ReasonTitle, "", 1)
+					continue
+				}
+				if min(len(titles[i]), len(titles[j])) < minFuzzyTitleLength {
+					continue
+				}
+				if similarity := titleSimilarity(titles[i], titles[j]); similarity >= minTitleSimilarity {
+					add(a, b, ReasonTitle, "", similarity)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// Detect finds the duplicate media items in the library and records them,
+// replacing the pairs found before. It returns how many pairs it found.
+func (s *Service) Detect(ctx context.Context) (int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, kind, title, year, COALESCE(external_ids, '{}'::jsonb)
+		FROM media_items
+		WHERE deleted_at IS NULL AND kind = ANY($1)
+	`, detectedKinds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list media items: %w", err)
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var idsJSON []byte
+		if err := rows.Scan(&c.ID, &c.Kind, &c.Title, &c.Year, &idsJSON); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan media item: %w", err)
+		}
+		if err := json.Unmarshal(idsJSON, &c.ExternalIDs); err != nil {
+			s.logger.Warn("Invalid external IDs", zap.Int64("media_item_id", c.ID), zap.Error(err))
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list media items: %w", err)
+	}
+
+	pairs := findDuplicates(candidates)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM media_duplicates`); err != nil {
+		return 0, fmt.Errorf("failed to clear duplicates: %w", err)
+	}
+	for _, p := range pairs {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO media_duplicates (kind, media_item_id, duplicate_item_id, reason, detail, similarity)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		`, p.kind, p.first, p.second, string(p.reason), p.detail, p.similarity)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record duplicate: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit duplicates: %w", err)
+	}
+
+	s.logger.Info("Detected duplicate media items", zap.Int("items", len(candidates)), zap.Int("duplicates", len(pairs)))
+	return len(pairs), nil
+}
+
+// List returns the duplicates found by the last detection, of one kind when
+// kind isn't empty
+func (s *Service) List(ctx context.Context, kind string) ([]Duplicate, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT d.id, d.kind, d.reason, COALESCE(d.detail, ''), d.similarity, d.detected_at,
+		       a.id, a.title, a.year, COALESCE(a.external_ids, '{}'::jsonb), a.created_at,
+		       (SELECT COUNT(*) FROM media_files f WHERE f.media_item_id = a.id),
+		       b.id, b.title, b.year, COALESCE(b.external_ids, '{}'::jsonb), b.created_at,
+		       (SELECT COUNT(*) FROM media_files f WHERE f.media_item_id = b.id)
+		FROM media_duplicates d
+		JOIN media_items a ON a.id = d.media_item_id
+		JOIN media_items b ON b.id = d.duplicate_item_id
+		WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+		  AND ($1 = '' OR d.kind = $1)
+		ORDER BY d.kind, a.title, d.id
+	`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	duplicates := []Duplicate{}
+	for rows.Next() {
+		var d Duplicate
+		var a, b Item
+		var idsA, idsB []byte
+		err := rows.Scan(&d.ID, &d.Kind, &d.Reason, &d.Detail, &d.Similarity, &d.DetectedAt,
+			&a.ID, &a.Title, &a.Year, &idsA, &a.CreatedAt, &a.FileCount,
+			&b.ID, &b.Title, &b.Year, &idsB, &b.CreatedAt, &b.FileCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		_ = json.Unmarshal(idsA, &a.ExternalIDs)
+		_ = json.Unmarshal(idsB, &b.ExternalIDs)
+		d.Items = []Item{a, b}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
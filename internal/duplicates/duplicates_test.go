@@ -0,0 +1,154 @@
+package duplicates
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int { return &i }
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		year  *int
+		want  string
+	}{
+		{"The Matrix", nil, "matrix"},
+		{"Matrix, The", nil, "matrix the"},
+		{"Law & Order: SVU", nil, "law and order svu"},
+		{"Blade Runner 2049", intPtr(2017), "blade runner 2049"},
+		{"Dune (2021)", intPtr(2021), "dune"},
+		{"The", nil, "the"},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.title, tt.year); got != tt.want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	if got := titleSimilarity("lord of the rings fellowship of the ring", "lord of the rings the fellowship of the ring"); got < minTitleSimilarity {
+		t.Errorf("similarity = %.2f, want at least %.2f", got, minTitleSimilarity)
+	}
+	if got := titleSimilarity("alien", "aliens"); got >= minTitleSimilarity {
+		t.Errorf("similarity of different films = %.2f", got)
+	}
+}
+
+func TestConflictingIDs(t *testing.T) {
+	a := map[string]interface{}{"tmdb": float64(603), "imdb_id": "tt0133093"}
+	b := map[string]interface{}{"tmdb_id": "604", "imdb": "TT0133093", "tvdb_id": ""}
+	if got := conflictingIDs(a, b); !reflect.DeepEqual(got, []string{"tmdb: 603 != 604"}) {
+		t.Errorf("conflicts = %v", got)
+	}
+	if got := conflictingIDs(a, map[string]interface{}{"tmdb_id": "603"}); len(got) != 0 {
+		t.Errorf("conflicts of the same ID = %v", got)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	candidates := []candidate{
+		{ID: 1, Kind: "movie", Title: "The Matrix", Year: intPtr(1999), ExternalIDs: map[string]interface{}{"tmdb_id": "603"}},
+		// Created by the scanner with the year in its title and no IDs
+		{ID: 2, Kind: "movie", Title: "Matrix (1999)", Year: intPtr(1999)},
+		// Same TMDB ID under another title
+		{ID: 3, Kind: "movie", Title: "Matrix Reloaded", Year: intPtr(1999), ExternalIDs: map[string]interface{}{"tmdb": float64(603)}},
+		// Same title, different year
+		{ID: 4, Kind: "movie", Title: "The Matrix", Year: intPtr(2030)},
+		// Same title and year but another TMDB ID, so a different movie
+		{ID: 5, Kind: "movie", Title: "The Matrix", Year: intPtr(1999), ExternalIDs: map[string]interface{}{"tmdb_id": "999"}},
+		// A series isn't a duplicate of a movie
+		{ID: 6, Kind: "tv_series", Title: "The Matrix", Year: intPtr(1999)},
+		// No year only matches the same title
+		{ID: 7, Kind: "tv_series", Title: "Matrix"},
+		{ID: 8, Kind: "tv_series", Title: "Matrix Reloaded"},
+		{ID: 9, Kind: "movie", Title: "The Lord of the Rings Fellowship of the Ring", Year: intPtr(2001)},
+		{ID: 10, Kind: "movie", Title: "Lord of the Rings: The Fellowship of the Ring", Year: intPtr(2001)},
+	}
+
+	var got []string
+	for _, p := range findDuplicates(candidates) {
+		got = append(got, fmt.Sprintf("%s/%s/%s/%d/%d", p.kind, p.reason, p.detail, p.first, p.second))
+	}
+	want := []string{
+		"movie/external_id/tmdb:603/1/3",
+		"movie/title//1/2",
+		"movie/title//2/5",
+		"movie/title//9/10",
+		"tv_series/title//6/7",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("duplicates =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	kept := map[string]interface{}{"overview": "", "poster_path": "/kept.jpg", "genres": []interface{}{}}
+	removed := map[string]interface{}{"overview": "A hacker learns...", "poster_path": "/removed.jpg", "genres": []interface{}{"Action"}, "runtime": float64(136)}
+	want := map[string]interface{}{"overview": "A hacker learns...", "poster_path": "/kept.jpg", "genres": []interface{}{"Action"}, "runtime": float64(136)}
+	if got := mergeFields(kept, removed); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %v, want %v", got, want)
+	}
+}
+
+func TestMoveQuery(t *testing.T) {
+	tests := []struct {
+		ref  reference
+		want string
+	}{
+		{reference{"media_files", "media_item_id", nil},
+			`UPDATE media_files t SET media_item_id = $1 WHERE t.media_item_id = $2`},
+		{reference{"monitoring_rules", "media_item_id", []string{}},
+			`UPDATE monitoring_rules t SET media_item_id = $1 WHERE t.media_item_id = $2` +
+				` AND NOT EXISTS (SELECT 1 FROM monitoring_rules k WHERE k.media_item_id = $1)`},
+		{reference{"watched_media", "media_item_id", []string{"user_id"}},
+			`UPDATE watched_media t SET media_item_id = $1 WHERE t.media_item_id = $2` +
+				` AND NOT EXISTS (SELECT 1 FROM watched_media k WHERE k.media_item_id = $1 AND k.user_id = t.user_id)`},
+	}
+	for _, tt := range tests {
+		if got := tt.ref.moveQuery(); got != tt.want {
+			t.Errorf("%s.%s:\n%s\nwant\n%s", tt.ref.table, tt.ref.column, got, tt.want)
+		}
+	}
+}
+
+// notMoved are the columns referring to media items a merge leaves alone
+var notMoved = map[string]bool{
+	"media_items.parent_id":              true, // Children are merged one by one
+	"media_deletions.media_item_id":      true, // Deletion manifests of the removed item go with it
+	"media_duplicates.media_item_id":     true, // Replaced by the next duplicate scan
+	"media_duplicates.duplicate_item_id": true,
+}
+
+func TestReferencesCoverSchema(t *testing.T) {
+	schema, err := os.ReadFile("../db/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved := make(map[string]bool)
+	for _, ref := range references {
+		moved[ref.table+"."+ref.column] = true
+	}
+
+	createTable := regexp.MustCompile(`^CREATE TABLE (\w+)`)
+	column := regexp.MustCompile(`^\s+(\w+)\s.*REFERENCES media_items\(id\)`)
+	table := ""
+	for _, line := range strings.Split(string(schema), "\n") {
+		if m := createTable.FindStringSubmatch(line); m != nil {
+			table = m[1]
+			continue
+		}
+		if m := column.FindStringSubmatch(line); m != nil {
+			name := table + "." + m[1]
+			if !moved[name] && !notMoved[name] {
+				t.Errorf("%s refers to media items but isn't moved by merges", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package duplicates
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles duplicate detection and merge HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new duplicates handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "duplicates-handler")),
+	}
+}
+
+// SetupLibraryRoutes registers the duplicates list, which must be mounted on
+// the library router
+func SetupLibraryRoutes(r chi.Router, h *Handler) {
+	r.Get("/duplicates", h.ListDuplicates)
+}
+
+// SetupMediaRoutes registers the merge route, which must be mounted on the
+// media router behind the admin middleware
+func SetupMediaRoutes(r chi.Router, h *Handler) {
+	r.Post("/{id}/merge/{remove_id}", h.MergeMediaItems)
+}
+
+// actor returns the user making a request
+// Note: Must use the same context key string as the auth middleware ("user")
+func actor(r *http.Request) Actor {
+	claims, ok := r.Context().Value("user").(*auth.Claims)
+	if !ok {
+		return Actor{}
+	}
+	return Actor{UserID: &claims.UserID, Username: claims.Username}
+}
+
+// ListDuplicates handles GET /api/library/duplicates, with ?kind= to list one
+// kind of media
+func (h *Handler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := h.service.List(r.Context(), r.URL.Query().Get("kind"))
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to list duplicates")
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to list duplicates")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"duplicates": duplicates,
+		"total":      len(duplicates),
+	})
+}
+
+// MergeMediaItems handles POST /api/media/{keep_id}/merge/{remove_id}. Media
+// items with conflicting external IDs are only merged with ?force=true.
+func (h *Handler) MergeMediaItems(w http.ResponseWriter, r *http.Request) {
+	keepID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "invalid ID")
+		return
+	}
+	removeID, err := strconv.ParseInt(chi.URLParam(r, "remove_id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "invalid ID of the item to remove")
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "true"
+	result, err := h.service.Merge(r.Context(), keepID, removeID, force, actor(r))
+	var conflict *ConflictError
+	switch {
+	case err == nil:
+		httputil.RespondJSON(w, http.StatusOK, result)
+	case errors.As(err, &conflict):
+		httputil.RespondJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"conflicts": conflict.Conflicts,
+			"code":      http.StatusConflict,
+		})
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
+	case errors.Is(err, ErrSameItem), errors.Is(err, ErrKindMismatch), errors.Is(err, ErrDeleted):
+		httputil.RespondError(w, http.StatusBadRequest, err, "failed to merge media items")
+	default:
+		httputil.LogError(h.logger, err, "failed to merge media items", zap.Int64("keep_id", keepID), zap.Int64("remove_id", removeID))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "failed to merge media items")
+	}
+}
@@ -0,0 +1,347 @@
+package duplicates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for a media item that doesn't exist
+	ErrNotFound = errors.New("media item not found")
+
+	// ErrSameItem is returned when merging a media item into itself
+	ErrSameItem = errors.New("a media item can't be merged into itself")
+
+	// ErrKindMismatch is returned when merging media items of different kinds
+	ErrKindMismatch = errors.New("only media items of the same kind can be merged")
+
+	// ErrDeleted is returned when merging a media item in the recycle bin
+	ErrDeleted = errors.New("deleted media items can't be merged")
+)
+
+// ConflictError is returned when merging media items with different IDs of
+// the same provider, unless forced
+type ConflictError struct {
+	Conflicts []string // Like "tmdb: 603 != 604"
+}
+
+func (e *ConflictError) Error() string {
+	return "media items have conflicting external IDs: " + strings.Join(e.Conflicts, ", ")
+}
+
+// Actor is the user merging media items
+type Actor struct {
+	UserID   *int64
+	Username string
+}
+
+// MergeResult is what merging two media items did
+type MergeResult struct {
+	KeptID      int64            `json:"kept_id"`
+	RemovedID   int64            `json:"removed_id"`
+	Title       string           `json:"title"`
+	Kind        string           `json:"kind"`
+	Forced      bool             `json:"forced"`
+	Conflicts   []string         `json:"conflicts,omitempty"` // The conflicting external IDs a forced merge ignored
+	Moved       map[string]int64 `json:"moved"`               // Rows moved onto the kept item by table
+	MergedItems int              `json:"merged_items"`        // Seasons, episodes and other children merged into the kept item's
+}
+
+// mergedItem is a media item as merged
+type mergedItem struct {
+	ID           int64
+	Kind         string
+	Title        string
+	Year         *int
+	ExternalIDs  map[string]interface{}
+	Metadata     map[string]interface{}
+	RootFolderID *int64
+	Deleted      bool
+}
+
+// reference is a column that refers to media items. unique lists the
+// columns the column is unique together with: a row of the removed item that
+// the kept item already has is left to be deleted with the removed item. nil
+// means rows never clash, an empty list that there is one row per item.
+type reference struct {
+	table  string
+	column string
+	unique []string
+}
+
+// references are the columns moved onto the kept item of a merge. Playback
+// progress and monitoring rules of the kept item win over those of the
+// removed one.
+var references = []reference{
+	{"media_files", "media_item_id", nil},
+	{"media_file_episodes", "media_item_id", []string{"media_file_id"}},
+	{"media_relations", "parent_id", []string{"child_id", "relation"}},
+	{"media_relations", "child_id", []string{"parent_id", "relation"}},
+	{"media_quality", "media_item_id", []string{"media_file_id"}},
+	{"quality_upgrade_history", "media_item_id", nil},
+	{"monitoring_rules", "media_item_id", []string{}},
+	{"episode_monitoring", "media_item_id", []string{}},
+	{"search_history", "media_item_id", nil},
+	{"blocklist", "media_item_id", []string{"release_hash"}},
+	{"calendar_events", "media_item_id", []string{"event_type", "event_key"}},
+	{"watched_media", "media_item_id", []string{"user_id"}},
+	{"playback_progress", "media_item_id", []string{"user_id"}},
+	{"downloads", "media_item_id", nil},
+	{"import_decisions", "media_item_id", nil},
+	{"import_decisions", "resolved_media_item_id", nil},
+	{"import_jobs", "media_item_id", nil},
+	{"media_requests", "media_item_id", nil},
+	{"import_list_entries", "media_item_id", nil},
+	{"import_list_run_items", "media_item_id", nil},
+	{"history_events", "media_item_id", nil},
+}
+
+// moveQuery returns the statement moving the rows of a reference from the
+// removed item ($2) to the kept item ($1)
+func (r reference) moveQuery() string {
+	query := fmt.Sprintf(`UPDATE %s t SET %s = $1 WHERE t.%s = $2`, r.table, r.column, r.column)
+	if r.unique == nil {
+		return query
+	}
+	clash := fmt.Sprintf(`k.%s = $1`, r.column)
+	for _, column := range r.unique {
+		clash += fmt.Sprintf(` AND k.%s = t.%s`, column, column)
+	}
+	return query + fmt.Sprintf(` AND NOT EXISTS (SELECT 1 FROM %s k WHERE %s)`, r.table, clash)
+}
+
+// isEmpty reports whether a metadata value is missing or empty
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// mergeFields returns the fields of kept, with those it lacks or has empty
+// taken from removed
+func mergeFields(kept, removed map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(kept)+len(removed))
+	for key, value := range removed {
+		if !isEmpty(value) {
+			merged[key] = value
+		}
+	}
+	for key, value := range kept {
+		if !isEmpty(value) {
+			merged[key] = value
+		} else if _, ok := merged[key]; !ok {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// getItem gets and locks a media item for a merge
+func getItem(ctx context.Context, tx pgx.Tx, id int64) (*mergedItem, error) {
+	item := &mergedItem{ID: id}
+	var idsJSON, metadataJSON []byte
+	err := tx.QueryRow(ctx, `
+		SELECT kind, title, year, COALESCE(external_ids, '{}'::jsonb), COALESCE(metadata, '{}'::jsonb),
+		       root_folder_id, deleted_at IS NOT NULL
+		FROM media_items WHERE id = $1 FOR UPDATE
+	`, id).Scan(&item.Kind, &item.Title, &item.Year, &idsJSON, &metadataJSON, &item.RootFolderID, &item.Deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+	if err := json.Unmarshal(idsJSON, &item.ExternalIDs); err != nil {
+		return nil, fmt.Errorf("failed to decode external IDs of media item %d: %w", id, err)
+	}
+	if err := json.Unmarshal(metadataJSON, &item.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata of media item %d: %w", id, err)
+	}
+	return item, nil
+}
+
+// Merge merges the media item removeID into keepID and deletes it, in one
+// transaction. Its files, monitoring, watch state, history and everything
+// else referring to it move to the kept item, its children become the kept
+// item's or are merged into those with the same title, and its metadata and
+// external IDs fill in what the kept item lacks. Items with different IDs of
+// the same provider are only merged with force.
+func (s *Service) Merge(ctx context.Context, keepID, removeID int64, force bool, actor Actor) (*MergeResult, error) {
+	if keepID == removeID {
+		return nil, ErrSameItem
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock in ID order so two merges of the same items can't deadlock
+	first, second := min(keepID, removeID), max(keepID, removeID)
+	a, err := getItem(ctx, tx, first)
+	if err != nil {
+		return nil, err
+	}
+	b, err := getItem(ctx, tx, second)
+	if err != nil {
+		return nil, err
+	}
+	keep, remove := a, b
+	if keep.ID != keepID {
+		keep, remove = b, a
+	}
+
+	if keep.Deleted || remove.Deleted {
+		return nil, ErrDeleted
+	}
+	if keep.Kind != remove.Kind {
+		return nil, ErrKindMismatch
+	}
+	conflicts := conflictingIDs(keep.ExternalIDs, remove.ExternalIDs)
+	if len(conflicts) > 0 && !force {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	result := &MergeResult{
+		KeptID:    keep.ID,
+		RemovedID: remove.ID,
+		Title:     keep.Title,
+		Kind:      keep.Kind,
+		Forced:    len(conflicts) > 0,
+		Conflicts: conflicts,
+		Moved:     map[string]int64{},
+	}
+	if err := s.mergeItem(ctx, tx, keep, remove, result); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	s.history.Record(ctx, history.Event{
+		Type:        history.EventMerged,
+		MediaItemID: &keep.ID,
+		Title:       keep.Title,
+		Data: map[string]interface{}{
+			"media_kind":           keep.Kind,
+			"removed_id":           remove.ID,
+			"removed_title":        remove.Title,
+			"removed_year":         remove.Year,
+			"removed_external_ids": remove.ExternalIDs,
+			"forced":               result.Forced,
+			"conflicting_ids":      conflicts,
+			"moved":                result.Moved,
+			"merged_items":         result.MergedItems,
+			"user_id":              actor.UserID,
+			"username":             actor.Username,
+		},
+	})
+
+	s.logger.Info("Merged media items",
+		zap.Int64("kept_id", keep.ID),
+		zap.Int64("removed_id", remove.ID),
+		zap.Bool("forced", result.Forced),
+		zap.Int("merged_children", result.MergedItems))
+	return result, nil
+}
+
+// mergeItem merges remove into keep within a transaction, children first
+func (s *Service) mergeItem(ctx context.Context, tx pgx.Tx, keep, remove *mergedItem, result *MergeResult) error {
+	// A child clashing with one of the kept item's, like the same season, is
+	// merged into it; the others are moved over
+	rows, err := tx.Query(ctx, `SELECT id FROM media_items WHERE parent_id = $1 ORDER BY id`, remove.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list children: %w", err)
+	}
+	children, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return fmt.Errorf("failed to list children: %w", err)
+	}
+	for _, childID := range children {
+		child, err := getItem(ctx, tx, childID)
+		if err != nil {
+			return err
+		}
+		var clashID int64
+		err = tx.QueryRow(ctx, `
+			SELECT id FROM media_items
+			WHERE parent_id = $1 AND kind = $2 AND title = $3 AND COALESCE(year, -1) = COALESCE($4, -1)
+		`, keep.ID, child.Kind, child.Title, child.Year).Scan(&clashID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, err := tx.Exec(ctx, `UPDATE media_items SET parent_id = $1, updated_at = NOW() WHERE id = $2`, keep.ID, child.ID); err != nil {
+				return fmt.Errorf("failed to move child %d: %w", child.ID, err)
+			}
+			result.Moved["media_items"]++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find matching child: %w", err)
+		}
+		keptChild, err := getItem(ctx, tx, clashID)
+		if err != nil {
+			return err
+		}
+		if err := s.mergeItem(ctx, tx, keptChild, child, result); err != nil {
+			return err
+		}
+		result.MergedItems++
+	}
+
+	for _, ref := range references {
+		tag, err := tx.Exec(ctx, ref.moveQuery(), keep.ID, remove.ID)
+		if err != nil {
+			return fmt.Errorf("failed to move %s: %w", ref.table, err)
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			result.Moved[ref.table] += n
+		}
+	}
+
+	// The removed item goes first, so the kept item can take its year
+	if _, err := tx.Exec(ctx, `DELETE FROM media_items WHERE id = $1`, remove.ID); err != nil {
+		return fmt.Errorf("failed to delete media item %d: %w", remove.ID, err)
+	}
+
+	year := keep.Year
+	if year == nil {
+		year = remove.Year
+	}
+	rootFolderID := keep.RootFolderID
+	if rootFolderID == nil {
+		rootFolderID = remove.RootFolderID
+	}
+	metadataJSON, err := json.Marshal(mergeFields(keep.Metadata, remove.Metadata))
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	idsJSON, err := json.Marshal(mergeFields(keep.ExternalIDs, remove.ExternalIDs))
+	if err != nil {
+		return fmt.Errorf("failed to encode external IDs: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE media_items
+		SET year = $1, root_folder_id = $2, metadata = $3, external_ids = $4, updated_at = NOW()
+		WHERE id = $5
+	`, year, rootFolderID, metadataJSON, idsJSON, keep.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update media item %d: %w", keep.ID, err)
+	}
+	return nil
+}
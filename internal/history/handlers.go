@@ -0,0 +1,164 @@
+package history
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPageSize is how many events are listed when no limit is given
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Handler handles history HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new history handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "history-handler")),
+	}
+}
+
+// SetupRoutes registers the history routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/history", h.ListHistory)
+}
+
+// historyPage is a page of events. NextCursor is passed as cursor to get the
+// next page, and is missing on the last page.
+type historyPage struct {
+	Events     []Event `json:"events"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// ListHistory handles GET /api/history
+//
+// Query parameters: event_type, media_item_id, since and until (RFC 3339 or
+// YYYY-MM-DD), cursor and limit.
+func (h *Handler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if raw := query.Get("event_type"); raw != "" {
+		if !IsValidEventType(raw) {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown event type: "+raw)
+			return
+		}
+		filter.Type = EventType(raw)
+	}
+	if raw := query.Get("media_item_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media item ID")
+			return
+		}
+		filter.MediaItemID = &id
+	}
+
+	h.respondPage(w, r, filter)
+}
+
+// ListMediaHistory handles GET /api/media/{id}/history, the history of a
+// media item and its seasons and episodes
+func (h *Handler) ListMediaHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.MediaItemID = &id
+
+	h.respondPage(w, r, filter)
+}
+
+// respondPage lists a page of events and writes it with the cursor of the next page
+func (h *Handler) respondPage(w http.ResponseWriter, r *http.Request, filter Filter) {
+	// Fetch one more than asked for to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	events, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to list history")
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list history")
+		return
+	}
+
+	page := historyPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		cursor := strconv.FormatInt(page.Events[pageSize-1].ID, 10)
+		page.NextCursor = &cursor
+	}
+	httputil.RespondJSON(w, http.StatusOK, page)
+}
+
+// parseFilter reads the date range, cursor and limit shared by the history
+// endpoints, and responds with an error when one is invalid
+func parseFilter(w http.ResponseWriter, r *http.Request) (Filter, bool) {
+	query := r.URL.Query()
+	filter := Filter{Limit: defaultPageSize}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid cursor")
+			return filter, false
+		}
+		filter.BeforeID = &cursor
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, param.name+" must be a date (YYYY-MM-DD) or RFC 3339 time")
+			return filter, false
+		}
+		*param.dest = &t
+	}
+
+	return filter, true
+}
+
+// parseTime parses an RFC 3339 time or a date, which means midnight UTC
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,40 @@
+package history
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/history?since=2026-01-02&until=2026-01-03T12:00:00Z&cursor=42&limit=10", nil)
+	w := httptest.NewRecorder()
+
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		t.Fatalf("parseFilter failed: %s", w.Body.String())
+	}
+	if filter.Limit != 10 {
+		t.Errorf("Limit = %d, want 10", filter.Limit)
+	}
+	if filter.BeforeID == nil || *filter.BeforeID != 42 {
+		t.Errorf("BeforeID = %v, want 42", filter.BeforeID)
+	}
+	if want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); filter.Since == nil || !filter.Since.Equal(want) {
+		t.Errorf("Since = %v, want %v", filter.Since, want)
+	}
+	if want := time.Date(2026, 1, 3, 12, 0, 0, 0, time.UTC); filter.Until == nil || !filter.Until.Equal(want) {
+		t.Errorf("Until = %v, want %v", filter.Until, want)
+	}
+}
+
+func TestParseFilterRejectsInvalid(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=501", "cursor=abc", "since=yesterday"} {
+		r := httptest.NewRequest("GET", "/api/history?"+query, nil)
+		w := httptest.NewRecorder()
+		if _, ok := parseFilter(w, r); ok || w.Code != http.StatusBadRequest {
+			t.Errorf("parseFilter(%s) = %v, status %d, want 400", query, ok, w.Code)
+		}
+	}
+}
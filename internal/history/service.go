@@ -0,0 +1,192 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/realtime"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+// EventType identifies what kind of thing happened
+type EventType string
+
+const (
+	EventGrabbed           EventType = "grabbed"            // A release was sent to a downloader
+	EventDownloadCompleted EventType = "download_completed" // A download finished
+	EventDownloadFailed    EventType = "download_failed"    // A download failed
+	EventImported          EventType = "imported"           // A file was imported into the library
+	EventImportFailed      EventType = "import_failed"      // A file could not be imported
+	EventUpgraded          EventType = "upgraded"           // An import replaced files of lower quality
+	EventDeleted           EventType = "deleted"            // A media item or file was deleted
+	EventRestored          EventType = "restored"           // A deleted media item was restored from the recycle bin
+	EventSearched          EventType = "searched"           // A monitoring search finished
+	EventMonitoringUpdated EventType = "monitoring_updated" // A monitoring rule was changed by a bulk edit
+	EventMonitoringDeleted EventType = "monitoring_deleted" // A monitoring rule was deleted by a bulk edit
+	EventMetadataRefreshed EventType = "metadata_refreshed" // A metadata refresh added or updated seasons and episodes
+	EventRenamed           EventType = "renamed"            // A library file was renamed to match the naming templates
+	EventMerged            EventType = "merged"             // A duplicate media item was merged into another
+)
+
+// EventTypes lists all event types, in the order they usually happen
+var EventTypes = []EventType{
+	EventSearched,
+	EventGrabbed,
+	EventDownloadCompleted,
+	EventDownloadFailed,
+	EventImported,
+	EventUpgraded,
+	EventImportFailed,
+	EventDeleted,
+	EventRestored,
+	EventMonitoringUpdated,
+	EventMonitoringDeleted,
+	EventMetadataRefreshed,
+	EventRenamed,
+	EventMerged,
+}
+
+// IsValidEventType reports whether an event type exists
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if string(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is an entry in the history
+type Event struct {
+	ID          int64                  `json:"id"`
+	Type        EventType              `json:"event_type"`
+	MediaItemID *int64                 `json:"media_item_id,omitempty"`
+	DownloadID  *string                `json:"download_id,omitempty"`
+	Title       string                 `json:"title"`
+	Data        map[string]interface{} `json:"data"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// Filter narrows down the events List returns
+type Filter struct {
+	Type        EventType  // Only events of this type
+	MediaItemID *int64     // Only events of this item and its seasons and episodes
+	Since       *time.Time // Only events at or after this time
+	Until       *time.Time // Only events before this time
+	BeforeID    *int64     // Continue after the event with this ID
+	Limit       int
+}
+
+// Service records and lists history events
+type Service struct {
+	queries *generated.Queries
+	hub     *realtime.Hub
+	logger  *zap.Logger
+}
+
+// NewService creates a new history service
+func NewService(queries *generated.Queries, logger *zap.Logger) *Service {
+	return &Service{
+		queries: queries,
+		logger:  logger.With(zap.String("component", "history")),
+	}
+}
+
+// SetHub sets the hub new events are pushed to clients through
+func (s *Service) SetHub(hub *realtime.Hub) {
+	s.hub = hub
+}
+
+// Record adds an event to the history. Failures are logged rather than
+// returned, since history is never a reason to fail the work it describes.
+// Record on a nil Service does nothing, so event sources don't need to check
+// whether history is set up.
+func (s *Service) Record(ctx context.Context, event Event) {
+	if s == nil {
+		return
+	}
+
+	data := event.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		s.logger.Error("Failed to encode history event", zap.String("event_type", string(event.Type)), zap.Error(err))
+		return
+	}
+
+	// The event already happened, so record it even if the caller's request
+	// was cancelled in the meantime
+	row, err := s.queries.CreateHistoryEvent(context.WithoutCancel(ctx), generated.CreateHistoryEventParams{
+		EventType:   string(event.Type),
+		MediaItemID: event.MediaItemID,
+		DownloadID:  event.DownloadID,
+		Title:       event.Title,
+		Data:        dataJSON,
+	})
+	if err != nil {
+		s.logger.Error("Failed to record history event",
+			zap.String("event_type", string(event.Type)),
+			zap.String("title", event.Title),
+			zap.Error(err))
+		return
+	}
+
+	topics := []string{realtime.TopicHistory}
+	if row.MediaItemID != nil {
+		topics = append(topics, realtime.MediaTopic(*row.MediaItemID))
+	}
+	s.hub.Publish(realtime.Event{Type: "history.event", Data: toEvent(row), Topics: topics})
+}
+
+// List returns the events matching a filter, newest first
+func (s *Service) List(ctx context.Context, filter Filter) ([]Event, error) {
+	params := generated.ListHistoryEventsParams{
+		MediaItemID: filter.MediaItemID,
+		BeforeID:    filter.BeforeID,
+		Limit:       int32(filter.Limit),
+	}
+	if filter.Type != "" {
+		eventType := string(filter.Type)
+		params.EventType = &eventType
+	}
+	if filter.Since != nil {
+		params.Since = pgtype.Timestamptz{Time: *filter.Since, Valid: true}
+	}
+	if filter.Until != nil {
+		params.Until = pgtype.Timestamptz{Time: *filter.Until, Valid: true}
+	}
+
+	rows, err := s.queries.ListHistoryEvents(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history events: %w", err)
+	}
+
+	events := make([]Event, len(rows))
+	for i, row := range rows {
+		events[i] = toEvent(row)
+	}
+	return events, nil
+}
+
+// toEvent converts a database row to an Event
+func toEvent(row generated.HistoryEvent) Event {
+	data := map[string]interface{}{}
+	if len(row.Data) > 0 {
+		_ = json.Unmarshal(row.Data, &data)
+	}
+	return Event{
+		ID:          row.ID,
+		Type:        EventType(row.EventType),
+		MediaItemID: row.MediaItemID,
+		DownloadID:  row.DownloadID,
+		Title:       row.Title,
+		Data:        data,
+		CreatedAt:   row.CreatedAt.Time,
+	}
+}
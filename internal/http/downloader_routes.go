@@ -28,23 +28,34 @@ func setupDownloaderRoutes(r chi.Router, downloaderService *downloader.Service,
 		}
 	})
 
-	// List all downloads (optionally filtered)
+	// List a page of downloads, optionally filtered
 	r.Get("/downloads", func(w http.ResponseWriter, r *http.Request) {
-		pluginID := r.URL.Query().Get("plugin_id")
-		status := r.URL.Query().Get("status")
+		filter, err := downloader.ParseListFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		resp, err := downloaderService.ListDownloads(r.Context(), pluginID, status)
+		resp, err := downloaderService.ListDownloads(r.Context(), filter)
 		if err != nil {
 			logger.Error("Failed to list downloads", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		body := map[string]interface{}{
 			"downloads": resp.Downloads,
 			"total":     resp.Total,
-		}); err != nil {
+			"limit":     resp.Limit,
+			"offset":    resp.Offset,
+			"has_more":  resp.HasMore,
+		}
+		if resp.EstimatedCompletionAt != nil {
+			body["estimated_completion_at"] = resp.EstimatedCompletionAt
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
 			logger.Error("Failed to encode downloads response", zap.Error(err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -138,12 +149,17 @@ func setupDownloaderRoutes(r chi.Router, downloaderService *downloader.Service,
 		w.WriteHeader(http.StatusNoContent)
 	})
 
-	// Cancel/delete a download
+	// Cancel/delete a download, with ?delete_files=true to remove its files and
+	// ?add_to_blocklist=true to keep its release from being grabbed again
 	r.Delete("/downloads/{plugin_id}/{download_id}", func(w http.ResponseWriter, r *http.Request) {
 		pluginID := chi.URLParam(r, "plugin_id")
 		downloadID := chi.URLParam(r, "download_id")
+		opts := downloader.DeleteOptions{
+			DeleteFiles:    r.URL.Query().Get("delete_files") == "true",
+			AddToBlocklist: r.URL.Query().Get("add_to_blocklist") == "true",
+		}
 
-		if err := downloaderService.CancelDownload(r.Context(), downloadID, pluginID); err != nil {
+		if err := downloaderService.CancelDownload(r.Context(), downloadID, pluginID, opts); err != nil {
 			logger.Error("Failed to cancel download", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -151,4 +167,29 @@ func setupDownloaderRoutes(r chi.Router, downloaderService *downloader.Service,
 
 		w.WriteHeader(http.StatusNoContent)
 	})
+
+	// Import completed downloads, optionally previewing them first
+	downloadHandler := downloader.NewHandler(downloaderService, queries, configStore, db, logger)
+	// Plugins call these with the internal API token. Imports can replace
+	// library files, so users need to be admins.
+	r.Group(func(r chi.Router) {
+		r.Use(RequireInternalOrAdminMiddleware(logger))
+		r.Post("/downloads/import", downloadHandler.ImportCompletedDownload)
+		r.Post("/downloads/import/confirm", downloadHandler.ConfirmImport)
+		r.Get("/downloads/import/jobs", downloadHandler.ListImportJobs)
+		r.Get("/downloads/import/jobs/{id}", downloadHandler.GetImportJob)
+
+		// Manual import queue for downloads that could not be imported automatically
+		r.Get("/imports/pending", downloadHandler.ListPendingImports)
+		r.Post("/imports/{id}/resolve", downloadHandler.ResolveImport)
+	})
+
+	// Remote path mappings for download clients on other hosts
+	r.Get("/downloads/path-mappings", downloadHandler.ListPathMappings)
+	r.Group(func(r chi.Router) {
+		r.Use(RequireAdminMiddleware(logger))
+		r.Post("/downloads/path-mappings", downloadHandler.CreatePathMapping)
+		r.Put("/downloads/path-mappings/{id}", downloadHandler.UpdatePathMapping)
+		r.Delete("/downloads/path-mappings/{id}", downloadHandler.DeletePathMapping)
+	})
 }
@@ -47,19 +47,29 @@ func (h *ConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var value interface{}
-	if err := json.Unmarshal(cfg.Value, &value); err != nil {
-		h.logger.Warn("failed to unmarshal config value", zap.String("key", key), zap.Error(err))
-		value = cfg.Value
-	}
-
 	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"key":      key,
-		"value":    value,
+		"value":    h.displayValue(key, cfg.Value),
 		"metadata": metadata,
+		"secret":   configstore.IsSecret(cfg.Value),
 	})
 }
 
+// displayValue decodes a config value for a response. Secrets are never
+// decrypted for display, only shown as redacted.
+func (h *ConfigHandler) displayValue(key string, raw json.RawMessage) interface{} {
+	if configstore.IsSecret(raw) {
+		return configstore.RedactedValue
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		h.logger.Warn("failed to unmarshal config value", zap.String("key", key), zap.Error(err))
+		return raw
+	}
+	return value
+}
+
 // SetConfig handles PUT /api/config/{key}
 func (h *ConfigHandler) SetConfig(w http.ResponseWriter, r *http.Request) {
 	key := chi.URLParam(r, "key")
@@ -80,17 +90,23 @@ func (h *ConfigHandler) SetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.Set(r.Context(), key, value); err != nil {
-		httputil.LogError(h.logger, err, "failed to set config", zap.String("key", key))
-		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to set config")
-		return
+	// Saving back a redacted secret leaves the secret unchanged
+	existing, err := h.store.Get(r.Context(), key)
+	keepSecret := err == nil && configstore.IsSecret(existing) && value == configstore.RedactedValue
+
+	if !keepSecret {
+		if err := h.store.Set(r.Context(), key, value); err != nil {
+			httputil.LogError(h.logger, err, "failed to set config", zap.String("key", key))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to set config")
+			return
+		}
 	}
 
 	// Return the stored value
 	storedValue, _ := h.store.Get(r.Context(), key)
 	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"key":   key,
-		"value": storedValue,
+		"value": h.displayValue(key, storedValue),
 	})
 }
 
@@ -146,16 +162,11 @@ func (h *ConfigHandler) configToMap(cfg generated.Config) map[string]interface{}
 		}
 	}
 
-	var value interface{}
-	if err := json.Unmarshal(cfg.Value, &value); err != nil {
-		h.logger.Warn("failed to unmarshal config value", zap.String("key", cfg.Key), zap.Error(err))
-		value = cfg.Value
-	}
-
 	return map[string]interface{}{
 		"key":      cfg.Key,
-		"value":    value,
+		"value":    h.displayValue(cfg.Key, cfg.Value),
 		"metadata": metadata,
+		"secret":   configstore.IsSecret(cfg.Value),
 	}
 }
 
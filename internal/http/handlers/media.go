@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/blakestevenson/nimbus/internal/history"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/images"
 	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -14,7 +18,14 @@ import (
 // MediaHandler handles media-related HTTP requests
 type MediaHandler struct {
 	service media.Service
+	events  *plugins.EventBus
+	plugins *plugins.PluginManager
+	history *history.Service
+	watch   WatchAnnotator
 	logger  *zap.Logger
+
+	// proxyArtwork points artwork URLs in responses at the image cache
+	proxyArtwork bool
 }
 
 // NewMediaHandler creates a new media handler
@@ -25,6 +36,71 @@ func NewMediaHandler(service media.Service, logger *zap.Logger) *MediaHandler {
 	}
 }
 
+// SetEventBus sets the bus media item changes are published to plugins on
+func (h *MediaHandler) SetEventBus(events *plugins.EventBus) {
+	h.events = events
+}
+
+// SetHistory sets the service deletions are recorded in
+func (h *MediaHandler) SetHistory(historySvc *history.Service) {
+	h.history = historySvc
+}
+
+// SetArtworkProxy sets whether artwork URLs in responses point at the image
+// cache instead of upstream
+func (h *MediaHandler) SetArtworkProxy(enabled bool) {
+	h.proxyArtwork = enabled
+}
+
+// WatchAnnotator sets the watch state of media items for a user
+type WatchAnnotator interface {
+	Annotate(ctx context.Context, userID int64, items []*media.MediaItem) error
+}
+
+// SetWatchState sets what adds the current user's watch state to media items
+// in responses
+func (h *MediaHandler) SetWatchState(watch WatchAnnotator) {
+	h.watch = watch
+}
+
+// presentItems rewrites the artwork URLs of items about to be sent to a
+// client and, for a signed in user, adds their watch state
+func (h *MediaHandler) presentItems(r *http.Request, items ...*media.MediaItem) {
+	if h.proxyArtwork {
+		for _, item := range items {
+			images.RewriteURLs(item)
+		}
+	}
+	if h.watch == nil || len(items) == 0 {
+		return
+	}
+	claims, ok := getUserClaims(r)
+	if !ok {
+		return
+	}
+	if err := h.watch.Annotate(r.Context(), claims.UserID, items); err != nil {
+		h.logger.Warn("Failed to add watch state", zap.Error(err))
+	}
+}
+
+// publishItemEvent tells plugins about a created or updated media item
+func (h *MediaHandler) publishItemEvent(eventType string, item *media.MediaItem) {
+	data := map[string]interface{}{
+		"media_item_id": item.ID,
+		"kind":          string(item.Kind),
+		"title":         item.Title,
+		"external_ids":  item.ExternalIDs,
+		"source":        "api",
+	}
+	if item.Year != nil {
+		data["year"] = *item.Year
+	}
+	if item.ParentID != nil {
+		data["parent_id"] = *item.ParentID
+	}
+	h.events.Publish(eventType, data)
+}
+
 // CreateMediaItem handles POST /api/media
 func (h *MediaHandler) CreateMediaItem(w http.ResponseWriter, r *http.Request) {
 	var params media.CreateMediaParams
@@ -35,15 +111,21 @@ func (h *MediaHandler) CreateMediaItem(w http.ResponseWriter, r *http.Request) {
 
 	item, err := h.service.CreateMediaItem(r.Context(), params)
 	if err != nil {
-		if errors.Is(err, media.ErrInvalidKind) || errors.Is(err, media.ErrTitleRequired) {
+		if errors.Is(err, media.ErrInvalidKind) || errors.Is(err, media.ErrTitleRequired) || errors.Is(err, media.ErrInvalidParent) {
 			httputil.RespondError(w, http.StatusBadRequest, err, "validation error")
 			return
 		}
+		if errors.Is(err, media.ErrDeleted) {
+			httputil.RespondError(w, http.StatusConflict, err, "media item is in the recycle bin")
+			return
+		}
 		httputil.LogError(h.logger, err, "failed to create media item")
 		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to create media item")
 		return
 	}
 
+	h.publishItemEvent(plugins.EventMediaItemCreated, item)
+	h.presentItems(r, item)
 	httputil.RespondJSON(w, http.StatusCreated, item)
 }
 
@@ -66,6 +148,7 @@ func (h *MediaHandler) GetMediaItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.presentItems(r, item)
 	httputil.RespondJSON(w, http.StatusOK, item)
 }
 
@@ -116,6 +199,7 @@ func (h *MediaHandler) ListMediaItems(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.presentItems(r, list.Items...)
 	httputil.RespondJSON(w, http.StatusOK, list)
 }
 
@@ -133,13 +217,20 @@ func (h *MediaHandler) UpdateMediaItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Artwork URLs the client got rewritten are stored as the upstream URLs
+	if h.proxyArtwork && params.Metadata != nil {
+		if stored, err := h.service.GetMediaItem(r.Context(), id); err == nil {
+			images.RestoreSourceURLs(params.Metadata, stored.Metadata)
+		}
+	}
+
 	item, err := h.service.UpdateMediaItem(r.Context(), id, params)
 	if err != nil {
 		if errors.Is(err, media.ErrNotFound) {
 			httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
 			return
 		}
-		if errors.Is(err, media.ErrTitleRequired) {
+		if errors.Is(err, media.ErrTitleRequired) || errors.Is(err, media.ErrInvalidParent) {
 			httputil.RespondError(w, http.StatusBadRequest, err, "validation error")
 			return
 		}
@@ -148,6 +239,8 @@ func (h *MediaHandler) UpdateMediaItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.publishItemEvent(plugins.EventMediaItemUpdated, item)
+	h.presentItems(r, item)
 	httputil.RespondJSON(w, http.StatusOK, item)
 }
 
@@ -159,7 +252,11 @@ func (h *MediaHandler) DeleteMediaItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.DeleteMediaItem(r.Context(), id); err != nil {
+	item, err := h.service.GetMediaItem(r.Context(), id)
+	if err == nil {
+		err = h.service.DeleteMediaItem(r.Context(), id)
+	}
+	if err != nil {
 		if errors.Is(err, media.ErrNotFound) {
 			httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
 			return
@@ -169,6 +266,17 @@ func (h *MediaHandler) DeleteMediaItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.history.Record(r.Context(), history.Event{
+		Type:        history.EventDeleted,
+		MediaItemID: &item.ID,
+		Title:       item.Title,
+		Data: map[string]interface{}{
+			"kind":          "media_item",
+			"media_kind":    item.Kind,
+			"files_deleted": false,
+		},
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -199,6 +307,7 @@ func (h *MediaHandler) ListTVEpisodes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.presentItems(r, items...)
 	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"items": items,
 		"total": len(items),
@@ -211,6 +320,41 @@ func (h *MediaHandler) ListBooks(w http.ResponseWriter, r *http.Request) {
 	h.listByKind(w, r, kind)
 }
 
+// ListMusicArtists handles GET /api/music/artists
+func (h *MediaHandler) ListMusicArtists(w http.ResponseWriter, r *http.Request) {
+	kind := media.MediaKindMusicArtist
+	h.listByKind(w, r, kind)
+}
+
+// ListMusicAlbums handles GET /api/music/albums
+func (h *MediaHandler) ListMusicAlbums(w http.ResponseWriter, r *http.Request) {
+	kind := media.MediaKindMusicAlbum
+	h.listByKind(w, r, kind)
+}
+
+// ListMusicChildren handles GET /api/music/artists/{id}/albums and
+// GET /api/music/albums/{id}/tracks
+func (h *MediaHandler) ListMusicChildren(w http.ResponseWriter, r *http.Request) {
+	parentID, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "invalid ID")
+		return
+	}
+
+	items, err := h.service.ListChildItems(r.Context(), parentID)
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to list music items", zap.Int64("parent_id", parentID))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to list music items")
+		return
+	}
+
+	h.presentItems(r, items...)
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
+
 // Helper methods
 
 func (h *MediaHandler) listByKind(w http.ResponseWriter, r *http.Request, kind media.MediaKind) {
@@ -243,6 +387,7 @@ func (h *MediaHandler) listByKind(w http.ResponseWriter, r *http.Request, kind m
 		return
 	}
 
+	h.presentItems(r, list.Items...)
 	httputil.RespondJSON(w, http.StatusOK, list)
 }
 
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// tmdbPluginID is the metadata plugin media items are matched with
+const tmdbPluginID = "tmdb-plugin"
+
+// MatchRequest is the TMDB entry to match a media item to
+type MatchRequest struct {
+	TMDBID string `json:"tmdb_id"`
+	Type   string `json:"type"` // "movie" or "tv"
+}
+
+// fieldChange is the old and new value of a metadata or external ID key
+type fieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// MatchResponse summarizes what matching a media item changed
+type MatchResponse struct {
+	Item            *media.MediaItem       `json:"item"`
+	PreviousTMDBID  string                 `json:"previous_tmdb_id,omitempty"`
+	TMDBID          string                 `json:"tmdb_id"`
+	Metadata        map[string]fieldChange `json:"metadata_changes"`
+	ExternalIDs     map[string]fieldChange `json:"external_id_changes"`
+	SeasonsUpdated  int                    `json:"seasons_updated"`
+	EpisodesUpdated int                    `json:"episodes_updated"`
+	Warnings        []string               `json:"warnings,omitempty"`
+}
+
+// SetPluginManager sets where the TMDB plugin used to match media items is found
+func (h *MediaHandler) SetPluginManager(pm *plugins.PluginManager) {
+	h.plugins = pm
+}
+
+// MatchMediaItem handles POST /api/media/{id}/match. It replaces the metadata
+// and external IDs of a wrongly matched movie or series with those of the
+// given TMDB entry, including the seasons and episodes of a series.
+func (h *MediaHandler) MatchMediaItem(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "invalid ID")
+		return
+	}
+
+	var req MatchRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	if req.TMDBID == "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "tmdb_id is required")
+		return
+	}
+
+	before, err := h.service.GetMediaItem(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
+			return
+		}
+		httputil.LogError(h.logger, err, "failed to get media item", zap.Int64("id", id))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to get media item")
+		return
+	}
+
+	switch {
+	case before.Kind == media.MediaKindMovie && req.Type == "movie":
+	case before.Kind == media.MediaKindTVSeries && req.Type == "tv":
+	default:
+		httputil.RespondErrorMessage(w, http.StatusBadRequest,
+			fmt.Sprintf("a %s can't be matched to a TMDB %q entry, use type \"movie\" for movies and \"tv\" for series", before.Kind, req.Type))
+		return
+	}
+
+	if h.plugins == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "plugins are disabled")
+		return
+	}
+	plugin, ok := h.plugins.GetPlugin(tmdbPluginID)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "the TMDB plugin is not loaded")
+		return
+	}
+
+	body, _ := json.Marshal(req)
+	resp, err := plugin.Client.HandleAPI(r.Context(), &plugins.PluginHTTPRequest{
+		Method:  "POST",
+		Path:    fmt.Sprintf("/api/plugins/tmdb/enrich/%d", id),
+		Query:   map[string][]string{},
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to call TMDB plugin", zap.Int64("id", id))
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, "failed to call the TMDB plugin")
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		var pluginErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(resp.Body, &pluginErr)
+		if pluginErr.Error == "" {
+			pluginErr.Error = fmt.Sprintf("TMDB plugin returned HTTP %d", resp.StatusCode)
+		}
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, pluginErr.Error)
+		return
+	}
+
+	var summary struct {
+		SeasonsUpdated  int      `json:"seasons_updated"`
+		EpisodesUpdated int      `json:"episodes_updated"`
+		Warnings        []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(resp.Body, &summary); err != nil {
+		httputil.LogError(h.logger, err, "failed to decode TMDB plugin response", zap.Int64("id", id))
+	}
+
+	after, err := h.service.GetMediaItem(r.Context(), id)
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to get matched media item", zap.Int64("id", id))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to get media item")
+		return
+	}
+
+	response := MatchResponse{
+		Item:            after,
+		PreviousTMDBID:  matchedTMDBID(before),
+		TMDBID:          req.TMDBID,
+		Metadata:        diffFields(before.Metadata, after.Metadata),
+		ExternalIDs:     diffFields(before.ExternalIDs, after.ExternalIDs),
+		SeasonsUpdated:  summary.SeasonsUpdated,
+		EpisodesUpdated: summary.EpisodesUpdated,
+		Warnings:        summary.Warnings,
+	}
+
+	h.events.Publish(plugins.EventMediaItemRematched, map[string]interface{}{
+		"media_item_id":    after.ID,
+		"kind":             string(after.Kind),
+		"title":            after.Title,
+		"tmdb_id":          req.TMDBID,
+		"previous_tmdb_id": response.PreviousTMDBID,
+		"external_ids":     after.ExternalIDs,
+		"source":           "api",
+	})
+
+	h.presentItems(r, after)
+	httputil.RespondJSON(w, http.StatusOK, response)
+}
+
+// matchedTMDBID returns the TMDB ID a media item is matched to, if any
+func matchedTMDBID(item *media.MediaItem) string {
+	for _, m := range []map[string]interface{}{item.ExternalIDs, item.Metadata} {
+		switch v := m["tmdb"].(type) {
+		case string:
+			return v
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+		if v, ok := m["tmdb_id"].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// diffFields returns the keys whose values differ between before and after
+func diffFields(before, after map[string]interface{}) map[string]fieldChange {
+	changes := make(map[string]fieldChange)
+	for key, value := range after {
+		if old, ok := before[key]; !ok && value == nil {
+			continue
+		} else if !reflect.DeepEqual(old, value) {
+			changes[key] = fieldChange{Old: old, New: value}
+		}
+	}
+	for key, old := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = fieldChange{Old: old}
+		}
+	}
+	return changes
+}
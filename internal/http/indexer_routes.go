@@ -6,12 +6,16 @@ import (
 	"strconv"
 
 	"github.com/blakestevenson/nimbus/internal/indexer"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/quality"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-// setupIndexerRoutes registers the unified indexer API endpoints
-func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, logger *zap.Logger) {
+// setupIndexerRoutes registers the unified indexer API endpoints. Search results
+// are annotated with whether the automatic search would accept them when
+// autoSearcher is not nil.
+func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, qualityService *quality.Service, autoSearcher *monitoring.AutoSearcher, logger *zap.Logger) {
 	// List available indexers
 	r.Get("/indexers", func(w http.ResponseWriter, r *http.Request) {
 		indexers := indexerService.ListIndexers()
@@ -40,7 +44,7 @@ func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, logger *z
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"releases": resp.Releases,
+			"releases": annotateReleases(r, autoSearcher, nil, resp.Releases, logger),
 			"total":    resp.Total,
 			"sources":  resp.Sources,
 		}); err != nil {
@@ -62,7 +66,7 @@ func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, logger *z
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"releases": resp.Releases,
+			"releases": annotateReleases(r, autoSearcher, nil, resp.Releases, logger),
 			"total":    resp.Total,
 			"sources":  resp.Sources,
 		}); err != nil {
@@ -84,7 +88,7 @@ func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, logger *z
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"releases": resp.Releases,
+			"releases": annotateReleases(r, autoSearcher, nil, resp.Releases, logger),
 			"total":    resp.Total,
 			"sources":  resp.Sources,
 		}); err != nil {
@@ -92,6 +96,57 @@ func setupIndexerRoutes(r chi.Router, indexerService *indexer.Service, logger *z
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 	})
+
+	// Aggregated search across every indexer plugin. Plugins that fail are
+	// listed in warnings instead of failing the request.
+	r.Get("/search", handleAggregateSearch(indexerService, qualityService, autoSearcher, "general", logger))
+	r.Get("/search/tv", handleAggregateSearch(indexerService, qualityService, autoSearcher, "tv", logger))
+	r.Get("/search/movie", handleAggregateSearch(indexerService, qualityService, autoSearcher, "movie", logger))
+}
+
+// handleAggregateSearch searches all indexer plugins. The sort parameter
+// orders results by date (default), score, size or seeders.
+func handleAggregateSearch(indexerService *indexer.Service, qualityService *quality.Service, autoSearcher *monitoring.AutoSearcher, searchType string, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := parseIndexerSearchRequest(r)
+		req.Type = searchType
+
+		resp := indexerService.SearchAll(r.Context(), req)
+
+		sortOrder := r.URL.Query().Get("sort")
+		switch sortOrder {
+		case "", indexer.SortByDate:
+		case indexer.SortByScore:
+			if qualityService == nil {
+				http.Error(w, "Sorting by score requires the quality service", http.StatusServiceUnavailable)
+				return
+			}
+			scorer, err := qualityService.NewReleaseScorer(r.Context())
+			if err != nil {
+				logger.Error("Failed to load release scorer", zap.Error(err))
+				http.Error(w, "Failed to load release scorer", http.StatusInternalServerError)
+				return
+			}
+			indexer.SortReleases(resp.Releases, sortOrder, scorer)
+		case indexer.SortBySize, indexer.SortBySeeders:
+			indexer.SortReleases(resp.Releases, sortOrder, nil)
+		default:
+			http.Error(w, "sort must be one of date, score, size or seeders", http.StatusBadRequest)
+			return
+		}
+
+		// The annotated releases replace the plain ones of the embedded response
+		annotated := struct {
+			*indexer.AggregateResponse
+			Releases interface{} `json:"releases"`
+		}{resp, annotateReleases(r, autoSearcher, nil, resp.Releases, logger)}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(annotated); err != nil {
+			logger.Error("Failed to encode aggregated search response", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
 }
 
 // parseIndexerSearchRequest parses query parameters into an IndexerSearchRequest
@@ -142,5 +197,15 @@ func parseIndexerSearchRequest(r *http.Request) indexer.SearchRequest {
 		}
 	}
 
+	if minSeeders := query.Get("minseeders"); minSeeders != "" {
+		if m, err := strconv.Atoi(minSeeders); err == nil {
+			req.MinimumSeeders = m
+		}
+	}
+
+	// Manual searches can skip cached indexer results
+	fresh := query.Get("fresh")
+	req.Fresh = fresh == "1" || fresh == "true"
+
 	return req
 }
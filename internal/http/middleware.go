@@ -2,12 +2,14 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/auth"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 )
@@ -15,6 +17,9 @@ import (
 const (
 	// ContextKeyUser is the context key for storing user claims (must be a plain string to avoid type conflicts)
 	ContextKeyUser = "user"
+
+	// ContextKeyInternal is set on requests made by plugins with the internal API token
+	ContextKeyInternal = "internal"
 )
 
 // LoggingMiddleware logs HTTP requests
@@ -182,6 +187,50 @@ func RequireAdminMiddleware(logger *zap.Logger) func(next http.Handler) http.Han
 	}
 }
 
+// InternalOrAuthMiddleware accepts requests carrying the internal API token
+// plugins are started with, and validates user tokens like AuthMiddleware
+// otherwise
+func InternalOrAuthMiddleware(internalToken string, authService auth.Service, logger *zap.Logger) func(next http.Handler) http.Handler {
+	userAuth := AuthMiddleware(authService, logger)
+	return func(next http.Handler) http.Handler {
+		authenticated := userAuth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(plugins.InternalTokenHeader)
+			if token == "" {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			if internalToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(internalToken)) != 1 {
+				logger.Warn("invalid internal API token",
+					zap.String("path", r.URL.Path),
+				)
+				httputil.RespondErrorMessage(w, http.StatusUnauthorized, "invalid internal token")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ContextKeyInternal, true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireInternalOrAdminMiddleware limits a route to plugins calling with the
+// internal API token and admins
+func RequireInternalOrAdminMiddleware(logger *zap.Logger) func(next http.Handler) http.Handler {
+	requireAdmin := RequireAdminMiddleware(logger)
+	return func(next http.Handler) http.Handler {
+		admin := requireAdmin(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if internal, _ := r.Context().Value(ContextKeyInternal).(bool); internal {
+				next.ServeHTTP(w, r)
+				return
+			}
+			admin.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserClaims extracts user claims from the request context
 func GetUserClaims(r *http.Request) (*auth.Claims, bool) {
 	claims, ok := r.Context().Value(ContextKeyUser).(*auth.Claims)
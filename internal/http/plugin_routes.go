@@ -2,16 +2,20 @@ package http
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/httputil"
 	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/tags"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-// setupPluginRoutes sets up the plugin management API routes
-func setupPluginRoutes(r chi.Router, pluginManager interface{}, logger *zap.Logger) {
+// setupPluginRoutes sets up the plugin management routes, which require an
+// admin, and dispatches the API routes plugins declare
+func setupPluginRoutes(r chi.Router, pluginManager interface{}, tagHandler *tags.Handler, authService auth.Service, logger *zap.Logger) {
 	pm, ok := pluginManager.(*plugins.PluginManager)
 	if !ok {
 		logger.Error("Invalid plugin manager type")
@@ -21,10 +25,35 @@ func setupPluginRoutes(r chi.Router, pluginManager interface{}, logger *zap.Logg
 	handlers := plugins.NewAPIHandlers(pm, logger)
 
 	r.Route("/plugins", func(r chi.Router) {
-		r.Get("/", handlers.ListPlugins)
-		r.Get("/{id}/ui-manifest", handlers.GetPluginUIManifest)
-		r.Post("/{id}/enable", handlers.EnablePlugin)
-		r.Post("/{id}/disable", handlers.DisablePlugin)
+		r.Group(func(r chi.Router) {
+			r.Use(AuthMiddleware(authService, logger))
+			r.Use(RequireAdminMiddleware(logger))
+
+			r.Get("/", handlers.ListPlugins)
+			r.Post("/reload", handlers.ReloadPlugins)
+			r.Get("/{id}/ui-manifest", handlers.GetPluginUIManifest)
+			r.Post("/{id}/enable", handlers.EnablePlugin)
+			r.Post("/{id}/disable", handlers.DisablePlugin)
+			r.Post("/{id}/restart", handlers.RestartPlugin)
+			r.Delete("/{id}", handlers.UnloadPlugin)
+
+			// Tags limit indexer and downloader plugins to media with a shared tag
+			if tagHandler != nil {
+				r.Get("/{id}/tags", tagHandler.GetPluginTags)
+				r.Put("/{id}/tags", tagHandler.SetPluginTags)
+			}
+		})
+
+		// Everything else under a plugin's prefix is one of its own routes,
+		// with auth handled per route by the plugin's descriptor
+		r.HandleFunc("/{id}/*", pluginAPIDispatcher(pm, handlers, authService, logger))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthMiddleware(authService, logger))
+		r.Use(RequireAdminMiddleware(logger))
+
+		r.Get("/admin/events/recent", handlers.RecentEvents)
 	})
 }
 
@@ -42,35 +71,27 @@ func setupPluginStaticRoutes(r chi.Router, pluginManager interface{}, logger *za
 	r.Get("/plugins/{id}/*", handlers.ServePluginStatic)
 }
 
-// registerPluginAPIRoutes registers plugin-provided API routes
-func registerPluginAPIRoutes(r chi.Router, pluginManager interface{}, authService auth.Service, logger *zap.Logger) {
-	pm, ok := pluginManager.(*plugins.PluginManager)
-	if !ok {
-		logger.Error("Invalid plugin manager type")
-		return
-	}
-
-	handlers := plugins.NewAPIHandlers(pm, logger)
-
-	// Get all loaded plugins and register their routes
-	loadedPlugins := pm.ListPlugins()
-
-	for _, lp := range loadedPlugins {
-		logger.Info("Registering plugin API routes",
-			zap.String("plugin_id", lp.Meta.ID),
-			zap.Int("route_count", len(lp.Routes)))
-
-		for _, route := range lp.Routes {
-			handler := makePluginRouteHandler(lp, route, handlers, authService, logger)
-
-			r.Method(route.Method, route.Path, handler)
-
-			logger.Debug("Registered plugin route",
-				zap.String("plugin_id", lp.Meta.ID),
-				zap.String("method", route.Method),
-				zap.String("path", route.Path),
-				zap.String("auth", route.Auth))
+// pluginAPIDispatcher routes a request to the plugin that declares its path.
+// The route is looked up through the plugin manager on every request, so
+// plugins loaded or unloaded at runtime take effect immediately.
+func pluginAPIDispatcher(
+	pm *plugins.PluginManager,
+	handlers *plugins.APIHandlers,
+	authService auth.Service,
+	logger *zap.Logger,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lp, route, err := pm.MatchRoute(r.Method, r.URL.Path)
+		switch {
+		case errors.Is(err, plugins.ErrMethodNotAllowed):
+			httputil.RespondErrorMessage(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		case err != nil:
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Plugin route not found")
+			return
 		}
+
+		makePluginRouteHandler(lp, route, handlers, authService, logger)(w, r)
 	}
 }
 
@@ -84,8 +105,14 @@ func makePluginRouteHandler(
 ) http.HandlerFunc {
 	baseHandler := makePluginAPIHandlerWrapper(lp, route, handlers)
 
-	// Apply authentication based on route.Auth
-	switch route.Auth {
+	// A route limited to a role always needs a session
+	authMode := route.Auth
+	if route.Role != "" {
+		authMode = "session"
+	}
+
+	// Apply authentication based on the route's auth mode
+	switch authMode {
 	case "session":
 		// Require authenticated session
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -98,6 +125,16 @@ func makePluginRouteHandler(
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+			if route.Role == plugins.ScopeAdmin {
+				claims, _ := r.Context().Value(ContextKeyUser).(*auth.Claims)
+				if claims == nil || !claims.IsAdmin {
+					logger.Warn("access denied - admin required",
+						zap.String("plugin_id", lp.Meta.ID),
+						zap.String("path", route.Path))
+					httputil.RespondErrorMessage(w, http.StatusForbidden, "admin access required")
+					return
+				}
+			}
 			baseHandler(w, r)
 		}
 
@@ -175,3 +212,9 @@ func extractToken(r *http.Request) (string, error) {
 func contextWithUser(ctx context.Context, claims *auth.Claims) context.Context {
 	return context.WithValue(ctx, "user", claims)
 }
+
+// pluginManagerEvents returns the plugin event bus, or nil when plugins are disabled
+func pluginManagerEvents(pluginManager interface{}) *plugins.EventBus {
+	pm, _ := pluginManager.(*plugins.PluginManager)
+	return pm.Events()
+}
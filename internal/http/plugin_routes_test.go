@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+// fakeAuth accepts the tokens "admin" and "user"
+type fakeAuth struct {
+	auth.Service
+}
+
+func (fakeAuth) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
+	switch token {
+	case "admin":
+		return &auth.Claims{UserID: 1, Username: "admin", IsAdmin: true}, nil
+	case "user":
+		return &auth.Claims{UserID: 2, Username: "user"}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestPluginRouteRoles(t *testing.T) {
+	logger := zap.NewNop()
+	lp := &plugins.LoadedPlugin{Meta: &plugins.PluginMetadata{ID: "nzb-downloader"}}
+	// The plugin isn't running, so requests that get past auth end in a 503
+	handlers := plugins.NewAPIHandlers(&plugins.PluginManager{}, logger)
+
+	tests := []struct {
+		name  string
+		route plugins.RouteDescriptor
+		token string
+		want  int
+	}{
+		{"admin route as user", plugins.RouteDescriptor{Auth: "session", Role: "admin"}, "user", http.StatusForbidden},
+		{"admin route as admin", plugins.RouteDescriptor{Auth: "session", Role: "admin"}, "admin", http.StatusServiceUnavailable},
+		{"admin route signed out", plugins.RouteDescriptor{Auth: "session", Role: "admin"}, "", http.StatusUnauthorized},
+		{"admin role implies session", plugins.RouteDescriptor{Auth: "none", Role: "admin"}, "user", http.StatusForbidden},
+		{"user route as user", plugins.RouteDescriptor{Auth: "session", Role: "user"}, "user", http.StatusServiceUnavailable},
+		{"session route as user", plugins.RouteDescriptor{Auth: "session"}, "user", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.route.Method = "DELETE"
+			tt.route.Path = "/api/plugins/nzb-downloader/servers/{id}"
+			handler := makePluginRouteHandler(lp, tt.route, handlers, fakeAuth{}, logger)
+
+			req := httptest.NewRequest("DELETE", "/api/plugins/nzb-downloader/servers/1", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
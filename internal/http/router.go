@@ -5,18 +5,35 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/blakestevenson/nimbus/internal/arrimport"
 	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/backup"
+	"github.com/blakestevenson/nimbus/internal/collections"
 	"github.com/blakestevenson/nimbus/internal/configstore"
 	"github.com/blakestevenson/nimbus/internal/db/generated"
 	"github.com/blakestevenson/nimbus/internal/downloader"
+	"github.com/blakestevenson/nimbus/internal/duplicates"
+	"github.com/blakestevenson/nimbus/internal/history"
 	"github.com/blakestevenson/nimbus/internal/http/handlers"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/images"
+	"github.com/blakestevenson/nimbus/internal/importer"
+	"github.com/blakestevenson/nimbus/internal/importlists"
 	"github.com/blakestevenson/nimbus/internal/indexer"
 	"github.com/blakestevenson/nimbus/internal/library"
 	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"github.com/blakestevenson/nimbus/internal/mediaservers"
 	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/notifications"
 	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/blakestevenson/nimbus/internal/quality"
+	"github.com/blakestevenson/nimbus/internal/realtime"
+	"github.com/blakestevenson/nimbus/internal/recyclebin"
+	"github.com/blakestevenson/nimbus/internal/requests"
+	"github.com/blakestevenson/nimbus/internal/rootfolders"
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"github.com/blakestevenson/nimbus/internal/watch"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -32,6 +49,10 @@ func NewRouter(
 	db interface{}, // *pgxpool.Pool
 	libraryRootPath string,
 	pluginManager interface{}, // *plugins.PluginManager or nil
+	autoSearcher *monitoring.AutoSearcher, // nil when plugins are unavailable
+	notificationService *notifications.Service,
+	historyService *history.Service,
+	realtimeHub *realtime.Hub,
 	logger *zap.Logger,
 ) http.Handler {
 	r := chi.NewRouter()
@@ -50,9 +71,55 @@ func NewRouter(
 	configHandler := handlers.NewConfigHandler(configStore, logger)
 	libraryHandler := library.NewHandler(queries, logger, libraryRootPath)
 	fileHandler := library.NewFileHandler(queries, logger)
+	notificationHandler := notifications.NewHandler(notificationService, queries, logger)
+	historyHandler := history.NewHandler(historyService, logger)
+	namingService := importer.NewService(queries, configStore, logger)
+	namingService.SetHistory(historyService)
+	namingHandler := importer.NewNamingHandler(namingService, logger)
+	rootFolderHandler := rootfolders.NewHandler(rootfolders.NewService(queries, configStore, logger), queries, logger)
+	requestService := requests.NewService(queries, mediaService, configStore, notificationService, logger)
+	requestHandler := requests.NewHandler(requestService, queries, logger)
+	libraryHandler.Verifier().SetNotifier(notificationService)
+	mediaHandler.SetHistory(historyService)
+	fileHandler.SetHistory(historyService)
 
-	// Load media-specific library paths from config
 	ctx := context.Background()
+
+	// Media changes and finished scans are published to plugins
+	pluginEvents := pluginManagerEvents(pluginManager)
+	var metadataRefresher *library.MetadataRefresher
+	var metadataEnricher *library.MetadataEnricher
+	mediaHandler.SetEventBus(pluginEvents)
+	libraryHandler.SetEventBus(pluginEvents)
+	if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+		mediaHandler.SetPluginManager(pm)
+		fileHandler.SetPluginManager(pm)
+		requestService.SetPluginManager(pm)
+		pm.OnHealthChange(func(id string, health plugins.PluginHealth) {
+			realtimeHub.Publish(realtime.Event{
+				Type: "plugin.health",
+				Data: map[string]interface{}{
+					"plugin_id":  id,
+					"status":     health.Status,
+					"last_error": health.LastError,
+					"restarts":   health.Restarts,
+				},
+				Topics: []string{realtime.TopicHealth},
+			})
+		})
+		metadataEnricher = library.NewMetadataEnricher(pm, queries, configStore, logger)
+		libraryHandler.SetMetadataEnricher(metadataEnricher)
+		metadataRefresher = library.NewMetadataRefresher(pm, queries, logger)
+		metadataRefresher.SetHistory(historyService)
+		libraryHandler.SetMetadataRefresher(metadataRefresher)
+	}
+
+	// Artwork is cached locally and served from /api/images
+	imageCache := images.NewCache(configStore.GetOrDefault(ctx, "images.cache_path", images.DefaultCachePath), logger)
+	imageHandler := images.NewHandler(imageCache, mediaService, logger)
+	mediaHandler.SetArtworkProxy(configStore.GetBoolOrDefault(ctx, "images.proxy_enabled", true))
+
+	// Load media-specific library paths from config
 	mediaPathConfigs := map[string]string{
 		"movie": "library.movie_path",
 		"tv":    "library.tv_path",
@@ -72,6 +139,11 @@ func NewRouter(
 		}
 	}
 
+	// Media info is read with ffprobe, and skipped when it isn't installed
+	prober := mediainfo.NewProber(configStore.GetOrDefault(ctx, "library.ffprobe_path", mediainfo.DefaultFFprobePath), logger)
+	libraryHandler.SetMediaInfoRefresher(library.NewMediaInfoRefresher(queries, prober,
+		configStore.GetIntOrDefault(ctx, "library.mediainfo_concurrency", 2), logger))
+
 	// Initialize indexer service if plugin manager is available
 	var indexerService *indexer.Service
 	if pluginManager != nil {
@@ -91,6 +163,82 @@ func NewRouter(
 		}
 	}
 
+	// Initialize tags if db is available. Media searches and notifications are
+	// routed by tag.
+	var tagService *tags.Service
+	var tagHandler *tags.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		tagService = tags.NewService(dbPool)
+		tagHandler = tags.NewHandler(tagService, logger)
+		if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+			tagHandler.SetPluginManager(pm)
+		}
+		if indexerService != nil {
+			indexerService.SetTags(tagService)
+		}
+		notificationHandler.SetTags(tagService)
+	}
+
+	// Initialize the recycle bin for deleted media if db is available
+	var recycleBin *recyclebin.Service
+	var recycleBinHandler *recyclebin.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		recycleBin = recyclebin.NewService(dbPool, configStore, historyService, logger)
+		recycleBinHandler = recyclebin.NewHandler(recycleBin, logger)
+	}
+
+	// Movies are grouped into their TMDB collections as they are matched, if
+	// db is available
+	var collectionService *collections.Service
+	var collectionHandler *collections.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		collectionService = collections.NewService(dbPool, queries, requestService, logger)
+		collectionHandler = collections.NewHandler(collectionService, logger)
+		if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+			pm.GetSDK().SetMetadataObserver(collectionService)
+		}
+		if metadataEnricher != nil {
+			metadataEnricher.SetMetadataObserver(collectionService)
+		}
+	}
+
+	// Track what each user has watched if db is available
+	var duplicateService *duplicates.Service
+	var duplicateHandler *duplicates.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		duplicateService = duplicates.NewService(dbPool, historyService, logger)
+		duplicateHandler = duplicates.NewHandler(duplicateService, logger)
+	}
+
+	var watchHandler *watch.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		watchService := watch.NewService(dbPool, logger)
+		watchHandler = watch.NewHandler(watchService, logger)
+		mediaHandler.SetWatchState(watchService)
+	}
+
+	// Initialize configuration backups if db is available
+	var backupService *backup.Service
+	var backupHandler *backup.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		backupService = backup.NewService(dbPool, configStore, logger)
+		backupHandler = backup.NewHandler(backupService, logger)
+	}
+
+	// Initialize settings import from Sonarr and Radarr if db is available
+	var arrImportHandler *arrimport.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		arrImportHandler = arrimport.NewHandler(arrimport.NewService(dbPool, configStore, logger), logger)
+	}
+
+	// Plex and Jellyfin servers scan imported folders if db is available
+	var mediaServerService *mediaservers.Service
+	var mediaServerHandler *mediaservers.Handler
+	if dbPool, ok := db.(*pgxpool.Pool); ok {
+		mediaServerService = mediaservers.NewService(dbPool, configStore, logger)
+		mediaServerHandler = mediaservers.NewHandler(mediaServerService, logger)
+	}
+
 	// Initialize downloader service if plugin manager is available
 	var downloaderService *downloader.Service
 	if pluginManager != nil && db != nil {
@@ -100,6 +248,22 @@ func NewRouter(
 			if dbPool, ok := db.(*pgxpool.Pool); ok {
 				logger.Info("Creating downloader service")
 				downloaderService = downloader.NewService(pm, dbPool, logger)
+				downloaderService.SetNotifier(notificationService)
+				downloaderService.SetHistory(historyService)
+				downloaderService.SetHub(realtimeHub)
+				downloaderService.SetMediaServers(mediaServerService)
+				// Downloader plugins report downloads and completed files through the SDK
+				sdk := pm.GetSDK()
+				sdk.SetDownloadSyncer(downloaderService)
+				importHandler := downloader.NewHandler(downloaderService, queries, configStore, dbPool, logger)
+				sdk.SetImportHandler(importHandler)
+				// Imports run in the background with a limited concurrency,
+				// retrying transient filesystem errors
+				importQueue := importer.NewQueue(dbPool, configStore, importHandler, logger)
+				downloaderService.SetImportQueue(importQueue)
+				importQueue.Start(context.Background())
+				// Requests become available once their media is imported
+				downloaderService.OnImportCompleted(requestService.MediaImported)
 				// Sync pending downloads from database to plugin queues
 				logger.Info("Initializing downloader service")
 				if err := downloaderService.Initialize(context.Background()); err != nil {
@@ -119,11 +283,112 @@ func NewRouter(
 	var monitoringService *monitoring.Service
 	var monitoringScheduler *monitoring.Scheduler
 	var monitoringHandler *monitoring.Handler
+	var importListHandler *importlists.Handler
 	if db != nil {
 		if dbPool, ok := db.(*pgxpool.Pool); ok {
 			monitoringService = monitoring.NewService(dbPool)
+			monitoringService.SetConfigStore(configStore)
 			monitoringScheduler = monitoring.NewScheduler(dbPool, monitoringService)
+			monitoringScheduler.SetHub(realtimeHub)
 			monitoringHandler = monitoring.NewHandler(monitoringService, monitoringScheduler, logger)
+			if autoSearcher != nil {
+				monitoringHandler.SetBacklogSearcher(monitoring.NewBacklogSearcher(autoSearcher, logger))
+				monitoringHandler.SetManualSearcher(monitoring.NewManualSearcher(autoSearcher, logger))
+				monitoringScheduler.RegisterJobHandler("rss_sync", monitoring.NewRSSSync(autoSearcher, logger).HandleJob)
+
+				// Plugins report download state to this service, so failures are seen here
+				if downloaderService != nil {
+					downloaderService.OnDownloadFailed(autoSearcher.HandleFailedDownload)
+				}
+			}
+			monitoringHandler.SetConfigStore(configStore)
+			monitoringHandler.SetHistory(historyService)
+			monitoringHandler.SetTags(tagService)
+			requestService.SetMonitoring(monitoringService, autoSearcher)
+			if collectionService != nil {
+				collectionService.SetMonitoring(monitoringService, autoSearcher)
+			}
+			if downloaderService != nil {
+				downloaderService.SetBlocklister(func(ctx context.Context, download *downloader.Download) error {
+					_, err := monitoringService.BlocklistDownload(ctx, download)
+					return err
+				})
+			}
+			if pm, ok := pluginManager.(*plugins.PluginManager); ok {
+				calendarSync := monitoring.NewCalendarSync(monitoringService, pm, logger)
+				monitoringScheduler.RegisterJobHandler("calendar_update", calendarSync.HandleJob)
+			}
+
+			// New seasons and episodes are added from TMDB and monitored as their
+			// series' rule says
+			if metadataRefresher != nil {
+				metadataRefresher.OnEpisodesAdded(monitoringService.MonitorNewSeriesEpisodes)
+				monitoringScheduler.RegisterJobHandler("metadata_refresh", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+					_, err := metadataRefresher.RefreshAll(ctx)
+					return err
+				})
+			}
+
+			// Import lists add what they list as monitored media
+			importListService := importlists.NewService(queries, configStore, requestService, mediaService, monitoringService, logger)
+			importListService.SetSearcher(autoSearcher)
+			importListService.SetHistory(historyService)
+			importListHandler = importlists.NewHandler(importListService, logger)
+			monitoringScheduler.RegisterJobHandler("import_list_sync", importListService.HandleSyncJob)
+
+			monitoringScheduler.RegisterJobHandler("image_cache_cleanup", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+				_, err := imageCache.Cleanup(ctx, mediaService)
+				return err
+			})
+
+			monitoringScheduler.RegisterJobHandler("library_scan", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+				return libraryHandler.Scanner().Run(ctx)
+			})
+
+			monitoringScheduler.RegisterJobHandler("media_verification", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+				computeHashes, _ := job.Config["compute_hashes"].(bool)
+				return libraryHandler.Verifier().Run(ctx, library.VerifyOptions{ComputeHashes: computeHashes, Resume: true})
+			})
+
+			if duplicateService != nil {
+				monitoringScheduler.RegisterJobHandler("duplicate_detection", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+					found, err := duplicateService.Detect(ctx)
+					if err == nil {
+						job.Logf("Found %d duplicate media items", found)
+					}
+					return err
+				})
+			}
+
+			if recycleBin != nil {
+				monitoringScheduler.RegisterJobHandler("recycle_bin_cleanup", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+					_, err := recycleBin.Purge(ctx)
+					return err
+				})
+			}
+
+			if backupService != nil {
+				monitoringScheduler.RegisterJobHandler("config_backup", func(ctx context.Context, job *monitoring.SchedulerJob) error {
+					dir, _ := job.Config["directory"].(string)
+					keep, _ := job.Config["keep"].(float64)
+					_, err := backupService.WriteBackup(ctx, dir, int(keep))
+					return err
+				})
+			}
+
+			// Episodes stop being searched once their file meets the cutoff, which
+			// editing a quality profile can change for many of them at once
+			monitoringScheduler.RegisterJobHandler(monitoring.JobCutoffRecompute, func(ctx context.Context, job *monitoring.SchedulerJob) error {
+				_, err := importer.NewService(queries, configStore, logger).RecomputeCutoffs(ctx)
+				return err
+			})
+			if qualityHandler != nil {
+				qualityHandler.OnProfileChanged(func() {
+					if err := monitoringScheduler.TriggerJobByName(context.Background(), monitoring.JobCutoffRecompute); err != nil {
+						logger.Warn("Failed to start the cutoff recompute", zap.Error(err))
+					}
+				})
+			}
 
 			// Start the scheduler
 			if err := monitoringScheduler.Start(context.Background()); err != nil {
@@ -159,6 +424,13 @@ func NewRouter(
 			r.Put("/auth/me", authHandler.UpdateProfile)
 		})
 
+		// Real-time updates (WebSocket, requires authentication)
+		r.Group(func(r chi.Router) {
+			r.Use(AuthMiddleware(authService, logger))
+
+			r.Handle("/ws", realtimeHub.Handler())
+		})
+
 		// Protected media routes (require authentication)
 		r.Group(func(r chi.Router) {
 			r.Use(AuthMiddleware(authService, logger))
@@ -169,18 +441,56 @@ func NewRouter(
 				r.Post("/", mediaHandler.CreateMediaItem)
 				r.Get("/{id}", mediaHandler.GetMediaItem)
 				r.Put("/{id}", mediaHandler.UpdateMediaItem)
-				r.Delete("/{id}", mediaHandler.DeleteMediaItem)
+				if recycleBinHandler != nil {
+					// Deleted media goes to the recycle bin until restored or purged
+					r.Get("/deleted", recycleBinHandler.ListDeleted)
+					r.Delete("/{id}", recycleBinHandler.DeleteMediaItem)
+					r.Delete("/{id}/with-files", recycleBinHandler.DeleteMediaItem)
+					r.Post("/{id}/restore", recycleBinHandler.RestoreMediaItem)
+				} else {
+					r.Delete("/{id}", mediaHandler.DeleteMediaItem)
+				}
+				r.Post("/{id}/match", mediaHandler.MatchMediaItem)
+				r.Post("/{id}/refresh", libraryHandler.RefreshSeriesMetadata)
 
 				// Media file routes
 				r.Get("/{id}/files", fileHandler.GetMediaFiles)
-				r.Delete("/{id}/with-files", fileHandler.DeleteMediaItemWithFiles)
+				r.Get("/{id}/history", historyHandler.ListMediaHistory)
+
+				// Subtitles, fetched with the OpenSubtitles plugin
+				r.Post("/{id}/subtitles/search", fileHandler.SearchSubtitles)
+				r.Post("/{id}/subtitles", fileHandler.DownloadSubtitle)
 
 				// Individual file deletion
 				r.Delete("/files/{fileId}", fileHandler.DeleteMediaFile)
 
 				// Interactive search route (if indexer service is available)
 				if indexerService != nil {
-					setupSearchRoutes(r, indexerService, queries, logger)
+					setupSearchRoutes(r, indexerService, autoSearcher, queries, logger)
+				}
+
+				// Search in the background following the monitoring rule
+				if monitoringHandler != nil {
+					r.Post("/{id}/search", monitoringHandler.StartMediaSearch)
+				}
+
+				// Renaming files to match the naming templates (admin only)
+				r.Group(func(r chi.Router) {
+					r.Use(RequireAdminMiddleware(logger))
+					importer.SetupRenameRoutes(r, namingHandler)
+				})
+
+				// Merging duplicate media items (admin only)
+				if duplicateHandler != nil {
+					r.Group(func(r chi.Router) {
+						r.Use(RequireAdminMiddleware(logger))
+						duplicates.SetupMediaRoutes(r, duplicateHandler)
+					})
+				}
+
+				// Per-user watched state and playback progress
+				if watchHandler != nil {
+					watch.SetupMediaRoutes(r, watchHandler)
 				}
 			})
 
@@ -191,6 +501,42 @@ func NewRouter(
 				r.Get("/series/{id}/episodes", mediaHandler.ListTVEpisodes)
 			})
 			r.Get("/books", mediaHandler.ListBooks)
+			r.Route("/music", func(r chi.Router) {
+				r.Get("/artists", mediaHandler.ListMusicArtists)
+				r.Get("/artists/{id}/albums", mediaHandler.ListMusicChildren)
+				r.Get("/albums", mediaHandler.ListMusicAlbums)
+				r.Get("/albums/{id}/tracks", mediaHandler.ListMusicChildren)
+			})
+
+			// Cached artwork
+			images.SetupRoutes(r, imageHandler)
+
+			// Activity history
+			history.SetupRoutes(r, historyHandler)
+
+			// Continue watching
+			if watchHandler != nil {
+				watch.SetupRoutes(r, watchHandler)
+			}
+		})
+
+		// Media requests (any user can request, admins review them)
+		r.Group(func(r chi.Router) {
+			r.Use(AuthMiddleware(authService, logger))
+
+			r.Route("/requests", func(r chi.Router) {
+				r.Get("/", requestHandler.ListRequests)
+				r.Post("/", requestHandler.CreateRequest)
+				r.Get("/{id}", requestHandler.GetRequest)
+				r.Delete("/{id}", requestHandler.DeleteRequest)
+
+				// Admin-only review
+				r.Group(func(r chi.Router) {
+					r.Use(RequireAdminMiddleware(logger))
+					r.Post("/{id}/approve", requestHandler.ApproveRequest)
+					r.Post("/{id}/deny", requestHandler.DenyRequest)
+				})
+			})
 		})
 
 		// Protected quality profile routes (require authentication)
@@ -222,6 +568,21 @@ func NewRouter(
 					})
 				})
 
+				// Custom formats (all authenticated users can view, admin can modify)
+				r.Route("/custom-formats", func(r chi.Router) {
+					r.Get("/", qualityHandler.ListCustomFormats)
+					r.Get("/{id}", qualityHandler.GetCustomFormat)
+					r.Post("/score", qualityHandler.ScoreRelease)
+
+					// Admin-only custom format modifications
+					r.Group(func(r chi.Router) {
+						r.Use(RequireAdminMiddleware(logger))
+						r.Post("/", qualityHandler.CreateCustomFormat)
+						r.Put("/{id}", qualityHandler.UpdateCustomFormat)
+						r.Delete("/{id}", qualityHandler.DeleteCustomFormat)
+					})
+				})
+
 				// Quality detection and utilities
 				r.Post("/quality/detect", qualityHandler.DetectQuality)
 
@@ -244,6 +605,70 @@ func NewRouter(
 				// Setup monitoring routes
 				monitoring.SetupRoutes(r, monitoringHandler)
 			})
+
+			// Background tasks, monitoring defaults and the calendar feed token (admin only)
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+				r.Use(RequireAdminMiddleware(logger))
+				monitoring.SetupTaskRoutes(r, monitoringHandler)
+				monitoring.SetupSettingsRoutes(r, monitoringHandler)
+			})
+
+			// iCal feed (authorized by its own token so calendar apps can subscribe)
+			monitoring.SetupPublicRoutes(r, monitoringHandler)
+		}
+
+		// Protected import list routes (require authentication and admin)
+		if importListHandler != nil {
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+				r.Use(RequireAdminMiddleware(logger))
+				importlists.SetupRoutes(r, importListHandler)
+			})
+		}
+
+		// Movie collections (all authenticated users can view, admin can monitor)
+		if collectionHandler != nil {
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+
+				collections.SetupRoutes(r, collectionHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(RequireAdminMiddleware(logger))
+					collections.SetupAdminRoutes(r, collectionHandler)
+				})
+			})
+		}
+
+		// Configuration backup and restore (admin only)
+		if backupHandler != nil {
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+				r.Use(RequireAdminMiddleware(logger))
+				backup.SetupRoutes(r, backupHandler)
+			})
+		}
+
+		// Settings import from Sonarr and Radarr (admin only)
+		if arrImportHandler != nil {
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+				r.Use(RequireAdminMiddleware(logger))
+				arrimport.SetupRoutes(r, arrImportHandler)
+			})
+		}
+
+		// Tags (all authenticated users can view, admin can modify)
+		if tagHandler != nil {
+			r.Group(func(r chi.Router) {
+				r.Use(AuthMiddleware(authService, logger))
+
+				tags.SetupRoutes(r, tagHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(RequireAdminMiddleware(logger))
+					tags.SetupAdminRoutes(r, tagHandler)
+				})
+			})
 		}
 
 		// Protected config routes (require authentication and admin)
@@ -257,6 +682,13 @@ func NewRouter(
 				r.Put("/{key}", configHandler.SetConfig)
 				r.Delete("/{key}", configHandler.DeleteConfig)
 			})
+
+			notifications.SetupRoutes(r, notificationHandler)
+			rootfolders.SetupRoutes(r, rootFolderHandler)
+			importer.SetupNamingRoutes(r, namingHandler)
+			if mediaServerHandler != nil {
+				mediaservers.SetupRoutes(r, mediaServerHandler)
+			}
 		})
 
 		// Protected library routes (require authentication)
@@ -266,6 +698,9 @@ func NewRouter(
 			r.Route("/library", func(r chi.Router) {
 				// Status endpoint - available to all authenticated users
 				r.Get("/scan/status", libraryHandler.GetScanStatus)
+				r.Get("/verify/report", libraryHandler.GetVerificationReport)
+				r.Get("/refresh-mediainfo/status", libraryHandler.GetMediaInfoRefreshStatus)
+				r.Get("/import-existing/status", libraryHandler.GetImportExistingStatus)
 
 				// Admin-only endpoints
 				r.Group(func(r chi.Router) {
@@ -274,6 +709,14 @@ func NewRouter(
 					r.Post("/scan", libraryHandler.StartScan)
 					r.Post("/scan/stop", libraryHandler.StopScan)
 					r.Post("/scan/reset", libraryHandler.ResetScanner)
+					r.Post("/verify", libraryHandler.StartVerification)
+					r.Post("/verify/cancel", libraryHandler.CancelVerification)
+					r.Post("/refresh-mediainfo", libraryHandler.RefreshMediaInfo)
+					r.Post("/import-existing", libraryHandler.ImportExisting)
+					r.Post("/import-existing/cancel", libraryHandler.CancelImportExisting)
+					if duplicateHandler != nil {
+						duplicates.SetupLibraryRoutes(r, duplicateHandler)
+					}
 				})
 			})
 		})
@@ -283,50 +726,19 @@ func NewRouter(
 			r.Group(func(r chi.Router) {
 				r.Use(AuthMiddleware(authService, logger))
 
-				setupIndexerRoutes(r, indexerService, logger)
-			})
-		}
-
-		// Internal API routes (no authentication required - for plugin-to-host communication)
-		if downloaderService != nil {
-			if dbPool, ok := db.(*pgxpool.Pool); ok {
-				// Create download handler for internal routes
-				downloadHandler := downloader.NewHandler(downloaderService, queries, configStore, dbPool, logger)
-
-				// Import endpoint - internal use by plugins only
-				r.Post("/downloads/import", downloadHandler.ImportCompletedDownload)
-			}
-		}
-
-		// Internal media query endpoint - for plugins to look up media items
-		r.Get("/internal/media", mediaHandler.ListMediaItems)
-
-		// Internal download sync endpoint - for plugins to sync download state to database
-		if downloaderService != nil {
-			r.Put("/internal/downloads/{id}", func(w http.ResponseWriter, r *http.Request) {
-				downloadID := chi.URLParam(r, "id")
-
-				var payload map[string]interface{}
-				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-					http.Error(w, "Invalid request body", http.StatusBadRequest)
-					return
-				}
-
-				// Upsert download to database
-				if err := downloaderService.UpsertDownload(r.Context(), downloadID, payload); err != nil {
-					logger.Error("Failed to upsert download", zap.Error(err), zap.String("id", downloadID))
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
+				setupIndexerRoutes(r, indexerService, qualityService, autoSearcher, logger)
+				if autoSearcher != nil {
+					r.Post("/search/grab", handleGrabRelease(autoSearcher, logger))
 				}
-
-				w.WriteHeader(http.StatusOK)
 			})
 		}
 
 		// Unified downloader routes (require authentication)
 		if downloaderService != nil {
+			// Downloader plugins call back into these routes with the internal API token
+			pm, _ := pluginManager.(*plugins.PluginManager)
 			r.Group(func(r chi.Router) {
-				r.Use(AuthMiddleware(authService, logger))
+				r.Use(InternalOrAuthMiddleware(pm.InternalAPI().Token, authService, logger))
 
 				// Cast db to pgxpool.Pool for downloader routes
 				if dbPool, ok := db.(*pgxpool.Pool); ok {
@@ -335,14 +747,9 @@ func NewRouter(
 			})
 		}
 
-		// Plugin management routes (require authentication and admin)
+		// Plugin management routes, and the API routes plugins declare
 		if pluginManager != nil {
-			r.Group(func(r chi.Router) {
-				r.Use(AuthMiddleware(authService, logger))
-				r.Use(RequireAdminMiddleware(logger))
-
-				setupPluginRoutes(r, pluginManager, logger)
-			})
+			setupPluginRoutes(r, pluginManager, tagHandler, authService, logger)
 		}
 	})
 
@@ -351,10 +758,5 @@ func NewRouter(
 		setupPluginStaticRoutes(r, pluginManager, logger)
 	}
 
-	// Register plugin API routes (auth handled per-route by plugin descriptor)
-	if pluginManager != nil {
-		registerPluginAPIRoutes(r, pluginManager, authService, logger)
-	}
-
 	return r
 }
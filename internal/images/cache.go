@@ -0,0 +1,351 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Decoders for artwork formats
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCachePath is where images are cached when images.cache_path isn't set
+	DefaultCachePath = "/var/lib/nimbus/images"
+
+	// maxImageSize is the largest image downloaded from upstream
+	maxImageSize = 20 << 20
+
+	// maxImageDimension is the widest or tallest image that is decoded to be
+	// resized. A small file can hold an image far larger than that, which
+	// would take gigabytes to decode.
+	maxImageDimension = 10000
+
+	// jpegQuality is the quality resized variants are encoded with
+	jpegQuality = 85
+)
+
+// errPrivateAddress is returned for images on addresses that aren't public
+var errPrivateAddress = errors.New("image URL is not on a public address")
+
+// Kind is a kind of artwork a media item has
+type Kind string
+
+const (
+	KindPoster   Kind = "poster"
+	KindBackdrop Kind = "backdrop"
+	KindStill    Kind = "still"
+)
+
+// Kinds are the kinds of artwork that are cached
+var Kinds = []Kind{KindPoster, KindBackdrop, KindStill}
+
+// MetadataKey returns the media item metadata key holding the artwork URL
+func (k Kind) MetadataKey() string {
+	return string(k) + "_url"
+}
+
+// ParseKind parses a kind of artwork
+func ParseKind(s string) (Kind, bool) {
+	for _, k := range Kinds {
+		if string(k) == s {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// Size is a variant of a cached image
+type Size string
+
+const (
+	SizeThumb    Size = "thumb"
+	SizeMedium   Size = "medium"
+	SizeOriginal Size = "original"
+)
+
+// sizeWidths are the widths images are resized to. Images narrower than that
+// are served as they are.
+var sizeWidths = map[Size]int{
+	SizeThumb:  185,
+	SizeMedium: 500,
+}
+
+// ParseSize parses an image size, where empty means the original
+func ParseSize(s string) (Size, bool) {
+	switch Size(s) {
+	case "", SizeOriginal:
+		return SizeOriginal, true
+	case SizeThumb, SizeMedium:
+		return Size(s), true
+	default:
+		return "", false
+	}
+}
+
+// Version identifies the image at a source URL. It is part of cached file
+// names and image URLs, so a new URL is a new image to clients and the cache.
+func Version(sourceURL string) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Image is a cached image file
+type Image struct {
+	Path        string
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+}
+
+// Cache downloads artwork on first use and keeps it, with resized variants,
+// under a directory per media item
+type Cache struct {
+	dir    string
+	client *http.Client
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	inflight map[string]*inflightFetch // Downloads in progress by file path
+}
+
+// inflightFetch is a download other requests for the same image wait for
+type inflightFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewCache creates an image cache in dir
+func NewCache(dir string, logger *zap.Logger) *Cache {
+	if dir == "" {
+		dir = DefaultCachePath
+	}
+	return &Cache{
+		dir:      dir,
+		client:   newPublicClient(),
+		logger:   logger.With(zap.String("component", "image-cache")),
+		inflight: make(map[string]*inflightFetch),
+	}
+}
+
+// newPublicClient returns the client images are downloaded with. It only
+// connects to public addresses, checked on the address dialed after DNS
+// resolution and on every redirect, so artwork URLs in metadata can't reach
+// the host itself or the local network.
+func newPublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", errPrivateAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the address dialed instead of the image's
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}
+}
+
+// isPublicAddr reports whether addr is a public unicast address, not a
+// loopback, private, link-local or shared one
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns a size of the artwork at sourceURL, downloading and resizing it
+// when it isn't cached yet. Images that can't be decoded are served at their
+// original size.
+func (c *Cache) Get(ctx context.Context, mediaID int64, kind Kind, sourceURL string, size Size) (*Image, error) {
+	version := Version(sourceURL)
+	original := c.path(mediaID, kind, version, SizeOriginal)
+
+	if err := c.once(original, func() error { return c.download(ctx, sourceURL, original) }); err != nil {
+		return nil, err
+	}
+	if size == SizeOriginal {
+		return c.stat(original, version, SizeOriginal)
+	}
+
+	variant := c.path(mediaID, kind, version, size)
+	if err := c.once(variant, func() error { return c.resize(original, variant, sizeWidths[size]) }); err != nil {
+		c.logger.Warn("failed to resize image, serving the original",
+			zap.String("path", original), zap.Error(err))
+		return c.stat(original, version, SizeOriginal)
+	}
+	return c.stat(variant, version, size)
+}
+
+// path returns where a size of an image is cached
+func (c *Cache) path(mediaID int64, kind Kind, version string, size Size) string {
+	return filepath.Join(c.dir, strconv.FormatInt(mediaID, 10), fmt.Sprintf("%s-%s-%s", kind, version, size))
+}
+
+// once creates the file at path with create unless it exists. Concurrent
+// calls for the same path wait for the first one.
+func (c *Cache) once(path string, create func() error) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	if f, ok := c.inflight[path]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	f := &inflightFetch{done: make(chan struct{})}
+	c.inflight[path] = f
+	c.mu.Unlock()
+
+	f.err = create()
+
+	c.mu.Lock()
+	delete(c.inflight, path)
+	c.mu.Unlock()
+	close(f.done)
+	return f.err
+}
+
+// download fetches an image from upstream into path
+func (c *Cache) download(ctx context.Context, sourceURL, path string) error {
+	if u, err := url.Parse(sourceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid image URL: %s", sourceURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid image URL: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download image: upstream returned HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > maxImageSize {
+		return fmt.Errorf("image is larger than %d bytes", maxImageSize)
+	}
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return fmt.Errorf("upstream returned %s, not an image", http.DetectContentType(data))
+	}
+
+	c.logger.Debug("cached image", zap.String("url", sourceURL), zap.Int("bytes", len(data)))
+	return writeFile(path, data)
+}
+
+// resize writes a JPEG of the image at src scaled down to width. Images that
+// are no wider, too large to decode, or in a format that can't be decoded are
+// used as they are.
+func (c *Cache) resize(src, dst string, width int) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= width {
+		return writeFile(dst, data)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		c.logger.Warn("image too large to resize, serving the original",
+			zap.String("path", src), zap.Int("width", cfg.Width), zap.Int("height", cfg.Height))
+		return writeFile(dst, data)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() <= width {
+		return writeFile(dst, data)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleToWidth(img, width), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return writeFile(dst, buf.Bytes())
+}
+
+// stat describes a cached image file
+func (c *Cache) stat(path, version string, size Size) (*Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+
+	return &Image{
+		Path:        path,
+		ContentType: http.DetectContentType(head[:n]),
+		ETag:        fmt.Sprintf(`"%s-%s"`, version, size),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+// writeFile writes data to path through a temporary file, so readers never
+// see a partial image
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,107 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/media"
+	"go.uber.org/zap"
+)
+
+// CleanupResult is what a cache cleanup removed
+type CleanupResult struct {
+	ItemsChecked  int   `json:"items_checked"`
+	ItemsRemoved  int   `json:"items_removed"`
+	FilesRemoved  int   `json:"files_removed"`
+	BytesRemoved  int64 `json:"bytes_removed"`
+	FailedRemoves int   `json:"failed_removes"`
+}
+
+// Cleanup evicts the images of deleted media items, and images whose media
+// item now has different artwork
+func (c *Cache) Cleanup(ctx context.Context, items media.Service) (*CleanupResult, error) {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return &CleanupResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CleanupResult{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		id, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		result.ItemsChecked++
+
+		dir := filepath.Join(c.dir, entry.Name())
+		item, err := items.GetMediaItem(ctx, id)
+		if errors.Is(err, media.ErrNotFound) {
+			c.remove(dir, result)
+			result.ItemsRemoved++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		// Files are named {kind}-{version}-{size}, so images of artwork the
+		// item no longer has are those with another version
+		current := make(map[string]bool)
+		for _, kind := range Kinds {
+			if url, ok := SourceURL(item.Metadata, kind); ok {
+				current[string(kind)+"-"+Version(url)] = true
+			}
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			name := f.Name()
+			if i := strings.LastIndex(name, "-"); i > 0 && current[name[:i]] {
+				continue
+			}
+			c.remove(filepath.Join(dir, name), result)
+		}
+	}
+
+	c.logger.Info("image cache cleanup finished",
+		zap.Int("items_checked", result.ItemsChecked),
+		zap.Int("items_removed", result.ItemsRemoved),
+		zap.Int("files_removed", result.FilesRemoved),
+		zap.Int64("bytes_removed", result.BytesRemoved))
+	return result, nil
+}
+
+// remove deletes a cached file or a media item's directory and counts it
+func (c *Cache) remove(path string, result *CleanupResult) {
+	var size int64
+	var files int
+	_ = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+			files++
+		}
+		return nil
+	})
+
+	if err := os.RemoveAll(path); err != nil {
+		c.logger.Warn("failed to remove cached image", zap.String("path", path), zap.Error(err))
+		result.FailedRemoves++
+		return
+	}
+	result.FilesRemoved += files
+	result.BytesRemoved += size
+}
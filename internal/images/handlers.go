@@ -0,0 +1,117 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler serves media item artwork from the cache
+type Handler struct {
+	cache  *Cache
+	media  media.Service
+	logger *zap.Logger
+}
+
+// NewHandler creates a new image handler
+func NewHandler(cache *Cache, mediaService media.Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		cache:  cache,
+		media:  mediaService,
+		logger: logger.With(zap.String("component", "images-handler")),
+	}
+}
+
+// SetupRoutes registers the image routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/images/{id}/{kind}", h.ServeImage)
+}
+
+// ServeImage handles GET /api/images/{id}/{kind}?size=thumb|medium|original.
+// Artwork is downloaded on first request. When upstream can't be reached a
+// placeholder is served instead, which clients don't keep.
+func (h *Handler) ServeImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "invalid ID format")
+		return
+	}
+	kind, ok := ParseKind(chi.URLParam(r, "kind"))
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "kind must be poster, backdrop or still")
+		return
+	}
+	size, ok := ParseSize(r.URL.Query().Get("size"))
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "size must be thumb, medium or original")
+		return
+	}
+
+	item, err := h.media.GetMediaItem(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
+			return
+		}
+		httputil.LogError(h.logger, err, "failed to get media item", zap.Int64("id", id))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to get media item")
+		return
+	}
+
+	sourceURL, ok := SourceURL(item.Metadata, kind)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, fmt.Sprintf("media item has no %s", kind))
+		return
+	}
+
+	img, err := h.cache.Get(r.Context(), id, kind, sourceURL, size)
+	if err != nil {
+		h.logger.Warn("failed to cache image, serving a placeholder",
+			zap.Int64("id", id), zap.String("kind", string(kind)), zap.Error(err))
+		servePlaceholder(w, kind)
+		return
+	}
+
+	f, err := os.Open(img.Path)
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to open cached image", zap.String("path", img.Path))
+		servePlaceholder(w, kind)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", img.ETag)
+	if r.URL.Query().Get("v") == Version(sourceURL) {
+		// Versioned URLs change with the artwork, so they never go stale
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+	}
+	http.ServeContent(w, r, "", img.ModTime, f)
+}
+
+// placeholderSVG is served for artwork that can't be fetched, sized like posters or backdrops
+const placeholderSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">` +
+	`<rect width="100%%" height="100%%" fill="#2a2a2e"/>` +
+	`<text x="50%%" y="50%%" fill="#6b6b73" font-family="sans-serif" font-size="%d" text-anchor="middle" dominant-baseline="middle">No image</text>` +
+	`</svg>`
+
+func servePlaceholder(w http.ResponseWriter, kind Kind) {
+	width, height := 500, 750
+	if kind != KindPoster {
+		width, height = 1280, 720
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Image-Placeholder", "true")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, placeholderSVG, width, height, width, height, width/12)
+}
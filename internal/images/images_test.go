@@ -0,0 +1,227 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/media"
+	"go.uber.org/zap"
+)
+
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCacheGet(t *testing.T) {
+	poster := testImage(t, 600, 900)
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/missing.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(poster)
+	}))
+	defer upstream.Close()
+
+	c := NewCache(t.TempDir(), zap.NewNop())
+	c.client = upstream.Client() // The upstream listens on loopback
+	ctx := context.Background()
+	source := upstream.URL + "/poster.png"
+
+	// Concurrent first requests download the image once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Get(ctx, 1, KindPoster, source, SizeOriginal); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("upstream requested %d times, want 1", n)
+	}
+
+	thumb, err := c.Get(ctx, 1, KindPoster, source, SizeThumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(thumb.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 185 || cfg.Height != 277 || thumb.ContentType != "image/jpeg" {
+		t.Errorf("thumb is %dx%d %s, want 185x277 image/jpeg", cfg.Width, cfg.Height, thumb.ContentType)
+	}
+	if thumb.ETag != `"`+Version(source)+`-thumb"` {
+		t.Errorf("ETag = %s", thumb.ETag)
+	}
+
+	if _, err := c.Get(ctx, 1, KindPoster, upstream.URL+"/missing.jpg", SizeOriginal); err == nil {
+		t.Error("Get() of a missing upstream image succeeded")
+	}
+}
+
+func TestCacheGetPrivateAddress(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(testImage(t, 10, 10))
+	}))
+	defer upstream.Close()
+
+	c := NewCache(t.TempDir(), zap.NewNop())
+	for _, source := range []string{upstream.URL + "/poster.png", "file:///etc/passwd"} {
+		if _, err := c.Get(context.Background(), 1, KindPoster, source, SizeOriginal); err == nil {
+			t.Errorf("Get(%s) succeeded, want it refused", source)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("upstream on loopback requested %d times, want 0", n)
+	}
+
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"140.82.112.3", true},
+		{"2606:4700::6810:84e5", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.5", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestResizeTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, zap.NewNop())
+	src := filepath.Join(dir, "original")
+	data := testImage(t, maxImageDimension+1, 1)
+	if err := writeFile(src, data); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "thumb")
+	if err := c.resize(src, dst, sizeWidths[SizeThumb]); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("resize() of an image wider than %d px didn't keep the original", maxImageDimension)
+	}
+}
+
+// fakeMedia returns media items from a map
+type fakeMedia struct {
+	media.Service
+	items map[int64]*media.MediaItem
+}
+
+func (f *fakeMedia) GetMediaItem(ctx context.Context, id int64) (*media.MediaItem, error) {
+	if item, ok := f.items[id]; ok {
+		return item, nil
+	}
+	return nil, media.ErrNotFound
+}
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, zap.NewNop())
+
+	current := "https://image.tmdb.org/t/p/original/new.jpg"
+	files := []string{
+		c.path(1, KindPoster, Version(current), SizeOriginal),
+		c.path(1, KindPoster, Version(current), SizeThumb),
+		c.path(1, KindPoster, Version("https://image.tmdb.org/t/p/original/old.jpg"), SizeOriginal),
+		c.path(2, KindBackdrop, Version(current), SizeOriginal),
+	}
+	for _, path := range files {
+		if err := writeFile(path, []byte("image")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items := &fakeMedia{items: map[int64]*media.MediaItem{
+		1: {ID: 1, Metadata: map[string]interface{}{"poster_url": current}},
+	}}
+	result, err := c.Cleanup(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ItemsRemoved != 1 || result.FilesRemoved != 2 {
+		t.Errorf("Cleanup() = %+v, want 1 item and 2 files removed", result)
+	}
+	for i, path := range files {
+		_, err := os.Stat(path)
+		if kept := err == nil; kept != (i < 2) {
+			t.Errorf("%s kept = %v", filepath.Base(path), kept)
+		}
+	}
+}
+
+func TestRewriteURLs(t *testing.T) {
+	poster := "https://image.tmdb.org/t/p/original/poster.jpg"
+	stored := map[string]interface{}{"poster_url": poster, "still_url": "", "title": "Alien"}
+	item := &media.MediaItem{ID: 7, Metadata: stored}
+
+	RewriteURLs(item)
+	if got := item.Metadata["poster_url"]; got != "/api/images/7/poster?v="+Version(poster) {
+		t.Errorf("poster_url = %v", got)
+	}
+	if stored["poster_url"] != poster {
+		t.Error("RewriteURLs() changed the stored metadata")
+	}
+	if item.Metadata["still_url"] != "" {
+		t.Errorf("still_url = %v, want it left empty", item.Metadata["still_url"])
+	}
+
+	sent := map[string]interface{}{"poster_url": item.Metadata["poster_url"], "backdrop_url": "/api/images/7/backdrop"}
+	RestoreSourceURLs(sent, stored)
+	if sent["poster_url"] != poster {
+		t.Errorf("restored poster_url = %v", sent["poster_url"])
+	}
+	if _, ok := sent["backdrop_url"]; ok {
+		t.Error("backdrop_url without a stored URL was kept")
+	}
+}
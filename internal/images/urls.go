@@ -0,0 +1,76 @@
+package images
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/media"
+)
+
+// localPrefix starts the URLs artwork is served from by this server
+const localPrefix = "/api/images/"
+
+// LocalURL returns the URL a media item's artwork is served from by the
+// cache. The version changes with the source URL, so clients can cache the
+// image for good.
+func LocalURL(mediaID int64, kind Kind, sourceURL string) string {
+	return fmt.Sprintf("%s%d/%s?v=%s", localPrefix, mediaID, kind, Version(sourceURL))
+}
+
+// isRemote reports whether an artwork URL points at an upstream server
+func isRemote(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// SourceURL returns the upstream URL of a media item's artwork
+func SourceURL(metadata map[string]interface{}, kind Kind) (string, bool) {
+	url, ok := metadata[kind.MetadataKey()].(string)
+	if !ok || !isRemote(url) {
+		return "", false
+	}
+	return url, true
+}
+
+// RewriteURLs points the artwork URLs of a media item at the image cache. The
+// metadata map is copied, so the stored item isn't changed.
+func RewriteURLs(item *media.MediaItem) {
+	if item == nil || item.Metadata == nil {
+		return
+	}
+
+	var metadata map[string]interface{}
+	for _, kind := range Kinds {
+		url, ok := SourceURL(item.Metadata, kind)
+		if !ok {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]interface{}, len(item.Metadata))
+			for k, v := range item.Metadata {
+				metadata[k] = v
+			}
+		}
+		metadata[kind.MetadataKey()] = LocalURL(item.ID, kind, url)
+	}
+	if metadata != nil {
+		item.Metadata = metadata
+	}
+}
+
+// RestoreSourceURLs replaces cache URLs in metadata sent back by a client
+// with the upstream URLs they were rewritten from, so saving an item shown
+// with rewritten URLs doesn't lose its artwork
+func RestoreSourceURLs(metadata, stored map[string]interface{}) {
+	for _, kind := range Kinds {
+		key := kind.MetadataKey()
+		url, ok := metadata[key].(string)
+		if !ok || !strings.HasPrefix(url, localPrefix) {
+			continue
+		}
+		if source, ok := stored[key]; ok {
+			metadata[key] = source
+		} else {
+			delete(metadata, key)
+		}
+	}
+}
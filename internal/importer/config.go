@@ -25,9 +25,16 @@ type ImportConfig struct {
 	CreateSeriesFolder   bool
 	RenameEpisodes       bool
 
+	// Music naming
+	MusicNamingFormat       string
+	MusicArtistFolderFormat string
+	MusicAlbumFolderFormat  string
+	RenameTracks            bool
+
 	// File management
 	ReplaceIllegalCharacters bool
 	ColonReplacement         string // "delete", "dash", "space", "spacedash"
+	SpaceReplacement         string // "space", "dot", "underscore"; of file names
 
 	// Quality
 	PreferredQuality      string
@@ -44,6 +51,7 @@ type ImportConfig struct {
 	UseHardlinks        bool
 	ImportExtraFiles    bool
 	ExtraFileExtensions string
+	StabilityInterval   int // Seconds a file must stay unchanged before it is imported; 0 skips the check
 
 	// Advanced
 	SetPermissions    bool
@@ -67,8 +75,13 @@ func (s *Service) loadConfig(ctx context.Context) (*ImportConfig, error) {
 		TVUseSeasonFolders:        true,
 		CreateSeriesFolder:        true,
 		RenameEpisodes:            true,
+		MusicNamingFormat:         "{track:00} - {Track Title}",
+		MusicArtistFolderFormat:   "{Artist Name}",
+		MusicAlbumFolderFormat:    "{Album Title} ({Release Year})",
+		RenameTracks:              true,
 		ReplaceIllegalCharacters:  true,
 		ColonReplacement:          "dash",
+		SpaceReplacement:          SpaceReplacementSpace,
 		PreferredQuality:          "1080p",
 		EnableQualityUpgrades:     true,
 		UpgradeUntilQuality:       "1080p",
@@ -79,6 +92,7 @@ func (s *Service) loadConfig(ctx context.Context) (*ImportConfig, error) {
 		UseHardlinks:              true,
 		ImportExtraFiles:          true,
 		ExtraFileExtensions:       "srt,nfo,txt",
+		StabilityInterval:         10,
 		SetPermissions:            false,
 		ChmodFolder:               "755",
 		ChmodFile:                 "644",
@@ -98,8 +112,13 @@ func (s *Service) loadConfig(ctx context.Context) (*ImportConfig, error) {
 		"downloads.tv_use_season_folders":       &config.TVUseSeasonFolders,
 		"downloads.create_series_folder":        &config.CreateSeriesFolder,
 		"downloads.rename_episodes":             &config.RenameEpisodes,
+		"downloads.music_naming_format":         &config.MusicNamingFormat,
+		"downloads.music_artist_folder_format":  &config.MusicArtistFolderFormat,
+		"downloads.music_album_folder_format":   &config.MusicAlbumFolderFormat,
+		"downloads.rename_tracks":               &config.RenameTracks,
 		"downloads.replace_illegal_characters":  &config.ReplaceIllegalCharacters,
 		"downloads.colon_replacement":           &config.ColonReplacement,
+		"downloads.space_replacement":           &config.SpaceReplacement,
 		"downloads.preferred_quality":           &config.PreferredQuality,
 		"downloads.enable_quality_upgrades":     &config.EnableQualityUpgrades,
 		"downloads.upgrade_until_quality":       &config.UpgradeUntilQuality,
@@ -110,6 +129,7 @@ func (s *Service) loadConfig(ctx context.Context) (*ImportConfig, error) {
 		"downloads.use_hardlinks":               &config.UseHardlinks,
 		"downloads.import_extra_files":          &config.ImportExtraFiles,
 		"downloads.extra_file_extensions":       &config.ExtraFileExtensions,
+		"downloads.stability_check_interval":    &config.StabilityInterval,
 		"downloads.set_permissions":             &config.SetPermissions,
 		"downloads.chmod_folder":                &config.ChmodFolder,
 		"downloads.chmod_file":                  &config.ChmodFile,
@@ -151,13 +171,25 @@ func (s *Service) loadConfig(ctx context.Context) (*ImportConfig, error) {
 		}
 	}
 
+	// The library recycle bin takes over from the older downloads setting
+	if value, err := s.configStore.Get(ctx, "library.recycle_bin"); err == nil {
+		var path string
+		if err := json.Unmarshal(value, &path); err == nil && path != "" {
+			config.RecycleBinPath = path
+		}
+	}
+
 	// Clean up string values (remove quotes if present)
 	config.MovieNamingFormat = cleanConfigString(config.MovieNamingFormat)
 	config.MovieFolderFormat = cleanConfigString(config.MovieFolderFormat)
 	config.TVNamingFormat = cleanConfigString(config.TVNamingFormat)
 	config.TVFolderFormat = cleanConfigString(config.TVFolderFormat)
 	config.TVSeasonFolderFormat = cleanConfigString(config.TVSeasonFolderFormat)
+	config.MusicNamingFormat = cleanConfigString(config.MusicNamingFormat)
+	config.MusicArtistFolderFormat = cleanConfigString(config.MusicArtistFolderFormat)
+	config.MusicAlbumFolderFormat = cleanConfigString(config.MusicAlbumFolderFormat)
 	config.ColonReplacement = cleanConfigString(config.ColonReplacement)
+	config.SpaceReplacement = cleanConfigString(config.SpaceReplacement)
 	config.PreferredQuality = cleanConfigString(config.PreferredQuality)
 	config.UpgradeUntilQuality = cleanConfigString(config.UpgradeUntilQuality)
 	config.ExtraFileExtensions = cleanConfigString(config.ExtraFileExtensions)
@@ -190,9 +222,15 @@ func (c *ImportConfig) Validate() error {
 	if c.TVNamingFormat == "" {
 		return fmt.Errorf("TV naming format cannot be empty")
 	}
+	if c.MusicNamingFormat == "" {
+		return fmt.Errorf("music naming format cannot be empty")
+	}
 	if c.MinimumFreeSpaceMB < 0 {
 		return fmt.Errorf("minimum free space cannot be negative")
 	}
+	if c.StabilityInterval < 0 {
+		return fmt.Errorf("stability check interval cannot be negative")
+	}
 	if c.RecycleBinCleanup < 0 {
 		return fmt.Errorf("recycle bin cleanup days cannot be negative")
 	}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"go.uber.org/zap"
+)
+
+// recordCutoff marks the episodes an imported file covers as having a file and
+// records whether it meets the cutoff of their quality profile. Monitoring stops
+// searching for episodes that meet it.
+func (s *Service) recordCutoff(ctx context.Context, req *ImportRequest, finalPath string, mediaItemID *int64, result *ImportResult) {
+	if mediaItemID == nil || (req.MediaType != "tv" && req.MediaType != "tv_episode") {
+		return
+	}
+	file, err := s.queries.GetMediaFileByPath(ctx, finalPath)
+	if err != nil {
+		s.logger.Warn("no media_files entry to record the cutoff of", zap.String("path", finalPath), zap.Error(err))
+		return
+	}
+
+	rank := qualityRank(importQuality(req, quality.NewDetector()))
+	for _, id := range append([]int64{*mediaItemID}, result.LinkedEpisodes...) {
+		met, err := s.cutoffMet(ctx, id, rank)
+		if err != nil {
+			s.logger.Warn("failed to check quality cutoff", zap.Int64("media_item_id", id), zap.Error(err))
+			continue
+		}
+		if err := s.queries.SetEpisodeFileCutoff(ctx, generated.SetEpisodeFileCutoffParams{
+			MediaItemID: id,
+			FileID:      file.ID,
+			CutoffMet:   met,
+		}); err != nil {
+			s.logger.Warn("failed to record quality cutoff", zap.Int64("media_item_id", id), zap.Error(err))
+		}
+	}
+}
+
+// cutoffMet reports whether a file of the given quality rank leaves nothing to
+// upgrade for a media item
+func (s *Service) cutoffMet(ctx context.Context, mediaItemID int64, rank int) (bool, error) {
+	profile, cutoff, err := s.qualityCutoff(ctx, mediaItemID)
+	if err != nil {
+		return false, err
+	}
+	return meetsCutoff(profile, cutoff, rank), nil
+}
+
+// meetsCutoff reports whether a file of the given quality rank meets a profile's
+// cutoff, or the profile doesn't allow upgrades at all. Files of unknown quality
+// never meet it.
+func meetsCutoff(profile *generated.QualityProfile, cutoff *generated.QualityDefinition, rank int) bool {
+	if profile != nil && !profile.UpgradeAllowed {
+		return true
+	}
+	return cutoff != nil && rank > 0 && rank >= definitionRank(cutoff)
+}
+
+// RecomputeCutoffs works out again whether the file of every episode meets its
+// quality profile's cutoff. Editing a profile can make many episodes eligible
+// for upgrades, or stop them being so. It returns the number of episodes that
+// changed.
+func (s *Service) RecomputeCutoffs(ctx context.Context) (int, error) {
+	episodes, err := s.queries.ListEpisodeFileCutoffs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list episodes with files: %w", err)
+	}
+
+	detector := quality.NewDetector()
+	changed := 0
+	for _, episode := range episodes {
+		if err := ctx.Err(); err != nil {
+			return changed, err
+		}
+
+		file := generated.MediaFile{Path: episode.Path, ReleaseTitle: episode.ReleaseTitle, Quality: episode.Quality}
+		met, err := s.cutoffMet(ctx, episode.MediaItemID, qualityRank(fileQuality(file, detector)))
+		if err != nil {
+			s.logger.Warn("failed to check quality cutoff", zap.Int64("media_item_id", episode.MediaItemID), zap.Error(err))
+			continue
+		}
+		if met == episode.CutoffMet {
+			continue
+		}
+		if err := s.queries.SetEpisodeCutoffMet(ctx, generated.SetEpisodeCutoffMetParams{
+			MediaItemID: episode.MediaItemID,
+			CutoffMet:   met,
+		}); err != nil {
+			return changed, fmt.Errorf("failed to update quality cutoff: %w", err)
+		}
+		changed++
+	}
+
+	s.logger.Info("recomputed quality cutoffs",
+		zap.Int("episodes", len(episodes)),
+		zap.Int("changed", changed))
+	return changed, nil
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"go.uber.org/zap"
+)
+
+// Import decision statuses
+const (
+	DecisionStatusPending  = "pending"
+	DecisionStatusResolved = "resolved"
+)
+
+// FailedImport describes an import that could not be completed automatically
+type FailedImport struct {
+	DownloadID  string                 // Download the file came from, if known
+	SourcePath  string                 // File or folder that was not imported
+	MediaItemID *int64                 // Media item the download was grabbed for, if known
+	Detected    map[string]interface{} // Attributes detected from the download
+	Reason      string                 // Why the import failed
+}
+
+// ImportDecision is a failed import awaiting manual resolution
+type ImportDecision struct {
+	ID                  int64                  `json:"id"`
+	DownloadID          *string                `json:"download_id,omitempty"`
+	SourcePath          string                 `json:"source_path"`
+	MediaItemID         *int64                 `json:"media_item_id,omitempty"`
+	Detected            map[string]interface{} `json:"detected"`
+	Reason              string                 `json:"reason"`
+	Status              string                 `json:"status"`
+	ResolvedMediaItemID *int64                 `json:"resolved_media_item_id,omitempty"`
+	FinalPath           *string                `json:"final_path,omitempty"`
+	ResolvedAt          *time.Time             `json:"resolved_at,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+}
+
+// DecisionList is a page of import decisions
+type DecisionList struct {
+	Items   []ImportDecision `json:"items"`
+	Total   int64            `json:"total"`
+	Limit   int32            `json:"limit"`
+	Offset  int32            `json:"offset"`
+	HasMore bool             `json:"has_more"`
+}
+
+// DetectedAttributes returns the attributes of the request worth keeping with a failed import
+func (req *ImportRequest) DetectedAttributes() map[string]interface{} {
+	detected := map[string]interface{}{}
+	if req.MediaType != "" {
+		detected["media_type"] = req.MediaType
+	}
+	if req.Title != "" {
+		detected["title"] = req.Title
+	}
+	if req.Year != nil {
+		detected["year"] = *req.Year
+	}
+	if req.Season != nil {
+		detected["season"] = *req.Season
+	}
+	if req.Episode != nil {
+		detected["episode"] = *req.Episode
+	}
+	if req.EpisodeTitle != nil {
+		detected["episode_title"] = *req.EpisodeTitle
+	}
+	if req.Quality != nil {
+		detected["quality"] = *req.Quality
+	}
+	return detected
+}
+
+// RecordFailure stores a failed import as a pending import decision. A path that
+// is already pending is updated with the latest reason.
+func (s *Service) RecordFailure(ctx context.Context, failure FailedImport) (*ImportDecision, error) {
+	detected := failure.Detected
+	if detected == nil {
+		detected = map[string]interface{}{}
+	}
+	detectedJSON, err := json.Marshal(detected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal detected attributes: %w", err)
+	}
+
+	params := generated.UpsertPendingImportDecisionParams{
+		SourcePath:  failure.SourcePath,
+		MediaItemID: failure.MediaItemID,
+		Detected:    detectedJSON,
+		Reason:      failure.Reason,
+	}
+	if failure.DownloadID != "" {
+		params.DownloadID = &failure.DownloadID
+	}
+
+	row, err := s.queries.UpsertPendingImportDecision(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record import decision: %w", err)
+	}
+
+	s.logger.Info("recorded failed import for manual resolution",
+		zap.Int64("decision_id", row.ID),
+		zap.String("download_id", failure.DownloadID),
+		zap.String("source", failure.SourcePath),
+		zap.String("reason", failure.Reason))
+
+	return toImportDecision(row), nil
+}
+
+// GetDecision returns an import decision by ID
+func (s *Service) GetDecision(ctx context.Context, id int64) (*ImportDecision, error) {
+	row, err := s.queries.GetImportDecision(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toImportDecision(row), nil
+}
+
+// ListPendingDecisions returns a page of failed imports awaiting resolution, newest first
+func (s *Service) ListPendingDecisions(ctx context.Context, limit, offset int32) (*DecisionList, error) {
+	rows, err := s.queries.ListPendingImportDecisions(ctx, generated.ListPendingImportDecisionsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import decisions: %w", err)
+	}
+
+	total, err := s.queries.CountPendingImportDecisions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count import decisions: %w", err)
+	}
+
+	items := make([]ImportDecision, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, *toImportDecision(row))
+	}
+
+	return &DecisionList{
+		Items:   items,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: int64(offset)+int64(len(items)) < total,
+	}, nil
+}
+
+// ResolveDecision marks a decision as resolved by a successful import
+func (s *Service) ResolveDecision(ctx context.Context, id int64, result *ImportResult) (*ImportDecision, error) {
+	row, err := s.queries.ResolveImportDecision(ctx, generated.ResolveImportDecisionParams{
+		ID:                  id,
+		ResolvedMediaItemID: result.MediaItemID,
+		FinalPath:           &result.FinalPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve import decision: %w", err)
+	}
+	return toImportDecision(row), nil
+}
+
+// toImportDecision converts a database row to an ImportDecision
+func toImportDecision(row generated.ImportDecision) *ImportDecision {
+	decision := &ImportDecision{
+		ID:                  row.ID,
+		DownloadID:          row.DownloadID,
+		SourcePath:          row.SourcePath,
+		MediaItemID:         row.MediaItemID,
+		Detected:            map[string]interface{}{},
+		Reason:              row.Reason,
+		Status:              row.Status,
+		ResolvedMediaItemID: row.ResolvedMediaItemID,
+		FinalPath:           row.FinalPath,
+		CreatedAt:           row.CreatedAt.Time,
+		UpdatedAt:           row.UpdatedAt.Time,
+	}
+	if len(row.Detected) > 0 {
+		_ = json.Unmarshal(row.Detected, &decision.Detected)
+	}
+	if row.ResolvedAt.Valid {
+		resolvedAt := row.ResolvedAt.Time
+		decision.ResolvedAt = &resolvedAt
+	}
+	return decision
+}
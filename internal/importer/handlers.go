@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// NamingHandler handles naming settings HTTP requests
+type NamingHandler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewNamingHandler creates a new naming settings handler
+func NewNamingHandler(service *Service, logger *zap.Logger) *NamingHandler {
+	return &NamingHandler{
+		service: service,
+		logger:  logger.With(zap.String("component", "naming-handler")),
+	}
+}
+
+// SetupNamingRoutes registers the naming settings routes
+func SetupNamingRoutes(r chi.Router, h *NamingHandler) {
+	r.Route("/settings/naming", func(r chi.Router) {
+		r.Get("/", h.GetNaming)
+		r.Put("/", h.UpdateNaming)
+		r.Post("/preview", h.PreviewNaming)
+	})
+}
+
+// SetupRenameRoutes registers the routes that rename the files of a media
+// item to match the naming templates, which must be mounted on the media
+// router behind the admin middleware
+func SetupRenameRoutes(r chi.Router, h *NamingHandler) {
+	r.Get("/{id}/rename-preview", h.PreviewRename)
+	r.Post("/{id}/rename", h.Rename)
+}
+
+// namingResponse is the naming settings with the tokens each template can use
+type namingResponse struct {
+	Settings *NamingSettings     `json:"settings"`
+	Tokens   map[string][]string `json:"tokens"`
+}
+
+// GetNaming returns the naming settings
+// GET /api/settings/naming
+func (h *NamingHandler) GetNaming(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.service.NamingSettings(r.Context())
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to load naming settings")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, namingResponse{Settings: settings, Tokens: TemplateTokens()})
+}
+
+// UpdateNaming validates and saves naming settings. Fields left out keep their
+// values.
+// PUT /api/settings/naming
+func (h *NamingHandler) UpdateNaming(w http.ResponseWriter, r *http.Request) {
+	var update json.RawMessage
+	if err := httputil.DecodeJSON(r, &update); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	settings, err := h.service.UpdateNamingSettings(r.Context(), update)
+	if err != nil {
+		h.respondNamingError(w, err, "Failed to save naming settings")
+		return
+	}
+	h.logger.Info("naming settings updated",
+		zap.String("movie_file_format", settings.MovieFileFormat),
+		zap.String("episode_file_format", settings.EpisodeFileFormat))
+	httputil.RespondJSON(w, http.StatusOK, namingResponse{Settings: settings, Tokens: TemplateTokens()})
+}
+
+// PreviewNaming renders naming settings, saved or not, against sample media
+// POST /api/settings/naming/preview
+func (h *NamingHandler) PreviewNaming(w http.ResponseWriter, r *http.Request) {
+	var req NamingPreviewRequest
+	if r.ContentLength != 0 {
+		if err := httputil.DecodeJSON(r, &req); err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	preview, err := h.service.PreviewNaming(r.Context(), &req)
+	if err != nil {
+		h.respondNamingError(w, err, "Failed to preview naming")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, preview)
+}
+
+// respondNamingError responds 400 to invalid settings and 500 otherwise
+func (h *NamingHandler) respondNamingError(w http.ResponseWriter, err error, message string) {
+	var namingErr *NamingError
+	if errors.As(err, &namingErr) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, namingErr.Message)
+		return
+	}
+	httputil.RespondError(w, http.StatusInternalServerError, err, message)
+}
+
+func parseMediaID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondRenameError maps a rename error to a response
+func (h *NamingHandler) respondRenameError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrRenameNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item not found")
+	case errors.Is(err, ErrRenameUnsupported):
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+	default:
+		httputil.RespondError(w, http.StatusInternalServerError, err, message)
+	}
+}
+
+// PreviewRename lists the files of a movie, series, season or episode with
+// the paths the current naming templates would give them
+// GET /api/media/{id}/rename-preview
+func (h *NamingHandler) PreviewRename(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	preview, err := h.service.PreviewRename(r.Context(), id)
+	if err != nil {
+		h.respondRenameError(w, err, "Failed to preview rename")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, preview)
+}
+
+// renameRequest is the body of a rename request
+type renameRequest struct {
+	FileIDs []int64 `json:"file_ids"` // Media files to rename; all changed files when empty
+}
+
+// Rename renames the selected files of a media item to match the naming
+// templates
+// POST /api/media/{id}/rename
+func (h *NamingHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	var req renameRequest
+	if r.ContentLength != 0 {
+		if err := httputil.DecodeJSON(r, &req); err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	result, err := h.service.Rename(r.Context(), id, req.FileIDs)
+	if err != nil {
+		h.respondRenameError(w, err, "Failed to rename files")
+		return
+	}
+	h.logger.Info("renamed media files",
+		zap.Int64("media_item_id", id),
+		zap.Int("renamed", result.Renamed),
+		zap.Int("failed", result.Failed))
+	httputil.RespondJSON(w, http.StatusOK, result)
+}
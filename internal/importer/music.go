@@ -0,0 +1,309 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"go.uber.org/zap"
+)
+
+// unknownArtist names the artist folder of tracks without an artist, as the
+// library scanner does
+const unknownArtist = "Unknown Artist"
+
+// isMusicType reports whether an import media type is music
+func isMusicType(mediaType string) bool {
+	switch mediaType {
+	case "music", "music_track", "music_album":
+		return true
+	}
+	return false
+}
+
+// importMusicTrack imports a music track file. An import for an album is
+// matched to the album's track named by the file, which is created when the
+// album doesn't have it yet.
+func (s *Service) importMusicTrack(ctx context.Context, req *ImportRequest, config *ImportConfig, libraryPath string, result *ImportResult) (string, *int64, error) {
+	var albumID *int64
+	if req.MediaType == "music_album" && req.MediaItemID != nil {
+		album, err := s.queries.GetMediaItem(ctx, *req.MediaItemID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get album: %w", err)
+		}
+		if err := s.matchTrack(ctx, &album, req); err != nil {
+			return "", nil, err
+		}
+		if req.MediaType == "music_album" {
+			// The album doesn't have this track yet
+			albumID = &album.ID
+			req.MediaItemID = nil
+			req.MediaType = "music_track"
+		}
+	} else {
+		fillTrackFromFile(req, "")
+	}
+
+	if req.Title == "" {
+		return "", nil, fmt.Errorf("track title is required for music imports")
+	}
+
+	target, err := s.importTarget(req, config, libraryPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Check the import against the files the track already has
+	upgrade, err := s.planUpgrade(ctx, req, config)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, folder := range target.Folders {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create folder: %w", err)
+		}
+		result.CreatedFolders = append(result.CreatedFolders, folder)
+	}
+	targetDir, fileName, finalPath := target.Dir, target.FileName, target.FinalPath
+
+	// Move/copy the file, replacing the files it upgrades
+	if err := s.placeFile(ctx, req, finalPath, upgrade, config, result); err != nil {
+		return "", nil, err
+	}
+
+	// Import extra files, e.g. lyrics
+	if config.ImportExtraFiles {
+		extras := s.findExtraFiles(req.SourcePath, config.ExtraFileExtensions)
+		for _, extra := range extras {
+			extraName := s.generateExtraFileName(fileName, extra, config)
+			extraPath := filepath.Join(targetDir, extraName)
+			if err := s.transferFile(extra, extraPath, transferMode(req, config)); err != nil {
+				s.logger.Warn("failed to import extra file", zap.String("file", extra), zap.Error(err))
+			} else {
+				result.ImportedExtras = append(result.ImportedExtras, extraPath)
+			}
+		}
+	}
+
+	// Set permissions
+	if config.SetPermissions {
+		s.setPermissions(finalPath, config.ChmodFile)
+		s.setPermissions(targetDir, config.ChmodFolder)
+	}
+
+	// Upsert/update media item
+	var mediaItemID *int64
+	if req.MediaItemID != nil {
+		mediaItemID = req.MediaItemID
+
+		// Create media_files entry for the imported file
+		fileSize, _ := s.getFileSize(finalPath)
+		_, err := s.queries.CreateMediaFile(ctx, generated.CreateMediaFileParams{
+			MediaItemID: req.MediaItemID,
+			Path:        finalPath,
+			Size:        &fileSize,
+			Hash:        nil,
+		})
+		if err != nil {
+			s.logger.Warn("failed to create media_files entry",
+				zap.String("path", finalPath),
+				zap.Error(err))
+		}
+	} else {
+		fileSize, _ := s.getFileSize(finalPath)
+		parsed := &library.ParsedMedia{
+			Kind:   "music_track",
+			Title:  req.Title,
+			Artist: derefString(req.Artist),
+			Album:  derefString(req.Album),
+		}
+		if req.Track != nil {
+			parsed.Track = *req.Track
+		}
+		if req.Year != nil {
+			parsed.Year = *req.Year
+		}
+
+		libraryService := library.NewService(s.queries, s.logger)
+		var itemID int64
+		if albumID != nil {
+			itemID, _, err = libraryService.UpsertAlbumTrack(ctx, *albumID, parsed, finalPath, fileSize)
+		} else {
+			itemID, _, err = libraryService.UpsertMusicTrack(ctx, parsed, finalPath, fileSize)
+		}
+		if err != nil {
+			s.logger.Warn("failed to create media item", zap.Error(err))
+		} else {
+			mediaItemID = &itemID
+		}
+	}
+
+	return finalPath, mediaItemID, nil
+}
+
+// matchTrack resolves the track of an album that a file belongs to and fills
+// in the album details needed by the naming templates. When the album has no
+// track with the file's number or title, the request stays on the album.
+func (s *Service) matchTrack(ctx context.Context, album *generated.MediaItem, req *ImportRequest) error {
+	albumTitle := album.Title
+	req.Album = &albumTitle
+	if album.Year != nil {
+		year := int(*album.Year)
+		req.Year = &year
+	}
+	if req.Artist == nil && album.ParentID != nil {
+		if artist, err := s.queries.GetMediaItem(ctx, *album.ParentID); err == nil {
+			req.Artist = &artist.Title
+		}
+	}
+
+	if !fillTrackFromFile(req, album.Title) && (req.Title == "" || req.Title == album.Title) {
+		return fmt.Errorf("could not parse track from file name %s", filepath.Base(req.SourcePath))
+	}
+
+	tracks, err := s.queries.ListChildMediaItems(ctx, &album.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list tracks: %w", err)
+	}
+	for _, track := range tracks {
+		number, hasNumber := metadataInt(track.Metadata, "track_number", "track")
+		matched := strings.EqualFold(track.Title, req.Title)
+		if req.Track != nil && hasNumber && number > 0 {
+			matched = number == *req.Track
+		}
+		if matched {
+			id := track.ID
+			title := track.Title
+			req.MediaType = "music_track"
+			req.MediaItemID = &id
+			req.Title = title
+			return nil
+		}
+	}
+
+	req.MediaType = "music_album"
+	req.MediaItemID = &album.ID
+	return nil
+}
+
+// fillTrackFromFile completes the track number, title, artist and album of a
+// music import from its file name. A title equal to albumTitle is the album's
+// own and is replaced by the track's. It reports whether the file name could
+// be parsed as a track.
+func fillTrackFromFile(req *ImportRequest, albumTitle string) bool {
+	parsed := library.ParseFilename(req.SourcePath)
+	if parsed == nil || parsed.Kind != "music_track" {
+		return false
+	}
+
+	if req.Track == nil && parsed.Track > 0 {
+		req.Track = &parsed.Track
+	}
+	if req.Title == "" || (albumTitle != "" && req.Title == albumTitle) {
+		req.Title = parsed.Title
+	}
+	if req.Artist == nil && parsed.Artist != "" {
+		req.Artist = &parsed.Artist
+	}
+	if req.Album == nil && parsed.Album != "" {
+		req.Album = &parsed.Album
+	}
+	if req.Year == nil && parsed.Year > 0 {
+		req.Year = &parsed.Year
+	}
+	return true
+}
+
+// musicTarget computes the Artist/Album folders and file name of a track
+func (s *Service) musicTarget(target *importTarget, req *ImportRequest, config *ImportConfig, libraryPath string) error {
+	if req.Album == nil || *req.Album == "" {
+		return fmt.Errorf("album is required for music imports")
+	}
+
+	artistFolderName := s.sanitizePath(s.applyMusicNamingTemplate(config.MusicArtistFolderFormat, req), config)
+	if artistFolderName == "" {
+		artistFolderName = unknownArtist
+	}
+	artistDir := filepath.Join(libraryPath, artistFolderName)
+
+	albumFolderName := s.sanitizePath(s.applyMusicNamingTemplate(config.MusicAlbumFolderFormat, req), config)
+	target.Dir = filepath.Join(artistDir, albumFolderName)
+	target.Folders = append(target.Folders, artistDir, target.Dir)
+
+	target.FileName = s.sanitizePath(s.applyMusicNamingTemplate(config.MusicNamingFormat, req), config)
+	return nil
+}
+
+// trackNumberToken matches padded track numbers in naming templates, e.g. {track:00}
+var trackNumberToken = regexp.MustCompile(`\{track:(\d+)\}`)
+
+// applyMusicNamingTemplate applies a music naming or folder template with
+// token replacement
+func (s *Service) applyMusicNamingTemplate(template string, req *ImportRequest) string {
+	result := template
+
+	result = strings.ReplaceAll(result, "{Track Title}", req.Title)
+
+	artist := derefString(req.Artist)
+	if artist == "" {
+		artist = unknownArtist
+	}
+	result = strings.ReplaceAll(result, "{Artist Name}", artist)
+	result = strings.ReplaceAll(result, "{Album Title}", derefString(req.Album))
+
+	if req.Track != nil {
+		result = strings.ReplaceAll(result, "{Track}", fmt.Sprintf("%d", *req.Track))
+		result = trackNumberToken.ReplaceAllStringFunc(result, func(m string) string {
+			return padNumber(trackNumberToken.FindStringSubmatch(m)[1], *req.Track)
+		})
+	} else {
+		result = strings.ReplaceAll(result, "{Track}", "")
+		result = trackNumberToken.ReplaceAllString(result, "")
+	}
+
+	if req.Year != nil {
+		result = strings.ReplaceAll(result, "{Release Year}", fmt.Sprintf("%d", *req.Year))
+	} else {
+		result = strings.ReplaceAll(result, "{Release Year}", "")
+	}
+
+	if req.Quality != nil {
+		result = strings.ReplaceAll(result, "{Quality}", *req.Quality)
+	} else {
+		result = strings.ReplaceAll(result, "{Quality}", "")
+	}
+
+	// Clean up double spaces, empty brackets and the separator of a missing track number
+	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
+	result = regexp.MustCompile(`\[\s*\]`).ReplaceAllString(result, "")
+	result = regexp.MustCompile(`\(\s*\)`).ReplaceAllString(result, "")
+	result = strings.TrimSpace(result)
+	result = strings.TrimPrefix(result, "- ")
+
+	return strings.TrimSpace(result)
+}
+
+// padNumber zero-pads n to the width of a token's format, which is either a
+// run of zeros ({track:00}) or a digit count ({track:2})
+func padNumber(format string, n int) string {
+	width := len(format)
+	if !strings.HasPrefix(format, "0") {
+		width, _ = strconv.Atoi(format)
+	}
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// derefString returns the string a pointer points to, or "" for nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
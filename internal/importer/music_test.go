@@ -0,0 +1,58 @@
+package importer
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMusicImportTarget(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	config := &ImportConfig{
+		MusicNamingFormat:        "{track:00} - {Track Title}",
+		MusicArtistFolderFormat:  "{Artist Name}",
+		MusicAlbumFolderFormat:   "{Album Title} ({Release Year})",
+		RenameTracks:             true,
+		ReplaceIllegalCharacters: true,
+		ColonReplacement:         "dash",
+	}
+
+	// Track details missing from the request come from the file name
+	req := &ImportRequest{
+		SourcePath: "/downloads/Radiohead - OK Computer (1997) [FLAC]/2. Paranoid Android.flac",
+		MediaType:  "music",
+	}
+	if !fillTrackFromFile(req, "") {
+		t.Fatal("fillTrackFromFile() could not parse the file name")
+	}
+
+	target, err := s.importTarget(req, config, "/music")
+	if err != nil {
+		t.Fatalf("importTarget() error = %v", err)
+	}
+	if want := "/music/Radiohead/OK Computer (1997)/02 - Paranoid Android.flac"; target.FinalPath != want {
+		t.Errorf("FinalPath = %q, want %q", target.FinalPath, want)
+	}
+	if len(target.Folders) != 2 {
+		t.Errorf("Folders = %v, want artist and album folders", target.Folders)
+	}
+
+	// Without a year, artist or track number the leftovers are cleaned up
+	album := "Untitled"
+	target, err = s.importTarget(&ImportRequest{
+		SourcePath: "/downloads/track.mp3",
+		MediaType:  "music_track",
+		Title:      "Intro",
+		Album:      &album,
+	}, config, "/music")
+	if err != nil {
+		t.Fatalf("importTarget() error = %v", err)
+	}
+	if want := "/music/Unknown Artist/Untitled/Intro.mp3"; target.FinalPath != want {
+		t.Errorf("FinalPath = %q, want %q", target.FinalPath, want)
+	}
+
+	if _, err := s.importTarget(&ImportRequest{SourcePath: "/downloads/track.mp3", MediaType: "music_track", Title: "Intro"}, config, "/music"); err == nil {
+		t.Error("importTarget() without an album succeeded")
+	}
+}
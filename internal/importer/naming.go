@@ -0,0 +1,701 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+)
+
+// Naming templates
+const (
+	TemplateMovieFolder  = "movie_folder_format"
+	TemplateMovieFile    = "movie_file_format"
+	TemplateSeriesFolder = "series_folder_format"
+	TemplateSeasonFolder = "season_folder_format"
+	TemplateEpisodeFile  = "episode_file_format"
+)
+
+// Space replacements
+const (
+	SpaceReplacementSpace      = "space"
+	SpaceReplacementDot        = "dot"
+	SpaceReplacementUnderscore = "underscore"
+)
+
+var (
+	// namingToken matches a token of a naming template, like {Movie Title} or
+	// {season:00}
+	namingToken = regexp.MustCompile(`\{([^{}:]+)(?::([^{}]*))?\}`)
+
+	// numberFormat is the padding of a numbered token, a run of zeros
+	// ({season:00}) or a digit count ({season:2})
+	numberFormat = regexp.MustCompile(`^(0+|[1-9])$`)
+
+	// spaceRun matches the spaces, and dashes set off by spaces, that dots or
+	// underscores replace
+	spaceRun = regexp.MustCompile(`\s+-\s+|\s+`)
+)
+
+// tokenDef is a token naming templates can use
+type tokenDef struct {
+	Name     string // As written in templates
+	Numbered bool   // Takes a padding format, e.g. {season:00}
+}
+
+var (
+	movieTitleTokens = []tokenDef{{Name: "Movie Title"}, {Name: "Release Year"}}
+	seriesTitleToken = []tokenDef{{Name: "Series Title"}, {Name: "Year"}}
+
+	// fileTokens describe the file rather than the media, so they only make
+	// sense in file names
+	fileTokens = []tokenDef{
+		{Name: "Quality"},
+		{Name: "Release Group"},
+		{Name: "MediaInfo VideoCodec"},
+		{Name: "MediaInfo VideoBitDepth"},
+		{Name: "MediaInfo VideoDynamicRange"},
+		{Name: "MediaInfo AudioCodec"},
+		{Name: "MediaInfo AudioChannels"},
+	}
+
+	seasonToken  = tokenDef{Name: "Season", Numbered: true}
+	episodeToken = tokenDef{Name: "Episode", Numbered: true}
+
+	// episodeCodeToken is the season and episodes as "S01E01", or
+	// "S01E01-E02" for a multi-episode file
+	episodeCodeToken = tokenDef{Name: "Episode Code"}
+
+	// absoluteToken is the absolute episode number anime is numbered by
+	absoluteToken = tokenDef{Name: "Absolute", Numbered: true}
+
+	episodeTokens = []tokenDef{seasonToken, episodeToken, episodeCodeToken, absoluteToken, {Name: "Episode Title"}}
+
+	// templateTokens are the tokens each naming template can use
+	templateTokens = map[string][]tokenDef{
+		TemplateMovieFolder:  movieTitleTokens,
+		TemplateMovieFile:    concatTokens(movieTitleTokens, fileTokens),
+		TemplateSeriesFolder: seriesTitleToken,
+		TemplateSeasonFolder: {seasonToken},
+		TemplateEpisodeFile:  concatTokens(seriesTitleToken, episodeTokens, fileTokens),
+	}
+)
+
+func concatTokens(lists ...[]tokenDef) []tokenDef {
+	var tokens []tokenDef
+	for _, list := range lists {
+		tokens = append(tokens, list...)
+	}
+	return tokens
+}
+
+// TemplateTokens returns the tokens each naming template can use, as written
+// in templates, for editors to offer
+func TemplateTokens() map[string][]string {
+	tokens := make(map[string][]string, len(templateTokens))
+	for template, defs := range templateTokens {
+		for _, def := range defs {
+			tokens[template] = append(tokens[template], "{"+def.Name+"}")
+			if def.Numbered {
+				tokens[template] = append(tokens[template], "{"+strings.ToLower(def.Name)+":00}")
+			}
+		}
+	}
+	return tokens
+}
+
+// ValidateTemplate checks that a naming template only uses tokens the
+// template supports, with valid formats
+func ValidateTemplate(template, format string) error {
+	defs, ok := templateTokens[template]
+	if !ok {
+		return fmt.Errorf("unknown naming template %q", template)
+	}
+	if strings.TrimSpace(format) == "" {
+		return fmt.Errorf("%s cannot be empty", template)
+	}
+
+	var unknown []string
+	for _, m := range namingToken.FindAllStringSubmatch(format, -1) {
+		def := findToken(defs, m[1])
+		switch {
+		case def == nil:
+			unknown = append(unknown, m[0])
+		case len(m[2]) > 0 && !def.Numbered:
+			return fmt.Errorf("%s: token %s doesn't take a format", template, m[0])
+		case def.Numbered && strings.Contains(m[0], ":") && !numberFormat.MatchString(m[2]):
+			return fmt.Errorf("%s: invalid number format in %s, use zeros like {%s:00}", template, m[0], strings.ToLower(def.Name))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s: unknown tokens %s", template, strings.Join(unknown, ", "))
+	}
+
+	// Braces left over belong to no token
+	if strings.ContainsAny(namingToken.ReplaceAllString(format, ""), "{}") {
+		return fmt.Errorf("%s: unbalanced braces", template)
+	}
+
+	// Every episode of a season would get the same name
+	if template == TemplateEpisodeFile && !usesToken(format, episodeToken.Name) && !usesToken(format, episodeCodeToken.Name) &&
+		!usesToken(format, absoluteToken.Name) {
+		return fmt.Errorf("%s must contain an episode number, like {episode:00}, {Episode Code} or {absolute:000}", template)
+	}
+	return nil
+}
+
+// findToken returns the definition of a token name, ignoring case
+func findToken(defs []tokenDef, name string) *tokenDef {
+	for i := range defs {
+		if strings.EqualFold(defs[i].Name, strings.TrimSpace(name)) {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+// usesToken reports whether a template uses a token, ignoring case
+func usesToken(format, name string) bool {
+	for _, m := range namingToken.FindAllStringSubmatch(format, -1) {
+		if strings.EqualFold(strings.TrimSpace(m[1]), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesMediaInfo reports whether a template uses media info tokens, which
+// need the file probed before it can be named
+func usesMediaInfo(format string) bool {
+	return strings.Contains(strings.ToLower(format), "{mediainfo ")
+}
+
+// tokenValue is what a token renders to. Numbered tokens render their numbers,
+// padded, with a range for several like the episodes of a multi-episode file.
+type tokenValue struct {
+	text    string
+	numbers []int
+}
+
+// tokenValues maps lower-case token names to their values
+type tokenValues map[string]tokenValue
+
+func (v tokenValues) setText(name string, value *string) {
+	if value != nil {
+		v[strings.ToLower(name)] = tokenValue{text: *value}
+	}
+}
+
+func (v tokenValues) setNumbers(name string, numbers ...int) {
+	v[strings.ToLower(name)] = tokenValue{numbers: numbers}
+}
+
+// renderTemplate replaces the tokens of a naming template. Tokens without a
+// value render empty, and the separators and brackets left around them are
+// cleaned up; tokens the template doesn't know are left as they are.
+func renderTemplate(format string, values tokenValues) string {
+	result := namingToken.ReplaceAllStringFunc(format, func(m string) string {
+		parts := namingToken.FindStringSubmatch(m)
+		value, ok := values[strings.ToLower(strings.TrimSpace(parts[1]))]
+		if !ok {
+			if findToken(allTokens, parts[1]) != nil {
+				return ""
+			}
+			return m
+		}
+		if value.numbers == nil {
+			return value.text
+		}
+
+		numbers := value.numbers
+		if len(numbers) > 2 {
+			numbers = []int{numbers[0], numbers[len(numbers)-1]}
+		}
+		padded := make([]string, len(numbers))
+		for i, n := range numbers {
+			padded[i] = padNumber(parts[2], n)
+		}
+		return strings.Join(padded, "-")
+	})
+
+	// Clean up double spaces, empty brackets and separators of missing values
+	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
+	result = regexp.MustCompile(`\[\s*\]`).ReplaceAllString(result, "")
+	result = regexp.MustCompile(`\(\s*\)`).ReplaceAllString(result, "")
+	result = regexp.MustCompile(`\s+-(\s+-)+\s+`).ReplaceAllString(result, " - ")
+	result = strings.ReplaceAll(result, " - -", " -")
+
+	return strings.Trim(strings.TrimSpace(result), " -")
+}
+
+// allTokens are the tokens of every template, which render empty without a
+// value rather than being left in the name
+var allTokens = concatTokens(movieTitleTokens, seriesTitleToken, fileTokens, episodeTokens)
+
+// movieTokenValues returns the token values of a movie import
+func movieTokenValues(req *ImportRequest) tokenValues {
+	values := tokenValues{}
+	values.setText("Movie Title", &req.Title)
+	if req.Year != nil {
+		year := fmt.Sprintf("%d", *req.Year)
+		values.setText("Release Year", &year)
+	}
+	values.addFileTokens(req)
+	return values
+}
+
+// episodeTokenValues returns the token values of an episode import
+func episodeTokenValues(req *ImportRequest) tokenValues {
+	values := tokenValues{}
+	values.setText("Series Title", &req.Title)
+	if req.Year != nil {
+		year := fmt.Sprintf("%d", *req.Year)
+		values.setText("Year", &year)
+	}
+	if req.Season != nil {
+		values.setNumbers("Season", *req.Season)
+	}
+	if req.Episode != nil {
+		if req.LastEpisode != nil && *req.LastEpisode > *req.Episode {
+			values.setNumbers("Episode", *req.Episode, *req.LastEpisode)
+		} else {
+			values.setNumbers("Episode", *req.Episode)
+		}
+	}
+	if req.Season != nil && req.Episode != nil {
+		code := fmt.Sprintf("S%02dE%02d", *req.Season, *req.Episode)
+		if req.LastEpisode != nil && *req.LastEpisode > *req.Episode {
+			code += fmt.Sprintf("-E%02d", *req.LastEpisode)
+		}
+		values.setText("Episode Code", &code)
+	}
+	if req.Absolute != nil && *req.Absolute > 0 {
+		values.setNumbers("Absolute", *req.Absolute)
+	}
+	values.setText("Episode Title", req.EpisodeTitle)
+	values.addFileTokens(req)
+	return values
+}
+
+// addFileTokens sets the quality, release group and media info tokens
+func (v tokenValues) addFileTokens(req *ImportRequest) {
+	v.setText("Quality", req.Quality)
+	v.setText("Release Group", req.ReleaseGroup)
+
+	info := req.MediaInfo
+	if info == nil {
+		return
+	}
+	if video := info.Video; video != nil {
+		codec := videoCodecName(video.Codec)
+		v.setText("MediaInfo VideoCodec", &codec)
+		if video.BitDepth > 0 {
+			depth := fmt.Sprintf("%dbit", video.BitDepth)
+			v.setText("MediaInfo VideoBitDepth", &depth)
+		}
+		if len(video.HDR) > 0 {
+			hdr := strings.Join(video.HDR, " ")
+			v.setText("MediaInfo VideoDynamicRange", &hdr)
+		}
+	}
+	if audio := mainAudioStream(info); audio != nil {
+		codec := audioCodecName(audio.Codec)
+		v.setText("MediaInfo AudioCodec", &codec)
+		if audio.Channels > 0 {
+			channels := audioChannels(audio.Channels)
+			v.setText("MediaInfo AudioChannels", &channels)
+		}
+	}
+}
+
+// mainAudioStream returns the default audio stream, else the first
+func mainAudioStream(info *mediainfo.MediaInfo) *mediainfo.AudioStream {
+	for i := range info.Audio {
+		if info.Audio[i].Default {
+			return &info.Audio[i]
+		}
+	}
+	if len(info.Audio) > 0 {
+		return &info.Audio[0]
+	}
+	return nil
+}
+
+// videoCodecName returns the name releases use for an ffprobe video codec
+func videoCodecName(codec string) string {
+	switch strings.ToLower(codec) {
+	case "h264":
+		return "h264"
+	case "hevc", "h265":
+		return "h265"
+	case "av1":
+		return "AV1"
+	case "mpeg2video":
+		return "MPEG2"
+	case "mpeg4":
+		return "XviD"
+	case "vc1":
+		return "VC1"
+	}
+	return codec
+}
+
+// audioCodecName returns the name releases use for an ffprobe audio codec
+func audioCodecName(codec string) string {
+	switch strings.ToLower(codec) {
+	case "truehd":
+		return "TrueHD"
+	case "opus":
+		return "Opus"
+	case "vorbis":
+		return "Vorbis"
+	}
+	return strings.ToUpper(codec)
+}
+
+// audioChannels returns a channel count the way releases write it, like 5.1
+func audioChannels(channels int) string {
+	if channels >= 6 {
+		return fmt.Sprintf("%d.1", channels-1)
+	}
+	return fmt.Sprintf("%d.0", channels)
+}
+
+// replaceSpaces replaces the spaces of a name with dots or underscores, taking
+// the dashes separating parts of the name with them
+func replaceSpaces(name, replacement string) string {
+	var sep string
+	switch replacement {
+	case SpaceReplacementDot:
+		sep = "."
+	case SpaceReplacementUnderscore:
+		sep = "_"
+	default:
+		return name
+	}
+	name = spaceRun.ReplaceAllString(strings.TrimSpace(name), sep)
+	for strings.Contains(name, sep+sep) {
+		name = strings.ReplaceAll(name, sep+sep, sep)
+	}
+	return name
+}
+
+// NamingSettings are the naming templates and the settings of how files are
+// named when they are imported
+type NamingSettings struct {
+	RenameMovies      bool   `json:"rename_movies"`
+	CreateMovieFolder bool   `json:"create_movie_folder"`
+	MovieFolderFormat string `json:"movie_folder_format"`
+	MovieFileFormat   string `json:"movie_file_format"`
+
+	RenameEpisodes     bool   `json:"rename_episodes"`
+	CreateSeriesFolder bool   `json:"create_series_folder"`
+	SeriesFolderFormat string `json:"series_folder_format"`
+	UseSeasonFolders   bool   `json:"use_season_folders"`
+	SeasonFolderFormat string `json:"season_folder_format"`
+	EpisodeFileFormat  string `json:"episode_file_format"`
+
+	ReplaceIllegalCharacters bool   `json:"replace_illegal_characters"`
+	ColonReplacement         string `json:"colon_replacement"` // delete, dash, space or spacedash
+	SpaceReplacement         string `json:"space_replacement"` // space, dot or underscore
+}
+
+// namingSettings returns the naming settings of an import configuration
+func namingSettings(config *ImportConfig) *NamingSettings {
+	return &NamingSettings{
+		RenameMovies:             config.RenameMovies,
+		CreateMovieFolder:        config.CreateMovieFolder,
+		MovieFolderFormat:        config.MovieFolderFormat,
+		MovieFileFormat:          config.MovieNamingFormat,
+		RenameEpisodes:           config.RenameEpisodes,
+		CreateSeriesFolder:       config.CreateSeriesFolder,
+		SeriesFolderFormat:       config.TVFolderFormat,
+		UseSeasonFolders:         config.TVUseSeasonFolders,
+		SeasonFolderFormat:       config.TVSeasonFolderFormat,
+		EpisodeFileFormat:        config.TVNamingFormat,
+		ReplaceIllegalCharacters: config.ReplaceIllegalCharacters,
+		ColonReplacement:         config.ColonReplacement,
+		SpaceReplacement:         config.SpaceReplacement,
+	}
+}
+
+// apply sets the naming settings on an import configuration
+func (n *NamingSettings) apply(config *ImportConfig) {
+	config.RenameMovies = n.RenameMovies
+	config.CreateMovieFolder = n.CreateMovieFolder
+	config.MovieFolderFormat = n.MovieFolderFormat
+	config.MovieNamingFormat = n.MovieFileFormat
+	config.RenameEpisodes = n.RenameEpisodes
+	config.CreateSeriesFolder = n.CreateSeriesFolder
+	config.TVFolderFormat = n.SeriesFolderFormat
+	config.TVUseSeasonFolders = n.UseSeasonFolders
+	config.TVSeasonFolderFormat = n.SeasonFolderFormat
+	config.TVNamingFormat = n.EpisodeFileFormat
+	config.ReplaceIllegalCharacters = n.ReplaceIllegalCharacters
+	config.ColonReplacement = n.ColonReplacement
+	config.SpaceReplacement = n.SpaceReplacement
+}
+
+// configValues maps the config keys the settings are stored under to their
+// values
+func (n *NamingSettings) configValues() map[string]any {
+	return map[string]any{
+		"downloads.rename_movies":              n.RenameMovies,
+		"downloads.create_movie_folder":        n.CreateMovieFolder,
+		"downloads.movie_folder_format":        n.MovieFolderFormat,
+		"downloads.movie_naming_format":        n.MovieFileFormat,
+		"downloads.rename_episodes":            n.RenameEpisodes,
+		"downloads.create_series_folder":       n.CreateSeriesFolder,
+		"downloads.tv_folder_format":           n.SeriesFolderFormat,
+		"downloads.tv_use_season_folders":      n.UseSeasonFolders,
+		"downloads.tv_season_folder_format":    n.SeasonFolderFormat,
+		"downloads.tv_naming_format":           n.EpisodeFileFormat,
+		"downloads.replace_illegal_characters": n.ReplaceIllegalCharacters,
+		"downloads.colon_replacement":          n.ColonReplacement,
+		"downloads.space_replacement":          n.SpaceReplacement,
+	}
+}
+
+// Validate normalizes the settings and checks every template and option
+func (n *NamingSettings) Validate() error {
+	templates := map[string]*string{
+		TemplateMovieFolder:  &n.MovieFolderFormat,
+		TemplateMovieFile:    &n.MovieFileFormat,
+		TemplateSeriesFolder: &n.SeriesFolderFormat,
+		TemplateSeasonFolder: &n.SeasonFolderFormat,
+		TemplateEpisodeFile:  &n.EpisodeFileFormat,
+	}
+	names := make([]string, 0, len(templates))
+	for name, format := range templates {
+		*format = strings.TrimSpace(*format)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ValidateTemplate(name, *templates[name]); err != nil {
+			return err
+		}
+	}
+
+	n.ColonReplacement = strings.ToLower(strings.TrimSpace(n.ColonReplacement))
+	switch n.ColonReplacement {
+	case "delete", "dash", "space", "spacedash":
+	default:
+		return fmt.Errorf("invalid colon replacement %q, must be delete, dash, space or spacedash", n.ColonReplacement)
+	}
+
+	n.SpaceReplacement = strings.ToLower(strings.TrimSpace(n.SpaceReplacement))
+	switch n.SpaceReplacement {
+	case "":
+		n.SpaceReplacement = SpaceReplacementSpace
+	case SpaceReplacementSpace, SpaceReplacementDot, SpaceReplacementUnderscore:
+	default:
+		return fmt.Errorf("invalid space replacement %q, must be space, dot or underscore", n.SpaceReplacement)
+	}
+	return nil
+}
+
+// NamingSettings returns the current naming settings
+func (s *Service) NamingSettings(ctx context.Context) (*NamingSettings, error) {
+	config, err := s.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return namingSettings(config), nil
+}
+
+// UpdateNamingSettings applies a JSON update to the current naming settings,
+// leaving out fields keeps their values, and saves them when they are valid
+func (s *Service) UpdateNamingSettings(ctx context.Context, update json.RawMessage) (*NamingSettings, error) {
+	settings, err := s.NamingSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(update, settings); err != nil {
+		return nil, &NamingError{Message: fmt.Sprintf("invalid naming settings: %v", err)}
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, &NamingError{Message: err.Error()}
+	}
+
+	for key, value := range settings.configValues() {
+		if err := s.configStore.Set(ctx, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// NamingError is returned for naming settings that are invalid
+type NamingError struct {
+	Message string
+}
+
+func (e *NamingError) Error() string {
+	return e.Message
+}
+
+// NamingSample is a media item naming templates are previewed against
+type NamingSample struct {
+	Title            string               `json:"title"`
+	Year             *int                 `json:"year,omitempty"`
+	Season           *int                 `json:"season,omitempty"`
+	Episodes         []int                `json:"episodes,omitempty"` // Several for a multi-episode file
+	Absolute         *int                 `json:"absolute,omitempty"` // Absolute episode number, for anime
+	EpisodeTitle     *string              `json:"episode_title,omitempty"`
+	Quality          *string              `json:"quality,omitempty"`
+	ReleaseGroup     *string              `json:"release_group,omitempty"`
+	OriginalFileName string               `json:"original_file_name,omitempty"` // Kept when renaming is off
+	MediaInfo        *mediainfo.MediaInfo `json:"media_info,omitempty"`
+}
+
+// NamingPreviewRequest asks for the paths naming settings produce. Settings
+// are applied over the saved ones, so unsaved changes can be tried out, and
+// samples replace the built-in ones.
+type NamingPreviewRequest struct {
+	Settings json.RawMessage `json:"settings,omitempty"`
+	Movie    *NamingSample   `json:"movie,omitempty"`
+	Episode  *NamingSample   `json:"episode,omitempty"`
+}
+
+// NamingPreview is the paths a movie and an episode would be imported to
+type NamingPreview struct {
+	Settings *NamingSettings    `json:"settings"`
+	Movie    *NamingPreviewPath `json:"movie"`
+	Episode  *NamingPreviewPath `json:"episode"`
+}
+
+// NamingPreviewPath is where a sample would be placed, relative to its root
+// folder
+type NamingPreviewPath struct {
+	Folders  []string `json:"folders"`
+	FileName string   `json:"file_name"`
+	Path     string   `json:"path"`
+}
+
+// Sample media the templates are previewed against when none is given
+func sampleMovie() *NamingSample {
+	year := 1999
+	quality, group := "Bluray-1080p", "GROUP"
+	return &NamingSample{
+		Title:            "The Matrix",
+		Year:             &year,
+		Quality:          &quality,
+		ReleaseGroup:     &group,
+		OriginalFileName: "The.Matrix.1999.1080p.BluRay.x264-GROUP.mkv",
+		MediaInfo: &mediainfo.MediaInfo{
+			Video: &mediainfo.VideoStream{Codec: "h264", Width: 1920, Height: 1080, BitDepth: 8},
+			Audio: []mediainfo.AudioStream{{Codec: "dts", Channels: 6, Default: true}},
+		},
+	}
+}
+
+func sampleEpisode() *NamingSample {
+	year, season, absolute := 2008, 1, 1
+	title, quality, group := "Pilot", "WEBDL-1080p", "GROUP"
+	return &NamingSample{
+		Title:            "Breaking Bad",
+		Year:             &year,
+		Season:           &season,
+		Episodes:         []int{1},
+		Absolute:         &absolute,
+		EpisodeTitle:     &title,
+		Quality:          &quality,
+		ReleaseGroup:     &group,
+		OriginalFileName: "Breaking.Bad.S01E01.1080p.WEB-DL.H264-GROUP.mkv",
+		MediaInfo: &mediainfo.MediaInfo{
+			Video: &mediainfo.VideoStream{Codec: "hevc", Width: 1920, Height: 1080, BitDepth: 10, HDR: []string{"HDR10"}},
+			Audio: []mediainfo.AudioStream{{Codec: "eac3", Channels: 6, Default: true}},
+		},
+	}
+}
+
+// importRequest converts a sample into the import request it would be
+func (n *NamingSample) importRequest(mediaType string) *ImportRequest {
+	req := &ImportRequest{
+		SourcePath:   filepath.Join("/downloads", filepath.Base(n.OriginalFileName)),
+		MediaType:    mediaType,
+		Title:        n.Title,
+		Year:         n.Year,
+		Season:       n.Season,
+		Absolute:     n.Absolute,
+		EpisodeTitle: n.EpisodeTitle,
+		Quality:      n.Quality,
+		ReleaseGroup: n.ReleaseGroup,
+		MediaInfo:    n.MediaInfo,
+	}
+	if n.OriginalFileName == "" {
+		req.SourcePath = "/downloads/sample.mkv"
+	}
+	if len(n.Episodes) > 0 {
+		episodes := append([]int(nil), n.Episodes...)
+		sort.Ints(episodes)
+		req.Episode = &episodes[0]
+		if len(episodes) > 1 {
+			req.LastEpisode = &episodes[len(episodes)-1]
+		}
+	}
+	return req
+}
+
+// PreviewNaming renders the naming settings against sample media and returns
+// the paths they would be imported to
+func (s *Service) PreviewNaming(ctx context.Context, req *NamingPreviewRequest) (*NamingPreview, error) {
+	config, err := s.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings := namingSettings(config)
+	if len(req.Settings) > 0 {
+		if err := json.Unmarshal(req.Settings, settings); err != nil {
+			return nil, &NamingError{Message: fmt.Sprintf("invalid naming settings: %v", err)}
+		}
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, &NamingError{Message: err.Error()}
+	}
+	settings.apply(config)
+
+	movie, episode := req.Movie, req.Episode
+	if movie == nil {
+		movie = sampleMovie()
+	}
+	if episode == nil {
+		episode = sampleEpisode()
+	}
+	if episode.Season == nil || len(episode.Episodes) == 0 {
+		return nil, &NamingError{Message: "the episode sample needs a season and at least one episode"}
+	}
+
+	preview := &NamingPreview{Settings: settings}
+	if preview.Movie, err = s.previewPath(movie.importRequest("movie"), config); err != nil {
+		return nil, err
+	}
+	if preview.Episode, err = s.previewPath(episode.importRequest("tv_episode"), config); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// previewPath computes where an import would be placed, relative to its root
+// folder
+func (s *Service) previewPath(req *ImportRequest, config *ImportConfig) (*NamingPreviewPath, error) {
+	target, err := s.importTarget(req, config, "")
+	if err != nil {
+		return nil, &NamingError{Message: err.Error()}
+	}
+	folders := target.Folders
+	if folders == nil {
+		folders = []string{}
+	}
+	return &NamingPreviewPath{
+		Folders:  folders,
+		FileName: filepath.Base(target.FinalPath),
+		Path:     target.FinalPath,
+	}, nil
+}
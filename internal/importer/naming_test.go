@@ -0,0 +1,251 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"go.uber.org/zap"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		format   string
+		wantErr  string
+	}{
+		{TemplateMovieFile, "{Movie Title} ({Release Year}) [{Quality}] - {Release Group}", ""},
+		{TemplateMovieFile, "{movie title} {MediaInfo VideoCodec} {MediaInfo AudioChannels}", ""},
+		{TemplateMovieFolder, "{Movie Title} ({Release Year})", ""},
+		{TemplateEpisodeFile, "{Series Title} - S{season:00}E{episode:00} - {Episode Title}", ""},
+		{TemplateEpisodeFile, "{Series Title} {Season}x{episode:2}", ""},
+		{TemplateSeasonFolder, "Season {season:00}", ""},
+		{TemplateEpisodeFile, "{Series Title} - {Episode Code} - {Episode Title}", ""},
+		{TemplateEpisodeFile, "{Series Title} - {absolute:000}", ""},
+		{TemplateMovieFile, "{Movie Title} {Episode Code}", "unknown tokens {Episode Code}"},
+		{TemplateSeriesFolder, "{Series Title} ({Year})", ""},
+		{TemplateMovieFile, "{Movie Titel} ({Release Year})", "unknown tokens {Movie Titel}"},
+		{TemplateMovieFolder, "{Movie Title} [{Quality}]", "unknown tokens {Quality}"},
+		{TemplateSeasonFolder, "{Series Title} Season {Season}", "unknown tokens {Series Title}"},
+		{TemplateMovieFile, "{Movie Title:00}", "doesn't take a format"},
+		{TemplateEpisodeFile, "S{season:xx}E{episode:00}", "invalid number format"},
+		{TemplateMovieFile, "{Movie Title} ({Release Year)", "unbalanced braces"},
+		{TemplateMovieFile, "{Movie Title}}", "unbalanced braces"},
+		{TemplateEpisodeFile, "{Series Title} - Season {Season}", "must contain an episode number"},
+		{TemplateMovieFile, "  ", "cannot be empty"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTemplate(tt.template, tt.format)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateTemplate(%s, %q) error = %v", tt.template, tt.format, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateTemplate(%s, %q) error = %v, want %q", tt.template, tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	year, season, episode, last, absolute := 1999, 1, 5, 6, 30
+	title, quality, group := "Pilot", "WEBDL-1080p", "GROUP"
+
+	tests := []struct {
+		name   string
+		format string
+		req    *ImportRequest
+		render func(string, *ImportRequest) string
+		want   string
+	}{
+		{
+			name:   "movie",
+			format: "{Movie Title} ({Release Year}) [{Quality}]-{Release Group}",
+			req:    &ImportRequest{Title: "The Matrix", Year: &year, Quality: &quality, ReleaseGroup: &group},
+			render: s.applyMovieNamingTemplate,
+			want:   "The Matrix (1999) [WEBDL-1080p]-GROUP",
+		},
+		{
+			name:   "movie without year or quality",
+			format: "{Movie Title} ({Release Year}) [{Quality}]",
+			req:    &ImportRequest{Title: "The Matrix"},
+			render: s.applyMovieNamingTemplate,
+			want:   "The Matrix",
+		},
+		{
+			name:   "padded episode",
+			format: "{Series Title} - S{season:00}E{episode:00} - {Episode Title}",
+			req:    &ImportRequest{Title: "Show", Season: &season, Episode: &episode, EpisodeTitle: &title},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - S01E05 - Pilot",
+		},
+		{
+			name:   "episode without title",
+			format: "{Series Title} - S{season:00}E{episode:00} - {Episode Title} - {Quality}",
+			req:    &ImportRequest{Title: "Show", Season: &season, Episode: &episode},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - S01E05",
+		},
+		{
+			name:   "multi-episode file",
+			format: "{Series Title} - S{season:00}E{episode:00}",
+			req:    &ImportRequest{Title: "Show", Season: &season, Episode: &episode, LastEpisode: &last},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - S01E05-06",
+		},
+		{
+			name:   "multi-episode code",
+			format: "{Series Title} - {Episode Code} - {Episode Title}",
+			req:    &ImportRequest{Title: "Show", Season: &season, Episode: &episode, LastEpisode: &last, EpisodeTitle: &title},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - S01E05-E06 - Pilot",
+		},
+		{
+			name:   "anime absolute number",
+			format: "{Series Title} - {absolute:000} - {Episode Code}",
+			req:    &ImportRequest{Title: "Show", Season: &season, Episode: &episode, Absolute: &absolute},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - 030 - S01E05",
+		},
+		{
+			name:   "absolute number missing",
+			format: "{Series Title} - {absolute:000} - {Episode Title}",
+			req:    &ImportRequest{Title: "Show", EpisodeTitle: &title},
+			render: s.applyTVNamingTemplate,
+			want:   "Show - Pilot",
+		},
+		{
+			name:   "series folder without year",
+			format: "{Series Title} ({Year})",
+			req:    &ImportRequest{Title: "Show"},
+			render: s.applyTVSeriesFolderTemplate,
+			want:   "Show",
+		},
+		{
+			name:   "media info",
+			format: "{Movie Title} {MediaInfo VideoCodec} {MediaInfo VideoBitDepth} {MediaInfo VideoDynamicRange} {MediaInfo AudioCodec} {MediaInfo AudioChannels}",
+			req: &ImportRequest{Title: "Movie", MediaInfo: &mediainfo.MediaInfo{
+				Video: &mediainfo.VideoStream{Codec: "hevc", BitDepth: 10, HDR: []string{"HDR10"}},
+				Audio: []mediainfo.AudioStream{{Codec: "aac", Channels: 2}, {Codec: "truehd", Channels: 8, Default: true}},
+			}},
+			render: s.applyMovieNamingTemplate,
+			want:   "Movie h265 10bit HDR10 TrueHD 7.1",
+		},
+		{
+			name:   "media info not probed",
+			format: "{Movie Title} [{MediaInfo VideoCodec} {MediaInfo AudioCodec}]",
+			req:    &ImportRequest{Title: "Movie"},
+			render: s.applyMovieNamingTemplate,
+			want:   "Movie",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.render(tt.format, tt.req); got != tt.want {
+				t.Errorf("render(%q) = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceSpaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement string
+		want        string
+	}{
+		{"Show - S01E05 - Pilot", SpaceReplacementDot, "Show.S01E05.Pilot"},
+		{"The Matrix (1999)", SpaceReplacementUnderscore, "The_Matrix_(1999)"},
+		{"The Matrix (1999)", SpaceReplacementSpace, "The Matrix (1999)"},
+		{"Mr. Robot - S01E01", SpaceReplacementDot, "Mr.Robot.S01E01"},
+	}
+
+	for _, tt := range tests {
+		if got := replaceSpaces(tt.name, tt.replacement); got != tt.want {
+			t.Errorf("replaceSpaces(%q, %q) = %q, want %q", tt.name, tt.replacement, got, tt.want)
+		}
+	}
+}
+
+func TestNamingSettingsValidate(t *testing.T) {
+	config := &ImportConfig{
+		MovieNamingFormat:    "{Movie Title} ({Release Year})",
+		MovieFolderFormat:    "{Movie Title} ({Release Year})",
+		TVNamingFormat:       "{Series Title} - S{season:00}E{episode:00}",
+		TVFolderFormat:       "{Series Title}",
+		TVSeasonFolderFormat: "Season {season:00}",
+		ColonReplacement:     "dash",
+	}
+
+	settings := namingSettings(config)
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if settings.SpaceReplacement != SpaceReplacementSpace {
+		t.Errorf("SpaceReplacement = %q, want the default", settings.SpaceReplacement)
+	}
+
+	settings.SpaceReplacement = "hyphen"
+	if err := settings.Validate(); err == nil {
+		t.Error("Validate() accepted an unknown space replacement")
+	}
+
+	settings = namingSettings(config)
+	settings.EpisodeFileFormat = "{Series Title} - {Episode Name}"
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "{Episode Name}") {
+		t.Errorf("Validate() error = %v, want the unknown token named", err)
+	}
+}
+
+func TestPreviewPath(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	config := &ImportConfig{
+		MovieNamingFormat:    "{Movie Title} ({Release Year}) {Quality}",
+		MovieFolderFormat:    "{Movie Title} ({Release Year})",
+		CreateMovieFolder:    true,
+		RenameMovies:         true,
+		TVNamingFormat:       "{Series Title} - S{season:00}E{episode:00} - {Episode Title}",
+		TVFolderFormat:       "{Series Title} ({Year})",
+		TVSeasonFolderFormat: "Season {season:00}",
+		TVUseSeasonFolders:   true,
+		CreateSeriesFolder:   true,
+		RenameEpisodes:       true,
+		SpaceReplacement:     SpaceReplacementDot,
+	}
+
+	movie, err := s.previewPath(sampleMovie().importRequest("movie"), config)
+	if err != nil {
+		t.Fatalf("previewPath() error = %v", err)
+	}
+	if want := "The Matrix (1999)/The.Matrix.(1999).Bluray-1080p.mkv"; movie.Path != want {
+		t.Errorf("movie Path = %q, want %q", movie.Path, want)
+	}
+
+	sample := sampleEpisode()
+	sample.Year = nil
+	sample.Episodes = []int{2, 1}
+	episode, err := s.previewPath(sample.importRequest("tv_episode"), config)
+	if err != nil {
+		t.Fatalf("previewPath() error = %v", err)
+	}
+	if want := "Breaking Bad/Season 01/Breaking.Bad.S01E01-02.Pilot.mkv"; episode.Path != want {
+		t.Errorf("episode Path = %q, want %q", episode.Path, want)
+	}
+	if len(episode.Folders) != 2 {
+		t.Errorf("Folders = %v, want series and season folders", episode.Folders)
+	}
+
+	// Without renaming the file keeps its name
+	config.RenameMovies = false
+	movie, err = s.previewPath(sampleMovie().importRequest("movie"), config)
+	if err != nil {
+		t.Fatalf("previewPath() error = %v", err)
+	}
+	if want := "The Matrix (1999)/The.Matrix.1999.1080p.BluRay.x264-GROUP.mkv"; movie.Path != want {
+		t.Errorf("movie Path = %q, want %q", movie.Path, want)
+	}
+}
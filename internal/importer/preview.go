@@ -0,0 +1,428 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+// Import actions
+const (
+	ImportActionMove     = "move"
+	ImportActionCopy     = "copy"
+	ImportActionHardlink = "hardlink"
+	ImportActionSkip     = "skip"
+)
+
+// PreviewDecision describes what an import would do with one source file. The
+// same structure is accepted back to execute the import, after the user has had
+// the chance to change the matches.
+type PreviewDecision struct {
+	SourcePath      string   `json:"source_path"`
+	Size            int64    `json:"size"`
+	MediaType       string   `json:"media_type,omitempty"`
+	MediaItemID     *int64   `json:"media_item_id,omitempty"`
+	MatchedTitle    string   `json:"matched_title,omitempty"`
+	Title           string   `json:"title,omitempty"`
+	Year            *int     `json:"year,omitempty"`
+	Season          *int     `json:"season,omitempty"`
+	Episode         *int     `json:"episode,omitempty"`
+	EpisodeTitle    *string  `json:"episode_title,omitempty"`
+	Artist          *string  `json:"artist,omitempty"`
+	Album           *string  `json:"album,omitempty"`
+	Track           *int     `json:"track,omitempty"`
+	Quality         *string  `json:"quality,omitempty"`
+	DestinationPath string   `json:"destination_path,omitempty"`
+	Action          string   `json:"action"`
+	TransferMode    string   `json:"transfer_mode,omitempty"` // Requested transfer mode, which Action resolves
+	Disposable      bool     `json:"disposable,omitempty"`
+	Force           bool     `json:"force,omitempty"` // Replace existing files even if this isn't an upgrade
+	Warnings        []string `json:"warnings"`
+}
+
+// ImportPreview is the result of a dry-run import
+type ImportPreview struct {
+	SourcePath string            `json:"source_path"`
+	Decisions  []PreviewDecision `json:"decisions"`
+}
+
+// ImportRequest converts a confirmed decision back into an import request that
+// places the file at exactly the previewed destination
+func (d *PreviewDecision) ImportRequest() *ImportRequest {
+	return &ImportRequest{
+		SourcePath:      d.SourcePath,
+		MediaType:       d.MediaType,
+		MediaItemID:     d.MediaItemID,
+		Title:           d.Title,
+		Year:            d.Year,
+		Season:          d.Season,
+		Episode:         d.Episode,
+		EpisodeTitle:    d.EpisodeTitle,
+		Artist:          d.Artist,
+		Album:           d.Album,
+		Track:           d.Track,
+		Quality:         d.Quality,
+		Metadata:        make(map[string]interface{}),
+		Force:           d.Force,
+		DestinationPath: d.DestinationPath,
+		TransferMode:    d.TransferMode,
+		Disposable:      d.Disposable,
+	}
+}
+
+// Preview works out what Import would do with every media file under the source
+// path without touching the filesystem
+func (s *Service) Preview(ctx context.Context, req *ImportRequest) (*ImportPreview, error) {
+	config, err := s.loadConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	files, err := collectMediaFiles(req.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no media files found in %s", req.SourcePath)
+	}
+
+	var target *generated.MediaItem
+	if req.MediaItemID != nil {
+		item, err := s.queries.GetMediaItem(ctx, *req.MediaItemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get media item: %w", err)
+		}
+		target = &item
+	}
+
+	preview := &ImportPreview{
+		SourcePath: req.SourcePath,
+		Decisions:  make([]PreviewDecision, 0, len(files)),
+	}
+
+	// A movie, single episode or track is one file, the largest one; the rest are samples or extras
+	single := req.MediaType == "movie" || (target != nil && isSingleFile(target.Kind))
+	detector := quality.NewDetector()
+
+	for i, file := range files {
+		if single && i > 0 {
+			preview.Decisions = append(preview.Decisions, PreviewDecision{
+				SourcePath: file.path,
+				Size:       file.size,
+				Action:     ImportActionSkip,
+				Warnings:   []string{"not the main media file"},
+			})
+			continue
+		}
+
+		decision := s.previewFile(ctx, req, target, file, config, detector)
+		preview.Decisions = append(preview.Decisions, decision)
+	}
+
+	return preview, nil
+}
+
+// previewFile matches one media file to a media item and computes its destination
+func (s *Service) previewFile(ctx context.Context, req *ImportRequest, target *generated.MediaItem, file mediaFile, config *ImportConfig, detector *quality.Detector) PreviewDecision {
+	decision := PreviewDecision{
+		SourcePath:   file.path,
+		Size:         file.size,
+		Action:       ImportActionSkip,
+		TransferMode: req.TransferMode,
+		Disposable:   req.Disposable,
+		Warnings:     []string{},
+	}
+
+	fileReq := *req
+	fileReq.SourcePath = file.path
+	if fileReq.Quality == nil {
+		if info := detector.DetectQuality(filepath.Base(file.path)); info.QualityName != "Unknown" {
+			fileReq.Quality = &info.QualityName
+		}
+	}
+
+	parsed := library.ParseFilename(file.path)
+	if parsed != nil {
+		if fileReq.MediaType == "" {
+			fileReq.MediaType = parsed.Kind
+		}
+		if fileReq.Title == "" {
+			fileReq.Title = parsed.Title
+		}
+		if fileReq.Year == nil && parsed.Year != 0 {
+			fileReq.Year = &parsed.Year
+		}
+		if fileReq.Season == nil && parsed.Season != 0 {
+			fileReq.Season = &parsed.Season
+		}
+		if fileReq.Episode == nil && parsed.Episode != 0 {
+			fileReq.Episode = &parsed.Episode
+		}
+	}
+	if isMusicType(fileReq.MediaType) && (target == nil || target.Kind != "music_album") {
+		fillTrackFromFile(&fileReq, "")
+	}
+
+	switch {
+	case target != nil && target.Kind == "music_album":
+		// An album resolves to the track named by the file, or a new one
+		if err := s.matchTrack(ctx, target, &fileReq); err != nil {
+			decision.Warnings = append(decision.Warnings, err.Error())
+			fillDecision(&decision, &fileReq)
+			return decision
+		}
+
+	case target != nil && !isSingleFile(target.Kind):
+		// A season or series resolves to the episode named by the file
+		if parsed == nil || (parsed.Season == 0 && parsed.Episode == 0) {
+			decision.Warnings = append(decision.Warnings, "could not parse season and episode from file name")
+			return decision
+		}
+		fileReq.Season, fileReq.Episode = &parsed.Season, &parsed.Episode
+
+		if err := s.matchEpisode(ctx, target, &fileReq); err != nil {
+			decision.Warnings = append(decision.Warnings, err.Error())
+			fillDecision(&decision, &fileReq)
+			return decision
+		}
+	}
+
+	if fileReq.MediaType == "music_album" {
+		decision.Warnings = append(decision.Warnings, "track not found on album; a new one will be created")
+	} else if fileReq.MediaItemID != nil {
+		if item, err := s.queries.GetMediaItem(ctx, *fileReq.MediaItemID); err == nil {
+			decision.MatchedTitle = item.Title
+		}
+	} else {
+		decision.Warnings = append(decision.Warnings, "no media item matched; a new one will be created")
+	}
+
+	fillDecision(&decision, &fileReq)
+	if fileReq.Title == "" {
+		decision.Warnings = append(decision.Warnings, "could not determine title")
+		return decision
+	}
+
+	libraryPath, _, err := s.getLibraryPath(ctx, &fileReq)
+	if err != nil {
+		decision.Warnings = append(decision.Warnings, fmt.Sprintf("failed to get library path: %v", err))
+		return decision
+	}
+
+	importTarget, err := s.importTarget(&fileReq, config, libraryPath)
+	if err != nil {
+		decision.Warnings = append(decision.Warnings, err.Error())
+		return decision
+	}
+	decision.DestinationPath = importTarget.FinalPath
+
+	switch mode := transferMode(&fileReq, config); mode {
+	case TransferAuto:
+		decision.Action = ImportActionHardlink
+	default:
+		decision.Action = mode
+	}
+
+	if _, err := os.Stat(importTarget.FinalPath); err == nil {
+		decision.Warnings = append(decision.Warnings, "destination file already exists and would be overwritten")
+	}
+
+	if fileReq.MediaItemID != nil {
+		decision.Warnings = append(decision.Warnings, s.existingFileWarnings(ctx, &fileReq, config)...)
+	}
+
+	return decision
+}
+
+// matchEpisode resolves the episode of a season or series that a file belongs to
+// and fills in the series details needed by the naming templates
+func (s *Service) matchEpisode(ctx context.Context, target *generated.MediaItem, req *ImportRequest) error {
+	series := target
+	var seasons []generated.MediaItem
+	if target.Kind == "tv_season" || target.Kind == "season" {
+		seasons = []generated.MediaItem{*target}
+		if target.ParentID != nil {
+			if parent, err := s.queries.GetMediaItem(ctx, *target.ParentID); err == nil {
+				series = &parent
+			}
+		}
+	} else {
+		children, err := s.queries.ListChildMediaItems(ctx, &target.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list seasons: %w", err)
+		}
+		seasons = children
+	}
+
+	req.MediaType = "tv_episode"
+	req.Title = series.Title
+	req.Year = nil
+	if series.Year != nil {
+		year := int(*series.Year)
+		req.Year = &year
+	}
+	req.MediaItemID = nil
+
+	for _, season := range seasons {
+		if n, ok := metadataInt(season.Metadata, "season", "season_number"); ok && n != *req.Season {
+			continue
+		}
+
+		episodes, err := s.queries.ListChildMediaItems(ctx, &season.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list episodes: %w", err)
+		}
+		for _, episode := range episodes {
+			seasonNum, _ := metadataInt(episode.Metadata, "season", "season_number")
+			episodeNum, _ := metadataInt(episode.Metadata, "episode", "episode_number")
+			if seasonNum == *req.Season && episodeNum == *req.Episode {
+				id := episode.ID
+				title := episode.Title
+				req.MediaItemID = &id
+				req.EpisodeTitle = &title
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("episode S%02dE%02d not found in library", *req.Season, *req.Episode)
+}
+
+// existingFileWarnings describes what an import would do to the files a media
+// item already has
+func (s *Service) existingFileWarnings(ctx context.Context, req *ImportRequest, config *ImportConfig) []string {
+	upgrade, err := s.planUpgrade(ctx, req, config)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if upgrade == nil {
+		return nil
+	}
+
+	warnings := make([]string, 0, len(upgrade.Files))
+	for _, file := range upgrade.Files {
+		warnings = append(warnings, fmt.Sprintf("would replace existing file (%s -> %s): %s", upgrade.From, upgrade.To, file.Path))
+	}
+	return warnings
+}
+
+// fillDecision copies the resolved attributes of a request into a decision
+func fillDecision(decision *PreviewDecision, req *ImportRequest) {
+	decision.MediaType = req.MediaType
+	decision.MediaItemID = req.MediaItemID
+	decision.Title = req.Title
+	decision.Year = req.Year
+	decision.Season = req.Season
+	decision.Episode = req.Episode
+	decision.EpisodeTitle = req.EpisodeTitle
+	decision.Artist = req.Artist
+	decision.Album = req.Album
+	decision.Track = req.Track
+	decision.Quality = req.Quality
+}
+
+// mediaFile is a media file found under an import source path
+type mediaFile struct {
+	path string
+	size int64
+}
+
+// collectMediaFiles returns the media files under a path, largest first
+func collectMediaFiles(sourcePath string) ([]mediaFile, error) {
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("source path does not exist: %s", sourcePath)
+	}
+	if !info.IsDir() {
+		return []mediaFile{{path: sourcePath, size: info.Size()}}, nil
+	}
+
+	var files []mediaFile
+	err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() || !library.IsSupportedMediaFile(path) {
+			return nil
+		}
+		if strings.Contains(strings.ToLower(filepath.Base(path)), "sample") {
+			return nil
+		}
+		files = append(files, mediaFile{path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk source path: %w", err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].size > files[j].size
+	})
+
+	return files, nil
+}
+
+// isSingleFile reports whether a media kind is imported as a single file
+func isSingleFile(kind string) bool {
+	switch kind {
+	case "movie", "episode", "tv_episode", "music_track":
+		return true
+	}
+	return false
+}
+
+// metadataInt reads the first numeric value found under the given keys
+func metadataInt(metadata []byte, keys ...string) (int, bool) {
+	if len(metadata) == 0 {
+		return 0, false
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(metadata, &m); err != nil {
+		return 0, false
+	}
+	for _, key := range keys {
+		if v, ok := m[key].(float64); ok {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// qualityRank orders detected qualities by resolution, then source, then remux
+func qualityRank(info *quality.DetectedQualityInfo) int {
+	if info == nil {
+		return 0
+	}
+
+	rank := 0
+	if info.Resolution != nil {
+		rank = *info.Resolution * 100
+	}
+	if info.Source != nil {
+		switch *info.Source {
+		case "BLURAY":
+			rank += 50
+		case "WEBDL":
+			rank += 40
+		case "WEBRIP":
+			rank += 30
+		case "HDTV":
+			rank += 20
+		case "DVD", "TV":
+			rank += 10
+		}
+	}
+	if info.IsRemux {
+		rank += 5
+	}
+
+	return rank
+}
@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestImportTarget(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	config := &ImportConfig{
+		TVNamingFormat:       "{Series Title} - S{season:2}E{episode:2}",
+		TVFolderFormat:       "{Series Title}",
+		TVSeasonFolderFormat: "Season {season:2}",
+		TVUseSeasonFolders:   true,
+		CreateSeriesFolder:   true,
+		RenameEpisodes:       true,
+	}
+	season, episode := 1, 5
+
+	target, err := s.importTarget(&ImportRequest{
+		SourcePath: "/downloads/show.s01e05.mkv",
+		MediaType:  "tv_episode",
+		Title:      "Show",
+		Season:     &season,
+		Episode:    &episode,
+	}, config, "/tv")
+	if err != nil {
+		t.Fatalf("importTarget() error = %v", err)
+	}
+	if want := "/tv/Show/Season 01/Show - S01E05.mkv"; target.FinalPath != want {
+		t.Errorf("FinalPath = %q, want %q", target.FinalPath, want)
+	}
+	if len(target.Folders) != 2 {
+		t.Errorf("Folders = %v, want series and season folders", target.Folders)
+	}
+
+	// A confirmed preview places the file exactly where it was previewed
+	target, err = s.importTarget(&ImportRequest{
+		SourcePath:      "/downloads/show.s01e05.mkv",
+		MediaType:       "tv_episode",
+		DestinationPath: "/tv/Other/Show S01E05.mkv",
+	}, config, "/tv")
+	if err != nil {
+		t.Fatalf("importTarget() error = %v", err)
+	}
+	if target.FinalPath != "/tv/Other/Show S01E05.mkv" || target.Dir != "/tv/Other" || target.FileName != "Show S01E05" {
+		t.Errorf("importTarget() = %+v, want the explicit destination", target)
+	}
+}
+
+// A title filled into the naming templates can't place a file outside the
+// library folder, by a path or through a symlink in it
+func TestImportTargetStaysInLibrary(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	config := &ImportConfig{
+		MovieNamingFormat: "{Movie Title}",
+		MovieFolderFormat: "{Movie Title}",
+		CreateMovieFolder: true,
+		RenameMovies:      true,
+	}
+	library := t.TempDir()
+
+	for _, title := range []string{"../../etc/cron.d/evil", "a/../../../evil"} {
+		req := &ImportRequest{SourcePath: "/downloads/movie.mkv", MediaType: "movie", Title: title}
+		if target, err := s.importTarget(req, config, library); err == nil {
+			t.Errorf("title %q imported to %s", title, target.FinalPath)
+		}
+	}
+
+	if err := os.Symlink(t.TempDir(), filepath.Join(library, "Linked")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	req := &ImportRequest{SourcePath: "/downloads/movie.mkv", MediaType: "movie", Title: "Linked"}
+	if target, err := s.importTarget(req, config, library); err == nil {
+		t.Errorf("import through a symlink placed at %s", target.FinalPath)
+	}
+
+	req.Title = "Movie\x00"
+	target, err := s.importTarget(req, config, library)
+	if err != nil {
+		t.Fatalf("importTarget() error = %v", err)
+	}
+	if want := filepath.Join(library, "Movie", "Movie.mkv"); target.FinalPath != want {
+		t.Errorf("FinalPath = %q, want %q", target.FinalPath, want)
+	}
+}
+
+func TestCollectMediaFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("show.s01e01.mkv", 10)
+	write("show.s01e02.mkv", 20)
+	write("show.s01e02.sample.mkv", 5)
+	write("show.nfo", 1)
+
+	files, err := collectMediaFiles(dir)
+	if err != nil {
+		t.Fatalf("collectMediaFiles() error = %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("collectMediaFiles() found %d files, want 2", len(files))
+	}
+	if filepath.Base(files[0].path) != "show.s01e02.mkv" {
+		t.Errorf("first file = %s, want the largest", files[0].path)
+	}
+}
@@ -0,0 +1,519 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// ImportJobStatus is the state of a queued import
+type ImportJobStatus string
+
+const (
+	ImportJobQueued    ImportJobStatus = "queued"
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobRetrying  ImportJobStatus = "retrying" // Failed on a transient error and waits for its next attempt
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobFailed    ImportJobStatus = "failed"
+)
+
+const (
+	defaultImportConcurrency = 2
+	defaultImportMaxAttempts = 5
+
+	// queuePollInterval is how often the queue looks for jobs whose retry is due
+	// when nothing wakes it up sooner
+	queuePollInterval = 15 * time.Second
+
+	// Retries of transient failures back off from the first delay, doubling up
+	// to the last
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 15 * time.Minute
+
+	// finishedJobRetention is how long completed and failed jobs are kept for
+	// their status to be looked up
+	finishedJobRetention = 7 * 24 * time.Hour
+)
+
+// ErrImportJobNotFound is returned when an import job doesn't exist
+var ErrImportJobNotFound = errors.New("import job not found")
+
+// ImportJob is an import waiting in, or processed by, the import queue
+type ImportJob struct {
+	ID            int64           `json:"id"`
+	DownloadID    *string         `json:"download_id,omitempty"`
+	SourcePath    string          `json:"source_path"`
+	MediaItemID   *int64          `json:"media_item_id,omitempty"`
+	Title         string          `json:"title"`
+	Status        ImportJobStatus `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"` // Of queued and retrying jobs
+	LastError     *string         `json:"last_error,omitempty"`
+	Result        *ImportResult   `json:"result,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	FinishedAt    *time.Time      `json:"finished_at,omitempty"`
+
+	// Request is the import the job runs
+	Request *ImportRequest `json:"-"`
+}
+
+// Pending reports whether the job is still waiting to run or running
+func (j *ImportJob) Pending() bool {
+	return j.Status != ImportJobCompleted && j.Status != ImportJobFailed
+}
+
+// JobRunner runs the imports of the queue
+type JobRunner interface {
+	// RunImport imports the files of a job
+	RunImport(ctx context.Context, job *ImportJob) (*ImportResult, error)
+	// ImportFailed is called once a job has failed for good, to leave its
+	// files for a manual import
+	ImportFailed(ctx context.Context, job *ImportJob, err error)
+}
+
+// ImportJobFilter narrows down the jobs listed
+type ImportJobFilter struct {
+	Status     ImportJobStatus
+	DownloadID string
+	Limit      int
+}
+
+// jobOutcome is how a job finished, handed to the callers waiting for it
+type jobOutcome struct {
+	job *ImportJob
+	err error
+}
+
+// Queue runs imports in the background with a limited concurrency. Jobs are
+// stored so they survive restarts, and failures that are likely to go away on
+// their own, like an I/O error on a network share, are retried with backoff
+// before the files are left for a manual import.
+type Queue struct {
+	db     *pgxpool.Pool
+	config *configstore.Store
+	runner JobRunner
+	logger *zap.Logger
+	wake   chan struct{}
+
+	mu      sync.Mutex
+	running int
+	waiters map[int64][]chan jobOutcome
+}
+
+// NewQueue creates an import queue whose jobs are run by the runner
+func NewQueue(db *pgxpool.Pool, config *configstore.Store, runner JobRunner, logger *zap.Logger) *Queue {
+	return &Queue{
+		db:      db,
+		config:  config,
+		runner:  runner,
+		logger:  logger.With(zap.String("component", "import-queue")),
+		wake:    make(chan struct{}, 1),
+		waiters: make(map[int64][]chan jobOutcome),
+	}
+}
+
+// Start processes the queue until the context is done. Jobs interrupted by a
+// restart are queued again.
+func (q *Queue) Start(ctx context.Context) {
+	result, err := q.db.Exec(ctx, `
+		UPDATE import_jobs
+		SET status = 'queued', next_attempt_at = NOW()
+		WHERE status = 'running'
+	`)
+	if err != nil {
+		q.logger.Error("failed to requeue interrupted imports", zap.Error(err))
+	} else if result.RowsAffected() > 0 {
+		q.logger.Info("requeued interrupted imports", zap.Int64("count", result.RowsAffected()))
+	}
+
+	go q.run(ctx)
+}
+
+// run dispatches jobs whenever one is enqueued, one finishes or a retry may be due
+func (q *Queue) run(ctx context.Context) {
+	poll := time.NewTicker(queuePollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	q.prune(ctx)
+	for {
+		q.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-poll.C:
+		case <-prune.C:
+			q.prune(ctx)
+		}
+	}
+}
+
+// signal wakes the dispatcher without blocking
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// concurrency returns how many imports may run at the same time
+func (q *Queue) concurrency(ctx context.Context) int {
+	n := q.config.GetIntOrDefault(ctx, "downloads.import_concurrency", defaultImportConcurrency)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// maxAttempts returns how often a job failing on transient errors is tried
+func (q *Queue) maxAttempts(ctx context.Context) int {
+	n := q.config.GetIntOrDefault(ctx, "downloads.import_max_attempts", defaultImportMaxAttempts)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// dispatch starts due jobs until the concurrency limit is reached
+func (q *Queue) dispatch(ctx context.Context) {
+	limit := q.concurrency(ctx)
+	for {
+		q.mu.Lock()
+		free := limit - q.running
+		q.mu.Unlock()
+		if free <= 0 {
+			return
+		}
+
+		job, err := q.claim(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				q.logger.Error("failed to claim import job", zap.Error(err))
+			}
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		q.mu.Lock()
+		q.running++
+		q.mu.Unlock()
+		go q.execute(context.WithoutCancel(ctx), job)
+	}
+}
+
+// claim marks the next due job running and returns it, or nil when no job is due
+func (q *Queue) claim(ctx context.Context) (*ImportJob, error) {
+	row := q.db.QueryRow(ctx, `
+		UPDATE import_jobs
+		SET status = 'running',
+		    attempts = attempts + 1,
+		    started_at = COALESCE(started_at, NOW())
+		WHERE id = (
+		    SELECT id FROM import_jobs
+		    WHERE status IN ('queued', 'retrying') AND next_attempt_at <= NOW()
+		    ORDER BY next_attempt_at, id
+		    LIMIT 1
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+importJobColumns)
+	job, err := scanImportJob(row, true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// execute runs a claimed job and records how it went. A transient failure is
+// retried later unless the job has no attempts left.
+func (q *Queue) execute(ctx context.Context, job *ImportJob) {
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.mu.Unlock()
+		q.signal()
+	}()
+
+	maxAttempts := q.maxAttempts(ctx)
+	retryable := job.Attempts < maxAttempts
+	// Failures that are retried aren't reported until the last attempt
+	job.Request.retryable = retryable
+
+	logger := q.logger.With(zap.Int64("job_id", job.ID), zap.String("source", job.SourcePath), zap.Int("attempt", job.Attempts))
+	result, err := q.runner.RunImport(ctx, job)
+	switch {
+	case err == nil:
+		q.finish(ctx, job, ImportJobCompleted, result, nil)
+		logger.Info("import job completed", zap.String("final_path", result.FinalPath))
+	case retryable && IsTransient(err):
+		delay := retryDelay(job.Attempts)
+		q.retry(ctx, job, err, delay)
+		logger.Warn("import job failed on a transient error, retrying", zap.Duration("delay", delay), zap.Error(err))
+		return
+	default:
+		q.finish(ctx, job, ImportJobFailed, nil, err)
+		logger.Error("import job failed", zap.Error(err))
+		q.runner.ImportFailed(ctx, job, err)
+	}
+
+	q.mu.Lock()
+	waiters := q.waiters[job.ID]
+	delete(q.waiters, job.ID)
+	q.mu.Unlock()
+	for _, waiter := range waiters {
+		waiter <- jobOutcome{job: job, err: err}
+	}
+}
+
+// finish records the final status of a job
+func (q *Queue) finish(ctx context.Context, job *ImportJob, status ImportJobStatus, result *ImportResult, jobErr error) {
+	now := time.Now()
+	job.Status = status
+	job.Result = result
+	job.FinishedAt = &now
+	job.NextAttemptAt = nil
+	var resultJSON []byte
+	if result != nil {
+		resultJSON, _ = json.Marshal(result)
+	}
+	if jobErr != nil {
+		message := jobErr.Error()
+		job.LastError = &message
+	}
+
+	_, err := q.db.Exec(ctx, `
+		UPDATE import_jobs
+		SET status = $2, result = $3, last_error = COALESCE($4, last_error), finished_at = $5
+		WHERE id = $1
+	`, job.ID, status, resultJSON, job.LastError, now)
+	if err != nil {
+		q.logger.Error("failed to record import job status", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// retry schedules the next attempt of a job
+func (q *Queue) retry(ctx context.Context, job *ImportJob, jobErr error, delay time.Duration) {
+	next := time.Now().Add(delay)
+	message := jobErr.Error()
+	job.Status = ImportJobRetrying
+	job.NextAttemptAt = &next
+	job.LastError = &message
+
+	_, err := q.db.Exec(ctx, `
+		UPDATE import_jobs
+		SET status = 'retrying', next_attempt_at = $2, last_error = $3
+		WHERE id = $1
+	`, job.ID, next, message)
+	if err != nil {
+		q.logger.Error("failed to schedule import job retry", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// prune removes jobs that finished longer ago than they are kept
+func (q *Queue) prune(ctx context.Context) {
+	result, err := q.db.Exec(ctx, `DELETE FROM import_jobs WHERE finished_at < $1`, time.Now().Add(-finishedJobRetention))
+	if err != nil {
+		if ctx.Err() == nil {
+			q.logger.Warn("failed to prune import jobs", zap.Error(err))
+		}
+		return
+	}
+	if result.RowsAffected() > 0 {
+		q.logger.Debug("pruned finished import jobs", zap.Int64("count", result.RowsAffected()))
+	}
+}
+
+// Enqueue adds an import to the queue and returns its job right away
+func (q *Queue) Enqueue(ctx context.Context, downloadID string, req *ImportRequest) (*ImportJob, error) {
+	job, err := q.insert(ctx, downloadID, req)
+	if err != nil {
+		return nil, err
+	}
+	q.signal()
+	return job, nil
+}
+
+// Run adds an import to the queue and waits until it completed or failed for
+// good, returning its result or the error of its last attempt. The job keeps
+// running when the context is done before.
+func (q *Queue) Run(ctx context.Context, downloadID string, req *ImportRequest) (*ImportResult, error) {
+	waiter := make(chan jobOutcome, 1)
+
+	// The job can't finish before its waiter is registered, since finishing
+	// takes the lock too
+	q.mu.Lock()
+	job, err := q.insert(ctx, downloadID, req)
+	if err != nil {
+		q.mu.Unlock()
+		return nil, err
+	}
+	q.waiters[job.ID] = append(q.waiters[job.ID], waiter)
+	q.mu.Unlock()
+	q.signal()
+
+	select {
+	case <-ctx.Done():
+		q.removeWaiter(job.ID, waiter)
+		return nil, fmt.Errorf("import job %d: %w", job.ID, ctx.Err())
+	case outcome := <-waiter:
+		return outcome.job.Result, outcome.err
+	}
+}
+
+// removeWaiter stops delivering the outcome of a job to a waiter
+func (q *Queue) removeWaiter(id int64, waiter chan jobOutcome) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiters := q.waiters[id]
+	for i, w := range waiters {
+		if w == waiter {
+			q.waiters[id] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(q.waiters[id]) == 0 {
+		delete(q.waiters, id)
+	}
+}
+
+// insert stores a new queued job
+func (q *Queue) insert(ctx context.Context, downloadID string, req *ImportRequest) (*ImportJob, error) {
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode import request: %w", err)
+	}
+	var download *string
+	if downloadID != "" {
+		download = &downloadID
+	}
+
+	row := q.db.QueryRow(ctx, `
+		INSERT INTO import_jobs (download_id, source_path, media_item_id, request)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+importJobColumns, download, req.SourcePath, req.MediaItemID, request)
+	job, err := scanImportJob(row, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue import: %w", err)
+	}
+	return job, nil
+}
+
+// Get returns a job of the queue
+func (q *Queue) Get(ctx context.Context, id int64) (*ImportJob, error) {
+	row := q.db.QueryRow(ctx, `SELECT `+importJobColumns+` FROM import_jobs WHERE id = $1`, id)
+	job, err := scanImportJob(row, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, nil
+}
+
+// List returns the jobs of the queue, newest first
+func (q *Queue) List(ctx context.Context, filter ImportJobFilter) ([]*ImportJob, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := q.db.Query(ctx, `
+		SELECT `+importJobColumns+`
+		FROM import_jobs
+		WHERE ($1 = '' OR status = $1)
+		  AND ($2 = '' OR download_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, string(filter.Status), filter.DownloadID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*ImportJob{}
+	for rows.Next() {
+		job, err := scanImportJob(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+const importJobColumns = `id, download_id, source_path, media_item_id, request, status, attempts,
+	next_attempt_at, last_error, result, created_at, updated_at, started_at, finished_at`
+
+// scanImportJob scans a row of importJobColumns. The request is only decoded
+// for jobs that are going to run; the others take their title from it.
+func scanImportJob(row pgx.Row, withRequest bool) (*ImportJob, error) {
+	var job ImportJob
+	var status string
+	var request, result []byte
+	var nextAttemptAt time.Time
+	err := row.Scan(&job.ID, &job.DownloadID, &job.SourcePath, &job.MediaItemID, &request, &status, &job.Attempts,
+		&nextAttemptAt, &job.LastError, &result, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Status = ImportJobStatus(status)
+	if job.Pending() {
+		job.NextAttemptAt = &nextAttemptAt
+	}
+
+	if withRequest {
+		job.Request = &ImportRequest{}
+		if err := json.Unmarshal(request, job.Request); err != nil {
+			return nil, fmt.Errorf("failed to decode import request of job %d: %w", job.ID, err)
+		}
+		job.Title = job.Request.Title
+	} else {
+		var summary struct{ Title string }
+		if err := json.Unmarshal(request, &summary); err == nil {
+			job.Title = summary.Title
+		}
+	}
+	if len(result) > 0 {
+		job.Result = &ImportResult{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode import result of job %d: %w", job.ID, err)
+		}
+	}
+	return &job, nil
+}
+
+// IsTransient reports whether an import failure is likely to go away when the
+// import is tried again: I/O errors and stale handles of network shares,
+// resources that are temporarily unavailable, and files still being written
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrFileNotStable) ||
+		errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ESTALE) ||
+		errors.Is(err, syscall.EAGAIN)
+}
+
+// retryDelay returns how long to wait before the next attempt after the given
+// attempt failed on a transient error
+func retryDelay(attempt int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
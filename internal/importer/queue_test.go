@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "write", Path: "/mnt/nas/movie.mkv", Err: syscall.EIO}, true},
+		{fmt.Errorf("failed to move file: %w", &fs.PathError{Op: "rename", Path: "/mnt/nas", Err: syscall.ESTALE}), true},
+		{fmt.Errorf("failed to copy file: %w", syscall.EAGAIN), true},
+		{notStable("/downloads/movie.mkv", "is open for writing"), true},
+		{&fs.PathError{Op: "open", Path: "/downloads/movie.mkv", Err: syscall.ENOENT}, false},
+		{fmt.Errorf("%w: quality upgrades are disabled", ErrNotUpgrade), false},
+		{errors.New("unsupported media type: game"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 15 * time.Minute, 15 * time.Minute}
+	for i, delay := range want {
+		if got := retryDelay(i + 1); got != delay {
+			t.Errorf("retryDelay(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestQuietFailure(t *testing.T) {
+	err := &fs.PathError{Op: "rename", Path: "/mnt/nas", Err: syscall.ESTALE}
+	if quietFailure(&ImportRequest{}, err) {
+		t.Error("transient failure of the last attempt is quiet")
+	}
+	if !quietFailure(&ImportRequest{retryable: true}, err) {
+		t.Error("transient failure that is retried is reported")
+	}
+	if quietFailure(&ImportRequest{retryable: true}, errors.New("permission denied")) {
+		t.Error("permanent failure is quiet")
+	}
+}
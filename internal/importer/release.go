@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"go.uber.org/zap"
+)
+
+// fillRelease completes the quality and release group of an import from its
+// release title when the request doesn't have them. It returns the quality
+// detected from the release title, or nil without one.
+func fillRelease(req *ImportRequest) *quality.DetectedQualityInfo {
+	if req.ReleaseTitle == nil || *req.ReleaseTitle == "" {
+		return nil
+	}
+
+	info := quality.NewDetector().DetectQuality(*req.ReleaseTitle)
+	if req.Quality == nil && info.QualityName != "Unknown" {
+		req.Quality = &info.QualityName
+	}
+	if req.ReleaseGroup == nil {
+		if group := quality.ParseReleaseGroup(*req.ReleaseTitle); group != "" {
+			req.ReleaseGroup = &group
+		}
+	}
+	return info
+}
+
+// recordRelease stores the release an imported file came from on its
+// media_files entry and in the metadata of its media item, so later upgrades
+// can be judged against it
+func (s *Service) recordRelease(ctx context.Context, req *ImportRequest, release *quality.DetectedQualityInfo, finalPath string, mediaItemID *int64) {
+	if req.Quality == nil && req.ReleaseGroup == nil && req.ReleaseTitle == nil {
+		return
+	}
+
+	if err := s.queries.SetMediaFileRelease(ctx, generated.SetMediaFileReleaseParams{
+		Quality:      req.Quality,
+		ReleaseGroup: req.ReleaseGroup,
+		ReleaseTitle: req.ReleaseTitle,
+		Path:         finalPath,
+	}); err != nil {
+		s.logger.Warn("failed to record release on media file",
+			zap.String("path", finalPath),
+			zap.Error(err))
+	}
+
+	if mediaItemID == nil {
+		return
+	}
+
+	metadata := make(map[string]interface{})
+	if req.Quality != nil {
+		metadata["quality"] = *req.Quality
+	}
+	if req.ReleaseGroup != nil {
+		metadata["release_group"] = *req.ReleaseGroup
+	}
+	if req.ReleaseTitle != nil {
+		metadata["release_title"] = *req.ReleaseTitle
+	}
+	if release != nil && release.CodecVideo != nil {
+		metadata["codec"] = *release.CodecVideo
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	if _, err := s.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{
+		Metadata: data,
+		ID:       *mediaItemID,
+	}); err != nil {
+		s.logger.Warn("failed to record release on media item",
+			zap.Int64("media_item_id", *mediaItemID),
+			zap.Error(err))
+	}
+}
+
+// fileQuality detects the quality of a media file from its name or failing that
+// from the release it was imported from
+func fileQuality(file generated.MediaFile, detector *quality.Detector) *quality.DetectedQualityInfo {
+	info := detector.DetectQuality(filepath.Base(file.Path))
+	if info.Resolution != nil {
+		return info
+	}
+	for _, name := range []*string{file.ReleaseTitle, file.Quality} {
+		if name != nil && *name != "" {
+			if released := detector.DetectQuality(*name); released.Resolution != nil {
+				return released
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,570 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Rename outcomes
+const (
+	RenameRenamed = "renamed"
+	RenameSkipped = "skipped"
+	RenameFailed  = "failed"
+)
+
+var (
+	// ErrRenameNotFound is returned when renaming the files of a media item
+	// that doesn't exist
+	ErrRenameNotFound = errors.New("media item not found")
+
+	// ErrRenameUnsupported is returned when renaming the files of a media item
+	// that isn't a movie, series, season or episode
+	ErrRenameUnsupported = errors.New("only the files of movies, series, seasons and episodes can be renamed")
+)
+
+// RenamePath is a file and the path the naming templates give it
+type RenamePath struct {
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+}
+
+// RenameFile is a library file and the path the current naming templates
+// would give it
+type RenameFile struct {
+	MediaFileID int64        `json:"media_file_id"`
+	MediaItemID int64        `json:"media_item_id"`
+	Title       string       `json:"title"`
+	OldPath     string       `json:"old_path"`
+	NewPath     string       `json:"new_path"`
+	Changed     bool         `json:"changed"`
+	Conflict    string       `json:"conflict,omitempty"` // Why the file can't be renamed
+	Extras      []RenamePath `json:"extras,omitempty"`   // Subtitles renamed along with it
+
+	root string // Root folder the file is in, which emptied folders are removed up to
+}
+
+// RenamePreview lists the files of a media item with the paths the current
+// naming templates would give them
+type RenamePreview struct {
+	MediaItemID int64        `json:"media_item_id"`
+	Files       []RenameFile `json:"files"`
+}
+
+// RenameOutcome is what happened to a file selected for renaming
+type RenameOutcome struct {
+	RenameFile
+	Status string `json:"status"` // renamed, skipped or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// RenameResult is what happened to the files selected for renaming
+type RenameResult struct {
+	MediaItemID int64           `json:"media_item_id"`
+	Renamed     int             `json:"renamed"`
+	Failed      int             `json:"failed"`
+	Files       []RenameOutcome `json:"files"`
+}
+
+// PreviewRename computes, for every file of a movie, series, season or
+// episode, the path the current naming templates would give it
+func (s *Service) PreviewRename(ctx context.Context, mediaItemID int64) (*RenamePreview, error) {
+	config, err := s.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.planRename(ctx, mediaItemID, config)
+	if err != nil {
+		return nil, err
+	}
+	return &RenamePreview{MediaItemID: mediaItemID, Files: files}, nil
+}
+
+// Rename renames the selected files of a media item to the paths the current
+// naming templates give them, or all changed files when none are selected.
+// Each file is renamed on its own: when one fails it is put back, and the
+// others are still renamed.
+func (s *Service) Rename(ctx context.Context, mediaItemID int64, fileIDs []int64) (*RenameResult, error) {
+	config, err := s.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.planRename(ctx, mediaItemID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int64]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		selected[id] = true
+	}
+
+	result := &RenameResult{MediaItemID: mediaItemID, Files: []RenameOutcome{}}
+	for _, file := range files {
+		if len(selected) > 0 && !selected[file.MediaFileID] {
+			continue
+		}
+		delete(selected, file.MediaFileID)
+
+		outcome := RenameOutcome{RenameFile: file}
+		switch {
+		case !file.Changed:
+			outcome.Status = RenameSkipped
+		case file.Conflict != "":
+			outcome.Status = RenameFailed
+			outcome.Error = file.Conflict
+		default:
+			if err := s.renameFile(ctx, file); err != nil {
+				outcome.Status = RenameFailed
+				outcome.Error = err.Error()
+				s.logger.Warn("failed to rename file",
+					zap.String("from", file.OldPath),
+					zap.String("to", file.NewPath),
+					zap.Error(err))
+			} else {
+				outcome.Status = RenameRenamed
+				s.recordRename(ctx, file)
+			}
+		}
+		switch outcome.Status {
+		case RenameRenamed:
+			result.Renamed++
+		case RenameFailed:
+			result.Failed++
+		}
+		result.Files = append(result.Files, outcome)
+	}
+
+	// Selected files that don't belong to the media item
+	ids := make([]int64, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		result.Failed++
+		result.Files = append(result.Files, RenameOutcome{
+			RenameFile: RenameFile{MediaFileID: id},
+			Status:     RenameFailed,
+			Error:      "not a file of this media item",
+		})
+	}
+	return result, nil
+}
+
+// planRename lists the files of a media item with their new paths
+func (s *Service) planRename(ctx context.Context, mediaItemID int64, config *ImportConfig) ([]RenameFile, error) {
+	item, err := s.queries.GetMediaItem(ctx, mediaItemID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && item.DeletedAt.Valid) {
+		return nil, ErrRenameNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	var requests []*ImportRequest
+	switch item.Kind {
+	case "movie":
+		requests = append(requests, movieRenameRequest(item))
+	case "tv_series", "tv_season", "tv_episode":
+		requests, err = s.episodeRenameRequests(ctx, item, config)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrRenameUnsupported
+	}
+
+	rootFolders, err := s.queries.ListRootFolders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list root folders: %w", err)
+	}
+
+	files := []RenameFile{}
+	for _, req := range requests {
+		itemFiles, err := s.queries.ListMediaFilesByItem(ctx, req.MediaItemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of media item %d: %w", *req.MediaItemID, err)
+		}
+		for _, file := range itemFiles {
+			if file.Kind != "media" {
+				continue
+			}
+			planned, err := s.planFile(ctx, req, file, itemFiles, rootFolders, config)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, planned)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].OldPath < files[j].OldPath })
+	markRenameConflicts(files)
+	return files, nil
+}
+
+// movieRenameRequest describes a movie as if it were being imported
+func movieRenameRequest(item generated.MediaItem) *ImportRequest {
+	req := &ImportRequest{
+		MediaType:   "movie",
+		MediaItemID: &item.ID,
+		Title:       item.Title,
+	}
+	if item.Year != nil {
+		year := int(*item.Year)
+		req.Year = &year
+	}
+	return req
+}
+
+// episodeRenameRequests describes the episodes of a series, season or
+// episode as if they were being imported
+func (s *Service) episodeRenameRequests(ctx context.Context, item generated.MediaItem, config *ImportConfig) ([]*ImportRequest, error) {
+	var series generated.MediaItem
+	var seasons, episodes []generated.MediaItem
+	var err error
+
+	switch item.Kind {
+	case "tv_series":
+		series = item
+		seasons, err = s.queries.ListChildMediaItems(ctx, &item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list seasons: %w", err)
+		}
+	case "tv_season":
+		seasons = []generated.MediaItem{item}
+	case "tv_episode":
+		episodes = []generated.MediaItem{item}
+	}
+	for _, season := range seasons {
+		if season.Kind != "tv_season" {
+			continue
+		}
+		children, err := s.queries.ListChildMediaItems(ctx, &season.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list episodes of season %d: %w", season.ID, err)
+		}
+		episodes = append(episodes, children...)
+	}
+
+	if item.Kind != "tv_series" {
+		parentID := item.ParentID
+		if item.Kind == "tv_episode" && parentID != nil {
+			season, err := s.queries.GetMediaItem(ctx, *parentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get season: %w", err)
+			}
+			parentID = season.ParentID
+		}
+		if parentID == nil {
+			return nil, fmt.Errorf("the series of media item %d is unknown", item.ID)
+		}
+		if series, err = s.queries.GetMediaItem(ctx, *parentID); err != nil {
+			return nil, fmt.Errorf("failed to get series: %w", err)
+		}
+	}
+
+	// Absolute numbers are only looked up when the template uses them
+	absolutes := map[int64]int{}
+	if usesToken(config.TVNamingFormat, absoluteToken.Name) {
+		seriesEpisodes, err := library.ListSeriesEpisodes(ctx, s.queries, series.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range seriesEpisodes {
+			absolutes[e.MediaItemID] = e.Absolute
+		}
+	}
+
+	requests := make([]*ImportRequest, 0, len(episodes))
+	for _, episode := range episodes {
+		seasonNumber, ok := metadataInt(episode.Metadata, "season", "season_number")
+		episodeNumber, ok2 := metadataInt(episode.Metadata, "episode", "episode_number")
+		if episode.Kind != "tv_episode" || !ok || !ok2 {
+			continue
+		}
+		episode := episode
+		title := episode.Title
+		req := &ImportRequest{
+			MediaType:    "tv_episode",
+			MediaItemID:  &episode.ID,
+			Title:        series.Title,
+			Season:       &seasonNumber,
+			Episode:      &episodeNumber,
+			EpisodeTitle: &title,
+		}
+		if series.Year != nil {
+			year := int(*series.Year)
+			req.Year = &year
+		}
+		if absolute := absolutes[episode.ID]; absolute > 0 {
+			req.Absolute = &absolute
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// planFile computes the new path of a file, along with its subtitles
+func (s *Service) planFile(ctx context.Context, base *ImportRequest, file generated.MediaFile, itemFiles []generated.MediaFile, rootFolders []generated.RootFolder, config *ImportConfig) (RenameFile, error) {
+	req := *base
+	req.SourcePath = file.Path
+	req.Quality = file.Quality
+	req.ReleaseGroup = file.ReleaseGroup
+	req.ReleaseTitle = file.ReleaseTitle
+	if len(file.Mediainfo) > 0 {
+		var info mediainfo.MediaInfo
+		if json.Unmarshal(file.Mediainfo, &info) == nil {
+			req.MediaInfo = &info
+		}
+	}
+	if err := s.fillLastEpisode(ctx, &req, file.ID); err != nil {
+		return RenameFile{}, err
+	}
+
+	planned := RenameFile{
+		MediaFileID: file.ID,
+		MediaItemID: *req.MediaItemID,
+		Title:       req.Title,
+		OldPath:     file.Path,
+		root:        fileRootFolder(file.Path, rootFolders),
+	}
+	if req.Season != nil && req.Episode != nil {
+		planned.Title = fmt.Sprintf("%s S%02dE%02d", req.Title, *req.Season, *req.Episode)
+	}
+
+	libraryPath := planned.root
+	if libraryPath == "" {
+		path, _, err := s.getLibraryPath(ctx, &req)
+		if err != nil {
+			return RenameFile{}, err
+		}
+		libraryPath = path
+	}
+	target, err := s.importTarget(&req, config, libraryPath)
+	if err != nil {
+		return RenameFile{}, err
+	}
+	planned.NewPath = target.FinalPath
+	planned.Changed = planned.NewPath != planned.OldPath
+	if !planned.Changed {
+		return planned, nil
+	}
+
+	// Subtitles named after the file keep their suffix, e.g. .en.srt
+	oldBase := strings.TrimSuffix(file.Path, filepath.Ext(file.Path))
+	newBase := strings.TrimSuffix(planned.NewPath, filepath.Ext(planned.NewPath))
+	for _, extra := range itemFiles {
+		if extra.Kind == "media" || !strings.HasPrefix(extra.Path, oldBase+".") {
+			continue
+		}
+		planned.Extras = append(planned.Extras, RenamePath{
+			OldPath: extra.Path,
+			NewPath: newBase + strings.TrimPrefix(extra.Path, oldBase),
+		})
+	}
+
+	planned.Conflict = renameConflict(planned.OldPath, planned.NewPath)
+	for _, extra := range planned.Extras {
+		if planned.Conflict == "" {
+			planned.Conflict = renameConflict(extra.OldPath, extra.NewPath)
+		}
+	}
+	return planned, nil
+}
+
+// fillLastEpisode sets the last episode of a multi-episode file from the
+// further episodes linked to it
+func (s *Service) fillLastEpisode(ctx context.Context, req *ImportRequest, fileID int64) error {
+	if req.Episode == nil {
+		return nil
+	}
+	linked, err := s.queries.ListMediaFileEpisodes(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to list episodes of file %d: %w", fileID, err)
+	}
+	last := *req.Episode
+	for _, id := range linked {
+		episode, err := s.queries.GetMediaItem(ctx, id)
+		if err != nil {
+			continue
+		}
+		if n, ok := metadataInt(episode.Metadata, "episode", "episode_number"); ok && n > last {
+			last = n
+		}
+	}
+	if last > *req.Episode {
+		req.LastEpisode = &last
+	}
+	return nil
+}
+
+// fileRootFolder returns the root folder a file is in, the deepest one when
+// they are nested, or "" when it isn't in one
+func fileRootFolder(path string, rootFolders []generated.RootFolder) string {
+	root := ""
+	for _, folder := range rootFolders {
+		dir := filepath.Clean(folder.Path)
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) && len(dir) > len(root) {
+			root = dir
+		}
+	}
+	return root
+}
+
+// renameConflict returns why a file can't be moved to a new path: another
+// file is already there. The same file under a differently cased name, on a
+// case-insensitive filesystem, isn't a conflict.
+func renameConflict(oldPath, newPath string) string {
+	existing, err := os.Stat(newPath)
+	if err != nil {
+		return ""
+	}
+	if current, err := os.Stat(oldPath); err == nil && os.SameFile(current, existing) && strings.EqualFold(oldPath, newPath) {
+		return ""
+	}
+	return "a different file already exists at " + newPath
+}
+
+// markRenameConflicts flags files that would be renamed to the same path
+func markRenameConflicts(files []RenameFile) {
+	targets := make(map[string]int, len(files))
+	for _, file := range files {
+		targets[file.NewPath]++
+	}
+	for i := range files {
+		if files[i].Changed && files[i].Conflict == "" && targets[files[i].NewPath] > 1 {
+			files[i].Conflict = "another file of this media item would be renamed to " + files[i].NewPath
+		}
+	}
+}
+
+// renameFile moves a file and its subtitles to their new paths and updates
+// their media_files entries. When any step fails, everything done so far is
+// undone.
+func (s *Service) renameFile(ctx context.Context, file RenameFile) (err error) {
+	moves := append([]RenamePath{{OldPath: file.OldPath, NewPath: file.NewPath}}, file.Extras...)
+
+	// Check again right before moving, the preview may be stale
+	for _, m := range moves {
+		if conflict := renameConflict(m.OldPath, m.NewPath); conflict != "" {
+			return errors.New(conflict)
+		}
+	}
+
+	created, err := createFolders(filepath.Dir(file.NewPath))
+	if err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	var moved []RenamePath
+	var updated []RenamePath
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(updated) - 1; i >= 0; i-- {
+			s.setFilePath(ctx, updated[i].NewPath, updated[i].OldPath)
+		}
+		for i := len(moved) - 1; i >= 0; i-- {
+			if undoErr := s.moveFile(moved[i].NewPath, moved[i].OldPath); undoErr != nil {
+				s.logger.Error("failed to move renamed file back",
+					zap.String("path", moved[i].NewPath),
+					zap.String("original", moved[i].OldPath),
+					zap.Error(undoErr))
+			}
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}()
+
+	for _, m := range moves {
+		if err = s.moveFile(m.OldPath, m.NewPath); err != nil {
+			return fmt.Errorf("failed to move %s: %w", filepath.Base(m.OldPath), err)
+		}
+		moved = append(moved, m)
+	}
+	for _, m := range moves {
+		if err = s.setFilePath(ctx, m.OldPath, m.NewPath); err != nil {
+			return err
+		}
+		updated = append(updated, m)
+	}
+
+	if file.root != "" {
+		removeEmptyFolders(filepath.Dir(file.OldPath), file.root)
+	}
+	return nil
+}
+
+// setFilePath points the media_files entry of a path at a new path
+func (s *Service) setFilePath(ctx context.Context, oldPath, newPath string) error {
+	entry, err := s.queries.GetMediaFileByPath(ctx, oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to find media file %s: %w", oldPath, err)
+	}
+	if err := s.queries.SetMediaFilePath(ctx, generated.SetMediaFilePathParams{ID: entry.ID, Path: newPath}); err != nil {
+		return fmt.Errorf("failed to update media file path: %w", err)
+	}
+	return nil
+}
+
+// createFolders creates a folder and its missing parents, returning the ones
+// it created, outermost first
+func createFolders(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// removeEmptyFolders removes a folder and its parents while they are empty,
+// stopping at root, which is kept
+func removeEmptyFolders(dir, root string) {
+	for strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// recordRename adds a renamed file to the history
+func (s *Service) recordRename(ctx context.Context, file RenameFile) {
+	data := map[string]interface{}{
+		"from": file.OldPath,
+		"to":   file.NewPath,
+	}
+	if len(file.Extras) > 0 {
+		data["extras"] = file.Extras
+	}
+	s.history.Record(ctx, history.Event{
+		Type:        history.EventRenamed,
+		MediaItemID: &file.MediaItemID,
+		Title:       file.Title,
+		Data:        data,
+	})
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+)
+
+func TestFileRootFolder(t *testing.T) {
+	folders := []generated.RootFolder{{Path: "/media/tv"}, {Path: "/media/tv/anime/"}, {Path: "/media/movies"}}
+	tests := []struct {
+		path, want string
+	}{
+		{"/media/tv/Show/Season 01/Show.S01E01.mkv", "/media/tv"},
+		{"/media/tv/anime/Show/Show.S01E01.mkv", "/media/tv/anime"},
+		{"/media/tvshows/Show/Show.S01E01.mkv", ""},
+		{"/downloads/Show.S01E01.mkv", ""},
+	}
+	for _, tt := range tests {
+		if got := fileRootFolder(tt.path, folders); got != tt.want {
+			t.Errorf("fileRootFolder(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMarkRenameConflicts(t *testing.T) {
+	files := []RenameFile{
+		{MediaFileID: 1, OldPath: "/tv/a.mkv", NewPath: "/tv/S01E01.mkv", Changed: true},
+		{MediaFileID: 2, OldPath: "/tv/b.mkv", NewPath: "/tv/S01E01.mkv", Changed: true},
+		{MediaFileID: 3, OldPath: "/tv/S01E02.mkv", NewPath: "/tv/S01E02.mkv"},
+	}
+	markRenameConflicts(files)
+	if files[0].Conflict == "" || files[1].Conflict == "" {
+		t.Errorf("files renamed to the same path = %+v, want conflicts", files[:2])
+	}
+	if files[2].Conflict != "" {
+		t.Errorf("unchanged file conflict = %q, want none", files[2].Conflict)
+	}
+}
+
+func TestRenameConflict(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.mkv")
+	other := filepath.Join(dir, "other.mkv")
+	for _, path := range []string{oldPath, other} {
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if conflict := renameConflict(oldPath, filepath.Join(dir, "new.mkv")); conflict != "" {
+		t.Errorf("renameConflict() to a free path = %q", conflict)
+	}
+	if conflict := renameConflict(oldPath, other); conflict == "" {
+		t.Error("renameConflict() onto another file reported no conflict")
+	}
+}
+
+func TestCreateAndRemoveFolders(t *testing.T) {
+	root := t.TempDir()
+	season := filepath.Join(root, "Show", "Season 01")
+
+	created, err := createFolders(season)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || created[0] != filepath.Join(root, "Show") || created[1] != season {
+		t.Errorf("createFolders() = %v, want the series and season folders", created)
+	}
+	if created, err := createFolders(season); err != nil || len(created) != 0 {
+		t.Errorf("createFolders() again = %v, %v, want nothing created", created, err)
+	}
+
+	// A folder with a file left in it is kept, along with its parents
+	other := filepath.Join(root, "Show", "Season 02")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(other, "extra.nfo"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	removeEmptyFolders(season, root)
+	if _, err := os.Stat(season); !os.IsNotExist(err) {
+		t.Error("emptied season folder was kept")
+	}
+	if _, err := os.Stat(filepath.Join(root, "Show")); err != nil {
+		t.Error("series folder that isn't empty was removed")
+	}
+
+	os.Remove(filepath.Join(other, "extra.nfo"))
+	removeEmptyFolders(other, root)
+	if _, err := os.Stat(filepath.Join(root, "Show")); !os.IsNotExist(err) {
+		t.Error("emptied series folder was kept")
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Error("root folder was removed")
+	}
+}
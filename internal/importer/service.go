@@ -3,15 +3,24 @@ package importer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/blakestevenson/nimbus/internal/configstore"
 	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/history"
 	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"github.com/blakestevenson/nimbus/internal/mediaservers"
+	"github.com/blakestevenson/nimbus/internal/notifications"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/realtime"
+	"github.com/blakestevenson/nimbus/internal/rootfolders"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +29,16 @@ type Service struct {
 	queries     *generated.Queries
 	configStore *configstore.Store
 	logger      *zap.Logger
+	notifier    *notifications.Service
+	history     *history.Service
+	hub         *realtime.Hub
+	events      *plugins.EventBus
+	servers     *mediaservers.Service
+	onImported  []ImportedHandler
+
+	proberMu   sync.Mutex
+	prober     *mediainfo.Prober
+	proberPath string // Configured path the prober was created for
 }
 
 // NewService creates a new importer service
@@ -34,15 +53,42 @@ func NewService(queries *generated.Queries, configStore *configstore.Store, logg
 // ImportRequest represents a request to import downloaded media
 type ImportRequest struct {
 	SourcePath   string                 // Path to downloaded file(s)
-	MediaType    string                 // "movie" or "tv"
+	MediaType    string                 // "movie", "tv" or "music"
 	MediaItemID  *int64                 // Optional: Associated media item ID
 	Title        string                 // Media title
 	Year         *int                   // Release year (for movies)
 	Season       *int                   // Season number (for TV)
 	Episode      *int                   // Episode number (for TV)
+	LastEpisode  *int                   // Last episode of a multi-episode file (for TV)
+	Absolute     *int                   // Absolute episode number (for anime)
 	EpisodeTitle *string                // Episode title (for TV)
+	Artist       *string                // Artist name (for music)
+	Album        *string                // Album title (for music)
+	Track        *int                   // Track number (for music)
 	Quality      *string                // Quality (e.g., "1080p")
+	ReleaseGroup *string                // Release group (e.g., "GROUP")
+	ReleaseTitle *string                // Release name (e.g., "Show.S01E05.1080p.WEB.H264-GROUP")
 	Metadata     map[string]interface{} // Additional metadata
+	Force        bool                   // Replace existing files even if this isn't an upgrade
+	TransferMode string                 // How the file gets to the library: "move" (the default), "copy", "hardlink" or "auto"
+	Disposable   bool                   // The source may be removed, so auto mode moves it
+	RootFolderID *int64                 // Optional: Root folder to import into instead of the media item's
+
+	// MediaInfo of the source file, for the media info tokens of the naming
+	// templates. Probed when a template uses them and it isn't set.
+	MediaInfo *mediainfo.MediaInfo
+
+	// DestinationPath places the file at exactly this path instead of applying
+	// the naming templates, e.g. when confirming a previewed import
+	DestinationPath string
+
+	// ExtraMediaItemIDs are the further episodes of a multi-episode file, whose
+	// first episode is MediaItemID. The file is linked to each of them.
+	ExtraMediaItemIDs []int64
+
+	// retryable is set by the import queue when a transient failure of this
+	// attempt will be retried
+	retryable bool
 }
 
 // ImportResult represents the result of an import operation
@@ -55,10 +101,309 @@ type ImportResult struct {
 	CreatedFolders []string `json:"created_folders,omitempty"`
 	MovedFiles     []string `json:"moved_files,omitempty"`
 	ImportedExtras []string `json:"imported_extras,omitempty"`
+	Replaced       []string `json:"replaced,omitempty"`
+	UpgradeFrom    string   `json:"upgrade_from,omitempty"`
+	UpgradeTo      string   `json:"upgrade_to,omitempty"`
+	LinkedEpisodes []int64  `json:"linked_episodes,omitempty"` // Further episodes of a multi-episode file
+
+	// MediaServers are the Plex and Jellyfin servers asked to scan the
+	// imported folder, and whether they could be
+	MediaServers []mediaservers.RefreshResult `json:"media_servers,omitempty"`
+}
+
+// ImportedHandler is called, in the background, after a file was imported for
+// a media item
+type ImportedHandler func(ctx context.Context, mediaItemID int64)
+
+// OnImported registers a handler that is called after every successful import
+// of a file for a media item
+func (s *Service) OnImported(handler ImportedHandler) {
+	s.onImported = append(s.onImported, handler)
+}
+
+// SetNotifier sets the service import events are sent to
+func (s *Service) SetNotifier(notifier *notifications.Service) {
+	s.notifier = notifier
+}
+
+// SetHistory sets the service imports are recorded in
+func (s *Service) SetHistory(historySvc *history.Service) {
+	s.history = historySvc
+}
+
+// SetHub sets the hub finished imports are pushed to clients through
+func (s *Service) SetHub(hub *realtime.Hub) {
+	s.hub = hub
+}
+
+// SetEventBus sets the bus completed imports are published to plugins on
+func (s *Service) SetEventBus(events *plugins.EventBus) {
+	s.events = events
+}
+
+// SetMediaServers sets the service that asks Plex and Jellyfin servers to
+// scan the folders files are imported into
+func (s *Service) SetMediaServers(servers *mediaservers.Service) {
+	s.servers = servers
+}
+
+// mediaProber returns the ffprobe prober for the configured path, recreating it
+// when the path setting changes
+func (s *Service) mediaProber(ctx context.Context) *mediainfo.Prober {
+	path := s.configStore.GetOrDefault(ctx, "library.ffprobe_path", mediainfo.DefaultFFprobePath)
+
+	s.proberMu.Lock()
+	defer s.proberMu.Unlock()
+	if s.prober == nil || s.proberPath != path {
+		s.prober = mediainfo.NewProber(path, s.logger)
+		s.proberPath = path
+	}
+	return s.prober
+}
+
+// probeImportedFile stores the media info of an imported file. Media info is
+// optional, so failures are only logged.
+func (s *Service) probeImportedFile(ctx context.Context, path string) {
+	file, err := s.queries.GetMediaFileByPath(ctx, path)
+	if err != nil {
+		s.logger.Debug("no media_files entry to store media info on", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	err = library.ProbeMediaFile(ctx, s.queries, s.mediaProber(ctx), file.ID, path)
+	if err != nil && !errors.Is(err, mediainfo.ErrUnavailable) {
+		s.logger.Warn("failed to read media info", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// probeSourceFile reads the media info of the file being imported when the
+// naming template it is named by uses media info tokens. Without it those
+// tokens are left empty.
+func (s *Service) probeSourceFile(ctx context.Context, req *ImportRequest, config *ImportConfig) {
+	if req.MediaInfo != nil || req.DestinationPath != "" {
+		return
+	}
+	switch req.MediaType {
+	case "movie":
+		if !usesMediaInfo(config.MovieNamingFormat) {
+			return
+		}
+	case "tv", "tv_episode":
+		if !usesMediaInfo(config.TVNamingFormat) {
+			return
+		}
+	default:
+		return
+	}
+	if info, err := os.Stat(req.SourcePath); err != nil || info.IsDir() {
+		return
+	}
+
+	info, err := s.mediaProber(ctx).Probe(ctx, req.SourcePath)
+	if err != nil {
+		if !errors.Is(err, mediainfo.ErrUnavailable) {
+			s.logger.Warn("failed to read media info for naming", zap.String("path", req.SourcePath), zap.Error(err))
+		}
+		return
+	}
+	req.MediaInfo = info
+}
+
+// fillAbsoluteEpisode looks up the absolute number of the episode being
+// imported when the naming template uses {absolute}
+func (s *Service) fillAbsoluteEpisode(ctx context.Context, req *ImportRequest, config *ImportConfig) {
+	if req.Absolute != nil || req.MediaItemID == nil || req.DestinationPath != "" ||
+		(req.MediaType != "tv" && req.MediaType != "tv_episode") ||
+		!usesToken(config.TVNamingFormat, absoluteToken.Name) {
+		return
+	}
+
+	episode, err := s.queries.GetMediaItem(ctx, *req.MediaItemID)
+	if err != nil {
+		return
+	}
+	absolute, err := library.AbsoluteEpisodeNumber(ctx, s.queries, episode)
+	if err != nil {
+		s.logger.Warn("failed to look up absolute episode number", zap.Int64("media_item_id", episode.ID), zap.Error(err))
+		return
+	}
+	if absolute > 0 {
+		req.Absolute = &absolute
+	}
 }
 
 // Import imports downloaded media into the library
 func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult, error) {
+	result, err := s.importMedia(ctx, req)
+	if err == nil {
+		result.MediaServers = s.servers.RefreshImported(ctx, req.MediaType, result.FinalPath)
+	}
+	s.notifyImport(req, result, err)
+	s.recordImport(ctx, req, result, err)
+	s.pushImport(req, result, err)
+	if err == nil {
+		s.publishImported(req, result)
+		if result.MediaItemID != nil {
+			for _, handler := range s.onImported {
+				go handler(context.Background(), *result.MediaItemID)
+			}
+		}
+	}
+	return result, err
+}
+
+// publishImported tells plugins that a file was imported into the library
+func (s *Service) publishImported(req *ImportRequest, result *ImportResult) {
+	data := map[string]interface{}{
+		"title":       req.Title,
+		"media_type":  req.MediaType,
+		"source_path": req.SourcePath,
+		"final_path":  result.FinalPath,
+	}
+	if result.MediaItemID != nil {
+		data["media_item_id"] = *result.MediaItemID
+	}
+	if downloadID, ok := req.Metadata["download_id"]; ok {
+		data["download_id"] = downloadID
+	}
+	s.events.Publish(plugins.EventImportCompleted, data)
+}
+
+// quietFailure reports whether an import failure is expected and isn't reported
+// as one: declined non-upgrades, files still being written that are imported
+// once they are complete, and transient errors the import queue tries again
+func quietFailure(req *ImportRequest, err error) bool {
+	return errors.Is(err, ErrNotUpgrade) || errors.Is(err, ErrFileNotStable) ||
+		(req.retryable && IsTransient(err))
+}
+
+// notifyImport sends the import event for a finished import. Quiet failures
+// aren't reported.
+func (s *Service) notifyImport(req *ImportRequest, result *ImportResult, err error) {
+	if s.notifier == nil || quietFailure(req, err) {
+		return
+	}
+
+	data := map[string]interface{}{
+		"title":      req.Title,
+		"media_type": req.MediaType,
+		"source":     req.SourcePath,
+	}
+	if req.MediaItemID != nil {
+		data["media_item_id"] = *req.MediaItemID
+	}
+	if req.Quality != nil {
+		data["quality"] = *req.Quality
+	}
+	if downloadID, ok := req.Metadata["download_id"]; ok {
+		data["download_id"] = downloadID
+	}
+
+	if err != nil {
+		data["error"] = err.Error()
+		s.notifier.Notify(notifications.NewEvent(notifications.EventImportFailed,
+			"Import failed", fmt.Sprintf("%s: %v", req.Title, err), data))
+		return
+	}
+
+	data["path"] = result.FinalPath
+	if result.UpgradeFrom != "" {
+		data["upgrade_from"] = result.UpgradeFrom
+		data["upgrade_to"] = result.UpgradeTo
+	}
+	s.notifier.Notify(notifications.NewEvent(notifications.EventImportCompleted,
+		"Import completed", fmt.Sprintf("%s imported to %s", req.Title, result.FinalPath), data))
+}
+
+// recordImport adds a finished import to the history. Like notifyImport, it
+// skips quiet failures.
+func (s *Service) recordImport(ctx context.Context, req *ImportRequest, result *ImportResult, err error) {
+	if quietFailure(req, err) {
+		return
+	}
+
+	data := map[string]interface{}{
+		"media_type": req.MediaType,
+		"source":     req.SourcePath,
+	}
+	if req.Quality != nil {
+		data["quality"] = *req.Quality
+	}
+	if req.ReleaseTitle != nil {
+		data["release_title"] = *req.ReleaseTitle
+	}
+
+	event := history.Event{
+		Type:        history.EventImported,
+		MediaItemID: req.MediaItemID,
+		Title:       req.Title,
+		Data:        data,
+	}
+	if downloadID, ok := req.Metadata["download_id"].(string); ok && downloadID != "" {
+		event.DownloadID = &downloadID
+	}
+
+	if err != nil {
+		event.Type = history.EventImportFailed
+		data["error"] = err.Error()
+	} else {
+		if result.MediaItemID != nil {
+			event.MediaItemID = result.MediaItemID
+		}
+		data["destination"] = result.FinalPath
+		if len(result.MediaServers) > 0 {
+			data["media_servers"] = result.MediaServers
+		}
+		if len(result.Replaced) > 0 {
+			event.Type = history.EventUpgraded
+			data["replaced"] = result.Replaced
+			if result.UpgradeFrom != "" {
+				data["upgrade_from"] = result.UpgradeFrom
+				data["upgrade_to"] = result.UpgradeTo
+			}
+		}
+	}
+	s.history.Record(ctx, event)
+}
+
+// pushImport tells clients that an import finished. Quiet failures aren't
+// pushed.
+func (s *Service) pushImport(req *ImportRequest, result *ImportResult, err error) {
+	if quietFailure(req, err) {
+		return
+	}
+
+	data := map[string]interface{}{
+		"title":      req.Title,
+		"media_type": req.MediaType,
+		"source":     req.SourcePath,
+	}
+	if downloadID, ok := req.Metadata["download_id"]; ok {
+		data["download_id"] = downloadID
+	}
+	mediaItemID := req.MediaItemID
+
+	eventType := "import.completed"
+	if err != nil {
+		eventType = "import.failed"
+		data["error"] = err.Error()
+	} else {
+		data["destination"] = result.FinalPath
+		if result.MediaItemID != nil {
+			mediaItemID = result.MediaItemID
+		}
+	}
+
+	topics := []string{realtime.TopicImports, realtime.TopicDownloads}
+	if mediaItemID != nil {
+		data["media_item_id"] = *mediaItemID
+		topics = append(topics, realtime.MediaTopic(*mediaItemID))
+	}
+	s.hub.Publish(realtime.Event{Type: eventType, Data: data, Topics: topics})
+}
+
+// importMedia does the work of Import
+func (s *Service) importMedia(ctx context.Context, req *ImportRequest) (*ImportResult, error) {
 	s.logger.Info("starting media import",
 		zap.String("source", req.SourcePath),
 		zap.String("type", req.MediaType),
@@ -68,6 +413,7 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 		CreatedFolders: []string{},
 		MovedFiles:     []string{},
 		ImportedExtras: []string{},
+		Replaced:       []string{},
 	}
 
 	// Load configuration
@@ -77,8 +423,12 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 		return result, err
 	}
 
-	// Determine library path based on media type
-	libraryPath, err := s.getLibraryPath(ctx, req.MediaType)
+	release := fillRelease(req)
+	s.probeSourceFile(ctx, req, config)
+	s.fillAbsoluteEpisode(ctx, req, config)
+
+	// Determine the root folder the media is placed in
+	libraryPath, rootFolderID, err := s.getLibraryPath(ctx, req)
 	if err != nil {
 		result.Error = fmt.Sprintf("failed to get library path: %v", err)
 		return result, err
@@ -90,6 +440,14 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 		return result, err
 	}
 
+	// Make sure the file isn't still being written, e.g. by a torrent client
+	if config.StabilityInterval > 0 {
+		if err := s.checkStable(ctx, req, time.Duration(config.StabilityInterval)*time.Second); err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
 	// Check free space if enabled
 	if !config.SkipFreeSpaceCheck {
 		if err := s.checkFreeSpace(libraryPath, config.MinimumFreeSpaceMB); err != nil {
@@ -107,6 +465,8 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 		finalPath, mediaItemID, err = s.importMovie(ctx, req, config, libraryPath, result)
 	case "tv", "tv_episode":
 		finalPath, mediaItemID, err = s.importTVEpisode(ctx, req, config, libraryPath, result)
+	case "music", "music_track", "music_album":
+		finalPath, mediaItemID, err = s.importMusicTrack(ctx, req, config, libraryPath, result)
 	default:
 		err = fmt.Errorf("unsupported media type: %s", req.MediaType)
 	}
@@ -121,6 +481,15 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 	result.MediaItemID = mediaItemID
 	result.Message = fmt.Sprintf("Successfully imported %s to %s", req.Title, finalPath)
 
+	if mediaItemID != nil && rootFolderID != nil {
+		s.recordRootFolder(ctx, *mediaItemID, *rootFolderID)
+	}
+	s.recordRelease(ctx, req, release, finalPath, mediaItemID)
+	s.linkEpisodes(ctx, req, finalPath, mediaItemID, result)
+	s.recordCutoff(ctx, req, finalPath, mediaItemID, result)
+
+	s.probeImportedFile(ctx, finalPath)
+
 	s.logger.Info("media import completed",
 		zap.String("title", req.Title),
 		zap.String("final_path", finalPath),
@@ -131,46 +500,28 @@ func (s *Service) Import(ctx context.Context, req *ImportRequest) (*ImportResult
 
 // importMovie imports a movie file
 func (s *Service) importMovie(ctx context.Context, req *ImportRequest, config *ImportConfig, libraryPath string, result *ImportResult) (string, *int64, error) {
-	// Generate folder name if creating movie folders
-	var targetDir string
-	if config.CreateMovieFolder {
-		folderName := s.applyMovieFolderTemplate(config.MovieFolderFormat, req)
-		folderName = s.sanitizePath(folderName, config)
-		targetDir = filepath.Join(libraryPath, folderName)
-
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			return "", nil, fmt.Errorf("failed to create movie folder: %w", err)
-		}
-		result.CreatedFolders = append(result.CreatedFolders, targetDir)
-	} else {
-		targetDir = libraryPath
+	target, err := s.importTarget(req, config, libraryPath)
+	if err != nil {
+		return "", nil, err
 	}
 
-	// Generate file name
-	fileName := s.applyMovieNamingTemplate(config.MovieNamingFormat, req)
-	fileName = s.sanitizePath(fileName, config)
-
-	// Get original extension
-	ext := filepath.Ext(req.SourcePath)
-	if ext == "" {
-		ext = ".mkv" // Default extension
+	// Check the import against the files the media item already has
+	upgrade, err := s.planUpgrade(ctx, req, config)
+	if err != nil {
+		return "", nil, err
 	}
 
-	finalPath := filepath.Join(targetDir, fileName+ext)
-
-	// Move/copy the file
-	if config.RenameMovies {
-		if err := s.moveFile(req.SourcePath, finalPath, config.UseHardlinks); err != nil {
-			return "", nil, fmt.Errorf("failed to move file: %w", err)
-		}
-		result.MovedFiles = append(result.MovedFiles, finalPath)
-	} else {
-		// Just move without renaming
-		finalPath = filepath.Join(targetDir, filepath.Base(req.SourcePath))
-		if err := s.moveFile(req.SourcePath, finalPath, config.UseHardlinks); err != nil {
-			return "", nil, fmt.Errorf("failed to move file: %w", err)
+	for _, folder := range target.Folders {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create movie folder: %w", err)
 		}
-		result.MovedFiles = append(result.MovedFiles, finalPath)
+		result.CreatedFolders = append(result.CreatedFolders, folder)
+	}
+	targetDir, fileName, finalPath := target.Dir, target.FileName, target.FinalPath
+
+	// Move/copy the file, replacing the files it upgrades
+	if err := s.placeFile(ctx, req, finalPath, upgrade, config, result); err != nil {
+		return "", nil, err
 	}
 
 	// Import extra files if enabled
@@ -179,7 +530,7 @@ func (s *Service) importMovie(ctx context.Context, req *ImportRequest, config *I
 		for _, extra := range extras {
 			extraName := s.generateExtraFileName(fileName, extra, config)
 			extraPath := filepath.Join(targetDir, extraName)
-			if err := s.moveFile(extra, extraPath, config.UseHardlinks); err != nil {
+			if err := s.transferFile(extra, extraPath, transferMode(req, config)); err != nil {
 				s.logger.Warn("failed to import extra file", zap.String("file", extra), zap.Error(err))
 			} else {
 				result.ImportedExtras = append(result.ImportedExtras, extraPath)
@@ -245,60 +596,28 @@ func (s *Service) importTVEpisode(ctx context.Context, req *ImportRequest, confi
 		return "", nil, fmt.Errorf("season and episode numbers are required for TV imports")
 	}
 
-	// Generate series folder
-	var seriesFolderName string
-	if config.CreateSeriesFolder {
-		seriesFolderName = s.applyTVSeriesFolderTemplate(config.TVFolderFormat, req)
-		seriesFolderName = s.sanitizePath(seriesFolderName, config)
-	} else {
-		seriesFolderName = s.sanitizePath(req.Title, config)
+	target, err := s.importTarget(req, config, libraryPath)
+	if err != nil {
+		return "", nil, err
 	}
 
-	seriesDir := filepath.Join(libraryPath, seriesFolderName)
-	if err := os.MkdirAll(seriesDir, 0755); err != nil {
-		return "", nil, fmt.Errorf("failed to create series folder: %w", err)
+	// Check the import against the files the media item already has
+	upgrade, err := s.planUpgrade(ctx, req, config)
+	if err != nil {
+		return "", nil, err
 	}
-	result.CreatedFolders = append(result.CreatedFolders, seriesDir)
-
-	// Generate season folder if enabled
-	var targetDir string
-	if config.TVUseSeasonFolders {
-		seasonFolderName := s.applyTVSeasonFolderTemplate(config.TVSeasonFolderFormat, req)
-		seasonFolderName = s.sanitizePath(seasonFolderName, config)
-		targetDir = filepath.Join(seriesDir, seasonFolderName)
 
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			return "", nil, fmt.Errorf("failed to create season folder: %w", err)
+	for _, folder := range target.Folders {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create folder: %w", err)
 		}
-		result.CreatedFolders = append(result.CreatedFolders, targetDir)
-	} else {
-		targetDir = seriesDir
-	}
-
-	// Generate episode file name
-	fileName := s.applyTVNamingTemplate(config.TVNamingFormat, req)
-	fileName = s.sanitizePath(fileName, config)
-
-	// Get original extension
-	ext := filepath.Ext(req.SourcePath)
-	if ext == "" {
-		ext = ".mkv"
+		result.CreatedFolders = append(result.CreatedFolders, folder)
 	}
+	targetDir, fileName, finalPath := target.Dir, target.FileName, target.FinalPath
 
-	finalPath := filepath.Join(targetDir, fileName+ext)
-
-	// Move/copy the file
-	if config.RenameEpisodes {
-		if err := s.moveFile(req.SourcePath, finalPath, config.UseHardlinks); err != nil {
-			return "", nil, fmt.Errorf("failed to move file: %w", err)
-		}
-		result.MovedFiles = append(result.MovedFiles, finalPath)
-	} else {
-		finalPath = filepath.Join(targetDir, filepath.Base(req.SourcePath))
-		if err := s.moveFile(req.SourcePath, finalPath, config.UseHardlinks); err != nil {
-			return "", nil, fmt.Errorf("failed to move file: %w", err)
-		}
-		result.MovedFiles = append(result.MovedFiles, finalPath)
+	// Move/copy the file, replacing the files it upgrades
+	if err := s.placeFile(ctx, req, finalPath, upgrade, config, result); err != nil {
+		return "", nil, err
 	}
 
 	// Import extra files
@@ -307,7 +626,7 @@ func (s *Service) importTVEpisode(ctx context.Context, req *ImportRequest, confi
 		for _, extra := range extras {
 			extraName := s.generateExtraFileName(fileName, extra, config)
 			extraPath := filepath.Join(targetDir, extraName)
-			if err := s.moveFile(extra, extraPath, config.UseHardlinks); err != nil {
+			if err := s.transferFile(extra, extraPath, transferMode(req, config)); err != nil {
 				s.logger.Warn("failed to import extra file", zap.String("file", extra), zap.Error(err))
 			} else {
 				result.ImportedExtras = append(result.ImportedExtras, extraPath)
@@ -368,20 +687,147 @@ func (s *Service) importTVEpisode(ctx context.Context, req *ImportRequest, confi
 	return finalPath, mediaItemID, nil
 }
 
+// importTarget is where an import places its file
+type importTarget struct {
+	Folders   []string // Folders to create, outermost first
+	Dir       string   // Folder the file ends up in
+	FileName  string   // Name from the naming template, without extension
+	FinalPath string   // Full destination path
+}
+
+// importTarget computes the destination of an import from the naming templates,
+// or from the request's explicit destination path
+func (s *Service) importTarget(req *ImportRequest, config *ImportConfig, libraryPath string) (*importTarget, error) {
+	if req.DestinationPath != "" {
+		dir := filepath.Dir(req.DestinationPath)
+		return &importTarget{
+			Folders:   []string{dir},
+			Dir:       dir,
+			FileName:  strings.TrimSuffix(filepath.Base(req.DestinationPath), filepath.Ext(req.DestinationPath)),
+			FinalPath: req.DestinationPath,
+		}, nil
+	}
+
+	// Get original extension
+	ext := filepath.Ext(req.SourcePath)
+	if ext == "" {
+		ext = ".mkv" // Default extension
+	}
+
+	target := &importTarget{}
+	var rename bool
+
+	switch req.MediaType {
+	case "movie":
+		// Generate folder name if creating movie folders
+		if config.CreateMovieFolder {
+			folderName := s.applyMovieFolderTemplate(config.MovieFolderFormat, req)
+			folderName = s.sanitizePath(folderName, config)
+			target.Dir = filepath.Join(libraryPath, folderName)
+			target.Folders = append(target.Folders, target.Dir)
+		} else {
+			target.Dir = libraryPath
+		}
+
+		target.FileName = s.sanitizePath(s.applyMovieNamingTemplate(config.MovieNamingFormat, req), config)
+		target.FileName = replaceSpaces(target.FileName, config.SpaceReplacement)
+		rename = config.RenameMovies
+
+	case "tv", "tv_episode":
+		if req.Season == nil || req.Episode == nil {
+			return nil, fmt.Errorf("season and episode numbers are required for TV imports")
+		}
+
+		// Generate series folder
+		var seriesFolderName string
+		if config.CreateSeriesFolder {
+			seriesFolderName = s.applyTVSeriesFolderTemplate(config.TVFolderFormat, req)
+			seriesFolderName = s.sanitizePath(seriesFolderName, config)
+		} else {
+			seriesFolderName = s.sanitizePath(req.Title, config)
+		}
+		seriesDir := filepath.Join(libraryPath, seriesFolderName)
+		target.Folders = append(target.Folders, seriesDir)
+
+		// Generate season folder if enabled
+		if config.TVUseSeasonFolders {
+			seasonFolderName := s.applyTVSeasonFolderTemplate(config.TVSeasonFolderFormat, req)
+			seasonFolderName = s.sanitizePath(seasonFolderName, config)
+			target.Dir = filepath.Join(seriesDir, seasonFolderName)
+			target.Folders = append(target.Folders, target.Dir)
+		} else {
+			target.Dir = seriesDir
+		}
+
+		target.FileName = s.sanitizePath(s.applyTVNamingTemplate(config.TVNamingFormat, req), config)
+		target.FileName = replaceSpaces(target.FileName, config.SpaceReplacement)
+		rename = config.RenameEpisodes
+
+	case "music", "music_track", "music_album":
+		if err := s.musicTarget(target, req, config, libraryPath); err != nil {
+			return nil, err
+		}
+		rename = config.RenameTracks
+
+	default:
+		return nil, fmt.Errorf("unsupported media type: %s", req.MediaType)
+	}
+
+	if rename {
+		target.FinalPath = filepath.Join(target.Dir, target.FileName+ext)
+	} else {
+		// Just move without renaming
+		target.FinalPath = filepath.Join(target.Dir, filepath.Base(req.SourcePath))
+	}
+
+	// Titles filled into the naming templates can't place a file outside the library
+	if !withinDir(libraryPath, target.FinalPath) {
+		return nil, fmt.Errorf("import path %s is outside the library folder %s", target.FinalPath, libraryPath)
+	}
+
+	return target, nil
+}
+
 // Helper methods for template application will be in naming.go
 // Helper methods for file operations will be in fileops.go
 // Configuration loading will be in config.go
 
-func (s *Service) getLibraryPath(ctx context.Context, mediaType string) (string, error) {
+// getLibraryPath returns the folder an import is placed in, and the root
+// folder it belongs to when one is configured. The root folder is taken from
+// the request, then from the media item or the monitoring rule of it or one of
+// its parents, then the default root folder of the media kind. Without root
+// folders the library path settings are used.
+func (s *Service) getLibraryPath(ctx context.Context, req *ImportRequest) (string, *int64, error) {
+	if req.RootFolderID != nil {
+		folder, err := s.queries.GetRootFolder(ctx, *req.RootFolderID)
+		if err != nil {
+			return "", nil, fmt.Errorf("root folder %d: %w", *req.RootFolderID, err)
+		}
+		return folder.Path, &folder.ID, nil
+	}
+
+	if req.MediaItemID != nil {
+		if folderID := s.itemRootFolder(ctx, *req.MediaItemID); folderID != nil {
+			if folder, err := s.queries.GetRootFolder(ctx, *folderID); err == nil {
+				return folder.Path, &folder.ID, nil
+			}
+		}
+	}
+
+	kind := rootfolders.KindForMediaType(req.MediaType)
+	if folder, err := s.queries.GetDefaultRootFolder(ctx, kind); err == nil {
+		return folder.Path, &folder.ID, nil
+	}
+
 	var configKey string
-	switch mediaType {
-	case "movie":
+	switch kind {
+	case rootfolders.KindMovie:
 		configKey = "library.movie_path"
-	case "tv", "tv_episode":
+	case rootfolders.KindTV:
 		configKey = "library.tv_path"
-	case "music":
+	case rootfolders.KindMusic:
 		configKey = "library.music_path"
-	case "book":
+	case rootfolders.KindBook:
 		configKey = "library.book_path"
 	default:
 		configKey = "library.root_path"
@@ -392,16 +838,102 @@ func (s *Service) getLibraryPath(ctx context.Context, mediaType string) (string,
 		// Fall back to root path
 		pathValue, err = s.configStore.Get(ctx, "library.root_path")
 		if err != nil {
-			return "/media", nil // Ultimate fallback
+			return "/media", nil, nil // Ultimate fallback
 		}
 	}
 
 	var path string
 	if err := json.Unmarshal(pathValue, &path); err != nil {
-		return "/media", nil
+		return "/media", nil, nil
+	}
+
+	return path, nil, nil
+}
+
+// maxRootFolderDepth bounds the walk up a media item's parents, e.g. episode,
+// season, series
+const maxRootFolderDepth = 4
+
+// itemRootFolder returns the root folder recorded on a media item or its
+// monitoring rule, walking up to its parents, so an episode is imported next
+// to the rest of its series
+func (s *Service) itemRootFolder(ctx context.Context, itemID int64) *int64 {
+	id := &itemID
+	for depth := 0; id != nil && depth < maxRootFolderDepth; depth++ {
+		item, err := s.queries.GetMediaItem(ctx, *id)
+		if err != nil {
+			return nil
+		}
+		if item.RootFolderID != nil {
+			return item.RootFolderID
+		}
+		if folderID, err := s.queries.GetMonitoringRuleRootFolder(ctx, item.ID); err == nil && folderID != nil {
+			return folderID
+		}
+		id = item.ParentID
 	}
+	return nil
+}
 
-	return path, nil
+// recordRootFolder stores the root folder an imported media item lives in on
+// it and any parents that don't have one yet
+func (s *Service) recordRootFolder(ctx context.Context, itemID int64, rootFolderID int64) {
+	id := &itemID
+	for depth := 0; id != nil && depth < maxRootFolderDepth; depth++ {
+		item, err := s.queries.GetMediaItem(ctx, *id)
+		if err != nil || item.RootFolderID != nil {
+			return
+		}
+		if err := s.queries.SetMediaItemRootFolder(ctx, generated.SetMediaItemRootFolderParams{
+			RootFolderID: &rootFolderID,
+			ID:           item.ID,
+		}); err != nil {
+			s.logger.Warn("failed to record root folder",
+				zap.Int64("media_item_id", item.ID),
+				zap.Error(err))
+			return
+		}
+		id = item.ParentID
+	}
+}
+
+// linkEpisodes links an imported multi-episode file to its further episodes and
+// marks all episodes it covers as having a file
+func (s *Service) linkEpisodes(ctx context.Context, req *ImportRequest, finalPath string, mediaItemID *int64, result *ImportResult) {
+	if mediaItemID == nil || len(req.ExtraMediaItemIDs) == 0 {
+		return
+	}
+	file, err := s.queries.GetMediaFileByPath(ctx, finalPath)
+	if err != nil {
+		s.logger.Warn("no media_files entry to link episodes to", zap.String("path", finalPath), zap.Error(err))
+		return
+	}
+
+	covered := []int64{*mediaItemID}
+	for _, id := range req.ExtraMediaItemIDs {
+		if id == *mediaItemID {
+			continue
+		}
+		if err := s.queries.LinkMediaFileEpisode(ctx, generated.LinkMediaFileEpisodeParams{
+			MediaFileID: file.ID,
+			MediaItemID: id,
+		}); err != nil {
+			s.logger.Warn("failed to link episode to file",
+				zap.Int64("media_file_id", file.ID),
+				zap.Int64("media_item_id", id),
+				zap.Error(err))
+			continue
+		}
+		covered = append(covered, id)
+		result.LinkedEpisodes = append(result.LinkedEpisodes, id)
+	}
+
+	if err := s.queries.SetEpisodesHasFile(ctx, generated.SetEpisodesHasFileParams{
+		FileID:       file.ID,
+		MediaItemIds: covered,
+	}); err != nil {
+		s.logger.Warn("failed to mark episodes as having a file", zap.Int64("media_file_id", file.ID), zap.Error(err))
+	}
 }
 
 func (s *Service) sanitizePath(name string, config *ImportConfig) string {
@@ -428,6 +960,14 @@ func (s *Service) sanitizePath(name string, config *ImportConfig) string {
 		}
 	}
 
+	// Control characters are never kept, NUL least of all
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+
 	// Trim whitespace and dots
 	name = strings.TrimSpace(name)
 	name = strings.Trim(name, ".")
@@ -435,45 +975,25 @@ func (s *Service) sanitizePath(name string, config *ImportConfig) string {
 	return name
 }
 
-func (s *Service) moveFile(src, dst string, useHardlinks bool) error {
-	// Check if src and dst are on the same filesystem for hardlinks
-	if useHardlinks {
-		// Try hardlink first
-		if err := os.Link(src, dst); err == nil {
-			// Hardlink successful, remove source
-			os.Remove(src)
-			return nil
-		} else {
-			// Hardlink failed, fall through to copy
-			s.logger.Debug("hardlink failed, falling back to copy", zap.Error(err))
-		}
-	}
-
-	// Copy file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
+// withinDir reports whether path is inside dir, following the symlinks of as
+// much of both as exists
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(resolvePath(dir), resolvePath(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
-	if _, err := dstFile.ReadFrom(srcFile); err != nil {
-		return err
+// resolvePath cleans path and follows the symlinks of its deepest existing
+// part
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
 	}
-
-	// Copy permissions
-	srcInfo, err := os.Stat(src)
-	if err == nil {
-		os.Chmod(dst, srcInfo.Mode())
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
 	}
-
-	// Remove source
-	return os.Remove(src)
+	return filepath.Join(resolvePath(parent), filepath.Base(path))
 }
 
 func (s *Service) findExtraFiles(mainFile string, extensions string) []string {
@@ -545,29 +1065,7 @@ func (s *Service) getFileSize(path string) (int64, error) {
 
 // applyMovieNamingTemplate applies naming template with token replacement
 func (s *Service) applyMovieNamingTemplate(template string, req *ImportRequest) string {
-	result := template
-
-	// Replace tokens
-	result = strings.ReplaceAll(result, "{Movie Title}", req.Title)
-
-	if req.Year != nil {
-		result = strings.ReplaceAll(result, "{Release Year}", fmt.Sprintf("%d", *req.Year))
-	} else {
-		result = strings.ReplaceAll(result, "{Release Year}", "")
-	}
-
-	if req.Quality != nil {
-		result = strings.ReplaceAll(result, "{Quality}", *req.Quality)
-	} else {
-		result = strings.ReplaceAll(result, "{Quality}", "")
-	}
-
-	// Clean up double spaces and brackets
-	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
-	result = regexp.MustCompile(`\[\s*\]`).ReplaceAllString(result, "")
-	result = regexp.MustCompile(`\(\s*\)`).ReplaceAllString(result, "")
-
-	return strings.TrimSpace(result)
+	return renderTemplate(template, movieTokenValues(req))
 }
 
 func (s *Service) applyMovieFolderTemplate(template string, req *ImportRequest) string {
@@ -575,85 +1073,13 @@ func (s *Service) applyMovieFolderTemplate(template string, req *ImportRequest)
 }
 
 func (s *Service) applyTVNamingTemplate(template string, req *ImportRequest) string {
-	result := template
-
-	result = strings.ReplaceAll(result, "{Series Title}", req.Title)
-
-	if req.Season != nil {
-		result = strings.ReplaceAll(result, "{Season}", fmt.Sprintf("%d", *req.Season))
-		result = regexp.MustCompile(`\{season:(\d+)\}`).ReplaceAllStringFunc(result, func(m string) string {
-			re := regexp.MustCompile(`\{season:(\d+)\}`)
-			matches := re.FindStringSubmatch(m)
-			if len(matches) > 1 {
-				format := fmt.Sprintf("%%0%sd", matches[1])
-				return fmt.Sprintf(format, *req.Season)
-			}
-			return m
-		})
-	}
-
-	if req.Episode != nil {
-		result = strings.ReplaceAll(result, "{Episode}", fmt.Sprintf("%d", *req.Episode))
-		result = regexp.MustCompile(`\{episode:(\d+)\}`).ReplaceAllStringFunc(result, func(m string) string {
-			re := regexp.MustCompile(`\{episode:(\d+)\}`)
-			matches := re.FindStringSubmatch(m)
-			if len(matches) > 1 {
-				format := fmt.Sprintf("%%0%sd", matches[1])
-				return fmt.Sprintf(format, *req.Episode)
-			}
-			return m
-		})
-	}
-
-	if req.EpisodeTitle != nil {
-		result = strings.ReplaceAll(result, "{Episode Title}", *req.EpisodeTitle)
-	} else {
-		result = strings.ReplaceAll(result, "{Episode Title}", "")
-	}
-
-	if req.Quality != nil {
-		result = strings.ReplaceAll(result, "{Quality}", *req.Quality)
-	} else {
-		result = strings.ReplaceAll(result, "{Quality}", "")
-	}
-
-	// Clean up
-	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
-	result = regexp.MustCompile(`\[\s*\]`).ReplaceAllString(result, "")
-	result = regexp.MustCompile(`\(\s*\)`).ReplaceAllString(result, "")
-	result = strings.ReplaceAll(result, " - -", " -")
-
-	return strings.TrimSpace(result)
+	return renderTemplate(template, episodeTokenValues(req))
 }
 
 func (s *Service) applyTVSeriesFolderTemplate(template string, req *ImportRequest) string {
-	result := template
-	result = strings.ReplaceAll(result, "{Series Title}", req.Title)
-
-	if req.Year != nil {
-		result = strings.ReplaceAll(result, "{Year}", fmt.Sprintf("%d", *req.Year))
-	} else {
-		result = strings.ReplaceAll(result, "{Year}", "")
-	}
-
-	return strings.TrimSpace(result)
+	return renderTemplate(template, episodeTokenValues(req))
 }
 
 func (s *Service) applyTVSeasonFolderTemplate(template string, req *ImportRequest) string {
-	result := template
-
-	if req.Season != nil {
-		result = strings.ReplaceAll(result, "{Season}", fmt.Sprintf("%d", *req.Season))
-		result = regexp.MustCompile(`\{season:(\d+)\}`).ReplaceAllStringFunc(result, func(m string) string {
-			re := regexp.MustCompile(`\{season:(\d+)\}`)
-			matches := re.FindStringSubmatch(m)
-			if len(matches) > 1 {
-				format := fmt.Sprintf("%%0%sd", matches[1])
-				return fmt.Sprintf(format, *req.Season)
-			}
-			return m
-		})
-	}
-
-	return strings.TrimSpace(result)
+	return renderTemplate(template, episodeTokenValues(req))
 }
@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// ErrFileNotStable is returned when the file being imported may still be
+// written to. Unlike other failures it is retryable: the import should be tried
+// again later instead of failing the download. It is the plugins error, so
+// downloader plugins can tell it apart too.
+var ErrFileNotStable = plugins.ErrFileNotStable
+
+// containerHeader is the signature a container format's files start with
+type containerHeader struct {
+	name   string
+	offset int
+	magic  []byte
+}
+
+var (
+	ebmlHeader = containerHeader{"Matroska", 0, []byte{0x1A, 0x45, 0xDF, 0xA3}}
+	mp4Header  = containerHeader{"MP4", 4, []byte("ftyp")}
+	aviHeader  = containerHeader{"AVI", 8, []byte("AVI ")}
+)
+
+// containerHeaders maps the extensions of known containers onto their headers
+var containerHeaders = map[string]containerHeader{
+	".mkv":  ebmlHeader,
+	".mka":  ebmlHeader,
+	".mk3d": ebmlHeader,
+	".webm": ebmlHeader,
+	".mp4":  mp4Header,
+	".m4v":  mp4Header,
+	".mov":  mp4Header,
+	".avi":  aviHeader,
+}
+
+// notStable returns the ErrFileNotStable of a file
+func notStable(path, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s %s", ErrFileNotStable, filepath.Base(path), fmt.Sprintf(format, args...))
+}
+
+// checkStable makes sure the file being imported is complete before it is
+// moved. Its size and modification time must not change over the interval, no
+// process may have it open for writing, and files of known containers must
+// look whole.
+func (s *Service) checkStable(ctx context.Context, req *ImportRequest, interval time.Duration) error {
+	path := req.SourcePath
+	before, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if before.IsDir() {
+		return nil
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if after.Size() != before.Size() {
+		return notStable(path, "grew from %d to %d bytes", before.Size(), after.Size())
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		return notStable(path, "was modified")
+	}
+
+	if openForWriting(path) {
+		return notStable(path, "is open for writing")
+	}
+
+	return s.checkContainer(ctx, req)
+}
+
+// checkContainer does a cheap sanity check of a file in a known container
+// format. Its header must be there and, when ffprobe is available, ffprobe
+// must be able to read it.
+func (s *Service) checkContainer(ctx context.Context, req *ImportRequest) error {
+	header, ok := containerHeaders[strings.ToLower(filepath.Ext(req.SourcePath))]
+	if !ok {
+		return nil
+	}
+	if err := header.check(req.SourcePath); err != nil {
+		return err
+	}
+	if req.MediaInfo != nil {
+		// Already read for naming
+		return nil
+	}
+
+	info, err := s.mediaProber(ctx).Probe(ctx, req.SourcePath)
+	if errors.Is(err, mediainfo.ErrUnavailable) {
+		return nil
+	}
+	if err != nil {
+		return notStable(req.SourcePath, "can't be read by ffprobe: %v", err)
+	}
+	req.MediaInfo = info
+	return nil
+}
+
+// check makes sure a file starts with the header of the container
+func (h containerHeader) check(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, h.offset+len(h.magic))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return notStable(path, "is too short to be a %s file", h.name)
+		}
+		return err
+	}
+	if !bytes.Equal(buf[h.offset:], h.magic) {
+		return notStable(path, "has no %s header", h.name)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package importer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// openForWriting reports whether a process has the file open for writing. It
+// looks through the open files of every process in /proc, skipping those it
+// isn't allowed to see.
+func openForWriting(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return false
+	}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", proc.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name()))
+			if err != nil || target != path {
+				continue
+			}
+			if writeFlags(filepath.Join(dir, "fdinfo", fd.Name())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFlags reports whether the flags in an fdinfo file open it for writing
+func writeFlags(fdinfo string) bool {
+	f, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "flags:")
+		if !ok {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(value), 8, 64)
+		if err != nil {
+			return false
+		}
+		mode := flags & syscall.O_ACCMODE
+		return mode == syscall.O_WRONLY || mode == syscall.O_RDWR
+	}
+	return false
+}
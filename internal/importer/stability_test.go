@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckStable(t *testing.T) {
+	s := &Service{logger: zap.NewNop()}
+	dir := t.TempDir()
+
+	stable := filepath.Join(dir, "stable.txt")
+	if err := os.WriteFile(stable, []byte("complete"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkStable(context.Background(), &ImportRequest{SourcePath: stable}, 20*time.Millisecond); err != nil {
+		t.Errorf("checkStable(stable) = %v, want nil", err)
+	}
+
+	growing := filepath.Join(dir, "growing.txt")
+	f, err := os.Create(growing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(20 * time.Millisecond)
+		f.Write([]byte("more"))
+	}()
+	err = s.checkStable(context.Background(), &ImportRequest{SourcePath: growing}, 100*time.Millisecond)
+	<-done
+	if !errors.Is(err, ErrFileNotStable) {
+		t.Errorf("checkStable(growing) = %v, want ErrFileNotStable", err)
+	}
+}
+
+func TestContainerHeaderCheck(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		file      string
+		content   []byte
+		wantError bool
+	}{
+		{"matroska", "movie.mkv", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x01, 0x00}, false},
+		{"mp4", "movie.mp4", []byte("\x00\x00\x00\x20ftypisom"), false},
+		{"missing header", "movie.mkv", []byte("not a matroska file"), true},
+		{"too short", "movie.mkv", []byte{0x1A, 0x45}, true},
+		{"empty", "movie.mp4", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := containerHeaders[filepath.Ext(path)].check(path)
+			if tt.wantError && !errors.Is(err, ErrFileNotStable) {
+				t.Errorf("check() = %v, want ErrFileNotStable", err)
+			}
+			if !tt.wantError && err != nil {
+				t.Errorf("check() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestOpenForWriting(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("open files are only looked up on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "movie.mkv")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openForWriting(path) {
+		t.Error("openForWriting() = false while open for writing, want true")
+	}
+	f.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if openForWriting(path) {
+		t.Error("openForWriting() = true while open for reading, want false")
+	}
+}
@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// Transfer modes, how an imported file gets from the download to the library
+const (
+	TransferMove     = "move"     // Move the file, copying it across filesystems
+	TransferCopy     = "copy"     // Copy the file, keeping the original
+	TransferHardlink = "hardlink" // Hardlink the file, keeping the original
+	TransferAuto     = "auto"     // Move disposable sources, hardlink or copy the others
+)
+
+// transferVerbs describe the resolved transfer modes in errors
+var transferVerbs = map[string]string{
+	TransferMove:     "move",
+	TransferCopy:     "copy",
+	TransferHardlink: "hardlink",
+	TransferAuto:     "hardlink or copy",
+}
+
+// largeCopySize is the size from which the progress of a copy is logged
+const largeCopySize = 1 << 30
+
+// ValidTransferMode reports whether mode is a transfer mode. The empty mode
+// is valid and means a move.
+func ValidTransferMode(mode string) bool {
+	switch mode {
+	case "", TransferMove, TransferCopy, TransferHardlink, TransferAuto:
+		return true
+	}
+	return false
+}
+
+// transferMode resolves the transfer mode of an import request. Auto moves
+// disposable sources, like the files of an NZB download, and keeps the others,
+// e.g. torrents that are still seeding, hardlinking them when hardlinks are
+// enabled.
+func transferMode(req *ImportRequest, config *ImportConfig) string {
+	switch req.TransferMode {
+	case "":
+		return TransferMove
+	case TransferAuto:
+		if req.Disposable {
+			return TransferMove
+		}
+		if config.UseHardlinks {
+			return TransferAuto
+		}
+		return TransferCopy
+	}
+	return req.TransferMode
+}
+
+// transferFile puts a file at dst using a resolved transfer mode. In auto mode
+// the file is hardlinked, or copied when it can't be, e.g. across filesystems.
+func (s *Service) transferFile(src, dst, mode string) error {
+	switch mode {
+	case TransferCopy:
+		return s.copyFile(src, dst)
+	case TransferHardlink:
+		return linkFile(src, dst)
+	case TransferAuto:
+		if err := linkFile(src, dst); err != nil {
+			s.logger.Debug("hardlink failed, falling back to copy", zap.String("file", src), zap.Error(err))
+			return s.copyFile(src, dst)
+		}
+		return nil
+	default:
+		return s.moveFile(src, dst)
+	}
+}
+
+// moveFile moves a file, copying it when the destination is on another
+// filesystem. The source is only removed once the copy is on disk.
+func (s *Service) moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := s.copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies a file with its permissions and syncs it to disk. A partial
+// copy is removed when the copy fails.
+func (s *Service) copyFile(src, dst string) (err error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dstFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	var w io.Writer = dstFile
+	if info.Size() >= largeCopySize {
+		w = &copyProgress{w: dstFile, logger: s.logger, file: src, size: info.Size()}
+	}
+	if _, err := io.Copy(w, srcFile); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+	if err := dstFile.Sync(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, info.Mode())
+}
+
+// linkFile hardlinks a file, replacing a file already at dst
+func linkFile(src, dst string) error {
+	tmp := dst + ".nimbus-link"
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// copyProgress logs the progress of a large copy every tenth of the file
+type copyProgress struct {
+	w       io.Writer
+	logger  *zap.Logger
+	file    string
+	size    int64
+	written int64
+	logged  int64 // Tenths logged
+}
+
+func (p *copyProgress) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if tenths := p.written * 10 / p.size; tenths > p.logged && tenths < 10 {
+		p.logged = tenths
+		p.logger.Info("copying file",
+			zap.String("file", p.file),
+			zap.Int64("percent", tenths*10),
+			zap.Int64("bytes", p.written))
+	}
+	return n, err
+}
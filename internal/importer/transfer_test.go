@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestTransferMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          ImportRequest
+		useHardlinks bool
+		want         string
+	}{
+		{"default moves", ImportRequest{}, true, TransferMove},
+		{"explicit copy", ImportRequest{TransferMode: TransferCopy}, true, TransferCopy},
+		{"explicit hardlink", ImportRequest{TransferMode: TransferHardlink}, false, TransferHardlink},
+		{"auto moves disposable", ImportRequest{TransferMode: TransferAuto, Disposable: true}, true, TransferMove},
+		{"auto links with hardlinks", ImportRequest{TransferMode: TransferAuto}, true, TransferAuto},
+		{"auto copies without hardlinks", ImportRequest{TransferMode: TransferAuto}, false, TransferCopy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transferMode(&tt.req, &ImportConfig{UseHardlinks: tt.useHardlinks}); got != tt.want {
+				t.Errorf("transferMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if ValidTransferMode("symlink") {
+		t.Error("ValidTransferMode(symlink) = true, want false")
+	}
+	if !ValidTransferMode("") {
+		t.Error("ValidTransferMode(\"\") = false, want true")
+	}
+}
+
+func TestTransferFile(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+
+	tests := []struct {
+		mode       string
+		keepSource bool
+		linked     bool
+	}{
+		{TransferMove, false, false},
+		{TransferCopy, true, false},
+		{TransferHardlink, true, true},
+		{TransferAuto, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "download.mkv")
+			dst := filepath.Join(dir, "library.mkv")
+			if err := os.WriteFile(src, []byte("video"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			// An existing file at the destination is replaced
+			if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.transferFile(src, dst, tt.mode); err != nil {
+				t.Fatalf("transferFile() error = %v", err)
+			}
+
+			data, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "video" {
+				t.Errorf("destination = %q, want %q", data, "video")
+			}
+			dstInfo, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dstInfo.Mode().Perm() != 0640 {
+				t.Errorf("destination mode = %v, want 0640", dstInfo.Mode().Perm())
+			}
+
+			srcInfo, err := os.Stat(src)
+			if !tt.keepSource {
+				if !os.IsNotExist(err) {
+					t.Error("source still exists after a move")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("source is gone after a %s: %v", tt.mode, err)
+			}
+			if linked := os.SameFile(srcInfo, dstInfo); linked != tt.linked {
+				t.Errorf("destination linked to source = %v, want %v", linked, tt.linked)
+			}
+			if _, err := os.Stat(dst + ".nimbus-link"); !os.IsNotExist(err) {
+				t.Error("temporary link left behind")
+			}
+		})
+	}
+}
+
+func TestTransferFileAutoFallsBackToCopy(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	dir := t.TempDir()
+	src := filepath.Join(dir, "download.mkv")
+	if err := os.WriteFile(src, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Block the temporary link name so the link fails, as it would across
+	// filesystems
+	dst := filepath.Join(dir, "library.mkv")
+	if err := os.Mkdir(dst+".nimbus-link", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst+".nimbus-link", "keep"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.transferFile(src, dst, TransferAuto); err != nil {
+		t.Fatalf("transferFile() error = %v", err)
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(srcInfo, dstInfo) {
+		t.Error("destination is a link, want a copy")
+	}
+}
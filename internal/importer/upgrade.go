@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ErrNotUpgrade is returned when an import would replace a media item's existing
+// file without improving on it. Setting Force on the request imports anyway.
+var ErrNotUpgrade = errors.New("not an upgrade")
+
+// upgradePlan describes the existing files an import replaces
+type upgradePlan struct {
+	Files []generated.MediaFile // Files of the media item to replace
+	From  string                // Quality of the best existing file
+	To    string                // Quality of the imported file
+}
+
+// planUpgrade decides whether an import may replace the files its media item
+// already has. It returns nil when there is nothing to replace and ErrNotUpgrade
+// when the import is rejected.
+func (s *Service) planUpgrade(ctx context.Context, req *ImportRequest, config *ImportConfig) (*upgradePlan, error) {
+	if req.MediaItemID == nil {
+		return nil, nil
+	}
+
+	existing, err := s.queries.ListMediaFilesByItem(ctx, req.MediaItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing files: %w", err)
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	detector := quality.NewDetector()
+	newInfo := importQuality(req, detector)
+
+	// Files that failed verification are replaced whatever their quality
+	best := &quality.DetectedQualityInfo{QualityName: "Unknown"}
+	for _, file := range existing {
+		if file.Status != library.FileStatusOK {
+			continue
+		}
+		info := fileQuality(file, detector)
+		if qualityRank(info) > qualityRank(best) {
+			best = info
+		}
+	}
+
+	plan := &upgradePlan{
+		Files: existing,
+		From:  best.QualityName,
+		To:    newInfo.QualityName,
+	}
+	if req.Force {
+		return plan, nil
+	}
+
+	if !config.EnableQualityUpgrades {
+		return nil, fmt.Errorf("%w: quality upgrades are disabled", ErrNotUpgrade)
+	}
+
+	// An existing file of unknown quality can't be judged, so the newer grab wins
+	bestRank := qualityRank(best)
+	if bestRank > 0 && qualityRank(newInfo) <= bestRank {
+		return nil, fmt.Errorf("%w: existing file is %s, new file is %s", ErrNotUpgrade, plan.From, plan.To)
+	}
+
+	// Stop upgrading once the existing file meets the monitoring rule's cutoff
+	profile, cutoff, err := s.qualityCutoff(ctx, *req.MediaItemID)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil && !profile.UpgradeAllowed {
+		return nil, fmt.Errorf("%w: quality profile %q does not allow upgrades", ErrNotUpgrade, profile.Name)
+	}
+	if cutoff != nil && bestRank > 0 && bestRank >= definitionRank(cutoff) {
+		return nil, fmt.Errorf("%w: existing file (%s) already meets the %s cutoff", ErrNotUpgrade, plan.From, cutoff.Title)
+	}
+
+	return plan, nil
+}
+
+// qualityCutoff returns the quality profile that applies to a media item and its
+// cutoff quality, either of which may be nil
+func (s *Service) qualityCutoff(ctx context.Context, mediaItemID int64) (*generated.QualityProfile, *generated.QualityDefinition, error) {
+	profileID, err := s.queries.GetEffectiveQualityProfileID(ctx, mediaItemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get quality profile: %w", err)
+	}
+	if profileID == nil {
+		return nil, nil, nil
+	}
+
+	profile, err := s.queries.GetQualityProfile(ctx, *profileID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get quality profile: %w", err)
+	}
+	if profile.CutoffQualityID == nil {
+		return &profile, nil, nil
+	}
+
+	cutoff, err := s.queries.GetQualityDefinition(ctx, *profile.CutoffQualityID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get cutoff quality: %w", err)
+	}
+	return &profile, &cutoff, nil
+}
+
+// replaceFiles removes the files of an upgrade from the library. Files are moved
+// to the recycle bin when one is configured, otherwise they are deleted.
+func (s *Service) replaceFiles(ctx context.Context, files []generated.MediaFile, config *ImportConfig, result *ImportResult) error {
+	for _, file := range files {
+		if err := s.recycleFile(file.Path, config.RecycleBinPath); err != nil {
+			return fmt.Errorf("failed to remove replaced file %s: %w", file.Path, err)
+		}
+		if err := s.queries.DeleteMediaFile(ctx, file.ID); err != nil {
+			s.logger.Warn("failed to delete replaced media_files entry",
+				zap.String("path", file.Path),
+				zap.Error(err))
+		}
+		result.Replaced = append(result.Replaced, file.Path)
+	}
+	return nil
+}
+
+// recycleFile moves a file into the recycle bin, or deletes it when the recycle
+// bin is disabled. A file that is already gone is not an error.
+func (s *Service) recycleFile(path, recycleBin string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	if recycleBin == "" {
+		return os.Remove(path)
+	}
+
+	if err := os.MkdirAll(recycleBin, 0755); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(recycleBin, filepath.Base(path))
+	if _, err := os.Stat(dst); err == nil {
+		ext := filepath.Ext(dst)
+		dst = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(dst, ext), time.Now().Unix(), ext)
+	}
+
+	return s.moveFile(path, dst)
+}
+
+// splitReplaced separates the files at the import destination, which have to go
+// before the new file is moved into place, from the rest
+func splitReplaced(files []generated.MediaFile, destination string) (atDestination, others []generated.MediaFile) {
+	for _, file := range files {
+		if file.Path == destination {
+			atDestination = append(atDestination, file)
+		} else {
+			others = append(others, file)
+		}
+	}
+	return atDestination, others
+}
+
+// importQuality detects the quality of the file being imported, from its name or
+// failing that from the release title or quality given with the request
+func importQuality(req *ImportRequest, detector *quality.Detector) *quality.DetectedQualityInfo {
+	info := detector.DetectQuality(filepath.Base(req.SourcePath))
+	if info.Resolution == nil && req.ReleaseTitle != nil {
+		info = detector.DetectQuality(*req.ReleaseTitle)
+	}
+	if info.Resolution == nil && req.Quality != nil {
+		info = detector.DetectQuality(*req.Quality)
+	}
+	return info
+}
+
+// definitionRank ranks a quality definition on the same scale as qualityRank
+func definitionRank(def *generated.QualityDefinition) int {
+	info := &quality.DetectedQualityInfo{
+		Source:  def.Source,
+		IsRemux: strings.HasPrefix(def.Name, "Remux") || (def.Modifier != nil && strings.EqualFold(*def.Modifier, "remux")),
+	}
+	if def.Resolution != nil {
+		resolution := int(*def.Resolution)
+		info.Resolution = &resolution
+	}
+	return qualityRank(info)
+}
+
+// placeFile moves the imported file to its destination and replaces the files it
+// upgrades. A file already at the destination is replaced first so it isn't
+// overwritten; the others only once the new file is in place.
+func (s *Service) placeFile(ctx context.Context, req *ImportRequest, finalPath string, upgrade *upgradePlan, config *ImportConfig, result *ImportResult) error {
+	var replaceAfter []generated.MediaFile
+	if upgrade != nil {
+		var replaceFirst []generated.MediaFile
+		replaceFirst, replaceAfter = splitReplaced(upgrade.Files, finalPath)
+		if err := s.replaceFiles(ctx, replaceFirst, config, result); err != nil {
+			return err
+		}
+	}
+
+	mode := transferMode(req, config)
+	if err := s.transferFile(req.SourcePath, finalPath, mode); err != nil {
+		return fmt.Errorf("failed to %s file: %w", transferVerbs[mode], err)
+	}
+	result.MovedFiles = append(result.MovedFiles, finalPath)
+
+	if upgrade == nil {
+		return nil
+	}
+
+	// The new file is in place, so failing to clear out an old one isn't fatal
+	if err := s.replaceFiles(ctx, replaceAfter, config, result); err != nil {
+		s.logger.Warn("failed to replace existing file", zap.Error(err))
+	}
+	result.UpgradeFrom = upgrade.From
+	result.UpgradeTo = upgrade.To
+
+	s.logger.Info("upgraded existing file",
+		zap.Int64("media_item_id", *req.MediaItemID),
+		zap.String("from", upgrade.From),
+		zap.String("to", upgrade.To),
+		zap.Bool("forced", req.Force),
+		zap.Strings("replaced", result.Replaced))
+
+	return nil
+}
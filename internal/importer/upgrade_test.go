@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"go.uber.org/zap"
+)
+
+func TestDefinitionRankMatchesDetectedQuality(t *testing.T) {
+	resolution := int32(1080)
+	bluray := "BLURAY"
+	cutoff := &generated.QualityDefinition{Name: "Bluray-1080p", Resolution: &resolution, Source: &bluray}
+	detector := quality.NewDetector()
+
+	if got, want := definitionRank(cutoff), qualityRank(detector.DetectQuality("Movie.2020.1080p.BluRay.x264")); got != want {
+		t.Errorf("definitionRank() = %d, want %d for the same quality", got, want)
+	}
+	if definitionRank(cutoff) <= qualityRank(detector.DetectQuality("Movie.2020.1080p.WEB-DL.x264")) {
+		t.Error("Bluray-1080p cutoff should rank above a 1080p WEB-DL")
+	}
+
+	remux := &generated.QualityDefinition{Name: "Remux-1080p", Resolution: &resolution, Source: &bluray}
+	if definitionRank(remux) <= definitionRank(cutoff) {
+		t.Error("Remux-1080p should rank above Bluray-1080p")
+	}
+}
+
+func TestRecycleFile(t *testing.T) {
+	s := NewService(nil, nil, zap.NewNop())
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "recycle")
+
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(dir, "movie.mkv")
+		if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.recycleFile(path, bin); err != nil {
+			t.Fatalf("recycleFile() error = %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("recycled file still exists at %s", path)
+		}
+	}
+
+	// A second file of the same name must not overwrite the first
+	entries, err := os.ReadDir(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("recycle bin has %d files, want 2", len(entries))
+	}
+
+	// Already gone is fine
+	if err := s.recycleFile(filepath.Join(dir, "missing.mkv"), bin); err != nil {
+		t.Errorf("recycleFile() of a missing file error = %v", err)
+	}
+}
+
+func TestFillRelease(t *testing.T) {
+	title := "Show.S01E05.1080p.WEB-DL.H264-GROUP"
+	req := &ImportRequest{SourcePath: "/downloads/abc123.mkv", ReleaseTitle: &title}
+	info := fillRelease(req)
+	if req.Quality == nil || *req.Quality != info.QualityName || info.QualityName == "Unknown" {
+		t.Errorf("fillRelease() quality = %v, want %q", req.Quality, info.QualityName)
+	}
+	if req.ReleaseGroup == nil || *req.ReleaseGroup != "GROUP" {
+		t.Errorf("fillRelease() release group = %v, want GROUP", req.ReleaseGroup)
+	}
+
+	// An obfuscated file is judged by the release it was imported from
+	file := generated.MediaFile{Path: "/media/tv/abc123.mkv", ReleaseTitle: &title}
+	if got := fileQuality(file, quality.NewDetector()); got.Resolution == nil || *got.Resolution != 1080 {
+		t.Errorf("fileQuality() resolution = %v, want 1080", got.Resolution)
+	}
+
+	given := "HDTV 720p"
+	req = &ImportRequest{ReleaseTitle: &title, Quality: &given}
+	fillRelease(req)
+	if *req.Quality != "HDTV 720p" {
+		t.Errorf("fillRelease() replaced quality %q", *req.Quality)
+	}
+}
+
+func TestMeetsCutoff(t *testing.T) {
+	resolution := int32(1080)
+	webdl := "WEBDL"
+	cutoff := &generated.QualityDefinition{Name: "WEBDL-1080p", Title: "WEBDL-1080p", Resolution: &resolution, Source: &webdl}
+	upgradable := &generated.QualityProfile{Name: "HD", UpgradeAllowed: true}
+	detector := quality.NewDetector()
+
+	tests := []struct {
+		name    string
+		profile *generated.QualityProfile
+		cutoff  *generated.QualityDefinition
+		release string
+		want    bool
+	}{
+		{"below cutoff", upgradable, cutoff, "Show.S01E01.720p.WEB-DL.x264", false},
+		{"at cutoff", upgradable, cutoff, "Show.S01E01.1080p.WEB-DL.x264", true},
+		{"above cutoff", upgradable, cutoff, "Show.S01E01.1080p.BluRay.x264", true},
+		{"unknown quality", upgradable, cutoff, "Show.S01E01", false},
+		{"no cutoff", upgradable, nil, "Show.S01E01.2160p.BluRay.x265", false},
+		{"upgrades not allowed", &generated.QualityProfile{Name: "Any"}, cutoff, "Show.S01E01.480p.HDTV", true},
+		{"no profile", nil, nil, "Show.S01E01.1080p.WEB-DL.x264", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank := qualityRank(detector.DetectQuality(tt.release))
+			if got := meetsCutoff(tt.profile, tt.cutoff, rank); got != tt.want {
+				t.Errorf("meetsCutoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package importlists
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSyncInterval is how often lists are synced when no interval is given
+	defaultSyncInterval = 360
+
+	// minSyncInterval keeps lists from being synced more often than the
+	// import_list_sync job runs
+	minSyncInterval = 15
+
+	// defaultRunLimit is how many runs are listed when no limit is given
+	defaultRunLimit = 20
+)
+
+// Handler handles import list HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new import list handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "import-lists-handler")),
+	}
+}
+
+// SetupRoutes registers the import list routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Route("/import-lists", func(r chi.Router) {
+		r.Get("/", h.ListLists)
+		r.Post("/", h.CreateList)
+		r.Get("/runs/{runId}", h.GetRun)
+		r.Get("/{id}", h.GetList)
+		r.Put("/{id}", h.UpdateList)
+		r.Delete("/{id}", h.DeleteList)
+		r.Post("/{id}/auth", h.StartAuthorization)
+		r.Get("/{id}/auth", h.GetAuthorization)
+		r.Get("/{id}/lists", h.ListAvailableLists)
+		r.Post("/{id}/sync", h.SyncList)
+		r.Get("/{id}/runs", h.ListRuns)
+	})
+}
+
+// listRequest is the body of a create or update request
+type listRequest struct {
+	Name                string          `json:"name"`
+	Type                string          `json:"type"`
+	Enabled             *bool           `json:"enabled,omitempty"` // Defaults to true
+	Settings            json.RawMessage `json:"settings"`
+	ClientSecret        string          `json:"client_secret,omitempty"` // Of the Trakt API app; kept when empty on updates
+	QualityProfileID    *int32          `json:"quality_profile_id"`
+	RootFolderID        *int64          `json:"root_folder_id"`
+	SearchOnAdd         *bool           `json:"search_on_add,omitempty"`         // Defaults to true
+	RemovalAction       RemovalAction   `json:"removal_action,omitempty"`        // Defaults to ignore
+	SyncIntervalMinutes int32           `json:"sync_interval_minutes,omitempty"` // Defaults to 360
+}
+
+// validate normalizes a request and returns a message when it is invalid
+func (req *listRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.ClientSecret = strings.TrimSpace(req.ClientSecret)
+	if req.Name == "" {
+		return "name is required"
+	}
+
+	switch req.Type {
+	case TypeTrakt:
+		var settings TraktSettings
+		if len(req.Settings) > 0 {
+			if err := json.Unmarshal(req.Settings, &settings); err != nil {
+				return "invalid trakt settings"
+			}
+		}
+		settings.ClientID = strings.TrimSpace(settings.ClientID)
+		settings.Username = strings.TrimSpace(settings.Username)
+		settings.List = strings.TrimSpace(settings.List)
+		if settings.ClientID == "" {
+			return "settings.client_id is required"
+		}
+		if settings.List == "" {
+			settings.List = traktWatchlist
+		}
+		req.Settings, _ = json.Marshal(settings)
+	default:
+		return `type must be "trakt"`
+	}
+
+	if req.RemovalAction == "" {
+		req.RemovalAction = RemovalIgnore
+	}
+	if !req.RemovalAction.IsValid() {
+		return `removal_action must be "ignore", "unmonitor" or "delete"`
+	}
+	if req.SyncIntervalMinutes == 0 {
+		req.SyncIntervalMinutes = defaultSyncInterval
+	}
+	if req.SyncIntervalMinutes < minSyncInterval {
+		return "sync_interval_minutes must be at least " + strconv.Itoa(minSyncInterval)
+	}
+	return ""
+}
+
+// params returns the list settings of a validated request
+func (req *listRequest) params() ListParams {
+	return ListParams{
+		Name:                req.Name,
+		Type:                req.Type,
+		Enabled:             req.Enabled == nil || *req.Enabled,
+		Settings:            req.Settings,
+		QualityProfileID:    req.QualityProfileID,
+		RootFolderID:        req.RootFolderID,
+		SearchOnAdd:         req.SearchOnAdd == nil || *req.SearchOnAdd,
+		RemovalAction:       req.RemovalAction,
+		SyncIntervalMinutes: req.SyncIntervalMinutes,
+		ClientSecret:        req.ClientSecret,
+	}
+}
+
+// validTargets checks that the quality profile and root folder of a request
+// exist, responding with an error when they don't
+func (h *Handler) validTargets(w http.ResponseWriter, r *http.Request, req *listRequest) bool {
+	if req.QualityProfileID != nil {
+		exists, err := h.service.monitoring.QualityProfileExists(r.Context(), int(*req.QualityProfileID))
+		if err != nil {
+			httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to look up quality profile")
+			return false
+		}
+		if !exists {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Quality profile not found")
+			return false
+		}
+	}
+	if req.RootFolderID != nil {
+		exists, err := h.service.monitoring.RootFolderExists(r.Context(), *req.RootFolderID)
+		if err != nil {
+			httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to look up root folder")
+			return false
+		}
+		if !exists {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Root folder not found")
+			return false
+		}
+	}
+	return true
+}
+
+// listResponse is an import list as returned by the API. Credentials are never
+// returned; authorized says whether the account was authorized.
+type listResponse struct {
+	ID                  int64           `json:"id"`
+	Name                string          `json:"name"`
+	Type                string          `json:"type"`
+	Enabled             bool            `json:"enabled"`
+	Settings            json.RawMessage `json:"settings"`
+	QualityProfileID    *int32          `json:"quality_profile_id"`
+	RootFolderID        *int64          `json:"root_folder_id"`
+	SearchOnAdd         bool            `json:"search_on_add"`
+	RemovalAction       string          `json:"removal_action"`
+	SyncIntervalMinutes int32           `json:"sync_interval_minutes"`
+	Authorized          bool            `json:"authorized"`
+	LastSyncAt          *time.Time      `json:"last_sync_at"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+func (h *Handler) present(r *http.Request, list *generated.ImportList) listResponse {
+	settings := json.RawMessage(list.Settings)
+	if len(settings) == 0 {
+		settings = json.RawMessage("{}")
+	}
+	response := listResponse{
+		ID:                  list.ID,
+		Name:                list.Name,
+		Type:                list.Type,
+		Enabled:             list.Enabled,
+		Settings:            settings,
+		QualityProfileID:    list.QualityProfileID,
+		RootFolderID:        list.RootFolderID,
+		SearchOnAdd:         list.SearchOnAdd,
+		RemovalAction:       list.RemovalAction,
+		SyncIntervalMinutes: list.SyncIntervalMinutes,
+		Authorized:          h.service.Authorized(r.Context(), list),
+		CreatedAt:           list.CreatedAt.Time,
+		UpdatedAt:           list.UpdatedAt.Time,
+	}
+	if list.LastSyncAt.Valid {
+		response.LastSyncAt = &list.LastSyncAt.Time
+	}
+	return response
+}
+
+// runResponse is a sync run as returned by the API
+type runResponse struct {
+	ID           int64             `json:"id"`
+	ListID       int64             `json:"list_id"`
+	Status       string            `json:"status"`
+	Added        int32             `json:"added"`
+	Existing     int32             `json:"existing"`
+	Failed       int32             `json:"failed"`
+	Removed      int32             `json:"removed"`
+	ErrorMessage *string           `json:"error_message,omitempty"`
+	StartedAt    time.Time         `json:"started_at"`
+	FinishedAt   *time.Time        `json:"finished_at"`
+	Items        []runItemResponse `json:"items,omitempty"`
+}
+
+// runItemResponse is what a sync run did with an entry
+type runItemResponse struct {
+	Status      string  `json:"status"`
+	MediaType   string  `json:"media_type"`
+	TMDBID      *string `json:"tmdb_id"`
+	Title       string  `json:"title"`
+	Year        *int32  `json:"year,omitempty"`
+	MediaItemID *int64  `json:"media_item_id,omitempty"`
+	Message     *string `json:"message,omitempty"`
+}
+
+func toRunResponse(run generated.ImportListRun) runResponse {
+	response := runResponse{
+		ID:           run.ID,
+		ListID:       run.ListID,
+		Status:       run.Status,
+		Added:        run.Added,
+		Existing:     run.Existing,
+		Failed:       run.Failed,
+		Removed:      run.Removed,
+		ErrorMessage: run.ErrorMessage,
+		StartedAt:    run.StartedAt.Time,
+	}
+	if run.FinishedAt.Valid {
+		response.FinishedAt = &run.FinishedAt.Time
+	}
+	return response
+}
+
+func parseListID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// getList loads the list of a request, responding with an error when it
+// can't be found
+func (h *Handler) getList(w http.ResponseWriter, r *http.Request) (*generated.ImportList, bool) {
+	id, ok := parseListID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid import list ID")
+		return nil, false
+	}
+	list, err := h.service.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Import list not found")
+		return nil, false
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to get import list")
+		return nil, false
+	}
+	return list, true
+}
+
+// ListLists returns all import lists
+// GET /api/import-lists
+func (h *Handler) ListLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.service.List(r.Context())
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list import lists")
+		return
+	}
+
+	response := make([]listResponse, len(lists))
+	for i := range lists {
+		response[i] = h.present(r, &lists[i])
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"import_lists": response})
+}
+
+// GetList returns an import list
+// GET /api/import-lists/{id}
+func (h *Handler) GetList(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, h.present(r, list))
+}
+
+// CreateList adds an import list
+// POST /api/import-lists
+func (h *Handler) CreateList(w http.ResponseWriter, r *http.Request) {
+	var req listRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !h.validTargets(w, r, &req) {
+		return
+	}
+
+	list, err := h.service.Create(r.Context(), req.params())
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create import list")
+		return
+	}
+
+	h.logger.Info("import list created", zap.Int64("id", list.ID), zap.String("name", list.Name), zap.String("type", list.Type))
+	httputil.RespondJSON(w, http.StatusCreated, h.present(r, list))
+}
+
+// UpdateList replaces the settings of an import list
+// PUT /api/import-lists/{id}
+func (h *Handler) UpdateList(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+
+	var req listRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Type == "" {
+		req.Type = existing.Type
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.Type != existing.Type {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "type can't be changed")
+		return
+	}
+	if !h.validTargets(w, r, &req) {
+		return
+	}
+
+	list, err := h.service.Update(r.Context(), existing.ID, req.params())
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Import list not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to update import list")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, h.present(r, list))
+}
+
+// DeleteList removes an import list. Media it added stays in the library.
+// DELETE /api/import-lists/{id}
+func (h *Handler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseListID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid import list ID")
+		return
+	}
+
+	err := h.service.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Import list not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to delete import list")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartAuthorization starts the device-code authorization of the account of
+// a list. The user enters the returned code at the verification URL.
+// POST /api/import-lists/{id}/auth
+func (h *Handler) StartAuthorization(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+
+	auth, err := h.service.StartAuthorization(r.Context(), list)
+	if errors.Is(err, ErrClientSecretRequired) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to start import list authorization", zap.Int64("list_id", list.ID))
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, auth)
+}
+
+// GetAuthorization returns whether the account of a list is authorized and
+// the state of the authorization started last
+// GET /api/import-lists/{id}/auth
+func (h *Handler) GetAuthorization(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"authorized": h.service.Authorized(r.Context(), list),
+		"device":     h.service.Authorization(list.ID),
+	})
+}
+
+// ListAvailableLists returns the lists of the authorized account the import
+// list can be set to sync
+// GET /api/import-lists/{id}/lists
+func (h *Handler) ListAvailableLists(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := h.service.AvailableLists(r.Context(), list)
+	if errors.Is(err, errTraktUnauthorized) {
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to list available lists", zap.Int64("list_id", list.ID))
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"lists": options})
+}
+
+// SyncList starts syncing a list in the background and returns the run
+// GET /api/import-lists/runs/{runId} shows its progress.
+// POST /api/import-lists/{id}/sync
+func (h *Handler) SyncList(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseListID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid import list ID")
+		return
+	}
+
+	run, err := h.service.StartSync(r.Context(), id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Import list not found")
+	case errors.Is(err, ErrSyncRunning):
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+	case err != nil:
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to start import list sync")
+	default:
+		httputil.RespondJSON(w, http.StatusAccepted, toRunResponse(*run))
+	}
+}
+
+// ListRuns returns the latest sync runs of a list, newest first
+// GET /api/import-lists/{id}/runs?limit=20
+func (h *Handler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.getList(w, r)
+	if !ok {
+		return
+	}
+
+	limit := int32(defaultRunLimit)
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 500 {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = int32(n)
+	}
+
+	runs, err := h.service.Runs(r.Context(), list.ID, limit)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list import list runs")
+		return
+	}
+	response := make([]runResponse, len(runs))
+	for i, run := range runs {
+		response[i] = toRunResponse(run)
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"runs": response})
+}
+
+// GetRun returns a sync run with what it did with each entry, optionally only
+// the entries with one status
+// GET /api/import-lists/runs/{runId}?status=failed
+func (h *Handler) GetRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "runId"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", ItemAdded, ItemExisting, ItemFailed, ItemRemoved:
+	default:
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown status: "+status)
+		return
+	}
+
+	run, err := h.service.GetRun(r.Context(), id, status)
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Run not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to get import list run")
+		return
+	}
+
+	response := toRunResponse(run.ImportListRun)
+	response.Items = make([]runItemResponse, len(run.Items))
+	for i, item := range run.Items {
+		response.Items[i] = runItemResponse{
+			Status:      item.Status,
+			MediaType:   item.MediaType,
+			TMDBID:      item.TmdbID,
+			Title:       item.Title,
+			Year:        item.Year,
+			MediaItemID: item.MediaItemID,
+			Message:     item.Message,
+		}
+	}
+	httputil.RespondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,793 @@
+package importlists
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/requests"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// RemovalAction is what happens to the media of entries removed from a list
+type RemovalAction string
+
+const (
+	RemovalIgnore    RemovalAction = "ignore"    // Keep and monitor it
+	RemovalUnmonitor RemovalAction = "unmonitor" // Disable its monitoring rule
+	RemovalDelete    RemovalAction = "delete"    // Delete it when the list added it, otherwise unmonitor it
+)
+
+// IsValid reports whether a removal action is known
+func (a RemovalAction) IsValid() bool {
+	switch a {
+	case RemovalIgnore, RemovalUnmonitor, RemovalDelete:
+		return true
+	}
+	return false
+}
+
+// Run statuses
+const (
+	RunRunning = "running"
+	RunSuccess = "success"
+	RunFailed  = "failed"
+)
+
+// Run item statuses
+const (
+	ItemAdded    = "added"    // Added to the library or newly monitored
+	ItemExisting = "existing" // Skipped, already in the library and monitored
+	ItemFailed   = "failed"   // Couldn't be looked up or added; retried next sync
+	ItemRemoved  = "removed"  // Gone from the list
+)
+
+// importListSource says an import list added or deleted a media item
+const importListSource = "import_list"
+
+var (
+	// ErrNotFound is returned when an import list or run doesn't exist
+	ErrNotFound = errors.New("import list not found")
+
+	// ErrSyncRunning is returned when a list is synced while it is syncing
+	ErrSyncRunning = errors.New("import list is already syncing")
+
+	// ErrClientSecretRequired is returned when authorizing without a client secret
+	ErrClientSecretRequired = errors.New("client_secret is required to authorize")
+)
+
+// Service manages import lists and syncs them into the library
+type Service struct {
+	queries    *generated.Queries
+	config     *configstore.Store
+	titles     *requests.Service
+	media      media.Service
+	monitoring *monitoring.Service
+	searcher   *monitoring.AutoSearcher // nil when plugins are unavailable
+	history    *history.Service
+	logger     *zap.Logger
+
+	http     *http.Client
+	traktURL string
+
+	mu      sync.Mutex
+	syncing map[int64]bool        // Lists being synced
+	auths   map[int64]*DeviceAuth // Device authorizations in progress, by list
+}
+
+// NewService creates a new import list service. Titles are added to the
+// library the way approved requests are.
+func NewService(queries *generated.Queries, config *configstore.Store, titles *requests.Service, mediaService media.Service, monitoringSvc *monitoring.Service, logger *zap.Logger) *Service {
+	return &Service{
+		queries:    queries,
+		config:     config,
+		titles:     titles,
+		media:      mediaService,
+		monitoring: monitoringSvc,
+		logger:     logger.With(zap.String("component", "import-lists")),
+		http:       &http.Client{Timeout: 30 * time.Second},
+		traktURL:   traktAPIURL,
+		syncing:    make(map[int64]bool),
+		auths:      make(map[int64]*DeviceAuth),
+	}
+}
+
+// SetSearcher enables the initial search for new entries
+func (s *Service) SetSearcher(searcher *monitoring.AutoSearcher) {
+	s.searcher = searcher
+}
+
+// SetHistory sets where media deleted from the library is recorded
+func (s *Service) SetHistory(history *history.Service) {
+	s.history = history
+}
+
+// ListParams are the settings of an import list
+type ListParams struct {
+	Name                string
+	Type                string
+	Enabled             bool
+	Settings            json.RawMessage // TraktSettings for Trakt lists
+	QualityProfileID    *int32
+	RootFolderID        *int64
+	SearchOnAdd         bool
+	RemovalAction       RemovalAction
+	SyncIntervalMinutes int32
+
+	// ClientSecret of the Trakt API app. Stored encrypted; an empty secret
+	// keeps the stored one on updates.
+	ClientSecret string
+}
+
+// List returns all import lists
+func (s *Service) List(ctx context.Context) ([]generated.ImportList, error) {
+	lists, err := s.queries.ListImportLists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import lists: %w", err)
+	}
+	return lists, nil
+}
+
+// Get returns an import list
+func (s *Service) Get(ctx context.Context, id int64) (*generated.ImportList, error) {
+	list, err := s.queries.GetImportList(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import list: %w", err)
+	}
+	return &list, nil
+}
+
+// Create adds an import list
+func (s *Service) Create(ctx context.Context, params ListParams) (*generated.ImportList, error) {
+	list, err := s.queries.CreateImportList(ctx, generated.CreateImportListParams{
+		Name:                params.Name,
+		Type:                params.Type,
+		Enabled:             params.Enabled,
+		Settings:            params.Settings,
+		QualityProfileID:    params.QualityProfileID,
+		RootFolderID:        params.RootFolderID,
+		SearchOnAdd:         params.SearchOnAdd,
+		RemovalAction:       string(params.RemovalAction),
+		SyncIntervalMinutes: params.SyncIntervalMinutes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import list: %w", err)
+	}
+
+	if params.ClientSecret != "" {
+		if err := s.saveTraktCredentials(ctx, list.ID, traktCredentials{ClientSecret: params.ClientSecret}); err != nil {
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// Update replaces the settings of an import list. A new client secret
+// discards the stored tokens, so the account has to be authorized again.
+func (s *Service) Update(ctx context.Context, id int64, params ListParams) (*generated.ImportList, error) {
+	list, err := s.queries.UpdateImportList(ctx, generated.UpdateImportListParams{
+		ID:                  id,
+		Name:                params.Name,
+		Enabled:             params.Enabled,
+		Settings:            params.Settings,
+		QualityProfileID:    params.QualityProfileID,
+		RootFolderID:        params.RootFolderID,
+		SearchOnAdd:         params.SearchOnAdd,
+		RemovalAction:       string(params.RemovalAction),
+		SyncIntervalMinutes: params.SyncIntervalMinutes,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update import list: %w", err)
+	}
+
+	if params.ClientSecret != "" {
+		creds, err := s.loadTraktCredentials(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if creds.ClientSecret != params.ClientSecret {
+			if err := s.saveTraktCredentials(ctx, id, traktCredentials{ClientSecret: params.ClientSecret}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &list, nil
+}
+
+// Delete removes an import list with its runs and credentials. Media it added
+// stays in the library.
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	deleted, err := s.queries.DeleteImportList(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete import list: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	if err := s.config.Delete(ctx, credentialsKey(id)); err != nil {
+		s.logger.Warn("failed to delete import list credentials", zap.Int64("list_id", id), zap.Error(err))
+	}
+	s.mu.Lock()
+	delete(s.auths, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Authorized reports whether the account of a list is authorized
+func (s *Service) Authorized(ctx context.Context, list *generated.ImportList) bool {
+	if list.Type != TypeTrakt {
+		return false
+	}
+	creds, err := s.loadTraktCredentials(ctx, list.ID)
+	return err == nil && creds.authorized()
+}
+
+// AvailableLists returns the lists of the account a list can be set to sync
+func (s *Service) AvailableLists(ctx context.Context, list *generated.ImportList) ([]ListOption, error) {
+	source, err := s.source(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	return source.Lists(ctx)
+}
+
+// source returns where the entries of a list come from
+func (s *Service) source(ctx context.Context, list *generated.ImportList) (Source, error) {
+	switch list.Type {
+	case TypeTrakt:
+		return s.traktClient(ctx, list)
+	default:
+		return nil, fmt.Errorf("unknown import list type: %s", list.Type)
+	}
+}
+
+// traktClient returns a Trakt client with the settings and credentials of a list
+func (s *Service) traktClient(ctx context.Context, list *generated.ImportList) (*traktClient, error) {
+	var settings TraktSettings
+	if err := json.Unmarshal(list.Settings, &settings); err != nil {
+		return nil, fmt.Errorf("invalid trakt settings: %w", err)
+	}
+	creds, err := s.loadTraktCredentials(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &traktClient{
+		baseURL:  s.traktURL,
+		http:     s.http,
+		settings: settings,
+		creds:    creds,
+		save: func(ctx context.Context, creds traktCredentials) error {
+			return s.saveTraktCredentials(ctx, list.ID, creds)
+		},
+	}, nil
+}
+
+// credentialsKey is the config key the secrets of a list are stored under
+func credentialsKey(listID int64) string {
+	return fmt.Sprintf("import_lists.%d.credentials", listID)
+}
+
+// loadTraktCredentials returns the stored secrets of a list, which are empty
+// before a client secret was set
+func (s *Service) loadTraktCredentials(ctx context.Context, listID int64) (traktCredentials, error) {
+	var creds traktCredentials
+	raw, err := s.config.GetSecret(ctx, credentialsKey(listID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return creds, nil
+	}
+	if err != nil {
+		return creds, fmt.Errorf("failed to load import list credentials: %w", err)
+	}
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return creds, fmt.Errorf("failed to decode import list credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// saveTraktCredentials stores the secrets of a list encrypted
+func (s *Service) saveTraktCredentials(ctx context.Context, listID int64, creds traktCredentials) error {
+	if err := s.config.SetSecret(ctx, credentialsKey(listID), creds); err != nil {
+		return fmt.Errorf("failed to store import list credentials: %w", err)
+	}
+	return nil
+}
+
+// StartAuthorization starts a device-code authorization of the account of a
+// Trakt list. The returned code is entered at the verification URL; the
+// service polls Trakt in the background and stores the tokens once it was.
+func (s *Service) StartAuthorization(ctx context.Context, list *generated.ImportList) (*DeviceAuth, error) {
+	if list.Type != TypeTrakt {
+		return nil, fmt.Errorf("%s lists don't need authorization", list.Type)
+	}
+	client, err := s.traktClient(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	if client.creds.ClientSecret == "" {
+		return nil, ErrClientSecretRequired
+	}
+
+	code, err := client.requestDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &DeviceAuth{
+		Status:          DeviceAuthPending,
+		UserCode:        code.UserCode,
+		VerificationURL: code.VerificationURL,
+		ExpiresAt:       time.Now().Add(time.Duration(code.ExpiresIn) * time.Second).UTC(),
+	}
+	s.mu.Lock()
+	s.auths[list.ID] = auth
+	s.mu.Unlock()
+
+	go s.pollAuthorization(list.ID, client, code, auth)
+
+	current := *auth
+	return &current, nil
+}
+
+// pollAuthorization polls Trakt until the user entered the device code, the
+// code expired or the authorization was replaced by a newer one
+func (s *Service) pollAuthorization(listID int64, client *traktClient, code *traktDeviceCode, auth *DeviceAuth) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), auth.ExpiresAt)
+	defer cancel()
+
+	status := DeviceAuthPending
+	var pollErr error
+	for status == DeviceAuthPending {
+		select {
+		case <-ctx.Done():
+			status = DeviceAuthExpired
+			continue
+		case <-time.After(interval):
+		}
+
+		s.mu.Lock()
+		replaced := s.auths[listID] != auth
+		s.mu.Unlock()
+		if replaced {
+			return
+		}
+
+		status, pollErr = client.pollDeviceToken(ctx, code.DeviceCode)
+		if pollErr != nil && status == DeviceAuthPending {
+			s.logger.Debug("trakt device token poll failed", zap.Int64("list_id", listID), zap.Error(pollErr))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auths[listID] != auth {
+		return
+	}
+	auth.Status = status
+	if pollErr != nil {
+		auth.Error = pollErr.Error()
+	}
+	s.logger.Info("trakt authorization finished", zap.Int64("list_id", listID), zap.String("status", string(status)))
+}
+
+// Authorization returns the device authorization of a list started last, or
+// nil when none was started since the server started
+func (s *Service) Authorization(listID int64) *DeviceAuth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth, ok := s.auths[listID]
+	if !ok {
+		return nil
+	}
+	current := *auth
+	return &current
+}
+
+// Run is a sync run with what it did with each entry
+type Run struct {
+	generated.ImportListRun
+	Items []generated.ImportListRunItem
+}
+
+// Runs returns the latest sync runs of a list
+func (s *Service) Runs(ctx context.Context, listID int64, limit int32) ([]generated.ImportListRun, error) {
+	runs, err := s.queries.ListImportListRuns(ctx, generated.ListImportListRunsParams{ListID: listID, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import list runs: %w", err)
+	}
+	return runs, nil
+}
+
+// GetRun returns a sync run with its items, optionally only those with status
+func (s *Service) GetRun(ctx context.Context, id int64, status string) (*Run, error) {
+	run, err := s.queries.GetImportListRun(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import list run: %w", err)
+	}
+
+	var statusFilter *string
+	if status != "" {
+		statusFilter = &status
+	}
+	items, err := s.queries.ListImportListRunItems(ctx, generated.ListImportListRunItemsParams{RunID: id, Status: statusFilter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import list run items: %w", err)
+	}
+	return &Run{ImportListRun: run, Items: items}, nil
+}
+
+// Sync syncs a list and returns the finished run
+func (s *Service) Sync(ctx context.Context, listID int64) (*generated.ImportListRun, error) {
+	list, run, err := s.beginSync(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	return s.finishSync(ctx, list, run), nil
+}
+
+// StartSync starts syncing a list in the background and returns the run
+func (s *Service) StartSync(ctx context.Context, listID int64) (*generated.ImportListRun, error) {
+	list, run, err := s.beginSync(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	go s.finishSync(context.Background(), list, run)
+	return run, nil
+}
+
+// HandleSyncJob syncs the enabled lists whose sync interval has passed, for the
+// import_list_sync scheduler job
+func (s *Service) HandleSyncJob(ctx context.Context, job *monitoring.SchedulerJob) error {
+	lists, err := s.queries.ListImportListsDueForSync(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list import lists due for sync: %w", err)
+	}
+
+	var errs []error
+	for _, list := range lists {
+		run, err := s.Sync(ctx, list.ID)
+		if errors.Is(err, ErrSyncRunning) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", list.Name, err))
+			continue
+		}
+		if run.Status == RunFailed && run.ErrorMessage != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", list.Name, *run.ErrorMessage))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// beginSync marks a list as syncing and records the start of a run
+func (s *Service) beginSync(ctx context.Context, listID int64) (*generated.ImportList, *generated.ImportListRun, error) {
+	list, err := s.Get(ctx, listID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	if s.syncing[listID] {
+		s.mu.Unlock()
+		return nil, nil, ErrSyncRunning
+	}
+	s.syncing[listID] = true
+	s.mu.Unlock()
+
+	run, err := s.queries.CreateImportListRun(ctx, listID)
+	if err != nil {
+		s.mu.Lock()
+		delete(s.syncing, listID)
+		s.mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to create import list run: %w", err)
+	}
+	return list, &run, nil
+}
+
+// runCounts are the outcomes of a sync run
+type runCounts struct {
+	added, existing, failed, removed int32
+}
+
+// finishSync syncs a list and records the outcome of the run
+func (s *Service) finishSync(ctx context.Context, list *generated.ImportList, run *generated.ImportListRun) *generated.ImportListRun {
+	defer func() {
+		s.mu.Lock()
+		delete(s.syncing, list.ID)
+		s.mu.Unlock()
+	}()
+
+	counts, syncErr := s.syncList(ctx, list, run.ID)
+	if err := s.queries.SetImportListSynced(ctx, list.ID); err != nil {
+		s.logger.Error("failed to record import list sync", zap.Int64("list_id", list.ID), zap.Error(err))
+	}
+
+	status := RunSuccess
+	var message *string
+	if syncErr != nil {
+		status = RunFailed
+		msg := syncErr.Error()
+		message = &msg
+		s.logger.Warn("import list sync failed", zap.Int64("list_id", list.ID), zap.String("name", list.Name), zap.Error(syncErr))
+	}
+
+	finished, err := s.queries.FinishImportListRun(ctx, generated.FinishImportListRunParams{
+		ID:           run.ID,
+		Status:       status,
+		Added:        counts.added,
+		Existing:     counts.existing,
+		Failed:       counts.failed,
+		Removed:      counts.removed,
+		ErrorMessage: message,
+	})
+	if err != nil {
+		s.logger.Error("failed to finish import list run", zap.Int64("run_id", run.ID), zap.Error(err))
+		run.Status = status
+		run.ErrorMessage = message
+		return run
+	}
+
+	s.logger.Info("import list synced",
+		zap.Int64("list_id", list.ID),
+		zap.String("name", list.Name),
+		zap.Int32("added", counts.added),
+		zap.Int32("existing", counts.existing),
+		zap.Int32("failed", counts.failed),
+		zap.Int32("removed", counts.removed))
+	return &finished
+}
+
+// syncList adds the new entries of a list and handles the removed ones
+func (s *Service) syncList(ctx context.Context, list *generated.ImportList, runID int64) (runCounts, error) {
+	var counts runCounts
+
+	source, err := s.source(ctx, list)
+	if err != nil {
+		return counts, err
+	}
+	entries, err := source.Entries(ctx)
+	if err != nil {
+		return counts, err
+	}
+	known, err := s.queries.ListImportListEntries(ctx, list.ID)
+	if err != nil {
+		return counts, fmt.Errorf("failed to list import list entries: %w", err)
+	}
+
+	added, removed := diffEntries(entries, known)
+	for _, entry := range added {
+		status, mediaItemID, message := s.addEntry(ctx, list, entry)
+		switch status {
+		case ItemAdded:
+			counts.added++
+		case ItemExisting:
+			counts.existing++
+		default:
+			counts.failed++
+		}
+		s.recordItem(ctx, runID, status, entry, mediaItemID, message)
+	}
+	for _, entry := range removed {
+		message := s.removeEntry(ctx, list, entry)
+		counts.removed++
+		s.recordItem(ctx, runID, ItemRemoved, Entry{MediaType: entry.MediaType, TMDBID: entry.TmdbID, Title: entry.Title}, entry.MediaItemID, message)
+	}
+	return counts, nil
+}
+
+// entryKey identifies an entry within a list
+func entryKey(mediaType, tmdbID string) string {
+	return mediaType + ":" + tmdbID
+}
+
+// diffEntries returns the entries of a list that weren't on it at the last
+// sync, and the remembered entries that are gone from it. Entries without a
+// TMDB ID are always new, as they are never remembered.
+func diffEntries(entries []Entry, known []generated.ImportListEntry) (added []Entry, removed []generated.ImportListEntry) {
+	current := make(map[string]bool, len(entries))
+	seen := make(map[string]bool, len(known))
+	for _, entry := range known {
+		seen[entryKey(entry.MediaType, entry.TmdbID)] = true
+	}
+
+	for _, entry := range entries {
+		if entry.TMDBID == "" {
+			added = append(added, entry)
+			continue
+		}
+		key := entryKey(entry.MediaType, entry.TMDBID)
+		if current[key] {
+			continue
+		}
+		current[key] = true
+		if !seen[key] {
+			added = append(added, entry)
+		}
+	}
+
+	for _, entry := range known {
+		if !current[entryKey(entry.MediaType, entry.TmdbID)] {
+			removed = append(removed, entry)
+		}
+	}
+	return added, removed
+}
+
+// addEntry adds a new entry to the library and monitors it. Entries that
+// fail aren't remembered, so they are tried again on the next sync.
+func (s *Service) addEntry(ctx context.Context, list *generated.ImportList, entry Entry) (status string, mediaItemID *int64, message string) {
+	if entry.TMDBID == "" {
+		return ItemFailed, nil, "The list has no TMDB ID for this title"
+	}
+
+	item, created, err := s.titles.FindOrAddTitle(ctx, requests.Title{
+		MediaType: entry.MediaType,
+		TMDBID:    entry.TMDBID,
+		Title:     entry.Title,
+		Year:      entry.Year,
+	}, importListSource)
+	if err != nil {
+		return ItemFailed, nil, err.Error()
+	}
+	mediaItemID = &item.ID
+
+	rule, err := s.monitoring.GetMonitoringRuleByMediaItem(ctx, item.ID)
+	if err == nil && !created {
+		status, message = ItemExisting, "Already in the library and monitored"
+	} else {
+		rule, err = s.monitor(ctx, list, item.ID)
+		if err != nil {
+			return ItemFailed, mediaItemID, fmt.Sprintf("failed to create monitoring rule: %v", err)
+		}
+		status = ItemAdded
+		if !created {
+			message = "Already in the library, now monitored"
+		}
+		if list.SearchOnAdd && s.searcher != nil {
+			go func() {
+				if err := s.searcher.SearchRule(context.Background(), rule, monitoring.SearchTypeAutomatic, monitoring.TriggerSourceScheduler); err != nil {
+					s.logger.Warn("initial search for import list entry failed", zap.Int64("media_item_id", item.ID), zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	if err := s.queries.UpsertImportListEntry(ctx, generated.UpsertImportListEntryParams{
+		ListID:      list.ID,
+		MediaType:   entry.MediaType,
+		TmdbID:      entry.TMDBID,
+		Title:       entry.Title,
+		MediaItemID: mediaItemID,
+		AddedByList: created,
+	}); err != nil {
+		s.logger.Error("failed to remember import list entry", zap.Int64("list_id", list.ID), zap.String("tmdb_id", entry.TMDBID), zap.Error(err))
+	}
+	return status, mediaItemID, message
+}
+
+// monitor creates the monitoring rule of a new entry from the monitoring
+// defaults of its kind, with the quality profile, root folder and search on add
+// setting of its list
+func (s *Service) monitor(ctx context.Context, list *generated.ImportList, mediaItemID int64) (*monitoring.MonitoringRule, error) {
+	params, err := s.monitoring.NewRuleParams(ctx, mediaItemID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if list.QualityProfileID != nil {
+		id := int(*list.QualityProfileID)
+		params.QualityProfileID = &id
+	}
+	if list.RootFolderID != nil {
+		params.RootFolderID = list.RootFolderID
+	}
+	params.SearchOnAdd = list.SearchOnAdd
+
+	return s.monitoring.CreateMonitoringRule(ctx, params)
+}
+
+// removeEntry applies the removal action of a list to an entry gone from it
+// and forgets the entry. It returns what was done.
+func (s *Service) removeEntry(ctx context.Context, list *generated.ImportList, entry generated.ImportListEntry) string {
+	defer func() {
+		if err := s.queries.DeleteImportListEntry(ctx, generated.DeleteImportListEntryParams{
+			ListID:    list.ID,
+			MediaType: entry.MediaType,
+			TmdbID:    entry.TmdbID,
+		}); err != nil {
+			s.logger.Error("failed to forget import list entry", zap.Int64("list_id", list.ID), zap.String("tmdb_id", entry.TmdbID), zap.Error(err))
+		}
+	}()
+
+	action := RemovalAction(list.RemovalAction)
+	if action == RemovalIgnore {
+		return "Kept in the library"
+	}
+	if entry.MediaItemID == nil {
+		return "The media item no longer exists"
+	}
+
+	if action == RemovalDelete && entry.AddedByList {
+		item, err := s.media.GetMediaItem(ctx, *entry.MediaItemID)
+		if err == nil {
+			err = s.media.DeleteMediaItem(ctx, item.ID)
+		}
+		if errors.Is(err, media.ErrNotFound) {
+			return "The media item no longer exists"
+		}
+		if err != nil {
+			return fmt.Sprintf("failed to delete from the library: %v", err)
+		}
+		s.history.Record(ctx, history.Event{
+			Type:        history.EventDeleted,
+			MediaItemID: &item.ID,
+			Title:       item.Title,
+			Data: map[string]interface{}{
+				"kind":          "media_item",
+				"media_kind":    item.Kind,
+				"files_deleted": false,
+				"source":        importListSource,
+				"list_id":       list.ID,
+			},
+		})
+		return "Deleted from the library"
+	}
+
+	rule, err := s.monitoring.GetMonitoringRuleByMediaItem(ctx, *entry.MediaItemID)
+	if err != nil {
+		return "Not monitored"
+	}
+	disabled := false
+	if _, err := s.monitoring.UpdateMonitoringRule(ctx, rule.ID, monitoring.UpdateMonitoringRuleParams{Enabled: &disabled}); err != nil {
+		return fmt.Sprintf("failed to unmonitor: %v", err)
+	}
+	if action == RemovalDelete {
+		return "Unmonitored, not deleted as it was in the library before the list added it"
+	}
+	return "Unmonitored"
+}
+
+// recordItem records what a run did with an entry
+func (s *Service) recordItem(ctx context.Context, runID int64, status string, entry Entry, mediaItemID *int64, message string) {
+	params := generated.CreateImportListRunItemParams{
+		RunID:       runID,
+		Status:      status,
+		MediaType:   entry.MediaType,
+		Title:       entry.Title,
+		Year:        entry.Year,
+		MediaItemID: mediaItemID,
+	}
+	if entry.TMDBID != "" {
+		params.TmdbID = &entry.TMDBID
+	}
+	if message != "" {
+		params.Message = &message
+	}
+	if err := s.queries.CreateImportListRunItem(ctx, params); err != nil {
+		s.logger.Error("failed to record import list run item", zap.Int64("run_id", runID), zap.Error(err))
+	}
+}
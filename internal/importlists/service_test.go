@@ -0,0 +1,48 @@
+package importlists
+
+import (
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+)
+
+func TestDiffEntries(t *testing.T) {
+	entries := []Entry{
+		{MediaType: MediaTypeMovie, TMDBID: "1", Title: "Known"},
+		{MediaType: MediaTypeMovie, TMDBID: "2", Title: "New"},
+		{MediaType: MediaTypeMovie, TMDBID: "2", Title: "New again"},
+		{MediaType: MediaTypeTV, TMDBID: "1", Title: "Same ID, other type"},
+		{MediaType: MediaTypeMovie, Title: "No TMDB ID"},
+	}
+	known := []generated.ImportListEntry{
+		{MediaType: MediaTypeMovie, TmdbID: "1"},
+		{MediaType: MediaTypeMovie, TmdbID: "3"},
+	}
+
+	added, removed := diffEntries(entries, known)
+
+	wantAdded := []string{"New", "Same ID, other type", "No TMDB ID"}
+	if len(added) != len(wantAdded) {
+		t.Fatalf("added %d entries, want %d: %+v", len(added), len(wantAdded), added)
+	}
+	for i, title := range wantAdded {
+		if added[i].Title != title {
+			t.Errorf("added[%d] = %q, want %q", i, added[i].Title, title)
+		}
+	}
+
+	if len(removed) != 1 || removed[0].TmdbID != "3" {
+		t.Errorf("removed = %+v, want only TMDB ID 3", removed)
+	}
+}
+
+func TestRemovalActionIsValid(t *testing.T) {
+	for _, action := range []RemovalAction{RemovalIgnore, RemovalUnmonitor, RemovalDelete} {
+		if !action.IsValid() {
+			t.Errorf("%q should be valid", action)
+		}
+	}
+	if RemovalAction("archive").IsValid() {
+		t.Error(`"archive" should not be valid`)
+	}
+}
@@ -0,0 +1,65 @@
+// Package importlists keeps the library in sync with external lists such as a
+// Trakt watchlist: new entries are added to the library and monitored, and
+// removed entries are ignored, unmonitored or deleted.
+package importlists
+
+import (
+	"context"
+	"time"
+)
+
+// List types
+const (
+	TypeTrakt = "trakt"
+)
+
+// Entry media types, as TMDB names them
+const (
+	MediaTypeMovie = "movie"
+	MediaTypeTV    = "tv"
+)
+
+// Entry is a movie or series on an external list
+type Entry struct {
+	MediaType string `json:"media_type"` // MediaTypeMovie or MediaTypeTV
+	TMDBID    string `json:"tmdb_id"`    // Empty when the list doesn't know it
+	Title     string `json:"title"`
+	Year      *int32 `json:"year,omitempty"`
+}
+
+// ListOption is a list of the account an import list can be set to sync
+type ListOption struct {
+	ID        string `json:"id"` // Value of the list setting
+	Name      string `json:"name"`
+	ItemCount int    `json:"item_count"`
+}
+
+// Source is where the entries of an import list come from
+type Source interface {
+	// Entries returns everything currently on the list
+	Entries(ctx context.Context) ([]Entry, error)
+
+	// Lists returns the lists of the account the import list can sync
+	Lists(ctx context.Context) ([]ListOption, error)
+}
+
+// DeviceAuthStatus is how far a device-code authorization has come
+type DeviceAuthStatus string
+
+const (
+	DeviceAuthPending    DeviceAuthStatus = "pending"    // Waiting for the user to enter the code
+	DeviceAuthAuthorized DeviceAuthStatus = "authorized" // Tokens were stored
+	DeviceAuthDenied     DeviceAuthStatus = "denied"     // The user declined
+	DeviceAuthExpired    DeviceAuthStatus = "expired"    // The code expired before it was entered
+	DeviceAuthFailed     DeviceAuthStatus = "failed"
+)
+
+// DeviceAuth is an authorization in progress: the user enters UserCode at
+// VerificationURL to grant access to their account
+type DeviceAuth struct {
+	Status          DeviceAuthStatus `json:"status"`
+	UserCode        string           `json:"user_code,omitempty"`
+	VerificationURL string           `json:"verification_url,omitempty"`
+	ExpiresAt       time.Time        `json:"expires_at"`
+	Error           string           `json:"error,omitempty"`
+}
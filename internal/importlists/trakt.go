@@ -0,0 +1,356 @@
+package importlists
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// traktAPIURL is the Trakt API all requests go to
+	traktAPIURL = "https://api.trakt.tv"
+
+	// traktWatchlist is the list setting that syncs the watchlist
+	traktWatchlist = "watchlist"
+
+	// traktPageSize is how many list items are fetched per request
+	traktPageSize = 100
+
+	// traktMaxPages bounds how many pages of a list are fetched
+	traktMaxPages = 50
+
+	// traktRedirectURI is the redirect URI of device-code authorizations
+	traktRedirectURI = "urn:ietf:wg:oauth:2.0:oob"
+)
+
+// errTraktUnauthorized is returned when the list has no valid tokens
+var errTraktUnauthorized = errors.New("trakt account is not authorized, start the device authorization first")
+
+// TraktSettings are the settings of a Trakt import list
+type TraktSettings struct {
+	ClientID string `json:"client_id"`          // Of the API app created on trakt.tv
+	Username string `json:"username,omitempty"` // Owner of the list (default: the authorized user)
+	List     string `json:"list,omitempty"`     // "watchlist" (default) or the slug of a personal list
+}
+
+// traktCredentials are the secrets of a Trakt import list, stored encrypted
+// in the config store
+type traktCredentials struct {
+	ClientSecret string    `json:"client_secret"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// authorized reports whether the credentials hold tokens
+func (c traktCredentials) authorized() bool {
+	return c.AccessToken != "" || c.RefreshToken != ""
+}
+
+// traktToken is the token response of the Trakt OAuth endpoints
+type traktToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Seconds
+	CreatedAt    int64  `json:"created_at"` // Unix time
+}
+
+// traktDeviceCode is the response of the device code endpoint
+type traktDeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"` // Seconds
+	Interval        int    `json:"interval"`   // Seconds between polls
+}
+
+// traktClient talks to the Trakt API for one import list
+type traktClient struct {
+	baseURL  string
+	http     *http.Client
+	settings TraktSettings
+	creds    traktCredentials
+
+	// save stores credentials after the tokens were refreshed
+	save func(ctx context.Context, creds traktCredentials) error
+}
+
+// Entries returns the movies and shows on the configured list
+func (c *traktClient) Entries(ctx context.Context) ([]Entry, error) {
+	user := c.settings.Username
+	if user == "" {
+		user = "me"
+	}
+	path := fmt.Sprintf("/users/%s/watchlist/movies,shows", url.PathEscape(user))
+	if list := c.settings.List; list != "" && list != traktWatchlist {
+		path = fmt.Sprintf("/users/%s/lists/%s/items/movies,shows", url.PathEscape(user), url.PathEscape(list))
+	}
+
+	var entries []Entry
+	for page := 1; page <= traktMaxPages; page++ {
+		var items []traktListItem
+		query := url.Values{"page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(traktPageSize)}}
+		pageCount, err := c.get(ctx, path, query, &items)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if entry, ok := item.entry(); ok {
+				entries = append(entries, entry)
+			}
+		}
+		if page >= pageCount || len(items) == 0 {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Lists returns the watchlist and the personal lists of the user
+func (c *traktClient) Lists(ctx context.Context) ([]ListOption, error) {
+	user := c.settings.Username
+	if user == "" {
+		user = "me"
+	}
+
+	var lists []struct {
+		Name      string `json:"name"`
+		ItemCount int    `json:"item_count"`
+		IDs       struct {
+			Slug string `json:"slug"`
+		} `json:"ids"`
+	}
+	if _, err := c.get(ctx, fmt.Sprintf("/users/%s/lists", url.PathEscape(user)), nil, &lists); err != nil {
+		return nil, err
+	}
+
+	options := []ListOption{{ID: traktWatchlist, Name: "Watchlist"}}
+	for _, list := range lists {
+		options = append(options, ListOption{ID: list.IDs.Slug, Name: list.Name, ItemCount: list.ItemCount})
+	}
+	return options, nil
+}
+
+// traktListItem is an item of a watchlist or personal list
+type traktListItem struct {
+	Type  string      `json:"type"` // movie, show, season, episode, person
+	Movie *traktMedia `json:"movie"`
+	Show  *traktMedia `json:"show"`
+}
+
+// traktMedia is a movie or show
+type traktMedia struct {
+	Title string `json:"title"`
+	Year  *int32 `json:"year"`
+	IDs   struct {
+		TMDB *int64 `json:"tmdb"`
+	} `json:"ids"`
+}
+
+// entry converts a list item to an Entry. Seasons, episodes and people are
+// skipped.
+func (item traktListItem) entry() (Entry, bool) {
+	var media *traktMedia
+	var mediaType string
+	switch item.Type {
+	case "movie":
+		media, mediaType = item.Movie, MediaTypeMovie
+	case "show":
+		media, mediaType = item.Show, MediaTypeTV
+	}
+	if media == nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{MediaType: mediaType, Title: media.Title, Year: media.Year}
+	if media.IDs.TMDB != nil {
+		entry.TMDBID = strconv.FormatInt(*media.IDs.TMDB, 10)
+	}
+	return entry, true
+}
+
+// get sends an authorized GET request, decodes the response into out and
+// returns the page count of paginated responses
+func (c *traktClient) get(ctx context.Context, path string, query url.Values, out interface{}) (int, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	c.setHeaders(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("trakt request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return 0, errTraktUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, traktError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("failed to decode trakt response: %w", err)
+	}
+
+	pageCount, _ := strconv.Atoi(resp.Header.Get("X-Pagination-Page-Count"))
+	return pageCount, nil
+}
+
+// accessToken returns a valid access token, refreshing it when it expired
+func (c *traktClient) accessToken(ctx context.Context) (string, error) {
+	if !c.creds.authorized() {
+		return "", errTraktUnauthorized
+	}
+	if c.creds.AccessToken != "" && time.Now().Add(time.Minute).Before(c.creds.ExpiresAt) {
+		return c.creds.AccessToken, nil
+	}
+	if c.creds.RefreshToken == "" {
+		return "", errTraktUnauthorized
+	}
+
+	var token traktToken
+	status, err := c.post(ctx, "/oauth/token", map[string]string{
+		"refresh_token": c.creds.RefreshToken,
+		"client_id":     c.settings.ClientID,
+		"client_secret": c.creds.ClientSecret,
+		"redirect_uri":  traktRedirectURI,
+		"grant_type":    "refresh_token",
+	}, &token)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusUnauthorized || status == http.StatusBadRequest {
+		return "", errTraktUnauthorized
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("trakt token refresh returned HTTP %d", status)
+	}
+
+	c.creds = c.creds.withToken(token)
+	if c.save != nil {
+		if err := c.save(ctx, c.creds); err != nil {
+			return "", err
+		}
+	}
+	return c.creds.AccessToken, nil
+}
+
+// withToken returns the credentials with the tokens of a token response
+func (c traktCredentials) withToken(token traktToken) traktCredentials {
+	created := time.Now()
+	if token.CreatedAt > 0 {
+		created = time.Unix(token.CreatedAt, 0)
+	}
+	c.AccessToken = token.AccessToken
+	c.RefreshToken = token.RefreshToken
+	c.ExpiresAt = created.Add(time.Duration(token.ExpiresIn) * time.Second).UTC()
+	return c
+}
+
+// requestDeviceCode starts a device-code authorization
+func (c *traktClient) requestDeviceCode(ctx context.Context) (*traktDeviceCode, error) {
+	var code traktDeviceCode
+	status, err := c.post(ctx, "/oauth/device/code", map[string]string{"client_id": c.settings.ClientID}, &code)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("trakt device code request returned HTTP %d, check the client ID", status)
+	}
+	return &code, nil
+}
+
+// pollDeviceToken checks once whether the user entered the device code. It
+// returns DeviceAuthPending until they did, and stores the tokens once they did.
+func (c *traktClient) pollDeviceToken(ctx context.Context, deviceCode string) (DeviceAuthStatus, error) {
+	var token traktToken
+	status, err := c.post(ctx, "/oauth/device/token", map[string]string{
+		"code":          deviceCode,
+		"client_id":     c.settings.ClientID,
+		"client_secret": c.creds.ClientSecret,
+	}, &token)
+	if err != nil {
+		return DeviceAuthPending, err
+	}
+
+	switch status {
+	case http.StatusOK:
+		c.creds = c.creds.withToken(token)
+		if err := c.save(ctx, c.creds); err != nil {
+			return DeviceAuthFailed, err
+		}
+		return DeviceAuthAuthorized, nil
+	case http.StatusBadRequest, http.StatusTooManyRequests:
+		// Not entered yet, or polling too fast
+		return DeviceAuthPending, nil
+	case http.StatusGone:
+		return DeviceAuthExpired, nil
+	case http.StatusTeapot:
+		return DeviceAuthDenied, nil
+	default:
+		return DeviceAuthFailed, fmt.Errorf("trakt device token request returned HTTP %d", status)
+	}
+}
+
+// post sends a JSON POST request and decodes successful responses into out
+func (c *traktClient) post(ctx context.Context, path string, body interface{}, out interface{}) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("trakt request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, fmt.Errorf("failed to decode trakt response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// setHeaders sets the headers every Trakt API request needs
+func (c *traktClient) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("trakt-api-version", "2")
+	req.Header.Set("trakt-api-key", c.settings.ClientID)
+}
+
+// traktError describes an unsuccessful response
+func traktError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		return fmt.Errorf("trakt returned HTTP %d", resp.StatusCode)
+	}
+	return fmt.Errorf("trakt returned HTTP %d: %s", resp.StatusCode, message)
+}
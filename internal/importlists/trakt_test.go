@@ -0,0 +1,158 @@
+package importlists
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTraktEntriesPaginates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/me/watchlist/movies,shows" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer access" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Header().Set("X-Pagination-Page-Count", "2")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `[{"type":"movie","movie":{"title":"Heat","year":1995,"ids":{"tmdb":949}}},
+				{"type":"season","season":{"number":1}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"type":"show","show":{"title":"Severance","year":2022,"ids":{"tmdb":95396}}},
+				{"type":"movie","movie":{"title":"Unmatched","ids":{}}}]`)
+		default:
+			t.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer server.Close()
+
+	client := &traktClient{
+		baseURL:  server.URL,
+		http:     server.Client(),
+		settings: TraktSettings{ClientID: "id"},
+		creds:    traktCredentials{AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	entries, err := client.Entries(context.Background())
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+
+	want := []Entry{
+		{MediaType: MediaTypeMovie, TMDBID: "949", Title: "Heat"},
+		{MediaType: MediaTypeTV, TMDBID: "95396", Title: "Severance"},
+		{MediaType: MediaTypeMovie, Title: "Unmatched"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i := range want {
+		if entries[i].MediaType != want[i].MediaType || entries[i].TMDBID != want[i].TMDBID || entries[i].Title != want[i].Title {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestTraktRefreshesExpiredToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["grant_type"] != "refresh_token" || body["refresh_token"] != "refresh" || body["client_secret"] != "secret" {
+				t.Errorf("unexpected refresh body %v", body)
+			}
+			fmt.Fprint(w, `{"access_token":"new-access","refresh_token":"new-refresh","expires_in":7776000}`)
+		case "/users/me/lists":
+			if got := r.Header.Get("Authorization"); got != "Bearer new-access" {
+				t.Errorf("Authorization = %q", got)
+			}
+			fmt.Fprint(w, `[{"name":"Favorites","item_count":3,"ids":{"slug":"favorites"}}]`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var saved traktCredentials
+	client := &traktClient{
+		baseURL:  server.URL,
+		http:     server.Client(),
+		settings: TraktSettings{ClientID: "id"},
+		creds:    traktCredentials{ClientSecret: "secret", AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)},
+		save: func(ctx context.Context, creds traktCredentials) error {
+			saved = creds
+			return nil
+		},
+	}
+	lists, err := client.Lists(context.Background())
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	if len(lists) != 2 || lists[0].ID != traktWatchlist || lists[1].ID != "favorites" {
+		t.Errorf("lists = %+v", lists)
+	}
+	if saved.AccessToken != "new-access" || saved.RefreshToken != "new-refresh" || saved.ClientSecret != "secret" {
+		t.Errorf("saved credentials = %+v", saved)
+	}
+	if !saved.ExpiresAt.After(time.Now().Add(80 * 24 * time.Hour)) {
+		t.Errorf("expires at %v, want about 90 days from now", saved.ExpiresAt)
+	}
+}
+
+func TestTraktUnauthorizedWithoutTokens(t *testing.T) {
+	client := &traktClient{settings: TraktSettings{ClientID: "id"}}
+	if _, err := client.Entries(context.Background()); !errors.Is(err, errTraktUnauthorized) {
+		t.Errorf("err = %v, want errTraktUnauthorized", err)
+	}
+}
+
+func TestTraktPollDeviceToken(t *testing.T) {
+	tests := []struct {
+		status int
+		want   DeviceAuthStatus
+	}{
+		{http.StatusOK, DeviceAuthAuthorized},
+		{http.StatusBadRequest, DeviceAuthPending},
+		{http.StatusTooManyRequests, DeviceAuthPending},
+		{http.StatusGone, DeviceAuthExpired},
+		{http.StatusTeapot, DeviceAuthDenied},
+		{http.StatusNotFound, DeviceAuthFailed},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			if tt.status == http.StatusOK {
+				fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","expires_in":3600}`)
+			}
+		}))
+
+		saved := false
+		client := &traktClient{
+			baseURL:  server.URL,
+			http:     server.Client(),
+			settings: TraktSettings{ClientID: "id"},
+			creds:    traktCredentials{ClientSecret: "secret"},
+			save: func(ctx context.Context, creds traktCredentials) error {
+				saved = creds.AccessToken == "access"
+				return nil
+			},
+		}
+		got, _ := client.pollDeviceToken(context.Background(), "device")
+		server.Close()
+
+		if got != tt.want {
+			t.Errorf("HTTP %d: status = %q, want %q", tt.status, got, tt.want)
+		}
+		if saved != (tt.want == DeviceAuthAuthorized) {
+			t.Errorf("HTTP %d: saved = %v", tt.status, saved)
+		}
+	}
+}
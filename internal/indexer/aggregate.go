@@ -0,0 +1,197 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"go.uber.org/zap"
+)
+
+// DefaultPluginSearchTimeout bounds how long SearchAll waits for a single plugin
+const DefaultPluginSearchTimeout = 30 * time.Second
+
+// Sort orders for aggregated results
+const (
+	SortByDate    = "date"    // Newest first
+	SortByScore   = "score"   // Best quality and custom format score first
+	SortBySize    = "size"    // Largest first
+	SortBySeeders = "seeders" // Most seeders first; usenet releases last
+)
+
+// SearchWarning reports an indexer plugin that failed during an aggregated search
+type SearchWarning struct {
+	PluginID   string `json:"plugin_id"`
+	PluginName string `json:"plugin_name"`
+	Message    string `json:"message"`
+}
+
+// AggregateResponse is the merged result of searching every indexer plugin
+type AggregateResponse struct {
+	Releases []plugins.IndexerRelease `json:"releases"`
+	Total    int                      `json:"total"`
+	Sources  []string                 `json:"sources"`  // Plugins that answered
+	Warnings []SearchWarning          `json:"warnings"` // Plugins that failed or timed out
+}
+
+// SetPluginTimeout sets the per-plugin timeout used by SearchAll
+func (s *Service) SetPluginTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.pluginTimeout = timeout
+	}
+}
+
+// SearchAll calls Search on every indexer plugin concurrently and merges the
+// results, de-duplicated by GUID and newest first. A plugin that fails or
+// doesn't answer within the plugin timeout is reported as a warning instead of
+// failing the search, so the response always holds whatever could be found.
+func (s *Service) SearchAll(ctx context.Context, req SearchRequest) *AggregateResponse {
+	indexerPlugins := s.pluginManager.ListIndexerPlugins()
+
+	resp := &AggregateResponse{
+		Releases: []plugins.IndexerRelease{},
+		Sources:  []string{},
+		Warnings: []SearchWarning{},
+	}
+	if len(indexerPlugins) == 0 {
+		return resp
+	}
+
+	type result struct {
+		plugin   *plugins.LoadedPlugin
+		releases []plugins.IndexerRelease
+		err      error
+	}
+
+	pluginReq := &plugins.IndexerSearchRequest{
+		Query:          req.searchQuery(),
+		Type:           req.Type,
+		Categories:     req.Categories,
+		TVDBID:         req.TVDBID,
+		TVRageID:       req.TVRageID,
+		Season:         req.Season,
+		Episode:        req.Episode,
+		IMDBID:         req.IMDBID,
+		TMDBID:         req.TMDBID,
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+		Fresh:          req.Fresh,
+		MinimumSeeders: req.MinimumSeeders,
+	}
+
+	resultChan := make(chan result, len(indexerPlugins))
+	var wg sync.WaitGroup
+
+	for _, plugin := range indexerPlugins {
+		wg.Add(1)
+		go func(p *plugins.LoadedPlugin) {
+			defer wg.Done()
+
+			pluginCtx, cancel := context.WithTimeout(ctx, s.pluginTimeout)
+			defer cancel()
+
+			searchResp, err := p.Client.Search(pluginCtx, pluginReq)
+			if err != nil {
+				if pluginCtx.Err() == context.DeadlineExceeded {
+					err = fmt.Errorf("timed out after %s", s.pluginTimeout)
+				}
+				resultChan <- result{plugin: p, err: err}
+				return
+			}
+			resultChan <- result{plugin: p, releases: searchResp.Releases}
+		}(plugin)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	allReleases := []plugins.IndexerRelease{}
+	for res := range resultChan {
+		if res.err != nil {
+			s.logger.Warn("Search failed for indexer",
+				zap.String("plugin_id", res.plugin.Meta.ID),
+				zap.Error(res.err))
+			resp.Warnings = append(resp.Warnings, SearchWarning{
+				PluginID:   res.plugin.Meta.ID,
+				PluginName: res.plugin.Meta.Name,
+				Message:    res.err.Error(),
+			})
+			continue
+		}
+
+		// Attribute releases to the plugin when it didn't name its own indexer
+		for i := range res.releases {
+			if res.releases[i].IndexerID == "" {
+				res.releases[i].IndexerID = res.plugin.Meta.ID
+				res.releases[i].IndexerName = res.plugin.Meta.Name
+			}
+		}
+		allReleases = append(allReleases, res.releases...)
+		resp.Sources = append(resp.Sources, res.plugin.Meta.ID)
+	}
+
+	releases := s.deduplicateReleases(allReleases)
+	SortReleases(releases, SortByDate, nil)
+
+	if req.Limit > 0 && len(releases) > req.Limit {
+		releases = releases[:req.Limit]
+	}
+
+	resp.Releases = releases
+	resp.Total = len(releases)
+	return resp
+}
+
+// SortReleases sorts releases in place. SortByScore needs a scorer and falls
+// back to SortByDate without one; ties always go to the newest release.
+func SortReleases(releases []plugins.IndexerRelease, order string, scorer *quality.ReleaseScorer) {
+	newer := func(i, j int) bool {
+		return releases[i].PublishDate.After(releases[j].PublishDate)
+	}
+
+	switch {
+	case order == SortByScore && scorer != nil:
+		scores := make(map[string]int, len(releases))
+		for _, release := range releases {
+			scores[release.GUID] = scorer.Score(release.Title, release.Attributes).Total
+		}
+		sort.SliceStable(releases, func(i, j int) bool {
+			if a, b := scores[releases[i].GUID], scores[releases[j].GUID]; a != b {
+				return a > b
+			}
+			return newer(i, j)
+		})
+	case order == SortBySize:
+		sort.SliceStable(releases, func(i, j int) bool {
+			if releases[i].Size != releases[j].Size {
+				return releases[i].Size > releases[j].Size
+			}
+			return newer(i, j)
+		})
+	case order == SortBySeeders:
+		sort.SliceStable(releases, func(i, j int) bool {
+			a, b := releaseSeeders(releases[i]), releaseSeeders(releases[j])
+			if a != b {
+				return a > b
+			}
+			return newer(i, j)
+		})
+	default:
+		sort.SliceStable(releases, newer)
+	}
+}
+
+// releaseSeeders returns a torrent release's seeders, or -1 when unknown
+func releaseSeeders(release plugins.IndexerRelease) int {
+	if seeders, err := strconv.Atoi(release.Attributes["seeders"]); err == nil {
+		return seeders
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+func TestSortReleases(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	releases := func() []plugins.IndexerRelease {
+		return []plugins.IndexerRelease{
+			{GUID: "old-720p", Title: "Show.S01E01.720p.HDTV.x264-GRP", Size: 800, PublishDate: base, Attributes: map[string]string{"seeders": "50"}},
+			{GUID: "new-1080p", Title: "Show.S01E01.1080p.WEB-DL.x264-GRP", Size: 1500, PublishDate: base.Add(2 * time.Hour), Attributes: map[string]string{}},
+			{GUID: "mid-2160p", Title: "Show.S01E01.2160p.WEB-DL.x265-GRP", Size: 4000, PublishDate: base.Add(time.Hour), Attributes: map[string]string{"seeders": "5"}},
+		}
+	}
+	guids := func(releases []plugins.IndexerRelease) []string {
+		out := make([]string, len(releases))
+		for i, release := range releases {
+			out[i] = release.GUID
+		}
+		return out
+	}
+
+	scorer := quality.NewReleaseScorerFrom(nil, nil, []quality.CustomFormat{
+		{Name: "UHD", MatchType: quality.CustomFormatMatchTerms, Field: "title", Terms: []string{"2160p"}, Score: 30, Enabled: true},
+		{Name: "HD", MatchType: quality.CustomFormatMatchTerms, Field: "title", Terms: []string{"1080p"}, Score: 20, Enabled: true},
+	})
+
+	tests := []struct {
+		order  string
+		scorer *quality.ReleaseScorer
+		want   []string
+	}{
+		{SortByDate, nil, []string{"new-1080p", "mid-2160p", "old-720p"}},
+		{SortBySize, nil, []string{"mid-2160p", "new-1080p", "old-720p"}},
+		// Usenet releases have no seeders and sort last
+		{SortBySeeders, nil, []string{"old-720p", "mid-2160p", "new-1080p"}},
+		{SortByScore, scorer, []string{"mid-2160p", "new-1080p", "old-720p"}},
+		// Without a scorer, score falls back to date
+		{SortByScore, nil, []string{"new-1080p", "mid-2160p", "old-720p"}},
+	}
+
+	for _, tt := range tests {
+		sorted := releases()
+		SortReleases(sorted, tt.order, tt.scorer)
+		got := guids(sorted)
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("SortReleases(%q) = %v, want %v", tt.order, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// RSSFeed is the recent releases of an indexer plugin's RSS feed
+type RSSFeed struct {
+	Releases []plugins.IndexerRelease `json:"releases"`
+
+	// Indexers reports the feed of each indexer of plugins that aggregate
+	// several, so one failing indexer doesn't hide the others
+	Indexers []RSSFeedIndexer `json:"indexers,omitempty"`
+}
+
+// RSSFeedIndexer is the outcome of fetching one indexer's feed
+type RSSFeedIndexer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+}
+
+// ListRSSIndexers returns the indexer plugins that have an RSS feed
+func (s *Service) ListRSSIndexers() []IndexerInfo {
+	var indexers []IndexerInfo
+	for _, plugin := range s.pluginManager.ListIndexerPlugins() {
+		if !hasRSSRoute(plugin) {
+			continue
+		}
+		indexers = append(indexers, IndexerInfo{
+			ID:          plugin.Meta.ID,
+			Name:        plugin.Meta.Name,
+			Version:     plugin.Meta.Version,
+			Description: plugin.Meta.Description,
+		})
+	}
+	return indexers
+}
+
+// hasRSSRoute reports whether a plugin serves its RSS feed at /api/plugins/{id}/rss
+func hasRSSRoute(plugin *plugins.LoadedPlugin) bool {
+	path := fmt.Sprintf("/api/plugins/%s/rss", plugin.Meta.ID)
+	for _, route := range plugin.Routes {
+		if route.Method == http.MethodGet && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchRSS fetches the newest releases of an indexer plugin's RSS feed, up to
+// limit (0 for the plugin's default), in the given categories
+func (s *Service) FetchRSS(ctx context.Context, pluginID string, categories []string, limit int) (*RSSFeed, error) {
+	plugin, exists := s.pluginManager.GetPlugin(pluginID)
+	if !exists {
+		return nil, fmt.Errorf("plugin %s not found", pluginID)
+	}
+
+	query := url.Values{}
+	if len(categories) > 0 {
+		query.Set("categories", strings.Join(categories, ","))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	pluginResp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  http.MethodGet,
+		Path:    fmt.Sprintf("/api/plugins/%s/rss", pluginID),
+		Headers: map[string][]string{},
+		Query:   query,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call plugin: %w", err)
+	}
+	if pluginResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", pluginResp.StatusCode, string(pluginResp.Body))
+	}
+
+	var feed RSSFeed
+	if err := json.Unmarshal(pluginResp.Body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+	return &feed, nil
+}
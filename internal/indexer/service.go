@@ -7,12 +7,14 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/blakestevenson/nimbus/internal/library"
 	"github.com/blakestevenson/nimbus/internal/plugins"
 	"go.uber.org/zap"
 )
@@ -22,7 +24,9 @@ type Service struct {
 	pluginManager *plugins.PluginManager
 	logger        *zap.Logger
 	httpClient    *http.Client
-	baseURL       string // Base URL for internal API calls (e.g., "http://localhost:8080")
+	baseURL       string // Base URL for internal API calls, from NIMBUS_INTERNAL_URL
+	pluginTimeout time.Duration
+	tags          TagSource
 }
 
 // NewService creates a new indexer service
@@ -33,7 +37,8 @@ func NewService(pluginManager *plugins.PluginManager, logger *zap.Logger) *Servi
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		baseURL: "http://localhost:8080", // Default, should be configurable
+		baseURL:       pluginManager.InternalAPI().BaseURL,
+		pluginTimeout: DefaultPluginSearchTimeout,
 	}
 }
 
@@ -55,6 +60,27 @@ type SearchRequest struct {
 	TMDBID     string
 	Limit      int
 	Offset     int
+	Fresh      bool // Bypass indexer result caches
+
+	// MinimumSeeders drops torrent results with fewer seeders (0 = no minimum)
+	MinimumSeeders int
+
+	// AbsoluteEpisode searches an anime episode by title and absolute episode
+	// number rather than by season and episode (0 = not an anime search)
+	AbsoluteEpisode int
+
+	// MediaItemID is the media item searched for (0 = not a media search).
+	// Media searches only use indexers sharing a tag with the media.
+	MediaItemID int64
+}
+
+// searchQuery is the query sent to the indexers, which for anime episodes
+// carries the absolute episode number
+func (r SearchRequest) searchQuery() string {
+	if r.AbsoluteEpisode > 0 {
+		return fmt.Sprintf("%s %02d", r.Query, r.AbsoluteEpisode)
+	}
+	return r.Query
 }
 
 // SearchResponse represents aggregated search results from all indexers
@@ -72,7 +98,7 @@ func (s *Service) Search(ctx context.Context, req SearchRequest) (*SearchRespons
 // SearchWithAuth performs a search with authentication cookies
 func (s *Service) SearchWithAuth(ctx context.Context, req SearchRequest, cookies []*http.Cookie) (*SearchResponse, error) {
 	// Get all indexer plugins
-	indexerPlugins := s.pluginManager.ListIndexerPlugins()
+	indexerPlugins := s.filterByTags(ctx, s.pluginManager.ListIndexerPlugins(), req)
 
 	if len(indexerPlugins) == 0 {
 		return &SearchResponse{
@@ -102,7 +128,7 @@ func (s *Service) SearchWithAuth(ctx context.Context, req SearchRequest, cookies
 		go func(p *plugins.LoadedPlugin) {
 			defer wg.Done()
 
-			releases, err := s.searchPluginViaHTTP(ctx, p.Meta.ID, req, cookies)
+			releases, err := s.searchPlugin(ctx, p.Meta.ID, req, cookies)
 			resultChan <- result{
 				releases: releases,
 				err:      err,
@@ -167,7 +193,7 @@ func (s *Service) SearchWithAuth(ctx context.Context, req SearchRequest, cookies
 			go func(pid string) {
 				defer fallbackWg.Done()
 
-				releases, err := s.searchPluginViaHTTP(ctx, pid, fallbackReq, cookies)
+				releases, err := s.searchPlugin(ctx, pid, fallbackReq, cookies)
 				fallbackResultChan <- result{
 					releases: releases,
 					err:      err,
@@ -206,6 +232,11 @@ func (s *Service) SearchWithAuth(ctx context.Context, req SearchRequest, cookies
 		uniqueReleases = s.filterBySeriesName(uniqueReleases, req.Query)
 	}
 
+	// Anime searches by title return every episode of the series
+	if req.AbsoluteEpisode > 0 {
+		uniqueReleases = filterByAbsoluteEpisode(uniqueReleases, req.AbsoluteEpisode)
+	}
+
 	// For season searches (season specified but no episode), filter out individual episodes
 	if req.Type == "tv" && req.Season > 0 && req.Episode == 0 {
 		uniqueReleases = s.filterSeasonPacks(uniqueReleases)
@@ -255,6 +286,57 @@ type IndexerInfo struct {
 	Description string `json:"description"`
 }
 
+// searchPlugin searches a single plugin. Requests made on behalf of a user go
+// through the HTTP API with their cookies; background searches without cookies
+// call the plugin directly so they don't need to authenticate.
+func (s *Service) searchPlugin(ctx context.Context, pluginID string, req SearchRequest, cookies []*http.Cookie) ([]plugins.IndexerRelease, error) {
+	if cookies == nil {
+		return s.searchPluginViaRPC(ctx, pluginID, req)
+	}
+	return s.searchPluginViaHTTP(ctx, pluginID, req, cookies)
+}
+
+// searchPluginViaRPC searches a plugin by calling its search route over RPC
+func (s *Service) searchPluginViaRPC(ctx context.Context, pluginID string, req SearchRequest) ([]plugins.IndexerRelease, error) {
+	plugin, exists := s.pluginManager.GetPlugin(pluginID)
+	if !exists {
+		return nil, fmt.Errorf("plugin %s not found", pluginID)
+	}
+
+	path := fmt.Sprintf("/api/plugins/%s/search", pluginID)
+	switch req.Type {
+	case "tv":
+		path += "/tv"
+	case "movie":
+		path += "/movie"
+	}
+
+	pluginReq := &plugins.PluginHTTPRequest{
+		Method:  "GET",
+		Path:    path,
+		Headers: map[string][]string{},
+		Query:   buildSearchParams(req),
+	}
+
+	pluginResp, err := plugin.Client.HandleAPI(ctx, pluginReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call plugin: %w", err)
+	}
+
+	if pluginResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", pluginResp.StatusCode, string(pluginResp.Body))
+	}
+
+	var searchResp struct {
+		Releases []plugins.IndexerRelease `json:"releases"`
+	}
+	if err := json.Unmarshal(pluginResp.Body, &searchResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return searchResp.Releases, nil
+}
+
 // searchPluginViaHTTP searches a plugin using its HTTP API endpoints
 func (s *Service) searchPluginViaHTTP(ctx context.Context, pluginID string, req SearchRequest, cookies []*http.Cookie) ([]plugins.IndexerRelease, error) {
 	// Build the appropriate endpoint based on search type
@@ -269,39 +351,7 @@ func (s *Service) searchPluginViaHTTP(ctx context.Context, pluginID string, req
 	}
 
 	// Build query parameters
-	params := url.Values{}
-	if req.Query != "" {
-		params.Add("q", req.Query)
-	}
-	if len(req.Categories) > 0 {
-		for _, cat := range req.Categories {
-			params.Add("categories", cat)
-		}
-	}
-	if req.TVDBID != "" {
-		params.Add("tvdbid", req.TVDBID)
-	}
-	if req.TVRageID != "" {
-		params.Add("tvrageid", req.TVRageID)
-	}
-	if req.Season > 0 {
-		params.Add("season", strconv.Itoa(req.Season))
-	}
-	if req.Episode > 0 {
-		params.Add("episode", strconv.Itoa(req.Episode))
-	}
-	if req.IMDBID != "" {
-		params.Add("imdbid", req.IMDBID)
-	}
-	if req.TMDBID != "" {
-		params.Add("tmdbid", req.TMDBID)
-	}
-	if req.Limit > 0 {
-		params.Add("limit", strconv.Itoa(req.Limit))
-	}
-	if req.Offset > 0 {
-		params.Add("offset", strconv.Itoa(req.Offset))
-	}
+	params := buildSearchParams(req)
 
 	fullURL := endpoint
 	if len(params) > 0 {
@@ -351,6 +401,51 @@ func (s *Service) searchPluginViaHTTP(ctx context.Context, pluginID string, req
 	return searchResp.Releases, nil
 }
 
+// buildSearchParams converts a search request into indexer query parameters
+func buildSearchParams(req SearchRequest) url.Values {
+	params := url.Values{}
+	if query := req.searchQuery(); query != "" {
+		params.Add("q", query)
+	}
+	if len(req.Categories) > 0 {
+		for _, cat := range req.Categories {
+			params.Add("categories", cat)
+		}
+	}
+	if req.TVDBID != "" {
+		params.Add("tvdbid", req.TVDBID)
+	}
+	if req.TVRageID != "" {
+		params.Add("tvrageid", req.TVRageID)
+	}
+	if req.Season > 0 {
+		params.Add("season", strconv.Itoa(req.Season))
+	}
+	if req.Episode > 0 {
+		params.Add("episode", strconv.Itoa(req.Episode))
+	}
+	if req.IMDBID != "" {
+		params.Add("imdbid", req.IMDBID)
+	}
+	if req.TMDBID != "" {
+		params.Add("tmdbid", req.TMDBID)
+	}
+	if req.Limit > 0 {
+		params.Add("limit", strconv.Itoa(req.Limit))
+	}
+	if req.Offset > 0 {
+		params.Add("offset", strconv.Itoa(req.Offset))
+	}
+	if req.Fresh {
+		params.Add("fresh", "1")
+	}
+	if req.MinimumSeeders > 0 {
+		params.Add("minseeders", strconv.Itoa(req.MinimumSeeders))
+	}
+
+	return params
+}
+
 // deduplicateReleases removes duplicate releases based on GUID
 func (s *Service) deduplicateReleases(releases []plugins.IndexerRelease) []plugins.IndexerRelease {
 	seen := make(map[string]bool)
@@ -418,11 +513,12 @@ func (s *Service) filterBySeriesName(releases []plugins.IndexerRelease, seriesNa
 	filtered := []plugins.IndexerRelease{}
 
 	// Normalize series name for comparison (lowercase, replace spaces with dots)
-	normalizedSeries := strings.ToLower(strings.ReplaceAll(seriesName, " ", "."))
+	normalizedSeries := normalizeReleaseName(seriesName)
 
 	for _, release := range releases {
-		// Normalize release title
-		normalizedTitle := strings.ToLower(release.Title)
+		// Normalize release title, dropping a release group in brackets in front
+		// of it as anime releases have
+		normalizedTitle := normalizeReleaseName(leadingTagsPattern.ReplaceAllString(release.Title, ""))
 
 		// Check if the release title starts with the series name
 		// e.g., "The.Rookie.S01E01" matches "The.Rookie" but "The.Rookie.Feds.S01E01" does not
@@ -435,10 +531,12 @@ func (s *Service) filterBySeriesName(releases []plugins.IndexerRelease, seriesNa
 			// - Year in parentheses like (2018)
 			// - Season marker like .S01 or .s01
 			// - Direct season marker like S01 (no dot)
+			// - A dash before an anime episode number like " - 125"
 			if len(afterSeries) == 0 ||
 				afterSeries[0] == '(' || // Year
 				(len(afterSeries) >= 2 && afterSeries[0] == '.' && (afterSeries[1] == 's' || afterSeries[1] == 'S')) || // .S01
-				(afterSeries[0] == 's' || afterSeries[0] == 'S') { // S01
+				(afterSeries[0] == 's' || afterSeries[0] == 'S') || // S01
+				strings.HasPrefix(afterSeries, ".-") { // - 125
 				filtered = append(filtered, release)
 			}
 		}
@@ -446,3 +544,24 @@ func (s *Service) filterBySeriesName(releases []plugins.IndexerRelease, seriesNa
 
 	return filtered
 }
+
+// leadingTagsPattern matches tags in brackets in front of a release title, like
+// the "[SubsPlease]" of "[SubsPlease] Show - 125 (1080p)"
+var leadingTagsPattern = regexp.MustCompile(`^(?:\s*\[[^\]]*\])+\s*`)
+
+// normalizeReleaseName lowercases a name and separates its words with dots
+func normalizeReleaseName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", ".", "_", ".").Replace(name)
+}
+
+// filterByAbsoluteEpisode keeps the anime releases of one absolute episode
+func filterByAbsoluteEpisode(releases []plugins.IndexerRelease, absolute int) []plugins.IndexerRelease {
+	filtered := []plugins.IndexerRelease{}
+	for _, release := range releases {
+		if _, number, ok := library.ParseAbsoluteEpisode(release.Title); ok && number == absolute {
+			filtered = append(filtered, release)
+		}
+	}
+	return filtered
+}
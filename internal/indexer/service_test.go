@@ -0,0 +1,55 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func releaseTitles(releases []plugins.IndexerRelease) []string {
+	titles := make([]string, len(releases))
+	for i, release := range releases {
+		titles[i] = release.Title
+	}
+	return titles
+}
+
+func TestFilterBySeriesName(t *testing.T) {
+	releases := []plugins.IndexerRelease{
+		{Title: "The.Rookie.S01E01.1080p.WEB.H264-GROUP"},
+		{Title: "The.Rookie.Feds.S01E01.1080p.WEB.H264-GROUP"},
+		{Title: "[SubsPlease] The Rookie - 05 (1080p) [ABCD1234]"},
+		{Title: "[Group][1080p] The_Rookie_-_06"},
+		{Title: "[SubsPlease] The Rookie Feds - 05 (1080p)"},
+	}
+
+	got := releaseTitles((&Service{}).filterBySeriesName(releases, "The Rookie"))
+	want := []string{releases[0].Title, releases[2].Title, releases[3].Title}
+	if len(got) != len(want) {
+		t.Fatalf("filterBySeriesName() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("filterBySeriesName()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFilterByAbsoluteEpisode(t *testing.T) {
+	releases := []plugins.IndexerRelease{
+		{Title: "[SubsPlease] One Piece - 1071 (1080p) [ABCD1234]"},
+		{Title: "[SubsPlease] One Piece - 1070 (1080p)"},
+		{Title: "[Group] One Piece - 1071v2 [720p]"},
+		{Title: "One.Piece.S21E01.1080p.WEB"},
+	}
+
+	got := releaseTitles(filterByAbsoluteEpisode(releases, 1071))
+	if len(got) != 2 || got[0] != releases[0].Title || got[1] != releases[2].Title {
+		t.Errorf("filterByAbsoluteEpisode() = %v", got)
+	}
+
+	req := SearchRequest{Query: "One Piece", AbsoluteEpisode: 5}
+	if q := buildSearchParams(req).Get("q"); q != "One Piece 05" {
+		t.Errorf("query = %q, want the absolute episode number appended", q)
+	}
+}
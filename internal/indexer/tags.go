@@ -0,0 +1,57 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"go.uber.org/zap"
+)
+
+// TagSource looks up the tags of media items and plugins
+type TagSource interface {
+	MediaTags(ctx context.Context, mediaItemID int64) ([]string, error)
+	PluginTags(ctx context.Context) (map[string][]string, error)
+}
+
+// SetTags enables tag routing: indexers with tags only serve searches for
+// media whose monitoring rule shares one of them
+func (s *Service) SetTags(source TagSource) {
+	s.tags = source
+}
+
+// filterByTags drops the indexers a media search mustn't use. Searches that
+// aren't for a media item use every indexer, and lookup failures are logged
+// rather than failing the search.
+func (s *Service) filterByTags(ctx context.Context, indexers []*plugins.LoadedPlugin, req SearchRequest) []*plugins.LoadedPlugin {
+	if s.tags == nil || req.MediaItemID == 0 || len(indexers) == 0 {
+		return indexers
+	}
+
+	pluginTags, err := s.tags.PluginTags(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to look up indexer tags, searching all indexers", zap.Error(err))
+		return indexers
+	}
+	if len(pluginTags) == 0 {
+		return indexers
+	}
+	mediaTags, err := s.tags.MediaTags(ctx, req.MediaItemID)
+	if err != nil {
+		s.logger.Warn("Failed to look up media tags, searching all indexers",
+			zap.Int64("media_item_id", req.MediaItemID), zap.Error(err))
+		return indexers
+	}
+
+	filtered := make([]*plugins.LoadedPlugin, 0, len(indexers))
+	for _, p := range indexers {
+		if tags.Matches(pluginTags[p.Meta.ID], mediaTags) {
+			filtered = append(filtered, p)
+		} else {
+			s.logger.Debug("Skipping indexer without a tag of the media",
+				zap.String("plugin_id", p.Meta.ID),
+				zap.Int64("media_item_id", req.MediaItemID))
+		}
+	}
+	return filtered
+}
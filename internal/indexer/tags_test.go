@@ -0,0 +1,61 @@
+package indexer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+type fakeTagSource struct {
+	media   map[int64][]string
+	plugins map[string][]string
+}
+
+func (f fakeTagSource) MediaTags(ctx context.Context, mediaItemID int64) ([]string, error) {
+	return f.media[mediaItemID], nil
+}
+
+func (f fakeTagSource) PluginTags(ctx context.Context) (map[string][]string, error) {
+	return f.plugins, nil
+}
+
+func TestFilterByTags(t *testing.T) {
+	s := &Service{logger: zap.NewNop()}
+	s.SetTags(fakeTagSource{
+		media:   map[int64][]string{1: {"anime"}, 2: {"kids"}},
+		plugins: map[string][]string{"anime-tracker": {"anime"}},
+	})
+	indexers := []*plugins.LoadedPlugin{
+		{Meta: &plugins.PluginMetadata{ID: "newznab"}},
+		{Meta: &plugins.PluginMetadata{ID: "anime-tracker"}},
+	}
+	ids := func(indexers []*plugins.LoadedPlugin) []string {
+		out := make([]string, len(indexers))
+		for i, p := range indexers {
+			out[i] = p.Meta.ID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		req  SearchRequest
+		want []string
+	}{
+		{"not a media search", SearchRequest{Query: "anything"}, []string{"newznab", "anime-tracker"}},
+		{"media sharing the tag", SearchRequest{MediaItemID: 1}, []string{"newznab", "anime-tracker"}},
+		{"media with another tag", SearchRequest{MediaItemID: 2}, []string{"newznab"}},
+		{"untagged media", SearchRequest{MediaItemID: 3}, []string{"newznab"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(s.filterByTags(context.Background(), indexers, tt.req))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterByTags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
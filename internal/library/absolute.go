@@ -0,0 +1,116 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+)
+
+// SeriesEpisode is an episode of a series with its numbers
+type SeriesEpisode struct {
+	MediaItemID int64
+	Season      int
+	Episode     int
+	Absolute    int // 0 for specials
+}
+
+// ListSeriesEpisodes lists the episodes of a series in airing order with their
+// absolute numbers. Episodes without an absolute_number in their metadata, as
+// TMDB doesn't provide one, are numbered on from the episode before them.
+func ListSeriesEpisodes(ctx context.Context, queries *generated.Queries, seriesID int64) ([]SeriesEpisode, error) {
+	seasons, err := queries.ListChildMediaItems(ctx, &seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+
+	var episodes []SeriesEpisode
+	for _, season := range seasons {
+		if season.Kind != "tv_season" {
+			continue
+		}
+		items, err := queries.ListChildMediaItems(ctx, &season.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list episodes of season %d: %w", season.ID, err)
+		}
+		for _, item := range items {
+			var metadata struct {
+				Season   int `json:"season"`
+				Episode  int `json:"episode"`
+				Absolute int `json:"absolute_number"`
+			}
+			if json.Unmarshal(item.Metadata, &metadata) != nil || metadata.Episode == 0 {
+				continue
+			}
+			episodes = append(episodes, SeriesEpisode{
+				MediaItemID: item.ID,
+				Season:      metadata.Season,
+				Episode:     metadata.Episode,
+				Absolute:    metadata.Absolute,
+			})
+		}
+	}
+
+	sort.Slice(episodes, func(i, j int) bool {
+		if episodes[i].Season != episodes[j].Season {
+			return episodes[i].Season < episodes[j].Season
+		}
+		return episodes[i].Episode < episodes[j].Episode
+	})
+	numberAbsolute(episodes)
+	return episodes, nil
+}
+
+// numberAbsolute fills in the absolute numbers of sorted episodes that have
+// none, counting on from the episode before. Specials aren't counted.
+func numberAbsolute(episodes []SeriesEpisode) {
+	last := 0
+	for i := range episodes {
+		if episodes[i].Season == 0 {
+			episodes[i].Absolute = 0
+			continue
+		}
+		if episodes[i].Absolute == 0 {
+			episodes[i].Absolute = last + 1
+		}
+		last = episodes[i].Absolute
+	}
+}
+
+// FindAbsoluteEpisode returns the episode with an absolute number, or nil
+func FindAbsoluteEpisode(episodes []SeriesEpisode, absolute int) *SeriesEpisode {
+	for i := range episodes {
+		if episodes[i].Absolute == absolute {
+			return &episodes[i]
+		}
+	}
+	return nil
+}
+
+// AbsoluteEpisodeNumber returns the absolute number of an episode media item,
+// or 0 when it has none. The episode's series is looked up through its season.
+func AbsoluteEpisodeNumber(ctx context.Context, queries *generated.Queries, episode generated.MediaItem) (int, error) {
+	if episode.ParentID == nil {
+		return 0, nil
+	}
+	season, err := queries.GetMediaItem(ctx, *episode.ParentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get season: %w", err)
+	}
+	if season.ParentID == nil {
+		return 0, nil
+	}
+
+	episodes, err := ListSeriesEpisodes(ctx, queries, *season.ParentID)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range episodes {
+		if e.MediaItemID == episode.ID {
+			return e.Absolute, nil
+		}
+	}
+	return 0, nil
+}
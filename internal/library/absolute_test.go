@@ -0,0 +1,27 @@
+package library
+
+import "testing"
+
+func TestNumberAbsolute(t *testing.T) {
+	episodes := []SeriesEpisode{
+		{Season: 0, Episode: 1, Absolute: 3},
+		{Season: 1, Episode: 1},
+		{Season: 1, Episode: 2},
+		{Season: 2, Episode: 1, Absolute: 13},
+		{Season: 2, Episode: 2},
+	}
+	numberAbsolute(episodes)
+
+	want := []int{0, 1, 2, 13, 14}
+	for i, e := range episodes {
+		if e.Absolute != want[i] {
+			t.Errorf("S%02dE%02d absolute = %d, want %d", e.Season, e.Episode, e.Absolute, want[i])
+		}
+	}
+	if e := FindAbsoluteEpisode(episodes, 14); e == nil || e.Season != 2 || e.Episode != 2 {
+		t.Errorf("FindAbsoluteEpisode(14) = %+v, want S02E02", e)
+	}
+	if e := FindAbsoluteEpisode(episodes, 3); e != nil {
+		t.Errorf("FindAbsoluteEpisode(3) = %+v, want specials skipped", e)
+	}
+}
@@ -0,0 +1,366 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// MetadataEnricher - Look up new media items with the metadata plugins
+// =============================================================================
+// Items the scanner creates are looked up with the provider configured in
+// metadata.preferred_provider first, falling back to the other providers when
+// it finds nothing. Music is looked up with MusicBrainz. Lookups run in the background; the media.item.created
+// event is published once the lookup is done, with metadata_lookup set so
+// plugins don't look the item up again.
+// =============================================================================
+
+const (
+	// ConfigPreferredProvider is the config key of the provider tried first
+	ConfigPreferredProvider = "metadata.preferred_provider"
+
+	// defaultProvider is tried first when no provider is configured
+	defaultProvider = "tmdb"
+
+	// enrichQueueSize is how many created items may wait for a lookup. Items
+	// past that are published straight away and left to the plugins.
+	enrichQueueSize = 1000
+
+	// enrichTimeout bounds the lookups of one item across all providers
+	enrichTimeout = 2 * time.Minute
+)
+
+// metadataProvider is a metadata plugin and its enrich endpoint
+type metadataProvider struct {
+	Name     string
+	PluginID string
+	Path     string
+}
+
+// metadataProviders are the providers in the order they are tried after the preferred one
+var metadataProviders = []metadataProvider{
+	{Name: "tmdb", PluginID: "tmdb-plugin", Path: "/api/plugins/tmdb/enrich"},
+	{Name: "tvdb", PluginID: "tvdb-plugin", Path: "/api/plugins/tvdb/enrich"},
+}
+
+// musicProvider looks up artists, albums and tracks
+var musicProvider = metadataProvider{Name: "musicbrainz", PluginID: "musicbrainz-plugin", Path: "/api/plugins/musicbrainz/enrich"}
+
+// providersFor returns the providers to look up an item of a kind with, in order
+func providersFor(kind, preferred string) []metadataProvider {
+	if isMusicKind(kind) {
+		return []metadataProvider{musicProvider}
+	}
+	return providerOrder(preferred)
+}
+
+// providerOrder returns the providers with the preferred one first
+func providerOrder(preferred string) []metadataProvider {
+	order := make([]metadataProvider, 0, len(metadataProviders))
+	for _, p := range metadataProviders {
+		if p.Name == preferred {
+			order = append(order, p)
+		}
+	}
+	for _, p := range metadataProviders {
+		if p.Name != preferred {
+			order = append(order, p)
+		}
+	}
+	return order
+}
+
+// enrichable reports whether items of a kind are looked up by the enricher
+func enrichable(kind string) bool {
+	switch kind {
+	case "movie", "tv_series", "tv_season", "tv_episode":
+		return true
+	}
+	return isMusicKind(kind)
+}
+
+// isMusicKind reports whether a media kind is music
+func isMusicKind(kind string) bool {
+	switch kind {
+	case "music_artist", "music_album", "music_track":
+		return true
+	}
+	return false
+}
+
+// enrichJob is a created item waiting for a lookup, with its created event
+type enrichJob struct {
+	item generated.MediaItem
+	data map[string]interface{}
+}
+
+// MetadataEnricher looks up created media items with the metadata plugins
+type MetadataEnricher struct {
+	plugins *plugins.PluginManager
+	queries *generated.Queries
+	config  *configstore.Store
+	logger  *zap.Logger
+
+	queue    chan enrichJob
+	start    sync.Once
+	observer plugins.MetadataObserver
+}
+
+// NewMetadataEnricher creates a metadata enricher
+func NewMetadataEnricher(pm *plugins.PluginManager, queries *generated.Queries, config *configstore.Store, logger *zap.Logger) *MetadataEnricher {
+	return &MetadataEnricher{
+		plugins: pm,
+		queries: queries,
+		config:  config,
+		logger:  logger.With(zap.String("component", "metadata-enricher")),
+		queue:   make(chan enrichJob, enrichQueueSize),
+	}
+}
+
+// SetMetadataObserver sets what is told about the items the enricher stored
+// metadata on
+func (e *MetadataEnricher) SetMetadataObserver(observer plugins.MetadataObserver) {
+	e.observer = observer
+}
+
+// enqueue schedules a lookup of a created item, and reports false when the
+// queue is full
+func (e *MetadataEnricher) enqueue(item generated.MediaItem, data map[string]interface{}) bool {
+	e.start.Do(func() { go e.run() })
+
+	select {
+	case e.queue <- enrichJob{item: item, data: data}:
+		return true
+	default:
+		return false
+	}
+}
+
+// run looks up queued items one at a time, so a large scan doesn't flood the providers
+func (e *MetadataEnricher) run() {
+	for job := range e.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), enrichTimeout)
+		provider, externalIDs := e.enrich(ctx, job.item, job.data)
+		cancel()
+
+		job.data["metadata_lookup"] = provider
+		if len(externalIDs) > 0 {
+			job.data["external_ids"] = externalIDs
+		}
+		e.plugins.Events().Publish(plugins.EventMediaItemCreated, job.data)
+	}
+}
+
+// enrichRequest is the body of a provider's enrich endpoint
+type enrichRequest struct {
+	Title       string                 `json:"title"`
+	Year        int                    `json:"year,omitempty"`
+	Kind        string                 `json:"kind"`
+	Season      int                    `json:"season,omitempty"`
+	Episode     int                    `json:"episode,omitempty"`
+	Artist      string                 `json:"artist,omitempty"`
+	Album       string                 `json:"album,omitempty"`
+	Track       int                    `json:"track,omitempty"`
+	ExternalIDs map[string]interface{} `json:"external_ids,omitempty"`
+}
+
+// enrichResponse is what a provider's enrich endpoint returns
+type enrichResponse struct {
+	Success       bool                   `json:"success"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	ExternalIDs   map[string]interface{} `json:"external_ids"`
+	LowConfidence bool                   `json:"low_confidence"`
+	Score         float64                `json:"score"`
+	Candidates    []interface{}          `json:"candidates"`
+}
+
+// enrich looks an item up with each provider until one finds it, and stores
+// what it found. It returns the provider that found the item, or "none", and
+// the item's external IDs.
+func (e *MetadataEnricher) enrich(ctx context.Context, item generated.MediaItem, data map[string]interface{}) (string, map[string]interface{}) {
+	req := enrichRequest{Kind: item.Kind}
+	req.Title, _ = data["search_title"].(string)
+	if req.Title == "" {
+		req.Title = item.Title
+	}
+	req.Year, _ = data["year"].(int)
+	req.Season, _ = data["season"].(int)
+	req.Episode, _ = data["episode"].(int)
+	req.Artist, _ = data["artist"].(string)
+	req.Album, _ = data["album"].(string)
+	req.Track, _ = data["track"].(int)
+	req.ExternalIDs = e.seriesExternalIDs(ctx, item)
+
+	preferred := e.config.GetOrDefault(ctx, ConfigPreferredProvider, defaultProvider)
+
+	var lowConfidence *enrichResponse
+	for _, provider := range providersFor(item.Kind, preferred) {
+		resp, err := e.lookup(ctx, provider, req)
+		if err != nil {
+			e.logger.Debug("metadata lookup failed",
+				zap.String("provider", provider.Name),
+				zap.Int64("media_item_id", item.ID),
+				zap.Error(err))
+			continue
+		}
+		if resp == nil {
+			continue
+		}
+		if !resp.Success {
+			if resp.LowConfidence && lowConfidence == nil {
+				lowConfidence = resp
+			}
+			continue
+		}
+
+		externalIDs, err := e.store(ctx, item.ID, resp.Metadata, resp.ExternalIDs)
+		if err != nil {
+			e.logger.Warn("failed to store metadata",
+				zap.String("provider", provider.Name),
+				zap.Int64("media_item_id", item.ID),
+				zap.Error(err))
+			return "none", nil
+		}
+		e.logger.Debug("looked up media item",
+			zap.String("provider", provider.Name),
+			zap.Int64("media_item_id", item.ID),
+			zap.String("kind", item.Kind))
+		return provider.Name, externalIDs
+	}
+
+	// Keep the closest results so the item can be matched by hand
+	if lowConfidence != nil {
+		match := map[string]interface{}{
+			"tmdb_match": map[string]interface{}{
+				"score":          lowConfidence.Score,
+				"low_confidence": true,
+				"candidates":     lowConfidence.Candidates,
+			},
+		}
+		if _, err := e.store(ctx, item.ID, match, nil); err != nil {
+			e.logger.Warn("failed to store match candidates", zap.Int64("media_item_id", item.ID), zap.Error(err))
+		}
+	}
+	return "none", nil
+}
+
+// lookup calls a provider's enrich endpoint. It returns nil without an error
+// when the provider isn't loaded or has no match.
+func (e *MetadataEnricher) lookup(ctx context.Context, provider metadataProvider, req enrichRequest) (*enrichResponse, error) {
+	plugin, ok := e.plugins.GetPlugin(provider.PluginID)
+	if !ok {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  "POST",
+		Path:    provider.Path,
+		Query:   map[string][]string{},
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s enrich returned HTTP %d: %s", provider.Name, resp.StatusCode, resp.Body)
+	}
+
+	var result enrichResponse
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode %s enrich response: %w", provider.Name, err)
+	}
+	return &result, nil
+}
+
+// store merges metadata and external IDs into a media item and returns its
+// external IDs
+func (e *MetadataEnricher) store(ctx context.Context, id int64, metadata, externalIDs map[string]interface{}) (map[string]interface{}, error) {
+	if externalIDs == nil {
+		externalIDs = make(map[string]interface{})
+	}
+	if tmdbID, ok := metadata["tmdb_id"].(string); ok && tmdbID != "" {
+		externalIDs["tmdb"] = tmdbID
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	item, err := e.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{
+		ID:       id,
+		Metadata: metadataJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(externalIDs) > 0 {
+		externalIDsJSON, err := json.Marshal(externalIDs)
+		if err != nil {
+			return nil, err
+		}
+		if item, err = e.queries.UpdateMediaExternalIDs(ctx, generated.UpdateMediaExternalIDsParams{
+			ID:          id,
+			ExternalIds: externalIDsJSON,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if e.observer != nil {
+		if err := e.observer.MetadataUpdated(ctx, item); err != nil {
+			e.logger.Warn("failed to process stored metadata", zap.Int64("media_item_id", id), zap.Error(err))
+		}
+	}
+
+	var stored map[string]interface{}
+	_ = json.Unmarshal(item.ExternalIds, &stored)
+	return stored, nil
+}
+
+// seriesExternalIDs returns the external IDs to look an item up by. Seasons
+// and episodes are looked up by those of their series, and tracks by those of
+// their album, so a series or album matched once keeps its children on it.
+func (e *MetadataEnricher) seriesExternalIDs(ctx context.Context, item generated.MediaItem) map[string]interface{} {
+	externalIDs := make(map[string]interface{})
+	_ = json.Unmarshal(item.ExternalIds, &externalIDs)
+
+	current := item
+	for depth := 0; depth < 2 && !isLookupRoot(current.Kind) && current.ParentID != nil; depth++ {
+		parent, err := e.queries.GetMediaItem(ctx, *current.ParentID)
+		if err != nil {
+			break
+		}
+		current = parent
+	}
+	if current.ID != item.ID && isLookupRoot(current.Kind) {
+		var seriesIDs map[string]interface{}
+		if err := json.Unmarshal(current.ExternalIds, &seriesIDs); err == nil {
+			for k, v := range seriesIDs {
+				externalIDs[k] = v
+			}
+		}
+	}
+	return externalIDs
+}
+
+// isLookupRoot reports whether the children of items of a kind are looked up
+// by its external IDs
+func isLookupRoot(kind string) bool {
+	return kind == "tv_series" || kind == "music_album"
+}
@@ -0,0 +1,25 @@
+package library
+
+import "testing"
+
+func TestProviderOrder(t *testing.T) {
+	tests := []struct {
+		preferred string
+		want      []string
+	}{
+		{"tmdb", []string{"tmdb", "tvdb"}},
+		{"tvdb", []string{"tvdb", "tmdb"}},
+		{"unknown", []string{"tmdb", "tvdb"}},
+	}
+	for _, tt := range tests {
+		order := providerOrder(tt.preferred)
+		if len(order) != len(tt.want) {
+			t.Fatalf("providerOrder(%q) returned %d providers, want %d", tt.preferred, len(order), len(tt.want))
+		}
+		for i, p := range order {
+			if p.Name != tt.want[i] {
+				t.Errorf("providerOrder(%q)[%d] = %q, want %q", tt.preferred, i, p.Name, tt.want[i])
+			}
+		}
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/history"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -18,6 +21,8 @@ import (
 
 type FileHandler struct {
 	queries *generated.Queries
+	plugins *plugins.PluginManager
+	history *history.Service
 	logger  *zap.Logger
 }
 
@@ -29,6 +34,16 @@ func NewFileHandler(queries *generated.Queries, logger *zap.Logger) *FileHandler
 	}
 }
 
+// SetPluginManager sets where the subtitles plugin used to fetch subtitles is found
+func (h *FileHandler) SetPluginManager(pm *plugins.PluginManager) {
+	h.plugins = pm
+}
+
+// SetHistory sets the service deletions are recorded in
+func (h *FileHandler) SetHistory(historySvc *history.Service) {
+	h.history = historySvc
+}
+
 // =============================================================================
 // GetMediaFiles - GET /api/media/{id}/files
 // =============================================================================
@@ -79,25 +94,33 @@ func (h *FileHandler) GetMediaFiles(w http.ResponseWriter, r *http.Request) {
 
 	// Convert to response format
 	type FileResponse struct {
-		ID          int64   `json:"id"`
-		MediaItemID *int64  `json:"media_item_id"`
-		Path        string  `json:"path"`
-		Size        *int64  `json:"size"`
-		Hash        *string `json:"hash"`
-		CreatedAt   string  `json:"created_at"`
-		UpdatedAt   string  `json:"updated_at"`
+		ID           int64   `json:"id"`
+		MediaItemID  *int64  `json:"media_item_id"`
+		Path         string  `json:"path"`
+		Kind         string  `json:"kind"`
+		Size         *int64  `json:"size"`
+		Hash         *string `json:"hash"`
+		Quality      *string `json:"quality"`
+		ReleaseGroup *string `json:"release_group"`
+		ReleaseTitle *string `json:"release_title"`
+		CreatedAt    string  `json:"created_at"`
+		UpdatedAt    string  `json:"updated_at"`
 	}
 
 	response := make([]FileResponse, len(files))
 	for i, file := range files {
 		response[i] = FileResponse{
-			ID:          file.ID,
-			MediaItemID: file.MediaItemID,
-			Path:        file.Path,
-			Size:        file.Size,
-			Hash:        file.Hash,
-			CreatedAt:   file.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:   file.UpdatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+			ID:           file.ID,
+			MediaItemID:  file.MediaItemID,
+			Path:         file.Path,
+			Kind:         file.Kind,
+			Size:         file.Size,
+			Hash:         file.Hash,
+			Quality:      file.Quality,
+			ReleaseGroup: file.ReleaseGroup,
+			ReleaseTitle: file.ReleaseTitle,
+			CreatedAt:    file.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt:    file.UpdatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
 		}
 	}
 
@@ -158,69 +181,16 @@ func (h *FileHandler) DeleteMediaFile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-// =============================================================================
-// DeleteMediaItemWithFiles - DELETE /api/media/{id}/with-files
-// =============================================================================
-// Deletes a media item and optionally deletes all associated physical files.
-//
-// Query Parameters:
-//   - delete_files: "true" to also delete physical files (default: false)
-//
-// Response:
-//   - 204 No Content: Media item deleted successfully
-//   - 400 Bad Request: Invalid media ID
-//   - 404 Not Found: Media item not found
-//   - 500 Internal Server Error: Database or filesystem error
-// =============================================================================
-
-func (h *FileHandler) DeleteMediaItemWithFiles(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	mediaIDStr := chi.URLParam(r, "id")
-	mediaID, err := strconv.ParseInt(mediaIDStr, 10, 64)
-	if err != nil {
-		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
-		return
-	}
-
-	// Check if we should delete physical files
-	deleteFiles := r.URL.Query().Get("delete_files") == "true"
-
-	// Get all files before deleting if we need to delete physical files
-	var filePaths []string
-	if deleteFiles {
-		files, err := h.queries.ListMediaFilesByItem(ctx, &mediaID)
-		if err != nil {
-			h.logger.Error("failed to get media files", zap.Error(err))
-			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get media files")
-			return
-		}
-
-		for _, file := range files {
-			filePaths = append(filePaths, file.Path)
-		}
-	}
-
-	// Delete media item (cascade will delete media_files entries)
-	if err := h.queries.DeleteMediaItem(ctx, mediaID); err != nil {
-		h.logger.Error("failed to delete media item", zap.Error(err))
-		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to delete media item")
-		return
-	}
-
-	// Optionally delete physical files
-	if deleteFiles {
-		for _, path := range filePaths {
-			if err := os.Remove(path); err != nil {
-				h.logger.Warn("failed to delete physical file",
-					zap.String("path", path),
-					zap.Error(err))
-				// Continue deleting other files even if one fails
-			}
-		}
-	}
+	h.history.Record(ctx, history.Event{
+		Type:        history.EventDeleted,
+		MediaItemID: file.MediaItemID,
+		Title:       filepath.Base(file.Path),
+		Data: map[string]interface{}{
+			"kind":          "file",
+			"path":          file.Path,
+			"files_deleted": deletePhysical,
+		},
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
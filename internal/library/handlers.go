@@ -3,10 +3,15 @@ package library
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/go-chi/chi/v5"
 
 	"go.uber.org/zap"
 )
@@ -16,10 +21,14 @@ import (
 // =============================================================================
 
 type Handler struct {
-	queries *generated.Queries
-	scanner *Scanner
-	logger  *zap.Logger
-	rootDir string
+	queries   *generated.Queries
+	scanner   *Scanner
+	verifier  *Verifier
+	importer  *Importer
+	mediaInfo *MediaInfoRefresher // nil until SetMediaInfoRefresher is called
+	refresher *MetadataRefresher  // nil until SetMetadataRefresher is called
+	logger    *zap.Logger
+	rootDir   string
 }
 
 // NewHandler creates a new library handler
@@ -27,13 +36,45 @@ func NewHandler(queries *generated.Queries, logger *zap.Logger, rootDir string)
 	scanner := NewScanner(queries, logger, rootDir)
 
 	return &Handler{
-		queries: queries,
-		scanner: scanner,
-		logger:  logger,
-		rootDir: rootDir,
+		queries:  queries,
+		scanner:  scanner,
+		verifier: NewVerifier(queries, logger),
+		importer: NewImporter(queries, scanner.service, logger),
+		logger:   logger,
+		rootDir:  rootDir,
 	}
 }
 
+// Verifier returns the handler's verifier, so scheduled runs share its state
+func (h *Handler) Verifier() *Verifier {
+	return h.verifier
+}
+
+// Scanner returns the handler's scanner, so scheduled scans share its paths
+func (h *Handler) Scanner() *Scanner {
+	return h.scanner
+}
+
+// SetMediaInfoRefresher enables the media info refresh endpoints
+func (h *Handler) SetMediaInfoRefresher(refresher *MediaInfoRefresher) {
+	h.mediaInfo = refresher
+}
+
+// SetMetadataRefresher enables refreshing series on demand
+func (h *Handler) SetMetadataRefresher(refresher *MetadataRefresher) {
+	h.refresher = refresher
+}
+
+// SetEventBus sets the bus the scanner publishes to plugins on
+func (h *Handler) SetEventBus(events *plugins.EventBus) {
+	h.scanner.SetEventBus(events)
+}
+
+// SetMetadataEnricher sets the enricher the scanner looks up created items with
+func (h *Handler) SetMetadataEnricher(enricher *MetadataEnricher) {
+	h.scanner.SetMetadataEnricher(enricher)
+}
+
 // SetMediaPath sets the library path for a specific media type on the scanner
 func (h *Handler) SetMediaPath(mediaType, path string) {
 	h.scanner.SetMediaPath(mediaType, path)
@@ -209,3 +250,347 @@ func (h *Handler) ResetScanner(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// =============================================================================
+// StartVerification - POST /api/library/verify
+// =============================================================================
+// Starts verifying media files in the background. With resume (the default),
+// a cancelled or interrupted run continues where it stopped.
+//
+// Access: Admin only (enforced by middleware)
+//
+// Request Body (optional):
+//   {
+//     "compute_hashes": false,
+//     "resume": true
+//   }
+//
+// Response:
+//   - 202 Accepted: Verification started, returns the run
+//   - 409 Conflict: Verification already running
+//   - 500 Internal Server Error: Database error
+// =============================================================================
+
+func (h *Handler) StartVerification(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		ComputeHashes bool  `json:"compute_hashes"`
+		Resume        *bool `json:"resume"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+			return
+		}
+	}
+
+	opts := VerifyOptions{ComputeHashes: body.ComputeHashes, Resume: true}
+	if body.Resume != nil {
+		opts.Resume = *body.Resume
+	}
+
+	run, err := h.verifier.Start(opts)
+	if err != nil {
+		if errors.Is(err, ErrVerificationRunning) {
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Verification already in progress")
+			return
+		}
+		h.logger.Error("failed to start verification", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to start verification")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, run)
+}
+
+// =============================================================================
+// CancelVerification - POST /api/library/verify/cancel
+// =============================================================================
+// Cancels the running verification. Its progress is kept so it can be resumed.
+//
+// Access: Admin only (enforced by middleware)
+//
+// Response:
+//   - 200 OK: Verification cancelled
+//   - 409 Conflict: No verification running
+// =============================================================================
+
+func (h *Handler) CancelVerification(w http.ResponseWriter, r *http.Request) {
+	if !h.verifier.Cancel() {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "No verification in progress")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]string{
+		"status":  "cancelled",
+		"message": "Verification cancelled",
+	})
+}
+
+// =============================================================================
+// GetVerificationReport - GET /api/library/verify/report
+// =============================================================================
+// Returns the progress of the last verification run, counts of files per
+// failed status, and a page of the files that failed verification.
+//
+// Access: Authenticated users (enforced by middleware)
+//
+// Query Parameters:
+//   - limit: Files per page (default 50, max 500)
+//   - offset: Files to skip
+// =============================================================================
+
+func (h *Handler) GetVerificationReport(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	report, err := h.verifier.Report(r.Context(), int32(limit), int32(offset))
+	if err != nil {
+		h.logger.Error("failed to get verification report", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get verification report")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, report)
+}
+
+// =============================================================================
+// ImportExisting - POST /api/library/import-existing
+// =============================================================================
+// Imports an already organized folder in the background, registering every
+// file where it is. Nothing is moved or renamed. With resume (the default), a
+// cancelled or interrupted import of the same folder continues where it
+// stopped.
+//
+// Access: Admin only (enforced by middleware)
+//
+// Request Body:
+//   {
+//     "path": "/media/movies",
+//     "kind": "movie",
+//     "resume": true
+//   }
+//
+// Response:
+//   - 202 Accepted: Import started, returns the run
+//   - 400 Bad Request: Invalid path or kind
+//   - 409 Conflict: Import already running
+//   - 500 Internal Server Error: Database error
+// =============================================================================
+
+func (h *Handler) ImportExisting(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Path   string `json:"path"`
+		Kind   string `json:"kind"`
+		Resume *bool  `json:"resume"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if !ValidImportKind(body.Kind) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "kind must be one of movie, tv, music or book")
+		return
+	}
+	path, err := checkImportPath(body.Path)
+	if err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "Invalid path")
+		return
+	}
+
+	opts := ImportOptions{Path: path, Kind: body.Kind, Resume: true}
+	if body.Resume != nil {
+		opts.Resume = *body.Resume
+	}
+
+	run, err := h.importer.Start(opts)
+	if err != nil {
+		if errors.Is(err, ErrImportRunning) {
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Library import already in progress")
+			return
+		}
+		h.logger.Error("failed to start library import", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to start library import")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, run)
+}
+
+// =============================================================================
+// CancelImportExisting - POST /api/library/import-existing/cancel
+// =============================================================================
+// Cancels the running import. Its progress is kept so it can be resumed.
+//
+// Access: Admin only (enforced by middleware)
+//
+// Response:
+//   - 200 OK: Import cancelled
+//   - 409 Conflict: No import running
+// =============================================================================
+
+func (h *Handler) CancelImportExisting(w http.ResponseWriter, r *http.Request) {
+	if !h.importer.Cancel() {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "No library import in progress")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]string{
+		"status":  "cancelled",
+		"message": "Library import cancelled",
+	})
+}
+
+// =============================================================================
+// GetImportExistingStatus - GET /api/library/import-existing/status
+// =============================================================================
+// Returns the progress of the current or last import, with the files it left
+// alone and why.
+//
+// Access: Authenticated users (enforced by middleware)
+//
+// Response:
+//   - 200 OK: The last run, or null when nothing was imported yet
+//   - 500 Internal Server Error: Database error
+// =============================================================================
+
+func (h *Handler) GetImportExistingStatus(w http.ResponseWriter, r *http.Request) {
+	run, err := h.importer.Latest(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get library import", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get library import")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, run)
+}
+
+// =============================================================================
+// RefreshMediaInfo - POST /api/library/refresh-mediainfo
+// =============================================================================
+// Probes media files with ffprobe in the background and stores their media
+// info. By default only files without media info are probed.
+//
+// Access: Admin only (enforced by middleware)
+//
+// Request Body (optional):
+//   {
+//     "all": false,
+//     "concurrency": 2
+//   }
+//
+// Response:
+//   - 202 Accepted: Refresh started, returns its progress
+//   - 409 Conflict: Refresh already running
+//   - 503 Service Unavailable: ffprobe is not available
+// =============================================================================
+
+func (h *Handler) RefreshMediaInfo(w http.ResponseWriter, r *http.Request) {
+	if h.mediaInfo == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Media info extraction is not configured")
+		return
+	}
+
+	body := struct {
+		All         bool `json:"all"`
+		Concurrency int  `json:"concurrency"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httputil.RespondError(w, http.StatusBadRequest, err, "Invalid request body")
+			return
+		}
+	}
+	if body.Concurrency < 0 || body.Concurrency > MaxMediaInfoConcurrency {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "concurrency must be between 1 and "+strconv.Itoa(MaxMediaInfoConcurrency))
+		return
+	}
+
+	progress, err := h.mediaInfo.Start(MediaInfoRefreshOptions{All: body.All, Concurrency: body.Concurrency})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrMediaInfoRefreshRunning):
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Media info refresh already in progress")
+		case errors.Is(err, mediainfo.ErrUnavailable):
+			httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "ffprobe is not available")
+		default:
+			h.logger.Error("failed to start media info refresh", zap.Error(err))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to start media info refresh")
+		}
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, progress)
+}
+
+// =============================================================================
+// GetMediaInfoRefreshStatus - GET /api/library/refresh-mediainfo/status
+// =============================================================================
+// Returns the progress of the current or last media info refresh.
+//
+// Access: Authenticated users (enforced by middleware)
+// =============================================================================
+
+func (h *Handler) GetMediaInfoRefreshStatus(w http.ResponseWriter, r *http.Request) {
+	if h.mediaInfo == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Media info extraction is not configured")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, h.mediaInfo.Status())
+}
+
+// =============================================================================
+// RefreshSeriesMetadata - POST /api/media/{id}/refresh
+// =============================================================================
+// Refreshes a series from TMDB right away, adding its new seasons and episodes,
+// and returns what changed.
+//
+// Access: Authenticated users (enforced by middleware)
+// =============================================================================
+
+func (h *Handler) RefreshSeriesMetadata(w http.ResponseWriter, r *http.Request) {
+	if h.refresher == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Metadata refresh needs the TMDB plugin")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media item ID")
+		return
+	}
+	item, err := h.queries.GetMediaItem(r.Context(), id)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item not found")
+		return
+	}
+	if item.Kind != "tv_series" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Only series can be refreshed")
+		return
+	}
+
+	summary, err := h.refresher.RefreshSeries(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNoTMDBID) {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Series has no TMDB ID, match it first")
+			return
+		}
+		h.logger.Error("failed to refresh series", zap.Int64("series_id", id), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, "Failed to refresh series")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, summary)
+}
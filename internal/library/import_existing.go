@@ -0,0 +1,451 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// Importer - In-place import of an already organized library
+// =============================================================================
+// Pointing Nimbus at a library another tool organized shouldn't move anything.
+// The Importer walks a folder of one media kind and registers every file where
+// it is:
+//   1. Titles, years and seasons are read from the folder names the library is
+//      organized by, falling back to the file names
+//   2. Media items and media_files rows are created as the scanner does, so new
+//      items are enriched by the metadata plugin
+//   3. Files that are already registered, or whose names can't be read
+//      unambiguously, are collected as conflicts instead of stopping the run
+//
+// Files are never moved or renamed. Runs are saved in library_imports and walk
+// the folder in path order, so a cancelled or interrupted run resumes after
+// the last file it saved.
+// =============================================================================
+
+// Media kinds a folder can be imported as
+const (
+	ImportKindMovie = "movie"
+	ImportKindTV    = "tv"
+	ImportKindMusic = "music"
+	ImportKindBook  = "book"
+)
+
+// importBatchSize is how many files are imported between saving progress
+const importBatchSize = 50
+
+// ErrImportRunning is returned when an import is already running
+var ErrImportRunning = errors.New("library import already running")
+
+// ValidImportKind reports whether kind is one of the import kinds
+func ValidImportKind(kind string) bool {
+	switch kind {
+	case ImportKindMovie, ImportKindTV, ImportKindMusic, ImportKindBook:
+		return true
+	}
+	return false
+}
+
+// ImportOptions controls an in-place import
+type ImportOptions struct {
+	Path   string // Folder to import, e.g. /media/movies
+	Kind   string // One of the import kinds
+	Resume bool   // Continue the last run of the folder if it did not complete
+}
+
+// ImportConflict is a file an import left alone, and why
+type ImportConflict struct {
+	Path        string `json:"path"`
+	Reason      string `json:"reason"`
+	MediaItemID *int64 `json:"media_item_id,omitempty"` // Item the file is already registered to
+}
+
+// LibraryImport is the progress and conflict report of an import run. Runs
+// share the statuses of verification runs.
+type LibraryImport struct {
+	ID            int64            `json:"id"`
+	Path          string           `json:"path"`
+	Kind          string           `json:"kind"`
+	Status        string           `json:"status"`
+	LastPath      string           `json:"last_path,omitempty"`
+	FilesFound    int32            `json:"files_found"`
+	FilesImported int32            `json:"files_imported"`
+	ItemsCreated  int32            `json:"items_created"`
+	Conflicts     []ImportConflict `json:"conflicts"`
+	Error         *string          `json:"error,omitempty"`
+	StartedAt     time.Time        `json:"started_at"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+}
+
+type Importer struct {
+	queries *generated.Queries
+	service *Service
+	logger  *zap.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // Set while a run is active
+}
+
+// NewImporter creates an importer creating items with the scanner's service
+func NewImporter(queries *generated.Queries, service *Service, logger *zap.Logger) *Importer {
+	return &Importer{
+		queries: queries,
+		service: service,
+		logger:  logger.With(zap.String("component", "library-import")),
+	}
+}
+
+// Start begins an import in the background and returns it
+func (i *Importer) Start(opts ImportOptions) (*LibraryImport, error) {
+	run, ctx, err := i.begin(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := i.execute(ctx, run); err != nil && !errors.Is(err, context.Canceled) {
+			i.logger.Error("library import failed", zap.Int64("run_id", run.ID), zap.Error(err))
+		}
+	}()
+
+	return toLibraryImport(*run), nil
+}
+
+// Cancel stops the active run, which can be resumed later. It reports whether a
+// run was active.
+func (i *Importer) Cancel() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.cancel == nil {
+		return false
+	}
+	i.cancel()
+	return true
+}
+
+// Latest returns the last import run, or nil when there was none
+func (i *Importer) Latest(ctx context.Context) (*LibraryImport, error) {
+	latest, err := i.queries.GetLatestLibraryImport(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last library import: %w", err)
+	}
+	return toLibraryImport(latest), nil
+}
+
+// begin creates a new run, or resumes the last run of the folder, and claims
+// the importer for it
+func (i *Importer) begin(ctx context.Context, opts ImportOptions) (*generated.LibraryImport, context.Context, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.cancel != nil {
+		return nil, nil, ErrImportRunning
+	}
+
+	// A run still marked running can't be active, since no run is active in
+	// this process, so it was interrupted by a restart
+	latest, err := i.queries.GetLatestLibraryImport(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to get last library import: %w", err)
+	}
+	resumable := err == nil && opts.Resume && latest.Status != VerificationCompleted &&
+		latest.Path == opts.Path && latest.Kind == opts.Kind
+
+	var run generated.LibraryImport
+	if resumable {
+		run, err = i.queries.ResumeLibraryImport(ctx, latest.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resume library import: %w", err)
+		}
+		i.logger.Info("resuming library import",
+			zap.Int64("run_id", run.ID),
+			zap.String("path", run.Path),
+			zap.String("after_path", run.LastPath))
+	} else {
+		if err == nil && latest.Status == VerificationRunning {
+			i.finish(ctx, latest.ID, VerificationCancelled, nil)
+		}
+
+		run, err = i.queries.CreateLibraryImport(ctx, generated.CreateLibraryImportParams{
+			Path: opts.Path,
+			Kind: opts.Kind,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create library import: %w", err)
+		}
+		i.logger.Info("starting library import",
+			zap.Int64("run_id", run.ID),
+			zap.String("path", run.Path),
+			zap.String("kind", run.Kind))
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	i.cancel = cancel
+	return &run, runCtx, nil
+}
+
+// execute imports the files of a run, saving progress after every batch
+func (i *Importer) execute(ctx context.Context, run *generated.LibraryImport) error {
+	defer func() {
+		i.mu.Lock()
+		i.cancel()
+		i.cancel = nil
+		i.mu.Unlock()
+	}()
+
+	// Progress still has to be saved once the run is cancelled
+	saveCtx := context.WithoutCancel(ctx)
+
+	var files []string
+	if err := WalkLibrary(run.Path, nil, func(path string) { files = append(files, path) }); err != nil {
+		msg := err.Error()
+		i.finish(saveCtx, run.ID, VerificationFailed, &msg)
+		return fmt.Errorf("failed to walk %s: %w", run.Path, err)
+	}
+	sort.Strings(files)
+	run.FilesFound = int32(len(files))
+
+	var conflicts []ImportConflict
+	pending := 0
+	for _, path := range files {
+		if path <= run.LastPath {
+			continue
+		}
+
+		conflict := i.importFile(ctx, run, path)
+		if ctx.Err() != nil {
+			// The file is tried again when the run is resumed
+			i.saveProgress(saveCtx, run, conflicts)
+			i.finish(saveCtx, run.ID, VerificationCancelled, nil)
+			i.logger.Info("library import cancelled",
+				zap.Int64("run_id", run.ID),
+				zap.Int32("files_imported", run.FilesImported))
+			return ctx.Err()
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+		run.LastPath = path
+
+		if pending++; pending == importBatchSize {
+			i.saveProgress(saveCtx, run, conflicts)
+			conflicts, pending = nil, 0
+		}
+	}
+	i.saveProgress(saveCtx, run, conflicts)
+
+	i.finish(saveCtx, run.ID, VerificationCompleted, nil)
+	i.logger.Info("library import completed",
+		zap.Int64("run_id", run.ID),
+		zap.Int32("files_found", run.FilesFound),
+		zap.Int32("files_imported", run.FilesImported),
+		zap.Int32("items_created", run.ItemsCreated))
+	return nil
+}
+
+// importFile registers a single file where it is, returning a conflict when it
+// was left alone
+func (i *Importer) importFile(ctx context.Context, run *generated.LibraryImport, path string) *ImportConflict {
+	if existing, err := i.queries.GetMediaFileByPath(ctx, path); err == nil {
+		return &ImportConflict{Path: path, Reason: "file is already in the library", MediaItemID: existing.MediaItemID}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return &ImportConflict{Path: path, Reason: err.Error()}
+	}
+
+	parsed, err := parseExisting(run.Path, path, run.Kind)
+	if err != nil {
+		return &ImportConflict{Path: path, Reason: err.Error()}
+	}
+
+	size, err := GetFileInfo(path)
+	if err != nil {
+		return &ImportConflict{Path: path, Reason: fmt.Sprintf("failed to get file info: %v", err)}
+	}
+
+	var created bool
+	switch parsed.Kind {
+	case "movie":
+		_, created, err = i.service.UpsertMovie(ctx, parsed, path, size)
+	case "tv_episode":
+		_, created, err = i.service.UpsertTVEpisode(ctx, parsed, path, size)
+	case "music_track":
+		_, created, err = i.service.UpsertMusicTrack(ctx, parsed, path, size)
+	case "book":
+		_, created, err = i.service.UpsertBook(ctx, parsed, path, size)
+	}
+	if err != nil {
+		i.logger.Warn("failed to import file", zap.String("path", path), zap.Error(err))
+		return &ImportConflict{Path: path, Reason: err.Error()}
+	}
+
+	run.FilesImported++
+	if created {
+		run.ItemsCreated++
+	}
+	return nil
+}
+
+// parseExisting reads the title, year and season of a file in an organized
+// folder of the given kind. The folders the library is organized by win over
+// the file name: root/Movie (2010)/file for movies, and
+// root/Show (2008)/Season 01/file for series.
+func parseExisting(root, path, kind string) (*ParsedMedia, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var dirs []string
+	if rel, err := filepath.Rel(root, filepath.Dir(path)); err == nil && rel != "." {
+		dirs = strings.Split(rel, string(filepath.Separator))
+	}
+
+	switch kind {
+	case ImportKindMovie:
+		if !videoExtensions[ext] {
+			return nil, fmt.Errorf("not a video file")
+		}
+		parsed := parseMovie(name)
+		if len(dirs) > 0 {
+			folder := parseMovie(dirs[0])
+			if folder.Year > 0 && parsed.Year > 0 && folder.Year != parsed.Year {
+				return nil, fmt.Errorf("folder %q has year %d but the file has %d", dirs[0], folder.Year, parsed.Year)
+			}
+			parsed.Title = folder.Title
+			if folder.Year > 0 {
+				parsed.Year = folder.Year
+			}
+		}
+		if parsed.Title == "" {
+			return nil, fmt.Errorf("no movie title in the folder or file name")
+		}
+		return parsed, nil
+
+	case ImportKindTV:
+		if !videoExtensions[ext] {
+			return nil, fmt.Errorf("not a video file")
+		}
+		parsed := parseTVEpisode(name, filepath.Dir(path))
+		if parsed == nil {
+			return nil, fmt.Errorf("no season and episode in the file name")
+		}
+		if len(dirs) > 1 {
+			if season := extractSeasonFromPath(dirs[1]); season > 0 && parsed.Season > 0 && season != parsed.Season {
+				return nil, fmt.Errorf("folder %q is season %d but the file is season %d", dirs[1], season, parsed.Season)
+			}
+		}
+		if len(dirs) > 0 {
+			series := parseMovie(dirs[0])
+			parsed.Title = series.Title
+			parsed.Year = series.Year
+		}
+		if parsed.Title == "" {
+			return nil, fmt.Errorf("no series title in the folder or file name")
+		}
+		return parsed, nil
+
+	case ImportKindMusic:
+		if !audioExtensions[ext] {
+			return nil, fmt.Errorf("not an audio file")
+		}
+		return parseMusicTrack(name, filepath.Dir(path)), nil
+
+	case ImportKindBook:
+		if !bookExtensions[ext] {
+			return nil, fmt.Errorf("not a book file")
+		}
+		return parseBook(name), nil
+	}
+
+	return nil, fmt.Errorf("unsupported import kind: %s", kind)
+}
+
+// saveProgress stores the position and counters of a run, and appends the
+// conflicts found since the last save
+func (i *Importer) saveProgress(ctx context.Context, run *generated.LibraryImport, conflicts []ImportConflict) {
+	if conflicts == nil {
+		conflicts = []ImportConflict{}
+	}
+	conflictsJSON, err := json.Marshal(conflicts)
+	if err != nil {
+		i.logger.Warn("failed to encode import conflicts", zap.Error(err))
+		conflictsJSON = []byte("[]")
+	}
+
+	if err := i.queries.UpdateLibraryImportProgress(ctx, generated.UpdateLibraryImportProgressParams{
+		ID:            run.ID,
+		LastPath:      run.LastPath,
+		FilesFound:    run.FilesFound,
+		FilesImported: run.FilesImported,
+		ItemsCreated:  run.ItemsCreated,
+		Conflicts:     conflictsJSON,
+	}); err != nil {
+		i.logger.Warn("failed to save library import progress", zap.Int64("run_id", run.ID), zap.Error(err))
+	}
+}
+
+// finish marks a run as no longer running
+func (i *Importer) finish(ctx context.Context, runID int64, status string, errorMessage *string) {
+	if _, err := i.queries.FinishLibraryImport(ctx, generated.FinishLibraryImportParams{
+		ID:           runID,
+		Status:       status,
+		ErrorMessage: errorMessage,
+	}); err != nil {
+		i.logger.Warn("failed to finish library import", zap.Int64("run_id", runID), zap.Error(err))
+	}
+}
+
+// checkImportPath returns the cleaned absolute path of a folder to import
+func checkImportPath(path string) (string, error) {
+	if path == "" || !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path must be an absolute path")
+	}
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("path is not accessible: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder")
+	}
+	return path, nil
+}
+
+// toLibraryImport converts a database row to a LibraryImport
+func toLibraryImport(row generated.LibraryImport) *LibraryImport {
+	run := &LibraryImport{
+		ID:            row.ID,
+		Path:          row.Path,
+		Kind:          row.Kind,
+		Status:        row.Status,
+		LastPath:      row.LastPath,
+		FilesFound:    row.FilesFound,
+		FilesImported: row.FilesImported,
+		ItemsCreated:  row.ItemsCreated,
+		Conflicts:     []ImportConflict{},
+		Error:         row.ErrorMessage,
+		StartedAt:     row.StartedAt.Time,
+	}
+	if len(row.Conflicts) > 0 {
+		_ = json.Unmarshal(row.Conflicts, &run.Conflicts)
+	}
+	if row.FinishedAt.Valid {
+		finishedAt := row.FinishedAt.Time
+		run.FinishedAt = &finishedAt
+	}
+	return run
+}
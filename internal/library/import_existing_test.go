@@ -0,0 +1,92 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExisting(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		kind        string
+		wantTitle   string
+		wantYear    int
+		wantSeason  int
+		wantEpisode int
+		wantErr     string
+	}{
+		{
+			name:      "movie folder wins over the file name",
+			path:      "/media/movies/The Dark Knight (2008)/TDK.1080p.BluRay.mkv",
+			kind:      ImportKindMovie,
+			wantTitle: "The Dark Knight",
+			wantYear:  2008,
+		},
+		{
+			name:      "movie without a folder",
+			path:      "/media/movies/Inception (2010).mp4",
+			kind:      ImportKindMovie,
+			wantTitle: "Inception",
+			wantYear:  2010,
+		},
+		{
+			name:    "movie folder and file disagree on the year",
+			path:    "/media/movies/Dune (2021)/Dune.1984.mkv",
+			kind:    ImportKindMovie,
+			wantErr: "has year 2021",
+		},
+		{
+			name:    "audio file in a movie folder",
+			path:    "/media/movies/Movie (2020)/soundtrack.mp3",
+			kind:    ImportKindMovie,
+			wantErr: "not a video file",
+		},
+		{
+			name:        "series folder wins over the file name",
+			path:        "/media/tv/Breaking Bad (2008)/Season 02/breaking.bad.s02e05.720p.mkv",
+			kind:        ImportKindTV,
+			wantTitle:   "Breaking Bad",
+			wantYear:    2008,
+			wantSeason:  2,
+			wantEpisode: 5,
+		},
+		{
+			name:    "season folder and file disagree",
+			path:    "/media/tv/Show (2020)/Season 01/Show - S02E01.mkv",
+			kind:    ImportKindTV,
+			wantErr: "is season 1",
+		},
+		{
+			name:    "episode without numbers",
+			path:    "/media/tv/Show (2020)/Extras/Behind the Scenes.mkv",
+			kind:    ImportKindTV,
+			wantErr: "no season and episode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "/media/movies"
+			if tt.kind == ImportKindTV {
+				root = "/media/tv"
+			}
+			parsed, err := parseExisting(root, tt.path, tt.kind)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseExisting() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExisting() error = %v", err)
+			}
+			if parsed.Title != tt.wantTitle || parsed.Year != tt.wantYear ||
+				parsed.Season != tt.wantSeason || parsed.Episode != tt.wantEpisode {
+				t.Errorf("parseExisting() = %q (%d) S%02dE%02d, want %q (%d) S%02dE%02d",
+					parsed.Title, parsed.Year, parsed.Season, parsed.Episode,
+					tt.wantTitle, tt.wantYear, tt.wantSeason, tt.wantEpisode)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/mediainfo"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// MediaInfoRefresher - Backfills ffprobe media info for existing files
+// =============================================================================
+// Files imported before media info extraction existed, or while ffprobe was
+// unavailable, have no mediainfo. A refresh pages through media_files in ID
+// order and probes each batch with a bounded number of ffprobe processes.
+// Progress is kept in memory and reported by Status.
+// =============================================================================
+
+// mediaInfoBatchSize is how many files are loaded at a time
+const mediaInfoBatchSize = 100
+
+// MaxMediaInfoConcurrency caps the ffprobe processes run at once
+const MaxMediaInfoConcurrency = 16
+
+// ErrMediaInfoRefreshRunning is returned when a refresh is already running
+var ErrMediaInfoRefreshRunning = errors.New("media info refresh already running")
+
+// MediaInfoRefreshOptions controls a media info refresh
+type MediaInfoRefreshOptions struct {
+	All         bool // Probe every file, not only files without media info
+	Concurrency int  // ffprobe processes run at once, 0 for the refresher's default
+}
+
+// MediaInfoProgress is the progress of the current or last media info refresh
+type MediaInfoProgress struct {
+	Running     bool       `json:"running"`
+	All         bool       `json:"all"`
+	Concurrency int        `json:"concurrency"`
+	Total       int64      `json:"total"`
+	Processed   int64      `json:"processed"`
+	Updated     int64      `json:"updated"`
+	Failed      int64      `json:"failed"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+type MediaInfoRefresher struct {
+	queries     *generated.Queries
+	prober      *mediainfo.Prober
+	concurrency int
+	logger      *zap.Logger
+
+	mu       sync.Mutex
+	progress MediaInfoProgress
+
+	// Counters updated by the probe workers
+	processed atomic.Int64
+	updated   atomic.Int64
+	failed    atomic.Int64
+}
+
+// NewMediaInfoRefresher creates a refresher that runs concurrency ffprobe
+// processes at once unless a refresh asks for a different number
+func NewMediaInfoRefresher(queries *generated.Queries, prober *mediainfo.Prober, concurrency int, logger *zap.Logger) *MediaInfoRefresher {
+	return &MediaInfoRefresher{
+		queries:     queries,
+		prober:      prober,
+		concurrency: concurrency,
+		logger:      logger.With(zap.String("component", "mediainfo_refresher")),
+	}
+}
+
+// Start begins a refresh in the background and returns its initial progress
+func (r *MediaInfoRefresher) Start(opts MediaInfoRefreshOptions) (MediaInfoProgress, error) {
+	if !r.prober.Available() {
+		return MediaInfoProgress{}, mediainfo.ErrUnavailable
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = r.concurrency
+	}
+	opts.Concurrency = max(1, min(opts.Concurrency, MaxMediaInfoConcurrency))
+
+	r.mu.Lock()
+	if r.progress.Running {
+		r.mu.Unlock()
+		return MediaInfoProgress{}, ErrMediaInfoRefreshRunning
+	}
+	// Claim the run before counting so two requests can't both start one
+	now := time.Now()
+	r.progress = MediaInfoProgress{
+		Running:     true,
+		All:         opts.All,
+		Concurrency: opts.Concurrency,
+		StartedAt:   &now,
+	}
+	r.processed.Store(0)
+	r.updated.Store(0)
+	r.failed.Store(0)
+	r.mu.Unlock()
+
+	ctx := context.Background()
+	total, err := r.queries.CountMediaFilesForMediaInfo(ctx, opts.All)
+	if err != nil {
+		r.finish(err)
+		return MediaInfoProgress{}, fmt.Errorf("failed to count media files: %w", err)
+	}
+
+	r.mu.Lock()
+	r.progress.Total = total
+	r.mu.Unlock()
+
+	go func() {
+		err := r.run(ctx, opts)
+		if err != nil {
+			r.logger.Error("media info refresh failed", zap.Error(err))
+		}
+		r.finish(err)
+	}()
+
+	return r.Status(), nil
+}
+
+// Status returns the progress of the current or last refresh
+func (r *MediaInfoRefresher) Status() MediaInfoProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	progress := r.progress
+	progress.Processed = r.processed.Load()
+	progress.Updated = r.updated.Load()
+	progress.Failed = r.failed.Load()
+	return progress
+}
+
+func (r *MediaInfoRefresher) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.progress.Running = false
+	r.progress.FinishedAt = &now
+	if err != nil {
+		r.progress.Error = err.Error()
+	}
+}
+
+// run probes every matching file, one batch at a time
+func (r *MediaInfoRefresher) run(ctx context.Context, opts MediaInfoRefreshOptions) error {
+	r.logger.Info("media info refresh started",
+		zap.Bool("all", opts.All),
+		zap.Int("concurrency", opts.Concurrency))
+
+	sem := make(chan struct{}, opts.Concurrency)
+	var afterID int64
+	for {
+		files, err := r.queries.ListMediaFilesForMediaInfo(ctx, generated.ListMediaFilesForMediaInfoParams{
+			AfterID:    afterID,
+			IncludeAll: opts.All,
+			BatchSize:  mediaInfoBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list media files: %w", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		var wg sync.WaitGroup
+		for _, file := range files {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(file generated.MediaFile) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				if err := ProbeMediaFile(ctx, r.queries, r.prober, file.ID, file.Path); err != nil {
+					r.logger.Warn("failed to refresh media info",
+						zap.String("path", file.Path),
+						zap.Error(err))
+					r.failed.Add(1)
+				} else {
+					r.updated.Add(1)
+				}
+				r.processed.Add(1)
+			}(file)
+		}
+		wg.Wait()
+
+		afterID = files[len(files)-1].ID
+	}
+
+	r.logger.Info("media info refresh completed",
+		zap.Int64("processed", r.processed.Load()),
+		zap.Int64("updated", r.updated.Load()),
+		zap.Int64("failed", r.failed.Load()))
+	return nil
+}
+
+// ProbeMediaFile probes a media file with ffprobe and stores the result on its
+// media_files row
+func ProbeMediaFile(ctx context.Context, queries *generated.Queries, prober *mediainfo.Prober, fileID int64, path string) error {
+	info, err := prober.Probe(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode media info: %w", err)
+	}
+
+	if err := queries.SetMediaFileMediaInfo(ctx, generated.SetMediaFileMediaInfoParams{
+		ID:        fileID,
+		Mediainfo: data,
+	}); err != nil {
+		return fmt.Errorf("failed to save media info: %w", err)
+	}
+	return nil
+}
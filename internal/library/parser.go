@@ -17,17 +17,20 @@ import (
 //   - movie: Standalone video files with optional year
 //   - tv_episode: TV show episodes with season/episode numbers
 //   - music_track: Audio files within Artist/Album directory structure
+//   - music_album: Album folders and release names ("Artist - Album (2020)")
 //   - book: eBook files with optional author information
 //
 // All fields are optional and will be empty/zero if not detected.
 // =============================================================================
 
 type ParsedMedia struct {
-	Kind         string // "movie", "tv_episode", "music_track", "book", etc.
+	Kind         string // "movie", "tv_episode", "music_track", "music_album", "book", etc.
 	Title        string // Main title (movie name, show name, track name, book title)
-	Year         int    // Release year (primarily for movies)
+	Year         int    // Release year (movies, and albums when the folder has one)
 	Season       int    // TV season number
 	Episode      int    // TV episode number
+	LastEpisode  int    // Last episode of a multi-episode file, 0 for a single episode
+	Absolute     int    // Absolute episode number of anime releases, which have no season and episode
 	EpisodeTitle string // TV episode title (e.g., "Crash Course" in "The Rookie - S01E02 - Crash Course")
 	Artist       string // Music artist name
 	Album        string // Music album name
@@ -50,10 +53,36 @@ var (
 	tvSeasonEpisodePattern2 = regexp.MustCompile(`(\d{1,2})[xX](\d{1,2})`)                        // 1x02
 	tvSeasonEpisodePattern3 = regexp.MustCompile(`[Ss]eason\s*(\d{1,2}).*[Ee]pisode\s*(\d{1,2})`) // Season 1 Episode 2
 
+	// Further episodes of a multi-episode file, following S01E01 or 1x01
+	// Examples: "E02" in "S01E01E02", "-E02" in "S01E01-E02", "-02" in "S01E01-02"
+	multiEpisodePattern = regexp.MustCompile(`^(?:-?[Ee]|-)(\d{1,2})`)
+
+	// Anime releases numbered by absolute episode after a dash
+	// Examples: "Show - 125 [1080p]", "Show - 05v2 (720p)"
+	animeEpisodePattern = regexp.MustCompile(`^(.+?)\s+-\s+(\d{2,4})(?:v\d)?(?:\s|[\[\(]|$)`)
+
+	// Release group and other tags in brackets leading a name
+	// Examples: "[SubsPlease] ", "[Group][1080p] "
+	leadingTagsPattern = regexp.MustCompile(`^(?:\s*\[[^\]]*\])+`)
+
 	// Music track number pattern
 	// Examples: "01 Track Name.mp3", "1. Track Name.flac"
 	trackNumberPattern = regexp.MustCompile(`^(\d{1,3})[\s\.\-_]+`)
 
+	// Music file names carrying the artist and album
+	// Examples: "Artist - Album - 01 - Track Name.flac", "Artist - 01 - Track Name.mp3"
+	artistAlbumTrackPattern = regexp.MustCompile(`^(.+?)\s+-\s+(.+?)\s+-\s+(\d{1,3})\s+-\s+(.+)$`)
+	artistTrackPattern      = regexp.MustCompile(`^(.+?)\s+-\s+(\d{1,3})\s+-\s+(.+)$`)
+
+	// Album folder and release names
+	// Examples: "Artist - Album (2020)", "Artist - Album [2020] [FLAC]", "Artist-Album-WEB-2020-GROUP"
+	musicReleasePattern      = regexp.MustCompile(`^(.+?)\s+-\s+(.+?)(?:\s*[\(\[]((?:19|20)\d{2})[\)\]])?(?:\s*\[[^\]]*\])*$`)
+	sceneMusicReleasePattern = regexp.MustCompile(`^([^-]+)-([^-]+)-(?:.*-)?((?:19|20)\d{2})-[^-]+$`)
+
+	// Disc folders inside an album folder
+	// Examples: "CD1", "Disc 2"
+	discFolderPattern = regexp.MustCompile(`(?i)^(cd|dis[ck])\s*\d{1,2}$`)
+
 	// Book author pattern (after dash or hyphen)
 	// Examples: "Book Title - Author Name.epub"
 	bookAuthorPattern = regexp.MustCompile(`^(.+?)\s*[-–]\s*(.+)$`)
@@ -187,6 +216,18 @@ func parseTVEpisode(filename, dir string) *ParsedMedia {
 	original := filename
 	cleaned := cleanFilename(filename)
 
+	// Anime releases are numbered by absolute episode rather than by season,
+	// and carry resolutions like 1920x1080 that would pass for 1x02
+	if !tvSeasonEpisodePattern1.MatchString(cleaned) {
+		if title, absolute, ok := ParseAbsoluteEpisode(original); ok {
+			return &ParsedMedia{
+				Kind:     "tv_episode",
+				Title:    title,
+				Absolute: absolute,
+			}
+		}
+	}
+
 	// Try different season/episode patterns
 	patterns := []*regexp.Regexp{
 		tvSeasonEpisodePattern1,
@@ -204,12 +245,18 @@ func parseTVEpisode(filename, dir string) *ParsedMedia {
 				Season:  season,
 				Episode: episode,
 			}
+			if pattern != tvSeasonEpisodePattern3 {
+				if end := pattern.FindStringIndex(cleaned); end != nil {
+					parsed.LastEpisode, _ = parseMultiEpisode(cleaned[end[1]:], episode)
+				}
+			}
 
 			// Show name is everything before the season/episode marker (use original, not cleaned)
 			episodeIndex := pattern.FindStringIndex(original)
 			if episodeIndex != nil && episodeIndex[0] > 0 {
 				showName := original[:episodeIndex[0]]
-				// Remove trailing separators before normalizing
+				// Remove a leading release group and trailing separators before normalizing
+				showName = leadingTagsPattern.ReplaceAllString(showName, "")
 				showName = strings.TrimRight(showName, " .-_")
 				parsed.Title = normalizeTitle(showName)
 			}
@@ -253,13 +300,17 @@ func parseTVEpisode(filename, dir string) *ParsedMedia {
 // parseMusicTrack - Extract artist, album, track number, and track name
 // =============================================================================
 // Parsing strategy:
-//   1. Use directory structure: Artist/Album/Track.mp3
-//   2. Extract track number from beginning of filename
-//   3. Remaining filename becomes track title
+//   1. Use directory structure: Artist/Album/Track.mp3, skipping disc folders
+//   2. An album folder named like a release ("Artist - Album (2020)") gives
+//      the artist, album and year on its own
+//   3. Extract track number from beginning of filename
+//   4. An artist and album in the filename take precedence over the folders
 //
 // Examples:
 //   "Artist/Album/01 Track Name.mp3" -> Artist: "Artist", Album: "Album", Track: 1, Title: "Track Name"
 //   "Various Artists/Best Of/05. Song.flac" -> Track 5
+//   "downloads/Artist - Album (2020)/03 - Song.flac" -> Artist, Album, 2020, Track 3
+//   "Artist - Album - 07 - Song.mp3" -> Artist, Album, Track 7
 // =============================================================================
 
 func parseMusicTrack(filename, dir string) *ParsedMedia {
@@ -267,6 +318,39 @@ func parseMusicTrack(filename, dir string) *ParsedMedia {
 		Kind: "music_track",
 	}
 
+	// Try to get artist and album from directory structure
+	// Expected: /path/to/Artist/Album/Track.mp3
+	parts := strings.Split(dir, string(filepath.Separator))
+	if len(parts) > 1 && discFolderPattern.MatchString(parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+	}
+	if release := ParseMusicRelease(parts[len(parts)-1]); release != nil {
+		parsed.Artist = release.Artist
+		parsed.Album = release.Album
+		parsed.Year = release.Year
+	} else if len(parts) >= 2 {
+		parsed.Album = parts[len(parts)-1]
+		parsed.Artist = parts[len(parts)-2]
+	} else if len(parts) == 1 {
+		parsed.Album = parts[0]
+	}
+
+	// Artist and album in the filename win over the folders, which may just be
+	// a download folder
+	if matches := artistAlbumTrackPattern.FindStringSubmatch(filename); len(matches) == 5 {
+		parsed.Artist = strings.TrimSpace(matches[1])
+		parsed.Album = strings.TrimSpace(matches[2])
+		parsed.Track, _ = strconv.Atoi(matches[3])
+		parsed.Title = normalizeTitle(matches[4])
+		return parsed
+	}
+	if matches := artistTrackPattern.FindStringSubmatch(filename); len(matches) == 4 {
+		parsed.Artist = strings.TrimSpace(matches[1])
+		parsed.Track, _ = strconv.Atoi(matches[2])
+		parsed.Title = normalizeTitle(matches[3])
+		return parsed
+	}
+
 	// Extract track number from filename
 	if matches := trackNumberPattern.FindStringSubmatch(filename); len(matches) > 1 {
 		track, _ := strconv.Atoi(matches[1])
@@ -276,17 +360,49 @@ func parseMusicTrack(filename, dir string) *ParsedMedia {
 		parsed.Title = normalizeTitle(filename)
 	}
 
-	// Try to get artist and album from directory structure
-	// Expected: /path/to/Artist/Album/Track.mp3
-	parts := strings.Split(dir, string(filepath.Separator))
-	if len(parts) >= 2 {
-		parsed.Album = parts[len(parts)-1]
-		parsed.Artist = parts[len(parts)-2]
-	} else if len(parts) == 1 {
-		parsed.Album = parts[0]
+	return parsed
+}
+
+// =============================================================================
+// ParseMusicRelease - Extract artist, album and year from an album name
+// =============================================================================
+// Album folders and music release names put the artist first. Returns nil
+// when the name doesn't look like an album.
+//
+// Examples:
+//   "Radiohead - OK Computer (1997)" -> Artist: "Radiohead", Album: "OK Computer", Year: 1997
+//   "Radiohead - OK Computer [1997] [FLAC]" -> same
+//   "Radiohead-OK_Computer-WEB-1997-GROUP" -> same
+// =============================================================================
+
+func ParseMusicRelease(name string) *ParsedMedia {
+	name = strings.TrimSpace(name)
+
+	if matches := musicReleasePattern.FindStringSubmatch(name); len(matches) == 4 {
+		parsed := &ParsedMedia{
+			Kind:   "music_album",
+			Artist: strings.TrimSpace(matches[1]),
+			Album:  strings.TrimSpace(matches[2]),
+		}
+		if matches[3] != "" {
+			parsed.Year, _ = strconv.Atoi(matches[3])
+		}
+		parsed.Title = parsed.Album
+		return parsed
 	}
 
-	return parsed
+	if matches := sceneMusicReleasePattern.FindStringSubmatch(name); len(matches) == 4 {
+		parsed := &ParsedMedia{
+			Kind:   "music_album",
+			Artist: normalizeTitle(matches[1]),
+			Album:  normalizeTitle(matches[2]),
+		}
+		parsed.Year, _ = strconv.Atoi(matches[3])
+		parsed.Title = parsed.Album
+		return parsed
+	}
+
+	return nil
 }
 
 // =============================================================================
@@ -385,6 +501,49 @@ func extractSeasonFromPath(dir string) int {
 	return 0
 }
 
+// ParseAbsoluteEpisode reads an anime release or file name numbered by
+// absolute episode, like "[Group] Show - 125 [1080p]", and returns the show
+// title and the episode number. A year is not taken for an episode number.
+func ParseAbsoluteEpisode(name string) (title string, absolute int, ok bool) {
+	name = strings.ReplaceAll(name, "_", " ")
+	name = strings.TrimSpace(leadingTagsPattern.ReplaceAllString(name, ""))
+
+	matches := animeEpisodePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return "", 0, false
+	}
+	absolute, _ = strconv.Atoi(matches[2])
+	if len(matches[2]) == 4 && absolute >= 1900 && absolute < 2100 {
+		return "", 0, false
+	}
+	return normalizeTitle(matches[1]), absolute, true
+}
+
+// parseMultiEpisode reads the further episodes of a multi-episode file from
+// what follows its first episode marker. It returns the last episode, or 0 for
+// a single episode, and the length of the text the further episodes take up.
+// Each episode must follow the one before it, so "S01E01-1080p" or a year
+// isn't mistaken for one.
+func parseMultiEpisode(rest string, episode int) (last int, length int) {
+	previous := episode
+	for {
+		match := multiEpisodePattern.FindStringSubmatchIndex(rest[length:])
+		if match == nil {
+			break
+		}
+		end := length + match[1]
+		if end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			break
+		}
+		number, _ := strconv.Atoi(rest[length+match[2] : length+match[3]])
+		if number <= previous {
+			break
+		}
+		previous, last, length = number, number, end
+	}
+	return last, length
+}
+
 // extractEpisodeTitle extracts the episode title that appears after the season/episode pattern
 // Examples:
 //
@@ -398,8 +557,14 @@ func extractEpisodeTitle(filename string, pattern *regexp.Regexp) string {
 		return ""
 	}
 
-	// Get everything after the season/episode marker
+	// Get everything after the season/episode marker, and the further
+	// episodes of a multi-episode file
 	afterEpisode := filename[episodeIndex[1]:]
+	if pattern != tvSeasonEpisodePattern3 {
+		episode, _ := strconv.Atoi(pattern.FindStringSubmatch(filename)[2])
+		_, length := parseMultiEpisode(afterEpisode, episode)
+		afterEpisode = afterEpisode[length:]
+	}
 
 	// Remove leading separators (spaces, dots, dashes, underscores)
 	afterEpisode = strings.TrimLeft(afterEpisode, " .-_")
@@ -444,6 +609,11 @@ func extractEpisodeTitle(filename string, pattern *regexp.Regexp) string {
 	return ""
 }
 
+// IsAudioFile checks if the file extension is a music format
+func IsAudioFile(path string) bool {
+	return audioExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
 // IsSupportedMediaFile checks if the file extension is supported
 func IsSupportedMediaFile(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
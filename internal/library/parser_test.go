@@ -74,3 +74,167 @@ func TestParseTVEpisodeWithTitle(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMultiEpisode(t *testing.T) {
+	tests := []struct {
+		filename    string
+		wantEpisode int
+		wantLast    int
+		wantEpTitle string
+	}{
+		{"Show.S01E01E02.720p.HDTV", 1, 2, ""},
+		{"Show.S01E01-E02.Pilot.1080p", 1, 2, "Pilot"},
+		{"Show - S01E03-04 - Two Parter", 3, 4, "Two Parter"},
+		{"Show.S02E05E06E07.720p", 5, 7, ""},
+		{"Show - 1x01-02 - Pilot", 1, 2, "Pilot"},
+		{"Show.S01E01.mkv", 1, 0, ""},
+		{"Show.S01E05-1080p", 5, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			result := parseTVEpisode(tt.filename, "/media/tv")
+			if result == nil {
+				t.Fatal("parseTVEpisode returned nil")
+			}
+			if result.Episode != tt.wantEpisode || result.LastEpisode != tt.wantLast {
+				t.Errorf("episodes = %d-%d, want %d-%d", result.Episode, result.LastEpisode, tt.wantEpisode, tt.wantLast)
+			}
+			if result.EpisodeTitle != tt.wantEpTitle {
+				t.Errorf("EpisodeTitle = %q, want %q", result.EpisodeTitle, tt.wantEpTitle)
+			}
+		})
+	}
+}
+
+func TestParseAbsoluteEpisode(t *testing.T) {
+	tests := []struct {
+		filename     string
+		wantTitle    string
+		wantAbsolute int
+		wantSeason   int
+	}{
+		{"[SubsPlease] One Piece - 1071 (1080p) [ABCD1234]", "One Piece", 1071, 0},
+		{"[Group]_Show_Name_-_05v2_[720p]", "Show Name", 5, 0},
+		{"[Group][1080p] Show - 125", "Show", 125, 0},
+		{"Show - 12 (1920x1080 HEVC)", "Show", 12, 0},
+		{"[Group] Show - S01E05 [1080p]", "Show", 0, 1},
+		{"The Office - 1x02 - Diversity Day", "The Office", 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			result := parseTVEpisode(tt.filename, "/media/tv")
+			if result == nil {
+				t.Fatal("parseTVEpisode returned nil")
+			}
+			if result.Title != tt.wantTitle || result.Absolute != tt.wantAbsolute || result.Season != tt.wantSeason {
+				t.Errorf("got %q absolute %d season %d, want %q absolute %d season %d",
+					result.Title, result.Absolute, result.Season, tt.wantTitle, tt.wantAbsolute, tt.wantSeason)
+			}
+		})
+	}
+
+	if _, _, ok := ParseAbsoluteEpisode("Show - 2019 [1080p]"); ok {
+		t.Error("a year was taken for an absolute episode number")
+	}
+}
+
+func TestParseMusicTrack(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantArtist string
+		wantAlbum  string
+		wantYear   int
+		wantTrack  int
+		wantTitle  string
+	}{
+		{
+			name:       "Artist/Album folders",
+			path:       "/media/music/Radiohead/OK Computer/02 Paranoid Android.flac",
+			wantArtist: "Radiohead",
+			wantAlbum:  "OK Computer",
+			wantTrack:  2,
+			wantTitle:  "Paranoid Android",
+		},
+		{
+			name:       "Disc folder is skipped",
+			path:       "/media/music/Pink Floyd/The Wall/CD2/01. Hey You.mp3",
+			wantArtist: "Pink Floyd",
+			wantAlbum:  "The Wall",
+			wantTrack:  1,
+			wantTitle:  "Hey You",
+		},
+		{
+			name:       "Release folder",
+			path:       "/downloads/complete/Radiohead - OK Computer (1997) [FLAC]/03 - Subterranean Homesick Alien.flac",
+			wantArtist: "Radiohead",
+			wantAlbum:  "OK Computer",
+			wantYear:   1997,
+			wantTrack:  3,
+			wantTitle:  "Subterranean Homesick Alien",
+		},
+		{
+			name:       "Artist and album in the filename",
+			path:       "/downloads/abc123/Radiohead - OK Computer - 07 - Fitter Happier.m4a",
+			wantArtist: "Radiohead",
+			wantAlbum:  "OK Computer",
+			wantTrack:  7,
+			wantTitle:  "Fitter Happier",
+		},
+		{
+			name:       "Artist in the filename",
+			path:       "/media/music/Compilations/Best Of/Radiohead - 04 - Creep.mp3",
+			wantArtist: "Radiohead",
+			wantAlbum:  "Best Of",
+			wantTrack:  4,
+			wantTitle:  "Creep",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseFilename(tt.path)
+			if result == nil || result.Kind != "music_track" {
+				t.Fatalf("ParseFilename() = %+v, want a music track", result)
+			}
+			if result.Artist != tt.wantArtist || result.Album != tt.wantAlbum || result.Year != tt.wantYear {
+				t.Errorf("Artist, Album, Year = %q, %q, %d, want %q, %q, %d",
+					result.Artist, result.Album, result.Year, tt.wantArtist, tt.wantAlbum, tt.wantYear)
+			}
+			if result.Track != tt.wantTrack || result.Title != tt.wantTitle {
+				t.Errorf("Track, Title = %d, %q, want %d, %q", result.Track, result.Title, tt.wantTrack, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestParseMusicRelease(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantArtist string
+		wantAlbum  string
+		wantYear   int
+	}{
+		{"Radiohead - OK Computer (1997)", "Radiohead", "OK Computer", 1997},
+		{"Radiohead - OK Computer [1997] [FLAC]", "Radiohead", "OK Computer", 1997},
+		{"Radiohead - Kid A", "Radiohead", "Kid A", 0},
+		{"Radiohead-OK_Computer-WEB-1997-GROUP", "Radiohead", "OK Computer", 1997},
+	}
+
+	for _, tt := range tests {
+		result := ParseMusicRelease(tt.name)
+		if result == nil {
+			t.Errorf("ParseMusicRelease(%q) = nil", tt.name)
+			continue
+		}
+		if result.Kind != "music_album" || result.Artist != tt.wantArtist || result.Album != tt.wantAlbum || result.Year != tt.wantYear {
+			t.Errorf("ParseMusicRelease(%q) = %+v", tt.name, result)
+		}
+	}
+
+	if result := ParseMusicRelease("OK Computer"); result != nil {
+		t.Errorf("ParseMusicRelease(%q) = %+v, want nil", "OK Computer", result)
+	}
+}
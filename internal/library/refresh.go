@@ -0,0 +1,579 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// MetadataRefresher - Keeps the seasons and episodes of series up to date
+// =============================================================================
+// A series gets its seasons and episodes when it is added or scanned, and
+// nothing adds the ones announced later. A refresh fetches the series and its
+// seasons from the TMDB plugin, creates the seasons and episodes the library
+// doesn't have, and updates the titles, air dates and overviews of the others.
+// Series whose TMDB details haven't changed since their last refresh are
+// skipped, unless the refresh is forced.
+// =============================================================================
+
+// refreshBatchSize is how many series are loaded at a time
+const refreshBatchSize = 100
+
+// placeholderEpisodeName is what TMDB calls episodes that have no name yet
+const placeholderEpisodeName = "TBA"
+
+// ErrNoTMDBID is returned when a series can't be refreshed as it has no TMDB ID
+var ErrNoTMDBID = errors.New("series has no TMDB ID")
+
+// RefreshSummary is what refreshing a series changed
+type RefreshSummary struct {
+	SeriesID        int64    `json:"series_id"`
+	Title           string   `json:"title"`
+	Status          string   `json:"status,omitempty"` // continuing or ended
+	Skipped         bool     `json:"skipped"`          // Unchanged since the last refresh
+	SeasonsAdded    int      `json:"seasons_added"`
+	EpisodesAdded   int      `json:"episodes_added"`
+	SeasonsUpdated  int      `json:"seasons_updated"`
+	EpisodesUpdated int      `json:"episodes_updated"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// changed reports whether the refresh added or updated anything
+func (s *RefreshSummary) changed() bool {
+	return s.SeasonsAdded+s.EpisodesAdded+s.SeasonsUpdated+s.EpisodesUpdated > 0
+}
+
+// MetadataRefresher refreshes series from the TMDB plugin
+type MetadataRefresher struct {
+	plugins *plugins.PluginManager
+	queries *generated.Queries
+	scanner *Service
+	history *history.Service
+	logger  *zap.Logger
+
+	// episodesAdded is called after episodes were added to a series, so they
+	// are monitored as its rules say
+	episodesAdded func(ctx context.Context, seriesID int64) error
+}
+
+// NewMetadataRefresher creates a metadata refresher
+func NewMetadataRefresher(pm *plugins.PluginManager, queries *generated.Queries, logger *zap.Logger) *MetadataRefresher {
+	return &MetadataRefresher{
+		plugins: pm,
+		queries: queries,
+		scanner: NewService(queries, logger),
+		logger:  logger.With(zap.String("component", "metadata-refresh")),
+	}
+}
+
+// SetHistory sets where refreshes that changed a series are recorded
+func (r *MetadataRefresher) SetHistory(history *history.Service) {
+	r.history = history
+}
+
+// OnEpisodesAdded sets what is called after a refresh added episodes to a series
+func (r *MetadataRefresher) OnEpisodesAdded(fn func(ctx context.Context, seriesID int64) error) {
+	r.episodesAdded = fn
+}
+
+// RefreshAll refreshes every series with a TMDB ID. A series that fails is
+// logged and skipped. It returns the number of series that changed.
+func (r *MetadataRefresher) RefreshAll(ctx context.Context) (int, error) {
+	var refreshed, changed, added, updated int
+	for offset := int32(0); ; offset += refreshBatchSize {
+		series, err := r.queries.ListMediaItemsByKind(ctx, generated.ListMediaItemsByKindParams{
+			Kind:   "tv_series",
+			Limit:  refreshBatchSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return changed, fmt.Errorf("failed to list series: %w", err)
+		}
+
+		for _, item := range series {
+			if err := ctx.Err(); err != nil {
+				return changed, err
+			}
+			summary, err := r.refresh(ctx, item, false)
+			if errors.Is(err, ErrNoTMDBID) {
+				continue
+			}
+			if err != nil {
+				r.logger.Warn("failed to refresh series",
+					zap.Int64("series_id", item.ID), zap.String("title", item.Title), zap.Error(err))
+				continue
+			}
+			refreshed++
+			if summary.changed() {
+				changed++
+				added += summary.SeasonsAdded + summary.EpisodesAdded
+				updated += summary.SeasonsUpdated + summary.EpisodesUpdated
+			}
+		}
+		if len(series) < refreshBatchSize {
+			break
+		}
+	}
+
+	r.logger.Info("refreshed series metadata",
+		zap.Int("series", refreshed),
+		zap.Int("changed", changed),
+		zap.Int("items_added", added),
+		zap.Int("items_updated", updated))
+	return changed, nil
+}
+
+// RefreshSeries refreshes one series with fresh TMDB details, whether or not
+// they changed since its last refresh
+func (r *MetadataRefresher) RefreshSeries(ctx context.Context, seriesID int64) (*RefreshSummary, error) {
+	item, err := r.queries.GetMediaItem(ctx, seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series: %w", err)
+	}
+	if item.Kind != "tv_series" {
+		return nil, fmt.Errorf("media item %d is a %s, not a series", seriesID, item.Kind)
+	}
+	return r.refresh(ctx, item, true)
+}
+
+// tmdbSeries is the part of a TMDB series the refresh needs
+type tmdbSeries struct {
+	Name             string `json:"name"`
+	Overview         string `json:"overview"`
+	Status           string `json:"status"`
+	LastAirDate      string `json:"last_air_date"`
+	LastUpdated      string `json:"last_updated"` // Not every provider has it
+	NumberOfSeasons  int    `json:"number_of_seasons"`
+	NumberOfEpisodes int    `json:"number_of_episodes"`
+	NextEpisodeToAir *struct {
+		AirDate string `json:"air_date"`
+	} `json:"next_episode_to_air"`
+	Seasons []struct {
+		SeasonNumber int    `json:"season_number"`
+		Name         string `json:"name"`
+		AirDate      string `json:"air_date"`
+		EpisodeCount int    `json:"episode_count"`
+	} `json:"seasons"`
+}
+
+// tmdbSeasonDetails is a TMDB season with its episodes
+type tmdbSeasonDetails struct {
+	SeasonNumber int    `json:"season_number"`
+	Name         string `json:"name"`
+	Overview     string `json:"overview"`
+	AirDate      string `json:"air_date"`
+	PosterPath   string `json:"poster_path"`
+	Episodes     []struct {
+		EpisodeNumber int    `json:"episode_number"`
+		Name          string `json:"name"`
+		Overview      string `json:"overview"`
+		AirDate       string `json:"air_date"`
+		Runtime       int    `json:"runtime"`
+		StillPath     string `json:"still_path"`
+	} `json:"episodes"`
+}
+
+// tmdbImageURL is where TMDB serves original size images
+const tmdbImageURL = "https://image.tmdb.org/t/p/original"
+
+// refresh refreshes a series, skipping it when its TMDB details haven't changed
+// since the last refresh unless force is set
+func (r *MetadataRefresher) refresh(ctx context.Context, series generated.MediaItem, force bool) (*RefreshSummary, error) {
+	var metadata, externalIDs map[string]interface{}
+	_ = json.Unmarshal(series.Metadata, &metadata)
+	_ = json.Unmarshal(series.ExternalIds, &externalIDs)
+	tmdbID := jsonString(externalIDs["tmdb"])
+	if tmdbID == "" {
+		tmdbID = jsonString(metadata["tmdb_id"])
+	}
+	if tmdbID == "" {
+		return nil, ErrNoTMDBID
+	}
+
+	var details tmdbSeries
+	if err := r.tmdbGet(ctx, fmt.Sprintf("/api/plugins/tmdb/tv/%s", tmdbID), force, &details); err != nil {
+		return nil, err
+	}
+
+	summary := &RefreshSummary{SeriesID: series.ID, Title: series.Title, Status: seriesStatus(details.Status)}
+	fingerprint := refreshFingerprint(&details)
+	if !force && jsonString(metadata["refresh_fingerprint"]) == fingerprint {
+		summary.Skipped = true
+		return summary, nil
+	}
+
+	seasons, err := r.queries.ListChildMediaItems(ctx, &series.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	bySeason := make(map[int]generated.MediaItem)
+	for _, season := range seasons {
+		if season.Kind != "tv_season" {
+			continue
+		}
+		var m map[string]interface{}
+		_ = json.Unmarshal(season.Metadata, &m)
+		if n, ok := jsonInt(m["season_number"]); ok {
+			bySeason[n] = season
+		}
+	}
+
+	for _, s := range details.Seasons {
+		// Specials are only kept up to date, as searching them is rarely wanted
+		existing, ok := bySeason[s.SeasonNumber]
+		if s.SeasonNumber == 0 && !ok {
+			continue
+		}
+		var season tmdbSeasonDetails
+		path := fmt.Sprintf("/api/plugins/tmdb/tv/%s/season/%d", tmdbID, s.SeasonNumber)
+		if err := r.tmdbGet(ctx, path, force, &season); err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("season %d: %v", s.SeasonNumber, err))
+			continue
+		}
+		var seasonItem *generated.MediaItem
+		if ok {
+			seasonItem = &existing
+		}
+		if err := r.refreshSeason(ctx, series, seasonItem, tmdbID, &season, summary); err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("season %d: %v", s.SeasonNumber, err))
+		}
+	}
+
+	seriesMetadata := map[string]interface{}{
+		"series_status":         summary.Status,
+		"last_air_date":         details.LastAirDate,
+		"number_of_seasons":     details.NumberOfSeasons,
+		"number_of_episodes":    details.NumberOfEpisodes,
+		"refresh_fingerprint":   fingerprint,
+		"metadata_refreshed_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if details.Overview != "" {
+		seriesMetadata["description"] = details.Overview
+	}
+	// A failed season is tried again on the next refresh
+	if len(summary.Warnings) > 0 {
+		seriesMetadata["refresh_fingerprint"] = ""
+	}
+	if err := r.updateMetadata(ctx, series.ID, seriesMetadata); err != nil {
+		return summary, err
+	}
+
+	if summary.EpisodesAdded > 0 && r.episodesAdded != nil {
+		if err := r.episodesAdded(ctx, series.ID); err != nil {
+			r.logger.Warn("failed to monitor added episodes", zap.Int64("series_id", series.ID), zap.Error(err))
+		}
+	}
+	if summary.changed() {
+		seriesID := series.ID
+		r.history.Record(ctx, history.Event{
+			Type:        history.EventMetadataRefreshed,
+			MediaItemID: &seriesID,
+			Title:       series.Title,
+			Data: map[string]interface{}{
+				"seasons_added":    summary.SeasonsAdded,
+				"episodes_added":   summary.EpisodesAdded,
+				"seasons_updated":  summary.SeasonsUpdated,
+				"episodes_updated": summary.EpisodesUpdated,
+				"status":           summary.Status,
+			},
+		})
+	}
+	return summary, nil
+}
+
+// refreshSeason creates a season when the library doesn't have it yet, and
+// creates or updates its episodes
+func (r *MetadataRefresher) refreshSeason(ctx context.Context, series generated.MediaItem, season *generated.MediaItem, tmdbID string, details *tmdbSeasonDetails, summary *RefreshSummary) error {
+	number := details.SeasonNumber
+	seasonMetadata := map[string]interface{}{
+		"tmdb_id":       tmdbID,
+		"season_number": number,
+		"name":          details.Name,
+		"description":   details.Overview,
+		"air_date":      details.AirDate,
+	}
+	if details.PosterPath != "" {
+		seasonMetadata["poster_url"] = tmdbImageURL + details.PosterPath
+	}
+
+	if season == nil {
+		seasonMetadata["source"] = "metadata_refresh"
+		created, err := r.createItem(ctx, "tv_season", fmt.Sprintf("Season %d", number), series.ID, series.ExternalIds, seasonMetadata)
+		if err != nil {
+			return err
+		}
+		if err := r.scanner.upsertMediaRelation(ctx, series.ID, created.ID, "series-season", float64(number)); err != nil {
+			r.logger.Warn("failed to create series-season relation", zap.Error(err))
+		}
+		season = &created
+		summary.SeasonsAdded++
+	} else if changes := metadataChanges(season.Metadata, seasonMetadata); len(changes) > 0 {
+		if err := r.updateMetadata(ctx, season.ID, changes); err != nil {
+			return err
+		}
+		summary.SeasonsUpdated++
+	}
+
+	episodes, err := r.queries.ListChildMediaItems(ctx, &season.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list episodes: %w", err)
+	}
+	byEpisode := make(map[int]generated.MediaItem)
+	titles := make(map[string]bool)
+	for _, episode := range episodes {
+		titles[episode.Title] = true
+		var m map[string]interface{}
+		_ = json.Unmarshal(episode.Metadata, &m)
+		n, ok := jsonInt(m["episode"])
+		if !ok {
+			n, ok = jsonInt(m["episode_number"])
+		}
+		if ok {
+			byEpisode[n] = episode
+		}
+	}
+	names := make(map[string]int)
+	for _, e := range details.Episodes {
+		names[e.Name]++
+	}
+
+	for _, e := range details.Episodes {
+		episodeMetadata := map[string]interface{}{
+			"tmdb_id":        tmdbID,
+			"season":         number,
+			"season_number":  number,
+			"episode":        e.EpisodeNumber,
+			"episode_number": e.EpisodeNumber,
+			"episode_name":   e.Name,
+			"description":    e.Overview,
+			"air_date":       e.AirDate,
+		}
+		if e.Runtime > 0 {
+			episodeMetadata["runtime"] = e.Runtime
+		}
+		if e.StillPath != "" {
+			episodeMetadata["still_url"] = tmdbImageURL + e.StillPath
+		}
+
+		existing, ok := byEpisode[e.EpisodeNumber]
+		if !ok {
+			title := episodeTitle(e.Name, number, e.EpisodeNumber, names[e.Name] > 1 || titles[e.Name])
+			episodeMetadata["source"] = "metadata_refresh"
+			created, err := r.createItem(ctx, "tv_episode", title, season.ID, series.ExternalIds, episodeMetadata)
+			if err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("S%02dE%02d: %v", number, e.EpisodeNumber, err))
+				continue
+			}
+			titles[title] = true
+			if err := r.scanner.upsertMediaRelation(ctx, season.ID, created.ID, "season-episode", float64(e.EpisodeNumber)); err != nil {
+				r.logger.Warn("failed to create season-episode relation", zap.Error(err))
+			}
+			summary.EpisodesAdded++
+			continue
+		}
+
+		updated := false
+		if title := episodeTitle(e.Name, number, e.EpisodeNumber, names[e.Name] > 1); title != existing.Title && !titles[title] && isRealName(e.Name) {
+			sortTitle := title
+			if _, err := r.queries.UpdateMediaItem(ctx, generated.UpdateMediaItemParams{
+				ID:        existing.ID,
+				Title:     &title,
+				SortTitle: &sortTitle,
+			}); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("S%02dE%02d: %v", number, e.EpisodeNumber, err))
+				continue
+			}
+			delete(titles, existing.Title)
+			titles[title] = true
+			updated = true
+		}
+		if changes := metadataChanges(existing.Metadata, episodeMetadata); len(changes) > 0 {
+			if err := r.updateMetadata(ctx, existing.ID, changes); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("S%02dE%02d: %v", number, e.EpisodeNumber, err))
+				continue
+			}
+			updated = true
+		}
+		if updated {
+			summary.EpisodesUpdated++
+		}
+	}
+	return nil
+}
+
+// createItem creates a season or episode below parentID, with the external
+// IDs of its series
+func (r *MetadataRefresher) createItem(ctx context.Context, kind, title string, parentID int64, externalIDs []byte, metadata map[string]interface{}) (generated.MediaItem, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return generated.MediaItem{}, err
+	}
+	if len(externalIDs) == 0 {
+		externalIDs = []byte("{}")
+	}
+	item, err := r.queries.CreateMediaItem(ctx, generated.CreateMediaItemParams{
+		Kind:        kind,
+		Title:       title,
+		SortTitle:   title,
+		ExternalIds: externalIDs,
+		Metadata:    metadataJSON,
+		ParentID:    &parentID,
+	})
+	if err != nil {
+		return generated.MediaItem{}, fmt.Errorf("failed to create %s: %w", kind, err)
+	}
+	return item, nil
+}
+
+// updateMetadata merges metadata into a media item
+func (r *MetadataRefresher) updateMetadata(ctx context.Context, id int64, metadata map[string]interface{}) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if _, err := r.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{
+		ID:       id,
+		Metadata: metadataJSON,
+	}); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+	return nil
+}
+
+// tmdbGet calls a TMDB plugin route and decodes its JSON response. A fresh
+// refresh skips the plugin's cache.
+func (r *MetadataRefresher) tmdbGet(ctx context.Context, path string, fresh bool, out interface{}) error {
+	provider := metadataProviders[0]
+	plugin, ok := r.plugins.GetPlugin(provider.PluginID)
+	if !ok {
+		return fmt.Errorf("plugin %s not found", provider.PluginID)
+	}
+
+	query := map[string][]string{}
+	if fresh {
+		query["refresh"] = []string{"true"}
+	}
+	resp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  "GET",
+		Path:    path,
+		Query:   query,
+		Headers: map[string][]string{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to call plugin: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Body)
+	}
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("failed to decode plugin response: %w", err)
+	}
+	return nil
+}
+
+// seriesStatus maps a TMDB series status to continuing or ended
+func seriesStatus(status string) string {
+	switch status {
+	case "Ended", "Canceled":
+		return "ended"
+	case "":
+		return ""
+	}
+	return "continuing"
+}
+
+// refreshFingerprint identifies the state of a series' TMDB details. A series
+// whose fingerprint is unchanged has no new seasons or episodes. The provider's
+// last update time is used when it has one.
+func refreshFingerprint(details *tmdbSeries) string {
+	if details.LastUpdated != "" {
+		return details.LastUpdated
+	}
+
+	parts := []string{details.Status, details.LastAirDate,
+		strconv.Itoa(details.NumberOfSeasons), strconv.Itoa(details.NumberOfEpisodes)}
+	if details.NextEpisodeToAir != nil {
+		parts = append(parts, details.NextEpisodeToAir.AirDate)
+	}
+	seasons := make([]string, 0, len(details.Seasons))
+	for _, s := range details.Seasons {
+		seasons = append(seasons, fmt.Sprintf("%d:%d:%s:%s", s.SeasonNumber, s.EpisodeCount, s.AirDate, s.Name))
+	}
+	sort.Strings(seasons)
+	return strings.Join(append(parts, seasons...), "|")
+}
+
+// episodeTitle is the title of an episode: its name, or S01E02 when it has
+// none yet or another episode of the season has the same name
+func episodeTitle(name string, season, episode int, duplicate bool) string {
+	if !isRealName(name) || duplicate {
+		return fmt.Sprintf("S%02dE%02d", season, episode)
+	}
+	return name
+}
+
+// isRealName reports whether an episode name is more than a placeholder
+func isRealName(name string) bool {
+	return name != "" && name != placeholderEpisodeName
+}
+
+// metadataChanges returns the keys of fresh whose values differ from the
+// stored metadata. Empty fresh values don't clear stored ones.
+func metadataChanges(stored []byte, fresh map[string]interface{}) map[string]interface{} {
+	var current map[string]interface{}
+	_ = json.Unmarshal(stored, &current)
+
+	// Round-trip so numbers compare like the stored ones
+	freshJSON, _ := json.Marshal(fresh)
+	var normalized map[string]interface{}
+	_ = json.Unmarshal(freshJSON, &normalized)
+
+	changes := make(map[string]interface{})
+	for key, value := range normalized {
+		if value == "" || value == nil {
+			continue
+		}
+		if !reflect.DeepEqual(current[key], value) {
+			changes[key] = fresh[key]
+		}
+	}
+	return changes
+}
+
+// jsonString reads a string or numeric JSON value as a string
+func jsonString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		if v > 0 {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// jsonInt reads a numeric or numeric string JSON value
+func jsonInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package library
+
+import "testing"
+
+func TestEpisodeTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		duplicate bool
+		want      string
+	}{
+		{"Pilot", false, "Pilot"},
+		{"", false, "S02E05"},
+		{"TBA", false, "S02E05"},
+		{"Part One", true, "S02E05"},
+	}
+	for _, tt := range tests {
+		if got := episodeTitle(tt.name, 2, 5, tt.duplicate); got != tt.want {
+			t.Errorf("episodeTitle(%q, %v) = %q, want %q", tt.name, tt.duplicate, got, tt.want)
+		}
+	}
+}
+
+func TestSeriesStatus(t *testing.T) {
+	tests := map[string]string{
+		"Returning Series": "continuing",
+		"In Production":    "continuing",
+		"Ended":            "ended",
+		"Canceled":         "ended",
+		"":                 "",
+	}
+	for status, want := range tests {
+		if got := seriesStatus(status); got != want {
+			t.Errorf("seriesStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestRefreshFingerprint(t *testing.T) {
+	details := &tmdbSeries{Status: "Returning Series", LastAirDate: "2024-03-01", NumberOfSeasons: 2, NumberOfEpisodes: 16}
+	details.Seasons = append(details.Seasons, struct {
+		SeasonNumber int    `json:"season_number"`
+		Name         string `json:"name"`
+		AirDate      string `json:"air_date"`
+		EpisodeCount int    `json:"episode_count"`
+	}{SeasonNumber: 2, Name: "Season 2", AirDate: "2024-02-01", EpisodeCount: 8})
+	before := refreshFingerprint(details)
+
+	if refreshFingerprint(details) != before {
+		t.Error("fingerprint of unchanged details changed")
+	}
+	details.Seasons[0].EpisodeCount = 10
+	if refreshFingerprint(details) == before {
+		t.Error("fingerprint unchanged after episodes were announced")
+	}
+
+	details.LastUpdated = "2024-03-02T10:00:00Z"
+	if got := refreshFingerprint(details); got != details.LastUpdated {
+		t.Errorf("fingerprint = %q, want the last update time", got)
+	}
+}
+
+func TestMetadataChanges(t *testing.T) {
+	stored := []byte(`{"season": 1, "episode": 2, "air_date": "2024-01-08", "description": "Old", "runtime": 42}`)
+	changes := metadataChanges(stored, map[string]interface{}{
+		"season":      1,
+		"episode":     2,
+		"air_date":    "2024-01-09",
+		"description": "",
+		"runtime":     42,
+		"tmdb_id":     "1399",
+	})
+
+	if len(changes) != 2 {
+		t.Fatalf("changes = %v, want air_date and tmdb_id", changes)
+	}
+	if changes["air_date"] != "2024-01-09" || changes["tmdb_id"] != "1399" {
+		t.Errorf("changes = %v", changes)
+	}
+}
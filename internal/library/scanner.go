@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 
 	"go.uber.org/zap"
 )
@@ -28,6 +32,7 @@ import (
 type Scanner struct {
 	queries    *generated.Queries
 	service    *Service
+	events     *plugins.EventBus
 	logger     *zap.Logger
 	rootDir    string            // Legacy single root directory
 	mediaPaths map[string]string // Media type specific paths: "movie", "tv", "music", "book"
@@ -44,6 +49,17 @@ func NewScanner(queries *generated.Queries, logger *zap.Logger, rootDir string)
 	}
 }
 
+// SetEventBus sets the bus created items and finished scans are published to plugins on
+func (s *Scanner) SetEventBus(events *plugins.EventBus) {
+	s.events = events
+	s.service.events = events
+}
+
+// SetMetadataEnricher sets the enricher created movies and TV items are looked up with
+func (s *Scanner) SetMetadataEnricher(enricher *MetadataEnricher) {
+	s.service.enricher = enricher
+}
+
 // SetMediaPath sets the library path for a specific media type
 func (s *Scanner) SetMediaPath(mediaType, path string) {
 	s.mediaPaths[mediaType] = path
@@ -107,37 +123,31 @@ func (s *Scanner) Run(ctx context.Context) error {
 	}
 
 	// Collect all paths to scan
-	pathsToScan := []string{}
-
-	// Add media-specific paths if configured
-	mediaTypes := []string{"movie", "tv", "music", "book"}
-	for _, mediaType := range mediaTypes {
-		if path := s.GetMediaPath(mediaType); path != "" && path != s.rootDir {
-			pathsToScan = append(pathsToScan, path)
-			s.logger.Info("scanning media-specific path",
-				zap.String("media_type", mediaType),
-				zap.String("path", path))
-		}
-	}
-
-	// Add root directory if no media-specific paths are configured, or as fallback
-	if len(pathsToScan) == 0 {
-		pathsToScan = append(pathsToScan, s.rootDir)
-		s.logger.Info("scanning root directory", zap.String("root", s.rootDir))
+	pathsToScan := s.scanPaths(ctx)
+	for _, path := range pathsToScan {
+		s.logger.Info("scanning library path", zap.String("path", path))
 	}
 
 	// Walk all configured paths and collect files
 	var allFiles []string
+	var directoriesScanned int32
 	for _, path := range pathsToScan {
 		s.logger.Info("walking filesystem", zap.String("path", path))
-		files, err := WalkMediaFiles(path)
+		err := WalkLibrary(path,
+			func(string) { directoriesScanned++ },
+			func(file string) { allFiles = append(allFiles, file) })
 		if err != nil {
 			errMsg := fmt.Sprintf("failed to walk filesystem at %s: %v", path, err)
 			s.appendError(ctx, errMsg)
 			s.logger.Warn("failed to walk path", zap.String("path", path), zap.Error(err))
 			continue // Continue with other paths even if one fails
 		}
-		allFiles = append(allFiles, files...)
+	}
+
+	if _, err := s.queries.UpdateScanProgress(ctx, generated.UpdateScanProgressParams{
+		DirectoriesScanned: directoriesScanned,
+	}); err != nil {
+		s.logger.Warn("failed to update scan progress", zap.Error(err))
 	}
 
 	totalFiles := len(allFiles)
@@ -200,19 +210,158 @@ func (s *Scanner) Run(ctx context.Context) error {
 		}
 	}
 
+	// Drop the files that have disappeared from the library since the last scan
+	seen := make(map[string]bool, len(allFiles))
+	for _, filePath := range allFiles {
+		seen[filePath] = true
+	}
+	var itemsRemoved int32
+	for _, path := range pathsToScan {
+		removed, err := s.removeVanishedFiles(ctx, path, seen)
+		if err != nil {
+			s.appendError(ctx, fmt.Sprintf("Error removing missing files under %s: %v", path, err))
+			s.logger.Warn("failed to remove missing files", zap.String("path", path), zap.Error(err))
+		}
+		itemsRemoved += removed
+	}
+	if itemsRemoved > 0 {
+		if _, err := s.queries.UpdateScanProgress(ctx, generated.UpdateScanProgressParams{
+			ItemsRemoved: itemsRemoved,
+		}); err != nil {
+			s.logger.Warn("failed to update scan progress", zap.Error(err))
+		}
+	}
+
 	// Final log
 	finalState, _ := s.queries.GetScannerState(ctx)
-	logMsg := fmt.Sprintf("Scan completed: %d files scanned, %d items created, %d items updated",
-		finalState.FilesScanned, finalState.ItemsCreated, finalState.ItemsUpdated)
+	logMsg := fmt.Sprintf("Scan completed: %d directories, %d files scanned, %d items created, %d items updated, %d removed",
+		finalState.DirectoriesScanned, finalState.FilesScanned, finalState.ItemsCreated, finalState.ItemsUpdated, finalState.ItemsRemoved)
 	s.appendLog(ctx, "info", logMsg)
 	s.logger.Info("scan completed",
+		zap.Int32("directories_scanned", finalState.DirectoriesScanned),
 		zap.Int32("files_scanned", finalState.FilesScanned),
 		zap.Int32("items_created", finalState.ItemsCreated),
-		zap.Int32("items_updated", finalState.ItemsUpdated))
+		zap.Int32("items_updated", finalState.ItemsUpdated),
+		zap.Int32("items_removed", finalState.ItemsRemoved))
+
+	s.events.Publish(plugins.EventLibraryScanFinished, map[string]interface{}{
+		"directories_scanned": finalState.DirectoriesScanned,
+		"files_scanned":       finalState.FilesScanned,
+		"items_created":       finalState.ItemsCreated,
+		"items_updated":       finalState.ItemsUpdated,
+		"items_removed":       finalState.ItemsRemoved,
+	})
 
 	return nil
 }
 
+// =============================================================================
+// scanPaths - Library folders covered by a scan
+// =============================================================================
+// The configured root folders and media-specific paths, or the legacy root
+// directory when none are set. Nested folders are only scanned once.
+// =============================================================================
+
+func (s *Scanner) scanPaths(ctx context.Context) []string {
+	var candidates []string
+	if folders, err := s.queries.ListRootFolders(ctx); err != nil {
+		s.logger.Warn("failed to list root folders", zap.Error(err))
+	} else {
+		for _, folder := range folders {
+			candidates = append(candidates, folder.Path)
+		}
+	}
+	for _, mediaType := range []string{"movie", "tv", "music", "book"} {
+		if path := s.GetMediaPath(mediaType); path != "" && path != s.rootDir {
+			candidates = append(candidates, path)
+		}
+	}
+
+	var paths []string
+	for _, candidate := range candidates {
+		candidate = filepath.Clean(candidate)
+		covered := false
+		for i, path := range paths {
+			if isWithin(candidate, path) {
+				covered = true
+				break
+			}
+			if isWithin(path, candidate) {
+				paths[i] = candidate
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			paths = append(paths, candidate)
+		}
+	}
+
+	if len(paths) == 0 && s.rootDir != "" {
+		paths = append(paths, s.rootDir)
+	}
+	return paths
+}
+
+// isWithin reports whether path is dir or lies below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// =============================================================================
+// removeVanishedFiles - Delete media_files rows for files that no longer exist
+// =============================================================================
+// Checks every file recorded under root that the scan did not see, so files
+// that appeared or moved during the scan are left alone.
+// =============================================================================
+
+func (s *Scanner) removeVanishedFiles(ctx context.Context, root string, seen map[string]bool) (int32, error) {
+	files, err := s.queries.ListMediaFilesUnderPath(ctx, likePrefix(root))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list media files: %w", err)
+	}
+
+	var removed int32
+	for _, file := range files {
+		if seen[file.Path] {
+			continue
+		}
+		if _, err := os.Stat(file.Path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := s.queries.DeleteMediaFile(ctx, file.ID); err != nil {
+			return removed, fmt.Errorf("failed to delete media file %s: %w", file.Path, err)
+		}
+		s.logger.Info("removed missing media file", zap.String("path", file.Path))
+		removed++
+	}
+	return removed, nil
+}
+
+// =============================================================================
+// removePath - Delete media_files rows for a file or folder that was removed
+// =============================================================================
+
+func (s *Scanner) removePath(ctx context.Context, path string) (int32, error) {
+	if _, err := s.queries.GetMediaFileByPath(ctx, path); err == nil {
+		if err := s.queries.DeleteMediaFileByPath(ctx, path); err != nil {
+			return 0, fmt.Errorf("failed to delete media file: %w", err)
+		}
+		s.logger.Info("removed missing media file", zap.String("path", path))
+		return 1, nil
+	}
+
+	// Not a known file, so possibly a folder that was deleted or moved away
+	return s.removeVanishedFiles(ctx, path, nil)
+}
+
+// likePrefix builds a LIKE pattern matching every path under a folder
+func likePrefix(dir string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filepath.Clean(dir))
+	return escaped + string(filepath.Separator) + "%"
+}
+
 // =============================================================================
 // processFile - Process a single media file
 // =============================================================================
@@ -329,6 +478,8 @@ type ScanStatus struct {
 	FilesScanned int32      `json:"files_scanned"`
 	ItemsCreated int32      `json:"items_created"`
 	ItemsUpdated int32      `json:"items_updated"`
+	ItemsRemoved int32      `json:"items_removed"`
+	Directories  int32      `json:"directories_scanned"`
 	Errors       []LogEntry `json:"errors"`
 	Log          []LogEntry `json:"log"`
 }
@@ -350,6 +501,8 @@ func (s *Scanner) GetScanStatus(ctx context.Context) (*ScanStatus, error) {
 		FilesScanned: state.FilesScanned,
 		ItemsCreated: state.ItemsCreated,
 		ItemsUpdated: state.ItemsUpdated,
+		ItemsRemoved: state.ItemsRemoved,
+		Directories:  state.DirectoriesScanned,
 		Errors:       []LogEntry{},
 		Log:          []LogEntry{},
 	}
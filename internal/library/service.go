@@ -1,17 +1,14 @@
 package library
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
-	"time"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
 	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/plugins"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
@@ -31,19 +28,19 @@ import (
 // =============================================================================
 
 type Service struct {
-	queries     *generated.Queries
-	logger      *zap.Logger
-	tmdbBaseURL string
-	enableTMDB  bool
+	queries *generated.Queries
+	logger  *zap.Logger
+	events  *plugins.EventBus // Created items are published to plugins here
+
+	// enricher looks up created movies and TV items before they are published
+	enricher *MetadataEnricher
 }
 
 // NewService creates a new scanner service
 func NewService(queries *generated.Queries, logger *zap.Logger) *Service {
 	return &Service{
-		queries:     queries,
-		logger:      logger,
-		tmdbBaseURL: "http://localhost:8080/api/plugins/tmdb/enrich",
-		enableTMDB:  true, // Can be configured later
+		queries: queries,
+		logger:  logger,
 	}
 }
 
@@ -100,8 +97,9 @@ func (s *Service) UpsertMovie(ctx context.Context, parsed *ParsedMedia, filePath
 		return item.ID, created, fmt.Errorf("failed to upsert media file: %w", err)
 	}
 
-	// Enrich with TMDB metadata (best effort, don't fail on errors)
-	go s.enrichWithTMDB(context.Background(), item.ID, parsed)
+	if created {
+		s.publishCreated(item, parsed)
+	}
 
 	return item.ID, created, nil
 }
@@ -131,6 +129,12 @@ func (s *Service) UpsertTVEpisode(ctx context.Context, parsed *ParsedMedia, file
 		return 0, false, fmt.Errorf("failed to ensure TV series: %w", err)
 	}
 
+	// Anime files are numbered by absolute episode; the series' episodes tell
+	// the season and episode it is
+	if parsed.Season == 0 && parsed.Absolute > 0 {
+		s.resolveAbsoluteEpisode(ctx, seriesID, parsed)
+	}
+
 	// Step 2: Ensure TV season exists
 	seasonID, err := s.ensureTVSeason(ctx, seriesID, parsed.Season, parsed.Title)
 	if err != nil {
@@ -143,6 +147,11 @@ func (s *Service) UpsertTVEpisode(ctx context.Context, parsed *ParsedMedia, file
 	if episodeTitle == "" {
 		episodeTitle = fmt.Sprintf("S%02dE%02d", parsed.Season, parsed.Episode)
 	}
+	// An episode a metadata refresh added is matched by its number, as its
+	// title rarely matches the file name
+	if existing := s.findSeasonEpisode(ctx, seasonID, parsed.Episode); existing != nil {
+		episodeTitle = existing.Title
+	}
 	sortTitle := episodeTitle
 
 	metadata := map[string]interface{}{
@@ -153,6 +162,9 @@ func (s *Service) UpsertTVEpisode(ctx context.Context, parsed *ParsedMedia, file
 	if parsed.EpisodeTitle != "" {
 		metadata["episode_title"] = parsed.EpisodeTitle
 	}
+	if parsed.Absolute > 0 {
+		metadata["absolute_number"] = parsed.Absolute
+	}
 	metadataJSON, _ := json.Marshal(metadata)
 
 	item, err := s.queries.UpsertMediaItem(ctx, generated.UpsertMediaItemParams{
@@ -180,12 +192,49 @@ func (s *Service) UpsertTVEpisode(ctx context.Context, parsed *ParsedMedia, file
 		return item.ID, created, fmt.Errorf("failed to upsert media file: %w", err)
 	}
 
-	// Enrich with TMDB metadata (best effort, don't fail on errors)
-	go s.enrichWithTMDB(context.Background(), item.ID, parsed)
+	if created {
+		s.publishCreated(item, parsed)
+	}
 
 	return item.ID, created, nil
 }
 
+// findSeasonEpisode returns the episode of a season with the given number, or
+// nil when the season doesn't have it
+func (s *Service) findSeasonEpisode(ctx context.Context, seasonID int64, number int) *generated.MediaItem {
+	episodes, err := s.queries.ListChildMediaItems(ctx, &seasonID)
+	if err != nil {
+		return nil
+	}
+	for i := range episodes {
+		var metadata map[string]interface{}
+		_ = json.Unmarshal(episodes[i].Metadata, &metadata)
+		n, ok := jsonInt(metadata["episode"])
+		if !ok {
+			n, ok = jsonInt(metadata["episode_number"])
+		}
+		if ok && n == number {
+			return &episodes[i]
+		}
+	}
+	return nil
+}
+
+// resolveAbsoluteEpisode sets the season and episode of a file numbered by
+// absolute episode. Without a matching episode it is taken to be in season 1.
+func (s *Service) resolveAbsoluteEpisode(ctx context.Context, seriesID int64, parsed *ParsedMedia) {
+	parsed.Season, parsed.Episode = 1, parsed.Absolute
+
+	episodes, err := ListSeriesEpisodes(ctx, s.queries, seriesID)
+	if err != nil {
+		s.logger.Warn("failed to list episodes of series", zap.Int64("series_id", seriesID), zap.Error(err))
+		return
+	}
+	if episode := FindAbsoluteEpisode(episodes, parsed.Absolute); episode != nil {
+		parsed.Season, parsed.Episode = episode.Season, episode.Episode
+	}
+}
+
 // =============================================================================
 // UpsertMusicTrack - Create or update a music track and its hierarchy
 // =============================================================================
@@ -207,12 +256,22 @@ func (s *Service) UpsertMusicTrack(ctx context.Context, parsed *ParsedMedia, fil
 	}
 
 	// Step 2: Ensure album exists
-	albumID, err := s.ensureMusicAlbum(ctx, artistID, parsed.Album)
+	albumID, err := s.ensureMusicAlbum(ctx, artistID, parsed)
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to ensure album: %w", err)
 	}
 
-	// Step 3: Upsert the track
+	return s.UpsertAlbumTrack(ctx, albumID, parsed, filePath, fileSize)
+}
+
+// =============================================================================
+// UpsertAlbumTrack - Create or update a music track under a known album
+// =============================================================================
+// Imports for an album media item add their tracks to it directly, rather than
+// looking the album up by artist and title.
+// =============================================================================
+
+func (s *Service) UpsertAlbumTrack(ctx context.Context, albumID int64, parsed *ParsedMedia, filePath string, fileSize int64) (itemID int64, created bool, err error) {
 	sortTitle := generateSortTitle(parsed.Title)
 
 	metadata := map[string]interface{}{
@@ -238,16 +297,20 @@ func (s *Service) UpsertMusicTrack(ctx context.Context, parsed *ParsedMedia, fil
 
 	created = item.CreatedAt.Time.Equal(item.UpdatedAt.Time)
 
-	// Step 4: Create media relation (album -> track)
+	// Create media relation (album -> track)
 	if err := s.upsertMediaRelation(ctx, albumID, item.ID, "album-track", float64(parsed.Track)); err != nil {
 		s.logger.Warn("failed to upsert media relation", zap.Error(err))
 	}
 
-	// Step 5: Upsert media file
+	// Upsert media file
 	if err := s.upsertMediaFile(ctx, item.ID, filePath, fileSize); err != nil {
 		return item.ID, created, fmt.Errorf("failed to upsert media file: %w", err)
 	}
 
+	if created {
+		s.publishCreated(item, parsed)
+	}
+
 	return item.ID, created, nil
 }
 
@@ -291,6 +354,10 @@ func (s *Service) UpsertBook(ctx context.Context, parsed *ParsedMedia, filePath
 		return item.ID, created, fmt.Errorf("failed to upsert media file: %w", err)
 	}
 
+	if created {
+		s.publishCreated(item, parsed)
+	}
+
 	return item.ID, created, nil
 }
 
@@ -325,13 +392,13 @@ func (s *Service) ensureTVSeries(ctx context.Context, title string, year int) (i
 		return 0, err
 	}
 
-	// Enrich with TMDB metadata (best effort, don't fail on errors)
-	parsed := &ParsedMedia{
-		Kind:  "tv_series",
-		Title: title,
-		Year:  year,
+	if item.CreatedAt.Time.Equal(item.UpdatedAt.Time) {
+		s.publishCreated(item, &ParsedMedia{
+			Kind:  "tv_series",
+			Title: title,
+			Year:  year,
+		})
 	}
-	go s.enrichWithTMDB(context.Background(), item.ID, parsed)
 
 	return item.ID, nil
 }
@@ -358,13 +425,13 @@ func (s *Service) ensureTVSeason(ctx context.Context, seriesID int64, seasonNumb
 		return 0, err
 	}
 
-	// Enrich with TMDB metadata (best effort, don't fail on errors)
-	parsed := &ParsedMedia{
-		Kind:   "tv_season",
-		Title:  seriesTitle, // Use series title for TMDB search
-		Season: seasonNumber,
+	if item.CreatedAt.Time.Equal(item.UpdatedAt.Time) {
+		s.publishCreated(item, &ParsedMedia{
+			Kind:   "tv_season",
+			Title:  seriesTitle,
+			Season: seasonNumber,
+		})
 	}
-	go s.enrichWithTMDB(context.Background(), item.ID, parsed)
 
 	// Create series -> season relation
 	if err := s.upsertMediaRelation(ctx, seriesID, item.ID, "series-season", float64(seasonNumber)); err != nil {
@@ -402,7 +469,8 @@ func (s *Service) ensureMusicArtist(ctx context.Context, artistName string) (int
 	return item.ID, nil
 }
 
-func (s *Service) ensureMusicAlbum(ctx context.Context, artistID int64, albumName string) (int64, error) {
+func (s *Service) ensureMusicAlbum(ctx context.Context, artistID int64, parsed *ParsedMedia) (int64, error) {
+	albumName := parsed.Album
 	if albumName == "" {
 		albumName = "Unknown Album"
 	}
@@ -432,6 +500,17 @@ func (s *Service) ensureMusicAlbum(ctx context.Context, artistID int64, albumNam
 		s.logger.Warn("failed to create artist-album relation", zap.Error(err))
 	}
 
+	// Albums are what music metadata plugins look up
+	if item.CreatedAt.Time.Equal(item.UpdatedAt.Time) {
+		s.publishCreated(item, &ParsedMedia{
+			Kind:   string(media.MediaKindMusicAlbum),
+			Title:  albumName,
+			Year:   parsed.Year,
+			Artist: parsed.Artist,
+			Album:  albumName,
+		})
+	}
+
 	return item.ID, nil
 }
 
@@ -502,125 +581,50 @@ func generateSortTitle(title string) string {
 }
 
 // =============================================================================
-// enrichWithTMDB - Fetch and store TMDB metadata for a media item
+// publishCreated - Tell plugins about a media item the scanner created
 // =============================================================================
-// This method calls the TMDB plugin to fetch metadata and updates the media
-// item's metadata column with poster URLs, descriptions, ratings, etc.
+// Metadata plugins subscribe to media.item.created to enrich new items. The
+// event carries what was parsed from the file name; search_title is the movie
+// or series title to look up, which for seasons and episodes differs from the
+// item's own title.
 //
-// Note: This is a best-effort operation. Failures are logged but don't fail
-// the overall scan.
+// With a metadata enricher set, movies and TV items are looked up with the
+// preferred provider first and published after, with metadata_lookup set.
 // =============================================================================
 
-func (s *Service) enrichWithTMDB(ctx context.Context, itemID int64, parsed *ParsedMedia) {
-	if !s.enableTMDB {
-		return
+func (s *Service) publishCreated(item generated.MediaItem, parsed *ParsedMedia) {
+	data := map[string]interface{}{
+		"media_item_id": item.ID,
+		"kind":          item.Kind,
+		"title":         item.Title,
+		"search_title":  parsed.Title,
+		"source":        "scanner",
 	}
-
-	// Build request payload
-	payload := map[string]interface{}{
-		"title": parsed.Title,
-		"kind":  parsed.Kind,
+	if item.ParentID != nil {
+		data["parent_id"] = *item.ParentID
 	}
-
 	if parsed.Year > 0 {
-		payload["year"] = parsed.Year
-	}
-
-	if parsed.Kind == "tv_season" {
-		payload["season"] = parsed.Season
-	}
-
-	if parsed.Kind == "tv_episode" {
-		payload["season"] = parsed.Season
-		payload["episode"] = parsed.Episode
-	}
-
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		s.logger.Warn("Failed to marshal TMDB request", zap.Error(err))
-		return
-	}
-
-	// Make HTTP request to TMDB plugin
-	req, err := http.NewRequestWithContext(ctx, "POST", s.tmdbBaseURL, bytes.NewReader(payloadJSON))
-	if err != nil {
-		s.logger.Warn("Failed to create TMDB request", zap.Error(err))
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		s.logger.Warn("Failed to call TMDB plugin", zap.Error(err))
-		return
+		data["year"] = parsed.Year
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		s.logger.Warn("TMDB plugin returned error",
-			zap.Int("status", resp.StatusCode),
-			zap.String("body", string(body)))
-		return
+	if parsed.Season > 0 {
+		data["season"] = parsed.Season
 	}
-
-	// Parse response
-	var tmdbResp struct {
-		Metadata map[string]interface{} `json:"metadata"`
-		Success  bool                   `json:"success"`
+	if parsed.Episode > 0 {
+		data["episode"] = parsed.Episode
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tmdbResp); err != nil {
-		s.logger.Warn("Failed to decode TMDB response", zap.Error(err))
-		return
+	if parsed.Artist != "" {
+		data["artist"] = parsed.Artist
 	}
-
-	if !tmdbResp.Success || len(tmdbResp.Metadata) == 0 {
-		s.logger.Debug("No TMDB metadata returned", zap.Int64("item_id", itemID))
-		return
+	if parsed.Album != "" {
+		data["album"] = parsed.Album
 	}
-
-	// Update media item with TMDB metadata
-	metadataJSON, err := json.Marshal(tmdbResp.Metadata)
-	if err != nil {
-		s.logger.Warn("Failed to marshal TMDB metadata", zap.Error(err))
-		return
+	if parsed.Track > 0 {
+		data["track"] = parsed.Track
 	}
 
-	// Merge with existing metadata using JSONB concat operator
-	_, err = s.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{
-		ID:       itemID,
-		Metadata: metadataJSON,
-	})
-
-	if err != nil {
-		s.logger.Warn("Failed to update media metadata",
-			zap.Int64("item_id", itemID),
-			zap.Error(err))
+	// The enricher publishes the event once it has looked the item up
+	if s.enricher != nil && enrichable(item.Kind) && s.enricher.enqueue(item, data) {
 		return
 	}
-
-	// Update external_ids with TMDB ID if available
-	if tmdbID, ok := tmdbResp.Metadata["tmdb_id"].(string); ok && tmdbID != "" {
-		externalIDs := map[string]interface{}{
-			"tmdb": tmdbID,
-		}
-		externalIDsJSON, err := json.Marshal(externalIDs)
-		if err == nil {
-			_, err = s.queries.UpdateMediaExternalIDs(ctx, generated.UpdateMediaExternalIDsParams{
-				ID:          itemID,
-				ExternalIds: externalIDsJSON,
-			})
-			if err != nil {
-				s.logger.Warn("Failed to update external IDs",
-					zap.Int64("item_id", itemID),
-					zap.Error(err))
-			}
-		}
-	}
-
-	s.logger.Debug("Successfully enriched media with TMDB data",
-		zap.Int64("item_id", itemID),
-		zap.String("title", parsed.Title))
+	s.events.Publish(plugins.EventMediaItemCreated, data)
 }
@@ -0,0 +1,186 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// subtitlesPluginID is the plugin subtitles are searched and downloaded with
+	subtitlesPluginID = "opensubtitles-plugin"
+
+	// FileKindMedia is the kind of a media item's own file
+	FileKindMedia = "media"
+
+	// FileKindSubtitle is the kind of a subtitle file next to a media file
+	FileKindSubtitle = "subtitle"
+)
+
+// subtitleRequest is what the subtitles plugin is asked for a media file
+type subtitleRequest struct {
+	MediaItemID int64    `json:"media_item_id"`
+	Languages   []string `json:"languages,omitempty"`
+	FileID      int64    `json:"file_id,omitempty"`
+	Language    string   `json:"language,omitempty"`
+}
+
+// =============================================================================
+// SearchSubtitles - POST /api/media/{id}/subtitles/search
+// =============================================================================
+// Lists the subtitles available for a media item's file, best first, to pick
+// one to download.
+//
+// Request body (optional):
+//   {"languages": ["en", "es"]} - defaults to the configured languages
+//
+// Response:
+//   - 200 OK: {"candidates": [...]}
+//   - 404 Not Found: The media item has no file
+//   - 503 Service Unavailable: The subtitles plugin isn't loaded
+// =============================================================================
+
+func (h *FileHandler) SearchSubtitles(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Languages []string `json:"languages"`
+	}
+	if r.ContentLength != 0 {
+		if err := httputil.DecodeJSON(r, &body); err != nil {
+			httputil.RespondError(w, http.StatusBadRequest, err, "invalid request body")
+			return
+		}
+	}
+
+	req, ok := h.subtitleRequest(w, r)
+	if !ok {
+		return
+	}
+	req.Languages = body.Languages
+	h.callSubtitlesPlugin(w, r, "search", req)
+}
+
+// =============================================================================
+// DownloadSubtitle - POST /api/media/{id}/subtitles
+// =============================================================================
+// Downloads a subtitle picked from the search next to the media item's file
+// and records it in the item's files.
+//
+// Request body:
+//   {"file_id": 123, "language": "en"}
+//
+// Response:
+//   - 200 OK: {"success": true, "path": "...", "language": "en"}
+// =============================================================================
+
+func (h *FileHandler) DownloadSubtitle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		FileID   int64  `json:"file_id"`
+		Language string `json:"language"`
+	}
+	if err := httputil.DecodeJSON(r, &body); err != nil {
+		httputil.RespondError(w, http.StatusBadRequest, err, "invalid request body")
+		return
+	}
+	if body.FileID <= 0 || body.Language == "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "file_id and language are required")
+		return
+	}
+
+	req, ok := h.subtitleRequest(w, r)
+	if !ok {
+		return
+	}
+	req.FileID = body.FileID
+	req.Language = body.Language
+	h.callSubtitlesPlugin(w, r, "download", req)
+}
+
+// subtitleRequest checks the media item has a file subtitles can be fetched
+// for, which the plugin looks up itself, writing an error response when it
+// has none
+func (h *FileHandler) subtitleRequest(w http.ResponseWriter, r *http.Request) (subtitleRequest, bool) {
+	mediaID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return subtitleRequest{}, false
+	}
+
+	files, err := h.queries.ListMediaFilesByItem(r.Context(), &mediaID)
+	if err != nil {
+		h.logger.Error("failed to get media files", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get media files")
+		return subtitleRequest{}, false
+	}
+	if mainMediaFile(files) == nil {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item has no file")
+		return subtitleRequest{}, false
+	}
+	return subtitleRequest{MediaItemID: mediaID}, true
+}
+
+// mainMediaFile returns the media file of an item that subtitles belong to:
+// a file that passed verification, or any media file otherwise
+func mainMediaFile(files []generated.MediaFile) *generated.MediaFile {
+	var fallback *generated.MediaFile
+	for i := range files {
+		if files[i].Kind != FileKindMedia {
+			continue
+		}
+		if files[i].Status == FileStatusOK {
+			return &files[i]
+		}
+		if fallback == nil {
+			fallback = &files[i]
+		}
+	}
+	return fallback
+}
+
+// callSubtitlesPlugin forwards a request to the subtitles plugin and relays its response
+func (h *FileHandler) callSubtitlesPlugin(w http.ResponseWriter, r *http.Request, action string, req subtitleRequest) {
+	if h.plugins == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "plugins are disabled")
+		return
+	}
+	plugin, ok := h.plugins.GetPlugin(subtitlesPluginID)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "the OpenSubtitles plugin is not loaded")
+		return
+	}
+
+	body, _ := json.Marshal(req)
+	resp, err := plugin.Client.HandleAPI(r.Context(), &plugins.PluginHTTPRequest{
+		Method:  "POST",
+		Path:    "/api/plugins/opensubtitles/" + action,
+		Query:   map[string][]string{},
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+	if err != nil {
+		h.logger.Error("failed to call subtitles plugin", zap.Int64("media_item_id", req.MediaItemID), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusBadGateway, "failed to call the OpenSubtitles plugin")
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		var pluginErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(resp.Body, &pluginErr)
+		if pluginErr.Error == "" {
+			pluginErr.Error = fmt.Sprintf("OpenSubtitles plugin returned HTTP %d", resp.StatusCode)
+		}
+		httputil.RespondErrorMessage(w, resp.StatusCode, pluginErr.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.Body)
+}
@@ -0,0 +1,502 @@
+package library
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/notifications"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// Verifier - Media file integrity verification
+// =============================================================================
+// The Verifier walks every media_files row in ID order and checks that:
+//   1. The file still exists
+//   2. Its size matches the recorded size
+//   3. Optionally, its SHA-256 hash matches the recorded hash (files without a
+//      hash get one stored)
+//
+// Each file is flagged with a status (ok/missing/size_mismatch/hash_mismatch).
+// Episodes whose only file fails verification are marked as missing in
+// episode_monitoring so the automatic search grabs them again.
+//
+// Progress is saved in verification_runs as it goes, so a run that is
+// cancelled, or interrupted by a restart, resumes where it stopped.
+// =============================================================================
+
+// Media file verification statuses
+const (
+	FileStatusOK           = "ok"
+	FileStatusMissing      = "missing"
+	FileStatusSizeMismatch = "size_mismatch"
+	FileStatusHashMismatch = "hash_mismatch"
+)
+
+// Verification run statuses
+const (
+	VerificationRunning   = "running"
+	VerificationCompleted = "completed"
+	VerificationCancelled = "cancelled"
+	VerificationFailed    = "failed"
+)
+
+// verifyBatchSize is how many files are loaded, and progress saved, at a time
+const verifyBatchSize = 100
+
+// ErrVerificationRunning is returned when a verification is already running
+var ErrVerificationRunning = errors.New("verification already running")
+
+// VerifyOptions controls a verification run
+type VerifyOptions struct {
+	ComputeHashes bool // Hash every file, storing missing hashes and comparing existing ones
+	Resume        bool // Continue the last run if it did not complete
+}
+
+// VerificationRun is the progress of a verification run
+type VerificationRun struct {
+	ID             int64      `json:"id"`
+	Status         string     `json:"status"`
+	ComputeHashes  bool       `json:"compute_hashes"`
+	TotalFiles     int64      `json:"total_files"`
+	FilesChecked   int32      `json:"files_checked"`
+	FilesOK        int32      `json:"files_ok"`
+	FilesMissing   int32      `json:"files_missing"`
+	SizeMismatches int32      `json:"size_mismatches"`
+	HashMismatches int32      `json:"hash_mismatches"`
+	HashesComputed int32      `json:"hashes_computed"`
+	Error          *string    `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// UnhealthyFile is a media file that failed verification
+type UnhealthyFile struct {
+	ID          int64      `json:"id"`
+	MediaItemID *int64     `json:"media_item_id,omitempty"`
+	Path        string     `json:"path"`
+	Size        *int64     `json:"size,omitempty"`
+	Status      string     `json:"status"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+}
+
+// VerificationReport summarizes the last verification run and the files that
+// currently fail verification
+type VerificationReport struct {
+	Running bool             `json:"running"`
+	Run     *VerificationRun `json:"run"`
+	Counts  map[string]int64 `json:"counts"`
+	Files   []UnhealthyFile  `json:"files"`
+	Total   int64            `json:"total"`
+	Limit   int32            `json:"limit"`
+	Offset  int32            `json:"offset"`
+	HasMore bool             `json:"has_more"`
+}
+
+type Verifier struct {
+	queries  *generated.Queries
+	logger   *zap.Logger
+	notifier *notifications.Service
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // Set while a run is active
+}
+
+// NewVerifier creates a new verifier
+func NewVerifier(queries *generated.Queries, logger *zap.Logger) *Verifier {
+	return &Verifier{
+		queries: queries,
+		logger:  logger.With(zap.String("component", "verifier")),
+	}
+}
+
+// SetNotifier sets the service problems found by verification are reported to
+func (v *Verifier) SetNotifier(notifier *notifications.Service) {
+	v.notifier = notifier
+}
+
+// Start begins a verification run in the background and returns it
+func (v *Verifier) Start(opts VerifyOptions) (*VerificationRun, error) {
+	run, ctx, err := v.begin(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := v.execute(ctx, run); err != nil && !errors.Is(err, context.Canceled) {
+			v.logger.Error("verification failed", zap.Int64("run_id", run.ID), zap.Error(err))
+		}
+	}()
+
+	return toVerificationRun(*run), nil
+}
+
+// Run runs a verification and waits for it to finish
+func (v *Verifier) Run(ctx context.Context, opts VerifyOptions) error {
+	run, runCtx, err := v.begin(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return v.execute(runCtx, run)
+}
+
+// Cancel stops the active run, which can be resumed later. It reports whether a
+// run was active.
+func (v *Verifier) Cancel() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.cancel == nil {
+		return false
+	}
+	v.cancel()
+	return true
+}
+
+// Running reports whether a run is active in this process
+func (v *Verifier) Running() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.cancel != nil
+}
+
+// begin creates a new run, or resumes the last one, and claims the verifier for it
+func (v *Verifier) begin(ctx context.Context, opts VerifyOptions) (*generated.VerificationRun, context.Context, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.cancel != nil {
+		return nil, nil, ErrVerificationRunning
+	}
+
+	latest, err := v.queries.GetLatestVerificationRun(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to get last verification run: %w", err)
+	}
+
+	var run generated.VerificationRun
+	if err == nil && opts.Resume && latest.Status != VerificationCompleted {
+		// A run still marked running can't be active, since no run is active in
+		// this process, so it was interrupted by a restart
+		run, err = v.queries.ResumeVerificationRun(ctx, latest.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resume verification run: %w", err)
+		}
+		v.logger.Info("resuming verification run",
+			zap.Int64("run_id", run.ID),
+			zap.Int64("after_file_id", run.LastFileID))
+	} else {
+		if err == nil && latest.Status == VerificationRunning {
+			v.finish(ctx, latest.ID, VerificationCancelled, nil)
+		}
+
+		total, err := v.queries.CountMediaFiles(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to count media files: %w", err)
+		}
+		run, err = v.queries.CreateVerificationRun(ctx, generated.CreateVerificationRunParams{
+			ComputeHashes: opts.ComputeHashes,
+			TotalFiles:    total,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create verification run: %w", err)
+		}
+		v.logger.Info("starting verification run",
+			zap.Int64("run_id", run.ID),
+			zap.Int64("total_files", total),
+			zap.Bool("compute_hashes", opts.ComputeHashes))
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	v.cancel = cancel
+	return &run, runCtx, nil
+}
+
+// execute verifies the files of a run, saving progress after every batch
+func (v *Verifier) execute(ctx context.Context, run *generated.VerificationRun) error {
+	defer func() {
+		v.mu.Lock()
+		v.cancel()
+		v.cancel = nil
+		v.mu.Unlock()
+	}()
+
+	// Progress still has to be saved once the run is cancelled
+	saveCtx := context.WithoutCancel(ctx)
+
+	for {
+		files, err := v.queries.ListMediaFilesAfterID(ctx, generated.ListMediaFilesAfterIDParams{
+			ID:    run.LastFileID,
+			Limit: verifyBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				v.finish(saveCtx, run.ID, VerificationCancelled, nil)
+				return ctx.Err()
+			}
+			msg := err.Error()
+			v.finish(saveCtx, run.ID, VerificationFailed, &msg)
+			return fmt.Errorf("failed to list media files: %w", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			if err := v.verifyFile(ctx, file, run); err != nil {
+				if ctx.Err() != nil {
+					v.saveProgress(saveCtx, run)
+					v.finish(saveCtx, run.ID, VerificationCancelled, nil)
+					v.logger.Info("verification cancelled",
+						zap.Int64("run_id", run.ID),
+						zap.Int32("files_checked", run.FilesChecked))
+					return ctx.Err()
+				}
+				v.logger.Warn("failed to verify file", zap.String("path", file.Path), zap.Error(err))
+			}
+			run.LastFileID = file.ID
+
+			// Hashing is slow, so don't lose more than one file of work
+			if run.ComputeHashes {
+				v.saveProgress(saveCtx, run)
+			}
+		}
+		v.saveProgress(saveCtx, run)
+	}
+
+	v.finish(saveCtx, run.ID, VerificationCompleted, nil)
+	v.logger.Info("verification completed",
+		zap.Int64("run_id", run.ID),
+		zap.Int32("files_checked", run.FilesChecked),
+		zap.Int32("files_missing", run.FilesMissing),
+		zap.Int32("size_mismatches", run.SizeMismatches),
+		zap.Int32("hash_mismatches", run.HashMismatches))
+	v.notifyProblems(run)
+	return nil
+}
+
+// notifyProblems sends a health notification when a run found damaged or missing files
+func (v *Verifier) notifyProblems(run *generated.VerificationRun) {
+	problems := run.FilesMissing + run.SizeMismatches + run.HashMismatches
+	if problems == 0 {
+		return
+	}
+
+	v.notifier.Notify(notifications.NewEvent(notifications.EventHealthIssue, "Library verification found problems",
+		fmt.Sprintf("%d of %d files checked are missing or damaged", problems, run.FilesChecked),
+		map[string]interface{}{
+			"run_id":          run.ID,
+			"files_checked":   run.FilesChecked,
+			"files_missing":   run.FilesMissing,
+			"size_mismatches": run.SizeMismatches,
+			"hash_mismatches": run.HashMismatches,
+		}))
+}
+
+// verifyFile checks a single file and records its status
+func (v *Verifier) verifyFile(ctx context.Context, file generated.MediaFile, run *generated.VerificationRun) error {
+	status := FileStatusOK
+	hash := file.Hash
+
+	info, err := os.Stat(file.Path)
+	switch {
+	case os.IsNotExist(err):
+		status = FileStatusMissing
+	case err != nil:
+		return err
+	case file.Size != nil && info.Size() != *file.Size:
+		status = FileStatusSizeMismatch
+	case run.ComputeHashes:
+		sum, err := hashFile(ctx, file.Path)
+		if err != nil {
+			return err
+		}
+		if hash == nil {
+			hash = &sum
+			run.HashesComputed++
+		} else if *hash != sum {
+			status = FileStatusHashMismatch
+		}
+	}
+
+	if err := v.queries.SetMediaFileVerification(ctx, generated.SetMediaFileVerificationParams{
+		ID:     file.ID,
+		Status: status,
+		Hash:   hash,
+	}); err != nil {
+		return fmt.Errorf("failed to record verification: %w", err)
+	}
+
+	run.FilesChecked++
+	switch status {
+	case FileStatusOK:
+		run.FilesOk++
+		return nil
+	case FileStatusMissing:
+		run.FilesMissing++
+	case FileStatusSizeMismatch:
+		run.SizeMismatches++
+	case FileStatusHashMismatch:
+		run.HashMismatches++
+	}
+
+	v.logger.Warn("media file failed verification",
+		zap.Int64("file_id", file.ID),
+		zap.String("path", file.Path),
+		zap.String("status", status))
+
+	// Let the automatic search replace the episode, and every further episode
+	// of a multi-episode file
+	var episodeIDs []int64
+	if file.MediaItemID != nil {
+		episodeIDs = append(episodeIDs, *file.MediaItemID)
+	}
+	if linked, err := v.queries.ListMediaFileEpisodes(ctx, file.ID); err == nil {
+		episodeIDs = append(episodeIDs, linked...)
+	}
+	for _, episodeID := range episodeIDs {
+		if err := v.queries.ClearEpisodeHasFile(ctx, episodeID); err != nil {
+			v.logger.Warn("failed to update episode monitoring",
+				zap.Int64("media_item_id", episodeID),
+				zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// saveProgress stores the position and counters of a run
+func (v *Verifier) saveProgress(ctx context.Context, run *generated.VerificationRun) {
+	if err := v.queries.UpdateVerificationProgress(ctx, generated.UpdateVerificationProgressParams{
+		ID:             run.ID,
+		LastFileID:     run.LastFileID,
+		FilesChecked:   run.FilesChecked,
+		FilesOk:        run.FilesOk,
+		FilesMissing:   run.FilesMissing,
+		SizeMismatches: run.SizeMismatches,
+		HashMismatches: run.HashMismatches,
+		HashesComputed: run.HashesComputed,
+	}); err != nil {
+		v.logger.Warn("failed to save verification progress", zap.Int64("run_id", run.ID), zap.Error(err))
+	}
+}
+
+// finish marks a run as no longer running
+func (v *Verifier) finish(ctx context.Context, runID int64, status string, errorMessage *string) {
+	if _, err := v.queries.FinishVerificationRun(ctx, generated.FinishVerificationRunParams{
+		ID:           runID,
+		Status:       status,
+		ErrorMessage: errorMessage,
+	}); err != nil {
+		v.logger.Warn("failed to finish verification run", zap.Int64("run_id", runID), zap.Error(err))
+	}
+}
+
+// Report summarizes the last run and lists a page of files failing verification
+func (v *Verifier) Report(ctx context.Context, limit, offset int32) (*VerificationReport, error) {
+	report := &VerificationReport{
+		Running: v.Running(),
+		Counts:  map[string]int64{},
+		Files:   []UnhealthyFile{},
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	latest, err := v.queries.GetLatestVerificationRun(ctx)
+	if err == nil {
+		report.Run = toVerificationRun(latest)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get last verification run: %w", err)
+	}
+
+	for _, status := range []string{FileStatusMissing, FileStatusSizeMismatch, FileStatusHashMismatch} {
+		count, err := v.queries.CountMediaFilesByStatus(ctx, status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s files: %w", status, err)
+		}
+		report.Counts[status] = count
+		report.Total += count
+	}
+
+	files, err := v.queries.ListUnhealthyMediaFiles(ctx, generated.ListUnhealthyMediaFilesParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	for _, file := range files {
+		unhealthy := UnhealthyFile{
+			ID:          file.ID,
+			MediaItemID: file.MediaItemID,
+			Path:        file.Path,
+			Size:        file.Size,
+			Status:      file.Status,
+		}
+		if file.VerifiedAt.Valid {
+			verifiedAt := file.VerifiedAt.Time
+			unhealthy.VerifiedAt = &verifiedAt
+		}
+		report.Files = append(report.Files, unhealthy)
+	}
+	report.HasMore = int64(offset)+int64(len(report.Files)) < report.Total
+
+	return report, nil
+}
+
+// hashFile computes the hex SHA-256 of a file, giving up when ctx is cancelled
+func hashFile(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	buf := make([]byte, 1<<20)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := f.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// toVerificationRun converts a database row to a VerificationRun
+func toVerificationRun(row generated.VerificationRun) *VerificationRun {
+	run := &VerificationRun{
+		ID:             row.ID,
+		Status:         row.Status,
+		ComputeHashes:  row.ComputeHashes,
+		TotalFiles:     row.TotalFiles,
+		FilesChecked:   row.FilesChecked,
+		FilesOK:        row.FilesOk,
+		FilesMissing:   row.FilesMissing,
+		SizeMismatches: row.SizeMismatches,
+		HashMismatches: row.HashMismatches,
+		HashesComputed: row.HashesComputed,
+		Error:          row.ErrorMessage,
+		StartedAt:      row.StartedAt.Time,
+	}
+	if row.FinishedAt.Valid {
+		finishedAt := row.FinishedAt.Time
+		run.FinishedAt = &finishedAt
+	}
+	return run
+}
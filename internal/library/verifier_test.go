@@ -0,0 +1,30 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := hashFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("hashFile() error = %v", err)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; sum != want {
+		t.Errorf("hashFile() = %s, want %s", sum, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hashFile(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("hashFile() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
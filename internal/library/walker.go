@@ -81,6 +81,57 @@ func WalkMediaFiles(root string) ([]string, error) {
 	return mediaFiles, err
 }
 
+// =============================================================================
+// WalkLibrary - Traverse a library folder, reporting folders as well as files
+// =============================================================================
+// Applies the same filtering as WalkMediaFiles, but calls visitDir for every
+// folder it descends into (including root) and visitFile for every supported
+// media file, both with absolute paths. The library scanner uses it to count
+// folders and the watcher to find the folders it needs to watch.
+// =============================================================================
+
+func WalkLibrary(root string, visitDir func(path string), visitFile func(path string)) error {
+	// Verify root directory exists
+	if _, err := os.Stat(root); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Don't stop on permission errors, just skip
+			return nil
+		}
+
+		// Skip hidden files and directories, but never the root itself
+		if path != root && d.Name()[0] == '.' {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+
+		if d.IsDir() {
+			if shouldSkipDirectory(d.Name()) {
+				return filepath.SkipDir
+			}
+			if visitDir != nil {
+				visitDir(absPath)
+			}
+			return nil
+		}
+
+		if visitFile != nil && IsSupportedMediaFile(path) {
+			visitFile(absPath)
+		}
+		return nil
+	})
+}
+
 // =============================================================================
 // shouldSkipDirectory - Check if a directory should be skipped during scan
 // =============================================================================
@@ -0,0 +1,42 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalkLibrary(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"Show/Season 01", ".hidden", "@eaDir"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"Show/Season 01/Show - S01E01.mkv", "Show/notes.txt", ".hidden/Movie (2020).mkv", "@eaDir/thumb.mkv"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dirs, files []string
+	err := WalkLibrary(root,
+		func(dir string) { dirs = append(dirs, dir) },
+		func(file string) { files = append(files, file) })
+	if err != nil {
+		t.Fatalf("WalkLibrary() error = %v", err)
+	}
+
+	if len(dirs) != 3 {
+		t.Errorf("visited folders %v, want root, Show and Season 01", dirs)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "Show - S01E01.mkv" {
+		t.Errorf("visited files %v, want only the episode", files)
+	}
+}
+
+func TestLikePrefix(t *testing.T) {
+	if got, want := likePrefix("/media/tv_shows/100%/"), `/media/tv\_shows/100\%/%`; got != want {
+		t.Errorf("likePrefix() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package library
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// Watcher - Keeps the library in sync with the filesystem
+// =============================================================================
+// The Watcher runs a full scan on start, then watches every library folder for
+// files being created, renamed or deleted and updates the database to match.
+//
+// Events are debounced: changed paths are collected until the library has been
+// quiet for watchDebounce, so copying a large folder results in one batch
+// instead of thousands of upserts. A continuously busy library is still
+// processed at least every watchMaxDelay.
+// =============================================================================
+
+const (
+	watchDebounce = 2 * time.Second
+	watchMaxDelay = 30 * time.Second
+)
+
+type Watcher struct {
+	scanner *Scanner
+	logger  *zap.Logger
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewWatcher creates a watcher for the library folders of a scanner
+func NewWatcher(scanner *Scanner, logger *zap.Logger) *Watcher {
+	return &Watcher{
+		scanner: scanner,
+		logger:  logger.With(zap.String("component", "library_watcher")),
+	}
+}
+
+// Start runs the startup scan and begins watching in the background
+func (w *Watcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+// Stop stops watching and waits for the watcher to exit
+func (w *Watcher) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	// Nothing can still be scanning at startup; a set flag is left over from a
+	// previous process that didn't shut down cleanly
+	if _, err := w.scanner.queries.SetScannerRunning(ctx, false); err != nil {
+		w.logger.Warn("failed to reset scanner state", zap.Error(err))
+	}
+	if err := w.scanner.Run(ctx); err != nil {
+		w.logger.Error("startup library scan failed", zap.Error(err))
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.logger.Error("failed to create filesystem watcher", zap.Error(err))
+		return
+	}
+	defer fsw.Close()
+
+	for _, root := range w.scanner.scanPaths(ctx) {
+		w.watchTree(fsw, root)
+	}
+
+	pending := make(map[string]bool)
+	var firstEvent time.Time
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			if !w.relevant(event) {
+				continue
+			}
+
+			// New folders have to be watched themselves
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.watchTree(fsw, event.Name)
+				}
+			}
+			// A folder renamed away keeps its old watch otherwise
+			if event.Has(fsnotify.Rename) {
+				_ = fsw.Remove(event.Name)
+			}
+
+			if len(pending) == 0 {
+				firstEvent = time.Now()
+			}
+			pending[event.Name] = true
+
+			// Wait for events to settle, but don't put off a busy library forever
+			delay := watchDebounce
+			if remaining := watchMaxDelay - time.Since(firstEvent); remaining < delay {
+				delay = max(remaining, 0)
+			}
+			timer.Reset(delay)
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("filesystem watcher error", zap.Error(err))
+
+		case <-timer.C:
+			w.sync(ctx, pending)
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// relevant reports whether an event could change the library
+func (w *Watcher) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(event.Name)
+	return name != "" && name[0] != '.' && !shouldSkipDirectory(name)
+}
+
+// watchTree adds a watch for a folder and every folder below it
+func (w *Watcher) watchTree(fsw *fsnotify.Watcher, root string) {
+	err := WalkLibrary(root, func(dir string) {
+		if err := fsw.Add(dir); err != nil {
+			w.logger.Warn("failed to watch folder", zap.String("path", dir), zap.Error(err))
+		}
+	}, nil)
+	if err != nil && !os.IsNotExist(err) {
+		w.logger.Warn("failed to walk folder", zap.String("path", root), zap.Error(err))
+	}
+}
+
+// sync brings the database up to date with a batch of changed paths. Paths that
+// still exist are upserted, along with everything in a new folder; paths that
+// are gone have their media_files rows removed.
+func (w *Watcher) sync(ctx context.Context, paths map[string]bool) {
+	var created, updated, removed int
+	processed := make(map[string]bool)
+
+	process := func(file string) {
+		if processed[file] {
+			return
+		}
+		processed[file] = true
+
+		isNew, err := w.scanner.processFile(ctx, file)
+		if err != nil {
+			w.logger.Warn("failed to process file", zap.String("path", file), zap.Error(err))
+			return
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	for path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			n, err := w.scanner.removePath(ctx, path)
+			if err != nil {
+				w.logger.Warn("failed to remove missing files", zap.String("path", path), zap.Error(err))
+			}
+			removed += int(n)
+		case err != nil:
+			w.logger.Warn("failed to stat changed path", zap.String("path", path), zap.Error(err))
+		case info.IsDir():
+			if err := WalkLibrary(path, nil, process); err != nil {
+				w.logger.Warn("failed to walk folder", zap.String("path", path), zap.Error(err))
+			}
+		case IsSupportedMediaFile(path):
+			process(path)
+		}
+	}
+
+	if created > 0 || updated > 0 || removed > 0 {
+		w.logger.Info("synced library changes",
+			zap.Int("paths", len(paths)),
+			zap.Int("items_created", created),
+			zap.Int("items_updated", updated),
+			zap.Int("items_removed", removed))
+	}
+}
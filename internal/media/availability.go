@@ -0,0 +1,68 @@
+package media
+
+import "time"
+
+// Availability of a movie, going by its release dates
+const (
+	AvailabilityAnnounced = "announced"  // Not in cinemas yet
+	AvailabilityInCinemas = "in_cinemas" // In cinemas, not released for home viewing yet
+	AvailabilityReleased  = "released"   // Out digitally or on disc
+)
+
+// assumedHomeRelease is how long after reaching cinemas a movie with no known
+// digital or physical release date is taken to be released
+const assumedHomeRelease = 90 * 24 * time.Hour
+
+// ReleaseDates are the days a movie is released on, from the "release_dates"
+// of its metadata. The cinema date falls back to the movie's "release_date".
+type ReleaseDates struct {
+	Cinema   *time.Time
+	Digital  *time.Time
+	Physical *time.Time
+}
+
+// MovieReleaseDates reads the release dates of a movie from its metadata
+func MovieReleaseDates(metadata map[string]interface{}) ReleaseDates {
+	var dates ReleaseDates
+	known, _ := metadata["release_dates"].(map[string]interface{})
+	dates.Cinema = metadataDay(known["cinema"])
+	dates.Digital = metadataDay(known["digital"])
+	dates.Physical = metadataDay(known["physical"])
+	if dates.Cinema == nil {
+		dates.Cinema = metadataDay(metadata["release_date"])
+	}
+	return dates
+}
+
+// metadataDay parses a YYYY-MM-DD day of metadata
+func metadataDay(value interface{}) *time.Time {
+	s, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	day, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil
+	}
+	return &day
+}
+
+// MovieAvailability returns whether a movie is announced, in cinemas or
+// released at a time. A movie with no release dates at all is announced.
+func MovieAvailability(metadata map[string]interface{}, now time.Time) string {
+	dates := MovieReleaseDates(metadata)
+	passed := func(day *time.Time) bool {
+		return day != nil && !day.After(now)
+	}
+
+	if passed(dates.Digital) || passed(dates.Physical) {
+		return AvailabilityReleased
+	}
+	if !passed(dates.Cinema) {
+		return AvailabilityAnnounced
+	}
+	if dates.Digital == nil && dates.Physical == nil && now.Sub(*dates.Cinema) >= assumedHomeRelease {
+		return AvailabilityReleased
+	}
+	return AvailabilityInCinemas
+}
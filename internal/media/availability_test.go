@@ -0,0 +1,39 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMovieAvailability(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     string
+	}{
+		{"no dates", map[string]interface{}{}, AvailabilityAnnounced},
+		{"cinema ahead", map[string]interface{}{"release_date": "2026-07-01"}, AvailabilityAnnounced},
+		{"in cinemas", map[string]interface{}{
+			"release_date":  "2026-05-01",
+			"release_dates": map[string]interface{}{"cinema": "2026-05-01", "digital": "2026-07-15"},
+		}, AvailabilityInCinemas},
+		{"digital out", map[string]interface{}{
+			"release_dates": map[string]interface{}{"cinema": "2026-04-01", "digital": "2026-06-01"},
+		}, AvailabilityReleased},
+		{"physical out", map[string]interface{}{
+			"release_dates": map[string]interface{}{"physical": "2026-05-20"},
+		}, AvailabilityReleased},
+		{"recently in cinemas without home dates", map[string]interface{}{"release_date": "2026-04-01"}, AvailabilityInCinemas},
+		{"long in cinemas without home dates", map[string]interface{}{"release_date": "2025-01-01"}, AvailabilityReleased},
+		{"invalid date", map[string]interface{}{"release_date": "soon"}, AvailabilityAnnounced},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MovieAvailability(tt.metadata, now); got != tt.want {
+				t.Errorf("MovieAvailability() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,9 +9,16 @@ var (
 	// ErrInvalidKind is returned when an invalid media kind is provided
 	ErrInvalidKind = errors.New("invalid media kind")
 
+	// ErrInvalidParent is returned when a media item can't be a child of the
+	// given parent, such as a movie in a TV series
+	ErrInvalidParent = errors.New("invalid parent media item")
+
 	// ErrTitleRequired is returned when title is empty
 	ErrTitleRequired = errors.New("title is required")
 
+	// ErrDeleted is returned when creating an item that is in the recycle bin
+	ErrDeleted = errors.New("media item is in the recycle bin")
+
 	// ErrInvalidFilter is returned when filter parameters are invalid
 	ErrInvalidFilter = errors.New("invalid filter parameters")
 )
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/blakestevenson/nimbus/internal/db/generated"
 	"github.com/jackc/pgx/v5"
@@ -41,6 +42,12 @@ func (s *service) CreateMediaItem(ctx context.Context, params CreateMediaParams)
 		return nil, err
 	}
 
+	if params.ParentID != nil {
+		if err := s.checkParent(ctx, params.Kind, *params.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate sort_title if not provided
 	sortTitle := params.SortTitle
 	if sortTitle == "" {
@@ -69,6 +76,15 @@ func (s *service) CreateMediaItem(ctx context.Context, params CreateMediaParams)
 		ParentID:    params.ParentID,
 	})
 	if err != nil {
+		// A deleted item keeps its title until it is restored or purged
+		if existing, getErr := s.queries.GetMediaItemByTitleYearAndParent(ctx, generated.GetMediaItemByTitleYearAndParentParams{
+			Title:    params.Title,
+			Year:     params.Year,
+			Kind:     string(params.Kind),
+			ParentID: params.ParentID,
+		}); getErr == nil && existing.DeletedAt.Valid {
+			return nil, fmt.Errorf("%w: restore media item %d instead", ErrDeleted, existing.ID)
+		}
 		return nil, fmt.Errorf("failed to create media item: %w", err)
 	}
 
@@ -150,13 +166,18 @@ func (s *service) UpdateMediaItem(ctx context.Context, id int64, params UpdateMe
 	}
 
 	// Check if item exists
-	_, err := s.queries.GetMediaItem(ctx, id)
+	existing, err := s.queries.GetMediaItem(ctx, id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get media item: %w", err)
 	}
+	if params.ParentID != nil {
+		if err := s.checkParent(ctx, MediaKind(existing.Kind), *params.ParentID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Marshal external IDs and metadata if provided
 	var externalIDs []byte
@@ -195,7 +216,7 @@ func (s *service) UpdateMediaItem(ctx context.Context, id int64, params UpdateMe
 // DeleteMediaItem deletes a media item
 func (s *service) DeleteMediaItem(ctx context.Context, id int64) error {
 	// Check if item exists
-	_, err := s.queries.GetMediaItem(ctx, id)
+	item, err := s.queries.GetMediaItem(ctx, id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -203,6 +224,13 @@ func (s *service) DeleteMediaItem(ctx context.Context, id int64) error {
 		return fmt.Errorf("failed to get media item: %w", err)
 	}
 
+	// A collection only groups its movies, which stay in the library without it
+	if MediaKind(item.Kind) == MediaKindMovieCollection {
+		if err := s.queries.DetachChildMediaItems(ctx, &id); err != nil {
+			return fmt.Errorf("failed to detach collection movies: %w", err)
+		}
+	}
+
 	if err := s.queries.DeleteMediaItem(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete media item: %w", err)
 	}
@@ -232,6 +260,22 @@ func (s *service) ListChildItems(ctx context.Context, parentID int64) ([]*MediaI
 
 // Helper functions
 
+// checkParent returns ErrInvalidParent unless a media item of kind may be a
+// child of the item parentID
+func (s *service) checkParent(ctx context.Context, kind MediaKind, parentID int64) error {
+	parent, err := s.queries.GetMediaItem(ctx, parentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: media item %d not found", ErrInvalidParent, parentID)
+		}
+		return fmt.Errorf("failed to get parent media item: %w", err)
+	}
+	if !CanHaveParent(kind, MediaKind(parent.Kind)) {
+		return fmt.Errorf("%w: a %s can't be in a %s", ErrInvalidParent, kind, parent.Kind)
+	}
+	return nil
+}
+
 func dbItemToMediaItem(dbItem generated.MediaItem) (*MediaItem, error) {
 	externalIDs, err := UnmarshalMap(dbItem.ExternalIds)
 	if err != nil {
@@ -243,7 +287,7 @@ func dbItemToMediaItem(dbItem generated.MediaItem) (*MediaItem, error) {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 
-	return &MediaItem{
+	item := &MediaItem{
 		ID:          dbItem.ID,
 		Kind:        MediaKind(dbItem.Kind),
 		Title:       dbItem.Title,
@@ -254,7 +298,14 @@ func dbItemToMediaItem(dbItem generated.MediaItem) (*MediaItem, error) {
 		ParentID:    dbItem.ParentID,
 		CreatedAt:   dbItem.CreatedAt.Time,
 		UpdatedAt:   dbItem.UpdatedAt.Time,
-	}, nil
+	}
+	if dbItem.DeletedAt.Valid {
+		item.DeletedAt = &dbItem.DeletedAt.Time
+	}
+	if item.Kind == MediaKindMovie {
+		item.Availability = MovieAvailability(metadata, time.Now())
+	}
+	return item, nil
 }
 
 func generateSortTitle(title string) string {
@@ -18,8 +18,28 @@ const (
 	MediaKindMusicTrack  MediaKind = "music_track"
 	MediaKindBook        MediaKind = "book"
 	MediaKindBookSeries  MediaKind = "book_series"
+
+	MediaKindMovieCollection MediaKind = "movie_collection"
 )
 
+// parentKinds is the kind of parent each kind of media item may have. Kinds
+// not listed are always top-level.
+var parentKinds = map[MediaKind]MediaKind{
+	MediaKindMovie:      MediaKindMovieCollection,
+	MediaKindTVSeason:   MediaKindTVSeries,
+	MediaKindTVEpisode:  MediaKindTVSeason,
+	MediaKindMusicAlbum: MediaKindMusicArtist,
+	MediaKindMusicTrack: MediaKindMusicAlbum,
+	MediaKindBook:       MediaKindBookSeries,
+}
+
+// CanHaveParent reports whether a media item of kind may be a child of one of
+// parentKind
+func CanHaveParent(kind, parentKind MediaKind) bool {
+	want, ok := parentKinds[kind]
+	return ok && want == parentKind
+}
+
 // MediaItem represents a generic media item
 type MediaItem struct {
 	ID          int64                  `json:"id"`
@@ -32,6 +52,24 @@ type MediaItem struct {
 	ParentID    *int64                 `json:"parent_id,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
+	DeletedAt   *time.Time             `json:"deleted_at,omitempty"` // Set while the item is in the recycle bin
+	Watch       *WatchState            `json:"watch,omitempty"`      // Of the requesting user, when there is one
+
+	// Availability of a movie: announced, in_cinemas or released
+	Availability string `json:"availability,omitempty"`
+}
+
+// WatchState is what a user has watched of a media item. Series, seasons and
+// collections count the movies and episodes below them, and are watched once
+// all of those are.
+type WatchState struct {
+	Watched         bool       `json:"watched"`
+	WatchedAt       *time.Time `json:"watched_at,omitempty"`
+	PositionSeconds *int       `json:"position_seconds,omitempty"` // Where playback stopped, while partly watched
+	DurationSeconds *int       `json:"duration_seconds,omitempty"`
+	Progress        *float64   `json:"progress,omitempty"` // Fraction played, when the duration is known
+	WatchedCount    *int       `json:"watched_count,omitempty"`
+	TotalCount      *int       `json:"total_count,omitempty"`
 }
 
 // CreateMediaParams holds parameters for creating a media item
@@ -0,0 +1,252 @@
+// Package mediainfo extracts technical details of media files with ffprobe
+package mediainfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultFFprobePath is used when no path is configured, and resolved via PATH
+const DefaultFFprobePath = "ffprobe"
+
+// probeTimeout bounds a single ffprobe run, which only reads container headers
+const probeTimeout = 60 * time.Second
+
+// ErrUnavailable is returned when ffprobe can't be found
+var ErrUnavailable = errors.New("ffprobe is not available")
+
+// MediaInfo describes the streams and container of a media file
+type MediaInfo struct {
+	Container string           `json:"container,omitempty"`
+	Duration  float64          `json:"duration,omitempty"` // Seconds
+	Bitrate   int64            `json:"bitrate,omitempty"`  // Overall bits per second
+	Video     *VideoStream     `json:"video,omitempty"`    // The main video stream
+	Audio     []AudioStream    `json:"audio"`
+	Subtitles []SubtitleStream `json:"subtitles"`
+}
+
+// VideoStream describes a video stream
+type VideoStream struct {
+	Codec     string   `json:"codec"`
+	Profile   string   `json:"profile,omitempty"`
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	BitDepth  int      `json:"bit_depth,omitempty"`
+	FrameRate float64  `json:"frame_rate,omitempty"`
+	HDR       []string `json:"hdr,omitempty"` // HDR10, HLG, DolbyVision
+}
+
+// AudioStream describes an audio stream
+type AudioStream struct {
+	Codec         string `json:"codec"`
+	Profile       string `json:"profile,omitempty"`
+	Channels      int    `json:"channels"`
+	ChannelLayout string `json:"channel_layout,omitempty"`
+	Language      string `json:"language,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Default       bool   `json:"default"`
+}
+
+// SubtitleStream describes an embedded subtitle stream
+type SubtitleStream struct {
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+}
+
+// Prober runs ffprobe. It checks for the binary once and reports ErrUnavailable
+// from then on if it is missing, so callers can treat media info as optional.
+type Prober struct {
+	path   string
+	logger *zap.Logger
+
+	once      sync.Once
+	available bool
+}
+
+// NewProber creates a prober for the ffprobe binary at path, or on PATH when
+// path is empty
+func NewProber(path string, logger *zap.Logger) *Prober {
+	if path == "" {
+		path = DefaultFFprobePath
+	}
+	return &Prober{
+		path:   path,
+		logger: logger.With(zap.String("component", "mediainfo")),
+	}
+}
+
+// Available reports whether ffprobe can be run
+func (p *Prober) Available() bool {
+	p.once.Do(func() {
+		resolved, err := exec.LookPath(p.path)
+		if err != nil {
+			p.logger.Info("ffprobe not found, media info extraction disabled", zap.String("path", p.path))
+			return
+		}
+		p.path = resolved
+		p.available = true
+	})
+	return p.available
+}
+
+// Probe extracts the media info of a file
+func (p *Prober) Probe(ctx context.Context, path string) (*MediaInfo, error) {
+	if !p.Available() {
+		return nil, ErrUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.path,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	return Parse(output)
+}
+
+// ffprobe JSON output, limited to the fields we use
+type probeOutput struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+type probeStream struct {
+	CodecType        string `json:"codec_type"`
+	CodecName        string `json:"codec_name"`
+	Profile          string `json:"profile"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	PixFmt           string `json:"pix_fmt"`
+	BitsPerRawSample string `json:"bits_per_raw_sample"`
+	ColorTransfer    string `json:"color_transfer"`
+	RFrameRate       string `json:"r_frame_rate"`
+	Channels         int    `json:"channels"`
+	ChannelLayout    string `json:"channel_layout"`
+	Disposition      struct {
+		Default     int `json:"default"`
+		Forced      int `json:"forced"`
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+	Tags struct {
+		Language string `json:"language"`
+		Title    string `json:"title"`
+	} `json:"tags"`
+	SideDataList []struct {
+		SideDataType string `json:"side_data_type"`
+	} `json:"side_data_list"`
+}
+
+// Parse converts ffprobe JSON output into MediaInfo
+func Parse(data []byte) (*MediaInfo, error) {
+	var output probeOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	info := &MediaInfo{
+		Container: output.Format.FormatName,
+		Audio:     []AudioStream{},
+		Subtitles: []SubtitleStream{},
+	}
+	info.Duration, _ = strconv.ParseFloat(output.Format.Duration, 64)
+	info.Bitrate, _ = strconv.ParseInt(output.Format.BitRate, 10, 64)
+
+	for _, stream := range output.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Cover art is stored as a video stream
+			if info.Video != nil || stream.Disposition.AttachedPic == 1 {
+				continue
+			}
+			info.Video = parseVideo(stream)
+		case "audio":
+			info.Audio = append(info.Audio, AudioStream{
+				Codec:         stream.CodecName,
+				Profile:       stream.Profile,
+				Channels:      stream.Channels,
+				ChannelLayout: stream.ChannelLayout,
+				Language:      stream.Tags.Language,
+				Title:         stream.Tags.Title,
+				Default:       stream.Disposition.Default == 1,
+			})
+		case "subtitle":
+			info.Subtitles = append(info.Subtitles, SubtitleStream{
+				Codec:    stream.CodecName,
+				Language: stream.Tags.Language,
+				Title:    stream.Tags.Title,
+				Default:  stream.Disposition.Default == 1,
+				Forced:   stream.Disposition.Forced == 1,
+			})
+		}
+	}
+
+	return info, nil
+}
+
+// parseVideo extracts the details of a video stream
+func parseVideo(stream probeStream) *VideoStream {
+	video := &VideoStream{
+		Codec:   stream.CodecName,
+		Profile: stream.Profile,
+		Width:   stream.Width,
+		Height:  stream.Height,
+	}
+
+	video.BitDepth, _ = strconv.Atoi(stream.BitsPerRawSample)
+	if video.BitDepth == 0 {
+		switch {
+		case strings.Contains(stream.PixFmt, "12le"), strings.Contains(stream.PixFmt, "12be"):
+			video.BitDepth = 12
+		case strings.Contains(stream.PixFmt, "10le"), strings.Contains(stream.PixFmt, "10be"):
+			video.BitDepth = 10
+		case stream.PixFmt != "":
+			video.BitDepth = 8
+		}
+	}
+
+	if num, den, ok := strings.Cut(stream.RFrameRate, "/"); ok {
+		n, errN := strconv.ParseFloat(num, 64)
+		d, errD := strconv.ParseFloat(den, 64)
+		if errN == nil && errD == nil && d > 0 {
+			video.FrameRate = n / d
+		}
+	}
+
+	switch stream.ColorTransfer {
+	case "smpte2084":
+		video.HDR = append(video.HDR, "HDR10")
+	case "arib-std-b67":
+		video.HDR = append(video.HDR, "HLG")
+	}
+	for _, sideData := range stream.SideDataList {
+		if strings.HasPrefix(sideData.SideDataType, "DOVI configuration") {
+			video.HDR = append(video.HDR, "DolbyVision")
+		}
+	}
+
+	return video
+}
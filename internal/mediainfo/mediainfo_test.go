@@ -0,0 +1,108 @@
+package mediainfo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const ffprobeOutput = `{
+	"streams": [
+		{
+			"codec_type": "video",
+			"codec_name": "hevc",
+			"profile": "Main 10",
+			"width": 3840,
+			"height": 2160,
+			"pix_fmt": "yuv420p10le",
+			"color_transfer": "smpte2084",
+			"r_frame_rate": "24000/1001",
+			"side_data_list": [{"side_data_type": "DOVI configuration record"}],
+			"disposition": {"default": 1}
+		},
+		{
+			"codec_type": "audio",
+			"codec_name": "eac3",
+			"channels": 6,
+			"channel_layout": "5.1(side)",
+			"disposition": {"default": 1},
+			"tags": {"language": "eng", "title": "Surround"}
+		},
+		{
+			"codec_type": "audio",
+			"codec_name": "aac",
+			"channels": 2,
+			"tags": {"language": "ger"}
+		},
+		{
+			"codec_type": "subtitle",
+			"codec_name": "subrip",
+			"disposition": {"forced": 1},
+			"tags": {"language": "eng"}
+		},
+		{
+			"codec_type": "video",
+			"codec_name": "mjpeg",
+			"width": 600,
+			"height": 900,
+			"disposition": {"attached_pic": 1}
+		}
+	],
+	"format": {
+		"format_name": "matroska,webm",
+		"duration": "5400.250000",
+		"bit_rate": "18000000"
+	}
+}`
+
+func TestParse(t *testing.T) {
+	info, err := Parse([]byte(ffprobeOutput))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if info.Container != "matroska,webm" || info.Duration != 5400.25 || info.Bitrate != 18000000 {
+		t.Errorf("format = %q, %v, %d", info.Container, info.Duration, info.Bitrate)
+	}
+
+	video := info.Video
+	if video == nil {
+		t.Fatal("Video = nil, want the hevc stream")
+	}
+	if video.Codec != "hevc" || video.Width != 3840 || video.Height != 2160 {
+		t.Errorf("Video = %+v, want 3840x2160 hevc", video)
+	}
+	if video.BitDepth != 10 {
+		t.Errorf("BitDepth = %d, want 10", video.BitDepth)
+	}
+	if video.FrameRate < 23.97 || video.FrameRate > 23.98 {
+		t.Errorf("FrameRate = %v, want 23.976", video.FrameRate)
+	}
+	if !slices.Equal(video.HDR, []string{"HDR10", "DolbyVision"}) {
+		t.Errorf("HDR = %v, want HDR10 and DolbyVision", video.HDR)
+	}
+
+	if len(info.Audio) != 2 {
+		t.Fatalf("Audio has %d streams, want 2", len(info.Audio))
+	}
+	if a := info.Audio[0]; a.Codec != "eac3" || a.Channels != 6 || a.Language != "eng" || !a.Default {
+		t.Errorf("Audio[0] = %+v", a)
+	}
+
+	if len(info.Subtitles) != 1 || !info.Subtitles[0].Forced || info.Subtitles[0].Language != "eng" {
+		t.Errorf("Subtitles = %+v, want one forced English track", info.Subtitles)
+	}
+}
+
+func TestProberUnavailable(t *testing.T) {
+	p := NewProber("/nonexistent/ffprobe", zap.NewNop())
+	if p.Available() {
+		t.Fatal("Available() = true for a missing binary")
+	}
+	if _, err := p.Probe(context.Background(), "/media/movie.mkv"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Probe() error = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,211 @@
+package mediaservers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Section is a library section of a media server
+type Section struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Type      string   `json:"type"`      // movie, show, artist... (Plex) or movies, tvshows, music... (Jellyfin)
+	Locations []string `json:"locations"` // Folders of the section, as the server sees them
+}
+
+// Connector talks to a media server
+type Connector interface {
+	// Sections lists the library sections, which also checks the token
+	Sections(ctx context.Context) ([]Section, error)
+
+	// Refresh asks the server to scan a folder of a library section. Servers
+	// that find the section from the folder ignore sectionID.
+	Refresh(ctx context.Context, sectionID, dir string) error
+}
+
+// NewConnector returns the connector for a media server
+func NewConnector(server Server, client *http.Client) (Connector, error) {
+	base := strings.TrimRight(server.URL, "/")
+	switch server.Type {
+	case TypePlex:
+		return &plexConnector{url: base, token: server.Token, client: client}, nil
+	case TypeJellyfin:
+		return &jellyfinConnector{url: base, token: server.Token, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown media server type: %s", server.Type)
+	}
+}
+
+// do sends a request and decodes a JSON response into out, when it isn't nil.
+// A non-2xx response is an error.
+func do(ctx context.Context, client *http.Client, req *http.Request, out interface{}) error {
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Nimbus")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("HTTP %d: the token was rejected", resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// ========================
+// Plex
+// ========================
+
+type plexConnector struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (c *plexConnector) request(method, path string, query url.Values) (*http.Request, error) {
+	target := c.url + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Plex-Token", c.token)
+	return req, nil
+}
+
+func (c *plexConnector) Sections(ctx context.Context) ([]Section, error) {
+	req, err := c.request(http.MethodGet, "/library/sections", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		MediaContainer struct {
+			Directory []struct {
+				Key      string `json:"key"`
+				Title    string `json:"title"`
+				Type     string `json:"type"`
+				Location []struct {
+					Path string `json:"path"`
+				} `json:"Location"`
+			} `json:"Directory"`
+		} `json:"MediaContainer"`
+	}
+	if err := do(ctx, c.client, req, &resp); err != nil {
+		return nil, err
+	}
+
+	sections := make([]Section, 0, len(resp.MediaContainer.Directory))
+	for _, dir := range resp.MediaContainer.Directory {
+		section := Section{ID: dir.Key, Title: dir.Title, Type: dir.Type, Locations: []string{}}
+		for _, location := range dir.Location {
+			section.Locations = append(section.Locations, location.Path)
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+func (c *plexConnector) Refresh(ctx context.Context, sectionID, dir string) error {
+	if sectionID == "" {
+		return fmt.Errorf("no library section is mapped")
+	}
+	req, err := c.request(http.MethodGet, "/library/sections/"+url.PathEscape(sectionID)+"/refresh",
+		url.Values{"path": {dir}})
+	if err != nil {
+		return err
+	}
+	return do(ctx, c.client, req, nil)
+}
+
+// ========================
+// Jellyfin
+// ========================
+
+type jellyfinConnector struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (c *jellyfinConnector) request(method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Emby-Token", c.token)
+	return req, nil
+}
+
+func (c *jellyfinConnector) Sections(ctx context.Context) ([]Section, error) {
+	req, err := c.request(http.MethodGet, "/Library/VirtualFolders", nil)
+	if err != nil {
+		return nil, err
+	}
+	var folders []struct {
+		Name           string   `json:"Name"`
+		ItemID         string   `json:"ItemId"`
+		CollectionType string   `json:"CollectionType"`
+		Locations      []string `json:"Locations"`
+	}
+	if err := do(ctx, c.client, req, &folders); err != nil {
+		return nil, err
+	}
+
+	sections := make([]Section, 0, len(folders))
+	for _, folder := range folders {
+		locations := folder.Locations
+		if locations == nil {
+			locations = []string{}
+		}
+		sections = append(sections, Section{
+			ID:        folder.ItemID,
+			Title:     folder.Name,
+			Type:      folder.CollectionType,
+			Locations: locations,
+		})
+	}
+	return sections, nil
+}
+
+func (c *jellyfinConnector) Refresh(ctx context.Context, _, dir string) error {
+	req, err := c.request(http.MethodPost, "/Library/Media/Updated", map[string]interface{}{
+		"Updates": []map[string]string{{"Path": dir, "UpdateType": "Created"}},
+	})
+	if err != nil {
+		return err
+	}
+	return do(ctx, c.client, req, nil)
+}
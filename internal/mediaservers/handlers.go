@@ -0,0 +1,193 @@
+package mediaservers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles media server HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new media server handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "mediaservers-handler")),
+	}
+}
+
+// SetupRoutes registers the media server routes, which must be mounted behind
+// the admin middleware
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Route("/settings/media-servers", func(r chi.Router) {
+		r.Get("/", h.ListServers)
+		r.Post("/", h.CreateServer)
+		r.Post("/test", h.TestServer)
+		r.Get("/{id}", h.GetServer)
+		r.Put("/{id}", h.UpdateServer)
+		r.Delete("/{id}", h.DeleteServer)
+		r.Post("/{id}/test", h.TestSavedServer)
+	})
+}
+
+func parseServerID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError writes the response for an error of the service
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media server not found")
+	case errors.Is(err, ErrInvalid):
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+	default:
+		httputil.LogError(h.logger, err, message)
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+	}
+}
+
+// ListServers returns all media servers
+// GET /api/settings/media-servers
+func (h *Handler) ListServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := h.service.List(r.Context())
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to list media servers")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"servers": servers})
+}
+
+// GetServer returns a media server
+// GET /api/settings/media-servers/{id}
+func (h *Handler) GetServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServerID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media server ID")
+		return
+	}
+
+	server, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get media server")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, server)
+}
+
+// CreateServer adds a media server
+// POST /api/settings/media-servers
+func (h *Handler) CreateServer(w http.ResponseWriter, r *http.Request) {
+	var params ServerParams
+	if err := httputil.DecodeJSON(r, &params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	server, err := h.service.Create(r.Context(), params)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to create media server")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusCreated, server)
+}
+
+// UpdateServer changes the settings of a media server
+// PUT /api/settings/media-servers/{id}
+func (h *Handler) UpdateServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServerID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media server ID")
+		return
+	}
+
+	var params ServerParams
+	if err := httputil.DecodeJSON(r, &params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	server, err := h.service.Update(r.Context(), id, params)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to update media server")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, server)
+}
+
+// DeleteServer removes a media server
+// DELETE /api/settings/media-servers/{id}
+func (h *Handler) DeleteServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServerID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media server ID")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.respondServiceError(w, err, "Failed to delete media server")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondTest writes the outcome of a connection test. A failed connection
+// is a result of the test, not an error of the request.
+func (h *Handler) respondTest(w http.ResponseWriter, sections []Section, err error) {
+	if err != nil && (errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalid)) {
+		h.respondServiceError(w, err, "Failed to test media server")
+		return
+	}
+	if err != nil {
+		httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"success":  false,
+			"error":    err.Error(),
+			"sections": []Section{},
+		})
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"sections": sections,
+	})
+}
+
+// TestServer connects to a media server with unsaved settings and lists its
+// library sections
+// POST /api/settings/media-servers/test
+func (h *Handler) TestServer(w http.ResponseWriter, r *http.Request) {
+	var params ServerParams
+	if err := httputil.DecodeJSON(r, &params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if params.Name == "" {
+		params.Name = "test"
+	}
+
+	sections, err := h.service.Test(r.Context(), params)
+	h.respondTest(w, sections, err)
+}
+
+// TestSavedServer connects to a saved media server and lists its library
+// sections
+// POST /api/settings/media-servers/{id}/test
+func (h *Handler) TestSavedServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServerID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media server ID")
+		return
+	}
+
+	sections, err := h.service.TestSaved(r.Context(), id)
+	h.respondTest(w, sections, err)
+}
@@ -0,0 +1,521 @@
+// Package mediaservers tells Plex and Jellyfin servers about imported files.
+//
+// Without it a media server only sees a new file on its next scheduled scan.
+// After every import each enabled server is asked to scan the folder the file
+// was imported into: Plex through a partial scan of the library section
+// mapped to the media's kind or path, Jellyfin through its media updated
+// notification, which finds the library from the path itself.
+package mediaservers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Media server types
+const (
+	TypePlex     = "plex"
+	TypeJellyfin = "jellyfin"
+)
+
+// Refresh statuses
+const (
+	StatusRefreshed = "refreshed"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// attemptTimeout bounds a single request to a media server
+const attemptTimeout = 10 * time.Second
+
+// defaultRetryDelays are the waits before each retry of a failed refresh.
+// Imports wait for the refresh, so they are short.
+var defaultRetryDelays = []time.Duration{2 * time.Second, 5 * time.Second}
+
+// mediaKinds are the media kinds a section can be mapped to, as imports name
+// them
+var mediaKinds = map[string]bool{"movie": true, "tv": true, "music": true, "book": true}
+
+var (
+	// ErrNotFound is returned for a media server that doesn't exist
+	ErrNotFound = errors.New("media server not found")
+
+	// ErrInvalid is wrapped by the errors of invalid media server settings
+	ErrInvalid = errors.New("invalid media server")
+)
+
+// SectionMapping maps imports to a library section of a media server. Imports
+// under PathPrefix use it, the longest prefix winning; other imports use the
+// first mapping of their MediaKind without a path prefix.
+type SectionMapping struct {
+	SectionID  string `json:"section_id,omitempty"`  // Library section to scan; required for Plex
+	MediaKind  string `json:"media_kind,omitempty"`  // movie, tv, music or book
+	PathPrefix string `json:"path_prefix,omitempty"` // Folder the section's media is imported into
+}
+
+// Server is a Plex or Jellyfin server
+type Server struct {
+	ID        int64            `json:"id"`
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	URL       string           `json:"url"`
+	Token     string           `json:"-"` // Write-only, stored encrypted
+	Enabled   bool             `json:"enabled"`
+	Sections  []SectionMapping `json:"sections"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// ServerParams are the settings of a media server being created or updated
+type ServerParams struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	URL      string           `json:"url"`
+	Token    string           `json:"token"`             // Keeps the saved token when empty on update
+	Enabled  *bool            `json:"enabled,omitempty"` // Defaults to true
+	Sections []SectionMapping `json:"sections"`
+}
+
+// RefreshResult is what happened when a media server was asked to scan an
+// imported folder
+type RefreshResult struct {
+	ServerID   int64  `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Status     string `json:"status"` // refreshed, failed or skipped
+	SectionID  string `json:"section_id,omitempty"`
+	Path       string `json:"path"`
+	Attempts   int    `json:"attempts"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Service stores media servers and asks them to scan imported folders
+type Service struct {
+	db          *pgxpool.Pool
+	configStore *configstore.Store // Encrypts tokens with the config secrets' keyring
+	logger      *zap.Logger
+	client      *http.Client
+
+	retryDelays []time.Duration
+}
+
+// NewService creates a new media server service
+func NewService(db *pgxpool.Pool, configStore *configstore.Store, logger *zap.Logger) *Service {
+	return &Service{
+		db:          db,
+		configStore: configStore,
+		logger:      logger.With(zap.String("component", "mediaservers")),
+		client:      &http.Client{Timeout: attemptTimeout},
+		retryDelays: defaultRetryDelays,
+	}
+}
+
+// normalize cleans up the settings of a media server and returns an error
+// wrapping ErrInvalid when they are invalid
+func (p *ServerParams) normalize(requireToken bool) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+	p.URL = strings.TrimRight(strings.TrimSpace(p.URL), "/")
+	p.Token = strings.TrimSpace(p.Token)
+
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if p.Type != TypePlex && p.Type != TypeJellyfin {
+		return fmt.Errorf("%w: type must be plex or jellyfin", ErrInvalid)
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalid)
+	}
+	if requireToken && p.Token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalid)
+	}
+
+	if p.Sections == nil {
+		p.Sections = []SectionMapping{}
+	}
+	for i := range p.Sections {
+		m := &p.Sections[i]
+		m.SectionID = strings.TrimSpace(m.SectionID)
+		m.MediaKind = strings.ToLower(strings.TrimSpace(m.MediaKind))
+		m.PathPrefix = strings.TrimSpace(m.PathPrefix)
+		if m.MediaKind == "" && m.PathPrefix == "" {
+			return fmt.Errorf("%w: section %d needs a media_kind or a path_prefix", ErrInvalid, i+1)
+		}
+		if m.MediaKind != "" && !mediaKinds[m.MediaKind] {
+			return fmt.Errorf("%w: section %d: media_kind must be movie, tv, music or book", ErrInvalid, i+1)
+		}
+		if m.PathPrefix != "" {
+			if !filepath.IsAbs(m.PathPrefix) {
+				return fmt.Errorf("%w: section %d: path_prefix must be an absolute path", ErrInvalid, i+1)
+			}
+			m.PathPrefix = filepath.Clean(m.PathPrefix)
+		}
+		if p.Type == TypePlex && m.SectionID == "" {
+			return fmt.Errorf("%w: section %d: section_id is required for Plex", ErrInvalid, i+1)
+		}
+	}
+	return nil
+}
+
+func (p *ServerParams) enabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// serverColumns are the columns scanServer reads
+const serverColumns = `id, name, type, url, token, enabled, sections, created_at, updated_at`
+
+func scanServer(row pgx.Row) (Server, error) {
+	var server Server
+	var sections []byte
+	err := row.Scan(&server.ID, &server.Name, &server.Type, &server.URL, &server.Token,
+		&server.Enabled, &sections, &server.CreatedAt, &server.UpdatedAt)
+	if err != nil {
+		return server, err
+	}
+	if err := json.Unmarshal(sections, &server.Sections); err != nil || server.Sections == nil {
+		server.Sections = []SectionMapping{}
+	}
+	return server, nil
+}
+
+// sealToken encrypts a token for storage
+func (s *Service) sealToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	sealed, err := s.configStore.Seal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	return string(sealed), nil
+}
+
+// openToken decrypts a stored token. Tokens stored before they were encrypted
+// are read as they are; legacy reports whether that was the case.
+func (s *Service) openToken(stored string) (token string, legacy bool, err error) {
+	if !configstore.IsSecret(json.RawMessage(stored)) {
+		return stored, true, nil
+	}
+	plaintext, err := s.configStore.Open(json.RawMessage(stored))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	if err := json.Unmarshal(plaintext, &token); err != nil {
+		return "", false, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return token, false, nil
+}
+
+// openServers decrypts the tokens of servers as read from the database.
+// Tokens still stored in plain text are encrypted on the way; failing to do
+// so only delays it to the next read.
+func (s *Service) openServers(ctx context.Context, servers []Server) error {
+	for i := range servers {
+		token, legacy, err := s.openToken(servers[i].Token)
+		if err != nil {
+			return fmt.Errorf("media server %s: %w", servers[i].Name, err)
+		}
+		if legacy && token != "" {
+			if sealed, err := s.sealToken(token); err == nil {
+				_, _ = s.db.Exec(ctx, `UPDATE media_servers SET token = $2 WHERE id = $1 AND token = $3`,
+					servers[i].ID, sealed, token)
+			}
+		}
+		servers[i].Token = token
+	}
+	return nil
+}
+
+// queryServers reads the media servers a query returns
+func (s *Service) queryServers(ctx context.Context, query string, args ...interface{}) ([]Server, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Server, error) {
+		return scanServer(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.openServers(ctx, servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// queryServer reads the media server a query returns
+func (s *Service) queryServer(ctx context.Context, query string, args ...interface{}) (*Server, error) {
+	server, err := scanServer(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, err
+	}
+	servers := []Server{server}
+	if err := s.openServers(ctx, servers); err != nil {
+		return nil, err
+	}
+	return &servers[0], nil
+}
+
+// List returns all media servers, sorted by name
+func (s *Service) List(ctx context.Context) ([]Server, error) {
+	servers, err := s.queryServers(ctx, `SELECT `+serverColumns+` FROM media_servers ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media servers: %w", err)
+	}
+	return servers, nil
+}
+
+// Get returns a media server
+func (s *Service) Get(ctx context.Context, id int64) (*Server, error) {
+	server, err := s.queryServer(ctx, `SELECT `+serverColumns+` FROM media_servers WHERE id = $1`, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media server: %w", err)
+	}
+	return server, nil
+}
+
+// Create adds a media server
+func (s *Service) Create(ctx context.Context, params ServerParams) (*Server, error) {
+	if err := params.normalize(true); err != nil {
+		return nil, err
+	}
+	sections, err := json.Marshal(params.Sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sections: %w", err)
+	}
+	token, err := s.sealToken(params.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := s.queryServer(ctx, `
+		INSERT INTO media_servers (name, type, url, token, enabled, sections)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+serverColumns,
+		params.Name, params.Type, params.URL, token, params.enabled(), sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media server: %w", err)
+	}
+	return server, nil
+}
+
+// Update changes the settings of a media server
+func (s *Service) Update(ctx context.Context, id int64, params ServerParams) (*Server, error) {
+	if err := params.normalize(false); err != nil {
+		return nil, err
+	}
+	sections, err := json.Marshal(params.Sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sections: %w", err)
+	}
+	token, err := s.sealToken(params.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := s.queryServer(ctx, `
+		UPDATE media_servers SET
+		    name = $2, type = $3, url = $4,
+		    token = COALESCE(NULLIF($5, ''), token),
+		    enabled = $6, sections = $7
+		WHERE id = $1
+		RETURNING `+serverColumns,
+		id, params.Name, params.Type, params.URL, token, params.enabled(), sections)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update media server: %w", err)
+	}
+	return server, nil
+}
+
+// Delete removes a media server
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM media_servers WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete media server: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Test connects to a media server with the given settings and lists its
+// library sections, for setting up the section mappings
+func (s *Service) Test(ctx context.Context, params ServerParams) ([]Section, error) {
+	if err := params.normalize(true); err != nil {
+		return nil, err
+	}
+	connector, err := NewConnector(Server{Type: params.Type, URL: params.URL, Token: params.Token}, s.client)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+	return connector.Sections(ctx)
+}
+
+// TestSaved connects to a saved media server and lists its library sections
+func (s *Service) TestSaved(ctx context.Context, id int64) ([]Section, error) {
+	server, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Test(ctx, ServerParams{Name: server.Name, Type: server.Type, URL: server.URL, Token: server.Token})
+}
+
+// RefreshImported asks every enabled media server to scan the folder of a
+// file imported for media of the given kind, retrying failures, and returns
+// what happened on each. Failures are only reported, as the import itself
+// succeeded. RefreshImported on a nil Service does nothing, so the importer
+// doesn't need to check whether media servers are set up.
+func (s *Service) RefreshImported(ctx context.Context, mediaKind, path string) []RefreshResult {
+	if s == nil || path == "" {
+		return nil
+	}
+
+	servers, err := s.queryServers(ctx, `SELECT `+serverColumns+` FROM media_servers WHERE enabled ORDER BY name, id`)
+	if err != nil {
+		s.logger.Warn("Failed to load media servers", zap.Error(err))
+		return nil
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	kind := importKind(mediaKind)
+	results := make([]RefreshResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server Server) {
+			defer wg.Done()
+			results[i] = s.refresh(ctx, server, kind, dir)
+		}(i, server)
+	}
+	wg.Wait()
+	return results
+}
+
+// refresh asks one media server to scan a folder
+func (s *Service) refresh(ctx context.Context, server Server, kind, dir string) RefreshResult {
+	result := RefreshResult{ServerID: server.ID, ServerName: server.Name, Path: dir}
+
+	mapping, ok := matchSection(server.Sections, kind, dir)
+	if !ok && (server.Type == TypePlex || len(server.Sections) > 0) {
+		result.Status = StatusSkipped
+		result.Error = fmt.Sprintf("no library section is mapped to %s or to this folder", kind)
+		return result
+	}
+	result.SectionID = mapping.SectionID
+
+	connector, err := NewConnector(server, s.client)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	for {
+		result.Attempts++
+		err = s.send(ctx, connector, mapping.SectionID, dir)
+		if err == nil || result.Attempts > len(s.retryDelays) {
+			break
+		}
+
+		s.logger.Debug("media server refresh failed, retrying",
+			zap.Int64("server_id", server.ID),
+			zap.Int("attempt", result.Attempts),
+			zap.Error(err))
+		time.Sleep(s.retryDelays[result.Attempts-1])
+	}
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		s.logger.Warn("Failed to refresh media server",
+			zap.String("server", server.Name),
+			zap.String("path", dir),
+			zap.Int("attempts", result.Attempts),
+			zap.Error(err))
+		return result
+	}
+	result.Status = StatusRefreshed
+	s.logger.Debug("Refreshed media server", zap.String("server", server.Name), zap.String("path", dir))
+	return result
+}
+
+func (s *Service) send(ctx context.Context, connector Connector, sectionID, dir string) error {
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+	return connector.Refresh(ctx, sectionID, dir)
+}
+
+// importKind returns the media kind sections are mapped to for the media type
+// of an import
+func importKind(mediaType string) string {
+	switch mediaType {
+	case "tv_episode", "tv_season", "tv_series":
+		return "tv"
+	case "music_track", "music_album", "music_artist":
+		return "music"
+	default:
+		return mediaType
+	}
+}
+
+// matchSection picks the section mapping for a folder of media of the given
+// kind: the one with the longest path prefix containing the folder, or else
+// the first one of the kind without a path prefix. A mapping with both only
+// serves its kind under its path.
+func matchSection(mappings []SectionMapping, kind, dir string) (SectionMapping, bool) {
+	dir = filepath.Clean(dir)
+	best, found := SectionMapping{}, false
+	for _, m := range mappings {
+		if m.PathPrefix == "" || (m.MediaKind != "" && m.MediaKind != kind) {
+			continue
+		}
+		if hasPathPrefix(dir, m.PathPrefix) && len(m.PathPrefix) > len(best.PathPrefix) {
+			best, found = m, true
+		}
+	}
+	if found {
+		return best, true
+	}
+	for _, m := range mappings {
+		if m.PathPrefix == "" && m.MediaKind == kind {
+			return m, true
+		}
+	}
+	return SectionMapping{}, false
+}
+
+// hasPathPrefix reports whether dir is prefix or a folder below it
+func hasPathPrefix(dir, prefix string) bool {
+	if dir == prefix || prefix == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(dir, prefix+string(filepath.Separator))
+}
@@ -0,0 +1,249 @@
+package mediaservers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"go.uber.org/zap"
+)
+
+func TestMatchSection(t *testing.T) {
+	mappings := []SectionMapping{
+		{SectionID: "1", MediaKind: "movie"},
+		{SectionID: "2", MediaKind: "tv"},
+		{SectionID: "3", PathPrefix: "/media/kids"},
+		{SectionID: "4", PathPrefix: "/media/kids/tv"},
+		{SectionID: "5", MediaKind: "music", PathPrefix: "/media/audio"},
+	}
+	tests := []struct {
+		kind, dir string
+		want      string
+	}{
+		{"movie", "/media/movies/Alien (1979)", "1"},
+		{"tv", "/media/tv/Show/Season 01", "2"},
+		{"movie", "/media/kids/Up (2009)", "3"},
+		{"tv", "/media/kids/tv/Bluey/Season 01", "4"},
+		{"movie", "/media/kidsmovies/Up (2009)", "1"},
+		{"music", "/media/audio/Artist/Album", "5"},
+		{"movie", "/media/audio/Soundtracks", "1"},
+		{"music", "/media/music/Artist/Album", ""},
+	}
+	for _, tt := range tests {
+		got, ok := matchSection(mappings, tt.kind, tt.dir)
+		if got.SectionID != tt.want || ok != (tt.want != "") {
+			t.Errorf("matchSection(%s, %q) = %q, %v, want %q", tt.kind, tt.dir, got.SectionID, ok, tt.want)
+		}
+	}
+}
+
+func TestTokenSealing(t *testing.T) {
+	keyring, err := configstore.NewKeyring("current-master-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := configstore.New(nil)
+	store.SetKeyring(keyring)
+	s := &Service{configStore: store, logger: zap.NewNop()}
+
+	sealed, err := s.sealToken("plex-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "plex-token") {
+		t.Errorf("sealed token %s holds the token in plain text", sealed)
+	}
+	token, legacy, err := s.openToken(sealed)
+	if err != nil || token != "plex-token" || legacy {
+		t.Errorf("openToken(sealed) = %q, %v, %v, want plex-token", token, legacy, err)
+	}
+
+	// Tokens saved before they were encrypted still work
+	token, legacy, err = s.openToken("plain-token")
+	if err != nil || token != "plain-token" || !legacy {
+		t.Errorf("openToken(plain) = %q, %v, %v, want plain-token as legacy", token, legacy, err)
+	}
+
+	other, _ := configstore.NewKeyring("other-master-key")
+	store.SetKeyring(other)
+	if _, _, err := s.openToken(sealed); err == nil {
+		t.Error("openToken() with another key succeeded")
+	}
+
+	data, _ := json.Marshal(Server{Name: "Plex", Token: "plex-token"})
+	if strings.Contains(string(data), "plex-token") {
+		t.Errorf("server JSON %s exposes the token", data)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	valid := ServerParams{
+		Name:     " Plex ",
+		Type:     "Plex",
+		URL:      "http://plex:32400/",
+		Token:    "secret",
+		Sections: []SectionMapping{{SectionID: "1", MediaKind: "Movie"}, {SectionID: "2", PathPrefix: "/media/tv/"}},
+	}
+	params := valid
+	params.Sections = append([]SectionMapping(nil), valid.Sections...)
+	if err := params.normalize(true); err != nil {
+		t.Fatalf("normalize() = %v", err)
+	}
+	if params.Name != "Plex" || params.Type != TypePlex || params.URL != "http://plex:32400" ||
+		params.Sections[0].MediaKind != "movie" || params.Sections[1].PathPrefix != "/media/tv" {
+		t.Errorf("normalize() = %+v", params)
+	}
+
+	invalid := []func(p *ServerParams){
+		func(p *ServerParams) { p.Name = "" },
+		func(p *ServerParams) { p.Type = "emby" },
+		func(p *ServerParams) { p.URL = "plex:32400" },
+		func(p *ServerParams) { p.Token = "" },
+		func(p *ServerParams) { p.Sections = []SectionMapping{{SectionID: "1"}} },
+		func(p *ServerParams) { p.Sections = []SectionMapping{{SectionID: "1", MediaKind: "anime"}} },
+		func(p *ServerParams) { p.Sections = []SectionMapping{{SectionID: "1", PathPrefix: "media"}} },
+		func(p *ServerParams) { p.Sections = []SectionMapping{{MediaKind: "movie"}} },
+	}
+	for i, change := range invalid {
+		params := valid
+		params.Sections = append([]SectionMapping(nil), valid.Sections...)
+		change(&params)
+		if err := params.normalize(true); !errors.Is(err, ErrInvalid) {
+			t.Errorf("case %d: normalize() = %v, want ErrInvalid", i, err)
+		}
+	}
+
+	// Jellyfin finds the section from the path
+	jellyfin := ServerParams{Name: "Jellyfin", Type: "jellyfin", URL: "http://jellyfin:8096", Token: "secret",
+		Sections: []SectionMapping{{MediaKind: "tv"}}}
+	if err := jellyfin.normalize(true); err != nil {
+		t.Errorf("normalize() = %v for a Jellyfin mapping without a section", err)
+	}
+}
+
+func TestPlexConnector(t *testing.T) {
+	var refreshed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Plex-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/library/sections":
+			w.Write([]byte(`{"MediaContainer":{"Directory":[
+				{"key":"1","title":"Movies","type":"movie","Location":[{"path":"/data/movies"}]},
+				{"key":"2","title":"TV Shows","type":"show","Location":[{"path":"/data/tv"},{"path":"/data/anime"}]}]}}`))
+		case "/library/sections/2/refresh":
+			refreshed = r.URL.Query().Get("path")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector(Server{Type: TypePlex, URL: server.URL + "/", Token: "secret"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := connector.Sections(context.Background())
+	if err != nil {
+		t.Fatalf("Sections() = %v", err)
+	}
+	if len(sections) != 2 || sections[1].ID != "2" || sections[1].Title != "TV Shows" || len(sections[1].Locations) != 2 {
+		t.Errorf("Sections() = %+v", sections)
+	}
+
+	if err := connector.Refresh(context.Background(), "2", "/data/tv/Show/Season 01"); err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if refreshed != "/data/tv/Show/Season 01" {
+		t.Errorf("refreshed path = %q", refreshed)
+	}
+
+	wrongToken, _ := NewConnector(Server{Type: TypePlex, URL: server.URL, Token: "wrong"}, server.Client())
+	if _, err := wrongToken.Sections(context.Background()); err == nil {
+		t.Error("Sections() with a wrong token succeeded")
+	}
+}
+
+func TestJellyfinConnector(t *testing.T) {
+	var updates struct {
+		Updates []struct {
+			Path       string `json:"Path"`
+			UpdateType string `json:"UpdateType"`
+		} `json:"Updates"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Emby-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/Library/VirtualFolders":
+			w.Write([]byte(`[{"Name":"Movies","ItemId":"abc","CollectionType":"movies","Locations":["/data/movies"]}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/Library/Media/Updated":
+			json.NewDecoder(r.Body).Decode(&updates)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector(Server{Type: TypeJellyfin, URL: server.URL, Token: "secret"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := connector.Sections(context.Background())
+	if err != nil {
+		t.Fatalf("Sections() = %v", err)
+	}
+	if len(sections) != 1 || sections[0].ID != "abc" || sections[0].Type != "movies" {
+		t.Errorf("Sections() = %+v", sections)
+	}
+
+	if err := connector.Refresh(context.Background(), "", "/data/movies/Alien (1979)"); err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if len(updates.Updates) != 1 || updates.Updates[0].Path != "/data/movies/Alien (1979)" || updates.Updates[0].UpdateType != "Created" {
+		t.Errorf("updates = %+v", updates)
+	}
+}
+
+func TestRefreshRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer server.Close()
+
+	s := &Service{logger: zap.NewNop(), client: server.Client(), retryDelays: []time.Duration{0, 0}}
+	plex := Server{ID: 1, Name: "Plex", Type: TypePlex, URL: server.URL, Token: "secret",
+		Sections: []SectionMapping{{SectionID: "1", MediaKind: "movie"}}}
+
+	result := s.refresh(context.Background(), plex, "movie", "/media/movies/Alien (1979)")
+	if result.Status != StatusRefreshed || result.Attempts != 3 || result.SectionID != "1" {
+		t.Errorf("refresh() = %+v, want refreshed on the third attempt", result)
+	}
+
+	calls.Store(-10)
+	result = s.refresh(context.Background(), plex, "movie", "/media/movies/Alien (1979)")
+	if result.Status != StatusFailed || result.Attempts != 3 || result.Error == "" {
+		t.Errorf("refresh() = %+v, want failed after three attempts", result)
+	}
+
+	result = s.refresh(context.Background(), plex, "tv", "/media/tv/Show/Season 01")
+	if result.Status != StatusSkipped || result.Attempts != 0 {
+		t.Errorf("refresh() = %+v, want skipped without a section for tv", result)
+	}
+}
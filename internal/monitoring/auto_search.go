@@ -0,0 +1,654 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/downloader"
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/blakestevenson/nimbus/internal/indexer"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/notifications"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSearchConcurrency is how many searches may hit the indexers at once
+	defaultSearchConcurrency = 2
+
+	// maxEpisodesPerRule caps the episodes searched for one series or season rule per run
+	maxEpisodesPerRule = 25
+
+	// autoSearchGrabSource marks downloads grabbed by the automatic search in their metadata
+	autoSearchGrabSource = "auto_search"
+
+	// animeCategory is the newznab category anime is searched in
+	animeCategory = "5070"
+
+	// Downloader plugins used for grabbed releases, by protocol
+	usenetDownloaderID  = "nzb-downloader"
+	torrentDownloaderID = "remote-torrent"
+)
+
+// AutoSearcher periodically searches the indexers for monitoring rules that are
+// due and grabs the best matching release for each wanted item
+type AutoSearcher struct {
+	monitoringSvc *Service
+	qualitySvc    *quality.Service
+	indexerSvc    *indexer.Service
+	downloaderSvc *downloader.Service
+	queries       *generated.Queries
+	tags          indexer.TagSource
+	logger        *zap.Logger
+
+	interval        time.Duration
+	blocklistExpiry time.Duration // How long releases of failed downloads stay blocked
+	limits          ReleaseLimits // Size and age limits for search results
+	slots           chan struct{} // Global limit on concurrent indexer searches
+	runMu           sync.Mutex    // Held while a sweep over due rules is in progress
+	stopChan        chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewAutoSearcher creates a new automatic searcher
+func NewAutoSearcher(
+	monitoringSvc *Service,
+	qualitySvc *quality.Service,
+	indexerSvc *indexer.Service,
+	downloaderSvc *downloader.Service,
+	queries *generated.Queries,
+	logger *zap.Logger,
+) *AutoSearcher {
+	return &AutoSearcher{
+		monitoringSvc:   monitoringSvc,
+		qualitySvc:      qualitySvc,
+		indexerSvc:      indexerSvc,
+		downloaderSvc:   downloaderSvc,
+		queries:         queries,
+		logger:          logger.With(zap.String("component", "auto-search")),
+		interval:        time.Minute,
+		blocklistExpiry: defaultBlocklistExpiry,
+		slots:           make(chan struct{}, defaultSearchConcurrency),
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// SetConcurrency sets how many searches may run at the same time. It must be
+// called before Start.
+func (a *AutoSearcher) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	a.slots = make(chan struct{}, n)
+}
+
+// SetTags enables tag routing: downloaders with tags only get releases for
+// media whose monitoring rule shares one of them
+func (a *AutoSearcher) SetTags(source indexer.TagSource) {
+	a.tags = source
+}
+
+// Start runs the search loop in the background until the context is cancelled or Stop is called
+func (a *AutoSearcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.stopChan:
+				return
+			case <-ticker.C:
+				a.RunDueSearches(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops the search loop
+func (a *AutoSearcher) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stopChan)
+	})
+}
+
+// RunDueSearches searches every monitoring rule that is due. A sweep that is
+// still running when the next tick fires is not started again.
+func (a *AutoSearcher) RunDueSearches(ctx context.Context) {
+	if !a.runMu.TryLock() {
+		a.logger.Debug("Previous automatic search run still in progress, skipping")
+		return
+	}
+	defer a.runMu.Unlock()
+
+	rules, err := a.monitoringSvc.GetMonitoringRulesDueForSearch(ctx)
+	if err != nil {
+		a.logger.Error("Failed to get monitoring rules due for search", zap.Error(err))
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	a.logger.Info("Running automatic search", zap.Int("due_rules", len(rules)))
+
+	var wg sync.WaitGroup
+	for i := range rules {
+		rule := rules[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.SearchRule(ctx, &rule, SearchTypeAutomatic, TriggerSourceScheduler); err != nil {
+				a.logger.Warn("Automatic search failed",
+					zap.Int64("rule_id", rule.ID),
+					zap.Int64("media_item_id", rule.MediaItemID),
+					zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SearchRule searches for everything a monitoring rule wants: the item itself for
+// movies and episodes, or its missing episodes for series and seasons. The rule's
+// next search time is always advanced, even when the search fails.
+func (a *AutoSearcher) SearchRule(ctx context.Context, rule *MonitoringRule, searchType SearchType, trigger TriggerSource) error {
+	defer func() {
+		if err := a.monitoringSvc.UpdateMonitoringRuleSearchTime(ctx, rule.ID); err != nil {
+			a.logger.Error("Failed to update monitoring rule search time", zap.Int64("rule_id", rule.ID), zap.Error(err))
+		}
+	}()
+
+	found, grabbed, err := a.searchWanted(ctx, rule, rule.MediaItemID, searchType, trigger)
+	if err != nil {
+		return err
+	}
+
+	if err := a.monitoringSvc.RecordMonitoringRuleResults(ctx, rule.ID, found, grabbed); err != nil {
+		a.logger.Error("Failed to record monitoring rule results", zap.Int64("rule_id", rule.ID), zap.Error(err))
+	}
+
+	return nil
+}
+
+// searchWanted searches for what is still wanted below a media item: the item
+// itself for movies and episodes, or the missing episodes of a series or season.
+// It returns the number of approved releases and grabbed downloads.
+func (a *AutoSearcher) searchWanted(ctx context.Context, rule *MonitoringRule, mediaItemID int64, searchType SearchType, trigger TriggerSource) (int, int, error) {
+	media, err := a.queries.GetMediaItem(ctx, mediaItemID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	var targets []int64
+	found, grabbed := 0, 0
+	fallbacks := map[int64]string{}
+	switch media.Kind {
+	case "tv_series", "tv_season":
+		missing, err := a.monitoringSvc.GetMissingEpisodesForMedia(ctx, media.ID, maxEpisodesPerRule)
+		if err != nil {
+			return 0, 0, err
+		}
+		if rule != nil && rule.PreferSeasonPacks {
+			var packsFound, packsGrabbed int
+			missing, fallbacks, packsFound, packsGrabbed = a.grabSeasonPacks(ctx, rule, missing, searchType, trigger)
+			found += packsFound
+			grabbed += packsGrabbed
+		}
+		for _, episode := range missing {
+			targets = append(targets, episode.MediaItemID)
+		}
+	default:
+		satisfied, err := a.monitoringSvc.IsMediaSatisfied(ctx, media.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !satisfied {
+			targets = []int64{media.ID}
+		}
+	}
+
+	for _, mediaItemID := range targets {
+		var metadata map[string]interface{}
+		if reason, ok := fallbacks[mediaItemID]; ok {
+			metadata = map[string]interface{}{
+				"strategy":        searchStrategyEpisode,
+				"fallback_reason": reason,
+			}
+		}
+
+		history, err := a.searchMediaItem(ctx, rule, mediaItemID, searchType, trigger, metadata)
+		if err != nil {
+			a.logger.Warn("Search for media item failed", zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+		}
+		if history != nil {
+			found += history.ResultsApproved
+			if history.DownloadGrabbed {
+				grabbed++
+			}
+		}
+	}
+
+	return found, grabbed, nil
+}
+
+// SearchMediaItem searches the indexers for one media item, grabs the best approved
+// release and records the attempt in the search history
+func (a *AutoSearcher) SearchMediaItem(ctx context.Context, rule *MonitoringRule, mediaItemID int64, searchType SearchType, trigger TriggerSource) (*SearchHistory, error) {
+	return a.searchMediaItem(ctx, rule, mediaItemID, searchType, trigger, nil)
+}
+
+// searchMediaItem is SearchMediaItem with extra metadata stored in the search history
+func (a *AutoSearcher) searchMediaItem(ctx context.Context, rule *MonitoringRule, mediaItemID int64, searchType SearchType, trigger TriggerSource, metadata map[string]interface{}) (*SearchHistory, error) {
+	select {
+	case a.slots <- struct{}{}:
+		defer func() { <-a.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	history := newSearchHistory(rule, mediaItemID, searchType, trigger)
+	for k, v := range metadata {
+		history.Metadata[k] = v
+	}
+
+	err := a.searchAndGrab(ctx, rule, mediaItemID, history)
+	a.finishSearchHistory(ctx, history, start, err)
+
+	return history, err
+}
+
+// newSearchHistory starts a search history record
+func newSearchHistory(rule *MonitoringRule, mediaItemID int64, searchType SearchType, trigger TriggerSource) *SearchHistory {
+	history := &SearchHistory{
+		MediaItemID:   mediaItemID,
+		SearchType:    searchType,
+		TriggerSource: &trigger,
+		Status:        SearchStatusCompleted,
+		Metadata:      map[string]interface{}{},
+	}
+	if rule != nil {
+		history.MonitoringRuleID = &rule.ID
+	}
+	return history
+}
+
+// finishSearchHistory records the outcome and duration of a search and saves it
+func (a *AutoSearcher) finishSearchHistory(ctx context.Context, history *SearchHistory, start time.Time, err error) {
+	if err != nil {
+		history.Status = SearchStatusFailed
+		msg := err.Error()
+		history.ErrorMessage = &msg
+	}
+
+	duration := int(time.Since(start).Milliseconds())
+	history.SearchDurationMs = &duration
+
+	if _, herr := a.monitoringSvc.CreateSearchHistory(ctx, history); herr != nil {
+		a.logger.Error("Failed to record search history", zap.Int64("media_item_id", history.MediaItemID), zap.Error(herr))
+	}
+	a.recordSearch(ctx, history)
+}
+
+// recordSearch adds the outcome of a search to the activity history
+func (a *AutoSearcher) recordSearch(ctx context.Context, search *SearchHistory) {
+	title := fmt.Sprintf("Media item %d", search.MediaItemID)
+	if search.Query != nil && *search.Query != "" {
+		title = *search.Query
+	}
+
+	data := map[string]interface{}{
+		"search_type":      search.SearchType,
+		"status":           search.Status,
+		"results_found":    search.ResultsFound,
+		"results_approved": search.ResultsApproved,
+		"results_rejected": search.ResultsRejected,
+		"grabbed":          search.DownloadGrabbed,
+	}
+	if search.TriggerSource != nil {
+		data["trigger"] = *search.TriggerSource
+	}
+	if search.MonitoringRuleID != nil {
+		data["monitoring_rule_id"] = *search.MonitoringRuleID
+	}
+	if search.ErrorMessage != nil {
+		data["error"] = *search.ErrorMessage
+	}
+	if grabbed, ok := search.Metadata["grabbed_release"]; ok {
+		data["grabbed_release"] = grabbed
+	}
+
+	mediaItemID := search.MediaItemID
+	a.downloaderSvc.History().Record(ctx, history.Event{
+		Type:        history.EventSearched,
+		MediaItemID: &mediaItemID,
+		DownloadID:  search.DownloadID,
+		Title:       title,
+		Data:        data,
+	})
+}
+
+// searchAndGrab performs the search for a media item and fills in the history record
+func (a *AutoSearcher) searchAndGrab(ctx context.Context, rule *MonitoringRule, mediaItemID int64, history *SearchHistory) error {
+	media, err := a.queries.GetMediaItem(ctx, mediaItemID)
+	if err != nil {
+		return fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	req := a.searchRequest(ctx, rule, media)
+	query := describeSearchRequest(req)
+	history.Query = &query
+
+	resp, err := a.indexerSvc.Search(ctx, req)
+	if err != nil {
+		return fmt.Errorf("indexer search failed: %w", err)
+	}
+	history.ResultsFound = len(resp.Releases)
+
+	approved, rejections, err := a.evaluateReleases(ctx, rule, mediaItemID, history.SearchType, resp.Releases)
+	if err != nil {
+		return err
+	}
+	history.ResultsApproved = len(approved)
+	history.RecordRejections(rejections)
+
+	if len(approved) == 0 {
+		return nil
+	}
+
+	winner := approved[0]
+	download, err := a.grabRelease(ctx, &media, winner, autoSearchGrabSource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to grab release: %w", err)
+	}
+
+	history.DownloadGrabbed = true
+	history.DownloadID = &download.ID
+	history.RecordGrabbedRelease(winner)
+
+	a.logger.Info("Grabbed release",
+		zap.Int64("media_item_id", mediaItemID),
+		zap.String("release", winner.Release.Title),
+		zap.Int("score", winner.Score.Total),
+		zap.String("download_id", download.ID))
+
+	return nil
+}
+
+// evaluateReleases drops blocklisted releases and releases the quality profile,
+// size and age limits or seeder requirement do not allow, and returns the rest
+// ranked best first together with the rejection counts
+func (a *AutoSearcher) evaluateReleases(ctx context.Context, rule *MonitoringRule, mediaItemID int64, searchType SearchType, releases []plugins.IndexerRelease) ([]ScoredRelease, Rejections, error) {
+	scorer, err := a.qualitySvc.NewReleaseScorer(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load release scorer: %w", err)
+	}
+
+	evaluator, err := a.newReleaseEvaluator(ctx, rule, &mediaItemID, searchType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var approved []ScoredRelease
+	rejections := Rejections{}
+	for _, candidate := range RankReleases(scorer, releases) {
+		reasons, err := evaluator.reasons(ctx, candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(reasons) > 0 {
+			rejections[reasons[0]]++
+			continue
+		}
+
+		approved = append(approved, candidate)
+	}
+
+	return approved, rejections, nil
+}
+
+// allowedQualities returns the quality definition IDs the rule's profile accepts,
+// or nil when every quality is acceptable
+func (a *AutoSearcher) allowedQualities(ctx context.Context, rule *MonitoringRule) (map[int]bool, error) {
+	if rule == nil || rule.QualityProfile == nil {
+		return nil, nil
+	}
+
+	profile, err := a.qualitySvc.GetQualityProfile(ctx, *rule.QualityProfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quality profile: %w", err)
+	}
+	if len(profile.Items) == 0 {
+		return nil, nil
+	}
+
+	allowed := make(map[int]bool, len(profile.Items))
+	for _, item := range profile.Items {
+		if item.Allowed {
+			allowed[item.QualityID] = true
+		}
+	}
+
+	return allowed, nil
+}
+
+// grabRelease hands a release to the matching downloader plugin. media is nil
+// for releases grabbed without a media item. wanted lists the missing episodes
+// a season pack is grabbed for, and is nil for any other release.
+func (a *AutoSearcher) grabRelease(ctx context.Context, media *generated.MediaItem, release ScoredRelease, grabbedBy string, wanted []plugins.WantedEpisode) (*downloader.Download, error) {
+	pluginID, err := a.selectDownloader(ctx, media, release.Release)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"indexer_id":    release.Release.IndexerID,
+		"indexer_name":  release.Release.IndexerName,
+		"size":          release.Release.Size,
+		"release_guid":  release.Release.GUID,
+		"release_score": release.Score.Total,
+		"grabbed_by":    grabbedBy,
+		"release_title": release.Release.Title,
+	}
+	if release.Score.ReleaseGroup != "" {
+		metadata["release_group"] = release.Score.ReleaseGroup
+	}
+	if media != nil {
+		metadata["media_id"] = media.ID
+		metadata["media_title"] = media.Title
+		metadata["media_kind"] = media.Kind
+	}
+	if release.Score.Quality != nil {
+		metadata["quality"] = release.Score.Quality.Name
+	}
+	if len(wanted) > 0 {
+		// Kept with the download, so it is added again for the same
+		// episodes after a restart
+		metadata["wanted_episodes"] = wanted
+	}
+
+	// When the release can't be downloaded, like an NZB that is gone, the
+	// same release is grabbed from the other indexers carrying it
+	var download *downloader.Download
+	for i, source := range releaseSources(release.Release) {
+		if i > 0 {
+			a.logger.Warn("Grab failed, trying the release on another indexer",
+				zap.String("release", source.Title),
+				zap.String("indexer", source.IndexerName),
+				zap.Error(err))
+		}
+		metadata["indexer_id"] = source.IndexerID
+		metadata["indexer_name"] = source.IndexerName
+		metadata["release_guid"] = source.GUID
+
+		download, err = a.downloaderSvc.CreateDownload(ctx, downloader.DownloadRequest{
+			PluginID:       pluginID,
+			Name:           source.Title,
+			URL:            source.DownloadURL,
+			Metadata:       metadata,
+			WantedEpisodes: wanted,
+		})
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if media != nil {
+		a.notifyIfUpgrade(ctx, media, release, download)
+	}
+	return download, nil
+}
+
+// notifyIfUpgrade sends an upgrade notification when the grabbed release
+// replaces files the media item already has
+func (a *AutoSearcher) notifyIfUpgrade(ctx context.Context, media *generated.MediaItem, release ScoredRelease, download *downloader.Download) {
+	notifier := a.downloaderSvc.Notifier()
+	if notifier == nil {
+		return
+	}
+
+	files, err := a.queries.ListMediaFilesByItem(ctx, &media.ID)
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	data := map[string]interface{}{
+		"download_id":   download.ID,
+		"media_item_id": media.ID,
+		"media_title":   media.Title,
+		"release":       release.Release.Title,
+		"indexer_name":  release.Release.IndexerName,
+		"release_score": release.Score.Total,
+	}
+	if release.Score.Quality != nil {
+		data["quality"] = release.Score.Quality.Name
+	}
+	notifier.Notify(notifications.NewEvent(notifications.EventUpgradeGrabbed, "Upgrade grabbed",
+		fmt.Sprintf("%s: %s", media.Title, release.Release.Title), data))
+}
+
+// selectDownloader picks the downloader plugin for a release's protocol,
+// which must share a tag with the media when it has tags
+func (a *AutoSearcher) selectDownloader(ctx context.Context, media *generated.MediaItem, release plugins.IndexerRelease) (string, error) {
+	preferred := usenetDownloaderID
+	if isTorrentRelease(release) {
+		preferred = torrentDownloaderID
+	}
+
+	for _, d := range a.downloaderSvc.ListDownloaders() {
+		if d.ID != preferred {
+			continue
+		}
+		if err := a.checkDownloaderTags(ctx, media, d.ID); err != nil {
+			return "", err
+		}
+		return d.ID, nil
+	}
+
+	return "", fmt.Errorf("downloader plugin %s is not available", preferred)
+}
+
+// checkDownloaderTags returns an error when a downloader has tags the media
+// doesn't share. Releases grabbed without a media item may use any downloader.
+func (a *AutoSearcher) checkDownloaderTags(ctx context.Context, media *generated.MediaItem, pluginID string) error {
+	if a.tags == nil || media == nil {
+		return nil
+	}
+
+	pluginTags, err := a.tags.PluginTags(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up downloader tags: %w", err)
+	}
+	if len(pluginTags[pluginID]) == 0 {
+		return nil
+	}
+	mediaTags, err := a.tags.MediaTags(ctx, media.ID)
+	if err != nil {
+		return fmt.Errorf("failed to look up media tags: %w", err)
+	}
+	if !tags.Matches(pluginTags[pluginID], mediaTags) {
+		return fmt.Errorf("downloader plugin %s shares no tag with %q", pluginID, media.Title)
+	}
+	return nil
+}
+
+// isTorrentRelease reports whether a release is a torrent rather than an NZB
+func isTorrentRelease(release plugins.IndexerRelease) bool {
+	if protocol := release.Attributes["protocol"]; protocol != "" {
+		return protocol == "torrent"
+	}
+	url := strings.ToLower(release.DownloadURL)
+	return strings.HasPrefix(url, "magnet:") || strings.HasSuffix(url, ".torrent")
+}
+
+// searchRequest builds the indexer search for a media item under a rule
+func (a *AutoSearcher) searchRequest(ctx context.Context, rule *MonitoringRule, media generated.MediaItem) indexer.SearchRequest {
+	req := indexer.BuildMediaSearchRequest(ctx, a.queries, media, a.logger)
+	if rule != nil {
+		req.MinimumSeeders = rule.MinimumSeeders
+		if rule.SeriesType == SeriesTypeAnime {
+			a.applyAnimeSearch(ctx, &req, media)
+		}
+	}
+	return req
+}
+
+// applyAnimeSearch searches anime in the anime category, and its episodes by
+// title and absolute episode number as anime releases are named. Episodes
+// without an absolute number keep the season and episode search.
+func (a *AutoSearcher) applyAnimeSearch(ctx context.Context, req *indexer.SearchRequest, media generated.MediaItem) {
+	req.Categories = []string{animeCategory}
+	if media.Kind != "tv_episode" {
+		return
+	}
+
+	absolute, err := library.AbsoluteEpisodeNumber(ctx, a.queries, media)
+	if err != nil {
+		a.logger.Warn("Failed to look up absolute episode number", zap.Int64("media_item_id", media.ID), zap.Error(err))
+		return
+	}
+	if absolute == 0 {
+		return
+	}
+	req.AbsoluteEpisode = absolute
+	req.Season, req.Episode = 0, 0
+	req.TVDBID = ""
+}
+
+// describeSearchRequest renders a search request for the search history
+func describeSearchRequest(req indexer.SearchRequest) string {
+	parts := []string{req.Query}
+	if req.AbsoluteEpisode > 0 {
+		parts = append(parts, fmt.Sprintf("%02d", req.AbsoluteEpisode))
+	}
+	if req.Season > 0 {
+		if req.Episode > 0 {
+			parts = append(parts, fmt.Sprintf("S%02dE%02d", req.Season, req.Episode))
+		} else {
+			parts = append(parts, fmt.Sprintf("S%02d", req.Season))
+		}
+	}
+	if req.TVDBID != "" {
+		parts = append(parts, "tvdb:"+req.TVDBID)
+	}
+	if req.IMDBID != "" {
+		parts = append(parts, "imdb:"+req.IMDBID)
+	}
+	if req.TMDBID != "" {
+		parts = append(parts, "tmdb:"+req.TMDBID)
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
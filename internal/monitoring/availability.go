@@ -0,0 +1,60 @@
+package monitoring
+
+import (
+	"context"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+)
+
+// Movie availability preferences: the release a monitored movie is searched
+// from
+const (
+	MovieAvailabilityCinema   = "cinema"
+	MovieAvailabilityDigital  = "digital"
+	MovieAvailabilityPhysical = "physical"
+)
+
+const (
+	defaultMovieAvailability = MovieAvailabilityDigital
+	defaultPreReleaseDays    = 1
+)
+
+// SetConfigStore sets where the movie availability settings are read from.
+// Without it the defaults apply.
+func (s *Service) SetConfigStore(config *configstore.Store) {
+	s.config = config
+}
+
+// movieAvailability returns the release monitored movies are searched from
+// and how many days before it they are searched
+func (s *Service) movieAvailability(ctx context.Context) (string, int) {
+	if s.config == nil {
+		return defaultMovieAvailability, defaultPreReleaseDays
+	}
+	preference := s.config.GetOrDefault(ctx, "monitoring.movie_availability", defaultMovieAvailability)
+	days := s.config.GetIntOrDefault(ctx, "monitoring.movie_prerelease_days", defaultPreReleaseDays)
+	if days < 0 {
+		days = 0
+	}
+	return preference, days
+}
+
+// movieAvailableSQL is the day a movie becomes available for a preference,
+// from the metadata of its media item aliased mi. A cinema preference takes
+// the earliest release of any kind, the others their own release, falling
+// back to the next one known. It is NULL for movies without release dates.
+func movieAvailableSQL(preference string) string {
+	day := func(kind string) string {
+		return "metadata_date(mi.metadata #>> '{release_dates," + kind + "}')"
+	}
+	released := "metadata_date(mi.metadata ->> 'release_date')"
+
+	switch preference {
+	case MovieAvailabilityCinema:
+		return "LEAST(" + day("cinema") + ", " + day("digital") + ", " + day("physical") + ", " + released + ")"
+	case MovieAvailabilityPhysical:
+		return "COALESCE(" + day("physical") + ", " + day("digital") + ", " + day("cinema") + ", " + released + ")"
+	default:
+		return "COALESCE(LEAST(" + day("digital") + ", " + day("physical") + "), " + day("cinema") + ", " + released + ")"
+	}
+}
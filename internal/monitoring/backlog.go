@@ -0,0 +1,208 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultBacklogInterval is the minimum delay between two backlog searches being started
+const defaultBacklogInterval = 2 * time.Second
+
+// BacklogStatus reports the progress of a backlog search
+type BacklogStatus struct {
+	Running     bool       `json:"running"`
+	Cancelled   bool       `json:"cancelled"`
+	MediaItemID *int64     `json:"media_item_id,omitempty"`
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Grabbed     int        `json:"grabbed"`
+	NotFound    int        `json:"not_found"`
+	Failed      int        `json:"failed"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// BacklogEpisode is a missing episode queued for a backlog search
+type BacklogEpisode struct {
+	MediaItemID int64
+	RuleID      *int64
+}
+
+// BacklogSearcher sweeps all missing episodes, searching for each one through a
+// rate-limited worker pool. Only one backlog search runs at a time.
+type BacklogSearcher struct {
+	searcher *AutoSearcher
+	logger   *zap.Logger
+	interval time.Duration
+	workers  int
+
+	mu     sync.Mutex
+	status BacklogStatus
+	cancel context.CancelFunc
+}
+
+// NewBacklogSearcher creates a backlog searcher on top of an automatic searcher
+func NewBacklogSearcher(searcher *AutoSearcher, logger *zap.Logger) *BacklogSearcher {
+	return &BacklogSearcher{
+		searcher: searcher,
+		logger:   logger.With(zap.String("component", "backlog-search")),
+		interval: defaultBacklogInterval,
+		workers:  cap(searcher.slots),
+	}
+}
+
+// Start starts a backlog search in the background, optionally scoped to a series,
+// season or episode
+func (b *BacklogSearcher) Start(mediaItemID *int64) (BacklogStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status.Running {
+		return b.status, fmt.Errorf("a backlog search is already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	b.cancel = cancel
+	b.status = BacklogStatus{
+		Running:     true,
+		MediaItemID: mediaItemID,
+		StartedAt:   &now,
+	}
+
+	go b.run(ctx, mediaItemID)
+
+	return b.status, nil
+}
+
+// Cancel stops a running backlog search, aborting the searches in flight
+func (b *BacklogSearcher) Cancel() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.status.Running || b.cancel == nil {
+		return false
+	}
+
+	b.status.Cancelled = true
+	b.cancel()
+	return true
+}
+
+// Status returns the progress of the current or last backlog search
+func (b *BacklogSearcher) Status() BacklogStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// run enumerates the missing episodes and feeds them to the workers
+func (b *BacklogSearcher) run(ctx context.Context, mediaItemID *int64) {
+	defer func() {
+		b.mu.Lock()
+		now := time.Now()
+		b.status.Running = false
+		b.status.FinishedAt = &now
+		b.cancel = nil
+		b.mu.Unlock()
+	}()
+
+	episodes, err := b.searcher.monitoringSvc.GetBacklogEpisodes(ctx, mediaItemID)
+	if err != nil {
+		b.logger.Error("Failed to enumerate backlog", zap.Error(err))
+		b.update(func(s *BacklogStatus) { s.Error = err.Error() })
+		return
+	}
+
+	b.update(func(s *BacklogStatus) { s.Total = len(episodes) })
+	b.logger.Info("Backlog search started", zap.Int("episodes", len(episodes)))
+
+	tasks := make(chan BacklogEpisode)
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for episode := range tasks {
+				b.searchEpisode(ctx, episode)
+			}
+		}()
+	}
+
+	limiter := time.NewTicker(b.interval)
+	defer limiter.Stop()
+
+dispatch:
+	for i, episode := range episodes {
+		if i > 0 {
+			select {
+			case <-limiter.C:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+
+		select {
+		case tasks <- episode:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	status := b.Status()
+	b.logger.Info("Backlog search finished",
+		zap.Int("processed", status.Processed),
+		zap.Int("grabbed", status.Grabbed),
+		zap.Int("failed", status.Failed),
+		zap.Bool("cancelled", status.Cancelled))
+}
+
+// searchEpisode searches for a single backlog episode and records the outcome
+func (b *BacklogSearcher) searchEpisode(ctx context.Context, episode BacklogEpisode) {
+	var rule *MonitoringRule
+	if episode.RuleID != nil {
+		r, err := b.searcher.monitoringSvc.GetMonitoringRule(ctx, *episode.RuleID)
+		if err != nil {
+			b.logger.Warn("Failed to load monitoring rule", zap.Int64("rule_id", *episode.RuleID), zap.Error(err))
+		} else {
+			rule = r
+		}
+	}
+
+	history, err := b.searcher.SearchMediaItem(ctx, rule, episode.MediaItemID, SearchTypeBacklog, TriggerSourceUser)
+	if ctx.Err() != nil {
+		// Cancelled; the episode was not really searched
+		return
+	}
+
+	grabbed := history != nil && history.DownloadGrabbed
+	if err := b.searcher.monitoringSvc.RecordEpisodeSearch(ctx, episode.MediaItemID, grabbed); err != nil {
+		b.logger.Warn("Failed to record episode search", zap.Int64("media_item_id", episode.MediaItemID), zap.Error(err))
+	}
+
+	b.update(func(s *BacklogStatus) {
+		s.Processed++
+		switch {
+		case err != nil:
+			s.Failed++
+		case grabbed:
+			s.Grabbed++
+		default:
+			s.NotFound++
+		}
+	})
+}
+
+// update applies a change to the status under the lock
+func (b *BacklogSearcher) update(fn func(s *BacklogStatus)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fn(&b.status)
+}
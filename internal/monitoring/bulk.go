@@ -0,0 +1,280 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ruleSelectionQuery selects the rules of a RuleSelection with their media
+// item titles. $1 are the rule IDs, $2 the media kind, $3 the tag and $4 the
+// template the rules were created from, each matching any rule when empty.
+const ruleSelectionQuery = `
+	SELECT r.id, r.media_item_id, m.title
+	FROM monitoring_rules r
+	JOIN media_items m ON m.id = r.media_item_id
+	WHERE ($1::bigint[] IS NULL OR r.id = ANY($1::bigint[]))
+	  AND ($2::text = '' OR m.kind = $2::text)
+	  AND ($3::text = '' OR r.tags @> ARRAY[$3::text])
+	  AND ($4::bigint IS NULL OR r.template_id = $4::bigint)
+	ORDER BY r.id
+`
+
+// selectedRule is a rule picked by a RuleSelection
+type selectedRule struct {
+	ID          int64
+	MediaItemID int64
+	Title       string
+}
+
+// ruleQuerier is a pool or a transaction
+type ruleQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// selectRules returns the rules a selection picks. With lock, the rules stay
+// locked until the transaction ends.
+func selectRules(ctx context.Context, q ruleQuerier, selection RuleSelection, lock bool) ([]selectedRule, error) {
+	query := ruleSelectionQuery
+	if lock {
+		query += " FOR UPDATE OF r"
+	}
+
+	var ids []int64
+	if len(selection.RuleIDs) > 0 {
+		ids = selection.RuleIDs
+	}
+	rows, err := q.Query(ctx, query, ids, selection.Kind, selection.Tag, selection.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select monitoring rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []selectedRule
+	for rows.Next() {
+		var rule selectedRule
+		if err := rows.Scan(&rule.ID, &rule.MediaItemID, &rule.Title); err != nil {
+			return nil, fmt.Errorf("failed to scan monitoring rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// bulkResults builds the per-rule results of a bulk operation: one with status
+// for each selected rule, followed by one for each listed ID that wasn't selected
+func bulkResults(selection RuleSelection, rules []selectedRule, status BulkRuleStatus) []BulkRuleResult {
+	results := make([]BulkRuleResult, 0, len(rules))
+	found := make(map[int64]bool, len(rules))
+	for _, rule := range rules {
+		mediaItemID := rule.MediaItemID
+		results = append(results, BulkRuleResult{
+			RuleID:      rule.ID,
+			MediaItemID: &mediaItemID,
+			Title:       rule.Title,
+			Status:      status,
+		})
+		found[rule.ID] = true
+	}
+	for _, id := range selection.RuleIDs {
+		if !found[id] {
+			results = append(results, BulkRuleResult{RuleID: id, Status: BulkRuleNotFound})
+			found[id] = true
+		}
+	}
+	return results
+}
+
+// SelectMonitoringRules returns the results a bulk operation on a selection
+// would have, with the selected rules having the given status
+func (s *Service) SelectMonitoringRules(ctx context.Context, selection RuleSelection, status BulkRuleStatus) ([]BulkRuleResult, error) {
+	rules, err := selectRules(ctx, s.db, selection, false)
+	if err != nil {
+		return nil, err
+	}
+	return bulkResults(selection, rules, status), nil
+}
+
+// BulkUpdateMonitoringRules applies the same changes to all selected rules in
+// one transaction. Either every selected rule is updated or none is.
+func (s *Service) BulkUpdateMonitoringRules(ctx context.Context, selection RuleSelection, params BulkUpdateMonitoringRuleParams) ([]BulkRuleResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rules, err := selectRules(ctx, tx, selection, true)
+	if err != nil {
+		return nil, err
+	}
+	results := bulkResults(selection, rules, BulkRuleUpdated)
+	if len(rules) == 0 {
+		return results, nil
+	}
+
+	ids := make([]int64, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+	}
+	addTags, removeTags := params.AddTags, params.RemoveTags
+	if addTags == nil {
+		addTags = []string{}
+	}
+	if removeTags == nil {
+		removeTags = []string{}
+	}
+
+	// Tags are only rewritten when some are added or removed, so the order of
+	// untouched tag lists is kept
+	query := `
+		UPDATE monitoring_rules
+		SET enabled = COALESCE($1, enabled),
+		    quality_profile_id = COALESCE($2, quality_profile_id),
+		    monitor_mode = COALESCE($3, monitor_mode),
+		    search_interval_minutes = COALESCE($4, search_interval_minutes),
+		    automatic_search = COALESCE($8, automatic_search),
+		    backlog_search = COALESCE($9, backlog_search),
+		    search_upgrades = COALESCE($10, search_upgrades),
+		    prefer_season_packs = COALESCE($11, prefer_season_packs),
+		    minimum_seeders = COALESCE($12, minimum_seeders),
+		    tags = CASE
+		        WHEN cardinality($5::text[]) = 0 AND cardinality($6::text[]) = 0 THEN tags
+		        ELSE ARRAY(
+		            SELECT DISTINCT t
+		            FROM unnest(COALESCE(tags, '{}') || $5::text[]) AS t
+		            WHERE NOT t = ANY($6::text[])
+		            ORDER BY t
+		        )
+		    END
+		WHERE id = ANY($7)
+		RETURNING id, media_item_id, enabled, quality_profile_id, monitor_mode,
+		          search_on_add, automatic_search, backlog_search,
+		          prefer_season_packs, minimum_seeders, tags,
+		          search_interval_minutes, last_search_at, next_search_at,
+		          search_count, items_found_count, items_grabbed_count,
+		          created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		          template_id
+	`
+
+	rows, err := tx.Query(ctx, query,
+		params.Enabled, params.QualityProfileID, params.MonitorMode,
+		params.SearchIntervalMinutes, addTags, removeTags, ids,
+		params.AutomaticSearch, params.BacklogSearch, params.SearchUpgrades,
+		params.PreferSeasonPacks, params.MinimumSeeders,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update monitoring rules: %w", err)
+	}
+	updated := make(map[int64]*MonitoringRule, len(rules))
+	for rows.Next() {
+		var rule MonitoringRule
+		err := rows.Scan(
+			&rule.ID, &rule.MediaItemID, &rule.Enabled, &rule.QualityProfile, &rule.MonitorMode,
+			&rule.SearchOnAdd, &rule.AutomaticSearch, &rule.BacklogSearch,
+			&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
+			&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
+			&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
+			&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+			&rule.TemplateID,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan monitoring rule: %w", err)
+		}
+		updated[rule.ID] = &rule
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to update monitoring rules: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if params.MonitorMode != nil {
+		for _, rule := range updated {
+			if err := s.ApplyMonitorMode(ctx, rule); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].Rule = updated[results[i].RuleID]
+	}
+	return results, nil
+}
+
+// BulkDeleteMonitoringRules deletes all selected rules in one transaction
+func (s *Service) BulkDeleteMonitoringRules(ctx context.Context, selection RuleSelection) ([]BulkRuleResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rules, err := selectRules(ctx, tx, selection, true)
+	if err != nil {
+		return nil, err
+	}
+	results := bulkResults(selection, rules, BulkRuleDeleted)
+	if len(rules) == 0 {
+		return results, nil
+	}
+
+	ids := make([]int64, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM monitoring_rules WHERE id = ANY($1)`, ids); err != nil {
+		return nil, fmt.Errorf("failed to delete monitoring rules: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+// QualityProfileExists reports whether a quality profile exists
+func (s *Service) QualityProfileExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM quality_profiles WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up quality profile: %w", err)
+	}
+	return exists, nil
+}
+
+// SearchRules searches for the rules with the given IDs in the background, as
+// if each had been searched manually. Rules are searched regardless of whether
+// they are enabled.
+func (a *AutoSearcher) SearchRules(ruleIDs []int64) {
+	go func() {
+		ctx := context.Background()
+		var wg sync.WaitGroup
+		for _, id := range ruleIDs {
+			rule, err := a.monitoringSvc.GetMonitoringRule(ctx, id)
+			if err != nil {
+				a.logger.Warn("Monitoring rule to search not found", zap.Int64("rule_id", id), zap.Error(err))
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := a.SearchRule(ctx, rule, SearchTypeManual, TriggerSourceUser); err != nil {
+					a.logger.Warn("Search for monitoring rule failed",
+						zap.Int64("rule_id", rule.ID),
+						zap.Int64("media_item_id", rule.MediaItemID),
+						zap.Error(err))
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+}
@@ -0,0 +1,62 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestBulkResults(t *testing.T) {
+	selection := RuleSelection{RuleIDs: []int64{3, 1, 9, 9}}
+	rules := []selectedRule{{ID: 1, MediaItemID: 10, Title: "Alpha"}, {ID: 3, MediaItemID: 30, Title: "Gamma"}}
+
+	results := bulkResults(selection, rules, BulkRuleUpdated)
+
+	want := []struct {
+		id     int64
+		status BulkRuleStatus
+	}{{1, BulkRuleUpdated}, {3, BulkRuleUpdated}, {9, BulkRuleNotFound}}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		if results[i].RuleID != w.id || results[i].Status != w.status {
+			t.Errorf("result %d = %d %s, want %d %s", i, results[i].RuleID, results[i].Status, w.id, w.status)
+		}
+	}
+	if results[0].MediaItemID == nil || *results[0].MediaItemID != 10 {
+		t.Errorf("media item of rule 1 = %v, want 10", results[0].MediaItemID)
+	}
+}
+
+func TestBulkUpdateValidation(t *testing.T) {
+	h := NewHandler(nil, nil, zap.NewNop())
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no selection", `{"update":{"enabled":false}}`, "rule_ids, kind or tag is required"},
+		{"no changes", `{"rule_ids":[1],"update":{}}`, "update must change at least one field"},
+		{"unknown monitor mode", `{"kind":"tv_series","update":{"monitor_mode":"sometimes"}}`, "Unknown monitor mode"},
+		{"zero interval", `{"tag":"kids","update":{"search_interval_minutes":0}}`, "search_interval_minutes must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/monitoring/rules/bulk", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.BulkUpdateMonitoringRules(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+const (
+	// tmdbPluginID is the metadata plugin episode air dates are fetched from
+	tmdbPluginID = "tmdb-plugin"
+
+	defaultCalendarDaysAhead  = 30
+	defaultCalendarDaysBehind = 7
+)
+
+// CalendarSync fills the calendar with episode air dates of monitored series and
+// release dates of movies
+type CalendarSync struct {
+	monitoringSvc *Service
+	pluginManager *plugins.PluginManager
+	logger        *zap.Logger
+}
+
+// NewCalendarSync creates a calendar sync. Without a plugin manager only movie
+// events are produced, since episode air dates come from the TMDB plugin.
+func NewCalendarSync(monitoringSvc *Service, pluginManager *plugins.PluginManager, logger *zap.Logger) *CalendarSync {
+	return &CalendarSync{
+		monitoringSvc: monitoringSvc,
+		pluginManager: pluginManager,
+		logger:        logger.With(zap.String("component", "calendar-sync")),
+	}
+}
+
+// HandleJob runs the sync as the calendar_update scheduler job
+func (c *CalendarSync) HandleJob(ctx context.Context, job *SchedulerJob) error {
+	daysAhead, daysBehind := defaultCalendarDaysAhead, defaultCalendarDaysBehind
+	if val, ok := job.Config["days_ahead"].(float64); ok {
+		daysAhead = int(val)
+	}
+	if val, ok := job.Config["days_behind"].(float64); ok {
+		daysBehind = int(val)
+	}
+
+	return c.Sync(ctx, daysBehind, daysAhead)
+}
+
+// Sync creates or updates the calendar events within the given window around today
+func (c *CalendarSync) Sync(ctx context.Context, daysBehind, daysAhead int) error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -daysBehind)
+	end := today.AddDate(0, 0, daysAhead)
+
+	movies, err := c.syncMovies(ctx, start, end)
+	if err != nil {
+		return err
+	}
+
+	episodes := 0
+	if c.pluginManager != nil {
+		episodes, err = c.syncSeries(ctx, start, end)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.monitoringSvc.RefreshCalendarEventStatus(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("Calendar synced", zap.Int("movie_events", movies), zap.Int("episode_events", episodes))
+	return nil
+}
+
+// syncMovies creates digital and physical release events from movie metadata.
+// The generic release_date is used as the digital release when the metadata has
+// no specific release dates.
+func (c *CalendarSync) syncMovies(ctx context.Context, start, end time.Time) (int, error) {
+	movies, err := c.monitoringSvc.ListCalendarMediaItems(ctx, "movie", false)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, movie := range movies {
+		dates := map[EventType]string{
+			EventTypeDigitalRelease:  metadataString(movie.Metadata, "digital_release_date"),
+			EventTypePhysicalRelease: metadataString(movie.Metadata, "physical_release_date"),
+		}
+		if dates[EventTypeDigitalRelease] == "" && dates[EventTypePhysicalRelease] == "" {
+			dates[EventTypeDigitalRelease] = metadataString(movie.Metadata, "release_date")
+		}
+
+		for eventType, value := range dates {
+			date, ok := parseEventDate(value)
+			if !ok || date.Before(start) || date.After(end) {
+				continue
+			}
+
+			err := c.monitoringSvc.UpsertCalendarEvent(ctx, UpsertCalendarEventParams{
+				MediaItemID: movie.ID,
+				EventType:   eventType,
+				EventDate:   date,
+				Monitored:   movie.Monitored,
+				Title:       movie.Title,
+				Metadata:    map[string]interface{}{"media_kind": "movie"},
+			})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// syncSeries creates episode air events for every monitored series with a TMDB ID
+func (c *CalendarSync) syncSeries(ctx context.Context, start, end time.Time) (int, error) {
+	series, err := c.monitoringSvc.ListCalendarMediaItems(ctx, "tv_series", true)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, show := range series {
+		tmdbID := metadataString(show.ExternalIDs, "tmdb_id")
+		if tmdbID == "" {
+			tmdbID = metadataString(show.Metadata, "tmdb_id")
+		}
+		if tmdbID == "" {
+			continue
+		}
+
+		n, err := c.syncShow(ctx, show, tmdbID, start, end)
+		if err != nil {
+			// One broken show should not keep the others off the calendar
+			c.logger.Warn("Failed to sync series calendar",
+				zap.Int64("media_item_id", show.ID), zap.String("tmdb_id", tmdbID), zap.Error(err))
+			continue
+		}
+		count += n
+	}
+
+	return count, nil
+}
+
+// tmdbSeason is the part of a TMDB season the calendar needs
+type tmdbSeason struct {
+	SeasonNumber int    `json:"season_number"`
+	AirDate      string `json:"air_date"`
+	Episodes     []struct {
+		EpisodeNumber int    `json:"episode_number"`
+		SeasonNumber  int    `json:"season_number"`
+		Name          string `json:"name"`
+		Overview      string `json:"overview"`
+		AirDate       string `json:"air_date"`
+		Runtime       int    `json:"runtime"`
+	} `json:"episodes"`
+}
+
+// syncShow creates the events for the current and upcoming seasons of a series
+func (c *CalendarSync) syncShow(ctx context.Context, show CalendarMediaItem, tmdbID string, start, end time.Time) (int, error) {
+	var details struct {
+		Seasons          []tmdbSeason `json:"seasons"`
+		LastEpisodeToAir *struct {
+			SeasonNumber int `json:"season_number"`
+		} `json:"last_episode_to_air"`
+	}
+	if err := c.tmdbGet(ctx, fmt.Sprintf("/api/plugins/tmdb/tv/%s", tmdbID), &details); err != nil {
+		return 0, err
+	}
+
+	currentSeason := 1
+	if details.LastEpisodeToAir != nil && details.LastEpisodeToAir.SeasonNumber > 0 {
+		currentSeason = details.LastEpisodeToAir.SeasonNumber
+	}
+
+	count := 0
+	for _, summary := range details.Seasons {
+		// Specials and seasons that ended before the current one cannot fall in the window
+		if summary.SeasonNumber < currentSeason {
+			continue
+		}
+		if date, ok := parseEventDate(summary.AirDate); ok && date.After(end) {
+			continue
+		}
+
+		var season tmdbSeason
+		path := fmt.Sprintf("/api/plugins/tmdb/tv/%s/season/%d", tmdbID, summary.SeasonNumber)
+		if err := c.tmdbGet(ctx, path, &season); err != nil {
+			return count, err
+		}
+
+		for _, ep := range season.Episodes {
+			date, ok := parseEventDate(ep.AirDate)
+			if !ok || date.Before(start) || date.After(end) {
+				continue
+			}
+
+			if err := c.upsertEpisodeEvent(ctx, show, summary.SeasonNumber, ep.EpisodeNumber, ep.Name, ep.Overview, ep.Runtime, date); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// upsertEpisodeEvent stores an episode air event on the episode when the library
+// already has it, otherwise on the series until the episode shows up
+func (c *CalendarSync) upsertEpisodeEvent(ctx context.Context, show CalendarMediaItem, season, episode int, name, overview string, runtime int, date time.Time) error {
+	key := fmt.Sprintf("S%02dE%02d", season, episode)
+	if name == "" {
+		name = fmt.Sprintf("Episode %d", episode)
+	}
+
+	params := UpsertCalendarEventParams{
+		MediaItemID: show.ID,
+		EventType:   EventTypeEpisodeAir,
+		EventKey:    key,
+		EventDate:   date,
+		Monitored:   show.Monitored,
+		Title:       name,
+		ParentTitle: &show.Title,
+		Metadata: map[string]interface{}{
+			"media_kind":     "tv_episode",
+			"series_id":      show.ID,
+			"season_number":  season,
+			"episode_number": episode,
+			"overview":       overview,
+			"runtime":        runtime,
+		},
+	}
+
+	episodeID, monitored, err := c.monitoringSvc.FindEpisode(ctx, show.ID, season, episode)
+	if err != nil {
+		return err
+	}
+	if episodeID != nil {
+		// Drop the placeholder kept on the series before the episode existed
+		if err := c.monitoringSvc.DeleteCalendarEvent(ctx, show.ID, EventTypeEpisodeAir, key); err != nil {
+			return err
+		}
+		params.MediaItemID = *episodeID
+		params.Monitored = show.Monitored && monitored
+	}
+
+	return c.monitoringSvc.UpsertCalendarEvent(ctx, params)
+}
+
+// tmdbGet calls a TMDB plugin route over RPC and decodes the JSON response
+func (c *CalendarSync) tmdbGet(ctx context.Context, path string, out interface{}) error {
+	plugin, ok := c.pluginManager.GetPlugin(tmdbPluginID)
+	if !ok {
+		return fmt.Errorf("plugin %s not found", tmdbPluginID)
+	}
+
+	resp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  "GET",
+		Path:    path,
+		Query:   map[string][]string{},
+		Headers: map[string][]string{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to call plugin: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(resp.Body))
+	}
+
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("failed to decode plugin response: %w", err)
+	}
+
+	return nil
+}
+
+// metadataString reads a string or numeric value from a JSON object
+func metadataString(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		if v > 0 {
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
+
+// parseEventDate parses a YYYY-MM-DD date
+func parseEventDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
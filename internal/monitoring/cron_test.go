@@ -0,0 +1,52 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, 6, 3, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 6, 3, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 6, 3, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 6, 4, 3, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2026, 6, 7, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2026, 6, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 6, 3, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 6, 5, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	never, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("Next(30 February) = %v, want zero", got)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/downloader"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+// defaultBlocklistExpiry is how long the release of a failed download stays blocked
+const defaultBlocklistExpiry = 7 * 24 * time.Hour
+
+// SetBlocklistExpiry sets how long releases of failed downloads stay blocked
+func (a *AutoSearcher) SetBlocklistExpiry(expiry time.Duration) {
+	if expiry > 0 {
+		a.blocklistExpiry = expiry
+	}
+}
+
+// HandleFailedDownload blocklists the release of a failed download grabbed by
+// the automatic search or RSS sync and immediately searches for a replacement.
+// It is registered with the downloader service as a failed download handler.
+func (a *AutoSearcher) HandleFailedDownload(ctx context.Context, download *downloader.Download) {
+	if source, _ := download.Metadata["grabbed_by"].(string); source != autoSearchGrabSource && source != rssGrabSource {
+		return
+	}
+	params, ok := downloadBlocklistParams(download, BlockReasonFailedDownload)
+	if !ok {
+		return
+	}
+	mediaItemID := *params.MediaItemID
+	if download.ErrorMessage != "" {
+		params.Message = &download.ErrorMessage
+	}
+	expiresAt := time.Now().Add(a.blocklistExpiry)
+	params.ExpiresAt = &expiresAt
+
+	if _, err := a.monitoringSvc.CreateBlocklistEntry(ctx, params); err != nil {
+		a.logger.Error("Failed to blocklist failed download",
+			zap.String("download_id", download.ID), zap.Error(err))
+		return
+	}
+
+	a.logger.Info("Blocklisted failed download",
+		zap.String("download_id", download.ID),
+		zap.String("release", download.Name),
+		zap.Int64("media_item_id", mediaItemID),
+		zap.String("error", download.ErrorMessage))
+
+	rule, err := a.monitoringSvc.GetEffectiveMonitoringRule(ctx, mediaItemID)
+	if err != nil {
+		a.logger.Error("Failed to get monitoring rule for replacement search",
+			zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+		return
+	}
+	if rule != nil && !rule.Enabled {
+		return
+	}
+
+	// The blocklisted release is skipped by the search, so this picks the next best one
+	found, grabbed, err := a.searchWanted(ctx, rule, mediaItemID, SearchTypeAutomatic, TriggerSourceFailed)
+	if err != nil {
+		a.logger.Warn("Replacement search failed", zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+		return
+	}
+
+	a.logger.Info("Replacement search finished",
+		zap.Int64("media_item_id", mediaItemID),
+		zap.Int("approved", found),
+		zap.Int("grabbed", grabbed))
+}
+
+// BlocklistDownload permanently blocklists the release of a download for its
+// media item, e.g. when a user removes it. It returns nil when the download has
+// no media item or nothing identifying its release.
+func (s *Service) BlocklistDownload(ctx context.Context, download *downloader.Download) (*BlocklistEntry, error) {
+	guid, _ := download.Metadata["release_guid"].(string)
+	params, ok := downloadBlocklistParams(download, BlockReasonManual)
+	if !ok || (guid == "" && download.URL == "") {
+		return nil, nil
+	}
+	params.Permanent = true
+	return s.CreateBlocklistEntry(ctx, params)
+}
+
+// downloadBlocklistParams returns the blocklist entry of a download's release,
+// identified like search results by the GUID or URL the download was grabbed
+// with. It reports false when the download has no media item.
+func downloadBlocklistParams(download *downloader.Download, reason BlockReason) (CreateBlocklistEntryParams, bool) {
+	release := plugins.IndexerRelease{
+		Title:       download.Name,
+		DownloadURL: download.URL,
+	}
+	release.GUID, _ = download.Metadata["release_guid"].(string)
+	release.IndexerID, _ = download.Metadata["indexer_id"].(string)
+	if download.MediaItemID == nil {
+		return CreateBlocklistEntryParams{}, false
+	}
+
+	params := CreateBlocklistEntryParams{
+		MediaItemID:  download.MediaItemID,
+		ReleaseHash:  ReleaseHash(release),
+		ReleaseTitle: download.Name,
+		Reason:       reason,
+		DownloadID:   &download.ID,
+	}
+	if release.IndexerID != "" {
+		params.IndexerID = &release.IndexerID
+	}
+	return params, true
+}
@@ -0,0 +1,156 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/downloader"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/jackc/pgx/v5"
+)
+
+// manualGrabSource marks downloads grabbed from search results in their metadata
+const manualGrabSource = "manual"
+
+var (
+	// ErrReleaseBlocked is returned when grabbing a blocklisted release
+	ErrReleaseBlocked = errors.New("release is blocklisted")
+
+	// ErrMediaItemNotFound is returned when grabbing for a media item that doesn't exist
+	ErrMediaItemNotFound = errors.New("media item not found")
+)
+
+// GrabRequest is a release picked from search results, optionally for a media item
+type GrabRequest struct {
+	Release     GrabRelease `json:"release"`
+	MediaItemID *int64      `json:"media_item_id,omitempty"`
+}
+
+// GrabRelease is the part of a search result needed to download it
+type GrabRelease struct {
+	GUID        string            `json:"guid"`
+	Title       string            `json:"title"`
+	DownloadURL string            `json:"download_url"`
+	MagnetURL   string            `json:"magnet_url,omitempty"`
+	Size        int64             `json:"size"`
+	Protocol    string            `json:"protocol,omitempty"` // "usenet" or "torrent"; guessed from the URL when empty
+	IndexerID   string            `json:"indexer_id"`
+	IndexerName string            `json:"indexer_name,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+
+	// Other indexers carrying the release, grabbed from in turn when the
+	// download URL fails
+	AlsoAvailableOn []plugins.ReleaseSource `json:"also_available_on,omitempty"`
+}
+
+// indexerRelease converts the release into the form the indexers return
+func (r GrabRelease) indexerRelease() plugins.IndexerRelease {
+	release := plugins.IndexerRelease{
+		GUID:            r.GUID,
+		Title:           r.Title,
+		DownloadURL:     r.DownloadURL,
+		Size:            r.Size,
+		IndexerID:       r.IndexerID,
+		IndexerName:     r.IndexerName,
+		Attributes:      map[string]string{},
+		AlsoAvailableOn: r.AlsoAvailableOn,
+	}
+	for k, v := range r.Attributes {
+		release.Attributes[k] = v
+	}
+	if release.DownloadURL == "" {
+		release.DownloadURL = r.MagnetURL
+	}
+	if r.Protocol != "" {
+		release.Attributes["protocol"] = r.Protocol
+	}
+	return release
+}
+
+// Grab downloads a release picked by a user from search results. Blocklisted
+// releases are refused with ErrReleaseBlocked. When the release is grabbed for
+// a media item, the grab is recorded in its search history.
+func (a *AutoSearcher) Grab(ctx context.Context, req GrabRequest, userID *int64) (*downloader.Download, error) {
+	if req.Release.Title == "" {
+		return nil, fmt.Errorf("release title is required")
+	}
+	if req.Release.DownloadURL == "" && req.Release.MagnetURL == "" {
+		return nil, fmt.Errorf("release download URL is required")
+	}
+
+	release := req.Release.indexerRelease()
+
+	blocked, err := a.monitoringSvc.IsBlocked(ctx, ReleaseHash(release), req.MediaItemID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrReleaseBlocked
+	}
+
+	var media *generated.MediaItem
+	if req.MediaItemID != nil {
+		item, err := a.queries.GetMediaItem(ctx, *req.MediaItemID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMediaItemNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get media item: %w", err)
+		}
+		media = &item
+	}
+
+	scorer, err := a.qualitySvc.NewReleaseScorer(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load release scorer: %w", err)
+	}
+	scored := ScoredRelease{
+		Release: release,
+		Score:   scorer.Score(release.Title, release.Attributes),
+	}
+
+	start := time.Now()
+	download, err := a.grabRelease(ctx, media, scored, manualGrabSource, nil)
+
+	// The search history is kept per media item, so only grabs for one are recorded
+	if media != nil {
+		history := newSearchHistory(nil, media.ID, SearchTypeManual, TriggerSourceManual)
+		history.Query = &release.Title
+		history.ResultsFound = 1
+		history.ResultsApproved = 1
+		history.CreatedByUser = userID
+		if err == nil {
+			history.DownloadGrabbed = true
+			history.DownloadID = &download.ID
+			history.RecordGrabbedRelease(scored)
+		}
+		a.finishSearchHistory(ctx, history, start, err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to grab release: %w", err)
+	}
+	return download, nil
+}
+
+// releaseSources returns a release followed by the same release on each of
+// the other indexers carrying it, to grab in turn until one downloads
+func releaseSources(release plugins.IndexerRelease) []plugins.IndexerRelease {
+	sources := []plugins.IndexerRelease{release}
+	for _, source := range release.AlsoAvailableOn {
+		if source.DownloadURL == "" {
+			continue
+		}
+		alternate := release
+		alternate.GUID = source.GUID
+		alternate.DownloadURL = source.DownloadURL
+		alternate.IndexerID = source.IndexerID
+		alternate.IndexerName = source.IndexerName
+		alternate.AlsoAvailableOn = nil
+		sources = append(sources, alternate)
+	}
+	return sources
+}
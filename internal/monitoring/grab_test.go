@@ -0,0 +1,41 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func TestReleaseSources(t *testing.T) {
+	var req GrabRequest
+	body := `{"release": {
+		"guid": "b1", "title": "Show.S01E01.1080p", "download_url": "https://beta/get/b1",
+		"indexer_id": "b", "indexer_name": "Beta", "size": 1000,
+		"also_available_on": [
+			{"indexer_id": "c", "indexer_name": "Gamma", "guid": "c1", "download_url": "https://gamma/get/c1"},
+			{"indexer_id": "d", "indexer_name": "Delta", "guid": "d1", "download_url": ""}
+		]
+	}}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := releaseSources(req.Release.indexerRelease())
+	if len(sources) != 2 {
+		t.Fatalf("sources = %+v, want the release and one alternate", sources)
+	}
+	if sources[0].DownloadURL != "https://beta/get/b1" || len(sources[0].AlsoAvailableOn) != 2 {
+		t.Errorf("first source = %+v, want the release itself", sources[0])
+	}
+	want := plugins.IndexerRelease{
+		GUID: "c1", Title: "Show.S01E01.1080p", DownloadURL: "https://gamma/get/c1",
+		IndexerID: "c", IndexerName: "Gamma", Size: 1000,
+	}
+	alternate := sources[1]
+	if alternate.GUID != want.GUID || alternate.Title != want.Title || alternate.DownloadURL != want.DownloadURL ||
+		alternate.IndexerID != want.IndexerID || alternate.IndexerName != want.IndexerName || alternate.Size != want.Size ||
+		alternate.AlsoAvailableOn != nil {
+		t.Errorf("alternate = %+v, want %+v", alternate, want)
+	}
+}
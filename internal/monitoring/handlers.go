@@ -1,12 +1,20 @@
 package monitoring
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/history"
 	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/tags"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -15,6 +23,11 @@ import (
 type Handler struct {
 	service   *Service
 	scheduler *Scheduler
+	backlog   *BacklogSearcher
+	searches  *ManualSearcher
+	config    *configstore.Store
+	history   *history.Service
+	tags      *tags.Service
 	logger    *zap.Logger
 }
 
@@ -27,28 +40,129 @@ func NewHandler(service *Service, scheduler *Scheduler, logger *zap.Logger) *Han
 	}
 }
 
+// SetBacklogSearcher enables the backlog search endpoints
+func (h *Handler) SetBacklogSearcher(backlog *BacklogSearcher) {
+	h.backlog = backlog
+}
+
+// SetManualSearcher enables searching a media item on demand
+func (h *Handler) SetManualSearcher(searches *ManualSearcher) {
+	h.searches = searches
+}
+
+// SetHistory sets where bulk changes to monitoring rules are recorded
+func (h *Handler) SetHistory(history *history.Service) {
+	h.history = history
+}
+
+// SetTags makes tags set on rules appear in the tag list
+func (h *Handler) SetTags(service *tags.Service) {
+	h.tags = service
+}
+
+// SetConfigStore enables the iCal feed, whose token is kept in the config store
+func (h *Handler) SetConfigStore(config *configstore.Store) {
+	h.config = config
+}
+
 // ========================
 // Monitoring Rules
 // ========================
 
-// CreateMonitoringRule creates a new monitoring rule
+// CreateMonitoringRule creates a new monitoring rule. Fields left out are
+// taken from the template picked with template_id or, without one, from the
+// monitoring defaults of the media item's kind.
 func (h *Handler) CreateMonitoringRule(w http.ResponseWriter, r *http.Request) {
-	var params CreateMonitoringRuleParams
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+	var body json.RawMessage
+	if err := httputil.DecodeJSON(r, &body); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var target struct {
+		MediaItemID int64  `json:"media_item_id"`
+		TemplateID  *int64 `json:"template_id"`
+	}
+	if err := json.Unmarshal(body, &target); err != nil {
 		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	params, err := h.service.NewRuleParams(r.Context(), target.MediaItemID, target.TemplateID)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to create monitoring rule")
+		return
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if params.SeriesType != "" && !params.SeriesType.IsValid() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown series type: "+string(params.SeriesType))
+		return
+	}
+	if params.MonitorMode != "" && !params.MonitorMode.IsValid() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown monitor mode: "+string(params.MonitorMode))
+		return
+	}
+	if !h.validRootFolder(w, r, params.RootFolderID) {
+		return
+	}
+	if !h.validTags(w, r, &params.Tags) {
+		return
+	}
+
 	rule, err := h.service.CreateMonitoringRule(r.Context(), params)
 	if err != nil {
 		h.logger.Error("Failed to create monitoring rule", zap.Error(err))
 		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to create monitoring rule")
 		return
 	}
+	h.fillCoverage(r, rule)
 
 	httputil.RespondJSON(w, http.StatusCreated, rule)
 }
 
+// validTags normalizes the tags of a request and creates the ones that don't
+// exist yet, responding with an error when they are invalid. Nil tags stay
+// nil, as they mean the tags aren't changed.
+func (h *Handler) validTags(w http.ResponseWriter, r *http.Request, names *[]string) bool {
+	if *names == nil {
+		return true
+	}
+	normalized, err := tags.NormalizeAll(*names)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err := h.tags.Ensure(r.Context(), normalized); err != nil {
+		h.logger.Error("Failed to create tags", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to create tags")
+		return false
+	}
+	*names = normalized
+	return true
+}
+
+// validRootFolder checks that a rule's root folder exists, responding with an
+// error when it doesn't
+func (h *Handler) validRootFolder(w http.ResponseWriter, r *http.Request, id *int64) bool {
+	if id == nil {
+		return true
+	}
+	exists, err := h.service.RootFolderExists(r.Context(), *id)
+	if err != nil {
+		h.logger.Error("Failed to look up root folder", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to look up root folder")
+		return false
+	}
+	if !exists {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Root folder not found")
+		return false
+	}
+	return true
+}
+
 // GetMonitoringRule gets a monitoring rule by ID
 func (h *Handler) GetMonitoringRule(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -64,6 +178,7 @@ func (h *Handler) GetMonitoringRule(w http.ResponseWriter, r *http.Request) {
 		httputil.RespondErrorMessage(w, http.StatusNotFound, "Monitoring rule not found")
 		return
 	}
+	h.fillCoverage(r, rule)
 
 	httputil.RespondJSON(w, http.StatusOK, rule)
 }
@@ -83,6 +198,7 @@ func (h *Handler) GetMonitoringRuleByMediaItem(w http.ResponseWriter, r *http.Re
 		httputil.RespondErrorMessage(w, http.StatusNotFound, "Monitoring rule not found")
 		return
 	}
+	h.fillCoverage(r, rule)
 
 	httputil.RespondJSON(w, http.StatusOK, rule)
 }
@@ -98,6 +214,11 @@ func (h *Handler) ListMonitoringRules(w http.ResponseWriter, r *http.Request) {
 		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list monitoring rules")
 		return
 	}
+	ptrs := make([]*MonitoringRule, len(rules))
+	for i := range rules {
+		ptrs[i] = &rules[i]
+	}
+	h.fillCoverage(r, ptrs...)
 
 	httputil.RespondJSON(w, http.StatusOK, rules)
 }
@@ -117,16 +238,51 @@ func (h *Handler) UpdateMonitoringRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if params.SeriesType != nil && !params.SeriesType.IsValid() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown series type: "+string(*params.SeriesType))
+		return
+	}
+	if params.MonitorMode != nil && !params.MonitorMode.IsValid() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown monitor mode: "+string(*params.MonitorMode))
+		return
+	}
+	if !h.validRootFolder(w, r, params.RootFolderID) {
+		return
+	}
+	if !h.validTags(w, r, &params.Tags) {
+		return
+	}
+
 	rule, err := h.service.UpdateMonitoringRule(r.Context(), id, params)
 	if err != nil {
 		h.logger.Error("Failed to update monitoring rule", zap.Error(err))
 		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to update monitoring rule")
 		return
 	}
+	if params.QualityProfileID != nil {
+		h.recomputeCutoffs()
+	}
+	h.fillCoverage(r, rule)
 
 	httputil.RespondJSON(w, http.StatusOK, rule)
 }
 
+// fillCoverage adds the episode counts to rules in a response. The rules are
+// still returned without them when counting fails.
+func (h *Handler) fillCoverage(r *http.Request, rules ...*MonitoringRule) {
+	if err := h.service.FillRuleCoverage(r.Context(), rules...); err != nil {
+		h.logger.Warn("Failed to count episodes of monitoring rules", zap.Error(err))
+	}
+}
+
+// recomputeCutoffs runs the cutoff recompute job after the quality profile of
+// rules changed
+func (h *Handler) recomputeCutoffs() {
+	if err := h.scheduler.TriggerJobByName(context.Background(), JobCutoffRecompute); err != nil {
+		h.logger.Warn("Failed to start the cutoff recompute", zap.Error(err))
+	}
+}
+
 // DeleteMonitoringRule deletes a monitoring rule
 func (h *Handler) DeleteMonitoringRule(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -145,6 +301,166 @@ func (h *Handler) DeleteMonitoringRule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// bulkRuleRequest is the body of the bulk endpoints: the rules to act on, and
+// for updates the changes to make
+type bulkRuleRequest struct {
+	RuleSelection
+	Update BulkUpdateMonitoringRuleParams `json:"update"`
+}
+
+// bulkRuleResponse lists the outcome for every selected or listed rule
+type bulkRuleResponse struct {
+	Results []BulkRuleResult `json:"results"`
+}
+
+// decodeBulkRequest reads the body of a bulk request, responding with an error
+// when it is invalid or selects nothing
+func decodeBulkRequest(w http.ResponseWriter, r *http.Request) (bulkRuleRequest, bool) {
+	var req bulkRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return req, false
+	}
+	req.Tag = tags.Normalize(req.Tag)
+	if req.IsEmpty() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "rule_ids, kind or tag is required")
+		return req, false
+	}
+	return req, true
+}
+
+// BulkUpdateMonitoringRules handles PUT /api/monitoring/rules/bulk, applying
+// the same changes to all selected rules at once
+func (h *Handler) BulkUpdateMonitoringRules(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBulkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	update := req.Update
+	if update.IsEmpty() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "update must change at least one field")
+		return
+	}
+	if update.MonitorMode != nil && !update.MonitorMode.IsValid() {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Unknown monitor mode: "+string(*update.MonitorMode))
+		return
+	}
+	if update.SearchIntervalMinutes != nil && *update.SearchIntervalMinutes < 1 {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "search_interval_minutes must be at least 1")
+		return
+	}
+	if update.MinimumSeeders != nil && *update.MinimumSeeders < 0 {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "minimum_seeders can't be negative")
+		return
+	}
+	if !h.validTags(w, r, &update.AddTags) {
+		return
+	}
+	if update.RemoveTags != nil {
+		removeTags, err := tags.NormalizeAll(update.RemoveTags)
+		if err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.RemoveTags = removeTags
+	}
+	if update.QualityProfileID != nil {
+		exists, err := h.service.QualityProfileExists(r.Context(), *update.QualityProfileID)
+		if err != nil {
+			h.logger.Error("Failed to look up quality profile", zap.Error(err))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to look up quality profile")
+			return
+		}
+		if !exists {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Quality profile not found")
+			return
+		}
+	}
+
+	results, err := h.service.BulkUpdateMonitoringRules(r.Context(), req.RuleSelection, update)
+	if err != nil {
+		h.logger.Error("Failed to bulk update monitoring rules", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to update monitoring rules")
+		return
+	}
+
+	h.recordBulk(r, history.EventMonitoringUpdated, results, map[string]interface{}{"changes": update})
+	if update.QualityProfileID != nil {
+		h.recomputeCutoffs()
+	}
+	httputil.RespondJSON(w, http.StatusOK, bulkRuleResponse{Results: results})
+}
+
+// BulkDeleteMonitoringRules handles POST /api/monitoring/rules/bulk/delete
+func (h *Handler) BulkDeleteMonitoringRules(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBulkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.service.BulkDeleteMonitoringRules(r.Context(), req.RuleSelection)
+	if err != nil {
+		h.logger.Error("Failed to bulk delete monitoring rules", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to delete monitoring rules")
+		return
+	}
+
+	h.recordBulk(r, history.EventMonitoringDeleted, results, nil)
+	httputil.RespondJSON(w, http.StatusOK, bulkRuleResponse{Results: results})
+}
+
+// BulkSearchMonitoringRules handles POST /api/monitoring/rules/bulk/search,
+// starting a search for all selected rules in the background
+func (h *Handler) BulkSearchMonitoringRules(w http.ResponseWriter, r *http.Request) {
+	if h.backlog == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Search is not available")
+		return
+	}
+
+	req, ok := decodeBulkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.service.SelectMonitoringRules(r.Context(), req.RuleSelection, BulkRuleQueued)
+	if err != nil {
+		h.logger.Error("Failed to select monitoring rules", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to select monitoring rules")
+		return
+	}
+
+	var ids []int64
+	for _, result := range results {
+		if result.Status == BulkRuleQueued {
+			ids = append(ids, result.RuleID)
+		}
+	}
+	h.backlog.searcher.SearchRules(ids)
+
+	httputil.RespondJSON(w, http.StatusAccepted, bulkRuleResponse{Results: results})
+}
+
+// recordBulk adds an event for every rule a bulk operation changed to the
+// activity history
+func (h *Handler) recordBulk(r *http.Request, eventType history.EventType, results []BulkRuleResult, data map[string]interface{}) {
+	for _, result := range results {
+		if result.Status == BulkRuleNotFound {
+			continue
+		}
+		event := map[string]interface{}{"monitoring_rule_id": result.RuleID, "bulk": true}
+		for k, v := range data {
+			event[k] = v
+		}
+		h.history.Record(r.Context(), history.Event{
+			Type:        eventType,
+			MediaItemID: result.MediaItemID,
+			Title:       result.Title,
+			Data:        event,
+		})
+	}
+}
+
 // ========================
 // Episode Monitoring
 // ========================
@@ -222,35 +538,89 @@ func (h *Handler) CreateBlocklistEntry(w http.ResponseWriter, r *http.Request) {
 	httputil.RespondJSON(w, http.StatusCreated, entry)
 }
 
+// ListBlocklist lists blocklist entries with limit/offset paging
+func (h *Handler) ListBlocklist(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 500 {
+			limit = parsedLimit
+		}
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	page, err := h.service.ListBlocklist(r.Context(), limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list blocklist", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list blocklist")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, page)
+}
+
+// DeleteBlocklistEntry removes a blocklist entry so the release can be grabbed again
+func (h *Handler) DeleteBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid blocklist entry ID")
+		return
+	}
+
+	deleted, err := h.service.DeleteBlocklistEntry(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to delete blocklist entry", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to delete blocklist entry")
+		return
+	}
+	if !deleted {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Blocklist entry not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ========================
 // Calendar
 // ========================
 
 // GetCalendarEvents gets calendar events
 func (h *Handler) GetCalendarEvents(w http.ResponseWriter, r *http.Request) {
-	// Parse date range from query params
-	startDateStr := r.URL.Query().Get("start")
-	endDateStr := r.URL.Query().Get("end")
-	monitoredOnlyStr := r.URL.Query().Get("monitored")
+	startDate, endDate, monitoredOnly := parseCalendarQuery(r, 30, 30)
 
-	// Default to 30 days before and after today
-	now := time.Now()
-	startDate := now.AddDate(0, 0, -30)
-	endDate := now.AddDate(0, 0, 30)
+	events, err := h.service.GetCalendarEvents(r.Context(), startDate, endDate, monitoredOnly)
+	if err != nil {
+		h.logger.Error("Failed to get calendar events", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to get calendar events")
+		return
+	}
 
-	if startDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", startDateStr); err == nil {
-			startDate = parsed
-		}
+	httputil.RespondJSON(w, http.StatusOK, events)
+}
+
+// GetCalendarFeed serves the calendar as an iCal feed. It is not behind the auth
+// middleware so calendar apps can subscribe; the feed token authorizes the request.
+func (h *Handler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if h.config == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Calendar feed is not available")
+		return
 	}
 
-	if endDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = parsed
-		}
+	token, err := h.config.GetString(r.Context(), calendarFeedTokenKey)
+	provided := r.URL.Query().Get("token")
+	if err != nil || token == "" || provided == "" || subtle.ConstantTimeCompare([]byte(token), []byte(provided)) != 1 {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "Invalid calendar feed token")
+		return
 	}
 
-	monitoredOnly := monitoredOnlyStr == "true"
+	startDate, endDate, monitoredOnly := parseCalendarQuery(r, 30, 90)
 
 	events, err := h.service.GetCalendarEvents(r.Context(), startDate, endDate, monitoredOnly)
 	if err != nil {
@@ -259,7 +629,100 @@ func (h *Handler) GetCalendarEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	httputil.RespondJSON(w, http.StatusOK, events)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="nimbus.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(RenderICalendar(events, time.Now()))
+}
+
+// GetCalendarFeedToken returns the iCal feed token, creating one on first use
+func (h *Handler) GetCalendarFeedToken(w http.ResponseWriter, r *http.Request) {
+	if h.config == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Calendar feed is not available")
+		return
+	}
+
+	token, err := h.config.GetString(r.Context(), calendarFeedTokenKey)
+	if err != nil || token == "" {
+		token, err = h.rotateCalendarFeedToken(r)
+		if err != nil {
+			h.logger.Error("Failed to create calendar feed token", zap.Error(err))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to create calendar feed token")
+			return
+		}
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, calendarFeedTokenResponse(token))
+}
+
+// RegenerateCalendarFeedToken replaces the iCal feed token, invalidating existing subscriptions
+func (h *Handler) RegenerateCalendarFeedToken(w http.ResponseWriter, r *http.Request) {
+	if h.config == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Calendar feed is not available")
+		return
+	}
+
+	token, err := h.rotateCalendarFeedToken(r)
+	if err != nil {
+		h.logger.Error("Failed to regenerate calendar feed token", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to regenerate calendar feed token")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, calendarFeedTokenResponse(token))
+}
+
+// calendarFeedTokenKey is the config key holding the iCal feed token
+const calendarFeedTokenKey = "calendar.feed_token"
+
+// rotateCalendarFeedToken generates and stores a new feed token
+func (h *Handler) rotateCalendarFeedToken(r *http.Request) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := h.config.SetString(r.Context(), calendarFeedTokenKey, token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// calendarFeedTokenResponse builds the response for the feed token endpoints
+func calendarFeedTokenResponse(token string) map[string]string {
+	return map[string]string{
+		"token": token,
+		"url":   "/api/calendar/feed.ics?token=" + token,
+	}
+}
+
+// parseCalendarQuery reads the start, end and monitored_only parameters. The range
+// defaults to the given number of days before and after today.
+func parseCalendarQuery(r *http.Request, daysBefore, daysAfter int) (time.Time, time.Time, bool) {
+	query := r.URL.Query()
+
+	now := time.Now()
+	startDate := now.AddDate(0, 0, -daysBefore)
+	endDate := now.AddDate(0, 0, daysAfter)
+
+	if startDateStr := query.Get("start"); startDateStr != "" {
+		if parsed, err := time.Parse("2006-01-02", startDateStr); err == nil {
+			startDate = parsed
+		}
+	}
+
+	if endDateStr := query.Get("end"); endDateStr != "" {
+		if parsed, err := time.Parse("2006-01-02", endDateStr); err == nil {
+			endDate = parsed
+		}
+	}
+
+	// "monitored" is the older name of the parameter
+	monitoredOnly := query.Get("monitored_only") == "true" || query.Get("monitored") == "true"
+
+	return startDate, endDate, monitoredOnly
 }
 
 // ========================
@@ -278,6 +741,123 @@ func (h *Handler) GetMonitoringStats(w http.ResponseWriter, r *http.Request) {
 	httputil.RespondJSON(w, http.StatusOK, stats)
 }
 
+// ========================
+// Backlog Search
+// ========================
+
+// StartBacklogSearch starts searching for all missing episodes, optionally scoped
+// to a single series, season or episode
+func (h *Handler) StartBacklogSearch(w http.ResponseWriter, r *http.Request) {
+	if h.backlog == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Backlog search is not available")
+		return
+	}
+
+	var req struct {
+		MediaItemID *int64 `json:"media_item_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	status, err := h.backlog.Start(req.MediaItemID)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, status)
+}
+
+// GetBacklogSearchStatus returns the progress of the current or last backlog search
+func (h *Handler) GetBacklogSearchStatus(w http.ResponseWriter, r *http.Request) {
+	if h.backlog == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Backlog search is not available")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, h.backlog.Status())
+}
+
+// CancelBacklogSearch cancels the running backlog search
+func (h *Handler) CancelBacklogSearch(w http.ResponseWriter, r *http.Request) {
+	if h.backlog == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Backlog search is not available")
+		return
+	}
+
+	if !h.backlog.Cancel() {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "No backlog search is running")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, h.backlog.Status())
+}
+
+// ========================
+// Manual Search
+// ========================
+
+// StartMediaSearch handles POST /api/media/{id}/search, searching for a movie,
+// episode, season or series in the background. It returns the task to poll at
+// /api/tasks/{id}.
+func (h *Handler) StartMediaSearch(w http.ResponseWriter, r *http.Request) {
+	if h.searches == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Search is not available")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	task, err := h.searches.Start(r.Context(), id)
+	if err != nil {
+		h.logger.Warn("Failed to start media search", zap.Int64("media_item_id", id), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item not found")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, task)
+}
+
+// GetSearchTask returns the progress of a media search
+func (h *Handler) GetSearchTask(w http.ResponseWriter, r *http.Request) {
+	if h.searches == nil {
+		httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Search is not available")
+		return
+	}
+
+	task, ok := h.searches.Get(chi.URLParam(r, "id"))
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, task)
+}
+
+// ========================
+// RSS Sync
+// ========================
+
+// ListRSSSyncStates lists the RSS sync state of every indexer feed
+func (h *Handler) ListRSSSyncStates(w http.ResponseWriter, r *http.Request) {
+	states, err := h.service.ListRSSSyncStates(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list RSS sync states", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list RSS sync states")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, states)
+}
+
 // ========================
 // Scheduler Jobs
 // ========================
@@ -323,6 +903,10 @@ func (h *Handler) TriggerSchedulerJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.scheduler.TriggerJob(r.Context(), id); err != nil {
+		if errors.Is(err, ErrJobRunning) {
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Job is already running")
+			return
+		}
 		h.logger.Error("Failed to trigger scheduler job", zap.Error(err))
 		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to trigger scheduler job")
 		return
@@ -332,3 +916,328 @@ func (h *Handler) TriggerSchedulerJob(w http.ResponseWriter, r *http.Request) {
 		"message": "Job triggered successfully",
 	})
 }
+
+// ========================
+// System Tasks
+// ========================
+
+// ListTasks lists the scheduler jobs with their schedules and last results
+func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.scheduler.ListTasks(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list tasks", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list tasks")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, tasks)
+}
+
+// GetTask gets a task by name
+func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
+	task, err := h.scheduler.GetTask(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondTaskError(w, err, "Failed to get task")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, task)
+}
+
+// RunTask runs a task now. A task that is already running responds 409, and
+// the attempt is recorded as skipped.
+func (h *Handler) RunTask(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := h.scheduler.RunTask(r.Context(), name); err != nil {
+		if errors.Is(err, ErrJobRunning) {
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Task is already running")
+			return
+		}
+		h.respondTaskError(w, err, "Failed to run task")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusAccepted, map[string]string{
+		"message": "Task started",
+	})
+}
+
+// UpdateTask changes the interval or cron expression of a task, or enables or
+// disables it
+func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	var params UpdateTaskParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	task, err := h.scheduler.UpdateTask(r.Context(), chi.URLParam(r, "name"), params)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Task not found")
+			return
+		}
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, task)
+}
+
+// ListTaskHistory lists the latest runs of a task with their outcome and log
+func (h *Handler) ListTaskHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	runs, err := h.scheduler.TaskHistory(r.Context(), chi.URLParam(r, "name"), limit)
+	if err != nil {
+		h.respondTaskError(w, err, "Failed to list task history")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, runs)
+}
+
+func (h *Handler) respondTaskError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, ErrTaskNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	h.logger.Error(message, zap.Error(err))
+	httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+}
+
+// ========================
+// Monitoring Defaults & Templates
+// ========================
+
+// GetMonitoringDefaults returns the settings of rules created without a
+// template
+// GET /api/settings/monitoring-defaults
+func (h *Handler) GetMonitoringDefaults(w http.ResponseWriter, r *http.Request) {
+	defaults, err := h.service.MonitoringDefaults(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to load monitoring defaults", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to load monitoring defaults")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, defaults)
+}
+
+// UpdateMonitoringDefaults validates and saves the monitoring defaults. Fields
+// left out keep their values.
+// PUT /api/settings/monitoring-defaults
+func (h *Handler) UpdateMonitoringDefaults(w http.ResponseWriter, r *http.Request) {
+	var update json.RawMessage
+	if err := httputil.DecodeJSON(r, &update); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	defaults, err := h.service.UpdateMonitoringDefaults(r.Context(), update)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to save monitoring defaults")
+		return
+	}
+	if !h.ensureTags(w, r, defaults.Movie.Tags, defaults.TVSeries.Tags) {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, defaults)
+}
+
+// ListMonitoringTemplates lists the monitoring templates
+// GET /api/monitoring/templates
+func (h *Handler) ListMonitoringTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.service.ListTemplates(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list monitoring templates", zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to list monitoring templates")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, templates)
+}
+
+// GetMonitoringTemplate gets a monitoring template by ID
+// GET /api/monitoring/templates/{id}
+func (h *Handler) GetMonitoringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.service.GetTemplate(r.Context(), id)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to get monitoring template")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, template)
+}
+
+// CreateMonitoringTemplate creates a monitoring template. Settings left out
+// are taken from the monitoring defaults of the template's kind.
+// POST /api/monitoring/templates
+func (h *Handler) CreateMonitoringTemplate(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := httputil.DecodeJSON(r, &body); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	template, err := h.service.CreateTemplate(r.Context(), body)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to create monitoring template")
+		return
+	}
+	if !h.ensureTags(w, r, template.Tags) {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusCreated, template)
+}
+
+// templateUpdateResponse is a template after an update, with the rules it was
+// re-applied to when that was asked for. Otherwise the template's rule_count
+// tells how many rules it could be re-applied to.
+type templateUpdateResponse struct {
+	Template *MonitoringTemplate `json:"template"`
+	Applied  bool                `json:"applied"`
+	Results  []BulkRuleResult    `json:"results,omitempty"`
+}
+
+// UpdateMonitoringTemplate updates a monitoring template. Fields left out keep
+// their values. With ?apply=true the new settings are also given to the rules
+// created from the template.
+// PUT /api/monitoring/templates/{id}
+func (h *Handler) UpdateMonitoringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+	var update json.RawMessage
+	if err := httputil.DecodeJSON(r, &update); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	template, previousTags, err := h.service.UpdateTemplate(r.Context(), id, update)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to update monitoring template")
+		return
+	}
+	if !h.ensureTags(w, r, template.Tags) {
+		return
+	}
+
+	resp := templateUpdateResponse{Template: template}
+	if r.URL.Query().Get("apply") == "true" {
+		resp.Results, ok = h.reapplyTemplate(w, r, template, previousTags)
+		if !ok {
+			return
+		}
+		resp.Applied = true
+	}
+	httputil.RespondJSON(w, http.StatusOK, resp)
+}
+
+// ApplyMonitoringTemplate gives the rules created from a template its current
+// settings
+// POST /api/monitoring/templates/{id}/apply
+func (h *Handler) ApplyMonitoringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.service.GetTemplate(r.Context(), id)
+	if err != nil {
+		h.respondTemplateError(w, err, "Failed to get monitoring template")
+		return
+	}
+	results, ok := h.reapplyTemplate(w, r, template, nil)
+	if !ok {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, bulkRuleResponse{Results: results})
+}
+
+// reapplyTemplate updates the rules created from a template, responding with
+// an error when that fails
+func (h *Handler) reapplyTemplate(w http.ResponseWriter, r *http.Request, template *MonitoringTemplate, previousTags []string) ([]BulkRuleResult, bool) {
+	results, changes, err := h.service.ReapplyTemplate(r.Context(), template, previousTags)
+	if err != nil {
+		h.logger.Error("Failed to re-apply monitoring template", zap.Int64("template_id", template.ID), zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to re-apply monitoring template")
+		return nil, false
+	}
+
+	h.recordBulk(r, history.EventMonitoringUpdated, results, map[string]interface{}{
+		"changes":     changes,
+		"template_id": template.ID,
+	})
+	if changes.QualityProfileID != nil && len(results) > 0 {
+		h.recomputeCutoffs()
+	}
+	return results, true
+}
+
+// DeleteMonitoringTemplate deletes a monitoring template. Rules created from
+// it keep their settings.
+// DELETE /api/monitoring/templates/{id}
+func (h *Handler) DeleteMonitoringTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTemplate(r.Context(), id); err != nil {
+		h.respondTemplateError(w, err, "Failed to delete monitoring template")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTemplateID reads the template ID of a request, responding with an
+// error when it is invalid
+func parseTemplateID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid template ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// ensureTags creates the tags of saved settings that don't exist yet,
+// responding with an error when that fails
+func (h *Handler) ensureTags(w http.ResponseWriter, r *http.Request, lists ...[]string) bool {
+	for _, names := range lists {
+		if err := h.tags.Ensure(r.Context(), names); err != nil {
+			h.logger.Error("Failed to create tags", zap.Error(err))
+			httputil.RespondErrorMessage(w, http.StatusInternalServerError, "Failed to create tags")
+			return false
+		}
+	}
+	return true
+}
+
+// respondTemplateError responds 400 to invalid settings, 404 to a missing
+// template, 409 to a duplicate name and 500 otherwise
+func (h *Handler) respondTemplateError(w http.ResponseWriter, err error, message string) {
+	var settingsErr *SettingsError
+	switch {
+	case errors.As(err, &settingsErr):
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, settingsErr.Message)
+	case errors.Is(err, ErrTemplateNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Monitoring template not found")
+	case errors.Is(err, ErrMediaItemNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item not found")
+	case errors.Is(err, ErrTemplateNameTaken):
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error(message, zap.Error(err))
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+	}
+}
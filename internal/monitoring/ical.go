@@ -0,0 +1,107 @@
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// icalLineLimit is the maximum line length in octets before folding (RFC 5545 3.1)
+const icalLineLimit = 75
+
+// RenderICalendar renders calendar events as an iCalendar feed of all-day events
+func RenderICalendar(events []CalendarEvent, now time.Time) []byte {
+	var b strings.Builder
+
+	writeLine := func(line string) {
+		b.WriteString(foldICalLine(line))
+		b.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//Nimbus//Calendar//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	writeLine("X-WR-CALNAME:Nimbus")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:nimbus-calendar-%d@nimbus", event.ID))
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART;VALUE=DATE:" + event.EventDate.Format("20060102"))
+		writeLine("DTEND;VALUE=DATE:" + event.EventDate.AddDate(0, 0, 1).Format("20060102"))
+		writeLine("SUMMARY:" + escapeICalText(calendarEventSummary(event)))
+		if overview, ok := event.Metadata["overview"].(string); ok && overview != "" {
+			writeLine("DESCRIPTION:" + escapeICalText(overview))
+		}
+		writeLine("CATEGORIES:" + escapeICalText(string(event.EventType)))
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+
+	return []byte(b.String())
+}
+
+// calendarEventSummary builds the event title, e.g. "Show - S01E05 - Pilot"
+func calendarEventSummary(event CalendarEvent) string {
+	switch event.EventType {
+	case EventTypeEpisodeAir:
+		parts := []string{}
+		if event.ParentTitle != nil && *event.ParentTitle != "" {
+			parts = append(parts, *event.ParentTitle)
+		}
+		season, _ := event.Metadata["season_number"].(float64)
+		episode, _ := event.Metadata["episode_number"].(float64)
+		if season > 0 || episode > 0 {
+			parts = append(parts, fmt.Sprintf("S%02dE%02d", int(season), int(episode)))
+		}
+		parts = append(parts, event.Title)
+		return strings.Join(parts, " - ")
+	case EventTypeDigitalRelease:
+		return event.Title + " (Digital release)"
+	case EventTypePhysicalRelease:
+		return event.Title + " (Physical release)"
+	default:
+		return event.Title
+	}
+}
+
+// escapeICalText escapes a TEXT value (RFC 5545 3.3.11)
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// foldICalLine splits a content line into 75 octet chunks joined by CRLF and a
+// space, without breaking UTF-8 sequences
+func foldICalLine(line string) string {
+	if len(line) <= icalLineLimit {
+		return line
+	}
+
+	var b strings.Builder
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package monitoring
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderICalendar(t *testing.T) {
+	series := "Show, The"
+	events := []CalendarEvent{
+		{
+			ID:          7,
+			EventType:   EventTypeEpisodeAir,
+			EventDate:   time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC),
+			Title:       "Pilot; Part 1",
+			ParentTitle: &series,
+			Metadata:    map[string]interface{}{"season_number": float64(1), "episode_number": float64(2)},
+		},
+	}
+
+	feed := string(RenderICalendar(events, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:nimbus-calendar-7@nimbus\r\n",
+		"DTSTART;VALUE=DATE:20260314\r\n",
+		"DTEND;VALUE=DATE:20260315\r\n",
+		`SUMMARY:Show\, The - S01E02 - Pilot\; Part 1` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed missing %q:\n%s", want, feed)
+		}
+	}
+}
+
+func TestFoldICalLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 100)
+	folded := foldICalLine(line)
+
+	for i, part := range strings.Split(folded, "\r\n") {
+		if len(part) > icalLineLimit {
+			t.Errorf("line %d is %d octets long", i, len(part))
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Errorf("continuation line %d does not start with a space", i)
+		}
+	}
+
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("unfolding does not restore the line")
+	}
+}
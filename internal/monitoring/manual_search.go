@@ -0,0 +1,357 @@
+package monitoring
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxSearchTasks is how many finished search tasks are kept for their status
+	maxSearchTasks = 100
+
+	// maxManualSearchEpisodes caps the missing episodes searched by one season or
+	// series search
+	maxManualSearchEpisodes = 500
+)
+
+// SearchTaskStatus is the state of a search task or of one of its items
+type SearchTaskStatus string
+
+const (
+	SearchTaskQueued    SearchTaskStatus = "queued"
+	SearchTaskRunning   SearchTaskStatus = "running"
+	SearchTaskCompleted SearchTaskStatus = "completed"
+	SearchTaskFailed    SearchTaskStatus = "failed"
+)
+
+// SearchTask reports the progress of a manual search of a media item. Searching
+// a series or season searches its missing episodes, so its items are the seasons
+// of the series or the episodes of the season.
+type SearchTask struct {
+	ID          string           `json:"id"`
+	MediaItemID int64            `json:"media_item_id"`
+	Kind        string           `json:"kind"`
+	Title       string           `json:"title"`
+	Status      SearchTaskStatus `json:"status"`
+	Searched    int              `json:"searched"`
+	Found       int              `json:"found"`
+	Grabbed     int              `json:"grabbed"`
+	Items       []SearchTaskItem `json:"items"`
+	Error       string           `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+}
+
+// SearchTaskItem is the progress of a search task for one child item. Searched
+// and Grabbed count episodes, Found counts approved releases.
+type SearchTaskItem struct {
+	MediaItemID int64            `json:"media_item_id"`
+	Kind        string           `json:"kind"`
+	Title       string           `json:"title"`
+	Status      SearchTaskStatus `json:"status"`
+	Searched    int              `json:"searched"`
+	Found       int              `json:"found"`
+	Grabbed     int              `json:"grabbed"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// ManualSearcher runs searches requested for a media item in the background,
+// keeping their progress as tasks. Searches follow the item's monitoring rule
+// and are recorded in the search history as manual.
+type ManualSearcher struct {
+	searcher *AutoSearcher
+	logger   *zap.Logger
+
+	mu    sync.Mutex
+	tasks map[string]*SearchTask
+	order []string // Task IDs, oldest first
+}
+
+// NewManualSearcher creates a manual searcher on top of an automatic searcher
+func NewManualSearcher(searcher *AutoSearcher, logger *zap.Logger) *ManualSearcher {
+	return &ManualSearcher{
+		searcher: searcher,
+		logger:   logger.With(zap.String("component", "manual-search")),
+		tasks:    make(map[string]*SearchTask),
+	}
+}
+
+// Start starts a search of a media item in the background: the item itself for
+// movies and episodes, the season pack and missing episodes of a season, or
+// every season with missing episodes of a series
+func (m *ManualSearcher) Start(ctx context.Context, mediaItemID int64) (SearchTask, error) {
+	media, err := m.searcher.queries.GetMediaItem(ctx, mediaItemID)
+	if err != nil {
+		return SearchTask{}, fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	task := &SearchTask{
+		ID:          newSearchTaskID(),
+		MediaItemID: media.ID,
+		Kind:        media.Kind,
+		Title:       media.Title,
+		Status:      SearchTaskQueued,
+		Items:       []SearchTaskItem{},
+		CreatedAt:   time.Now(),
+	}
+
+	m.mu.Lock()
+	m.tasks[task.ID] = task
+	m.order = append(m.order, task.ID)
+	m.prune()
+	snapshot := task.snapshot()
+	m.mu.Unlock()
+
+	go m.run(context.Background(), task)
+
+	return snapshot, nil
+}
+
+// Get returns the progress of a search task
+func (m *ManualSearcher) Get(id string) (SearchTask, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[id]
+	if !ok {
+		return SearchTask{}, false
+	}
+	return task.snapshot(), true
+}
+
+// prune drops the oldest finished tasks beyond maxSearchTasks. Must be called
+// with the lock held.
+func (m *ManualSearcher) prune() {
+	excess := len(m.order) - maxSearchTasks
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if excess > 0 && m.tasks[id].FinishedAt != nil {
+			delete(m.tasks, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+// run performs the search of a task
+func (m *ManualSearcher) run(ctx context.Context, task *SearchTask) {
+	m.update(func() { task.Status = SearchTaskRunning })
+
+	err := m.search(ctx, task)
+
+	m.update(func() {
+		now := time.Now()
+		task.FinishedAt = &now
+		task.Status = SearchTaskCompleted
+		if err != nil {
+			task.Status = SearchTaskFailed
+			task.Error = err.Error()
+		}
+	})
+
+	if err != nil {
+		m.logger.Warn("Manual search failed", zap.String("task_id", task.ID), zap.Int64("media_item_id", task.MediaItemID), zap.Error(err))
+		return
+	}
+	m.logger.Info("Manual search finished",
+		zap.String("task_id", task.ID),
+		zap.Int64("media_item_id", task.MediaItemID),
+		zap.Int("searched", task.Searched),
+		zap.Int("grabbed", task.Grabbed))
+}
+
+// search enumerates the items of a task and searches for them
+func (m *ManualSearcher) search(ctx context.Context, task *SearchTask) error {
+	svc := m.searcher.monitoringSvc
+
+	switch task.Kind {
+	case "tv_series", "tv_season":
+		missing, err := svc.GetMissingEpisodesForMedia(ctx, task.MediaItemID, maxManualSearchEpisodes)
+		if err != nil {
+			return err
+		}
+
+		// Items are the episodes of a season, or the seasons of a series
+		owners := make(map[int64]int)
+		for _, episode := range missing {
+			child := episode.MediaItemID
+			if task.Kind == "tv_series" {
+				child = episode.SeasonID
+			}
+			if _, ok := owners[child]; !ok {
+				owners[child] = m.addItem(ctx, task, child)
+			}
+			owners[episode.MediaItemID] = owners[child]
+		}
+
+		for _, season := range groupBySeason(missing) {
+			m.searchSeason(ctx, task, season, owners)
+		}
+		return nil
+
+	default:
+		item := m.addItem(ctx, task, task.MediaItemID)
+		rule, err := svc.GetEffectiveMonitoringRule(ctx, task.MediaItemID)
+		if err != nil {
+			return err
+		}
+		history, err := m.searcher.SearchMediaItem(ctx, rule, task.MediaItemID, SearchTypeManual, TriggerSourceManual)
+		m.record(task, item, history, err)
+		return err
+	}
+}
+
+// searchSeason searches for the missing episodes of one season: a season pack
+// when the season's rule prefers them, then every episode the pack didn't cover
+func (m *ManualSearcher) searchSeason(ctx context.Context, task *SearchTask, episodes []MissingEpisode, owners map[int64]int) {
+	a := m.searcher
+	seasonID := episodes[0].SeasonID
+
+	rule, err := a.monitoringSvc.GetEffectiveMonitoringRule(ctx, seasonID)
+	if err != nil {
+		m.logger.Warn("Failed to get monitoring rule", zap.Int64("media_item_id", seasonID), zap.Error(err))
+	}
+
+	remaining := episodes
+	fallbacks := map[int64]string{}
+	if rule != nil && rule.PreferSeasonPacks {
+		var found int
+		remaining, fallbacks, found, _ = a.grabSeasonPacks(ctx, rule, episodes, SearchTypeManual, TriggerSourceManual)
+
+		left := make(map[int64]bool, len(remaining))
+		for _, episode := range remaining {
+			left[episode.MediaItemID] = true
+		}
+		m.update(func() {
+			task.Found += found
+			task.Items[owners[episodes[0].MediaItemID]].Found += found
+			for _, episode := range episodes {
+				if left[episode.MediaItemID] {
+					continue
+				}
+				item := &task.Items[owners[episode.MediaItemID]]
+				item.Searched++
+				item.Grabbed++
+				task.Searched++
+				task.Grabbed++
+			}
+		})
+	}
+
+	for _, episode := range remaining {
+		if ctx.Err() != nil {
+			return
+		}
+
+		episodeRule := rule
+		if r, err := a.monitoringSvc.GetEffectiveMonitoringRule(ctx, episode.MediaItemID); err == nil && r != nil {
+			episodeRule = r
+		}
+
+		var metadata map[string]interface{}
+		if reason, ok := fallbacks[episode.MediaItemID]; ok {
+			metadata = map[string]interface{}{
+				"strategy":        searchStrategyEpisode,
+				"fallback_reason": reason,
+			}
+		}
+
+		history, err := a.searchMediaItem(ctx, episodeRule, episode.MediaItemID, SearchTypeManual, TriggerSourceManual, metadata)
+		if err != nil {
+			m.logger.Warn("Search for episode failed", zap.Int64("media_item_id", episode.MediaItemID), zap.Error(err))
+		}
+		m.record(task, owners[episode.MediaItemID], history, err)
+	}
+
+	m.update(func() {
+		for _, episode := range episodes {
+			if item := &task.Items[owners[episode.MediaItemID]]; item.Status == SearchTaskRunning {
+				item.Status = SearchTaskCompleted
+			}
+		}
+	})
+}
+
+// addItem adds a child item to a task and returns its index
+func (m *ManualSearcher) addItem(ctx context.Context, task *SearchTask, mediaItemID int64) int {
+	item := SearchTaskItem{MediaItemID: mediaItemID, Status: SearchTaskRunning}
+	if media, err := m.searcher.queries.GetMediaItem(ctx, mediaItemID); err == nil {
+		item.Kind = media.Kind
+		item.Title = media.Title
+	}
+
+	var index int
+	m.update(func() {
+		task.Items = append(task.Items, item)
+		index = len(task.Items) - 1
+	})
+	return index
+}
+
+// record adds the outcome of a search to a task and one of its items
+func (m *ManualSearcher) record(task *SearchTask, index int, history *SearchHistory, err error) {
+	m.update(func() {
+		item := &task.Items[index]
+		item.Searched++
+		task.Searched++
+		if history != nil {
+			item.Found += history.ResultsApproved
+			task.Found += history.ResultsApproved
+			if history.DownloadGrabbed {
+				item.Grabbed++
+				task.Grabbed++
+			}
+		}
+		if err != nil {
+			item.Status = SearchTaskFailed
+			item.Error = err.Error()
+		} else if task.Kind != "tv_series" {
+			item.Status = SearchTaskCompleted
+		}
+	})
+}
+
+// update applies a change to a task under the lock
+func (m *ManualSearcher) update(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+}
+
+// snapshot copies a task so it can be read without the lock
+func (t *SearchTask) snapshot() SearchTask {
+	copied := *t
+	copied.Items = append([]SearchTaskItem{}, t.Items...)
+	return copied
+}
+
+// groupBySeason splits missing episodes by season, keeping their order
+func groupBySeason(episodes []MissingEpisode) [][]MissingEpisode {
+	var seasons [][]MissingEpisode
+	index := make(map[int64]int)
+	for _, episode := range episodes {
+		i, ok := index[episode.SeasonID]
+		if !ok {
+			i = len(seasons)
+			index[episode.SeasonID] = i
+			seasons = append(seasons, nil)
+		}
+		seasons[i] = append(seasons[i], episode)
+	}
+	return seasons
+}
+
+// newSearchTaskID returns a random search task ID
+func newSearchTaskID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
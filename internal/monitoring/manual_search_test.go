@@ -0,0 +1,59 @@
+package monitoring
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGroupBySeason(t *testing.T) {
+	episodes := []MissingEpisode{
+		{MediaItemID: 11, SeasonID: 1},
+		{MediaItemID: 21, SeasonID: 2},
+		{MediaItemID: 12, SeasonID: 1},
+	}
+
+	want := [][]MissingEpisode{
+		{{MediaItemID: 11, SeasonID: 1}, {MediaItemID: 12, SeasonID: 1}},
+		{{MediaItemID: 21, SeasonID: 2}},
+	}
+	if got := groupBySeason(episodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupBySeason() = %v, want %v", got, want)
+	}
+}
+
+func TestManualSearcherPrune(t *testing.T) {
+	m := &ManualSearcher{tasks: make(map[string]*SearchTask)}
+	finished := time.Now()
+	for i := 0; i < maxSearchTasks+5; i++ {
+		task := &SearchTask{ID: fmt.Sprint(i)}
+		if i != 0 {
+			task.FinishedAt = &finished
+		}
+		m.tasks[task.ID] = task
+		m.order = append(m.order, task.ID)
+	}
+
+	m.prune()
+
+	if len(m.tasks) != maxSearchTasks || len(m.order) != maxSearchTasks {
+		t.Fatalf("kept %d tasks (%d ordered), want %d", len(m.tasks), len(m.order), maxSearchTasks)
+	}
+	if _, ok := m.Get("0"); !ok {
+		t.Error("running task was pruned")
+	}
+	if _, ok := m.Get("1"); ok {
+		t.Error("oldest finished task was kept")
+	}
+}
+
+func TestSearchTaskSnapshot(t *testing.T) {
+	task := &SearchTask{Items: []SearchTaskItem{{MediaItemID: 1}}}
+	snapshot := task.snapshot()
+	task.Items[0].Grabbed = 1
+
+	if snapshot.Items[0].Grabbed != 0 {
+		t.Error("snapshot shares its items with the task")
+	}
+}
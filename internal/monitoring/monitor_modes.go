@@ -0,0 +1,297 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// modeEpisode is an episode below a series or season rule, with what monitor
+// modes decide on
+type modeEpisode struct {
+	ID      int64
+	Season  int // 0 for specials, -1 when unknown
+	Episode int // -1 when unknown
+	AirDate *time.Time
+	HasFile bool
+}
+
+// RuleCoverage counts the episodes below a series or season rule
+type RuleCoverage struct {
+	Total     int `json:"total"`
+	Monitored int `json:"monitored"`
+	Missing   int `json:"missing"` // Monitored, aired and without a file
+}
+
+// monitoredByMode decides which episodes a monitor mode monitors. The future
+// mode monitors episodes airing on or after the day the rule was created, and
+// those without an air date yet. Specials don't count as the first or latest
+// season.
+func monitoredByMode(mode MonitorMode, episodes []modeEpisode, created time.Time) map[int64]bool {
+	first, latest := -1, -1
+	for _, e := range episodes {
+		if e.Season < 1 {
+			continue
+		}
+		if first == -1 || e.Season < first {
+			first = e.Season
+		}
+		if e.Season > latest {
+			latest = e.Season
+		}
+	}
+	createdDay := created.UTC().Truncate(24 * time.Hour)
+
+	monitored := make(map[int64]bool, len(episodes))
+	for _, e := range episodes {
+		var m bool
+		switch mode {
+		case MonitorModeFuture:
+			m = e.AirDate == nil || !e.AirDate.Before(createdDay)
+		case MonitorModeMissing:
+			m = !e.HasFile
+		case MonitorModeExisting:
+			m = e.HasFile
+		case MonitorModeFirstSeason:
+			m = e.Season >= 1 && e.Season == first
+		case MonitorModeLatestSeason:
+			m = e.Season >= 1 && e.Season == latest
+		case MonitorModePilot:
+			m = e.Season == 1 && e.Episode == 1
+		case MonitorModeNone:
+			m = false
+		default:
+			m = true
+		}
+		monitored[e.ID] = m
+	}
+	return monitored
+}
+
+// ruleEpisodes lists the episodes a series or season rule applies to. Episodes
+// of a season with a rule of its own are left to that rule.
+func (s *Service) ruleEpisodes(ctx context.Context, rule *MonitoringRule) ([]modeEpisode, error) {
+	query := `
+		SELECT e.id,
+		       COALESCE(e.metadata->>'season', e.metadata->>'season_number', season.metadata->>'season_number', ''),
+		       COALESCE(e.metadata->>'episode', e.metadata->>'episode_number', ''),
+		       COALESCE(e.metadata->>'air_date', ''),
+		       EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media')
+		       OR EXISTS (
+		           SELECT 1 FROM media_file_episodes fe JOIN media_files f ON f.id = fe.media_file_id
+		           WHERE fe.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media'
+		       )
+		FROM media_items e
+		JOIN media_items season ON season.id = e.parent_id
+		WHERE e.kind = 'tv_episode'
+		  AND (season.id = $1 OR season.parent_id = $1)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM monitoring_rules sr
+		      WHERE sr.media_item_id = season.id AND sr.id <> $2
+		  )
+	`
+
+	rows, err := s.db.Query(ctx, query, rule.MediaItemID, rule.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list episodes of monitoring rule: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []modeEpisode
+	for rows.Next() {
+		var e modeEpisode
+		var season, episode, airDate string
+		if err := rows.Scan(&e.ID, &season, &episode, &airDate, &e.HasFile); err != nil {
+			return nil, fmt.Errorf("failed to scan episode: %w", err)
+		}
+		e.Season = metadataNumber(season)
+		e.Episode = metadataNumber(episode)
+		if date, ok := parseEventDate(airDate); ok {
+			e.AirDate = &date
+		}
+		episodes = append(episodes, e)
+	}
+
+	return episodes, rows.Err()
+}
+
+// metadataNumber parses a season or episode number stored in metadata, which
+// is -1 when missing
+func metadataNumber(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// ApplyMonitorMode sets whether every episode below a series or season rule is
+// monitored, according to the rule's monitor mode. It is a no-op for rules of
+// other media.
+func (s *Service) ApplyMonitorMode(ctx context.Context, rule *MonitoringRule) error {
+	_, err := s.applyMonitorMode(ctx, rule, false)
+	return err
+}
+
+// applyMonitorMode applies a rule's monitor mode to its episodes, only to those
+// not monitored or unmonitored yet when onlyNew is set. It returns the number of
+// episodes changed.
+func (s *Service) applyMonitorMode(ctx context.Context, rule *MonitoringRule, onlyNew bool) (int, error) {
+	episodes, err := s.ruleEpisodes(ctx, rule)
+	if err != nil || len(episodes) == 0 {
+		return 0, err
+	}
+
+	monitored := monitoredByMode(rule.MonitorMode, episodes, rule.CreatedAt)
+	ids := make([]int64, 0, len(monitored))
+	flags := make([]bool, 0, len(monitored))
+	for _, e := range episodes {
+		ids = append(ids, e.ID)
+		flags = append(flags, monitored[e.ID])
+	}
+
+	query := `
+		INSERT INTO episode_monitoring (media_item_id, monitored)
+		SELECT * FROM unnest($1::BIGINT[], $2::BOOLEAN[])
+		ON CONFLICT (media_item_id) DO UPDATE
+		SET monitored = EXCLUDED.monitored
+		WHERE episode_monitoring.monitored <> EXCLUDED.monitored
+	`
+	if onlyNew {
+		query = `
+			INSERT INTO episode_monitoring (media_item_id, monitored)
+			SELECT * FROM unnest($1::BIGINT[], $2::BOOLEAN[])
+			ON CONFLICT (media_item_id) DO NOTHING
+		`
+	}
+
+	result, err := s.db.Exec(ctx, query, ids, flags)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply monitor mode: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// MonitorNewEpisodes applies the monitor mode of every enabled series and season
+// rule to its episodes that have no monitoring yet, like those a metadata
+// refresh or the library scanner added. It returns the number of episodes that
+// were added.
+func (s *Service) MonitorNewEpisodes(ctx context.Context) (int, error) {
+	return s.monitorNewEpisodes(ctx, nil)
+}
+
+// MonitorNewSeriesEpisodes applies the monitor modes of a series' rules to its
+// episodes that have no monitoring yet
+func (s *Service) MonitorNewSeriesEpisodes(ctx context.Context, seriesID int64) error {
+	_, err := s.monitorNewEpisodes(ctx, &seriesID)
+	return err
+}
+
+// monitorNewEpisodes applies monitor modes to the new episodes of every series,
+// or only of one
+func (s *Service) monitorNewEpisodes(ctx context.Context, seriesID *int64) (int, error) {
+	query := `
+		SELECT DISTINCT mr.id
+		FROM monitoring_rules mr
+		JOIN media_items season ON mr.media_item_id IN (season.id, season.parent_id)
+		JOIN media_items e ON e.parent_id = season.id
+		LEFT JOIN episode_monitoring em ON em.media_item_id = e.id
+		WHERE mr.enabled = true
+		  AND season.kind = 'tv_season'
+		  AND e.kind = 'tv_episode'
+		  AND em.id IS NULL
+		  AND ($1::BIGINT IS NULL OR season.parent_id = $1)
+	`
+
+	rows, err := s.db.Query(ctx, query, seriesID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find rules with new episodes: %w", err)
+	}
+	var ruleIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan monitoring rule: %w", err)
+		}
+		ruleIDs = append(ruleIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find rules with new episodes: %w", err)
+	}
+
+	added := 0
+	for _, id := range ruleIDs {
+		rule, err := s.GetMonitoringRule(ctx, id)
+		if err != nil {
+			return added, err
+		}
+		n, err := s.applyMonitorMode(ctx, rule, true)
+		if err != nil {
+			return added, err
+		}
+		added += n
+	}
+
+	return added, nil
+}
+
+// FillRuleCoverage counts the monitored and missing episodes of series and
+// season rules. Rules of other media are left without coverage.
+func (s *Service) FillRuleCoverage(ctx context.Context, rules ...*MonitoringRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+	}
+
+	query := `
+		SELECT mr.id,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE COALESCE(em.monitored, true)),
+		       COUNT(*) FILTER (
+		           WHERE COALESCE(em.monitored, true)
+		             AND (e.metadata->>'air_date' IS NULL OR e.metadata->>'air_date' <= TO_CHAR(CURRENT_DATE, 'YYYY-MM-DD'))
+		             AND NOT EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media')
+		             AND NOT EXISTS (
+		                 SELECT 1 FROM media_file_episodes fe JOIN media_files f ON f.id = fe.media_file_id
+		                 WHERE fe.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media'
+		             )
+		       )
+		FROM monitoring_rules mr
+		JOIN media_items season ON mr.media_item_id IN (season.id, season.parent_id)
+		JOIN media_items e ON e.parent_id = season.id
+		LEFT JOIN episode_monitoring em ON em.media_item_id = e.id
+		WHERE mr.id = ANY($1)
+		  AND season.kind = 'tv_season'
+		  AND e.kind = 'tv_episode'
+		GROUP BY mr.id
+	`
+
+	rows, err := s.db.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("failed to count episodes of monitoring rules: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*MonitoringRule, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+	for rows.Next() {
+		var id int64
+		var coverage RuleCoverage
+		if err := rows.Scan(&id, &coverage.Total, &coverage.Monitored, &coverage.Missing); err != nil {
+			return fmt.Errorf("failed to scan episode counts: %w", err)
+		}
+		if rule := byID[id]; rule != nil {
+			rule.Episodes = &coverage
+		}
+	}
+
+	return rows.Err()
+}
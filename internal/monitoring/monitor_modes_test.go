@@ -0,0 +1,55 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonitoredByMode(t *testing.T) {
+	day := func(s string) *time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return &d
+	}
+	episodes := []modeEpisode{
+		{ID: 1, Season: 0, Episode: 1, AirDate: day("2019-12-01"), HasFile: true},
+		{ID: 2, Season: 1, Episode: 1, AirDate: day("2020-01-01"), HasFile: true},
+		{ID: 3, Season: 1, Episode: 2, AirDate: day("2020-01-08")},
+		{ID: 4, Season: 2, Episode: 1, AirDate: day("2024-03-01"), HasFile: true},
+		{ID: 5, Season: 2, Episode: 2, AirDate: day("2024-03-08")},
+		{ID: 6, Season: 2, Episode: 3},
+	}
+	created := time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		mode MonitorMode
+		want []int64
+	}{
+		{MonitorModeAll, []int64{1, 2, 3, 4, 5, 6}},
+		{"", []int64{1, 2, 3, 4, 5, 6}},
+		{MonitorModeFuture, []int64{5, 6}},
+		{MonitorModeMissing, []int64{3, 5, 6}},
+		{MonitorModeExisting, []int64{1, 2, 4}},
+		{MonitorModeFirstSeason, []int64{2, 3}},
+		{MonitorModeLatestSeason, []int64{4, 5, 6}},
+		{MonitorModePilot, []int64{2}},
+		{MonitorModeNone, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := monitoredByMode(tt.mode, episodes, created)
+			if len(got) != len(episodes) {
+				t.Fatalf("got %d episodes, want %d", len(got), len(episodes))
+			}
+			want := make(map[int64]bool)
+			for _, id := range tt.want {
+				want[id] = true
+			}
+			for _, e := range episodes {
+				if got[e.ID] != want[e.ID] {
+					t.Errorf("episode %d monitored = %v, want %v", e.ID, got[e.ID], want[e.ID])
+				}
+			}
+		})
+	}
+}
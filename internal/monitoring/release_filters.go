@@ -0,0 +1,388 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+// bytesPerMB converts the megabyte limits to release sizes
+const bytesPerMB = 1024 * 1024
+
+// RejectionReason is why a search result is not grabbed
+type RejectionReason string
+
+const (
+	RejectionBlocked  RejectionReason = "blocked"
+	RejectionQuality  RejectionReason = "quality not allowed"
+	RejectionTooSmall RejectionReason = "too small"
+	RejectionTooLarge RejectionReason = "too large"
+	RejectionTooOld   RejectionReason = "too old"
+	RejectionSeeders  RejectionReason = "too few seeders"
+)
+
+// rejectionOrder is the order reasons are listed in rejection summaries
+var rejectionOrder = []RejectionReason{
+	RejectionTooSmall,
+	RejectionTooLarge,
+	RejectionTooOld,
+	RejectionBlocked,
+	RejectionQuality,
+	RejectionSeeders,
+}
+
+// ReleaseLimits are the size and age limits every search result must meet on
+// top of the size limits of its quality. Sizes per minute of runtime are used
+// when the runtime of the media is known, the absolute sizes otherwise. Zero
+// means no limit.
+type ReleaseLimits struct {
+	MinSizeMB      int64
+	MaxSizeMB      int64
+	MinMBPerMinute float64
+	MaxMBPerMinute float64
+	MaxAge         time.Duration // Only applied to releases grabbed from RSS feeds
+}
+
+// SetReleaseLimits sets the size and age limits for search results
+func (a *AutoSearcher) SetReleaseLimits(limits ReleaseLimits) {
+	a.limits = limits
+}
+
+// Rejections counts rejected search results by their first rejection reason
+type Rejections map[RejectionReason]int
+
+// Total returns the number of rejected releases
+func (r Rejections) Total() int {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// Summary describes the rejections, e.g.
+// "rejected: 14 releases — 6 too small, 3 too large, 5 blocked"
+func (r Rejections) Summary() string {
+	total := r.Total()
+	if total == 0 {
+		return ""
+	}
+
+	var parts []string
+	for _, reason := range rejectionOrder {
+		if n := r[reason]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, reason))
+		}
+	}
+
+	noun := "releases"
+	if total == 1 {
+		noun = "release"
+	}
+	return fmt.Sprintf("rejected: %d %s — %s", total, noun, strings.Join(parts, ", "))
+}
+
+// RecordRejections stores the rejection counts and their summary in the search
+// history
+func (h *SearchHistory) RecordRejections(rejections Rejections) {
+	h.ResultsRejected += rejections.Total()
+	if len(rejections) == 0 {
+		return
+	}
+	if h.Metadata == nil {
+		h.Metadata = make(map[string]interface{})
+	}
+
+	counts := make(map[string]int, len(rejections))
+	for reason, n := range rejections {
+		counts[string(reason)] = n
+	}
+	h.Metadata["rejections"] = counts
+	h.Metadata["rejection_summary"] = rejections.Summary()
+}
+
+// EvaluatedRelease is a search result annotated with whether the automatic
+// search would accept it, and why not
+type EvaluatedRelease struct {
+	plugins.IndexerRelease
+	Score      quality.ReleaseScore `json:"score"`
+	Accepted   bool                 `json:"accepted"`
+	Rejections []RejectionReason    `json:"rejections,omitempty"`
+}
+
+// sizeRange is the range of release sizes in bytes a release must fall in.
+// A zero bound is unlimited.
+type sizeRange struct {
+	min, max int64
+}
+
+// mediaLength is how much media a release for a media item holds
+type mediaLength struct {
+	runtime int // Minutes, 0 when unknown
+	items   int // Movies or episodes, scaling the absolute size limits
+}
+
+// limitRange works out a size range from limits per minute of runtime, or from
+// absolute limits in bytes for each item when the runtime is unknown
+func limitRange(minPerMinute, maxPerMinute float64, minSize, maxSize int64, length mediaLength) sizeRange {
+	items := int64(length.items)
+	if items < 1 {
+		items = 1
+	}
+
+	var r sizeRange
+	if length.runtime > 0 && minPerMinute > 0 {
+		r.min = int64(minPerMinute * float64(length.runtime) * bytesPerMB)
+	} else if minSize > 0 {
+		r.min = minSize * items
+	}
+	if length.runtime > 0 && maxPerMinute > 0 {
+		r.max = int64(maxPerMinute * float64(length.runtime) * bytesPerMB)
+	} else if maxSize > 0 {
+		r.max = maxSize * items
+	}
+	return r
+}
+
+// intersect narrows the range to the part that is also inside other
+func (r sizeRange) intersect(other sizeRange) sizeRange {
+	if other.min > r.min {
+		r.min = other.min
+	}
+	if other.max > 0 && (r.max == 0 || other.max < r.max) {
+		r.max = other.max
+	}
+	return r
+}
+
+// check returns why a release of size bytes is outside the range, or "" when
+// it fits. Releases of unknown size always fit.
+func (r sizeRange) check(size int64) RejectionReason {
+	switch {
+	case size <= 0:
+		return ""
+	case r.min > 0 && size < r.min:
+		return RejectionTooSmall
+	case r.max > 0 && size > r.max:
+		return RejectionTooLarge
+	}
+	return ""
+}
+
+// sizeRange returns the size range for a release of a quality, which must meet
+// both the global limits and those of the quality
+func (l ReleaseLimits) sizeRange(def *quality.QualityDefinition, length mediaLength) sizeRange {
+	r := limitRange(l.MinMBPerMinute, l.MaxMBPerMinute, l.MinSizeMB*bytesPerMB, l.MaxSizeMB*bytesPerMB, length)
+	if def == nil {
+		return r
+	}
+
+	var minPerMinute, maxPerMinute float64
+	var minSize, maxSize int64
+	if def.MinMBPerMinute != nil {
+		minPerMinute = *def.MinMBPerMinute
+	}
+	if def.MaxMBPerMinute != nil {
+		maxPerMinute = *def.MaxMBPerMinute
+	}
+	if def.MinSize != nil {
+		minSize = *def.MinSize
+	}
+	if def.MaxSize != nil {
+		maxSize = *def.MaxSize
+	}
+	return r.intersect(limitRange(minPerMinute, maxPerMinute, minSize, maxSize, length))
+}
+
+// releaseEvaluator checks search results against everything that can reject
+// them for a media item
+type releaseEvaluator struct {
+	monitoringSvc  *Service
+	mediaItemID    *int64
+	allowed        map[int]bool
+	minimumSeeders int
+	limits         ReleaseLimits
+	length         mediaLength
+	maxAge         time.Duration
+	now            time.Time
+}
+
+// newReleaseEvaluator prepares the checks for search results for a media item,
+// or for no media item when mediaItemID is nil. The age limit only applies to
+// RSS searches.
+func (a *AutoSearcher) newReleaseEvaluator(ctx context.Context, rule *MonitoringRule, mediaItemID *int64, searchType SearchType) (*releaseEvaluator, error) {
+	allowed, err := a.allowedQualities(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &releaseEvaluator{
+		monitoringSvc: a.monitoringSvc,
+		mediaItemID:   mediaItemID,
+		allowed:       allowed,
+		limits:        a.limits,
+		now:           time.Now(),
+	}
+	if rule != nil {
+		e.minimumSeeders = rule.MinimumSeeders
+	}
+	if searchType == SearchTypeRSS {
+		e.maxAge = a.limits.MaxAge
+	}
+	if mediaItemID != nil {
+		e.length = a.mediaLength(ctx, *mediaItemID)
+	}
+	return e, nil
+}
+
+// reasons returns every reason a release is rejected for, most decisive first,
+// or nil when it is acceptable
+func (e *releaseEvaluator) reasons(ctx context.Context, candidate ScoredRelease) ([]RejectionReason, error) {
+	var reasons []RejectionReason
+
+	blocked, err := e.monitoringSvc.IsBlocked(ctx, ReleaseHash(candidate.Release), e.mediaItemID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		reasons = append(reasons, RejectionBlocked)
+	}
+
+	if e.allowed != nil && (candidate.Score.Quality == nil || !e.allowed[candidate.Score.Quality.ID]) {
+		reasons = append(reasons, RejectionQuality)
+	}
+
+	if reason := e.limits.sizeRange(candidate.Score.Quality, e.length).check(candidate.Release.Size); reason != "" {
+		reasons = append(reasons, reason)
+	}
+
+	published := candidate.Release.PublishDate
+	if e.maxAge > 0 && !published.IsZero() && e.now.Sub(published) > e.maxAge {
+		reasons = append(reasons, RejectionTooOld)
+	}
+
+	if e.minimumSeeders > 0 {
+		if seeders, err := strconv.Atoi(candidate.Release.Attributes["seeders"]); err == nil && seeders < e.minimumSeeders {
+			reasons = append(reasons, RejectionSeeders)
+		}
+	}
+
+	return reasons, nil
+}
+
+// EvaluateReleases annotates search results with whether the automatic search
+// would grab them for a media item, and why not, keeping their order. Without a
+// media item only the blocklist and the size limits for an unknown runtime apply.
+func (a *AutoSearcher) EvaluateReleases(ctx context.Context, mediaItemID *int64, releases []plugins.IndexerRelease) ([]EvaluatedRelease, error) {
+	var rule *MonitoringRule
+	if mediaItemID != nil {
+		var err error
+		rule, err = a.monitoringSvc.GetEffectiveMonitoringRule(ctx, *mediaItemID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scorer, err := a.qualitySvc.NewReleaseScorer(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load release scorer: %w", err)
+	}
+	evaluator, err := a.newReleaseEvaluator(ctx, rule, mediaItemID, SearchTypeManual)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated := make([]EvaluatedRelease, 0, len(releases))
+	for _, release := range releases {
+		candidate := ScoredRelease{Release: release, Score: scorer.Score(release.Title, release.Attributes)}
+		reasons, err := evaluator.reasons(ctx, candidate)
+		if err != nil {
+			return nil, err
+		}
+		evaluated = append(evaluated, EvaluatedRelease{
+			IndexerRelease: release,
+			Score:          candidate.Score,
+			Accepted:       len(reasons) == 0,
+			Rejections:     reasons,
+		})
+	}
+
+	return evaluated, nil
+}
+
+// mediaLength works out how much media a release for a media item holds: one
+// movie or episode, or every episode of a season. Episodes without a runtime
+// of their own use the series runtime. Lookup failures leave the runtime
+// unknown so the absolute size limits apply.
+func (a *AutoSearcher) mediaLength(ctx context.Context, mediaItemID int64) mediaLength {
+	length := mediaLength{items: 1}
+
+	media, err := a.queries.GetMediaItem(ctx, mediaItemID)
+	if err != nil {
+		return length
+	}
+
+	switch media.Kind {
+	case "tv_episode":
+		length.runtime = metadataRuntime(media.Metadata)
+		if length.runtime == 0 && media.ParentID != nil {
+			if season, err := a.queries.GetMediaItem(ctx, *media.ParentID); err == nil {
+				length.runtime = a.seriesRuntime(ctx, season)
+			}
+		}
+	case "tv_season":
+		episodes, err := a.queries.ListChildMediaItems(ctx, &media.ID)
+		if err != nil || len(episodes) == 0 {
+			return length
+		}
+		length.items = len(episodes)
+
+		seriesRuntime := a.seriesRuntime(ctx, media)
+		for _, episode := range episodes {
+			runtime := metadataRuntime(episode.Metadata)
+			if runtime == 0 {
+				runtime = seriesRuntime
+			}
+			if runtime == 0 {
+				length.runtime = 0
+				break
+			}
+			length.runtime += runtime
+		}
+	default:
+		length.runtime = metadataRuntime(media.Metadata)
+	}
+
+	return length
+}
+
+// seriesRuntime returns the episode runtime of the series a season belongs to,
+// or 0 when it is unknown
+func (a *AutoSearcher) seriesRuntime(ctx context.Context, season generated.MediaItem) int {
+	if season.ParentID == nil {
+		return 0
+	}
+	series, err := a.queries.GetMediaItem(ctx, *season.ParentID)
+	if err != nil {
+		return 0
+	}
+	return metadataRuntime(series.Metadata)
+}
+
+// metadataRuntime returns the runtime in minutes from media item metadata, or 0
+func metadataRuntime(raw []byte) int {
+	var metadata struct {
+		Runtime int `json:"runtime"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &metadata) != nil {
+		return 0
+	}
+	return metadata.Runtime
+}
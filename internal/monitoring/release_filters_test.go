@@ -0,0 +1,104 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+func TestRejectionsSummary(t *testing.T) {
+	rejections := Rejections{
+		RejectionBlocked:  5,
+		RejectionTooSmall: 6,
+		RejectionTooLarge: 3,
+	}
+	if got := rejections.Total(); got != 14 {
+		t.Errorf("Total() = %d, want 14", got)
+	}
+	if got, want := rejections.Summary(), "rejected: 14 releases — 6 too small, 3 too large, 5 blocked"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	if got, want := (Rejections{RejectionTooOld: 1}).Summary(), "rejected: 1 release — 1 too old"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if got := (Rejections{}).Summary(); got != "" {
+		t.Errorf("Summary() of no rejections = %q, want empty", got)
+	}
+}
+
+func TestRecordRejections(t *testing.T) {
+	history := &SearchHistory{ResultsRejected: 2}
+	history.RecordRejections(Rejections{RejectionTooLarge: 3})
+
+	if history.ResultsRejected != 5 {
+		t.Errorf("ResultsRejected = %d, want 5", history.ResultsRejected)
+	}
+	if counts, _ := history.Metadata["rejections"].(map[string]int); counts["too large"] != 3 {
+		t.Errorf("rejections = %v, want 3 too large", history.Metadata["rejections"])
+	}
+	if history.Metadata["rejection_summary"] != "rejected: 3 releases — 3 too large" {
+		t.Errorf("rejection_summary = %v", history.Metadata["rejection_summary"])
+	}
+}
+
+func TestReleaseLimitsSizeRange(t *testing.T) {
+	const gb = 1024 * bytesPerMB
+	minSize, maxSize := int64(1*gb), int64(10*gb)
+	minRate, maxRate := 20.0, 100.0
+	hd := &quality.QualityDefinition{
+		Name:           "Bluray-1080p",
+		MinSize:        &minSize,
+		MaxSize:        &maxSize,
+		MinMBPerMinute: &minRate,
+		MaxMBPerMinute: &maxRate,
+	}
+	movie := mediaLength{runtime: 120, items: 1}
+
+	tests := []struct {
+		name   string
+		limits ReleaseLimits
+		def    *quality.QualityDefinition
+		length mediaLength
+		size   int64
+		want   RejectionReason
+	}{
+		{"no limits", ReleaseLimits{}, nil, movie, 500 * gb, ""},
+		{"unknown size", ReleaseLimits{MinSizeMB: 100}, hd, movie, 0, ""},
+		{"junk 1080p by runtime", ReleaseLimits{}, hd, movie, 2 * gb, RejectionTooSmall},
+		{"fits runtime", ReleaseLimits{}, hd, movie, 8 * gb, ""},
+		{"oversized by runtime", ReleaseLimits{}, hd, movie, 12 * gb, RejectionTooLarge},
+		{"absolute fallback", ReleaseLimits{}, hd, mediaLength{items: 1}, 12 * gb, RejectionTooLarge},
+		{"absolute fallback fits", ReleaseLimits{}, hd, mediaLength{items: 1}, 8 * gb, ""},
+		{"absolute limits scale with episodes", ReleaseLimits{}, hd, mediaLength{items: 10}, 50 * gb, ""},
+		{"global limit is stricter", ReleaseLimits{MaxSizeMB: 5 * 1024}, hd, mediaLength{items: 1}, 8 * gb, RejectionTooLarge},
+		{"global per minute", ReleaseLimits{MaxMBPerMinute: 50}, nil, movie, 8 * gb, RejectionTooLarge},
+		{"quality is stricter than global", ReleaseLimits{MinSizeMB: 100}, hd, movie, 1 * gb, RejectionTooSmall},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.sizeRange(tt.def, tt.length).check(tt.size); got != tt.want {
+				t.Errorf("check(%d) = %q, want %q", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataRuntime(t *testing.T) {
+	tests := []struct {
+		metadata string
+		want     int
+	}{
+		{`{"runtime": 42}`, 42},
+		{`{"title": "Pilot"}`, 0},
+		{`{"runtime": "unknown"}`, 0},
+		{``, 0},
+	}
+
+	for _, tt := range tests {
+		if got := metadataRuntime([]byte(tt.metadata)); got != tt.want {
+			t.Errorf("metadataRuntime(%q) = %d, want %d", tt.metadata, got, tt.want)
+		}
+	}
+}
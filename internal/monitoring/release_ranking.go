@@ -0,0 +1,86 @@
+package monitoring
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+// ScoredRelease is an indexer release together with its computed score
+type ScoredRelease struct {
+	Release plugins.IndexerRelease `json:"release"`
+	Score   quality.ReleaseScore   `json:"score"`
+}
+
+// RankReleases scores every release and returns them sorted best first.
+// Ties on total score are broken by quality score, then by the newest release.
+func RankReleases(scorer *quality.ReleaseScorer, releases []plugins.IndexerRelease) []ScoredRelease {
+	ranked := make([]ScoredRelease, 0, len(releases))
+	for _, release := range releases {
+		ranked = append(ranked, ScoredRelease{
+			Release: release,
+			Score:   scorer.Score(release.Title, release.Attributes),
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score.Total != b.Score.Total {
+			return a.Score.Total > b.Score.Total
+		}
+		if a.Score.QualityScore != b.Score.QualityScore {
+			return a.Score.QualityScore > b.Score.QualityScore
+		}
+		return a.Release.PublishDate.After(b.Release.PublishDate)
+	})
+
+	return ranked
+}
+
+// RecordGrabbedRelease stores the winning release and the reason it won in the
+// search history metadata
+func (h *SearchHistory) RecordGrabbedRelease(winner ScoredRelease) {
+	if h.Metadata == nil {
+		h.Metadata = make(map[string]interface{})
+	}
+
+	grabbed := map[string]interface{}{
+		"title":           winner.Release.Title,
+		"guid":            winner.Release.GUID,
+		"indexer_id":      winner.Release.IndexerID,
+		"size":            winner.Release.Size,
+		"score":           winner.Score.Total,
+		"quality_score":   winner.Score.QualityScore,
+		"format_score":    winner.Score.FormatScore,
+		"matched_formats": winner.Score.MatchedFormats,
+	}
+	if winner.Score.Quality != nil {
+		grabbed["quality"] = winner.Score.Quality.Name
+	}
+	if winner.Score.ReleaseGroup != "" {
+		grabbed["release_group"] = winner.Score.ReleaseGroup
+	}
+
+	h.Metadata["grabbed_release"] = grabbed
+}
+
+// ReleaseHash identifies a release for the blocklist. The indexer GUID is used
+// when present, otherwise a hash of the download URL, or of the indexer and
+// normalized title when the release has no URL.
+func ReleaseHash(release plugins.IndexerRelease) string {
+	if release.GUID != "" {
+		return release.GUID
+	}
+
+	key := release.DownloadURL
+	if key == "" {
+		key = release.IndexerID + "|" + strings.ToLower(strings.TrimSpace(release.Title))
+	}
+
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
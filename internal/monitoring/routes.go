@@ -14,11 +14,34 @@ func SetupRoutes(r chi.Router, handler *Handler) {
 		r.Put("/{id}", handler.UpdateMonitoringRule)
 		r.Delete("/{id}", handler.DeleteMonitoringRule)
 
+		// Bulk edits of many rules at once
+		r.Put("/rules/bulk", handler.BulkUpdateMonitoringRules)
+		r.Post("/rules/bulk/delete", handler.BulkDeleteMonitoringRules)
+		r.Post("/rules/bulk/search", handler.BulkSearchMonitoringRules)
+
+		// Templates of rule settings picked when adding media
+		r.Route("/templates", func(r chi.Router) {
+			r.Get("/", handler.ListMonitoringTemplates)
+			r.Post("/", handler.CreateMonitoringTemplate)
+			r.Get("/{id}", handler.GetMonitoringTemplate)
+			r.Put("/{id}", handler.UpdateMonitoringTemplate)
+			r.Delete("/{id}", handler.DeleteMonitoringTemplate)
+			r.Post("/{id}/apply", handler.ApplyMonitoringTemplate)
+		})
+
 		// Statistics
 		r.Get("/stats", handler.GetMonitoringStats)
 
 		// Missing episodes/wanted items
 		r.Get("/missing", handler.GetMissingEpisodes)
+
+		// Backlog search
+		r.Post("/backlog-search", handler.StartBacklogSearch)
+		r.Get("/backlog-search/status", handler.GetBacklogSearchStatus)
+		r.Delete("/backlog-search", handler.CancelBacklogSearch)
+
+		// RSS sync state per indexer
+		r.Get("/rss", handler.ListRSSSyncStates)
 	})
 
 	// Media-specific monitoring routes
@@ -27,16 +50,56 @@ func SetupRoutes(r chi.Router, handler *Handler) {
 		r.Get("/history", handler.GetSearchHistory)
 	})
 
-	// Calendar
+	// Calendar. The feed token is shared by every user, so only admins can
+	// regenerate it (see SetupSettingsRoutes).
 	r.Get("/calendar", handler.GetCalendarEvents)
+	r.Get("/calendar/feed-token", handler.GetCalendarFeedToken)
+
+	// Progress of media searches started with POST /media/{id}/search
+	r.Get("/tasks/{id}", handler.GetSearchTask)
 
 	// Blocklist
-	r.Post("/blocklist", handler.CreateBlocklistEntry)
+	r.Route("/blocklist", func(r chi.Router) {
+		r.Get("/", handler.ListBlocklist)
+		r.Post("/", handler.CreateBlocklistEntry)
+		r.Delete("/{id}", handler.DeleteBlocklistEntry)
+	})
+}
 
-	// Scheduler jobs
+// SetupSettingsRoutes configures the monitoring defaults and calendar feed
+// token routes, which must be mounted behind the admin middleware
+func SetupSettingsRoutes(r chi.Router, handler *Handler) {
+	r.Route("/settings/monitoring-defaults", func(r chi.Router) {
+		r.Get("/", handler.GetMonitoringDefaults)
+		r.Put("/", handler.UpdateMonitoringDefaults)
+	})
+
+	// Regenerating the token breaks every user's calendar subscriptions
+	r.Post("/calendar/feed-token", handler.RegenerateCalendarFeedToken)
+}
+
+// SetupTaskRoutes configures the system task routes, which list, run and
+// schedule the scheduler jobs by name, and the older scheduler job routes.
+// They must be mounted behind the admin middleware.
+func SetupTaskRoutes(r chi.Router, handler *Handler) {
 	r.Route("/scheduler", func(r chi.Router) {
 		r.Get("/jobs", handler.ListSchedulerJobs)
 		r.Get("/jobs/{id}", handler.GetSchedulerJob)
 		r.Post("/jobs/{id}/trigger", handler.TriggerSchedulerJob)
 	})
+
+	r.Route("/system/tasks", func(r chi.Router) {
+		r.Get("/", handler.ListTasks)
+		r.Get("/{name}", handler.GetTask)
+		r.Put("/{name}", handler.UpdateTask)
+		r.Post("/{name}/run", handler.RunTask)
+		r.Get("/{name}/history", handler.ListTaskHistory)
+	})
+}
+
+// SetupPublicRoutes configures monitoring routes that authorize requests themselves
+// and must be mounted outside the auth middleware
+func SetupPublicRoutes(r chi.Router, handler *Handler) {
+	// iCal feed, authorized by the feed token
+	r.Get("/calendar/feed.ics", handler.GetCalendarFeed)
 }
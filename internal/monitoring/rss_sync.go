@@ -0,0 +1,652 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/indexer"
+	"github.com/blakestevenson/nimbus/internal/library"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+const (
+	// rssGrabSource marks downloads grabbed from RSS feeds in their metadata
+	rssGrabSource = "rss"
+
+	// defaultRSSJitter is the longest an indexer's feed is fetched after the
+	// sync starts, so installs syncing at the same time don't all hit an
+	// indexer at once
+	defaultRSSJitter = time.Minute
+
+	// defaultRSSFeedLimit is how many of the newest releases are read from a feed
+	defaultRSSFeedLimit = 100
+
+	// maxRSSSeenGUIDs caps the GUIDs kept as the high-water mark of a feed
+	maxRSSSeenGUIDs = 1000
+
+	// maxRSSWantedEpisodes caps the missing episodes of a series matched per sync
+	maxRSSWantedEpisodes = 1000
+)
+
+var (
+	// rssTitleSeparatorPattern matches the characters titles are split into words on
+	rssTitleSeparatorPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// rssTrailingYearPattern matches a year in parentheses at the end of a title
+	rssTrailingYearPattern = regexp.MustCompile(`\s*\((\d{4})\)\s*$`)
+)
+
+// RSSSyncResult is the outcome of one RSS sync
+type RSSSyncResult struct {
+	Indexers int `json:"indexers"`
+	Failed   int `json:"failed"`
+	Found    int `json:"found"`   // New releases across all feeds
+	Matched  int `json:"matched"` // Monitored media items with a new release
+	Grabbed  int `json:"grabbed"`
+}
+
+// RSSSync polls the RSS feeds of the indexer plugins and grabs new releases of
+// monitored media that is missing or can be upgraded, so releases are grabbed
+// soon after they are posted rather than at the next scheduled search
+type RSSSync struct {
+	searcher *AutoSearcher
+	logger   *zap.Logger
+	jitter   time.Duration
+
+	mu sync.Mutex // Held while a sync is in progress
+}
+
+// NewRSSSync creates an RSS sync on top of an automatic searcher
+func NewRSSSync(searcher *AutoSearcher, logger *zap.Logger) *RSSSync {
+	return &RSSSync{
+		searcher: searcher,
+		logger:   logger.With(zap.String("component", "rss-sync")),
+		jitter:   defaultRSSJitter,
+	}
+}
+
+// HandleJob runs a sync as the rss_sync scheduler job. The job config may set
+// max_items_per_sync and jitter_seconds.
+func (r *RSSSync) HandleJob(ctx context.Context, job *SchedulerJob) error {
+	limit := defaultRSSFeedLimit
+	if val, ok := job.Config["max_items_per_sync"].(float64); ok && val > 0 {
+		limit = int(val)
+	}
+	jitter := r.jitter
+	if val, ok := job.Config["jitter_seconds"].(float64); ok && val >= 0 {
+		jitter = time.Duration(val) * time.Second
+	}
+
+	var nextSyncAt *time.Time
+	if job.IntervalMinutes != nil {
+		next := time.Now().Add(time.Duration(*job.IntervalMinutes) * time.Minute)
+		nextSyncAt = &next
+	}
+
+	result, err := r.Sync(ctx, limit, jitter, nextSyncAt)
+	if err != nil {
+		return err
+	}
+	r.logger.Info("RSS sync finished",
+		zap.Int("indexers", result.Indexers),
+		zap.Int("failed", result.Failed),
+		zap.Int("found", result.Found),
+		zap.Int("grabbed", result.Grabbed))
+	return nil
+}
+
+// rssFeed is the new releases of one indexer's feed in a sync
+type rssFeed struct {
+	indexerID   string
+	pluginID    string
+	indexerName string
+	releases    []plugins.IndexerRelease
+	fresh       []plugins.IndexerRelease
+	grabbed     int
+}
+
+// Sync fetches every RSS feed, each after a random delay of up to jitter, and
+// grabs the new releases of wanted media. A failing feed is recorded and
+// skipped; the sync only fails when every feed does.
+func (r *RSSSync) Sync(ctx context.Context, limit int, jitter time.Duration, nextSyncAt *time.Time) (*RSSSyncResult, error) {
+	if !r.mu.TryLock() {
+		return nil, fmt.Errorf("an RSS sync is already running")
+	}
+	defer r.mu.Unlock()
+
+	result := &RSSSyncResult{}
+	indexers := r.searcher.indexerSvc.ListRSSIndexers()
+	if len(indexers) == 0 {
+		return result, nil
+	}
+
+	type fetched struct {
+		plugin indexer.IndexerInfo
+		feed   *indexer.RSSFeed
+		err    error
+	}
+	results := make([]fetched, len(indexers))
+	var wg sync.WaitGroup
+	for i, info := range indexers {
+		wg.Add(1)
+		go func(i int, info indexer.IndexerInfo) {
+			defer wg.Done()
+			results[i].plugin = info
+			if jitter > 0 {
+				select {
+				case <-time.After(time.Duration(rand.Int63n(int64(jitter)))):
+				case <-ctx.Done():
+					results[i].err = ctx.Err()
+					return
+				}
+			}
+			results[i].feed, results[i].err = r.searcher.indexerSvc.FetchRSS(ctx, info.ID, nil, limit)
+		}(i, info)
+	}
+	wg.Wait()
+
+	var feeds []*rssFeed
+	for _, res := range results {
+		if res.err != nil {
+			result.Indexers++
+			result.Failed++
+			r.recordFailure(ctx, res.plugin.ID, res.plugin.ID, res.plugin.Name, res.err.Error(), nextSyncAt)
+			continue
+		}
+
+		for _, feedIndexer := range res.feed.Indexers {
+			if feedIndexer.Error != "" {
+				result.Indexers++
+				result.Failed++
+				r.recordFailure(ctx, feedIndexer.ID, res.plugin.ID, feedIndexer.Name, feedIndexer.Error, nextSyncAt)
+			}
+		}
+		for _, feed := range splitRSSFeed(res.plugin, res.feed) {
+			state, err := r.searcher.monitoringSvc.GetRSSSyncState(ctx, feed.indexerID)
+			if err != nil {
+				return nil, err
+			}
+			if state != nil && !state.Enabled {
+				continue
+			}
+			feed.fresh = newRSSReleases(state, feed.releases)
+			result.Indexers++
+			result.Found += len(feed.fresh)
+			feeds = append(feeds, feed)
+		}
+	}
+
+	if err := r.grabWanted(ctx, feeds, result); err != nil {
+		return nil, err
+	}
+
+	for _, feed := range feeds {
+		mark, seen := rssHighWaterMark(feed.releases)
+		err := r.searcher.monitoringSvc.RecordRSSSync(ctx, RecordRSSSyncParams{
+			IndexerID:     feed.indexerID,
+			PluginID:      feed.pluginID,
+			IndexerName:   feed.indexerName,
+			NextSyncAt:    nextSyncAt,
+			ItemsFound:    len(feed.fresh),
+			ItemsGrabbed:  feed.grabbed,
+			LastReleaseAt: mark,
+			SeenGUIDs:     seen,
+		})
+		if err != nil {
+			r.logger.Error("Failed to record RSS sync", zap.String("indexer_id", feed.indexerID), zap.Error(err))
+		}
+	}
+
+	if result.Failed > 0 && result.Failed == result.Indexers {
+		return result, fmt.Errorf("all %d RSS feeds failed", result.Failed)
+	}
+	return result, nil
+}
+
+// recordFailure records a failed feed and logs it
+func (r *RSSSync) recordFailure(ctx context.Context, indexerID, pluginID, name, message string, nextSyncAt *time.Time) {
+	r.logger.Warn("RSS feed failed",
+		zap.String("indexer_id", indexerID),
+		zap.String("plugin_id", pluginID),
+		zap.String("error", message))
+	if err := r.searcher.monitoringSvc.RecordRSSSyncFailure(ctx, indexerID, pluginID, name, message, nextSyncAt); err != nil {
+		r.logger.Error("Failed to record RSS sync failure", zap.String("indexer_id", indexerID), zap.Error(err))
+	}
+}
+
+// splitRSSFeed splits a plugin's feed into the feeds of its indexers. Releases
+// without an indexer, and plugins that don't report their indexers, form one
+// feed named after the plugin. Indexers whose feed failed are left out.
+func splitRSSFeed(plugin indexer.IndexerInfo, feed *indexer.RSSFeed) []*rssFeed {
+	var feeds []*rssFeed
+	byID := make(map[string]*rssFeed)
+	add := func(id, name string) *rssFeed {
+		if f, ok := byID[id]; ok {
+			return f
+		}
+		f := &rssFeed{indexerID: id, pluginID: plugin.ID, indexerName: name}
+		byID[id] = f
+		feeds = append(feeds, f)
+		return f
+	}
+
+	failed := make(map[string]bool)
+	for _, feedIndexer := range feed.Indexers {
+		if feedIndexer.Error != "" {
+			failed[feedIndexer.ID] = true
+			continue
+		}
+		add(feedIndexer.ID, feedIndexer.Name)
+	}
+	if len(feed.Indexers) == 0 {
+		add(plugin.ID, plugin.Name)
+	}
+
+	for _, release := range feed.Releases {
+		id, name := release.IndexerID, release.IndexerName
+		if id == "" || len(feed.Indexers) == 0 {
+			id, name = plugin.ID, plugin.Name
+		}
+		if failed[id] {
+			continue
+		}
+		f := add(id, name)
+		f.releases = append(f.releases, release)
+	}
+
+	return feeds
+}
+
+// newRSSReleases returns the releases of a feed that weren't in it at the last
+// sync. Releases older than the newest one seen before are taken to have
+// dropped out of the remembered GUIDs rather than being new.
+func newRSSReleases(state *RSSSyncState, releases []plugins.IndexerRelease) []plugins.IndexerRelease {
+	if state == nil {
+		return releases
+	}
+
+	seen := make(map[string]bool, len(state.SeenGUIDs))
+	for _, guid := range state.SeenGUIDs {
+		seen[guid] = true
+	}
+
+	var fresh []plugins.IndexerRelease
+	for _, release := range releases {
+		if seen[ReleaseHash(release)] {
+			continue
+		}
+		if state.LastReleaseAt != nil && !release.PublishDate.IsZero() && release.PublishDate.Before(*state.LastReleaseAt) {
+			continue
+		}
+		fresh = append(fresh, release)
+	}
+	return fresh
+}
+
+// rssHighWaterMark returns the newest publish date of a feed and the GUIDs of
+// its releases, or a nil date when no release has one
+func rssHighWaterMark(releases []plugins.IndexerRelease) (*time.Time, []string) {
+	var newest *time.Time
+	seen := make([]string, 0, len(releases))
+	for _, release := range releases {
+		if len(seen) < maxRSSSeenGUIDs {
+			seen = append(seen, ReleaseHash(release))
+		}
+		if published := release.PublishDate; !published.IsZero() && (newest == nil || published.After(*newest)) {
+			newest = &published
+		}
+	}
+	return newest, seen
+}
+
+// rssCandidate is a monitored media item and the new releases matching it
+type rssCandidate struct {
+	media    generated.MediaItem
+	releases []plugins.IndexerRelease
+}
+
+// grabWanted matches the new releases of all feeds against monitored media and
+// grabs the best release of every item that is missing or can be upgraded
+func (r *RSSSync) grabWanted(ctx context.Context, feeds []*rssFeed, result *RSSSyncResult) error {
+	var fresh []plugins.IndexerRelease
+	feedOf := make(map[string]*rssFeed)
+	for _, feed := range feeds {
+		for _, release := range feed.fresh {
+			fresh = append(fresh, release)
+			feedOf[ReleaseHash(release)] = feed
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	catalog, err := r.loadCatalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	var order []int64
+	candidates := make(map[int64]*rssCandidate)
+	for _, release := range fresh {
+		media, err := catalog.match(ctx, release.Title)
+		if err != nil {
+			r.logger.Warn("Failed to match RSS release", zap.String("release", release.Title), zap.Error(err))
+			continue
+		}
+		if media == nil {
+			continue
+		}
+		candidate, ok := candidates[media.ID]
+		if !ok {
+			candidate = &rssCandidate{media: *media}
+			candidates[media.ID] = candidate
+			order = append(order, media.ID)
+		}
+		candidate.releases = append(candidate.releases, release)
+	}
+
+	for _, mediaItemID := range order {
+		candidate := candidates[mediaItemID]
+		wanted, grabbed, err := r.grabCandidate(ctx, candidate)
+		if err != nil {
+			r.logger.Warn("Failed to grab RSS release",
+				zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+		}
+		if wanted {
+			result.Matched++
+		}
+		if grabbed != nil {
+			result.Grabbed++
+			if feed := feedOf[ReleaseHash(*grabbed)]; feed != nil {
+				feed.grabbed++
+			}
+		}
+	}
+
+	return nil
+}
+
+// grabCandidate grabs the best release of a media item when it is wanted, and
+// records the attempt in its search history. It reports whether the item was
+// wanted and the release grabbed, if any.
+func (r *RSSSync) grabCandidate(ctx context.Context, candidate *rssCandidate) (bool, *plugins.IndexerRelease, error) {
+	a := r.searcher
+	mediaItemID := candidate.media.ID
+
+	rule, err := a.monitoringSvc.GetEffectiveMonitoringRule(ctx, mediaItemID)
+	if err != nil {
+		return false, nil, err
+	}
+	if rule == nil || !rule.Enabled || !rule.AutomaticSearch {
+		return false, nil, nil
+	}
+
+	upgrade, wanted, err := r.wanted(ctx, rule, candidate.media)
+	if err != nil || !wanted {
+		return false, nil, err
+	}
+
+	start := time.Now()
+	history := newSearchHistory(rule, mediaItemID, SearchTypeRSS, TriggerSourceRSSSync)
+	query := fmt.Sprintf("RSS: %s", candidate.releases[0].Title)
+	history.Query = &query
+	history.ResultsFound = len(candidate.releases)
+	if upgrade {
+		history.Metadata["upgrade"] = true
+	}
+
+	grabbed, err := r.grab(ctx, rule, candidate, upgrade, history)
+	a.finishSearchHistory(ctx, history, start, err)
+	return true, grabbed, err
+}
+
+// wanted reports whether a media item is wanted: missing, or having a file that
+// can still be upgraded. Episodes are only missing when they are monitored and
+// have aired. A file that meets the quality cutoff is only upgraded when the
+// rule searches for upgrades.
+func (r *RSSSync) wanted(ctx context.Context, rule *MonitoringRule, media generated.MediaItem) (upgrade bool, wanted bool, err error) {
+	svc := r.searcher.monitoringSvc
+
+	satisfied, err := svc.IsMediaSatisfied(ctx, media.ID)
+	if err != nil {
+		return false, false, err
+	}
+	if !satisfied {
+		if media.Kind != "tv_episode" || media.ParentID == nil {
+			return false, true, nil
+		}
+		missing, err := svc.GetMissingEpisodesForMedia(ctx, *media.ParentID, maxRSSWantedEpisodes)
+		if err != nil {
+			return false, false, err
+		}
+		for _, episode := range missing {
+			if episode.MediaItemID == media.ID {
+				return false, true, nil
+			}
+		}
+		return false, false, nil
+	}
+
+	active, err := svc.HasActiveDownload(ctx, media.ID)
+	if err != nil || active {
+		return false, false, err
+	}
+	if !rule.SearchUpgrades {
+		met, err := svc.IsCutoffMet(ctx, media.ID)
+		if err != nil || met {
+			return false, false, err
+		}
+	}
+	return true, true, nil
+}
+
+// grab evaluates the releases of a candidate like the automatic search and
+// grabs the best one, returning it. Upgrades only take releases better than
+// the current file.
+func (r *RSSSync) grab(ctx context.Context, rule *MonitoringRule, candidate *rssCandidate, upgrade bool, history *SearchHistory) (*plugins.IndexerRelease, error) {
+	a := r.searcher
+
+	approved, rejections, err := a.evaluateReleases(ctx, rule, candidate.media.ID, SearchTypeRSS, candidate.releases)
+	if err != nil {
+		return nil, err
+	}
+	history.RecordRejections(rejections)
+
+	if upgrade {
+		var upgrades []ScoredRelease
+		for _, release := range approved {
+			if release.Score.Quality == nil {
+				continue
+			}
+			check, err := a.qualitySvc.CheckUpgradeAvailable(ctx, candidate.media.ID, release.Score.Quality.ID)
+			if err != nil {
+				return nil, err
+			}
+			if check.CanUpgrade {
+				upgrades = append(upgrades, release)
+			}
+		}
+		history.ResultsRejected += len(approved) - len(upgrades)
+		approved = upgrades
+	}
+	history.ResultsApproved = len(approved)
+
+	if len(approved) == 0 {
+		return nil, nil
+	}
+
+	winner := approved[0]
+	download, err := a.grabRelease(ctx, &candidate.media, winner, rssGrabSource, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grab release: %w", err)
+	}
+
+	history.DownloadGrabbed = true
+	history.DownloadID = &download.ID
+	history.RecordGrabbedRelease(winner)
+
+	r.logger.Info("Grabbed release from RSS",
+		zap.Int64("media_item_id", candidate.media.ID),
+		zap.String("release", winner.Release.Title),
+		zap.Bool("upgrade", upgrade),
+		zap.String("download_id", download.ID))
+	return &winner.Release, nil
+}
+
+// rssCatalog is the monitored media RSS releases are matched against, by title
+type rssCatalog struct {
+	sync    *RSSSync
+	movies  map[string][]generated.MediaItem
+	series  map[string][]generated.MediaItem
+	anime   map[int64]bool
+	seasons map[int64][]library.SeriesEpisode // Episodes of a series, loaded when first matched
+}
+
+// loadCatalog indexes the media of the enabled monitoring rules with automatic
+// search by title. Season rules add their series.
+func (r *RSSSync) loadCatalog(ctx context.Context) (*rssCatalog, error) {
+	rules, err := r.searcher.monitoringSvc.ListMonitoringRules(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &rssCatalog{
+		sync:    r,
+		movies:  make(map[string][]generated.MediaItem),
+		series:  make(map[string][]generated.MediaItem),
+		anime:   make(map[int64]bool),
+		seasons: make(map[int64][]library.SeriesEpisode),
+	}
+	added := make(map[int64]bool)
+	for _, rule := range rules {
+		if !rule.AutomaticSearch {
+			continue
+		}
+		media, err := r.searcher.queries.GetMediaItem(ctx, rule.MediaItemID)
+		if err != nil {
+			continue
+		}
+		if media.Kind == "tv_season" && media.ParentID != nil {
+			if media, err = r.searcher.queries.GetMediaItem(ctx, *media.ParentID); err != nil {
+				continue
+			}
+		}
+		if rule.SeriesType == SeriesTypeAnime {
+			catalog.anime[media.ID] = true
+		}
+		if added[media.ID] {
+			continue
+		}
+		added[media.ID] = true
+
+		switch media.Kind {
+		case "movie":
+			catalog.movies[rssTitleKey(media.Title)] = append(catalog.movies[rssTitleKey(media.Title)], media)
+		case "tv_series":
+			for _, key := range seriesTitleKeys(media) {
+				catalog.series[key] = append(catalog.series[key], media)
+			}
+		}
+	}
+
+	return catalog, nil
+}
+
+// match returns the monitored movie or episode a release title names, or nil
+func (c *rssCatalog) match(ctx context.Context, title string) (*generated.MediaItem, error) {
+	parsed := library.ParseFilename(title + ".mkv")
+	if parsed == nil || parsed.Title == "" {
+		return nil, nil
+	}
+
+	if parsed.Kind == "movie" {
+		if parsed.Year == 0 {
+			return nil, nil
+		}
+		for _, movie := range c.movies[rssTitleKey(parsed.Title)] {
+			if movie.Year != nil && int(*movie.Year) == parsed.Year {
+				return &movie, nil
+			}
+		}
+		return nil, nil
+	}
+
+	for _, series := range c.series[rssTitleKey(parsed.Title)] {
+		episodes, err := c.episodes(ctx, series.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var episode *library.SeriesEpisode
+		switch {
+		case parsed.Absolute > 0 && c.anime[series.ID]:
+			episode = library.FindAbsoluteEpisode(episodes, parsed.Absolute)
+		case parsed.Season > 0 || parsed.Episode > 0:
+			for i := range episodes {
+				if episodes[i].Season == parsed.Season && episodes[i].Episode == parsed.Episode {
+					episode = &episodes[i]
+					break
+				}
+			}
+		}
+		if episode == nil {
+			continue
+		}
+
+		media, err := c.sync.searcher.queries.GetMediaItem(ctx, episode.MediaItemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get episode: %w", err)
+		}
+		return &media, nil
+	}
+
+	return nil, nil
+}
+
+// episodes returns the episodes of a series, loading them once per sync
+func (c *rssCatalog) episodes(ctx context.Context, seriesID int64) ([]library.SeriesEpisode, error) {
+	if episodes, ok := c.seasons[seriesID]; ok {
+		return episodes, nil
+	}
+	episodes, err := library.ListSeriesEpisodes(ctx, c.sync.searcher.queries, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	c.seasons[seriesID] = episodes
+	return episodes, nil
+}
+
+// seriesTitleKeys returns the title keys a series is matched by: its title with
+// and without the year, as releases may name either
+func seriesTitleKeys(series generated.MediaItem) []string {
+	title := series.Title
+	year := 0
+	if m := rssTrailingYearPattern.FindStringSubmatch(title); m != nil {
+		title = rssTrailingYearPattern.ReplaceAllString(title, "")
+		year, _ = strconv.Atoi(m[1])
+	} else if series.Year != nil {
+		year = int(*series.Year)
+	}
+
+	keys := []string{rssTitleKey(title)}
+	if year > 0 {
+		keys = append(keys, rssTitleKey(fmt.Sprintf("%s %d", title, year)))
+	}
+	return keys
+}
+
+// rssTitleKey normalizes a title for matching release names, ignoring case,
+// punctuation and apostrophes
+func rssTitleKey(title string) string {
+	title = strings.ToLower(title)
+	title = strings.NewReplacer("'", "", "’", "", "&", " and ").Replace(title)
+	return strings.TrimSpace(rssTitleSeparatorPattern.ReplaceAllString(title, " "))
+}
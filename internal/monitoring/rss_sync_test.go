@@ -0,0 +1,161 @@
+package monitoring
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/indexer"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func TestRSSTitleKey(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Office", "the office"},
+		{"The.Office", "the office"},
+		{"Grey's Anatomy", "greys anatomy"},
+		{"Law & Order: SVU", "law and order svu"},
+		{"  Mr. Robot ", "mr robot"},
+	}
+
+	for _, tt := range tests {
+		if got := rssTitleKey(tt.title); got != tt.want {
+			t.Errorf("rssTitleKey(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSeriesTitleKeys(t *testing.T) {
+	year := int32(2005)
+	tests := []struct {
+		series generated.MediaItem
+		want   []string
+	}{
+		{generated.MediaItem{Title: "The Office"}, []string{"the office"}},
+		{generated.MediaItem{Title: "The Office", Year: &year}, []string{"the office", "the office 2005"}},
+		{generated.MediaItem{Title: "Doctor Who (2005)"}, []string{"doctor who", "doctor who 2005"}},
+	}
+
+	for _, tt := range tests {
+		if got := seriesTitleKeys(tt.series); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seriesTitleKeys(%q) = %v, want %v", tt.series.Title, got, tt.want)
+		}
+	}
+}
+
+func TestNewRSSReleases(t *testing.T) {
+	mark := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	releases := []plugins.IndexerRelease{
+		{GUID: "seen", PublishDate: mark},
+		{GUID: "newer", PublishDate: mark.Add(time.Hour)},
+		{GUID: "same-time", PublishDate: mark},
+		{GUID: "older", PublishDate: mark.Add(-time.Hour)},
+		{GUID: "undated"},
+	}
+
+	if got := newRSSReleases(nil, releases); len(got) != len(releases) {
+		t.Errorf("first sync found %d new releases, want %d", len(got), len(releases))
+	}
+
+	state := &RSSSyncState{LastReleaseAt: &mark, SeenGUIDs: []string{"seen"}}
+	var guids []string
+	for _, release := range newRSSReleases(state, releases) {
+		guids = append(guids, release.GUID)
+	}
+	if want := []string{"newer", "same-time", "undated"}; !reflect.DeepEqual(guids, want) {
+		t.Errorf("new releases = %v, want %v", guids, want)
+	}
+}
+
+func TestRSSHighWaterMark(t *testing.T) {
+	newest := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	mark, seen := rssHighWaterMark([]plugins.IndexerRelease{
+		{GUID: "a", PublishDate: newest.Add(-time.Hour)},
+		{GUID: "b", PublishDate: newest},
+		{GUID: "c"},
+	})
+
+	if mark == nil || !mark.Equal(newest) {
+		t.Errorf("mark = %v, want %v", mark, newest)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("seen = %v, want %v", seen, want)
+	}
+
+	if mark, _ := rssHighWaterMark([]plugins.IndexerRelease{{GUID: "a"}}); mark != nil {
+		t.Errorf("mark of undated feed = %v, want nil", mark)
+	}
+}
+
+func TestSplitRSSFeed(t *testing.T) {
+	plugin := indexer.IndexerInfo{ID: "usenet-indexer", Name: "Usenet Indexer"}
+
+	feeds := splitRSSFeed(plugin, &indexer.RSSFeed{
+		Releases: []plugins.IndexerRelease{
+			{GUID: "1", IndexerID: "geek"},
+			{GUID: "2", IndexerID: "planet"},
+			{GUID: "3", IndexerID: "geek"},
+		},
+		Indexers: []indexer.RSSFeedIndexer{
+			{ID: "geek", Name: "Geek", Count: 2},
+			{ID: "planet", Name: "Planet", Error: "HTTP 503"},
+			{ID: "empty", Name: "Empty"},
+		},
+	})
+
+	counts := make(map[string]int)
+	for _, feed := range feeds {
+		if feed.pluginID != plugin.ID {
+			t.Errorf("feed %s has plugin %q, want %q", feed.indexerID, feed.pluginID, plugin.ID)
+		}
+		counts[feed.indexerID] = len(feed.releases)
+	}
+	if want := map[string]int{"geek": 2, "empty": 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("feeds = %v, want %v", counts, want)
+	}
+
+	// Plugins that don't report their indexers have a single feed
+	feeds = splitRSSFeed(plugin, &indexer.RSSFeed{
+		Releases: []plugins.IndexerRelease{{GUID: "1", IndexerID: "geek"}},
+	})
+	if len(feeds) != 1 || feeds[0].indexerID != plugin.ID || len(feeds[0].releases) != 1 {
+		t.Errorf("single feed = %+v, want one %s feed with 1 release", feeds, plugin.ID)
+	}
+}
+
+func TestRSSCatalogMatchMovie(t *testing.T) {
+	year := int32(2021)
+	dune := generated.MediaItem{ID: 7, Kind: "movie", Title: "Dune", Year: &year}
+	catalog := &rssCatalog{
+		movies: map[string][]generated.MediaItem{rssTitleKey(dune.Title): {dune}},
+		series: map[string][]generated.MediaItem{},
+	}
+
+	tests := []struct {
+		title string
+		want  int64
+	}{
+		{"Dune.2021.1080p.BluRay.x264-GROUP", 7},
+		{"Dune.1984.1080p.BluRay.x264-GROUP", 0},
+		{"Dune.Part.Two.2024.2160p.WEB-DL-GROUP", 0},
+	}
+
+	for _, tt := range tests {
+		media, err := catalog.match(context.Background(), tt.title)
+		if err != nil {
+			t.Fatalf("match(%q): %v", tt.title, err)
+		}
+		var got int64
+		if media != nil {
+			got = media.ID
+		}
+		if got != tt.want {
+			t.Errorf("match(%q) = %d, want %d", tt.title, got, tt.want)
+		}
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/blakestevenson/nimbus/internal/realtime"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 )
 
 // Scheduler manages background job execution
@@ -18,8 +21,17 @@ type Scheduler struct {
 	running       bool
 	jobHandlers   map[string]JobHandler
 	tickInterval  time.Duration
+	hub           *realtime.Hub
 }
 
+// JobCutoffRecompute is the job that recomputes which episodes meet their
+// quality profile's cutoff. It also runs when a profile changes.
+const JobCutoffRecompute = "cutoff_recompute"
+
+// ErrJobRunning is returned when a job is started while a run of it is still
+// going. The attempt is recorded as skipped in the job's history.
+var ErrJobRunning = errors.New("job is already running")
+
 // JobHandler is a function that handles a job execution
 type JobHandler func(ctx context.Context, job *SchedulerJob) error
 
@@ -39,6 +51,11 @@ func (s *Scheduler) RegisterJobHandler(jobName string, handler JobHandler) {
 	s.jobHandlers[jobName] = handler
 }
 
+// SetHub sets the hub job starts and results are pushed to clients through
+func (s *Scheduler) SetHub(hub *realtime.Hub) {
+	s.hub = hub
+}
+
 // Start starts the scheduler
 func (s *Scheduler) Start(ctx context.Context) error {
 	if s.running {
@@ -47,6 +64,12 @@ func (s *Scheduler) Start(ctx context.Context) error {
 
 	s.running = true
 
+	// Runs interrupted by a restart would otherwise keep their jobs marked
+	// running, and every later run skipped
+	if err := s.resetInterruptedJobs(ctx); err != nil {
+		fmt.Printf("failed to reset interrupted jobs: %v\n", err)
+	}
+
 	// Register default job handlers
 	s.registerDefaultHandlers()
 
@@ -91,19 +114,34 @@ func (s *Scheduler) processDueJobs(ctx context.Context) {
 	}
 
 	for _, job := range jobs {
-		// Execute job in goroutine to avoid blocking
-		go s.executeJob(ctx, &job)
+		if err := s.startJob(ctx, &job); err != nil && !errors.Is(err, ErrJobRunning) {
+			fmt.Printf("failed to start job %s: %v\n", job.JobName, err)
+		}
 	}
 }
 
-// executeJob executes a single job
-func (s *Scheduler) executeJob(ctx context.Context, job *SchedulerJob) {
-	// Mark job as running
-	if err := s.markJobRunning(ctx, job.ID, true); err != nil {
-		fmt.Printf("failed to mark job as running: %v\n", err)
-		return
+// startJob claims a job and runs it in the background. A job that is already
+// running is not run again; the attempt is recorded as skipped and
+// ErrJobRunning returned.
+func (s *Scheduler) startJob(ctx context.Context, job *SchedulerJob) error {
+	claimed, err := s.claimJob(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark job as running: %w", err)
+	}
+	if !claimed {
+		if err := s.recordSkipped(ctx, job, "Skipped because the job is already running"); err != nil {
+			fmt.Printf("failed to record skipped job: %v\n", err)
+		}
+		return ErrJobRunning
 	}
 
+	go s.executeJob(context.WithoutCancel(ctx), job)
+	return nil
+}
+
+// executeJob executes a single job claimed by startJob
+func (s *Scheduler) executeJob(ctx context.Context, job *SchedulerJob) {
+	job.log = &jobLog{}
 	startTime := time.Now()
 
 	// Create job history entry
@@ -114,6 +152,12 @@ func (s *Scheduler) executeJob(ctx context.Context, job *SchedulerJob) {
 		return
 	}
 
+	s.hub.Publish(realtime.Event{
+		Type:   "job.started",
+		Data:   map[string]interface{}{"id": job.ID, "job_name": job.JobName},
+		Topics: []string{realtime.TopicJobs},
+	})
+
 	// Execute job handler
 	var execErr error
 	handler, ok := s.jobHandlers[job.JobName]
@@ -135,12 +179,12 @@ func (s *Scheduler) executeJob(ctx context.Context, job *SchedulerJob) {
 		errorMsg = &msg
 	}
 
-	if err := s.updateJobHistory(ctx, historyID, finishTime, duration, status, errorMsg); err != nil {
+	if err := s.updateJobHistory(ctx, historyID, finishTime, duration, status, errorMsg, job.log.entries()); err != nil {
 		fmt.Printf("failed to update job history: %v\n", err)
 	}
 
 	// Update job status
-	if err := s.updateJobStatus(ctx, job.ID, finishTime, duration, status, errorMsg); err != nil {
+	if err := s.updateJobStatus(ctx, job.ID, finishTime, duration, status, errorMsg, job.nextRun(finishTime)); err != nil {
 		fmt.Printf("failed to update job status: %v\n", err)
 	}
 
@@ -148,6 +192,17 @@ func (s *Scheduler) executeJob(ctx context.Context, job *SchedulerJob) {
 	if err := s.markJobRunning(ctx, job.ID, false); err != nil {
 		fmt.Printf("failed to mark job as not running: %v\n", err)
 	}
+
+	result := map[string]interface{}{
+		"id":          job.ID,
+		"job_name":    job.JobName,
+		"status":      status,
+		"duration_ms": duration,
+	}
+	if errorMsg != nil {
+		result["error"] = *errorMsg
+	}
+	s.hub.Publish(realtime.Event{Type: "job.finished", Data: result, Topics: []string{realtime.TopicJobs}})
 }
 
 // GetDueJobs gets jobs that are due to run
@@ -198,6 +253,51 @@ func (s *Scheduler) GetDueJobs(ctx context.Context) ([]SchedulerJob, error) {
 	return jobs, rows.Err()
 }
 
+// claimJob marks a job as running unless it already is, reporting whether it
+// was claimed
+func (s *Scheduler) claimJob(ctx context.Context, jobID int64) (bool, error) {
+	result, err := s.db.Exec(ctx, `UPDATE scheduler_jobs SET running = true WHERE id = $1 AND running = false`, jobID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// resetInterruptedJobs clears the running mark of jobs whose run ended with
+// the previous process, failing their unfinished history entries
+func (s *Scheduler) resetInterruptedJobs(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE scheduler_job_history
+		SET finished_at = NOW(),
+		    status = $1,
+		    error_message = 'Interrupted by a restart'
+		WHERE finished_at IS NULL
+	`, JobStatusFailed)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx, `UPDATE scheduler_jobs SET running = false WHERE running = true`)
+	return err
+}
+
+// recordSkipped records a run of a job that did not happen
+func (s *Scheduler) recordSkipped(ctx context.Context, job *SchedulerJob, reason string) error {
+	query := `
+		INSERT INTO scheduler_job_history (job_id, started_at, finished_at, duration_ms, status, error_message)
+		VALUES ($1, NOW(), NOW(), 0, $2, $3)
+	`
+	if _, err := s.db.Exec(ctx, query, job.ID, JobStatusSkipped, reason); err != nil {
+		return err
+	}
+
+	s.hub.Publish(realtime.Event{
+		Type:   "job.skipped",
+		Data:   map[string]interface{}{"id": job.ID, "job_name": job.JobName, "reason": reason},
+		Topics: []string{realtime.TopicJobs},
+	})
+	return nil
+}
+
 // markJobRunning marks a job as running or not running
 func (s *Scheduler) markJobRunning(ctx context.Context, jobID int64, running bool) error {
 	query := `UPDATE scheduler_jobs SET running = $1 WHERE id = $2`
@@ -223,32 +323,36 @@ func (s *Scheduler) createJobHistory(ctx context.Context, jobID int64, startTime
 }
 
 // updateJobHistory updates a job history entry
-func (s *Scheduler) updateJobHistory(ctx context.Context, historyID int64, finishTime time.Time, durationMs int, status JobStatus, errorMsg *string) error {
+func (s *Scheduler) updateJobHistory(ctx context.Context, historyID int64, finishTime time.Time, durationMs int, status JobStatus, errorMsg *string, logEntries []JobLogEntry) error {
+	logJSON, err := json.Marshal(logEntries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job log: %w", err)
+	}
+
 	query := `
 		UPDATE scheduler_job_history
 		SET finished_at = $1,
 		    duration_ms = $2,
 		    status = $3,
-		    error_message = $4
-		WHERE id = $5
+		    error_message = $4,
+		    log_entries = $5
+		WHERE id = $6
 	`
 
-	_, err := s.db.Exec(ctx, query, finishTime, durationMs, status, errorMsg, historyID)
+	_, err = s.db.Exec(ctx, query, finishTime, durationMs, status, errorMsg, logJSON, historyID)
 	return err
 }
 
-// updateJobStatus updates job status after execution
-func (s *Scheduler) updateJobStatus(ctx context.Context, jobID int64, finishTime time.Time, durationMs int, status JobStatus, errorMsg *string) error {
+// updateJobStatus updates job status after execution. The next run is kept
+// when nextRunAt is nil.
+func (s *Scheduler) updateJobStatus(ctx context.Context, jobID int64, finishTime time.Time, durationMs int, status JobStatus, errorMsg *string, nextRunAt *time.Time) error {
 	query := `
 		UPDATE scheduler_jobs
 		SET last_run_at = $1,
 		    last_run_duration_ms = $2,
 		    last_status = $3,
 		    last_error = $4,
-		    next_run_at = CASE
-		        WHEN interval_minutes IS NOT NULL THEN $1::timestamptz + (interval_minutes || ' minutes')::INTERVAL
-		        ELSE next_run_at
-		    END,
+		    next_run_at = COALESCE($6, next_run_at),
 		    total_runs = total_runs + 1,
 		    consecutive_failures = CASE
 		        WHEN $3 = 'failed' THEN consecutive_failures + 1
@@ -257,49 +361,50 @@ func (s *Scheduler) updateJobStatus(ctx context.Context, jobID int64, finishTime
 		WHERE id = $5
 	`
 
-	_, err := s.db.Exec(ctx, query, finishTime, durationMs, status, errorMsg, jobID)
+	_, err := s.db.Exec(ctx, query, finishTime, durationMs, status, errorMsg, jobID, nextRunAt)
 	return err
 }
 
-// registerDefaultHandlers registers default job handlers
+// registerDefaultHandlers registers default job handlers. Handlers registered
+// before Start take precedence over the defaults.
 func (s *Scheduler) registerDefaultHandlers() {
 	// RSS sync handler
-	s.RegisterJobHandler("rss_sync", s.handleRSSSync)
+	s.registerDefaultHandler("rss_sync", s.handleRSSSync)
 
 	// Backlog search handler
-	s.RegisterJobHandler("backlog_search", s.handleBacklogSearch)
+	s.registerDefaultHandler("backlog_search", s.handleBacklogSearch)
 
 	// Calendar update handler
-	s.RegisterJobHandler("calendar_update", s.handleCalendarUpdate)
+	s.registerDefaultHandler("calendar_update", s.handleCalendarUpdate)
 
 	// Monitoring check handler
-	s.RegisterJobHandler("monitoring_check", s.handleMonitoringCheck)
+	s.registerDefaultHandler("monitoring_check", s.handleMonitoringCheck)
 
 	// Download cleanup handler
-	s.RegisterJobHandler("download_cleanup", s.handleDownloadCleanup)
+	s.registerDefaultHandler("download_cleanup", s.handleDownloadCleanup)
 
 	// Blocklist cleanup handler
-	s.RegisterJobHandler("blocklist_cleanup", s.handleBlocklistCleanup)
+	s.registerDefaultHandler("blocklist_cleanup", s.handleBlocklistCleanup)
 
 	// Quality upgrade search handler
-	s.RegisterJobHandler("quality_upgrade_search", s.handleQualityUpgradeSearch)
+	s.registerDefaultHandler("quality_upgrade_search", s.handleQualityUpgradeSearch)
+}
+
+// registerDefaultHandler registers a handler unless one is already registered for the job
+func (s *Scheduler) registerDefaultHandler(jobName string, handler JobHandler) {
+	if _, exists := s.jobHandlers[jobName]; !exists {
+		s.jobHandlers[jobName] = handler
+	}
 }
 
 // ========================
 // Job Handlers
 // ========================
 
-// handleRSSSync handles RSS feed synchronization
+// handleRSSSync handles RSS feed synchronization. Feeds are fetched from the
+// indexer plugins, so this default does nothing; the server replaces it with
+// an RSSSync when plugins are available.
 func (s *Scheduler) handleRSSSync(ctx context.Context, job *SchedulerJob) error {
-	// TODO: Implement RSS sync logic
-	// This would:
-	// 1. Get all enabled indexers
-	// 2. Fetch RSS feeds from each
-	// 3. Parse new releases
-	// 4. Match against monitored items
-	// 5. Download if quality matches
-
-	fmt.Printf("Executing RSS sync job\n")
 	return nil
 }
 
@@ -317,7 +422,7 @@ func (s *Scheduler) handleBacklogSearch(ctx context.Context, job *SchedulerJob)
 		return fmt.Errorf("failed to get missing episodes: %w", err)
 	}
 
-	fmt.Printf("Backlog search: found %d missing episodes\n", len(missingEpisodes))
+	job.Logf("Backlog search: found %d missing episodes", len(missingEpisodes))
 
 	// TODO: Implement actual search logic
 	// For each missing episode:
@@ -329,16 +434,11 @@ func (s *Scheduler) handleBacklogSearch(ctx context.Context, job *SchedulerJob)
 	return nil
 }
 
-// handleCalendarUpdate handles calendar event updates
+// handleCalendarUpdate handles calendar event updates. Episode air dates need the
+// TMDB plugin, so this default only covers movies; the server replaces it with a
+// plugin-backed CalendarSync when plugins are available.
 func (s *Scheduler) handleCalendarUpdate(ctx context.Context, job *SchedulerJob) error {
-	// TODO: Implement calendar update logic
-	// This would:
-	// 1. Query TVDB/TMDB for upcoming episodes
-	// 2. Update calendar_events table
-	// 3. Create episode_monitoring records for new episodes
-
-	fmt.Printf("Executing calendar update job\n")
-	return nil
+	return NewCalendarSync(s.monitoringSvc, nil, zap.NewNop()).HandleJob(ctx, job)
 }
 
 // handleMonitoringCheck handles monitoring checks for new releases
@@ -349,15 +449,17 @@ func (s *Scheduler) handleMonitoringCheck(ctx context.Context, job *SchedulerJob
 		return fmt.Errorf("failed to get monitoring rules: %w", err)
 	}
 
-	fmt.Printf("Monitoring check: found %d rules due for search\n", len(rules))
+	// The searches themselves are run by the AutoSearcher; this job only reports the backlog
+	job.Logf("Monitoring check: found %d rules due for search", len(rules))
 
-	// TODO: Implement monitoring check logic
-	// For each rule:
-	// 1. Search indexers for new releases
-	// 2. Filter by quality profile
-	// 3. Check blocklist
-	// 4. Download if match found
-	// 5. Update monitoring rule timestamps
+	// Episodes added since the last check are monitored as their rule's mode says
+	added, err := s.monitoringSvc.MonitorNewEpisodes(ctx)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		job.Logf("Monitoring check: applied monitor modes to %d new episodes", added)
+	}
 
 	return nil
 }
@@ -400,7 +502,7 @@ func (s *Scheduler) handleDownloadCleanup(ctx context.Context, job *SchedulerJob
 
 	failedDeleted := result.RowsAffected()
 
-	fmt.Printf("Download cleanup: deleted %d completed, %d failed downloads\n", completedDeleted, failedDeleted)
+	job.Logf("Download cleanup: deleted %d completed, %d failed downloads", completedDeleted, failedDeleted)
 	return nil
 }
 
@@ -419,7 +521,7 @@ func (s *Scheduler) handleBlocklistCleanup(ctx context.Context, job *SchedulerJo
 	}
 
 	deleted := result.RowsAffected()
-	fmt.Printf("Blocklist cleanup: deleted %d expired entries\n", deleted)
+	job.Logf("Blocklist cleanup: deleted %d expired entries", deleted)
 	return nil
 }
 
@@ -431,7 +533,7 @@ func (s *Scheduler) handleQualityUpgradeSearch(ctx context.Context, job *Schedul
 	// 2. Search for better quality releases
 	// 3. Download if found and better than current
 
-	fmt.Printf("Executing quality upgrade search job\n")
+	job.Logf("Executing quality upgrade search job")
 	return nil
 }
 
@@ -526,10 +628,15 @@ func (s *Scheduler) TriggerJob(ctx context.Context, jobID int64) error {
 		return err
 	}
 
-	if job.Running {
-		return fmt.Errorf("job is already running")
-	}
+	return s.startJob(ctx, job)
+}
 
-	go s.executeJob(ctx, job)
-	return nil
+// TriggerJobByName runs a job now, by its name
+func (s *Scheduler) TriggerJobByName(ctx context.Context, jobName string) error {
+	var jobID int64
+	err := s.db.QueryRow(ctx, `SELECT id FROM scheduler_jobs WHERE job_name = $1`, jobName).Scan(&jobID)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobName, err)
+	}
+	return s.TriggerJob(ctx, jobID)
 }
@@ -0,0 +1,268 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"go.uber.org/zap"
+)
+
+const (
+	// minSeasonPackEpisodes is how many episodes of a season must be missing before
+	// a season pack is searched for
+	minSeasonPackEpisodes = 2
+
+	// maxSeasonPackSizeRatio is how much larger a season pack may be than grabbing
+	// the missing episodes one by one
+	maxSeasonPackSizeRatio = 2.0
+
+	// Search strategies recorded in the search history
+	searchStrategySeasonPack = "season_pack"
+	searchStrategyEpisode    = "episode"
+)
+
+var (
+	// episodeMarkerPattern matches releases that name single episodes or an episode range
+	episodeMarkerPattern = regexp.MustCompile(`(?i)\bS\d{1,2}[ ._-]?E\d{1,3}\b|\b\d{1,2}x\d{2,3}\b`)
+
+	// seasonMarkerPattern extracts the season number from S01 or "Season 1" style titles
+	seasonMarkerPattern = regexp.MustCompile(`(?i)\b(?:S|Season[ ._-]?)(\d{1,2})\b`)
+)
+
+// grabSeasonPacks tries to grab a season pack for every season with several missing
+// episodes. It returns the episodes that still need to be searched individually,
+// why their season pack was not used, and the number of approved releases and grabs.
+func (a *AutoSearcher) grabSeasonPacks(ctx context.Context, rule *MonitoringRule, missing []MissingEpisode, searchType SearchType, trigger TriggerSource) ([]MissingEpisode, map[int64]string, int, int) {
+	var seasonOrder []int64
+	bySeason := make(map[int64][]MissingEpisode)
+	for _, episode := range missing {
+		if _, ok := bySeason[episode.SeasonID]; !ok {
+			seasonOrder = append(seasonOrder, episode.SeasonID)
+		}
+		bySeason[episode.SeasonID] = append(bySeason[episode.SeasonID], episode)
+	}
+
+	var remaining []MissingEpisode
+	fallbacks := make(map[int64]string)
+	found, grabbed := 0, 0
+	for _, seasonID := range seasonOrder {
+		episodes := bySeason[seasonID]
+		if len(episodes) < minSeasonPackEpisodes {
+			remaining = append(remaining, episodes...)
+			continue
+		}
+
+		history, err := a.searchSeasonPack(ctx, rule, seasonID, episodes, searchType, trigger)
+		if err != nil {
+			a.logger.Warn("Season pack search failed", zap.Int64("season_id", seasonID), zap.Error(err))
+		}
+		if history != nil {
+			found += history.ResultsApproved
+		}
+		if history != nil && history.DownloadGrabbed {
+			grabbed++
+			continue
+		}
+
+		reason := "season pack search failed"
+		if history != nil {
+			if r, ok := history.Metadata["fallback_reason"].(string); ok {
+				reason = r
+			}
+		}
+		for _, episode := range episodes {
+			fallbacks[episode.MediaItemID] = reason
+		}
+		remaining = append(remaining, episodes...)
+	}
+
+	return remaining, fallbacks, found, grabbed
+}
+
+// searchSeasonPack searches for a full season release covering the missing episodes,
+// grabs it when it beats grabbing the episodes individually and records the chosen
+// strategy in the search history
+func (a *AutoSearcher) searchSeasonPack(ctx context.Context, rule *MonitoringRule, seasonID int64, episodes []MissingEpisode, searchType SearchType, trigger TriggerSource) (*SearchHistory, error) {
+	select {
+	case a.slots <- struct{}{}:
+		defer func() { <-a.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	history := newSearchHistory(rule, seasonID, searchType, trigger)
+	history.Metadata["strategy"] = searchStrategyEpisode
+	history.Metadata["missing_episodes"] = len(episodes)
+
+	err := a.searchAndGrabSeasonPack(ctx, rule, seasonID, episodes, history)
+	a.finishSearchHistory(ctx, history, start, err)
+
+	return history, err
+}
+
+// searchAndGrabSeasonPack performs the season pack search and fills in the history record
+func (a *AutoSearcher) searchAndGrabSeasonPack(ctx context.Context, rule *MonitoringRule, seasonID int64, episodes []MissingEpisode, history *SearchHistory) error {
+	season, err := a.queries.GetMediaItem(ctx, seasonID)
+	if err != nil {
+		return fmt.Errorf("failed to get season: %w", err)
+	}
+
+	// A season search carries the season number without an episode, which is
+	// the Newznab convention for full season releases
+	req := a.searchRequest(ctx, rule, season)
+	req.Episode = 0
+	query := describeSearchRequest(req)
+	history.Query = &query
+
+	if req.Season == 0 {
+		history.Metadata["fallback_reason"] = "season number unknown"
+		return nil
+	}
+
+	resp, err := a.indexerSvc.Search(ctx, req)
+	if err != nil {
+		history.Metadata["fallback_reason"] = "season pack search failed"
+		return fmt.Errorf("indexer search failed: %w", err)
+	}
+	history.ResultsFound = len(resp.Releases)
+
+	var packs []plugins.IndexerRelease
+	for _, release := range resp.Releases {
+		if isFullSeasonPack(release.Title, req.Season) {
+			packs = append(packs, release)
+		}
+	}
+
+	approved, rejections, err := a.evaluateReleases(ctx, rule, seasonID, history.SearchType, packs)
+	if err != nil {
+		return err
+	}
+	history.ResultsApproved = len(approved)
+	history.ResultsRejected = len(resp.Releases) - len(packs)
+	history.RecordRejections(rejections)
+
+	if len(approved) == 0 {
+		history.Metadata["fallback_reason"] = "no season pack found"
+		return nil
+	}
+	pack := approved[0]
+
+	// Compare against the best release for one of the missing episodes to decide
+	// whether the pack is worth it
+	individual, err := a.bestEpisodeRelease(ctx, rule, episodes[0].MediaItemID, history.SearchType)
+	if err != nil {
+		a.logger.Warn("Failed to search episode for season pack comparison",
+			zap.Int64("media_item_id", episodes[0].MediaItemID), zap.Error(err))
+	}
+	if reason := preferEpisodesOverPack(pack, individual, len(episodes)); reason != "" {
+		history.Metadata["fallback_reason"] = reason
+		return nil
+	}
+
+	download, err := a.grabRelease(ctx, &season, pack, autoSearchGrabSource, a.wantedEpisodes(ctx, req.Season, episodes))
+	if err != nil {
+		history.Metadata["fallback_reason"] = "season pack grab failed"
+		return fmt.Errorf("failed to grab season pack: %w", err)
+	}
+
+	history.DownloadGrabbed = true
+	history.DownloadID = &download.ID
+	history.Metadata["strategy"] = searchStrategySeasonPack
+	history.RecordGrabbedRelease(pack)
+
+	a.logger.Info("Grabbed season pack",
+		zap.Int64("season_id", seasonID),
+		zap.Int("missing_episodes", len(episodes)),
+		zap.String("release", pack.Release.Title),
+		zap.Int("score", pack.Score.Total),
+		zap.String("download_id", download.ID))
+
+	return nil
+}
+
+// wantedEpisodes numbers the missing episodes a season pack is grabbed for, so
+// the downloader can skip the episodes already in the library. It returns nil
+// when an episode number is unknown, and the whole pack is downloaded.
+func (a *AutoSearcher) wantedEpisodes(ctx context.Context, season int, episodes []MissingEpisode) []plugins.WantedEpisode {
+	wanted := make([]plugins.WantedEpisode, 0, len(episodes))
+	for _, episode := range episodes {
+		item, err := a.queries.GetMediaItem(ctx, episode.MediaItemID)
+		if err != nil {
+			a.logger.Warn("Failed to get missing episode", zap.Int64("media_item_id", episode.MediaItemID), zap.Error(err))
+			return nil
+		}
+		var metadata struct {
+			Episode int `json:"episode"`
+		}
+		if err := json.Unmarshal(item.Metadata, &metadata); err != nil || metadata.Episode == 0 {
+			return nil
+		}
+		wanted = append(wanted, plugins.WantedEpisode{Season: season, Episode: metadata.Episode})
+	}
+	return wanted
+}
+
+// bestEpisodeRelease returns the best approved release for a single episode, or nil
+func (a *AutoSearcher) bestEpisodeRelease(ctx context.Context, rule *MonitoringRule, mediaItemID int64, searchType SearchType) (*ScoredRelease, error) {
+	media, err := a.queries.GetMediaItem(ctx, mediaItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	resp, err := a.indexerSvc.Search(ctx, a.searchRequest(ctx, rule, media))
+	if err != nil {
+		return nil, fmt.Errorf("indexer search failed: %w", err)
+	}
+
+	approved, _, err := a.evaluateReleases(ctx, rule, mediaItemID, searchType, resp.Releases)
+	if err != nil || len(approved) == 0 {
+		return nil, err
+	}
+
+	return &approved[0], nil
+}
+
+// preferEpisodesOverPack compares a season pack with the best single episode
+// release and returns why the episodes should be grabbed individually, or "" to
+// take the pack. The pack loses when its quality is lower or when it is much larger
+// than grabbing the missing episodes at the individual release's size.
+func preferEpisodesOverPack(pack ScoredRelease, individual *ScoredRelease, missing int) string {
+	if individual == nil {
+		return ""
+	}
+
+	if individual.Score.QualityScore > pack.Score.QualityScore {
+		return "season pack quality is lower than the individual episodes"
+	}
+
+	if individual.Release.Size > 0 && pack.Release.Size > 0 {
+		estimate := float64(individual.Release.Size) * float64(missing)
+		if float64(pack.Release.Size) > estimate*maxSeasonPackSizeRatio {
+			return "season pack is much larger than the missing episodes"
+		}
+	}
+
+	return ""
+}
+
+// isFullSeasonPack reports whether a release title is a complete pack of the given
+// season rather than single episodes or a partial range
+func isFullSeasonPack(title string, season int) bool {
+	if episodeMarkerPattern.MatchString(title) {
+		return false
+	}
+
+	for _, m := range seasonMarkerPattern.FindAllStringSubmatch(title, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n == season {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/blakestevenson/nimbus/internal/quality"
+)
+
+func TestIsFullSeasonPack(t *testing.T) {
+	tests := []struct {
+		title  string
+		season int
+		want   bool
+	}{
+		{"Show.Name.S02.1080p.WEB-DL.x264-GRP", 2, true},
+		{"Show Name Season 2 Complete 720p", 2, true},
+		{"Show.Name.S02.1080p.WEB-DL.x264-GRP", 3, false},
+		{"Show.Name.S02E05.1080p.WEB-DL.x264-GRP", 2, false},
+		{"Show.Name.S02E01-E04.1080p.WEB-DL", 2, false},
+		{"Show.Name.2x05.720p.HDTV", 2, false},
+		{"Show.Name.1080p.WEB-DL", 2, false},
+	}
+
+	for _, tt := range tests {
+		if got := isFullSeasonPack(tt.title, tt.season); got != tt.want {
+			t.Errorf("isFullSeasonPack(%q, %d) = %v, want %v", tt.title, tt.season, got, tt.want)
+		}
+	}
+}
+
+func TestPreferEpisodesOverPack(t *testing.T) {
+	release := func(size int64, qualityScore int) ScoredRelease {
+		return ScoredRelease{
+			Release: plugins.IndexerRelease{Size: size},
+			Score:   quality.ReleaseScore{QualityScore: qualityScore},
+		}
+	}
+
+	pack := release(10_000, 50)
+
+	if reason := preferEpisodesOverPack(pack, nil, 4); reason != "" {
+		t.Errorf("pack without individual releases should win, got %q", reason)
+	}
+
+	individual := release(1_000, 50)
+	if reason := preferEpisodesOverPack(pack, &individual, 8); reason != "" {
+		t.Errorf("pack covering many missing episodes should win, got %q", reason)
+	}
+	if reason := preferEpisodesOverPack(pack, &individual, 2); reason == "" {
+		t.Error("oversized pack for two missing episodes should lose")
+	}
+
+	better := release(1_000, 80)
+	if reason := preferEpisodesOverPack(pack, &better, 8); reason == "" {
+		t.Error("pack of lower quality should lose")
+	}
+}
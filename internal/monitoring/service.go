@@ -4,15 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Service handles monitoring operations
 type Service struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	config *configstore.Store
 }
 
 // NewService creates a new monitoring service
@@ -33,9 +37,10 @@ func (s *Service) CreateMonitoringRule(ctx context.Context, params CreateMonitor
 			media_item_id, enabled, quality_profile_id, monitor_mode,
 			search_on_add, automatic_search, backlog_search,
 			prefer_season_packs, minimum_seeders, tags,
-			search_interval_minutes, created_by_user_id
+			search_interval_minutes, created_by_user_id, root_folder_id, series_type, search_upgrades,
+			template_id
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		ON CONFLICT (media_item_id) DO UPDATE SET
 			enabled = EXCLUDED.enabled,
 			quality_profile_id = EXCLUDED.quality_profile_id,
@@ -46,33 +51,53 @@ func (s *Service) CreateMonitoringRule(ctx context.Context, params CreateMonitor
 			prefer_season_packs = EXCLUDED.prefer_season_packs,
 			minimum_seeders = EXCLUDED.minimum_seeders,
 			tags = EXCLUDED.tags,
-			search_interval_minutes = EXCLUDED.search_interval_minutes
+			search_interval_minutes = EXCLUDED.search_interval_minutes,
+			root_folder_id = EXCLUDED.root_folder_id,
+			series_type = EXCLUDED.series_type,
+			search_upgrades = EXCLUDED.search_upgrades,
+			template_id = EXCLUDED.template_id
 		RETURNING id, media_item_id, enabled, quality_profile_id, monitor_mode,
 		          search_on_add, automatic_search, backlog_search,
 		          prefer_season_packs, minimum_seeders, tags,
 		          search_interval_minutes, last_search_at, next_search_at,
 		          search_count, items_found_count, items_grabbed_count,
-		          created_at, updated_at, created_by_user_id
+		          created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		          template_id
 	`
 
+	seriesType := params.SeriesType
+	if seriesType == "" {
+		seriesType = SeriesTypeStandard
+	}
+	monitorMode := params.MonitorMode
+	if monitorMode == "" {
+		monitorMode = MonitorModeAll
+	}
+
 	var rule MonitoringRule
 	err := s.db.QueryRow(ctx, query,
-		params.MediaItemID, params.Enabled, params.QualityProfileID, params.MonitorMode,
+		params.MediaItemID, params.Enabled, params.QualityProfileID, monitorMode,
 		params.SearchOnAdd, params.AutomaticSearch, params.BacklogSearch,
 		params.PreferSeasonPacks, params.MinimumSeeders, params.Tags,
-		params.SearchIntervalMinutes, params.CreatedByUserID,
+		params.SearchIntervalMinutes, params.CreatedByUserID, params.RootFolderID, seriesType,
+		params.SearchUpgrades, params.TemplateID,
 	).Scan(
 		&rule.ID, &rule.MediaItemID, &rule.Enabled, &rule.QualityProfile, &rule.MonitorMode,
 		&rule.SearchOnAdd, &rule.AutomaticSearch, &rule.BacklogSearch,
 		&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 		&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 		&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+		&rule.TemplateID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create monitoring rule: %w", err)
 	}
 
+	if err := s.ApplyMonitorMode(ctx, &rule); err != nil {
+		return nil, err
+	}
+
 	return &rule, nil
 }
 
@@ -84,7 +109,8 @@ func (s *Service) GetMonitoringRule(ctx context.Context, id int64) (*MonitoringR
 		       prefer_season_packs, minimum_seeders, tags,
 		       search_interval_minutes, last_search_at, next_search_at,
 		       search_count, items_found_count, items_grabbed_count,
-		       created_at, updated_at, created_by_user_id
+		       created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		       template_id
 		FROM monitoring_rules
 		WHERE id = $1
 	`
@@ -96,7 +122,8 @@ func (s *Service) GetMonitoringRule(ctx context.Context, id int64) (*MonitoringR
 		&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 		&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 		&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+		&rule.TemplateID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -116,7 +143,8 @@ func (s *Service) GetMonitoringRuleByMediaItem(ctx context.Context, mediaItemID
 		       prefer_season_packs, minimum_seeders, tags,
 		       search_interval_minutes, last_search_at, next_search_at,
 		       search_count, items_found_count, items_grabbed_count,
-		       created_at, updated_at, created_by_user_id
+		       created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		       template_id
 		FROM monitoring_rules
 		WHERE media_item_id = $1
 	`
@@ -128,7 +156,8 @@ func (s *Service) GetMonitoringRuleByMediaItem(ctx context.Context, mediaItemID
 		&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 		&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 		&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+		&rule.TemplateID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -148,7 +177,8 @@ func (s *Service) ListMonitoringRules(ctx context.Context, enabledOnly bool) ([]
 		       prefer_season_packs, minimum_seeders, tags,
 		       search_interval_minutes, last_search_at, next_search_at,
 		       search_count, items_found_count, items_grabbed_count,
-		       created_at, updated_at, created_by_user_id
+		       created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		       template_id
 		FROM monitoring_rules
 	`
 
@@ -173,7 +203,8 @@ func (s *Service) ListMonitoringRules(ctx context.Context, enabledOnly bool) ([]
 			&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 			&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 			&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-			&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+			&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+			&rule.TemplateID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan monitoring rule: %w", err)
@@ -197,14 +228,18 @@ func (s *Service) UpdateMonitoringRule(ctx context.Context, id int64, params Upd
 		    prefer_season_packs = COALESCE($7, prefer_season_packs),
 		    minimum_seeders = COALESCE($8, minimum_seeders),
 		    tags = COALESCE($9, tags),
-		    search_interval_minutes = COALESCE($10, search_interval_minutes)
-		WHERE id = $11
+		    search_interval_minutes = COALESCE($10, search_interval_minutes),
+		    root_folder_id = COALESCE($11, root_folder_id),
+		    series_type = COALESCE($12, series_type),
+		    search_upgrades = COALESCE($13, search_upgrades)
+		WHERE id = $14
 		RETURNING id, media_item_id, enabled, quality_profile_id, monitor_mode,
 		          search_on_add, automatic_search, backlog_search,
 		          prefer_season_packs, minimum_seeders, tags,
 		          search_interval_minutes, last_search_at, next_search_at,
 		          search_count, items_found_count, items_grabbed_count,
-		          created_at, updated_at, created_by_user_id
+		          created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		          template_id
 	`
 
 	var rule MonitoringRule
@@ -213,14 +248,16 @@ func (s *Service) UpdateMonitoringRule(ctx context.Context, id int64, params Upd
 		params.Enabled, params.QualityProfileID, params.MonitorMode,
 		params.SearchOnAdd, params.AutomaticSearch, params.BacklogSearch,
 		params.PreferSeasonPacks, params.MinimumSeeders, params.Tags,
-		params.SearchIntervalMinutes, id,
+		params.SearchIntervalMinutes, params.RootFolderID, params.SeriesType,
+		params.SearchUpgrades, id,
 	).Scan(
 		&rule.ID, &rule.MediaItemID, &rule.Enabled, &rule.QualityProfile, &rule.MonitorMode,
 		&rule.SearchOnAdd, &rule.AutomaticSearch, &rule.BacklogSearch,
 		&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 		&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 		&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+		&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+		&rule.TemplateID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -229,9 +266,25 @@ func (s *Service) UpdateMonitoringRule(ctx context.Context, id int64, params Upd
 		return nil, fmt.Errorf("failed to update monitoring rule: %w", err)
 	}
 
+	if params.MonitorMode != nil {
+		if err := s.ApplyMonitorMode(ctx, &rule); err != nil {
+			return nil, err
+		}
+	}
+
 	return &rule, nil
 }
 
+// RootFolderExists reports whether a root folder exists
+func (s *Service) RootFolderExists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM root_folders WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up root folder: %w", err)
+	}
+	return exists, nil
+}
+
 // DeleteMonitoringRule deletes a monitoring rule
 func (s *Service) DeleteMonitoringRule(ctx context.Context, id int64) error {
 	query := `DELETE FROM monitoring_rules WHERE id = $1`
@@ -248,24 +301,60 @@ func (s *Service) DeleteMonitoringRule(ctx context.Context, id int64) error {
 	return nil
 }
 
-// GetMonitoringRulesDueForSearch returns monitoring rules that need to be searched
+// cutoffMetSQL is true when a rule's media item leaves nothing to search for: an
+// episode whose file meets its quality profile's cutoff, or a series or season
+// all of whose monitored episodes have such a file. The rule is aliased mr.
+const cutoffMetSQL = `
+		      EXISTS (
+		          SELECT 1 FROM episode_monitoring em
+		          WHERE em.media_item_id = mr.media_item_id AND em.has_file AND em.cutoff_met
+		      )
+		      OR (
+		          EXISTS (
+		              SELECT 1 FROM media_items e
+		              JOIN media_items season ON season.id = e.parent_id
+		              WHERE e.kind = 'tv_episode' AND mr.media_item_id IN (season.id, season.parent_id)
+		          )
+		          AND NOT EXISTS (
+		              SELECT 1 FROM media_items e
+		              JOIN media_items season ON season.id = e.parent_id
+		              LEFT JOIN episode_monitoring em ON em.media_item_id = e.id
+		              WHERE e.kind = 'tv_episode'
+		                AND mr.media_item_id IN (season.id, season.parent_id)
+		                AND COALESCE(em.monitored, true) = true
+		                AND NOT COALESCE(em.has_file AND em.cutoff_met, false)
+		          )
+		      )`
+
+// GetMonitoringRulesDueForSearch returns monitoring rules that need to be searched.
+// Rules whose media already meets the quality cutoff are skipped unless they
+// search for upgrades, and movies until shortly before they become available.
 func (s *Service) GetMonitoringRulesDueForSearch(ctx context.Context) ([]MonitoringRule, error) {
+	preference, preReleaseDays := s.movieAvailability(ctx)
 	query := `
 		SELECT id, media_item_id, enabled, quality_profile_id, monitor_mode,
 		       search_on_add, automatic_search, backlog_search,
 		       prefer_season_packs, minimum_seeders, tags,
 		       search_interval_minutes, last_search_at, next_search_at,
 		       search_count, items_found_count, items_grabbed_count,
-		       created_at, updated_at, created_by_user_id
-		FROM monitoring_rules
+		       created_at, updated_at, created_by_user_id, root_folder_id, series_type, search_upgrades,
+		       template_id
+		FROM monitoring_rules mr
 		WHERE enabled = true
 		  AND automatic_search = true
 		  AND (next_search_at IS NULL OR next_search_at <= NOW())
+		  AND (search_upgrades OR NOT (` + cutoffMetSQL + `))
+		  AND NOT EXISTS (
+		      SELECT 1 FROM media_items mi
+		      WHERE mi.id = mr.media_item_id
+		        AND mi.kind = 'movie'
+		        AND ` + movieAvailableSQL(preference) + ` > CURRENT_DATE + $1::int
+		  )
 		ORDER BY next_search_at ASC NULLS FIRST
 		LIMIT 100
 	`
 
-	rows, err := s.db.Query(ctx, query)
+	rows, err := s.db.Query(ctx, query, preReleaseDays)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get monitoring rules due for search: %w", err)
 	}
@@ -280,7 +369,8 @@ func (s *Service) GetMonitoringRulesDueForSearch(ctx context.Context) ([]Monitor
 			&rule.PreferSeasonPacks, &rule.MinimumSeeders, &rule.Tags,
 			&rule.SearchIntervalMinutes, &rule.LastSearchAt, &rule.NextSearchAt,
 			&rule.SearchCount, &rule.ItemsFoundCount, &rule.ItemsGrabbedCount,
-			&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser,
+			&rule.CreatedAt, &rule.UpdatedAt, &rule.CreatedByUser, &rule.RootFolderID, &rule.SeriesType, &rule.SearchUpgrades,
+			&rule.TemplateID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan monitoring rule: %w", err)
@@ -309,6 +399,23 @@ func (s *Service) UpdateMonitoringRuleSearchTime(ctx context.Context, id int64)
 	return nil
 }
 
+// RecordMonitoringRuleResults adds the outcome of a search run to the rule statistics
+func (s *Service) RecordMonitoringRuleResults(ctx context.Context, id int64, found, grabbed int) error {
+	query := `
+		UPDATE monitoring_rules
+		SET items_found_count = items_found_count + $1,
+		    items_grabbed_count = items_grabbed_count + $2
+		WHERE id = $3
+	`
+
+	_, err := s.db.Exec(ctx, query, found, grabbed, id)
+	if err != nil {
+		return fmt.Errorf("failed to record monitoring rule results: %w", err)
+	}
+
+	return nil
+}
+
 // ========================
 // Episode Monitoring
 // ========================
@@ -320,14 +427,14 @@ func (s *Service) CreateEpisodeMonitoring(ctx context.Context, mediaItemID int64
 		VALUES ($1, $2)
 		ON CONFLICT (media_item_id) DO UPDATE
 		SET monitored = EXCLUDED.monitored
-		RETURNING id, media_item_id, monitored, has_file, file_id,
+		RETURNING id, media_item_id, monitored, has_file, cutoff_met, file_id,
 		          air_date, air_date_utc, search_count, last_search_at,
 		          created_at, updated_at
 	`
 
 	var em EpisodeMonitoring
 	err := s.db.QueryRow(ctx, query, mediaItemID, monitored).Scan(
-		&em.ID, &em.MediaItemID, &em.Monitored, &em.HasFile, &em.FileID,
+		&em.ID, &em.MediaItemID, &em.Monitored, &em.HasFile, &em.CutoffMet, &em.FileID,
 		&em.AirDate, &em.AirDateUTC, &em.SearchCount, &em.LastSearchAt,
 		&em.CreatedAt, &em.UpdatedAt,
 	)
@@ -341,7 +448,7 @@ func (s *Service) CreateEpisodeMonitoring(ctx context.Context, mediaItemID int64
 // GetMissingEpisodes returns monitored episodes without files
 func (s *Service) GetMissingEpisodes(ctx context.Context, limit int) ([]EpisodeMonitoring, error) {
 	query := `
-		SELECT id, media_item_id, monitored, has_file, file_id,
+		SELECT id, media_item_id, monitored, has_file, cutoff_met, file_id,
 		       air_date, air_date_utc, search_count, last_search_at,
 		       created_at, updated_at
 		FROM episode_monitoring
@@ -362,7 +469,7 @@ func (s *Service) GetMissingEpisodes(ctx context.Context, limit int) ([]EpisodeM
 	for rows.Next() {
 		var em EpisodeMonitoring
 		err := rows.Scan(
-			&em.ID, &em.MediaItemID, &em.Monitored, &em.HasFile, &em.FileID,
+			&em.ID, &em.MediaItemID, &em.Monitored, &em.HasFile, &em.CutoffMet, &em.FileID,
 			&em.AirDate, &em.AirDateUTC, &em.SearchCount, &em.LastSearchAt,
 			&em.CreatedAt, &em.UpdatedAt,
 		)
@@ -375,6 +482,191 @@ func (s *Service) GetMissingEpisodes(ctx context.Context, limit int) ([]EpisodeM
 	return episodes, rows.Err()
 }
 
+// GetEffectiveMonitoringRule returns the monitoring rule that applies to a media
+// item: its own rule, or the rule of its season or series. It returns nil when no
+// rule applies.
+func (s *Service) GetEffectiveMonitoringRule(ctx context.Context, mediaItemID int64) (*MonitoringRule, error) {
+	query := `
+		SELECT mr.id
+		FROM media_items m
+		LEFT JOIN media_items parent ON parent.id = m.parent_id
+		JOIN monitoring_rules mr ON mr.media_item_id IN (m.id, parent.id, parent.parent_id)
+		WHERE m.id = $1
+		ORDER BY (mr.media_item_id = m.id) DESC, (mr.media_item_id = parent.id) DESC
+		LIMIT 1
+	`
+
+	var ruleID int64
+	err := s.db.QueryRow(ctx, query, mediaItemID).Scan(&ruleID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find monitoring rule: %w", err)
+	}
+
+	return s.GetMonitoringRule(ctx, ruleID)
+}
+
+// GetMissingEpisodesForMedia returns the monitored, already aired episodes below a
+// series or season that have no file and no active download for the episode or its season
+func (s *Service) GetMissingEpisodesForMedia(ctx context.Context, mediaItemID int64, limit int) ([]MissingEpisode, error) {
+	query := `
+		SELECT e.id, season.id
+		FROM media_items e
+		JOIN media_items season ON e.parent_id = season.id
+		LEFT JOIN episode_monitoring em ON em.media_item_id = e.id
+		WHERE e.kind = 'tv_episode'
+		  AND (season.id = $1 OR season.parent_id = $1)
+		  AND COALESCE(em.monitored, true) = true
+		  AND COALESCE(em.has_file, false) = false
+		  AND (em.air_date IS NULL OR em.air_date <= CURRENT_DATE)
+		  AND (e.metadata->>'air_date' IS NULL OR e.metadata->>'air_date' <= TO_CHAR(CURRENT_DATE, 'YYYY-MM-DD'))
+		  AND NOT EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media')
+		  AND NOT EXISTS (
+		      SELECT 1 FROM media_file_episodes fe JOIN media_files f ON f.id = fe.media_file_id
+		      WHERE fe.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media'
+		  )
+		  AND NOT EXISTS (
+		      SELECT 1 FROM downloads d
+		      WHERE d.media_item_id IN (e.id, season.id)
+		        AND d.status IN ('queued', 'downloading', 'paused', 'processing', 'waiting_import')
+		  )
+		ORDER BY e.id
+		LIMIT $2
+	`
+
+	rows, err := s.db.Query(ctx, query, mediaItemID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missing episodes: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []MissingEpisode
+	for rows.Next() {
+		var episode MissingEpisode
+		if err := rows.Scan(&episode.MediaItemID, &episode.SeasonID); err != nil {
+			return nil, fmt.Errorf("failed to scan episode: %w", err)
+		}
+		episodes = append(episodes, episode)
+	}
+
+	return episodes, rows.Err()
+}
+
+// IsMediaSatisfied reports whether a media item already has a file or an active download
+func (s *Service) IsMediaSatisfied(ctx context.Context, mediaItemID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM media_files WHERE media_item_id = $1 AND status = 'ok' AND kind = 'media')
+		    OR EXISTS (
+		        SELECT 1 FROM media_file_episodes fe JOIN media_files f ON f.id = fe.media_file_id
+		        WHERE fe.media_item_id = $1 AND f.status = 'ok' AND f.kind = 'media'
+		    )
+		    OR EXISTS (
+		        SELECT 1 FROM downloads
+		        WHERE media_item_id = $1
+		          AND status IN ('queued', 'downloading', 'paused', 'processing', 'waiting_import')
+		    )
+	`
+
+	var satisfied bool
+	if err := s.db.QueryRow(ctx, query, mediaItemID).Scan(&satisfied); err != nil {
+		return false, fmt.Errorf("failed to check media status: %w", err)
+	}
+
+	return satisfied, nil
+}
+
+// IsCutoffMet reports whether a media item's file meets its quality profile's
+// cutoff, so there is nothing left to upgrade
+func (s *Service) IsCutoffMet(ctx context.Context, mediaItemID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+		    SELECT 1 FROM episode_monitoring
+		    WHERE media_item_id = $1 AND has_file = true AND cutoff_met = true
+		)
+	`
+
+	var met bool
+	if err := s.db.QueryRow(ctx, query, mediaItemID).Scan(&met); err != nil {
+		return false, fmt.Errorf("failed to check quality cutoff: %w", err)
+	}
+
+	return met, nil
+}
+
+// GetBacklogEpisodes returns monitored, already aired episodes without a file,
+// optionally scoped to an episode, season or series. Episodes that have been
+// searched the least come first. Episodes whose nearest monitoring rule is
+// disabled or has backlog search turned off are skipped.
+func (s *Service) GetBacklogEpisodes(ctx context.Context, mediaItemID *int64) ([]BacklogEpisode, error) {
+	query := `
+		SELECT em.media_item_id, rule.id
+		FROM episode_monitoring em
+		JOIN media_items e ON e.id = em.media_item_id
+		LEFT JOIN media_items season ON season.id = e.parent_id
+		LEFT JOIN LATERAL (
+		    SELECT mr.id, mr.enabled, mr.backlog_search
+		    FROM monitoring_rules mr
+		    WHERE mr.media_item_id IN (e.id, season.id, season.parent_id)
+		    ORDER BY (mr.media_item_id = e.id) DESC, (mr.media_item_id = season.id) DESC
+		    LIMIT 1
+		) rule ON true
+		WHERE em.monitored = true
+		  AND em.has_file = false
+		  AND (em.air_date IS NULL OR em.air_date <= CURRENT_DATE)
+		  AND COALESCE(rule.enabled AND rule.backlog_search, true) = true
+		  AND ($1::BIGINT IS NULL OR e.id = $1 OR season.id = $1 OR season.parent_id = $1)
+		  AND NOT EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media')
+		  AND NOT EXISTS (
+		      SELECT 1 FROM media_file_episodes fe JOIN media_files f ON f.id = fe.media_file_id
+		      WHERE fe.media_item_id = e.id AND f.status = 'ok' AND f.kind = 'media'
+		  )
+		  AND NOT EXISTS (
+		      SELECT 1 FROM downloads d
+		      WHERE d.media_item_id IN (e.id, e.parent_id)
+		        AND d.status IN ('queued', 'downloading', 'paused', 'processing', 'waiting_import')
+		  )
+		ORDER BY em.search_count ASC, em.air_date DESC NULLS LAST
+	`
+
+	rows, err := s.db.Query(ctx, query, mediaItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backlog episodes: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []BacklogEpisode
+	for rows.Next() {
+		var episode BacklogEpisode
+		if err := rows.Scan(&episode.MediaItemID, &episode.RuleID); err != nil {
+			return nil, fmt.Errorf("failed to scan backlog episode: %w", err)
+		}
+		episodes = append(episodes, episode)
+	}
+
+	return episodes, rows.Err()
+}
+
+// RecordEpisodeSearch records that an episode was searched. Searches that did not
+// grab anything increment the search count, which pushes the episode further back
+// in later backlog runs.
+func (s *Service) RecordEpisodeSearch(ctx context.Context, mediaItemID int64, grabbed bool) error {
+	query := `
+		UPDATE episode_monitoring
+		SET last_search_at = NOW(),
+		    search_count = search_count + CASE WHEN $2 THEN 0 ELSE 1 END
+		WHERE media_item_id = $1
+	`
+
+	_, err := s.db.Exec(ctx, query, mediaItemID, grabbed)
+	if err != nil {
+		return fmt.Errorf("failed to record episode search: %w", err)
+	}
+
+	return nil
+}
+
 // ========================
 // Search History
 // ========================
@@ -490,6 +782,57 @@ func (s *Service) CreateBlocklistEntry(ctx context.Context, params CreateBlockli
 	return &entry, nil
 }
 
+// ListBlocklist lists blocklist entries, newest first
+func (s *Service) ListBlocklist(ctx context.Context, limit, offset int) (*BlocklistPage, error) {
+	page := &BlocklistPage{Items: []BlocklistEntry{}, Limit: limit, Offset: offset}
+
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM blocklist`).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count blocklist entries: %w", err)
+	}
+
+	query := `
+		SELECT id, media_item_id, release_hash, release_title, indexer_id, reason, message,
+		       permanent, expires_at, download_id, search_history_id, created_at, created_by_user_id
+		FROM blocklist
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := s.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocklist entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry BlocklistEntry
+		err := rows.Scan(
+			&entry.ID, &entry.MediaItemID, &entry.ReleaseHash, &entry.ReleaseTitle, &entry.IndexerID, &entry.Reason, &entry.Message,
+			&entry.Permanent, &entry.ExpiresAt, &entry.DownloadID, &entry.SearchHistoryID, &entry.CreatedAt, &entry.CreatedByUser,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+		}
+		page.Items = append(page.Items, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page.HasMore = int64(offset+len(page.Items)) < page.Total
+	return page, nil
+}
+
+// DeleteBlocklistEntry removes a blocklist entry. It returns false when no entry has the ID.
+func (s *Service) DeleteBlocklistEntry(ctx context.Context, id int64) (bool, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM blocklist WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
 // IsBlocked checks if a release is blocked
 func (s *Service) IsBlocked(ctx context.Context, releaseHash string, mediaItemID *int64) (bool, error) {
 	query := `
@@ -561,6 +904,299 @@ func (s *Service) GetCalendarEvents(ctx context.Context, startDate, endDate time
 	return events, rows.Err()
 }
 
+// UpsertCalendarEvent creates a calendar event or updates the existing one for the
+// same item, type and key. File flags are derived from the item's media files and downloads.
+func (s *Service) UpsertCalendarEvent(ctx context.Context, params UpsertCalendarEventParams) error {
+	metadataJSON, err := json.Marshal(params.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	query := `
+		INSERT INTO calendar_events (
+			media_item_id, event_type, event_key, event_date, event_datetime_utc,
+			monitored, has_file, downloaded, title, parent_title, metadata
+		) VALUES (
+			$1, $2, $3, $4, $5, $6,
+			EXISTS (SELECT 1 FROM media_files WHERE media_item_id = $1)
+			    OR EXISTS (SELECT 1 FROM media_file_episodes WHERE media_item_id = $1),
+			EXISTS (SELECT 1 FROM media_files WHERE media_item_id = $1)
+			    OR EXISTS (SELECT 1 FROM media_file_episodes WHERE media_item_id = $1)
+			    OR EXISTS (SELECT 1 FROM downloads WHERE media_item_id = $1 AND status = 'completed'),
+			$7, $8, $9
+		)
+		ON CONFLICT (media_item_id, event_type, event_key) DO UPDATE SET
+			event_date = EXCLUDED.event_date,
+			event_datetime_utc = EXCLUDED.event_datetime_utc,
+			monitored = EXCLUDED.monitored,
+			has_file = EXCLUDED.has_file,
+			downloaded = EXCLUDED.downloaded,
+			title = EXCLUDED.title,
+			parent_title = EXCLUDED.parent_title,
+			metadata = EXCLUDED.metadata
+	`
+
+	_, err = s.db.Exec(ctx, query,
+		params.MediaItemID, params.EventType, params.EventKey, params.EventDate, params.EventDateTimeUTC,
+		params.Monitored, params.Title, params.ParentTitle, metadataJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert calendar event: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCalendarEvent deletes the calendar event of an item with the given type and key
+func (s *Service) DeleteCalendarEvent(ctx context.Context, mediaItemID int64, eventType EventType, eventKey string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM calendar_events
+		WHERE media_item_id = $1 AND event_type = $2 AND event_key = $3
+	`, mediaItemID, eventType, eventKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar event: %w", err)
+	}
+
+	return nil
+}
+
+// RefreshCalendarEventStatus recomputes the file and download flags of every calendar event
+func (s *Service) RefreshCalendarEventStatus(ctx context.Context) error {
+	query := `
+		UPDATE calendar_events ce
+		SET has_file = EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = ce.media_item_id)
+		        OR EXISTS (SELECT 1 FROM media_file_episodes fe WHERE fe.media_item_id = ce.media_item_id),
+		    downloaded = EXISTS (SELECT 1 FROM media_files f WHERE f.media_item_id = ce.media_item_id)
+		        OR EXISTS (SELECT 1 FROM media_file_episodes fe WHERE fe.media_item_id = ce.media_item_id)
+		        OR EXISTS (
+		            SELECT 1 FROM downloads d
+		            WHERE d.media_item_id = ce.media_item_id AND d.status = 'completed'
+		        )
+	`
+
+	_, err := s.db.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to refresh calendar event status: %w", err)
+	}
+
+	return nil
+}
+
+// ListCalendarMediaItems lists media items of a kind with whether an enabled
+// monitoring rule covers them. With monitoredOnly, unmonitored items are skipped.
+func (s *Service) ListCalendarMediaItems(ctx context.Context, kind string, monitoredOnly bool) ([]CalendarMediaItem, error) {
+	query := `
+		SELECT m.id, m.title, COALESCE(mr.enabled, false), m.metadata, m.external_ids
+		FROM media_items m
+		LEFT JOIN monitoring_rules mr ON mr.media_item_id = m.id
+		WHERE m.kind = $1
+		  AND ($2 = false OR mr.enabled = true)
+		ORDER BY m.id
+	`
+
+	rows, err := s.db.Query(ctx, query, kind, monitoredOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar media items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []CalendarMediaItem
+	for rows.Next() {
+		var item CalendarMediaItem
+		var metadataJSON, externalIDsJSON []byte
+		if err := rows.Scan(&item.ID, &item.Title, &item.Monitored, &metadataJSON, &externalIDsJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan media item: %w", err)
+		}
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &item.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+		if len(externalIDsJSON) > 0 {
+			if err := json.Unmarshal(externalIDsJSON, &item.ExternalIDs); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal external ids: %w", err)
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// FindEpisode looks up the episode of a series by season and episode number, and
+// whether it is monitored. It returns nil when the library has no such episode yet.
+func (s *Service) FindEpisode(ctx context.Context, seriesID int64, season, episode int) (*int64, bool, error) {
+	query := `
+		SELECT e.id, COALESCE(em.monitored, true)
+		FROM media_items e
+		JOIN media_items season ON season.id = e.parent_id
+		LEFT JOIN episode_monitoring em ON em.media_item_id = e.id
+		WHERE e.kind = 'tv_episode'
+		  AND season.parent_id = $1
+		  AND COALESCE(e.metadata->>'season', e.metadata->>'season_number') = $2::TEXT
+		  AND COALESCE(e.metadata->>'episode', e.metadata->>'episode_number') = $3::TEXT
+		LIMIT 1
+	`
+
+	var id int64
+	var monitored bool
+	err := s.db.QueryRow(ctx, query, seriesID, season, episode).Scan(&id, &monitored)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to find episode: %w", err)
+	}
+
+	return &id, monitored, nil
+}
+
+// ========================
+// RSS Sync
+// ========================
+
+// rssSyncStateColumns are the columns scanned by scanRSSSyncState
+const rssSyncStateColumns = `
+	id, indexer_id, plugin_id, indexer_name, last_sync_at, next_sync_at,
+	COALESCE(sync_interval_minutes, 0), COALESCE(total_syncs, 0),
+	COALESCE(total_items_found, 0), COALESCE(total_items_grabbed, 0),
+	last_items_found, last_items_grabbed, COALESCE(consecutive_failures, 0),
+	last_error, last_release_at, seen_guids, enabled, created_at, updated_at`
+
+// scanRSSSyncState scans a row of rssSyncStateColumns
+func scanRSSSyncState(row pgx.Row) (*RSSSyncState, error) {
+	var state RSSSyncState
+	err := row.Scan(
+		&state.ID, &state.IndexerID, &state.PluginID, &state.IndexerName, &state.LastSyncAt, &state.NextSyncAt,
+		&state.SyncIntervalMinutes, &state.TotalSyncs,
+		&state.TotalItemsFound, &state.TotalItemsGrabbed,
+		&state.LastItemsFound, &state.LastItemsGrabbed, &state.ConsecutiveFailures,
+		&state.LastError, &state.LastReleaseAt, &state.SeenGUIDs, &state.Enabled, &state.CreatedAt, &state.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetRSSSyncState gets the sync state of an indexer's RSS feed, or nil when the
+// feed has never been synced
+func (s *Service) GetRSSSyncState(ctx context.Context, indexerID string) (*RSSSyncState, error) {
+	query := `SELECT ` + rssSyncStateColumns + ` FROM rss_sync_state WHERE indexer_id = $1`
+
+	state, err := scanRSSSyncState(s.db.QueryRow(ctx, query, indexerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get RSS sync state: %w", err)
+	}
+	return state, nil
+}
+
+// ListRSSSyncStates lists the sync state of every RSS feed
+func (s *Service) ListRSSSyncStates(ctx context.Context) ([]RSSSyncState, error) {
+	query := `SELECT ` + rssSyncStateColumns + ` FROM rss_sync_state ORDER BY plugin_id, indexer_name, indexer_id`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RSS sync states: %w", err)
+	}
+	defer rows.Close()
+
+	states := []RSSSyncState{}
+	for rows.Next() {
+		state, err := scanRSSSyncState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan RSS sync state: %w", err)
+		}
+		states = append(states, *state)
+	}
+
+	return states, rows.Err()
+}
+
+// RecordRSSSync records a successful sync of an indexer's feed and moves its
+// high-water mark
+func (s *Service) RecordRSSSync(ctx context.Context, params RecordRSSSyncParams) error {
+	query := `
+		INSERT INTO rss_sync_state (
+			indexer_id, plugin_id, indexer_name, last_sync_at, next_sync_at,
+			total_syncs, total_items_found, total_items_grabbed,
+			last_items_found, last_items_grabbed, consecutive_failures,
+			last_release_at, seen_guids
+		) VALUES ($1, $2, $3, NOW(), $4, 1, $5, $6, $5, $6, 0, $7, $8)
+		ON CONFLICT (indexer_id) DO UPDATE SET
+			plugin_id = EXCLUDED.plugin_id,
+			indexer_name = EXCLUDED.indexer_name,
+			last_sync_at = NOW(),
+			next_sync_at = EXCLUDED.next_sync_at,
+			total_syncs = COALESCE(rss_sync_state.total_syncs, 0) + 1,
+			total_items_found = COALESCE(rss_sync_state.total_items_found, 0) + EXCLUDED.last_items_found,
+			total_items_grabbed = COALESCE(rss_sync_state.total_items_grabbed, 0) + EXCLUDED.last_items_grabbed,
+			last_items_found = EXCLUDED.last_items_found,
+			last_items_grabbed = EXCLUDED.last_items_grabbed,
+			consecutive_failures = 0,
+			last_error = NULL,
+			last_release_at = COALESCE(EXCLUDED.last_release_at, rss_sync_state.last_release_at),
+			seen_guids = EXCLUDED.seen_guids
+	`
+
+	seen := params.SeenGUIDs
+	if seen == nil {
+		seen = []string{}
+	}
+	_, err := s.db.Exec(ctx, query,
+		params.IndexerID, params.PluginID, params.IndexerName, params.NextSyncAt,
+		params.ItemsFound, params.ItemsGrabbed, params.LastReleaseAt, seen,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record RSS sync: %w", err)
+	}
+	return nil
+}
+
+// RecordRSSSyncFailure records a failed sync of an indexer's feed. Its
+// high-water mark is kept, so the next sync picks up what was missed.
+func (s *Service) RecordRSSSyncFailure(ctx context.Context, indexerID, pluginID, indexerName, message string, nextSyncAt *time.Time) error {
+	query := `
+		INSERT INTO rss_sync_state (indexer_id, plugin_id, indexer_name, last_sync_at, next_sync_at, consecutive_failures, last_error)
+		VALUES ($1, $2, $3, NOW(), $4, 1, $5)
+		ON CONFLICT (indexer_id) DO UPDATE SET
+			plugin_id = EXCLUDED.plugin_id,
+			indexer_name = EXCLUDED.indexer_name,
+			last_sync_at = NOW(),
+			next_sync_at = EXCLUDED.next_sync_at,
+			last_items_found = 0,
+			last_items_grabbed = 0,
+			consecutive_failures = COALESCE(rss_sync_state.consecutive_failures, 0) + 1,
+			last_error = EXCLUDED.last_error
+	`
+
+	if _, err := s.db.Exec(ctx, query, indexerID, pluginID, indexerName, nextSyncAt, message); err != nil {
+		return fmt.Errorf("failed to record RSS sync failure: %w", err)
+	}
+	return nil
+}
+
+// HasActiveDownload reports whether a media item has a download that is queued,
+// running or waiting to be imported
+func (s *Service) HasActiveDownload(ctx context.Context, mediaItemID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+		    SELECT 1 FROM downloads
+		    WHERE media_item_id = $1
+		      AND status IN ('queued', 'downloading', 'paused', 'processing', 'waiting_import')
+		)
+	`
+
+	var active bool
+	if err := s.db.QueryRow(ctx, query, mediaItemID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check active downloads: %w", err)
+	}
+	return active, nil
+}
+
 // ========================
 // Statistics
 // ========================
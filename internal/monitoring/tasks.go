@@ -0,0 +1,255 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Scheduler jobs are listed and controlled by name as system tasks, under
+// /api/system/tasks
+
+// ErrTaskNotFound is returned for a task name no scheduler job has
+var ErrTaskNotFound = errors.New("task not found")
+
+// maxJobLogEntries is how many log lines of a run are kept in its history.
+// Later lines are counted but dropped.
+const maxJobLogEntries = 100
+
+// JobLogEntry is a line a job logged during a run
+type JobLogEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// jobLog collects the lines a job logs during a run
+type jobLog struct {
+	mu      sync.Mutex
+	lines   []JobLogEntry
+	dropped int
+}
+
+func (l *jobLog) add(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) >= maxJobLogEntries {
+		l.dropped++
+		return
+	}
+	l.lines = append(l.lines, JobLogEntry{Time: time.Now(), Message: message})
+}
+
+// entries returns the lines logged, noting how many were dropped
+func (l *jobLog) entries() []JobLogEntry {
+	if l == nil {
+		return []JobLogEntry{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := append([]JobLogEntry{}, l.lines...)
+	if l.dropped > 0 {
+		lines = append(lines, JobLogEntry{Time: time.Now(), Message: fmt.Sprintf("%d more lines not kept", l.dropped)})
+	}
+	return lines
+}
+
+// Logf logs a line of a job's run, kept in the run's history
+func (j *SchedulerJob) Logf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Printf("%s: %s\n", j.JobName, message)
+	if j.log != nil {
+		j.log.add(message)
+	}
+}
+
+// nextRun returns when a job runs next after a run finishing at from, going
+// by its cron expression or else its interval. It is nil for jobs with
+// neither.
+func (j *SchedulerJob) nextRun(from time.Time) *time.Time {
+	if j.CronExpression != nil && *j.CronExpression != "" {
+		schedule, err := ParseCron(*j.CronExpression)
+		if err != nil {
+			return nil
+		}
+		next := schedule.Next(from)
+		if next.IsZero() {
+			return nil
+		}
+		return &next
+	}
+	if j.IntervalMinutes != nil && *j.IntervalMinutes > 0 {
+		next := from.Add(time.Duration(*j.IntervalMinutes) * time.Minute)
+		return &next
+	}
+	return nil
+}
+
+// Task is a scheduler job as listed by the system tasks API
+type Task struct {
+	SchedulerJob
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateTaskParams changes the schedule of a task. Setting an interval
+// replaces a cron expression, and an empty cron expression goes back to the
+// interval.
+type UpdateTaskParams struct {
+	IntervalMinutes *int    `json:"interval_minutes"`
+	CronExpression  *string `json:"cron_expression"`
+	Enabled         *bool   `json:"enabled"`
+}
+
+func newTask(job SchedulerJob) Task {
+	description, _ := job.Config["description"].(string)
+	return Task{SchedulerJob: job, Description: description}
+}
+
+// ListTasks lists all scheduler jobs as tasks
+func (s *Scheduler) ListTasks(ctx context.Context) ([]Task, error) {
+	jobs, err := s.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]Task, 0, len(jobs))
+	for _, job := range jobs {
+		tasks = append(tasks, newTask(job))
+	}
+	return tasks, nil
+}
+
+// GetTask gets a task by name
+func (s *Scheduler) GetTask(ctx context.Context, name string) (*Task, error) {
+	var id int64
+	err := s.db.QueryRow(ctx, `SELECT id FROM scheduler_jobs WHERE job_name = $1`, name).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find task: %w", err)
+	}
+
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	task := newTask(*job)
+	return &task, nil
+}
+
+// RunTask runs a task now. A task that is already running is not run again;
+// the attempt is recorded as skipped and ErrJobRunning returned.
+func (s *Scheduler) RunTask(ctx context.Context, name string) error {
+	task, err := s.GetTask(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.startJob(ctx, &task.SchedulerJob)
+}
+
+// UpdateTask changes the schedule of a task or enables or disables it. Its
+// next run is worked out again from the new schedule.
+func (s *Scheduler) UpdateTask(ctx context.Context, name string, params UpdateTaskParams) (*Task, error) {
+	task, err := s.GetTask(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	job := task.SchedulerJob
+
+	if params.IntervalMinutes != nil {
+		if *params.IntervalMinutes < 1 {
+			return nil, fmt.Errorf("interval must be at least one minute")
+		}
+		job.IntervalMinutes = params.IntervalMinutes
+		job.CronExpression = nil
+	}
+	if params.CronExpression != nil {
+		expr := strings.TrimSpace(*params.CronExpression)
+		if expr == "" {
+			job.CronExpression = nil
+		} else {
+			if _, err := ParseCron(expr); err != nil {
+				return nil, fmt.Errorf("invalid cron expression: %w", err)
+			}
+			job.CronExpression = &expr
+		}
+	}
+	if params.Enabled != nil {
+		job.Enabled = *params.Enabled
+	}
+	if job.CronExpression == nil && job.IntervalMinutes == nil {
+		return nil, fmt.Errorf("task needs an interval or a cron expression")
+	}
+
+	// Interval tasks keep counting from their last run, cron tasks go by the clock
+	from := time.Now()
+	if job.CronExpression == nil && job.LastRunAt != nil {
+		from = *job.LastRunAt
+	}
+	nextRunAt := job.nextRun(from)
+
+	query := `
+		UPDATE scheduler_jobs
+		SET interval_minutes = $1,
+		    cron_expression = $2,
+		    enabled = $3,
+		    next_run_at = $4
+		WHERE id = $5
+	`
+	if _, err := s.db.Exec(ctx, query, job.IntervalMinutes, job.CronExpression, job.Enabled, nextRunAt, job.ID); err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+	return s.GetTask(ctx, name)
+}
+
+// TaskHistory lists the latest runs of a task, newest first
+func (s *Scheduler) TaskHistory(ctx context.Context, name string, limit int) ([]SchedulerJobHistory, error) {
+	task, err := s.GetTask(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, job_id, started_at, finished_at, duration_ms, status, error_message,
+		       COALESCE(items_processed, 0), log_entries, metadata, created_at
+		FROM scheduler_job_history
+		WHERE job_id = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := s.db.Query(ctx, query, task.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task history: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []SchedulerJobHistory{}
+	for rows.Next() {
+		var run SchedulerJobHistory
+		var logJSON, metadataJSON []byte
+		err := rows.Scan(
+			&run.ID, &run.JobID, &run.StartedAt, &run.FinishedAt, &run.DurationMs, &run.Status, &run.ErrorMessage,
+			&run.ItemsProcessed, &logJSON, &metadataJSON, &run.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task history: %w", err)
+		}
+		if len(logJSON) > 0 {
+			if err := json.Unmarshal(logJSON, &run.LogEntries); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal task log: %w", err)
+			}
+		}
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &run.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal task metadata: %w", err)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
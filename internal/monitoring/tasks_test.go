@@ -0,0 +1,47 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerJobNextRun(t *testing.T) {
+	from := time.Date(2026, 6, 3, 10, 17, 0, 0, time.UTC)
+	interval := 30
+	cron := "0 * * * *"
+
+	tests := []struct {
+		name string
+		job  SchedulerJob
+		want *time.Time
+	}{
+		{"interval", SchedulerJob{IntervalMinutes: &interval}, ptrTime(from.Add(30 * time.Minute))},
+		{"cron over interval", SchedulerJob{IntervalMinutes: &interval, CronExpression: &cron}, ptrTime(time.Date(2026, 6, 3, 11, 0, 0, 0, time.UTC))},
+		{"no schedule", SchedulerJob{}, nil},
+	}
+	for _, tt := range tests {
+		got := tt.job.nextRun(from)
+		if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+			t.Errorf("%s: nextRun() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJobLogKeepsExcerpt(t *testing.T) {
+	job := SchedulerJob{JobName: "test", log: &jobLog{}}
+	for i := 0; i < maxJobLogEntries+5; i++ {
+		job.Logf("line %d", i)
+	}
+
+	entries := job.log.entries()
+	if len(entries) != maxJobLogEntries+1 {
+		t.Fatalf("got %d entries, want %d", len(entries), maxJobLogEntries+1)
+	}
+	if entries[0].Message != "line 0" || entries[len(entries)-1].Message != "5 more lines not kept" {
+		t.Errorf("entries = %q ... %q", entries[0].Message, entries[len(entries)-1].Message)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,530 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Config keys the monitoring defaults of each kind are stored under
+const (
+	configDefaultsMovie    = "monitoring.defaults.movie"
+	configDefaultsTVSeries = "monitoring.defaults.tv_series"
+)
+
+var (
+	// ErrTemplateNotFound is returned for a monitoring template that doesn't exist
+	ErrTemplateNotFound = errors.New("monitoring template not found")
+
+	// ErrTemplateNameTaken is returned when a template's name is already used
+	ErrTemplateNameTaken = errors.New("a monitoring template with this name already exists")
+)
+
+// RuleSettings are the settings a new monitoring rule starts with, taken from
+// the monitoring defaults of its kind or from a template
+type RuleSettings struct {
+	Enabled               bool        `json:"enabled"`
+	QualityProfileID      *int        `json:"quality_profile_id"`
+	RootFolderID          *int64      `json:"root_folder_id"`
+	MonitorMode           MonitorMode `json:"monitor_mode"`
+	SeriesType            SeriesType  `json:"series_type"`
+	SearchOnAdd           bool        `json:"search_on_add"`
+	AutomaticSearch       bool        `json:"automatic_search"`
+	BacklogSearch         bool        `json:"backlog_search"`
+	SearchUpgrades        bool        `json:"search_upgrades"`
+	PreferSeasonPacks     bool        `json:"prefer_season_packs"`
+	MinimumSeeders        int         `json:"minimum_seeders"`
+	Tags                  []string    `json:"tags"`
+	SearchIntervalMinutes int         `json:"search_interval_minutes"`
+}
+
+// builtinRuleSettings returns the settings of new rules when no monitoring
+// defaults were saved, matching the column defaults of monitoring_rules
+func builtinRuleSettings() RuleSettings {
+	return RuleSettings{
+		Enabled:               true,
+		MonitorMode:           MonitorModeAll,
+		SeriesType:            SeriesTypeStandard,
+		SearchOnAdd:           true,
+		AutomaticSearch:       true,
+		BacklogSearch:         true,
+		MinimumSeeders:        1,
+		Tags:                  []string{},
+		SearchIntervalMinutes: 60,
+	}
+}
+
+// Validate normalizes the settings and checks every option
+func (rs *RuleSettings) Validate() error {
+	if rs.MonitorMode == "" {
+		rs.MonitorMode = MonitorModeAll
+	}
+	if !rs.MonitorMode.IsValid() {
+		return fmt.Errorf("unknown monitor mode: %s", rs.MonitorMode)
+	}
+	if rs.SeriesType == "" {
+		rs.SeriesType = SeriesTypeStandard
+	}
+	if !rs.SeriesType.IsValid() {
+		return fmt.Errorf("unknown series type: %s", rs.SeriesType)
+	}
+	if rs.MinimumSeeders < 0 {
+		return errors.New("minimum_seeders can't be negative")
+	}
+	if rs.SearchIntervalMinutes < 1 {
+		return errors.New("search_interval_minutes must be at least 1")
+	}
+	normalized, err := tags.NormalizeAll(rs.Tags)
+	if err != nil {
+		return err
+	}
+	rs.Tags = normalized
+	return nil
+}
+
+// Params expands the settings into the parameters of a rule for a media item
+func (rs RuleSettings) Params(mediaItemID int64) CreateMonitoringRuleParams {
+	return CreateMonitoringRuleParams{
+		MediaItemID:           mediaItemID,
+		Enabled:               rs.Enabled,
+		QualityProfileID:      rs.QualityProfileID,
+		MonitorMode:           rs.MonitorMode,
+		SeriesType:            rs.SeriesType,
+		SearchOnAdd:           rs.SearchOnAdd,
+		AutomaticSearch:       rs.AutomaticSearch,
+		BacklogSearch:         rs.BacklogSearch,
+		SearchUpgrades:        rs.SearchUpgrades,
+		PreferSeasonPacks:     rs.PreferSeasonPacks,
+		MinimumSeeders:        rs.MinimumSeeders,
+		Tags:                  append([]string{}, rs.Tags...),
+		SearchIntervalMinutes: rs.SearchIntervalMinutes,
+		RootFolderID:          rs.RootFolderID,
+	}
+}
+
+// SettingsError is returned for monitoring defaults or a template with
+// invalid settings
+type SettingsError struct {
+	Message string
+}
+
+func (e *SettingsError) Error() string {
+	return e.Message
+}
+
+// checkSettings validates rule settings and checks that their quality profile
+// and root folder exist
+func (s *Service) checkSettings(ctx context.Context, settings *RuleSettings) error {
+	if err := settings.Validate(); err != nil {
+		return &SettingsError{Message: err.Error()}
+	}
+	if settings.QualityProfileID != nil {
+		exists, err := s.QualityProfileExists(ctx, *settings.QualityProfileID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return &SettingsError{Message: "quality profile not found"}
+		}
+	}
+	if settings.RootFolderID != nil {
+		exists, err := s.RootFolderExists(ctx, *settings.RootFolderID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return &SettingsError{Message: "root folder not found"}
+		}
+	}
+	return nil
+}
+
+// ========================
+// Monitoring Defaults
+// ========================
+
+// MonitoringDefaults are the settings of rules created without a template,
+// by kind of media
+type MonitoringDefaults struct {
+	Movie    RuleSettings `json:"movie"`
+	TVSeries RuleSettings `json:"tv_series"` // Also used for seasons and episodes
+}
+
+// defaultsKind returns the kind whose monitoring defaults apply to a media
+// kind, or "" when none do
+func defaultsKind(kind string) string {
+	switch kind {
+	case "movie":
+		return "movie"
+	case "tv_series", "tv_season", "tv_episode":
+		return "tv_series"
+	}
+	return ""
+}
+
+// loadRuleSettings reads rule settings saved under a config key over the
+// built-in ones
+func (s *Service) loadRuleSettings(ctx context.Context, key string) (RuleSettings, error) {
+	settings := builtinRuleSettings()
+	if s.config == nil {
+		return settings, nil
+	}
+	raw, err := s.config.Get(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return settings, fmt.Errorf("invalid monitoring defaults in %s: %w", key, err)
+	}
+	return settings, nil
+}
+
+// MonitoringDefaults returns the current monitoring defaults
+func (s *Service) MonitoringDefaults(ctx context.Context) (*MonitoringDefaults, error) {
+	movie, err := s.loadRuleSettings(ctx, configDefaultsMovie)
+	if err != nil {
+		return nil, err
+	}
+	tvSeries, err := s.loadRuleSettings(ctx, configDefaultsTVSeries)
+	if err != nil {
+		return nil, err
+	}
+	return &MonitoringDefaults{Movie: movie, TVSeries: tvSeries}, nil
+}
+
+// UpdateMonitoringDefaults applies a JSON update to the current monitoring
+// defaults, leaving out fields keeps their values, and saves them when they
+// are valid
+func (s *Service) UpdateMonitoringDefaults(ctx context.Context, update json.RawMessage) (*MonitoringDefaults, error) {
+	if s.config == nil {
+		return nil, errors.New("monitoring defaults can't be saved without a config store")
+	}
+	defaults, err := s.MonitoringDefaults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(update, defaults); err != nil {
+		return nil, &SettingsError{Message: fmt.Sprintf("invalid monitoring defaults: %v", err)}
+	}
+	if err := s.checkSettings(ctx, &defaults.Movie); err != nil {
+		return nil, prefixSettingsError(err, "movie")
+	}
+	if err := s.checkSettings(ctx, &defaults.TVSeries); err != nil {
+		return nil, prefixSettingsError(err, "tv_series")
+	}
+
+	if err := s.config.Set(ctx, configDefaultsMovie, defaults.Movie); err != nil {
+		return nil, err
+	}
+	if err := s.config.Set(ctx, configDefaultsTVSeries, defaults.TVSeries); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// prefixSettingsError names the kind of invalid monitoring defaults
+func prefixSettingsError(err error, kind string) error {
+	var settingsErr *SettingsError
+	if errors.As(err, &settingsErr) {
+		return &SettingsError{Message: kind + ": " + settingsErr.Message}
+	}
+	return err
+}
+
+// DefaultRuleSettings returns the settings of a new rule for a kind of media
+// when no template is picked. Kinds without monitoring defaults get the
+// built-in settings.
+func (s *Service) DefaultRuleSettings(ctx context.Context, kind string) (RuleSettings, error) {
+	switch defaultsKind(kind) {
+	case "movie":
+		return s.loadRuleSettings(ctx, configDefaultsMovie)
+	case "tv_series":
+		return s.loadRuleSettings(ctx, configDefaultsTVSeries)
+	}
+	return builtinRuleSettings(), nil
+}
+
+// NewRuleParams returns the parameters of a new rule for a media item, expanded
+// from a template or, without one, from the monitoring defaults of its kind
+func (s *Service) NewRuleParams(ctx context.Context, mediaItemID int64, templateID *int64) (CreateMonitoringRuleParams, error) {
+	var kind string
+	err := s.db.QueryRow(ctx, `SELECT kind FROM media_items WHERE id = $1`, mediaItemID).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CreateMonitoringRuleParams{}, ErrMediaItemNotFound
+	}
+	if err != nil {
+		return CreateMonitoringRuleParams{}, fmt.Errorf("failed to get media item: %w", err)
+	}
+
+	if templateID == nil {
+		settings, err := s.DefaultRuleSettings(ctx, kind)
+		if err != nil {
+			return CreateMonitoringRuleParams{}, err
+		}
+		return settings.Params(mediaItemID), nil
+	}
+
+	template, err := s.GetTemplate(ctx, *templateID)
+	if err != nil {
+		return CreateMonitoringRuleParams{}, err
+	}
+	if template.Kind != "" && template.Kind != defaultsKind(kind) {
+		return CreateMonitoringRuleParams{}, &SettingsError{
+			Message: fmt.Sprintf("template %q is for %s, not %s", template.Name, template.Kind, kind),
+		}
+	}
+	params := template.Params(mediaItemID)
+	params.TemplateID = &template.ID
+	return params, nil
+}
+
+// ========================
+// Monitoring Templates
+// ========================
+
+// MonitoringTemplate is a named set of rule settings that can be picked when
+// adding media, like "Kids shows" or "4K movies"
+type MonitoringTemplate struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"` // movie or tv_series, empty for any kind
+	RuleSettings
+
+	RuleCount int       `json:"rule_count"` // Rules created from the template
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// validateTemplate normalizes the template and checks its name, kind and settings
+func (s *Service) validateTemplate(ctx context.Context, template *MonitoringTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return &SettingsError{Message: "name is required"}
+	}
+	template.Description = strings.TrimSpace(template.Description)
+	switch template.Kind {
+	case "", "movie", "tv_series":
+	default:
+		return &SettingsError{Message: fmt.Sprintf("invalid kind %q, must be movie, tv_series or empty for any kind", template.Kind)}
+	}
+	return s.checkSettings(ctx, &template.RuleSettings)
+}
+
+// reapplyParams returns the bulk update that gives the rules created from a
+// template its current settings. Tags the template had before are removed.
+// Whether rules are enabled, their root folder and series type are chosen per
+// item and left alone, as is search on add, which only applies when adding.
+func (t *MonitoringTemplate) reapplyParams(previousTags []string) BulkUpdateMonitoringRuleParams {
+	current := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		current[tag] = true
+	}
+	removeTags := []string{}
+	for _, tag := range previousTags {
+		if !current[tag] {
+			removeTags = append(removeTags, tag)
+		}
+	}
+
+	monitorMode := t.MonitorMode
+	interval := t.SearchIntervalMinutes
+	automatic, backlog, upgrades := t.AutomaticSearch, t.BacklogSearch, t.SearchUpgrades
+	seasonPacks, seeders := t.PreferSeasonPacks, t.MinimumSeeders
+	return BulkUpdateMonitoringRuleParams{
+		QualityProfileID:      t.QualityProfileID,
+		MonitorMode:           &monitorMode,
+		SearchIntervalMinutes: &interval,
+		AddTags:               append([]string{}, t.Tags...),
+		RemoveTags:            removeTags,
+		AutomaticSearch:       &automatic,
+		BacklogSearch:         &backlog,
+		SearchUpgrades:        &upgrades,
+		PreferSeasonPacks:     &seasonPacks,
+		MinimumSeeders:        &seeders,
+	}
+}
+
+const templateColumns = `
+	t.id, t.name, t.description, t.kind, t.enabled, t.quality_profile_id, t.root_folder_id,
+	t.monitor_mode, t.series_type, t.search_on_add, t.automatic_search, t.backlog_search,
+	t.search_upgrades, t.prefer_season_packs, t.minimum_seeders, t.tags,
+	t.search_interval_minutes, t.created_at, t.updated_at,
+	(SELECT COUNT(*) FROM monitoring_rules r WHERE r.template_id = t.id)
+`
+
+// scanTemplate scans a row of templateColumns
+func scanTemplate(row pgx.Row) (*MonitoringTemplate, error) {
+	var t MonitoringTemplate
+	err := row.Scan(
+		&t.ID, &t.Name, &t.Description, &t.Kind, &t.Enabled, &t.QualityProfileID, &t.RootFolderID,
+		&t.MonitorMode, &t.SeriesType, &t.SearchOnAdd, &t.AutomaticSearch, &t.BacklogSearch,
+		&t.SearchUpgrades, &t.PreferSeasonPacks, &t.MinimumSeeders, &t.Tags,
+		&t.SearchIntervalMinutes, &t.CreatedAt, &t.UpdatedAt,
+		&t.RuleCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTemplates lists the monitoring templates by name
+func (s *Service) ListTemplates(ctx context.Context) ([]MonitoringTemplate, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+templateColumns+` FROM monitoring_templates t ORDER BY t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list monitoring templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []MonitoringTemplate{}
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan monitoring template: %w", err)
+		}
+		templates = append(templates, *template)
+	}
+	return templates, rows.Err()
+}
+
+// GetTemplate gets a monitoring template by ID
+func (s *Service) GetTemplate(ctx context.Context, id int64) (*MonitoringTemplate, error) {
+	row := s.db.QueryRow(ctx, `SELECT `+templateColumns+` FROM monitoring_templates t WHERE t.id = $1`, id)
+	template, err := scanTemplate(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monitoring template: %w", err)
+	}
+	return template, nil
+}
+
+// CreateTemplate creates a monitoring template from JSON. Settings left out
+// are taken from the monitoring defaults of the template's kind.
+func (s *Service) CreateTemplate(ctx context.Context, body json.RawMessage) (*MonitoringTemplate, error) {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		return nil, &SettingsError{Message: fmt.Sprintf("invalid monitoring template: %v", err)}
+	}
+	settings, err := s.DefaultRuleSettings(ctx, head.Kind)
+	if err != nil {
+		return nil, err
+	}
+	template := &MonitoringTemplate{RuleSettings: settings}
+	if err := json.Unmarshal(body, template); err != nil {
+		return nil, &SettingsError{Message: fmt.Sprintf("invalid monitoring template: %v", err)}
+	}
+	if err := s.validateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+
+	var id int64
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO monitoring_templates (
+			name, description, kind, enabled, quality_profile_id, root_folder_id,
+			monitor_mode, series_type, search_on_add, automatic_search, backlog_search,
+			search_upgrades, prefer_season_packs, minimum_seeders, tags, search_interval_minutes
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id
+	`, template.Name, template.Description, template.Kind, template.Enabled,
+		template.QualityProfileID, template.RootFolderID, template.MonitorMode, template.SeriesType,
+		template.SearchOnAdd, template.AutomaticSearch, template.BacklogSearch, template.SearchUpgrades,
+		template.PreferSeasonPacks, template.MinimumSeeders, template.Tags, template.SearchIntervalMinutes,
+	).Scan(&id)
+	if isUniqueViolation(err) {
+		return nil, ErrTemplateNameTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring template: %w", err)
+	}
+	return s.GetTemplate(ctx, id)
+}
+
+// UpdateTemplate applies a JSON update to a monitoring template, leaving out
+// fields keeps their values. It returns the updated template and the tags it
+// had before.
+func (s *Service) UpdateTemplate(ctx context.Context, id int64, update json.RawMessage) (*MonitoringTemplate, []string, error) {
+	updated, err := s.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	previousTags := append([]string{}, updated.Tags...)
+	if err := json.Unmarshal(update, updated); err != nil {
+		return nil, nil, &SettingsError{Message: fmt.Sprintf("invalid monitoring template: %v", err)}
+	}
+	updated.ID = id
+	if err := s.validateTemplate(ctx, updated); err != nil {
+		return nil, nil, err
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		UPDATE monitoring_templates
+		SET name = $2, description = $3, kind = $4, enabled = $5, quality_profile_id = $6,
+		    root_folder_id = $7, monitor_mode = $8, series_type = $9, search_on_add = $10,
+		    automatic_search = $11, backlog_search = $12, search_upgrades = $13,
+		    prefer_season_packs = $14, minimum_seeders = $15, tags = $16,
+		    search_interval_minutes = $17
+		WHERE id = $1
+	`, id, updated.Name, updated.Description, updated.Kind, updated.Enabled,
+		updated.QualityProfileID, updated.RootFolderID, updated.MonitorMode, updated.SeriesType,
+		updated.SearchOnAdd, updated.AutomaticSearch, updated.BacklogSearch, updated.SearchUpgrades,
+		updated.PreferSeasonPacks, updated.MinimumSeeders, updated.Tags, updated.SearchIntervalMinutes,
+	)
+	if isUniqueViolation(err) {
+		return nil, nil, ErrTemplateNameTaken
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update monitoring template: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil, ErrTemplateNotFound
+	}
+
+	template, err := s.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, previousTags, nil
+}
+
+// DeleteTemplate deletes a monitoring template. Rules created from it keep
+// their settings.
+func (s *Service) DeleteTemplate(ctx context.Context, id int64) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM monitoring_templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete monitoring template: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// ReapplyTemplate gives the rules created from a template its current settings
+// with a bulk update, removing the tags it had before and no longer has
+func (s *Service) ReapplyTemplate(ctx context.Context, template *MonitoringTemplate, previousTags []string) ([]BulkRuleResult, BulkUpdateMonitoringRuleParams, error) {
+	params := template.reapplyParams(previousTags)
+	results, err := s.BulkUpdateMonitoringRules(ctx, RuleSelection{TemplateID: &template.ID}, params)
+	return results, params, err
+}
+
+// isUniqueViolation reports whether err is a duplicate key
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
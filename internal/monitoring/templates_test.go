@@ -0,0 +1,94 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRuleSettingsValidate(t *testing.T) {
+	settings := RuleSettings{SearchIntervalMinutes: 30, Tags: []string{"Kids", "kids", " 4K "}}
+	if err := settings.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if settings.MonitorMode != MonitorModeAll || settings.SeriesType != SeriesTypeStandard {
+		t.Errorf("defaults = %s %s, want all standard", settings.MonitorMode, settings.SeriesType)
+	}
+	if !reflect.DeepEqual(settings.Tags, []string{"kids", "4k"}) {
+		t.Errorf("tags = %v", settings.Tags)
+	}
+
+	invalid := []RuleSettings{
+		{MonitorMode: "sometimes", SearchIntervalMinutes: 60},
+		{SeriesType: "daily", SearchIntervalMinutes: 60},
+		{MinimumSeeders: -1, SearchIntervalMinutes: 60},
+		{SearchIntervalMinutes: 0},
+	}
+	for _, settings := range invalid {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("%+v is valid", settings)
+		}
+	}
+}
+
+func TestRuleSettingsParams(t *testing.T) {
+	profile := 4
+	settings := builtinRuleSettings()
+	settings.QualityProfileID = &profile
+	settings.Tags = []string{"kids"}
+
+	params := settings.Params(12)
+	if params.MediaItemID != 12 || params.QualityProfileID != &profile || !params.Enabled ||
+		params.SearchIntervalMinutes != 60 || params.MinimumSeeders != 1 || params.TemplateID != nil {
+		t.Errorf("params = %+v", params)
+	}
+
+	// Fields in a request override the expanded settings, the others are kept
+	if err := json.Unmarshal([]byte(`{"media_item_id":12,"search_on_add":false,"tags":["4k"]}`), &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.SearchOnAdd || !params.AutomaticSearch || params.MonitorMode != MonitorModeAll {
+		t.Errorf("overridden params = %+v", params)
+	}
+	if !reflect.DeepEqual(settings.Tags, []string{"kids"}) {
+		t.Errorf("overriding changed the settings' tags to %v", settings.Tags)
+	}
+}
+
+func TestDefaultsKind(t *testing.T) {
+	tests := map[string]string{
+		"movie":        "movie",
+		"tv_series":    "tv_series",
+		"tv_season":    "tv_series",
+		"tv_episode":   "tv_series",
+		"music_artist": "",
+	}
+	for kind, want := range tests {
+		if got := defaultsKind(kind); got != want {
+			t.Errorf("defaultsKind(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestTemplateReapplyParams(t *testing.T) {
+	template := &MonitoringTemplate{ID: 3, Name: "Kids shows", RuleSettings: builtinRuleSettings()}
+	template.MonitorMode = MonitorModeFuture
+	template.MinimumSeeders = 5
+	template.Tags = []string{"kids", "family"}
+
+	params := template.reapplyParams([]string{"kids", "cartoons"})
+	if *params.MonitorMode != MonitorModeFuture || *params.MinimumSeeders != 5 || *params.SearchIntervalMinutes != 60 {
+		t.Errorf("params = %+v", params)
+	}
+	if !reflect.DeepEqual(params.AddTags, []string{"kids", "family"}) || !reflect.DeepEqual(params.RemoveTags, []string{"cartoons"}) {
+		t.Errorf("tags added %v removed %v, want kids family added and cartoons removed", params.AddTags, params.RemoveTags)
+	}
+	// Whether rules are enabled is chosen per item, and a template without a
+	// quality profile doesn't clear the ones of its rules
+	if params.Enabled != nil || params.QualityProfileID != nil {
+		t.Errorf("params change enabled %v and quality profile %v", params.Enabled, params.QualityProfileID)
+	}
+	if params.IsEmpty() {
+		t.Error("re-applying a template changes nothing")
+	}
+}
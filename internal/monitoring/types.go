@@ -18,6 +18,29 @@ const (
 	MonitorModeNone         MonitorMode = "none"          // Don't monitor
 )
 
+// IsValid reports whether the monitor mode exists
+func (m MonitorMode) IsValid() bool {
+	switch m {
+	case MonitorModeAll, MonitorModeFuture, MonitorModeMissing, MonitorModeExisting,
+		MonitorModeFirstSeason, MonitorModeLatestSeason, MonitorModePilot, MonitorModeNone:
+		return true
+	}
+	return false
+}
+
+// SeriesType defines how the episodes of a series are numbered in releases
+type SeriesType string
+
+const (
+	SeriesTypeStandard SeriesType = "standard" // Releases are named by season and episode, like S01E05
+	SeriesTypeAnime    SeriesType = "anime"    // Releases are named by absolute episode number, like "Show - 125"
+)
+
+// IsValid reports whether the series type exists
+func (t SeriesType) IsValid() bool {
+	return t == SeriesTypeStandard || t == SeriesTypeAnime
+}
+
 // SearchType defines the type of search
 type SearchType string
 
@@ -32,10 +55,12 @@ const (
 type TriggerSource string
 
 const (
-	TriggerSourceUser      TriggerSource = "user"          // User action
-	TriggerSourceScheduler TriggerSource = "scheduler"     // Scheduler job
-	TriggerSourceRSSSync   TriggerSource = "rss_sync"      // RSS sync
-	TriggerSourceMissing   TriggerSource = "missing_check" // Missing items check
+	TriggerSourceUser      TriggerSource = "user"            // User action
+	TriggerSourceScheduler TriggerSource = "scheduler"       // Scheduler job
+	TriggerSourceRSSSync   TriggerSource = "rss_sync"        // RSS sync
+	TriggerSourceMissing   TriggerSource = "missing_check"   // Missing items check
+	TriggerSourceFailed    TriggerSource = "download_failed" // Replacement for a failed download
+	TriggerSourceManual    TriggerSource = "manual"          // Media search or release grabbed from search results
 )
 
 // SearchStatus defines the status of a search
@@ -54,7 +79,7 @@ const (
 	BlockReasonQuality        BlockReason = "quality"         // Quality doesn't meet profile
 	BlockReasonFake           BlockReason = "fake"            // Suspected fake release
 	BlockReasonCorrupted      BlockReason = "corrupted"       // Corrupted file
-	BlockReasonFailedDownload BlockReason = "failed_download" // Download failed
+	BlockReasonFailedDownload BlockReason = "download_failed" // Download failed
 	BlockReasonManual         BlockReason = "manual"          // Manually blocked
 	BlockReasonDuplicate      BlockReason = "duplicate"       // Duplicate release
 	BlockReasonSize           BlockReason = "size"            // File size issues
@@ -65,7 +90,7 @@ const (
 type EventType string
 
 const (
-	EventTypeAirDate         EventType = "air_date"         // Episode air date
+	EventTypeEpisodeAir      EventType = "episode_air"      // Episode air date
 	EventTypeDigitalRelease  EventType = "digital_release"  // Digital release date
 	EventTypePhysicalRelease EventType = "physical_release" // Physical media release date
 )
@@ -94,11 +119,14 @@ type MonitoringRule struct {
 	Enabled        bool        `json:"enabled"`
 	QualityProfile *int        `json:"quality_profile_id"`
 	MonitorMode    MonitorMode `json:"monitor_mode"`
+	SeriesType     SeriesType  `json:"series_type"`
+	RootFolderID   *int64      `json:"root_folder_id"` // Imports go here instead of the default root folder
 
 	// Search settings
 	SearchOnAdd     bool `json:"search_on_add"`
 	AutomaticSearch bool `json:"automatic_search"`
 	BacklogSearch   bool `json:"backlog_search"`
+	SearchUpgrades  bool `json:"search_upgrades"` // Keep searching once the file meets the cutoff
 
 	// Release preferences
 	PreferSeasonPacks bool     `json:"prefer_season_packs"`
@@ -115,9 +143,13 @@ type MonitoringRule struct {
 	ItemsFoundCount   int `json:"items_found_count"`
 	ItemsGrabbedCount int `json:"items_grabbed_count"`
 
+	// Episodes counts the episodes of series and season rules, when requested
+	Episodes *RuleCoverage `json:"episodes,omitempty"`
+
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	CreatedByUser *int64    `json:"created_by_user_id"`
+	TemplateID    *int64    `json:"template_id"` // Template the rule was created from
 }
 
 // EpisodeMonitoring tracks monitoring for individual episodes
@@ -126,6 +158,7 @@ type EpisodeMonitoring struct {
 	MediaItemID  int64      `json:"media_item_id"`
 	Monitored    bool       `json:"monitored"`
 	HasFile      bool       `json:"has_file"`
+	CutoffMet    bool       `json:"cutoff_met"` // The file meets the quality profile's cutoff
 	FileID       *int64     `json:"file_id"`
 	AirDate      *time.Time `json:"air_date"`
 	AirDateUTC   *time.Time `json:"air_date_utc"`
@@ -135,6 +168,12 @@ type EpisodeMonitoring struct {
 	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
+// MissingEpisode is a wanted episode together with the season it belongs to
+type MissingEpisode struct {
+	MediaItemID int64
+	SeasonID    int64
+}
+
 // SearchHistory tracks search executions
 type SearchHistory struct {
 	ID               int64          `json:"id"`
@@ -180,23 +219,50 @@ type BlocklistEntry struct {
 	CreatedByUser   *int64      `json:"created_by_user_id"`
 }
 
+// BlocklistPage is a page of blocklist entries
+type BlocklistPage struct {
+	Items   []BlocklistEntry `json:"items"`
+	Total   int64            `json:"total"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+	HasMore bool             `json:"has_more"`
+}
+
 // RSSSyncState tracks RSS feed synchronization state
 type RSSSyncState struct {
 	ID                  int64      `json:"id"`
 	IndexerID           string     `json:"indexer_id"`
+	PluginID            string     `json:"plugin_id"`
+	IndexerName         string     `json:"indexer_name"`
 	LastSyncAt          *time.Time `json:"last_sync_at"`
 	NextSyncAt          *time.Time `json:"next_sync_at"`
 	SyncIntervalMinutes int        `json:"sync_interval_minutes"`
 	TotalSyncs          int        `json:"total_syncs"`
 	TotalItemsFound     int        `json:"total_items_found"`
 	TotalItemsGrabbed   int        `json:"total_items_grabbed"`
+	LastItemsFound      int        `json:"last_items_found"`
+	LastItemsGrabbed    int        `json:"last_items_grabbed"`
 	ConsecutiveFailures int        `json:"consecutive_failures"`
 	LastError           *string    `json:"last_error"`
+	LastReleaseAt       *time.Time `json:"last_release_at"`
+	SeenGUIDs           []string   `json:"-"`
 	Enabled             bool       `json:"enabled"`
 	CreatedAt           time.Time  `json:"created_at"`
 	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
+// RecordRSSSyncParams is the outcome of a successful sync of one indexer's feed
+type RecordRSSSyncParams struct {
+	IndexerID     string
+	PluginID      string
+	IndexerName   string
+	NextSyncAt    *time.Time
+	ItemsFound    int
+	ItemsGrabbed  int
+	LastReleaseAt *time.Time
+	SeenGUIDs     []string
+}
+
 // CalendarEvent represents an upcoming or recent release
 type CalendarEvent struct {
 	ID               int64                  `json:"id"`
@@ -233,6 +299,8 @@ type SchedulerJob struct {
 	Config              map[string]interface{} `json:"config"`
 	CreatedAt           time.Time              `json:"created_at"`
 	UpdatedAt           time.Time              `json:"updated_at"`
+
+	log *jobLog // Lines logged during a run, see Logf
 }
 
 // SchedulerJobHistory tracks job execution history
@@ -256,14 +324,18 @@ type CreateMonitoringRuleParams struct {
 	Enabled               bool        `json:"enabled"`
 	QualityProfileID      *int        `json:"quality_profile_id"`
 	MonitorMode           MonitorMode `json:"monitor_mode"`
+	SeriesType            SeriesType  `json:"series_type"` // Defaults to standard
 	SearchOnAdd           bool        `json:"search_on_add"`
 	AutomaticSearch       bool        `json:"automatic_search"`
 	BacklogSearch         bool        `json:"backlog_search"`
+	SearchUpgrades        bool        `json:"search_upgrades"`
 	PreferSeasonPacks     bool        `json:"prefer_season_packs"`
 	MinimumSeeders        int         `json:"minimum_seeders"`
 	Tags                  []string    `json:"tags"`
 	SearchIntervalMinutes int         `json:"search_interval_minutes"`
 	CreatedByUserID       *int64      `json:"created_by_user_id"`
+	RootFolderID          *int64      `json:"root_folder_id"`
+	TemplateID            *int64      `json:"template_id"` // Template the parameters were expanded from
 }
 
 // UpdateMonitoringRuleParams defines parameters for updating a monitoring rule
@@ -271,13 +343,76 @@ type UpdateMonitoringRuleParams struct {
 	Enabled               *bool        `json:"enabled"`
 	QualityProfileID      *int         `json:"quality_profile_id"`
 	MonitorMode           *MonitorMode `json:"monitor_mode"`
+	SeriesType            *SeriesType  `json:"series_type"`
 	SearchOnAdd           *bool        `json:"search_on_add"`
 	AutomaticSearch       *bool        `json:"automatic_search"`
 	BacklogSearch         *bool        `json:"backlog_search"`
+	SearchUpgrades        *bool        `json:"search_upgrades"`
 	PreferSeasonPacks     *bool        `json:"prefer_season_packs"`
 	MinimumSeeders        *int         `json:"minimum_seeders"`
 	Tags                  []string     `json:"tags"`
 	SearchIntervalMinutes *int         `json:"search_interval_minutes"`
+	RootFolderID          *int64       `json:"root_folder_id"`
+}
+
+// RuleSelection selects monitoring rules for a bulk operation: the rules with
+// the given IDs, or all rules matching the media kind and tag. When both IDs
+// and a filter are given, only the listed rules matching the filter are selected.
+type RuleSelection struct {
+	RuleIDs    []int64 `json:"rule_ids"`
+	Kind       string  `json:"kind"` // Media kind, e.g. tv_series
+	Tag        string  `json:"tag"`
+	TemplateID *int64  `json:"template_id"` // Rules created from the template
+}
+
+// IsEmpty reports whether the selection has neither IDs nor a filter
+func (s RuleSelection) IsEmpty() bool {
+	return len(s.RuleIDs) == 0 && s.Kind == "" && s.Tag == "" && s.TemplateID == nil
+}
+
+// BulkUpdateMonitoringRuleParams defines the changes a bulk update makes to
+// every selected rule. Fields that are not set are left untouched.
+type BulkUpdateMonitoringRuleParams struct {
+	Enabled               *bool        `json:"enabled"`
+	QualityProfileID      *int         `json:"quality_profile_id"`
+	MonitorMode           *MonitorMode `json:"monitor_mode"`
+	SearchIntervalMinutes *int         `json:"search_interval_minutes"`
+	AddTags               []string     `json:"add_tags"`
+	RemoveTags            []string     `json:"remove_tags"`
+
+	// Search settings
+	AutomaticSearch   *bool `json:"automatic_search"`
+	BacklogSearch     *bool `json:"backlog_search"`
+	SearchUpgrades    *bool `json:"search_upgrades"`
+	PreferSeasonPacks *bool `json:"prefer_season_packs"`
+	MinimumSeeders    *int  `json:"minimum_seeders"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (p BulkUpdateMonitoringRuleParams) IsEmpty() bool {
+	return p.Enabled == nil && p.QualityProfileID == nil && p.MonitorMode == nil &&
+		p.SearchIntervalMinutes == nil && len(p.AddTags) == 0 && len(p.RemoveTags) == 0 &&
+		p.AutomaticSearch == nil && p.BacklogSearch == nil && p.SearchUpgrades == nil &&
+		p.PreferSeasonPacks == nil && p.MinimumSeeders == nil
+}
+
+// BulkRuleStatus is the outcome of a bulk operation for one rule
+type BulkRuleStatus string
+
+const (
+	BulkRuleUpdated  BulkRuleStatus = "updated"   // The rule was changed
+	BulkRuleDeleted  BulkRuleStatus = "deleted"   // The rule was deleted
+	BulkRuleQueued   BulkRuleStatus = "queued"    // A search for the rule was started
+	BulkRuleNotFound BulkRuleStatus = "not_found" // A listed rule doesn't exist or doesn't match the filter
+)
+
+// BulkRuleResult is the outcome of a bulk operation for one rule
+type BulkRuleResult struct {
+	RuleID      int64           `json:"rule_id"`
+	MediaItemID *int64          `json:"media_item_id,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Status      BulkRuleStatus  `json:"status"`
+	Rule        *MonitoringRule `json:"rule,omitempty"` // The rule after an update
 }
 
 // CreateBlocklistEntryParams defines parameters for creating a blocklist entry
@@ -295,6 +430,28 @@ type CreateBlocklistEntryParams struct {
 	CreatedByUserID *int64      `json:"created_by_user_id"`
 }
 
+// UpsertCalendarEventParams defines parameters for creating or updating a calendar event
+type UpsertCalendarEventParams struct {
+	MediaItemID      int64                  `json:"media_item_id"`
+	EventType        EventType              `json:"event_type"`
+	EventKey         string                 `json:"event_key"`
+	EventDate        time.Time              `json:"event_date"`
+	EventDateTimeUTC *time.Time             `json:"event_datetime_utc"`
+	Monitored        bool                   `json:"monitored"`
+	Title            string                 `json:"title"`
+	ParentTitle      *string                `json:"parent_title"`
+	Metadata         map[string]interface{} `json:"metadata"`
+}
+
+// CalendarMediaItem is a monitoring candidate the calendar sync produces events for
+type CalendarMediaItem struct {
+	ID          int64
+	Title       string
+	Monitored   bool
+	Metadata    map[string]interface{}
+	ExternalIDs map[string]interface{}
+}
+
 // MonitoringStats represents monitoring statistics
 type MonitoringStats struct {
 	TotalMonitored      int `json:"total_monitored"`
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"time"
+)
+
+// EventType identifies what happened, and is what notifiers subscribe to
+type EventType string
+
+const (
+	EventDownloadAdded     EventType = "download_added"
+	EventDownloadCompleted EventType = "download_completed"
+	EventDownloadFailed    EventType = "download_failed"
+	EventImportCompleted   EventType = "import_completed"
+	EventImportFailed      EventType = "import_failed"
+	EventUpgradeGrabbed    EventType = "upgrade_grabbed"
+	EventHealthIssue       EventType = "health_issue"
+
+	// Request events. New requests go to server-wide notifiers, the others
+	// also to the personal notifiers of the requester.
+	EventRequestCreated   EventType = "request_created"
+	EventRequestApproved  EventType = "request_approved"
+	EventRequestDenied    EventType = "request_denied"
+	EventRequestAvailable EventType = "request_available"
+
+	// EventTest is only sent by the test endpoint, to the notifier being tested
+	EventTest EventType = "test"
+)
+
+// EventTypes lists the event types notifiers can subscribe to
+var EventTypes = []EventType{
+	EventDownloadAdded,
+	EventDownloadCompleted,
+	EventDownloadFailed,
+	EventImportCompleted,
+	EventImportFailed,
+	EventUpgradeGrabbed,
+	EventHealthIssue,
+	EventRequestCreated,
+	EventRequestApproved,
+	EventRequestDenied,
+	EventRequestAvailable,
+}
+
+// IsValidEventType reports whether notifiers can subscribe to an event type
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if string(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a notification about something that happened
+type Event struct {
+	Type      EventType              `json:"event"`
+	Title     string                 `json:"title"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// NewEvent creates an event timestamped now
+func NewEvent(eventType EventType, title, message string, data map[string]interface{}) Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return Event{
+		Type:      eventType,
+		Title:     title,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// sampleEvent is sent when testing a notifier
+func sampleEvent() Event {
+	return NewEvent(EventTest, "Test notification", "This is a test notification from Nimbus.", map[string]interface{}{
+		"download_id": "test",
+		"name":        "Example.Movie.2024.1080p.WEB-DL.x264-GROUP",
+	})
+}
@@ -0,0 +1,404 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// defaultDeliveryLimit is how many deliveries are listed when no limit is given
+const defaultDeliveryLimit = 50
+
+// Handler handles notification HTTP requests
+type Handler struct {
+	service *Service
+	queries *generated.Queries
+	tags    *tags.Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new notification handler
+func NewHandler(service *Service, queries *generated.Queries, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		queries: queries,
+		logger:  logger.With(zap.String("component", "notifications-handler")),
+	}
+}
+
+// SetTags makes tags set on notifiers appear in the tag list
+func (h *Handler) SetTags(service *tags.Service) {
+	h.tags = service
+}
+
+// SetupRoutes registers the notification routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Route("/notifications", func(r chi.Router) {
+		r.Get("/", h.ListNotifiers)
+		r.Post("/", h.CreateNotifier)
+		r.Get("/events", h.ListEventTypes)
+		r.Get("/{id}", h.GetNotifier)
+		r.Put("/{id}", h.UpdateNotifier)
+		r.Delete("/{id}", h.DeleteNotifier)
+		r.Post("/{id}/test", h.TestNotifier)
+		r.Get("/{id}/deliveries", h.ListDeliveries)
+	})
+}
+
+// notifierRequest is the body of a create or update request
+type notifierRequest struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Enabled  *bool           `json:"enabled,omitempty"` // Defaults to true
+	Events   []string        `json:"events"`
+	Settings json.RawMessage `json:"settings"`
+	UserID   *int64          `json:"user_id,omitempty"` // Makes it a personal notifier of the user
+	Tags     []string        `json:"tags,omitempty"`    // Limits media events to media with a shared tag
+}
+
+// notifierResponse is a notifier as returned by the API
+type notifierResponse struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Enabled   bool            `json:"enabled"`
+	Events    []string        `json:"events"`
+	Settings  json.RawMessage `json:"settings"`
+	UserID    *int64          `json:"user_id"`
+	Tags      []string        `json:"tags"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func toNotifierResponse(n generated.Notifier) notifierResponse {
+	events := n.Events
+	if events == nil {
+		events = []string{}
+	}
+	notifierTags := n.Tags
+	if notifierTags == nil {
+		notifierTags = []string{}
+	}
+	settings := json.RawMessage(n.Settings)
+	if len(settings) == 0 {
+		settings = json.RawMessage("{}")
+	}
+	return notifierResponse{
+		ID:        n.ID,
+		Name:      n.Name,
+		Type:      n.Type,
+		Enabled:   n.Enabled,
+		Events:    events,
+		Settings:  settings,
+		UserID:    n.UserID,
+		Tags:      notifierTags,
+		CreatedAt: n.CreatedAt.Time,
+		UpdatedAt: n.UpdatedAt.Time,
+	}
+}
+
+// deliveryResponse is a delivery log entry as returned by the API
+type deliveryResponse struct {
+	ID           int64           `json:"id"`
+	NotifierID   int64           `json:"notifier_id"`
+	EventType    string          `json:"event_type"`
+	Status       string          `json:"status"`
+	Attempts     int32           `json:"attempts"`
+	ErrorMessage *string         `json:"error_message,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func toDeliveryResponse(d generated.NotificationDelivery) deliveryResponse {
+	return deliveryResponse{
+		ID:           d.ID,
+		NotifierID:   d.NotifierID,
+		EventType:    d.EventType,
+		Status:       d.Status,
+		Attempts:     d.Attempts,
+		ErrorMessage: d.ErrorMessage,
+		Payload:      json.RawMessage(d.Payload),
+		CreatedAt:    d.CreatedAt.Time,
+	}
+}
+
+// validate normalizes a request and returns a message when it is invalid
+func (req *notifierRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	if req.Name == "" {
+		return "name is required"
+	}
+	if len(req.Settings) == 0 {
+		req.Settings = json.RawMessage("{}")
+	}
+	if _, err := NewNotifier(req.Type, req.Settings, nil); err != nil {
+		return err.Error()
+	}
+
+	events := make([]string, 0, len(req.Events))
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		if !IsValidEventType(event) {
+			return "unknown event type: " + event
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	req.Events = events
+
+	notifierTags, err := tags.NormalizeAll(req.Tags)
+	if err != nil {
+		return err.Error()
+	}
+	req.Tags = notifierTags
+	return ""
+}
+
+// validUser checks that the user of a personal notifier exists, responding
+// with an error when it doesn't
+func (h *Handler) validUser(w http.ResponseWriter, r *http.Request, id *int64) bool {
+	if id == nil {
+		return true
+	}
+	_, err := h.queries.GetUserByID(r.Context(), *id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "User not found")
+		return false
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to look up user")
+		return false
+	}
+	return true
+}
+
+func (req *notifierRequest) enabled() bool {
+	return req.Enabled == nil || *req.Enabled
+}
+
+func parseNotifierID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// ListNotifiers returns all notifiers
+// GET /api/notifications
+func (h *Handler) ListNotifiers(w http.ResponseWriter, r *http.Request) {
+	notifiers, err := h.queries.ListNotifiers(r.Context())
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list notifiers")
+		return
+	}
+
+	response := make([]notifierResponse, len(notifiers))
+	for i, n := range notifiers {
+		response[i] = toNotifierResponse(n)
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"notifiers": response})
+}
+
+// ListEventTypes returns the events notifiers can subscribe to
+// GET /api/notifications/events
+func (h *Handler) ListEventTypes(w http.ResponseWriter, r *http.Request) {
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"events": EventTypes})
+}
+
+// GetNotifier returns a notifier
+// GET /api/notifications/{id}
+func (h *Handler) GetNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseNotifierID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	n, err := h.queries.GetNotifier(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to get notifier")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, toNotifierResponse(n))
+}
+
+// CreateNotifier adds a notifier
+// POST /api/notifications
+func (h *Handler) CreateNotifier(w http.ResponseWriter, r *http.Request) {
+	var req notifierRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !h.validUser(w, r, req.UserID) {
+		return
+	}
+	if err := h.tags.Ensure(r.Context(), req.Tags); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create tags")
+		return
+	}
+
+	n, err := h.queries.CreateNotifier(r.Context(), generated.CreateNotifierParams{
+		Name:     req.Name,
+		Type:     req.Type,
+		Enabled:  req.enabled(),
+		Events:   req.Events,
+		Settings: req.Settings,
+		UserID:   req.UserID,
+		Tags:     req.Tags,
+	})
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create notifier")
+		return
+	}
+
+	h.logger.Info("notifier created", zap.Int64("id", n.ID), zap.String("name", n.Name), zap.String("type", n.Type))
+	httputil.RespondJSON(w, http.StatusCreated, toNotifierResponse(n))
+}
+
+// UpdateNotifier replaces a notifier
+// PUT /api/notifications/{id}
+func (h *Handler) UpdateNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseNotifierID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	var req notifierRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !h.validUser(w, r, req.UserID) {
+		return
+	}
+	if err := h.tags.Ensure(r.Context(), req.Tags); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create tags")
+		return
+	}
+
+	n, err := h.queries.UpdateNotifier(r.Context(), generated.UpdateNotifierParams{
+		ID:       id,
+		Name:     req.Name,
+		Type:     req.Type,
+		Enabled:  req.enabled(),
+		Events:   req.Events,
+		Settings: req.Settings,
+		UserID:   req.UserID,
+		Tags:     req.Tags,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to update notifier")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, toNotifierResponse(n))
+}
+
+// DeleteNotifier removes a notifier and its delivery log
+// DELETE /api/notifications/{id}
+func (h *Handler) DeleteNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseNotifierID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	deleted, err := h.queries.DeleteNotifier(r.Context(), id)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to delete notifier")
+		return
+	}
+	if deleted == 0 {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestNotifier sends a sample notification and reports whether it was delivered
+// POST /api/notifications/{id}/test
+func (h *Handler) TestNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseNotifierID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	delivery, err := h.service.Test(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+
+	response := map[string]interface{}{"success": err == nil}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	if delivery != nil {
+		response["delivery"] = toDeliveryResponse(*delivery)
+	}
+	httputil.RespondJSON(w, http.StatusOK, response)
+}
+
+// ListDeliveries returns the most recent deliveries of a notifier
+// GET /api/notifications/{id}/deliveries?limit=50
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseNotifierID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 500 {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.queries.ListNotificationDeliveries(r.Context(), generated.ListNotificationDeliveriesParams{
+		NotifierID: id,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list deliveries")
+		return
+	}
+
+	response := make([]deliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		response[i] = toDeliveryResponse(d)
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"deliveries": response})
+}
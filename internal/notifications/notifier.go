@@ -0,0 +1,305 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notifier types
+const (
+	TypeWebhook  = "webhook"
+	TypeDiscord  = "discord"
+	TypeTelegram = "telegram"
+)
+
+// defaultTelegramAPIURL is where Telegram bot API requests are sent
+const defaultTelegramAPIURL = "https://api.telegram.org"
+
+// Notifier delivers events to an external service
+type Notifier interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// NewNotifier creates the notifier for a type from its JSON settings,
+// validating the settings
+func NewNotifier(notifierType string, settings json.RawMessage, client *http.Client) (Notifier, error) {
+	if len(settings) == 0 {
+		settings = json.RawMessage("{}")
+	}
+
+	switch notifierType {
+	case TypeWebhook:
+		var s WebhookSettings
+		if err := json.Unmarshal(settings, &s); err != nil {
+			return nil, fmt.Errorf("invalid webhook settings: %w", err)
+		}
+		return newWebhookNotifier(s, client)
+	case TypeDiscord:
+		var s DiscordSettings
+		if err := json.Unmarshal(settings, &s); err != nil {
+			return nil, fmt.Errorf("invalid discord settings: %w", err)
+		}
+		if err := validateURL(s.WebhookURL); err != nil {
+			return nil, fmt.Errorf("webhook_url: %w", err)
+		}
+		return &discordNotifier{settings: s, client: client}, nil
+	case TypeTelegram:
+		var s TelegramSettings
+		if err := json.Unmarshal(settings, &s); err != nil {
+			return nil, fmt.Errorf("invalid telegram settings: %w", err)
+		}
+		if s.BotToken == "" {
+			return nil, fmt.Errorf("bot_token is required")
+		}
+		if s.ChatID == "" {
+			return nil, fmt.Errorf("chat_id is required")
+		}
+		return &telegramNotifier{settings: s, client: client, apiURL: defaultTelegramAPIURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type: %s", notifierType)
+	}
+}
+
+// validateURL checks that a notification target is an absolute http(s) URL
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an http or https URL")
+	}
+	return nil
+}
+
+// post sends a request and turns a non-2xx response into an error
+func post(ctx context.Context, client *http.Client, method, target string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Nimbus")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ========================
+// Webhook
+// ========================
+
+// WebhookSettings configure a generic JSON webhook
+type WebhookSettings struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`  // POST when empty
+	Headers map[string]string `json:"headers,omitempty"` // Extra headers, e.g. for authorization
+	// Template is a Go text/template rendered with the event to produce the
+	// body. The event itself is sent as JSON when it is empty.
+	Template string `json:"template,omitempty"`
+}
+
+type webhookNotifier struct {
+	settings WebhookSettings
+	tmpl     *template.Template
+	client   *http.Client
+}
+
+func newWebhookNotifier(s WebhookSettings, client *http.Client) (*webhookNotifier, error) {
+	if err := validateURL(s.URL); err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	s.Method = strings.ToUpper(strings.TrimSpace(s.Method))
+	if s.Method == "" {
+		s.Method = http.MethodPost
+	}
+	if s.Method != http.MethodPost && s.Method != http.MethodPut {
+		return nil, fmt.Errorf("method must be POST or PUT")
+	}
+
+	n := &webhookNotifier{settings: s, client: client}
+	if s.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": templateJSON}).Parse(s.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		n.tmpl = tmpl
+	}
+	return n, nil
+}
+
+// templateJSON renders a value as JSON, so templates can embed strings safely
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// render produces the request body for an event
+func (n *webhookNotifier) render(event Event) ([]byte, error) {
+	if n.tmpl == nil {
+		return json.Marshal(event)
+	}
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, event); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *webhookNotifier) Send(ctx context.Context, event Event) error {
+	body, err := n.render(event)
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.client, n.settings.Method, n.settings.URL, n.settings.Headers, body)
+}
+
+// ========================
+// Discord
+// ========================
+
+// DiscordSettings configure a Discord channel webhook
+type DiscordSettings struct {
+	WebhookURL string `json:"webhook_url"`
+	Username   string `json:"username,omitempty"` // Overrides the webhook's name
+}
+
+type discordNotifier struct {
+	settings DiscordSettings
+	client   *http.Client
+}
+
+// discordColors tint the embed by how good the news is
+var discordColors = map[EventType]int{
+	EventDownloadAdded:     0x3498db,
+	EventDownloadCompleted: 0x2ecc71,
+	EventImportCompleted:   0x2ecc71,
+	EventUpgradeGrabbed:    0x9b59b6,
+	EventDownloadFailed:    0xe74c3c,
+	EventImportFailed:      0xe74c3c,
+	EventHealthIssue:       0xf39c12,
+	EventRequestCreated:    0x3498db,
+	EventRequestApproved:   0x2ecc71,
+	EventRequestDenied:     0xe74c3c,
+	EventRequestAvailable:  0x2ecc71,
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Timestamp   string              `json:"timestamp"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+func (n *discordNotifier) Send(ctx context.Context, event Event) error {
+	embed := discordEmbed{
+		Title:       event.Title,
+		Description: event.Message,
+		Color:       discordColors[event.Type],
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+	}
+	for _, key := range sortedKeys(event.Data) {
+		// Discord allows at most 25 fields per embed
+		if len(embed.Fields) == 25 {
+			break
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:   key,
+			Value:  fmt.Sprint(event.Data[key]),
+			Inline: true,
+		})
+	}
+
+	payload := map[string]interface{}{"embeds": []discordEmbed{embed}}
+	if n.settings.Username != "" {
+		payload["username"] = n.settings.Username
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.client, http.MethodPost, n.settings.WebhookURL, nil, body)
+}
+
+// ========================
+// Telegram
+// ========================
+
+// TelegramSettings configure a Telegram bot posting to a chat
+type TelegramSettings struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+type telegramNotifier struct {
+	settings TelegramSettings
+	client   *http.Client
+	apiURL   string
+}
+
+func (n *telegramNotifier) Send(ctx context.Context, event Event) error {
+	text := event.Title
+	if event.Message != "" {
+		text += "\n" + event.Message
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id": n.settings.ChatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s/bot%s/sendMessage", n.apiURL, n.settings.BotToken)
+	if err := post(ctx, n.client, http.MethodPost, target, nil, body); err != nil {
+		// The URL holds the bot token, so keep it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
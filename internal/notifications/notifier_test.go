@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookTemplate(t *testing.T) {
+	var gotBody, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	settings, _ := json.Marshal(WebhookSettings{
+		URL:      server.URL,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+		Template: `{"text": {{json .Title}}, "name": {{json (index .Data "name")}}}`,
+	})
+	notifier, err := NewNotifier(TypeWebhook, settings, server.Client())
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	event := NewEvent(EventDownloadCompleted, `Download "completed"`, "", map[string]interface{}{"name": "Show.S01E01"})
+	if err := notifier.Send(context.Background(), event); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	want := `{"text": "Download \"completed\"", "name": "Show.S01E01"}`
+	if gotBody != want {
+		t.Errorf("body = %s, want %s", gotBody, want)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer secret")
+	}
+}
+
+func TestNotifierErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	settings, _ := json.Marshal(DiscordSettings{WebhookURL: server.URL})
+	notifier, err := NewNotifier(TypeDiscord, settings, server.Client())
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+	if err := notifier.Send(context.Background(), sampleEvent()); err == nil {
+		t.Error("Send() succeeded on HTTP 403")
+	}
+
+	invalid := []struct {
+		notifierType string
+		settings     string
+	}{
+		{TypeWebhook, `{"url": "ftp://example.com"}`},
+		{TypeWebhook, `{"url": "https://example.com", "template": "{{.Nope"}`},
+		{TypeDiscord, `{}`},
+		{TypeTelegram, `{"bot_token": "123:abc"}`},
+		{"email", `{}`},
+	}
+	for _, tt := range invalid {
+		if _, err := NewNotifier(tt.notifierType, json.RawMessage(tt.settings), nil); err == nil {
+			t.Errorf("NewNotifier(%s, %s) succeeded, want error", tt.notifierType, tt.settings)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/tags"
+	"go.uber.org/zap"
+)
+
+// Delivery statuses
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// sendTimeout bounds a single delivery attempt
+const sendTimeout = 15 * time.Second
+
+// defaultRetryDelays are the waits before each retry of a failed delivery
+var defaultRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second}
+
+// TagSource looks up the tags of a media item
+type TagSource interface {
+	MediaTags(ctx context.Context, mediaItemID int64) ([]string, error)
+}
+
+// Service dispatches events to the notifiers subscribed to them
+type Service struct {
+	queries *generated.Queries
+	logger  *zap.Logger
+	client  *http.Client
+	tags    TagSource
+
+	retryDelays []time.Duration
+	slots       chan struct{} // Limits concurrent deliveries
+}
+
+// NewService creates a new notification service
+func NewService(queries *generated.Queries, logger *zap.Logger) *Service {
+	return &Service{
+		queries:     queries,
+		logger:      logger.With(zap.String("component", "notifications")),
+		client:      &http.Client{Timeout: sendTimeout},
+		retryDelays: defaultRetryDelays,
+		slots:       make(chan struct{}, 4),
+	}
+}
+
+// SetTags enables tag routing: notifiers with tags only receive events about
+// media whose monitoring rule shares one of them
+func (s *Service) SetTags(source TagSource) {
+	s.tags = source
+}
+
+// Notify sends an event to every enabled server-wide notifier subscribed to it.
+// Delivery happens in the background, so callers are never slowed down by a
+// slow or unreachable service. Notify on a nil Service does nothing, so event
+// sources don't need to check whether notifications are set up.
+func (s *Service) Notify(event Event) {
+	if s == nil {
+		return
+	}
+	s.dispatch(event, func(ctx context.Context) ([]generated.Notifier, error) {
+		return s.queries.ListNotifiersForEvent(ctx, string(event.Type))
+	})
+}
+
+// NotifyUser sends an event to the personal notifiers of a user subscribed to
+// it, in the background like Notify
+func (s *Service) NotifyUser(userID int64, event Event) {
+	if s == nil {
+		return
+	}
+	s.dispatch(event, func(ctx context.Context) ([]generated.Notifier, error) {
+		return s.queries.ListUserNotifiersForEvent(ctx, generated.ListUserNotifiersForEventParams{
+			UserID:    userID,
+			EventType: string(event.Type),
+		})
+	})
+}
+
+// dispatch delivers an event to the notifiers load returns
+func (s *Service) dispatch(event Event, load func(ctx context.Context) ([]generated.Notifier, error)) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	go func() {
+		ctx := context.Background()
+		notifiers, err := load(ctx)
+		if err != nil {
+			s.logger.Error("failed to load notifiers", zap.String("event", string(event.Type)), zap.Error(err))
+			return
+		}
+
+		mediaTags, tagged := s.eventTags(ctx, event)
+		for _, n := range notifiers {
+			if tagged && !tags.Matches(n.Tags, mediaTags) {
+				continue
+			}
+			go s.deliver(ctx, n, event)
+		}
+	}()
+}
+
+// eventTags returns the tags of the media item an event is about. Events that
+// aren't about a media item aren't routed by tags, so they reach every
+// subscribed notifier.
+func (s *Service) eventTags(ctx context.Context, event Event) ([]string, bool) {
+	if s.tags == nil {
+		return nil, false
+	}
+	id, ok := eventMediaItemID(event)
+	if !ok {
+		return nil, false
+	}
+	mediaTags, err := s.tags.MediaTags(ctx, id)
+	if err != nil {
+		s.logger.Warn("failed to look up media tags, notifying all subscribers",
+			zap.Int64("media_item_id", id), zap.Error(err))
+		return nil, false
+	}
+	return mediaTags, true
+}
+
+// eventMediaItemID returns the media item an event is about, if any
+func eventMediaItemID(event Event) (int64, bool) {
+	switch id := event.Data["media_item_id"].(type) {
+	case int64:
+		return id, true
+	case int:
+		return int64(id), true
+	case int32:
+		return int64(id), true
+	case float64:
+		return int64(id), true
+	}
+	return 0, false
+}
+
+// deliver sends an event to one notifier, retrying failures, and records the outcome
+func (s *Service) deliver(ctx context.Context, n generated.Notifier, event Event) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	notifier, err := NewNotifier(n.Type, n.Settings, s.client)
+	if err != nil {
+		s.recordDelivery(ctx, n, event, 0, err)
+		return
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		err = s.send(ctx, notifier, event)
+		if err == nil || attempts > len(s.retryDelays) {
+			break
+		}
+
+		s.logger.Debug("notification failed, retrying",
+			zap.Int64("notifier_id", n.ID),
+			zap.Int("attempt", attempts),
+			zap.Error(err))
+		time.Sleep(s.retryDelays[attempts-1])
+	}
+
+	if err != nil {
+		s.logger.Warn("failed to send notification",
+			zap.Int64("notifier_id", n.ID),
+			zap.String("notifier", n.Name),
+			zap.String("event", string(event.Type)),
+			zap.Int("attempts", attempts),
+			zap.Error(err))
+	}
+	s.recordDelivery(ctx, n, event, attempts, err)
+}
+
+func (s *Service) send(ctx context.Context, notifier Notifier, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return notifier.Send(ctx, event)
+}
+
+// recordDelivery adds a delivery to the log
+func (s *Service) recordDelivery(ctx context.Context, n generated.Notifier, event Event, attempts int, sendErr error) *generated.NotificationDelivery {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		payload = []byte("{}")
+	}
+
+	params := generated.CreateNotificationDeliveryParams{
+		NotifierID: n.ID,
+		EventType:  string(event.Type),
+		Status:     DeliveryDelivered,
+		Attempts:   int32(attempts),
+		Payload:    payload,
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		params.Status = DeliveryFailed
+		params.ErrorMessage = &msg
+	}
+
+	delivery, err := s.queries.CreateNotificationDelivery(ctx, params)
+	if err != nil {
+		s.logger.Error("failed to record notification delivery", zap.Int64("notifier_id", n.ID), zap.Error(err))
+		return nil
+	}
+	return &delivery
+}
+
+// Test sends a sample event to a notifier right away, without retries, and
+// returns the recorded delivery
+func (s *Service) Test(ctx context.Context, id int64) (*generated.NotificationDelivery, error) {
+	n, err := s.queries.GetNotifier(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event := sampleEvent()
+	notifier, err := NewNotifier(n.Type, n.Settings, s.client)
+	if err == nil {
+		err = s.send(ctx, notifier, event)
+	}
+
+	delivery := s.recordDelivery(ctx, n, event, 1, err)
+	if err != nil {
+		return delivery, fmt.Errorf("test notification failed: %w", err)
+	}
+	return delivery, nil
+}
@@ -1,11 +1,15 @@
 package plugins
 
 import (
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/blakestevenson/nimbus/internal/auth"
 	"github.com/blakestevenson/nimbus/internal/httputil"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 )
 
@@ -39,11 +43,30 @@ func (h *APIHandlers) ListPlugins(w http.ResponseWriter, r *http.Request) {
 	plugins := make([]map[string]interface{}, len(dbPlugins))
 	for i, dbPlugin := range dbPlugins {
 		plugins[i] = ConvertDBPluginToJSON(dbPlugin)
+		addHealthToJSON(plugins[i], dbPlugin.Enabled, h.manager)
 	}
 
 	httputil.RespondJSON(w, http.StatusOK, plugins)
 }
 
+// RecentEvents returns the events most recently published to plugins
+// GET /api/admin/events/recent?limit=50
+func (h *APIHandlers) RecentEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxRecentEvents {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxRecentEvents))
+			return
+		}
+		limit = parsed
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"events": h.manager.Events().Recent(limit),
+	})
+}
+
 // GetPluginUIManifest returns the UI manifest for a plugin
 // GET /api/plugins/{id}/ui-manifest
 func (h *APIHandlers) GetPluginUIManifest(w http.ResponseWriter, r *http.Request) {
@@ -111,6 +134,77 @@ func (h *APIHandlers) DisablePlugin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// RestartPlugin stops and starts a plugin's process
+// POST /api/plugins/{id}/restart
+func (h *APIHandlers) RestartPlugin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pluginID := chi.URLParam(r, "id")
+
+	h.logger.Info("Restarting plugin via API", zap.String("plugin_id", pluginID))
+
+	if err := h.manager.RestartPlugin(ctx, pluginID); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Plugin not found")
+		case errors.Is(err, ErrPluginNotEnabled):
+			httputil.RespondErrorMessage(w, http.StatusConflict, "Plugin is not enabled")
+		default:
+			h.logger.Error("Failed to restart plugin",
+				zap.String("plugin_id", pluginID),
+				zap.Error(err))
+			httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to restart plugin")
+		}
+		return
+	}
+
+	health, _ := h.manager.Health(pluginID)
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Plugin restarted successfully",
+		"id":      pluginID,
+		"status":  health.Status,
+	})
+}
+
+// ReloadPlugins rescans the plugins directory, starting new plugins, stopping
+// removed ones and restarting upgraded ones
+// POST /api/plugins/reload
+func (h *APIHandlers) ReloadPlugins(w http.ResponseWriter, r *http.Request) {
+	result, err := h.manager.Reload(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to reload plugins", zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to reload plugins")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, result)
+}
+
+// UnloadPlugin stops a running plugin and disables it
+// DELETE /api/plugins/{id}
+func (h *APIHandlers) UnloadPlugin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pluginID := chi.URLParam(r, "id")
+
+	h.logger.Info("Unloading plugin via API", zap.String("plugin_id", pluginID))
+
+	if err := h.manager.UnloadPlugin(ctx, pluginID); err != nil {
+		if errors.Is(err, ErrPluginNotLoaded) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Plugin not found or not loaded")
+			return
+		}
+		h.logger.Error("Failed to unload plugin",
+			zap.String("plugin_id", pluginID),
+			zap.Error(err))
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to unload plugin")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Plugin unloaded successfully",
+		"id":      pluginID,
+	})
+}
+
 // HandlePluginAPI forwards an HTTP request to a plugin (public method for router)
 func (h *APIHandlers) HandlePluginAPI(w http.ResponseWriter, r *http.Request, lp *LoadedPlugin, route RouteDescriptor) {
 	h.makePluginAPIHandler(lp, route)(w, r)
@@ -121,6 +215,19 @@ func (h *APIHandlers) makePluginAPIHandler(lp *LoadedPlugin, route RouteDescript
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// Routes outlive plugin restarts, so always dispatch to the plugin's
+		// current process
+		current, ok := h.manager.GetPlugin(lp.Meta.ID)
+		if !ok || !h.manager.isAvailable(lp.Meta.ID) {
+			httputil.RespondErrorMessage(w, http.StatusServiceUnavailable, "Plugin is not running")
+			return
+		}
+		lp = current
+
+		// Unloading waits for requests in flight to finish
+		lp.inflight.Add(1)
+		defer lp.inflight.Add(-1)
+
 		// Read request body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -137,16 +244,12 @@ func (h *APIHandlers) makePluginAPIHandler(lp *LoadedPlugin, route RouteDescript
 			Query:   r.URL.Query(),
 			Headers: r.Header,
 			Body:    body,
+			Route:   route.Path,
 		}
+		pluginReq.PathParams, _ = MatchPath(route.Path, r.URL.Path)
 
-		// Extract user context if authenticated (set by auth middleware using key "user")
-		if userID := getUserIDFromRequest(r); userID != nil {
-			pluginReq.UserID = userID
-		}
-
-		// TODO: Extract scopes from context if applicable
-		// This could be based on user roles or API key permissions
-		// pluginReq.Scopes = extractScopes(ctx)
+		// Pass on who is calling, if the route required a session
+		pluginReq.UserID, pluginReq.Scopes = requestScopes(r)
 
 		// Log before forwarding to plugin
 		h.logger.Info("Forwarding request to plugin",
@@ -205,25 +308,17 @@ func (h *APIHandlers) ServePluginStatic(w http.ResponseWriter, r *http.Request)
 	http.ServeFile(w, r, realPath)
 }
 
-// getUserClaims extracts user claims from the request context
-// Note: Must use the same context key string as auth middleware ("user")
-func getUserClaims(r *http.Request) (map[string]interface{}, bool) {
-	claims, ok := r.Context().Value("user").(map[string]interface{})
-	return claims, ok
-}
-
-// getUserIDFromRequest extracts the user ID from the request context
-func getUserIDFromRequest(r *http.Request) *int64 {
-	// Try to get from claims context (set by auth middleware)
-	if claims, ok := r.Context().Value("user").(map[string]interface{}); ok {
-		if userID, ok := claims["user_id"].(int64); ok {
-			return &userID
-		}
-		// Try float64 (JSON numbers)
-		if userIDFloat, ok := claims["user_id"].(float64); ok {
-			userID := int64(userIDFloat)
-			return &userID
-		}
+// requestScopes returns the user ID and scopes of the signed in user, read
+// from the claims the auth middleware stores under "user"
+func requestScopes(r *http.Request) (*int64, []string) {
+	claims, ok := r.Context().Value("user").(*auth.Claims)
+	if !ok {
+		return nil, nil
+	}
+	userID := claims.UserID
+	scopes := []string{ScopeUser}
+	if claims.IsAdmin {
+		scopes = append(scopes, ScopeAdmin)
 	}
-	return nil
+	return &userID, scopes
 }
@@ -0,0 +1,262 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ErrConfigMigrationUnsupported is returned by MigrateConfig when the plugin
+// doesn't migrate its config
+var ErrConfigMigrationUnsupported = errors.New("plugin does not migrate config")
+
+// configPrefix is the prefix of every config key a plugin owns
+func configPrefix(id string) string {
+	return "plugins." + id + "."
+}
+
+// migrateConfig upgrades a plugin's stored config to the schema version of its
+// config section, once. Secrets are left out of what the plugin is given to
+// migrate. The stored version is only advanced when the migration succeeds, so
+// a failed one is retried on the next load.
+func (pm *PluginManager) migrateConfig(ctx context.Context, id string, client MediaSuitePlugin, section *ConfigSection) error {
+	if section == nil || section.SchemaVersion == 0 {
+		return nil
+	}
+
+	stored, err := pm.queries.GetPluginConfigVersion(ctx, id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to get config version: %w", err)
+	}
+	if int(stored) >= section.SchemaVersion {
+		return nil
+	}
+
+	if migrator, ok := client.(ConfigMigrator); ok {
+		if err := pm.runConfigMigration(ctx, id, migrator, int(stored)); err != nil {
+			return fmt.Errorf("config migration from version %d to %d failed: %w", stored, section.SchemaVersion, err)
+		}
+	}
+
+	if err := pm.queries.SetPluginConfigVersion(ctx, generated.SetPluginConfigVersionParams{
+		ID:            id,
+		ConfigVersion: int32(section.SchemaVersion),
+	}); err != nil {
+		return fmt.Errorf("failed to store config version: %w", err)
+	}
+
+	pm.logger.Info("Migrated plugin config",
+		zap.String("plugin_id", id),
+		zap.Int32("from_version", stored),
+		zap.Int("to_version", section.SchemaVersion))
+	return nil
+}
+
+// runConfigMigration passes a plugin its stored config and writes back what
+// the migration changed
+func (pm *PluginManager) runConfigMigration(ctx context.Context, id string, migrator ConfigMigrator, fromVersion int) error {
+	prefix := configPrefix(id)
+	raw, err := pm.configStore.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	config := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		if configstore.IsSecret(value) {
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(value, &decoded); err != nil {
+			return fmt.Errorf("invalid stored value for %s: %w", key, err)
+		}
+		config[key] = decoded
+	}
+
+	migrated, err := migrator.MigrateConfig(ctx, fromVersion, config)
+	if errors.Is(err, ErrConfigMigrationUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for key := range migrated {
+		if !strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("migration returned key %s outside of %s", key, prefix)
+		}
+	}
+	for key, value := range migrated {
+		if value == nil {
+			if _, exists := raw[key]; !exists {
+				continue
+			}
+			if err := pm.configStore.Delete(ctx, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := pm.configStore.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkConfig migrates a plugin's stored config and validates it, returning
+// the problems found. A plugin with a broken config still loads, so that it can
+// be fixed from its settings.
+func (pm *PluginManager) checkConfig(ctx context.Context, id string, client MediaSuitePlugin, section *ConfigSection) []string {
+	var problems []string
+	if err := pm.migrateConfig(ctx, id, client, section); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	invalid, err := pm.validateConfig(ctx, id, section)
+	if err != nil {
+		problems = append(problems, "failed to validate config: "+err.Error())
+	}
+	problems = append(problems, invalid...)
+
+	if len(problems) > 0 {
+		pm.logger.Warn("Plugin config has problems",
+			zap.String("plugin_id", id),
+			zap.Strings("problems", problems))
+	}
+	return problems
+}
+
+// validateConfig checks the stored config of a plugin against the fields of
+// its config section
+func (pm *PluginManager) validateConfig(ctx context.Context, id string, section *ConfigSection) ([]string, error) {
+	if section == nil || len(section.Fields) == 0 {
+		return nil, nil
+	}
+
+	values, err := pm.configStore.GetByPrefix(ctx, configPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+	return validateConfigValues(section.Fields, values), nil
+}
+
+// validateConfigValues returns a problem for every field whose stored value
+// doesn't fit it. Secrets can't be read back, so only their presence is
+// checked. Fields of custom types are left to the plugin.
+func validateConfigValues(fields []ConfigField, values map[string]json.RawMessage) []string {
+	var problems []string
+	for _, field := range fields {
+		raw, ok := values[field.Key]
+		if !ok || string(raw) == "null" || string(raw) == `""` {
+			if field.Required && field.DefaultValue == "" {
+				problems = append(problems, field.Label+" is required")
+			}
+			continue
+		}
+		if configstore.IsSecret(raw) {
+			continue
+		}
+		if problem := validateConfigValue(field, raw); problem != "" {
+			problems = append(problems, field.Label+": "+problem)
+		}
+	}
+	return problems
+}
+
+// validateConfigValue returns what is wrong with the stored value of a field,
+// or an empty string when it fits
+func validateConfigValue(field ConfigField, raw json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "stored value is not valid JSON"
+	}
+	invalid := func(problem string) string {
+		if field.Validation != nil && field.Validation.ErrorMessage != "" {
+			return field.Validation.ErrorMessage
+		}
+		return problem
+	}
+
+	switch field.Type {
+	case "number":
+		n, ok := configNumber(value)
+		if !ok {
+			return "must be a number"
+		}
+		if v := field.Validation; v != nil {
+			if v.Min != nil && n < float64(*v.Min) {
+				return invalid(fmt.Sprintf("must be at least %d", *v.Min))
+			}
+			if v.Max != nil && n > float64(*v.Max) {
+				return invalid(fmt.Sprintf("must be at most %d", *v.Max))
+			}
+		}
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(v); err != nil {
+				return "must be true or false"
+			}
+		default:
+			return "must be true or false"
+		}
+	case "array":
+		if s, ok := value.(string); ok {
+			var decoded []interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+				return "must be a list"
+			}
+		} else if _, ok := value.([]interface{}); !ok {
+			return "must be a list"
+		}
+	case "select":
+		s := configString(value)
+		if len(field.Options) > 0 && !slices.Contains(field.Options, s) {
+			return fmt.Sprintf("%q is not one of %s", s, strings.Join(field.Options, ", "))
+		}
+	case "text", "textarea", "password":
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return "must be text"
+		}
+		if v := field.Validation; v != nil && v.Pattern != "" {
+			pattern, err := regexp.Compile(v.Pattern)
+			if err == nil && !pattern.MatchString(configString(value)) {
+				return invalid("does not match " + v.Pattern)
+			}
+		}
+	}
+	return ""
+}
+
+// configNumber reads a number stored as either a JSON number or a string
+func configNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// configString formats a scalar config value as the UI shows it
+func configString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,71 @@
+package plugins
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfigValues(t *testing.T) {
+	lo, hi := int32(1), int32(50)
+	fields := []ConfigField{
+		{Key: "plugins.nzb.download_dir", Label: "Download Directory", Type: "text", Required: true},
+		{Key: "plugins.nzb.connections", Label: "Max Connections", Type: "number",
+			Validation: &ConfigFieldValidation{Min: &lo, Max: &hi, ErrorMessage: "Must be between 1 and 50"}},
+		{Key: "plugins.nzb.preempt", Label: "Preempt", Type: "boolean"},
+		{Key: "plugins.nzb.mode", Label: "Mode", Type: "select", Options: []string{"copy", "move"}},
+		{Key: "plugins.nzb.tags", Label: "Tags", Type: "array"},
+		{Key: "plugins.nzb.servers", Label: "Servers", Type: "custom"},
+		{Key: "plugins.nzb.password", Label: "Password", Type: "password", Required: true},
+		{Key: "plugins.nzb.category", Label: "Category", Type: "text", Required: true, DefaultValue: "tv"},
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   []string
+	}{
+		{
+			name: "valid",
+			values: map[string]string{
+				"plugins.nzb.download_dir": `"/downloads"`,
+				"plugins.nzb.connections":  `10`,
+				"plugins.nzb.preempt":      `"true"`,
+				"plugins.nzb.mode":         `"copy"`,
+				"plugins.nzb.tags":         `"[\"a\"]"`,
+				"plugins.nzb.servers":      `{"anything": 1}`,
+				"plugins.nzb.password":     `{"$secret_key": "k1", "$secret": "abc"}`,
+			},
+		},
+		{
+			name: "invalid",
+			values: map[string]string{
+				"plugins.nzb.download_dir": `""`,
+				"plugins.nzb.connections":  `"80"`,
+				"plugins.nzb.preempt":      `3`,
+				"plugins.nzb.mode":         `"link"`,
+				"plugins.nzb.tags":         `{}`,
+			},
+			want: []string{
+				"Download Directory is required",
+				"Max Connections: Must be between 1 and 50",
+				"Preempt: must be true or false",
+				`Mode: "link" is not one of copy, move`,
+				"Tags: must be a list",
+				"Password is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make(map[string]json.RawMessage, len(tt.values))
+			for key, value := range tt.values {
+				values[key] = json.RawMessage(value)
+			}
+			if got := validateConfigValues(fields, values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateConfigValues() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
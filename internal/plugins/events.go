@@ -0,0 +1,243 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"go.uber.org/zap"
+)
+
+// Events the host publishes to plugins
+const (
+	EventMediaItemCreated    = "media.item.created"
+	EventMediaItemUpdated    = "media.item.updated"
+	EventMediaItemRematched  = "media.item.rematched"
+	EventDownloadCompleted   = "download.completed"
+	EventDownloadFailed      = "download.failed"
+	EventImportCompleted     = "import.completed"
+	EventLibraryScanFinished = "library.scan.finished"
+	EventPluginLoaded        = "plugin.loaded"
+	EventPluginUnloaded      = "plugin.unloaded"
+	EventHostShutdown        = "host.shutdown"
+
+	// EventConfigChanged is delivered to the plugin whose config key
+	// (plugins.<id>.*) was written or deleted, with the key, the new value
+	// (left out for secrets), and whether it was deleted
+	EventConfigChanged = "config.changed"
+)
+
+const (
+	// defaultEventTimeout bounds how long a plugin may take to handle an event
+	defaultEventTimeout = 10 * time.Second
+
+	// maxRecentEvents is how many published events are kept for debugging
+	maxRecentEvents = 200
+)
+
+// EventDelivery is the outcome of delivering an event to one plugin
+type EventDelivery struct {
+	PluginID   string `json:"plugin_id"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Done       bool   `json:"done"`
+}
+
+// PublishedEvent is an event as it was delivered, kept for debugging
+type PublishedEvent struct {
+	Type       string                 `json:"type"`
+	Data       map[string]interface{} `json:"data"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Deliveries []EventDelivery        `json:"deliveries"`
+}
+
+// EventBus delivers host events to every loaded plugin. Delivery is fire and
+// forget: each plugin handles the event in the background with a timeout, and
+// failures are only logged.
+type EventBus struct {
+	manager *PluginManager
+	logger  *zap.Logger
+	timeout time.Duration
+
+	mu     sync.Mutex
+	recent []*PublishedEvent // Oldest first
+}
+
+func newEventBus(manager *PluginManager, logger *zap.Logger) *EventBus {
+	return &EventBus{
+		manager: manager,
+		logger:  logger.With(zap.String("component", "plugin-events")),
+		timeout: defaultEventTimeout,
+	}
+}
+
+// Publish delivers an event to all loaded plugins. Publish on a nil EventBus
+// does nothing, so event sources work the same with plugins disabled.
+func (b *EventBus) Publish(eventType string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.publish(eventType, data, b.manager.ListPlugins())
+}
+
+// PublishTo delivers an event to one plugin, if it is loaded
+func (b *EventBus) PublishTo(pluginID, eventType string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	lp, ok := b.manager.GetPlugin(pluginID)
+	if !ok {
+		return
+	}
+	b.publish(eventType, data, []*LoadedPlugin{lp})
+}
+
+// publish delivers an event to plugins in the background
+func (b *EventBus) publish(eventType string, data map[string]interface{}, loaded []*LoadedPlugin) {
+	evt, published := b.prepare(eventType, data, loaded)
+	for i, lp := range loaded {
+		go func(i int, lp *LoadedPlugin) {
+			ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+			defer cancel()
+			b.deliver(ctx, lp, evt, published, i)
+		}(i, lp)
+	}
+}
+
+// PublishAndWait delivers an event to all loaded plugins and waits until every
+// plugin has handled it or ctx is done. It is for events plugins must act on
+// before the host goes on, like the host shutting down.
+func (b *EventBus) PublishAndWait(ctx context.Context, eventType string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	loaded := b.manager.ListPlugins()
+	evt, published := b.prepare(eventType, data, loaded)
+	var wg sync.WaitGroup
+	for i, lp := range loaded {
+		wg.Add(1)
+		go func(i int, lp *LoadedPlugin) {
+			defer wg.Done()
+			b.deliver(ctx, lp, evt, published, i)
+		}(i, lp)
+	}
+	wg.Wait()
+}
+
+// prepare builds an event for plugins and records it
+func (b *EventBus) prepare(eventType string, data map[string]interface{}, loaded []*LoadedPlugin) (Event, *PublishedEvent) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	evt := Event{
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now().UTC(),
+	}
+
+	published := &PublishedEvent{
+		Type:       evt.Type,
+		Data:       evt.Data,
+		Timestamp:  evt.Timestamp,
+		Deliveries: make([]EventDelivery, len(loaded)),
+	}
+	for i, lp := range loaded {
+		published.Deliveries[i].PluginID = lp.Meta.ID
+	}
+	b.record(published)
+
+	b.logger.Debug("publishing event", zap.String("type", eventType), zap.Int("plugins", len(loaded)))
+	return evt, published
+}
+
+// deliver hands an event to one plugin and records the outcome
+func (b *EventBus) deliver(ctx context.Context, lp *LoadedPlugin, evt Event, published *PublishedEvent, index int) {
+	start := time.Now()
+	err := lp.Client.HandleEvent(ctx, evt)
+	if err != nil {
+		b.logger.Warn("plugin failed to handle event",
+			zap.String("plugin_id", lp.Meta.ID),
+			zap.String("type", evt.Type),
+			zap.Error(err))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delivery := &published.Deliveries[index]
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	delivery.Done = true
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+}
+
+// record adds an event to the recent events, dropping the oldest
+func (b *EventBus) record(published *PublishedEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.recent = append(b.recent, published)
+	if len(b.recent) > maxRecentEvents {
+		b.recent = b.recent[len(b.recent)-maxRecentEvents:]
+	}
+}
+
+// Recent returns up to limit of the most recently published events, newest first
+func (b *EventBus) Recent(limit int) []PublishedEvent {
+	if b == nil {
+		return []PublishedEvent{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if limit <= 0 || limit > len(b.recent) {
+		limit = len(b.recent)
+	}
+	events := make([]PublishedEvent, 0, limit)
+	for i := len(b.recent) - 1; i >= 0 && len(events) < limit; i-- {
+		evt := *b.recent[i]
+		evt.Deliveries = append([]EventDelivery(nil), evt.Deliveries...)
+		events = append(events, evt)
+	}
+	return events
+}
+
+// configChanged tells the plugin that owns a changed config key about it
+func (pm *PluginManager) configChanged(change configstore.Change) {
+	pluginID, data, ok := configChangeEvent(change)
+	if !ok {
+		return
+	}
+	pm.events.PublishTo(pluginID, EventConfigChanged, data)
+}
+
+// configChangeEvent builds the config.changed event for a change to a
+// plugins.<id>.* key, returning the ID of the plugin it is for
+func configChangeEvent(change configstore.Change) (string, map[string]interface{}, bool) {
+	rest := strings.TrimPrefix(change.Key, "plugins.")
+	pluginID, _, found := strings.Cut(rest, ".")
+	if rest == change.Key || !found || pluginID == "" {
+		return "", nil, false
+	}
+
+	data := map[string]interface{}{
+		"key":     change.Key,
+		"secret":  change.Secret,
+		"deleted": change.Deleted,
+	}
+	if len(change.Value) > 0 {
+		var value interface{}
+		if err := json.Unmarshal(change.Value, &value); err == nil {
+			data["value"] = value
+		}
+	}
+	return pluginID, data, true
+}
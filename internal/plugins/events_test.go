@@ -0,0 +1,38 @@
+package plugins
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+)
+
+func TestConfigChangeEvent(t *testing.T) {
+	pluginID, data, ok := configChangeEvent(configstore.Change{
+		Key:   "plugins.nzb-downloader.servers",
+		Value: json.RawMessage(`[{"id":"a"}]`),
+	})
+	if !ok || pluginID != "nzb-downloader" {
+		t.Fatalf("got %q, %v", pluginID, ok)
+	}
+	if data["key"] != "plugins.nzb-downloader.servers" || data["deleted"] != false {
+		t.Errorf("unexpected data %v", data)
+	}
+	if servers, _ := data["value"].([]interface{}); len(servers) != 1 {
+		t.Errorf("value not decoded: %v", data["value"])
+	}
+
+	_, data, ok = configChangeEvent(configstore.Change{Key: "plugins.usenet-indexer.api_keys", Secret: true})
+	if !ok || data["secret"] != true {
+		t.Errorf("secret change: %v, %v", data, ok)
+	}
+	if _, has := data["value"]; has {
+		t.Error("secret change carries a value")
+	}
+
+	for _, key := range []string{"plugins.", "plugins.nzb-downloader", "server.port", "plugins..x"} {
+		if _, _, ok := configChangeEvent(configstore.Change{Key: key}); ok {
+			t.Errorf("%q should not produce an event", key)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"go.uber.org/zap"
+)
+
+// PluginStatus is the health of a loaded plugin process
+type PluginStatus string
+
+const (
+	PluginStatusHealthy    PluginStatus = "healthy"
+	PluginStatusUnhealthy  PluginStatus = "unhealthy" // Running, but failing health checks
+	PluginStatusCrashed    PluginStatus = "crashed"   // Dead, waiting to be restarted
+	PluginStatusRestarting PluginStatus = "restarting"
+)
+
+const (
+	// healthCheckInterval is how often the supervisor looks at each plugin
+	healthCheckInterval = 10 * time.Second
+
+	// healthPingInterval is how often a running plugin is pinged
+	healthPingInterval = 30 * time.Second
+
+	// healthPingTimeout bounds a single Metadata ping
+	healthPingTimeout = 5 * time.Second
+
+	// maxPingFailures is how many consecutive failed pings mark a running
+	// plugin as hung, so that it is killed and restarted
+	maxPingFailures = 3
+
+	// Restarts back off exponentially from minRestartBackoff up to
+	// maxRestartBackoff. The backoff resets once a plugin has stayed up for
+	// stableUptime.
+	minRestartBackoff = 5 * time.Second
+	maxRestartBackoff = 5 * time.Minute
+	stableUptime      = 10 * time.Minute
+)
+
+var (
+	// ErrPluginNotEnabled is returned when restarting a plugin that is not enabled
+	ErrPluginNotEnabled = errors.New("plugin is not enabled")
+
+	// ErrPluginNotLoaded is returned when unloading a plugin that is not running
+	ErrPluginNotLoaded = errors.New("plugin is not loaded")
+)
+
+// PluginHealth is the supervisor's view of a plugin process
+type PluginHealth struct {
+	Status      PluginStatus `json:"status"`
+	LastError   string       `json:"last_error,omitempty"`
+	StartedAt   time.Time    `json:"started_at"`
+	LastCheckAt time.Time    `json:"last_check_at"`
+	Restarts    int          `json:"restarts"`
+	// ConfigErrors are the problems found in the plugin's stored config when
+	// it was last loaded
+	ConfigErrors []string `json:"config_errors,omitempty"`
+
+	pingFailures int       // Consecutive failed pings
+	attempts     int       // Restart attempts since the plugin was last stable
+	nextRestart  time.Time // When a crashed plugin is restarted next
+}
+
+// Uptime returns how long the current plugin process has been running
+func (h PluginHealth) Uptime() time.Duration {
+	if h.StartedAt.IsZero() || (h.Status != PluginStatusHealthy && h.Status != PluginStatusUnhealthy) {
+		return 0
+	}
+	return time.Since(h.StartedAt)
+}
+
+// restartBackoff returns the delay before the given restart attempt
+func restartBackoff(attempt int) time.Duration {
+	delay := minRestartBackoff
+	for i := 0; i < attempt && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRestartBackoff)
+}
+
+// Health returns the health of a plugin. ok is false for plugins that were
+// never started.
+func (pm *PluginManager) Health(id string) (PluginHealth, bool) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	h, ok := pm.health[id]
+	if !ok {
+		return PluginHealth{}, false
+	}
+	return *h, true
+}
+
+// isAvailable reports whether a plugin is running and can take requests
+func (pm *PluginManager) isAvailable(id string) bool {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	h, ok := pm.health[id]
+	return !ok || h.Status == PluginStatusHealthy || h.Status == PluginStatusUnhealthy
+}
+
+// HealthHandler is called, in the background, when the health status of a
+// plugin changes
+type HealthHandler func(id string, health PluginHealth)
+
+// OnHealthChange registers a handler that is called whenever the health
+// status of a plugin changes
+func (pm *PluginManager) OnHealthChange(handler HealthHandler) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	pm.healthHandlers = append(pm.healthHandlers, handler)
+}
+
+// setStatus changes the status of a plugin and runs the health handlers when
+// it is a different one. healthMu must be held.
+func (pm *PluginManager) setStatus(id string, h *PluginHealth, status PluginStatus) {
+	if h.Status == status {
+		return
+	}
+	h.Status = status
+
+	snapshot := *h
+	for _, handler := range pm.healthHandlers {
+		go handler(id, snapshot)
+	}
+}
+
+// recordStarted marks a freshly loaded plugin as healthy
+func (pm *PluginManager) recordStarted(id string) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	h, ok := pm.health[id]
+	if !ok {
+		h = &PluginHealth{}
+		pm.health[id] = h
+	}
+	now := time.Now()
+	h.LastError = ""
+	h.StartedAt = now
+	h.LastCheckAt = now
+	h.pingFailures = 0
+	pm.setStatus(id, h, PluginStatusHealthy)
+}
+
+// recordConfigErrors records the problems found in a plugin's config on load
+func (pm *PluginManager) recordConfigErrors(id string, problems []string) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	if h, ok := pm.health[id]; ok {
+		h.ConfigErrors = problems
+	}
+}
+
+// recordStartFailed marks a plugin that could not be started as crashed, so
+// that starting it is retried with backoff
+func (pm *PluginManager) recordStartFailed(id string, err error) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	h, ok := pm.health[id]
+	if !ok {
+		h = &PluginHealth{}
+		pm.health[id] = h
+	}
+	h.LastError = err.Error()
+	h.nextRestart = time.Now().Add(restartBackoff(h.attempts))
+	pm.setStatus(id, h, PluginStatusCrashed)
+}
+
+// forgetHealth stops supervising a plugin that was unloaded on purpose
+func (pm *PluginManager) forgetHealth(id string) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	delete(pm.health, id)
+}
+
+// supervise checks plugin health until the context is cancelled
+func (pm *PluginManager) supervise(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pm.checkPlugins(ctx)
+			pm.restartDuePlugins(ctx)
+		}
+	}
+}
+
+// checkPlugins detects dead plugin processes and pings running ones
+func (pm *PluginManager) checkPlugins(ctx context.Context) {
+	for _, lp := range pm.ListPlugins() {
+		if ctx.Err() != nil {
+			return
+		}
+		id := lp.Meta.ID
+
+		h, ok := pm.Health(id)
+		if !ok || h.Status == PluginStatusCrashed || h.Status == PluginStatusRestarting {
+			continue
+		}
+
+		if lp.RawClient != nil && lp.RawClient.Exited() {
+			pm.markCrashed(ctx, lp, errors.New("plugin process exited"))
+			continue
+		}
+
+		if time.Since(h.LastCheckAt) < healthPingInterval {
+			continue
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		_, err := lp.Client.Metadata(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if pm.recordPing(id, err) {
+			pm.markCrashed(ctx, lp, fmt.Errorf("plugin stopped responding: %w", err))
+		}
+	}
+}
+
+// recordPing records the result of a health ping, returning true when the
+// plugin has failed enough pings in a row to be considered hung
+func (pm *PluginManager) recordPing(id string, err error) bool {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+
+	h, ok := pm.health[id]
+	if !ok {
+		return false
+	}
+	h.LastCheckAt = time.Now()
+
+	if err != nil {
+		h.pingFailures++
+		h.LastError = err.Error()
+		pm.setStatus(id, h, PluginStatusUnhealthy)
+		pm.logger.Warn("Plugin health check failed",
+			zap.String("plugin_id", id),
+			zap.Int("consecutive_failures", h.pingFailures),
+			zap.Error(err))
+		return h.pingFailures >= maxPingFailures
+	}
+
+	if h.Status == PluginStatusUnhealthy {
+		pm.logger.Info("Plugin recovered", zap.String("plugin_id", id))
+	}
+	h.pingFailures = 0
+	pm.setStatus(id, h, PluginStatusHealthy)
+	if time.Since(h.StartedAt) >= stableUptime {
+		h.attempts = 0
+	}
+	return false
+}
+
+// markCrashed kills a dead or hung plugin and schedules its restart
+func (pm *PluginManager) markCrashed(ctx context.Context, lp *LoadedPlugin, cause error) {
+	id := lp.Meta.ID
+
+	pm.healthMu.Lock()
+	h, ok := pm.health[id]
+	if !ok || h.Status == PluginStatusCrashed || h.Status == PluginStatusRestarting {
+		pm.healthMu.Unlock()
+		return
+	}
+	h.LastError = cause.Error()
+	h.nextRestart = time.Now().Add(restartBackoff(h.attempts))
+	nextRestart := h.nextRestart
+	pm.setStatus(id, h, PluginStatusCrashed)
+	pm.healthMu.Unlock()
+
+	pm.logger.Error("Plugin crashed",
+		zap.String("plugin_id", id),
+		zap.Time("next_restart", nextRestart),
+		zap.Error(cause))
+
+	if lp.RawClient != nil {
+		lp.RawClient.Kill()
+	}
+
+	if lp.IsDownloader {
+		pm.interruptDownloads(ctx, id, cause)
+	}
+}
+
+// interruptDownloads marks the active downloads of a crashed downloader
+// plugin, so it is clear why their progress stopped. The plugin reports their
+// real state again once it is back.
+func (pm *PluginManager) interruptDownloads(ctx context.Context, id string, cause error) {
+	message := "Download plugin crashed: " + cause.Error()
+	count, err := pm.queries.MarkPluginDownloadsInterrupted(ctx, generated.MarkPluginDownloadsInterruptedParams{
+		PluginID:     id,
+		ErrorMessage: &message,
+	})
+	if err != nil {
+		pm.logger.Error("Failed to mark downloads as interrupted",
+			zap.String("plugin_id", id),
+			zap.Error(err))
+		return
+	}
+	if count > 0 {
+		pm.logger.Warn("Marked downloads as interrupted",
+			zap.String("plugin_id", id),
+			zap.Int64("count", count))
+	}
+}
+
+// restartDuePlugins restarts crashed plugins whose backoff has elapsed
+func (pm *PluginManager) restartDuePlugins(ctx context.Context) {
+	now := time.Now()
+
+	pm.healthMu.Lock()
+	var due []string
+	for id, h := range pm.health {
+		if h.Status == PluginStatusCrashed && !now.Before(h.nextRestart) {
+			due = append(due, id)
+		}
+	}
+	pm.healthMu.Unlock()
+
+	for _, id := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := pm.restartPlugin(ctx, id); err != nil {
+			pm.logger.Error("Failed to restart plugin",
+				zap.String("plugin_id", id),
+				zap.Error(err))
+		}
+	}
+}
+
+// RestartPlugin stops and starts a plugin immediately, whatever its health
+func (pm *PluginManager) RestartPlugin(ctx context.Context, id string) error {
+	dbPlugin, err := pm.queries.GetPlugin(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get plugin: %w", err)
+	}
+	if !dbPlugin.Enabled {
+		return ErrPluginNotEnabled
+	}
+
+	pm.healthMu.Lock()
+	if h, ok := pm.health[id]; ok {
+		if h.Status == PluginStatusRestarting {
+			pm.healthMu.Unlock()
+			return errors.New("plugin is already restarting")
+		}
+		// A manual restart starts the backoff over
+		h.attempts = 0
+	}
+	pm.healthMu.Unlock()
+
+	return pm.restartPlugin(ctx, id)
+}
+
+// restartPlugin kills a plugin's process and loads it again
+func (pm *PluginManager) restartPlugin(ctx context.Context, id string) error {
+	pm.healthMu.Lock()
+	h, ok := pm.health[id]
+	if !ok {
+		h = &PluginHealth{}
+		pm.health[id] = h
+	}
+	h.attempts++
+	h.Restarts++
+	pm.setStatus(id, h, PluginStatusRestarting)
+	pm.healthMu.Unlock()
+
+	pm.logger.Info("Restarting plugin", zap.String("plugin_id", id))
+
+	pm.mu.Lock()
+	if lp, ok := pm.plugins[id]; ok {
+		if lp.RawClient != nil {
+			lp.RawClient.Kill()
+		}
+		delete(pm.plugins, id)
+	}
+	pm.mu.Unlock()
+
+	if err := pm.loadPlugin(ctx, id); err != nil {
+		pm.recordStartFailed(id, err)
+		return err
+	}
+
+	pm.logger.Info("Plugin restarted", zap.String("plugin_id", id))
+	return nil
+}
@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 10 * time.Second},
+		{3, 40 * time.Second},
+		{6, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := restartBackoff(tt.attempt); got != tt.want {
+			t.Errorf("restartBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRecordPingMarksHungPlugin(t *testing.T) {
+	pm := &PluginManager{logger: zap.NewNop(), health: make(map[string]*PluginHealth)}
+	pm.recordStarted("nzb")
+
+	pingErr := errors.New("deadline exceeded")
+	for i := 1; i < maxPingFailures; i++ {
+		if pm.recordPing("nzb", pingErr) {
+			t.Fatalf("plugin considered hung after %d failed pings", i)
+		}
+	}
+	if h, _ := pm.Health("nzb"); h.Status != PluginStatusUnhealthy || h.LastError != pingErr.Error() {
+		t.Errorf("health after failed pings = %+v, want unhealthy with last error", h)
+	}
+	if !pm.isAvailable("nzb") {
+		t.Error("an unhealthy plugin should still take requests")
+	}
+	if !pm.recordPing("nzb", pingErr) {
+		t.Fatalf("plugin not considered hung after %d failed pings", maxPingFailures)
+	}
+
+	// A successful ping resets the failure count
+	pm.recordStarted("nzb")
+	pm.recordPing("nzb", pingErr)
+	pm.recordPing("nzb", nil)
+	if h, _ := pm.Health("nzb"); h.Status != PluginStatusHealthy || h.pingFailures != 0 {
+		t.Errorf("health after recovery = %+v, want healthy", h)
+	}
+}
+
+func TestCrashedPluginIsUnavailable(t *testing.T) {
+	pm := &PluginManager{logger: zap.NewNop(), health: make(map[string]*PluginHealth)}
+
+	pm.recordStartFailed("nzb", errors.New("plugin executable not found"))
+	if pm.isAvailable("nzb") {
+		t.Error("a crashed plugin should not take requests")
+	}
+	h, _ := pm.Health("nzb")
+	if h.Status != PluginStatusCrashed || h.Uptime() != 0 {
+		t.Errorf("health after failed start = %+v, want crashed with no uptime", h)
+	}
+
+	pm.recordStarted("nzb")
+	if !pm.isAvailable("nzb") {
+		t.Error("a restarted plugin should take requests")
+	}
+}
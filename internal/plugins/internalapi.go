@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The host passes the address of its HTTP API and a token to each plugin
+// process in these environment variables, so plugins can call back into
+// endpoints the SDK doesn't cover yet
+const (
+	EnvInternalURL   = "NIMBUS_INTERNAL_URL"
+	EnvInternalToken = "NIMBUS_INTERNAL_TOKEN"
+
+	// InternalTokenHeader authenticates requests from plugins to the host API
+	InternalTokenHeader = "X-Nimbus-Internal-Token"
+)
+
+// InternalAPI is where plugins reach the host HTTP API
+type InternalAPI struct {
+	BaseURL string // e.g. "http://localhost:8080", without a trailing slash
+	Token   string
+}
+
+// InternalAPIFromEnv reads the internal API settings the host started this
+// plugin with. The error says what is missing, so plugins can log it on
+// startup instead of failing later on every callback.
+func InternalAPIFromEnv() (*InternalAPI, error) {
+	api := &InternalAPI{
+		BaseURL: strings.TrimRight(os.Getenv(EnvInternalURL), "/"),
+		Token:   os.Getenv(EnvInternalToken),
+	}
+	if api.BaseURL == "" {
+		return nil, fmt.Errorf("%s is not set, the host may be older than this plugin", EnvInternalURL)
+	}
+	if err := validateInternalURL(api.BaseURL); err != nil {
+		return nil, err
+	}
+	if api.Token == "" {
+		return nil, fmt.Errorf("%s is not set", EnvInternalToken)
+	}
+	return api, nil
+}
+
+// validateInternalURL checks an internal API base URL is an absolute HTTP URL
+func validateInternalURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a valid URL: %w", EnvInternalURL, baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s %q must be an http or https URL with a host", EnvInternalURL, baseURL)
+	}
+	return nil
+}
+
+// URL returns the absolute URL of an API path such as "/api/downloads/import"
+func (a *InternalAPI) URL(path string) string {
+	return a.BaseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// NewRequest creates a request to an API path carrying the internal token
+func (a *InternalAPI) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.URL(path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(InternalTokenHeader, a.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// environ returns the environment variables that pass the API settings to a
+// plugin process
+func (a InternalAPI) environ() []string {
+	return []string{
+		EnvInternalURL + "=" + a.BaseURL,
+		EnvInternalToken + "=" + a.Token,
+	}
+}
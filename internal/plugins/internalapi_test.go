@@ -0,0 +1,41 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInternalAPIFromEnv(t *testing.T) {
+	t.Setenv(EnvInternalURL, "")
+	t.Setenv(EnvInternalToken, "")
+	if _, err := InternalAPIFromEnv(); err == nil || !strings.Contains(err.Error(), EnvInternalURL) {
+		t.Errorf("InternalAPIFromEnv() without a URL error = %v", err)
+	}
+
+	t.Setenv(EnvInternalURL, "localhost:9090")
+	if _, err := InternalAPIFromEnv(); err == nil {
+		t.Error("InternalAPIFromEnv() accepted a URL without a scheme")
+	}
+
+	t.Setenv(EnvInternalURL, "https://nimbus.example:9090/")
+	if _, err := InternalAPIFromEnv(); err == nil || !strings.Contains(err.Error(), EnvInternalToken) {
+		t.Errorf("InternalAPIFromEnv() without a token error = %v", err)
+	}
+
+	t.Setenv(EnvInternalToken, "secret")
+	api, err := InternalAPIFromEnv()
+	if err != nil {
+		t.Fatalf("InternalAPIFromEnv() error = %v", err)
+	}
+	req, err := api.NewRequest(context.Background(), "POST", "/api/downloads/import", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.URL.String(); got != "https://nimbus.example:9090/api/downloads/import" {
+		t.Errorf("request URL = %s", got)
+	}
+	if got := req.Header.Get(InternalTokenHeader); got != "secret" {
+		t.Errorf("request token = %q", got)
+	}
+}
@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// drainTimeout bounds how long an unloading plugin may finish in-flight
+// requests before its process is killed. Streaming requests never finish on
+// their own, so this is kept short.
+const drainTimeout = 10 * time.Second
+
+// binaryInfo identifies the executable a plugin process was started from
+type binaryInfo struct {
+	path    string
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// statBinary reads the identity of a plugin executable
+func statBinary(path string) (binaryInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return binaryInfo{}, err
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return binaryInfo{}, err
+	}
+	return binaryInfo{path: path, modTime: info.ModTime(), size: info.Size(), hash: hash}, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// changed reports whether the executable on disk differs from the one the
+// process was started from. The hash is only computed when the file was
+// touched, so an unchanged mtime and size skip reading the binary.
+func (b binaryInfo) changed() bool {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return true
+	}
+	if info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+		return false
+	}
+	hash, err := hashFile(b.path)
+	return err != nil || hash != b.hash
+}
+
+// ReloadResult describes what a plugin reload changed
+type ReloadResult struct {
+	Loaded    []string          `json:"loaded"`
+	Unloaded  []string          `json:"unloaded"`
+	Restarted []string          `json:"restarted"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// Reload rescans the plugins directory: it starts newly found plugins, stops
+// plugins that were removed, and restarts plugins whose executable changed.
+// Plugins that didn't change keep running.
+func (pm *PluginManager) Reload(ctx context.Context) (*ReloadResult, error) {
+	pm.logger.Info("Reloading plugins", zap.String("plugins_dir", pm.pluginsDir))
+
+	manifests, err := pm.discoverPlugins()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover plugins: %w", err)
+	}
+
+	result := &ReloadResult{
+		Loaded:    []string{},
+		Unloaded:  []string{},
+		Restarted: []string{},
+		Errors:    map[string]string{},
+	}
+
+	found := make(map[string]bool, len(manifests))
+	for _, manifest := range manifests {
+		found[manifest.ID] = true
+		if err := pm.upsertPluginMetadata(ctx, manifest); err != nil {
+			result.Errors[manifest.ID] = err.Error()
+		}
+	}
+
+	// Stop plugins whose directory is gone
+	for _, lp := range pm.ListPlugins() {
+		if !found[lp.Meta.ID] && pm.unloadPlugin(lp.Meta.ID, "removed") {
+			result.Unloaded = append(result.Unloaded, lp.Meta.ID)
+		}
+	}
+
+	enabledPlugins, err := pm.queries.ListEnabledPlugins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enabled plugins: %w", err)
+	}
+
+	for _, dbPlugin := range enabledPlugins {
+		id := dbPlugin.ID
+		if !found[id] {
+			continue
+		}
+
+		lp, loaded := pm.GetPlugin(id)
+		if loaded && !lp.binary.changed() {
+			continue
+		}
+
+		if loaded {
+			pm.unloadPlugin(id, "upgraded")
+		}
+		if err := pm.loadPlugin(ctx, id); err != nil {
+			pm.logger.Error("Failed to load plugin",
+				zap.String("plugin_id", id),
+				zap.Error(err))
+			pm.recordStartFailed(id, err)
+			result.Errors[id] = err.Error()
+			continue
+		}
+
+		if loaded {
+			result.Restarted = append(result.Restarted, id)
+		} else {
+			result.Loaded = append(result.Loaded, id)
+		}
+	}
+
+	pm.logger.Info("Plugins reloaded",
+		zap.Strings("loaded", result.Loaded),
+		zap.Strings("unloaded", result.Unloaded),
+		zap.Strings("restarted", result.Restarted),
+		zap.Int("errors", len(result.Errors)))
+
+	return result, nil
+}
+
+// UnloadPlugin stops a running plugin and disables it, so that it stays
+// stopped across reloads and server restarts until it is enabled again
+func (pm *PluginManager) UnloadPlugin(ctx context.Context, id string) error {
+	if _, ok := pm.GetPlugin(id); !ok {
+		return ErrPluginNotLoaded
+	}
+
+	if err := pm.queries.DisablePlugin(ctx, id); err != nil {
+		return fmt.Errorf("failed to disable plugin in database: %w", err)
+	}
+
+	pm.unloadPlugin(id, "unloaded")
+	return nil
+}
+
+// unloadPlugin stops routing requests to a plugin, waits for in-flight
+// requests to finish, and kills its process. It returns false when the plugin
+// wasn't running.
+func (pm *PluginManager) unloadPlugin(id, reason string) bool {
+	pm.mu.Lock()
+	lp, ok := pm.plugins[id]
+	delete(pm.plugins, id)
+	pm.mu.Unlock()
+
+	pm.forgetHealth(id)
+	if !ok {
+		return false
+	}
+
+	pm.logger.Info("Unloading plugin",
+		zap.String("plugin_id", id),
+		zap.String("reason", reason))
+
+	if !drain(lp, drainTimeout) {
+		pm.logger.Warn("Plugin still had requests in flight when it was stopped",
+			zap.String("plugin_id", id),
+			zap.Int64("requests", lp.inflight.Load()))
+	}
+	if lp.RawClient != nil {
+		lp.RawClient.Kill()
+	}
+
+	pm.events.Publish(EventPluginUnloaded, map[string]interface{}{
+		"plugin_id": id,
+		"reason":    reason,
+	})
+	return true
+}
+
+// drain waits until a plugin has no requests in flight, returning false if
+// the timeout elapsed first
+func drain(lp *LoadedPlugin, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for lp.inflight.Load() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/blakestevenson/nimbus/internal/configstore"
 	"github.com/blakestevenson/nimbus/internal/db/generated"
@@ -25,6 +26,9 @@ type LoadedPlugin struct {
 	IsIndexer    bool           // Whether this plugin provides indexer functionality
 	IsDownloader bool           // Whether this plugin provides downloader functionality
 	RawClient    *plugin.Client // The underlying go-plugin client
+
+	binary   binaryInfo   // The executable the process was started from
+	inflight atomic.Int64 // API requests currently being handled
 }
 
 // PluginManager manages the lifecycle of plugins
@@ -34,9 +38,18 @@ type PluginManager struct {
 	logger      *zap.Logger
 	pluginsDir  string
 	sdk         *SDK
+	events      *EventBus
 
 	mu      sync.RWMutex
 	plugins map[string]*LoadedPlugin
+
+	healthMu       sync.Mutex
+	health         map[string]*PluginHealth
+	healthHandlers []HealthHandler
+	stopSupervisor context.CancelFunc
+
+	// internalAPI is passed to plugin processes so they can call the host API
+	internalAPI InternalAPI
 }
 
 // PluginManifest is the manifest.json file in each plugin directory
@@ -57,14 +70,50 @@ func NewPluginManager(
 	logger *zap.Logger,
 	pluginsDir string,
 ) *PluginManager {
-	return &PluginManager{
+	pm := &PluginManager{
 		queries:     queries,
 		configStore: configStore,
 		logger:      logger.With(zap.String("component", "plugin-manager")),
 		pluginsDir:  pluginsDir,
 		sdk:         NewSDK(queries, configStore, logger),
 		plugins:     make(map[string]*LoadedPlugin),
+		health:      make(map[string]*PluginHealth),
+	}
+	pm.events = newEventBus(pm, logger)
+	if configStore != nil {
+		configStore.Subscribe("plugins.", pm.configChanged)
 	}
+	return pm
+}
+
+// SetInternalAPI sets the address and token plugins are started with to call
+// back into the host API. It must be called before Initialize.
+func (pm *PluginManager) SetInternalAPI(baseURL, token string) error {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if err := validateInternalURL(baseURL); err != nil {
+		return err
+	}
+	pm.internalAPI = InternalAPI{BaseURL: baseURL, Token: token}
+	return nil
+}
+
+// InternalAPI returns where plugins reach the host API, defaulting to the
+// default port on localhost
+func (pm *PluginManager) InternalAPI() InternalAPI {
+	if pm == nil || pm.internalAPI.BaseURL == "" {
+		return InternalAPI{BaseURL: "http://localhost:8080"}
+	}
+	return pm.internalAPI
+}
+
+// Events returns the bus host events are published to plugins on. It is nil
+// for a nil manager, so callers can publish without checking whether plugins
+// are enabled.
+func (pm *PluginManager) Events() *EventBus {
+	if pm == nil {
+		return nil
+	}
+	return pm.events
 }
 
 // Initialize discovers and loads all enabled plugins
@@ -104,16 +153,27 @@ func (pm *PluginManager) Initialize(ctx context.Context) error {
 			pm.logger.Error("Failed to load plugin",
 				zap.String("plugin_id", dbPlugin.ID),
 				zap.Error(err))
+			pm.recordStartFailed(dbPlugin.ID, err)
 			continue
 		}
 	}
 
+	// Watch plugin processes and restart the ones that die
+	supervisorCtx, cancel := context.WithCancel(context.Background())
+	pm.stopSupervisor = cancel
+	go pm.supervise(supervisorCtx)
+
 	pm.logger.Info("Plugin manager initialized", zap.Int("loaded", len(pm.plugins)))
 	return nil
 }
 
 // Shutdown stops all running plugins
 func (pm *PluginManager) Shutdown() {
+	// Stop supervising first, so stopped plugins aren't restarted
+	if pm.stopSupervisor != nil {
+		pm.stopSupervisor()
+	}
+
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -151,14 +211,14 @@ func (pm *PluginManager) GetPlugin(id string) (*LoadedPlugin, bool) {
 	return lp, ok
 }
 
-// ListIndexerPlugins returns all loaded plugins that provide indexer functionality
+// ListIndexerPlugins returns all running plugins that provide indexer functionality
 func (pm *PluginManager) ListIndexerPlugins() []*LoadedPlugin {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
 	indexers := make([]*LoadedPlugin, 0)
-	for _, lp := range pm.plugins {
-		if lp.IsIndexer {
+	for id, lp := range pm.plugins {
+		if lp.IsIndexer && pm.isAvailable(id) {
 			indexers = append(indexers, lp)
 		}
 	}
@@ -166,14 +226,14 @@ func (pm *PluginManager) ListIndexerPlugins() []*LoadedPlugin {
 	return indexers
 }
 
-// ListDownloaderPlugins returns all loaded plugins that provide downloader functionality
+// ListDownloaderPlugins returns all running plugins that provide downloader functionality
 func (pm *PluginManager) ListDownloaderPlugins() []*LoadedPlugin {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
 	downloaders := make([]*LoadedPlugin, 0)
-	for _, lp := range pm.plugins {
-		if lp.IsDownloader {
+	for id, lp := range pm.plugins {
+		if lp.IsDownloader && pm.isAvailable(id) {
 			downloaders = append(downloaders, lp)
 		}
 	}
@@ -204,15 +264,7 @@ func (pm *PluginManager) DisablePlugin(ctx context.Context, id string) error {
 	}
 
 	// Unload the plugin
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	if lp, ok := pm.plugins[id]; ok {
-		if lp.RawClient != nil {
-			lp.RawClient.Kill()
-		}
-		delete(pm.plugins, id)
-	}
+	pm.unloadPlugin(id, "disabled")
 
 	return nil
 }
@@ -279,30 +331,46 @@ func (pm *PluginManager) upsertPluginMetadata(ctx context.Context, manifest Plug
 
 // loadPlugin starts a plugin process and loads its metadata
 func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
+	lp, err := pm.startPlugin(ctx, id)
+	if err != nil || lp == nil {
+		return err
+	}
+
+	pm.events.Publish(EventPluginLoaded, map[string]interface{}{
+		"plugin_id": id,
+		"name":      lp.Meta.Name,
+		"version":   lp.Meta.Version,
+	})
+	return nil
+}
+
+// startPlugin starts a plugin process, returning nil if it is already running
+func (pm *PluginManager) startPlugin(ctx context.Context, id string) (*LoadedPlugin, error) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
 	// Check if already loaded
 	if _, ok := pm.plugins[id]; ok {
-		return nil // Already loaded
+		return nil, nil // Already loaded
 	}
 
 	// Find manifest
 	manifestPath := filepath.Join(pm.pluginsDir, id, "manifest.json")
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
-		return fmt.Errorf("failed to read manifest: %w", err)
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
 	}
 
 	var manifest PluginManifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
-		return fmt.Errorf("failed to parse manifest: %w", err)
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
 	}
 
 	// Build path to executable
 	execPath := filepath.Join(pm.pluginsDir, id, manifest.Executable)
-	if _, err := os.Stat(execPath); err != nil {
-		return fmt.Errorf("plugin executable not found: %w", err)
+	binary, err := statBinary(execPath)
+	if err != nil {
+		return nil, fmt.Errorf("plugin executable not found: %w", err)
 	}
 
 	pm.logger.Info("Starting plugin process",
@@ -316,10 +384,13 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 		},
 	}
 
+	cmd := exec.Command(execPath)
+	cmd.Env = append(os.Environ(), pm.InternalAPI().environ()...)
+
 	client := plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig: Handshake,
 		Plugins:         pluginMap,
-		Cmd:             exec.Command(execPath),
+		Cmd:             cmd,
 		AllowedProtocols: []plugin.Protocol{
 			plugin.ProtocolGRPC,
 		},
@@ -331,14 +402,14 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 	rpcClient, err := client.Client()
 	if err != nil {
 		client.Kill()
-		return fmt.Errorf("failed to get RPC client: %w", err)
+		return nil, fmt.Errorf("failed to get RPC client: %w", err)
 	}
 
 	// Request the plugin
 	raw, err := rpcClient.Dispense("media-suite")
 	if err != nil {
 		client.Kill()
-		return fmt.Errorf("failed to dispense plugin: %w", err)
+		return nil, fmt.Errorf("failed to dispense plugin: %w", err)
 	}
 
 	pluginClient := raw.(MediaSuitePlugin)
@@ -347,7 +418,7 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 	meta, err := pluginClient.Metadata(ctx)
 	if err != nil {
 		client.Kill()
-		return fmt.Errorf("failed to get plugin metadata: %w", err)
+		return nil, fmt.Errorf("failed to get plugin metadata: %w", err)
 	}
 
 	// Fetch API routes
@@ -367,6 +438,9 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 		}
 	}
 
+	// Bring the stored config up to the plugin's schema and check it
+	configErrors := pm.checkConfig(ctx, id, pluginClient, uiManifest.ConfigSection)
+
 	// Check if plugin is an indexer
 	isIndexer := false
 	indexerCheck, err := pluginClient.IsIndexer(ctx)
@@ -382,7 +456,7 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 	}
 
 	// Store loaded plugin
-	pm.plugins[id] = &LoadedPlugin{
+	lp := &LoadedPlugin{
 		Meta:         meta,
 		Client:       pluginClient,
 		Routes:       routes,
@@ -390,7 +464,11 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 		IsIndexer:    isIndexer,
 		IsDownloader: isDownloader,
 		RawClient:    client,
+		binary:       binary,
 	}
+	pm.plugins[id] = lp
+	pm.recordStarted(id)
+	pm.recordConfigErrors(id, configErrors)
 
 	pm.logger.Info("Plugin loaded successfully",
 		zap.String("plugin_id", id),
@@ -400,7 +478,7 @@ func (pm *PluginManager) loadPlugin(ctx context.Context, id string) error {
 		zap.Bool("is_indexer", isIndexer),
 		zap.Bool("is_downloader", isDownloader))
 
-	return nil
+	return lp, nil
 }
 
 // GetPluginsDir returns the plugins directory path
@@ -437,6 +515,26 @@ func ConvertDBPluginToJSON(dbPlugin generated.Plugin) map[string]interface{} {
 	}
 }
 
+// addHealthToJSON adds the health of a plugin's process to its JSON form
+func addHealthToJSON(plugin map[string]interface{}, enabled bool, pm *PluginManager) {
+	health, ok := pm.Health(plugin["id"].(string))
+	if !enabled || !ok {
+		plugin["status"] = "stopped"
+		return
+	}
+
+	plugin["status"] = health.Status
+	plugin["last_error"] = health.LastError
+	plugin["restarts"] = health.Restarts
+	if len(health.ConfigErrors) > 0 {
+		plugin["config_errors"] = health.ConfigErrors
+	}
+	plugin["uptime_seconds"] = int64(health.Uptime().Seconds())
+	if !health.StartedAt.IsZero() {
+		plugin["started_at"] = health.StartedAt
+	}
+}
+
 // ServePluginFile serves a static file from a plugin's web directory
 func (pm *PluginManager) ServePluginFile(pluginID, filePath string) (string, error) {
 	// Security: prevent directory traversal
@@ -462,30 +560,6 @@ func (pm *PluginManager) ServePluginFile(pluginID, filePath string) (string, err
 	return realPath, nil
 }
 
-// RegisterRoutes registers plugin API routes with the router
-// This should be called after plugins are loaded
-func (pm *PluginManager) RegisterRoutes(router interface{}, handlers *APIHandlers) {
-	pm.mu.RLock()
-	defer pm.mu.RUnlock()
-
-	// Type assert to chi.Router
-	chiRouter, ok := router.(interface {
-		Method(method, pattern string, h http.HandlerFunc)
-		MethodFunc(method, pattern string, h http.HandlerFunc)
-	})
-	if !ok {
-		pm.logger.Error("Invalid router type provided to RegisterRoutes")
-		return
-	}
-
-	for _, lp := range pm.plugins {
-		for _, route := range lp.Routes {
-			handler := handlers.makePluginAPIHandler(lp, route)
-			chiRouter.Method(route.Method, route.Path, handler)
-		}
-	}
-}
-
 // manifestToUpsertParams helper to convert PluginManifest to upsert params
 func manifestToUpsertParams(manifest PluginManifest, enabled bool) (generated.UpsertPluginParams, error) {
 	capabilities, err := json.Marshal(manifest.Capabilities)
@@ -0,0 +1,39 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// Header returns the first value of a request header, matching its name
+// case-insensitively
+func (r *PluginHTTPRequest) Header(name string) string {
+	for key, values := range r.Headers {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// MultipartForm parses a multipart/form-data request body. File parts beyond
+// maxMemory are spooled to temporary files, which the caller removes with the
+// form's RemoveAll. It returns nil when the request isn't a multipart form.
+func (r *PluginHTTPRequest) MultipartForm(maxMemory int64) (*multipart.Form, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, nil
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("multipart form has no boundary")
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(r.Body), params["boundary"]).ReadForm(maxMemory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+	return form, nil
+}
@@ -258,6 +258,7 @@ type RouteDescriptor struct {
 	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
 	Auth          string                 `protobuf:"bytes,3,opt,name=auth,proto3" json:"auth,omitempty"`
 	Tag           string                 `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
+	Role          string                 `protobuf:"bytes,5,opt,name=role,proto3" json:"role,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -320,6 +321,13 @@ func (x *RouteDescriptor) GetTag() string {
 	return ""
 }
 
+func (x *RouteDescriptor) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
 // Handle API request/response
 type HandleAPIRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -331,6 +339,8 @@ type HandleAPIRequest struct {
 	UserId        *int64                 `protobuf:"varint,6,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
 	Scopes        []string               `protobuf:"bytes,7,rep,name=scopes,proto3" json:"scopes,omitempty"`
 	SdkServerId   uint32                 `protobuf:"varint,8,opt,name=sdk_server_id,json=sdkServerId,proto3" json:"sdk_server_id,omitempty"`
+	PathParams    map[string]string      `protobuf:"bytes,9,rep,name=path_params,json=pathParams,proto3" json:"path_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Placeholders of the matched route pattern
+	Route         string                 `protobuf:"bytes,10,opt,name=route,proto3" json:"route,omitempty"`                                                                                                      // Pattern of the matched route
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -421,6 +431,20 @@ func (x *HandleAPIRequest) GetSdkServerId() uint32 {
 	return 0
 }
 
+func (x *HandleAPIRequest) GetPathParams() map[string]string {
+	if x != nil {
+		return x.PathParams
+	}
+	return nil
+}
+
+func (x *HandleAPIRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
 type StringList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Values        []string               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
@@ -712,6 +736,7 @@ type ConfigSection struct {
 	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
 	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
 	Fields        []*ConfigField         `protobuf:"bytes,3,rep,name=fields,proto3" json:"fields,omitempty"`
+	SchemaVersion int32                  `protobuf:"varint,4,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -767,6 +792,13 @@ func (x *ConfigSection) GetFields() []*ConfigField {
 	return nil
 }
 
+func (x *ConfigSection) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
 type ConfigField struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
@@ -949,6 +981,7 @@ type HandleEventRequest struct {
 	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
 	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // JSON-encoded map
 	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	SdkServerId   uint32                 `protobuf:"varint,4,opt,name=sdk_server_id,json=sdkServerId,proto3" json:"sdk_server_id,omitempty"` // Broker ID for SDK server (for plugin->host calls)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1004,6 +1037,13 @@ func (x *HandleEventRequest) GetTimestamp() int64 {
 	return 0
 }
 
+func (x *HandleEventRequest) GetSdkServerId() uint32 {
+	if x != nil {
+		return x.SdkServerId
+	}
+	return 0
+}
+
 type HandleEventResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -1433,27 +1473,28 @@ func (x *ConfigDeleteResponse) GetError() string {
 	return ""
 }
 
-// Indexer methods
-type IsIndexerRequest struct {
+// SDK Secret methods. Secrets are encrypted at rest by the host.
+type ConfigGetSecretRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IsIndexerRequest) Reset() {
-	*x = IsIndexerRequest{}
+func (x *ConfigGetSecretRequest) Reset() {
+	*x = ConfigGetSecretRequest{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IsIndexerRequest) String() string {
+func (x *ConfigGetSecretRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IsIndexerRequest) ProtoMessage() {}
+func (*ConfigGetSecretRequest) ProtoMessage() {}
 
-func (x *IsIndexerRequest) ProtoReflect() protoreflect.Message {
+func (x *ConfigGetSecretRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1465,33 +1506,40 @@ func (x *IsIndexerRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IsIndexerRequest.ProtoReflect.Descriptor instead.
-func (*IsIndexerRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ConfigGetSecretRequest.ProtoReflect.Descriptor instead.
+func (*ConfigGetSecretRequest) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{25}
 }
 
-type IsIndexerResponse struct {
+func (x *ConfigGetSecretRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type ConfigGetSecretResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	IsIndexer     bool                   `protobuf:"varint,1,opt,name=is_indexer,json=isIndexer,proto3" json:"is_indexer,omitempty"`
+	Value         []byte                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"` // JSON-encoded value, empty when the secret does not exist
 	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IsIndexerResponse) Reset() {
-	*x = IsIndexerResponse{}
+func (x *ConfigGetSecretResponse) Reset() {
+	*x = ConfigGetSecretResponse{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IsIndexerResponse) String() string {
+func (x *ConfigGetSecretResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IsIndexerResponse) ProtoMessage() {}
+func (*ConfigGetSecretResponse) ProtoMessage() {}
 
-func (x *IsIndexerResponse) ProtoReflect() protoreflect.Message {
+func (x *ConfigGetSecretResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1503,46 +1551,47 @@ func (x *IsIndexerResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IsIndexerResponse.ProtoReflect.Descriptor instead.
-func (*IsIndexerResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use ConfigGetSecretResponse.ProtoReflect.Descriptor instead.
+func (*ConfigGetSecretResponse) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *IsIndexerResponse) GetIsIndexer() bool {
+func (x *ConfigGetSecretResponse) GetValue() []byte {
 	if x != nil {
-		return x.IsIndexer
+		return x.Value
 	}
-	return false
+	return nil
 }
 
-func (x *IsIndexerResponse) GetError() string {
+func (x *ConfigGetSecretResponse) GetError() string {
 	if x != nil {
 		return x.Error
 	}
 	return ""
 }
 
-// Downloader methods
-type IsDownloaderRequest struct {
+type ConfigSetSecretRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"` // JSON-encoded value
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IsDownloaderRequest) Reset() {
-	*x = IsDownloaderRequest{}
+func (x *ConfigSetSecretRequest) Reset() {
+	*x = ConfigSetSecretRequest{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IsDownloaderRequest) String() string {
+func (x *ConfigSetSecretRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IsDownloaderRequest) ProtoMessage() {}
+func (*ConfigSetSecretRequest) ProtoMessage() {}
 
-func (x *IsDownloaderRequest) ProtoReflect() protoreflect.Message {
+func (x *ConfigSetSecretRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1554,33 +1603,46 @@ func (x *IsDownloaderRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IsDownloaderRequest.ProtoReflect.Descriptor instead.
-func (*IsDownloaderRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ConfigSetSecretRequest.ProtoReflect.Descriptor instead.
+func (*ConfigSetSecretRequest) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{27}
 }
 
-type IsDownloaderResponse struct {
+func (x *ConfigSetSecretRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ConfigSetSecretRequest) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type ConfigSetSecretResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	IsDownloader  bool                   `protobuf:"varint,1,opt,name=is_downloader,json=isDownloader,proto3" json:"is_downloader,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IsDownloaderResponse) Reset() {
-	*x = IsDownloaderResponse{}
+func (x *ConfigSetSecretResponse) Reset() {
+	*x = ConfigSetSecretResponse{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IsDownloaderResponse) String() string {
+func (x *ConfigSetSecretResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IsDownloaderResponse) ProtoMessage() {}
+func (*ConfigSetSecretResponse) ProtoMessage() {}
 
-func (x *IsDownloaderResponse) ProtoReflect() protoreflect.Message {
+func (x *ConfigSetSecretResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1592,56 +1654,48 @@ func (x *IsDownloaderResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IsDownloaderResponse.ProtoReflect.Descriptor instead.
-func (*IsDownloaderResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use ConfigSetSecretResponse.ProtoReflect.Descriptor instead.
+func (*ConfigSetSecretResponse) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *IsDownloaderResponse) GetIsDownloader() bool {
-	if x != nil {
-		return x.IsDownloader
-	}
-	return false
-}
-
-func (x *IsDownloaderResponse) GetError() string {
+func (x *ConfigSetSecretResponse) GetError() string {
 	if x != nil {
 		return x.Error
 	}
 	return ""
 }
 
-type IndexerSearchRequest struct {
+// SDK Media methods
+type MediaItem struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
-	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
-	Categories    []string               `protobuf:"bytes,3,rep,name=categories,proto3" json:"categories,omitempty"`
-	Tvdbid        string                 `protobuf:"bytes,4,opt,name=tvdbid,proto3" json:"tvdbid,omitempty"`
-	Tvrageid      string                 `protobuf:"bytes,5,opt,name=tvrageid,proto3" json:"tvrageid,omitempty"`
-	Season        int32                  `protobuf:"varint,6,opt,name=season,proto3" json:"season,omitempty"`
-	Episode       int32                  `protobuf:"varint,7,opt,name=episode,proto3" json:"episode,omitempty"`
-	Imdbid        string                 `protobuf:"bytes,8,opt,name=imdbid,proto3" json:"imdbid,omitempty"`
-	Tmdbid        string                 `protobuf:"bytes,9,opt,name=tmdbid,proto3" json:"tmdbid,omitempty"`
-	Limit         int32                  `protobuf:"varint,10,opt,name=limit,proto3" json:"limit,omitempty"`
-	Offset        int32                  `protobuf:"varint,11,opt,name=offset,proto3" json:"offset,omitempty"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Year          *int32                 `protobuf:"varint,4,opt,name=year,proto3,oneof" json:"year,omitempty"`
+	Metadata      []byte                 `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`                          // JSON-encoded map
+	ExternalIds   []byte                 `protobuf:"bytes,6,opt,name=external_ids,json=externalIds,proto3" json:"external_ids,omitempty"` // JSON-encoded map
+	ParentId      *int64                 `protobuf:"varint,7,opt,name=parent_id,json=parentId,proto3,oneof" json:"parent_id,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // Unix timestamp
+	UpdatedAt     int64                  `protobuf:"varint,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix timestamp
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IndexerSearchRequest) Reset() {
-	*x = IndexerSearchRequest{}
+func (x *MediaItem) Reset() {
+	*x = MediaItem{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IndexerSearchRequest) String() string {
+func (x *MediaItem) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IndexerSearchRequest) ProtoMessage() {}
+func (*MediaItem) ProtoMessage() {}
 
-func (x *IndexerSearchRequest) ProtoReflect() protoreflect.Message {
+func (x *MediaItem) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1653,113 +1707,95 @@ func (x *IndexerSearchRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IndexerSearchRequest.ProtoReflect.Descriptor instead.
-func (*IndexerSearchRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use MediaItem.ProtoReflect.Descriptor instead.
+func (*MediaItem) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *IndexerSearchRequest) GetQuery() string {
-	if x != nil {
-		return x.Query
-	}
-	return ""
-}
-
-func (x *IndexerSearchRequest) GetType() string {
-	if x != nil {
-		return x.Type
-	}
-	return ""
-}
-
-func (x *IndexerSearchRequest) GetCategories() []string {
+func (x *MediaItem) GetId() int64 {
 	if x != nil {
-		return x.Categories
+		return x.Id
 	}
-	return nil
+	return 0
 }
 
-func (x *IndexerSearchRequest) GetTvdbid() string {
+func (x *MediaItem) GetKind() string {
 	if x != nil {
-		return x.Tvdbid
+		return x.Kind
 	}
 	return ""
 }
 
-func (x *IndexerSearchRequest) GetTvrageid() string {
+func (x *MediaItem) GetTitle() string {
 	if x != nil {
-		return x.Tvrageid
+		return x.Title
 	}
 	return ""
 }
 
-func (x *IndexerSearchRequest) GetSeason() int32 {
-	if x != nil {
-		return x.Season
+func (x *MediaItem) GetYear() int32 {
+	if x != nil && x.Year != nil {
+		return *x.Year
 	}
 	return 0
 }
 
-func (x *IndexerSearchRequest) GetEpisode() int32 {
+func (x *MediaItem) GetMetadata() []byte {
 	if x != nil {
-		return x.Episode
+		return x.Metadata
 	}
-	return 0
+	return nil
 }
 
-func (x *IndexerSearchRequest) GetImdbid() string {
+func (x *MediaItem) GetExternalIds() []byte {
 	if x != nil {
-		return x.Imdbid
+		return x.ExternalIds
 	}
-	return ""
+	return nil
 }
 
-func (x *IndexerSearchRequest) GetTmdbid() string {
-	if x != nil {
-		return x.Tmdbid
+func (x *MediaItem) GetParentId() int64 {
+	if x != nil && x.ParentId != nil {
+		return *x.ParentId
 	}
-	return ""
+	return 0
 }
 
-func (x *IndexerSearchRequest) GetLimit() int32 {
+func (x *MediaItem) GetCreatedAt() int64 {
 	if x != nil {
-		return x.Limit
+		return x.CreatedAt
 	}
 	return 0
 }
 
-func (x *IndexerSearchRequest) GetOffset() int32 {
+func (x *MediaItem) GetUpdatedAt() int64 {
 	if x != nil {
-		return x.Offset
+		return x.UpdatedAt
 	}
 	return 0
 }
 
-type IndexerSearchResponse struct {
+type MediaGetRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Releases      []*IndexerRelease      `protobuf:"bytes,1,rep,name=releases,proto3" json:"releases,omitempty"`
-	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
-	IndexerId     string                 `protobuf:"bytes,3,opt,name=indexer_id,json=indexerId,proto3" json:"indexer_id,omitempty"`
-	IndexerName   string                 `protobuf:"bytes,4,opt,name=indexer_name,json=indexerName,proto3" json:"indexer_name,omitempty"`
-	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IndexerSearchResponse) Reset() {
-	*x = IndexerSearchResponse{}
+func (x *MediaGetRequest) Reset() {
+	*x = MediaGetRequest{}
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IndexerSearchResponse) String() string {
+func (x *MediaGetRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IndexerSearchResponse) ProtoMessage() {}
+func (*MediaGetRequest) ProtoMessage() {}
 
-func (x *IndexerSearchResponse) ProtoReflect() protoreflect.Message {
+func (x *MediaGetRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1771,79 +1807,93 @@ func (x *IndexerSearchResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IndexerSearchResponse.ProtoReflect.Descriptor instead.
-func (*IndexerSearchResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use MediaGetRequest.ProtoReflect.Descriptor instead.
+func (*MediaGetRequest) Descriptor() ([]byte, []int) {
 	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *IndexerSearchResponse) GetReleases() []*IndexerRelease {
+func (x *MediaGetRequest) GetId() int64 {
 	if x != nil {
-		return x.Releases
+		return x.Id
 	}
-	return nil
+	return 0
 }
 
-func (x *IndexerSearchResponse) GetTotal() int32 {
-	if x != nil {
-		return x.Total
-	}
-	return 0
+type MediaGetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Item          *MediaItem             `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IndexerSearchResponse) GetIndexerId() string {
+func (x *MediaGetResponse) Reset() {
+	*x = MediaGetResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaGetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaGetResponse) ProtoMessage() {}
+
+func (x *MediaGetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[31]
 	if x != nil {
-		return x.IndexerId
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *IndexerSearchResponse) GetIndexerName() string {
+// Deprecated: Use MediaGetResponse.ProtoReflect.Descriptor instead.
+func (*MediaGetResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *MediaGetResponse) GetItem() *MediaItem {
 	if x != nil {
-		return x.IndexerName
+		return x.Item
 	}
-	return ""
+	return nil
 }
 
-func (x *IndexerSearchResponse) GetError() string {
+func (x *MediaGetResponse) GetError() string {
 	if x != nil {
 		return x.Error
 	}
 	return ""
 }
 
-type IndexerRelease struct {
+type MediaListRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Guid          string                 `protobuf:"bytes,1,opt,name=guid,proto3" json:"guid,omitempty"`
-	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
-	Link          string                 `protobuf:"bytes,3,opt,name=link,proto3" json:"link,omitempty"`
-	Comments      string                 `protobuf:"bytes,4,opt,name=comments,proto3" json:"comments,omitempty"`
-	PublishDate   int64                  `protobuf:"varint,5,opt,name=publish_date,json=publishDate,proto3" json:"publish_date,omitempty"` // Unix timestamp
-	Category      string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
-	Size          int64                  `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
-	DownloadUrl   string                 `protobuf:"bytes,8,opt,name=download_url,json=downloadUrl,proto3" json:"download_url,omitempty"`
-	Description   string                 `protobuf:"bytes,9,opt,name=description,proto3" json:"description,omitempty"`
-	Attributes    map[string]string      `protobuf:"bytes,10,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	IndexerId     string                 `protobuf:"bytes,11,opt,name=indexer_id,json=indexerId,proto3" json:"indexer_id,omitempty"`
-	IndexerName   string                 `protobuf:"bytes,12,opt,name=indexer_name,json=indexerName,proto3" json:"indexer_name,omitempty"`
+	ParentId      int64                  `protobuf:"varint,1,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"` // 0 lists items regardless of parent
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`                          // Empty lists items of every kind
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IndexerRelease) Reset() {
-	*x = IndexerRelease{}
-	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[31]
+func (x *MediaListRequest) Reset() {
+	*x = MediaListRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *IndexerRelease) String() string {
+func (x *MediaListRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*IndexerRelease) ProtoMessage() {}
+func (*MediaListRequest) ProtoMessage() {}
 
-func (x *IndexerRelease) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[31]
+func (x *MediaListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1854,47 +1904,1443 @@ func (x *IndexerRelease) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use IndexerRelease.ProtoReflect.Descriptor instead.
-func (*IndexerRelease) Descriptor() ([]byte, []int) {
-	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{31}
+// Deprecated: Use MediaListRequest.ProtoReflect.Descriptor instead.
+func (*MediaListRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *IndexerRelease) GetGuid() string {
+func (x *MediaListRequest) GetParentId() int64 {
 	if x != nil {
-		return x.Guid
+		return x.ParentId
 	}
-	return ""
+	return 0
 }
 
-func (x *IndexerRelease) GetTitle() string {
+func (x *MediaListRequest) GetKind() string {
 	if x != nil {
-		return x.Title
+		return x.Kind
 	}
 	return ""
 }
 
-func (x *IndexerRelease) GetLink() string {
-	if x != nil {
-		return x.Link
-	}
-	return ""
+type MediaListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*MediaItem           `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *IndexerRelease) GetComments() string {
-	if x != nil {
-		return x.Comments
-	}
-	return ""
+func (x *MediaListResponse) Reset() {
+	*x = MediaListResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *IndexerRelease) GetPublishDate() int64 {
-	if x != nil {
-		return x.PublishDate
-	}
-	return 0
+func (x *MediaListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (x *IndexerRelease) GetCategory() string {
+func (*MediaListResponse) ProtoMessage() {}
+
+func (x *MediaListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaListResponse.ProtoReflect.Descriptor instead.
+func (*MediaListResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *MediaListResponse) GetItems() []*MediaItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *MediaListResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type MediaUpdateMetadataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Metadata      []byte                 `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"`                          // JSON-encoded map merged into the item's metadata
+	ExternalIds   []byte                 `protobuf:"bytes,3,opt,name=external_ids,json=externalIds,proto3" json:"external_ids,omitempty"` // JSON-encoded map merged into the item's external IDs
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaUpdateMetadataRequest) Reset() {
+	*x = MediaUpdateMetadataRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaUpdateMetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaUpdateMetadataRequest) ProtoMessage() {}
+
+func (x *MediaUpdateMetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaUpdateMetadataRequest.ProtoReflect.Descriptor instead.
+func (*MediaUpdateMetadataRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *MediaUpdateMetadataRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *MediaUpdateMetadataRequest) GetMetadata() []byte {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *MediaUpdateMetadataRequest) GetExternalIds() []byte {
+	if x != nil {
+		return x.ExternalIds
+	}
+	return nil
+}
+
+type MediaUpdateMetadataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Item          *MediaItem             `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaUpdateMetadataResponse) Reset() {
+	*x = MediaUpdateMetadataResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaUpdateMetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaUpdateMetadataResponse) ProtoMessage() {}
+
+func (x *MediaUpdateMetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaUpdateMetadataResponse.ProtoReflect.Descriptor instead.
+func (*MediaUpdateMetadataResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *MediaUpdateMetadataResponse) GetItem() *MediaItem {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *MediaUpdateMetadataResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type MediaAddExtraFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MediaItemId   int64                  `protobuf:"varint,1,opt,name=media_item_id,json=mediaItemId,proto3" json:"media_item_id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"` // e.g. "subtitle"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaAddExtraFileRequest) Reset() {
+	*x = MediaAddExtraFileRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaAddExtraFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaAddExtraFileRequest) ProtoMessage() {}
+
+func (x *MediaAddExtraFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaAddExtraFileRequest.ProtoReflect.Descriptor instead.
+func (*MediaAddExtraFileRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *MediaAddExtraFileRequest) GetMediaItemId() int64 {
+	if x != nil {
+		return x.MediaItemId
+	}
+	return 0
+}
+
+func (x *MediaAddExtraFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *MediaAddExtraFileRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+type MediaAddExtraFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaAddExtraFileResponse) Reset() {
+	*x = MediaAddExtraFileResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaAddExtraFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaAddExtraFileResponse) ProtoMessage() {}
+
+func (x *MediaAddExtraFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaAddExtraFileResponse.ProtoReflect.Descriptor instead.
+func (*MediaAddExtraFileResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *MediaAddExtraFileResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type MediaFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MediaItemId   int64                  `protobuf:"varint,1,opt,name=media_item_id,json=mediaItemId,proto3" json:"media_item_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaFilesRequest) Reset() {
+	*x = MediaFilesRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaFilesRequest) ProtoMessage() {}
+
+func (x *MediaFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaFilesRequest.ProtoReflect.Descriptor instead.
+func (*MediaFilesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *MediaFilesRequest) GetMediaItemId() int64 {
+	if x != nil {
+		return x.MediaItemId
+	}
+	return 0
+}
+
+type MediaFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`     // "media", or "subtitle" for extra files
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"` // Verification status, "ok" once verified
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaFile) Reset() {
+	*x = MediaFile{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaFile) ProtoMessage() {}
+
+func (x *MediaFile) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaFile.ProtoReflect.Descriptor instead.
+func (*MediaFile) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *MediaFile) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *MediaFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *MediaFile) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *MediaFile) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type MediaFilesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*MediaFile           `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MediaFilesResponse) Reset() {
+	*x = MediaFilesResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MediaFilesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MediaFilesResponse) ProtoMessage() {}
+
+func (x *MediaFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MediaFilesResponse.ProtoReflect.Descriptor instead.
+func (*MediaFilesResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *MediaFilesResponse) GetFiles() []*MediaFile {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *MediaFilesResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// SDK Download methods
+type DownloadSyncRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Download      []byte                 `protobuf:"bytes,1,opt,name=download,proto3" json:"download,omitempty"`   // JSON-encoded download, as stored in the downloads table
+	Downloads     []byte                 `protobuf:"bytes,2,opt,name=downloads,proto3" json:"downloads,omitempty"` // JSON-encoded array of downloads, synced together instead
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadSyncRequest) Reset() {
+	*x = DownloadSyncRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadSyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadSyncRequest) ProtoMessage() {}
+
+func (x *DownloadSyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadSyncRequest.ProtoReflect.Descriptor instead.
+func (*DownloadSyncRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *DownloadSyncRequest) GetDownload() []byte {
+	if x != nil {
+		return x.Download
+	}
+	return nil
+}
+
+func (x *DownloadSyncRequest) GetDownloads() []byte {
+	if x != nil {
+		return x.Downloads
+	}
+	return nil
+}
+
+type DownloadSyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadSyncResponse) Reset() {
+	*x = DownloadSyncResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadSyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadSyncResponse) ProtoMessage() {}
+
+func (x *DownloadSyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadSyncResponse.ProtoReflect.Descriptor instead.
+func (*DownloadSyncResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *DownloadSyncResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// SDK Import methods
+type ImportFileRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	DownloadId        string                 `protobuf:"bytes,1,opt,name=download_id,json=downloadId,proto3" json:"download_id,omitempty"`
+	SourcePath        string                 `protobuf:"bytes,2,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"`
+	MediaItemId       int64                  `protobuf:"varint,3,opt,name=media_item_id,json=mediaItemId,proto3" json:"media_item_id,omitempty"`
+	Quality           string                 `protobuf:"bytes,4,opt,name=quality,proto3" json:"quality,omitempty"`
+	ReleaseGroup      string                 `protobuf:"bytes,5,opt,name=release_group,json=releaseGroup,proto3" json:"release_group,omitempty"`
+	ReleaseTitle      string                 `protobuf:"bytes,6,opt,name=release_title,json=releaseTitle,proto3" json:"release_title,omitempty"`
+	ExtraMediaItemIds []int64                `protobuf:"varint,7,rep,packed,name=extra_media_item_ids,json=extraMediaItemIds,proto3" json:"extra_media_item_ids,omitempty"` // Further episodes of a multi-episode file
+	TransferMode      string                 `protobuf:"bytes,8,opt,name=transfer_mode,json=transferMode,proto3" json:"transfer_mode,omitempty"`                            // "move", "copy", "hardlink" or "auto"
+	Disposable        bool                   `protobuf:"varint,9,opt,name=disposable,proto3" json:"disposable,omitempty"`                                                   // The source may be moved in auto mode
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ImportFileRequest) Reset() {
+	*x = ImportFileRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportFileRequest) ProtoMessage() {}
+
+func (x *ImportFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportFileRequest.ProtoReflect.Descriptor instead.
+func (*ImportFileRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *ImportFileRequest) GetDownloadId() string {
+	if x != nil {
+		return x.DownloadId
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetSourcePath() string {
+	if x != nil {
+		return x.SourcePath
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetMediaItemId() int64 {
+	if x != nil {
+		return x.MediaItemId
+	}
+	return 0
+}
+
+func (x *ImportFileRequest) GetQuality() string {
+	if x != nil {
+		return x.Quality
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetReleaseGroup() string {
+	if x != nil {
+		return x.ReleaseGroup
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetReleaseTitle() string {
+	if x != nil {
+		return x.ReleaseTitle
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetExtraMediaItemIds() []int64 {
+	if x != nil {
+		return x.ExtraMediaItemIds
+	}
+	return nil
+}
+
+func (x *ImportFileRequest) GetTransferMode() string {
+	if x != nil {
+		return x.TransferMode
+	}
+	return ""
+}
+
+func (x *ImportFileRequest) GetDisposable() bool {
+	if x != nil {
+		return x.Disposable
+	}
+	return false
+}
+
+type ImportFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FinalPath     string                 `protobuf:"bytes,1,opt,name=final_path,json=finalPath,proto3" json:"final_path,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Retryable     bool                   `protobuf:"varint,3,opt,name=retryable,proto3" json:"retryable,omitempty"` // The file may still be written to; try again later
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportFileResponse) Reset() {
+	*x = ImportFileResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportFileResponse) ProtoMessage() {}
+
+func (x *ImportFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportFileResponse.ProtoReflect.Descriptor instead.
+func (*ImportFileResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ImportFileResponse) GetFinalPath() string {
+	if x != nil {
+		return x.FinalPath
+	}
+	return ""
+}
+
+func (x *ImportFileResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ImportFileResponse) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
+type ImportFailedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DownloadId    string                 `protobuf:"bytes,1,opt,name=download_id,json=downloadId,proto3" json:"download_id,omitempty"`
+	SourcePath    string                 `protobuf:"bytes,2,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Detected      []byte                 `protobuf:"bytes,4,opt,name=detected,proto3" json:"detected,omitempty"` // JSON-encoded attributes detected from the file
+	MediaItemId   *int64                 `protobuf:"varint,5,opt,name=media_item_id,json=mediaItemId,proto3,oneof" json:"media_item_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportFailedRequest) Reset() {
+	*x = ImportFailedRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportFailedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportFailedRequest) ProtoMessage() {}
+
+func (x *ImportFailedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportFailedRequest.ProtoReflect.Descriptor instead.
+func (*ImportFailedRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ImportFailedRequest) GetDownloadId() string {
+	if x != nil {
+		return x.DownloadId
+	}
+	return ""
+}
+
+func (x *ImportFailedRequest) GetSourcePath() string {
+	if x != nil {
+		return x.SourcePath
+	}
+	return ""
+}
+
+func (x *ImportFailedRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ImportFailedRequest) GetDetected() []byte {
+	if x != nil {
+		return x.Detected
+	}
+	return nil
+}
+
+func (x *ImportFailedRequest) GetMediaItemId() int64 {
+	if x != nil && x.MediaItemId != nil {
+		return *x.MediaItemId
+	}
+	return 0
+}
+
+type ImportFailedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportFailedResponse) Reset() {
+	*x = ImportFailedResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportFailedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportFailedResponse) ProtoMessage() {}
+
+func (x *ImportFailedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportFailedResponse.ProtoReflect.Descriptor instead.
+func (*ImportFailedResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ImportFailedResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Indexer methods
+type IsIndexerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsIndexerRequest) Reset() {
+	*x = IsIndexerRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsIndexerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsIndexerRequest) ProtoMessage() {}
+
+func (x *IsIndexerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsIndexerRequest.ProtoReflect.Descriptor instead.
+func (*IsIndexerRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{47}
+}
+
+type IsIndexerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsIndexer     bool                   `protobuf:"varint,1,opt,name=is_indexer,json=isIndexer,proto3" json:"is_indexer,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsIndexerResponse) Reset() {
+	*x = IsIndexerResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsIndexerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsIndexerResponse) ProtoMessage() {}
+
+func (x *IsIndexerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsIndexerResponse.ProtoReflect.Descriptor instead.
+func (*IsIndexerResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *IsIndexerResponse) GetIsIndexer() bool {
+	if x != nil {
+		return x.IsIndexer
+	}
+	return false
+}
+
+func (x *IsIndexerResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Downloader methods
+type IsDownloaderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsDownloaderRequest) Reset() {
+	*x = IsDownloaderRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsDownloaderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsDownloaderRequest) ProtoMessage() {}
+
+func (x *IsDownloaderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsDownloaderRequest.ProtoReflect.Descriptor instead.
+func (*IsDownloaderRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{49}
+}
+
+type IsDownloaderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsDownloader  bool                   `protobuf:"varint,1,opt,name=is_downloader,json=isDownloader,proto3" json:"is_downloader,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsDownloaderResponse) Reset() {
+	*x = IsDownloaderResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsDownloaderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsDownloaderResponse) ProtoMessage() {}
+
+func (x *IsDownloaderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsDownloaderResponse.ProtoReflect.Descriptor instead.
+func (*IsDownloaderResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *IsDownloaderResponse) GetIsDownloader() bool {
+	if x != nil {
+		return x.IsDownloader
+	}
+	return false
+}
+
+func (x *IsDownloaderResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Config migration
+type MigrateConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromVersion   int32                  `protobuf:"varint,1,opt,name=from_version,json=fromVersion,proto3" json:"from_version,omitempty"`
+	Config        []byte                 `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"` // JSON object of config key to value
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrateConfigRequest) Reset() {
+	*x = MigrateConfigRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrateConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrateConfigRequest) ProtoMessage() {}
+
+func (x *MigrateConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrateConfigRequest.ProtoReflect.Descriptor instead.
+func (*MigrateConfigRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *MigrateConfigRequest) GetFromVersion() int32 {
+	if x != nil {
+		return x.FromVersion
+	}
+	return 0
+}
+
+func (x *MigrateConfigRequest) GetConfig() []byte {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type MigrateConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Config        []byte                 `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"` // JSON object of config key to value, null deletes a key
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Supported     bool                   `protobuf:"varint,3,opt,name=supported,proto3" json:"supported,omitempty"` // false when the plugin doesn't migrate config
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrateConfigResponse) Reset() {
+	*x = MigrateConfigResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrateConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrateConfigResponse) ProtoMessage() {}
+
+func (x *MigrateConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrateConfigResponse.ProtoReflect.Descriptor instead.
+func (*MigrateConfigResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *MigrateConfigResponse) GetConfig() []byte {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *MigrateConfigResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *MigrateConfigResponse) GetSupported() bool {
+	if x != nil {
+		return x.Supported
+	}
+	return false
+}
+
+type IndexerSearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Categories    []string               `protobuf:"bytes,3,rep,name=categories,proto3" json:"categories,omitempty"`
+	Tvdbid        string                 `protobuf:"bytes,4,opt,name=tvdbid,proto3" json:"tvdbid,omitempty"`
+	Tvrageid      string                 `protobuf:"bytes,5,opt,name=tvrageid,proto3" json:"tvrageid,omitempty"`
+	Season        int32                  `protobuf:"varint,6,opt,name=season,proto3" json:"season,omitempty"`
+	Episode       int32                  `protobuf:"varint,7,opt,name=episode,proto3" json:"episode,omitempty"`
+	Imdbid        string                 `protobuf:"bytes,8,opt,name=imdbid,proto3" json:"imdbid,omitempty"`
+	Tmdbid        string                 `protobuf:"bytes,9,opt,name=tmdbid,proto3" json:"tmdbid,omitempty"`
+	Limit         int32                  `protobuf:"varint,10,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int32                  `protobuf:"varint,11,opt,name=offset,proto3" json:"offset,omitempty"`
+	SdkServerId   uint32                 `protobuf:"varint,12,opt,name=sdk_server_id,json=sdkServerId,proto3" json:"sdk_server_id,omitempty"`
+	Fresh         bool                   `protobuf:"varint,13,opt,name=fresh,proto3" json:"fresh,omitempty"`
+	MinSeeders    int32                  `protobuf:"varint,14,opt,name=min_seeders,json=minSeeders,proto3" json:"min_seeders,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexerSearchRequest) Reset() {
+	*x = IndexerSearchRequest{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexerSearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexerSearchRequest) ProtoMessage() {}
+
+func (x *IndexerSearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexerSearchRequest.ProtoReflect.Descriptor instead.
+func (*IndexerSearchRequest) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *IndexerSearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *IndexerSearchRequest) GetTvdbid() string {
+	if x != nil {
+		return x.Tvdbid
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetTvrageid() string {
+	if x != nil {
+		return x.Tvrageid
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetSeason() int32 {
+	if x != nil {
+		return x.Season
+	}
+	return 0
+}
+
+func (x *IndexerSearchRequest) GetEpisode() int32 {
+	if x != nil {
+		return x.Episode
+	}
+	return 0
+}
+
+func (x *IndexerSearchRequest) GetImdbid() string {
+	if x != nil {
+		return x.Imdbid
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetTmdbid() string {
+	if x != nil {
+		return x.Tmdbid
+	}
+	return ""
+}
+
+func (x *IndexerSearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *IndexerSearchRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *IndexerSearchRequest) GetSdkServerId() uint32 {
+	if x != nil {
+		return x.SdkServerId
+	}
+	return 0
+}
+
+func (x *IndexerSearchRequest) GetFresh() bool {
+	if x != nil {
+		return x.Fresh
+	}
+	return false
+}
+
+func (x *IndexerSearchRequest) GetMinSeeders() int32 {
+	if x != nil {
+		return x.MinSeeders
+	}
+	return 0
+}
+
+type IndexerSearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Releases      []*IndexerRelease      `protobuf:"bytes,1,rep,name=releases,proto3" json:"releases,omitempty"`
+	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	IndexerId     string                 `protobuf:"bytes,3,opt,name=indexer_id,json=indexerId,proto3" json:"indexer_id,omitempty"`
+	IndexerName   string                 `protobuf:"bytes,4,opt,name=indexer_name,json=indexerName,proto3" json:"indexer_name,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IndexerSearchResponse) Reset() {
+	*x = IndexerSearchResponse{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexerSearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexerSearchResponse) ProtoMessage() {}
+
+func (x *IndexerSearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexerSearchResponse.ProtoReflect.Descriptor instead.
+func (*IndexerSearchResponse) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *IndexerSearchResponse) GetReleases() []*IndexerRelease {
+	if x != nil {
+		return x.Releases
+	}
+	return nil
+}
+
+func (x *IndexerSearchResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *IndexerSearchResponse) GetIndexerId() string {
+	if x != nil {
+		return x.IndexerId
+	}
+	return ""
+}
+
+func (x *IndexerSearchResponse) GetIndexerName() string {
+	if x != nil {
+		return x.IndexerName
+	}
+	return ""
+}
+
+func (x *IndexerSearchResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type IndexerRelease struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Guid            string                 `protobuf:"bytes,1,opt,name=guid,proto3" json:"guid,omitempty"`
+	Title           string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Link            string                 `protobuf:"bytes,3,opt,name=link,proto3" json:"link,omitempty"`
+	Comments        string                 `protobuf:"bytes,4,opt,name=comments,proto3" json:"comments,omitempty"`
+	PublishDate     int64                  `protobuf:"varint,5,opt,name=publish_date,json=publishDate,proto3" json:"publish_date,omitempty"` // Unix timestamp
+	Category        string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
+	Size            int64                  `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
+	DownloadUrl     string                 `protobuf:"bytes,8,opt,name=download_url,json=downloadUrl,proto3" json:"download_url,omitempty"`
+	Description     string                 `protobuf:"bytes,9,opt,name=description,proto3" json:"description,omitempty"`
+	Attributes      map[string]string      `protobuf:"bytes,10,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	IndexerId       string                 `protobuf:"bytes,11,opt,name=indexer_id,json=indexerId,proto3" json:"indexer_id,omitempty"`
+	IndexerName     string                 `protobuf:"bytes,12,opt,name=indexer_name,json=indexerName,proto3" json:"indexer_name,omitempty"`
+	AlsoAvailableOn []*ReleaseSource       `protobuf:"bytes,13,rep,name=also_available_on,json=alsoAvailableOn,proto3" json:"also_available_on,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *IndexerRelease) Reset() {
+	*x = IndexerRelease{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IndexerRelease) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IndexerRelease) ProtoMessage() {}
+
+func (x *IndexerRelease) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IndexerRelease.ProtoReflect.Descriptor instead.
+func (*IndexerRelease) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *IndexerRelease) GetGuid() string {
+	if x != nil {
+		return x.Guid
+	}
+	return ""
+}
+
+func (x *IndexerRelease) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *IndexerRelease) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *IndexerRelease) GetComments() string {
+	if x != nil {
+		return x.Comments
+	}
+	return ""
+}
+
+func (x *IndexerRelease) GetPublishDate() int64 {
+	if x != nil {
+		return x.PublishDate
+	}
+	return 0
+}
+
+func (x *IndexerRelease) GetCategory() string {
 	if x != nil {
 		return x.Category
 	}
@@ -1943,6 +3389,81 @@ func (x *IndexerRelease) GetIndexerName() string {
 	return ""
 }
 
+func (x *IndexerRelease) GetAlsoAvailableOn() []*ReleaseSource {
+	if x != nil {
+		return x.AlsoAvailableOn
+	}
+	return nil
+}
+
+type ReleaseSource struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IndexerId     string                 `protobuf:"bytes,1,opt,name=indexer_id,json=indexerId,proto3" json:"indexer_id,omitempty"`
+	IndexerName   string                 `protobuf:"bytes,2,opt,name=indexer_name,json=indexerName,proto3" json:"indexer_name,omitempty"`
+	Guid          string                 `protobuf:"bytes,3,opt,name=guid,proto3" json:"guid,omitempty"`
+	DownloadUrl   string                 `protobuf:"bytes,4,opt,name=download_url,json=downloadUrl,proto3" json:"download_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReleaseSource) Reset() {
+	*x = ReleaseSource{}
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseSource) ProtoMessage() {}
+
+func (x *ReleaseSource) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_plugins_proto_plugin_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseSource.ProtoReflect.Descriptor instead.
+func (*ReleaseSource) Descriptor() ([]byte, []int) {
+	return file_internal_plugins_proto_plugin_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *ReleaseSource) GetIndexerId() string {
+	if x != nil {
+		return x.IndexerId
+	}
+	return ""
+}
+
+func (x *ReleaseSource) GetIndexerName() string {
+	if x != nil {
+		return x.IndexerName
+	}
+	return ""
+}
+
+func (x *ReleaseSource) GetGuid() string {
+	if x != nil {
+		return x.Guid
+	}
+	return ""
+}
+
+func (x *ReleaseSource) GetDownloadUrl() string {
+	if x != nil {
+		return x.DownloadUrl
+	}
+	return ""
+}
+
 var File_internal_plugins_proto_plugin_proto protoreflect.FileDescriptor
 
 const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
@@ -1958,12 +3479,13 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\"\n" +
 	"\fcapabilities\x18\x05 \x03(\tR\fcapabilities\"C\n" +
 	"\x11APIRoutesResponse\x12.\n" +
-	"\x06routes\x18\x01 \x03(\v2\x16.proto.RouteDescriptorR\x06routes\"c\n" +
+	"\x06routes\x18\x01 \x03(\v2\x16.proto.RouteDescriptorR\x06routes\"w\n" +
 	"\x0fRouteDescriptor\x12\x16\n" +
 	"\x06method\x18\x01 \x01(\tR\x06method\x12\x12\n" +
 	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
 	"\x04auth\x18\x03 \x01(\tR\x04auth\x12\x10\n" +
-	"\x03tag\x18\x04 \x01(\tR\x03tag\"\xce\x03\n" +
+	"\x03tag\x18\x04 \x01(\tR\x03tag\x12\x12\n" +
+	"\x04role\x18\x05 \x01(\tR\x04role\"\xed\x04\n" +
 	"\x10HandleAPIRequest\x12\x16\n" +
 	"\x06method\x18\x01 \x01(\tR\x06method\x12\x12\n" +
 	"\x04path\x18\x02 \x01(\tR\x04path\x128\n" +
@@ -1972,14 +3494,21 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\x04body\x18\x05 \x01(\fR\x04body\x12\x1c\n" +
 	"\auser_id\x18\x06 \x01(\x03H\x00R\x06userId\x88\x01\x01\x12\x16\n" +
 	"\x06scopes\x18\a \x03(\tR\x06scopes\x12\"\n" +
-	"\rsdk_server_id\x18\b \x01(\rR\vsdkServerId\x1aK\n" +
+	"\rsdk_server_id\x18\b \x01(\rR\vsdkServerId\x12H\n" +
+	"\vpath_params\x18\t \x03(\v2'.proto.HandleAPIRequest.PathParamsEntryR\n" +
+	"pathParams\x12\x14\n" +
+	"\x05route\x18\n" +
+	" \x01(\tR\x05route\x1aK\n" +
 	"\n" +
 	"QueryEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12'\n" +
 	"\x05value\x18\x02 \x01(\v2\x11.proto.StringListR\x05value:\x028\x01\x1aM\n" +
 	"\fHeadersEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12'\n" +
-	"\x05value\x18\x02 \x01(\v2\x11.proto.StringListR\x05value:\x028\x01B\n" +
+	"\x05value\x18\x02 \x01(\v2\x11.proto.StringListR\x05value:\x028\x01\x1a=\n" +
+	"\x0fPathParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\n" +
 	"\n" +
 	"\b_user_id\"$\n" +
 	"\n" +
@@ -2005,11 +3534,12 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\aUIRoute\x12\x12\n" +
 	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1d\n" +
 	"\n" +
-	"bundle_url\x18\x02 \x01(\tR\tbundleUrl\"s\n" +
+	"bundle_url\x18\x02 \x01(\tR\tbundleUrl\"\x9a\x01\n" +
 	"\rConfigSection\x12\x14\n" +
 	"\x05title\x18\x01 \x01(\tR\x05title\x12 \n" +
 	"\vdescription\x18\x02 \x01(\tR\vdescription\x12*\n" +
-	"\x06fields\x18\x03 \x03(\v2\x12.proto.ConfigFieldR\x06fields\"\xa6\x02\n" +
+	"\x06fields\x18\x03 \x03(\v2\x12.proto.ConfigFieldR\x06fields\x12%\n" +
+	"\x0eschema_version\x18\x04 \x01(\x05R\rschemaVersion\"\xa6\x02\n" +
 	"\vConfigField\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05label\x18\x02 \x01(\tR\x05label\x12 \n" +
@@ -2031,11 +3561,12 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\x04_maxB\n" +
 	"\n" +
 	"\b_patternB\x10\n" +
-	"\x0e_error_message\"Z\n" +
+	"\x0e_error_message\"~\n" +
 	"\x12HandleEventRequest\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
 	"\x04data\x18\x02 \x01(\fR\x04data\x12\x1c\n" +
-	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\"E\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\"\n" +
+	"\rsdk_server_id\x18\x04 \x01(\rR\vsdkServerId\"E\n" +
 	"\x13HandleEventResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"$\n" +
@@ -2057,6 +3588,100 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\x13ConfigDeleteRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\",\n" +
 	"\x14ConfigDeleteResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"*\n" +
+	"\x16ConfigGetSecretRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"E\n" +
+	"\x17ConfigGetSecretResponse\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\fR\x05value\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"@\n" +
+	"\x16ConfigSetSecretRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value\"/\n" +
+	"\x17ConfigSetSecretResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"\x94\x02\n" +
+	"\tMediaItem\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x17\n" +
+	"\x04year\x18\x04 \x01(\x05H\x00R\x04year\x88\x01\x01\x12\x1a\n" +
+	"\bmetadata\x18\x05 \x01(\fR\bmetadata\x12!\n" +
+	"\fexternal_ids\x18\x06 \x01(\fR\vexternalIds\x12 \n" +
+	"\tparent_id\x18\a \x01(\x03H\x01R\bparentId\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\b \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\x03R\tupdatedAtB\a\n" +
+	"\x05_yearB\f\n" +
+	"\n" +
+	"_parent_id\"!\n" +
+	"\x0fMediaGetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"N\n" +
+	"\x10MediaGetResponse\x12$\n" +
+	"\x04item\x18\x01 \x01(\v2\x10.proto.MediaItemR\x04item\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"C\n" +
+	"\x10MediaListRequest\x12\x1b\n" +
+	"\tparent_id\x18\x01 \x01(\x03R\bparentId\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\"Q\n" +
+	"\x11MediaListResponse\x12&\n" +
+	"\x05items\x18\x01 \x03(\v2\x10.proto.MediaItemR\x05items\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"k\n" +
+	"\x1aMediaUpdateMetadataRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\bmetadata\x18\x02 \x01(\fR\bmetadata\x12!\n" +
+	"\fexternal_ids\x18\x03 \x01(\fR\vexternalIds\"Y\n" +
+	"\x1bMediaUpdateMetadataResponse\x12$\n" +
+	"\x04item\x18\x01 \x01(\v2\x10.proto.MediaItemR\x04item\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"f\n" +
+	"\x18MediaAddExtraFileRequest\x12\"\n" +
+	"\rmedia_item_id\x18\x01 \x01(\x03R\vmediaItemId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\"1\n" +
+	"\x19MediaAddExtraFileResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"7\n" +
+	"\x11MediaFilesRequest\x12\"\n" +
+	"\rmedia_item_id\x18\x01 \x01(\x03R\vmediaItemId\"[\n" +
+	"\tMediaFile\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\"R\n" +
+	"\x12MediaFilesResponse\x12&\n" +
+	"\x05files\x18\x01 \x03(\v2\x10.proto.MediaFileR\x05files\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"O\n" +
+	"\x13DownloadSyncRequest\x12\x1a\n" +
+	"\bdownload\x18\x01 \x01(\fR\bdownload\x12\x1c\n" +
+	"\tdownloads\x18\x02 \x01(\fR\tdownloads\",\n" +
+	"\x14DownloadSyncResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"\xd3\x02\n" +
+	"\x11ImportFileRequest\x12\x1f\n" +
+	"\vdownload_id\x18\x01 \x01(\tR\n" +
+	"downloadId\x12\x1f\n" +
+	"\vsource_path\x18\x02 \x01(\tR\n" +
+	"sourcePath\x12\"\n" +
+	"\rmedia_item_id\x18\x03 \x01(\x03R\vmediaItemId\x12\x18\n" +
+	"\aquality\x18\x04 \x01(\tR\aquality\x12#\n" +
+	"\rrelease_group\x18\x05 \x01(\tR\freleaseGroup\x12#\n" +
+	"\rrelease_title\x18\x06 \x01(\tR\freleaseTitle\x12/\n" +
+	"\x14extra_media_item_ids\x18\a \x03(\x03R\x11extraMediaItemIds\x12#\n" +
+	"\rtransfer_mode\x18\b \x01(\tR\ftransferMode\x12\x1e\n" +
+	"\n" +
+	"disposable\x18\t \x01(\bR\n" +
+	"disposable\"g\n" +
+	"\x12ImportFileResponse\x12\x1d\n" +
+	"\n" +
+	"final_path\x18\x01 \x01(\tR\tfinalPath\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1c\n" +
+	"\tretryable\x18\x03 \x01(\bR\tretryable\"\xc6\x01\n" +
+	"\x13ImportFailedRequest\x12\x1f\n" +
+	"\vdownload_id\x18\x01 \x01(\tR\n" +
+	"downloadId\x12\x1f\n" +
+	"\vsource_path\x18\x02 \x01(\tR\n" +
+	"sourcePath\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x1a\n" +
+	"\bdetected\x18\x04 \x01(\fR\bdetected\x12'\n" +
+	"\rmedia_item_id\x18\x05 \x01(\x03H\x00R\vmediaItemId\x88\x01\x01B\x10\n" +
+	"\x0e_media_item_id\",\n" +
+	"\x14ImportFailedResponse\x12\x14\n" +
 	"\x05error\x18\x01 \x01(\tR\x05error\"\x12\n" +
 	"\x10IsIndexerRequest\"H\n" +
 	"\x11IsIndexerResponse\x12\x1d\n" +
@@ -2066,7 +3691,14 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\x13IsDownloaderRequest\"Q\n" +
 	"\x14IsDownloaderResponse\x12#\n" +
 	"\ris_downloader\x18\x01 \x01(\bR\fisDownloader\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"\xa4\x02\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"Q\n" +
+	"\x14MigrateConfigRequest\x12!\n" +
+	"\ffrom_version\x18\x01 \x01(\x05R\vfromVersion\x12\x16\n" +
+	"\x06config\x18\x02 \x01(\fR\x06config\"c\n" +
+	"\x15MigrateConfigResponse\x12\x16\n" +
+	"\x06config\x18\x01 \x01(\fR\x06config\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1c\n" +
+	"\tsupported\x18\x03 \x01(\bR\tsupported\"\xff\x02\n" +
 	"\x14IndexerSearchRequest\x12\x14\n" +
 	"\x05query\x18\x01 \x01(\tR\x05query\x12\x12\n" +
 	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1e\n" +
@@ -2081,14 +3713,18 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\x06tmdbid\x18\t \x01(\tR\x06tmdbid\x12\x14\n" +
 	"\x05limit\x18\n" +
 	" \x01(\x05R\x05limit\x12\x16\n" +
-	"\x06offset\x18\v \x01(\x05R\x06offset\"\xb8\x01\n" +
+	"\x06offset\x18\v \x01(\x05R\x06offset\x12\"\n" +
+	"\rsdk_server_id\x18\f \x01(\rR\vsdkServerId\x12\x14\n" +
+	"\x05fresh\x18\r \x01(\bR\x05fresh\x12\x1f\n" +
+	"\vmin_seeders\x18\x0e \x01(\x05R\n" +
+	"minSeeders\"\xb8\x01\n" +
 	"\x15IndexerSearchResponse\x121\n" +
 	"\breleases\x18\x01 \x03(\v2\x15.proto.IndexerReleaseR\breleases\x12\x14\n" +
 	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x1d\n" +
 	"\n" +
 	"indexer_id\x18\x03 \x01(\tR\tindexerId\x12!\n" +
 	"\findexer_name\x18\x04 \x01(\tR\vindexerName\x12\x14\n" +
-	"\x05error\x18\x05 \x01(\tR\x05error\"\xca\x03\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\x8c\x04\n" +
 	"\x0eIndexerRelease\x12\x12\n" +
 	"\x04guid\x18\x01 \x01(\tR\x04guid\x12\x14\n" +
 	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
@@ -2105,10 +3741,17 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"attributes\x12\x1d\n" +
 	"\n" +
 	"indexer_id\x18\v \x01(\tR\tindexerId\x12!\n" +
-	"\findexer_name\x18\f \x01(\tR\vindexerName\x1a=\n" +
+	"\findexer_name\x18\f \x01(\tR\vindexerName\x12@\n" +
+	"\x11also_available_on\x18\r \x03(\v2\x14.proto.ReleaseSourceR\x0falsoAvailableOn\x1a=\n" +
 	"\x0fAttributesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xa3\x04\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x88\x01\n" +
+	"\rReleaseSource\x12\x1d\n" +
+	"\n" +
+	"indexer_id\x18\x01 \x01(\tR\tindexerId\x12!\n" +
+	"\findexer_name\x18\x02 \x01(\tR\vindexerName\x12\x12\n" +
+	"\x04guid\x18\x03 \x01(\tR\x04guid\x12!\n" +
+	"\fdownload_url\x18\x04 \x01(\tR\vdownloadUrl2\xef\x04\n" +
 	"\rPluginService\x12;\n" +
 	"\bMetadata\x12\x16.proto.MetadataRequest\x1a\x17.proto.MetadataResponse\x12>\n" +
 	"\tAPIRoutes\x12\x17.proto.APIRoutesRequest\x1a\x18.proto.APIRoutesResponse\x12>\n" +
@@ -2118,13 +3761,25 @@ const file_internal_plugins_proto_plugin_proto_rawDesc = "" +
 	"\vHandleEvent\x12\x19.proto.HandleEventRequest\x1a\x1a.proto.HandleEventResponse\x12>\n" +
 	"\tIsIndexer\x12\x17.proto.IsIndexerRequest\x1a\x18.proto.IsIndexerResponse\x12C\n" +
 	"\x06Search\x12\x1b.proto.IndexerSearchRequest\x1a\x1c.proto.IndexerSearchResponse\x12G\n" +
-	"\fIsDownloader\x12\x1a.proto.IsDownloaderRequest\x1a\x1b.proto.IsDownloaderResponse2\xa7\x02\n" +
+	"\fIsDownloader\x12\x1a.proto.IsDownloaderRequest\x1a\x1b.proto.IsDownloaderResponse\x12J\n" +
+	"\rMigrateConfig\x12\x1b.proto.MigrateConfigRequest\x1a\x1c.proto.MigrateConfigResponse2\x99\b\n" +
 	"\n" +
 	"SDKService\x12>\n" +
 	"\tConfigGet\x12\x17.proto.ConfigGetRequest\x1a\x18.proto.ConfigGetResponse\x12P\n" +
 	"\x0fConfigGetString\x12\x1d.proto.ConfigGetStringRequest\x1a\x1e.proto.ConfigGetStringResponse\x12>\n" +
 	"\tConfigSet\x12\x17.proto.ConfigSetRequest\x1a\x18.proto.ConfigSetResponse\x12G\n" +
-	"\fConfigDelete\x12\x1a.proto.ConfigDeleteRequest\x1a\x1b.proto.ConfigDeleteResponseB9Z7github.com/blakestevenson/nimbus/internal/plugins/protob\x06proto3"
+	"\fConfigDelete\x12\x1a.proto.ConfigDeleteRequest\x1a\x1b.proto.ConfigDeleteResponse\x12P\n" +
+	"\x0fConfigGetSecret\x12\x1d.proto.ConfigGetSecretRequest\x1a\x1e.proto.ConfigGetSecretResponse\x12P\n" +
+	"\x0fConfigSetSecret\x12\x1d.proto.ConfigSetSecretRequest\x1a\x1e.proto.ConfigSetSecretResponse\x12;\n" +
+	"\bMediaGet\x12\x16.proto.MediaGetRequest\x1a\x17.proto.MediaGetResponse\x12>\n" +
+	"\tMediaList\x12\x17.proto.MediaListRequest\x1a\x18.proto.MediaListResponse\x12\\\n" +
+	"\x13MediaUpdateMetadata\x12!.proto.MediaUpdateMetadataRequest\x1a\".proto.MediaUpdateMetadataResponse\x12V\n" +
+	"\x11MediaAddExtraFile\x12\x1f.proto.MediaAddExtraFileRequest\x1a .proto.MediaAddExtraFileResponse\x12A\n" +
+	"\n" +
+	"MediaFiles\x12\x18.proto.MediaFilesRequest\x1a\x19.proto.MediaFilesResponse\x12G\n" +
+	"\fDownloadSync\x12\x1a.proto.DownloadSyncRequest\x1a\x1b.proto.DownloadSyncResponse\x12D\n" +
+	"\rImportRequest\x12\x18.proto.ImportFileRequest\x1a\x19.proto.ImportFileResponse\x12G\n" +
+	"\fImportFailed\x12\x1a.proto.ImportFailedRequest\x1a\x1b.proto.ImportFailedResponseB9Z7github.com/blakestevenson/nimbus/internal/plugins/protob\x06proto3"
 
 var (
 	file_internal_plugins_proto_plugin_proto_rawDescOnce sync.Once
@@ -2138,89 +3793,143 @@ func file_internal_plugins_proto_plugin_proto_rawDescGZIP() []byte {
 	return file_internal_plugins_proto_plugin_proto_rawDescData
 }
 
-var file_internal_plugins_proto_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 36)
+var file_internal_plugins_proto_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 62)
 var file_internal_plugins_proto_plugin_proto_goTypes = []any{
-	(*MetadataRequest)(nil),         // 0: proto.MetadataRequest
-	(*APIRoutesRequest)(nil),        // 1: proto.APIRoutesRequest
-	(*UIManifestRequest)(nil),       // 2: proto.UIManifestRequest
-	(*MetadataResponse)(nil),        // 3: proto.MetadataResponse
-	(*APIRoutesResponse)(nil),       // 4: proto.APIRoutesResponse
-	(*RouteDescriptor)(nil),         // 5: proto.RouteDescriptor
-	(*HandleAPIRequest)(nil),        // 6: proto.HandleAPIRequest
-	(*StringList)(nil),              // 7: proto.StringList
-	(*HandleAPIResponse)(nil),       // 8: proto.HandleAPIResponse
-	(*UIManifestResponse)(nil),      // 9: proto.UIManifestResponse
-	(*UINavItem)(nil),               // 10: proto.UINavItem
-	(*UIRoute)(nil),                 // 11: proto.UIRoute
-	(*ConfigSection)(nil),           // 12: proto.ConfigSection
-	(*ConfigField)(nil),             // 13: proto.ConfigField
-	(*ConfigFieldValidation)(nil),   // 14: proto.ConfigFieldValidation
-	(*HandleEventRequest)(nil),      // 15: proto.HandleEventRequest
-	(*HandleEventResponse)(nil),     // 16: proto.HandleEventResponse
-	(*ConfigGetRequest)(nil),        // 17: proto.ConfigGetRequest
-	(*ConfigGetResponse)(nil),       // 18: proto.ConfigGetResponse
-	(*ConfigGetStringRequest)(nil),  // 19: proto.ConfigGetStringRequest
-	(*ConfigGetStringResponse)(nil), // 20: proto.ConfigGetStringResponse
-	(*ConfigSetRequest)(nil),        // 21: proto.ConfigSetRequest
-	(*ConfigSetResponse)(nil),       // 22: proto.ConfigSetResponse
-	(*ConfigDeleteRequest)(nil),     // 23: proto.ConfigDeleteRequest
-	(*ConfigDeleteResponse)(nil),    // 24: proto.ConfigDeleteResponse
-	(*IsIndexerRequest)(nil),        // 25: proto.IsIndexerRequest
-	(*IsIndexerResponse)(nil),       // 26: proto.IsIndexerResponse
-	(*IsDownloaderRequest)(nil),     // 27: proto.IsDownloaderRequest
-	(*IsDownloaderResponse)(nil),    // 28: proto.IsDownloaderResponse
-	(*IndexerSearchRequest)(nil),    // 29: proto.IndexerSearchRequest
-	(*IndexerSearchResponse)(nil),   // 30: proto.IndexerSearchResponse
-	(*IndexerRelease)(nil),          // 31: proto.IndexerRelease
-	nil,                             // 32: proto.HandleAPIRequest.QueryEntry
-	nil,                             // 33: proto.HandleAPIRequest.HeadersEntry
-	nil,                             // 34: proto.HandleAPIResponse.HeadersEntry
-	nil,                             // 35: proto.IndexerRelease.AttributesEntry
+	(*MetadataRequest)(nil),             // 0: proto.MetadataRequest
+	(*APIRoutesRequest)(nil),            // 1: proto.APIRoutesRequest
+	(*UIManifestRequest)(nil),           // 2: proto.UIManifestRequest
+	(*MetadataResponse)(nil),            // 3: proto.MetadataResponse
+	(*APIRoutesResponse)(nil),           // 4: proto.APIRoutesResponse
+	(*RouteDescriptor)(nil),             // 5: proto.RouteDescriptor
+	(*HandleAPIRequest)(nil),            // 6: proto.HandleAPIRequest
+	(*StringList)(nil),                  // 7: proto.StringList
+	(*HandleAPIResponse)(nil),           // 8: proto.HandleAPIResponse
+	(*UIManifestResponse)(nil),          // 9: proto.UIManifestResponse
+	(*UINavItem)(nil),                   // 10: proto.UINavItem
+	(*UIRoute)(nil),                     // 11: proto.UIRoute
+	(*ConfigSection)(nil),               // 12: proto.ConfigSection
+	(*ConfigField)(nil),                 // 13: proto.ConfigField
+	(*ConfigFieldValidation)(nil),       // 14: proto.ConfigFieldValidation
+	(*HandleEventRequest)(nil),          // 15: proto.HandleEventRequest
+	(*HandleEventResponse)(nil),         // 16: proto.HandleEventResponse
+	(*ConfigGetRequest)(nil),            // 17: proto.ConfigGetRequest
+	(*ConfigGetResponse)(nil),           // 18: proto.ConfigGetResponse
+	(*ConfigGetStringRequest)(nil),      // 19: proto.ConfigGetStringRequest
+	(*ConfigGetStringResponse)(nil),     // 20: proto.ConfigGetStringResponse
+	(*ConfigSetRequest)(nil),            // 21: proto.ConfigSetRequest
+	(*ConfigSetResponse)(nil),           // 22: proto.ConfigSetResponse
+	(*ConfigDeleteRequest)(nil),         // 23: proto.ConfigDeleteRequest
+	(*ConfigDeleteResponse)(nil),        // 24: proto.ConfigDeleteResponse
+	(*ConfigGetSecretRequest)(nil),      // 25: proto.ConfigGetSecretRequest
+	(*ConfigGetSecretResponse)(nil),     // 26: proto.ConfigGetSecretResponse
+	(*ConfigSetSecretRequest)(nil),      // 27: proto.ConfigSetSecretRequest
+	(*ConfigSetSecretResponse)(nil),     // 28: proto.ConfigSetSecretResponse
+	(*MediaItem)(nil),                   // 29: proto.MediaItem
+	(*MediaGetRequest)(nil),             // 30: proto.MediaGetRequest
+	(*MediaGetResponse)(nil),            // 31: proto.MediaGetResponse
+	(*MediaListRequest)(nil),            // 32: proto.MediaListRequest
+	(*MediaListResponse)(nil),           // 33: proto.MediaListResponse
+	(*MediaUpdateMetadataRequest)(nil),  // 34: proto.MediaUpdateMetadataRequest
+	(*MediaUpdateMetadataResponse)(nil), // 35: proto.MediaUpdateMetadataResponse
+	(*MediaAddExtraFileRequest)(nil),    // 36: proto.MediaAddExtraFileRequest
+	(*MediaAddExtraFileResponse)(nil),   // 37: proto.MediaAddExtraFileResponse
+	(*MediaFilesRequest)(nil),           // 38: proto.MediaFilesRequest
+	(*MediaFile)(nil),                   // 39: proto.MediaFile
+	(*MediaFilesResponse)(nil),          // 40: proto.MediaFilesResponse
+	(*DownloadSyncRequest)(nil),         // 41: proto.DownloadSyncRequest
+	(*DownloadSyncResponse)(nil),        // 42: proto.DownloadSyncResponse
+	(*ImportFileRequest)(nil),           // 43: proto.ImportFileRequest
+	(*ImportFileResponse)(nil),          // 44: proto.ImportFileResponse
+	(*ImportFailedRequest)(nil),         // 45: proto.ImportFailedRequest
+	(*ImportFailedResponse)(nil),        // 46: proto.ImportFailedResponse
+	(*IsIndexerRequest)(nil),            // 47: proto.IsIndexerRequest
+	(*IsIndexerResponse)(nil),           // 48: proto.IsIndexerResponse
+	(*IsDownloaderRequest)(nil),         // 49: proto.IsDownloaderRequest
+	(*IsDownloaderResponse)(nil),        // 50: proto.IsDownloaderResponse
+	(*MigrateConfigRequest)(nil),        // 51: proto.MigrateConfigRequest
+	(*MigrateConfigResponse)(nil),       // 52: proto.MigrateConfigResponse
+	(*IndexerSearchRequest)(nil),        // 53: proto.IndexerSearchRequest
+	(*IndexerSearchResponse)(nil),       // 54: proto.IndexerSearchResponse
+	(*IndexerRelease)(nil),              // 55: proto.IndexerRelease
+	(*ReleaseSource)(nil),               // 56: proto.ReleaseSource
+	nil,                                 // 57: proto.HandleAPIRequest.QueryEntry
+	nil,                                 // 58: proto.HandleAPIRequest.HeadersEntry
+	nil,                                 // 59: proto.HandleAPIRequest.PathParamsEntry
+	nil,                                 // 60: proto.HandleAPIResponse.HeadersEntry
+	nil,                                 // 61: proto.IndexerRelease.AttributesEntry
 }
 var file_internal_plugins_proto_plugin_proto_depIdxs = []int32{
 	5,  // 0: proto.APIRoutesResponse.routes:type_name -> proto.RouteDescriptor
-	32, // 1: proto.HandleAPIRequest.query:type_name -> proto.HandleAPIRequest.QueryEntry
-	33, // 2: proto.HandleAPIRequest.headers:type_name -> proto.HandleAPIRequest.HeadersEntry
-	34, // 3: proto.HandleAPIResponse.headers:type_name -> proto.HandleAPIResponse.HeadersEntry
-	10, // 4: proto.UIManifestResponse.nav_items:type_name -> proto.UINavItem
-	11, // 5: proto.UIManifestResponse.routes:type_name -> proto.UIRoute
-	12, // 6: proto.UIManifestResponse.config_section:type_name -> proto.ConfigSection
-	13, // 7: proto.ConfigSection.fields:type_name -> proto.ConfigField
-	14, // 8: proto.ConfigField.validation:type_name -> proto.ConfigFieldValidation
-	31, // 9: proto.IndexerSearchResponse.releases:type_name -> proto.IndexerRelease
-	35, // 10: proto.IndexerRelease.attributes:type_name -> proto.IndexerRelease.AttributesEntry
-	7,  // 11: proto.HandleAPIRequest.QueryEntry.value:type_name -> proto.StringList
-	7,  // 12: proto.HandleAPIRequest.HeadersEntry.value:type_name -> proto.StringList
-	7,  // 13: proto.HandleAPIResponse.HeadersEntry.value:type_name -> proto.StringList
-	0,  // 14: proto.PluginService.Metadata:input_type -> proto.MetadataRequest
-	1,  // 15: proto.PluginService.APIRoutes:input_type -> proto.APIRoutesRequest
-	6,  // 16: proto.PluginService.HandleAPI:input_type -> proto.HandleAPIRequest
-	2,  // 17: proto.PluginService.UIManifest:input_type -> proto.UIManifestRequest
-	15, // 18: proto.PluginService.HandleEvent:input_type -> proto.HandleEventRequest
-	25, // 19: proto.PluginService.IsIndexer:input_type -> proto.IsIndexerRequest
-	29, // 20: proto.PluginService.Search:input_type -> proto.IndexerSearchRequest
-	27, // 21: proto.PluginService.IsDownloader:input_type -> proto.IsDownloaderRequest
-	17, // 22: proto.SDKService.ConfigGet:input_type -> proto.ConfigGetRequest
-	19, // 23: proto.SDKService.ConfigGetString:input_type -> proto.ConfigGetStringRequest
-	21, // 24: proto.SDKService.ConfigSet:input_type -> proto.ConfigSetRequest
-	23, // 25: proto.SDKService.ConfigDelete:input_type -> proto.ConfigDeleteRequest
-	3,  // 26: proto.PluginService.Metadata:output_type -> proto.MetadataResponse
-	4,  // 27: proto.PluginService.APIRoutes:output_type -> proto.APIRoutesResponse
-	8,  // 28: proto.PluginService.HandleAPI:output_type -> proto.HandleAPIResponse
-	9,  // 29: proto.PluginService.UIManifest:output_type -> proto.UIManifestResponse
-	16, // 30: proto.PluginService.HandleEvent:output_type -> proto.HandleEventResponse
-	26, // 31: proto.PluginService.IsIndexer:output_type -> proto.IsIndexerResponse
-	30, // 32: proto.PluginService.Search:output_type -> proto.IndexerSearchResponse
-	28, // 33: proto.PluginService.IsDownloader:output_type -> proto.IsDownloaderResponse
-	18, // 34: proto.SDKService.ConfigGet:output_type -> proto.ConfigGetResponse
-	20, // 35: proto.SDKService.ConfigGetString:output_type -> proto.ConfigGetStringResponse
-	22, // 36: proto.SDKService.ConfigSet:output_type -> proto.ConfigSetResponse
-	24, // 37: proto.SDKService.ConfigDelete:output_type -> proto.ConfigDeleteResponse
-	26, // [26:38] is the sub-list for method output_type
-	14, // [14:26] is the sub-list for method input_type
-	14, // [14:14] is the sub-list for extension type_name
-	14, // [14:14] is the sub-list for extension extendee
-	0,  // [0:14] is the sub-list for field type_name
+	57, // 1: proto.HandleAPIRequest.query:type_name -> proto.HandleAPIRequest.QueryEntry
+	58, // 2: proto.HandleAPIRequest.headers:type_name -> proto.HandleAPIRequest.HeadersEntry
+	59, // 3: proto.HandleAPIRequest.path_params:type_name -> proto.HandleAPIRequest.PathParamsEntry
+	60, // 4: proto.HandleAPIResponse.headers:type_name -> proto.HandleAPIResponse.HeadersEntry
+	10, // 5: proto.UIManifestResponse.nav_items:type_name -> proto.UINavItem
+	11, // 6: proto.UIManifestResponse.routes:type_name -> proto.UIRoute
+	12, // 7: proto.UIManifestResponse.config_section:type_name -> proto.ConfigSection
+	13, // 8: proto.ConfigSection.fields:type_name -> proto.ConfigField
+	14, // 9: proto.ConfigField.validation:type_name -> proto.ConfigFieldValidation
+	29, // 10: proto.MediaGetResponse.item:type_name -> proto.MediaItem
+	29, // 11: proto.MediaListResponse.items:type_name -> proto.MediaItem
+	29, // 12: proto.MediaUpdateMetadataResponse.item:type_name -> proto.MediaItem
+	39, // 13: proto.MediaFilesResponse.files:type_name -> proto.MediaFile
+	55, // 14: proto.IndexerSearchResponse.releases:type_name -> proto.IndexerRelease
+	61, // 15: proto.IndexerRelease.attributes:type_name -> proto.IndexerRelease.AttributesEntry
+	56, // 16: proto.IndexerRelease.also_available_on:type_name -> proto.ReleaseSource
+	7,  // 17: proto.HandleAPIRequest.QueryEntry.value:type_name -> proto.StringList
+	7,  // 18: proto.HandleAPIRequest.HeadersEntry.value:type_name -> proto.StringList
+	7,  // 19: proto.HandleAPIResponse.HeadersEntry.value:type_name -> proto.StringList
+	0,  // 20: proto.PluginService.Metadata:input_type -> proto.MetadataRequest
+	1,  // 21: proto.PluginService.APIRoutes:input_type -> proto.APIRoutesRequest
+	6,  // 22: proto.PluginService.HandleAPI:input_type -> proto.HandleAPIRequest
+	2,  // 23: proto.PluginService.UIManifest:input_type -> proto.UIManifestRequest
+	15, // 24: proto.PluginService.HandleEvent:input_type -> proto.HandleEventRequest
+	47, // 25: proto.PluginService.IsIndexer:input_type -> proto.IsIndexerRequest
+	53, // 26: proto.PluginService.Search:input_type -> proto.IndexerSearchRequest
+	49, // 27: proto.PluginService.IsDownloader:input_type -> proto.IsDownloaderRequest
+	51, // 28: proto.PluginService.MigrateConfig:input_type -> proto.MigrateConfigRequest
+	17, // 29: proto.SDKService.ConfigGet:input_type -> proto.ConfigGetRequest
+	19, // 30: proto.SDKService.ConfigGetString:input_type -> proto.ConfigGetStringRequest
+	21, // 31: proto.SDKService.ConfigSet:input_type -> proto.ConfigSetRequest
+	23, // 32: proto.SDKService.ConfigDelete:input_type -> proto.ConfigDeleteRequest
+	25, // 33: proto.SDKService.ConfigGetSecret:input_type -> proto.ConfigGetSecretRequest
+	27, // 34: proto.SDKService.ConfigSetSecret:input_type -> proto.ConfigSetSecretRequest
+	30, // 35: proto.SDKService.MediaGet:input_type -> proto.MediaGetRequest
+	32, // 36: proto.SDKService.MediaList:input_type -> proto.MediaListRequest
+	34, // 37: proto.SDKService.MediaUpdateMetadata:input_type -> proto.MediaUpdateMetadataRequest
+	36, // 38: proto.SDKService.MediaAddExtraFile:input_type -> proto.MediaAddExtraFileRequest
+	38, // 39: proto.SDKService.MediaFiles:input_type -> proto.MediaFilesRequest
+	41, // 40: proto.SDKService.DownloadSync:input_type -> proto.DownloadSyncRequest
+	43, // 41: proto.SDKService.ImportRequest:input_type -> proto.ImportFileRequest
+	45, // 42: proto.SDKService.ImportFailed:input_type -> proto.ImportFailedRequest
+	3,  // 43: proto.PluginService.Metadata:output_type -> proto.MetadataResponse
+	4,  // 44: proto.PluginService.APIRoutes:output_type -> proto.APIRoutesResponse
+	8,  // 45: proto.PluginService.HandleAPI:output_type -> proto.HandleAPIResponse
+	9,  // 46: proto.PluginService.UIManifest:output_type -> proto.UIManifestResponse
+	16, // 47: proto.PluginService.HandleEvent:output_type -> proto.HandleEventResponse
+	48, // 48: proto.PluginService.IsIndexer:output_type -> proto.IsIndexerResponse
+	54, // 49: proto.PluginService.Search:output_type -> proto.IndexerSearchResponse
+	50, // 50: proto.PluginService.IsDownloader:output_type -> proto.IsDownloaderResponse
+	52, // 51: proto.PluginService.MigrateConfig:output_type -> proto.MigrateConfigResponse
+	18, // 52: proto.SDKService.ConfigGet:output_type -> proto.ConfigGetResponse
+	20, // 53: proto.SDKService.ConfigGetString:output_type -> proto.ConfigGetStringResponse
+	22, // 54: proto.SDKService.ConfigSet:output_type -> proto.ConfigSetResponse
+	24, // 55: proto.SDKService.ConfigDelete:output_type -> proto.ConfigDeleteResponse
+	26, // 56: proto.SDKService.ConfigGetSecret:output_type -> proto.ConfigGetSecretResponse
+	28, // 57: proto.SDKService.ConfigSetSecret:output_type -> proto.ConfigSetSecretResponse
+	31, // 58: proto.SDKService.MediaGet:output_type -> proto.MediaGetResponse
+	33, // 59: proto.SDKService.MediaList:output_type -> proto.MediaListResponse
+	35, // 60: proto.SDKService.MediaUpdateMetadata:output_type -> proto.MediaUpdateMetadataResponse
+	37, // 61: proto.SDKService.MediaAddExtraFile:output_type -> proto.MediaAddExtraFileResponse
+	40, // 62: proto.SDKService.MediaFiles:output_type -> proto.MediaFilesResponse
+	42, // 63: proto.SDKService.DownloadSync:output_type -> proto.DownloadSyncResponse
+	44, // 64: proto.SDKService.ImportRequest:output_type -> proto.ImportFileResponse
+	46, // 65: proto.SDKService.ImportFailed:output_type -> proto.ImportFailedResponse
+	43, // [43:66] is the sub-list for method output_type
+	20, // [20:43] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_internal_plugins_proto_plugin_proto_init() }
@@ -2230,13 +3939,15 @@ func file_internal_plugins_proto_plugin_proto_init() {
 	}
 	file_internal_plugins_proto_plugin_proto_msgTypes[6].OneofWrappers = []any{}
 	file_internal_plugins_proto_plugin_proto_msgTypes[14].OneofWrappers = []any{}
+	file_internal_plugins_proto_plugin_proto_msgTypes[29].OneofWrappers = []any{}
+	file_internal_plugins_proto_plugin_proto_msgTypes[45].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_plugins_proto_plugin_proto_rawDesc), len(file_internal_plugins_proto_plugin_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   36,
+			NumMessages:   62,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -19,14 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	PluginService_Metadata_FullMethodName     = "/proto.PluginService/Metadata"
-	PluginService_APIRoutes_FullMethodName    = "/proto.PluginService/APIRoutes"
-	PluginService_HandleAPI_FullMethodName    = "/proto.PluginService/HandleAPI"
-	PluginService_UIManifest_FullMethodName   = "/proto.PluginService/UIManifest"
-	PluginService_HandleEvent_FullMethodName  = "/proto.PluginService/HandleEvent"
-	PluginService_IsIndexer_FullMethodName    = "/proto.PluginService/IsIndexer"
-	PluginService_Search_FullMethodName       = "/proto.PluginService/Search"
-	PluginService_IsDownloader_FullMethodName = "/proto.PluginService/IsDownloader"
+	PluginService_Metadata_FullMethodName      = "/proto.PluginService/Metadata"
+	PluginService_APIRoutes_FullMethodName     = "/proto.PluginService/APIRoutes"
+	PluginService_HandleAPI_FullMethodName     = "/proto.PluginService/HandleAPI"
+	PluginService_UIManifest_FullMethodName    = "/proto.PluginService/UIManifest"
+	PluginService_HandleEvent_FullMethodName   = "/proto.PluginService/HandleEvent"
+	PluginService_IsIndexer_FullMethodName     = "/proto.PluginService/IsIndexer"
+	PluginService_Search_FullMethodName        = "/proto.PluginService/Search"
+	PluginService_IsDownloader_FullMethodName  = "/proto.PluginService/IsDownloader"
+	PluginService_MigrateConfig_FullMethodName = "/proto.PluginService/MigrateConfig"
 )
 
 // PluginServiceClient is the client API for PluginService service.
@@ -43,6 +44,7 @@ type PluginServiceClient interface {
 	IsIndexer(ctx context.Context, in *IsIndexerRequest, opts ...grpc.CallOption) (*IsIndexerResponse, error)
 	Search(ctx context.Context, in *IndexerSearchRequest, opts ...grpc.CallOption) (*IndexerSearchResponse, error)
 	IsDownloader(ctx context.Context, in *IsDownloaderRequest, opts ...grpc.CallOption) (*IsDownloaderResponse, error)
+	MigrateConfig(ctx context.Context, in *MigrateConfigRequest, opts ...grpc.CallOption) (*MigrateConfigResponse, error)
 }
 
 type pluginServiceClient struct {
@@ -133,6 +135,16 @@ func (c *pluginServiceClient) IsDownloader(ctx context.Context, in *IsDownloader
 	return out, nil
 }
 
+func (c *pluginServiceClient) MigrateConfig(ctx context.Context, in *MigrateConfigRequest, opts ...grpc.CallOption) (*MigrateConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MigrateConfigResponse)
+	err := c.cc.Invoke(ctx, PluginService_MigrateConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PluginServiceServer is the server API for PluginService service.
 // All implementations must embed UnimplementedPluginServiceServer
 // for forward compatibility.
@@ -147,6 +159,7 @@ type PluginServiceServer interface {
 	IsIndexer(context.Context, *IsIndexerRequest) (*IsIndexerResponse, error)
 	Search(context.Context, *IndexerSearchRequest) (*IndexerSearchResponse, error)
 	IsDownloader(context.Context, *IsDownloaderRequest) (*IsDownloaderResponse, error)
+	MigrateConfig(context.Context, *MigrateConfigRequest) (*MigrateConfigResponse, error)
 	mustEmbedUnimplementedPluginServiceServer()
 }
 
@@ -181,6 +194,9 @@ func (UnimplementedPluginServiceServer) Search(context.Context, *IndexerSearchRe
 func (UnimplementedPluginServiceServer) IsDownloader(context.Context, *IsDownloaderRequest) (*IsDownloaderResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method IsDownloader not implemented")
 }
+func (UnimplementedPluginServiceServer) MigrateConfig(context.Context, *MigrateConfigRequest) (*MigrateConfigResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MigrateConfig not implemented")
+}
 func (UnimplementedPluginServiceServer) mustEmbedUnimplementedPluginServiceServer() {}
 func (UnimplementedPluginServiceServer) testEmbeddedByValue()                       {}
 
@@ -346,6 +362,24 @@ func _PluginService_IsDownloader_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PluginService_MigrateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).MigrateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_MigrateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).MigrateConfig(ctx, req.(*MigrateConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // PluginService_ServiceDesc is the grpc.ServiceDesc for PluginService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -385,16 +419,30 @@ var PluginService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "IsDownloader",
 			Handler:    _PluginService_IsDownloader_Handler,
 		},
+		{
+			MethodName: "MigrateConfig",
+			Handler:    _PluginService_MigrateConfig_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/plugins/proto/plugin.proto",
 }
 
 const (
-	SDKService_ConfigGet_FullMethodName       = "/proto.SDKService/ConfigGet"
-	SDKService_ConfigGetString_FullMethodName = "/proto.SDKService/ConfigGetString"
-	SDKService_ConfigSet_FullMethodName       = "/proto.SDKService/ConfigSet"
-	SDKService_ConfigDelete_FullMethodName    = "/proto.SDKService/ConfigDelete"
+	SDKService_ConfigGet_FullMethodName           = "/proto.SDKService/ConfigGet"
+	SDKService_ConfigGetString_FullMethodName     = "/proto.SDKService/ConfigGetString"
+	SDKService_ConfigSet_FullMethodName           = "/proto.SDKService/ConfigSet"
+	SDKService_ConfigDelete_FullMethodName        = "/proto.SDKService/ConfigDelete"
+	SDKService_ConfigGetSecret_FullMethodName     = "/proto.SDKService/ConfigGetSecret"
+	SDKService_ConfigSetSecret_FullMethodName     = "/proto.SDKService/ConfigSetSecret"
+	SDKService_MediaGet_FullMethodName            = "/proto.SDKService/MediaGet"
+	SDKService_MediaList_FullMethodName           = "/proto.SDKService/MediaList"
+	SDKService_MediaUpdateMetadata_FullMethodName = "/proto.SDKService/MediaUpdateMetadata"
+	SDKService_MediaAddExtraFile_FullMethodName   = "/proto.SDKService/MediaAddExtraFile"
+	SDKService_MediaFiles_FullMethodName          = "/proto.SDKService/MediaFiles"
+	SDKService_DownloadSync_FullMethodName        = "/proto.SDKService/DownloadSync"
+	SDKService_ImportRequest_FullMethodName       = "/proto.SDKService/ImportRequest"
+	SDKService_ImportFailed_FullMethodName        = "/proto.SDKService/ImportFailed"
 )
 
 // SDKServiceClient is the client API for SDKService service.
@@ -407,6 +455,16 @@ type SDKServiceClient interface {
 	ConfigGetString(ctx context.Context, in *ConfigGetStringRequest, opts ...grpc.CallOption) (*ConfigGetStringResponse, error)
 	ConfigSet(ctx context.Context, in *ConfigSetRequest, opts ...grpc.CallOption) (*ConfigSetResponse, error)
 	ConfigDelete(ctx context.Context, in *ConfigDeleteRequest, opts ...grpc.CallOption) (*ConfigDeleteResponse, error)
+	ConfigGetSecret(ctx context.Context, in *ConfigGetSecretRequest, opts ...grpc.CallOption) (*ConfigGetSecretResponse, error)
+	ConfigSetSecret(ctx context.Context, in *ConfigSetSecretRequest, opts ...grpc.CallOption) (*ConfigSetSecretResponse, error)
+	MediaGet(ctx context.Context, in *MediaGetRequest, opts ...grpc.CallOption) (*MediaGetResponse, error)
+	MediaList(ctx context.Context, in *MediaListRequest, opts ...grpc.CallOption) (*MediaListResponse, error)
+	MediaUpdateMetadata(ctx context.Context, in *MediaUpdateMetadataRequest, opts ...grpc.CallOption) (*MediaUpdateMetadataResponse, error)
+	MediaAddExtraFile(ctx context.Context, in *MediaAddExtraFileRequest, opts ...grpc.CallOption) (*MediaAddExtraFileResponse, error)
+	MediaFiles(ctx context.Context, in *MediaFilesRequest, opts ...grpc.CallOption) (*MediaFilesResponse, error)
+	DownloadSync(ctx context.Context, in *DownloadSyncRequest, opts ...grpc.CallOption) (*DownloadSyncResponse, error)
+	ImportRequest(ctx context.Context, in *ImportFileRequest, opts ...grpc.CallOption) (*ImportFileResponse, error)
+	ImportFailed(ctx context.Context, in *ImportFailedRequest, opts ...grpc.CallOption) (*ImportFailedResponse, error)
 }
 
 type sDKServiceClient struct {
@@ -457,6 +515,106 @@ func (c *sDKServiceClient) ConfigDelete(ctx context.Context, in *ConfigDeleteReq
 	return out, nil
 }
 
+func (c *sDKServiceClient) ConfigGetSecret(ctx context.Context, in *ConfigGetSecretRequest, opts ...grpc.CallOption) (*ConfigGetSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigGetSecretResponse)
+	err := c.cc.Invoke(ctx, SDKService_ConfigGetSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) ConfigSetSecret(ctx context.Context, in *ConfigSetSecretRequest, opts ...grpc.CallOption) (*ConfigSetSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigSetSecretResponse)
+	err := c.cc.Invoke(ctx, SDKService_ConfigSetSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) MediaGet(ctx context.Context, in *MediaGetRequest, opts ...grpc.CallOption) (*MediaGetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MediaGetResponse)
+	err := c.cc.Invoke(ctx, SDKService_MediaGet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) MediaList(ctx context.Context, in *MediaListRequest, opts ...grpc.CallOption) (*MediaListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MediaListResponse)
+	err := c.cc.Invoke(ctx, SDKService_MediaList_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) MediaUpdateMetadata(ctx context.Context, in *MediaUpdateMetadataRequest, opts ...grpc.CallOption) (*MediaUpdateMetadataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MediaUpdateMetadataResponse)
+	err := c.cc.Invoke(ctx, SDKService_MediaUpdateMetadata_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) MediaAddExtraFile(ctx context.Context, in *MediaAddExtraFileRequest, opts ...grpc.CallOption) (*MediaAddExtraFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MediaAddExtraFileResponse)
+	err := c.cc.Invoke(ctx, SDKService_MediaAddExtraFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) DownloadSync(ctx context.Context, in *DownloadSyncRequest, opts ...grpc.CallOption) (*DownloadSyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DownloadSyncResponse)
+	err := c.cc.Invoke(ctx, SDKService_DownloadSync_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) ImportRequest(ctx context.Context, in *ImportFileRequest, opts ...grpc.CallOption) (*ImportFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportFileResponse)
+	err := c.cc.Invoke(ctx, SDKService_ImportRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) ImportFailed(ctx context.Context, in *ImportFailedRequest, opts ...grpc.CallOption) (*ImportFailedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportFailedResponse)
+	err := c.cc.Invoke(ctx, SDKService_ImportFailed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKServiceClient) MediaFiles(ctx context.Context, in *MediaFilesRequest, opts ...grpc.CallOption) (*MediaFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MediaFilesResponse)
+	err := c.cc.Invoke(ctx, SDKService_MediaFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SDKServiceServer is the server API for SDKService service.
 // All implementations must embed UnimplementedSDKServiceServer
 // for forward compatibility.
@@ -467,6 +625,16 @@ type SDKServiceServer interface {
 	ConfigGetString(context.Context, *ConfigGetStringRequest) (*ConfigGetStringResponse, error)
 	ConfigSet(context.Context, *ConfigSetRequest) (*ConfigSetResponse, error)
 	ConfigDelete(context.Context, *ConfigDeleteRequest) (*ConfigDeleteResponse, error)
+	ConfigGetSecret(context.Context, *ConfigGetSecretRequest) (*ConfigGetSecretResponse, error)
+	ConfigSetSecret(context.Context, *ConfigSetSecretRequest) (*ConfigSetSecretResponse, error)
+	MediaGet(context.Context, *MediaGetRequest) (*MediaGetResponse, error)
+	MediaList(context.Context, *MediaListRequest) (*MediaListResponse, error)
+	MediaUpdateMetadata(context.Context, *MediaUpdateMetadataRequest) (*MediaUpdateMetadataResponse, error)
+	MediaAddExtraFile(context.Context, *MediaAddExtraFileRequest) (*MediaAddExtraFileResponse, error)
+	MediaFiles(context.Context, *MediaFilesRequest) (*MediaFilesResponse, error)
+	DownloadSync(context.Context, *DownloadSyncRequest) (*DownloadSyncResponse, error)
+	ImportRequest(context.Context, *ImportFileRequest) (*ImportFileResponse, error)
+	ImportFailed(context.Context, *ImportFailedRequest) (*ImportFailedResponse, error)
 	mustEmbedUnimplementedSDKServiceServer()
 }
 
@@ -489,6 +657,36 @@ func (UnimplementedSDKServiceServer) ConfigSet(context.Context, *ConfigSetReques
 func (UnimplementedSDKServiceServer) ConfigDelete(context.Context, *ConfigDeleteRequest) (*ConfigDeleteResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ConfigDelete not implemented")
 }
+func (UnimplementedSDKServiceServer) ConfigGetSecret(context.Context, *ConfigGetSecretRequest) (*ConfigGetSecretResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ConfigGetSecret not implemented")
+}
+func (UnimplementedSDKServiceServer) ConfigSetSecret(context.Context, *ConfigSetSecretRequest) (*ConfigSetSecretResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ConfigSetSecret not implemented")
+}
+func (UnimplementedSDKServiceServer) MediaGet(context.Context, *MediaGetRequest) (*MediaGetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MediaGet not implemented")
+}
+func (UnimplementedSDKServiceServer) MediaList(context.Context, *MediaListRequest) (*MediaListResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MediaList not implemented")
+}
+func (UnimplementedSDKServiceServer) MediaUpdateMetadata(context.Context, *MediaUpdateMetadataRequest) (*MediaUpdateMetadataResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MediaUpdateMetadata not implemented")
+}
+func (UnimplementedSDKServiceServer) MediaAddExtraFile(context.Context, *MediaAddExtraFileRequest) (*MediaAddExtraFileResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MediaAddExtraFile not implemented")
+}
+func (UnimplementedSDKServiceServer) DownloadSync(context.Context, *DownloadSyncRequest) (*DownloadSyncResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DownloadSync not implemented")
+}
+func (UnimplementedSDKServiceServer) ImportRequest(context.Context, *ImportFileRequest) (*ImportFileResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ImportRequest not implemented")
+}
+func (UnimplementedSDKServiceServer) ImportFailed(context.Context, *ImportFailedRequest) (*ImportFailedResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ImportFailed not implemented")
+}
+func (UnimplementedSDKServiceServer) MediaFiles(context.Context, *MediaFilesRequest) (*MediaFilesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MediaFiles not implemented")
+}
 func (UnimplementedSDKServiceServer) mustEmbedUnimplementedSDKServiceServer() {}
 func (UnimplementedSDKServiceServer) testEmbeddedByValue()                    {}
 
@@ -582,6 +780,186 @@ func _SDKService_ConfigDelete_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SDKService_ConfigGetSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigGetSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).ConfigGetSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_ConfigGetSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).ConfigGetSecret(ctx, req.(*ConfigGetSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_ConfigSetSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigSetSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).ConfigSetSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_ConfigSetSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).ConfigSetSecret(ctx, req.(*ConfigSetSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_MediaGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MediaGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).MediaGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_MediaGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).MediaGet(ctx, req.(*MediaGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_MediaList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MediaListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).MediaList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_MediaList_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).MediaList(ctx, req.(*MediaListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_MediaUpdateMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MediaUpdateMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).MediaUpdateMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_MediaUpdateMetadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).MediaUpdateMetadata(ctx, req.(*MediaUpdateMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_MediaAddExtraFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MediaAddExtraFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).MediaAddExtraFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_MediaAddExtraFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).MediaAddExtraFile(ctx, req.(*MediaAddExtraFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_DownloadSync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadSyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).DownloadSync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_DownloadSync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).DownloadSync(ctx, req.(*DownloadSyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_ImportRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).ImportRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_ImportRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).ImportRequest(ctx, req.(*ImportFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_ImportFailed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportFailedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).ImportFailed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_ImportFailed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).ImportFailed(ctx, req.(*ImportFailedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDKService_MediaFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MediaFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServiceServer).MediaFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SDKService_MediaFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServiceServer).MediaFiles(ctx, req.(*MediaFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SDKService_ServiceDesc is the grpc.ServiceDesc for SDKService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -605,6 +983,46 @@ var SDKService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ConfigDelete",
 			Handler:    _SDKService_ConfigDelete_Handler,
 		},
+		{
+			MethodName: "ConfigGetSecret",
+			Handler:    _SDKService_ConfigGetSecret_Handler,
+		},
+		{
+			MethodName: "ConfigSetSecret",
+			Handler:    _SDKService_ConfigSetSecret_Handler,
+		},
+		{
+			MethodName: "MediaGet",
+			Handler:    _SDKService_MediaGet_Handler,
+		},
+		{
+			MethodName: "MediaList",
+			Handler:    _SDKService_MediaList_Handler,
+		},
+		{
+			MethodName: "MediaUpdateMetadata",
+			Handler:    _SDKService_MediaUpdateMetadata_Handler,
+		},
+		{
+			MethodName: "MediaAddExtraFile",
+			Handler:    _SDKService_MediaAddExtraFile_Handler,
+		},
+		{
+			MethodName: "MediaFiles",
+			Handler:    _SDKService_MediaFiles_Handler,
+		},
+		{
+			MethodName: "DownloadSync",
+			Handler:    _SDKService_DownloadSync_Handler,
+		},
+		{
+			MethodName: "ImportRequest",
+			Handler:    _SDKService_ImportRequest_Handler,
+		},
+		{
+			MethodName: "ImportFailed",
+			Handler:    _SDKService_ImportFailed_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/plugins/proto/plugin.proto",
@@ -0,0 +1,141 @@
+package plugins
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrRouteNotFound is returned when no running plugin declares a path
+	ErrRouteNotFound = errors.New("no plugin route matches the path")
+
+	// ErrMethodNotAllowed is returned when a plugin declares a path, but not
+	// for the request's method
+	ErrMethodNotAllowed = errors.New("method not allowed for plugin route")
+)
+
+// MatchRoute finds the running plugin and declared route that handle a
+// request. Routes are looked up on every request, so plugins that are loaded,
+// restarted or unloaded take effect immediately. When several patterns match,
+// the one with static segments earliest wins, like in the host router.
+func (pm *PluginManager) MatchRoute(method, path string) (*LoadedPlugin, RouteDescriptor, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	segments := splitPath(path)
+
+	var (
+		bestPlugin   *LoadedPlugin
+		bestRoute    RouteDescriptor
+		bestSegments []string
+		pathMatched  bool
+	)
+	for _, lp := range pm.plugins {
+		for _, route := range lp.Routes {
+			pattern := splitPath(route.Path)
+			if !matchSegments(pattern, segments) {
+				continue
+			}
+			pathMatched = true
+			if !strings.EqualFold(route.Method, method) {
+				continue
+			}
+			if bestPlugin == nil || moreSpecific(pattern, bestSegments) {
+				bestPlugin, bestRoute, bestSegments = lp, route, pattern
+			}
+		}
+	}
+
+	switch {
+	case bestPlugin != nil:
+		return bestPlugin, bestRoute, nil
+	case pathMatched:
+		return nil, RouteDescriptor{}, ErrMethodNotAllowed
+	default:
+		return nil, RouteDescriptor{}, ErrRouteNotFound
+	}
+}
+
+// ResolveRoute finds which of a plugin's declared routes handles a request
+// and the values of its placeholders. The host calls plugins with just a
+// path, which is resolved the way MatchRoute resolves requests from clients.
+func ResolveRoute(routes []RouteDescriptor, method, path string) (RouteDescriptor, map[string]string, bool) {
+	segments := splitPath(path)
+
+	var best RouteDescriptor
+	var bestSegments []string
+	found := false
+	for _, route := range routes {
+		pattern := splitPath(route.Path)
+		if !strings.EqualFold(route.Method, method) || !matchSegments(pattern, segments) {
+			continue
+		}
+		if !found || moreSpecific(pattern, bestSegments) {
+			best, bestSegments, found = route, pattern, true
+		}
+	}
+	if !found {
+		return RouteDescriptor{}, nil, false
+	}
+	params, _ := MatchPath(best.Path, path)
+	return best, params, true
+}
+
+// MatchPath matches a path against a route pattern and returns the values of
+// the pattern's {name} placeholders. A trailing slash on either is ignored.
+func MatchPath(pattern, path string) (map[string]string, bool) {
+	segments := splitPath(pattern)
+	values := splitPath(path)
+	if !matchSegments(segments, values) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range segments {
+		if isParam(segment) {
+			params[segment[1:len(segment)-1]] = values[i]
+		}
+	}
+	return params, true
+}
+
+// splitPath splits a path into its segments, ignoring a trailing slash
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// isParam reports whether a pattern segment is a {name} placeholder
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// matchSegments reports whether a path matches a route pattern. Placeholders
+// match exactly one non-empty segment.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, segment := range pattern {
+		if path[i] == "" {
+			return false
+		}
+		if !isParam(segment) && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moreSpecific reports whether pattern a should win over pattern b, both
+// matching the same path: the first segment where only one is static decides
+func moreSpecific(a, b []string) bool {
+	for i := range a {
+		if isParam(a[i]) != isParam(b[i]) {
+			return !isParam(a[i])
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/plugins/proto"
+	"google.golang.org/grpc"
+)
+
+func TestMatchRoute(t *testing.T) {
+	pm := &PluginManager{plugins: map[string]*LoadedPlugin{
+		"nzb-downloader": {
+			Meta: &PluginMetadata{ID: "nzb-downloader"},
+			Routes: []RouteDescriptor{
+				{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads"},
+				{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/{id}"},
+				{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/stream"},
+				{Method: "DELETE", Path: "/api/plugins/nzb-downloader/downloads/{id}"},
+			},
+		},
+		"usenet-indexer": {
+			Meta: &PluginMetadata{ID: "usenet-indexer"},
+			Routes: []RouteDescriptor{
+				{Method: "GET", Path: "/api/plugins/usenet-indexer/indexers"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		method, path string
+		wantPlugin   string
+		wantPath     string
+		wantErr      error
+	}{
+		{"GET", "/api/plugins/nzb-downloader/downloads", "nzb-downloader", "/api/plugins/nzb-downloader/downloads", nil},
+		{"GET", "/api/plugins/nzb-downloader/downloads/", "nzb-downloader", "/api/plugins/nzb-downloader/downloads", nil},
+		{"GET", "/api/plugins/nzb-downloader/downloads/abc", "nzb-downloader", "/api/plugins/nzb-downloader/downloads/{id}", nil},
+		{"GET", "/api/plugins/nzb-downloader/downloads/stream", "nzb-downloader", "/api/plugins/nzb-downloader/downloads/stream", nil},
+		{"delete", "/api/plugins/nzb-downloader/downloads/abc", "nzb-downloader", "/api/plugins/nzb-downloader/downloads/{id}", nil},
+		{"GET", "/api/plugins/usenet-indexer/indexers", "usenet-indexer", "/api/plugins/usenet-indexer/indexers", nil},
+		{"POST", "/api/plugins/usenet-indexer/indexers", "", "", ErrMethodNotAllowed},
+		{"GET", "/api/plugins/nzb-downloader/downloads/abc/logs", "", "", ErrRouteNotFound},
+		{"GET", "/api/plugins/removed/anything", "", "", ErrRouteNotFound},
+	}
+	for _, tt := range tests {
+		lp, route, err := pm.MatchRoute(tt.method, tt.path)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("MatchRoute(%s %s) error = %v, want %v", tt.method, tt.path, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if lp.Meta.ID != tt.wantPlugin || route.Path != tt.wantPath {
+			t.Errorf("MatchRoute(%s %s) = %s %s, want %s %s",
+				tt.method, tt.path, lp.Meta.ID, route.Path, tt.wantPlugin, tt.wantPath)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          map[string]string
+		ok            bool
+	}{
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/tv/1399", map[string]string{"id": "1399"}, true},
+		{"/api/plugins/tmdb/tv/{id}/", "/api/plugins/tmdb/tv/1399", map[string]string{"id": "1399"}, true},
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/tv/1399/", map[string]string{"id": "1399"}, true},
+		{"/api/plugins/tmdb/tv/{id}/season/{season}/episode/{episode}", "/api/plugins/tmdb/tv/1399/season/1/episode/2",
+			map[string]string{"id": "1399", "season": "1", "episode": "2"}, true},
+		{"/api/plugins/nzb-downloader/downloads", "/api/plugins/nzb-downloader/downloads/", map[string]string{}, true},
+		{"/api/plugins/nzb-downloader/downloads/move", "/api/plugins/nzb-downloader/downloads/move", map[string]string{}, true},
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/tv", nil, false},
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/tv//", nil, false},
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/tv/1399/season/1", nil, false},
+		{"/api/plugins/tmdb/tv/{id}", "/api/plugins/tmdb/movie/1399", nil, false},
+	}
+	for _, tt := range tests {
+		params, ok := MatchPath(tt.pattern, tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(params, tt.want) {
+			t.Errorf("MatchPath(%q, %q) = %v, %v, want %v, %v", tt.pattern, tt.path, params, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatchRouteOverlapping(t *testing.T) {
+	// Routes whose patterns overlap resolve to the most specific one, whatever
+	// the order they are declared in
+	pm := &PluginManager{plugins: map[string]*LoadedPlugin{
+		"nzb-downloader": {
+			Meta: &PluginMetadata{ID: "nzb-downloader"},
+			Routes: []RouteDescriptor{
+				{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/{action}"},
+				{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/pause"},
+				{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}"},
+				{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/move"},
+				{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/move/{id}"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		path, wantRoute string
+		wantParams      map[string]string
+	}{
+		{"/api/plugins/nzb-downloader/downloads/move", "/api/plugins/nzb-downloader/downloads/move", map[string]string{}},
+		{"/api/plugins/nzb-downloader/downloads/move/", "/api/plugins/nzb-downloader/downloads/move", map[string]string{}},
+		{"/api/plugins/nzb-downloader/downloads/dl_1", "/api/plugins/nzb-downloader/downloads/{id}", map[string]string{"id": "dl_1"}},
+		{"/api/plugins/nzb-downloader/downloads/move/pause", "/api/plugins/nzb-downloader/downloads/move/{id}", map[string]string{"id": "pause"}},
+		{"/api/plugins/nzb-downloader/downloads/dl_1/pause", "/api/plugins/nzb-downloader/downloads/{id}/pause", map[string]string{"id": "dl_1"}},
+		{"/api/plugins/nzb-downloader/downloads/dl_1/retry", "/api/plugins/nzb-downloader/downloads/{id}/{action}",
+			map[string]string{"id": "dl_1", "action": "retry"}},
+	}
+	for _, tt := range tests {
+		_, route, err := pm.MatchRoute("POST", tt.path)
+		if err != nil {
+			t.Errorf("MatchRoute(%s) error = %v", tt.path, err)
+			continue
+		}
+		params, _ := MatchPath(route.Path, tt.path)
+		if route.Path != tt.wantRoute || !reflect.DeepEqual(params, tt.wantParams) {
+			t.Errorf("MatchRoute(%s) = %s %v, want %s %v", tt.path, route.Path, params, tt.wantRoute, tt.wantParams)
+		}
+	}
+}
+
+func TestRequestScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		want   []string
+	}{
+		{"signed out", nil, nil},
+		{"user", &auth.Claims{UserID: 2}, []string{ScopeUser}},
+		{"admin", &auth.Claims{UserID: 1, IsAdmin: true}, []string{ScopeUser, ScopeAdmin}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/plugins/nzb-downloader/servers", nil)
+			if tt.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "user", tt.claims))
+			}
+			userID, scopes := requestScopes(r)
+			if !reflect.DeepEqual(scopes, tt.want) {
+				t.Errorf("scopes = %v, want %v", scopes, tt.want)
+			}
+			if tt.claims != nil && (userID == nil || *userID != tt.claims.UserID) {
+				t.Errorf("userID = %v, want %d", userID, tt.claims.UserID)
+			}
+
+			req := &PluginHTTPRequest{Scopes: scopes}
+			resp := RequireScope(req, ScopeAdmin)
+			if isAdmin := tt.claims != nil && tt.claims.IsAdmin; isAdmin != (resp == nil) {
+				t.Errorf("RequireScope(admin) = %v for admin %v", resp, isAdmin)
+			} else if resp != nil && resp.StatusCode != http.StatusForbidden {
+				t.Errorf("RequireScope(admin) status = %d, want 403", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// routeRecorder is a plugin client that keeps the last API request it got
+type routeRecorder struct {
+	proto.PluginServiceClient
+	routes []*proto.RouteDescriptor
+	got    *proto.HandleAPIRequest
+}
+
+func (r *routeRecorder) APIRoutes(ctx context.Context, in *proto.APIRoutesRequest, opts ...grpc.CallOption) (*proto.APIRoutesResponse, error) {
+	return &proto.APIRoutesResponse{Routes: r.routes}, nil
+}
+
+func (r *routeRecorder) HandleAPI(ctx context.Context, in *proto.HandleAPIRequest, opts ...grpc.CallOption) (*proto.HandleAPIResponse, error) {
+	r.got = in
+	return &proto.HandleAPIResponse{StatusCode: http.StatusOK}, nil
+}
+
+func TestHandleAPIResolvesRoute(t *testing.T) {
+	recorder := &routeRecorder{routes: []*proto.RouteDescriptor{
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads"},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/{id}"},
+		{Method: "DELETE", Path: "/api/plugins/nzb-downloader/downloads/{id}"},
+	}}
+	client := &GRPCClient{client: recorder}
+	ctx := context.Background()
+	if _, err := client.APIRoutes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The host's own calls only set the path
+	if _, err := client.HandleAPI(ctx, &PluginHTTPRequest{Method: "DELETE", Path: "/api/plugins/nzb-downloader/downloads/abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.got.Route != "/api/plugins/nzb-downloader/downloads/{id}" || recorder.got.PathParams["id"] != "abc" {
+		t.Errorf("route = %q, params %v, want the download route with its id", recorder.got.Route, recorder.got.PathParams)
+	}
+
+	if _, err := client.HandleAPI(ctx, &PluginHTTPRequest{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads"}); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.got.Route != "/api/plugins/nzb-downloader/downloads" || len(recorder.got.PathParams) != 0 {
+		t.Errorf("route = %q, params %v, want the downloads route", recorder.got.Route, recorder.got.PathParams)
+	}
+
+	// Requests routed by the host router keep their route
+	if _, err := client.HandleAPI(ctx, &PluginHTTPRequest{Method: "GET", Path: "/x", Route: "/api/plugins/nzb-downloader/downloads"}); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.got.Route != "/api/plugins/nzb-downloader/downloads" {
+		t.Errorf("route = %q, want the one given", recorder.got.Route)
+	}
+
+	if _, err := client.HandleAPI(ctx, &PluginHTTPRequest{Method: "POST", Path: "/api/plugins/nzb-downloader/unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.got.Route != "" {
+		t.Errorf("route of an undeclared path = %q, want none", recorder.got.Route)
+	}
+}
@@ -3,12 +3,16 @@ package plugins
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/plugins/proto"
 	"github.com/hashicorp/go-plugin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Handshake is a common handshake that is shared by plugin and host.
@@ -82,6 +86,7 @@ func (s *GRPCServer) APIRoutes(ctx context.Context, req *proto.APIRoutesRequest)
 			Path:   r.Path,
 			Auth:   r.Auth,
 			Tag:    r.Tag,
+			Role:   r.Role,
 		}
 	}
 
@@ -92,12 +97,14 @@ func (s *GRPCServer) APIRoutes(ctx context.Context, req *proto.APIRoutesRequest)
 func (s *GRPCServer) HandleAPI(ctx context.Context, req *proto.HandleAPIRequest) (*proto.HandleAPIResponse, error) {
 	// Convert proto request to plugin request
 	pluginReq := &PluginHTTPRequest{
-		Method:  req.Method,
-		Path:    req.Path,
-		Query:   make(map[string][]string),
-		Headers: make(map[string][]string),
-		Body:    req.Body,
-		Scopes:  req.Scopes,
+		Method:     req.Method,
+		Path:       req.Path,
+		Query:      make(map[string][]string),
+		Headers:    make(map[string][]string),
+		Body:       req.Body,
+		Scopes:     req.Scopes,
+		Route:      req.Route,
+		PathParams: req.PathParams,
 	}
 
 	// Convert query parameters
@@ -209,9 +216,10 @@ func (s *GRPCServer) UIManifest(ctx context.Context, req *proto.UIManifestReques
 		}
 
 		resp.ConfigSection = &proto.ConfigSection{
-			Title:       manifest.ConfigSection.Title,
-			Description: manifest.ConfigSection.Description,
-			Fields:      fields,
+			Title:         manifest.ConfigSection.Title,
+			Description:   manifest.ConfigSection.Description,
+			Fields:        fields,
+			SchemaVersion: int32(manifest.ConfigSection.SchemaVersion),
 		}
 	}
 
@@ -237,6 +245,16 @@ func (s *GRPCServer) HandleEvent(ctx context.Context, req *proto.HandleEventRequ
 		Timestamp: time.Unix(req.Timestamp, 0),
 	}
 
+	// Connect to SDK server if host provided one
+	if req.SdkServerId != 0 && s.Broker != nil {
+		conn, err := s.Broker.Dial(req.SdkServerId)
+		if err == nil {
+			evt.SDK = &GRPCSDKClient{
+				client: proto.NewSDKServiceClient(conn),
+			}
+		}
+	}
+
 	err := s.Impl.HandleEvent(ctx, evt)
 	if err != nil {
 		return &proto.HandleEventResponse{
@@ -274,21 +292,63 @@ func (s *GRPCServer) IsDownloader(ctx context.Context, req *proto.IsDownloaderRe
 	return &proto.IsDownloaderResponse{IsDownloader: isDownloader}, nil
 }
 
+// MigrateConfig implements the MigrateConfig RPC for plugins that implement
+// ConfigMigrator
+func (s *GRPCServer) MigrateConfig(ctx context.Context, req *proto.MigrateConfigRequest) (*proto.MigrateConfigResponse, error) {
+	migrator, ok := s.Impl.(ConfigMigrator)
+	if !ok {
+		return &proto.MigrateConfigResponse{Supported: false}, nil
+	}
+
+	var config map[string]interface{}
+	if len(req.Config) > 0 {
+		if err := json.Unmarshal(req.Config, &config); err != nil {
+			return &proto.MigrateConfigResponse{Supported: true, Error: fmt.Sprintf("invalid config: %v", err)}, nil
+		}
+	}
+
+	migrated, err := migrator.MigrateConfig(ctx, int(req.FromVersion), config)
+	if err != nil {
+		return &proto.MigrateConfigResponse{Supported: true, Error: err.Error()}, nil
+	}
+
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		return &proto.MigrateConfigResponse{Supported: true, Error: fmt.Sprintf("failed to encode config: %v", err)}, nil
+	}
+
+	return &proto.MigrateConfigResponse{Supported: true, Config: data}, nil
+}
+
 // Search implements the Search RPC
 func (s *GRPCServer) Search(ctx context.Context, req *proto.IndexerSearchRequest) (*proto.IndexerSearchResponse, error) {
 	// Convert proto request to plugin request
 	searchReq := &IndexerSearchRequest{
-		Query:      req.Query,
-		Type:       req.Type,
-		Categories: req.Categories,
-		TVDBID:     req.Tvdbid,
-		TVRageID:   req.Tvrageid,
-		Season:     int(req.Season),
-		Episode:    int(req.Episode),
-		IMDBID:     req.Imdbid,
-		TMDBID:     req.Tmdbid,
-		Limit:      int(req.Limit),
-		Offset:     int(req.Offset),
+		Query:          req.Query,
+		Type:           req.Type,
+		Categories:     req.Categories,
+		TVDBID:         req.Tvdbid,
+		TVRageID:       req.Tvrageid,
+		Season:         int(req.Season),
+		Episode:        int(req.Episode),
+		IMDBID:         req.Imdbid,
+		TMDBID:         req.Tmdbid,
+		Limit:          int(req.Limit),
+		Offset:         int(req.Offset),
+		Fresh:          req.Fresh,
+		MinimumSeeders: int(req.MinSeeders),
+		SDKServerID:    req.SdkServerId,
+	}
+
+	// Connect to SDK server if host provided one
+	if req.SdkServerId != 0 && s.Broker != nil {
+		conn, err := s.Broker.Dial(req.SdkServerId)
+		if err == nil {
+			searchReq.SDK = &GRPCSDKClient{
+				client: proto.NewSDKServiceClient(conn),
+			}
+		}
+		// If SDK connection fails, continue without SDK (plugin can handle missing SDK)
 	}
 
 	// Call plugin implementation
@@ -316,6 +376,14 @@ func (s *GRPCServer) Search(ctx context.Context, req *proto.IndexerSearchRequest
 			IndexerId:   release.IndexerID,
 			IndexerName: release.IndexerName,
 		}
+		for _, source := range release.AlsoAvailableOn {
+			protoReleases[i].AlsoAvailableOn = append(protoReleases[i].AlsoAvailableOn, &proto.ReleaseSource{
+				IndexerId:   source.IndexerID,
+				IndexerName: source.IndexerName,
+				Guid:        source.GUID,
+				DownloadUrl: source.DownloadURL,
+			})
+		}
 	}
 
 	return &proto.IndexerSearchResponse{
@@ -331,6 +399,11 @@ type GRPCClient struct {
 	client proto.PluginServiceClient
 	broker *plugin.GRPCBroker
 	sdk    *SDK // SDK to expose to plugin (host-side only)
+
+	// routes are the plugin's declared API routes, kept from APIRoutes to
+	// resolve the route of requests the host makes itself
+	routesMu sync.RWMutex
+	routes   []RouteDescriptor
 }
 
 // Metadata calls the plugin's Metadata method
@@ -363,9 +436,14 @@ func (c *GRPCClient) APIRoutes(ctx context.Context) ([]RouteDescriptor, error) {
 			Path:   r.Path,
 			Auth:   r.Auth,
 			Tag:    r.Tag,
+			Role:   r.Role,
 		}
 	}
 
+	c.routesMu.Lock()
+	c.routes = routes
+	c.routesMu.Unlock()
+
 	return routes, nil
 }
 
@@ -383,12 +461,26 @@ func (c *GRPCClient) HandleAPI(ctx context.Context, req *PluginHTTPRequest) (*Pl
 	}
 
 	protoReq := &proto.HandleAPIRequest{
-		Method:  req.Method,
-		Path:    req.Path,
-		Query:   protoQuery,
-		Headers: protoHeaders,
-		Body:    req.Body,
-		Scopes:  req.Scopes,
+		Method:     req.Method,
+		Path:       req.Path,
+		Query:      protoQuery,
+		Headers:    protoHeaders,
+		Body:       req.Body,
+		Scopes:     req.Scopes,
+		Route:      req.Route,
+		PathParams: req.PathParams,
+	}
+
+	// Requests the host makes itself only carry a path, while plugins
+	// dispatch on the route it matches
+	if protoReq.Route == "" {
+		c.routesMu.RLock()
+		route, params, ok := ResolveRoute(c.routes, req.Method, req.Path)
+		c.routesMu.RUnlock()
+		if ok {
+			protoReq.Route = route.Path
+			protoReq.PathParams = params
+		}
 	}
 
 	if req.UserID != nil {
@@ -396,18 +488,7 @@ func (c *GRPCClient) HandleAPI(ctx context.Context, req *PluginHTTPRequest) (*Pl
 	}
 
 	// Start SDK server on host side if SDK is available
-	if c.sdk != nil && c.broker != nil {
-		sdkServerID := c.broker.NextId()
-		// Start SDK server in background - it will accept connections from plugin
-		go c.broker.AcceptAndServe(sdkServerID, func(opts []grpc.ServerOption) *grpc.Server {
-			server := grpc.NewServer(opts...)
-			proto.RegisterSDKServiceServer(server, &GRPCSDKServer{SDK: c.sdk})
-			return server
-		})
-		// Give the server a moment to start accepting
-		time.Sleep(50 * time.Millisecond)
-		protoReq.SdkServerId = sdkServerID
-	}
+	protoReq.SdkServerId = c.serveSDK()
 
 	// Call plugin
 	resp, err := c.client.HandleAPI(ctx, protoReq)
@@ -491,9 +572,10 @@ func (c *GRPCClient) UIManifest(ctx context.Context) (*UIManifest, error) {
 		}
 
 		manifest.ConfigSection = &ConfigSection{
-			Title:       resp.ConfigSection.Title,
-			Description: resp.ConfigSection.Description,
-			Fields:      fields,
+			Title:         resp.ConfigSection.Title,
+			Description:   resp.ConfigSection.Description,
+			Fields:        fields,
+			SchemaVersion: int(resp.ConfigSection.SchemaVersion),
 		}
 	}
 
@@ -509,9 +591,10 @@ func (c *GRPCClient) HandleEvent(ctx context.Context, evt Event) error {
 	}
 
 	resp, err := c.client.HandleEvent(ctx, &proto.HandleEventRequest{
-		Type:      evt.Type,
-		Data:      data,
-		Timestamp: evt.Timestamp.Unix(),
+		Type:        evt.Type,
+		Data:        data,
+		Timestamp:   evt.Timestamp.Unix(),
+		SdkServerId: c.serveSDK(),
 	})
 	if err != nil {
 		return err
@@ -552,6 +635,60 @@ func (c *GRPCClient) IsDownloader(ctx context.Context) (bool, error) {
 	return resp.IsDownloader, nil
 }
 
+// MigrateConfig calls the plugin's MigrateConfig method. It returns
+// ErrConfigMigrationUnsupported when the plugin doesn't migrate config, which
+// plugins built before the RPC existed don't.
+func (c *GRPCClient) MigrateConfig(ctx context.Context, fromVersion int, config map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	resp, err := c.client.MigrateConfig(ctx, &proto.MigrateConfigRequest{
+		FromVersion: int32(fromVersion),
+		Config:      data,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, ErrConfigMigrationUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Supported {
+		return nil, ErrConfigMigrationUnsupported
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin error: %s", resp.Error)
+	}
+
+	var migrated map[string]interface{}
+	if len(resp.Config) > 0 {
+		if err := json.Unmarshal(resp.Config, &migrated); err != nil {
+			return nil, fmt.Errorf("failed to decode migrated config: %w", err)
+		}
+	}
+	return migrated, nil
+}
+
+// serveSDK starts an SDK server on the broker for the plugin to dial and
+// returns its ID, or 0 when no SDK is available
+func (c *GRPCClient) serveSDK() uint32 {
+	if c.sdk == nil || c.broker == nil {
+		return 0
+	}
+
+	sdkServerID := c.broker.NextId()
+	// Start SDK server in background - it will accept connections from plugin
+	go c.broker.AcceptAndServe(sdkServerID, func(opts []grpc.ServerOption) *grpc.Server {
+		server := grpc.NewServer(opts...)
+		proto.RegisterSDKServiceServer(server, &GRPCSDKServer{SDK: c.sdk})
+		return server
+	})
+	// Give the server a moment to start accepting
+	time.Sleep(50 * time.Millisecond)
+	return sdkServerID
+}
+
 // Search calls the plugin's Search method
 func (c *GRPCClient) Search(ctx context.Context, req *IndexerSearchRequest) (*IndexerSearchResponse, error) {
 	// Convert to proto request
@@ -567,8 +704,13 @@ func (c *GRPCClient) Search(ctx context.Context, req *IndexerSearchRequest) (*In
 		Tmdbid:     req.TMDBID,
 		Limit:      int32(req.Limit),
 		Offset:     int32(req.Offset),
+		Fresh:      req.Fresh,
+		MinSeeders: int32(req.MinimumSeeders),
 	}
 
+	// Start SDK server on host side so the plugin can read its config
+	protoReq.SdkServerId = c.serveSDK()
+
 	// Call plugin
 	resp, err := c.client.Search(ctx, protoReq)
 	if err != nil {
@@ -596,6 +738,14 @@ func (c *GRPCClient) Search(ctx context.Context, req *IndexerSearchRequest) (*In
 			IndexerID:   protoRelease.IndexerId,
 			IndexerName: protoRelease.IndexerName,
 		}
+		for _, source := range protoRelease.AlsoAvailableOn {
+			releases[i].AlsoAvailableOn = append(releases[i].AlsoAvailableOn, ReleaseSource{
+				IndexerID:   source.IndexerId,
+				IndexerName: source.IndexerName,
+				GUID:        source.Guid,
+				DownloadURL: source.DownloadUrl,
+			})
+		}
 	}
 
 	return &IndexerSearchResponse{
@@ -664,6 +814,247 @@ func (s *GRPCSDKServer) ConfigDelete(ctx context.Context, req *proto.ConfigDelet
 	return &proto.ConfigDeleteResponse{}, nil
 }
 
+// ConfigGetSecret implements the ConfigGetSecret RPC
+func (s *GRPCSDKServer) ConfigGetSecret(ctx context.Context, req *proto.ConfigGetSecretRequest) (*proto.ConfigGetSecretResponse, error) {
+	value, err := s.SDK.ConfigGetSecret(ctx, req.Key)
+	if err != nil {
+		return &proto.ConfigGetSecretResponse{Error: err.Error()}, nil
+	}
+	if value == nil {
+		return &proto.ConfigGetSecretResponse{}, nil
+	}
+
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return &proto.ConfigGetSecretResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.ConfigGetSecretResponse{Value: jsonValue}, nil
+}
+
+// ConfigSetSecret implements the ConfigSetSecret RPC
+func (s *GRPCSDKServer) ConfigSetSecret(ctx context.Context, req *proto.ConfigSetSecretRequest) (*proto.ConfigSetSecretResponse, error) {
+	var value interface{}
+	if err := json.Unmarshal(req.Value, &value); err != nil {
+		return &proto.ConfigSetSecretResponse{Error: err.Error()}, nil
+	}
+
+	if err := s.SDK.ConfigSetSecret(ctx, req.Key, value); err != nil {
+		return &proto.ConfigSetSecretResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.ConfigSetSecretResponse{}, nil
+}
+
+// MediaGet implements the MediaGet RPC
+func (s *GRPCSDKServer) MediaGet(ctx context.Context, req *proto.MediaGetRequest) (*proto.MediaGetResponse, error) {
+	item, err := s.SDK.MediaGet(ctx, req.Id)
+	if err != nil {
+		return &proto.MediaGetResponse{Error: err.Error()}, nil
+	}
+
+	protoItem, err := mediaItemToProto(item)
+	if err != nil {
+		return &proto.MediaGetResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.MediaGetResponse{Item: protoItem}, nil
+}
+
+// MediaList implements the MediaList RPC
+func (s *GRPCSDKServer) MediaList(ctx context.Context, req *proto.MediaListRequest) (*proto.MediaListResponse, error) {
+	items, err := s.SDK.MediaList(ctx, req.ParentId, req.Kind)
+	if err != nil {
+		return &proto.MediaListResponse{Error: err.Error()}, nil
+	}
+
+	protoItems := make([]*proto.MediaItem, len(items))
+	for i, item := range items {
+		protoItem, err := mediaItemToProto(item)
+		if err != nil {
+			return &proto.MediaListResponse{Error: err.Error()}, nil
+		}
+		protoItems[i] = protoItem
+	}
+
+	return &proto.MediaListResponse{Items: protoItems}, nil
+}
+
+// MediaUpdateMetadata implements the MediaUpdateMetadata RPC
+func (s *GRPCSDKServer) MediaUpdateMetadata(ctx context.Context, req *proto.MediaUpdateMetadataRequest) (*proto.MediaUpdateMetadataResponse, error) {
+	var metadata, externalIDs map[string]interface{}
+	if len(req.Metadata) > 0 {
+		if err := json.Unmarshal(req.Metadata, &metadata); err != nil {
+			return &proto.MediaUpdateMetadataResponse{Error: err.Error()}, nil
+		}
+	}
+	if len(req.ExternalIds) > 0 {
+		if err := json.Unmarshal(req.ExternalIds, &externalIDs); err != nil {
+			return &proto.MediaUpdateMetadataResponse{Error: err.Error()}, nil
+		}
+	}
+
+	item, err := s.SDK.MediaUpdateMetadata(ctx, req.Id, metadata, externalIDs)
+	if err != nil {
+		return &proto.MediaUpdateMetadataResponse{Error: err.Error()}, nil
+	}
+
+	protoItem, err := mediaItemToProto(item)
+	if err != nil {
+		return &proto.MediaUpdateMetadataResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.MediaUpdateMetadataResponse{Item: protoItem}, nil
+}
+
+// MediaAddExtraFile implements the MediaAddExtraFile RPC
+func (s *GRPCSDKServer) MediaAddExtraFile(ctx context.Context, req *proto.MediaAddExtraFileRequest) (*proto.MediaAddExtraFileResponse, error) {
+	if err := s.SDK.MediaAddExtraFile(ctx, req.MediaItemId, req.Path, req.Kind); err != nil {
+		return &proto.MediaAddExtraFileResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.MediaAddExtraFileResponse{}, nil
+}
+
+// MediaFiles implements the MediaFiles RPC
+func (s *GRPCSDKServer) MediaFiles(ctx context.Context, req *proto.MediaFilesRequest) (*proto.MediaFilesResponse, error) {
+	files, err := s.SDK.MediaFiles(ctx, req.MediaItemId)
+	if err != nil {
+		return &proto.MediaFilesResponse{Error: err.Error()}, nil
+	}
+
+	protoFiles := make([]*proto.MediaFile, 0, len(files))
+	for _, file := range files {
+		protoFiles = append(protoFiles, &proto.MediaFile{
+			Id:     file.ID,
+			Path:   file.Path,
+			Kind:   file.Kind,
+			Status: file.Status,
+		})
+	}
+
+	return &proto.MediaFilesResponse{Files: protoFiles}, nil
+}
+
+// DownloadSync implements the DownloadSync RPC
+func (s *GRPCSDKServer) DownloadSync(ctx context.Context, req *proto.DownloadSyncRequest) (*proto.DownloadSyncResponse, error) {
+	if len(req.Downloads) > 0 {
+		var downloads []map[string]interface{}
+		if err := json.Unmarshal(req.Downloads, &downloads); err != nil {
+			return &proto.DownloadSyncResponse{Error: err.Error()}, nil
+		}
+		if err := s.SDK.DownloadSyncBatch(ctx, downloads); err != nil {
+			return &proto.DownloadSyncResponse{Error: err.Error()}, nil
+		}
+		return &proto.DownloadSyncResponse{}, nil
+	}
+
+	var download map[string]interface{}
+	if err := json.Unmarshal(req.Download, &download); err != nil {
+		return &proto.DownloadSyncResponse{Error: err.Error()}, nil
+	}
+
+	if err := s.SDK.DownloadSync(ctx, download); err != nil {
+		return &proto.DownloadSyncResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.DownloadSyncResponse{}, nil
+}
+
+// ImportRequest implements the ImportRequest RPC
+func (s *GRPCSDKServer) ImportRequest(ctx context.Context, req *proto.ImportFileRequest) (*proto.ImportFileResponse, error) {
+	result, err := s.SDK.ImportRequest(ctx, ImportFileRequest{
+		DownloadID:        req.DownloadId,
+		SourcePath:        req.SourcePath,
+		MediaItemID:       req.MediaItemId,
+		Quality:           req.Quality,
+		ReleaseGroup:      req.ReleaseGroup,
+		ReleaseTitle:      req.ReleaseTitle,
+		ExtraMediaItemIDs: req.ExtraMediaItemIds,
+		TransferMode:      req.TransferMode,
+		Disposable:        req.Disposable,
+	})
+	if err != nil {
+		return &proto.ImportFileResponse{Error: err.Error(), Retryable: errors.Is(err, ErrFileNotStable)}, nil
+	}
+
+	return &proto.ImportFileResponse{FinalPath: result.FinalPath}, nil
+}
+
+// ImportFailed implements the ImportFailed RPC
+func (s *GRPCSDKServer) ImportFailed(ctx context.Context, req *proto.ImportFailedRequest) (*proto.ImportFailedResponse, error) {
+	failed := FailedImportRequest{
+		DownloadID:  req.DownloadId,
+		SourcePath:  req.SourcePath,
+		Reason:      req.Reason,
+		MediaItemID: req.MediaItemId,
+	}
+	if len(req.Detected) > 0 {
+		if err := json.Unmarshal(req.Detected, &failed.Detected); err != nil {
+			return &proto.ImportFailedResponse{Error: err.Error()}, nil
+		}
+	}
+
+	if err := s.SDK.ImportFailed(ctx, failed); err != nil {
+		return &proto.ImportFailedResponse{Error: err.Error()}, nil
+	}
+
+	return &proto.ImportFailedResponse{}, nil
+}
+
+// mediaItemToProto converts a media item for the wire, JSON-encoding its maps
+func mediaItemToProto(item *MediaItem) (*proto.MediaItem, error) {
+	metadata, err := json.Marshal(item.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	externalIDs, err := json.Marshal(item.ExternalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.MediaItem{
+		Id:          item.ID,
+		Kind:        item.Kind,
+		Title:       item.Title,
+		Year:        item.Year,
+		Metadata:    metadata,
+		ExternalIds: externalIDs,
+		ParentId:    item.ParentID,
+		CreatedAt:   item.CreatedAt.Unix(),
+		UpdatedAt:   item.UpdatedAt.Unix(),
+	}, nil
+}
+
+// mediaItemFromProto converts a media item received over the wire
+func mediaItemFromProto(protoItem *proto.MediaItem) (*MediaItem, error) {
+	if protoItem == nil {
+		return nil, errors.New("missing media item in response")
+	}
+
+	item := &MediaItem{
+		ID:        protoItem.Id,
+		Kind:      protoItem.Kind,
+		Title:     protoItem.Title,
+		Year:      protoItem.Year,
+		ParentID:  protoItem.ParentId,
+		CreatedAt: time.Unix(protoItem.CreatedAt, 0),
+		UpdatedAt: time.Unix(protoItem.UpdatedAt, 0),
+	}
+	if len(protoItem.Metadata) > 0 {
+		if err := json.Unmarshal(protoItem.Metadata, &item.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	if len(protoItem.ExternalIds) > 0 {
+		if err := json.Unmarshal(protoItem.ExternalIds, &item.ExternalIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
 // ============================================================================
 // SDK gRPC Client (plugin-side)
 // ============================================================================
@@ -738,3 +1129,257 @@ func (c *GRPCSDKClient) ConfigDelete(ctx context.Context, key string) error {
 
 	return nil
 }
+
+// ConfigGetSecret calls the ConfigGetSecret RPC
+func (c *GRPCSDKClient) ConfigGetSecret(ctx context.Context, key string) (interface{}, error) {
+	resp, err := c.client.ConfigGetSecret(ctx, &proto.ConfigGetSecretRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if len(resp.Value) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(resp.Value, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// ConfigSetSecret calls the ConfigSetSecret RPC
+func (c *GRPCSDKClient) ConfigSetSecret(ctx context.Context, key string, value interface{}) error {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.ConfigSetSecret(ctx, &proto.ConfigSetSecretRequest{Key: key, Value: jsonValue})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	return nil
+}
+
+// MediaGet calls the MediaGet RPC
+func (c *GRPCSDKClient) MediaGet(ctx context.Context, id int64) (*MediaItem, error) {
+	resp, err := c.client.MediaGet(ctx, &proto.MediaGetRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return mediaItemFromProto(resp.Item)
+}
+
+// MediaList calls the MediaList RPC
+func (c *GRPCSDKClient) MediaList(ctx context.Context, parentID int64, kind string) ([]*MediaItem, error) {
+	resp, err := c.client.MediaList(ctx, &proto.MediaListRequest{ParentId: parentID, Kind: kind})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	items := make([]*MediaItem, len(resp.Items))
+	for i, protoItem := range resp.Items {
+		item, err := mediaItemFromProto(protoItem)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+
+	return items, nil
+}
+
+// MediaUpdateMetadata calls the MediaUpdateMetadata RPC
+func (c *GRPCSDKClient) MediaUpdateMetadata(ctx context.Context, id int64, metadata, externalIDs map[string]interface{}) (*MediaItem, error) {
+	req := &proto.MediaUpdateMetadataRequest{Id: id}
+	if len(metadata) > 0 {
+		jsonMetadata, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, err
+		}
+		req.Metadata = jsonMetadata
+	}
+	if len(externalIDs) > 0 {
+		jsonExternalIDs, err := json.Marshal(externalIDs)
+		if err != nil {
+			return nil, err
+		}
+		req.ExternalIds = jsonExternalIDs
+	}
+
+	resp, err := c.client.MediaUpdateMetadata(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return mediaItemFromProto(resp.Item)
+}
+
+// MediaAddExtraFile calls the MediaAddExtraFile RPC
+func (c *GRPCSDKClient) MediaAddExtraFile(ctx context.Context, mediaItemID int64, path, kind string) error {
+	resp, err := c.client.MediaAddExtraFile(ctx, &proto.MediaAddExtraFileRequest{
+		MediaItemId: mediaItemID,
+		Path:        path,
+		Kind:        kind,
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	return nil
+}
+
+// MediaFiles calls the MediaFiles RPC
+func (c *GRPCSDKClient) MediaFiles(ctx context.Context, mediaItemID int64) ([]MediaFile, error) {
+	resp, err := c.client.MediaFiles(ctx, &proto.MediaFilesRequest{MediaItemId: mediaItemID})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	files := make([]MediaFile, 0, len(resp.Files))
+	for _, file := range resp.Files {
+		files = append(files, MediaFile{
+			ID:     file.Id,
+			Path:   file.Path,
+			Kind:   file.Kind,
+			Status: file.Status,
+		})
+	}
+
+	return files, nil
+}
+
+// DownloadSyncBatch calls the DownloadSync RPC with several downloads
+func (c *GRPCSDKClient) DownloadSyncBatch(ctx context.Context, downloads []map[string]interface{}) error {
+	jsonDownloads, err := json.Marshal(downloads)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.DownloadSync(ctx, &proto.DownloadSyncRequest{Downloads: jsonDownloads})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// DownloadSync calls the DownloadSync RPC
+func (c *GRPCSDKClient) DownloadSync(ctx context.Context, download map[string]interface{}) error {
+	jsonDownload, err := json.Marshal(download)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.DownloadSync(ctx, &proto.DownloadSyncRequest{Download: jsonDownload})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	return nil
+}
+
+// ImportRequest calls the ImportRequest RPC
+func (c *GRPCSDKClient) ImportRequest(ctx context.Context, req ImportFileRequest) (*ImportFileResult, error) {
+	resp, err := c.client.ImportRequest(ctx, &proto.ImportFileRequest{
+		DownloadId:        req.DownloadID,
+		SourcePath:        req.SourcePath,
+		MediaItemId:       req.MediaItemID,
+		Quality:           req.Quality,
+		ReleaseGroup:      req.ReleaseGroup,
+		ReleaseTitle:      req.ReleaseTitle,
+		ExtraMediaItemIds: req.ExtraMediaItemIDs,
+		TransferMode:      req.TransferMode,
+		Disposable:        req.Disposable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Retryable {
+		return nil, notStableError(resp.Error)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &ImportFileResult{FinalPath: resp.FinalPath}, nil
+}
+
+// notStableError is an import failure the host reported as retryable. It keeps
+// the host's message and matches ErrFileNotStable.
+type notStableError string
+
+func (e notStableError) Error() string {
+	return string(e)
+}
+
+func (e notStableError) Is(target error) bool {
+	return target == ErrFileNotStable
+}
+
+// ImportFailed calls the ImportFailed RPC
+func (c *GRPCSDKClient) ImportFailed(ctx context.Context, req FailedImportRequest) error {
+	protoReq := &proto.ImportFailedRequest{
+		DownloadId:  req.DownloadID,
+		SourcePath:  req.SourcePath,
+		Reason:      req.Reason,
+		MediaItemId: req.MediaItemID,
+	}
+	if len(req.Detected) > 0 {
+		detected, err := json.Marshal(req.Detected)
+		if err != nil {
+			return err
+		}
+		protoReq.Detected = detected
+	}
+
+	resp, err := c.client.ImportFailed(ctx, protoReq)
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	return nil
+}
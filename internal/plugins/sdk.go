@@ -3,7 +3,11 @@ package plugins
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/blakestevenson/nimbus/internal/configstore"
 	"github.com/blakestevenson/nimbus/internal/db/generated"
@@ -16,8 +20,34 @@ type SDK struct {
 	queries     *generated.Queries
 	configStore *configstore.Store
 	logger      *zap.Logger
+
+	// Services created after the plugin manager, set once they exist
+	mu               sync.RWMutex
+	downloadSyncer   DownloadSyncer
+	importHandler    ImportHandler
+	metadataObserver MetadataObserver
+}
+
+// DownloadSyncer stores the download state reported by downloader plugins
+type DownloadSyncer interface {
+	UpsertDownload(ctx context.Context, downloadID string, payload map[string]interface{}) error
+}
+
+// ImportHandler imports the files of completed downloads for downloader plugins
+type ImportHandler interface {
+	ImportFile(ctx context.Context, req ImportFileRequest) (*ImportFileResult, error)
+	RecordFailedImport(ctx context.Context, req FailedImportRequest) error
+}
+
+// MetadataObserver is told about media items whose metadata was updated, such
+// as movies matched to a TMDB collection
+type MetadataObserver interface {
+	MetadataUpdated(ctx context.Context, item generated.MediaItem) error
 }
 
+// maxMediaListItems bounds MediaList when it is not scoped to a parent
+const maxMediaListItems = 1000
+
 // NewSDK creates a new SDK instance for plugin use
 func NewSDK(queries *generated.Queries, configStore *configstore.Store, logger *zap.Logger) *SDK {
 	return &SDK{
@@ -83,6 +113,35 @@ func (sdk *SDK) ConfigDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+// ConfigGetSecret retrieves and decrypts a secret. A secret that does not exist
+// is returned as nil without an error, so plugins can tell it apart from a
+// secret that can't be decrypted.
+func (sdk *SDK) ConfigGetSecret(ctx context.Context, key string) (interface{}, error) {
+	value, err := sdk.configStore.GetSecret(ctx, key)
+	if configstore.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", key, err)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(value, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret value: %w", err)
+	}
+
+	return result, nil
+}
+
+// ConfigSetSecret encrypts and stores a secret
+func (sdk *SDK) ConfigSetSecret(ctx context.Context, key string, value interface{}) error {
+	if err := sdk.configStore.SetSecret(ctx, key, value); err != nil {
+		return fmt.Errorf("failed to set secret %s: %w", key, err)
+	}
+
+	return nil
+}
+
 // ============================================================================
 // Media Methods
 // ============================================================================
@@ -185,6 +244,223 @@ func (sdk *SDK) DeleteMediaItem(ctx context.Context, id int64) error {
 	return nil
 }
 
+// MediaGet retrieves a media item by its ID
+func (sdk *SDK) MediaGet(ctx context.Context, id int64) (*MediaItem, error) {
+	return sdk.FindMediaByID(ctx, id)
+}
+
+// MediaList retrieves the media items under a parent of a kind. A parent ID of 0
+// and an empty kind don't filter.
+func (sdk *SDK) MediaList(ctx context.Context, parentID int64, kind string) ([]*MediaItem, error) {
+	topLevelOnly := false
+	params := generated.ListMediaItemsParams{TopLevelOnly: &topLevelOnly}
+	if parentID > 0 {
+		params.ParentID = &parentID
+	} else {
+		limit := int32(maxMediaListItems)
+		params.Limit = &limit
+	}
+	if kind != "" {
+		params.Kind = &kind
+	}
+
+	dbMediaList, err := sdk.queries.ListMediaItems(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media items: %w", err)
+	}
+
+	items := make([]*MediaItem, len(dbMediaList))
+	for i, dbMedia := range dbMediaList {
+		items[i] = sdk.convertDBMediaToMediaItem(dbMedia)
+	}
+
+	return items, nil
+}
+
+// MediaUpdateMetadata merges metadata and external IDs into a media item,
+// keeping the keys that are not in the patch
+func (sdk *SDK) MediaUpdateMetadata(ctx context.Context, id int64, metadata, externalIDs map[string]interface{}) (*MediaItem, error) {
+	dbMedia, err := sdk.queries.GetMediaItem(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item %d: %w", id, err)
+	}
+
+	if len(metadata) > 0 {
+		patch, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		dbMedia, err = sdk.queries.UpdateMediaMetadata(ctx, generated.UpdateMediaMetadataParams{
+			ID:       id,
+			Metadata: patch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update media item metadata: %w", err)
+		}
+	}
+
+	if len(externalIDs) > 0 {
+		patch, err := json.Marshal(externalIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal external IDs: %w", err)
+		}
+		dbMedia, err = sdk.queries.UpdateMediaExternalIDs(ctx, generated.UpdateMediaExternalIDsParams{
+			ID:          id,
+			ExternalIds: patch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update media item external IDs: %w", err)
+		}
+	}
+
+	sdk.mu.RLock()
+	observer := sdk.metadataObserver
+	sdk.mu.RUnlock()
+	if observer != nil && (len(metadata) > 0 || len(externalIDs) > 0) {
+		if err := observer.MetadataUpdated(ctx, dbMedia); err != nil {
+			sdk.logger.Warn("failed to process updated metadata", zap.Int64("media_item_id", id), zap.Error(err))
+		}
+	}
+
+	return sdk.convertDBMediaToMediaItem(dbMedia), nil
+}
+
+// SetMetadataObserver sets what is told about metadata updated by plugins
+func (sdk *SDK) SetMetadataObserver(observer MetadataObserver) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.metadataObserver = observer
+}
+
+// extraFileKinds are the kinds of extra files plugins may record
+var extraFileKinds = map[string]bool{
+	"subtitle": true,
+}
+
+// MediaAddExtraFile records an extra file of a media item, such as a subtitle
+// downloaded next to its video. The file must exist.
+func (sdk *SDK) MediaAddExtraFile(ctx context.Context, mediaItemID int64, path, kind string) error {
+	if !extraFileKinds[kind] {
+		return fmt.Errorf("unknown extra file kind: %s", kind)
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path must be absolute: %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat extra file: %w", err)
+	}
+	if _, err := sdk.queries.GetMediaItem(ctx, mediaItemID); err != nil {
+		return fmt.Errorf("failed to get media item %d: %w", mediaItemID, err)
+	}
+
+	size := info.Size()
+	if _, err := sdk.queries.UpsertExtraMediaFile(ctx, generated.UpsertExtraMediaFileParams{
+		MediaItemID: &mediaItemID,
+		Path:        path,
+		Size:        &size,
+		Kind:        kind,
+	}); err != nil {
+		return fmt.Errorf("failed to record extra file: %w", err)
+	}
+	return nil
+}
+
+// MediaFiles returns the files recorded for a media item
+func (sdk *SDK) MediaFiles(ctx context.Context, mediaItemID int64) ([]MediaFile, error) {
+	rows, err := sdk.queries.ListMediaFilesByItem(ctx, &mediaItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of media item %d: %w", mediaItemID, err)
+	}
+
+	files := make([]MediaFile, 0, len(rows))
+	for _, row := range rows {
+		files = append(files, MediaFile{ID: row.ID, Path: row.Path, Kind: row.Kind, Status: row.Status})
+	}
+	return files, nil
+}
+
+// ============================================================================
+// Download Methods
+// ============================================================================
+
+// SetDownloadSyncer sets where downloads reported by plugins are stored
+func (sdk *SDK) SetDownloadSyncer(syncer DownloadSyncer) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.downloadSyncer = syncer
+}
+
+// SetImportHandler sets what imports the files of completed downloads
+func (sdk *SDK) SetImportHandler(handler ImportHandler) {
+	sdk.mu.Lock()
+	defer sdk.mu.Unlock()
+	sdk.importHandler = handler
+}
+
+// DownloadSync stores the state of a plugin's download
+func (sdk *SDK) DownloadSync(ctx context.Context, download map[string]interface{}) error {
+	sdk.mu.RLock()
+	syncer := sdk.downloadSyncer
+	sdk.mu.RUnlock()
+	if syncer == nil {
+		return fmt.Errorf("download sync is not available")
+	}
+
+	id, _ := download["id"].(string)
+	if id == "" {
+		return fmt.Errorf("download id is required")
+	}
+	return syncer.UpsertDownload(ctx, id, download)
+}
+
+// DownloadSyncBatch stores the state of several of a plugin's downloads. Every
+// download is attempted; the errors of those that failed are returned together.
+func (sdk *SDK) DownloadSyncBatch(ctx context.Context, downloads []map[string]interface{}) error {
+	var errs []error
+	for _, download := range downloads {
+		if err := sdk.DownloadSync(ctx, download); err != nil {
+			id, _ := download["id"].(string)
+			errs = append(errs, fmt.Errorf("download %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ImportRequest imports a downloaded file into the library
+func (sdk *SDK) ImportRequest(ctx context.Context, req ImportFileRequest) (*ImportFileResult, error) {
+	sdk.mu.RLock()
+	handler := sdk.importHandler
+	sdk.mu.RUnlock()
+	if handler == nil {
+		return nil, fmt.Errorf("import is not available")
+	}
+	if req.SourcePath == "" {
+		return nil, fmt.Errorf("source path is required")
+	}
+	if req.MediaItemID <= 0 {
+		return nil, fmt.Errorf("media item ID is required")
+	}
+	return handler.ImportFile(ctx, req)
+}
+
+// ImportFailed adds a file a plugin could not import to the manual import queue
+func (sdk *SDK) ImportFailed(ctx context.Context, req FailedImportRequest) error {
+	sdk.mu.RLock()
+	handler := sdk.importHandler
+	sdk.mu.RUnlock()
+	if handler == nil {
+		return fmt.Errorf("import is not available")
+	}
+	if req.SourcePath == "" {
+		return fmt.Errorf("source path is required")
+	}
+	if req.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	return handler.RecordFailedImport(ctx, req)
+}
+
 // ============================================================================
 // Logging Methods
 // ============================================================================
@@ -224,14 +500,20 @@ func (sdk *SDK) convertDBMediaToMediaItem(dbMedia generated.MediaItem) *MediaIte
 		_ = json.Unmarshal(dbMedia.Metadata, &metadata)
 	}
 
+	var externalIDs map[string]interface{}
+	if len(dbMedia.ExternalIds) > 0 {
+		_ = json.Unmarshal(dbMedia.ExternalIds, &externalIDs)
+	}
+
 	return &MediaItem{
-		ID:        dbMedia.ID,
-		Kind:      dbMedia.Kind,
-		Title:     dbMedia.Title,
-		Year:      dbMedia.Year,
-		Metadata:  metadata,
-		ParentID:  dbMedia.ParentID,
-		CreatedAt: dbMedia.CreatedAt.Time,
-		UpdatedAt: dbMedia.UpdatedAt.Time,
+		ID:          dbMedia.ID,
+		Kind:        dbMedia.Kind,
+		Title:       dbMedia.Title,
+		Year:        dbMedia.Year,
+		Metadata:    metadata,
+		ExternalIDs: externalIDs,
+		ParentID:    dbMedia.ParentID,
+		CreatedAt:   dbMedia.CreatedAt.Time,
+		UpdatedAt:   dbMedia.UpdatedAt.Time,
 	}
 }
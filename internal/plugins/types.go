@@ -2,7 +2,9 @@ package plugins
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/plugins/proto"
@@ -24,6 +26,7 @@ type RouteDescriptor struct {
 	Path   string `json:"path"`   // e.g., "/api/plugins/sonarr/series"
 	Auth   string `json:"auth"`   // "session", "apikey", "none"
 	Tag    string `json:"tag"`    // Optional: "compat:sonarr", "internal", etc.
+	Role   string `json:"role"`   // Optional: "admin" or "user"; any role implies "session" auth
 }
 
 // PluginHTTPRequest represents an HTTP request forwarded to a plugin
@@ -34,6 +37,13 @@ type PluginHTTPRequest struct {
 	Headers map[string][]string `json:"headers"`
 	Body    []byte              `json:"body"`
 
+	// Route is the pattern of the declared route the host matched, e.g.
+	// "/api/plugins/sonarr/series/{id}", and PathParams the values of its
+	// placeholders, e.g. {"id": "42"}. Plugins dispatch on these instead of
+	// splitting Path.
+	Route      string            `json:"route,omitempty"`
+	PathParams map[string]string `json:"path_params,omitempty"`
+
 	// Auth context (populated by middleware)
 	UserID *int64   `json:"user_id,omitempty"`
 	Scopes []string `json:"scopes,omitempty"`
@@ -43,6 +53,37 @@ type PluginHTTPRequest struct {
 	SDK         SDKInterface `json:"-"` // SDK client for plugins to use
 }
 
+// Scopes the host grants a request forwarded to a plugin. Every signed in
+// user gets ScopeUser, admins also get ScopeAdmin.
+const (
+	ScopeUser  = "user"
+	ScopeAdmin = "admin"
+)
+
+// HasScope reports whether the host granted a forwarded request a scope
+func HasScope(req *PluginHTTPRequest, scope string) bool {
+	for _, s := range req.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope returns a 403 response when a forwarded request lacks a scope,
+// and nil when it has it. The host already enforces a route's Role; plugins
+// can call this to guard handlers shared by several routes.
+func RequireScope(req *PluginHTTPRequest, scope string) *PluginHTTPResponse {
+	if HasScope(req, scope) {
+		return nil
+	}
+	return &PluginHTTPResponse{
+		StatusCode: http.StatusForbidden,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"error":"` + scope + ` access required"}`),
+	}
+}
+
 // PluginHTTPResponse represents an HTTP response from a plugin
 type PluginHTTPResponse struct {
 	StatusCode int                 `json:"status_code"`
@@ -76,6 +117,10 @@ type ConfigSection struct {
 	Title       string        `json:"title"`
 	Description string        `json:"description,omitempty"`
 	Fields      []ConfigField `json:"fields"`
+	// SchemaVersion is the version of the shape of the stored config. When it
+	// is ahead of the version the host stored, the host asks the plugin to
+	// migrate its config on load.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 }
 
 // ConfigField describes a single configuration field
@@ -101,9 +146,12 @@ type ConfigFieldValidation struct {
 
 // Event represents a system event that can be sent to plugins
 type Event struct {
-	Type      string                 `json:"type"` // e.g., "download.finished", "media.imported"
+	Type      string                 `json:"type"` // e.g., "download.completed", "media.item.created"
 	Data      map[string]interface{} `json:"data"`
 	Timestamp time.Time              `json:"timestamp"`
+
+	// SDK provides access to host services while handling the event
+	SDK SDKInterface `json:"-"`
 }
 
 // MediaSuitePlugin is the main interface that all plugins must implement
@@ -139,17 +187,34 @@ type MediaSuitePlugin interface {
 	IsDownloader(ctx context.Context) (bool, error)
 }
 
+// ConfigMigrator is implemented by plugins whose stored config changes shape
+// between versions. On load the host passes the config stored under the keys of
+// the plugin's config section, along with the schema version it was written
+// at, and stores what is returned. Keys returned as nil are deleted.
+type ConfigMigrator interface {
+	MigrateConfig(ctx context.Context, fromVersion int, config map[string]interface{}) (map[string]interface{}, error)
+}
+
 // MediaItem represents a media item in the core system
 // This is used by the SDK to allow plugins to query/modify media
 type MediaItem struct {
-	ID        int64                  `json:"id"`
-	Kind      string                 `json:"kind"` // "movie", "tv-series", "book", etc.
-	Title     string                 `json:"title"`
-	Year      *int32                 `json:"year,omitempty"`
-	Metadata  map[string]interface{} `json:"metadata"`
-	ParentID  *int64                 `json:"parent_id,omitempty"`
-	CreatedAt time.Time              `json:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at"`
+	ID          int64                  `json:"id"`
+	Kind        string                 `json:"kind"` // "movie", "tv-series", "book", etc.
+	Title       string                 `json:"title"`
+	Year        *int32                 `json:"year,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	ExternalIDs map[string]interface{} `json:"external_ids,omitempty"`
+	ParentID    *int64                 `json:"parent_id,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// MediaFile represents a file of a media item in the core system
+type MediaFile struct {
+	ID     int64  `json:"id"`
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`   // "media", "subtitle", etc.
+	Status string `json:"status"` // "ok", "missing", etc.
 }
 
 // ConfigValue represents a configuration key-value pair
@@ -182,6 +247,16 @@ type IndexerSearchRequest struct {
 	// Pagination
 	Limit  int `json:"limit,omitempty"`
 	Offset int `json:"offset,omitempty"`
+
+	// Bypass any cached results
+	Fresh bool `json:"fresh,omitempty"`
+
+	// Drop torrent results with fewer seeders (0 = no minimum)
+	MinimumSeeders int `json:"min_seeders,omitempty"`
+
+	// SDK access (for plugin-side SDK calls)
+	SDKServerID uint32       `json:"-"` // Internal: broker ID for SDK server
+	SDK         SDKInterface `json:"-"` // SDK client for plugins to use
 }
 
 // IndexerSearchResponse represents the response from an indexer search
@@ -232,6 +307,25 @@ type IndexerRelease struct {
 	// Indexer that provided this release
 	IndexerID   string `json:"indexer_id"`
 	IndexerName string `json:"indexer_name"`
+
+	// Other indexers carrying the same release, to download it from when the
+	// download URL fails
+	AlsoAvailableOn []ReleaseSource `json:"also_available_on,omitempty"`
+}
+
+// ReleaseSource is another indexer a release can be downloaded from
+type ReleaseSource struct {
+	IndexerID   string `json:"indexer_id"`
+	IndexerName string `json:"indexer_name"`
+	GUID        string `json:"guid"`
+	DownloadURL string `json:"download_url"`
+}
+
+// WantedEpisode is an episode a season pack is grabbed for. Downloaders that
+// can fetch part of a release download only the files of wanted episodes.
+type WantedEpisode struct {
+	Season  int `json:"season"`
+	Episode int `json:"episode"`
 }
 
 // GetSDKClient creates an SDK client from a PluginHTTPRequest
@@ -262,4 +356,74 @@ type SDKInterface interface {
 	ConfigGetString(ctx context.Context, key string) (string, error)
 	ConfigSet(ctx context.Context, key string, value interface{}) error
 	ConfigDelete(ctx context.Context, key string) error
+
+	// ConfigGetSecret returns a secret stored with ConfigSetSecret, or nil when
+	// the secret does not exist
+	ConfigGetSecret(ctx context.Context, key string) (interface{}, error)
+	// ConfigSetSecret stores a value encrypted at rest
+	ConfigSetSecret(ctx context.Context, key string, value interface{}) error
+
+	// MediaGet returns a media item by ID
+	MediaGet(ctx context.Context, id int64) (*MediaItem, error)
+	// MediaList returns the media items under a parent (0 for any parent) of a
+	// kind (empty for every kind)
+	MediaList(ctx context.Context, parentID int64, kind string) ([]*MediaItem, error)
+	// MediaUpdateMetadata merges metadata and external IDs into a media item.
+	// Either map may be nil.
+	MediaUpdateMetadata(ctx context.Context, id int64, metadata, externalIDs map[string]interface{}) (*MediaItem, error)
+	// MediaAddExtraFile records a file placed next to a media item's file, such
+	// as a subtitle, in the item's files
+	MediaAddExtraFile(ctx context.Context, mediaItemID int64, path, kind string) error
+	// MediaFiles returns the files of a media item
+	MediaFiles(ctx context.Context, mediaItemID int64) ([]MediaFile, error)
+
+	// DownloadSync stores the state of one of the plugin's downloads on the host
+	DownloadSync(ctx context.Context, download map[string]interface{}) error
+	// DownloadSyncBatch stores the state of several downloads in one call
+	DownloadSyncBatch(ctx context.Context, downloads []map[string]interface{}) error
+
+	// ImportRequest imports a downloaded file into the library
+	ImportRequest(ctx context.Context, req ImportFileRequest) (*ImportFileResult, error)
+	// ImportFailed adds a file that could not be imported to the manual import queue
+	ImportFailed(ctx context.Context, req FailedImportRequest) error
+}
+
+// ImportFileRequest asks the host to import a downloaded file for a media item
+type ImportFileRequest struct {
+	DownloadID   string `json:"download_id"`
+	SourcePath   string `json:"source_path"`
+	MediaItemID  int64  `json:"media_item_id"`
+	Quality      string `json:"quality,omitempty"`       // e.g. "WEB-DL 1080p"
+	ReleaseGroup string `json:"release_group,omitempty"` // e.g. "GROUP"
+	ReleaseTitle string `json:"release_title,omitempty"` // Release the file came from
+
+	// ExtraMediaItemIDs are the further episodes of a multi-episode file, whose
+	// first episode is MediaItemID. The host works them out from the file name
+	// when they are left out.
+	ExtraMediaItemIDs []int64 `json:"extra_media_item_ids,omitempty"`
+
+	// TransferMode is how the file gets into the library: "move", "copy",
+	// "hardlink" or "auto". Auto keeps the file unless it is Disposable, e.g.
+	// an NZB download, so torrents can go on seeding. The default is a move.
+	TransferMode string `json:"transfer_mode,omitempty"`
+	Disposable   bool   `json:"disposable,omitempty"`
+}
+
+// ErrFileNotStable is returned by an import when the file may still be written
+// to, e.g. by a torrent client that hasn't finished it. Unlike other import
+// failures it is worth trying the import again a little later.
+var ErrFileNotStable = errors.New("file not stable")
+
+// ImportFileResult is the outcome of a successful import
+type ImportFileResult struct {
+	FinalPath string `json:"final_path"`
+}
+
+// FailedImportRequest describes a downloaded file the plugin could not import
+type FailedImportRequest struct {
+	DownloadID  string                 `json:"download_id"`
+	SourcePath  string                 `json:"source_path"`
+	Reason      string                 `json:"reason"`
+	Detected    map[string]interface{} `json:"detected,omitempty"` // Attributes detected from the file
+	MediaItemID *int64                 `json:"media_item_id,omitempty"`
 }
@@ -0,0 +1,352 @@
+package quality
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// releaseGroupPattern matches the trailing "-GROUP" of a scene release name
+var releaseGroupPattern = regexp.MustCompile(`-([A-Za-z0-9]+)(?:\[[^\]]*\])?(?:\.[A-Za-z0-9]{2,4})?$`)
+
+// Custom Formats
+
+// ListCustomFormats lists all custom formats
+func (s *Service) ListCustomFormats(ctx context.Context) ([]CustomFormat, error) {
+	query := `
+		SELECT id, name, match_type, field, pattern, terms, score, enabled, created_at, updated_at
+		FROM custom_formats
+		ORDER BY score DESC, name
+	`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom formats: %w", err)
+	}
+	defer rows.Close()
+
+	var formats []CustomFormat
+	for rows.Next() {
+		var cf CustomFormat
+		err := rows.Scan(
+			&cf.ID, &cf.Name, &cf.MatchType, &cf.Field, &cf.Pattern, &cf.Terms,
+			&cf.Score, &cf.Enabled, &cf.CreatedAt, &cf.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan custom format: %w", err)
+		}
+		formats = append(formats, cf)
+	}
+
+	return formats, rows.Err()
+}
+
+// GetCustomFormat gets a custom format by ID
+func (s *Service) GetCustomFormat(ctx context.Context, id int) (*CustomFormat, error) {
+	query := `
+		SELECT id, name, match_type, field, pattern, terms, score, enabled, created_at, updated_at
+		FROM custom_formats
+		WHERE id = $1
+	`
+
+	var cf CustomFormat
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&cf.ID, &cf.Name, &cf.MatchType, &cf.Field, &cf.Pattern, &cf.Terms,
+		&cf.Score, &cf.Enabled, &cf.CreatedAt, &cf.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("custom format not found")
+		}
+		return nil, fmt.Errorf("failed to get custom format: %w", err)
+	}
+
+	return &cf, nil
+}
+
+// CreateCustomFormat creates a new custom format
+func (s *Service) CreateCustomFormat(ctx context.Context, params CreateCustomFormatParams) (*CustomFormat, error) {
+	if params.MatchType == "" {
+		params.MatchType = CustomFormatMatchTerms
+	}
+	if params.Field == "" {
+		params.Field = "title"
+	}
+	if params.Terms == nil {
+		params.Terms = []string{}
+	}
+	enabled := true
+	if params.Enabled != nil {
+		enabled = *params.Enabled
+	}
+
+	if err := validateCustomFormat(params.Name, params.MatchType, params.Pattern, params.Terms); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO custom_formats (name, match_type, field, pattern, terms, score, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, name, match_type, field, pattern, terms, score, enabled, created_at, updated_at
+	`
+
+	var cf CustomFormat
+	err := s.db.QueryRow(ctx, query,
+		params.Name, params.MatchType, params.Field, params.Pattern, params.Terms, params.Score, enabled,
+	).Scan(
+		&cf.ID, &cf.Name, &cf.MatchType, &cf.Field, &cf.Pattern, &cf.Terms,
+		&cf.Score, &cf.Enabled, &cf.CreatedAt, &cf.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create custom format: %w", err)
+	}
+
+	return &cf, nil
+}
+
+// UpdateCustomFormat updates an existing custom format
+func (s *Service) UpdateCustomFormat(ctx context.Context, id int, params UpdateCustomFormatParams) (*CustomFormat, error) {
+	existing, err := s.GetCustomFormat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the format as it will look after the update
+	name, matchType, pattern, terms := existing.Name, existing.MatchType, existing.Pattern, existing.Terms
+	if params.Name != nil {
+		name = *params.Name
+	}
+	if params.MatchType != nil {
+		matchType = *params.MatchType
+	}
+	if params.Pattern != nil {
+		pattern = params.Pattern
+	}
+	if params.Terms != nil {
+		terms = params.Terms
+	}
+	if err := validateCustomFormat(name, matchType, pattern, terms); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE custom_formats
+		SET name = COALESCE($1, name),
+		    match_type = COALESCE($2, match_type),
+		    field = COALESCE($3, field),
+		    pattern = COALESCE($4, pattern),
+		    terms = COALESCE($5, terms),
+		    score = COALESCE($6, score),
+		    enabled = COALESCE($7, enabled)
+		WHERE id = $8
+		RETURNING id, name, match_type, field, pattern, terms, score, enabled, created_at, updated_at
+	`
+
+	var cf CustomFormat
+	err = s.db.QueryRow(ctx, query,
+		params.Name, params.MatchType, params.Field, params.Pattern, params.Terms, params.Score, params.Enabled, id,
+	).Scan(
+		&cf.ID, &cf.Name, &cf.MatchType, &cf.Field, &cf.Pattern, &cf.Terms,
+		&cf.Score, &cf.Enabled, &cf.CreatedAt, &cf.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update custom format: %w", err)
+	}
+
+	return &cf, nil
+}
+
+// DeleteCustomFormat deletes a custom format
+func (s *Service) DeleteCustomFormat(ctx context.Context, id int) error {
+	result, err := s.db.Exec(ctx, `DELETE FROM custom_formats WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete custom format: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("custom format not found")
+	}
+
+	return nil
+}
+
+// validateCustomFormat checks that a custom format can be compiled into a matcher
+func validateCustomFormat(name, matchType string, pattern *string, terms []string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("custom format name is required")
+	}
+
+	switch matchType {
+	case CustomFormatMatchRegex:
+		if pattern == nil || *pattern == "" {
+			return fmt.Errorf("regex custom formats require a pattern")
+		}
+		if _, err := regexp.Compile("(?i)" + *pattern); err != nil {
+			return fmt.Errorf("invalid custom format pattern: %w", err)
+		}
+	case CustomFormatMatchTerms:
+		if len(terms) == 0 {
+			return fmt.Errorf("term custom formats require at least one term")
+		}
+	default:
+		return fmt.Errorf("unsupported custom format match type: %s", matchType)
+	}
+
+	return nil
+}
+
+// Release Scoring
+
+// compiledFormat is a custom format ready to be matched against releases
+type compiledFormat struct {
+	format CustomFormat
+	regex  *regexp.Regexp
+	terms  []string
+}
+
+// matches reports whether the format matches the given field value
+func (f *compiledFormat) matches(value string) bool {
+	if value == "" {
+		return false
+	}
+	if f.regex != nil {
+		return f.regex.MatchString(value)
+	}
+
+	lower := strings.ToLower(value)
+	for _, term := range f.terms {
+		if term != "" && strings.Contains(lower, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseScorer scores release titles by quality tier plus matching custom formats.
+// It holds a snapshot of the definitions and formats, so create one per search.
+type ReleaseScorer struct {
+	detector    *Detector
+	definitions []QualityDefinition
+	formats     []compiledFormat
+}
+
+// NewReleaseScorer loads quality definitions and enabled custom formats into a scorer
+func (s *Service) NewReleaseScorer(ctx context.Context) (*ReleaseScorer, error) {
+	definitions, err := s.ListQualityDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	formats, err := s.ListCustomFormats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReleaseScorerFrom(s.detector, definitions, formats), nil
+}
+
+// NewReleaseScorerFrom builds a scorer from already loaded definitions and formats.
+// Disabled formats and formats whose pattern no longer compiles are skipped.
+func NewReleaseScorerFrom(detector *Detector, definitions []QualityDefinition, formats []CustomFormat) *ReleaseScorer {
+	if detector == nil {
+		detector = NewDetector()
+	}
+
+	scorer := &ReleaseScorer{
+		detector:    detector,
+		definitions: definitions,
+	}
+
+	for _, cf := range formats {
+		if !cf.Enabled {
+			continue
+		}
+
+		compiled := compiledFormat{format: cf}
+		if cf.MatchType == CustomFormatMatchRegex {
+			if cf.Pattern == nil {
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + *cf.Pattern)
+			if err != nil {
+				continue
+			}
+			compiled.regex = re
+		} else {
+			for _, term := range cf.Terms {
+				compiled.terms = append(compiled.terms, strings.ToLower(strings.TrimSpace(term)))
+			}
+		}
+		scorer.formats = append(scorer.formats, compiled)
+	}
+
+	return scorer
+}
+
+// Score computes the score of a release: the weight of its quality definition
+// plus the sum of the scores of all matching custom formats
+func (rs *ReleaseScorer) Score(title string, attributes map[string]string) ReleaseScore {
+	info := rs.detector.DetectQuality(title)
+	score := ReleaseScore{
+		ReleaseGroup:   ParseReleaseGroup(title),
+		MatchedFormats: []string{},
+	}
+
+	if def := rs.detector.MatchQualityDefinition(info, rs.definitions); def != nil {
+		score.Quality = def
+		score.QualityScore = def.Weight
+	}
+
+	for i := range rs.formats {
+		f := &rs.formats[i]
+		if f.matches(releaseField(f.format.Field, title, score.ReleaseGroup, info, attributes)) {
+			score.FormatScore += f.format.Score
+			score.MatchedFormats = append(score.MatchedFormats, f.format.Name)
+		}
+	}
+
+	score.Total = score.QualityScore + score.FormatScore
+	return score
+}
+
+// releaseField resolves the value a custom format field refers to
+func releaseField(field, title, releaseGroup string, info *DetectedQualityInfo, attributes map[string]string) string {
+	switch field {
+	case "", "title":
+		return title
+	case "release_group":
+		return releaseGroup
+	case "source":
+		if info.Source != nil {
+			return *info.Source
+		}
+	case "resolution":
+		if info.Resolution != nil {
+			return strconv.Itoa(*info.Resolution)
+		}
+	case "codec_video":
+		if info.CodecVideo != nil {
+			return *info.CodecVideo
+		}
+	case "codec_audio":
+		if info.CodecAudio != nil {
+			return *info.CodecAudio
+		}
+	default:
+		return attributes[field]
+	}
+	return ""
+}
+
+// ParseReleaseGroup extracts the release group from a scene-style release name
+func ParseReleaseGroup(title string) string {
+	match := releaseGroupPattern.FindStringSubmatch(strings.TrimSpace(title))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
@@ -0,0 +1,70 @@
+package quality
+
+import (
+	"testing"
+)
+
+func TestReleaseScorerScore(t *testing.T) {
+	res1080, res720 := 1080, 720
+	webdl := "WEBDL"
+	definitions := []QualityDefinition{
+		{ID: 1, Name: "WEBDL-1080p", Resolution: &res1080, Source: &webdl, Weight: 100},
+		{ID: 2, Name: "WEBDL-720p", Resolution: &res720, Source: &webdl, Weight: 60},
+	}
+
+	x265 := `x265|hevc`
+	formats := []CustomFormat{
+		{Name: "Repack", MatchType: CustomFormatMatchTerms, Field: "title", Terms: []string{"REPACK", "PROPER"}, Score: 10, Enabled: true},
+		{Name: "Favorite Group", MatchType: CustomFormatMatchTerms, Field: "release_group", Terms: []string{"NTb"}, Score: 25, Enabled: true},
+		{Name: "HEVC", MatchType: CustomFormatMatchRegex, Field: "title", Pattern: &x265, Score: -50, Enabled: true},
+		{Name: "Disabled", MatchType: CustomFormatMatchTerms, Field: "title", Terms: []string{"WEB"}, Score: 1000, Enabled: false},
+	}
+
+	scorer := NewReleaseScorerFrom(nil, definitions, formats)
+
+	tests := []struct {
+		name        string
+		title       string
+		wantQuality int
+		wantFormat  int
+		wantMatched int
+		wantGroup   string
+	}{
+		{"repack from favorite group", "Show.S01E01.REPACK.1080p.WEB-DL.DDP5.1.H.264-NTb", 100, 35, 2, "NTb"},
+		{"hevc penalized", "Show.S01E01.720p.WEB-DL.x265-GRP", 60, -50, 1, "GRP"},
+		{"no formats", "Show.S01E01.1080p.WEB-DL.H.264-OTHER", 100, 0, 0, "OTHER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scorer.Score(tt.title, nil)
+			if got.QualityScore != tt.wantQuality {
+				t.Errorf("QualityScore = %d, want %d", got.QualityScore, tt.wantQuality)
+			}
+			if got.FormatScore != tt.wantFormat {
+				t.Errorf("FormatScore = %d, want %d (matched %v)", got.FormatScore, tt.wantFormat, got.MatchedFormats)
+			}
+			if len(got.MatchedFormats) != tt.wantMatched {
+				t.Errorf("MatchedFormats = %v, want %d entries", got.MatchedFormats, tt.wantMatched)
+			}
+			if got.Total != got.QualityScore+got.FormatScore {
+				t.Errorf("Total = %d, want %d", got.Total, got.QualityScore+got.FormatScore)
+			}
+			if got.ReleaseGroup != tt.wantGroup {
+				t.Errorf("ReleaseGroup = %q, want %q", got.ReleaseGroup, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestReleaseScorerAttributeField(t *testing.T) {
+	formats := []CustomFormat{
+		{Name: "Freeleech", MatchType: CustomFormatMatchTerms, Field: "downloadvolumefactor", Terms: []string{"0"}, Score: 5, Enabled: true},
+	}
+	scorer := NewReleaseScorerFrom(nil, nil, formats)
+
+	got := scorer.Score("Movie.2023.1080p.BluRay.x264-GRP", map[string]string{"downloadvolumefactor": "0"})
+	if got.FormatScore != 5 {
+		t.Errorf("FormatScore = %d, want 5", got.FormatScore)
+	}
+}
@@ -11,8 +11,9 @@ import (
 
 // Handler handles HTTP requests for quality operations
 type Handler struct {
-	service *Service
-	logger  *zap.Logger
+	service          *Service
+	logger           *zap.Logger
+	onProfileChanged []func()
 }
 
 // NewHandler creates a new quality handler
@@ -23,6 +24,19 @@ func NewHandler(service *Service, logger *zap.Logger) *Handler {
 	}
 }
 
+// OnProfileChanged registers a handler that is called after a quality profile
+// or definition was changed, which can change the cutoff of many media items
+func (h *Handler) OnProfileChanged(handler func()) {
+	h.onProfileChanged = append(h.onProfileChanged, handler)
+}
+
+// profileChanged calls the profile change handlers
+func (h *Handler) profileChanged() {
+	for _, handler := range h.onProfileChanged {
+		handler()
+	}
+}
+
 // Quality Definitions Handlers
 
 func (h *Handler) ListQualityDefinitions(w http.ResponseWriter, r *http.Request) {
@@ -94,6 +108,7 @@ func (h *Handler) UpdateQualityDefinition(w http.ResponseWriter, r *http.Request
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.profileChanged()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(definition)
@@ -188,6 +203,7 @@ func (h *Handler) UpdateQualityProfile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.profileChanged()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(profile)
@@ -206,6 +222,7 @@ func (h *Handler) DeleteQualityProfile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.profileChanged()
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -354,3 +371,130 @@ func (h *Handler) ListMediaForUpgrade(w http.ResponseWriter, r *http.Request) {
 		"count":     len(mediaIDs),
 	})
 }
+
+// Custom Format Handlers
+
+func (h *Handler) ListCustomFormats(w http.ResponseWriter, r *http.Request) {
+	formats, err := h.service.ListCustomFormats(r.Context())
+	if err != nil {
+		h.logger.Error("failed to list custom formats", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(formats)
+}
+
+func (h *Handler) GetCustomFormat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	format, err := h.service.GetCustomFormat(r.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get custom format", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(format)
+}
+
+func (h *Handler) CreateCustomFormat(w http.ResponseWriter, r *http.Request) {
+	var params CreateCustomFormatParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCustomFormat(params.Name, defaultString(params.MatchType, CustomFormatMatchTerms), params.Pattern, params.Terms); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := h.service.CreateCustomFormat(r.Context(), params)
+	if err != nil {
+		h.logger.Error("failed to create custom format", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(format)
+}
+
+func (h *Handler) UpdateCustomFormat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var params UpdateCustomFormatParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	format, err := h.service.UpdateCustomFormat(r.Context(), id, params)
+	if err != nil {
+		h.logger.Error("failed to update custom format", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(format)
+}
+
+func (h *Handler) DeleteCustomFormat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteCustomFormat(r.Context(), id); err != nil {
+		h.logger.Error("failed to delete custom format", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ScoreRelease shows how a release name would be scored by the current quality
+// definitions and custom formats
+func (h *Handler) ScoreRelease(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ReleaseName string            `json:"release_name"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	scorer, err := h.service.NewReleaseScorer(r.Context())
+	if err != nil {
+		h.logger.Error("failed to load release scorer", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scorer.Score(req.ReleaseName, req.Attributes))
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
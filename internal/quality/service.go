@@ -27,7 +27,7 @@ func NewService(db *pgxpool.Pool) *Service {
 // ListQualityDefinitions lists all quality definitions
 func (s *Service) ListQualityDefinitions(ctx context.Context) ([]QualityDefinition, error) {
 	query := `
-		SELECT id, name, title, resolution, source, modifier, min_size, max_size, weight, created_at, updated_at
+		SELECT id, name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight, created_at, updated_at
 		FROM quality_definitions
 		ORDER BY weight DESC, name
 	`
@@ -43,7 +43,7 @@ func (s *Service) ListQualityDefinitions(ctx context.Context) ([]QualityDefiniti
 		var def QualityDefinition
 		err := rows.Scan(
 			&def.ID, &def.Name, &def.Title, &def.Resolution, &def.Source, &def.Modifier,
-			&def.MinSize, &def.MaxSize, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
+			&def.MinSize, &def.MaxSize, &def.MinMBPerMinute, &def.MaxMBPerMinute, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan quality definition: %w", err)
@@ -57,7 +57,7 @@ func (s *Service) ListQualityDefinitions(ctx context.Context) ([]QualityDefiniti
 // GetQualityDefinition gets a quality definition by ID
 func (s *Service) GetQualityDefinition(ctx context.Context, id int) (*QualityDefinition, error) {
 	query := `
-		SELECT id, name, title, resolution, source, modifier, min_size, max_size, weight, created_at, updated_at
+		SELECT id, name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight, created_at, updated_at
 		FROM quality_definitions
 		WHERE id = $1
 	`
@@ -65,7 +65,7 @@ func (s *Service) GetQualityDefinition(ctx context.Context, id int) (*QualityDef
 	var def QualityDefinition
 	err := s.db.QueryRow(ctx, query, id).Scan(
 		&def.ID, &def.Name, &def.Title, &def.Resolution, &def.Source, &def.Modifier,
-		&def.MinSize, &def.MaxSize, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
+		&def.MinSize, &def.MaxSize, &def.MinMBPerMinute, &def.MaxMBPerMinute, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -80,7 +80,7 @@ func (s *Service) GetQualityDefinition(ctx context.Context, id int) (*QualityDef
 // GetQualityDefinitionByName gets a quality definition by name
 func (s *Service) GetQualityDefinitionByName(ctx context.Context, name string) (*QualityDefinition, error) {
 	query := `
-		SELECT id, name, title, resolution, source, modifier, min_size, max_size, weight, created_at, updated_at
+		SELECT id, name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight, created_at, updated_at
 		FROM quality_definitions
 		WHERE name = $1
 	`
@@ -88,7 +88,7 @@ func (s *Service) GetQualityDefinitionByName(ctx context.Context, name string) (
 	var def QualityDefinition
 	err := s.db.QueryRow(ctx, query, name).Scan(
 		&def.ID, &def.Name, &def.Title, &def.Resolution, &def.Source, &def.Modifier,
-		&def.MinSize, &def.MaxSize, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
+		&def.MinSize, &def.MaxSize, &def.MinMBPerMinute, &def.MaxMBPerMinute, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -103,18 +103,18 @@ func (s *Service) GetQualityDefinitionByName(ctx context.Context, name string) (
 // CreateQualityDefinition creates a new quality definition
 func (s *Service) CreateQualityDefinition(ctx context.Context, params CreateQualityDefinitionParams) (*QualityDefinition, error) {
 	query := `
-		INSERT INTO quality_definitions (name, title, resolution, source, modifier, min_size, max_size, weight)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, title, resolution, source, modifier, min_size, max_size, weight, created_at, updated_at
+		INSERT INTO quality_definitions (name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight, created_at, updated_at
 	`
 
 	var def QualityDefinition
 	err := s.db.QueryRow(ctx, query,
 		params.Name, params.Title, params.Resolution, params.Source, params.Modifier,
-		params.MinSize, params.MaxSize, params.Weight,
+		params.MinSize, params.MaxSize, params.MinMBPerMinute, params.MaxMBPerMinute, params.Weight,
 	).Scan(
 		&def.ID, &def.Name, &def.Title, &def.Resolution, &def.Source, &def.Modifier,
-		&def.MinSize, &def.MaxSize, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
+		&def.MinSize, &def.MaxSize, &def.MinMBPerMinute, &def.MaxMBPerMinute, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create quality definition: %w", err)
@@ -133,18 +133,20 @@ func (s *Service) UpdateQualityDefinition(ctx context.Context, id int, params Up
 			modifier = COALESCE($4, modifier),
 			min_size = COALESCE($5, min_size),
 			max_size = COALESCE($6, max_size),
-			weight = COALESCE($7, weight)
-		WHERE id = $8
-		RETURNING id, name, title, resolution, source, modifier, min_size, max_size, weight, created_at, updated_at
+			min_mb_per_minute = COALESCE($7, min_mb_per_minute),
+			max_mb_per_minute = COALESCE($8, max_mb_per_minute),
+			weight = COALESCE($9, weight)
+		WHERE id = $10
+		RETURNING id, name, title, resolution, source, modifier, min_size, max_size, min_mb_per_minute, max_mb_per_minute, weight, created_at, updated_at
 	`
 
 	var def QualityDefinition
 	err := s.db.QueryRow(ctx, query,
 		params.Title, params.Resolution, params.Source, params.Modifier,
-		params.MinSize, params.MaxSize, params.Weight, id,
+		params.MinSize, params.MaxSize, params.MinMBPerMinute, params.MaxMBPerMinute, params.Weight, id,
 	).Scan(
 		&def.ID, &def.Name, &def.Title, &def.Resolution, &def.Source, &def.Modifier,
-		&def.MinSize, &def.MaxSize, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
+		&def.MinSize, &def.MaxSize, &def.MinMBPerMinute, &def.MaxMBPerMinute, &def.Weight, &def.CreatedAt, &def.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -4,17 +4,21 @@ import "time"
 
 // QualityDefinition represents a specific quality level with detailed specifications
 type QualityDefinition struct {
-	ID         int       `json:"id" db:"id"`
-	Name       string    `json:"name" db:"name"`
-	Title      string    `json:"title" db:"title"`
-	Resolution *int      `json:"resolution,omitempty" db:"resolution"`
-	Source     *string   `json:"source,omitempty" db:"source"`
-	Modifier   *string   `json:"modifier,omitempty" db:"modifier"`
-	MinSize    *int64    `json:"min_size,omitempty" db:"min_size"`
-	MaxSize    *int64    `json:"max_size,omitempty" db:"max_size"`
-	Weight     int       `json:"weight" db:"weight"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         int     `json:"id" db:"id"`
+	Name       string  `json:"name" db:"name"`
+	Title      string  `json:"title" db:"title"`
+	Resolution *int    `json:"resolution,omitempty" db:"resolution"`
+	Source     *string `json:"source,omitempty" db:"source"`
+	Modifier   *string `json:"modifier,omitempty" db:"modifier"`
+	MinSize    *int64  `json:"min_size,omitempty" db:"min_size"`
+	MaxSize    *int64  `json:"max_size,omitempty" db:"max_size"`
+	// Size limits per minute of runtime, used over MinSize and MaxSize when the
+	// runtime of the media is known
+	MinMBPerMinute *float64  `json:"min_mb_per_minute,omitempty" db:"min_mb_per_minute"`
+	MaxMBPerMinute *float64  `json:"max_mb_per_minute,omitempty" db:"max_mb_per_minute"`
+	Weight         int       `json:"weight" db:"weight"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // QualityProfile represents a user-defined quality profile with ordered preferences
@@ -99,25 +103,29 @@ type DetectedQualityInfo struct {
 
 // CreateQualityDefinitionParams represents parameters for creating a quality definition
 type CreateQualityDefinitionParams struct {
-	Name       string  `json:"name" binding:"required"`
-	Title      string  `json:"title" binding:"required"`
-	Resolution *int    `json:"resolution,omitempty"`
-	Source     *string `json:"source,omitempty"`
-	Modifier   *string `json:"modifier,omitempty"`
-	MinSize    *int64  `json:"min_size,omitempty"`
-	MaxSize    *int64  `json:"max_size,omitempty"`
-	Weight     int     `json:"weight"`
+	Name           string   `json:"name" binding:"required"`
+	Title          string   `json:"title" binding:"required"`
+	Resolution     *int     `json:"resolution,omitempty"`
+	Source         *string  `json:"source,omitempty"`
+	Modifier       *string  `json:"modifier,omitempty"`
+	MinSize        *int64   `json:"min_size,omitempty"`
+	MaxSize        *int64   `json:"max_size,omitempty"`
+	MinMBPerMinute *float64 `json:"min_mb_per_minute,omitempty"`
+	MaxMBPerMinute *float64 `json:"max_mb_per_minute,omitempty"`
+	Weight         int      `json:"weight"`
 }
 
 // UpdateQualityDefinitionParams represents parameters for updating a quality definition
 type UpdateQualityDefinitionParams struct {
-	Title      *string `json:"title,omitempty"`
-	Resolution *int    `json:"resolution,omitempty"`
-	Source     *string `json:"source,omitempty"`
-	Modifier   *string `json:"modifier,omitempty"`
-	MinSize    *int64  `json:"min_size,omitempty"`
-	MaxSize    *int64  `json:"max_size,omitempty"`
-	Weight     *int    `json:"weight,omitempty"`
+	Title          *string  `json:"title,omitempty"`
+	Resolution     *int     `json:"resolution,omitempty"`
+	Source         *string  `json:"source,omitempty"`
+	Modifier       *string  `json:"modifier,omitempty"`
+	MinSize        *int64   `json:"min_size,omitempty"`
+	MaxSize        *int64   `json:"max_size,omitempty"`
+	MinMBPerMinute *float64 `json:"min_mb_per_minute,omitempty"`
+	MaxMBPerMinute *float64 `json:"max_mb_per_minute,omitempty"`
+	Weight         *int     `json:"weight,omitempty"`
 }
 
 // CreateQualityProfileParams represents parameters for creating a quality profile
@@ -167,3 +175,56 @@ const (
 	QualitySame   QualityComparisonResult = 0
 	QualityBetter QualityComparisonResult = 1
 )
+
+// CustomFormat match types
+const (
+	CustomFormatMatchTerms = "terms"
+	CustomFormatMatchRegex = "regex"
+)
+
+// CustomFormat is a user-defined rule that adds a positive or negative score to
+// releases it matches, on top of the quality tier
+type CustomFormat struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	MatchType string    `json:"match_type" db:"match_type"` // terms, regex
+	Field     string    `json:"field" db:"field"`           // title, release_group, source, codec_video, codec_audio or a release attribute
+	Pattern   *string   `json:"pattern,omitempty" db:"pattern"`
+	Terms     []string  `json:"terms" db:"terms"`
+	Score     int       `json:"score" db:"score"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateCustomFormatParams represents parameters for creating a custom format
+type CreateCustomFormatParams struct {
+	Name      string   `json:"name" binding:"required"`
+	MatchType string   `json:"match_type"`
+	Field     string   `json:"field"`
+	Pattern   *string  `json:"pattern,omitempty"`
+	Terms     []string `json:"terms,omitempty"`
+	Score     int      `json:"score"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+// UpdateCustomFormatParams represents parameters for updating a custom format
+type UpdateCustomFormatParams struct {
+	Name      *string  `json:"name,omitempty"`
+	MatchType *string  `json:"match_type,omitempty"`
+	Field     *string  `json:"field,omitempty"`
+	Pattern   *string  `json:"pattern,omitempty"`
+	Terms     []string `json:"terms,omitempty"`
+	Score     *int     `json:"score,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+// ReleaseScore is the breakdown of how a release was scored
+type ReleaseScore struct {
+	Quality        *QualityDefinition `json:"quality,omitempty"`
+	QualityScore   int                `json:"quality_score"`
+	FormatScore    int                `json:"format_score"`
+	Total          int                `json:"total"`
+	MatchedFormats []string           `json:"matched_formats"`
+	ReleaseGroup   string             `json:"release_group,omitempty"`
+}
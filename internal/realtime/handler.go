@@ -0,0 +1,174 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// subscribeTimeout is how long a new connection has to send its subscription
+	subscribeTimeout = 10 * time.Second
+
+	// writeTimeout bounds writing a single message to a client
+	writeTimeout = 10 * time.Second
+
+	// pingInterval is how often an idle connection gets a ping message, so
+	// that proxies keep it open and dead clients are noticed
+	pingInterval = 30 * time.Second
+
+	// maxRequestBytes limits the size of messages clients send
+	maxRequestBytes = 64 << 10
+)
+
+// subscription is a message a client sends to change its subscriptions. The
+// first message on a connection must be one.
+type subscription struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// validate checks that all topics of a subscription exist
+func (s subscription) validate() error {
+	for _, topics := range [][]string{s.Subscribe, s.Unsubscribe} {
+		for _, topic := range topics {
+			if !IsValidTopic(topic) {
+				return fmt.Errorf("unknown topic: %s", topic)
+			}
+		}
+	}
+	return nil
+}
+
+// errOriginNotAllowed rejects handshakes from pages of other sites
+var errOriginNotAllowed = errors.New("origin not allowed")
+
+// Handler returns the WebSocket endpoint clients connect to. It must be
+// mounted behind the auth middleware.
+func (h *Hub) Handler() http.Handler {
+	return websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler:   h.serve,
+	}
+}
+
+// checkOrigin accepts handshakes from the server's own pages and the allowed
+// origins. Browsers send the session cookie along with any page's WebSocket
+// connections, so without this check another site could read a user's
+// updates. Clients that aren't browsers send no Origin and are accepted.
+func (h *Hub) checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin == nil || strings.EqualFold(origin.Host, req.Host) || h.allowedOrigins[normalizeOrigin(origin.String())] {
+		return nil
+	}
+	h.logger.Warn("rejected WebSocket connection from another origin",
+		zap.String("origin", origin.String()), zap.String("host", req.Host))
+	return errOriginNotAllowed
+}
+
+// normalizeOrigin returns an origin as "scheme://host[:port]" in lowercase,
+// or as given when it isn't a URL
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return strings.ToLower(strings.TrimSpace(origin))
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// serve runs a client connection until it is closed
+func (h *Hub) serve(ws *websocket.Conn) {
+	defer ws.Close()
+	ws.MaxPayloadBytes = maxRequestBytes
+
+	// The connection outlives the HTTP server's request timeouts
+	ws.SetDeadline(time.Now().Add(subscribeTimeout))
+
+	var first subscription
+	if err := websocket.JSON.Receive(ws, &first); err != nil {
+		h.logger.Debug("WebSocket client sent no subscription", zap.Error(err))
+		return
+	}
+	if err := first.validate(); err != nil {
+		h.send(ws, Message{Type: "error", Data: map[string]string{"error": err.Error()}})
+		return
+	}
+	ws.SetDeadline(time.Time{})
+
+	client := newClient()
+	client.Subscribe(first.Subscribe...)
+	h.register(client)
+	defer h.unregister(client)
+
+	if err := h.send(ws, Message{Type: "subscribed", Data: map[string][]string{"topics": first.Subscribe}}); err != nil {
+		return
+	}
+
+	// Read subscription changes until the client goes away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var sub subscription
+			if err := websocket.JSON.Receive(ws, &sub); err != nil {
+				return
+			}
+			if err := sub.validate(); err != nil {
+				h.send(ws, Message{Type: "error", Data: map[string]string{"error": err.Error()}})
+				continue
+			}
+			client.Unsubscribe(sub.Unsubscribe...)
+			client.Subscribe(sub.Subscribe...)
+		}
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			if err := h.send(ws, Message{Type: "ping"}); err != nil {
+				return
+			}
+		case <-client.wake:
+			msgs, dropped := client.take()
+			if dropped > 0 {
+				// Tell the client to reload what it shows instead of relying
+				// on the updates it missed
+				if err := h.send(ws, Message{Type: "dropped", Data: map[string]int{"count": dropped}}); err != nil {
+					return
+				}
+			}
+			for _, msg := range msgs {
+				if err := h.send(ws, msg); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// send writes a message to a connection
+func (h *Hub) send(ws *websocket.Conn, msg Message) error {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now().UTC()
+	}
+	ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := websocket.JSON.Send(ws, msg); err != nil {
+		h.logger.Debug("Failed to write to WebSocket client", zap.Error(err))
+		return err
+	}
+	return nil
+}
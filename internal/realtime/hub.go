@@ -0,0 +1,262 @@
+// Package realtime pushes events to connected UI clients over WebSockets.
+//
+// Services publish events into a Hub on one or more topics. Every client
+// subscribes to the topics it is interested in and gets the events published
+// on them. Publishing never blocks: a client that can't keep up has waiting
+// events with the same key replaced by newer ones, and its oldest waiting
+// events dropped once its queue is full.
+package realtime
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Topics clients can subscribe to. Events about a media item are also
+// published on MediaTopic of the item.
+const (
+	TopicDownloads = "downloads" // Download progress and status changes
+	TopicImports   = "imports"   // Completed and failed imports
+	TopicHistory   = "history"   // New history events
+	TopicHealth    = "health"    // Plugin health and download warnings
+	TopicJobs      = "jobs"      // Scheduled jobs starting and finishing
+)
+
+// mediaTopicPrefix starts the topic of a single media item, e.g. "media:123"
+const mediaTopicPrefix = "media:"
+
+// maxPending is how many events wait for a slow client before the oldest are
+// dropped
+const maxPending = 256
+
+// MediaTopic returns the topic events about a media item are published on
+func MediaTopic(mediaItemID int64) string {
+	return mediaTopicPrefix + strconv.FormatInt(mediaItemID, 10)
+}
+
+// IsValidTopic reports whether clients can subscribe to a topic
+func IsValidTopic(topic string) bool {
+	switch topic {
+	case TopicDownloads, TopicImports, TopicHistory, TopicHealth, TopicJobs:
+		return true
+	}
+	if id, ok := strings.CutPrefix(topic, mediaTopicPrefix); ok {
+		_, err := strconv.ParseInt(id, 10, 64)
+		return err == nil
+	}
+	return false
+}
+
+// Event is something published to clients
+type Event struct {
+	Type   string      // What happened, e.g. "download.progress"
+	Data   interface{} // Encoded as JSON
+	Topics []string    // Topics the event is published on
+
+	// Key, when set, identifies what the event is the latest state of. A newer
+	// event with the same key replaces one still waiting to be sent.
+	Key string
+}
+
+// Message is an event as it is sent to a client
+type Message struct {
+	Type      string      `json:"type"`
+	Topic     string      `json:"topic"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Hub delivers published events to the clients subscribed to their topics
+type Hub struct {
+	logger *zap.Logger
+
+	// allowedOrigins are the origins besides the server's own that may open
+	// connections, as "scheme://host[:port]"
+	allowedOrigins map[string]bool
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+
+	throttleMu sync.Mutex
+	lastSent   map[string]time.Time // Key of each throttled event, when it was last published
+}
+
+// NewHub creates a new hub
+func NewHub(logger *zap.Logger) *Hub {
+	return &Hub{
+		logger:   logger.With(zap.String("component", "realtime")),
+		clients:  make(map[*Client]struct{}),
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// SetAllowedOrigins sets the origins besides the server's own that browsers
+// may open connections from, such as "https://nimbus.example.com". It must be
+// called before the handler serves connections.
+func (h *Hub) SetAllowedOrigins(origins []string) {
+	h.allowedOrigins = make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		h.allowedOrigins[normalizeOrigin(origin)] = true
+	}
+}
+
+// Publish sends an event to every client subscribed to one of its topics.
+// Publish on a nil Hub does nothing, so event sources don't need to check
+// whether real-time updates are set up.
+func (h *Hub) Publish(event Event) {
+	if h == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if topic, ok := c.match(event.Topics); ok {
+			c.enqueue(Message{Type: event.Type, Topic: topic, Data: event.Data, Timestamp: now}, event.Key)
+		}
+	}
+}
+
+// PublishThrottled publishes an event unless one with the same key was
+// published less than interval ago, for frequent updates like download
+// progress. The event must have a key.
+func (h *Hub) PublishThrottled(event Event, interval time.Duration) {
+	if h == nil {
+		return
+	}
+
+	now := time.Now()
+	h.throttleMu.Lock()
+	if last, ok := h.lastSent[event.Key]; ok && now.Sub(last) < interval {
+		h.throttleMu.Unlock()
+		return
+	}
+	h.lastSent[event.Key] = now
+	if len(h.lastSent) > maxPending {
+		// Forget keys that wouldn't be throttled anymore anyway
+		for key, last := range h.lastSent {
+			if now.Sub(last) >= interval {
+				delete(h.lastSent, key)
+			}
+		}
+	}
+	h.throttleMu.Unlock()
+
+	h.Publish(event)
+}
+
+// register adds a client to the hub
+func (h *Hub) register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+}
+
+// unregister removes a client from the hub
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// Client is a connected subscriber. Messages queue up in the client until its
+// connection writes them.
+type Client struct {
+	mu      sync.Mutex
+	topics  map[string]bool
+	pending []Message
+	keys    map[string]int // Index in pending of the waiting message with each key
+	dropped int            // Messages dropped since the last take
+
+	wake chan struct{} // Signalled when messages are waiting
+}
+
+// newClient creates a client without subscriptions
+func newClient() *Client {
+	return &Client{
+		topics: make(map[string]bool),
+		keys:   make(map[string]int),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Subscribe adds topics to the client's subscriptions
+func (c *Client) Subscribe(topics ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+}
+
+// Unsubscribe removes topics from the client's subscriptions
+func (c *Client) Unsubscribe(topics ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.topics, topic)
+	}
+}
+
+// match returns the first of topics the client is subscribed to
+func (c *Client) match(topics []string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		if c.topics[topic] {
+			return topic, true
+		}
+	}
+	return "", false
+}
+
+// enqueue queues a message without ever blocking. A waiting message with the
+// same key is replaced, and the oldest message is dropped when the queue is full.
+func (c *Client) enqueue(msg Message, key string) {
+	c.mu.Lock()
+	if i, ok := c.keys[key]; ok && key != "" {
+		c.pending[i] = msg
+		c.mu.Unlock()
+		return
+	}
+
+	if len(c.pending) >= maxPending {
+		c.pending = c.pending[1:]
+		c.dropped++
+		for k, i := range c.keys {
+			if i == 0 {
+				delete(c.keys, k)
+			} else {
+				c.keys[k] = i - 1
+			}
+		}
+	}
+	c.pending = append(c.pending, msg)
+	if key != "" {
+		c.keys[key] = len(c.pending) - 1
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns the waiting messages, and how many were dropped
+// since the last take
+func (c *Client) take() ([]Message, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msgs, dropped := c.pending, c.dropped
+	c.pending = nil
+	c.keys = make(map[string]int)
+	c.dropped = 0
+	return msgs, dropped
+}
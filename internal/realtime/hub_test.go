@@ -0,0 +1,188 @@
+package realtime
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+func TestIsValidTopic(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"downloads", true},
+		{"health", true},
+		{"media:123", true},
+		{"media:", false},
+		{"media:abc", false},
+		{"uploads", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidTopic(tt.topic); got != tt.want {
+			t.Errorf("IsValidTopic(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestPublishMatchesSubscriptions(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	downloads := newClient()
+	downloads.Subscribe(TopicDownloads)
+	media := newClient()
+	media.Subscribe(MediaTopic(7))
+	hub.register(downloads)
+	hub.register(media)
+
+	hub.Publish(Event{Type: "download.status", Topics: []string{TopicDownloads, MediaTopic(7)}})
+	hub.Publish(Event{Type: "download.status", Topics: []string{TopicDownloads, MediaTopic(8)}})
+	hub.Publish(Event{Type: "history.event", Topics: []string{TopicHistory}})
+
+	if msgs, _ := downloads.take(); len(msgs) != 2 {
+		t.Errorf("downloads client got %d messages, want 2", len(msgs))
+	}
+	msgs, _ := media.take()
+	if len(msgs) != 1 {
+		t.Fatalf("media client got %d messages, want 1", len(msgs))
+	}
+	if msgs[0].Topic != "media:7" {
+		t.Errorf("topic = %q, want media:7", msgs[0].Topic)
+	}
+}
+
+func TestEnqueueReplacesSameKey(t *testing.T) {
+	c := newClient()
+	c.enqueue(Message{Type: "download.progress", Data: 10}, "a")
+	c.enqueue(Message{Type: "download.status"}, "")
+	c.enqueue(Message{Type: "download.progress", Data: 20}, "a")
+
+	msgs, dropped := c.take()
+	if len(msgs) != 2 || dropped != 0 {
+		t.Fatalf("got %d messages and %d dropped, want 2 and 0", len(msgs), dropped)
+	}
+	if msgs[0].Data != 20 {
+		t.Errorf("progress = %v, want the latest (20) in the original position", msgs[0].Data)
+	}
+}
+
+func TestEnqueueDropsOldestWhenFull(t *testing.T) {
+	c := newClient()
+	c.enqueue(Message{Type: "first"}, "first")
+	for i := 0; i < maxPending; i++ {
+		c.enqueue(Message{Type: "filler"}, "")
+	}
+	// The key of the dropped message must not point into the queue anymore
+	c.enqueue(Message{Type: "first again"}, "first")
+
+	msgs, dropped := c.take()
+	if dropped != 2 {
+		t.Errorf("dropped = %d, want 2", dropped)
+	}
+	if len(msgs) != maxPending {
+		t.Fatalf("got %d messages, want %d", len(msgs), maxPending)
+	}
+	if last := msgs[len(msgs)-1]; last.Type != "first again" {
+		t.Errorf("last message = %q, want %q", last.Type, "first again")
+	}
+}
+
+func TestPublishThrottled(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	c := newClient()
+	c.Subscribe(TopicDownloads)
+	hub.register(c)
+
+	event := Event{Type: "download.progress", Topics: []string{TopicDownloads}, Key: "download.progress:1"}
+	hub.PublishThrottled(event, time.Hour)
+	msgs, _ := c.take()
+	hub.PublishThrottled(event, time.Hour)
+	more, _ := c.take()
+
+	if len(msgs) != 1 || len(more) != 0 {
+		t.Errorf("got %d then %d messages, want 1 then 0", len(msgs), len(more))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := websocket.JSON.Send(ws, subscription{Subscribe: []string{TopicDownloads}}); err != nil {
+		t.Fatalf("send subscription: %v", err)
+	}
+	var msg Message
+	if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "subscribed" {
+		t.Fatalf("got %+v (%v), want subscribed", msg, err)
+	}
+
+	hub.Publish(Event{Type: "history.event", Topics: []string{TopicHistory}})
+	hub.Publish(Event{Type: "download.status", Data: map[string]string{"id": "1"}, Topics: []string{TopicDownloads}})
+
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if msg.Type != "download.status" || msg.Topic != TopicDownloads {
+		t.Errorf("got %s on %s, want download.status on downloads", msg.Type, msg.Topic)
+	}
+}
+
+func TestHandlerChecksOrigin(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	hub.SetAllowedOrigins([]string{"https://Nimbus.example.com/"})
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{server.URL, true},
+		{"https://nimbus.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://nimbus.example.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		ws, err := websocket.Dial(url, "", tt.origin)
+		if err == nil {
+			ws.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("dial from %s: error = %v, want accepted %v", tt.origin, err, tt.ok)
+		}
+	}
+}
+
+func TestHandlerRejectsUnknownTopic(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	websocket.JSON.Send(ws, subscription{Subscribe: []string{"secrets"}})
+	var msg Message
+	if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "error" {
+		t.Fatalf("got %+v (%v), want error", msg, err)
+	}
+}
@@ -0,0 +1,111 @@
+package recyclebin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// deletionDir is the directory in the recycle bin the files of one deletion go
+// to, e.g. 20260102-150405-42 for media item 42
+func deletionDir(bin string, itemID int64, at time.Time) string {
+	return filepath.Join(bin, fmt.Sprintf("%s-%d", at.UTC().Format("20060102-150405"), itemID))
+}
+
+// relativePath is where a file goes below its deletion's directory: its path
+// within the root folder holding it, or its full path when it is in none, so
+// the library structure is kept
+func relativePath(path string, roots []string) string {
+	best := ""
+	for _, root := range roots {
+		if isWithin(path, root) && len(root) > len(best) {
+			best = root
+		}
+	}
+	if best != "" {
+		if rel, err := filepath.Rel(best, path); err == nil && rel != "." {
+			return filepath.Join(filepath.Base(best), rel)
+		}
+	}
+	return strings.TrimLeft(strings.TrimPrefix(path, filepath.VolumeName(path)), string(filepath.Separator))
+}
+
+// isWithin reports whether path is dir or lies below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// moveFile moves a file, creating the destination's directory and copying the
+// file when the destination is on another filesystem. An existing file at the
+// destination is never replaced.
+func moveFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies a file with its permissions, removing a partial copy when
+// the copy fails
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping
+// at any of the stop directories
+func removeEmptyDirs(dir string, stop []string) {
+	for {
+		for _, s := range stop {
+			if filepath.Clean(dir) == filepath.Clean(s) {
+				return
+			}
+		}
+		if dir == filepath.Dir(dir) || os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
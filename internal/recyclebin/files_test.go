@@ -0,0 +1,118 @@
+package recyclebin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRelativePath(t *testing.T) {
+	roots := []string{"/media/tv", "/media/tv/anime", "/media/movies"}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/media/tv/Show (2020)/Season 01/Show - S01E01.mkv", "tv/Show (2020)/Season 01/Show - S01E01.mkv"},
+		{"/media/tv/anime/Show/Show - 001.mkv", "anime/Show/Show - 001.mkv"},
+		{"/media/movies/Movie (1999)/Movie.mkv", "movies/Movie (1999)/Movie.mkv"},
+		{"/media/tvshows/Other.mkv", "media/tvshows/Other.mkv"},
+		{"/downloads/Movie.mkv", "downloads/Movie.mkv"},
+	}
+	for _, tt := range tests {
+		if got := relativePath(tt.path, roots); got != filepath.FromSlash(tt.want) {
+			t.Errorf("relativePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDeletionDir(t *testing.T) {
+	at := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	if got, want := deletionDir("/recycle", 42, at), "/recycle/20260102-150405-42"; got != want {
+		t.Errorf("deletionDir() = %q, want %q", got, want)
+	}
+}
+
+func TestMoveFileKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "library", "Movie", "Movie.mkv")
+	dst := filepath.Join(dir, "recycle", "movies", "Movie", "Movie.mkv")
+	writeFile(t, src, "movie")
+
+	if err := moveFile(src, dst); err != nil {
+		t.Fatalf("moveFile() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists after the move: %v", err)
+	}
+	if got := readFile(t, dst); got != "movie" {
+		t.Errorf("moved file = %q, want %q", got, "movie")
+	}
+
+	// Moving back onto a file that appeared since must not replace it
+	writeFile(t, src, "new download")
+	if err := moveFile(dst, src); err == nil {
+		t.Fatal("moveFile() replaced an existing file")
+	}
+	if got := readFile(t, src); got != "new download" {
+		t.Errorf("existing file = %q, want it untouched", got)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+	season := filepath.Join(root, "Show", "Season 01")
+	if err := os.MkdirAll(season, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "Other", "Other.mkv"), "x")
+
+	removeEmptyDirs(season, []string{root})
+	if _, err := os.Stat(filepath.Join(root, "Show")); !os.IsNotExist(err) {
+		t.Errorf("empty series folder was kept: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Errorf("root folder was removed: %v", err)
+	}
+
+	removeEmptyDirs(filepath.Join(root, "Other"), []string{root})
+	if _, err := os.Stat(filepath.Join(root, "Other", "Other.mkv")); err != nil {
+		t.Errorf("folder with a file was removed: %v", err)
+	}
+}
+
+func TestRemoveEmptyTree(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "20260102-150405-42")
+	if err := os.MkdirAll(filepath.Join(dir, "tv", "Show", "Season 01"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	removeEmptyTree(dir)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("empty deletion directory was kept: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "tv", "Show", "left.mkv"), "x")
+	removeEmptyTree(dir)
+	if _, err := os.Stat(filepath.Join(dir, "tv", "Show", "left.mkv")); err != nil {
+		t.Errorf("deletion directory with a file left was removed: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,105 @@
+package recyclebin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles recycle bin HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new recycle bin handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "recyclebin-handler")),
+	}
+}
+
+// actor returns the user making a request
+// Note: Must use the same context key string as the auth middleware ("user")
+func actor(r *http.Request) Actor {
+	claims, ok := r.Context().Value("user").(*auth.Claims)
+	if !ok {
+		return Actor{}
+	}
+	return Actor{UserID: &claims.UserID, Username: claims.Username}
+}
+
+func parseMediaID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError maps a recycle bin error to a response
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string, id int64) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "media item not found")
+	case errors.Is(err, ErrAlreadyDeleted), errors.Is(err, ErrNotDeleted), errors.Is(err, ErrParentDeleted):
+		httputil.RespondError(w, http.StatusConflict, err, message)
+	default:
+		httputil.LogError(h.logger, err, message, zap.Int64("id", id))
+		httputil.RespondError(w, http.StatusInternalServerError, err, message)
+	}
+}
+
+// DeleteMediaItem handles DELETE /api/media/{id}. The item and its seasons and
+// episodes go to the recycle bin, with their files when delete_files=true.
+func (h *Handler) DeleteMediaItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	deleteFiles := r.URL.Query().Get("delete_files") == "true"
+	deletion, err := h.service.Delete(r.Context(), id, deleteFiles, actor(r))
+	if err != nil {
+		h.respondServiceError(w, err, "failed to delete media item", id)
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, deletion)
+}
+
+// RestoreMediaItem handles POST /api/media/{id}/restore
+func (h *Handler) RestoreMediaItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	deletion, err := h.service.Restore(r.Context(), id, actor(r))
+	if err != nil {
+		h.respondServiceError(w, err, "failed to restore media item", id)
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, deletion)
+}
+
+// ListDeleted handles GET /api/media/deleted
+func (h *Handler) ListDeleted(w http.ResponseWriter, r *http.Request) {
+	deletions, err := h.service.List(r.Context())
+	if err != nil {
+		httputil.LogError(h.logger, err, "failed to list deleted media")
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, "failed to list deleted media")
+		return
+	}
+
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": deletions,
+		"total": len(deletions),
+	})
+}
@@ -0,0 +1,647 @@
+// Package recyclebin deletes media items so that they can be restored.
+//
+// A deleted item and its seasons and episodes stay in the database with
+// deleted_at set, hidden from the library, and their monitoring rules are
+// disabled. Their files, when deleted too, are moved into the recycle bin
+// below a directory per deletion that keeps the library structure. A manifest
+// in media_deletions records what was done so that a restore can undo it, until
+// the cleanup job purges deletions older than the retention window.
+package recyclebin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/history"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// defaultRetentionDays is how long deleted media can be restored when
+// library.recycle_bin_retention_days isn't set
+const defaultRetentionDays = 7
+
+var (
+	// ErrNotFound is returned for a media item that doesn't exist
+	ErrNotFound = errors.New("media item not found")
+
+	// ErrAlreadyDeleted is returned when deleting an item in the recycle bin
+	ErrAlreadyDeleted = errors.New("media item is already deleted")
+
+	// ErrNotDeleted is returned when restoring an item that isn't deleted
+	ErrNotDeleted = errors.New("media item is not deleted")
+
+	// ErrParentDeleted is returned when restoring an item whose parent is
+	// deleted, which has to be restored first
+	ErrParentDeleted = errors.New("parent media item is deleted")
+)
+
+// Actor is the user deleting or restoring media
+type Actor struct {
+	UserID   *int64
+	Username string
+}
+
+// DeletedFile is a file of a deleted media item
+type DeletedFile struct {
+	MediaFileID  int64  `json:"media_file_id"`
+	Path         string `json:"path"`                    // Where the file was in the library
+	RecycledPath string `json:"recycled_path,omitempty"` // Where it is now, empty when deleted outright or already missing
+	Size         int64  `json:"size"`
+}
+
+// Deletion is a media item in the recycle bin
+type Deletion struct {
+	ID              int64         `json:"id"`
+	MediaItemID     int64         `json:"media_item_id"`
+	Title           string        `json:"title"`
+	Kind            string        `json:"kind"`
+	ItemIDs         []int64       `json:"item_ids"`
+	Files           []DeletedFile `json:"files"`
+	FilesDeleted    bool          `json:"files_deleted"`
+	RecyclePath     *string       `json:"recycle_path,omitempty"`
+	DeletedByUserID *int64        `json:"deleted_by_user_id,omitempty"`
+	DeletedAt       time.Time     `json:"deleted_at"`
+	PurgeAfter      *time.Time    `json:"purge_after,omitempty"` // Nil when deletions are kept forever
+
+	disabledRuleIDs []int64
+}
+
+// Service deletes, restores and purges media items
+type Service struct {
+	db          *pgxpool.Pool
+	configStore *configstore.Store
+	history     *history.Service
+	logger      *zap.Logger
+}
+
+// NewService creates a new recycle bin service
+func NewService(db *pgxpool.Pool, configStore *configstore.Store, historySvc *history.Service, logger *zap.Logger) *Service {
+	return &Service{
+		db:          db,
+		configStore: configStore,
+		history:     historySvc,
+		logger:      logger.With(zap.String("component", "recyclebin")),
+	}
+}
+
+// binPath returns the recycle bin directory, empty when files are deleted
+// outright. The library setting takes over from the older downloads one.
+func (s *Service) binPath(ctx context.Context) string {
+	if path := s.configStore.GetOrDefault(ctx, "library.recycle_bin", ""); path != "" {
+		return path
+	}
+	return s.configStore.GetOrDefault(ctx, "downloads.recycle_bin", "")
+}
+
+// retention returns how long deletions are kept, 0 when forever
+func (s *Service) retention(ctx context.Context) time.Duration {
+	days := s.configStore.GetIntOrDefault(ctx, "library.recycle_bin_retention_days", defaultRetentionDays)
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// rootFolders lists the root folder paths, which recycled files are placed
+// relative to
+func (s *Service) rootFolders(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Query(ctx, `SELECT path FROM root_folders`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list root folders: %w", err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// Delete soft-deletes a media item with its descendants and disables their
+// monitoring. With deleteFiles their files are moved into the recycle bin, or
+// deleted outright when no recycle bin is configured, in which case a restore
+// only brings back the item.
+func (s *Service) Delete(ctx context.Context, id int64, deleteFiles bool, actor Actor) (*Deletion, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	d := &Deletion{MediaItemID: id, FilesDeleted: deleteFiles, DeletedByUserID: actor.UserID}
+	var deletedAt *time.Time
+	err = tx.QueryRow(ctx, `SELECT title, kind, deleted_at FROM media_items WHERE id = $1 FOR UPDATE`, id).
+		Scan(&d.Title, &d.Kind, &deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+	if deletedAt != nil {
+		return nil, ErrAlreadyDeleted
+	}
+
+	// A collection only groups its movies, which stay in the library without it
+	if d.Kind == "movie_collection" {
+		if _, err := tx.Exec(ctx, `UPDATE media_items SET parent_id = NULL, updated_at = NOW() WHERE parent_id = $1`, id); err != nil {
+			return nil, fmt.Errorf("failed to detach collection movies: %w", err)
+		}
+	}
+
+	// Descendants deleted on their own before keep their own manifest
+	rows, err := tx.Query(ctx, `
+		WITH RECURSIVE subtree AS (
+		    SELECT id FROM media_items WHERE id = $1
+		    UNION ALL
+		    SELECT c.id FROM media_items c JOIN subtree s ON c.parent_id = s.id
+		    WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM subtree
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants: %w", err)
+	}
+	d.ItemIDs, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT id, path, COALESCE(size, 0), '' FROM media_files
+		WHERE media_item_id = ANY($1)
+		ORDER BY path
+	`, d.ItemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media files: %w", err)
+	}
+	d.Files, err = pgx.CollectRows(rows, pgx.RowToStructByPos[DeletedFile])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media files: %w", err)
+	}
+
+	now := time.Now()
+	bin := ""
+	var roots []string
+	var removals []string
+	if deleteFiles {
+		if roots, err = s.rootFolders(ctx); err != nil {
+			return nil, err
+		}
+		bin = s.binPath(ctx)
+		if bin != "" {
+			dir := deletionDir(bin, id, now)
+			d.RecyclePath = &dir
+			if err := s.recycleFiles(d.Files, dir, roots); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, f := range d.Files {
+				removals = append(removals, f.Path)
+			}
+		}
+	}
+
+	// Files moved back if anything below fails
+	committed := false
+	defer func() {
+		if !committed && d.RecyclePath != nil {
+			s.unrecycleFiles(d.Files)
+		}
+	}()
+
+	if deleteFiles {
+		for _, f := range d.Files {
+			if f.RecycledPath != "" {
+				_, err = tx.Exec(ctx, `UPDATE media_files SET path = $2, updated_at = NOW() WHERE id = $1`, f.MediaFileID, f.RecycledPath)
+			} else {
+				_, err = tx.Exec(ctx, `DELETE FROM media_files WHERE id = $1`, f.MediaFileID)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to update media file %s: %w", f.Path, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE media_items SET deleted_at = $2 WHERE id = ANY($1)`, d.ItemIDs, now); err != nil {
+		return nil, fmt.Errorf("failed to delete media items: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		UPDATE monitoring_rules SET enabled = false, updated_at = NOW()
+		WHERE media_item_id = ANY($1) AND enabled
+		RETURNING id
+	`, d.ItemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmonitor media items: %w", err)
+	}
+	d.disabledRuleIDs, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmonitor media items: %w", err)
+	}
+	if d.disabledRuleIDs == nil {
+		d.disabledRuleIDs = []int64{}
+	}
+
+	filesJSON, err := json.Marshal(d.Files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode deleted files: %w", err)
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO media_deletions (media_item_id, item_ids, disabled_rule_ids, files, files_deleted, recycle_path, deleted_by_user_id, deleted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, deleted_at
+	`, id, d.ItemIDs, d.disabledRuleIDs, filesJSON, deleteFiles, d.RecyclePath, actor.UserID, now).Scan(&d.ID, &d.DeletedAt); err != nil {
+		return nil, fmt.Errorf("failed to record deletion: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit deletion: %w", err)
+	}
+	committed = true
+
+	for _, path := range removals {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("Failed to delete media file", zap.String("path", path), zap.Error(err))
+		}
+	}
+	// Folders left empty are cleaned up, but only inside root folders
+	if deleteFiles {
+		for _, f := range d.Files {
+			for _, root := range roots {
+				if isWithin(f.Path, root) {
+					removeEmptyDirs(filepath.Dir(f.Path), roots)
+					break
+				}
+			}
+		}
+	}
+
+	if retention := s.retention(ctx); retention > 0 {
+		purgeAfter := d.DeletedAt.Add(retention)
+		d.PurgeAfter = &purgeAfter
+	}
+
+	paths := make([]string, len(d.Files))
+	for i, f := range d.Files {
+		paths[i] = f.Path
+	}
+	s.history.Record(ctx, history.Event{
+		Type:        history.EventDeleted,
+		MediaItemID: &d.MediaItemID,
+		Title:       d.Title,
+		Data: map[string]interface{}{
+			"kind":          "media_item",
+			"media_kind":    d.Kind,
+			"files_deleted": deleteFiles,
+			"recycled":      d.RecyclePath != nil,
+			"paths":         paths,
+			"deletion_id":   d.ID,
+			"user_id":       actor.UserID,
+			"username":      actor.Username,
+		},
+	})
+
+	s.logger.Info("Deleted media item",
+		zap.Int64("media_item_id", id),
+		zap.Int("items", len(d.ItemIDs)),
+		zap.Int("files", len(d.Files)),
+		zap.Bool("files_deleted", deleteFiles),
+		zap.Stringp("recycle_path", d.RecyclePath))
+	return d, nil
+}
+
+// recycleFiles moves files into a deletion's directory, recording where each
+// went. Files already missing are left out. When a move fails, the files moved
+// so far are put back.
+func (s *Service) recycleFiles(files []DeletedFile, dir string, roots []string) error {
+	for i := range files {
+		f := &files[i]
+		if _, err := os.Lstat(f.Path); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(dir, relativePath(f.Path, roots))
+		if err := moveFile(f.Path, dst); err != nil {
+			s.unrecycleFiles(files[:i])
+			return fmt.Errorf("failed to move %s to the recycle bin: %w", f.Path, err)
+		}
+		f.RecycledPath = dst
+	}
+	return nil
+}
+
+// unrecycleFiles moves recycled files back to where they were, logging the
+// ones that can't be. It is used to undo a deletion that failed.
+func (s *Service) unrecycleFiles(files []DeletedFile) {
+	for i := range files {
+		f := &files[i]
+		if f.RecycledPath == "" {
+			continue
+		}
+		if err := moveFile(f.RecycledPath, f.Path); err != nil {
+			s.logger.Error("Failed to move file back from the recycle bin",
+				zap.String("path", f.Path),
+				zap.String("recycled_path", f.RecycledPath),
+				zap.Error(err))
+			continue
+		}
+		f.RecycledPath = ""
+	}
+}
+
+// Restore brings back a deleted media item with the descendants deleted with
+// it, moves its recycled files back and re-enables the monitoring rules the
+// deletion disabled
+func (s *Service) Restore(ctx context.Context, id int64, actor Actor) (*Deletion, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var title, kind string
+	var deletedAt *time.Time
+	var parentDeleted bool
+	err = tx.QueryRow(ctx, `
+		SELECT mi.title, mi.kind, mi.deleted_at, COALESCE(parent.deleted_at IS NOT NULL, false)
+		FROM media_items mi
+		LEFT JOIN media_items parent ON parent.id = mi.parent_id
+		WHERE mi.id = $1
+		FOR UPDATE OF mi
+	`, id).Scan(&title, &kind, &deletedAt, &parentDeleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+	if deletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	if parentDeleted {
+		return nil, ErrParentDeleted
+	}
+
+	d, err := scanDeletion(tx.QueryRow(ctx, deletionQuery+`
+		WHERE d.media_item_id = $1
+		ORDER BY d.deleted_at DESC
+		LIMIT 1
+		FOR UPDATE OF d
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Deleted without a manifest, so there is only the item to bring back
+		d = &Deletion{MediaItemID: id, Title: title, Kind: kind, ItemIDs: []int64{id}}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get deletion: %w", err)
+	}
+
+	// Files are moved back before the rows are revived, and to the recycle bin
+	// again if that fails
+	restored, err := s.restoreFiles(d.Files)
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			s.recycleRestored(restored)
+		}
+	}()
+
+	for _, f := range restored {
+		if _, err := tx.Exec(ctx, `UPDATE media_files SET path = $2, updated_at = NOW() WHERE id = $1`, f.MediaFileID, f.Path); err != nil {
+			return nil, fmt.Errorf("failed to update media file %s: %w", f.Path, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE media_items SET deleted_at = NULL WHERE id = ANY($1)`, d.ItemIDs); err != nil {
+		return nil, fmt.Errorf("failed to restore media items: %w", err)
+	}
+	if len(d.disabledRuleIDs) > 0 {
+		if _, err := tx.Exec(ctx, `
+			UPDATE monitoring_rules SET enabled = true, updated_at = NOW()
+			WHERE id = ANY($1)
+		`, d.disabledRuleIDs); err != nil {
+			return nil, fmt.Errorf("failed to re-enable monitoring: %w", err)
+		}
+	}
+	if d.ID != 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM media_deletions WHERE id = $1`, d.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove deletion: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	committed = true
+
+	if d.RecyclePath != nil {
+		removeEmptyTree(*d.RecyclePath)
+	}
+
+	paths := make([]string, len(restored))
+	for i, f := range restored {
+		paths[i] = f.Path
+	}
+	s.history.Record(ctx, history.Event{
+		Type:        history.EventRestored,
+		MediaItemID: &d.MediaItemID,
+		Title:       d.Title,
+		Data: map[string]interface{}{
+			"kind":        "media_item",
+			"media_kind":  d.Kind,
+			"paths":       paths,
+			"deletion_id": d.ID,
+			"deleted_at":  d.DeletedAt,
+			"user_id":     actor.UserID,
+			"username":    actor.Username,
+		},
+	})
+
+	s.logger.Info("Restored media item",
+		zap.Int64("media_item_id", id),
+		zap.Int("items", len(d.ItemIDs)),
+		zap.Int("files", len(restored)))
+	return d, nil
+}
+
+// restoreFiles moves the recycled files of a deletion back and returns them.
+// Nothing is moved when a file is missing from the recycle bin or its original
+// path is taken, so a restore never overwrites the library.
+func (s *Service) restoreFiles(files []DeletedFile) ([]DeletedFile, error) {
+	var recycled []DeletedFile
+	for _, f := range files {
+		if f.RecycledPath == "" {
+			continue
+		}
+		if _, err := os.Lstat(f.RecycledPath); err != nil {
+			return nil, fmt.Errorf("recycled file %s is missing: %w", f.RecycledPath, err)
+		}
+		if _, err := os.Lstat(f.Path); err == nil {
+			return nil, fmt.Errorf("cannot restore %s, a file already exists there", f.Path)
+		}
+		recycled = append(recycled, f)
+	}
+
+	for i, f := range recycled {
+		if err := moveFile(f.RecycledPath, f.Path); err != nil {
+			s.recycleRestored(recycled[:i])
+			return nil, fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+	}
+	return recycled, nil
+}
+
+// recycleRestored moves restored files back into the recycle bin, to undo a
+// restore that failed
+func (s *Service) recycleRestored(files []DeletedFile) {
+	for _, f := range files {
+		if err := moveFile(f.Path, f.RecycledPath); err != nil {
+			s.logger.Error("Failed to move file back to the recycle bin",
+				zap.String("path", f.Path),
+				zap.String("recycled_path", f.RecycledPath),
+				zap.Error(err))
+		}
+	}
+}
+
+// removeEmptyTree removes a deletion's directory once restoring left only
+// empty directories in it
+func removeEmptyTree(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			return
+		}
+		removeEmptyTree(filepath.Join(dir, e.Name()))
+	}
+	os.Remove(dir)
+}
+
+// deletionQuery selects deletions with the title and kind of their item
+const deletionQuery = `
+	SELECT d.id, d.media_item_id, mi.title, mi.kind, d.item_ids, d.disabled_rule_ids, d.files,
+	       d.files_deleted, d.recycle_path, d.deleted_by_user_id, d.deleted_at
+	FROM media_deletions d
+	JOIN media_items mi ON mi.id = d.media_item_id
+`
+
+func scanDeletion(row pgx.Row) (*Deletion, error) {
+	var d Deletion
+	var files []byte
+	if err := row.Scan(&d.ID, &d.MediaItemID, &d.Title, &d.Kind, &d.ItemIDs, &d.disabledRuleIDs, &files,
+		&d.FilesDeleted, &d.RecyclePath, &d.DeletedByUserID, &d.DeletedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(files, &d.Files); err != nil {
+		return nil, fmt.Errorf("invalid files of deletion %d: %w", d.ID, err)
+	}
+	return &d, nil
+}
+
+// List returns the media items in the recycle bin, most recently deleted first
+func (s *Service) List(ctx context.Context) ([]*Deletion, error) {
+	rows, err := s.db.Query(ctx, deletionQuery+`
+		WHERE mi.deleted_at IS NOT NULL
+		  AND d.deleted_at = (SELECT MAX(deleted_at) FROM media_deletions WHERE media_item_id = d.media_item_id)
+		ORDER BY d.deleted_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deletions: %w", err)
+	}
+	defer rows.Close()
+
+	retention := s.retention(ctx)
+	deletions := []*Deletion{}
+	for rows.Next() {
+		d, err := scanDeletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deletion: %w", err)
+		}
+		if retention > 0 {
+			purgeAfter := d.DeletedAt.Add(retention)
+			d.PurgeAfter = &purgeAfter
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+// Purge permanently removes deletions older than the retention window: the
+// recycled files, and the items unless they were brought back since. It
+// returns the number of items removed.
+func (s *Service) Purge(ctx context.Context) (int, error) {
+	retention := s.retention(ctx)
+	if retention == 0 {
+		return 0, nil
+	}
+
+	// Oldest first, so a deletion below an item deleted later is purged before
+	// the item's removal takes its manifest with it
+	rows, err := s.db.Query(ctx, `
+		SELECT d.id, d.media_item_id, d.recycle_path,
+		       mi.deleted_at IS NOT NULL AND NOT EXISTS (
+		           SELECT 1 FROM media_deletions newer
+		           WHERE newer.media_item_id = d.media_item_id AND newer.deleted_at > d.deleted_at
+		       )
+		FROM media_deletions d
+		JOIN media_items mi ON mi.id = d.media_item_id
+		WHERE d.deleted_at < $1
+		ORDER BY d.deleted_at
+	`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired deletions: %w", err)
+	}
+	type expired struct {
+		id, itemID  int64
+		recyclePath *string
+		removeItem  bool
+	}
+	deletions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (expired, error) {
+		var e expired
+		err := row.Scan(&e.id, &e.itemID, &e.recyclePath, &e.removeItem)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired deletions: %w", err)
+	}
+
+	purged := 0
+	for _, e := range deletions {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+		if e.recyclePath != nil {
+			if err := os.RemoveAll(*e.recyclePath); err != nil {
+				s.logger.Warn("Failed to remove recycled files", zap.String("path", *e.recyclePath), zap.Error(err))
+				continue
+			}
+		}
+
+		// Removing the item cascades to its descendants, files, rules and
+		// manifest
+		query := `DELETE FROM media_deletions WHERE id = $1`
+		arg := e.id
+		if e.removeItem {
+			query = `DELETE FROM media_items WHERE id = $1 AND deleted_at IS NOT NULL`
+			arg = e.itemID
+		}
+		if _, err := s.db.Exec(ctx, query, arg); err != nil {
+			return purged, fmt.Errorf("failed to purge deletion %d: %w", e.id, err)
+		}
+		if e.removeItem {
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		s.logger.Info("Purged deleted media", zap.Int("items", purged))
+	}
+	return purged, nil
+}
@@ -0,0 +1,393 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// Handler handles media request HTTP requests
+type Handler struct {
+	service *Service
+	queries *generated.Queries
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new request handler
+func NewHandler(service *Service, queries *generated.Queries, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		queries: queries,
+		logger:  logger.With(zap.String("component", "requests-handler")),
+	}
+}
+
+// createRequest is the body of a new request
+type createRequest struct {
+	TMDBID string `json:"tmdb_id"`
+	Type   string `json:"type"`            // "movie" or "tv"
+	Title  string `json:"title,omitempty"` // Only used when TMDB can't be asked
+	Year   *int32 `json:"year,omitempty"`
+}
+
+// denyRequest is the body of a denial
+type denyRequest struct {
+	Reason string `json:"reason"`
+}
+
+// downloadResponse is an active download of a requested item
+type downloadResponse struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	Progress        int32  `json:"progress"`
+	TotalBytes      *int64 `json:"total_bytes,omitempty"`
+	DownloadedBytes *int64 `json:"downloaded_bytes,omitempty"`
+}
+
+// requestResponse is a request as returned by the API. Approved requests
+// include the downloads of their item and its episodes.
+type requestResponse struct {
+	ID           int64              `json:"id"`
+	UserID       int64              `json:"user_id"`
+	Username     string             `json:"username,omitempty"`
+	Type         string             `json:"type"`
+	TMDBID       string             `json:"tmdb_id"`
+	Title        string             `json:"title"`
+	Year         *int32             `json:"year,omitempty"`
+	PosterURL    *string            `json:"poster_url,omitempty"`
+	Status       string             `json:"status"`
+	DenialReason *string            `json:"denial_reason,omitempty"`
+	MediaItemID  *int64             `json:"media_item_id,omitempty"`
+	ReviewedBy   *int64             `json:"reviewed_by_user_id,omitempty"`
+	ReviewedAt   *time.Time         `json:"reviewed_at,omitempty"`
+	AvailableAt  *time.Time         `json:"available_at,omitempty"`
+	Downloads    []downloadResponse `json:"downloads,omitempty"`
+	Progress     *int               `json:"progress,omitempty"` // Average of the active downloads
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// present builds the response of a request, looking up its requester and,
+// while it is approved, its downloads. usernames caches requesters across a list.
+func (h *Handler) present(r *http.Request, request generated.MediaRequest, usernames map[int64]string) requestResponse {
+	response := requestResponse{
+		ID:           request.ID,
+		UserID:       request.UserID,
+		Type:         request.MediaType,
+		TMDBID:       request.TmdbID,
+		Title:        request.Title,
+		Year:         request.Year,
+		PosterURL:    request.PosterUrl,
+		Status:       request.Status,
+		DenialReason: request.DenialReason,
+		MediaItemID:  request.MediaItemID,
+		ReviewedBy:   request.ReviewedByUserID,
+		CreatedAt:    request.CreatedAt.Time,
+		UpdatedAt:    request.UpdatedAt.Time,
+	}
+	if request.ReviewedAt.Valid {
+		response.ReviewedAt = &request.ReviewedAt.Time
+	}
+	if request.AvailableAt.Valid {
+		response.AvailableAt = &request.AvailableAt.Time
+	}
+
+	username, ok := usernames[request.UserID]
+	if !ok {
+		if user, err := h.queries.GetUserByID(r.Context(), request.UserID); err == nil {
+			username = user.Username
+		}
+		usernames[request.UserID] = username
+	}
+	response.Username = username
+
+	if request.Status == StatusApproved && request.MediaItemID != nil {
+		downloads, err := h.queries.ListMediaRequestDownloads(r.Context(), *request.MediaItemID)
+		if err != nil {
+			h.logger.Warn("failed to list request downloads", zap.Int64("request_id", request.ID), zap.Error(err))
+		}
+		response.Downloads, response.Progress = summarizeDownloads(downloads)
+	}
+	return response
+}
+
+// summarizeDownloads converts the active downloads of a request and averages
+// their progress
+func summarizeDownloads(rows []generated.ListMediaRequestDownloadsRow) ([]downloadResponse, *int) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	downloads := make([]downloadResponse, len(rows))
+	total := 0
+	for i, row := range rows {
+		downloads[i] = downloadResponse{
+			ID:              row.ID,
+			Name:            row.Name,
+			Status:          row.Status,
+			Progress:        row.Progress,
+			TotalBytes:      row.TotalBytes,
+			DownloadedBytes: row.DownloadedBytes,
+		}
+		total += int(row.Progress)
+	}
+	progress := total / len(rows)
+	return downloads, &progress
+}
+
+// userClaims returns the user making a request
+// Note: Must use the same context key string as the auth middleware ("user")
+func userClaims(r *http.Request) (*auth.Claims, bool) {
+	claims, ok := r.Context().Value("user").(*auth.Claims)
+	return claims, ok
+}
+
+func parseRequestID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError writes the response of a failed service call
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Request not found")
+	case errors.Is(err, ErrAlreadyRequested), errors.Is(err, ErrNotPending):
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+	default:
+		httputil.RespondError(w, http.StatusInternalServerError, err, message)
+	}
+}
+
+// CreateRequest submits a request for a movie or series
+// POST /api/requests
+func (h *Handler) CreateRequest(w http.ResponseWriter, r *http.Request) {
+	claims, ok := userClaims(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var req createRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TMDBID == "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "tmdb_id is required")
+		return
+	}
+
+	request, err := h.service.Create(r.Context(), CreateParams{
+		UserID:    claims.UserID,
+		MediaType: req.Type,
+		TMDBID:    req.TMDBID,
+		Title:     req.Title,
+		Year:      req.Year,
+	})
+	if errors.Is(err, ErrInvalidType) || errors.Is(err, ErrTitleRequired) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to create request")
+		return
+	}
+
+	h.logger.Info("request created",
+		zap.Int64("id", request.ID),
+		zap.Int64("user_id", claims.UserID),
+		zap.String("title", request.Title))
+	httputil.RespondJSON(w, http.StatusCreated, h.present(r, *request, map[int64]string{}))
+}
+
+// ListRequests lists requests, newest first. Admins see everyone's requests
+// and may filter by user; other users only see their own.
+// GET /api/requests?status=pending&type=tv&user_id=1&limit=50&offset=0
+func (h *Handler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	claims, ok := userClaims(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	query := r.URL.Query()
+	var filter generated.CountMediaRequestsParams
+	if status := query.Get("status"); status != "" {
+		switch status {
+		case StatusPending, StatusApproved, StatusDenied, StatusAvailable:
+			filter.Status = &status
+		default:
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "status must be one of: pending, approved, denied, available")
+			return
+		}
+	}
+	if mediaType := query.Get("type"); mediaType != "" {
+		if mediaType != TypeMovie && mediaType != TypeTV {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, ErrInvalidType.Error())
+			return
+		}
+		filter.MediaType = &mediaType
+	}
+	if !claims.IsAdmin {
+		filter.UserID = &claims.UserID
+	} else if userID := query.Get("user_id"); userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		filter.UserID = &id
+	}
+
+	limit := defaultListLimit
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxListLimit)
+	}
+	offset := 0
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	requests, err := h.queries.ListMediaRequests(r.Context(), generated.ListMediaRequestsParams{
+		Status:    filter.Status,
+		UserID:    filter.UserID,
+		MediaType: filter.MediaType,
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+	})
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list requests")
+		return
+	}
+	total, err := h.queries.CountMediaRequests(r.Context(), filter)
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to count requests")
+		return
+	}
+
+	usernames := make(map[int64]string)
+	response := make([]requestResponse, len(requests))
+	for i, request := range requests {
+		response[i] = h.present(r, request, usernames)
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"requests": response,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// GetRequest returns a request of the user, or any request to admins
+// GET /api/requests/{id}
+func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.visibleRequest(w, r)
+	if !ok {
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, h.present(r, *request, map[int64]string{}))
+}
+
+// DeleteRequest withdraws a request. Users can withdraw their own pending
+// requests; admins can remove any request.
+// DELETE /api/requests/{id}
+func (h *Handler) DeleteRequest(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.visibleRequest(w, r)
+	if !ok {
+		return
+	}
+	claims, _ := userClaims(r)
+	if !claims.IsAdmin && request.Status != StatusPending {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "only pending requests can be withdrawn")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), request.ID); err != nil {
+		h.respondServiceError(w, err, "Failed to delete request")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApproveRequest approves a pending request, adding and searching for its title
+// POST /api/requests/{id}/approve
+func (h *Handler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRequestID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request ID")
+		return
+	}
+	claims, _ := userClaims(r)
+
+	request, err := h.service.Approve(r.Context(), id, claims.UserID)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to approve request")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, h.present(r, *request, map[int64]string{}))
+}
+
+// DenyRequest denies a pending request
+// POST /api/requests/{id}/deny
+func (h *Handler) DenyRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRequestID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request ID")
+		return
+	}
+	claims, _ := userClaims(r)
+
+	var req denyRequest
+	if r.ContentLength != 0 {
+		if err := httputil.DecodeJSON(r, &req); err != nil {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	request, err := h.service.Deny(r.Context(), id, claims.UserID, req.Reason)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to deny request")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, h.present(r, *request, map[int64]string{}))
+}
+
+// visibleRequest loads the request of the URL when the user may see it,
+// responding with an error otherwise. Requests of other users are reported
+// as not found to non-admins.
+func (h *Handler) visibleRequest(w http.ResponseWriter, r *http.Request) (*generated.MediaRequest, bool) {
+	claims, ok := userClaims(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	id, ok := parseRequestID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request ID")
+		return nil, false
+	}
+
+	request, err := h.service.Get(r.Context(), id)
+	if err == nil && !claims.IsAdmin && request.UserID != claims.UserID {
+		err = ErrNotFound
+	}
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get request")
+		return nil, false
+	}
+	return request, true
+}
@@ -0,0 +1,74 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+)
+
+func TestParseTitleDetails(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		title  string
+		year   int32
+		poster string
+	}{
+		{
+			name:   "movie",
+			body:   `{"id":603,"title":"The Matrix","release_date":"1999-03-30","poster_path":"/matrix.jpg"}`,
+			title:  "The Matrix",
+			year:   1999,
+			poster: tmdbPosterBaseURL + "/matrix.jpg",
+		},
+		{
+			name:  "series",
+			body:  `{"id":1396,"name":"Breaking Bad","first_air_date":"2008-01-20","poster_path":""}`,
+			title: "Breaking Bad",
+			year:  2008,
+		},
+		{
+			name:  "unreleased",
+			body:  `{"id":1,"title":"Untitled","release_date":""}`,
+			title: "Untitled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := parseTitleDetails([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if details.Title != tt.title {
+				t.Errorf("Title = %q, want %q", details.Title, tt.title)
+			}
+			if tt.year == 0 && details.Year != nil {
+				t.Errorf("Year = %d, want none", *details.Year)
+			} else if tt.year != 0 && (details.Year == nil || *details.Year != tt.year) {
+				t.Errorf("Year = %v, want %d", details.Year, tt.year)
+			}
+			if tt.poster == "" && details.PosterURL != nil {
+				t.Errorf("PosterURL = %q, want none", *details.PosterURL)
+			} else if tt.poster != "" && (details.PosterURL == nil || *details.PosterURL != tt.poster) {
+				t.Errorf("PosterURL = %v, want %q", details.PosterURL, tt.poster)
+			}
+		})
+	}
+}
+
+func TestSummarizeDownloads(t *testing.T) {
+	if downloads, progress := summarizeDownloads(nil); downloads != nil || progress != nil {
+		t.Errorf("summarizeDownloads(nil) = %v, %v, want nothing", downloads, progress)
+	}
+
+	downloads, progress := summarizeDownloads([]generated.ListMediaRequestDownloadsRow{
+		{ID: "dl_1", Name: "Show.S01E01", Status: "downloading", Progress: 80},
+		{ID: "dl_2", Name: "Show.S01E02", Status: "queued", Progress: 0},
+	})
+	if len(downloads) != 2 || downloads[0].ID != "dl_1" {
+		t.Errorf("downloads = %+v", downloads)
+	}
+	if progress == nil || *progress != 40 {
+		t.Errorf("progress = %v, want 40", progress)
+	}
+}
@@ -0,0 +1,570 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/blakestevenson/nimbus/internal/monitoring"
+	"github.com/blakestevenson/nimbus/internal/notifications"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// Request statuses
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusDenied    = "denied"
+	StatusAvailable = "available" // Something of the requested item was imported
+)
+
+// Requested media types, as TMDB names them
+const (
+	TypeMovie = "movie"
+	TypeTV    = "tv"
+)
+
+const (
+	// tmdbPluginID is the plugin requested titles are looked up with
+	tmdbPluginID = "tmdb-plugin"
+
+	// tmdbPosterBaseURL is where TMDB poster paths are served from
+	tmdbPosterBaseURL = "https://image.tmdb.org/t/p/w500"
+)
+
+var (
+	// ErrNotFound is returned when a request doesn't exist
+	ErrNotFound = errors.New("request not found")
+
+	// ErrInvalidType is returned for media types other than movie and tv
+	ErrInvalidType = errors.New(`type must be "movie" or "tv"`)
+
+	// ErrAlreadyRequested is returned when a title already has a pending or
+	// approved request
+	ErrAlreadyRequested = errors.New("this title was already requested")
+
+	// ErrNotPending is returned when approving or denying a reviewed request
+	ErrNotPending = errors.New("request was already reviewed")
+
+	// ErrTitleRequired is returned when a title can't be looked up on TMDB and
+	// none was given
+	ErrTitleRequired = errors.New("title is required when the TMDB plugin is not loaded")
+)
+
+// Service manages media requests: users submit them, admins approve or deny
+// them, and approved requests are added to the library and searched for
+type Service struct {
+	queries  *generated.Queries
+	media    media.Service
+	config   *configstore.Store
+	notifier *notifications.Service
+	logger   *zap.Logger
+
+	monitoring *monitoring.Service
+	searcher   *monitoring.AutoSearcher // nil when plugins are unavailable
+	plugins    *plugins.PluginManager   // nil when plugins are disabled
+}
+
+// NewService creates a new request service
+func NewService(queries *generated.Queries, mediaService media.Service, config *configstore.Store, notifier *notifications.Service, logger *zap.Logger) *Service {
+	return &Service{
+		queries:  queries,
+		media:    mediaService,
+		config:   config,
+		notifier: notifier,
+		logger:   logger.With(zap.String("component", "requests")),
+	}
+}
+
+// SetMonitoring sets where approved requests are monitored and searched for
+func (s *Service) SetMonitoring(service *monitoring.Service, searcher *monitoring.AutoSearcher) {
+	s.monitoring = service
+	s.searcher = searcher
+}
+
+// SetPluginManager sets where the TMDB plugin requested titles are looked up
+// with is found
+func (s *Service) SetPluginManager(pm *plugins.PluginManager) {
+	s.plugins = pm
+}
+
+// CreateParams is a request submitted by a user
+type CreateParams struct {
+	UserID    int64
+	MediaType string
+	TMDBID    string
+	Title     string // Used when the title can't be looked up on TMDB
+	Year      *int32
+}
+
+// Create submits a request. The title, year and poster are looked up on TMDB
+// when the plugin is loaded. Admins are notified of the new request.
+func (s *Service) Create(ctx context.Context, params CreateParams) (*generated.MediaRequest, error) {
+	params.MediaType = strings.ToLower(strings.TrimSpace(params.MediaType))
+	params.TMDBID = strings.TrimSpace(params.TMDBID)
+	if params.MediaType != TypeMovie && params.MediaType != TypeTV {
+		return nil, ErrInvalidType
+	}
+	if params.TMDBID == "" {
+		return nil, errors.New("tmdb_id is required")
+	}
+
+	if _, err := s.queries.GetOpenMediaRequest(ctx, generated.GetOpenMediaRequestParams{
+		MediaType: params.MediaType,
+		TmdbID:    params.TMDBID,
+	}); err == nil {
+		return nil, ErrAlreadyRequested
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check for an existing request: %w", err)
+	}
+
+	create := generated.CreateMediaRequestParams{
+		UserID:    params.UserID,
+		MediaType: params.MediaType,
+		TmdbID:    params.TMDBID,
+		Title:     strings.TrimSpace(params.Title),
+		Year:      params.Year,
+	}
+	details, err := s.lookupTitle(ctx, params.MediaType, params.TMDBID)
+	if err != nil {
+		s.logger.Warn("failed to look up requested title on TMDB",
+			zap.String("type", params.MediaType), zap.String("tmdb_id", params.TMDBID), zap.Error(err))
+	}
+	if details != nil {
+		create.Title = details.Title
+		create.Year = details.Year
+		create.PosterUrl = details.PosterURL
+	}
+	if create.Title == "" {
+		return nil, ErrTitleRequired
+	}
+
+	request, err := s.queries.CreateMediaRequest(ctx, create)
+	if isUniqueViolation(err) {
+		return nil, ErrAlreadyRequested
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	s.notifier.Notify(notifications.NewEvent(notifications.EventRequestCreated,
+		"New request", fmt.Sprintf("%s was requested", displayTitle(request)), eventData(request)))
+	return &request, nil
+}
+
+// Get returns a request
+func (s *Service) Get(ctx context.Context, id int64) (*generated.MediaRequest, error) {
+	request, err := s.queries.GetMediaRequest(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	return &request, nil
+}
+
+// Approve approves a pending request: the media item is added to the library
+// unless it already is, monitored with the request quality profile, and
+// searched for in the background
+func (s *Service) Approve(ctx context.Context, id, reviewerID int64) (*generated.MediaRequest, error) {
+	request, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+
+	item, created, err := s.findOrCreateItem(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule *monitoring.MonitoringRule
+	if s.monitoring != nil {
+		rule, err = s.monitor(ctx, item.ID, reviewerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	approved, err := s.queries.ApproveMediaRequest(ctx, generated.ApproveMediaRequestParams{
+		ID:               id,
+		MediaItemID:      &item.ID,
+		ReviewedByUserID: &reviewerID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Reviewed by someone else in the meantime
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve request: %w", err)
+	}
+
+	s.logger.Info("request approved",
+		zap.Int64("request_id", id),
+		zap.Int64("media_item_id", item.ID),
+		zap.Bool("created", created))
+	s.notifyRequester(approved, notifications.EventRequestApproved, "Request approved",
+		fmt.Sprintf("%s was approved and will be downloaded", displayTitle(approved)))
+
+	// A title that was already in the library may be there already
+	if !created && s.monitoring != nil && item.Kind == string(media.MediaKindMovie) {
+		if satisfied, err := s.monitoring.IsMediaSatisfied(ctx, item.ID); err == nil && satisfied {
+			s.MediaImported(ctx, item.ID)
+			return s.Get(ctx, id)
+		}
+	}
+
+	if rule != nil && rule.SearchOnAdd && s.searcher != nil {
+		go func() {
+			if err := s.searcher.SearchRule(context.Background(), rule, monitoring.SearchTypeAutomatic, monitoring.TriggerSourceUser); err != nil {
+				s.logger.Warn("initial search for approved request failed", zap.Int64("request_id", id), zap.Error(err))
+			}
+		}()
+	}
+	return &approved, nil
+}
+
+// Deny denies a pending request, with a reason shown to the requester
+func (s *Service) Deny(ctx context.Context, id, reviewerID int64, reason string) (*generated.MediaRequest, error) {
+	var denialReason *string
+	if reason = strings.TrimSpace(reason); reason != "" {
+		denialReason = &reason
+	}
+
+	denied, err := s.queries.DenyMediaRequest(ctx, generated.DenyMediaRequestParams{
+		ID:               id,
+		DenialReason:     denialReason,
+		ReviewedByUserID: &reviewerID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to deny request: %w", err)
+	}
+
+	message := fmt.Sprintf("%s was denied", displayTitle(denied))
+	if denialReason != nil {
+		message += ": " + *denialReason
+	}
+	s.notifyRequester(denied, notifications.EventRequestDenied, "Request denied", message)
+	return &denied, nil
+}
+
+// Delete withdraws a request
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	deleted, err := s.queries.DeleteMediaRequest(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete request: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MediaImported marks the approved requests of a media item available after a
+// file was imported for it, or for an episode or season of a requested series,
+// and tells the requesters
+func (s *Service) MediaImported(ctx context.Context, mediaItemID int64) {
+	ids := []int64{mediaItemID}
+	current := mediaItemID
+	for depth := 0; depth < 2; depth++ {
+		item, err := s.queries.GetMediaItem(ctx, current)
+		if err != nil || item.ParentID == nil {
+			break
+		}
+		current = *item.ParentID
+		ids = append(ids, current)
+	}
+
+	available, err := s.queries.MarkMediaRequestsAvailable(ctx, ids)
+	if err != nil {
+		s.logger.Error("failed to mark requests available", zap.Int64("media_item_id", mediaItemID), zap.Error(err))
+		return
+	}
+	for _, request := range available {
+		s.logger.Info("request available", zap.Int64("request_id", request.ID), zap.Int64("media_item_id", mediaItemID))
+		s.notifyRequester(request, notifications.EventRequestAvailable, "Request available",
+			fmt.Sprintf("%s is now available", displayTitle(request)))
+	}
+}
+
+// findOrCreateItem returns the library item of a requested title, adding it
+// when it isn't in the library yet
+func (s *Service) findOrCreateItem(ctx context.Context, request *generated.MediaRequest) (*generated.MediaItem, bool, error) {
+	return s.FindOrAddTitle(ctx, Title{
+		MediaType: request.MediaType,
+		TMDBID:    request.TmdbID,
+		Title:     request.Title,
+		Year:      request.Year,
+	}, "request")
+}
+
+// Title is a TMDB movie or series to add to the library
+type Title struct {
+	MediaType string // TypeMovie or TypeTV
+	TMDBID    string
+	Title     string // Kept when the TMDB plugin isn't loaded
+	Year      *int32
+}
+
+// FindOrAddTitle returns the library item of a TMDB movie or series, adding it
+// matched to its TMDB entry when it isn't in the library yet. source says what
+// added the item in the media item created event. created is true when the
+// item was added.
+func (s *Service) FindOrAddTitle(ctx context.Context, title Title, source string) (item *generated.MediaItem, created bool, err error) {
+	kind := string(media.MediaKindMovie)
+	if title.MediaType == TypeTV {
+		kind = string(media.MediaKindTVSeries)
+	}
+
+	// TMDB IDs are stored as strings, but older items may have numbers
+	candidates := []interface{}{title.TMDBID}
+	if n, err := strconv.ParseInt(title.TMDBID, 10, 64); err == nil {
+		candidates = append(candidates, n)
+	}
+	for _, tmdbID := range candidates {
+		filter, _ := json.Marshal(map[string]interface{}{"tmdb": tmdbID})
+		items, err := s.queries.GetMediaItemsByExternalID(ctx, filter)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to look up media item: %w", err)
+		}
+		for i := range items {
+			if items[i].Kind == kind {
+				return &items[i], false, nil
+			}
+		}
+	}
+
+	added, err := s.media.CreateMediaItem(ctx, media.CreateMediaParams{
+		Kind:        media.MediaKind(kind),
+		Title:       title.Title,
+		Year:        title.Year,
+		ExternalIDs: map[string]interface{}{"tmdb": title.TMDBID},
+		Metadata:    map[string]interface{}{"tmdb_id": title.TMDBID},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create media item: %w", err)
+	}
+
+	s.matchItem(ctx, added.ID, title)
+	s.plugins.Events().Publish(plugins.EventMediaItemCreated, map[string]interface{}{
+		"media_item_id": added.ID,
+		"kind":          kind,
+		"title":         added.Title,
+		"external_ids":  added.ExternalIDs,
+		"source":        source,
+	})
+
+	found, err := s.queries.GetMediaItem(ctx, added.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get media item: %w", err)
+	}
+	return &found, true, nil
+}
+
+// matchItem fills in the metadata of an added media item from its TMDB
+// entry. Without the TMDB plugin the item keeps the title it was added with.
+func (s *Service) matchItem(ctx context.Context, mediaItemID int64, title Title) {
+	body, _ := json.Marshal(map[string]string{"tmdb_id": title.TMDBID, "type": title.MediaType})
+	if _, err := s.callTMDB(ctx, "POST", fmt.Sprintf("/api/plugins/tmdb/enrich/%d", mediaItemID), body); err != nil {
+		s.logger.Warn("failed to match added media item on TMDB",
+			zap.Int64("media_item_id", mediaItemID), zap.String("tmdb_id", title.TMDBID), zap.Error(err))
+	}
+}
+
+// monitor returns the monitoring rule of an approved item, creating one from
+// the monitoring defaults of its kind when it has none. The request quality
+// profile, when set, overrides the default one.
+func (s *Service) monitor(ctx context.Context, mediaItemID, reviewerID int64) (*monitoring.MonitoringRule, error) {
+	if rule, err := s.monitoring.GetMonitoringRuleByMediaItem(ctx, mediaItemID); err == nil {
+		return rule, nil
+	}
+
+	params, err := s.monitoring.NewRuleParams(ctx, mediaItemID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if profileID := s.qualityProfileID(ctx); profileID != nil {
+		params.QualityProfileID = profileID
+	}
+	if !slices.Contains(params.Tags, "request") {
+		params.Tags = append(params.Tags, "request")
+	}
+	params.CreatedByUserID = &reviewerID
+
+	rule, err := s.monitoring.CreateMonitoringRule(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// qualityProfileID returns the ID of the quality profile approved requests
+// are monitored with, or nil when it isn't set or doesn't exist
+func (s *Service) qualityProfileID(ctx context.Context) *int {
+	name := s.config.GetOrDefault(ctx, "requests.quality_profile", "")
+	if name == "" {
+		return nil
+	}
+	id, err := s.queries.GetQualityProfileIDByName(ctx, name)
+	if err != nil {
+		s.logger.Warn("request quality profile not found", zap.String("profile", name), zap.Error(err))
+		return nil
+	}
+	profileID := int(id)
+	return &profileID
+}
+
+// notifyRequester sends a request event to server-wide notifiers and to the
+// personal notifiers of the requester
+func (s *Service) notifyRequester(request generated.MediaRequest, eventType notifications.EventType, title, message string) {
+	event := notifications.NewEvent(eventType, title, message, eventData(request))
+	s.notifier.Notify(event)
+	s.notifier.NotifyUser(request.UserID, event)
+}
+
+// eventData describes a request in notification events
+func eventData(request generated.MediaRequest) map[string]interface{} {
+	data := map[string]interface{}{
+		"request_id": request.ID,
+		"user_id":    request.UserID,
+		"media_type": request.MediaType,
+		"tmdb_id":    request.TmdbID,
+		"title":      request.Title,
+		"status":     request.Status,
+	}
+	if request.Year != nil {
+		data["year"] = *request.Year
+	}
+	if request.MediaItemID != nil {
+		data["media_item_id"] = *request.MediaItemID
+	}
+	if request.DenialReason != nil {
+		data["reason"] = *request.DenialReason
+	}
+	return data
+}
+
+// displayTitle is the title of a request with its year
+func displayTitle(request generated.MediaRequest) string {
+	if request.Year != nil {
+		return fmt.Sprintf("%s (%d)", request.Title, *request.Year)
+	}
+	return request.Title
+}
+
+// titleDetails is what a request shows of its TMDB entry
+type titleDetails struct {
+	Title     string
+	Year      *int32
+	PosterURL *string
+}
+
+// lookupTitle gets the title, year and poster of a TMDB movie or series. It
+// returns nil without an error when the TMDB plugin isn't loaded.
+func (s *Service) lookupTitle(ctx context.Context, mediaType, tmdbID string) (*titleDetails, error) {
+	if s.plugins == nil {
+		return nil, nil
+	}
+	if _, ok := s.plugins.GetPlugin(tmdbPluginID); !ok {
+		return nil, nil
+	}
+
+	body, err := s.callTMDB(ctx, "GET", fmt.Sprintf("/api/plugins/tmdb/%s/%s", mediaType, tmdbID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseTitleDetails(body)
+}
+
+// parseTitleDetails reads a TMDB movie or TV show response
+func parseTitleDetails(body []byte) (*titleDetails, error) {
+	var entry struct {
+		Title        string `json:"title"`
+		Name         string `json:"name"`
+		ReleaseDate  string `json:"release_date"`
+		FirstAirDate string `json:"first_air_date"`
+		PosterPath   string `json:"poster_path"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode TMDB response: %w", err)
+	}
+
+	details := &titleDetails{Title: entry.Title}
+	if details.Title == "" {
+		details.Title = entry.Name
+	}
+	date := entry.ReleaseDate
+	if date == "" {
+		date = entry.FirstAirDate
+	}
+	if len(date) >= 4 {
+		if year, err := strconv.Atoi(date[:4]); err == nil {
+			y := int32(year)
+			details.Year = &y
+		}
+	}
+	if entry.PosterPath != "" {
+		poster := tmdbPosterBaseURL + entry.PosterPath
+		details.PosterURL = &poster
+	}
+	return details, nil
+}
+
+// callTMDB sends a request to the TMDB plugin and returns the response body
+func (s *Service) callTMDB(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	if s.plugins == nil {
+		return nil, errors.New("plugins are disabled")
+	}
+	plugin, ok := s.plugins.GetPlugin(tmdbPluginID)
+	if !ok {
+		return nil, errors.New("the TMDB plugin is not loaded")
+	}
+
+	resp, err := plugin.Client.HandleAPI(ctx, &plugins.PluginHTTPRequest{
+		Method:  method,
+		Path:    path,
+		Query:   map[string][]string{},
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var pluginErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(resp.Body, &pluginErr)
+		if pluginErr.Error == "" {
+			pluginErr.Error = fmt.Sprintf("TMDB plugin returned HTTP %d", resp.StatusCode)
+		}
+		return nil, errors.New(pluginErr.Error)
+	}
+	return resp.Body, nil
+}
+
+// isUniqueViolation reports whether err is a second open request for a title
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package rootfolders
+
+// DiskUsage is not supported on this platform
+func DiskUsage(path string) (DiskSpace, error) {
+	return DiskSpace{}, ErrDiskUsageUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package rootfolders
+
+import "syscall"
+
+// DiskUsage returns the free and total bytes of the filesystem containing path.
+// Free space is what an unprivileged process can use.
+func DiskUsage(path string) (DiskSpace, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskSpace{}, err
+	}
+	blockSize := uint64(st.Bsize)
+	return DiskSpace{
+		FreeBytes:  uint64(st.Bavail) * blockSize,
+		TotalBytes: uint64(st.Blocks) * blockSize,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package rootfolders
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// Handler handles root folder HTTP requests
+type Handler struct {
+	service *Service
+	queries *generated.Queries
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new root folder handler
+func NewHandler(service *Service, queries *generated.Queries, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		queries: queries,
+		logger:  logger.With(zap.String("component", "rootfolders-handler")),
+	}
+}
+
+// SetupRoutes registers the root folder routes
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Route("/rootfolders", func(r chi.Router) {
+		r.Get("/", h.ListRootFolders)
+		r.Post("/", h.CreateRootFolder)
+		r.Get("/{id}", h.GetRootFolder)
+		r.Put("/{id}", h.UpdateRootFolder)
+		r.Delete("/{id}", h.DeleteRootFolder)
+	})
+}
+
+// rootFolderRequest is the body of a create or update request
+type rootFolderRequest struct {
+	Path      string `json:"path"`
+	MediaKind string `json:"media_kind"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// validate normalizes a request and returns a message when it is invalid
+func (req *rootFolderRequest) validate() string {
+	req.Path = strings.TrimSpace(req.Path)
+	req.MediaKind = strings.ToLower(strings.TrimSpace(req.MediaKind))
+	if req.Path == "" {
+		return "path is required"
+	}
+	if !filepath.IsAbs(req.Path) {
+		return "path must be absolute"
+	}
+	req.Path = filepath.Clean(req.Path)
+	if !IsValidKind(req.MediaKind) {
+		return "media_kind must be one of: " + strings.Join(Kinds, ", ")
+	}
+	return ""
+}
+
+func parseRootFolderID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// isUniqueViolation reports whether err is a duplicate path
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// ListRootFolders returns all root folders with their free space
+// GET /api/rootfolders
+func (h *Handler) ListRootFolders(w http.ResponseWriter, r *http.Request) {
+	folders, err := h.service.List(r.Context())
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to list root folders")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"root_folders": folders})
+}
+
+// GetRootFolder returns a root folder
+// GET /api/rootfolders/{id}
+func (h *Handler) GetRootFolder(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRootFolderID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid root folder ID")
+		return
+	}
+
+	folder, err := h.queries.GetRootFolder(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Root folder not found")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to get root folder")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, Describe(folder))
+}
+
+// CreateRootFolder adds a root folder
+// POST /api/rootfolders
+func (h *Handler) CreateRootFolder(w http.ResponseWriter, r *http.Request) {
+	var req rootFolderRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	folder, err := h.service.Save(r.Context(), 0, req.Path, req.MediaKind, req.IsDefault)
+	if isUniqueViolation(err) {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "A root folder with this path already exists")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to create root folder")
+		return
+	}
+
+	h.logger.Info("root folder created",
+		zap.Int64("id", folder.ID),
+		zap.String("path", folder.Path),
+		zap.String("media_kind", folder.MediaKind))
+	httputil.RespondJSON(w, http.StatusCreated, Describe(folder))
+}
+
+// UpdateRootFolder replaces a root folder's settings. Media already imported
+// stays where it is.
+// PUT /api/rootfolders/{id}
+func (h *Handler) UpdateRootFolder(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRootFolderID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid root folder ID")
+		return
+	}
+
+	var req rootFolderRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	folder, err := h.service.Save(r.Context(), id, req.Path, req.MediaKind, req.IsDefault)
+	if errors.Is(err, pgx.ErrNoRows) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Root folder not found")
+		return
+	}
+	if isUniqueViolation(err) {
+		httputil.RespondErrorMessage(w, http.StatusConflict, "A root folder with this path already exists")
+		return
+	}
+	if err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to update root folder")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, Describe(folder))
+}
+
+// DeleteRootFolder removes a root folder. Files on disk are left alone; media
+// items that lived in it fall back to the default root folder of their kind.
+// DELETE /api/rootfolders/{id}
+func (h *Handler) DeleteRootFolder(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRootFolderID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid root folder ID")
+		return
+	}
+
+	if _, err := h.queries.GetRootFolder(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httputil.RespondErrorMessage(w, http.StatusNotFound, "Root folder not found")
+			return
+		}
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to get root folder")
+		return
+	}
+
+	if err := h.queries.DeleteRootFolder(r.Context(), id); err != nil {
+		httputil.RespondError(w, http.StatusInternalServerError, err, "Failed to delete root folder")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
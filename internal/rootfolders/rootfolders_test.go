@@ -0,0 +1,80 @@
+package rootfolders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+)
+
+func TestKindForMediaType(t *testing.T) {
+	tests := map[string]string{
+		"movie":        KindMovie,
+		"tv":           KindTV,
+		"tv_series":    KindTV,
+		"tv_episode":   KindTV,
+		"music_album":  KindMusic,
+		"book":         KindBook,
+		"television":   "",
+		"unknown_kind": "",
+	}
+	for mediaType, want := range tests {
+		if got := KindForMediaType(mediaType); got != want {
+			t.Errorf("KindForMediaType(%q) = %q, want %q", mediaType, got, want)
+		}
+	}
+}
+
+func TestRootFolderRequestValidate(t *testing.T) {
+	tests := []struct {
+		req     rootFolderRequest
+		wantErr bool
+	}{
+		{rootFolderRequest{Path: "/media/movies/", MediaKind: "Movie"}, false},
+		{rootFolderRequest{Path: "media/movies", MediaKind: "movie"}, true},
+		{rootFolderRequest{Path: "", MediaKind: "movie"}, true},
+		{rootFolderRequest{Path: "/media/anime", MediaKind: "anime"}, true},
+	}
+	for _, tt := range tests {
+		req := tt.req
+		if msg := req.validate(); (msg != "") != tt.wantErr {
+			t.Errorf("validate(%+v) = %q, wantErr %v", tt.req, msg, tt.wantErr)
+		}
+	}
+
+	req := rootFolderRequest{Path: " /media/movies/ ", MediaKind: " Movie "}
+	req.validate()
+	if req.Path != "/media/movies" || req.MediaKind != KindMovie {
+		t.Errorf("validate() normalized to %q %q", req.Path, req.MediaKind)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	dir := t.TempDir()
+	rf := Describe(generated.RootFolder{ID: 1, Path: dir, MediaKind: KindMovie})
+	if !rf.Accessible || rf.Error != "" {
+		t.Fatalf("Describe(%s) accessible = %v, error = %q", dir, rf.Accessible, rf.Error)
+	}
+	if _, err := DiskUsage(dir); err == nil && (rf.FreeBytes == nil || rf.TotalBytes == nil || *rf.TotalBytes == 0) {
+		t.Errorf("Describe(%s) free = %v, total = %v", dir, rf.FreeBytes, rf.TotalBytes)
+	}
+
+	missing := Describe(generated.RootFolder{Path: filepath.Join(dir, "missing")})
+	if missing.Accessible || missing.Error == "" || missing.FreeBytes != nil {
+		t.Errorf("Describe(missing) = %+v, want inaccessible", missing)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rf := Describe(generated.RootFolder{Path: file}); rf.Accessible {
+		t.Errorf("Describe(file) accessible, want not a directory")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("write check left %d files behind, want 1", len(entries))
+	}
+}
@@ -0,0 +1,237 @@
+package rootfolders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/configstore"
+	"github.com/blakestevenson/nimbus/internal/db/generated"
+	"go.uber.org/zap"
+)
+
+// Media kinds a root folder can hold
+const (
+	KindMovie = "movie"
+	KindTV    = "tv"
+	KindMusic = "music"
+	KindBook  = "book"
+)
+
+// Kinds lists the valid root folder media kinds
+var Kinds = []string{KindMovie, KindTV, KindMusic, KindBook}
+
+// legacyPathKeys are the single-path settings root folders replace
+var legacyPathKeys = map[string]string{
+	KindMovie: "library.movie_path",
+	KindTV:    "library.tv_path",
+	KindMusic: "library.music_path",
+	KindBook:  "library.book_path",
+}
+
+// ErrDiskUsageUnsupported is returned where free space can't be read
+var ErrDiskUsageUnsupported = errors.New("disk usage is not supported on this platform")
+
+// DiskSpace is the free and total space of a filesystem
+type DiskSpace struct {
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// KindForMediaType returns the root folder kind for a media item kind or
+// import media type, e.g. "tv_episode" is placed in a "tv" root folder
+func KindForMediaType(mediaType string) string {
+	switch {
+	case mediaType == "movie":
+		return KindMovie
+	case mediaType == "tv" || strings.HasPrefix(mediaType, "tv_"):
+		return KindTV
+	case mediaType == "music" || strings.HasPrefix(mediaType, "music_"):
+		return KindMusic
+	case mediaType == "book" || strings.HasPrefix(mediaType, "book_"):
+		return KindBook
+	default:
+		return ""
+	}
+}
+
+// IsValidKind reports whether kind is a root folder media kind
+func IsValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// RootFolder is a root folder along with the live state of its directory
+type RootFolder struct {
+	ID         int64     `json:"id"`
+	Path       string    `json:"path"`
+	MediaKind  string    `json:"media_kind"`
+	IsDefault  bool      `json:"is_default"`
+	Accessible bool      `json:"accessible"`
+	Error      string    `json:"error,omitempty"` // Why the folder isn't accessible
+	FreeBytes  *uint64   `json:"free_bytes"`
+	TotalBytes *uint64   `json:"total_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Service manages root folders
+type Service struct {
+	queries     *generated.Queries
+	configStore *configstore.Store
+	logger      *zap.Logger
+}
+
+// NewService creates a new root folder service
+func NewService(queries *generated.Queries, configStore *configstore.Store, logger *zap.Logger) *Service {
+	return &Service{
+		queries:     queries,
+		configStore: configStore,
+		logger:      logger.With(zap.String("component", "rootfolders")),
+	}
+}
+
+// Describe returns a root folder with its accessibility and free space
+func Describe(folder generated.RootFolder) RootFolder {
+	rf := RootFolder{
+		ID:        folder.ID,
+		Path:      folder.Path,
+		MediaKind: folder.MediaKind,
+		IsDefault: folder.IsDefault,
+		CreatedAt: folder.CreatedAt.Time,
+		UpdatedAt: folder.UpdatedAt.Time,
+	}
+
+	if err := checkAccessible(folder.Path); err != nil {
+		rf.Error = err.Error()
+		return rf
+	}
+	rf.Accessible = true
+
+	if space, err := DiskUsage(folder.Path); err == nil {
+		rf.FreeBytes = &space.FreeBytes
+		rf.TotalBytes = &space.TotalBytes
+	}
+	return rf
+}
+
+// checkAccessible verifies that a root folder is a directory media can be
+// written to
+func checkAccessible(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("folder does not exist")
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("path is not a directory")
+	}
+
+	probe, err := os.CreateTemp(path, ".nimbus-write-test-*")
+	if err != nil {
+		return fmt.Errorf("folder is not writable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// List returns all root folders with their live state
+func (s *Service) List(ctx context.Context) ([]RootFolder, error) {
+	folders, err := s.queries.ListRootFolders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list root folders: %w", err)
+	}
+
+	result := make([]RootFolder, len(folders))
+	for i, folder := range folders {
+		result[i] = Describe(folder)
+	}
+	return result, nil
+}
+
+// Save creates a root folder, or updates it when id is non-zero. Making a
+// folder the default unsets the previous default of its kind, and the first
+// folder of a kind always becomes the default.
+func (s *Service) Save(ctx context.Context, id int64, path, kind string, isDefault bool) (generated.RootFolder, error) {
+	if !isDefault {
+		count, err := s.queries.CountRootFoldersByKind(ctx, kind)
+		if err != nil {
+			return generated.RootFolder{}, fmt.Errorf("failed to count root folders: %w", err)
+		}
+		isDefault = count == 0 || (count == 1 && id != 0 && s.isOnlyFolder(ctx, id, kind))
+	}
+
+	if isDefault {
+		if err := s.queries.ClearDefaultRootFolder(ctx, kind); err != nil {
+			return generated.RootFolder{}, fmt.Errorf("failed to clear default root folder: %w", err)
+		}
+	}
+
+	if id == 0 {
+		return s.queries.CreateRootFolder(ctx, generated.CreateRootFolderParams{
+			Path:      path,
+			MediaKind: kind,
+			IsDefault: isDefault,
+		})
+	}
+	return s.queries.UpdateRootFolder(ctx, generated.UpdateRootFolderParams{
+		Path:      path,
+		MediaKind: kind,
+		IsDefault: isDefault,
+		ID:        id,
+	})
+}
+
+// isOnlyFolder reports whether the folder being updated is the only one of
+// its kind
+func (s *Service) isOnlyFolder(ctx context.Context, id int64, kind string) bool {
+	folder, err := s.queries.GetRootFolder(ctx, id)
+	return err == nil && folder.MediaKind == kind
+}
+
+// EnsureDefaults creates a default root folder for every media kind that has
+// a library path setting but no root folder yet, so existing libraries keep
+// importing to the same place
+func (s *Service) EnsureDefaults(ctx context.Context) error {
+	for _, kind := range Kinds {
+		count, err := s.queries.CountRootFoldersByKind(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("failed to count root folders: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		path := s.configStore.GetOrDefault(ctx, legacyPathKeys[kind], "")
+		if path == "" {
+			continue
+		}
+
+		if _, err := s.queries.CreateRootFolder(ctx, generated.CreateRootFolderParams{
+			Path:      filepath.Clean(path),
+			MediaKind: kind,
+			IsDefault: true,
+		}); err != nil {
+			s.logger.Warn("failed to create root folder from library path",
+				zap.String("media_kind", kind),
+				zap.String("path", path),
+				zap.Error(err))
+			continue
+		}
+		s.logger.Info("created root folder from library path",
+			zap.String("media_kind", kind),
+			zap.String("path", path))
+	}
+	return nil
+}
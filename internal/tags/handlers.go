@@ -0,0 +1,240 @@
+package tags
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles tag HTTP requests
+type Handler struct {
+	service       *Service
+	pluginManager *plugins.PluginManager
+	logger        *zap.Logger
+}
+
+// NewHandler creates a new tag handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "tags-handler")),
+	}
+}
+
+// SetPluginManager lets tag usage tell indexer and downloader plugins apart
+func (h *Handler) SetPluginManager(pm *plugins.PluginManager) {
+	h.pluginManager = pm
+}
+
+// SetupRoutes registers the routes any user may call
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/tags", h.ListTags)
+	r.Get("/tags/{id}", h.GetTag)
+	r.Get("/tags/{id}/usage", h.GetTagUsage)
+}
+
+// SetupAdminRoutes registers the routes that change tags, which must be
+// mounted behind the admin middleware
+func SetupAdminRoutes(r chi.Router, h *Handler) {
+	r.Post("/tags", h.CreateTag)
+	r.Put("/tags/{id}", h.RenameTag)
+	r.Delete("/tags/{id}", h.DeleteTag)
+}
+
+// tagRequest is the body of a create or rename request
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+// pluginTagsRequest is the body of a request setting the tags of a plugin
+type pluginTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func parseTagID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError writes the response for an error of the service
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Tag not found")
+	case errors.Is(err, ErrExists):
+		httputil.RespondErrorMessage(w, http.StatusConflict, err.Error())
+	default:
+		httputil.LogError(h.logger, err, message)
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+	}
+}
+
+// ListTags returns all tags
+// GET /api/tags
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.service.List(r.Context())
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to list tags")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"tags": tags})
+}
+
+// GetTag returns a tag
+// GET /api/tags/{id}
+func (h *Handler) GetTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTagID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	tag, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get tag")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, tag)
+}
+
+// GetTagUsage lists the monitoring rules, notifiers and plugins a tag is
+// attached to, so the consequences of deleting it can be shown
+// GET /api/tags/{id}/usage
+func (h *Handler) GetTagUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTagID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	usage, err := h.service.Usage(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get tag usage")
+		return
+	}
+
+	if h.pluginManager != nil {
+		for i, plugin := range usage.Plugins {
+			lp, ok := h.pluginManager.GetPlugin(plugin.ID)
+			if !ok {
+				continue
+			}
+			if lp.IsIndexer {
+				usage.Plugins[i].Roles = append(usage.Plugins[i].Roles, "indexer")
+			}
+			if lp.IsDownloader {
+				usage.Plugins[i].Roles = append(usage.Plugins[i].Roles, "downloader")
+			}
+		}
+	}
+	httputil.RespondJSON(w, http.StatusOK, usage)
+}
+
+// CreateTag adds a tag
+// POST /api/tags
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateName(Normalize(req.Name)); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tag, err := h.service.Create(r.Context(), req.Name)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to create tag")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusCreated, tag)
+}
+
+// RenameTag renames a tag everywhere it is attached
+// PUT /api/tags/{id}
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTagID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var req tagRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateName(Normalize(req.Name)); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tag, err := h.service.Rename(r.Context(), id, req.Name)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to rename tag")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag, detaching it from everything it is attached to
+// DELETE /api/tags/{id}
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTagID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.respondServiceError(w, err, "Failed to delete tag")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPluginTags returns the tags of an indexer or downloader plugin
+// GET /api/plugins/{id}/tags
+func (h *Handler) GetPluginTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.service.GetPluginTags(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Plugin not found")
+		return
+	}
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to get plugin tags")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, pluginTagsRequest{Tags: tags})
+}
+
+// SetPluginTags replaces the tags of an indexer or downloader plugin
+// PUT /api/plugins/{id}/tags
+func (h *Handler) SetPluginTags(w http.ResponseWriter, r *http.Request) {
+	var req pluginTagsRequest
+	if err := httputil.DecodeJSON(r, &req); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, err := NormalizeAll(req.Tags); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tags, err := h.service.SetPluginTags(r.Context(), chi.URLParam(r, "id"), req.Tags)
+	if errors.Is(err, ErrNotFound) {
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Plugin not found")
+		return
+	}
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to set plugin tags")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, pluginTagsRequest{Tags: tags})
+}
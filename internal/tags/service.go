@@ -0,0 +1,424 @@
+// Package tags manages the tags shared by monitoring rules, indexers,
+// downloaders and notifiers.
+//
+// Tags are referenced by name from the tags column of each of those tables.
+// They route work: an indexer or downloader with tags only serves media whose
+// monitoring rule shares one of them, and a notifier with tags only fires for
+// events on such media. Anything without tags serves everything.
+package tags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxNameLength limits the length of a tag name
+const maxNameLength = 64
+
+var (
+	// ErrNotFound is returned for a tag that doesn't exist
+	ErrNotFound = errors.New("tag not found")
+
+	// ErrExists is returned when a tag with the same name already exists
+	ErrExists = errors.New("a tag with this name already exists")
+)
+
+// Tag is a label that can be attached to monitoring rules, indexers,
+// downloaders and notifiers
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RuleUsage is a monitoring rule a tag is attached to
+type RuleUsage struct {
+	ID          int64  `json:"id"`
+	MediaItemID int64  `json:"media_item_id"`
+	Title       string `json:"title"`
+}
+
+// NotifierUsage is a notifier a tag is attached to
+type NotifierUsage struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// PluginUsage is an indexer or downloader plugin a tag is attached to
+type PluginUsage struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"` // indexer and/or downloader, when the plugin is loaded
+}
+
+// Usage lists everything a tag is attached to
+type Usage struct {
+	Tag             Tag             `json:"tag"`
+	MonitoringRules []RuleUsage     `json:"monitoring_rules"`
+	Notifiers       []NotifierUsage `json:"notifiers"`
+	Plugins         []PluginUsage   `json:"plugins"`
+}
+
+// Normalize returns the canonical form of a tag name
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeAll normalizes a list of tag names, dropping duplicates, and
+// returns an error for an invalid name
+func NormalizeAll(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = Normalize(name)
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// validateName checks a normalized tag name
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("tag name is required")
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("tag name must be at most %d characters", maxNameLength)
+	}
+	if strings.ContainsAny(name, ",\n\t") {
+		return fmt.Errorf("tag name must not contain commas or line breaks: %s", name)
+	}
+	return nil
+}
+
+// Matches reports whether something with the given tags serves media with
+// mediaTags: it does when it has no tags or shares at least one
+func Matches(tags, mediaTags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, mediaTag := range mediaTags {
+			if tag == mediaTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Service stores tags and looks up the tags of media and plugins
+type Service struct {
+	db *pgxpool.Pool
+}
+
+// NewService creates a new tag service
+func NewService(db *pgxpool.Pool) *Service {
+	return &Service{db: db}
+}
+
+// List returns all tags, sorted by name
+func (s *Service) List(ctx context.Context) ([]Tag, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, name, created_at FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// Get returns a tag
+func (s *Service) Get(ctx context.Context, id int64) (*Tag, error) {
+	var tag Tag
+	err := s.db.QueryRow(ctx, `SELECT id, name, created_at FROM tags WHERE id = $1`, id).
+		Scan(&tag.ID, &tag.Name, &tag.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return &tag, nil
+}
+
+// Create adds a tag
+func (s *Service) Create(ctx context.Context, name string) (*Tag, error) {
+	name = Normalize(name)
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	var tag Tag
+	err := s.db.QueryRow(ctx, `INSERT INTO tags (name) VALUES ($1) RETURNING id, name, created_at`, name).
+		Scan(&tag.ID, &tag.Name, &tag.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	return &tag, nil
+}
+
+// Ensure creates the tags that don't exist yet, so names set directly on a
+// rule, notifier or plugin show up as tags. The names must be normalized.
+func (s *Service) Ensure(ctx context.Context, names []string) error {
+	if s == nil || len(names) == 0 {
+		return nil
+	}
+	_, err := s.db.Exec(ctx, `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, names)
+	if err != nil {
+		return fmt.Errorf("failed to create tags: %w", err)
+	}
+	return nil
+}
+
+// Rename renames a tag everywhere it is attached
+func (s *Service) Rename(ctx context.Context, id int64, name string) (*Tag, error) {
+	name = Normalize(name)
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var oldName string
+	err = tx.QueryRow(ctx, `SELECT name FROM tags WHERE id = $1 FOR UPDATE`, id).Scan(&oldName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	var tag Tag
+	err = tx.QueryRow(ctx, `UPDATE tags SET name = $2 WHERE id = $1 RETURNING id, name, created_at`, id, name).
+		Scan(&tag.ID, &tag.Name, &tag.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	for _, table := range taggedTables {
+		query := fmt.Sprintf(`UPDATE %s SET tags = array_replace(tags, $1, $2) WHERE $1 = ANY(tags)`, table)
+		if _, err := tx.Exec(ctx, query, oldName, name); err != nil {
+			return nil, fmt.Errorf("failed to rename tag in %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &tag, nil
+}
+
+// Delete removes a tag and detaches it from everything it is attached to
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	err = tx.QueryRow(ctx, `DELETE FROM tags WHERE id = $1 RETURNING name`, id).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	for _, table := range taggedTables {
+		query := fmt.Sprintf(`UPDATE %s SET tags = array_remove(tags, $1) WHERE $1 = ANY(tags)`, table)
+		if _, err := tx.Exec(ctx, query, name); err != nil {
+			return fmt.Errorf("failed to remove tag from %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// taggedTables are the tables with a tags column of tag names
+var taggedTables = []string{"monitoring_rules", "notifiers", "plugins"}
+
+// Usage returns everything a tag is attached to
+func (s *Service) Usage(ctx context.Context, id int64) (*Usage, error) {
+	tag, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{
+		Tag:             *tag,
+		MonitoringRules: []RuleUsage{},
+		Notifiers:       []NotifierUsage{},
+		Plugins:         []PluginUsage{},
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT r.id, r.media_item_id, m.title
+		FROM monitoring_rules r
+		JOIN media_items m ON m.id = r.media_item_id
+		WHERE $1 = ANY(r.tags)
+		ORDER BY m.title, r.id
+	`, tag.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tagged monitoring rules: %w", err)
+	}
+	for rows.Next() {
+		var rule RuleUsage
+		if err := rows.Scan(&rule.ID, &rule.MediaItemID, &rule.Title); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan monitoring rule: %w", err)
+		}
+		usage.MonitoringRules = append(usage.MonitoringRules, rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tagged monitoring rules: %w", err)
+	}
+
+	rows, err = s.db.Query(ctx, `SELECT id, name FROM notifiers WHERE $1 = ANY(tags) ORDER BY name, id`, tag.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tagged notifiers: %w", err)
+	}
+	for rows.Next() {
+		var notifier NotifierUsage
+		if err := rows.Scan(&notifier.ID, &notifier.Name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan notifier: %w", err)
+		}
+		usage.Notifiers = append(usage.Notifiers, notifier)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tagged notifiers: %w", err)
+	}
+
+	rows, err = s.db.Query(ctx, `SELECT id, name FROM plugins WHERE $1 = ANY(tags) ORDER BY name`, tag.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tagged plugins: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		plugin := PluginUsage{Roles: []string{}}
+		if err := rows.Scan(&plugin.ID, &plugin.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan plugin: %w", err)
+		}
+		usage.Plugins = append(usage.Plugins, plugin)
+	}
+	return usage, rows.Err()
+}
+
+// MediaTags returns the tags of the monitoring rule that applies to a media
+// item: its own rule, or else the rule of its season or series
+func (s *Service) MediaTags(ctx context.Context, mediaItemID int64) ([]string, error) {
+	var tags []string
+	err := s.db.QueryRow(ctx, `
+		SELECT r.tags
+		FROM media_items m
+		LEFT JOIN media_items p ON p.id = m.parent_id
+		LEFT JOIN media_items g ON g.id = p.parent_id
+		JOIN monitoring_rules r ON r.media_item_id IN (m.id, p.id, g.id)
+		WHERE m.id = $1
+		ORDER BY CASE r.media_item_id WHEN m.id THEN 0 WHEN p.id THEN 1 ELSE 2 END
+		LIMIT 1
+	`, mediaItemID).Scan(&tags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media tags: %w", err)
+	}
+	return tags, nil
+}
+
+// PluginTags returns the tags of every plugin that has any, by plugin ID
+func (s *Service) PluginTags(ctx context.Context) (map[string][]string, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, tags FROM plugins WHERE cardinality(tags) > 0`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var id string
+		var pluginTags []string
+		if err := rows.Scan(&id, &pluginTags); err != nil {
+			return nil, fmt.Errorf("failed to scan plugin tags: %w", err)
+		}
+		tags[id] = pluginTags
+	}
+	return tags, rows.Err()
+}
+
+// GetPluginTags returns the tags of a plugin
+func (s *Service) GetPluginTags(ctx context.Context, pluginID string) ([]string, error) {
+	var tags []string
+	err := s.db.QueryRow(ctx, `SELECT tags FROM plugins WHERE id = $1`, pluginID).Scan(&tags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin tags: %w", err)
+	}
+	return tags, nil
+}
+
+// SetPluginTags replaces the tags of a plugin, creating tags that don't exist
+func (s *Service) SetPluginTags(ctx context.Context, pluginID string, names []string) ([]string, error) {
+	names, err := NormalizeAll(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Ensure(ctx, names); err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	err = s.db.QueryRow(ctx, `UPDATE plugins SET tags = $2, updated_at = NOW() WHERE id = $1 RETURNING tags`, pluginID, names).Scan(&tags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set plugin tags: %w", err)
+	}
+	return tags, nil
+}
+
+// isUniqueViolation reports whether err is a duplicate tag name
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,49 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAll(t *testing.T) {
+	got, err := NormalizeAll([]string{" Kids ", "4K", "kids", "anime"})
+	if err != nil {
+		t.Fatalf("NormalizeAll: %v", err)
+	}
+	if want := []string{"kids", "4k", "anime"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeAll = %v, want %v", got, want)
+	}
+
+	if got, err := NormalizeAll(nil); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("NormalizeAll(nil) = %#v, %v, want an empty list", got, err)
+	}
+
+	for _, invalid := range []string{"  ", "a,b", "line\nbreak", strings.Repeat("x", maxNameLength+1)} {
+		if _, err := NormalizeAll([]string{invalid}); err == nil {
+			t.Errorf("NormalizeAll(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      []string
+		mediaTags []string
+		want      bool
+	}{
+		{"untagged serves everything", nil, nil, true},
+		{"untagged serves tagged media", nil, []string{"kids"}, true},
+		{"tagged skips untagged media", []string{"kids"}, nil, false},
+		{"shared tag", []string{"anime", "kids"}, []string{"kids"}, true},
+		{"no shared tag", []string{"anime"}, []string{"kids", "4k"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.tags, tt.mediaTags); got != tt.want {
+				t.Errorf("Matches(%v, %v) = %v, want %v", tt.tags, tt.mediaTags, got, tt.want)
+			}
+		})
+	}
+}
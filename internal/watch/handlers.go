@@ -0,0 +1,170 @@
+package watch
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/blakestevenson/nimbus/internal/auth"
+	"github.com/blakestevenson/nimbus/internal/httputil"
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Handler handles watch state HTTP requests
+type Handler struct {
+	service *Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new watch state handler
+func NewHandler(service *Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger.With(zap.String("component", "watch-handler")),
+	}
+}
+
+// SetupRoutes registers the routes of the current user's watch state, which
+// must be mounted behind the auth middleware
+func SetupRoutes(r chi.Router, h *Handler) {
+	r.Get("/users/me/continue-watching", h.ContinueWatching)
+}
+
+// SetupMediaRoutes registers the routes that mark a media item watched or
+// record its progress, which must be mounted on the media router
+func SetupMediaRoutes(r chi.Router, h *Handler) {
+	r.Post("/{id}/watched", h.SetWatched)
+	r.Put("/{id}/progress", h.SetProgress)
+}
+
+// userID returns the user making a request
+// Note: Must use the same context key string as the auth middleware ("user")
+func userID(r *http.Request) (int64, bool) {
+	claims, ok := r.Context().Value("user").(*auth.Claims)
+	if !ok {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+func parseMediaID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	return id, err == nil
+}
+
+// respondServiceError writes the response for an error of the service
+func (h *Handler) respondServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httputil.RespondErrorMessage(w, http.StatusNotFound, "Media item not found")
+	case errors.Is(err, ErrNotPlayable), errors.Is(err, ErrInvalidPosition):
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, err.Error())
+	default:
+		httputil.LogError(h.logger, err, message)
+		httputil.RespondErrorMessage(w, http.StatusInternalServerError, message)
+	}
+}
+
+// SetWatched marks a media item watched or unwatched for the current user.
+// Without a body, it toggles.
+// POST /api/media/{id}/watched
+func (h *Handler) SetWatched(w http.ResponseWriter, r *http.Request) {
+	user, ok := userID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	var body struct {
+		Watched *bool `json:"watched"`
+	}
+	if err := httputil.DecodeJSON(r, &body); err != nil && !errors.Is(err, io.EOF) {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var err error
+	var state *media.WatchState
+	if body.Watched == nil {
+		state, err = h.service.Toggle(r.Context(), user, id)
+	} else {
+		state, err = h.service.SetWatched(r.Context(), user, id, *body.Watched)
+	}
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to set watched")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, state)
+}
+
+// SetProgress records where the current user stopped playing a movie or
+// episode, for player integrations
+// PUT /api/media/{id}/progress
+func (h *Handler) SetProgress(w http.ResponseWriter, r *http.Request) {
+	user, ok := userID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, ok := parseMediaID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	var body struct {
+		PositionSeconds *int `json:"position_seconds"`
+		DurationSeconds int  `json:"duration_seconds"`
+	}
+	if err := httputil.DecodeJSON(r, &body); err != nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if body.PositionSeconds == nil {
+		httputil.RespondErrorMessage(w, http.StatusBadRequest, "position_seconds is required")
+		return
+	}
+
+	state, err := h.service.SetProgress(r.Context(), user, id, *body.PositionSeconds, body.DurationSeconds)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to record progress")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, state)
+}
+
+// ContinueWatching lists the movies and episodes the current user has partly
+// watched, most recently played first
+// GET /api/users/me/continue-watching
+func (h *Handler) ContinueWatching(w http.ResponseWriter, r *http.Request) {
+	user, ok := userID(r)
+	if !ok {
+		httputil.RespondErrorMessage(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			httputil.RespondErrorMessage(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	items, err := h.service.ContinueWatching(r.Context(), user, limit)
+	if err != nil {
+		h.respondServiceError(w, err, "Failed to list continue watching")
+		return
+	}
+	httputil.RespondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
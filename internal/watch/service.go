@@ -0,0 +1,393 @@
+// Package watch records what each user has watched.
+//
+// Movies and episodes are marked watched per user, and players report how far
+// into one a user got. Playback past the watched threshold marks the item
+// watched; playback between the continue threshold and that lists the item
+// under continue watching. Series, seasons and collections aren't watched
+// themselves: marking one watched marks the movies and episodes below it, and
+// their state is counted from those.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/media"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// continueThreshold is how much of an item must be played before it is
+	// listed under continue watching
+	continueThreshold = 0.05
+
+	// watchedThreshold is how much of an item must be played for it to be
+	// watched, so the credits don't have to be
+	watchedThreshold = 0.90
+
+	// defaultContinueLimit is how many items continue watching lists when no
+	// limit is given
+	defaultContinueLimit = 20
+
+	// maxContinueLimit bounds how many items continue watching lists
+	maxContinueLimit = 100
+)
+
+// playableKinds are the kinds of media items that are watched themselves
+var playableKinds = map[media.MediaKind]bool{
+	media.MediaKindMovie:     true,
+	media.MediaKindTVEpisode: true,
+}
+
+var (
+	// ErrNotFound is returned for a media item that doesn't exist
+	ErrNotFound = errors.New("media item not found")
+
+	// ErrNotPlayable is returned when reporting progress of a media item that
+	// isn't a movie or episode
+	ErrNotPlayable = errors.New("progress can only be reported for movies and episodes")
+
+	// ErrInvalidPosition is returned for a negative position or duration
+	ErrInvalidPosition = errors.New("position_seconds and duration_seconds must not be negative")
+)
+
+// ContinueItem is a partly watched movie or episode
+type ContinueItem struct {
+	MediaItemID     int64     `json:"media_item_id"`
+	Kind            string    `json:"kind"`
+	Title           string    `json:"title"`
+	Year            *int32    `json:"year,omitempty"`
+	SeriesID        *int64    `json:"series_id,omitempty"` // Of an episode
+	SeriesTitle     string    `json:"series_title,omitempty"`
+	Season          *int      `json:"season,omitempty"`
+	Episode         *int      `json:"episode,omitempty"`
+	PosterURL       string    `json:"poster_url,omitempty"`
+	PositionSeconds int       `json:"position_seconds"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Progress        float64   `json:"progress"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Service records and reports what users have watched
+type Service struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewService creates a new watch state service
+func NewService(db *pgxpool.Pool, logger *zap.Logger) *Service {
+	return &Service{
+		db:     db,
+		logger: logger.With(zap.String("component", "watch")),
+	}
+}
+
+// itemKind returns the kind of a media item, or ErrNotFound
+func (s *Service) itemKind(ctx context.Context, q pgx.Tx, mediaItemID int64) (media.MediaKind, error) {
+	var kind string
+	err := q.QueryRow(ctx, `SELECT kind FROM media_items WHERE id = $1 AND deleted_at IS NULL`, mediaItemID).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get media item: %w", err)
+	}
+	return media.MediaKind(kind), nil
+}
+
+// Toggle marks a media item watched when it isn't, and unwatched when it is
+func (s *Service) Toggle(ctx context.Context, userID, mediaItemID int64) (*media.WatchState, error) {
+	states, err := s.States(ctx, userID, []int64{mediaItemID})
+	if err != nil {
+		return nil, err
+	}
+	state, ok := states[mediaItemID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.SetWatched(ctx, userID, mediaItemID, !state.Watched)
+}
+
+// SetWatched marks a media item watched or unwatched for a user. A series,
+// season or collection marks the movies and episodes below it. Marking an
+// item watched clears its progress.
+func (s *Service) SetWatched(ctx context.Context, userID, mediaItemID int64, watched bool) (*media.WatchState, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.itemKind(ctx, tx, mediaItemID); err != nil {
+		return nil, err
+	}
+
+	// The item itself when it is playable, otherwise the playable items below it
+	const playable = `
+		WITH RECURSIVE subtree AS (
+		    SELECT id, kind FROM media_items WHERE id = $2
+		    UNION ALL
+		    SELECT c.id, c.kind FROM media_items c JOIN subtree s ON c.parent_id = s.id
+		    WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM subtree WHERE kind IN ('movie', 'tv_episode')`
+	if watched {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO watched_media (user_id, media_item_id)
+			SELECT $1, id FROM (`+playable+`) items
+			ON CONFLICT (user_id, media_item_id) DO NOTHING
+		`, userID, mediaItemID); err != nil {
+			return nil, fmt.Errorf("failed to mark watched: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM playback_progress
+			WHERE user_id = $1 AND media_item_id IN (`+playable+`)
+		`, userID, mediaItemID); err != nil {
+			return nil, fmt.Errorf("failed to clear progress: %w", err)
+		}
+	} else {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM watched_media
+			WHERE user_id = $1 AND media_item_id IN (`+playable+`)
+		`, userID, mediaItemID); err != nil {
+			return nil, fmt.Errorf("failed to mark unwatched: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	states, err := s.States(ctx, userID, []int64{mediaItemID})
+	if err != nil {
+		return nil, err
+	}
+	return states[mediaItemID], nil
+}
+
+// SetProgress records where a user stopped playing a movie or episode. Without
+// a duration, the duration of its file or its runtime is used. Playback past
+// the watched threshold marks the item watched instead.
+func (s *Service) SetProgress(ctx context.Context, userID, mediaItemID int64, positionSeconds, durationSeconds int) (*media.WatchState, error) {
+	if positionSeconds < 0 || durationSeconds < 0 {
+		return nil, ErrInvalidPosition
+	}
+
+	var kind string
+	var knownDuration *float64
+	err := s.db.QueryRow(ctx, `
+		SELECT m.kind, COALESCE(
+		    (SELECT (f.mediainfo->>'duration')::float FROM media_files f
+		     WHERE f.media_item_id = m.id AND f.kind = 'media' AND jsonb_typeof(f.mediainfo->'duration') = 'number'
+		     ORDER BY f.id LIMIT 1),
+		    CASE WHEN jsonb_typeof(m.metadata->'runtime') = 'number' THEN (m.metadata->>'runtime')::float * 60 END)
+		FROM media_items m
+		WHERE m.id = $1 AND m.deleted_at IS NULL
+	`, mediaItemID).Scan(&kind, &knownDuration)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media item: %w", err)
+	}
+	if !playableKinds[media.MediaKind(kind)] {
+		return nil, ErrNotPlayable
+	}
+	if durationSeconds == 0 && knownDuration != nil {
+		durationSeconds = int(*knownDuration)
+	}
+
+	if durationSeconds > 0 && float64(positionSeconds) >= watchedThreshold*float64(durationSeconds) {
+		return s.SetWatched(ctx, userID, mediaItemID, true)
+	}
+
+	var duration *int
+	if durationSeconds > 0 {
+		duration = &durationSeconds
+	}
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO playback_progress (user_id, media_item_id, position_seconds, duration_seconds, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, media_item_id) DO UPDATE SET
+		    position_seconds = EXCLUDED.position_seconds,
+		    duration_seconds = EXCLUDED.duration_seconds,
+		    updated_at = NOW()
+	`, userID, mediaItemID, positionSeconds, duration); err != nil {
+		return nil, fmt.Errorf("failed to record progress: %w", err)
+	}
+
+	states, err := s.States(ctx, userID, []int64{mediaItemID})
+	if err != nil {
+		return nil, err
+	}
+	return states[mediaItemID], nil
+}
+
+// States returns the watch state of media items for a user, by ID. Items
+// that don't exist are left out.
+func (s *Service) States(ctx context.Context, userID int64, ids []int64) (map[int64]*media.WatchState, error) {
+	states := make(map[int64]*media.WatchState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT m.id, m.kind, w.watched_at, p.position_seconds, p.duration_seconds
+		FROM media_items m
+		LEFT JOIN watched_media w ON w.media_item_id = m.id AND w.user_id = $1
+		LEFT JOIN playback_progress p ON p.media_item_id = m.id AND p.user_id = $1
+		WHERE m.id = ANY($2)
+	`, userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watch state: %w", err)
+	}
+	var containers []int64
+	for rows.Next() {
+		var id int64
+		var kind string
+		var watchedAt *time.Time
+		var position, duration *int
+		if err := rows.Scan(&id, &kind, &watchedAt, &position, &duration); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to get watch state: %w", err)
+		}
+		if !playableKinds[media.MediaKind(kind)] {
+			containers = append(containers, id)
+			continue
+		}
+		states[id] = playableState(watchedAt, position, duration)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get watch state: %w", err)
+	}
+
+	if len(containers) == 0 {
+		return states, nil
+	}
+	counts, err := s.db.Query(ctx, `
+		WITH RECURSIVE subtree AS (
+		    SELECT id AS root_id, id, kind FROM media_items WHERE id = ANY($2)
+		    UNION ALL
+		    SELECT s.root_id, c.id, c.kind FROM media_items c JOIN subtree s ON c.parent_id = s.id
+		    WHERE c.deleted_at IS NULL
+		)
+		SELECT s.root_id, COUNT(*), COUNT(w.media_item_id), MAX(w.watched_at)
+		FROM subtree s
+		LEFT JOIN watched_media w ON w.media_item_id = s.id AND w.user_id = $1
+		WHERE s.kind IN ('movie', 'tv_episode')
+		GROUP BY s.root_id
+	`, userID, containers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count watched items: %w", err)
+	}
+	defer counts.Close()
+	for _, id := range containers {
+		states[id] = containerState(0, 0, nil)
+	}
+	for counts.Next() {
+		var id int64
+		var total, watched int
+		var lastWatched *time.Time
+		if err := counts.Scan(&id, &total, &watched, &lastWatched); err != nil {
+			return nil, fmt.Errorf("failed to count watched items: %w", err)
+		}
+		states[id] = containerState(total, watched, lastWatched)
+	}
+	if err := counts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count watched items: %w", err)
+	}
+	return states, nil
+}
+
+// playableState returns the watch state of a movie or episode
+func playableState(watchedAt *time.Time, position, duration *int) *media.WatchState {
+	state := &media.WatchState{Watched: watchedAt != nil, WatchedAt: watchedAt}
+	if position != nil {
+		state.PositionSeconds = position
+		state.DurationSeconds = duration
+		if duration != nil && *duration > 0 {
+			progress := float64(*position) / float64(*duration)
+			state.Progress = &progress
+		}
+	}
+	return state
+}
+
+// containerState returns the watch state of a series, season or collection
+// from the movies and episodes below it
+func containerState(total, watched int, lastWatched *time.Time) *media.WatchState {
+	state := &media.WatchState{
+		Watched:      total > 0 && watched == total,
+		WatchedCount: &watched,
+		TotalCount:   &total,
+	}
+	if state.Watched {
+		state.WatchedAt = lastWatched
+	}
+	return state
+}
+
+// Annotate sets the watch state of media items for a user
+func (s *Service) Annotate(ctx context.Context, userID int64, items []*media.MediaItem) error {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	states, err := s.States(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		item.Watch = states[item.ID]
+	}
+	return nil
+}
+
+// ContinueWatching lists the movies and episodes a user has partly watched,
+// most recently played first
+func (s *Service) ContinueWatching(ctx context.Context, userID int64, limit int) ([]ContinueItem, error) {
+	if limit <= 0 {
+		limit = defaultContinueLimit
+	}
+	if limit > maxContinueLimit {
+		limit = maxContinueLimit
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT m.id, m.kind, m.title, m.year,
+		       series.id, COALESCE(series.title, ''),
+		       CASE WHEN jsonb_typeof(m.metadata->'season') = 'number' THEN (m.metadata->>'season')::int END,
+		       CASE WHEN jsonb_typeof(m.metadata->'episode') = 'number' THEN (m.metadata->>'episode')::int END,
+		       COALESCE(m.metadata->>'poster_url', series.metadata->>'poster_url', ''),
+		       p.position_seconds, p.duration_seconds, p.updated_at
+		FROM playback_progress p
+		JOIN media_items m ON m.id = p.media_item_id AND m.deleted_at IS NULL
+		LEFT JOIN media_items season ON m.kind = 'tv_episode' AND season.id = m.parent_id
+		LEFT JOIN media_items series ON series.id = season.parent_id
+		WHERE p.user_id = $1
+		  AND p.duration_seconds > 0
+		  AND p.position_seconds >= $2 * p.duration_seconds
+		  AND p.position_seconds < $3 * p.duration_seconds
+		ORDER BY p.updated_at DESC
+		LIMIT $4
+	`, userID, continueThreshold, watchedThreshold, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list continue watching: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ContinueItem, error) {
+		var item ContinueItem
+		err := row.Scan(&item.MediaItemID, &item.Kind, &item.Title, &item.Year,
+			&item.SeriesID, &item.SeriesTitle, &item.Season, &item.Episode, &item.PosterURL,
+			&item.PositionSeconds, &item.DurationSeconds, &item.UpdatedAt)
+		item.Progress = float64(item.PositionSeconds) / float64(item.DurationSeconds)
+		return item, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list continue watching: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,54 @@
+package watch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayableState(t *testing.T) {
+	now := time.Now()
+	position, duration := 600, 2400
+
+	state := playableState(nil, &position, &duration)
+	if state.Watched || state.Progress == nil || *state.Progress != 0.25 {
+		t.Errorf("playableState() = %+v, want unwatched at 25%%", state)
+	}
+
+	state = playableState(&now, nil, nil)
+	if !state.Watched || state.WatchedAt != &now || state.Progress != nil || state.PositionSeconds != nil {
+		t.Errorf("playableState() = %+v, want watched without progress", state)
+	}
+
+	// Without a duration the position is known but the progress isn't
+	state = playableState(nil, &position, nil)
+	if state.PositionSeconds == nil || *state.PositionSeconds != position || state.Progress != nil {
+		t.Errorf("playableState() = %+v, want a position without progress", state)
+	}
+}
+
+func TestContainerState(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name           string
+		total, watched int
+		want           bool
+	}{
+		{"all episodes watched", 10, 10, true},
+		{"some episodes watched", 10, 4, false},
+		{"no episodes", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := containerState(tt.total, tt.watched, &now)
+			if state.Watched != tt.want {
+				t.Errorf("Watched = %v, want %v", state.Watched, tt.want)
+			}
+			if *state.WatchedCount != tt.watched || *state.TotalCount != tt.total {
+				t.Errorf("counts = %d/%d, want %d/%d", *state.WatchedCount, *state.TotalCount, tt.watched, tt.total)
+			}
+			if (state.WatchedAt != nil) != tt.want {
+				t.Errorf("WatchedAt = %v, want set only when watched", state.WatchedAt)
+			}
+		})
+	}
+}
@@ -43,7 +43,10 @@ func (p *ExamplePlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescripto
 
 // HandleAPI handles HTTP requests for this plugin's routes
 func (p *ExamplePlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	switch req.Path {
+	// The host only forwards requests matching APIRoutes, and tells which
+	// route matched in req.Route. Placeholders like {id} in a route's path
+	// arrive in req.PathParams.
+	switch req.Route {
 	case "/api/plugins/example/hello":
 		return p.handleHello(ctx, req)
 	case "/api/plugins/example/status":
@@ -0,0 +1,16 @@
+#!/bin/bash
+set -e
+
+echo "Building MusicBrainz plugin..."
+go build -o musicbrainz-plugin .
+echo "✓ Build successful!"
+
+echo ""
+echo "Plugin ready at: $(pwd)/musicbrainz-plugin"
+echo ""
+echo "To use this plugin:"
+echo "1. Ensure ENABLE_PLUGINS=true"
+echo "2. Set PLUGINS_DIR to the plugins directory"
+echo "3. Restart the Nimbus server"
+echo ""
+echo "MusicBrainz needs no API key. Requests are limited to one per second."
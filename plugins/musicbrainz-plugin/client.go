@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// userAgent identifies Nimbus to MusicBrainz, which rejects anonymous clients
+	userAgent = "Nimbus/0.1.0 ( https://github.com/blakestevenson/nimbus )"
+
+	// requestInterval keeps to the MusicBrainz limit of one request per second
+	requestInterval = time.Second
+
+	// maxRetries is how often a request MusicBrainz turned away with 503 is retried
+	maxRetries = 3
+)
+
+// errNotFound is returned when MusicBrainz or the Cover Art Archive has no such record
+var errNotFound = errors.New("not found on MusicBrainz")
+
+// mbClient calls the MusicBrainz web service and the Cover Art Archive,
+// spacing requests to MusicBrainz a second apart
+type mbClient struct {
+	baseURL     string
+	coverArtURL string
+	http        *http.Client
+	interval    time.Duration // Minimum time between MusicBrainz requests
+
+	mu   sync.Mutex
+	next time.Time // Earliest time of the next MusicBrainz request
+}
+
+func newMBClient() *mbClient {
+	return &mbClient{
+		baseURL:     musicBrainzBaseURL,
+		coverArtURL: coverArtArchiveURL,
+		http:        &http.Client{Timeout: 30 * time.Second},
+		interval:    requestInterval,
+	}
+}
+
+// get fetches a MusicBrainz endpoint as JSON and decodes it into v
+func (c *mbClient) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("fmt", "json")
+	u := c.baseURL + path + "?" + query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+
+		status, body, err := c.fetch(ctx, u)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status == http.StatusOK:
+			if err := json.Unmarshal(body, v); err != nil {
+				return fmt.Errorf("failed to decode MusicBrainz response: %w", err)
+			}
+			return nil
+		case status == http.StatusNotFound:
+			return errNotFound
+		case status == http.StatusServiceUnavailable && attempt < maxRetries:
+			// MusicBrainz turns clients away with 503 when they go too fast
+			continue
+		default:
+			return fmt.Errorf("MusicBrainz returned HTTP %d: %s", status, truncate(string(body), 200))
+		}
+	}
+}
+
+// coverArt fetches the Cover Art Archive listing of a release
+func (c *mbClient) coverArt(ctx context.Context, releaseID string) (*coverArtListing, error) {
+	status, body, err := c.fetch(ctx, c.coverArtURL+"/release/"+url.PathEscape(releaseID))
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errNotFound
+	default:
+		return nil, fmt.Errorf("Cover Art Archive returned HTTP %d", status)
+	}
+
+	var listing coverArtListing
+	if err := json.Unmarshal(body, &listing); err != nil {
+		return nil, fmt.Errorf("failed to decode Cover Art Archive response: %w", err)
+	}
+	return &listing, nil
+}
+
+// fetch performs a GET request and returns the status and body
+func (c *mbClient) fetch(ctx context.Context, u string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// wait blocks until the next MusicBrainz request may be sent
+func (c *mbClient) wait(ctx context.Context) error {
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.interval)
+	c.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// luceneReplacer escapes the characters that have a meaning in MusicBrainz
+// search queries
+var luceneReplacer = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `&`, `\&`, `|`, `\|`, `!`, `\!`,
+	`(`, `\(`, `)`, `\)`, `{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`,
+	`^`, `\^`, `"`, `\"`, `~`, `\~`, `*`, `\*`, `?`, `\?`, `:`, `\:`, `/`, `\/`,
+)
+
+// phrase quotes a value as a search phrase for a field, e.g. artist:"Radiohead"
+func phrase(field, value string) string {
+	return field + `:"` + luceneReplacer.Replace(value) + `"`
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
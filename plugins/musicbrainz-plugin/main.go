@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/hashicorp/go-plugin"
+)
+
+const (
+	musicBrainzBaseURL = "https://musicbrainz.org/ws/2"
+	coverArtArchiveURL = "https://coverartarchive.org"
+)
+
+// MusicBrainzPlugin implements the MediaSuitePlugin interface
+type MusicBrainzPlugin struct {
+	client *mbClient
+}
+
+// NewMusicBrainzPlugin creates a new MusicBrainz plugin instance
+func NewMusicBrainzPlugin() *MusicBrainzPlugin {
+	return &MusicBrainzPlugin{
+		client: newMBClient(),
+	}
+}
+
+// Metadata returns plugin metadata
+func (p *MusicBrainzPlugin) Metadata(ctx context.Context) (*plugins.PluginMetadata, error) {
+	return &plugins.PluginMetadata{
+		ID:           "musicbrainz-plugin",
+		Name:         "MusicBrainz",
+		Version:      "0.1.0",
+		Description:  "Fetches artist, album and track metadata from MusicBrainz and cover art from the Cover Art Archive",
+		Capabilities: []string{"api"},
+	}, nil
+}
+
+// APIRoutes returns the HTTP routes this plugin provides
+func (p *MusicBrainzPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	return []plugins.RouteDescriptor{
+		{
+			Method: "GET",
+			Path:   "/api/plugins/musicbrainz/search/artist",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/musicbrainz/search/release",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/musicbrainz/artist/{id}",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/musicbrainz/release/{id}",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/musicbrainz/release/{id}/cover-art",
+			Auth:   "session",
+		},
+		{
+			Method: "POST",
+			Path:   "/api/plugins/musicbrainz/enrich",
+			Auth:   "none", // Allow internal scanner calls without auth
+		},
+	}, nil
+}
+
+// HandleAPI handles HTTP requests for this plugin's routes
+func (p *MusicBrainzPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern and take IDs from its placeholders
+	id := req.PathParams["id"]
+	switch strings.TrimPrefix(req.Route, "/api/plugins/musicbrainz") {
+	case "/search/artist":
+		return p.handleSearchArtist(ctx, req)
+	case "/search/release":
+		return p.handleSearchRelease(ctx, req)
+	case "/enrich":
+		return p.handleEnrich(ctx, req)
+	case "/artist/{id}":
+		return p.handleGetArtist(ctx, id)
+	case "/release/{id}":
+		return p.handleGetRelease(ctx, id)
+	case "/release/{id}/cover-art":
+		return p.handleGetCoverArt(ctx, id)
+	default:
+		return p.errorResponse(http.StatusNotFound, "Not found")
+	}
+}
+
+// handleSearchArtist searches MusicBrainz for artists
+func (p *MusicBrainzPlugin) handleSearchArtist(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	query := p.getQueryParam(req, "query")
+	if query == "" {
+		return p.errorResponse(http.StatusBadRequest, "query parameter is required")
+	}
+
+	results, err := p.searchArtists(ctx, query)
+	if err != nil {
+		return p.mbError(err)
+	}
+	return jsonResponse(map[string]interface{}{"results": results})
+}
+
+// handleSearchRelease searches MusicBrainz for releases of an album
+func (p *MusicBrainzPlugin) handleSearchRelease(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	album := p.getQueryParam(req, "album")
+	if album == "" {
+		album = p.getQueryParam(req, "query")
+	}
+	if album == "" {
+		return p.errorResponse(http.StatusBadRequest, "album parameter is required")
+	}
+	year, _ := strconv.Atoi(p.getQueryParam(req, "year"))
+
+	results, err := p.searchReleases(ctx, p.getQueryParam(req, "artist"), album, year)
+	if err != nil {
+		return p.mbError(err)
+	}
+	return jsonResponse(map[string]interface{}{"results": results})
+}
+
+// handleGetArtist returns an artist with its metadata
+func (p *MusicBrainzPlugin) handleGetArtist(ctx context.Context, id string) (*plugins.PluginHTTPResponse, error) {
+	a, err := p.getArtist(ctx, id)
+	if err != nil {
+		return p.mbError(err)
+	}
+	return jsonResponse(map[string]interface{}{
+		"artist":       a,
+		"metadata":     artistMetadata(a),
+		"external_ids": map[string]interface{}{"musicbrainz_artist_id": a.ID},
+	})
+}
+
+// handleGetRelease returns a release with its track listing and metadata
+func (p *MusicBrainzPlugin) handleGetRelease(ctx context.Context, id string) (*plugins.PluginHTTPResponse, error) {
+	r, err := p.getRelease(ctx, id)
+	if err != nil {
+		return p.mbError(err)
+	}
+	return jsonResponse(map[string]interface{}{
+		"release":      r,
+		"metadata":     releaseMetadata(r),
+		"external_ids": releaseExternalIDs(r),
+	})
+}
+
+// handleGetCoverArt returns the Cover Art Archive images of a release
+func (p *MusicBrainzPlugin) handleGetCoverArt(ctx context.Context, id string) (*plugins.PluginHTTPResponse, error) {
+	listing, err := p.client.coverArt(ctx, id)
+	if err != nil {
+		return p.mbError(err)
+	}
+	return jsonResponse(listing)
+}
+
+// handleEnrich looks up metadata for a media item (for scanner). It returns
+// metadata and external_ids like the TMDB and TVDB plugins' enrich endpoints.
+func (p *MusicBrainzPlugin) handleEnrich(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	var reqBody struct {
+		Title       string                 `json:"title"`
+		Year        int                    `json:"year,omitempty"`
+		Kind        string                 `json:"kind"` // "music_artist", "music_album" or "music_track"
+		Artist      string                 `json:"artist,omitempty"`
+		Album       string                 `json:"album,omitempty"`
+		Track       int                    `json:"track,omitempty"`
+		ExternalIDs map[string]interface{} `json:"external_ids,omitempty"`
+	}
+	if err := json.Unmarshal(req.Body, &reqBody); err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if reqBody.Kind == "" || (reqBody.Title == "" && len(reqBody.ExternalIDs) == 0) {
+		return p.errorResponse(http.StatusBadRequest, "kind and title or external_ids are required")
+	}
+
+	metadata, externalIDs, err := p.lookupMetadata(ctx, enrichQuery{
+		Title:       reqBody.Title,
+		Year:        reqBody.Year,
+		Kind:        reqBody.Kind,
+		Artist:      reqBody.Artist,
+		Album:       reqBody.Album,
+		Track:       reqBody.Track,
+		ExternalIDs: reqBody.ExternalIDs,
+	})
+	if errors.Is(err, errNotFound) {
+		return p.errorResponse(http.StatusNotFound, "No results found")
+	}
+	if err != nil {
+		return p.errorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	return jsonResponse(map[string]interface{}{
+		"success":      true,
+		"metadata":     metadata,
+		"external_ids": externalIDs,
+	})
+}
+
+// UIManifest returns the UI configuration for this plugin
+func (p *MusicBrainzPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error) {
+	return &plugins.UIManifest{
+		NavItems: []plugins.UINavItem{},
+		Routes:   []plugins.UIRoute{},
+	}, nil
+}
+
+// HandleEvent handles system events. Lookups are requested by the host
+// through the enrich endpoint, so no events are handled.
+func (p *MusicBrainzPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
+	return nil
+}
+
+// IsIndexer returns false as MusicBrainz is not an indexer plugin
+func (p *MusicBrainzPlugin) IsIndexer(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Search is not implemented for MusicBrainz plugin
+func (p *MusicBrainzPlugin) Search(ctx context.Context, req *plugins.IndexerSearchRequest) (*plugins.IndexerSearchResponse, error) {
+	return nil, fmt.Errorf("MusicBrainz plugin does not support search")
+}
+
+// IsDownloader returns false as MusicBrainz is not a downloader plugin
+func (p *MusicBrainzPlugin) IsDownloader(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Helper functions
+
+// mbError turns a MusicBrainz error into a response
+func (p *MusicBrainzPlugin) mbError(err error) (*plugins.PluginHTTPResponse, error) {
+	if errors.Is(err, errNotFound) {
+		return p.errorResponse(http.StatusNotFound, "Not found on MusicBrainz")
+	}
+	return p.errorResponse(http.StatusBadGateway, err.Error())
+}
+
+// jsonResponse encodes data as a 200 JSON response
+func jsonResponse(data interface{}) (*plugins.PluginHTTPResponse, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func (p *MusicBrainzPlugin) getQueryParam(req *plugins.PluginHTTPRequest, key string) string {
+	if values, ok := req.Query[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (p *MusicBrainzPlugin) errorResponse(statusCode int, message string) (*plugins.PluginHTTPResponse, error) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &plugins.PluginHTTPResponse{
+		StatusCode: statusCode,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func main() {
+	musicBrainzPlugin := NewMusicBrainzPlugin()
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: plugins.Handshake,
+		Plugins: map[string]plugin.Plugin{
+			"media-suite": &plugins.MediaSuitePluginGRPC{
+				Impl: musicBrainzPlugin,
+			},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
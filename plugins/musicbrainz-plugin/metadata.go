@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// searchLimit is how many search results are considered
+const searchLimit = 10
+
+// minArtistScore is the MusicBrainz search score an artist needs to be taken
+// without an exact name match
+const minArtistScore = 95
+
+// artistCredit is an artist a release or recording is credited to
+type artistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+// artist is a MusicBrainz artist
+type artist struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	SortName       string `json:"sort-name"`
+	Type           string `json:"type"`
+	Country        string `json:"country"`
+	Disambiguation string `json:"disambiguation"`
+	Score          int    `json:"score"`
+	LifeSpan       struct {
+		Begin string `json:"begin"`
+		End   string `json:"end"`
+	} `json:"life-span"`
+	Genres        []genre        `json:"genres"`
+	ReleaseGroups []releaseGroup `json:"release-groups"`
+}
+
+// genre is a MusicBrainz genre tag
+type genre struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// releaseGroup groups the editions of an album
+type releaseGroup struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	PrimaryType      string `json:"primary-type"`
+	FirstReleaseDate string `json:"first-release-date"`
+}
+
+// track is a track on a medium of a release
+type track struct {
+	ID        string `json:"id"`
+	Number    string `json:"number"`
+	Position  int    `json:"position"`
+	Title     string `json:"title"`
+	Length    int    `json:"length"` // Milliseconds
+	Recording struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"recording"`
+}
+
+// medium is a disc of a release
+type medium struct {
+	Position   int     `json:"position"`
+	Format     string  `json:"format"`
+	TrackCount int     `json:"track-count"`
+	Tracks     []track `json:"tracks"`
+}
+
+// release is a MusicBrainz release, one edition of an album
+type release struct {
+	ID             string         `json:"id"`
+	Title          string         `json:"title"`
+	Status         string         `json:"status"`
+	Date           string         `json:"date"`
+	Country        string         `json:"country"`
+	Barcode        string         `json:"barcode"`
+	Score          int            `json:"score"`
+	TrackCount     int            `json:"track-count"`
+	ArtistCredit   []artistCredit `json:"artist-credit"`
+	ReleaseGroup   releaseGroup   `json:"release-group"`
+	Media          []medium       `json:"media"`
+	Genres         []genre        `json:"genres"`
+	CoverArtStatus struct {
+		Front bool `json:"front"`
+	} `json:"cover-art-archive"`
+	LabelInfo []struct {
+		CatalogNumber string `json:"catalog-number"`
+		Label         *struct {
+			Name string `json:"name"`
+		} `json:"label"`
+	} `json:"label-info"`
+}
+
+// recording is a MusicBrainz recording, the track as heard
+type recording struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Length       int            `json:"length"`
+	Score        int            `json:"score"`
+	ArtistCredit []artistCredit `json:"artist-credit"`
+	Releases     []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"releases"`
+}
+
+// coverArtListing is the Cover Art Archive listing of a release
+type coverArtListing struct {
+	Images []struct {
+		Image      string            `json:"image"`
+		Front      bool              `json:"front"`
+		Back       bool              `json:"back"`
+		Types      []string          `json:"types"`
+		Thumbnails map[string]string `json:"thumbnails"`
+	} `json:"images"`
+}
+
+// enrichQuery describes the media item to look up
+type enrichQuery struct {
+	Title       string
+	Year        int
+	Kind        string // "music_artist", "music_album" or "music_track"
+	Artist      string
+	Album       string
+	Track       int
+	ExternalIDs map[string]interface{}
+}
+
+// lookupMetadata finds the artist, album or track a query describes and
+// returns its metadata and external IDs. A MusicBrainz ID already stored in
+// the external IDs is used instead of searching.
+func (p *MusicBrainzPlugin) lookupMetadata(ctx context.Context, query enrichQuery) (map[string]interface{}, map[string]interface{}, error) {
+	switch query.Kind {
+	case "music_artist":
+		id := storedID(query.ExternalIDs, "musicbrainz_artist_id")
+		if id == "" {
+			name := query.Artist
+			if name == "" {
+				name = query.Title
+			}
+			found, err := p.searchArtists(ctx, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			best := bestArtist(found, name)
+			if best == nil {
+				return nil, nil, errNotFound
+			}
+			id = best.ID
+		}
+		a, err := p.getArtist(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return artistMetadata(a), map[string]interface{}{"musicbrainz_artist_id": a.ID}, nil
+
+	case "music_album":
+		id := storedID(query.ExternalIDs, "musicbrainz_release_id")
+		if id == "" {
+			album := query.Album
+			if album == "" {
+				album = query.Title
+			}
+			found, err := p.searchReleases(ctx, query.Artist, album, query.Year)
+			if err != nil {
+				return nil, nil, err
+			}
+			best := bestRelease(found, query.Artist, album, query.Year)
+			if best == nil {
+				return nil, nil, errNotFound
+			}
+			id = best.ID
+		}
+		r, err := p.getRelease(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return releaseMetadata(r), releaseExternalIDs(r), nil
+
+	case "music_track":
+		// A track on a known release is read from its track listing
+		if id := storedID(query.ExternalIDs, "musicbrainz_release_id"); id != "" && query.Track > 0 {
+			r, err := p.getRelease(ctx, id)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, m := range r.Media {
+				for _, t := range m.Tracks {
+					if t.Position == query.Track && (len(r.Media) == 1 || strings.EqualFold(t.Title, query.Title)) {
+						return trackMetadata(r, m, t), map[string]interface{}{"musicbrainz_recording_id": t.Recording.ID}, nil
+					}
+				}
+			}
+			return nil, nil, errNotFound
+		}
+
+		found, err := p.searchRecordings(ctx, query.Artist, query.Title, query.Album)
+		if err != nil {
+			return nil, nil, err
+		}
+		best := bestRecording(found, query.Artist, query.Title)
+		if best == nil {
+			return nil, nil, errNotFound
+		}
+		return recordingMetadata(best, query.Track), map[string]interface{}{"musicbrainz_recording_id": best.ID}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported kind: %s", query.Kind)
+	}
+}
+
+// storedID returns a MusicBrainz ID stored in external IDs
+func storedID(externalIDs map[string]interface{}, key string) string {
+	if id, ok := externalIDs[key].(string); ok {
+		return id
+	}
+	return ""
+}
+
+// searchArtists searches MusicBrainz for artists by name
+func (p *MusicBrainzPlugin) searchArtists(ctx context.Context, name string) ([]artist, error) {
+	var resp struct {
+		Artists []artist `json:"artists"`
+	}
+	err := p.client.get(ctx, "/artist", url.Values{
+		"query": {phrase("artist", name)},
+		"limit": {strconv.Itoa(searchLimit)},
+	}, &resp)
+	return resp.Artists, err
+}
+
+// searchReleases searches MusicBrainz for releases of an album
+func (p *MusicBrainzPlugin) searchReleases(ctx context.Context, artistName, album string, year int) ([]release, error) {
+	terms := []string{phrase("release", album)}
+	if artistName != "" {
+		terms = append(terms, phrase("artist", artistName))
+	}
+	if year > 0 {
+		// A reissue may be dated later, so the year only ranks results
+		terms = append(terms, fmt.Sprintf("date:%d^2", year))
+	}
+
+	var resp struct {
+		Releases []release `json:"releases"`
+	}
+	err := p.client.get(ctx, "/release", url.Values{
+		"query": {strings.Join(terms, " ")},
+		"limit": {strconv.Itoa(searchLimit)},
+	}, &resp)
+	return resp.Releases, err
+}
+
+// searchRecordings searches MusicBrainz for recordings of a track
+func (p *MusicBrainzPlugin) searchRecordings(ctx context.Context, artistName, title, album string) ([]recording, error) {
+	terms := []string{phrase("recording", title)}
+	if artistName != "" {
+		terms = append(terms, "AND "+phrase("artist", artistName))
+	}
+	if album != "" {
+		terms = append(terms, phrase("release", album))
+	}
+
+	var resp struct {
+		Recordings []recording `json:"recordings"`
+	}
+	err := p.client.get(ctx, "/recording", url.Values{
+		"query": {strings.Join(terms, " ")},
+		"limit": {strconv.Itoa(searchLimit)},
+	}, &resp)
+	return resp.Recordings, err
+}
+
+// getArtist fetches an artist with its albums
+func (p *MusicBrainzPlugin) getArtist(ctx context.Context, id string) (*artist, error) {
+	var a artist
+	err := p.client.get(ctx, "/artist/"+url.PathEscape(id), url.Values{
+		"inc": {"release-groups genres"},
+	}, &a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// getRelease fetches a release with its track listing
+func (p *MusicBrainzPlugin) getRelease(ctx context.Context, id string) (*release, error) {
+	var r release
+	err := p.client.get(ctx, "/release/"+url.PathEscape(id), url.Values{
+		"inc": {"recordings artist-credits release-groups labels genres"},
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// bestArtist picks the artist whose name matches, or the top result when
+// MusicBrainz is confident enough
+func bestArtist(results []artist, name string) *artist {
+	want := normalizeName(name)
+	for i := range results {
+		if normalizeName(results[i].Name) == want {
+			return &results[i]
+		}
+	}
+	if len(results) > 0 && results[0].Score >= minArtistScore {
+		return &results[0]
+	}
+	return nil
+}
+
+// bestRelease picks the release of an album to take metadata from: one whose
+// title and artist match, preferring the year asked for, then official
+// releases, then the original edition. Without a title match there is no
+// result, since a wrong album is worse than none.
+func bestRelease(results []release, artistName, album string, year int) *release {
+	wantTitle := normalizeName(album)
+	wantArtist := normalizeName(artistName)
+
+	var best *release
+	bestRank := -1
+	for i := range results {
+		r := &results[i]
+		if normalizeName(r.Title) != wantTitle {
+			continue
+		}
+		if wantArtist != "" && normalizeName(creditName(r.ArtistCredit)) != wantArtist {
+			continue
+		}
+
+		rank := r.Score
+		if year > 0 && releaseYear(r.Date) == year {
+			rank += 100
+		}
+		if strings.EqualFold(r.Status, "Official") {
+			rank += 50
+		}
+		if r.Date != "" && r.Date == r.ReleaseGroup.FirstReleaseDate {
+			rank += 25
+		}
+		if rank > bestRank {
+			best, bestRank = r, rank
+		}
+	}
+	return best
+}
+
+// bestRecording picks the recording whose title and artist match
+func bestRecording(results []recording, artistName, title string) *recording {
+	wantTitle := normalizeName(title)
+	wantArtist := normalizeName(artistName)
+	for i := range results {
+		r := &results[i]
+		if normalizeName(r.Title) != wantTitle {
+			continue
+		}
+		if wantArtist != "" && normalizeName(creditName(r.ArtistCredit)) != wantArtist {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// artistMetadata extracts the metadata of an artist
+func artistMetadata(a *artist) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"musicbrainz_id":    a.ID,
+		"type":              "music_artist",
+		"metadata_provider": "musicbrainz",
+		"name":              a.Name,
+	}
+	setString(metadata, "sort_name", a.SortName)
+	setString(metadata, "artist_type", a.Type)
+	setString(metadata, "country", a.Country)
+	setString(metadata, "disambiguation", a.Disambiguation)
+	setString(metadata, "begin_date", a.LifeSpan.Begin)
+	setString(metadata, "end_date", a.LifeSpan.End)
+	if genres := genreNames(a.Genres); len(genres) > 0 {
+		metadata["genres"] = genres
+	}
+
+	albums := make([]map[string]interface{}, 0, len(a.ReleaseGroups))
+	for _, rg := range a.ReleaseGroups {
+		if rg.PrimaryType != "Album" && rg.PrimaryType != "EP" {
+			continue
+		}
+		albums = append(albums, map[string]interface{}{
+			"release_group_id": rg.ID,
+			"title":            rg.Title,
+			"type":             rg.PrimaryType,
+			"first_release":    rg.FirstReleaseDate,
+		})
+	}
+	if len(albums) > 0 {
+		metadata["albums"] = albums
+	}
+	return metadata
+}
+
+// releaseMetadata extracts the metadata of an album release, with its track
+// listing and cover art
+func releaseMetadata(r *release) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"musicbrainz_id":    r.ID,
+		"type":              "music_album",
+		"metadata_provider": "musicbrainz",
+		"album":             r.Title,
+		"artist":            creditName(r.ArtistCredit),
+		"disc_count":        len(r.Media),
+	}
+	setString(metadata, "release_date", r.Date)
+	setString(metadata, "first_release_date", r.ReleaseGroup.FirstReleaseDate)
+	setString(metadata, "album_type", r.ReleaseGroup.PrimaryType)
+	setString(metadata, "status", r.Status)
+	setString(metadata, "country", r.Country)
+	setString(metadata, "barcode", r.Barcode)
+	if year := releaseYear(r.Date); year > 0 {
+		metadata["release_year"] = year
+	}
+	for _, info := range r.LabelInfo {
+		if info.Label != nil && info.Label.Name != "" {
+			metadata["label"] = info.Label.Name
+			setString(metadata, "catalog_number", info.CatalogNumber)
+			break
+		}
+	}
+	if genres := genreNames(r.Genres); len(genres) > 0 {
+		metadata["genres"] = genres
+	}
+	if r.CoverArtStatus.Front {
+		metadata["poster_url"] = coverArtArchiveURL + "/release/" + r.ID + "/front"
+	}
+
+	tracks := make([]map[string]interface{}, 0, r.TrackCount)
+	for _, m := range r.Media {
+		for _, t := range m.Tracks {
+			tracks = append(tracks, map[string]interface{}{
+				"disc":         m.Position,
+				"track_number": t.Position,
+				"number":       t.Number,
+				"title":        t.Title,
+				"length_ms":    t.Length,
+				"recording_id": t.Recording.ID,
+			})
+		}
+	}
+	metadata["track_count"] = len(tracks)
+	metadata["tracks"] = tracks
+	return metadata
+}
+
+// releaseExternalIDs returns the MusicBrainz IDs of a release
+func releaseExternalIDs(r *release) map[string]interface{} {
+	externalIDs := map[string]interface{}{
+		"musicbrainz_release_id": r.ID,
+	}
+	if r.ReleaseGroup.ID != "" {
+		externalIDs["musicbrainz_release_group_id"] = r.ReleaseGroup.ID
+	}
+	if len(r.ArtistCredit) > 0 && r.ArtistCredit[0].Artist.ID != "" {
+		externalIDs["musicbrainz_artist_id"] = r.ArtistCredit[0].Artist.ID
+	}
+	return externalIDs
+}
+
+// trackMetadata extracts the metadata of a track from its release
+func trackMetadata(r *release, m medium, t track) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"musicbrainz_id":    t.Recording.ID,
+		"type":              "music_track",
+		"metadata_provider": "musicbrainz",
+		"title":             t.Title,
+		"track_number":      t.Position,
+		"disc":              m.Position,
+		"length_ms":         t.Length,
+		"album":             r.Title,
+		"artist":            creditName(r.ArtistCredit),
+	}
+	if r.CoverArtStatus.Front {
+		metadata["poster_url"] = coverArtArchiveURL + "/release/" + r.ID + "/front"
+	}
+	return metadata
+}
+
+// recordingMetadata extracts the metadata of a recording found by search
+func recordingMetadata(r *recording, trackNumber int) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"musicbrainz_id":    r.ID,
+		"type":              "music_track",
+		"metadata_provider": "musicbrainz",
+		"title":             r.Title,
+		"length_ms":         r.Length,
+		"artist":            creditName(r.ArtistCredit),
+	}
+	if trackNumber > 0 {
+		metadata["track_number"] = trackNumber
+	}
+	return metadata
+}
+
+// creditName joins an artist credit as it is printed, e.g. "Simon & Garfunkel"
+func creditName(credits []artistCredit) string {
+	var b strings.Builder
+	for _, c := range credits {
+		b.WriteString(c.Name)
+		b.WriteString(c.JoinPhrase)
+	}
+	return b.String()
+}
+
+// genreNames returns genre names, most tagged first as MusicBrainz lists them
+func genreNames(genres []genre) []string {
+	names := make([]string, 0, len(genres))
+	for _, g := range genres {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// releaseYear returns the year of a MusicBrainz date (YYYY, YYYY-MM or YYYY-MM-DD)
+func releaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(date[:4])
+	return year
+}
+
+// nonAlphanumeric matches what names are compared without
+var nonAlphanumeric = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// normalizeName lowercases a name and drops punctuation, a leading "the" and
+// "&" versus "and" differences, so "The Beatles" matches "Beatles"
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "&", " and ")
+	name = strings.TrimSpace(nonAlphanumeric.ReplaceAllString(name, " "))
+	name = strings.TrimPrefix(name, "the ")
+	return strings.ReplaceAll(name, " ", "")
+}
+
+// setString sets a metadata key when the value isn't empty
+func setString(metadata map[string]interface{}, key, value string) {
+	if value != "" {
+		metadata[key] = value
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLookupAlbum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "Nimbus/") {
+			t.Errorf("request without the Nimbus user agent: %q", r.Header.Get("User-Agent"))
+		}
+		if r.URL.Query().Get("fmt") != "json" {
+			t.Errorf("request without fmt=json: %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/release":
+			fmt.Fprint(w, `{"releases":[
+				{"id":"live","score":100,"title":"OK Computer (Live)","date":"1997","status":"Official","artist-credit":[{"name":"Radiohead"}]},
+				{"id":"reissue","score":100,"title":"OK Computer","date":"2009-03-24","status":"Official","artist-credit":[{"name":"Radiohead"}]},
+				{"id":"original","score":98,"title":"OK Computer","date":"1997-05-21","status":"Official","artist-credit":[{"name":"Radiohead"}]}]}`)
+		case "/release/original":
+			fmt.Fprint(w, `{"id":"original","title":"OK Computer","date":"1997-05-21","status":"Official",
+				"artist-credit":[{"name":"Radiohead","artist":{"id":"a74b1b7f"}}],
+				"release-group":{"id":"b1392450","primary-type":"Album","first-release-date":"1997-05-21"},
+				"cover-art-archive":{"front":true},
+				"label-info":[{"catalog-number":"NODATA 02","label":{"name":"Parlophone"}}],
+				"media":[{"position":1,"tracks":[
+					{"number":"1","position":1,"title":"Airbag","length":284000,"recording":{"id":"rec1"}},
+					{"number":"2","position":2,"title":"Paranoid Android","length":383000,"recording":{"id":"rec2"}}]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewMusicBrainzPlugin()
+	p.client.baseURL = server.URL
+	p.client.interval = 0
+
+	metadata, externalIDs, err := p.lookupMetadata(context.Background(), enrichQuery{
+		Title:  "OK Computer",
+		Year:   1997,
+		Kind:   "music_album",
+		Artist: "Radiohead",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["musicbrainz_id"] != "original" || metadata["label"] != "Parlophone" || metadata["track_count"] != 2 {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if metadata["poster_url"] != coverArtArchiveURL+"/release/original/front" {
+		t.Errorf("poster_url = %v", metadata["poster_url"])
+	}
+	if externalIDs["musicbrainz_release_group_id"] != "b1392450" || externalIDs["musicbrainz_artist_id"] != "a74b1b7f" {
+		t.Errorf("external IDs = %v", externalIDs)
+	}
+
+	// A track of a matched album is read from its track listing
+	metadata, externalIDs, err = p.lookupMetadata(context.Background(), enrichQuery{
+		Title:       "Paranoid Android",
+		Kind:        "music_track",
+		Track:       2,
+		ExternalIDs: map[string]interface{}{"musicbrainz_release_id": "original"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["length_ms"] != 383000 || externalIDs["musicbrainz_recording_id"] != "rec2" {
+		t.Errorf("track metadata = %v, external IDs = %v", metadata, externalIDs)
+	}
+}
+
+func TestBestRelease(t *testing.T) {
+	results := []release{
+		{ID: "1", Title: "The Wall", Score: 100, Date: "1979-11-30", Status: "Official", ArtistCredit: []artistCredit{{Name: "Pink Floyd"}}},
+		{ID: "2", Title: "The Wall", Score: 100, Date: "1994", Status: "Official", ArtistCredit: []artistCredit{{Name: "Pink Floyd"}}},
+		{ID: "3", Title: "The Wall", Score: 100, Date: "1979", ArtistCredit: []artistCredit{{Name: "Other Band"}}},
+	}
+	tests := []struct {
+		artist string
+		album  string
+		year   int
+		want   string
+	}{
+		{"Pink Floyd", "The Wall", 1994, "2"},
+		{"Pink Floyd", "Wall", 0, "1"},
+		{"Other Band", "The Wall", 0, "3"},
+		{"Pink Floyd", "Animals", 0, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if r := bestRelease(results, tt.artist, tt.album, tt.year); r != nil {
+			got = r.ID
+		}
+		if got != tt.want {
+			t.Errorf("bestRelease(%q, %q, %d) = %q, want %q", tt.artist, tt.album, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestPhrase(t *testing.T) {
+	if got, want := phrase("release", `AC/DC: "Live"`), `release:"AC\/DC\: \"Live\""`; got != want {
+		t.Errorf("phrase() = %s, want %s", got, want)
+	}
+}
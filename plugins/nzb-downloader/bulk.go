@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// queueError is a queue operation that can't be applied to a download
+type queueError struct {
+	status  int
+	message string
+}
+
+func (e *queueError) Error() string {
+	return e.message
+}
+
+var errDownloadNotFound = &queueError{http.StatusNotFound, "Download not found"}
+
+// queueErrorResponse turns a failed queue operation into an HTTP response
+func queueErrorResponse(err error) (*plugins.PluginHTTPResponse, error) {
+	status := http.StatusBadRequest
+	if qe, ok := err.(*queueError); ok {
+		status = qe.status
+	}
+	return jsonResponse(status, map[string]string{"error": err.Error()})
+}
+
+// The queue operations below are shared by the single and bulk endpoints.
+// Callers must hold the DownloadManager lock.
+
+// pauseLocked stops a queued or downloading download
+func (m *DownloadManager) pauseLocked(id string) error {
+	dl, exists := m.downloads[id]
+	if !exists {
+		return errDownloadNotFound
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	// Can only pause downloading items
+	if dl.Status != "downloading" && dl.Status != "queued" {
+		return &queueError{http.StatusBadRequest, "Download is not active"}
+	}
+
+	// Cancel the download
+	if dl.cancelDownload != nil {
+		dl.AddLog("Download paused by user")
+		dl.cancelDownload()
+	}
+
+	dl.Status = "paused"
+	dl.StartedAt = nil
+	dl.clearSpeed()
+	delete(m.active, id)
+	return nil
+}
+
+// resumeLocked queues a paused download again. Resuming a queued download is
+// a no-op and reports false.
+func (m *DownloadManager) resumeLocked(id string) (bool, error) {
+	dl, exists := m.downloads[id]
+	if !exists {
+		return false, errDownloadNotFound
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	// Can only resume paused or queued items (idempotent - allow resuming already queued downloads)
+	if dl.Status != "paused" && dl.Status != "queued" {
+		return false, &queueError{http.StatusBadRequest, fmt.Sprintf("Download cannot be resumed (status: %s)", dl.Status)}
+	}
+	if dl.Status == "queued" {
+		dl.AddLog("Download already queued")
+		return false, nil
+	}
+
+	// Reset status to queued so it gets picked up by the queue processor
+	dl.Status = "queued"
+	dl.Error = ""
+	dl.AddLog("Download resumed by user")
+	return true, nil
+}
+
+// retryLocked queues a failed or cancelled download from the start
+func (m *DownloadManager) retryLocked(id string) error {
+	dl, exists := m.downloads[id]
+	if !exists {
+		return errDownloadNotFound
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	// Can only retry failed or cancelled items
+	if dl.Status != "failed" && dl.Status != "cancelled" {
+		return &queueError{http.StatusBadRequest, "Download is not failed or cancelled"}
+	}
+
+	dl.Status = "queued"
+	dl.Progress = 0
+	dl.DownloadedBytes = 0
+	dl.Error = ""
+	dl.StartedAt = nil
+	dl.CompletedAt = nil
+	dl.clearPhasesLocked()
+	dl.AddLog("Download retry requested by user")
+	return nil
+}
+
+// removeLocked cancels a download, including its post-processing, and removes
+// it from the queue
+func (m *DownloadManager) removeLocked(id string) error {
+	dl, exists := m.downloads[id]
+	if !exists {
+		return errDownloadNotFound
+	}
+
+	if dl.cancelDownload != nil {
+		dl.cancelDownload()
+	}
+
+	delete(m.downloads, id)
+	delete(m.active, id)
+
+	newQueue := make([]string, 0, len(m.queue))
+	for _, queueID := range m.queue {
+		if queueID != id {
+			newQueue = append(newQueue, queueID)
+		}
+	}
+	m.queue = newQueue
+	return nil
+}
+
+// bulkResult is the outcome of a bulk operation on one download
+type bulkResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// applyBulk runs a queue operation on each download under a single lock, then
+// persists the queue once
+func (p *NZBDownloaderPlugin) applyBulk(ids []string, op func(id string) error) []bulkResult {
+	results := make([]bulkResult, 0, len(ids))
+
+	p.downloadManager.mu.Lock()
+	for _, id := range ids {
+		result := bulkResult{ID: id, Success: true}
+		if err := op(id); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	p.downloadManager.mu.Unlock()
+
+	go p.persistDownloadState()
+	return results
+}
+
+// idsWithStatus returns the queued downloads in one of the given states, in
+// queue order
+func (p *NZBDownloaderPlugin) idsWithStatus(statuses ...string) []string {
+	p.downloadManager.mu.RLock()
+	defer p.downloadManager.mu.RUnlock()
+
+	var ids []string
+	for _, id := range p.downloadManager.queue {
+		dl, exists := p.downloadManager.downloads[id]
+		if !exists {
+			continue
+		}
+		for _, status := range statuses {
+			if dl.currentStatus() == status {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+func bulkResponse(results []bulkResult) (*plugins.PluginHTTPResponse, error) {
+	return jsonResponse(http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// handlePauseAll pauses every queued and downloading download
+func (p *NZBDownloaderPlugin) handlePauseAll(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	ids := p.idsWithStatus("queued", "downloading")
+	return bulkResponse(p.applyBulk(ids, p.downloadManager.pauseLocked))
+}
+
+// handleResumeAll queues every paused download again
+func (p *NZBDownloaderPlugin) handleResumeAll(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	ids := p.idsWithStatus("paused")
+	return bulkResponse(p.applyBulk(ids, func(id string) error {
+		_, err := p.downloadManager.resumeLocked(id)
+		return err
+	}))
+}
+
+// handleBulkAction applies pause, resume, delete or retry to selected downloads
+func (p *NZBDownloaderPlugin) handleBulkAction(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	var input struct {
+		Action      string   `json:"action"`
+		DownloadIDs []string `json:"download_ids"`
+	}
+	if err := json.Unmarshal(req.Body, &input); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	}
+	if len(input.DownloadIDs) == 0 {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "No download IDs provided"})
+	}
+
+	var op func(id string) error
+	switch input.Action {
+	case "pause":
+		op = p.downloadManager.pauseLocked
+	case "resume":
+		op = func(id string) error {
+			_, err := p.downloadManager.resumeLocked(id)
+			return err
+		}
+	case "retry":
+		op = p.downloadManager.retryLocked
+	case "delete":
+		op = p.downloadManager.removeLocked
+	default:
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid action"})
+	}
+
+	return bulkResponse(p.applyBulk(input.DownloadIDs, op))
+}
+
+// handlePurgeDownloads removes every download in a finished state, e.g.
+// DELETE /downloads?status=completed
+func (p *NZBDownloaderPlugin) handlePurgeDownloads(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	var status string
+	if values := req.Query["status"]; len(values) > 0 {
+		status = values[0]
+	}
+
+	switch status {
+	case "completed", "failed", "cancelled":
+	case "":
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "status is required"})
+	default:
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "status must be completed, failed or cancelled"})
+	}
+
+	// The status is checked again under the lock, in case a download was
+	// retried in the meantime
+	ids := p.idsWithStatus(status)
+	return bulkResponse(p.applyBulk(ids, func(id string) error {
+		if dl, exists := p.downloadManager.downloads[id]; exists && dl.currentStatus() != status {
+			return &queueError{http.StatusConflict, fmt.Sprintf("Download is no longer %s", status)}
+		}
+		return p.downloadManager.removeLocked(id)
+	}))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// configChanged applies a config change the host told the plugin about.
+// Settings kept in memory are read again, and queued downloads take a new
+// snapshot of the servers when they or their passwords change, so they start
+// with the servers configured now rather than those configured when added.
+func (p *NZBDownloaderPlugin) configChanged(ctx context.Context, sdk plugins.SDKInterface, key string) error {
+	if sdk == nil {
+		var err error
+		if sdk, err = p.getSDK(); err != nil {
+			return err
+		}
+	}
+
+	switch key {
+	case configServers, configPasswords:
+		servers, err := p.getServers(ctx, sdk)
+		if err != nil {
+			return err
+		}
+		var enabled []NNTPServer
+		for _, srv := range servers {
+			if srv.Enabled {
+				enabled = append(enabled, srv)
+			}
+		}
+		if n := p.downloadManager.resnapshotQueued(enabled); n > 0 {
+			fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Servers changed, updated %d queued downloads\n", n)
+			p.persistDownloadState()
+		}
+	case configPreempt, configSpaceMultiplier, configSpaceMarginMB,
+		configLogRetention, configLogVerbosity, configLogToFile:
+		p.loadSettings(ctx, sdk)
+	}
+	return nil
+}
+
+// resnapshotQueued replaces the server snapshot of the downloads that are
+// queued and not yet started, returning how many were changed. Running and
+// paused downloads keep theirs.
+func (m *DownloadManager) resnapshotQueued(servers []NNTPServer) int {
+	ids := serverIDs(servers)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	updated := 0
+	for id, dl := range m.downloads {
+		if m.active[id] {
+			continue
+		}
+		dl.update(func() {
+			if dl.Status != "queued" {
+				return
+			}
+			dl.Servers = append([]NNTPServer(nil), servers...)
+			dl.ServerIDs = ids
+			updated++
+		})
+	}
+	return updated
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// configSDK is an SDK with only an in-memory config store
+type configSDK struct {
+	plugins.SDKInterface
+	config  map[string]interface{}
+	secrets map[string]interface{}
+}
+
+func (s *configSDK) ConfigGet(ctx context.Context, key string) (interface{}, error) {
+	return s.config[key], nil
+}
+
+func (s *configSDK) ConfigGetSecret(ctx context.Context, key string) (interface{}, error) {
+	return s.secrets[key], nil
+}
+
+// snapshotServers returns the server snapshot of a download
+func snapshotServers(dl *Download) (servers []NNTPServer, ids []string) {
+	dl.update(func() {
+		servers, ids = dl.Servers, dl.ServerIDs
+	})
+	return servers, ids
+}
+
+func TestConfigChangedResnapshotsQueuedDownloads(t *testing.T) {
+	p, running := newDownloadingPlugin(t, 1<<20)
+	oldServer := NNTPServer{ID: "a", Host: "news.a.example", Enabled: true}
+	running.Servers = []NNTPServer{oldServer}
+	running.ServerIDs = []string{"a"}
+
+	queued := &Download{ID: "dl_2", Status: "queued", Servers: []NNTPServer{oldServer}, ServerIDs: []string{"a"}}
+	paused := &Download{ID: "dl_3", Status: "paused", Servers: []NNTPServer{oldServer}, ServerIDs: []string{"a"}}
+	for _, dl := range []*Download{queued, paused} {
+		p.downloadManager.downloads[dl.ID] = dl
+		p.downloadManager.queue = append(p.downloadManager.queue, dl.ID)
+	}
+
+	sdk := &configSDK{
+		config: map[string]interface{}{configServers: []NNTPServer{
+			oldServer,
+			{ID: "b", Host: "news.b.example", Enabled: true},
+			{ID: "c", Host: "news.c.example", Enabled: false},
+		}},
+		secrets: map[string]interface{}{configPasswords: map[string]string{"b": "secret"}},
+	}
+	evt := plugins.Event{
+		Type: plugins.EventConfigChanged,
+		Data: map[string]interface{}{"key": configServers},
+		SDK:  sdk,
+	}
+	if err := p.HandleEvent(context.Background(), evt); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+
+	servers, ids := snapshotServers(queued)
+	if !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("queued server IDs = %v, want [a b]", ids)
+	}
+	if len(servers) != 2 || servers[1].Password != "secret" {
+		t.Errorf("queued servers = %+v, want b with its password", servers)
+	}
+	for _, dl := range []*Download{running, paused} {
+		if _, ids := snapshotServers(dl); !reflect.DeepEqual(ids, []string{"a"}) {
+			t.Errorf("%s server IDs = %v, want unchanged", dl.ID, ids)
+		}
+	}
+}
+
+func TestConfigChangedReloadsSettings(t *testing.T) {
+	p := &NZBDownloaderPlugin{downloadManager: NewDownloadManager(1), persist: newPersister()}
+	sdk := &configSDK{config: map[string]interface{}{configPreempt: true}}
+
+	evt := plugins.Event{
+		Type: plugins.EventConfigChanged,
+		Data: map[string]interface{}{"key": configPreempt, "value": true},
+		SDK:  sdk,
+	}
+	if err := p.HandleEvent(context.Background(), evt); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if !p.preemptOnPriority.Load() {
+		t.Error("preempt setting wasn't reloaded")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func TestDeleteDownloadWithFiles(t *testing.T) {
+	root := t.TempDir()
+	p := &NZBDownloaderPlugin{downloadManager: NewDownloadManager(1), persist: newPersister()}
+
+	// An active download is cancelled and its directory only removed once it stopped
+	dir := filepath.Join(root, "dl_active")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	dl := &Download{ID: "dl_active", Status: "downloading", DownloadDir: dir, cancelDownload: cancel}
+	p.downloadManager.downloads[dl.ID] = dl
+	p.downloadManager.active[dl.ID] = true
+
+	stopped := false
+	dl.workers.Add(1)
+	go func() {
+		defer dl.workers.Done()
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		stopped = true
+	}()
+
+	req := &plugins.PluginHTTPRequest{Query: map[string][]string{"delete_files": {"true"}}}
+	resp, err := p.handleDeleteDownload(context.Background(), req, dl.ID)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete = %v, %v", resp, err)
+	}
+	if !stopped {
+		t.Error("files were removed before the download stopped")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("download directory still exists: %v", err)
+	}
+	if _, exists := p.downloadManager.downloads[dl.ID]; exists {
+		t.Error("download is still queued")
+	}
+
+	// Without delete_files the directory is kept
+	kept := filepath.Join(root, "dl_kept")
+	if err := os.MkdirAll(kept, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p.downloadManager.downloads["dl_kept"] = &Download{ID: "dl_kept", Status: "completed", DownloadDir: kept}
+	if resp, _ := p.handleDeleteDownload(context.Background(), &plugins.PluginHTTPRequest{}, "dl_kept"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status = %d", resp.StatusCode)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("download directory was removed: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// renameLogName is the file in the download directory recording what
+	// deobfuscation renamed, for troubleshooting
+	renameLogName = "deobfuscation.json"
+
+	// par2HashSize is how much of the start of a file par2 hashes to
+	// identify it
+	par2HashSize = 16 * 1024
+
+	// par2MaxPacket bounds the packets read from a par2 file, so a damaged
+	// length can't make the reader allocate gigabytes
+	par2MaxPacket = 64 << 20
+)
+
+var (
+	par2Magic        = []byte("PAR2\x00PKT")
+	par2FileDescType = []byte("PAR 2.0\x00FileDesc")
+
+	// obfuscatedHex and obfuscatedWord match names made of one long run of
+	// random letters and digits, like the hex names obfuscated releases use
+	obfuscatedHex  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	obfuscatedWord = regexp.MustCompile(`^[A-Za-z0-9]{24,}$`)
+
+	// subjectNoise is what a subject line carries besides the release name
+	subjectNoise = regexp.MustCompile(`(?i)"[^"]*"|\byenc\b|[\[(]\s*\d+\s*(/|of)\s*\d+\s*[\])]|\d+\s*/\s*\d+`)
+)
+
+// par2FileDesc is the description par2 keeps of a file it protects
+type par2FileDesc struct {
+	Name    string
+	Hash16k [16]byte
+	Length  int64
+}
+
+// renameRecord is one file deobfuscation renamed
+type renameRecord struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Source string `json:"source"` // par2, nzb_subject or release_name
+}
+
+// renameRun is the renames of one post-processing run
+type renameRun struct {
+	At      time.Time      `json:"at"`
+	Renames []renameRecord `json:"renames"`
+}
+
+// renameLog collects the renames of a post-processing run
+type renameLog struct {
+	Renames []renameRecord
+}
+
+// save appends the renames to the mapping file in the download directory,
+// keeping those of earlier runs when a download is reprocessed
+func (l *renameLog) save(downloadDir string) {
+	if len(l.Renames) == 0 {
+		return
+	}
+
+	path := filepath.Join(downloadDir, renameLogName)
+	var mapping struct {
+		Runs []renameRun `json:"runs"`
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &mapping)
+	}
+	mapping.Runs = append(mapping.Runs, renameRun{At: time.Now().UTC(), Renames: l.Renames})
+
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// looksObfuscated reports whether a file name is random rather than the name
+// of a release
+func looksObfuscated(filename string) bool {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return obfuscatedHex.MatchString(base) || obfuscatedWord.MatchString(base)
+}
+
+// isPar2 reports whether a file is a par2 file, whatever it is named
+func isPar2(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(par2Magic))
+	_, err = io.ReadFull(f, header)
+	return err == nil && bytes.Equal(header, par2Magic)
+}
+
+// readPar2FileDescs returns the file descriptions in a par2 file. Every file
+// of a par2 set repeats them, and the other packets are skipped over.
+func readPar2FileDescs(path string) ([]par2FileDesc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var descs []par2FileDesc
+	seen := make(map[string]bool)
+	header := make([]byte, 64)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return descs, nil
+			}
+			return descs, err
+		}
+		if !bytes.Equal(header[:8], par2Magic) {
+			return descs, fmt.Errorf("invalid par2 packet")
+		}
+		length := binary.LittleEndian.Uint64(header[8:16])
+		if length < 64 || length%4 != 0 || length > par2MaxPacket {
+			return descs, fmt.Errorf("invalid par2 packet length %d", length)
+		}
+		bodyLength := int64(length) - 64
+
+		if !bytes.Equal(header[48:64], par2FileDescType) {
+			if _, err := f.Seek(bodyLength, io.SeekCurrent); err != nil {
+				return descs, err
+			}
+			continue
+		}
+
+		// File ID, MD5, MD5 of the first 16k and length, then the name
+		body := make([]byte, bodyLength)
+		if _, err := io.ReadFull(f, body); err != nil {
+			return descs, nil
+		}
+		if len(body) < 56 {
+			continue
+		}
+		fileID := string(body[:16])
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+
+		desc := par2FileDesc{
+			Name:   string(bytes.TrimRight(body[56:], "\x00")),
+			Length: int64(binary.LittleEndian.Uint64(body[48:56])),
+		}
+		copy(desc.Hash16k[:], body[32:48])
+		descs = append(descs, desc)
+	}
+}
+
+// hash16k returns the MD5 of the first 16k of a file and its size, which par2
+// identifies files by
+func hash16k(path string) ([16]byte, int64, error) {
+	var sum [16]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return sum, 0, err
+	}
+	h := md5.New()
+	if _, err := io.CopyN(h, f, par2HashSize); err != nil && err != io.EOF {
+		return sum, 0, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, info.Size(), nil
+}
+
+// releaseNameFromSubject returns the release name a subject line carries
+// besides the file name, like "Show.S01E03.1080p" in
+// `Show.S01E03.1080p [01/20] - "a1b2c3.bin" yEnc (1/50)`
+func releaseNameFromSubject(subject string) string {
+	name := subjectNoise.ReplaceAllString(subject, " ")
+	name = strings.Trim(strings.Join(strings.Fields(name), " "), " -_[]()")
+	if len(name) < 5 || strings.ContainsAny(name, `[]"/\`) || looksObfuscated(name) || !strings.ContainsAny(strings.ToLower(name), "abcdefghijklmnopqrstuvwxyz") {
+		return ""
+	}
+	return safeFilename(CleanFilename(name))
+}
+
+// deobfuscate renames downloaded files to the names they were posted under.
+// par2 records the original name of every file it protects, identified by
+// size and the hash of its first 16k; obfuscated files par2 doesn't know are
+// named after the release name in their NZB subject line. It returns the files
+// with their new paths.
+func (fd *FastDownloader) deobfuscate(files []string, downloadDir string, renames *renameLog) []string {
+	var descs []par2FileDesc
+	for _, file := range files {
+		if !isPar2(file) {
+			continue
+		}
+		found, err := readPar2FileDescs(file)
+		if err != nil {
+			fd.download.AddLog(fmt.Sprintf("Could not read all of par2 file %s: %v", filepath.Base(file), err))
+		}
+		if len(found) > 0 {
+			descs = found
+			break
+		}
+	}
+	subjects := fd.subjectNames(downloadDir)
+
+	result := make([]string, len(files))
+	for i, file := range files {
+		result[i] = file
+		name := filepath.Base(file)
+		if name == nzbFileName || name == renameLogName || isPar2(file) {
+			continue
+		}
+
+		newName, source := "", ""
+		if len(descs) > 0 {
+			if sum, size, err := hash16k(file); err == nil {
+				for _, desc := range descs {
+					if desc.Length == size && desc.Hash16k == sum {
+						newName, source = safeFilename(desc.Name), "par2"
+						break
+					}
+				}
+			}
+		}
+		if newName == "" && looksObfuscated(name) {
+			if release := subjects[name]; release != "" {
+				// The extension is kept, and corrected from the file contents later
+				newName, source = release+filepath.Ext(name), "nzb_subject"
+			}
+		}
+		if newName == "" || newName == name {
+			continue
+		}
+
+		target, err := safeJoin(downloadDir, newName)
+		if err != nil {
+			fd.download.AddLog(fmt.Sprintf("Not renaming %s: %v", name, err))
+			continue
+		}
+		if renamed, ok := fd.renameFile(file, target, source, renames); ok {
+			result[i] = renamed
+		}
+	}
+	return result
+}
+
+// subjectNames maps the name each file of the NZB was written to onto the
+// release name in its subject line. Release names shared by several files,
+// like the volumes of an archive, can't name any of them and are left out.
+func (fd *FastDownloader) subjectNames(downloadDir string) map[string]string {
+	names := make(map[string]string)
+	f, err := os.Open(filepath.Join(downloadDir, nzbFileName))
+	if err != nil {
+		return names
+	}
+	defer f.Close()
+
+	uses := make(map[string]int)
+	StreamNZB(f, func(index int, file *NZBFile) error {
+		if release := releaseNameFromSubject(file.Subject); release != "" {
+			names[outputFilename(fd.download, index, file)] = release
+			uses[release]++
+		}
+		return nil
+	})
+	for file, release := range names {
+		if uses[release] > 1 {
+			delete(names, file)
+		}
+	}
+	return names
+}
+
+// nameObfuscatedMedia names the media file of a download after its release
+// when it still has an obfuscated name after extraction, as archives often
+// hold. With several media files, like a season pack, there is no telling
+// which is which, so obfuscated ones are left alone.
+func (fd *FastDownloader) nameObfuscatedMedia(downloadDir string, renames *renameLog) {
+	mediaFiles, err := findAllMediaFiles(downloadDir)
+	if err != nil {
+		return
+	}
+	var obfuscated []string
+	for _, file := range mediaFiles {
+		if looksObfuscated(file) {
+			obfuscated = append(obfuscated, file)
+		}
+	}
+	if len(obfuscated) == 0 {
+		return
+	}
+	if len(mediaFiles) > 1 {
+		fd.download.AddLog(fmt.Sprintf("%d of %d media files have obfuscated names and can't be told apart, leaving them as they are", len(obfuscated), len(mediaFiles)))
+		return
+	}
+
+	release := fd.releaseName(downloadDir)
+	if release == "" {
+		fd.download.AddLog(fmt.Sprintf("No release name to give obfuscated file %s", filepath.Base(obfuscated[0])))
+		return
+	}
+	target, err := safeJoin(downloadDir, release+strings.ToLower(filepath.Ext(obfuscated[0])))
+	if err != nil {
+		fd.download.AddLog(fmt.Sprintf("Not renaming %s: %v", filepath.Base(obfuscated[0]), err))
+		return
+	}
+	fd.renameFile(obfuscated[0], target, "release_name", renames)
+}
+
+// releaseName returns the name of the release a download holds: the title in
+// the NZB head, else the download name, unless that is obfuscated too
+func (fd *FastDownloader) releaseName(downloadDir string) string {
+	if f, err := os.Open(filepath.Join(downloadDir, nzbFileName)); err == nil {
+		head, _ := StreamNZB(f, func(int, *NZBFile) error { return nil })
+		f.Close()
+		if head != nil {
+			for _, meta := range head.Meta {
+				title := safeFilename(CleanFilename(strings.TrimSpace(meta.Value)))
+				if meta.Type == "title" && title != "" && !looksObfuscated(title) {
+					return title
+				}
+			}
+		}
+	}
+
+	name := safeFilename(CleanFilename(strings.TrimSpace(fd.download.Name)))
+	if name == "" || looksObfuscated(name) {
+		return ""
+	}
+	return name
+}
+
+// renameFile renames a file unless something already has the new name, and
+// logs and records the rename
+func (fd *FastDownloader) renameFile(from, to, source string, renames *renameLog) (string, bool) {
+	if _, err := os.Stat(to); err == nil {
+		fd.download.AddLog(fmt.Sprintf("Not renaming %s to %s, which already exists", filepath.Base(from), filepath.Base(to)))
+		return from, false
+	}
+	if err := os.Rename(from, to); err != nil {
+		fd.download.AddLog(fmt.Sprintf("Failed to rename %s: %v", filepath.Base(from), err))
+		return from, false
+	}
+
+	fd.download.AddLog(fmt.Sprintf("Deobfuscated: %s -> %s (from %s)", filepath.Base(from), filepath.Base(to), source))
+	renames.Renames = append(renames.Renames, renameRecord{From: filepath.Base(from), To: filepath.Base(to), Source: source})
+	return to, true
+}
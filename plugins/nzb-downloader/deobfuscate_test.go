@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// par2FileDescPacket builds a par2 FileDesc packet for a file
+func par2FileDescPacket(name string, content []byte) []byte {
+	body := make([]byte, 56)
+	copy(body[:16], "file-id-"+name)
+	hash := md5.Sum(content[:min(len(content), par2HashSize)])
+	copy(body[32:48], hash[:])
+	binary.LittleEndian.PutUint64(body[48:56], uint64(len(content)))
+	body = append(body, name...)
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+
+	header := make([]byte, 64)
+	copy(header[:8], par2Magic)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(64+len(body)))
+	copy(header[48:64], par2FileDescType)
+	return append(header, body...)
+}
+
+func TestDeobfuscateFromPar2(t *testing.T) {
+	dir := t.TempDir()
+	episode := bytes.Repeat([]byte{0x1A, 0x45, 0xDF, 0xA3}, 5000)
+	other := []byte("unrelated")
+
+	// A creator packet par2 files also carry is skipped over
+	creator := make([]byte, 64+8)
+	copy(creator[:8], par2Magic)
+	binary.LittleEndian.PutUint64(creator[8:16], uint64(len(creator)))
+	copy(creator[48:64], "PAR 2.0\x00Creator\x00")
+	par2 := append(creator, par2FileDescPacket("Show.S01E03.1080p.WEB.mkv", episode)...)
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	files := []string{
+		write("0a1b2c3d4e5f60718293a4b5c6d7e8f9.bin", episode),
+		write("d41d8cd98f00b204e9800998ecf8427e.bin", par2),
+		write("9f8e7d6c5b4a39281706f5e4d3c2b1a0.bin", other),
+	}
+
+	fd := newPostProcessor(&Download{Name: "Show.S01.1080p.WEB"})
+	renames := &renameLog{}
+	result := fd.deobfuscate(files, dir, renames)
+
+	if filepath.Base(result[0]) != "Show.S01E03.1080p.WEB.mkv" {
+		t.Errorf("episode renamed to %s", filepath.Base(result[0]))
+	}
+	if result[1] != files[1] || result[2] != files[2] {
+		t.Errorf("par2 and unmatched files should keep their names: %v", result[1:])
+	}
+	if len(renames.Renames) != 1 || renames.Renames[0].Source != "par2" {
+		t.Errorf("renames = %+v", renames.Renames)
+	}
+	if season, episode, _, ok := parseEpisodeFromFilename(filepath.Base(result[0])); !ok || season != 1 || episode != 3 {
+		t.Errorf("renamed episode parses as S%02dE%02d (%v)", season, episode, ok)
+	}
+
+	renames.save(dir)
+	renames.save(dir)
+	data, err := os.ReadFile(filepath.Join(dir, renameLogName))
+	if err != nil || strings.Count(string(data), "Show.S01E03.1080p.WEB.mkv") != 2 {
+		t.Errorf("mapping file should hold both runs: %s, %v", data, err)
+	}
+}
+
+func TestReleaseNameFromSubject(t *testing.T) {
+	tests := []struct {
+		subject, want string
+	}{
+		{`Show.S01E03.1080p.WEB-GROUP [01/20] - "a1b2c3d4e5f60718293a.bin" yEnc (1/50)`, "Show.S01E03.1080p.WEB-GROUP"},
+		{`"0a1b2c3d4e5f60718293a4b5c6d7e8f9.bin" yEnc (1/50)`, ""},
+		{`[PRiVATE]-[WtFnZb]-[0a1b2c3d4e5f6071.bin]-[1/3] - "" yEnc`, ""},
+		{`[12/20] - "Movie.2023.mkv" yEnc (1/10)`, ""},
+	}
+	for _, tt := range tests {
+		if got := releaseNameFromSubject(tt.subject); got != tt.want {
+			t.Errorf("releaseNameFromSubject(%q) = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestLooksObfuscated(t *testing.T) {
+	for name, want := range map[string]bool{
+		"0a1b2c3d4e5f60718293a4b5c6d7e8f9.bin": true,
+		"aB3dE5gH7jK9mN1pQ3sT5vW7.mkv":         true,
+		"Show.S01E03.1080p.WEB.mkv":            false,
+		"movie.part01.rar":                     false,
+	} {
+		if got := looksObfuscated(name); got != want {
+			t.Errorf("looksObfuscated(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stages of a server connection test, reported with a failure so the UI can
+// tell the user what to fix
+const (
+	stageDNS      = "dns"
+	stageTCP      = "tcp"
+	stageTLS      = "tls"
+	stageGreeting = "greeting"
+	stageAuth     = "auth"
+	stageProtocol = "protocol"
+	stageArticle  = "article"
+)
+
+const (
+	diagnosticsDialTimeout = 10 * time.Second
+	diagnosticsTimeout     = 30 * time.Second
+)
+
+// TLSDiagnostics describes the TLS session negotiated with a server
+type TLSDiagnostics struct {
+	Version            string    `json:"version"`
+	CipherSuite        string    `json:"cipher_suite"`
+	ServerName         string    `json:"server_name"`
+	CertificateSubject string    `json:"certificate_subject,omitempty"`
+	CertificateIssuer  string    `json:"certificate_issuer,omitempty"`
+	CertificateExpires time.Time `json:"certificate_expires,omitempty"`
+	CertificateError   string    `json:"certificate_error,omitempty"` // Why the certificate wouldn't verify
+}
+
+// ArticleDiagnostics is the outcome of fetching a test article
+type ArticleDiagnostics struct {
+	MessageID  string `json:"message_id"`
+	Found      bool   `json:"found"`
+	Bytes      int    `json:"bytes,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ServerDiagnostics is the result of testing the connection to a server. When
+// the test fails, Stage is the step that failed.
+type ServerDiagnostics struct {
+	Success        bool                `json:"success"`
+	Stage          string              `json:"stage,omitempty"`
+	Error          string              `json:"error,omitempty"`
+	Message        string              `json:"message,omitempty"`
+	Address        string              `json:"address"`
+	RetentionDays  int                 `json:"retention_days"` // As configured, 0 when unknown
+	ResolvedIPs    []string            `json:"resolved_ips,omitempty"`
+	ConnectMS      int64               `json:"connect_ms,omitempty"`
+	TLS            *TLSDiagnostics     `json:"tls,omitempty"`
+	Greeting       string              `json:"greeting,omitempty"`
+	PostingAllowed *bool               `json:"posting_allowed,omitempty"`
+	LatencyMS      *int64              `json:"latency_ms,omitempty"` // Round trip of the DATE command
+	ServerDate     string              `json:"server_date,omitempty"`
+	Capabilities   []string            `json:"capabilities,omitempty"`
+	Article        *ArticleDiagnostics `json:"article,omitempty"`
+	Warnings       []string            `json:"warnings,omitempty"`
+}
+
+// fail records the stage a test failed at
+func (d *ServerDiagnostics) fail(stage, format string, args ...interface{}) ServerDiagnostics {
+	d.Success = false
+	d.Stage = stage
+	d.Error = fmt.Sprintf(format, args...)
+	return *d
+}
+
+// diagnoseServer connects to a server step by step, collecting what it learns
+// on the way. If messageID is set, that article is fetched to prove the
+// server can serve downloads.
+func diagnoseServer(ctx context.Context, server NNTPServer, messageID string) ServerDiagnostics {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+
+	d := ServerDiagnostics{Address: net.JoinHostPort(server.Host, strconv.Itoa(server.Port)), RetentionDays: server.RetentionDays}
+
+	// DNS
+	if net.ParseIP(server.Host) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, server.Host)
+		if err != nil {
+			return d.fail(stageDNS, "DNS lookup failed: %s", dnsErrorMessage(err))
+		}
+		d.ResolvedIPs = addrs
+	} else {
+		d.ResolvedIPs = []string{server.Host}
+	}
+
+	// TCP
+	start := time.Now()
+	dialer := net.Dialer{Timeout: diagnosticsDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		return d.fail(stageTCP, "TCP connection failed: %s", netErrorMessage(err))
+	}
+	defer conn.Close()
+	d.ConnectMS = time.Since(start).Milliseconds()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// TLS
+	if server.UseSSL {
+		// Downloads don't verify certificates, so the handshake doesn't either.
+		// The chain is verified afterwards to warn about it.
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: server.Host, InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return d.fail(stageTLS, "TLS handshake failed: %s", netErrorMessage(err))
+		}
+		d.TLS = describeTLS(tlsConn.ConnectionState(), server.Host)
+		if d.TLS.CertificateError != "" {
+			d.Warnings = append(d.Warnings, "Certificate doesn't verify: "+d.TLS.CertificateError)
+		}
+		conn = tlsConn
+	}
+
+	client := &NNTPClient{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+
+	// Greeting
+	code, message, err := client.readResponse()
+	if err != nil {
+		return d.fail(stageGreeting, "No greeting from server: %s", netErrorMessage(err))
+	}
+	d.Greeting = fmt.Sprintf("%d %s", code, message)
+	switch code {
+	case 200, 201: // Posting allowed, posting prohibited
+		posting := code == 200
+		d.PostingAllowed = &posting
+	case 400, 502: // Service unavailable, service permanently unavailable
+		return d.fail(stageGreeting, "Server refused the connection: %s", d.Greeting)
+	default:
+		return d.fail(stageGreeting, "Unexpected greeting: %s", d.Greeting)
+	}
+
+	// Auth
+	if server.Username != "" {
+		if err := client.Authenticate(server.Username, server.Password); err != nil {
+			return d.fail(stageAuth, "Authentication failed: %v", err)
+		}
+	}
+
+	// Capabilities
+	capabilities, err := client.capabilities()
+	if err != nil {
+		if !errors.Is(err, errUnsupportedCommand) {
+			return d.fail(stageProtocol, "CAPABILITIES failed: %v", err)
+		}
+		d.Warnings = append(d.Warnings, "Server doesn't support the CAPABILITIES command")
+	}
+	d.Capabilities = capabilities
+
+	// Latency
+	start = time.Now()
+	date, err := client.date()
+	if err != nil {
+		if !errors.Is(err, errUnsupportedCommand) {
+			return d.fail(stageProtocol, "DATE failed: %v", err)
+		}
+		d.Warnings = append(d.Warnings, "Server doesn't support the DATE command")
+	} else {
+		latency := time.Since(start).Milliseconds()
+		d.LatencyMS = &latency
+		d.ServerDate = date
+	}
+
+	// Test article
+	if messageID != "" {
+		article := &ArticleDiagnostics{MessageID: messageID}
+		d.Article = article
+		start = time.Now()
+		data, err := client.GetArticle(messageID)
+		article.DurationMS = time.Since(start).Milliseconds()
+		if err != nil {
+			article.Error = err.Error()
+			if errors.Is(err, errArticleMissing) {
+				return d.fail(stageArticle, "Test article %s not found on server", messageID)
+			}
+			return d.fail(stageArticle, "Test article fetch failed: %v", err)
+		}
+		article.Found = true
+		article.Bytes = len(data)
+	}
+
+	client.sendCommand("QUIT")
+
+	d.Success = true
+	d.Message = "Connection successful"
+	return d
+}
+
+// describeTLS reports the negotiated TLS session and whether its certificate
+// chain verifies for host
+func describeTLS(state tls.ConnectionState, host string) *TLSDiagnostics {
+	info := &TLSDiagnostics{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  host,
+	}
+	if len(state.PeerCertificates) == 0 {
+		info.CertificateError = "server sent no certificate"
+		return info
+	}
+
+	leaf := state.PeerCertificates[0]
+	info.CertificateSubject = leaf.Subject.String()
+	info.CertificateIssuer = leaf.Issuer.String()
+	info.CertificateExpires = leaf.NotAfter
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates}); err != nil {
+		info.CertificateError = certErrorMessage(err)
+	}
+	return info
+}
+
+// certErrorMessage describes why a certificate didn't verify
+func certErrorMessage(err error) string {
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) && invalid.Reason == x509.Expired {
+		return "certificate expired"
+	}
+	var unknown x509.UnknownAuthorityError
+	if errors.As(err, &unknown) {
+		return "certificate signed by unknown authority"
+	}
+	var hostname x509.HostnameError
+	if errors.As(err, &hostname) {
+		return "certificate is not valid for " + hostname.Host
+	}
+	return err.Error()
+}
+
+// dnsErrorMessage describes a failed host lookup
+func dnsErrorMessage(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return "host " + dnsErr.Name + " not found"
+		case dnsErr.IsTimeout:
+			return "lookup of " + dnsErr.Name + " timed out"
+		}
+		return dnsErr.Err
+	}
+	return err.Error()
+}
+
+// netErrorMessage describes a failed network operation without repeating the
+// addresses, which the diagnostics already report
+func netErrorMessage(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timed out"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Err != nil {
+		return netErrorMessage(opErr.Err)
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return certErrorMessage(certErr.Err)
+	}
+	return err.Error()
+}
+
+// errUnsupportedCommand is returned for commands the server doesn't know
+var errUnsupportedCommand = errors.New("command not supported")
+
+// capabilities lists the capabilities the server advertises
+func (c *NNTPClient) capabilities() ([]string, error) {
+	if err := c.sendCommand("CAPABILITIES"); err != nil {
+		return nil, err
+	}
+	code, message, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if code == 500 { // Unknown command
+		return nil, errUnsupportedCommand
+	}
+	if code != 101 { // 101 = Capability list follows
+		return nil, fmt.Errorf("unexpected response to CAPABILITIES: %d %s", code, message)
+	}
+
+	var capabilities []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			return capabilities, nil
+		}
+		capabilities = append(capabilities, line)
+	}
+}
+
+// date returns the server's clock, as YYYYMMDDhhmmss in UTC
+func (c *NNTPClient) date() (string, error) {
+	if err := c.sendCommand("DATE"); err != nil {
+		return "", err
+	}
+	code, message, err := c.readResponse()
+	if err != nil {
+		return "", err
+	}
+	if code == 500 { // Unknown command
+		return "", errUnsupportedCommand
+	}
+	if code != 111 { // 111 = Server date and time
+		return "", fmt.Errorf("unexpected response to DATE: %d %s", code, message)
+	}
+	return message, nil
+}
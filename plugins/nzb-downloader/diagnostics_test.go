@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveNNTP runs a minimal NNTP server on localhost answering one
+// connection at a time
+func serveNNTP(t *testing.T, greeting string, capabilities bool) NNTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				reply := func(lines ...string) {
+					conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+				}
+				reply(greeting)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.TrimSpace(line); {
+					case cmd == "AUTHINFO USER user":
+						reply("381 Password required")
+					case cmd == "AUTHINFO PASS secret":
+						reply("281 Welcome")
+					case strings.HasPrefix(cmd, "AUTHINFO PASS"):
+						reply("481 Invalid username or password")
+					case cmd == "CAPABILITIES" && capabilities:
+						reply("101 Capability list:", "VERSION 2", "READER", "AUTHINFO USER", ".")
+					case cmd == "DATE":
+						reply("111 20260101120000")
+					case cmd == "ARTICLE <found@test>":
+						reply("220 0 <found@test>", "Subject: test", "", "..body", ".")
+					case strings.HasPrefix(cmd, "ARTICLE"):
+						reply("430 No such article")
+					case cmd == "QUIT":
+						reply("205 Bye")
+						return
+					default:
+						reply("500 Unknown command")
+					}
+				}
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return NNTPServer{Host: host, Port: portNumber, Username: "user", Password: "secret"}
+}
+
+func TestDiagnoseServer(t *testing.T) {
+	server := serveNNTP(t, "201 Ready, posting prohibited", true)
+
+	d := diagnoseServer(context.Background(), server, "<found@test>")
+	if !d.Success || d.Stage != "" || d.Error != "" {
+		t.Fatalf("diagnoseServer() = %+v, want success", d)
+	}
+	if d.PostingAllowed == nil || *d.PostingAllowed {
+		t.Errorf("posting allowed = %v, want false from a 201 greeting", d.PostingAllowed)
+	}
+	if len(d.Capabilities) != 3 || d.Capabilities[0] != "VERSION 2" {
+		t.Errorf("capabilities = %v", d.Capabilities)
+	}
+	if d.LatencyMS == nil || d.ServerDate != "20260101120000" {
+		t.Errorf("latency = %v, server date = %q, want the DATE round trip", d.LatencyMS, d.ServerDate)
+	}
+	if d.Article == nil || !d.Article.Found || d.Article.Bytes == 0 {
+		t.Errorf("article = %+v, want found", d.Article)
+	}
+	if d.TLS != nil {
+		t.Errorf("tls = %+v on a plain connection", d.TLS)
+	}
+}
+
+func TestDiagnoseServerFailures(t *testing.T) {
+	server := serveNNTP(t, "200 Ready", false)
+
+	d := diagnoseServer(context.Background(), server, "")
+	if !d.Success || d.PostingAllowed == nil || !*d.PostingAllowed {
+		t.Errorf("diagnoseServer() = %+v, want success with posting allowed", d)
+	}
+	if len(d.Warnings) != 1 || d.Capabilities != nil {
+		t.Errorf("warnings = %v, capabilities = %v, want a warning without CAPABILITIES", d.Warnings, d.Capabilities)
+	}
+
+	wrongPassword := server
+	wrongPassword.Password = "wrong"
+	d = diagnoseServer(context.Background(), wrongPassword, "")
+	if d.Success || d.Stage != stageAuth || !strings.Contains(d.Error, "481") {
+		t.Errorf("diagnoseServer() with a wrong password = %+v, want an auth failure", d)
+	}
+
+	d = diagnoseServer(context.Background(), server, "<missing@test>")
+	if d.Success || d.Stage != stageArticle || d.Article == nil || d.Article.Found {
+		t.Errorf("diagnoseServer() for a missing article = %+v, want an article failure", d)
+	}
+
+	refused := serveNNTP(t, "502 Access denied", true)
+	d = diagnoseServer(context.Background(), refused, "")
+	if d.Success || d.Stage != stageGreeting {
+		t.Errorf("diagnoseServer() refused = %+v, want a greeting failure", d)
+	}
+
+	// A listener that was closed refuses the connection
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+	d = diagnoseServer(context.Background(), NNTPServer{Host: "127.0.0.1", Port: closed.Port}, "")
+	if d.Success || d.Stage != stageTCP {
+		t.Errorf("diagnoseServer() closed port = %+v, want a TCP failure", d)
+	}
+
+	d = diagnoseServer(context.Background(), NNTPServer{Host: "news.nonexistent.invalid", Port: 119}, "")
+	if d.Success || d.Stage != stageDNS {
+		t.Errorf("diagnoseServer() unknown host = %+v, want a DNS failure", d)
+	}
+}
+
+func TestDiagnoseServerTLSHandshakeFailure(t *testing.T) {
+	// A plain NNTP server doesn't speak TLS
+	server := serveNNTP(t, "200 Ready", true)
+	server.UseSSL = true
+
+	d := diagnoseServer(context.Background(), server, "")
+	if d.Success || d.Stage != stageTLS || !strings.HasPrefix(d.Error, "TLS handshake failed: ") {
+		t.Errorf("diagnoseServer() = %+v, want a TLS failure", d)
+	}
+}
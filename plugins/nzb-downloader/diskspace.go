@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const (
+	// defaultSpaceMultiplier covers the download plus its extracted copy
+	defaultSpaceMultiplier = 2.2
+	// defaultSpaceMarginMB is kept free on top of what a download needs
+	defaultSpaceMarginMB = 1024
+
+	// spaceRecheckInterval is how often a download waiting for space checks again
+	spaceRecheckInterval = 30 * time.Second
+)
+
+var errDiskFreeUnsupported = errors.New("free disk space is not supported on this platform")
+
+// diskSettings controls how much free space a download needs to start
+type diskSettings struct {
+	multiplier  float64 // Space needed as a multiple of the download size
+	marginBytes int64   // Extra space to leave free
+}
+
+// diskSettingsOrDefault returns the configured disk settings
+func (p *NZBDownloaderPlugin) diskSettingsOrDefault() diskSettings {
+	if s := p.disk.Load(); s != nil {
+		return *s
+	}
+	return diskSettings{multiplier: defaultSpaceMultiplier, marginBytes: defaultSpaceMarginMB << 20}
+}
+
+// loadDiskSettings reads the disk space settings from the config store
+func (p *NZBDownloaderPlugin) loadDiskSettings(ctx context.Context, sdk plugins.SDKInterface) {
+	settings := diskSettings{multiplier: defaultSpaceMultiplier, marginBytes: defaultSpaceMarginMB << 20}
+	if val, err := sdk.ConfigGet(ctx, configSpaceMultiplier); err == nil {
+		if multiplier, ok := parseNumber(val); ok && multiplier >= 1 {
+			settings.multiplier = multiplier
+		}
+	}
+	if val, err := sdk.ConfigGet(ctx, configSpaceMarginMB); err == nil {
+		if margin, ok := parseNumber(val); ok && margin >= 0 {
+			settings.marginBytes = int64(margin) << 20
+		}
+	}
+	p.disk.Store(&settings)
+}
+
+// parseNumber reads a numeric config value, which may be stored as a string
+func parseNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// required returns the free bytes needed to write multiplier times totalBytes
+func (s diskSettings) required(totalBytes int64, multiplier float64) uint64 {
+	return uint64(math.Ceil(float64(totalBytes)*multiplier)) + uint64(s.marginBytes)
+}
+
+// freeSpace returns the free bytes on the filesystem a directory is or will
+// be created on, checking its nearest existing parent
+func freeSpace(dir string) (uint64, error) {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return diskFree(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return diskFree(dir)
+		}
+		dir = parent
+	}
+}
+
+// checkSpace compares the free space in dir against what is needed, returning
+// a status detail when there isn't enough. Platforms that can't report free
+// space always pass.
+func checkSpace(dir string, needed uint64) (string, bool) {
+	free, err := freeSpace(dir)
+	if err != nil || free >= needed {
+		return "", true
+	}
+	return fmt.Sprintf("waiting: need %s, %s free", formatBytes(needed), formatBytes(free)), false
+}
+
+// formatBytes formats a size in the largest unit that keeps it above 1
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.0f%cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// downloadDir returns the directory a download is written to
+func downloadDir(dl *Download) string {
+	if dl.DownloadDir == "" {
+		return "/tmp/nzb-downloads"
+	}
+	return dl.DownloadDir
+}
+
+// hasSpaceLocked reports whether there is enough free space to start a
+// download. A download without space keeps a status detail saying so, is
+// checked again every spaceRecheckInterval, and raises one warning. Callers
+// must hold the DownloadManager lock.
+func (p *NZBDownloaderPlugin) hasSpaceLocked(dl *Download) bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	if dl.StatusDetail != "" && time.Since(dl.spaceCheckedAt) < spaceRecheckInterval {
+		return false
+	}
+
+	settings := p.diskSettingsOrDefault()
+	detail, ok := checkSpace(downloadDir(dl), settings.required(dl.TotalBytes, settings.multiplier))
+	dl.spaceCheckedAt = time.Now()
+	if ok {
+		if dl.StatusDetail != "" {
+			dl.AddLog("Enough disk space is available, starting")
+		}
+		dl.StatusDetail = ""
+		dl.spaceWarned = false
+		return true
+	}
+
+	if dl.StatusDetail != detail {
+		dl.StatusDetail = detail
+		dl.AddLog("Not enough disk space to start, " + detail)
+	}
+	if !dl.spaceWarned {
+		dl.spaceWarned = true
+		go p.warnDownload(dl, "Not enough disk space to start download, "+detail)
+	}
+	return false
+}
+
+// waitForExtractionSpace blocks until there is room to extract a finished
+// download, which roughly doubles its size. It returns false if the download
+// was cancelled while waiting.
+func (p *NZBDownloaderPlugin) waitForExtractionSpace(ctx context.Context, dl *Download, dir string) bool {
+	settings := p.diskSettingsOrDefault()
+	// The download itself is already on disk
+	needed := settings.required(dl.TotalBytes, math.Max(settings.multiplier-1, 0))
+
+	warned := false
+	for {
+		detail, ok := checkSpace(dir, needed)
+		if ok {
+			if warned {
+				dl.update(func() { dl.StatusDetail = "" })
+				dl.AddLog("Enough disk space is available, extracting")
+			}
+			return true
+		}
+
+		if !warned {
+			warned = true
+			dl.update(func() { dl.StatusDetail = detail })
+			dl.AddLog("Not enough disk space to extract, " + detail)
+			p.persistDownloadState()
+			go p.warnDownload(dl, "Not enough disk space to extract download, "+detail)
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(spaceRecheckInterval):
+		}
+	}
+}
+
+// warnDownload raises a warning about a download on the host
+func (p *NZBDownloaderPlugin) warnDownload(dl *Download, warning string) {
+	sdk, err := p.getSDK()
+	if err != nil {
+		return
+	}
+
+	payload := downloadPayload(dl)
+	payload["warning"] = warning
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sdk.DownloadSync(ctx, payload); err != nil {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Failed to send warning for %s: %v\n", dl.ID, err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+// diskFree is not supported on this platform, so the space check is skipped
+func diskFree(path string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -43,10 +45,15 @@ type FastDownloader struct {
 	downloadedBytes int64
 	download        *Download // Reference to download for logging
 	activeWorkers   int32     // Track active workers
+	server          NNTPServer
+	stats           *serverStats    // Statistics of the server, kept across downloads
+	articles        articleCounters // Article requests of this download
+	speed           speedMeter      // Sampled by the result loop of Download only
+	tools           ExtractTools    // External extraction tools, set before post-processing
 }
 
 // NewFastDownloader creates a new fast downloader with connection pool
-func NewFastDownloader(ctx context.Context, server NNTPServer, download *Download) (*FastDownloader, error) {
+func NewFastDownloader(ctx context.Context, server NNTPServer, download *Download, stats *serverStats) (*FastDownloader, error) {
 	numConnections := server.Connections
 	if numConnections <= 0 {
 		numConnections = 10
@@ -68,6 +75,8 @@ func NewFastDownloader(ctx context.Context, server NNTPServer, download *Downloa
 		ctx:         ctx,
 		cancel:      cancel,
 		download:    download,
+		server:      server,
+		stats:       stats,
 	}
 
 	download.AddLog(fmt.Sprintf("Initializing %d connections to %s:%d", numConnections, server.Host, server.Port))
@@ -129,6 +138,31 @@ func NewFastDownloader(ctx context.Context, server NNTPServer, download *Downloa
 	return fd, nil
 }
 
+// scanNZB counts the files, segments and bytes of a download's NZB
+func (fd *FastDownloader) scanNZB(download *Download, nzbPath string) error {
+	f, err := os.Open(nzbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open NZB: %v", err)
+	}
+	defer f.Close()
+
+	summary, err := ScanNZB(f)
+	if err != nil {
+		return fmt.Errorf("failed to read NZB: %v", err)
+	}
+	download.applySummary(summary)
+	if len(download.WantedEpisodes) > 0 {
+		download.selectWantedEpisodes(summary)
+	}
+	return nil
+}
+
+// newPostProcessor creates a downloader without connections, which can only
+// post-process files that are already on disk
+func newPostProcessor(download *Download) *FastDownloader {
+	return &FastDownloader{download: download}
+}
+
 // worker processes download jobs
 func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 	defer fd.wg.Done()
@@ -139,6 +173,9 @@ func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 		}
 	}()
 
+	fd.stats.connections.Add(1)
+	defer fd.stats.connections.Add(-1)
+
 	if id == 0 {
 		fd.download.AddLog(fmt.Sprintf("Started %d workers", len(fd.connPool)))
 	}
@@ -169,8 +206,18 @@ func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 			}
 
 			// Download segment
+			fd.stats.busy.Add(1)
+			start := time.Now()
 			article, err := conn.GetArticle(job.Segment.MessageID)
+			took := time.Since(start)
+			fd.stats.busy.Add(-1)
 			if err != nil {
+				outcome := articleErrored
+				if errors.Is(err, errArticleMissing) {
+					outcome = articleMissing
+				}
+				fd.recordArticle(outcome, 0, took)
+
 				// Retry logic
 				if job.Retries < 3 {
 					job.Retries++
@@ -193,6 +240,7 @@ func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 			// Decode yEnc
 			decoded, err := DecodeArticle(article)
 			if err != nil {
+				fd.recordArticle(articleErrored, 0, took)
 				fd.resultQueue <- &SegmentResult{
 					FileIndex:    job.FileIndex,
 					SegmentIndex: job.SegmentIndex,
@@ -208,10 +256,12 @@ func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 			tolerance := int64(float64(expectedSize) * 0.5) // 50% tolerance
 
 			if decodedSize > expectedSize+tolerance || decodedSize < expectedSize-tolerance {
-				fd.download.AddLog(fmt.Sprintf("WARNING: Segment size mismatch - expected ~%d bytes, got %d bytes (segment %d of file %d)",
+				fd.download.AddDetailLog(fmt.Sprintf("WARNING: Segment size mismatch - expected ~%d bytes, got %d bytes (segment %d of file %d)",
 					expectedSize, decodedSize, job.SegmentIndex, job.FileIndex))
 			}
 
+			fd.recordArticle(articleFound, decodedSize, took)
+
 			// Send result
 			fd.resultQueue <- &SegmentResult{
 				FileIndex:    job.FileIndex,
@@ -225,65 +275,140 @@ func (fd *FastDownloader) worker(id int, conn *NNTPClient) {
 	}
 }
 
-// Download downloads an NZB with all its files
+// Download downloads an NZB with all its files. The NZB is streamed from the
+// download directory, and only as many segments as the job queue holds are
+// queued or being downloaded at once.
 func (fd *FastDownloader) Download(download *Download, downloadDir string) error {
-	nzbData := download.NZBData
+	nzbPath := filepath.Join(downloadDir, nzbFileName)
 
-	fd.download.AddLog(fmt.Sprintf("Starting download process for %d files", len(nzbData.Files)))
+	// Downloads restored from before the counts were saved need a first pass
+	if download.SegmentCount == 0 {
+		if err := fd.scanNZB(download, nzbPath); err != nil {
+			return err
+		}
+	}
+	totalSegments := download.SegmentCount
+
+	fd.download.AddLog(fmt.Sprintf("Starting download process for %d files", download.FileCount))
 
 	// Calculate total bytes
 	fd.totalBytes = download.TotalBytes
 
-	// Create file writers map
-	fileWriters := make(map[int]*FileAssembler)
+	nzbFile, err := os.Open(nzbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open NZB: %v", err)
+	}
+	defer nzbFile.Close()
 
 	fd.download.AddLog(fmt.Sprintf("Creating output files in %s", downloadDir))
 
-	for fileIdx, file := range nzbData.Files {
-		filename := file.Filename()
-		if filename == "" || len(filename) == 0 {
-			filename = fmt.Sprintf("%s-part%d.bin", download.Name, fileIdx+1)
-		}
-		filename = filepath.Base(filename)
-		filename = CleanFilename(filename)
+	// Files are created as the NZB is read. The queueing goroutine adds each
+	// file before queueing its segments, so results never arrive for a file
+	// that isn't in the map yet.
+	fileWriters := make(map[int]*FileAssembler)
+	var writersMu sync.Mutex
 
-		outputPath := filepath.Join(downloadDir, filename)
+	fd.download.AddLog(fmt.Sprintf("Queueing %d segments across %d files (%.2f MB total)",
+		totalSegments, download.FileCount, float64(fd.totalBytes)/(1024*1024)))
+
+	// A slot is taken for each queued segment and given back with its result.
+	// With no more segments out than the queues hold, a worker re-queueing a
+	// retry or sending a result never blocks.
+	slots := make(chan struct{}, cap(fd.jobQueue))
+	queueErr := make(chan error, 1)
+
+	// A sequential download also takes a slot of the window for each segment
+	// of its main file
+	var window chan struct{}
+	mainFile := -1
+	if download.Sequential {
+		window = make(chan struct{}, sequentialWindow(fd.workers))
+		mainFile = download.MainFile
+		download.update(func() { download.ContiguousBytes = 0 })
+		fd.download.AddLog(fmt.Sprintf("Sequential download: queueing file %d first, %d segments at a time", mainFile+1, cap(window)))
+	}
+
+	queueFile := func(fileIdx int, file *NZBFile, segments []NZBSegment, window chan struct{}) error {
+		filename := outputFilename(download, fileIdx, file)
+		outputPath, err := safeJoin(downloadDir, filename)
+		if err != nil {
+			fd.download.AddLog(fmt.Sprintf("ERROR: Refusing to write file %s: %v", filename, err))
+			return fmt.Errorf("unsafe file name: %v", err)
+		}
 
 		assembler, err := NewFileAssembler(outputPath, len(file.Segments))
 		if err != nil {
 			fd.download.AddLog(fmt.Sprintf("ERROR creating file %s: %v", filename, err))
 			return fmt.Errorf("failed to create file assembler: %v", err)
 		}
+		writersMu.Lock()
 		fileWriters[fileIdx] = assembler
-	}
-
-	fd.download.AddLog(fmt.Sprintf("Created %d output files", len(fileWriters)))
+		writersMu.Unlock()
 
-	// Count total segments first
-	totalSegments := 0
-	for _, file := range nzbData.Files {
-		totalSegments += len(file.Segments)
+		for _, segment := range segments {
+			select {
+			case slots <- struct{}{}:
+			case <-fd.ctx.Done():
+				return fd.ctx.Err()
+			}
+			if window != nil {
+				select {
+				case window <- struct{}{}:
+				case <-fd.ctx.Done():
+					return fd.ctx.Err()
+				}
+			}
+			select {
+			case fd.jobQueue <- &SegmentJob{
+				FileIndex:    fileIdx,
+				SegmentIndex: segment.Number - 1, // Convert from 1-based to 0-based indexing
+				Segment:      segment,
+				Retries:      0,
+			}:
+			case <-fd.ctx.Done():
+				return fd.ctx.Err()
+			}
+		}
+		return nil
 	}
 
-	fd.download.AddLog(fmt.Sprintf("Queueing %d segments across %d files (%.2f MB total)",
-		totalSegments, len(nzbData.Files), float64(fd.totalBytes)/(1024*1024)))
+	// Files of a season pack left out for other episodes, see partial.go
+	skipped := skippedSet(download)
+	if len(skipped) > 0 {
+		fd.download.AddLog(fmt.Sprintf("Skipping %d files of other episodes", len(skipped)))
+	}
 
-	// Queue all segment jobs in background to avoid blocking
 	go func() {
-		for fileIdx, file := range nzbData.Files {
-			for _, segment := range file.Segments {
-				select {
-				case fd.jobQueue <- &SegmentJob{
-					FileIndex:    fileIdx,
-					SegmentIndex: segment.Number - 1, // Convert from 1-based to 0-based indexing
-					Segment:      segment,
-					Retries:      0,
-				}:
-				case <-fd.ctx.Done():
-					return
+		var err error
+		if mainFile >= 0 {
+			// Read the NZB up to the main file and queue it on its own first
+			_, err = StreamNZB(nzbFile, func(fileIdx int, file *NZBFile) error {
+				if fileIdx != mainFile {
+					return nil
 				}
+				if err := queueFile(fileIdx, file, orderedSegments(file), window); err != nil {
+					return err
+				}
+				return errMainFileQueued
+			})
+			if errors.Is(err, errMainFileQueued) {
+				err = nil
+			}
+			if err == nil {
+				_, err = nzbFile.Seek(0, io.SeekStart)
 			}
 		}
+		if err == nil {
+			_, err = StreamNZB(nzbFile, func(fileIdx int, file *NZBFile) error {
+				if fileIdx == mainFile || skipped[fileIdx] {
+					return nil
+				}
+				return queueFile(fileIdx, file, file.Segments, nil)
+			})
+		}
+		if err != nil && fd.ctx.Err() == nil {
+			queueErr <- err
+		}
 	}()
 
 	fd.download.AddLog("Processing results...")
@@ -293,14 +418,48 @@ func (fd *FastDownloader) Download(download *Download, downloadDir string) error
 	failedSegments := 0
 
 	startTime := time.Now()
-	lastUpdate := time.Now()
-	lastBytes := int64(0)
+	fd.speed.sample(atomic.LoadInt64(&fd.downloadedBytes), startTime)
+	ticker := time.NewTicker(speedSampleInterval)
+	defer ticker.Stop()
+	ticks := 0
 
 	for receivedSegments+failedSegments < totalSegments {
 		select {
 		case <-fd.ctx.Done():
+			// Keep what was written of each file
+			writersMu.Lock()
+			for _, assembler := range fileWriters {
+				assembler.Stop()
+			}
+			writersMu.Unlock()
 			return fmt.Errorf("download cancelled")
+		case err := <-queueErr:
+			writersMu.Lock()
+			for _, assembler := range fileWriters {
+				assembler.Close()
+			}
+			writersMu.Unlock()
+			return fmt.Errorf("failed to read NZB: %v", err)
+		case now := <-ticker.C:
+			// Sampling on a ticker keeps the speed falling while no
+			// segment finishes
+			downloaded := atomic.LoadInt64(&fd.downloadedBytes)
+			fd.speed.sample(downloaded, now)
+			download.setSpeed(fd.speed.speed(), fd.speed.eta(fd.totalBytes-downloaded))
+
+			// Progress log every 5 seconds to logs
+			ticks++
+			if ticks%5 == 0 {
+				progress := float64(receivedSegments) / float64(totalSegments) * 100
+				fd.download.AddDetailLog(fmt.Sprintf("Progress: %d/%d segments (%.1f%%) - %.2f MB/s",
+					receivedSegments, totalSegments, progress, float64(fd.speed.speed())/(1024*1024)))
+			}
 		case result := <-fd.resultQueue:
+			<-slots
+			if result != nil && result.FileIndex == mainFile {
+				<-window
+			}
+
 			if result == nil {
 				fd.download.AddLog("ERROR: Received nil result")
 				failedSegments++
@@ -308,13 +467,15 @@ func (fd *FastDownloader) Download(download *Download, downloadDir string) error
 			}
 
 			if result.Error != nil {
-				fd.download.AddLog(fmt.Sprintf("Segment %d/%d failed: %v", result.FileIndex, result.SegmentIndex, result.Error))
+				fd.download.AddDetailLog(fmt.Sprintf("Segment %d/%d failed: %v", result.FileIndex, result.SegmentIndex, result.Error))
 				failedSegments++
 				continue
 			}
 
 			// Write segment to assembler
+			writersMu.Lock()
 			assembler, ok := fileWriters[result.FileIndex]
+			writersMu.Unlock()
 			if !ok {
 				fd.download.AddLog(fmt.Sprintf("ERROR: No assembler for file index %d", result.FileIndex))
 				failedSegments++
@@ -328,46 +489,27 @@ func (fd *FastDownloader) Download(download *Download, downloadDir string) error
 			}
 
 			if err := assembler.WriteSegment(result.SegmentIndex, result.Data); err != nil {
-				fd.download.AddLog(fmt.Sprintf("Failed to write segment %d/%d: %v", result.FileIndex, result.SegmentIndex, err))
+				fd.download.AddDetailLog(fmt.Sprintf("Failed to write segment %d/%d: %v", result.FileIndex, result.SegmentIndex, err))
 				failedSegments++
 				continue
 			}
 
 			receivedSegments++
 
-			// Update progress
-			downloaded := atomic.LoadInt64(&fd.downloadedBytes)
-			download.DownloadedBytes = downloaded
-			download.Progress = float64(downloaded) / float64(fd.totalBytes) * 100
-
-			// Calculate speed and ETA every second
-			now := time.Now()
-			if now.Sub(lastUpdate) >= time.Second {
-				elapsed := now.Sub(lastUpdate).Seconds()
-				if elapsed > 0 {
-					download.Speed = int64(float64(downloaded-lastBytes) / elapsed)
-
-					if download.Speed > 0 {
-						remainingBytes := fd.totalBytes - downloaded
-						download.ETA = remainingBytes / download.Speed
-					}
-				}
-				lastUpdate = now
-				lastBytes = downloaded
-
-				// Progress log every 5 seconds to logs
-				if int(now.Sub(startTime).Seconds())%5 == 0 {
-					progress := float64(receivedSegments) / float64(totalSegments) * 100
-					fd.download.AddLog(fmt.Sprintf("Progress: %d/%d segments (%.1f%%) - %.2f MB/s",
-						receivedSegments, totalSegments, progress, float64(download.Speed)/(1024*1024)))
-				}
+			if result.FileIndex == mainFile {
+				contiguous := assembler.ContiguousBytes()
+				download.update(func() { download.ContiguousBytes = contiguous })
 			}
+
+			// Update progress
+			download.setProgress(atomic.LoadInt64(&fd.downloadedBytes), fd.totalBytes)
 		}
 	}
 
 	// Finalize all files
 	fd.download.AddLog("Finalizing files...")
 	downloadedFiles := []string{}
+	writersMu.Lock()
 	for fileIdx, assembler := range fileWriters {
 		if err := assembler.Close(); err != nil {
 			fd.download.AddLog(fmt.Sprintf("ERROR: Failed to finalize file %d: %v", fileIdx, err))
@@ -375,6 +517,7 @@ func (fd *FastDownloader) Download(download *Download, downloadDir string) error
 			downloadedFiles = append(downloadedFiles, assembler.filepath)
 		}
 	}
+	writersMu.Unlock()
 
 	if failedSegments > 0 {
 		fd.download.AddLog(fmt.Sprintf("WARNING: %d segments failed to download", failedSegments))
@@ -383,12 +526,48 @@ func (fd *FastDownloader) Download(download *Download, downloadDir string) error
 
 	totalTime := time.Since(startTime).Seconds()
 	avgSpeed := float64(fd.totalBytes) / totalTime / (1024 * 1024)
-	fd.download.AddLog(fmt.Sprintf("Downloaded %d segments in %.1fs (avg %.2f MB/s)",
-		totalSegments, totalTime, avgSpeed))
+	fd.download.AddLog(fmt.Sprintf("Downloaded %d segments in %.1fs (avg %.2f MB/s), %s",
+		totalSegments, totalTime, avgSpeed, fd.articleSummary()))
 
 	return nil
 }
 
+// recordArticle counts an article request for the download and its server
+func (fd *FastDownloader) recordArticle(outcome articleOutcome, bytes int64, took time.Duration) {
+	fd.articles.record(outcome, bytes, took)
+	fd.stats.record(outcome, bytes, took)
+}
+
+// articleSummary describes how many of the articles of the download the
+// server had
+func (fd *FastDownloader) articleSummary() string {
+	name := fd.server.Name
+	if name == "" {
+		name = fd.server.Host
+	}
+	return fmt.Sprintf("%s: %.1f%% articles found", name, fd.articles.counts().SuccessRate)
+}
+
+// outputFilename returns the name a file of an NZB is written to, its name
+// made safe, or one made up from the download name when nothing of it is left
+func outputFilename(download *Download, fileIdx int, file *NZBFile) string {
+	if filename := safeFilename(CleanFilename(file.Filename())); filename != "" {
+		return filename
+	}
+	return fmt.Sprintf("%s-part%d.bin", safeDownloadName(download, "download"), fileIdx+1)
+}
+
+// safeDownloadName returns the download name made safe to use as a file
+// name, or fallback when nothing of it is left
+func safeDownloadName(download *Download, fallback string) string {
+	if download != nil {
+		if name := safeFilename(download.Name); name != "" {
+			return name
+		}
+	}
+	return fallback
+}
+
 // parseVolumeFromFilename attempts to extract volume number from filename
 // Supports patterns like: .part01.rar, .part001.rar, .r01, .001.rar
 // Returns 0-based index (0 for first volume), or -1 if not found
@@ -554,20 +733,39 @@ func readVint(data []byte) (uint64, int) {
 // PostProcess handles post-download processing like file detection and extraction
 // This is called separately after download completes to allow next download to start
 func (fd *FastDownloader) PostProcess(downloadDir string) error {
-	// Get list of downloaded files
+	files, err := listDownloadFiles(downloadDir)
+	if err != nil {
+		return err
+	}
+
+	// Obfuscated files get their real names back before anything relies on them
+	renames := &renameLog{}
+	files = fd.deobfuscate(files, downloadDir, renames)
+	defer renames.save(downloadDir)
+
+	files = fd.repair(files, downloadDir)
+
+	if err := fd.postProcess(files, downloadDir); err != nil {
+		return err
+	}
+	fd.nameObfuscatedMedia(downloadDir, renames)
+	return nil
+}
+
+// listDownloadFiles lists the downloaded files of a download directory
+func listDownloadFiles(downloadDir string) ([]string, error) {
 	entries, err := os.ReadDir(downloadDir)
 	if err != nil {
-		return fmt.Errorf("failed to read download directory: %v", err)
+		return nil, fmt.Errorf("failed to read download directory: %v", err)
 	}
 
 	files := make([]string, 0)
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && entry.Name() != downloadLogName {
 			files = append(files, filepath.Join(downloadDir, entry.Name()))
 		}
 	}
-
-	return fd.postProcess(files, downloadDir)
+	return files, nil
 }
 
 // postProcess handles post-download processing like file detection and extraction
@@ -642,18 +840,18 @@ func (fd *FastDownloader) postProcess(files []string, downloadDir string) error
 	if len(rarFiles) > 1 {
 		fd.download.AddLog(fmt.Sprintf("Found %d RAR volumes, renaming for extraction...", len(rarFiles)))
 
-		// Determine base name from download name or first file
-		baseName := "archive"
-		if fd.download != nil && fd.download.Name != "" {
-			baseName = fd.download.Name
-		}
+		// Determine base name from download name
+		baseName := safeDownloadName(fd.download, "archive")
 
 		// Rename files to proper RAR volume naming (.part01.rar, .part02.rar, etc.)
 		renamedRarFiles := []string{}
 		renameFailed := false
 		for i, file := range rarFiles {
-			newName := filepath.Join(downloadDir, fmt.Sprintf("%s.part%02d.rar", baseName, i+1))
-			if err := os.Rename(file, newName); err != nil {
+			newName, err := safeJoin(downloadDir, fmt.Sprintf("%s.part%02d.rar", baseName, i+1))
+			if err == nil {
+				err = os.Rename(file, newName)
+			}
+			if err != nil {
 				fd.download.AddLog(fmt.Sprintf("ERROR: Failed to rename RAR volume %d (%s -> %s): %v",
 					i+1, filepath.Base(file), filepath.Base(newName), err))
 				renameFailed = true
@@ -706,37 +904,8 @@ func (fd *FastDownloader) postProcess(files []string, downloadDir string) error
 		// Skip the file type detection for multi-volume RARs - we'll extract directly
 		fd.download.AddLog(fmt.Sprintf("Detected %s archive, extracting...", archiveType))
 
-		// Try extracting with common passwords
-		output, err := fd.extractRARWithPassword(firstArchive, downloadDir)
-		if err != nil {
-			fd.download.AddLog(fmt.Sprintf("Extraction failed: %v", err))
-
-			// Log full output for debugging (split into chunks if needed)
-			outputStr := string(output)
-			if len(outputStr) > 0 {
-				// Log in chunks of 500 characters
-				for i := 0; i < len(outputStr); i += 500 {
-					end := i + 500
-					if end > len(outputStr) {
-						end = len(outputStr)
-					}
-					fd.download.AddLog(fmt.Sprintf("unrar output [%d-%d]: %s", i, end, outputStr[i:end]))
-				}
-			}
-
-			if strings.Contains(outputStr, "previous volume") || strings.Contains(outputStr, "Unexpected end") {
-				fd.download.AddLog("Archive appears incomplete - missing volumes or damaged files")
-				return fmt.Errorf("archive extraction failed: incomplete archive - missing volumes or damaged files")
-			} else if strings.Contains(outputStr, "CRC failed") {
-				fd.download.AddLog("Archive is corrupted - CRC check failed")
-				return fmt.Errorf("archive extraction failed: CRC check failed - corrupted archive")
-			} else if strings.Contains(outputStr, "cannot find volume") {
-				fd.download.AddLog("Missing archive volumes - multipart archive is incomplete")
-				return fmt.Errorf("archive extraction failed: missing archive volumes")
-			}
-
-			// Generic extraction failure
-			return fmt.Errorf("archive extraction failed: %v", err)
+		if err := fd.extractArchive(archiveType, renamedRarFiles, downloadDir); err != nil {
+			return err
 		}
 
 		fd.download.AddLog("Extraction complete")
@@ -844,50 +1013,8 @@ func (fd *FastDownloader) postProcess(files []string, downloadDir string) error
 
 	fd.download.AddLog(fmt.Sprintf("Detected %s archive, extracting...", archiveType))
 
-	// Extract based on type
-	var output []byte
-	var err error
-	switch archiveType {
-	case "rar":
-		output, err = fd.extractRARWithPassword(firstArchive, downloadDir)
-	case "zip":
-		cmd := exec.Command("unzip", "-o", firstArchive, "-d", downloadDir)
-		output, err = cmd.CombinedOutput()
-	case "7z":
-		cmd := exec.Command("7z", "x", "-o"+downloadDir, "-y", firstArchive)
-		output, err = cmd.CombinedOutput()
-	default:
-		return nil
-	}
-	if err != nil {
-		fd.download.AddLog(fmt.Sprintf("Extraction failed: %v", err))
-
-		// Log full output for debugging (split into chunks if needed)
-		outputStr := string(output)
-		if len(outputStr) > 0 {
-			// Log in chunks of 500 characters
-			for i := 0; i < len(outputStr); i += 500 {
-				end := i + 500
-				if end > len(outputStr) {
-					end = len(outputStr)
-				}
-				fd.download.AddLog(fmt.Sprintf("unrar output [%d-%d]: %s", i, end, outputStr[i:end]))
-			}
-		}
-
-		if strings.Contains(outputStr, "previous volume") || strings.Contains(outputStr, "Unexpected end") {
-			fd.download.AddLog("Archive appears incomplete - missing volumes or damaged files")
-			return fmt.Errorf("archive extraction failed: incomplete archive - missing volumes or damaged files")
-		} else if strings.Contains(outputStr, "CRC failed") {
-			fd.download.AddLog("Archive is corrupted - CRC check failed")
-			return fmt.Errorf("archive extraction failed: CRC check failed - corrupted archive")
-		} else if strings.Contains(outputStr, "cannot find volume") {
-			fd.download.AddLog("Missing archive volumes - multipart archive is incomplete")
-			return fmt.Errorf("archive extraction failed: missing archive volumes")
-		}
-
-		// Generic extraction failure
-		return fmt.Errorf("archive extraction failed: %v", err)
+	if err := fd.extractArchive(archiveType, []string{firstArchive}, downloadDir); err != nil {
+		return err
 	}
 
 	fd.download.AddLog("Extraction complete")
@@ -910,9 +1037,9 @@ func (fd *FastDownloader) extractRARWithPassword(rarFile, destDir string) ([]byt
 	passwords := []string{}
 
 	// First priority: password from NZB file metadata
-	if fd.download.NZBData != nil && fd.download.NZBData.Password != "" {
-		passwords = append(passwords, fd.download.NZBData.Password)
-		fd.download.AddLog(fmt.Sprintf("Found password in NZB metadata: %s", fd.download.NZBData.Password))
+	if fd.download.Password != "" {
+		passwords = append(passwords, fd.download.Password)
+		fd.download.AddLog(fmt.Sprintf("Found password in NZB metadata: %s", fd.download.Password))
 	}
 
 	// Second priority: try without password
@@ -970,8 +1097,13 @@ func (fd *FastDownloader) extractRARWithPassword(rarFile, destDir string) ([]byt
 		}
 		args = append(args, rarFile, destDir+"/")
 
-		cmd := exec.Command("unrar", args...)
-		output, err := cmd.CombinedOutput()
+		// unrar prints its progress as it extracts
+		progress := newProgressWriter(fd.extractProgress)
+		cmd := exec.Command(fd.tools.Unrar, args...)
+		cmd.Stdout = progress
+		cmd.Stderr = progress
+		err := cmd.Run()
+		output := progress.Bytes()
 
 		lastOutput = output
 		lastErr = err
@@ -1019,6 +1151,10 @@ func (fd *FastDownloader) cleanupAuxiliaryFiles(downloadDir string) {
 
 		// Remove auxiliary files
 		shouldRemove := false
+		if filename == nzbFileName {
+			// Kept so the download can be retried
+			continue
+		}
 		if ext == ".nfo" || ext == ".sfv" || ext == ".nzb" || ext == ".txt" {
 			shouldRemove = true
 		} else if strings.Contains(strings.ToLower(filename), "sample") {
@@ -1081,6 +1217,7 @@ type FileAssembler struct {
 	totalSegments int
 	mu            sync.Mutex
 	buffer        map[int][]byte
+	flushed       int64 // Bytes written in order from the start of the file
 }
 
 // NewFileAssembler creates a new file assembler
@@ -1134,6 +1271,7 @@ func (fa *FileAssembler) flushSequential() error {
 			if _, err := fa.file.Write(data); err != nil {
 				return err
 			}
+			fa.flushed += int64(len(data))
 			delete(fa.buffer, i)
 		}
 	}
@@ -1141,6 +1279,14 @@ func (fa *FileAssembler) flushSequential() error {
 	return nil
 }
 
+// ContiguousBytes returns how many bytes from the start of the file are
+// written, with no segment missing before them
+func (fa *FileAssembler) ContiguousBytes() int64 {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	return fa.flushed
+}
+
 // Close finalizes the file
 func (fa *FileAssembler) Close() error {
 	fa.mu.Lock()
@@ -1157,3 +1303,18 @@ func (fa *FileAssembler) Close() error {
 
 	return fa.file.Close()
 }
+
+// Stop closes the file of an unfinished download. The segments written in
+// order are kept, while those buffered after a missing segment are dropped, as
+// they can't be written at the right offset.
+func (fa *FileAssembler) Stop() error {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+
+	err := fa.flushSequential()
+	fa.buffer = make(map[int][]byte)
+	if closeErr := fa.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Archives are extracted in Go where that's possible, so a bare container
+// without unrar, unzip or 7z can still unpack most downloads: zip archives
+// always, and RAR archives whose files are stored and unencrypted. Compressed
+// or encrypted RAR archives need unrar and 7z archives need 7z.
+
+// ExtractTools are the external extraction tools found on the PATH when the
+// plugin starts. A tool that wasn't found has an empty path.
+type ExtractTools struct {
+	Unrar    string `json:"unrar"`
+	Unzip    string `json:"unzip"`
+	SevenZip string `json:"7z"`
+	Par2     string `json:"par2"` // Verifies and repairs downloads before extraction
+}
+
+// builtinExtractors are the archives extracted without external tools
+var builtinExtractors = []string{"zip", "rar (stored, unencrypted)"}
+
+// detectExtractTools looks for the extraction tools on the PATH
+func detectExtractTools() ExtractTools {
+	return ExtractTools{
+		Unrar:    lookPath("unrar"),
+		Unzip:    lookPath("unzip"),
+		SevenZip: lookPath("7z", "7za", "7zz"),
+		Par2:     lookPath("par2", "par2cmdline"),
+	}
+}
+
+// lookPath returns the path of the first of names found on the PATH
+func lookPath(names ...string) string {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// missing lists the extraction tools that weren't found
+func (t ExtractTools) missing() []string {
+	var missing []string
+	for _, tool := range []struct{ name, path string }{
+		{"unrar", t.Unrar},
+		{"unzip", t.Unzip},
+		{"7z", t.SevenZip},
+	} {
+		if tool.path == "" {
+			missing = append(missing, tool.name)
+		}
+	}
+	return missing
+}
+
+// status describes the extraction tools for the status endpoint
+func (t ExtractTools) status() map[string]interface{} {
+	missing := t.missing()
+	if missing == nil {
+		missing = []string{}
+	}
+	return map[string]interface{}{
+		"tools":   t,
+		"missing": missing,
+		"builtin": builtinExtractors,
+	}
+}
+
+// missingToolError is the failure of an extraction that needs a tool that
+// isn't installed
+func missingToolError(tool, reason string) error {
+	return fmt.Errorf("archive extraction failed: %s needs %s, which is not installed", reason, tool)
+}
+
+// extractArchive extracts an archive of the given type from its volumes, in
+// order, into destDir, logging the extractor used. Whether it succeeds or
+// not, whatever it wrote outside destDir, or pointing outside it, is
+// quarantined.
+func (fd *FastDownloader) extractArchive(archiveType string, volumes []string, destDir string) error {
+	fd.download.setPhase(phaseExtracting)
+	output, err := fd.runExtractor(archiveType, volumes, destDir)
+	fd.quarantineEscapes(destDir, listedPaths(output, destDir))
+	return err
+}
+
+// runExtractor runs the extractor for an archive type, returning the output
+// of an external tool. The built-in extractors refuse entries outside
+// destDir. unrar and 7z strip absolute paths and ".." from entries unless
+// told otherwise (unrar -ep3, 7z -spf), which they never are; 7z runs at -bb1
+// so it lists what it writes.
+func (fd *FastDownloader) runExtractor(archiveType string, volumes []string, destDir string) ([]byte, error) {
+	switch archiveType {
+	case "zip":
+		fd.download.AddLog("Extracting with the built-in zip extractor")
+		if err := extractZip(volumes[0], destDir); err != nil {
+			fd.download.AddLog(fmt.Sprintf("Extraction failed: %v", err))
+			return nil, fmt.Errorf("archive extraction failed: %v", err)
+		}
+		return nil, nil
+	case "rar":
+		return fd.extractRAR(volumes, destDir)
+	case "7z":
+		if fd.tools.SevenZip == "" {
+			fd.download.AddLog("Cannot extract: 7z is not installed")
+			return nil, missingToolError("7z", "a 7z archive")
+		}
+		fd.download.AddLog(fmt.Sprintf("Extracting with 7z (%s)", fd.tools.SevenZip))
+		output, err := exec.Command(fd.tools.SevenZip, "x", "-o"+destDir, "-y", "-bb1", volumes[0]).CombinedOutput()
+		return output, fd.externalExtractError("7z", output, err)
+	}
+	return nil, nil
+}
+
+// extractRAR extracts a RAR archive with the built-in extractor, falling back
+// to unrar for archives it can't read
+func (fd *FastDownloader) extractRAR(volumes []string, destDir string) ([]byte, error) {
+	fd.download.AddLog("Extracting with the built-in RAR extractor")
+	_, err := extractStoredRAR(volumes, destDir)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, errRARUnsupported) {
+		fd.download.AddLog(fmt.Sprintf("Extraction failed: %v", err))
+		return nil, fmt.Errorf("archive extraction failed: %w", err)
+	}
+	fd.download.AddLog(fmt.Sprintf("Built-in RAR extractor can't extract this archive: %v", err))
+
+	if fd.tools.Unrar == "" {
+		fd.download.AddLog("Cannot extract: unrar is not installed")
+		return nil, missingToolError("unrar", "a compressed or encrypted RAR archive")
+	}
+	fd.download.AddLog(fmt.Sprintf("Extracting with unrar (%s)", fd.tools.Unrar))
+	output, err := fd.extractRARWithPassword(volumes[0], destDir)
+	return output, fd.externalExtractError("unrar", output, err)
+}
+
+// externalExtractError turns the failure of an external tool into an error,
+// telling incomplete archives from corrupted ones by its output
+func (fd *FastDownloader) externalExtractError(tool string, output []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	fd.download.AddLog(fmt.Sprintf("Extraction failed: %v", err))
+
+	// Log full output for debugging (split into chunks if needed)
+	outputStr := string(output)
+	for i := 0; i < len(outputStr); i += 500 {
+		end := i + 500
+		if end > len(outputStr) {
+			end = len(outputStr)
+		}
+		fd.download.AddLog(fmt.Sprintf("%s output [%d-%d]: %s", tool, i, end, outputStr[i:end]))
+	}
+
+	if strings.Contains(outputStr, "previous volume") || strings.Contains(outputStr, "Unexpected end") {
+		fd.download.AddLog("Archive appears incomplete - missing volumes or damaged files")
+		return fmt.Errorf("archive extraction failed: incomplete archive - missing volumes or damaged files")
+	} else if strings.Contains(outputStr, "CRC failed") {
+		fd.download.AddLog("Archive is corrupted - CRC check failed")
+		return fmt.Errorf("archive extraction failed: CRC check failed - corrupted archive")
+	} else if strings.Contains(outputStr, "cannot find volume") {
+		fd.download.AddLog("Missing archive volumes - multipart archive is incomplete")
+		return fmt.Errorf("archive extraction failed: missing archive volumes")
+	}
+
+	// Generic extraction failure
+	return fmt.Errorf("archive extraction failed: %v", err)
+}
+
+// extractZip extracts a zip archive into destDir
+func extractZip(archive, destDir string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		path, err := archiveEntryPath(destDir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Flags&0x1 != 0 {
+			return fmt.Errorf("%s is encrypted", f.Name)
+		}
+		if err := extractZipFile(f, path); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractZipFile writes one file of a zip archive, whose checksum is
+// verified as it is read
+func extractZipFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(path)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rar5File is a file, or the part of one, stored in a test RAR 5 volume
+type rar5File struct {
+	name                    string
+	data                    []byte
+	crc                     uint32 // CRC of the whole file, checked on its last part
+	method                  uint64
+	splitBefore, splitAfter bool
+}
+
+func putVint(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b = append(b, c|0x80)
+			continue
+		}
+		return append(b, c)
+	}
+}
+
+func rar5Block(headerType, flags uint64, fields []byte, dataSize int) []byte {
+	body := append(putVint(headerType), putVint(flags)...)
+	if flags&rar5HasData != 0 {
+		body = append(body, putVint(uint64(dataSize))...)
+	}
+	body = append(body, fields...)
+	size := putVint(uint64(len(body)))
+	block := make([]byte, 4)
+	binary.LittleEndian.PutUint32(block, crc32.ChecksumIEEE(append(append([]byte{}, size...), body...)))
+	return append(append(block, size...), body...)
+}
+
+// rar5Volume builds a RAR 5 volume holding files, stored unless they set a
+// compression method
+func rar5Volume(files []rar5File, moreVolumes bool) []byte {
+	out := append([]byte{}, rar5Signature...)
+	archiveFlags := uint64(0)
+	if moreVolumes {
+		archiveFlags = 1
+	}
+	out = append(out, rar5Block(1, 0, putVint(archiveFlags), 0)...)
+
+	for _, f := range files {
+		fields := putVint(rar5FileHasCRC)
+		fields = append(fields, putVint(uint64(len(f.data)))...)
+		fields = append(fields, putVint(0)...)
+		crc := make([]byte, 4)
+		binary.LittleEndian.PutUint32(crc, f.crc)
+		fields = append(fields, crc...)
+		fields = append(fields, putVint(f.method<<7)...)
+		fields = append(fields, putVint(1)...)
+		fields = append(fields, putVint(uint64(len(f.name)))...)
+		fields = append(fields, f.name...)
+
+		flags := uint64(rar5HasData)
+		if f.splitBefore {
+			flags |= rar5SplitBefore
+		}
+		if f.splitAfter {
+			flags |= rar5SplitAfter
+		}
+		out = append(out, rar5Block(rar5HeaderFile, flags, fields, len(f.data))...)
+		out = append(out, f.data...)
+	}
+
+	endFlags := uint64(0)
+	if moreVolumes {
+		endFlags = 1
+	}
+	return append(out, rar5Block(rar5HeaderEnd, 0, putVint(endFlags), 0)...)
+}
+
+// rar4Archive builds a single RAR 4 volume holding stored files
+func rar4Archive(files map[string][]byte) []byte {
+	out := append([]byte{}, rar4Signature...)
+	main := []byte{0, 0, rar4BlockMain, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(main[0:2], uint16(crc32.ChecksumIEEE(main[2:])))
+	out = append(out, main...)
+
+	le := binary.LittleEndian
+	for name, data := range files {
+		header := make([]byte, 32+len(name))
+		header[2] = rar4BlockFile
+		le.PutUint16(header[3:5], rar4LongBlock)
+		le.PutUint16(header[5:7], uint16(len(header)))
+		le.PutUint32(header[7:11], uint32(len(data)))
+		le.PutUint32(header[11:15], uint32(len(data)))
+		header[15] = 3
+		le.PutUint32(header[16:20], crc32.ChecksumIEEE(data))
+		header[24] = 29
+		header[25] = rar4MethodStore
+		le.PutUint16(header[26:28], uint16(len(name)))
+		copy(header[32:], name)
+		le.PutUint16(header[0:2], uint16(crc32.ChecksumIEEE(header[2:])))
+		out = append(out, header...)
+		out = append(out, data...)
+	}
+
+	end := []byte{0, 0, rar4BlockEnd, 0, 0x40, 7, 0}
+	binary.LittleEndian.PutUint16(end[0:2], uint16(crc32.ChecksumIEEE(end[2:])))
+	return append(out, end...)
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) string {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractStoredRARVolumes(t *testing.T) {
+	dir := t.TempDir()
+	video := bytes.Repeat([]byte("video data "), 1000)
+	nfo := []byte("release notes")
+	crc := crc32.ChecksumIEEE(video)
+
+	volumes := []string{
+		writeTestFile(t, filepath.Join(dir, "a.part01.rar"), rar5Volume([]rar5File{
+			{name: "Movie/Movie.mkv", data: video[:4000], crc: crc32.ChecksumIEEE(video[:4000]), splitAfter: true},
+		}, true)),
+		writeTestFile(t, filepath.Join(dir, "a.part02.rar"), rar5Volume([]rar5File{
+			{name: "Movie/Movie.mkv", data: video[4000:], crc: crc, splitBefore: true},
+			{name: "Movie.nfo", data: nfo, crc: crc32.ChecksumIEEE(nfo)},
+		}, false)),
+	}
+
+	extracted, err := extractStoredRAR(volumes, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != 2 {
+		t.Fatalf("extracted %v", extracted)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "Movie", "Movie.mkv")); !bytes.Equal(got, video) {
+		t.Errorf("video extracted as %d bytes, want %d", len(got), len(video))
+	}
+
+	// A missing last volume leaves the split file incomplete
+	if _, err := extractStoredRAR(volumes[:1], t.TempDir()); !errors.Is(err, errRARIncomplete) {
+		t.Errorf("missing volume: err = %v, want incomplete", err)
+	}
+
+	// A wrong checksum is reported as corruption
+	bad := writeTestFile(t, filepath.Join(dir, "bad.rar"), rar5Volume([]rar5File{
+		{name: "bad.mkv", data: video, crc: crc + 1},
+	}, false))
+	if _, err := extractStoredRAR([]string{bad}, t.TempDir()); !errors.Is(err, errRARCRC) {
+		t.Errorf("bad CRC: err = %v, want CRC failure", err)
+	}
+}
+
+func TestExtractStoredRAR4(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, filepath.Join(dir, "a.rar"), rar4Archive(map[string][]byte{
+		`Sub\Episode.mkv`: []byte("episode"),
+	}))
+
+	if _, err := extractStoredRAR([]string{archive}, dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "Sub", "Episode.mkv")); err != nil || string(got) != "episode" {
+		t.Errorf("extracted %q, %v", got, err)
+	}
+}
+
+func TestExtractRARRefusesUnsafePaths(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("x")
+	archive := writeTestFile(t, filepath.Join(dir, "a.rar"), rar5Volume([]rar5File{
+		{name: "../escaped.mkv", data: data, crc: crc32.ChecksumIEEE(data)},
+	}, false))
+
+	if _, err := extractStoredRAR([]string{archive}, filepath.Join(dir, "out")); err == nil {
+		t.Error("entry outside the destination was extracted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.mkv")); err == nil {
+		t.Error("escaped.mkv was written outside the destination")
+	}
+}
+
+// Without any external tools, zip and stored RAR downloads are extracted in
+// Go and compressed RAR archives fail naming the missing unrar
+func TestPostProcessWithoutTools(t *testing.T) {
+	t.Run("zip", func(t *testing.T) {
+		dir := t.TempDir()
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create("Show.S01E01.mkv")
+		w.Write([]byte("episode"))
+		zw.Close()
+		writeTestFile(t, filepath.Join(dir, "download.zip"), buf.Bytes())
+
+		fd := newPostProcessor(&Download{Name: "Show.S01E01"})
+		if err := fd.PostProcess(dir); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := os.ReadFile(filepath.Join(dir, "Show.S01E01.mkv")); err != nil || string(got) != "episode" {
+			t.Errorf("extracted %q, %v", got, err)
+		}
+		if !logged(fd.download, "built-in zip extractor") {
+			t.Errorf("extractor not logged: %v", fd.download.Logs)
+		}
+	})
+
+	t.Run("stored rar", func(t *testing.T) {
+		dir := t.TempDir()
+		data := []byte("movie")
+		writeTestFile(t, filepath.Join(dir, "movie.rar"), rar5Volume([]rar5File{
+			{name: "Movie.mkv", data: data, crc: crc32.ChecksumIEEE(data)},
+		}, false))
+
+		fd := newPostProcessor(&Download{Name: "Movie"})
+		if err := fd.PostProcess(dir); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := os.ReadFile(filepath.Join(dir, "Movie.mkv")); err != nil || string(got) != "movie" {
+			t.Errorf("extracted %q, %v", got, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "movie.rar")); !os.IsNotExist(err) {
+			t.Error("archive not removed after extraction")
+		}
+	})
+
+	t.Run("compressed rar", func(t *testing.T) {
+		dir := t.TempDir()
+		data := []byte("movie")
+		writeTestFile(t, filepath.Join(dir, "movie.rar"), rar5Volume([]rar5File{
+			{name: "Movie.mkv", data: data, crc: crc32.ChecksumIEEE(data), method: 3},
+		}, false))
+
+		fd := newPostProcessor(&Download{Name: "Movie"})
+		err := fd.PostProcess(dir)
+		if err == nil || !strings.Contains(err.Error(), "needs unrar, which is not installed") {
+			t.Errorf("err = %v, want missing unrar", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Movie.mkv")); err == nil {
+			t.Error("compressed file written by the built-in extractor")
+		}
+	})
+}
+
+func TestExtractToolsStatus(t *testing.T) {
+	tools := ExtractTools{Unrar: "/usr/bin/unrar"}
+	missing := tools.missing()
+	if strings.Join(missing, ",") != "unzip,7z" {
+		t.Errorf("missing = %v", missing)
+	}
+}
+
+func logged(d *Download, text string) bool {
+	for _, line := range d.Logs {
+		if strings.Contains(line, text) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/blakestevenson/nimbus v0.0.0
 	github.com/hashicorp/go-plugin v1.6.2
+	google.golang.org/grpc v1.68.1
 )
 
 require (
@@ -26,7 +27,6 @@ require (
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
-	google.golang.org/grpc v1.68.1 // indirect
 	google.golang.org/protobuf v1.35.1 // indirect
 )
 
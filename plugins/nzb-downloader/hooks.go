@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Script hooks are executables run at points of a download's pipeline. Each
+// gets the download in its environment, and its output goes to the download
+// log. A hook that exits non-zero only logs a warning, unless it is set to
+// fail the download.
+
+// Events a hook can run on
+const (
+	HookAfterDownload = "after_download" // Every article is downloaded
+	HookAfterExtract  = "after_extract"  // Archives are extracted and repaired
+	HookAfterImport   = "after_import"   // The files are imported to the library
+	HookOnFailure     = "on_failure"     // The download failed
+)
+
+var hookEvents = []string{HookAfterDownload, HookAfterExtract, HookAfterImport, HookOnFailure}
+
+const (
+	defaultHookTimeout = 60 * time.Second
+	maxHookTimeout     = time.Hour
+
+	// maxHookLogLines is how many lines of a hook's output go to the download
+	// log, which only keeps its last 50 lines
+	maxHookLogLines = 20
+
+	// maxHookOutput is how many bytes of a hook's output are kept
+	maxHookOutput = 64 << 10
+)
+
+// ScriptHook is an executable run on events of a download
+type ScriptHook struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Path           string   `json:"path"`   // Absolute path of the executable
+	Events         []string `json:"events"` // Events the hook runs on
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	FailOnError    bool     `json:"fail_on_error"` // A non-zero exit fails the download
+	Enabled        bool     `json:"enabled"`
+}
+
+// timeout returns how long the hook may run
+func (h ScriptHook) timeout() time.Duration {
+	if h.TimeoutSeconds <= 0 {
+		return defaultHookTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+// runsOn reports whether the hook runs on an event
+func (h ScriptHook) runsOn(event string) bool {
+	return h.Enabled && slices.Contains(h.Events, event)
+}
+
+// validate checks a hook and fills in its defaults
+func (h *ScriptHook) validate() error {
+	if h.Path == "" || !filepath.IsAbs(h.Path) {
+		return fmt.Errorf("hook path must be an absolute path")
+	}
+	info, err := os.Stat(h.Path)
+	if err != nil {
+		return fmt.Errorf("hook path %s: %w", h.Path, err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("hook path %s is not an executable", h.Path)
+	}
+	if len(h.Events) == 0 {
+		return fmt.Errorf("hook needs at least one event")
+	}
+	for _, event := range h.Events {
+		if !slices.Contains(hookEvents, event) {
+			return fmt.Errorf("unknown hook event %q, expected one of %s", event, strings.Join(hookEvents, ", "))
+		}
+	}
+	if h.TimeoutSeconds < 0 || time.Duration(h.TimeoutSeconds)*time.Second > maxHookTimeout {
+		return fmt.Errorf("hook timeout must be between 0 and %d seconds", int(maxHookTimeout.Seconds()))
+	}
+	if h.ID == "" {
+		h.ID = generateID()
+	}
+	if h.Name == "" {
+		h.Name = filepath.Base(h.Path)
+	}
+	return nil
+}
+
+// hookEnv is what a hook is told about the download it runs for
+type hookEnv struct {
+	DownloadID string
+	Name       string
+	Path       string
+	MediaID    string
+	Event      string
+	Error      string // Why the download failed, for on_failure
+}
+
+// hookPassEnv are the variables of the plugin's environment a hook gets. The
+// rest, which holds the host's secrets and tokens, is not passed on.
+var hookPassEnv = []string{"PATH", "HOME", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// environ returns the environment of a hook: the download, and the few
+// variables of the plugin's own environment a script needs to run
+func (e hookEnv) environ() []string {
+	var env []string
+	for _, key := range hookPassEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	env = append(env,
+		"NIMBUS_DOWNLOAD_ID="+e.DownloadID,
+		"NIMBUS_NAME="+e.Name,
+		"NIMBUS_PATH="+e.Path,
+		"NIMBUS_MEDIA_ID="+e.MediaID,
+		"NIMBUS_EVENT="+e.Event,
+	)
+	if e.Error != "" {
+		env = append(env, "NIMBUS_ERROR="+e.Error)
+	}
+	return env
+}
+
+// hookResult is the outcome of running a hook
+type hookResult struct {
+	ExitCode   int      `json:"exit_code"`
+	Output     []string `json:"output"`
+	TimedOut   bool     `json:"timed_out,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// failed reports whether the hook did not run or exited non-zero
+func (r hookResult) failed() bool {
+	return r.ExitCode != 0 || r.Error != ""
+}
+
+// describe says how a failed hook failed
+func (r hookResult) describe() string {
+	switch {
+	case r.TimedOut:
+		return r.Error
+	case r.Error != "" && r.ExitCode == 0:
+		return r.Error
+	default:
+		return fmt.Sprintf("exit code %d", r.ExitCode)
+	}
+}
+
+// cappedBuffer keeps the first maxHookOutput bytes written to it
+type cappedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := maxHookOutput - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// runHook runs a hook and waits for it to exit or time out. The hook runs in
+// the download directory when it exists.
+func runHook(ctx context.Context, hook ScriptHook, env hookEnv) hookResult {
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	cmd.Env = env.environ()
+	if info, err := os.Stat(env.Path); err == nil && info.IsDir() {
+		cmd.Dir = env.Path
+	}
+	var output cappedBuffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Children left holding the output open must not keep the hook running
+	// past its timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	result := hookResult{DurationMs: time.Since(start).Milliseconds()}
+
+	scanner := bufio.NewScanner(&output.Buffer)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			result.Output = append(result.Output, line)
+		}
+	}
+	if output.truncated {
+		result.Output = append(result.Output, "(output truncated)")
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("timed out after %s", hook.timeout())
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runHooksFor runs the hooks for an event of a download one after another,
+// logging their output to the download. It returns an error once a hook set
+// to fail the download fails; other failures are only logged.
+//
+// Hooks may run for a long time, so they must never be run under the manager
+// lock.
+func runHooksFor(ctx context.Context, download *Download, hooks []ScriptHook, event, errorMessage string) error {
+	snapshot := download.snapshot()
+	env := hookEnv{
+		DownloadID: snapshot.ID,
+		Name:       snapshot.Name,
+		Path:       downloadDir(snapshot),
+		Event:      event,
+		Error:      errorMessage,
+	}
+	if mediaID, ok := snapshot.Metadata["media_id"]; ok && mediaID != nil {
+		env.MediaID = fmt.Sprint(mediaID)
+	}
+
+	for _, hook := range hooks {
+		if !hook.runsOn(event) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		download.AddLog(fmt.Sprintf("Running %s hook %s", event, hook.Name))
+		result := runHook(ctx, hook, env)
+		lines := result.Output
+		if len(lines) > maxHookLogLines {
+			download.AddLog(fmt.Sprintf("  (%d earlier lines of output not logged)", len(lines)-maxHookLogLines))
+			lines = lines[len(lines)-maxHookLogLines:]
+		}
+		for _, line := range lines {
+			download.AddLog("  " + line)
+		}
+
+		if !result.failed() {
+			continue
+		}
+		// A hook killed because the download was paused or deleted didn't fail
+		if ctx.Err() != nil && !result.TimedOut {
+			return nil
+		}
+		if hook.FailOnError {
+			return fmt.Errorf("%s hook %s failed: %s", event, hook.Name, result.describe())
+		}
+		download.AddLog(fmt.Sprintf("Warning: %s hook %s failed: %s", event, hook.Name, result.describe()))
+	}
+	return nil
+}
+
+// runHooks runs the configured hooks for an event of a download
+func (p *NZBDownloaderPlugin) runHooks(ctx context.Context, download *Download, event string) error {
+	sdk, err := p.getSDK()
+	if err != nil {
+		return nil
+	}
+	hooks, err := getHooks(ctx, sdk)
+	if err != nil {
+		download.AddLog(fmt.Sprintf("Warning: could not load hooks: %v", err))
+		return nil
+	}
+	return runHooksFor(ctx, download, hooks, event, "")
+}
+
+// failDownload marks a download failed and runs its on_failure hooks in the
+// background, so a slow hook doesn't hold up the queue
+func (p *NZBDownloaderPlugin) failDownload(download *Download, message string) {
+	download.fail(message)
+
+	sdk, err := p.getSDK()
+	if err != nil {
+		return
+	}
+	download.workers.Add(1)
+	go func() {
+		defer download.workers.Done()
+		ctx := context.Background()
+		hooks, err := getHooks(ctx, sdk)
+		if err != nil {
+			download.AddLog(fmt.Sprintf("Warning: could not load hooks: %v", err))
+			return
+		}
+		runHooksFor(ctx, download, hooks, HookOnFailure, message)
+	}()
+}
+
+// getHooks loads the configured hooks
+func getHooks(ctx context.Context, sdk plugins.SDKInterface) ([]ScriptHook, error) {
+	val, err := sdk.ConfigGet(ctx, configHooks)
+	if err != nil || val == nil {
+		return []ScriptHook{}, nil
+	}
+	var hooks []ScriptHook
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &hooks); err != nil {
+		return nil, fmt.Errorf("invalid stored hooks: %w", err)
+	}
+	return hooks, nil
+}
+
+func (p *NZBDownloaderPlugin) handleListHooks(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+	hooks, err := getHooks(ctx, req.SDK)
+	if err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"hooks": hooks, "events": hookEvents})
+}
+
+// handleSetHooks replaces the configured hooks
+func (p *NZBDownloaderPlugin) handleSetHooks(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+
+	var input struct {
+		Hooks []ScriptHook `json:"hooks"`
+	}
+	if err := json.Unmarshal(req.Body, &input); err != nil || input.Hooks == nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "hooks is required"})
+	}
+	seen := make(map[string]bool)
+	for i := range input.Hooks {
+		if err := input.Hooks[i].validate(); err != nil {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if seen[input.Hooks[i].ID] {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("duplicate hook ID %s", input.Hooks[i].ID)})
+		}
+		seen[input.Hooks[i].ID] = true
+	}
+
+	if err := req.SDK.ConfigSet(ctx, configHooks, input.Hooks); err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"hooks": input.Hooks})
+}
+
+// handleTestHook runs a hook once with sample values, without a download. The
+// hook is given in the body, so it can be tried before it is saved.
+func (p *NZBDownloaderPlugin) handleTestHook(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	var input struct {
+		ScriptHook
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(req.Body, &input); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	}
+	if input.Event == "" {
+		input.Event = HookAfterDownload
+	}
+	hook := input.ScriptHook
+	hook.Events = []string{input.Event}
+	if err := hook.validate(); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	dir, err := os.MkdirTemp("", "nimbus-hook-test-")
+	if err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer os.RemoveAll(dir)
+
+	env := hookEnv{
+		DownloadID: "test",
+		Name:       "Sample.Release.2024.1080p.WEB-DL",
+		Path:       dir,
+		MediaID:    "0",
+		Event:      input.Event,
+	}
+	if input.Event == HookOnFailure {
+		env.Error = "Sample failure"
+	}
+	result := runHook(ctx, hook, env)
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"success": !result.failed(),
+		"event":   input.Event,
+		"result":  result,
+	})
+}
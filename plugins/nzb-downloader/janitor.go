@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const (
+	// defaultJanitorRetentionDays is how long an unreferenced download
+	// directory is left alone before the janitor removes it
+	defaultJanitorRetentionDays = 7
+
+	// janitorInterval is how often the janitor runs
+	janitorInterval = 24 * time.Hour
+
+	// janitorCheckInterval is how often the janitor checks whether it is due,
+	// so it runs soon after startup once the download state is loaded
+	janitorCheckInterval = time.Minute
+
+	// trashDirName is the folder in the download directory removed download
+	// directories are moved to when a trash retention is set
+	trashDirName = ".trash"
+)
+
+// janitorSettings controls what the janitor removes
+type janitorSettings struct {
+	downloadDir   string
+	retentionDays int // Unreferenced directories untouched this long are removed
+	trashDays     int // 0 deletes straight away, otherwise moves to the trash for this long
+}
+
+// janitorCandidate is a directory the janitor removes
+type janitorCandidate struct {
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	SizeBytes    int64     `json:"size_bytes"`
+	LastModified time.Time `json:"last_modified"`
+	Trash        bool      `json:"trash,omitempty"` // An expired directory in the trash
+}
+
+// janitorReport is the outcome of a janitor run or preview
+type janitorReport struct {
+	DryRun        bool               `json:"dry_run"`
+	DownloadDir   string             `json:"download_dir"`
+	RetentionDays int                `json:"retention_days"`
+	TrashDays     int                `json:"trash_days"`
+	Removed       []janitorCandidate `json:"removed"`
+	Errors        []string           `json:"errors,omitempty"`
+	FreedBytes    int64              `json:"freed_bytes"` // Of the directories deleted
+	RanAt         time.Time          `json:"ran_at"`
+}
+
+// janitor remembers when cleanup ran, so only one runs at a time
+type janitor struct {
+	mu      sync.Mutex
+	lastRun time.Time
+}
+
+// loadJanitorSettings reads the janitor settings from the config store
+func loadJanitorSettings(ctx context.Context, sdk plugins.SDKInterface) janitorSettings {
+	settings := janitorSettings{downloadDir: "/tmp/nzb-downloads", retentionDays: defaultJanitorRetentionDays}
+	if val, err := sdk.ConfigGet(ctx, configDownloadDir); err == nil {
+		if dir, ok := val.(string); ok && dir != "" {
+			settings.downloadDir = dir
+		}
+	}
+	if val, err := sdk.ConfigGet(ctx, configJanitorRetentionDays); err == nil {
+		if days, ok := parseNumber(val); ok && days >= 1 {
+			settings.retentionDays = int(days)
+		}
+	}
+	if val, err := sdk.ConfigGet(ctx, configJanitorTrashDays); err == nil {
+		if days, ok := parseNumber(val); ok && days >= 0 {
+			settings.trashDays = int(days)
+		}
+	}
+	return settings
+}
+
+// runJanitor cleans up the download directory once the download state is
+// loaded after startup, and every janitorInterval after that
+func (p *NZBDownloaderPlugin) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(janitorCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Before the state is loaded every directory looks unreferenced
+		sdk, err := p.getSDK()
+		if err != nil || !p.persist.loaded.Load() {
+			continue
+		}
+		p.janitor.mu.Lock()
+		due := time.Since(p.janitor.lastRun) >= janitorInterval
+		p.janitor.mu.Unlock()
+		if due {
+			p.cleanDownloadDir(ctx, loadJanitorSettings(ctx, sdk), false)
+		}
+	}
+}
+
+// referencedDirs returns the download IDs and directories of every download in
+// the queue and history. Their directories are never removed, including those
+// of downloads that are processing or waiting for a manual import.
+func (p *NZBDownloaderPlugin) referencedDirs() (ids map[string]bool, dirs map[string]bool) {
+	p.downloadManager.mu.RLock()
+	defer p.downloadManager.mu.RUnlock()
+
+	ids = make(map[string]bool, len(p.downloadManager.downloads))
+	dirs = make(map[string]bool, len(p.downloadManager.downloads))
+	for id, dl := range p.downloadManager.downloads {
+		ids[id] = true
+		dirs[filepath.Clean(downloadDir(dl))] = true
+	}
+	return ids, dirs
+}
+
+// cleanDownloadDir removes the subdirectories of the download directory no
+// download references that weren't modified within the retention period, and
+// empties expired directories out of the trash. A dry run only reports them.
+func (p *NZBDownloaderPlugin) cleanDownloadDir(ctx context.Context, settings janitorSettings, dryRun bool) *janitorReport {
+	p.janitor.mu.Lock()
+	defer p.janitor.mu.Unlock()
+
+	report := &janitorReport{
+		DryRun:        dryRun,
+		DownloadDir:   settings.downloadDir,
+		RetentionDays: settings.retentionDays,
+		TrashDays:     settings.trashDays,
+		Removed:       []janitorCandidate{},
+		RanAt:         time.Now(),
+	}
+	if !dryRun {
+		p.janitor.lastRun = report.RanAt
+	}
+
+	ids, dirs := p.referencedDirs()
+	cutoff := report.RanAt.AddDate(0, 0, -settings.retentionDays)
+	candidates, err := staleDirs(settings.downloadDir, cutoff, func(name, path string) bool {
+		return ids[name] || dirs[path]
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if settings.trashDays > 0 {
+		trashCutoff := report.RanAt.AddDate(0, 0, -settings.trashDays)
+		expired, err := staleDirs(filepath.Join(settings.downloadDir, trashDirName), trashCutoff, nil)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		for i := range expired {
+			expired[i].Trash = true
+		}
+		candidates = append(candidates, expired...)
+	}
+
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		if !dryRun {
+			action, err := removeStaleDir(settings, candidate)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Janitor failed to remove %s: %v\n", candidate.Path, err)
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", candidate.Name, err))
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Janitor %s %s (%s, unchanged since %s)\n",
+				action, candidate.Path, formatBytes(uint64(candidate.SizeBytes)), candidate.LastModified.Format(time.RFC3339))
+		}
+		report.Removed = append(report.Removed, candidate)
+		if candidate.Trash || settings.trashDays == 0 {
+			// Directories moved to the trash still take up space
+			report.FreedBytes += candidate.SizeBytes
+		}
+	}
+
+	if !dryRun {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Janitor removed %d directories from %s, %s freed, %d errors\n",
+			len(report.Removed), settings.downloadDir, formatBytes(uint64(report.FreedBytes)), len(report.Errors))
+	}
+	return report
+}
+
+// staleDirs returns the subdirectories of dir that nothing under was modified
+// in after cutoff, skipping hidden ones and those keep reports true for
+func staleDirs(dir string, cutoff time.Time, keep func(name, path string) bool) ([]janitorCandidate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []janitorCandidate
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if keep != nil && keep(entry.Name(), path) {
+			continue
+		}
+		modified, size, err := dirUsage(path)
+		if err != nil || modified.After(cutoff) {
+			continue
+		}
+		stale = append(stale, janitorCandidate{Name: entry.Name(), Path: path, SizeBytes: size, LastModified: modified})
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].LastModified.Before(stale[j].LastModified) })
+	return stale, nil
+}
+
+// dirUsage returns the newest modification time of a directory or anything
+// in it, and the total size of its files
+func dirUsage(dir string) (time.Time, int64, error) {
+	var newest time.Time
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		if d.Type().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return newest, size, err
+}
+
+// removeStaleDir deletes a directory, or moves it to the trash when a trash
+// retention is set, and returns what it did
+func removeStaleDir(settings janitorSettings, candidate janitorCandidate) (string, error) {
+	if candidate.Trash || settings.trashDays == 0 {
+		return "deleted", os.RemoveAll(candidate.Path)
+	}
+
+	trashDir := filepath.Join(settings.downloadDir, trashDirName)
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(trashDir, candidate.Name)
+	if _, err := os.Stat(target); err == nil {
+		target = fmt.Sprintf("%s-%d", target, time.Now().Unix())
+	}
+	if err := os.Rename(candidate.Path, target); err != nil {
+		return "", err
+	}
+	// The trash retention counts from when the directory was trashed
+	now := time.Now()
+	os.Chtimes(target, now, now)
+	return "moved to trash", nil
+}
+
+// handleJanitorPreview lists what the janitor would remove, without removing it
+// GET /api/plugins/nzb-downloader/janitor/preview?retention_days=7
+func (p *NZBDownloaderPlugin) handleJanitorPreview(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	return p.handleJanitor(ctx, req, true)
+}
+
+// handleJanitorRun runs the janitor now
+// POST /api/plugins/nzb-downloader/janitor/run
+func (p *NZBDownloaderPlugin) handleJanitorRun(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	return p.handleJanitor(ctx, req, false)
+}
+
+func (p *NZBDownloaderPlugin) handleJanitor(ctx context.Context, req *plugins.PluginHTTPRequest, dryRun bool) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+	if !p.persist.loaded.Load() {
+		return jsonResponse(http.StatusServiceUnavailable, map[string]string{"error": "Download state is still loading"})
+	}
+
+	// A retention can be tried out before it is saved
+	settings := loadJanitorSettings(ctx, req.SDK)
+	if days := req.Query["retention_days"]; len(days) > 0 {
+		n, ok := parseNumber(days[0])
+		if !ok || n < 1 {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": "retention_days must be at least 1"})
+		}
+		settings.retentionDays = int(n)
+	}
+
+	return jsonResponse(http.StatusOK, p.cleanDownloadDir(ctx, settings, dryRun))
+}
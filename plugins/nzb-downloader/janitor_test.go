@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanDownloadDir(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().AddDate(0, 0, -10)
+	mkdir := func(name string, modified time.Time) string {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, "file.bin")
+		if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file, modified, modified)
+		os.Chtimes(dir, modified, modified)
+		return dir
+	}
+
+	mkdir("dl_queued", old)
+	mkdir("dl_waiting", old)
+	abandoned := mkdir("dl_abandoned", old)
+	recent := mkdir("dl_recent", time.Now())
+
+	p := &NZBDownloaderPlugin{downloadManager: NewDownloadManager(1), persist: newPersister()}
+	p.downloadManager.downloads["dl_queued"] = &Download{ID: "dl_queued", Status: "queued", DownloadDir: filepath.Join(root, "dl_queued")}
+	p.downloadManager.downloads["dl_waiting"] = &Download{ID: "dl_waiting", Status: "waiting_import", DownloadDir: filepath.Join(root, "dl_waiting")}
+
+	settings := janitorSettings{downloadDir: root, retentionDays: 7}
+	preview := p.cleanDownloadDir(context.Background(), settings, true)
+	if len(preview.Removed) != 1 || preview.Removed[0].Name != "dl_abandoned" || preview.FreedBytes != 4 {
+		t.Fatalf("preview = %+v, want only dl_abandoned", preview)
+	}
+	if _, err := os.Stat(abandoned); err != nil {
+		t.Fatalf("preview removed %s", abandoned)
+	}
+
+	// With a trash retention the directory is moved instead of deleted
+	settings.trashDays = 3
+	report := p.cleanDownloadDir(context.Background(), settings, false)
+	if len(report.Removed) != 1 || len(report.Errors) != 0 || report.FreedBytes != 0 {
+		t.Fatalf("run = %+v", report)
+	}
+	if _, err := os.Stat(abandoned); !os.IsNotExist(err) {
+		t.Errorf("%s still exists", abandoned)
+	}
+	trashed := filepath.Join(root, trashDirName, "dl_abandoned")
+	if _, err := os.Stat(trashed); err != nil {
+		t.Errorf("%s was not moved to the trash: %v", trashed, err)
+	}
+	for _, name := range []string{"dl_queued", "dl_waiting"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("referenced directory %s was removed", name)
+		}
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recently modified directory was removed")
+	}
+
+	// Once the trash retention has passed the directory is deleted
+	os.Chtimes(trashed, old, old)
+	os.Chtimes(filepath.Join(trashed, "file.bin"), old, old)
+	report = p.cleanDownloadDir(context.Background(), settings, false)
+	if len(report.Removed) != 1 || !report.Removed[0].Trash || report.FreedBytes != 4 {
+		t.Fatalf("trash run = %+v", report)
+	}
+	if _, err := os.Stat(trashed); !os.IsNotExist(err) {
+		t.Errorf("%s still exists", trashed)
+	}
+}
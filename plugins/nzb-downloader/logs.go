@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Each download keeps its latest log lines in memory, as many as the
+// retention setting. The verbosity decides whether the detail of a download,
+// its per-segment warnings and progress lines, is logged at all, and whether
+// lines are copied to stderr for the host's log. With the log file enabled
+// every line is also appended to downloadLogName in the download's directory,
+// so nothing is lost to the retention.
+
+const (
+	// downloadLogName is the file a download's full log is written to
+	downloadLogName = "download.log"
+
+	defaultLogRetention = 200
+	maxLogRetention     = 10000
+
+	// maxLogTail bounds the lines the log endpoint returns
+	maxLogTail = 10000
+)
+
+// Log verbosity levels
+const (
+	logQuiet  = "quiet"  // Only what happens to the download
+	logNormal = "normal" // Also per-segment warnings and progress lines
+	logDebug  = "debug"  // Also copied to stderr
+)
+
+// logSettings control what a download logs and where
+type logSettings struct {
+	retention int    // Lines kept in memory
+	verbosity string // logQuiet, logNormal or logDebug
+	toFile    bool   // Append every line to downloadLogName
+}
+
+// downloadLogs holds the log settings of all downloads
+var downloadLogs atomic.Pointer[logSettings]
+
+// currentLogSettings returns the configured log settings
+func currentLogSettings() logSettings {
+	if s := downloadLogs.Load(); s != nil {
+		return *s
+	}
+	return logSettings{retention: defaultLogRetention, verbosity: logNormal}
+}
+
+// loadLogSettings reads the log settings from the config store
+func loadLogSettings(ctx context.Context, sdk plugins.SDKInterface) {
+	settings := logSettings{retention: defaultLogRetention, verbosity: logNormal}
+	if val, err := sdk.ConfigGet(ctx, configLogRetention); err == nil {
+		if lines, ok := parseNumber(val); ok && lines >= 1 {
+			settings.retention = min(int(lines), maxLogRetention)
+		}
+	}
+	if val, err := sdk.ConfigGet(ctx, configLogVerbosity); err == nil {
+		if verbosity, ok := val.(string); ok && validVerbosity(verbosity) {
+			settings.verbosity = verbosity
+		}
+	}
+	if val, err := sdk.ConfigGet(ctx, configLogToFile); err == nil {
+		settings.toFile = parseBool(val)
+	}
+	downloadLogs.Store(&settings)
+}
+
+func validVerbosity(verbosity string) bool {
+	return verbosity == logQuiet || verbosity == logNormal || verbosity == logDebug
+}
+
+// AddLog adds a log message to the download
+func (d *Download) AddLog(msg string) {
+	d.addLog(msg, currentLogSettings())
+}
+
+// AddDetailLog adds a message about the detail of the download, like a
+// failed segment or its progress, unless the verbosity is quiet
+func (d *Download) AddDetailLog(msg string) {
+	settings := currentLogSettings()
+	if settings.verbosity == logQuiet {
+		return
+	}
+	d.addLog(msg, settings)
+}
+
+func (d *Download) addLog(msg string, settings logSettings) {
+	d.logMu.Lock()
+	defer d.logMu.Unlock()
+
+	now := time.Now()
+	d.Logs = append(d.Logs, fmt.Sprintf("[%s] %s", now.Format("15:04:05"), msg))
+	if len(d.Logs) > settings.retention {
+		d.Logs = d.Logs[len(d.Logs)-settings.retention:]
+	}
+
+	if settings.toFile && d.DownloadDir != "" {
+		d.appendLogFile(fmt.Sprintf("[%s] %s\n", now.Format(time.RFC3339), msg))
+	}
+	if settings.verbosity == logDebug {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", d.Name, msg)
+	}
+}
+
+// appendLogFile appends a line to the download's log file. A download whose
+// directory isn't there yet, or any more, isn't logged to a file.
+func (d *Download) appendLogFile(line string) {
+	f, err := os.OpenFile(filepath.Join(d.DownloadDir, downloadLogName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line)
+}
+
+// downloadLog is a download's log as returned by the log endpoint
+type downloadLog struct {
+	ID    string   `json:"id"`
+	Lines []string `json:"lines"`
+	// Source is "file" for the full log on disk, or "memory" for the latest
+	// lines kept in memory
+	Source string `json:"source"`
+	Total  int    `json:"total"` // Lines in the log, of which Lines are the last
+}
+
+// readLogFile reads the lines of a log file, keeping the last tail of them
+// when tail is positive
+func readLogFile(path string, tail int) ([]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var lines []string
+	total := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		total++
+		lines = append(lines, scanner.Text())
+		if tail > 0 && len(lines) > tail {
+			lines = lines[1:]
+		}
+	}
+	return lines, total, scanner.Err()
+}
+
+// handleDownloadLog returns the log of a download: the full log file when it
+// has one, otherwise the lines kept in memory. ?tail=N returns the last N.
+func (p *NZBDownloaderPlugin) handleDownloadLog(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	tail := 0
+	if values := req.Query["tail"]; len(values) > 0 && values[0] != "" {
+		n, err := strconv.Atoi(values[0])
+		if err != nil || n < 1 {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": "tail must be a positive number"})
+		}
+		tail = min(n, maxLogTail)
+	}
+
+	p.downloadManager.mu.RLock()
+	dl, exists := p.downloadManager.downloads[downloadID]
+	p.downloadManager.mu.RUnlock()
+	if !exists {
+		return queueErrorResponse(errDownloadNotFound)
+	}
+
+	result := downloadLog{ID: downloadID}
+	if dl.DownloadDir != "" {
+		lines, total, err := readLogFile(filepath.Join(dl.DownloadDir, downloadLogName), tail)
+		if err == nil {
+			result.Lines, result.Total, result.Source = lines, total, "file"
+		} else if !os.IsNotExist(err) {
+			return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "Failed to read download log: " + err.Error()})
+		}
+	}
+	if result.Source == "" {
+		lines := dl.snapshot().Logs
+		result.Total, result.Source = len(lines), "memory"
+		if tail > 0 && len(lines) > tail {
+			lines = lines[len(lines)-tail:]
+		}
+		result.Lines = lines
+	}
+	if result.Lines == nil {
+		result.Lines = []string{}
+	}
+	return jsonResponse(http.StatusOK, result)
+}
+
+// logConfig is the log settings as returned and taken by the config endpoint
+func logConfig() map[string]interface{} {
+	settings := currentLogSettings()
+	return map[string]interface{}{
+		"log_retention_lines": settings.retention,
+		"log_verbosity":       settings.verbosity,
+		"log_to_file":         settings.toFile,
+	}
+}
+
+// setLogConfig saves the log settings in a config update
+func setLogConfig(ctx context.Context, sdk plugins.SDKInterface, config map[string]interface{}) error {
+	if lines, ok := config["log_retention_lines"].(float64); ok && lines >= 1 {
+		sdk.ConfigSet(ctx, configLogRetention, min(int(lines), maxLogRetention))
+	}
+	if verbosity, ok := config["log_verbosity"].(string); ok {
+		verbosity = strings.ToLower(strings.TrimSpace(verbosity))
+		if !validVerbosity(verbosity) {
+			return fmt.Errorf("log_verbosity must be quiet, normal or debug")
+		}
+		sdk.ConfigSet(ctx, configLogVerbosity, verbosity)
+	}
+	if toFile, ok := config["log_to_file"].(bool); ok {
+		sdk.ConfigSet(ctx, configLogToFile, toFile)
+	}
+	loadLogSettings(ctx, sdk)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// useLogSettings loads log settings from a config store for one test
+func useLogSettings(t *testing.T, config map[string]interface{}) {
+	loadLogSettings(context.Background(), &configSDK{config: config})
+	t.Cleanup(func() { downloadLogs.Store(nil) })
+}
+
+func TestLogRetention(t *testing.T) {
+	useLogSettings(t, map[string]interface{}{configLogRetention: float64(3)})
+
+	dl := &Download{Name: "Movie"}
+	for i := 1; i <= 5; i++ {
+		dl.AddLog(fmt.Sprintf("line %d", i))
+	}
+	if len(dl.Logs) != 3 || !strings.HasSuffix(dl.Logs[0], "line 3") || !strings.HasSuffix(dl.Logs[2], "line 5") {
+		t.Errorf("logs = %v, want the last 3 lines", dl.Logs)
+	}
+
+	// Without settings the default is kept
+	downloadLogs.Store(nil)
+	dl = &Download{Name: "Movie"}
+	for i := 0; i < defaultLogRetention+10; i++ {
+		dl.AddLog("line")
+	}
+	if len(dl.Logs) != defaultLogRetention {
+		t.Errorf("kept %d lines, want %d", len(dl.Logs), defaultLogRetention)
+	}
+}
+
+func TestQuietVerbositySkipsDetail(t *testing.T) {
+	useLogSettings(t, map[string]interface{}{configLogVerbosity: logQuiet})
+
+	dl := &Download{Name: "Movie"}
+	dl.AddDetailLog("Segment 1/10 failed: timeout")
+	dl.AddLog("Download completed")
+	if len(dl.Logs) != 1 || !logged(dl, "Download completed") {
+		t.Errorf("logs = %v, want only the completion", dl.Logs)
+	}
+
+	useLogSettings(t, map[string]interface{}{configLogVerbosity: "loud"})
+	dl.AddDetailLog("Progress: 5/10 segments")
+	if !logged(dl, "Progress") {
+		t.Error("an unknown verbosity should fall back to normal")
+	}
+}
+
+func TestDownloadLogFile(t *testing.T) {
+	useLogSettings(t, map[string]interface{}{configLogRetention: float64(2), configLogToFile: true})
+	dir := t.TempDir()
+	p := &NZBDownloaderPlugin{downloadManager: NewDownloadManager(1), persist: newPersister()}
+	dl := &Download{ID: "dl_1", Name: "Movie", DownloadDir: dir}
+	p.downloadManager.downloads[dl.ID] = dl
+	for i := 1; i <= 5; i++ {
+		dl.AddLog(fmt.Sprintf("line %d", i))
+	}
+
+	getLog := func(query map[string][]string) (int, downloadLog) {
+		t.Helper()
+		resp, err := p.handleDownloadLog(context.Background(), &plugins.PluginHTTPRequest{Query: query}, dl.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body downloadLog
+		json.Unmarshal(resp.Body, &body)
+		return resp.StatusCode, body
+	}
+
+	// The file has every line, memory only the last two
+	status, body := getLog(nil)
+	if status != http.StatusOK || body.Source != "file" || body.Total != 5 || len(body.Lines) != 5 {
+		t.Fatalf("log = %d %+v, want all 5 lines from the file", status, body)
+	}
+	_, body = getLog(map[string][]string{"tail": {"2"}})
+	if len(body.Lines) != 2 || !strings.HasSuffix(body.Lines[0], "line 4") || body.Total != 5 {
+		t.Errorf("tail = %+v, want lines 4 and 5 of 5", body)
+	}
+	if status, _ := getLog(map[string][]string{"tail": {"-1"}}); status != http.StatusBadRequest {
+		t.Errorf("negative tail status = %d", status)
+	}
+
+	// Without the file the lines in memory are returned
+	os.Remove(filepath.Join(dir, downloadLogName))
+	_, body = getLog(map[string][]string{"tail": {"1"}})
+	if body.Source != "memory" || body.Total != 2 || len(body.Lines) != 1 || !strings.HasSuffix(body.Lines[0], "line 5") {
+		t.Errorf("memory log = %+v", body)
+	}
+
+	if resp, _ := p.handleDownloadLog(context.Background(), &plugins.PluginHTTPRequest{}, "dl_missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing download status = %d", resp.StatusCode)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/json"
@@ -11,8 +12,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/plugins"
@@ -24,15 +27,66 @@ type NZBDownloaderPlugin struct {
 	downloadManager *DownloadManager
 	sdk             plugins.SDKInterface
 	sdkMu           sync.RWMutex
+
+	// loadOnce restores the saved download state on the first host request
+	loadOnce sync.Once
+
+	// preemptOnPriority pauses lower priority downloads when a higher
+	// priority one is waiting for a slot
+	preemptOnPriority atomic.Bool
+
+	// disk holds the free space a download needs to start
+	disk atomic.Pointer[diskSettings]
+
+	// persist saves the download state in the background
+	persist *persister
+
+	// janitor removes download directories nothing references any more
+	janitor janitor
+
+	// stats counts the article requests made to each server
+	stats serverStatsRegistry
+
+	// tools are the external extraction tools found at startup
+	tools ExtractTools
 }
 
 // Configuration keys
 const (
-	configPrefix      = "plugins.nzb-downloader"
-	configServers     = configPrefix + ".servers"
-	configDownloadDir = configPrefix + ".download_dir"
-	configConnections = configPrefix + ".connections"
-	configDownloads   = configPrefix + ".downloads" // Persisted download state
+	configPrefix       = "plugins.nzb-downloader"
+	configServers      = configPrefix + ".servers"
+	configPasswords    = configPrefix + ".server_passwords" // Secret: server ID -> password
+	configDownloadDir  = configPrefix + ".download_dir"
+	configConnections  = configPrefix + ".connections"
+	configDownloads    = configPrefix + ".downloads"     // Persisted download state
+	configDownloadsAlt = configPrefix + ".downloads_alt" // Written in turn with configDownloads
+	configPreempt      = configPrefix + ".preempt_on_priority"
+
+	// Free space needed to start a download: its size times the multiplier,
+	// plus the margin
+	configSpaceMultiplier = configPrefix + ".space_multiplier"
+	configSpaceMarginMB   = configPrefix + ".space_margin_mb"
+
+	// Unreferenced download directories are removed after this many days, or
+	// moved to the trash and deleted from there after the trash days
+	configJanitorRetentionDays = configPrefix + ".janitor_retention_days"
+	configJanitorTrashDays     = configPrefix + ".janitor_trash_days"
+
+	// Script hooks run on events of a download
+	configHooks = configPrefix + ".hooks"
+
+	// Download logs, see logs.go
+	configLogRetention = configPrefix + ".log_retention_lines"
+	configLogVerbosity = configPrefix + ".log_verbosity"
+	configLogToFile    = configPrefix + ".log_to_file"
+
+	// importTimeout bounds an import, which may copy large files
+	importTimeout = 30 * time.Minute
+
+	// configSchemaVersion is the version of the shape of the stored config.
+	// Version 1 always stores servers as a JSON list, where older versions
+	// sometimes stored a string holding one.
+	configSchemaVersion = 1
 )
 
 // NNTPServer represents an NNTP server configuration
@@ -47,49 +101,87 @@ type NNTPServer struct {
 	Enabled     bool   `json:"enabled"`
 	Connections int    `json:"connections"`
 	Priority    int    `json:"priority"`
+
+	// RetentionDays is how many days the server keeps articles, 0 when
+	// unknown. NZBs older than that aren't downloaded from the server.
+	RetentionDays int `json:"retention_days"`
 }
 
 // Download represents a download job
 type Download struct {
-	ID              string                 `json:"id"`
-	Name            string                 `json:"name"`
-	Status          string                 `json:"status"` // queued, downloading, processing, paused, completed, failed
-	Progress        float64                `json:"progress"`
-	TotalBytes      int64                  `json:"total_bytes"`
-	DownloadedBytes int64                  `json:"downloaded_bytes"`
-	Speed           int64                  `json:"speed"`               // bytes per second
-	ETA             int64                  `json:"eta"`                 // seconds
-	URL             string                 `json:"url,omitempty"`       // Original download URL
-	FileName        string                 `json:"file_name,omitempty"` // Original filename if uploaded
-	Priority        int                    `json:"priority"`
-	Metadata        map[string]interface{} `json:"metadata,omitempty"`
-	AddedAt         time.Time              `json:"added_at"`
-	StartedAt       *time.Time             `json:"started_at,omitempty"`
-	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
-	Error           string                 `json:"error,omitempty"`
-	NZBData         *NZB                   `json:"-"`
-	Servers         []NNTPServer           `json:"-"`              // Snapshot of enabled servers at time of creation
-	DownloadDir     string                 `json:"-"`              // Download directory
-	Logs            []string               `json:"logs,omitempty"` // Recent log messages
-	logMu           sync.Mutex             `json:"-"`
-	cancelDownload  context.CancelFunc     `json:"-"` // Cancel function for this download
-}
-
-// AddLog adds a log message to the download
-func (d *Download) AddLog(msg string) {
-	d.logMu.Lock()
-	defer d.logMu.Unlock()
-
-	timestamp := time.Now().Format("15:04:05")
-	d.Logs = append(d.Logs, fmt.Sprintf("[%s] %s", timestamp, msg))
-
-	// Keep only last 50 log lines
-	if len(d.Logs) > 50 {
-		d.Logs = d.Logs[len(d.Logs)-50:]
-	}
-
-	// Also write to stderr for debugging
-	fmt.Fprintf(os.Stderr, "[%s] %s\n", d.Name, msg)
+	ID              string                  `json:"id"`
+	Name            string                  `json:"name"`
+	Status          string                  `json:"status"` // queued, downloading, processing, paused, completed, failed
+	Progress        float64                 `json:"progress"`
+	TotalBytes      int64                   `json:"total_bytes"`
+	DownloadedBytes int64                   `json:"downloaded_bytes"`
+	Speed           int64                   `json:"speed"`               // bytes per second
+	ETA             *int64                  `json:"eta"`                 // seconds, null until the speed is stable
+	URL             string                  `json:"url,omitempty"`       // Original download URL
+	FileName        string                  `json:"file_name,omitempty"` // Original filename if uploaded
+	Priority        int                     `json:"priority"`
+	Metadata        map[string]interface{}  `json:"metadata,omitempty"`
+	AddedAt         time.Time               `json:"added_at"`
+	StartedAt       *time.Time              `json:"started_at,omitempty"`
+	CompletedAt     *time.Time              `json:"completed_at,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+	StatusDetail    string                  `json:"status_detail,omitempty"`  // Why a download is waiting, e.g. for disk space
+	Phase           string                  `json:"phase,omitempty"`          // Post-processing phase of a processing download, see phases.go
+	PhaseProgress   *float64                `json:"phase_progress,omitempty"` // Percent of the phase done, when its tool tells
+	Phases          []PhaseTiming           `json:"phases,omitempty"`         // When each post-processing phase started and finished
+	FileCount       int                     `json:"file_count"`
+	SegmentCount    int                     `json:"segment_count"`
+	Sequential      bool                    `json:"sequential,omitempty"`       // Main file is downloaded from its start first, see sequential.go
+	MainFile        int                     `json:"-"`                          // Index of the largest file downloaded from the NZB
+	ContiguousBytes int64                   `json:"contiguous_bytes,omitempty"` // Bytes of the main file written from its start, for sequential downloads
+	Password        string                  `json:"-"`                          // Archive password from the NZB head
+	Servers         []NNTPServer            `json:"-"`                          // Snapshot of enabled servers at time of creation
+	ServerIDs       []string                `json:"-"`                          // IDs of the snapshot, which is all that is saved of it
+	WantedEpisodes  []plugins.WantedEpisode `json:"wanted_episodes,omitempty"`  // Episodes a season pack is downloaded for, see partial.go
+	SkippedFiles    []int                   `json:"skipped_files,omitempty"`    // Indexes of the NZB files left out for other episodes
+	PostedAt        *time.Time              `json:"posted_at,omitempty"`        // When the oldest file of the NZB was posted, for server retention
+	DownloadDir     string                  `json:"-"`                          // Download directory
+	Logs            []string                `json:"logs,omitempty"`             // Recent log messages
+	mu              sync.Mutex              `json:"-"`                          // Guards the status, progress and totals, see state.go
+	logMu           sync.Mutex              `json:"-"`
+	cancelDownload  context.CancelFunc      `json:"-"` // Cancel function for this download
+	workers         sync.WaitGroup          `json:"-"` // Goroutines downloading or processing this download
+	spaceCheckedAt  time.Time               `json:"-"` // Last disk space check while waiting to start
+	spaceWarned     bool                    `json:"-"` // Low disk space warning was sent
+}
+
+// applySummary records the totals of a download's NZB
+func (d *Download) applySummary(summary *NZBSummary) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.FileCount = summary.Files
+	d.SegmentCount = summary.Segments
+	d.TotalBytes = summary.TotalBytes
+	d.MainFile = summary.LargestFile
+	d.Password = summary.Password
+	d.PostedAt = summary.PostedAt
+	d.SkippedFiles = nil // Until the wanted episodes are selected again
+}
+
+// deleteStopTimeout is how long deleting a download with its files waits for
+// a cancelled download to close its files
+const deleteStopTimeout = 30 * time.Second
+
+// waitStopped waits until the download is no longer downloaded or processed,
+// reporting false if it is still running after timeout
+func (d *Download) waitStopped(timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
 
 // DownloadManager manages the download queue
@@ -129,25 +221,45 @@ func (p *NZBDownloaderPlugin) Metadata(ctx context.Context) (*plugins.PluginMeta
 
 // APIRoutes returns the HTTP routes this plugin provides
 func (p *NZBDownloaderPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	// Servers, settings and purging the queue are admin-only; any user can
+	// list, add and manage individual downloads
 	return []plugins.RouteDescriptor{
 		// Server management
-		{Method: "GET", Path: "/api/plugins/nzb-downloader/servers", Auth: "session"},
-		{Method: "POST", Path: "/api/plugins/nzb-downloader/servers", Auth: "session"},
-		{Method: "PUT", Path: "/api/plugins/nzb-downloader/servers/{id}", Auth: "session"},
-		{Method: "DELETE", Path: "/api/plugins/nzb-downloader/servers/{id}", Auth: "session"},
-		{Method: "POST", Path: "/api/plugins/nzb-downloader/servers/{id}/test", Auth: "session"},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/servers", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/servers", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "PUT", Path: "/api/plugins/nzb-downloader/servers/{id}", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "DELETE", Path: "/api/plugins/nzb-downloader/servers/{id}", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/servers/{id}/test", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/servers/{id}/stats", Auth: "session", Role: plugins.ScopeAdmin},
 		// Download management
 		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads", Auth: "session"},
 		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/stream", Auth: "session"},
 		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads", Auth: "session"},
+		{Method: "DELETE", Path: "/api/plugins/nzb-downloader/downloads", Auth: "session", Role: plugins.ScopeAdmin},
 		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/move", Auth: "session"},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/pause-all", Auth: "session"},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/resume-all", Auth: "session"},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/bulk", Auth: "session"},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/{id}", Auth: "session"},
 		{Method: "DELETE", Path: "/api/plugins/nzb-downloader/downloads/{id}", Auth: "session"},
 		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/pause", Auth: "session"},
 		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/resume", Auth: "session"},
 		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/retry", Auth: "session"},
+		{Method: "PUT", Path: "/api/plugins/nzb-downloader/downloads/{id}/priority", Auth: "session"},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/downloads/{id}/reprocess", Auth: "session"},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/downloads/{id}/log", Auth: "session"},
 		// Configuration
-		{Method: "GET", Path: "/api/plugins/nzb-downloader/config", Auth: "session"},
-		{Method: "POST", Path: "/api/plugins/nzb-downloader/config", Auth: "session"},
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/config", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/config", Auth: "session", Role: plugins.ScopeAdmin},
+		// Script hooks
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/hooks", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "PUT", Path: "/api/plugins/nzb-downloader/hooks", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/hooks/test", Auth: "session", Role: plugins.ScopeAdmin},
+		// Status
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/status", Auth: "session"},
+
+		{Method: "GET", Path: "/api/plugins/nzb-downloader/janitor/preview", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: "/api/plugins/nzb-downloader/janitor/run", Auth: "session", Role: plugins.ScopeAdmin},
 	}, nil
 }
 
@@ -158,90 +270,119 @@ func (p *NZBDownloaderPlugin) HandleAPI(ctx context.Context, req *plugins.Plugin
 		p.sdkMu.Lock()
 		if p.sdk == nil {
 			p.sdk = req.SDK
-			// Load persisted downloads on first API call
-			go p.loadDownloads(context.Background(), req.SDK)
+			go p.loadSettings(context.Background(), req.SDK)
 		}
 		p.sdkMu.Unlock()
-	}
 
-	// Server management
-	if req.Path == "/api/plugins/nzb-downloader/servers" {
-		if req.Method == "GET" {
-			return p.handleListServers(ctx, req)
-		}
-		return p.handleCreateServer(ctx, req)
+		// Load persisted downloads before answering the first API call, so the
+		// host sees which downloads survived a restart
+		p.loadOnce.Do(func() {
+			p.loadDownloads(context.Background(), req.SDK)
+		})
 	}
 
-	// Server operations with ID
-	if strings.HasPrefix(req.Path, "/api/plugins/nzb-downloader/servers/") {
-		parts := strings.Split(req.Path, "/")
-		if len(parts) >= 6 {
-			serverID := parts[5]
-
-			switch req.Method {
-			case "DELETE":
-				return p.handleDeleteServer(ctx, req, serverID)
-			case "PUT":
-				return p.handleUpdateServer(ctx, req, serverID)
-			case "POST":
-				// Check if it's a test request
-				if len(parts) == 7 && parts[6] == "test" {
-					return p.handleTestServer(ctx, req, serverID)
-				}
-			}
+	// The host enforces the admin routes, but check again in case a route
+	// table and handler ever disagree
+	if isAdminPath(req) {
+		if resp := plugins.RequireScope(req, plugins.ScopeAdmin); resp != nil {
+			return resp, nil
 		}
 	}
 
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern and take IDs from its placeholders
+	id := req.PathParams["id"]
+	switch req.Method + " " + strings.TrimPrefix(req.Route, "/api/plugins/nzb-downloader") {
+	// Server management
+	case "GET /servers":
+		return p.handleListServers(ctx, req)
+	case "POST /servers":
+		return p.handleCreateServer(ctx, req)
+	case "PUT /servers/{id}":
+		return p.handleUpdateServer(ctx, req, id)
+	case "DELETE /servers/{id}":
+		return p.handleDeleteServer(ctx, req, id)
+	case "POST /servers/{id}/test":
+		return p.handleTestServer(ctx, req, id)
+	case "GET /servers/{id}/stats":
+		return p.handleServerStats(ctx, req, id)
+
 	// Download management
-	if req.Path == "/api/plugins/nzb-downloader/downloads" {
-		if req.Method == "GET" {
-			return p.handleListDownloads(ctx, req)
-		}
+	case "GET /downloads":
+		return p.handleListDownloads(ctx, req)
+	case "POST /downloads":
 		return p.handleAddDownload(ctx, req)
-	}
-
-	if req.Path == "/api/plugins/nzb-downloader/downloads/move" {
+	case "DELETE /downloads":
+		return p.handlePurgeDownloads(ctx, req)
+	case "POST /downloads/move":
 		return p.handleMoveDownloads(ctx, req)
-	}
-
-	// Download operations with ID
-	if strings.HasPrefix(req.Path, "/api/plugins/nzb-downloader/downloads/") && req.Path != "/api/plugins/nzb-downloader/downloads/move" {
-		parts := strings.Split(req.Path, "/")
-		if len(parts) >= 6 {
-			downloadID := parts[5]
-
-			// Check for action routes (pause, resume, retry)
-			if len(parts) == 7 && req.Method == "POST" {
-				action := parts[6]
-				switch action {
-				case "pause":
-					return p.handlePauseDownload(ctx, req, downloadID)
-				case "resume":
-					return p.handleResumeDownload(ctx, req, downloadID)
-				case "retry":
-					return p.handleRetryDownload(ctx, req, downloadID)
-				}
-			}
-
-			// Direct operations
-			switch req.Method {
-			case "DELETE":
-				return p.handleDeleteDownload(ctx, req, downloadID)
-			}
-		}
-	}
+	case "POST /downloads/pause-all":
+		return p.handlePauseAll(ctx, req)
+	case "POST /downloads/resume-all":
+		return p.handleResumeAll(ctx, req)
+	case "POST /downloads/bulk":
+		return p.handleBulkAction(ctx, req)
+	case "GET /downloads/{id}":
+		return p.handleGetDownload(ctx, req, id)
+	case "DELETE /downloads/{id}":
+		return p.handleDeleteDownload(ctx, req, id)
+	case "POST /downloads/{id}/pause":
+		return p.handlePauseDownload(ctx, req, id)
+	case "POST /downloads/{id}/resume":
+		return p.handleResumeDownload(ctx, req, id)
+	case "POST /downloads/{id}/retry":
+		return p.handleRetryDownload(ctx, req, id)
+	case "POST /downloads/{id}/reprocess":
+		return p.handleReprocessDownload(ctx, req, id)
+	case "PUT /downloads/{id}/priority":
+		return p.handleSetPriority(ctx, req, id)
+	case "GET /downloads/{id}/log":
+		return p.handleDownloadLog(ctx, req, id)
 
 	// Configuration
-	if req.Path == "/api/plugins/nzb-downloader/config" {
-		if req.Method == "GET" {
-			return p.handleGetConfig(ctx, req)
-		}
+	case "GET /config":
+		return p.handleGetConfig(ctx, req)
+	case "POST /config":
 		return p.handleSetConfig(ctx, req)
+
+	// Script hooks
+	case "GET /hooks":
+		return p.handleListHooks(ctx, req)
+	case "PUT /hooks":
+		return p.handleSetHooks(ctx, req)
+	case "POST /hooks/test":
+		return p.handleTestHook(ctx, req)
+
+	// Status
+	case "GET /status":
+		return p.handleStatus(ctx, req)
+
+	// Download directory cleanup
+	case "GET /janitor/preview":
+		return p.handleJanitorPreview(ctx, req)
+	case "POST /janitor/run":
+		return p.handleJanitorRun(ctx, req)
 	}
 
 	return jsonResponse(http.StatusNotFound, map[string]string{"error": "Not found"})
 }
 
+// isAdminPath reports whether a request is for one of the admin-only routes
+func isAdminPath(req *plugins.PluginHTTPRequest) bool {
+	switch {
+	case req.Path == "/api/plugins/nzb-downloader/servers",
+		strings.HasPrefix(req.Path, "/api/plugins/nzb-downloader/servers/"),
+		strings.HasPrefix(req.Path, "/api/plugins/nzb-downloader/janitor/"),
+		req.Path == "/api/plugins/nzb-downloader/hooks",
+		req.Path == "/api/plugins/nzb-downloader/hooks/test",
+		req.Path == "/api/plugins/nzb-downloader/config":
+		return true
+	case req.Path == "/api/plugins/nzb-downloader/downloads":
+		return req.Method == "DELETE"
+	}
+	return false
+}
+
 // Server Management Handlers
 
 func (p *NZBDownloaderPlugin) handleListServers(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
@@ -259,18 +400,37 @@ func (p *NZBDownloaderPlugin) handleListServers(ctx context.Context, req *plugin
 		servers = []NNTPServer{}
 	}
 
-	// DEBUG: Log raw server data
-	fmt.Fprintf(os.Stderr, "handleListServers: Retrieved %d servers\n", len(servers))
-	for i, srv := range servers {
-		fmt.Fprintf(os.Stderr, "  Server %d: ID=%s, Name=%s, Enabled=%v\n", i, srv.ID, srv.Name, srv.Enabled)
+	// Mask passwords, and add how each server has done since the plugin started
+	type listedServer struct {
+		NNTPServer
+		Stats ServerStatsSummary `json:"stats"`
+	}
+	listed := make([]listedServer, len(servers))
+	for i, server := range servers {
+		server.Password = maskPassword(server.Password)
+		listed[i] = listedServer{NNTPServer: server, Stats: p.stats.get(server.ID).summary()}
 	}
 
-	// Mask passwords
-	for i := range servers {
-		servers[i].Password = maskPassword(servers[i].Password)
+	return jsonResponse(http.StatusOK, map[string]interface{}{"servers": listed})
+}
+
+// handleServerStats returns the article statistics of a server, since the
+// plugin started and over the last hour
+func (p *NZBDownloaderPlugin) handleServerStats(ctx context.Context, req *plugins.PluginHTTPRequest, serverID string) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
 	}
 
-	return jsonResponse(http.StatusOK, map[string]interface{}{"servers": servers})
+	servers, err := p.getServers(ctx, req.SDK)
+	if err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	for _, server := range servers {
+		if server.ID == serverID {
+			return jsonResponse(http.StatusOK, p.stats.get(serverID).snapshot(serverID))
+		}
+	}
+	return jsonResponse(http.StatusNotFound, map[string]string{"error": "Server not found"})
 }
 
 func (p *NZBDownloaderPlugin) handleCreateServer(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
@@ -282,6 +442,9 @@ func (p *NZBDownloaderPlugin) handleCreateServer(ctx context.Context, req *plugi
 	if err := json.Unmarshal(req.Body, &server); err != nil {
 		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
 	}
+	if server.RetentionDays < 0 {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "retention_days can't be negative"})
+	}
 
 	if server.ID == "" {
 		server.ID = generateID()
@@ -321,6 +484,9 @@ func (p *NZBDownloaderPlugin) handleUpdateServer(ctx context.Context, req *plugi
 	if err := json.Unmarshal(req.Body, &updatedServer); err != nil {
 		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
 	}
+	if updatedServer.RetentionDays < 0 {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "retention_days can't be negative"})
+	}
 
 	servers, err := p.getServers(ctx, req.SDK)
 	if err != nil {
@@ -331,6 +497,11 @@ func (p *NZBDownloaderPlugin) handleUpdateServer(ctx context.Context, req *plugi
 	for i, server := range servers {
 		if server.ID == serverID {
 			updatedServer.ID = serverID // Ensure ID doesn't change
+			// The UI only ever sees the masked password, so keep the stored one
+			// unless a new one is given
+			if updatedServer.Password == "" || updatedServer.Password == maskPassword(server.Password) {
+				updatedServer.Password = server.Password
+			}
 			servers[i] = updatedServer
 			found = true
 			break
@@ -401,27 +572,18 @@ func (p *NZBDownloaderPlugin) handleTestServer(ctx context.Context, req *plugins
 		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Server not found"})
 	}
 
-	// Try to connect and authenticate
-	conn, err := DialNNTP(server.Host, server.Port, server.UseSSL)
-	if err != nil {
-		return jsonResponse(http.StatusOK, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Connection failed: %v", err),
-		})
+	// The body may name a recent article to fetch, proving the server can
+	// serve downloads
+	var input struct {
+		MessageID string `json:"message_id"`
 	}
-	defer conn.Close()
-
-	if err := conn.Authenticate(server.Username, server.Password); err != nil {
-		return jsonResponse(http.StatusOK, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Authentication failed: %v", err),
-		})
+	if len(req.Body) > 0 {
+		if err := json.Unmarshal(req.Body, &input); err != nil {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
 	}
 
-	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Connection successful",
-	})
+	return jsonResponse(http.StatusOK, diagnoseServer(ctx, *server, strings.TrimSpace(input.MessageID)))
 }
 
 // Download Management Handlers
@@ -430,12 +592,15 @@ func (p *NZBDownloaderPlugin) handleListDownloads(ctx context.Context, req *plug
 	p.downloadManager.mu.RLock()
 	defer p.downloadManager.mu.RUnlock()
 
-	// Return downloads in queue order to maintain consistent ordering in UI
-	downloads := make([]*Download, 0, len(p.downloadManager.queue))
-	for _, id := range p.downloadManager.queue {
-		if dl, exists := p.downloadManager.downloads[id]; exists {
-			downloads = append(downloads, dl)
-		}
+	// Return downloads in the order they will run, so the UI matches the queue
+	positions := p.downloadManager.queuePositionsLocked()
+	order := p.downloadManager.runOrderLocked()
+	downloads := make([]queuedDownload, 0, len(order))
+	for _, id := range order {
+		downloads = append(downloads, queuedDownload{
+			Download:      p.downloadManager.downloads[id].snapshot(),
+			QueuePosition: positions[id],
+		})
 	}
 
 	return jsonResponse(http.StatusOK, map[string]interface{}{"downloads": downloads})
@@ -548,9 +713,9 @@ func (p *NZBDownloaderPlugin) handleMoveDownloads(ctx context.Context, req *plug
 	// After moving, check if the first queued item changed
 	// If so, pause any active downloads and restart the queue
 	var firstQueuedID string
-	for _, id := range p.downloadManager.queue {
-		dl := p.downloadManager.downloads[id]
-		if dl.Status == "queued" || dl.Status == "downloading" {
+	for _, id := range p.downloadManager.runOrderLocked() {
+		status := p.downloadManager.downloads[id].currentStatus()
+		if status == "queued" || status == "downloading" {
 			firstQueuedID = id
 			break
 		}
@@ -568,8 +733,10 @@ func (p *NZBDownloaderPlugin) handleMoveDownloads(ctx context.Context, req *plug
 			}
 
 			// Reset status to queued
-			dl.Status = "queued"
-			dl.StartedAt = nil
+			dl.update(func() {
+				dl.Status = "queued"
+				dl.StartedAt = nil
+			})
 			delete(p.downloadManager.active, activeID)
 		}
 	}
@@ -577,33 +744,34 @@ func (p *NZBDownloaderPlugin) handleMoveDownloads(ctx context.Context, req *plug
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Downloads moved successfully"})
 }
 
+// handleDeleteDownload cancels a download and removes it from the queue. With
+// ?delete_files=true its directory is removed too, once the download has stopped
+// writing to it.
 func (p *NZBDownloaderPlugin) handleDeleteDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
-	p.downloadManager.mu.Lock()
-	defer p.downloadManager.mu.Unlock()
-
-	// Check if download exists
-	if _, exists := p.downloadManager.downloads[downloadID]; !exists {
-		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
+	deleteFiles := false
+	if values, ok := req.Query["delete_files"]; ok && len(values) > 0 {
+		deleteFiles = values[0] == "true"
 	}
 
-	// Remove from downloads map
-	delete(p.downloadManager.downloads, downloadID)
-
-	// Remove from active downloads
-	delete(p.downloadManager.active, downloadID)
-
-	// Remove from queue
-	newQueue := make([]string, 0, len(p.downloadManager.queue))
-	for _, id := range p.downloadManager.queue {
-		if id != downloadID {
-			newQueue = append(newQueue, id)
-		}
+	p.downloadManager.mu.Lock()
+	dl := p.downloadManager.downloads[downloadID]
+	err := p.downloadManager.removeLocked(downloadID)
+	p.downloadManager.mu.Unlock()
+	if err != nil {
+		return queueErrorResponse(err)
 	}
-	p.downloadManager.queue = newQueue
 
 	// Persist download state
-	if req.SDK != nil {
-		go p.saveDownloads(context.Background(), req.SDK)
+	p.persistDownloadState()
+
+	if deleteFiles && dl.DownloadDir != "" {
+		if !dl.waitStopped(deleteStopTimeout) {
+			return jsonResponse(http.StatusConflict, map[string]string{"error": "Download deleted, but it did not stop in time to remove its files"})
+		}
+		if err := os.RemoveAll(dl.DownloadDir); err != nil {
+			return jsonResponse(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Download deleted, but its files could not be removed: %v", err)})
+		}
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Removed files of deleted download %s\n", downloadID)
 	}
 
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Download deleted successfully"})
@@ -613,28 +781,9 @@ func (p *NZBDownloaderPlugin) handlePauseDownload(ctx context.Context, req *plug
 	p.downloadManager.mu.Lock()
 	defer p.downloadManager.mu.Unlock()
 
-	// Check if download exists
-	dl, exists := p.downloadManager.downloads[downloadID]
-	if !exists {
-		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
-	}
-
-	// Can only pause downloading items
-	if dl.Status != "downloading" && dl.Status != "queued" {
-		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Download is not active"})
-	}
-
-	// Cancel the download
-	if dl.cancelDownload != nil {
-		dl.AddLog("Download paused by user")
-		dl.cancelDownload()
+	if err := p.downloadManager.pauseLocked(downloadID); err != nil {
+		return queueErrorResponse(err)
 	}
-
-	// Update status
-	dl.Status = "paused"
-	dl.StartedAt = nil
-	delete(p.downloadManager.active, downloadID)
-
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Download paused successfully"})
 }
 
@@ -642,28 +791,13 @@ func (p *NZBDownloaderPlugin) handleResumeDownload(ctx context.Context, req *plu
 	p.downloadManager.mu.Lock()
 	defer p.downloadManager.mu.Unlock()
 
-	// Check if download exists
-	dl, exists := p.downloadManager.downloads[downloadID]
-	if !exists {
-		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
-	}
-
-	// Can only resume paused or queued items (idempotent - allow resuming already queued downloads)
-	if dl.Status != "paused" && dl.Status != "queued" {
-		return jsonResponse(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Download cannot be resumed (status: %s)", dl.Status)})
+	resumed, err := p.downloadManager.resumeLocked(downloadID)
+	if err != nil {
+		return queueErrorResponse(err)
 	}
-
-	// If already queued, this is a no-op (idempotent)
-	if dl.Status == "queued" {
-		dl.AddLog("Download already queued")
+	if !resumed {
 		return jsonResponse(http.StatusOK, map[string]string{"message": "Download already queued"})
 	}
-
-	// Reset status to queued so it gets picked up by the queue processor
-	dl.Status = "queued"
-	dl.Error = ""
-	dl.AddLog("Download resumed by user")
-
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Download resumed successfully"})
 }
 
@@ -671,52 +805,72 @@ func (p *NZBDownloaderPlugin) handleRetryDownload(ctx context.Context, req *plug
 	p.downloadManager.mu.Lock()
 	defer p.downloadManager.mu.Unlock()
 
-	// Check if download exists
-	dl, exists := p.downloadManager.downloads[downloadID]
-	if !exists {
-		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
-	}
-
-	// Can only retry failed or cancelled items
-	if dl.Status != "failed" && dl.Status != "cancelled" {
-		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Download is not failed or cancelled"})
+	if err := p.downloadManager.retryLocked(downloadID); err != nil {
+		return queueErrorResponse(err)
 	}
-
-	// Reset download state
-	dl.Status = "queued"
-	dl.Progress = 0
-	dl.DownloadedBytes = 0
-	dl.Error = ""
-	dl.StartedAt = nil
-	dl.CompletedAt = nil
-	dl.AddLog("Download retry requested by user")
-
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Download retry initiated"})
 }
 
 func (p *NZBDownloaderPlugin) handleAddDownload(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] handleAddDownload called\n")
-	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Request body: %s\n", string(req.Body))
+	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] handleAddDownload called (%d byte body)\n", len(req.Body))
 
 	if req.SDK == nil {
 		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
 	}
 
-	// Parse multipart form for NZB file upload or URL
-	var nzbData *NZB
+	// The NZB is only read once, while saving it next to the download
+	var nzbSource io.Reader
 	var downloadName string
+	nameFromNZB := false
 
 	// Check if it's a URL or file upload
 	var input struct {
-		URL      string                 `json:"url"`
-		NZB      string                 `json:"nzb"`
-		Name     string                 `json:"name"`
-		Priority int                    `json:"priority"`
-		Metadata map[string]interface{} `json:"metadata"`
+		URL        string                 `json:"url"`
+		NZB        string                 `json:"nzb"`
+		ID         string                 `json:"id"` // Set by the host when adding a download again after a restart
+		Name       string                 `json:"name"`
+		Priority   int                    `json:"priority"`
+		Sequential bool                   `json:"sequential"` // Download the main file from its start first, see sequential.go
+		Metadata   map[string]interface{} `json:"metadata"`
+
+		// Episodes of a season pack to download, skipping the files of
+		// others, see partial.go
+		WantedEpisodes []plugins.WantedEpisode `json:"wanted_episodes"`
+	}
+
+	if len(req.Body) > maxNZBUploadSize {
+		return jsonResponse(http.StatusRequestEntityTooLarge, map[string]string{"error": "NZB upload is too large"})
 	}
 
-	var err error
-	if jsonErr := json.Unmarshal(req.Body, &input); jsonErr == nil && (input.URL != "" || input.NZB != "") {
+	form, err := req.MultipartForm(uploadMemory)
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if form != nil {
+		// NZB file posted as a multipart form, read straight from the form
+		defer form.RemoveAll()
+		upload, err := parseNZBUpload(form)
+		if err != nil {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		defer upload.file.Close()
+		nzbSource = upload.file
+
+		input.Name = upload.fileName
+		input.Priority = upload.priority
+		input.Sequential = upload.sequential
+		input.Metadata = upload.metadata
+		input.WantedEpisodes = upload.wantedEpisodes
+
+		// Name the download as asked, after the uploaded file, or else after
+		// the first file of the NZB
+		downloadName = upload.name
+		if downloadName == "" {
+			downloadName = upload.fileName
+		}
+		nameFromNZB = downloadName == ""
+	} else if jsonErr := json.Unmarshal(req.Body, &input); jsonErr == nil && (input.URL != "" || input.NZB != "") {
 		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Parsed input - URL: %s, Name: %s\n", input.URL, input.Name)
 		if input.URL != "" {
 			// Download NZB from URL
@@ -725,11 +879,11 @@ func (p *NZBDownloaderPlugin) handleAddDownload(ctx context.Context, req *plugin
 				return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Failed to download NZB"})
 			}
 			defer resp.Body.Close()
-
-			nzbData, err = ParseNZB(resp.Body)
-			if err != nil {
-				return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Failed to parse NZB"})
+			// The host grabs the release from another indexer when this fails
+			if resp.StatusCode != http.StatusOK {
+				return jsonResponse(http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to download NZB: HTTP %d", resp.StatusCode)})
 			}
+			nzbSource = resp.Body
 
 			// Use provided name or extract from URL
 			if input.Name != "" {
@@ -744,38 +898,30 @@ func (p *NZBDownloaderPlugin) handleAddDownload(ctx context.Context, req *plugin
 				}
 			}
 		} else if input.NZB != "" {
-			// Parse NZB content from JSON
-			nzbData, err = ParseNZB(io.NopCloser(strings.NewReader(input.NZB)))
-			if err != nil {
-				return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Failed to parse NZB"})
-			}
+			// NZB content from JSON
+			nzbSource = strings.NewReader(input.NZB)
 
 			// Use provided name from the file upload
 			downloadName = input.Name
 		}
 	} else {
-		// Parse uploaded NZB file
-		nzbData, err = ParseNZB(io.NopCloser(strings.NewReader(string(req.Body))))
-		if err != nil {
-			return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Failed to parse NZB"})
-		}
-
-		// Try to extract a sensible name from the NZB metadata
-		// Use the first file's name attribute if available
-		if len(nzbData.Files) > 0 && nzbData.Files[0].FileName != "" {
-			downloadName = nzbData.Files[0].FileName
-		}
+		// Uploaded NZB file, named after its first file once it's read
+		nzbSource = bytes.NewReader(req.Body)
+		nameFromNZB = true
 	}
 
-	// Clean up the name and provide fallback
-	if downloadName == "" {
-		downloadName = fmt.Sprintf("download-%d", time.Now().Unix())
-	} else {
-		// Remove any path separators and clean up
-		downloadName = filepath.Base(downloadName)
-		// Remove .nzb extension if present
-		downloadName = strings.TrimSuffix(downloadName, ".nzb")
-		downloadName = strings.TrimSpace(downloadName)
+	// The host adds its downloads again after a restart, and this one may
+	// have been restored from the saved state already
+	if input.ID != "" {
+		if !restorableID.MatchString(input.ID) {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid download ID"})
+		}
+		p.downloadManager.mu.RLock()
+		if existing, exists := p.downloadManager.downloads[input.ID]; exists {
+			defer p.downloadManager.mu.RUnlock()
+			return jsonResponse(http.StatusOK, existing.snapshot())
+		}
+		p.downloadManager.mu.RUnlock()
 	}
 
 	// Get enabled servers and download directory now (while SDK is valid)
@@ -806,36 +952,81 @@ func (p *NZBDownloaderPlugin) handleAddDownload(ctx context.Context, req *plugin
 		downloadDirStr = "/tmp/nzb-downloads"
 	}
 
-	// Calculate total size
-	var totalBytes int64
-	for _, file := range nzbData.Files {
-		for _, seg := range file.Segments {
-			totalBytes += seg.Bytes
-		}
+	// Generate download ID, unless the host is adding one of its downloads again
+	downloadID := input.ID
+	if downloadID == "" {
+		downloadID = generateID()
 	}
 
-	// Generate download ID
-	downloadID := generateID()
-
 	// Create a unique subdirectory for this download to avoid file conflicts
 	downloadDirStr = filepath.Join(downloadDirStr, downloadID)
 
+	// Keep the NZB on disk and only count its files and segments, so a huge
+	// NZB isn't held in memory while it waits in the queue
+	summary, err := SaveNZB(nzbSource, filepath.Join(downloadDirStr, nzbFileName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Failed to save NZB: %v\n", err)
+		os.Remove(downloadDirStr) // Only removes the directory if it's still empty
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Failed to parse NZB"})
+	}
+
+	// Every segment of an NZB older than the retention of all servers would
+	// be missing
+	if summary.PostedAt != nil {
+		days := ageDays(*summary.PostedAt, time.Now())
+		if usable, _ := planServers(enabledServers, days); len(usable) == 0 {
+			os.Remove(filepath.Join(downloadDirStr, nzbFileName))
+			os.Remove(downloadDirStr)
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": retentionError(days, enabledServers).Error()})
+		}
+	}
+
+	if input.Sequential {
+		if err := checkSequential(summary); err != nil {
+			os.Remove(filepath.Join(downloadDirStr, nzbFileName))
+			os.Remove(downloadDirStr)
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	// Try to extract a sensible name from the NZB metadata
+	// Use the first file's name attribute if available
+	if nameFromNZB {
+		downloadName = summary.FirstFileName
+	}
+
+	// Clean up the name and provide fallback
+	if downloadName == "" {
+		downloadName = fmt.Sprintf("download-%d", time.Now().Unix())
+	} else {
+		// Remove any path separators and clean up
+		downloadName = filepath.Base(downloadName)
+		// Remove .nzb extension if present
+		downloadName = strings.TrimSuffix(downloadName, ".nzb")
+		downloadName = strings.TrimSpace(downloadName)
+	}
+
 	// Create download with snapshot of servers and config
 	download := &Download{
 		ID:              downloadID,
 		Name:            downloadName,
 		Status:          "queued",
 		Progress:        0,
-		TotalBytes:      totalBytes,
 		DownloadedBytes: 0,
 		URL:             input.URL,      // Preserve original URL
 		FileName:        input.Name,     // Preserve original filename
 		Priority:        input.Priority, // Preserve priority
+		Sequential:      input.Sequential,
 		Metadata:        input.Metadata, // Preserve metadata (includes media_id)
 		AddedAt:         time.Now(),
-		NZBData:         nzbData,
 		Servers:         enabledServers,
+		ServerIDs:       serverIDs(enabledServers),
 		DownloadDir:     downloadDirStr,
+		WantedEpisodes:  input.WantedEpisodes,
+	}
+	download.applySummary(summary)
+	if len(input.WantedEpisodes) > 0 {
+		download.selectWantedEpisodes(summary)
 	}
 
 	p.downloadManager.mu.Lock()
@@ -847,11 +1038,9 @@ func (p *NZBDownloaderPlugin) handleAddDownload(ctx context.Context, req *plugin
 	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Download added to queue - ID: %s, Name: %s, Queue length: %d\n", download.ID, download.Name, queueLen)
 
 	// Persist download state
-	if req.SDK != nil {
-		go p.saveDownloads(context.Background(), req.SDK)
-	}
+	p.persistDownloadState()
 
-	return jsonResponse(http.StatusCreated, download)
+	return jsonResponse(http.StatusCreated, download.snapshot())
 }
 
 // Configuration Handlers
@@ -864,9 +1053,19 @@ func (p *NZBDownloaderPlugin) handleGetConfig(ctx context.Context, req *plugins.
 	downloadDir, _ := req.SDK.ConfigGet(ctx, configDownloadDir)
 	connections, _ := req.SDK.ConfigGet(ctx, configConnections)
 
+	janitor := loadJanitorSettings(ctx, req.SDK)
+
 	config := map[string]interface{}{
-		"download_dir": downloadDir,
-		"connections":  connections,
+		"download_dir":           downloadDir,
+		"connections":            connections,
+		"preempt_on_priority":    p.preemptOnPriority.Load(),
+		"space_multiplier":       p.diskSettingsOrDefault().multiplier,
+		"space_margin_mb":        p.diskSettingsOrDefault().marginBytes >> 20,
+		"janitor_retention_days": janitor.retentionDays,
+		"janitor_trash_days":     janitor.trashDays,
+	}
+	for key, value := range logConfig() {
+		config[key] = value
 	}
 
 	return jsonResponse(http.StatusOK, config)
@@ -882,12 +1081,32 @@ func (p *NZBDownloaderPlugin) handleSetConfig(ctx context.Context, req *plugins.
 		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
 	}
 
+	if err := setLogConfig(ctx, req.SDK, config); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 	if downloadDir, ok := config["download_dir"].(string); ok {
 		req.SDK.ConfigSet(ctx, configDownloadDir, downloadDir)
 	}
 	if connections, ok := config["connections"].(float64); ok {
 		req.SDK.ConfigSet(ctx, configConnections, int(connections))
 	}
+	if preempt, ok := config["preempt_on_priority"].(bool); ok {
+		req.SDK.ConfigSet(ctx, configPreempt, preempt)
+		p.preemptOnPriority.Store(preempt)
+	}
+	if multiplier, ok := config["space_multiplier"].(float64); ok && multiplier >= 1 {
+		req.SDK.ConfigSet(ctx, configSpaceMultiplier, multiplier)
+	}
+	if margin, ok := config["space_margin_mb"].(float64); ok && margin >= 0 {
+		req.SDK.ConfigSet(ctx, configSpaceMarginMB, int(margin))
+	}
+	if days, ok := config["janitor_retention_days"].(float64); ok && days >= 1 {
+		req.SDK.ConfigSet(ctx, configJanitorRetentionDays, int(days))
+	}
+	if days, ok := config["janitor_trash_days"].(float64); ok && days >= 0 {
+		req.SDK.ConfigSet(ctx, configJanitorTrashDays, int(days))
+	}
+	p.loadDiskSettings(ctx, req.SDK)
 
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Configuration saved"})
 }
@@ -904,37 +1123,40 @@ func (p *NZBDownloaderPlugin) processDownloadQueue(ctx context.Context) {
 		default:
 			p.downloadManager.mu.Lock()
 
-			// Check if we can start more downloads
-			if len(p.downloadManager.active) >= p.downloadManager.maxActive {
+			// Nothing starts once the host is shutting down
+			if p.downloadManager.ctx.Err() != nil {
 				p.downloadManager.mu.Unlock()
-				time.Sleep(time.Second)
 				continue
 			}
 
-			// Find next queued download
-			var nextID string
-			for _, id := range p.downloadManager.queue {
-				dl := p.downloadManager.downloads[id]
-				if dl.Status == "queued" && !p.downloadManager.active[id] {
-					nextID = id
-					break
-				}
+			// Find the next download by priority
+			next := p.downloadManager.nextQueuedLocked(p.hasSpaceLocked)
+			if next == nil {
+				p.downloadManager.mu.Unlock()
+				time.Sleep(time.Second)
+				continue
 			}
 
-			if nextID == "" {
+			// Check if we can start more downloads, making room for a higher
+			// priority download when preemption is enabled
+			if len(p.downloadManager.active) >= p.downloadManager.maxActive &&
+				!(p.preemptOnPriority.Load() && p.downloadManager.preemptLocked(next)) {
 				p.downloadManager.mu.Unlock()
 				time.Sleep(time.Second)
 				continue
 			}
+			nextID := next.ID
 
 			fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Starting download: %s\n", nextID)
 
 			// Start download
 			p.downloadManager.active[nextID] = true
 			download := p.downloadManager.downloads[nextID]
-			download.Status = "downloading"
-			now := time.Now()
-			download.StartedAt = &now
+			download.update(func() {
+				now := time.Now()
+				download.Status = "downloading"
+				download.StartedAt = &now
+			})
 
 			// Create a cancellable context for this download
 			downloadCtx, downloadCancel := context.WithCancel(context.Background())
@@ -943,12 +1165,14 @@ func (p *NZBDownloaderPlugin) processDownloadQueue(ctx context.Context) {
 			p.downloadManager.mu.Unlock()
 
 			// Download in background (servers and config are in Download struct)
+			download.workers.Add(1)
 			go p.downloadNZB(downloadCtx, download)
 		}
 	}
 }
 
 func (p *NZBDownloaderPlugin) downloadNZB(ctx context.Context, download *Download) {
+	defer download.workers.Done()
 	defer func() {
 		p.downloadManager.mu.Lock()
 		delete(p.downloadManager.active, download.ID)
@@ -958,10 +1182,26 @@ func (p *NZBDownloaderPlugin) downloadNZB(ctx context.Context, download *Downloa
 	// Use the provided context which can be cancelled for pause functionality
 	downloadCtx := ctx
 
-	// Use servers and download directory from Download struct (captured at creation time)
+	// Downloads restored after a restart have their NZB on disk, or fetch it
+	// again from its URL
+	if err := rehydrateNZB(ctx, download); err != nil {
+		p.failDownload(download, err.Error())
+		download.AddLog(err.Error())
+		p.persistDownloadState()
+		return
+	}
+
+	// Use servers and download directory from Download struct (captured at creation time).
+	// Downloads restored after a restart rebuild the snapshot from the servers configured now.
+	if len(download.Servers) == 0 {
+		servers := p.restoreServers(ctx, download.ServerIDs)
+		download.update(func() {
+			download.Servers = servers
+			download.ServerIDs = serverIDs(servers)
+		})
+	}
 	if len(download.Servers) == 0 {
-		download.Status = "failed"
-		download.Error = "No servers configured for this download"
+		p.failDownload(download, "No servers configured for this download")
 		p.persistDownloadState()
 		return
 	}
@@ -973,22 +1213,28 @@ func (p *NZBDownloaderPlugin) downloadNZB(ctx context.Context, download *Downloa
 
 	// Create download directory
 	if err := os.MkdirAll(downloadDirStr, 0755); err != nil {
-		download.Status = "failed"
-		download.Error = fmt.Sprintf("Failed to create download directory: %v", err)
+		p.failDownload(download, fmt.Sprintf("Failed to create download directory: %v", err))
 		p.persistDownloadState()
 		return
 	}
 
-	// Use the first enabled server
-	server := download.Servers[0]
+	// Servers that no longer keep the NZB's articles would fail every
+	// segment, so the first server whose retention covers it is used
+	servers, err := retentionServers(download)
+	if err != nil {
+		download.AddLog(err.Error())
+		p.failDownload(download, err.Error())
+		p.persistDownloadState()
+		return
+	}
+	server := servers[0]
 
 	download.AddLog(fmt.Sprintf("Starting download using server %s:%d", server.Host, server.Port))
 
 	// Create fast downloader with connection pool
-	downloader, err := NewFastDownloader(downloadCtx, server, download)
+	downloader, err := NewFastDownloader(downloadCtx, server, download, p.stats.get(server.ID))
 	if err != nil {
-		download.Status = "failed"
-		download.Error = fmt.Sprintf("Failed to create downloader: %v", err)
+		p.failDownload(download, fmt.Sprintf("Failed to create downloader: %v", err))
 		p.persistDownloadState()
 		return
 	}
@@ -1003,161 +1249,251 @@ func (p *NZBDownloaderPlugin) downloadNZB(ctx context.Context, download *Downloa
 			return
 		}
 
-		// Actual error occurred
-		download.Status = "failed"
-		download.Error = fmt.Sprintf("Download failed: %v", err)
-		// Clean up failed download files
+		// Actual error occurred. The files are cleaned up before the
+		// on_failure hooks run.
 		p.cleanupFailedDownload(downloadDirStr, download)
+		p.failDownload(download, fmt.Sprintf("Download failed: %v", err))
 		p.persistDownloadState()
 		return
 	}
 
 	// Mark as processing - this allows next download to start
-	download.Status = "processing"
-	download.Progress = 100
+	download.update(func() {
+		download.Status = "processing"
+		download.Progress = 100
+		download.clearSpeed()
+	})
 	download.AddLog("Download complete, processing files...")
 
-	// Run post-processing in background (doesn't block queue)
-	// Note: We don't defer anything here since processing happens after download completes
+	// Run hooks and post-processing in background (doesn't block queue)
+	download.workers.Add(1)
 	go func() {
-		// Post-process files (extraction, cleanup, etc.)
-		if err := downloader.PostProcess(downloadDirStr); err != nil {
-			download.AddLog(fmt.Sprintf("Post-processing failed: %v", err))
-			download.Status = "failed"
-			download.Error = fmt.Sprintf("Post-processing failed: %v", err)
+		if err := p.runHooks(ctx, download, HookAfterDownload); err != nil {
+			download.AddLog(err.Error())
+			p.failDownload(download, err.Error())
 			p.persistDownloadState()
+			download.workers.Done()
 			return
 		}
+		p.postProcessDownload(ctx, download, downloader, downloadDirStr)
+	}()
+}
 
-		// Check if this is a season pack download
-		mediaKind, _ := download.Metadata["media_kind"].(string)
-		if mediaKind == "tv_season" {
-			// Find all episode files
-			episodeFiles, err := findAllMediaFiles(downloadDirStr)
-			if err != nil || len(episodeFiles) == 0 {
-				download.AddLog(fmt.Sprintf("ERROR: Could not find episode files: %v", err))
-				download.Status = "failed"
-				download.Error = fmt.Sprintf("Could not find episode files: %v", err)
-				return
-			} else if len(episodeFiles) == 1 {
-				// Single file marked as season pack - treat as single episode
-				download.AddLog("Detected single episode (misidentified as season pack)")
-				download.AddLog(fmt.Sprintf("Found episode file: %s", filepath.Base(episodeFiles[0])))
-
-				// Import using the media_id directly (it's actually an episode ID)
-				if _, ok := download.Metadata["media_id"]; ok {
-					if err := importToLibrary(download, episodeFiles[0]); err != nil {
-						download.AddLog(fmt.Sprintf("Import failed: %v", err))
-						download.Status = "failed"
-						download.Error = fmt.Sprintf("Import failed: %v", err)
-						return
-					} else {
-						download.AddLog("Import completed successfully")
-					}
-				} else {
-					download.AddLog("ERROR: No media_id found - cannot import")
-					download.Status = "failed"
-					download.Error = "No media_id found - cannot import"
-					return
-				}
-			} else {
-				// Multiple files - actual season pack
-				download.AddLog(fmt.Sprintf("Detected season pack, processing %d episodes...", len(episodeFiles)))
-
-				// Get the season media_id to query for episodes
-				seasonMediaID, _ := download.Metadata["media_id"]
-				if seasonMediaID == nil {
-					download.AddLog("ERROR: No media_id found for season - cannot import episodes")
-					download.Status = "failed"
-					download.Error = "No media_id found for season - cannot import episodes"
-					return
-				} else {
-					// Import each episode file
-					successCount := 0
-					failCount := 0
-
-					for _, file := range episodeFiles {
-						fileName := filepath.Base(file)
-						download.AddLog(fmt.Sprintf("Processing: %s", fileName))
-
-						// Parse season and episode from filename
-						season, episode, found := parseEpisodeFromFilename(fileName)
-						if !found {
-							download.AddLog(fmt.Sprintf("  Could not parse season/episode from filename, skipping"))
-							failCount++
-							continue
-						}
+// postProcessDownload extracts a finished download and imports its files into
+// the library. It runs after the download leaves the queue, and again when a
+// download is reprocessed.
+func (p *NZBDownloaderPlugin) postProcessDownload(ctx context.Context, download *Download, downloader *FastDownloader, downloadDirStr string) {
+	defer download.workers.Done()
+
+	// Extraction roughly doubles the size on disk
+	if !p.waitForExtractionSpace(ctx, download, downloadDirStr) {
+		download.AddLog("Post-processing cancelled while waiting for disk space")
+		return
+	}
 
-						download.AddLog(fmt.Sprintf("  Detected S%02dE%02d", season, episode))
+	// Post-process files (extraction, cleanup, etc.)
+	downloader.tools = p.tools
+	if err := downloader.PostProcess(downloadDirStr); err != nil {
+		download.AddLog(fmt.Sprintf("Post-processing failed: %v", err))
+		p.failDownload(download, fmt.Sprintf("Post-processing failed: %v", err))
+		p.persistDownloadState()
+		return
+	}
 
-						// Find the episode in the database
-						episodeMediaID, err := findEpisodeMediaID(seasonMediaID, season, episode)
-						if err != nil {
-							download.AddLog(fmt.Sprintf("  Could not find episode in database: %v", err))
-							failCount++
-							continue
-						}
+	// A download deleted while it was extracted must not be imported
+	if ctx.Err() != nil {
+		download.AddLog("Post-processing cancelled")
+		return
+	}
+
+	if err := p.runHooks(ctx, download, HookAfterExtract); err != nil {
+		download.AddLog(err.Error())
+		p.failDownload(download, err.Error())
+		p.persistDownloadState()
+		return
+	}
 
-						download.AddLog(fmt.Sprintf("  Found episode media_id: %d", episodeMediaID))
+	download.setPhase(phaseImporting)
 
-						// Import this episode
-						if err := importEpisodeFile(file, episodeMediaID); err != nil {
-							download.AddLog(fmt.Sprintf("  Import failed: %v", err))
-							failCount++
+	// Check if this is a season pack download
+	mediaKind, _ := download.Metadata["media_kind"].(string)
+	if mediaKind == "music_album" {
+		// Every track of an album is imported to the album, which the host
+		// matches each file to a track of
+		trackFiles, err := findAllMediaFiles(downloadDirStr)
+		if err != nil {
+			download.AddLog(fmt.Sprintf("ERROR: Could not find track files: %v", err))
+			p.failDownload(download, fmt.Sprintf("Could not find track files: %v", err))
+			return
+		}
+
+		download.AddLog(fmt.Sprintf("Detected album, importing %d tracks...", len(trackFiles)))
+		failCount := 0
+		for i, file := range trackFiles {
+			download.AddLog(fmt.Sprintf("Processing: %s", filepath.Base(file)))
+			if err := p.importToLibrary(download, file); err != nil {
+				download.AddLog(fmt.Sprintf("  Import failed: %v", err))
+				failCount++
+			}
+			download.setPhaseProgress(float64(i+1) / float64(len(trackFiles)) * 100)
+		}
+		download.AddLog(fmt.Sprintf("Album import complete: %d succeeded, %d failed", len(trackFiles)-failCount, failCount))
+
+		if failCount > 0 {
+			p.markWaitingImport(download, fmt.Sprintf("%d of %d track imports failed", failCount, len(trackFiles)))
+			return
+		}
+	} else if mediaKind == "tv_season" {
+		// Find all episode files
+		episodeFiles, err := findAllMediaFiles(downloadDirStr)
+		if err != nil || len(episodeFiles) == 0 {
+			download.AddLog(fmt.Sprintf("ERROR: Could not find episode files: %v", err))
+			p.failDownload(download, fmt.Sprintf("Could not find episode files: %v", err))
+			return
+		} else if len(episodeFiles) == 1 {
+			// Single file marked as season pack - treat as single episode
+			download.AddLog("Detected single episode (misidentified as season pack)")
+			download.AddLog(fmt.Sprintf("Found episode file: %s", filepath.Base(episodeFiles[0])))
+
+			// Import using the media_id directly (it's actually an episode ID)
+			if err := p.importToLibrary(download, episodeFiles[0]); err != nil {
+				download.AddLog(fmt.Sprintf("Import failed: %v", err))
+				p.markWaitingImport(download, fmt.Sprintf("Import failed: %v", err))
+				return
+			}
+			download.AddLog("Import completed successfully")
+		} else {
+			// Multiple files - actual season pack
+			download.AddLog(fmt.Sprintf("Detected season pack, processing %d episodes...", len(episodeFiles)))
+
+			// Get the season media_id to query for episodes
+			seasonMediaID, _ := download.Metadata["media_id"]
+			if seasonMediaID == nil {
+				reason := "No media_id found for season - cannot import episodes"
+				download.AddLog("ERROR: " + reason)
+				p.reportFailedImport(download, downloadDirStr, reason, nil)
+				p.markWaitingImport(download, reason)
+				return
+			}
+
+			// Import each episode file. The imports run at the same time, the
+			// host's import queue bounds how many of them actually do.
+			imports := &episodeImports{plugin: p, download: download, total: len(episodeFiles)}
+			failCount := 0
+
+			for _, file := range episodeFiles {
+				fileName := filepath.Base(file)
+				download.AddLog(fmt.Sprintf("Processing: %s", fileName))
+
+				// Parse season and episode from filename
+				season, episode, lastEpisode, found := parseEpisodeFromFilename(fileName)
+				if !found {
+					// Anime is numbered by absolute episode
+					if absolute, ok := parseAbsoluteFromFilename(fileName); ok {
+						download.AddLog(fmt.Sprintf("  Detected absolute episode %d", absolute))
+						if episodeMediaID, ok := p.findAbsoluteEpisode(download, file, seasonMediaID, absolute); ok {
+							imports.start(file, episodeMediaID, nil)
 						} else {
-							download.AddLog(fmt.Sprintf("  Import successful"))
-							successCount++
+							failCount++
 						}
+						continue
 					}
+					download.AddLog("  Could not parse season/episode from filename, queued for manual import")
+					p.reportFailedImport(download, file, "Could not parse season/episode from filename", nil)
+					failCount++
+					continue
+				}
 
-					download.AddLog(fmt.Sprintf("Season pack import complete: %d succeeded, %d failed", successCount, failCount))
+				if lastEpisode > episode {
+					download.AddLog(fmt.Sprintf("  Detected S%02dE%02d-E%02d", season, episode, lastEpisode))
+				} else {
+					download.AddLog(fmt.Sprintf("  Detected S%02dE%02d", season, episode))
+				}
+
+				// Find the episode in the database
+				episodeMediaID, err := p.findEpisodeMediaID(seasonMediaID, season, episode)
+				if err != nil {
+					download.AddLog(fmt.Sprintf("  Could not find episode in database: %v", err))
+					p.reportFailedImport(download, file, fmt.Sprintf("Could not find episode in database: %v", err), map[string]interface{}{
+						"season":  season,
+						"episode": episode,
+					})
+					failCount++
+					continue
+				}
 
-					// If all imports failed, mark download as failed
-					if failCount > 0 && successCount == 0 {
-						download.AddLog("ERROR: All episode imports failed")
-						download.Status = "failed"
-						download.Error = fmt.Sprintf("All %d episode imports failed", failCount)
-						return
-					} else if failCount > 0 {
-						download.AddLog(fmt.Sprintf("WARNING: %d episode imports failed, but %d succeeded", failCount, successCount))
+				download.AddLog(fmt.Sprintf("  Found episode media_id: %d", episodeMediaID))
+
+				// The further episodes of a multi-episode file are linked to it too
+				var extraMediaIDs []int64
+				for e := episode + 1; e <= lastEpisode; e++ {
+					extraID, err := p.findEpisodeMediaID(seasonMediaID, season, e)
+					if err != nil {
+						download.AddLog(fmt.Sprintf("  Could not find episode %d in database: %v", e, err))
+						continue
 					}
+					extraMediaIDs = append(extraMediaIDs, extraID)
 				}
+
+				// Import this episode (the host queues failed imports for manual import)
+				imports.start(file, episodeMediaID, extraMediaIDs)
 			}
-		} else {
-			// Single episode download or movie
-			mainFile, err := findMainMediaFile(downloadDirStr)
-			if err != nil {
-				download.AddLog(fmt.Sprintf("ERROR: Could not find main media file: %v", err))
-				download.Status = "failed"
-				download.Error = fmt.Sprintf("Could not find main media file: %v", err)
+
+			successCount, importFailures := imports.wait()
+			failCount += importFailures
+
+			download.AddLog(fmt.Sprintf("Season pack import complete: %d succeeded, %d failed", successCount, failCount))
+
+			// Episodes that could not be imported wait for a manual import
+			if failCount > 0 {
+				p.markWaitingImport(download, fmt.Sprintf("%d of %d episode imports failed", failCount, len(episodeFiles)))
 				return
-			} else {
-				download.AddLog(fmt.Sprintf("Found main media file: %s", filepath.Base(mainFile)))
 			}
+		}
+	} else {
+		// Single episode download or movie
+		mainFile, err := findMainMediaFile(downloadDirStr)
+		if err != nil {
+			download.AddLog(fmt.Sprintf("ERROR: Could not find main media file: %v", err))
+			p.failDownload(download, fmt.Sprintf("Could not find main media file: %v", err))
+			return
+		} else {
+			download.AddLog(fmt.Sprintf("Found main media file: %s", filepath.Base(mainFile)))
+		}
 
-			// Trigger import if we have media metadata
-			if download.Metadata != nil {
-				if shouldImport(download.Metadata) {
-					download.AddLog("Importing to library...")
-					if err := importToLibrary(download, mainFile); err != nil {
-						download.AddLog(fmt.Sprintf("Import failed: %v", err))
-						download.Status = "failed"
-						download.Error = fmt.Sprintf("Import failed: %v", err)
-						return
-					} else {
-						download.AddLog("Import completed successfully")
-					}
-				}
-			}
+		// Trigger import if we have media metadata
+		if !shouldImport(download.Metadata) {
+			reason := "Download has no media information - cannot import"
+			p.reportFailedImport(download, mainFile, reason, nil)
+			p.markWaitingImport(download, reason)
+			return
 		}
 
-		// Mark as completed
-		download.Status = "completed"
+		download.AddLog("Importing to library...")
+		if err := p.importToLibrary(download, mainFile); err != nil {
+			download.AddLog(fmt.Sprintf("Import failed: %v", err))
+			p.markWaitingImport(download, fmt.Sprintf("Import failed: %v", err))
+			return
+		}
+		download.AddLog("Import completed successfully")
+	}
+
+	if err := p.runHooks(ctx, download, HookAfterImport); err != nil {
+		download.AddLog(err.Error())
+		p.failDownload(download, err.Error())
+		p.persistDownloadState()
+		return
+	}
+
+	// Mark as completed
+	download.update(func() {
 		now := time.Now()
+		download.Status = "completed"
 		download.CompletedAt = &now
-		download.AddLog("Processing completed successfully")
-		p.persistDownloadState()
-	}()
+		download.endPhaseLocked(now)
+	})
+	download.AddLog("Processing completed successfully")
+	p.persistDownloadState()
 }
 
 // UIManifest returns the UI configuration for this plugin
@@ -1166,8 +1502,9 @@ func (p *NZBDownloaderPlugin) UIManifest(ctx context.Context) (*plugins.UIManife
 		NavItems: []plugins.UINavItem{},
 		Routes:   []plugins.UIRoute{},
 		ConfigSection: &plugins.ConfigSection{
-			Title:       "NZB Downloader Settings",
-			Description: "Configure NNTP servers and download settings for Usenet downloads",
+			Title:         "NZB Downloader Settings",
+			Description:   "Configure NNTP servers and download settings for Usenet downloads",
+			SchemaVersion: configSchemaVersion,
 			Fields: []plugins.ConfigField{
 				{
 					Key:          configDownloadDir,
@@ -1192,6 +1529,85 @@ func (p *NZBDownloaderPlugin) UIManifest(ctx context.Context) (*plugins.UIManife
 						ErrorMessage: "Must be between 1 and 50",
 					},
 				},
+				{
+					Key:          configPreempt,
+					Label:        "Preempt for Priority",
+					Description:  "Pause lower priority downloads when a higher priority download is queued, resuming them afterwards",
+					Type:         "boolean",
+					DefaultValue: "false",
+					Required:     false,
+				},
+				{
+					Key:          configSpaceMultiplier,
+					Label:        "Disk Space Multiplier",
+					Description:  "Free space needed to start a download, as a multiple of its size. Extraction roughly doubles the size on disk.",
+					Type:         "number",
+					DefaultValue: "2.2",
+					Required:     false,
+					Placeholder:  "2.2",
+				},
+				{
+					Key:          configSpaceMarginMB,
+					Label:        "Disk Space Margin (MB)",
+					Description:  "Free space to keep on top of what a download needs",
+					Type:         "number",
+					DefaultValue: "1024",
+					Required:     false,
+					Placeholder:  "1024",
+				},
+				{
+					Key:          configJanitorRetentionDays,
+					Label:        "Stale Directory Retention (days)",
+					Description:  "Download directories no download in the queue or history refers to are removed once unchanged this long",
+					Type:         "number",
+					DefaultValue: "7",
+					Required:     false,
+					Placeholder:  "7",
+					Validation: &plugins.ConfigFieldValidation{
+						Min:          intPtr(1),
+						ErrorMessage: "Must be at least 1",
+					},
+				},
+				{
+					Key:          configJanitorTrashDays,
+					Label:        "Trash Retention (days)",
+					Description:  "Move removed directories to a .trash folder in the download directory and delete them after this many days. 0 deletes them straight away.",
+					Type:         "number",
+					DefaultValue: "0",
+					Required:     false,
+					Placeholder:  "0",
+				},
+				{
+					Key:          configLogRetention,
+					Label:        "Log Lines Kept",
+					Description:  "Log lines of each download kept in memory and shown with it",
+					Type:         "number",
+					DefaultValue: "200",
+					Required:     false,
+					Placeholder:  "200",
+					Validation: &plugins.ConfigFieldValidation{
+						Min:          intPtr(1),
+						Max:          intPtr(maxLogRetention),
+						ErrorMessage: "Must be between 1 and 10000",
+					},
+				},
+				{
+					Key:          configLogVerbosity,
+					Label:        "Log Verbosity",
+					Description:  "quiet leaves out per-segment warnings and progress lines, debug also copies every line to the host log",
+					Type:         "select",
+					Options:      []string{logQuiet, logNormal, logDebug},
+					DefaultValue: logNormal,
+					Required:     false,
+				},
+				{
+					Key:          configLogToFile,
+					Label:        "Write Log Files",
+					Description:  "Write the full log of each download to download.log in its directory",
+					Type:         "boolean",
+					DefaultValue: "false",
+					Required:     false,
+				},
 				{
 					Key:          configServers,
 					Label:        "NNTP Servers",
@@ -1205,6 +1621,24 @@ func (p *NZBDownloaderPlugin) UIManifest(ctx context.Context) (*plugins.UIManife
 	}, nil
 }
 
+// MigrateConfig brings config stored by older versions of the plugin up to
+// configSchemaVersion
+func (p *NZBDownloaderPlugin) MigrateConfig(ctx context.Context, fromVersion int, config map[string]interface{}) (map[string]interface{}, error) {
+	changes := make(map[string]interface{})
+	if fromVersion < 1 {
+		if raw, ok := config[configServers].(string); ok {
+			servers := []interface{}{}
+			if strings.TrimSpace(raw) != "" {
+				if err := json.Unmarshal([]byte(raw), &servers); err != nil {
+					return nil, fmt.Errorf("stored servers are not a JSON list: %w", err)
+				}
+			}
+			changes[configServers] = servers
+		}
+	}
+	return changes, nil
+}
+
 // Helper function to create int32 pointers
 func intPtr(i int32) *int32 {
 	return &i
@@ -1212,6 +1646,13 @@ func intPtr(i int32) *int32 {
 
 // HandleEvent handles system events
 func (p *NZBDownloaderPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
+	switch evt.Type {
+	case plugins.EventHostShutdown:
+		return p.shutdown(ctx, evt.SDK)
+	case plugins.EventConfigChanged:
+		key, _ := evt.Data["key"].(string)
+		return p.configChanged(ctx, evt.SDK, key)
+	}
 	return nil
 }
 
@@ -1242,7 +1683,9 @@ func (p *NZBDownloaderPlugin) cleanupFailedDownload(downloadDir string, download
 
 	removedCount := 0
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// The NZB stays so the download can be retried, and its log to see why
+		// it failed
+		if entry.IsDir() || entry.Name() == nzbFileName || entry.Name() == downloadLogName {
 			continue
 		}
 
@@ -1261,6 +1704,23 @@ func (p *NZBDownloaderPlugin) cleanupFailedDownload(downloadDir string, download
 
 // Helper functions
 
+// enabledServers returns the enabled NNTP servers from the config store
+func (p *NZBDownloaderPlugin) enabledServers(ctx context.Context) []NNTPServer {
+	sdk, err := p.getSDK()
+	if err != nil {
+		return nil
+	}
+	servers, _ := p.getServers(ctx, sdk)
+
+	var enabled []NNTPServer
+	for _, srv := range servers {
+		if srv.Enabled {
+			enabled = append(enabled, srv)
+		}
+	}
+	return enabled
+}
+
 func (p *NZBDownloaderPlugin) getServers(ctx context.Context, sdk plugins.SDKInterface) ([]NNTPServer, error) {
 	val, err := sdk.ConfigGet(ctx, configServers)
 	if err != nil {
@@ -1271,73 +1731,118 @@ func (p *NZBDownloaderPlugin) getServers(ctx context.Context, sdk plugins.SDKInt
 		return []NNTPServer{}, nil
 	}
 
+	// Stored servers are always a list, since the config was migrated to
+	// configSchemaVersion on load
 	var servers []NNTPServer
-	switch v := val.(type) {
-	case []interface{}:
-		jsonData, _ := json.Marshal(v)
-		if err := json.Unmarshal(jsonData, &servers); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR unmarshaling []interface{}: %v, data: %s\n", err, string(jsonData))
-		} else {
-			fmt.Fprintf(os.Stderr, "SUCCESS: Parsed %d servers from []interface{}\n", len(servers))
-			for i, s := range servers {
-				fmt.Fprintf(os.Stderr, "  Server %d: %s, enabled=%v\n", i, s.Name, s.Enabled)
-			}
-		}
-	case string:
-		if err := json.Unmarshal([]byte(v), &servers); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR unmarshaling string: %v, data: %s\n", err, v)
-		} else {
-			fmt.Fprintf(os.Stderr, "SUCCESS: Parsed %d servers from string\n", len(servers))
-			for i, s := range servers {
-				fmt.Fprintf(os.Stderr, "  Server %d: %s, enabled=%v\n", i, s.Name, s.Enabled)
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &servers); err != nil {
+		return nil, fmt.Errorf("invalid stored servers: %w", err)
+	}
+
+	passwords, err := p.getServerPasswords(ctx, sdk)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers saved before passwords became secrets still carry them in
+	// plaintext; move those into the secret store once
+	migrate := false
+	for i := range servers {
+		if servers[i].Password != "" {
+			if _, ok := passwords[servers[i].ID]; !ok {
+				passwords[servers[i].ID] = servers[i].Password
 			}
+			migrate = true
 		}
-	default:
-		fmt.Fprintf(os.Stderr, "WARNING: Unexpected type %T, value: %+v\n", v, v)
-		jsonData, _ := json.Marshal(v)
-		if err := json.Unmarshal(jsonData, &servers); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR unmarshaling default: %v, data: %s\n", err, string(jsonData))
-		} else {
-			fmt.Fprintf(os.Stderr, "SUCCESS: Parsed %d servers from default marshal\n", len(servers))
-			for i, s := range servers {
-				fmt.Fprintf(os.Stderr, "  Server %d: %s, enabled=%v\n", i, s.Name, s.Enabled)
-			}
+		servers[i].Password = passwords[servers[i].ID]
+	}
+	if migrate {
+		if err := p.saveServers(ctx, sdk, servers); err != nil {
+			return nil, fmt.Errorf("failed to migrate server passwords: %w", err)
 		}
 	}
 
 	return servers, nil
 }
 
+// getServerPasswords loads the server passwords. An unreadable secret is an
+// error rather than an empty map, so that saving never drops passwords.
+func (p *NZBDownloaderPlugin) getServerPasswords(ctx context.Context, sdk plugins.SDKInterface) (map[string]string, error) {
+	val, err := sdk.ConfigGetSecret(ctx, configPasswords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server passwords: %w", err)
+	}
+
+	passwords := make(map[string]string)
+	if val == nil {
+		return passwords, nil
+	}
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &passwords); err != nil {
+		return nil, fmt.Errorf("invalid server passwords: %w", err)
+	}
+	return passwords, nil
+}
+
+// saveServers stores the server list with passwords split out into a secret
 func (p *NZBDownloaderPlugin) saveServers(ctx context.Context, sdk plugins.SDKInterface, servers []NNTPServer) error {
-	return sdk.ConfigSet(ctx, configServers, servers)
+	passwords := make(map[string]string)
+	stored := make([]NNTPServer, len(servers))
+	for i, server := range servers {
+		if server.Password != "" {
+			passwords[server.ID] = server.Password
+		}
+		server.Password = ""
+		stored[i] = server
+	}
+
+	// Write the secret first so a failure never leaves servers without passwords
+	if err := sdk.ConfigSetSecret(ctx, configPasswords, passwords); err != nil {
+		return fmt.Errorf("failed to save server passwords: %w", err)
+	}
+	return sdk.ConfigSet(ctx, configServers, stored)
 }
 
 // PersistedDownload is a simplified version of Download for storage (excludes runtime fields)
 type PersistedDownload struct {
-	ID              string                 `json:"id"`
-	Name            string                 `json:"name"`
-	Status          string                 `json:"status"`
-	Progress        float64                `json:"progress"`
-	TotalBytes      int64                  `json:"total_bytes"`
-	DownloadedBytes int64                  `json:"downloaded_bytes"`
-	URL             string                 `json:"url,omitempty"`
-	FileName        string                 `json:"file_name,omitempty"`
-	Priority        int                    `json:"priority"`
-	Metadata        map[string]interface{} `json:"metadata,omitempty"`
-	AddedAt         time.Time              `json:"added_at"`
-	StartedAt       *time.Time             `json:"started_at,omitempty"`
-	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
-	Error           string                 `json:"error,omitempty"`
+	ID              string                  `json:"id"`
+	Name            string                  `json:"name"`
+	Status          string                  `json:"status"`
+	Progress        float64                 `json:"progress"`
+	TotalBytes      int64                   `json:"total_bytes"`
+	DownloadedBytes int64                   `json:"downloaded_bytes"`
+	URL             string                  `json:"url,omitempty"`
+	FileName        string                  `json:"file_name,omitempty"`
+	Priority        int                     `json:"priority"`
+	Metadata        map[string]interface{}  `json:"metadata,omitempty"`
+	AddedAt         time.Time               `json:"added_at"`
+	StartedAt       *time.Time              `json:"started_at,omitempty"`
+	CompletedAt     *time.Time              `json:"completed_at,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+	DownloadDir     string                  `json:"download_dir,omitempty"`
+	FileCount       int                     `json:"file_count,omitempty"`
+	SegmentCount    int                     `json:"segment_count,omitempty"`
+	Sequential      bool                    `json:"sequential,omitempty"`
+	MainFile        int                     `json:"main_file,omitempty"`
+	Password        string                  `json:"password,omitempty"`
+	ServerIDs       []string                `json:"server_ids,omitempty"` // Servers of the snapshot, without their passwords
+	WantedEpisodes  []plugins.WantedEpisode `json:"wanted_episodes,omitempty"`
+	SkippedFiles    []int                   `json:"skipped_files,omitempty"`
+	PostedAt        *time.Time              `json:"posted_at,omitempty"`
+	Phase           string                  `json:"phase,omitempty"`
+	PhaseProgress   *float64                `json:"phase_progress,omitempty"`
+	Phases          []PhaseTiming           `json:"phases,omitempty"`
 }
 
+// saveDownloads writes the download queue to the config store as a versioned
+// state, in turn to each of stateKeys
 func (p *NZBDownloaderPlugin) saveDownloads(ctx context.Context, sdk plugins.SDKInterface) error {
-	p.downloadManager.mu.RLock()
-	defer p.downloadManager.mu.RUnlock()
-
 	// Convert downloads to persistable format
 	persistedDownloads := make([]PersistedDownload, 0, len(p.downloadManager.queue))
+	p.downloadManager.mu.RLock()
 	for _, id := range p.downloadManager.queue {
 		if dl, exists := p.downloadManager.downloads[id]; exists {
+			dl = dl.snapshot()
 			persistedDownloads = append(persistedDownloads, PersistedDownload{
 				ID:              dl.ID,
 				Name:            dl.Name,
@@ -1353,40 +1858,43 @@ func (p *NZBDownloaderPlugin) saveDownloads(ctx context.Context, sdk plugins.SDK
 				StartedAt:       dl.StartedAt,
 				CompletedAt:     dl.CompletedAt,
 				Error:           dl.Error,
+				DownloadDir:     dl.DownloadDir,
+				FileCount:       dl.FileCount,
+				SegmentCount:    dl.SegmentCount,
+				Sequential:      dl.Sequential,
+				MainFile:        dl.MainFile,
+				Password:        dl.Password,
+				ServerIDs:       dl.ServerIDs,
+				WantedEpisodes:  dl.WantedEpisodes,
+				SkippedFiles:    dl.SkippedFiles,
+				PostedAt:        dl.PostedAt,
+				Phase:           dl.Phase,
+				PhaseProgress:   dl.PhaseProgress,
+				Phases:          dl.Phases,
 			})
 		}
 	}
+	p.downloadManager.mu.RUnlock()
 
-	return sdk.ConfigSet(ctx, configDownloads, persistedDownloads)
-}
-
-func (p *NZBDownloaderPlugin) loadDownloads(ctx context.Context, sdk plugins.SDKInterface) error {
-	val, err := sdk.ConfigGet(ctx, configDownloads)
+	key, state, err := p.encodeState(persistedDownloads)
 	if err != nil {
-		return nil // No saved downloads
+		return err
 	}
-
-	if val == nil {
-		return nil
+	if err := sdk.ConfigSet(ctx, key, state); err != nil {
+		return err
 	}
+	p.persist.persists.Add(1)
+	p.persist.bytesWritten.Add(int64(len(state.Downloads)))
+	return nil
+}
 
-	var persistedDownloads []PersistedDownload
-	switch v := val.(type) {
-	case []interface{}:
-		jsonData, _ := json.Marshal(v)
-		if err := json.Unmarshal(jsonData, &persistedDownloads); err != nil {
-			return err
-		}
-	case string:
-		if err := json.Unmarshal([]byte(v), &persistedDownloads); err != nil {
-			return err
-		}
-	default:
-		jsonData, _ := json.Marshal(v)
-		if err := json.Unmarshal(jsonData, &persistedDownloads); err != nil {
-			return err
-		}
-	}
+// loadDownloads restores the newest complete download state. Saving is held
+// off until it has run, so the saved state isn't overwritten by an empty queue.
+func (p *NZBDownloaderPlugin) loadDownloads(ctx context.Context, sdk plugins.SDKInterface) error {
+	defer p.persist.loaded.Store(true)
+
+	persistedDownloads, sequence := p.loadState(ctx, sdk)
+	p.persist.sequence.Store(sequence)
 
 	// Restore downloads to manager (skip downloads that are actively downloading)
 	p.downloadManager.mu.Lock()
@@ -1399,6 +1907,9 @@ func (p *NZBDownloaderPlugin) loadDownloads(ctx context.Context, sdk plugins.SDK
 			pd.Progress = 0
 			pd.DownloadedBytes = 0
 			pd.StartedAt = nil
+			pd.Phase = ""
+			pd.PhaseProgress = nil
+			pd.Phases = nil
 		}
 
 		download := &Download{
@@ -1416,6 +1927,19 @@ func (p *NZBDownloaderPlugin) loadDownloads(ctx context.Context, sdk plugins.SDK
 			StartedAt:       pd.StartedAt,
 			CompletedAt:     pd.CompletedAt,
 			Error:           pd.Error,
+			DownloadDir:     pd.DownloadDir,
+			FileCount:       pd.FileCount,
+			SegmentCount:    pd.SegmentCount,
+			Sequential:      pd.Sequential,
+			MainFile:        pd.MainFile,
+			Password:        pd.Password,
+			ServerIDs:       pd.ServerIDs,
+			WantedEpisodes:  pd.WantedEpisodes,
+			SkippedFiles:    pd.SkippedFiles,
+			PostedAt:        pd.PostedAt,
+			Phase:           pd.Phase,
+			PhaseProgress:   pd.PhaseProgress,
+			Phases:          pd.Phases,
 		}
 
 		p.downloadManager.downloads[download.ID] = download
@@ -1425,42 +1949,21 @@ func (p *NZBDownloaderPlugin) loadDownloads(ctx context.Context, sdk plugins.SDK
 	return nil
 }
 
-// persistDownloadState saves download state to config store and PostgreSQL database (non-blocking)
-func (p *NZBDownloaderPlugin) persistDownloadState() {
+// getSDK returns the SDK received with the first host request, or nil before then
+func (p *NZBDownloaderPlugin) getSDK() (plugins.SDKInterface, error) {
 	p.sdkMu.RLock()
-	sdk := p.sdk
-	p.sdkMu.RUnlock()
-
-	if sdk != nil {
-		// Save to config store (for plugin internal state)
-		p.saveDownloads(context.Background(), sdk)
-
-		// Also sync to PostgreSQL database (for unified /api/downloads endpoint)
-		go p.syncDownloadsToDatabase()
-	}
-}
-
-// syncDownloadsToDatabase syncs all downloads to the PostgreSQL database via internal API
-func (p *NZBDownloaderPlugin) syncDownloadsToDatabase() {
-	p.downloadManager.mu.RLock()
-	downloads := make([]*Download, 0, len(p.downloadManager.queue))
-	for _, id := range p.downloadManager.queue {
-		if dl, exists := p.downloadManager.downloads[id]; exists {
-			downloads = append(downloads, dl)
-		}
-	}
-	p.downloadManager.mu.RUnlock()
+	defer p.sdkMu.RUnlock()
 
-	// Sync each download to database via internal HTTP endpoint
-	for _, dl := range downloads {
-		p.syncDownloadToDatabase(dl)
+	if p.sdk == nil {
+		return nil, fmt.Errorf("SDK not available")
 	}
+	return p.sdk, nil
 }
 
-// syncDownloadToDatabase syncs a single download to the PostgreSQL database
-func (p *NZBDownloaderPlugin) syncDownloadToDatabase(dl *Download) {
-	// Create request payload for unified downloads API
-	payload := map[string]interface{}{
+// downloadPayload is a download as synced to the unified downloads API
+func downloadPayload(dl *Download) map[string]interface{} {
+	dl = dl.snapshot()
+	return map[string]interface{}{
 		"id":               dl.ID,
 		"plugin_id":        "nzb-downloader",
 		"name":             dl.Name,
@@ -1476,26 +1979,10 @@ func (p *NZBDownloaderPlugin) syncDownloadToDatabase(dl *Download) {
 		"created_at":       dl.AddedAt,
 		"started_at":       dl.StartedAt,
 		"completed_at":     dl.CompletedAt,
+		"phase":            dl.Phase,
+		"phase_progress":   dl.PhaseProgress,
+		"phases":           dl.Phases,
 	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return
-	}
-
-	// Call internal sync endpoint (no auth required for internal calls)
-	req, err := http.NewRequest("PUT", fmt.Sprintf("http://localhost:8080/api/internal/downloads/%s", dl.ID), strings.NewReader(string(payloadBytes)))
-	if err != nil {
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
 }
 
 func generateID() string {
@@ -1543,6 +2030,9 @@ func jsonResponse(statusCode int, data interface{}) (*plugins.PluginHTTPResponse
 	}, nil
 }
 
+// mediaExtensions are the video and audio files a download is imported from
+var mediaExtensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts", ".wmv", ".mov", ".flac", ".mp3", ".m4a"}
+
 // findAllMediaFiles finds all media files in a directory
 func findAllMediaFiles(dir string) ([]string, error) {
 	entries, err := os.ReadDir(dir)
@@ -1551,8 +2041,6 @@ func findAllMediaFiles(dir string) ([]string, error) {
 	}
 
 	var mediaFiles []string
-	mediaExtensions := []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts", ".wmv", ".mov"}
-
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -1592,8 +2080,6 @@ func findMainMediaFile(dir string) (string, error) {
 	var largestFile string
 	var largestSize int64
 
-	mediaExtensions := []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts", ".wmv", ".mov"}
-
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -1660,31 +2146,124 @@ func shouldImport(metadata map[string]interface{}) bool {
 }
 
 // parseEpisodeFromFilename extracts season and episode numbers from a filename
-// Supports patterns like: S01E01, s01e01, 1x01, etc.
-func parseEpisodeFromFilename(filename string) (season int, episode int, found bool) {
+// Supports patterns like: S01E01, s01e01, 1x01, etc. For a multi-episode file
+// like S01E01E02 or S01E01-E02 lastEpisode is the last episode it covers, else
+// it equals episode.
+func parseEpisodeFromFilename(filename string) (season int, episode int, lastEpisode int, found bool) {
 	lowerName := strings.ToLower(filename)
 
 	// Pattern 1: S01E01 or s01e01
-	re := regexp.MustCompile(`s(\d+)e(\d+)`)
-	if matches := re.FindStringSubmatch(lowerName); len(matches) == 3 {
-		fmt.Sscanf(matches[1], "%d", &season)
-		fmt.Sscanf(matches[2], "%d", &episode)
-		return season, episode, true
+	// Pattern 2: 1x01
+	for _, re := range []*regexp.Regexp{episodePattern, episodeXPattern} {
+		match := re.FindStringSubmatchIndex(lowerName)
+		if match == nil {
+			continue
+		}
+		season, _ = strconv.Atoi(lowerName[match[2]:match[3]])
+		episode, _ = strconv.Atoi(lowerName[match[4]:match[5]])
+		return season, episode, parseLastEpisode(lowerName[match[1]:], episode), true
 	}
 
-	// Pattern 2: 1x01
-	re = regexp.MustCompile(`(\d+)x(\d+)`)
-	if matches := re.FindStringSubmatch(lowerName); len(matches) == 3 {
-		fmt.Sscanf(matches[1], "%d", &season)
-		fmt.Sscanf(matches[2], "%d", &episode)
-		return season, episode, true
+	return 0, 0, 0, false
+}
+
+// parseAbsoluteFromFilename extracts the absolute episode number of an anime
+// file named like "[Group] Show - 125 [1080p].mkv"
+func parseAbsoluteFromFilename(filename string) (absolute int, found bool) {
+	name := strings.ReplaceAll(strings.TrimSuffix(filename, filepath.Ext(filename)), "_", " ")
+	name = leadingTagsPattern.ReplaceAllString(name, "")
+	match := absoluteEpisodePattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	absolute, _ = strconv.Atoi(match[1])
+	if len(match[1]) == 4 && absolute >= 1900 && absolute < 2100 {
+		return 0, false // A year
+	}
+	return absolute, true
+}
+
+var (
+	episodePattern      = regexp.MustCompile(`s(\d+)e(\d+)`)
+	episodeXPattern     = regexp.MustCompile(`(\d+)x(\d+)`)
+	multiEpisodePattern = regexp.MustCompile(`^(?:-?e|-)(\d{1,2})`)
+
+	absoluteEpisodePattern = regexp.MustCompile(`^.+?\s+-\s+(\d{2,4})(?:v\d)?(?:\s|[\[\(]|$)`)
+	leadingTagsPattern     = regexp.MustCompile(`^(?:\s*\[[^\]]*\])+\s*`)
+)
+
+// parseLastEpisode reads the further episodes of a multi-episode file from what
+// follows its first episode and returns the last one. Each must follow the one
+// before it, so a resolution like "-1080p" isn't mistaken for an episode.
+func parseLastEpisode(rest string, episode int) int {
+	last := episode
+	for {
+		match := multiEpisodePattern.FindStringSubmatchIndex(rest)
+		if match == nil || (match[1] < len(rest) && rest[match[1]] >= '0' && rest[match[1]] <= '9') {
+			return last
+		}
+		number, _ := strconv.Atoi(rest[match[2]:match[3]])
+		if number <= last {
+			return last
+		}
+		last, rest = number, rest[match[1]:]
+	}
+}
+
+// findEpisodeMediaID looks up the media_item_id of an episode of a season
+func (p *NZBDownloaderPlugin) findEpisodeMediaID(seasonMediaID interface{}, season int, episode int) (int64, error) {
+	episodes, err := p.listSeasonEpisodes(seasonMediaID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the episode with matching season/episode numbers
+	for _, item := range episodes {
+		if meta := item.Metadata; meta != nil {
+			itemSeason, _ := meta["season"].(float64)
+			itemEpisode, _ := meta["episode"].(float64)
+			if int(itemSeason) == season && int(itemEpisode) == episode {
+				return item.ID, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("episode S%02dE%02d not found in database", season, episode)
+}
+
+// findAbsoluteEpisodeMediaID looks up the media_item_id of an episode of a
+// season by its absolute number, as anime is named. Without absolute numbers
+// in the metadata the episode number is taken for it.
+func (p *NZBDownloaderPlugin) findAbsoluteEpisodeMediaID(seasonMediaID interface{}, absolute int) (int64, error) {
+	episodes, err := p.listSeasonEpisodes(seasonMediaID)
+	if err != nil {
+		return 0, err
+	}
+
+	var byEpisode int64
+	for _, item := range episodes {
+		if item.Metadata == nil {
+			continue
+		}
+		if number, ok := item.Metadata["absolute_number"].(float64); ok {
+			if int(number) == absolute {
+				return item.ID, nil
+			}
+			continue
+		}
+		if number, _ := item.Metadata["episode"].(float64); int(number) == absolute {
+			byEpisode = item.ID
+		}
+	}
+	if byEpisode != 0 {
+		return byEpisode, nil
 	}
 
-	return 0, 0, false
+	return 0, fmt.Errorf("episode %d not found in database", absolute)
 }
 
-// findEpisodeMediaID queries the Nimbus API to find the episode media_item_id
-func findEpisodeMediaID(seasonMediaID interface{}, season int, episode int) (int64, error) {
+// listSeasonEpisodes lists the episodes of a season
+func (p *NZBDownloaderPlugin) listSeasonEpisodes(seasonMediaID interface{}) ([]*plugins.MediaItem, error) {
 	// Convert seasonMediaID to int64
 	var seasonID int64
 	switch v := seasonMediaID.(type) {
@@ -1697,163 +2276,248 @@ func findEpisodeMediaID(seasonMediaID interface{}, season int, episode int) (int
 	case string:
 		parsed, err := fmt.Sscanf(v, "%d", &seasonID)
 		if err != nil || parsed != 1 {
-			return 0, fmt.Errorf("invalid season media_id format: %v", v)
+			return nil, fmt.Errorf("invalid season media_id format: %v", v)
 		}
 	default:
-		return 0, fmt.Errorf("unsupported season media_id type: %T", v)
+		return nil, fmt.Errorf("unsupported season media_id type: %T", v)
 	}
 
-	// Query the internal API for episodes of this season (no auth required)
-	url := fmt.Sprintf("http://localhost:8080/api/internal/media?parent_id=%d&kind=tv_episode", seasonID)
-	resp, err := http.Get(url)
+	sdk, err := p.getSDK()
 	if err != nil {
-		return 0, fmt.Errorf("failed to query episodes: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("API returned error: %s", string(body))
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	var result struct {
-		Items []struct {
-			ID       int64                  `json:"id"`
-			Metadata map[string]interface{} `json:"metadata"`
-		} `json:"items"`
+	episodes, err := sdk.MediaList(ctx, seasonID, "tv_episode")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query episodes: %v", err)
 	}
+	return episodes, nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, fmt.Errorf("failed to decode response: %v", err)
+// findAbsoluteEpisode finds the episode of a season pack file named by
+// absolute episode number. A file whose episode isn't found is queued for a
+// manual import.
+func (p *NZBDownloaderPlugin) findAbsoluteEpisode(download *Download, file string, seasonMediaID interface{}, absolute int) (int64, bool) {
+	episodeMediaID, err := p.findAbsoluteEpisodeMediaID(seasonMediaID, absolute)
+	if err != nil {
+		download.AddLog(fmt.Sprintf("  Could not find episode in database: %v", err))
+		p.reportFailedImport(download, file, fmt.Sprintf("Could not find episode in database: %v", err), map[string]interface{}{
+			"absolute": absolute,
+		})
+		return 0, false
 	}
 
-	// Find the episode with matching season/episode numbers
-	for _, item := range result.Items {
-		if meta := item.Metadata; meta != nil {
-			itemSeason, _ := meta["season"].(float64)
-			itemEpisode, _ := meta["episode"].(float64)
-			if int(itemSeason) == season && int(itemEpisode) == episode {
-				return item.ID, nil
-			}
-		}
-	}
+	download.AddLog(fmt.Sprintf("  Found episode media_id: %d", episodeMediaID))
+	return episodeMediaID, true
+}
 
-	return 0, fmt.Errorf("episode S%02dE%02d not found in database", season, episode)
+// episodeImports imports the episode files of a season pack in the background
+type episodeImports struct {
+	plugin    *NZBDownloaderPlugin
+	download  *Download
+	wg        sync.WaitGroup
+	succeeded atomic.Int32
+	failed    atomic.Int32
+	total     int // Episode files of the season pack, for the import progress
 }
 
-// importEpisodeFile imports a single episode file using the import API
-func importEpisodeFile(sourcePath string, mediaItemID int64) error {
-	importReq := map[string]interface{}{
-		"source_path":   sourcePath,
-		"media_item_id": mediaItemID,
-	}
+// start imports an episode file, linking it to the further episodes of a
+// multi-episode file
+func (e *episodeImports) start(file string, mediaItemID int64, extraMediaItemIDs []int64) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer e.reportProgress()
+		name := filepath.Base(file)
+		if err := e.plugin.importEpisodeFile(e.download, file, mediaItemID, extraMediaItemIDs); err != nil {
+			e.download.AddLog(fmt.Sprintf("  Import of %s failed: %v", name, err))
+			e.failed.Add(1)
+			return
+		}
+		e.download.AddLog(fmt.Sprintf("  Imported %s", name))
+		e.succeeded.Add(1)
+	}()
+}
 
-	reqBody, err := json.Marshal(importReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %v", err)
+// reportProgress records the share of the episode files imported so far
+func (e *episodeImports) reportProgress() {
+	if e.total > 0 {
+		done := e.succeeded.Load() + e.failed.Load()
+		e.download.setPhaseProgress(float64(done) / float64(e.total) * 100)
 	}
+}
 
-	req, err := http.NewRequest("POST", "http://localhost:8080/api/downloads/import", strings.NewReader(string(reqBody)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+// wait waits for the started imports and returns how many succeeded and failed
+func (e *episodeImports) wait() (succeeded, failed int) {
+	e.wg.Wait()
+	return int(e.succeeded.Load()), int(e.failed.Load())
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// importEpisodeFile imports a single episode file through the SDK, linking it
+// to the further episodes of a multi-episode file
+func (p *NZBDownloaderPlugin) importEpisodeFile(download *Download, sourcePath string, mediaItemID int64, extraMediaItemIDs []int64) error {
+	sdk, err := p.getSDK()
 	if err != nil {
-		return fmt.Errorf("failed to call import API: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("import API error: %s", string(body))
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
 
-	return nil
+	req := importFileRequest(download, sourcePath, mediaItemID)
+	req.ExtraMediaItemIDs = extraMediaItemIDs
+	_, err = sdk.ImportRequest(ctx, req)
+	return err
 }
 
-// importToLibrary calls the Nimbus import API to import completed download
-func importToLibrary(download *Download, sourcePath string) error {
-	// Build basic import request
-	importReq := map[string]interface{}{
-		"source_path": sourcePath,
-	}
-
-	// If we have a media_item_id, that's all the backend needs to look up the details
-	if mediaID, ok := download.Metadata["media_id"]; ok {
-		// Convert media_id to int64 (it might be a string or float64 from JSON)
-		var mediaItemID int64
-		switch v := mediaID.(type) {
-		case int:
-			mediaItemID = int64(v)
-		case int64:
-			mediaItemID = v
-		case float64:
-			mediaItemID = int64(v)
-		case string:
-			// Try to parse string as int
-			parsed, err := fmt.Sscanf(v, "%d", &mediaItemID)
-			if err != nil || parsed != 1 {
-				return fmt.Errorf("invalid media_id format: %v", v)
-			}
-		default:
-			return fmt.Errorf("unsupported media_id type: %T", v)
-		}
+// importFileRequest builds the host import request for a file of a download.
+// The release name travels with it so the host can record the quality and
+// release group of the imported file.
+func importFileRequest(download *Download, sourcePath string, mediaItemID int64) plugins.ImportFileRequest {
+	req := plugins.ImportFileRequest{
+		DownloadID:   download.ID,
+		SourcePath:   sourcePath,
+		MediaItemID:  mediaItemID,
+		ReleaseTitle: download.Name,
+		TransferMode: "move", // The downloaded files aren't needed after the import
+		Disposable:   true,
+	}
+	if title, ok := download.Metadata["release_title"].(string); ok && title != "" {
+		req.ReleaseTitle = title
+	}
+	if quality, ok := download.Metadata["quality"].(string); ok {
+		req.Quality = quality
+	}
+	if group, ok := download.Metadata["release_group"].(string); ok {
+		req.ReleaseGroup = group
+	}
+	return req
+}
 
-		importReq["media_item_id"] = mediaItemID
-		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Importing with media_item_id: %d\n", mediaItemID)
-	} else {
-		// No media_item_id, skip import - we can't import without knowing what media item this is for
+// importToLibrary imports a completed download through the SDK
+func (p *NZBDownloaderPlugin) importToLibrary(download *Download, sourcePath string) error {
+	// The media_item_id is all the host needs to look up the details
+	mediaID, ok := download.Metadata["media_id"]
+	if !ok {
+		// We can't import without knowing what media item this is for
+		p.reportFailedImport(download, sourcePath, "No media_item_id found in download metadata", nil)
 		return fmt.Errorf("no media_item_id found in download metadata - cannot import")
 	}
 
-	// Marshal request
-	reqBody, err := json.Marshal(importReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal import request: %v", err)
+	// Convert media_id to int64 (it might be a string or float64 from JSON)
+	var mediaItemID int64
+	switch v := mediaID.(type) {
+	case int:
+		mediaItemID = int64(v)
+	case int64:
+		mediaItemID = v
+	case float64:
+		mediaItemID = int64(v)
+	case string:
+		// Try to parse string as int
+		parsed, err := fmt.Sscanf(v, "%d", &mediaItemID)
+		if err != nil || parsed != 1 {
+			return fmt.Errorf("invalid media_id format: %v", v)
+		}
+	default:
+		return fmt.Errorf("unsupported media_id type: %T", v)
 	}
+	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Importing with media_item_id: %d\n", mediaItemID)
 
-	// Call import endpoint
-	req, err := http.NewRequest("POST", "http://localhost:8080/api/downloads/import", strings.NewReader(string(reqBody)))
+	sdk, err := p.getSDK()
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	result, err := sdk.ImportRequest(ctx, importFileRequest(download, sourcePath, mediaItemID))
 	if err != nil {
-		return fmt.Errorf("failed to call import API: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("import API returned error: %s", string(body))
+	if result.FinalPath != "" {
+		download.AddLog(fmt.Sprintf("Imported to: %s", result.FinalPath))
 	}
 
-	// Parse response to get final path
-	var importResult map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&importResult); err != nil {
-		return fmt.Errorf("failed to decode import response: %v", err)
+	return nil
+}
+
+// markWaitingImport leaves the files of a download in place until they are imported manually
+func (p *NZBDownloaderPlugin) markWaitingImport(download *Download, reason string) {
+	download.AddLog(fmt.Sprintf("Waiting for manual import: %s", reason))
+	download.update(func() {
+		download.Status = "waiting_import"
+		download.Error = reason
+		download.endPhaseLocked(time.Now())
+	})
+	p.persistDownloadState()
+}
+
+// reportFailedImport adds a file that could not be imported to the Nimbus manual import queue
+func (p *NZBDownloaderPlugin) reportFailedImport(download *Download, sourcePath string, reason string, detected map[string]interface{}) {
+	if detected == nil {
+		detected = map[string]interface{}{}
+	}
+	detected["release_name"] = download.Name
+	detected["file_name"] = filepath.Base(sourcePath)
+	for _, key := range []string{"title", "media_type", "media_kind", "year", "season", "episode", "quality"} {
+		if _, exists := detected[key]; exists {
+			continue
+		}
+		if value, ok := download.Metadata[key]; ok && value != nil {
+			detected[key] = value
+		}
 	}
 
-	if finalPath, ok := importResult["final_path"].(string); ok {
-		download.AddLog(fmt.Sprintf("Imported to: %s", finalPath))
+	sdk, err := p.getSDK()
+	if err != nil {
+		download.AddLog(fmt.Sprintf("Failed to queue manual import: %v", err))
+		return
 	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = sdk.ImportFailed(ctx, plugins.FailedImportRequest{
+		DownloadID: download.ID,
+		SourcePath: sourcePath,
+		Reason:     reason,
+		Detected:   detected,
+	})
+	if err != nil {
+		download.AddLog(fmt.Sprintf("Failed to queue manual import: %v", err))
+	}
 }
 
 func main() {
+	// Imports and download syncs go through the SDK, but anything reaching the
+	// host API directly needs the address and token the host passes in
+	if _, err := plugins.InternalAPIFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Internal API is not configured, direct API callbacks will fail: %v\n", err)
+	}
+
 	nzbPlugin := &NZBDownloaderPlugin{
 		downloadManager: NewDownloadManager(1), // Max 1 concurrent download (each uses many connections)
+		persist:         newPersister(),
+		tools:           detectExtractTools(),
+	}
+	if missing := nzbPlugin.tools.missing(); len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Extraction tools not found: %s. Zip and stored RAR archives are extracted without them\n",
+			strings.Join(missing, ", "))
+	}
+	if nzbPlugin.tools.Par2 == "" {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] par2 not found. Downloads are extracted without being verified or repaired\n")
 	}
 
 	// Start the download queue processor
 	go nzbPlugin.processDownloadQueue(nzbPlugin.downloadManager.ctx)
+	go nzbPlugin.runPersister(nzbPlugin.downloadManager.ctx)
+	go nzbPlugin.runJanitor(nzbPlugin.downloadManager.ctx)
 
 	plugin.Serve(&plugin.ServeConfig{
 		HandshakeConfig: plugins.Handshake,
@@ -1862,6 +2526,6 @@ func main() {
 				Impl: nzbPlugin,
 			},
 		},
-		GRPCServer: plugin.DefaultGRPCServer,
+		GRPCServer: grpcServer,
 	})
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+func TestAdminRoutesRequireScope(t *testing.T) {
+	p := &NZBDownloaderPlugin{}
+	tests := []struct {
+		method, path string
+		admin        bool
+	}{
+		{"DELETE", "/api/plugins/nzb-downloader/servers/1", true},
+		{"POST", "/api/plugins/nzb-downloader/config", true},
+		{"DELETE", "/api/plugins/nzb-downloader/downloads", true},
+		{"POST", "/api/plugins/nzb-downloader/janitor/run", true},
+		{"GET", "/api/plugins/nzb-downloader/downloads", false},
+	}
+	for _, tt := range tests {
+		req := &plugins.PluginHTTPRequest{Method: tt.method, Path: tt.path, Scopes: []string{plugins.ScopeUser}}
+		if got := isAdminPath(req); got != tt.admin {
+			t.Errorf("isAdminPath(%s %s) = %v, want %v", tt.method, tt.path, got, tt.admin)
+		}
+		if !tt.admin {
+			continue
+		}
+		resp, err := p.HandleAPI(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s as user = %d, want 403", tt.method, tt.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestParseEpisodeFromFilename(t *testing.T) {
+	tests := []struct {
+		name                  string
+		season, episode, last int
+		found                 bool
+	}{
+		{"Show.S01E05.1080p.WEB.mkv", 1, 5, 5, true},
+		{"Show.S01E01E02.1080p.mkv", 1, 1, 2, true},
+		{"Show.S02E03-E04.720p.mkv", 2, 3, 4, true},
+		{"Show.S01E01-02-03.mkv", 1, 1, 3, true},
+		{"Show.S01E01-1080p.mkv", 1, 1, 1, true},
+		{"Show.S01E05E03.mkv", 1, 5, 5, true},
+		{"Show 3x07.mkv", 3, 7, 7, true},
+		{"Movie.2020.1080p.mkv", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		season, episode, last, found := parseEpisodeFromFilename(tt.name)
+		if season != tt.season || episode != tt.episode || last != tt.last || found != tt.found {
+			t.Errorf("parseEpisodeFromFilename(%q) = %d, %d, %d, %v, want %d, %d, %d, %v",
+				tt.name, season, episode, last, found, tt.season, tt.episode, tt.last, tt.found)
+		}
+	}
+}
+
+func TestParseAbsoluteFromFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		absolute int
+		found    bool
+	}{
+		{"[SubsPlease] One Piece - 1071 (1080p) [ABCD1234].mkv", 1071, true},
+		{"[Group]_Show_Name_-_05v2_[720p].mkv", 5, true},
+		{"Show - 12.mkv", 12, true},
+		{"Show - 2019 [1080p].mkv", 0, false},
+		{"Show.S01E05.1080p.mkv", 0, false},
+	}
+
+	for _, tt := range tests {
+		absolute, found := parseAbsoluteFromFilename(tt.name)
+		if absolute != tt.absolute || found != tt.found {
+			t.Errorf("parseAbsoluteFromFilename(%q) = %d, %v, want %d, %v", tt.name, absolute, found, tt.absolute, tt.found)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -10,6 +11,9 @@ import (
 	"strings"
 )
 
+// errArticleMissing is returned for articles the server doesn't have
+var errArticleMissing = errors.New("article not found")
+
 // NNTPClient represents an NNTP client connection
 type NNTPClient struct {
 	conn   net.Conn
@@ -19,7 +23,7 @@ type NNTPClient struct {
 
 // DialNNTP connects to an NNTP server
 func DialNNTP(host string, port int, useSSL bool) (*NNTPClient, error) {
-	address := fmt.Sprintf("%s:%d", host, port)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
 
 	var conn net.Conn
 	var err error
@@ -80,13 +84,13 @@ func (c *NNTPClient) Authenticate(username, password string) error {
 		return err
 	}
 
-	code, _, err := c.readResponse()
+	code, message, err := c.readResponse()
 	if err != nil {
 		return err
 	}
 
 	if code != 381 { // 381 = Password required
-		return fmt.Errorf("unexpected response to USER command: %d", code)
+		return fmt.Errorf("unexpected response to USER command: %d %s", code, message)
 	}
 
 	// Send password
@@ -94,13 +98,13 @@ func (c *NNTPClient) Authenticate(username, password string) error {
 		return err
 	}
 
-	code, _, err = c.readResponse()
+	code, message, err = c.readResponse()
 	if err != nil {
 		return err
 	}
 
 	if code != 281 { // 281 = Authentication successful
-		return fmt.Errorf("authentication failed: %d", code)
+		return fmt.Errorf("credentials rejected: %d %s", code, message)
 	}
 
 	return nil
@@ -120,8 +124,11 @@ func (c *NNTPClient) GetArticle(messageID string) ([]byte, error) {
 		return nil, err
 	}
 
+	if code == 423 || code == 430 { // No such article
+		return nil, fmt.Errorf("%w: %d", errArticleMissing, code)
+	}
 	if code != 220 { // 220 = Article follows
-		return nil, fmt.Errorf("article not found: %d", code)
+		return nil, fmt.Errorf("unexpected response to ARTICLE: %d", code)
 	}
 
 	// Read article body until "." on a line by itself
@@ -2,18 +2,14 @@ package main
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
-// NZB represents the root element of an NZB file
-type NZB struct {
-	XMLName  xml.Name  `xml:"nzb"`
-	Head     NZBHead   `xml:"head"`
-	Files    []NZBFile `xml:"file"`
-	Password string    `xml:"-"` // Extracted password from head metadata
-}
-
 // NZBHead represents the head section of an NZB file
 type NZBHead struct {
 	Meta []NZBMeta `xml:"meta"`
@@ -220,33 +216,148 @@ func removeSuffixPattern(s string, pattern string) string {
 	return s
 }
 
-// ParseNZB parses an NZB file from a reader
-func ParseNZB(r io.Reader) (*NZB, error) {
-	var nzb NZB
-	decoder := xml.NewDecoder(r)
+// nzbFileName is the name of the NZB a download keeps in its directory
+const nzbFileName = "job.nzb"
 
-	if err := decoder.Decode(&nzb); err != nil {
-		return nil, err
-	}
+// NZBSummary holds the totals of an NZB, gathered without keeping its files
+type NZBSummary struct {
+	Files         int
+	Segments      int
+	TotalBytes    int64
+	FirstFileName string // Name attribute of the first file, if any
+	Password      string
 
-	// Extract password from head metadata if present
-	for _, meta := range nzb.Head.Meta {
-		if meta.Type == "password" {
-			nzb.Password = strings.TrimSpace(meta.Value)
+	// The largest file, which for a release that isn't packed is the media
+	LargestFile      int // Index of the file in the NZB
+	LargestFileName  string
+	LargestFileBytes int64
+
+	// PostedAt is when the oldest file was posted, nil when no file has a date
+	PostedAt *time.Time
+}
+
+// StreamNZB decodes an NZB one file at a time, calling fn with each file in
+// order. Only the current file is held in memory. The head, which comes
+// before the files, is returned once the whole NZB has been read.
+func StreamNZB(r io.Reader, fn func(index int, file *NZBFile) error) (*NZBHead, error) {
+	decoder := xml.NewDecoder(r)
+	head := &NZBHead{}
+	index := 0
+	sawRoot := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "nzb":
+			sawRoot = true
+		case "head":
+			if err := decoder.DecodeElement(head, &start); err != nil {
+				return nil, err
+			}
+		case "file":
+			var file NZBFile
+			if err := decoder.DecodeElement(&file, &start); err != nil {
+				return nil, err
+			}
+			if err := fn(index, &file); err != nil {
+				return nil, err
+			}
+			index++
+		}
 	}
 
-	return &nzb, nil
+	if !sawRoot {
+		return nil, fmt.Errorf("not an NZB file")
+	}
+	return head, nil
 }
 
-// TotalBytes calculates the total size of all files
-func (n *NZB) TotalBytes() int64 {
-	var total int64
-	for _, file := range n.Files {
+// ScanNZB reads an NZB and returns its totals
+func ScanNZB(r io.Reader) (*NZBSummary, error) {
+	summary := &NZBSummary{}
+	head, err := StreamNZB(r, func(index int, file *NZBFile) error {
+		if index == 0 {
+			summary.FirstFileName = file.FileName
+		}
+		summary.Files++
+		summary.Segments += len(file.Segments)
+		var size int64
 		for _, seg := range file.Segments {
-			total += seg.Bytes
+			size += seg.Bytes
+		}
+		summary.TotalBytes += size
+		if file.Date > 0 {
+			posted := time.Unix(file.Date, 0)
+			if summary.PostedAt == nil || posted.Before(*summary.PostedAt) {
+				summary.PostedAt = &posted
+			}
 		}
+		if index == 0 || size > summary.LargestFileBytes {
+			summary.LargestFile = index
+			summary.LargestFileName = file.Filename()
+			summary.LargestFileBytes = size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Password = head.password()
+	return summary, nil
+}
+
+// SaveNZB writes an NZB to path while scanning it, so the NZB is read only once
+// however it arrives. Nothing is left at path if the NZB can't be parsed.
+func SaveNZB(r io.Reader, path string) (*NZBSummary, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	tee := io.TeeReader(r, f)
+	summary, err := ScanNZB(tee)
+	if err == nil {
+		// Keep whatever follows the closing tag, like the original file
+		_, err = io.Copy(io.Discard, tee)
 	}
-	return total
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// password returns the password given in the head metadata, if any
+func (h *NZBHead) password() string {
+	for _, meta := range h.Meta {
+		if meta.Type == "password" {
+			return strings.TrimSpace(meta.Value)
+		}
+	}
+	return ""
 }
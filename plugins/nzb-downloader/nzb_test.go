@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNZB = `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+  <head>
+    <meta type="title">Show.S01E05.1080p.WEB.H264-GROUP</meta>
+    <meta type="password">secret</meta>
+  </head>
+  <file poster="poster" date="1700000000" subject="&quot;show.rar&quot; yEnc (1/2)">
+    <groups><group>alt.binaries.test</group></groups>
+    <segments>
+      <segment bytes="100" number="1">part1@example</segment>
+      <segment bytes="50" number="2">part2@example</segment>
+    </segments>
+  </file>
+  <file poster="poster" date="1700000000" subject="&quot;show.par2&quot; yEnc (1/1)">
+    <groups><group>alt.binaries.test</group></groups>
+    <segments>
+      <segment bytes="10" number="1">par@example</segment>
+    </segments>
+  </file>
+</nzb>
+`
+
+func TestSaveNZB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dl_1", nzbFileName)
+	summary, err := SaveNZB(strings.NewReader(testNZB), path)
+	if err != nil {
+		t.Fatalf("SaveNZB() error = %v", err)
+	}
+	if summary.Files != 2 || summary.Segments != 3 || summary.TotalBytes != 160 || summary.Password != "secret" {
+		t.Errorf("SaveNZB() summary = %+v", summary)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil || string(saved) != testNZB {
+		t.Errorf("saved NZB differs from the original (err %v)", err)
+	}
+
+	var names []string
+	f, _ := os.Open(path)
+	defer f.Close()
+	if _, err := StreamNZB(f, func(index int, file *NZBFile) error {
+		names = append(names, file.Filename())
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamNZB() error = %v", err)
+	}
+	if strings.Join(names, ",") != "show.rar,show.par2" {
+		t.Errorf("StreamNZB() files = %v", names)
+	}
+}
+
+func TestSaveNZBRejectsOtherXML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), nzbFileName)
+	if _, err := SaveNZB(strings.NewReader("<html><body>Not found</body></html>"), path); err == nil {
+		t.Fatal("SaveNZB() accepted a non-NZB document")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("SaveNZB() left a file behind")
+	}
+}
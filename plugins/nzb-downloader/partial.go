@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// A season pack grabbed for a few missing episodes only needs the files of
+// those episodes. Season packs are mostly posted as an archive (with its par2
+// files) per episode, named after the episode, so the files of an NZB can be
+// matched to episodes by name and the rest skipped. Obfuscated packs whose
+// files can't be matched are downloaded whole.
+
+// episodeSelection is the part of an NZB holding the wanted episodes
+type episodeSelection struct {
+	Skipped     []int // Indexes of the files left out
+	Files       int
+	Segments    int
+	TotalBytes  int64
+	SkipBytes   int64
+	LargestFile int // Index of the largest file kept
+
+	Missing []plugins.WantedEpisode // Wanted episodes no file was found for
+}
+
+// selectEpisodes matches the files of an NZB against the wanted episodes. It
+// returns nil when no file can be matched to a wanted episode, and the whole
+// NZB has to be downloaded.
+func selectEpisodes(r io.Reader, wanted []plugins.WantedEpisode) (*episodeSelection, error) {
+	sel := &episodeSelection{LargestFile: -1}
+	found := make(map[plugins.WantedEpisode]bool)
+	var largest int64
+
+	_, err := StreamNZB(r, func(index int, file *NZBFile) error {
+		var size int64
+		for _, seg := range file.Segments {
+			size += seg.Bytes
+		}
+
+		matched := fileEpisodes(file, wanted)
+		if len(matched) == 0 {
+			sel.Skipped = append(sel.Skipped, index)
+			sel.SkipBytes += size
+			return nil
+		}
+		for _, ep := range matched {
+			found[ep] = true
+		}
+
+		sel.Files++
+		sel.Segments += len(file.Segments)
+		sel.TotalBytes += size
+		if sel.LargestFile < 0 || size > largest {
+			sel.LargestFile = index
+			largest = size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sel.Files == 0 {
+		return nil, nil
+	}
+
+	for _, ep := range wanted {
+		if !found[ep] {
+			sel.Missing = append(sel.Missing, ep)
+		}
+	}
+	return sel, nil
+}
+
+// fileEpisodes returns the wanted episodes a file of an NZB belongs to, going
+// by its name or else its subject
+func fileEpisodes(file *NZBFile, wanted []plugins.WantedEpisode) []plugins.WantedEpisode {
+	season, episode, last, ok := parseEpisodeFromFilename(file.Filename())
+	if !ok {
+		if season, episode, last, ok = parseEpisodeFromFilename(file.Subject); !ok {
+			return nil
+		}
+	}
+
+	var matched []plugins.WantedEpisode
+	for _, ep := range wanted {
+		if ep.Season == season && ep.Episode >= episode && ep.Episode <= last {
+			matched = append(matched, ep)
+		}
+	}
+	return matched
+}
+
+// selectWantedEpisodes limits a new download to the files of its wanted
+// episodes, or leaves it whole with a note when they can't be told apart
+func (download *Download) selectWantedEpisodes(summary *NZBSummary) {
+	f, err := os.Open(filepath.Join(downloadDir(download), nzbFileName))
+	if err != nil {
+		download.AddLog(fmt.Sprintf("Failed to read NZB to select episodes, downloading everything: %v", err))
+		return
+	}
+	defer f.Close()
+
+	sel, err := selectEpisodes(f, download.WantedEpisodes)
+	if err != nil {
+		download.AddLog(fmt.Sprintf("Failed to read NZB to select episodes, downloading everything: %v", err))
+		return
+	}
+	if sel == nil {
+		download.AddLog(fmt.Sprintf("No files could be matched to %s, likely obfuscated names, downloading everything", describeEpisodes(download.WantedEpisodes)))
+		return
+	}
+
+	download.applySelection(sel)
+	download.AddLog(fmt.Sprintf("Downloading %d of %d files for %s, skipping %d files (%.2f GB)",
+		sel.Files, summary.Files, describeEpisodes(download.WantedEpisodes), len(sel.Skipped), float64(sel.SkipBytes)/(1<<30)))
+	if len(sel.Missing) > 0 {
+		download.AddLog(fmt.Sprintf("No files found for %s", describeEpisodes(sel.Missing)))
+	}
+}
+
+// applySelection limits a download to the files of its wanted episodes
+func (d *Download) applySelection(sel *episodeSelection) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.SkippedFiles = sel.Skipped
+	d.FileCount = sel.Files
+	d.SegmentCount = sel.Segments
+	d.TotalBytes = sel.TotalBytes
+	d.MainFile = sel.LargestFile
+}
+
+// describeEpisodes lists episodes like "S01E03, S01E05"
+func describeEpisodes(episodes []plugins.WantedEpisode) string {
+	names := make([]string, len(episodes))
+	for i, ep := range episodes {
+		names[i] = fmt.Sprintf("S%02dE%02d", ep.Season, ep.Episode)
+	}
+	return strings.Join(names, ", ")
+}
+
+// skippedSet returns the files of a download that are left out
+func skippedSet(download *Download) map[int]bool {
+	if len(download.SkippedFiles) == 0 {
+		return nil
+	}
+	skipped := make(map[int]bool, len(download.SkippedFiles))
+	for _, index := range download.SkippedFiles {
+		skipped[index] = true
+	}
+	return skipped
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// packNZB builds a season pack NZB with an archive and a par2 file for each
+// episode, and an nfo for the pack. Archives are 100 bytes and the rest 10.
+func packNZB(episodes int, obfuscated bool) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">` + "\n")
+	file := func(name string, bytes int) {
+		fmt.Fprintf(&b, `<file poster="p" date="1700000000" subject="&quot;%s&quot; yEnc (1/1)"><segments><segment bytes="%d" number="1">%s@example</segment></segments></file>`+"\n", name, bytes, name)
+	}
+	file("Show.S01.1080p.nfo", 10)
+	for ep := 1; ep <= episodes; ep++ {
+		name := fmt.Sprintf("Show.S01E%02d.1080p", ep)
+		if obfuscated {
+			name = fmt.Sprintf("a8f3c%02d", ep)
+		}
+		file(name+".rar", 100)
+		file(name+".par2", 10)
+	}
+	b.WriteString("</nzb>\n")
+	return b.String()
+}
+
+func TestSelectEpisodes(t *testing.T) {
+	wanted := []plugins.WantedEpisode{{Season: 1, Episode: 2}, {Season: 1, Episode: 4}, {Season: 1, Episode: 9}}
+	sel, err := selectEpisodes(strings.NewReader(packNZB(4, false)), wanted)
+	if err != nil || sel == nil {
+		t.Fatalf("selectEpisodes() = %v, %v", sel, err)
+	}
+
+	// The nfo and the files of episodes 1 and 3 are skipped
+	if want := []int{0, 1, 2, 5, 6}; !reflect.DeepEqual(sel.Skipped, want) {
+		t.Errorf("skipped = %v, want %v", sel.Skipped, want)
+	}
+	if sel.Files != 4 || sel.Segments != 4 || sel.TotalBytes != 220 || sel.SkipBytes != 230 {
+		t.Errorf("selection = %d files, %d segments, %d bytes, %d skipped", sel.Files, sel.Segments, sel.TotalBytes, sel.SkipBytes)
+	}
+	if sel.LargestFile != 3 {
+		t.Errorf("largest file = %d, want the archive of episode 2", sel.LargestFile)
+	}
+	if want := []plugins.WantedEpisode{{Season: 1, Episode: 9}}; !reflect.DeepEqual(sel.Missing, want) {
+		t.Errorf("missing = %v, want %v", sel.Missing, want)
+	}
+}
+
+func TestSelectEpisodesMultiEpisodeFile(t *testing.T) {
+	nzb := strings.Replace(packNZB(2, false), "Show.S01E02.1080p.rar", "Show.S01E02E03.1080p.rar", 1)
+	sel, err := selectEpisodes(strings.NewReader(nzb), []plugins.WantedEpisode{{Season: 1, Episode: 3}})
+	if err != nil || sel == nil || sel.Files != 1 {
+		t.Fatalf("selectEpisodes() = %+v, %v, want the double episode archive", sel, err)
+	}
+}
+
+func TestSelectWantedEpisodes(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		obfuscated bool
+		wantBytes  int64
+		wantSkip   int
+		wantLog    string
+	}{
+		{"named", false, 110, 5, "Downloading 2 of 7 files for S01E03, skipping 5 files"},
+		{"obfuscated", true, 340, 0, "downloading everything"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			summary, err := SaveNZB(strings.NewReader(packNZB(3, tt.obfuscated)), filepath.Join(dir, nzbFileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dl := &Download{ID: "dl_1", DownloadDir: dir, WantedEpisodes: []plugins.WantedEpisode{{Season: 1, Episode: 3}}}
+			dl.applySummary(summary)
+			dl.selectWantedEpisodes(summary)
+
+			got := dl.snapshot()
+			if got.TotalBytes != tt.wantBytes {
+				t.Errorf("total bytes = %d, want %d", got.TotalBytes, tt.wantBytes)
+			}
+			if len(got.SkippedFiles) != tt.wantSkip {
+				t.Errorf("skipped files = %v, want %d", got.SkippedFiles, tt.wantSkip)
+			}
+			if !strings.Contains(strings.Join(got.Logs, "\n"), tt.wantLog) {
+				t.Errorf("logs = %v, want %q", got.Logs, tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const (
+	// persistInterval is how often the download state is saved at most,
+	// unless a download reaches a terminal state
+	persistInterval = 5 * time.Second
+
+	// stateFormat is the version of persistedState written by this plugin
+	stateFormat = 1
+)
+
+// stateKeys are the config keys the download state is written to in turn, so
+// the previous state survives a write that doesn't complete
+var stateKeys = [2]string{configDownloads, configDownloadsAlt}
+
+// terminalStatuses are saved as soon as a download reaches them
+var terminalStatuses = map[string]bool{
+	"completed":      true,
+	"failed":         true,
+	"cancelled":      true,
+	"waiting_import": true,
+}
+
+// persistedState is the download queue as written to the config store. The
+// downloads are kept as a JSON string so the checksum can be verified against
+// exactly what was written.
+type persistedState struct {
+	Format    int    `json:"format"`
+	Sequence  uint64 `json:"sequence"`
+	Checksum  string `json:"checksum"` // Hex SHA-256 of Downloads
+	Downloads string `json:"downloads"`
+}
+
+// persister coalesces requests to save the download state
+type persister struct {
+	signal   chan struct{}
+	loaded   atomic.Bool   // Saved state was loaded, so it may be overwritten
+	sequence atomic.Uint64 // Sequence of the last state written or loaded
+	last     atomic.Int64  // Unix nanoseconds of the last save
+
+	// Owned by the persister goroutine
+	payloads map[string]string // Download ID -> payload last synced to the database
+	statuses map[string]string // Download ID -> status at the last save
+
+	persists        atomic.Int64 // State writes to the config store
+	bytesWritten    atomic.Int64 // Bytes of download state written
+	skipped         atomic.Int64 // Save requests deferred by the debounce
+	databaseSyncs   atomic.Int64 // Batched syncs to the database
+	syncedDownloads atomic.Int64 // Downloads sent in those syncs
+	ignoredOnLoad   atomic.Int64 // Saved states rejected as incomplete
+}
+
+func newPersister() *persister {
+	return &persister{
+		signal:   make(chan struct{}, 1),
+		payloads: make(map[string]string),
+		statuses: make(map[string]string),
+	}
+}
+
+// persistDownloadState asks for the download state to be saved to the config
+// store and synced to the database. It doesn't block; see runPersister.
+func (p *NZBDownloaderPlugin) persistDownloadState() {
+	select {
+	case p.persist.signal <- struct{}{}:
+	default:
+		// A save is already pending
+		p.persist.skipped.Add(1)
+	}
+}
+
+// runPersister saves the download state when asked to, at most every
+// persistInterval. Downloads reaching a terminal state are saved straight
+// away, and progress of active downloads is saved every persistInterval.
+func (p *NZBDownloaderPlugin) runPersister(ctx context.Context) {
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			if pending {
+				p.flushState()
+			}
+			return
+		case <-p.persist.signal:
+			since := time.Since(time.Unix(0, p.persist.last.Load()))
+			if since >= persistInterval || p.hasTerminalChange() {
+				pending = !p.flushState()
+			} else {
+				pending = true
+				p.persist.skipped.Add(1)
+			}
+		case <-ticker.C:
+			if pending || p.hasActiveDownloads() {
+				pending = !p.flushState()
+			}
+		}
+	}
+}
+
+// hasTerminalChange reports whether a download reached a terminal state since
+// the last save
+func (p *NZBDownloaderPlugin) hasTerminalChange() bool {
+	p.downloadManager.mu.RLock()
+	defer p.downloadManager.mu.RUnlock()
+
+	for id, dl := range p.downloadManager.downloads {
+		if status := dl.currentStatus(); terminalStatuses[status] && p.persist.statuses[id] != status {
+			return true
+		}
+	}
+	return false
+}
+
+// hasActiveDownloads reports whether any download is making progress
+func (p *NZBDownloaderPlugin) hasActiveDownloads() bool {
+	p.downloadManager.mu.RLock()
+	defer p.downloadManager.mu.RUnlock()
+	return len(p.downloadManager.active) > 0
+}
+
+// flushState saves the download state and syncs the downloads that changed to
+// the database. It returns false when the state can't be saved yet.
+func (p *NZBDownloaderPlugin) flushState() bool {
+	sdk, err := p.getSDK()
+	if err != nil || !p.persist.loaded.Load() {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p.persist.last.Store(time.Now().UnixNano())
+	if err := p.saveDownloads(ctx, sdk); err != nil {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Failed to save download state: %v\n", err)
+	}
+	p.syncChangedDownloads(ctx, sdk)
+	return true
+}
+
+// syncChangedDownloads sends the downloads that changed since the last sync to
+// the database in a single call
+func (p *NZBDownloaderPlugin) syncChangedDownloads(ctx context.Context, sdk plugins.SDKInterface) {
+	var changed []map[string]interface{}
+	var changedIDs []string
+	current := make(map[string]bool)
+
+	p.downloadManager.mu.RLock()
+	for _, id := range p.downloadManager.queue {
+		dl, exists := p.downloadManager.downloads[id]
+		if !exists {
+			continue
+		}
+		current[id] = true
+		payload := downloadPayload(dl)
+		p.persist.statuses[id], _ = payload["status"].(string)
+		data, err := json.Marshal(payload)
+		if err != nil || string(data) == p.persist.payloads[id] {
+			continue
+		}
+		p.persist.payloads[id] = string(data)
+		changed = append(changed, payload)
+		changedIDs = append(changedIDs, id)
+	}
+	p.downloadManager.mu.RUnlock()
+
+	// Forget downloads that were removed
+	for id := range p.persist.payloads {
+		if !current[id] {
+			delete(p.persist.payloads, id)
+			delete(p.persist.statuses, id)
+		}
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	if err := sdk.DownloadSyncBatch(ctx, changed); err != nil {
+		fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Failed to sync %d downloads: %v\n", len(changed), err)
+		// Send them again with the next sync
+		for _, id := range changedIDs {
+			delete(p.persist.payloads, id)
+		}
+		return
+	}
+	p.persist.databaseSyncs.Add(1)
+	p.persist.syncedDownloads.Add(int64(len(changed)))
+}
+
+// encodeState wraps the persisted downloads with the next sequence number and
+// their checksum, returning the state and the key to write it to
+func (p *NZBDownloaderPlugin) encodeState(downloads []PersistedDownload) (string, *persistedState, error) {
+	data, err := json.Marshal(downloads)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	sequence := p.persist.sequence.Add(1)
+	return stateKeys[sequence%2], &persistedState{
+		Format:    stateFormat,
+		Sequence:  sequence,
+		Checksum:  hex.EncodeToString(sum[:]),
+		Downloads: string(data),
+	}, nil
+}
+
+// decodeState reads the download state stored under one key. The state from
+// before it was versioned, a bare list of downloads, has sequence 0. A state
+// whose checksum doesn't match is rejected.
+func decodeState(val interface{}) ([]PersistedDownload, uint64, error) {
+	jsonData, err := json.Marshal(val)
+	if s, ok := val.(string); ok {
+		jsonData, err = []byte(s), nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var downloads []PersistedDownload
+	if json.Unmarshal(jsonData, &downloads) == nil {
+		return downloads, 0, nil
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(jsonData, &state); err != nil {
+		return nil, 0, err
+	}
+	if state.Format < 1 || state.Format > stateFormat {
+		return nil, 0, fmt.Errorf("unknown state format %d", state.Format)
+	}
+	sum := sha256.Sum256([]byte(state.Downloads))
+	if hex.EncodeToString(sum[:]) != state.Checksum {
+		return nil, 0, fmt.Errorf("checksum mismatch in state %d", state.Sequence)
+	}
+	if err := json.Unmarshal([]byte(state.Downloads), &downloads); err != nil {
+		return nil, 0, err
+	}
+	return downloads, state.Sequence, nil
+}
+
+// loadState returns the newest complete download state from the config store
+func (p *NZBDownloaderPlugin) loadState(ctx context.Context, sdk plugins.SDKInterface) ([]PersistedDownload, uint64) {
+	var newest []PersistedDownload
+	var newestSequence uint64
+	found := false
+
+	for _, key := range stateKeys {
+		val, err := sdk.ConfigGet(ctx, key)
+		if err != nil || val == nil {
+			continue
+		}
+		downloads, sequence, err := decodeState(val)
+		if err != nil {
+			p.persist.ignoredOnLoad.Add(1)
+			fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Ignoring incomplete download state in %s: %v\n", key, err)
+			continue
+		}
+		if !found || sequence > newestSequence {
+			newest, newestSequence, found = downloads, sequence, true
+		}
+	}
+	return newest, newestSequence
+}
+
+// handleStatus reports the queue and how the download state is being saved
+func (p *NZBDownloaderPlugin) handleStatus(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	p.downloadManager.mu.RLock()
+	statuses := make(map[string]int)
+	for _, dl := range p.downloadManager.downloads {
+		statuses[dl.currentStatus()]++
+	}
+	total := len(p.downloadManager.downloads)
+	active := len(p.downloadManager.active)
+	p.downloadManager.mu.RUnlock()
+
+	var lastPersist *time.Time
+	if last := p.persist.last.Load(); last != 0 {
+		t := time.Unix(0, last)
+		lastPersist = &t
+	}
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"downloads": map[string]interface{}{
+			"total":     total,
+			"active":    active,
+			"by_status": statuses,
+		},
+		"extraction": p.tools.status(),
+		"persistence": map[string]interface{}{
+			"persists":         p.persist.persists.Load(),
+			"bytes_written":    p.persist.bytesWritten.Load(),
+			"skipped_debounce": p.persist.skipped.Load(),
+			"database_syncs":   p.persist.databaseSyncs.Load(),
+			"synced_downloads": p.persist.syncedDownloads.Load(),
+			"ignored_on_load":  p.persist.ignoredOnLoad.Load(),
+			"sequence":         p.persist.sequence.Load(),
+			"last_persist":     lastPersist,
+		},
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeState(t *testing.T) {
+	p := &NZBDownloaderPlugin{persist: newPersister()}
+	p.persist.sequence.Store(6)
+
+	key, state, err := p.encodeState([]PersistedDownload{{ID: "dl_1", Name: "Show.S01E05"}})
+	if err != nil {
+		t.Fatalf("encodeState() error = %v", err)
+	}
+	if key != configDownloadsAlt || state.Sequence != 7 {
+		t.Errorf("encodeState() = %s sequence %d, want %s sequence 7", key, state.Sequence, configDownloadsAlt)
+	}
+
+	// The config store hands values back decoded from JSON
+	var stored interface{}
+	data, _ := json.Marshal(state)
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	downloads, sequence, err := decodeState(stored)
+	if err != nil || sequence != 7 || len(downloads) != 1 || downloads[0].ID != "dl_1" {
+		t.Errorf("decodeState() = %+v, %d, %v", downloads, sequence, err)
+	}
+
+	legacy := []interface{}{map[string]interface{}{"id": "dl_2", "name": "Movie"}}
+	downloads, sequence, err = decodeState(legacy)
+	if err != nil || sequence != 0 || len(downloads) != 1 || downloads[0].ID != "dl_2" {
+		t.Errorf("decodeState(legacy) = %+v, %d, %v", downloads, sequence, err)
+	}
+
+	state.Downloads = state.Downloads[:len(state.Downloads)/2]
+	if _, _, err := decodeState(state); err == nil {
+		t.Error("decodeState() accepted a truncated state")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A download in "processing" goes through phases: par2 verifies its files and
+// repairs them when needed, archives are extracted, then the files are
+// imported. The phase a download is in, how far along it is when its tool
+// tells, and when each phase started and finished are part of its state, so
+// the UI can show what takes the time and the history how long each took.
+const (
+	phaseVerifying  = "verifying"
+	phaseRepairing  = "repairing"
+	phaseExtracting = "extracting"
+	phaseImporting  = "importing"
+)
+
+// PhaseTiming records when a download started and finished a phase
+type PhaseTiming struct {
+	Phase      string     `json:"phase"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// setPhase moves a download into a phase, finishing the one it was in
+func (d *Download) setPhase(phase string) {
+	d.update(func() {
+		if d.Phase == phase {
+			return
+		}
+		now := time.Now()
+		d.endPhaseLocked(now)
+		d.Phase = phase
+		d.Phases = append(d.Phases, PhaseTiming{Phase: phase, StartedAt: now})
+	})
+}
+
+// setPhaseProgress records how far along the current phase of a download is,
+// in percent
+func (d *Download) setPhaseProgress(percent float64) {
+	percent = min(max(percent, 0), 100)
+	d.update(func() {
+		if d.Phase != "" {
+			d.PhaseProgress = &percent
+		}
+	})
+}
+
+// endPhase finishes the current phase of a download
+func (d *Download) endPhase() {
+	d.update(func() { d.endPhaseLocked(time.Now()) })
+}
+
+// endPhaseLocked finishes the current phase of a download at now. The caller
+// holds mu.
+func (d *Download) endPhaseLocked(now time.Time) {
+	if n := len(d.Phases); n > 0 && d.Phases[n-1].FinishedAt == nil {
+		d.Phases[n-1].FinishedAt = &now
+	}
+	d.Phase = ""
+	d.PhaseProgress = nil
+}
+
+// clearPhasesLocked forgets the phases of a download that is processed again.
+// The caller holds mu.
+func (d *Download) clearPhasesLocked() {
+	d.Phase = ""
+	d.PhaseProgress = nil
+	d.Phases = nil
+}
+
+// percentPattern matches a percentage as unrar ("  42%") and par2
+// ("Repairing: 42.5%") print it
+var percentPattern = regexp.MustCompile(`(\d{1,3}(?:\.\d+)?)%`)
+
+// parsePercent returns the last percentage in a line of tool output
+func parsePercent(line string) (float64, bool) {
+	matches := percentPattern.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(matches[len(matches)-1][1], 64)
+	if err != nil || percent > 100 {
+		return 0, false
+	}
+	return percent, true
+}
+
+// progressWriter captures the output of an external tool while it runs,
+// handing each line to onLine as it is printed. unrar redraws its progress
+// with backspaces and par2 with carriage returns, so either ends a line too.
+type progressWriter struct {
+	mu     sync.Mutex
+	output bytes.Buffer
+	line   []byte
+	onLine func(line string)
+}
+
+// newProgressWriter returns a writer calling onLine for every line written
+func newProgressWriter(onLine func(line string)) *progressWriter {
+	return &progressWriter{onLine: onLine}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.output.Write(p)
+	for _, b := range p {
+		if b == '\n' || b == '\r' || b == '\b' {
+			if len(w.line) > 0 {
+				w.onLine(string(w.line))
+				w.line = w.line[:0]
+			}
+			continue
+		}
+		w.line = append(w.line, b)
+	}
+	return len(p), nil
+}
+
+// Bytes returns all the output written, including the unfinished last line,
+// which is handed to onLine
+func (w *progressWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.line) > 0 {
+		w.onLine(string(w.line))
+		w.line = w.line[:0]
+	}
+	return w.output.Bytes()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"  42%", 42, true},
+		{"Repairing: 12.5%", 12.5, true},
+		{`Scanning: "Show.50%.mkv": 99.9%`, 99.9, true},
+		{"Extracting  Show.S01E01.mkv", 0, false},
+		{"250%", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePercent(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parsePercent(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var lines []string
+	w := newProgressWriter(func(line string) { lines = append(lines, line) })
+
+	// unrar redraws its percentage with backspaces, par2 with carriage returns
+	w.Write([]byte("Extracting  Show.mkv      5%\b\b\b\b"))
+	w.Write([]byte(" 57%\b\b\b\b100%\b\b\b\b  OK \n"))
+	w.Write([]byte("Repairing: 10.0%\rRepairing: 20.0%"))
+	output := w.Bytes()
+
+	want := []string{"Extracting  Show.mkv      5%", " 57%", "100%", "  OK ", "Repairing: 10.0%", "Repairing: 20.0%"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if !strings.HasPrefix(string(output), "Extracting  Show.mkv") || !strings.HasSuffix(string(output), "Repairing: 20.0%") {
+		t.Errorf("output = %q, want everything written", output)
+	}
+}
+
+func TestPhaseTimings(t *testing.T) {
+	d := &Download{}
+	d.setPhase(phaseVerifying)
+	d.setPhaseProgress(40)
+	d.setPhase(phaseVerifying)
+	if d.PhaseProgress == nil || *d.PhaseProgress != 40 || len(d.Phases) != 1 {
+		t.Fatalf("staying in a phase reset it: progress %v, phases %v", d.PhaseProgress, d.Phases)
+	}
+
+	d.setPhase(phaseExtracting)
+	if d.Phase != phaseExtracting || d.PhaseProgress != nil {
+		t.Errorf("phase = %q, progress %v, want extracting without progress", d.Phase, d.PhaseProgress)
+	}
+	if len(d.Phases) != 2 || d.Phases[0].FinishedAt == nil || d.Phases[1].FinishedAt != nil {
+		t.Fatalf("phases = %+v, want verifying finished and extracting running", d.Phases)
+	}
+
+	d.fail("CRC check failed")
+	if d.Phase != "" || d.Phases[1].FinishedAt == nil {
+		t.Errorf("failed download still in phase %q, phases %+v", d.Phase, d.Phases)
+	}
+
+	d.setPhaseProgress(50)
+	if d.PhaseProgress != nil {
+		t.Error("progress recorded outside of a phase")
+	}
+}
+
+func TestPar2Progress(t *testing.T) {
+	fd := newPostProcessor(&Download{})
+	fd.download.setPhase(phaseVerifying)
+	fd.par2Progress(`Scanning: "Show.part01.rar": 75.0%`)
+	if fd.download.Phase != phaseVerifying || *fd.download.PhaseProgress != 75 {
+		t.Errorf("phase = %q at %v, want verifying at 75", fd.download.Phase, *fd.download.PhaseProgress)
+	}
+	fd.par2Progress("Repairing: 5.5%")
+	if fd.download.Phase != phaseRepairing || *fd.download.PhaseProgress != 5.5 {
+		t.Errorf("phase = %q at %v, want repairing at 5.5", fd.download.Phase, *fd.download.PhaseProgress)
+	}
+}
+
+func TestSplitPar2Set(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	par2 := par2FileDescPacket("Show.mkv", []byte("episode"))
+	files := []string{
+		write("Show.vol00+01.par2", par2),
+		write("Show.mkv", []byte("episode")),
+		write("Show.par2", par2),
+		write(nzbFileName, []byte("<nzb/>")),
+	}
+
+	parFile, others := splitPar2Set(files)
+	if filepath.Base(parFile) != "Show.par2" {
+		t.Errorf("par2 file = %s, want the index file", parFile)
+	}
+	if len(others) != 1 || filepath.Base(others[0]) != "Show.mkv" {
+		t.Errorf("files to check = %v, want Show.mkv", others)
+	}
+
+	fd := newPostProcessor(&Download{})
+	if got := fd.repair(files[1:2], dir); len(got) != 1 {
+		t.Errorf("repair without par2 files = %v, want them unchanged", got)
+	}
+	fd.repair(files, dir)
+	if !logged(fd.download, "par2 is not installed") || fd.download.Phase != "" {
+		t.Errorf("repair without par2 installed: phase %q, logs %q", fd.download.Phase, fd.download.Logs)
+	}
+}
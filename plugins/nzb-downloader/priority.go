@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// The queue runs downloads by priority, highest first. Downloads of equal
+// priority run in queue order, which is the order they were added in unless
+// they were moved.
+
+// runOrderLocked returns the queue in the order downloads will run. Callers
+// must hold the DownloadManager lock.
+func (m *DownloadManager) runOrderLocked() []string {
+	order := make([]string, 0, len(m.queue))
+	for _, id := range m.queue {
+		if _, exists := m.downloads[id]; exists {
+			order = append(order, id)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return m.downloads[order[i]].Priority > m.downloads[order[j]].Priority
+	})
+	return order
+}
+
+// nextQueuedLocked returns the download that should start next, skipping
+// downloads that aren't ready, or nil when nothing is waiting
+func (m *DownloadManager) nextQueuedLocked(ready func(*Download) bool) *Download {
+	for _, id := range m.runOrderLocked() {
+		dl := m.downloads[id]
+		if dl.currentStatus() == "queued" && !m.active[id] && (ready == nil || ready(dl)) {
+			return dl
+		}
+	}
+	return nil
+}
+
+// queuePositionsLocked returns the 1-based position of each waiting download
+// in the run order
+func (m *DownloadManager) queuePositionsLocked() map[string]int {
+	positions := make(map[string]int)
+	for _, id := range m.runOrderLocked() {
+		if m.downloads[id].currentStatus() == "queued" && !m.active[id] {
+			positions[id] = len(positions) + 1
+		}
+	}
+	return positions
+}
+
+// preemptLocked makes room for next by pausing the lowest priority active
+// download, if it has a strictly lower priority. The paused download is queued
+// again, so it resumes once a slot frees up. Returns whether a download was
+// preempted.
+func (m *DownloadManager) preemptLocked(next *Download) bool {
+	var victim *Download
+	for id := range m.active {
+		dl, exists := m.downloads[id]
+		if !exists || dl.currentStatus() != "downloading" {
+			continue
+		}
+		if victim == nil || dl.Priority < victim.Priority {
+			victim = dl
+		}
+	}
+	if victim == nil || victim.Priority >= next.Priority {
+		return false
+	}
+
+	victim.AddLog(fmt.Sprintf("Paused for higher priority download %q", next.Name))
+	if victim.cancelDownload != nil {
+		victim.cancelDownload()
+	}
+	victim.update(func() {
+		victim.Status = "queued"
+		victim.StartedAt = nil
+	})
+	delete(m.active, victim.ID)
+	return true
+}
+
+// loadSettings reads the queue settings from the config store
+func (p *NZBDownloaderPlugin) loadSettings(ctx context.Context, sdk plugins.SDKInterface) {
+	if val, err := sdk.ConfigGet(ctx, configPreempt); err == nil {
+		p.preemptOnPriority.Store(parseBool(val))
+	}
+	p.loadDiskSettings(ctx, sdk)
+	loadLogSettings(ctx, sdk)
+}
+
+// parseBool reads a boolean config value, which may be stored as a string
+func parseBool(val interface{}) bool {
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// queuedDownload is a download as listed, with its place in the run order
+type queuedDownload struct {
+	*Download
+	QueuePosition int `json:"queue_position,omitempty"` // Unset unless waiting to start
+}
+
+func (p *NZBDownloaderPlugin) handleSetPriority(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	var input struct {
+		Priority *int `json:"priority"`
+	}
+	if err := json.Unmarshal(req.Body, &input); err != nil || input.Priority == nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "priority is required"})
+	}
+
+	p.downloadManager.mu.Lock()
+	dl, exists := p.downloadManager.downloads[downloadID]
+	if !exists {
+		p.downloadManager.mu.Unlock()
+		return queueErrorResponse(errDownloadNotFound)
+	}
+	if dl.Priority != *input.Priority {
+		dl.AddLog(fmt.Sprintf("Priority changed from %d to %d", dl.Priority, *input.Priority))
+		dl.update(func() { dl.Priority = *input.Priority })
+	}
+	position := p.downloadManager.queuePositionsLocked()[downloadID]
+	p.downloadManager.mu.Unlock()
+
+	go p.persistDownloadState()
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"message":        "Priority updated",
+		"priority":       *input.Priority,
+		"queue_position": position,
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// queuedManager returns a manager with downloads queued in the order given
+func queuedManager(downloads ...*Download) *DownloadManager {
+	m := NewDownloadManager(len(downloads))
+	for _, dl := range downloads {
+		if dl.Status == "" {
+			dl.Status = "queued"
+		}
+		m.downloads[dl.ID] = dl
+		m.queue = append(m.queue, dl.ID)
+	}
+	return m
+}
+
+func TestRunOrderLocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		downloads []*Download
+		want      string
+	}{
+		{"queue order", []*Download{{ID: "a"}, {ID: "b"}, {ID: "c"}}, "a,b,c"},
+		{"highest priority first", []*Download{{ID: "a"}, {ID: "b", Priority: 10}, {ID: "c", Priority: -5}}, "b,a,c"},
+		{"equal priority keeps queue order", []*Download{{ID: "a", Priority: 1}, {ID: "b"}, {ID: "c", Priority: 1}}, "a,c,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := queuedManager(tt.downloads...)
+			if got := strings.Join(m.runOrderLocked(), ","); got != tt.want {
+				t.Errorf("runOrderLocked() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// IDs left in the queue by a removed download are skipped
+	m := queuedManager(&Download{ID: "a"}, &Download{ID: "b"})
+	delete(m.downloads, "a")
+	if got := strings.Join(m.runOrderLocked(), ","); got != "b" {
+		t.Errorf("runOrderLocked() with a removed download = %s, want b", got)
+	}
+}
+
+func TestNextQueuedLocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		downloads []*Download
+		active    []string
+		ready     func(*Download) bool
+		want      string
+	}{
+		{"first in queue", []*Download{{ID: "a"}, {ID: "b"}}, nil, nil, "a"},
+		{"highest priority", []*Download{{ID: "a"}, {ID: "b", Priority: 5}}, nil, nil, "b"},
+		{"skips downloads not queued", []*Download{{ID: "a", Status: "paused", Priority: 5}, {ID: "b", Status: "failed"}, {ID: "c"}}, nil, nil, "c"},
+		{"skips active downloads", []*Download{{ID: "a", Priority: 5}, {ID: "b"}}, []string{"a"}, nil, "b"},
+		{"skips downloads not ready", []*Download{{ID: "a", Priority: 5}, {ID: "b"}}, nil, func(dl *Download) bool { return dl.ID != "a" }, "b"},
+		{"nothing waiting", []*Download{{ID: "a", Status: "completed"}}, nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := queuedManager(tt.downloads...)
+			for _, id := range tt.active {
+				m.active[id] = true
+			}
+			got := ""
+			if dl := m.nextQueuedLocked(tt.ready); dl != nil {
+				got = dl.ID
+			}
+			if got != tt.want {
+				t.Errorf("nextQueuedLocked() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueuePositionsLocked(t *testing.T) {
+	m := queuedManager(
+		&Download{ID: "a", Status: "downloading"},
+		&Download{ID: "b"},
+		&Download{ID: "c", Priority: 3},
+		&Download{ID: "d", Status: "paused", Priority: 9},
+		&Download{ID: "e"},
+	)
+	m.active["a"] = true
+
+	positions := m.queuePositionsLocked()
+	want := map[string]int{"c": 1, "b": 2, "e": 3}
+	if len(positions) != len(want) {
+		t.Errorf("queuePositionsLocked() = %v, want %v", positions, want)
+	}
+	for id, position := range want {
+		if positions[id] != position {
+			t.Errorf("position of %s = %d, want %d", id, positions[id], position)
+		}
+	}
+}
+
+func TestPreemptLocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		active    []*Download
+		next      int
+		preempted string
+	}{
+		{"lower priority", []*Download{{ID: "a", Priority: 0}}, 5, "a"},
+		{"lowest of the active", []*Download{{ID: "a", Priority: 3}, {ID: "b", Priority: -1}, {ID: "c", Priority: 1}}, 5, "b"},
+		{"equal priority", []*Download{{ID: "a", Priority: 5}}, 5, ""},
+		{"higher priority", []*Download{{ID: "a", Priority: 8}}, 5, ""},
+		{"only downloading ones", []*Download{{ID: "a", Status: "processing", Priority: -10}, {ID: "b", Priority: 5}}, 5, ""},
+		{"nothing active", nil, 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := make(map[string]bool)
+			for _, dl := range tt.active {
+				if dl.Status == "" {
+					dl.Status = "downloading"
+				}
+				started := time.Now()
+				dl.StartedAt = &started
+				id := dl.ID
+				dl.cancelDownload = func() { cancelled[id] = true }
+			}
+			next := &Download{ID: "next", Name: "Urgent", Priority: tt.next}
+			m := queuedManager(append(tt.active, next)...)
+			for _, dl := range tt.active {
+				m.active[dl.ID] = true
+			}
+
+			if got := m.preemptLocked(next); got != (tt.preempted != "") {
+				t.Fatalf("preemptLocked() = %v, want %v", got, tt.preempted != "")
+			}
+			for _, dl := range tt.active {
+				if dl.ID != tt.preempted {
+					if !m.active[dl.ID] || cancelled[dl.ID] {
+						t.Errorf("%s was stopped, want it left running", dl.ID)
+					}
+					continue
+				}
+				if m.active[dl.ID] || !cancelled[dl.ID] {
+					t.Errorf("%s active = %v, cancelled = %v, want it stopped", dl.ID, m.active[dl.ID], cancelled[dl.ID])
+				}
+				if got := dl.snapshot(); got.Status != "queued" || got.StartedAt != nil {
+					t.Errorf("%s status = %q, started %v, want queued again", dl.ID, got.Status, got.StartedAt)
+				}
+				if !logContains(dl, `Paused for higher priority download "Urgent"`) {
+					t.Errorf("%s log = %v, want why it was paused", dl.ID, dl.Logs)
+				}
+			}
+		})
+	}
+}
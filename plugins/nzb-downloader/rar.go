@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A reader for RAR archives whose files are stored without compression, as
+// scene releases usually are, so they can be extracted without unrar. RAR 4
+// and RAR 5 archives split over any number of volumes are read. Compressed
+// or encrypted archives are reported with errRARUnsupported and left to
+// unrar.
+
+var (
+	errRARUnsupported = errors.New("unsupported by the built-in RAR extractor")
+	errRARIncomplete  = errors.New("incomplete archive - missing volumes or damaged files")
+	errRARCRC         = errors.New("CRC check failed - corrupted archive")
+)
+
+var (
+	rar4Signature = []byte("Rar!\x1a\x07\x00")
+	rar5Signature = []byte("Rar!\x1a\x07\x01\x00")
+)
+
+// maxRARHeaderSize bounds the headers read, so a damaged size doesn't make
+// the reader allocate gigabytes
+const maxRARHeaderSize = 2 << 20
+
+// rarFilePart is the part of a file stored in one volume
+type rarFilePart struct {
+	name        string
+	dir         bool
+	offset      int64 // Where the data starts in the volume
+	size        int64 // Bytes of data in the volume
+	crc         uint32
+	hasCRC      bool
+	splitBefore bool // Continues a file from the previous volume
+	splitAfter  bool // Continues in the next volume
+}
+
+// extractStoredRAR extracts a RAR archive from its volumes, in order, into
+// destDir and returns the paths of the files extracted. All volumes are read
+// before anything is written, so an archive the reader can't handle leaves
+// destDir untouched.
+func extractStoredRAR(volumes []string, destDir string) ([]string, error) {
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("no RAR volumes")
+	}
+
+	parts := make([][]rarFilePart, len(volumes))
+	for i, volume := range volumes {
+		volumeParts, err := readRARVolume(volume)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(volume), err)
+		}
+		parts[i] = volumeParts
+	}
+
+	var extracted []string
+	var out *os.File
+	var outPath, outName string
+	hash := crc32.NewIEEE()
+
+	abort := func(err error) ([]string, error) {
+		if out != nil {
+			out.Close()
+			os.Remove(outPath)
+		}
+		return extracted, err
+	}
+
+	for i, volume := range volumes {
+		in, err := os.Open(volume)
+		if err != nil {
+			return abort(err)
+		}
+
+		for _, part := range parts[i] {
+			if part.dir {
+				path, err := archiveEntryPath(destDir, part.name)
+				if err != nil {
+					in.Close()
+					return abort(err)
+				}
+				if err := os.MkdirAll(path, 0755); err != nil {
+					in.Close()
+					return abort(err)
+				}
+				continue
+			}
+
+			switch {
+			case part.splitBefore && (out == nil || part.name != outName):
+				in.Close()
+				return abort(fmt.Errorf("%w: %s starts in a missing volume", errRARIncomplete, part.name))
+			case !part.splitBefore && out != nil:
+				in.Close()
+				return abort(fmt.Errorf("%w: %s ends in a missing volume", errRARIncomplete, outName))
+			case !part.splitBefore:
+				outPath, err = archiveEntryPath(destDir, part.name)
+				if err != nil {
+					in.Close()
+					return abort(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+					in.Close()
+					return abort(err)
+				}
+				if out, err = os.Create(outPath); err != nil {
+					in.Close()
+					return abort(err)
+				}
+				outName = part.name
+				hash.Reset()
+			}
+
+			if _, err := in.Seek(part.offset, io.SeekStart); err != nil {
+				in.Close()
+				return abort(err)
+			}
+			if _, err := io.CopyN(io.MultiWriter(out, hash), in, part.size); err != nil {
+				in.Close()
+				if err == io.EOF {
+					err = fmt.Errorf("%w: %s is truncated", errRARIncomplete, filepath.Base(volume))
+				}
+				return abort(err)
+			}
+
+			if part.splitAfter {
+				continue
+			}
+			// The last part carries the CRC of the whole file
+			if err := out.Close(); err != nil {
+				out = nil
+				in.Close()
+				return abort(err)
+			}
+			out = nil
+			if part.hasCRC && hash.Sum32() != part.crc {
+				in.Close()
+				os.Remove(outPath)
+				return extracted, fmt.Errorf("%w: %s", errRARCRC, part.name)
+			}
+			extracted = append(extracted, outPath)
+		}
+		in.Close()
+	}
+
+	if out != nil {
+		return abort(fmt.Errorf("%w: %s continues in a missing volume", errRARIncomplete, outName))
+	}
+	return extracted, nil
+}
+
+// readRARVolume lists the file parts stored in a RAR volume
+func readRARVolume(path string) ([]rarFilePart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, len(rar5Signature))
+	if _, err := f.ReadAt(signature, 0); err != nil {
+		return nil, fmt.Errorf("%w: too short for a RAR archive", errRARIncomplete)
+	}
+	switch {
+	case bytes.Equal(signature, rar5Signature):
+		return readRAR5Volume(f, info.Size())
+	case bytes.Equal(signature[:len(rar4Signature)], rar4Signature):
+		return readRAR4Volume(f, info.Size())
+	default:
+		return nil, fmt.Errorf("%w: no RAR signature at the start", errRARUnsupported)
+	}
+}
+
+// RAR 4 block types and flags
+const (
+	rar4BlockMain = 0x73
+	rar4BlockFile = 0x74
+	rar4BlockEnd  = 0x7b
+
+	rar4MainEncrypted = 0x0080 // Headers are encrypted
+
+	rar4FileSplitBefore = 0x0001
+	rar4FileSplitAfter  = 0x0002
+	rar4FileEncrypted   = 0x0004
+	rar4FileLarge       = 0x0100 // 64-bit sizes
+	rar4FileUnicode     = 0x0200 // Name is followed by its Unicode encoding
+	rar4FileDirMask     = 0x00e0
+	rar4LongBlock       = 0x8000 // A data size follows the header
+
+	rar4MethodStore = 0x30
+)
+
+func readRAR4Volume(f *os.File, size int64) ([]rarFilePart, error) {
+	var parts []rarFilePart
+	pos := int64(len(rar4Signature))
+	base := make([]byte, 7)
+
+	for pos+7 <= size {
+		if _, err := f.ReadAt(base, pos); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRARIncomplete, err)
+		}
+		blockType := base[2]
+		flags := binary.LittleEndian.Uint16(base[3:5])
+		headSize := int64(binary.LittleEndian.Uint16(base[5:7]))
+		if headSize < 7 {
+			return nil, fmt.Errorf("%w: bad block header at %d", errRARIncomplete, pos)
+		}
+
+		header := make([]byte, headSize)
+		if _, err := f.ReadAt(header, pos); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRARIncomplete, err)
+		}
+
+		var dataSize int64
+		if flags&rar4LongBlock != 0 && headSize >= 11 {
+			dataSize = int64(binary.LittleEndian.Uint32(header[7:11]))
+		}
+
+		switch blockType {
+		case rar4BlockMain:
+			if flags&rar4MainEncrypted != 0 {
+				return nil, fmt.Errorf("%w: headers are encrypted", errRARUnsupported)
+			}
+		case rar4BlockFile:
+			if uint16(crc32.ChecksumIEEE(header[2:])) != binary.LittleEndian.Uint16(header[0:2]) {
+				return nil, fmt.Errorf("%w: damaged file header at %d", errRARCRC, pos)
+			}
+			part, err := parseRAR4File(header, flags)
+			if err != nil {
+				return nil, err
+			}
+			part.offset = pos + headSize
+			dataSize = part.size
+			parts = append(parts, part)
+		case rar4BlockEnd:
+			return parts, nil
+		}
+
+		pos += headSize + dataSize
+	}
+	return parts, nil
+}
+
+func parseRAR4File(header []byte, flags uint16) (rarFilePart, error) {
+	if len(header) < 32 {
+		return rarFilePart{}, fmt.Errorf("%w: short file header", errRARIncomplete)
+	}
+	le := binary.LittleEndian
+	part := rarFilePart{
+		size:        int64(le.Uint32(header[7:11])),
+		crc:         le.Uint32(header[16:20]),
+		hasCRC:      true,
+		splitBefore: flags&rar4FileSplitBefore != 0,
+		splitAfter:  flags&rar4FileSplitAfter != 0,
+		dir:         flags&rar4FileDirMask == rar4FileDirMask,
+	}
+	method := header[25]
+	nameSize := int(le.Uint16(header[26:28]))
+
+	rest := header[32:]
+	if flags&rar4FileLarge != 0 {
+		if len(rest) < 8 {
+			return rarFilePart{}, fmt.Errorf("%w: short file header", errRARIncomplete)
+		}
+		part.size |= int64(le.Uint32(rest[0:4])) << 32
+		rest = rest[8:]
+	}
+	if len(rest) < nameSize {
+		return rarFilePart{}, fmt.Errorf("%w: short file header", errRARIncomplete)
+	}
+	name := rest[:nameSize]
+	if flags&rar4FileUnicode != 0 {
+		// The plain name comes first; its Unicode encoding is only needed for
+		// names outside the OEM code page
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+	}
+	part.name = strings.ReplaceAll(string(name), `\`, "/")
+
+	if flags&rar4FileEncrypted != 0 {
+		return rarFilePart{}, fmt.Errorf("%w: %s is encrypted", errRARUnsupported, part.name)
+	}
+	if !part.dir && method != rar4MethodStore {
+		return rarFilePart{}, fmt.Errorf("%w: %s is compressed", errRARUnsupported, part.name)
+	}
+	return part, nil
+}
+
+// RAR 5 header types and flags
+const (
+	rar5HeaderFile       = 2
+	rar5HeaderEncryption = 4
+	rar5HeaderEnd        = 5
+
+	rar5HasExtra       = 0x0001
+	rar5HasData        = 0x0002
+	rar5SplitBefore    = 0x0008
+	rar5SplitAfter     = 0x0010
+	rar5FileDir        = 0x0001
+	rar5FileHasTime    = 0x0002
+	rar5FileHasCRC     = 0x0004
+	rar5ExtraEncrypted = 0x01
+)
+
+func readRAR5Volume(f *os.File, size int64) ([]rarFilePart, error) {
+	var parts []rarFilePart
+	pos := int64(len(rar5Signature))
+	prefix := make([]byte, 4+10)
+
+	for pos < size {
+		n, _ := f.ReadAt(prefix, pos)
+		if n < 5 {
+			return nil, fmt.Errorf("%w: truncated header at %d", errRARIncomplete, pos)
+		}
+		headerSize, sizeLen := rar5Vint(prefix[4:n])
+		if sizeLen == 0 || headerSize == 0 || headerSize > maxRARHeaderSize {
+			return nil, fmt.Errorf("%w: bad header at %d", errRARIncomplete, pos)
+		}
+
+		header := make([]byte, headerSize)
+		if _, err := f.ReadAt(header, pos+4+int64(sizeLen)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errRARIncomplete, err)
+		}
+		hash := crc32.NewIEEE()
+		hash.Write(prefix[4 : 4+sizeLen])
+		hash.Write(header)
+		if hash.Sum32() != binary.LittleEndian.Uint32(prefix[:4]) {
+			return nil, fmt.Errorf("%w: damaged header at %d", errRARCRC, pos)
+		}
+
+		r := &rar5Reader{b: header}
+		headerType := r.vint()
+		flags := r.vint()
+		var extraSize, dataSize uint64
+		if flags&rar5HasExtra != 0 {
+			extraSize = r.vint()
+		}
+		if flags&rar5HasData != 0 {
+			dataSize = r.vint()
+		}
+		if r.bad || extraSize > uint64(len(header)) {
+			return nil, fmt.Errorf("%w: bad header at %d", errRARIncomplete, pos)
+		}
+		dataStart := pos + 4 + int64(sizeLen) + int64(headerSize)
+
+		switch headerType {
+		case rar5HeaderEncryption:
+			return nil, fmt.Errorf("%w: headers are encrypted", errRARUnsupported)
+		case rar5HeaderFile:
+			part, err := parseRAR5File(r, header[len(header)-int(extraSize):])
+			if err != nil {
+				return nil, err
+			}
+			part.offset = dataStart
+			part.size = int64(dataSize)
+			part.splitBefore = flags&rar5SplitBefore != 0
+			part.splitAfter = flags&rar5SplitAfter != 0
+			parts = append(parts, part)
+		case rar5HeaderEnd:
+			return parts, nil
+		}
+
+		pos = dataStart + int64(dataSize)
+	}
+	return parts, nil
+}
+
+func parseRAR5File(r *rar5Reader, extra []byte) (rarFilePart, error) {
+	var part rarFilePart
+	fileFlags := r.vint()
+	r.vint() // Unpacked size
+	r.vint() // Attributes
+	if fileFlags&rar5FileHasTime != 0 {
+		r.uint32()
+	}
+	if fileFlags&rar5FileHasCRC != 0 {
+		part.crc = r.uint32()
+		part.hasCRC = true
+	}
+	compression := r.vint()
+	r.vint() // Host OS
+	part.name = string(r.bytes(int(r.vint())))
+	if r.bad {
+		return rarFilePart{}, fmt.Errorf("%w: short file header", errRARIncomplete)
+	}
+	part.dir = fileFlags&rar5FileDir != 0
+
+	// Extra records: size, type, data
+	records := &rar5Reader{b: extra}
+	for len(records.b) > 0 && !records.bad {
+		recordSize := records.vint()
+		record := &rar5Reader{b: records.bytes(int(recordSize))}
+		if record.vint() == rar5ExtraEncrypted {
+			return rarFilePart{}, fmt.Errorf("%w: %s is encrypted", errRARUnsupported, part.name)
+		}
+	}
+
+	if method := (compression >> 7) & 7; !part.dir && method != 0 {
+		return rarFilePart{}, fmt.Errorf("%w: %s is compressed", errRARUnsupported, part.name)
+	}
+	return part, nil
+}
+
+// rar5Reader reads the fields of a RAR 5 header, noting when it runs short
+type rar5Reader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *rar5Reader) vint() uint64 {
+	v, n := rar5Vint(r.b)
+	if n == 0 {
+		r.bad = true
+		r.b = nil
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *rar5Reader) uint32() uint32 {
+	b := r.bytes(4)
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *rar5Reader) bytes(n int) []byte {
+	if n < 0 || n > len(r.b) {
+		r.bad = true
+		r.b = nil
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// rar5Vint reads a RAR 5 variable length integer, returning 0 bytes read when
+// it is cut short
+func rar5Vint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		value |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// archiveEntryPath is where an archive entry is extracted to in destDir.
+// Entries with absolute paths or climbing out of destDir are refused.
+func archiveEntryPath(destDir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q is outside the download directory", name)
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("archive entry %q has a NUL byte in its name", name)
+	}
+	return safeJoin(destDir, clean)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// repair verifies the files of a download with its par2 set, repairing the
+// damaged or missing ones par2 has the recovery blocks for. It returns the
+// files of the download afterwards. A download without par2 files, or on a
+// host without par2, is left as it is, and when the repair fails extraction
+// still tells whether the files are usable.
+func (fd *FastDownloader) repair(files []string, downloadDir string) []string {
+	parFile, others := splitPar2Set(files)
+	if parFile == "" {
+		return files
+	}
+	if fd.tools.Par2 == "" {
+		fd.download.AddLog("Skipping verification: par2 is not installed")
+		return files
+	}
+
+	fd.download.setPhase(phaseVerifying)
+	fd.download.AddLog(fmt.Sprintf("Verifying files with par2 (%s)", fd.tools.Par2))
+
+	// -p removes the par2 files and the backups of repaired files once
+	// everything is correct
+	args := append([]string{"r", "-p", parFile}, others...)
+	progress := newProgressWriter(fd.par2Progress)
+	cmd := exec.Command(fd.tools.Par2, args...)
+	cmd.Dir = downloadDir
+	cmd.Stdout = progress
+	cmd.Stderr = progress
+	err := cmd.Run()
+	output := progress.Bytes()
+
+	if err != nil {
+		fd.download.AddLog(fmt.Sprintf("par2 could not verify or repair the files: %v", err))
+		if summary := par2Summary(output); summary != "" {
+			fd.download.AddLog("par2: " + summary)
+		}
+	} else if strings.Contains(string(output), "Repair complete") {
+		fd.download.AddLog("par2 repaired the damaged files")
+	} else {
+		fd.download.AddLog("par2 verified all files")
+	}
+
+	listed, listErr := listDownloadFiles(downloadDir)
+	if listErr != nil {
+		return files
+	}
+	return listed
+}
+
+// par2Progress follows the output of par2, which verifies before it repairs
+func (fd *FastDownloader) par2Progress(line string) {
+	if strings.HasPrefix(strings.TrimSpace(line), "Repairing:") {
+		fd.download.setPhase(phaseRepairing)
+	}
+	if percent, ok := parsePercent(line); ok {
+		fd.download.setPhaseProgress(percent)
+	}
+}
+
+// extractProgress follows the output of an extractor
+func (fd *FastDownloader) extractProgress(line string) {
+	if percent, ok := parsePercent(line); ok {
+		fd.download.setPhaseProgress(percent)
+	}
+}
+
+// splitPar2Set returns the par2 file to run par2 with, preferring the index
+// file over the recovery volumes, and the files it is to check. Obfuscated
+// files keep names par2 doesn't expect, so they are all handed to it.
+func splitPar2Set(files []string) (parFile string, others []string) {
+	for _, file := range files {
+		if filepath.Base(file) == nzbFileName {
+			continue
+		}
+		if !isPar2(file) {
+			others = append(others, file)
+			continue
+		}
+		isVolume := strings.Contains(strings.ToLower(filepath.Base(file)), ".vol")
+		if parFile == "" || (!isVolume && strings.Contains(strings.ToLower(filepath.Base(parFile)), ".vol")) {
+			parFile = file
+		}
+	}
+	return parFile, others
+}
+
+// par2Summary returns the last line par2 printed that isn't progress, which
+// tells why it failed
+func par2Summary(output []byte) string {
+	lines := strings.FieldsFunc(string(output), func(r rune) bool { return r == '\n' || r == '\r' })
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !percentPattern.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
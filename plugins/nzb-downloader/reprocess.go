@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// handleReprocessDownload runs post-processing and import again on the files a
+// finished download left on disk, without downloading anything
+func (p *NZBDownloaderPlugin) handleReprocessDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	p.downloadManager.mu.Lock()
+	dl, exists := p.downloadManager.downloads[downloadID]
+	if !exists {
+		p.downloadManager.mu.Unlock()
+		return queueErrorResponse(errDownloadNotFound)
+	}
+
+	// Downloads still being processed are already importing their files
+	if status := dl.currentStatus(); status != "failed" && status != "completed" && status != "waiting_import" {
+		p.downloadManager.mu.Unlock()
+		return queueErrorResponse(&queueError{http.StatusConflict, fmt.Sprintf("Download cannot be reprocessed (status: %s)", status)})
+	}
+
+	dir := downloadDir(dl)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		p.downloadManager.mu.Unlock()
+		return queueErrorResponse(&queueError{http.StatusConflict, "Download directory no longer exists"})
+	}
+	hasFiles := false
+	for _, entry := range entries {
+		if !entry.IsDir() && entry.Name() != nzbFileName {
+			hasFiles = true
+			break
+		}
+	}
+	if !hasFiles {
+		p.downloadManager.mu.Unlock()
+		return queueErrorResponse(&queueError{http.StatusConflict, "Download directory has no files"})
+	}
+
+	processCtx, cancel := context.WithCancel(context.Background())
+	dl.cancelDownload = cancel
+	dl.update(func() {
+		dl.Status = "processing"
+		dl.Progress = 100
+		dl.clearSpeed()
+		dl.Error = ""
+		dl.StatusDetail = ""
+		dl.CompletedAt = nil
+		dl.clearPhasesLocked()
+	})
+	dl.AddLog("Reprocessing files already on disk")
+	p.downloadManager.mu.Unlock()
+
+	p.persistDownloadState()
+	dl.workers.Add(1)
+	go p.postProcessDownload(processCtx, dl, newPostProcessor(dl), dir)
+
+	return jsonResponse(http.StatusAccepted, map[string]string{"message": "Download reprocessing started"})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// nzbFetchTimeout bounds fetching an NZB again for a restored download
+const nzbFetchTimeout = 2 * time.Minute
+
+// errNZBMissing is returned for a download whose NZB is gone and can't be
+// fetched again, like an uploaded NZB whose directory was deleted
+var errNZBMissing = errors.New("NZB file is missing from the download directory and the download has no URL to fetch it again")
+
+// restorableID matches the IDs the host may ask a download to be added under
+var restorableID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// serverIDs returns the IDs of a server snapshot, so it can be saved without
+// the passwords
+func serverIDs(servers []NNTPServer) []string {
+	ids := make([]string, len(servers))
+	for i, srv := range servers {
+		ids[i] = srv.ID
+	}
+	return ids
+}
+
+// restoreServers rebuilds the server snapshot of a download restored after a
+// restart from the servers configured now. Servers of the snapshot that were
+// removed or disabled are dropped; when none are left every enabled server is
+// used, as for a new download.
+func (p *NZBDownloaderPlugin) restoreServers(ctx context.Context, ids []string) []NNTPServer {
+	enabled := p.enabledServers(ctx)
+	if len(ids) == 0 {
+		return enabled
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var servers []NNTPServer
+	for _, srv := range enabled {
+		if wanted[srv.ID] {
+			servers = append(servers, srv)
+		}
+	}
+	if len(servers) == 0 {
+		return enabled
+	}
+	return servers
+}
+
+// rehydrateNZB makes sure the NZB of a download is in its directory before it
+// starts. Downloads added from a URL whose NZB went missing fetch it again;
+// uploaded NZBs only exist on disk, so they fail with errNZBMissing.
+func rehydrateNZB(ctx context.Context, dl *Download) error {
+	path := filepath.Join(downloadDir(dl), nzbFileName)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if dl.URL == "" {
+		return errNZBMissing
+	}
+
+	dl.AddLog("NZB file is missing, fetching it again")
+	fetchCtx, cancel := context.WithTimeout(ctx, nzbFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, dl.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch NZB again: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch NZB again: HTTP %d", resp.StatusCode)
+	}
+
+	summary, err := SaveNZB(resp.Body, path)
+	if err != nil {
+		return fmt.Errorf("failed to parse NZB fetched again: %w", err)
+	}
+	dl.applySummary(summary)
+	if len(dl.WantedEpisodes) > 0 {
+		dl.selectWantedEpisodes(summary)
+	}
+	return nil
+}
+
+// handleGetDownload returns a download, so the host can check which of its
+// downloads survived a restart
+// GET /api/plugins/nzb-downloader/downloads/{id}
+func (p *NZBDownloaderPlugin) handleGetDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	p.downloadManager.mu.RLock()
+	defer p.downloadManager.mu.RUnlock()
+
+	dl, exists := p.downloadManager.downloads[downloadID]
+	if !exists {
+		return queueErrorResponse(errDownloadNotFound)
+	}
+	return jsonResponse(http.StatusOK, queuedDownload{
+		Download:      dl.snapshot(),
+		QueuePosition: p.downloadManager.queuePositionsLocked()[downloadID],
+	})
+}
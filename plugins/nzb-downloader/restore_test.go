@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRehydrateNZB(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testNZB)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "dl_1")
+	dl := &Download{ID: "dl_1", Name: "Show.S01E05", URL: server.URL, DownloadDir: dir}
+	if err := rehydrateNZB(context.Background(), dl); err != nil {
+		t.Fatalf("rehydrateNZB() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, nzbFileName)); err != nil {
+		t.Errorf("NZB was not fetched again: %v", err)
+	}
+	if dl.SegmentCount != 3 || dl.Password != "secret" {
+		t.Errorf("summary not applied: %+v", dl)
+	}
+
+	// An NZB already on disk is used as is
+	server.Close()
+	if err := rehydrateNZB(context.Background(), dl); err != nil {
+		t.Errorf("rehydrateNZB() with NZB on disk error = %v", err)
+	}
+
+	uploaded := &Download{ID: "dl_2", Name: "Upload", DownloadDir: filepath.Join(t.TempDir(), "dl_2")}
+	if err := rehydrateNZB(context.Background(), uploaded); !errors.Is(err, errNZBMissing) {
+		t.Errorf("rehydrateNZB() for missing upload error = %v, want errNZBMissing", err)
+	}
+}
+
+func TestPersistedServerIDs(t *testing.T) {
+	p := &NZBDownloaderPlugin{persist: newPersister()}
+	servers := []NNTPServer{{ID: "a", Password: "secret"}, {ID: "b"}}
+
+	_, state, err := p.encodeState([]PersistedDownload{{ID: "dl_1", ServerIDs: serverIDs(servers)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloads, _, err := decodeState(state)
+	if err != nil || len(downloads) != 1 {
+		t.Fatalf("decodeState() = %+v, %v", downloads, err)
+	}
+	if ids := downloads[0].ServerIDs; len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("ServerIDs = %v, want [a b]", ids)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// A server only keeps articles for its retention. Asked for an older one it
+// answers "no such article", so an NZB older than a server's retention is
+// downloaded from another server, and one older than every server's retention
+// isn't downloaded at all.
+
+// ageDays returns how many whole days before now an NZB posted at postedAt was
+// posted
+func ageDays(postedAt, now time.Time) int {
+	days := int(now.Sub(postedAt).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// keeps reports whether the server still has articles posted days ago. A
+// server without a retention set is assumed to have them.
+func (s NNTPServer) keeps(days int) bool {
+	return s.RetentionDays <= 0 || days <= s.RetentionDays
+}
+
+// planServers splits servers, in order, into those whose retention covers an
+// NZB posted days ago and those that don't
+func planServers(servers []NNTPServer, days int) (usable, skipped []NNTPServer) {
+	for _, srv := range servers {
+		if srv.keeps(days) {
+			usable = append(usable, srv)
+		} else {
+			skipped = append(skipped, srv)
+		}
+	}
+	return usable, skipped
+}
+
+// retentionError describes an NZB posted days ago that is older than the
+// retention of every server
+func retentionError(days int, servers []NNTPServer) error {
+	longest := 0
+	for _, srv := range servers {
+		longest = max(longest, srv.RetentionDays)
+	}
+	return fmt.Errorf("release is %d days old, the longest server retention is %d days", days, longest)
+}
+
+// age returns how many days ago the oldest file of the download's NZB was
+// posted, or false when the NZB has no posting dates
+func (d *Download) age(now time.Time) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.PostedAt == nil {
+		return 0, false
+	}
+	return ageDays(*d.PostedAt, now), true
+}
+
+// retentionServers returns the servers of a download whose retention covers
+// its NZB, logging the servers that are skipped. It fails when none does.
+func retentionServers(download *Download) ([]NNTPServer, error) {
+	days, ok := download.age(time.Now())
+	if !ok {
+		return download.Servers, nil
+	}
+
+	usable, skipped := planServers(download.Servers, days)
+	for _, srv := range skipped {
+		download.AddLog(fmt.Sprintf("Skipping server %s: release is %d days old, its retention is %d days", srv.Name, days, srv.RetentionDays))
+	}
+	if len(usable) == 0 {
+		return nil, retentionError(days, download.Servers)
+	}
+	return usable, nil
+}
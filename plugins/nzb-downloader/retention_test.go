@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanNZBPostedAt(t *testing.T) {
+	nzb := strings.Replace(testNZB, `date="1700000000" subject="&quot;show.par2`, `date="1600000000" subject="&quot;show.par2`, 1)
+	summary, err := ScanNZB(strings.NewReader(nzb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.PostedAt == nil || summary.PostedAt.Unix() != 1600000000 {
+		t.Errorf("PostedAt = %v, want the oldest file's date", summary.PostedAt)
+	}
+
+	undated := strings.ReplaceAll(testNZB, `date="1700000000" `, "")
+	if summary, err := ScanNZB(strings.NewReader(undated)); err != nil || summary.PostedAt != nil {
+		t.Errorf("PostedAt of an NZB without dates = %v (err %v)", summary.PostedAt, err)
+	}
+}
+
+func TestAgeDays(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	if got := ageDays(now.Add(-49*time.Hour), now); got != 2 {
+		t.Errorf("ageDays(49h ago) = %d, want 2", got)
+	}
+	if got := ageDays(now.Add(time.Hour), now); got != 0 {
+		t.Errorf("ageDays(future) = %d, want 0", got)
+	}
+}
+
+func TestPlanServers(t *testing.T) {
+	servers := []NNTPServer{
+		{ID: "primary", RetentionDays: 2000},
+		{ID: "block", RetentionDays: 4000},
+		{ID: "unknown"},
+	}
+
+	usable, skipped := planServers(servers, 2500)
+	if len(usable) != 2 || usable[0].ID != "block" || usable[1].ID != "unknown" {
+		t.Errorf("usable = %v, want block and unknown", usable)
+	}
+	if len(skipped) != 1 || skipped[0].ID != "primary" {
+		t.Errorf("skipped = %v, want primary", skipped)
+	}
+
+	if usable, _ := planServers(servers, 2000); len(usable) != 3 {
+		t.Errorf("servers usable at their retention = %d, want 3", len(usable))
+	}
+
+	usable, _ = planServers(servers[:2], 4500)
+	if len(usable) != 0 {
+		t.Errorf("usable = %v, want none", usable)
+	}
+	want := "release is 4500 days old, the longest server retention is 4000 days"
+	if err := retentionError(4500, servers[:2]); err.Error() != want {
+		t.Errorf("retentionError() = %q, want %q", err, want)
+	}
+}
+
+func TestRetentionServers(t *testing.T) {
+	posted := time.Now().Add(-3000 * 24 * time.Hour)
+	download := &Download{
+		Servers:  []NNTPServer{{ID: "primary", Name: "Primary", RetentionDays: 2000}, {ID: "block", Name: "Block", RetentionDays: 4000}},
+		PostedAt: &posted,
+	}
+
+	servers, err := retentionServers(download)
+	if err != nil || len(servers) != 1 || servers[0].ID != "block" {
+		t.Fatalf("retentionServers() = %v, %v, want block", servers, err)
+	}
+	if logs := strings.Join(download.Logs, "\n"); !strings.Contains(logs, "Skipping server Primary") {
+		t.Errorf("logs = %q, want the skipped server", logs)
+	}
+
+	download.Servers = download.Servers[:1]
+	if _, err := retentionServers(download); err == nil || !strings.Contains(err.Error(), "release is 3000 days old") {
+		t.Errorf("retentionServers() error = %v, want the release's age", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File names come from NZBs, par2 files, subject lines and archives, none of
+// which can be trusted: every file the downloader writes is checked to end up
+// inside the download directory, and anything extraction leaves outside it,
+// or pointing outside it, is moved to quarantineDirName.
+
+// quarantineDirName is the folder in the download directory that extracted
+// files escaping it are moved to. Media in it is never imported.
+const quarantineDirName = ".quarantine"
+
+// safeFilename reduces a name to a single path element that is safe to
+// create in the download directory: directories, backslashes, NUL and other
+// control characters are dropped. It returns "" for names that are empty or
+// nothing but dots.
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = strings.TrimSpace(filepath.Base(filepath.FromSlash(name)))
+	if strings.Trim(name, ".") == "" || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
+
+// safeJoin joins name onto dir, failing when the result would be outside dir,
+// including through a symlink
+func safeJoin(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	if err := checkInside(dir, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkInside checks that path resolves to dir or somewhere inside it once
+// symlinks are followed. A path that doesn't exist yet is checked by its
+// deepest existing parent.
+func checkInside(dir, path string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveExisting(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	if !within(root, resolved) {
+		return fmt.Errorf("%s is outside %s", path, dir)
+	}
+	return nil
+}
+
+// within reports whether path is root or inside it, both clean and absolute
+// or both relative to the same directory
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// resolveExisting follows the symlinks of path as far as it exists. A broken
+// symlink fails, as writing to it would create its target.
+func resolveExisting(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return "", fmt.Errorf("%s is a broken symlink", path)
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	resolvedParent, err := resolveExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(path)), nil
+}
+
+// listedPaths returns the paths an external extractor reports writing:
+// unrar's "Extracting" and "Creating" lines, and 7z's "- " lines at -bb1.
+// Relative paths are taken from destDir.
+func listedPaths(output []byte, destDir string) []string {
+	var paths []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		var path string
+		switch {
+		case strings.HasPrefix(line, "Extracting  "), strings.HasPrefix(line, "Creating    "):
+			path = line[12:]
+			// unrar follows the path with its progress, drawn with
+			// backspaces, and the result
+			if i := strings.IndexByte(path, '\b'); i >= 0 {
+				path = path[:i]
+			}
+			path = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(path), "OK"))
+		case strings.HasPrefix(line, "- "):
+			path = strings.TrimSpace(line[2:])
+		}
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(destDir, path)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// quarantineEscapes moves what extraction left outside downloadDir, or that
+// reaches outside it, into the quarantine folder: listed paths that resolve
+// outside it, and symlinks or special files anywhere in it. It returns the
+// paths quarantined.
+func (fd *FastDownloader) quarantineEscapes(downloadDir string, listed []string) []string {
+	root, err := filepath.EvalSymlinks(downloadDir)
+	if err != nil {
+		return nil
+	}
+	quarantineDir := filepath.Join(downloadDir, quarantineDirName)
+
+	var suspect []string
+	for _, path := range listed {
+		if checkInside(downloadDir, path) != nil {
+			if _, err := os.Lstat(path); err == nil {
+				suspect = append(suspect, path)
+			}
+		}
+	}
+	filepath.WalkDir(downloadDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path == quarantineDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			if target, err := filepath.EvalSymlinks(path); err != nil || !within(root, target) {
+				suspect = append(suspect, path)
+			}
+		case !entry.Type().IsRegular():
+			suspect = append(suspect, path)
+		}
+		return nil
+	})
+
+	var quarantined []string
+	for _, path := range suspect {
+		if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+			fd.download.AddLog(fmt.Sprintf("WARNING: Could not create quarantine folder: %v", err))
+			return quarantined
+		}
+		name := strings.ReplaceAll(strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator)), string(filepath.Separator), "_")
+		target := filepath.Join(quarantineDir, name)
+		if err := os.Rename(path, target); err != nil {
+			fd.download.AddLog(fmt.Sprintf("WARNING: %s escapes the download directory and could not be quarantined: %v", path, err))
+			continue
+		}
+		fd.download.AddLog(fmt.Sprintf("WARNING: Quarantined %s, which escapes the download directory", path))
+		quarantined = append(quarantined, target)
+	}
+	return quarantined
+}
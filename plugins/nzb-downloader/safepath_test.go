@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// maliciousNZB names its files with traversal sequences, absolute paths and
+// names that are nothing but dots once the yEnc part number is cut off
+const maliciousNZB = `<?xml version="1.0" encoding="utf-8"?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+  <file poster="p" date="1700000000" subject="&quot;../../evil.mkv&quot; yEnc (1/1)">
+    <segments><segment bytes="10" number="1">a@example</segment></segments>
+  </file>
+  <file poster="p" date="1700000000" subject="&quot;..\..\windows.mkv&quot; yEnc (1/1)">
+    <segments><segment bytes="10" number="1">b@example</segment></segments>
+  </file>
+  <file poster="p" date="1700000000" subject="&quot;/etc/cron.d/evil&quot; yEnc (1/1)">
+    <segments><segment bytes="10" number="1">c@example</segment></segments>
+  </file>
+  <file poster="p" date="1700000000" subject="&quot;..001&quot; yEnc (1/1)">
+    <segments><segment bytes="10" number="1">d@example</segment></segments>
+  </file>
+</nzb>
+`
+
+func TestOutputFilenameMaliciousNZB(t *testing.T) {
+	dir := t.TempDir()
+	download := &Download{Name: "../../Movie.2024"}
+
+	var names []string
+	_, err := StreamNZB(strings.NewReader(maliciousNZB), func(index int, file *NZBFile) error {
+		names = append(names, outputFilename(download, index, file))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NZB XML can't carry a NUL byte, but a name attribute filled in elsewhere can
+	names = append(names, outputFilename(download, 4, &NZBFile{FileName: "movie\x00.mkv/../.."}))
+
+	want := []string{"evil.mkv", "windows.mkv", "evil", "Movie.2024-part4.bin", "Movie.2024-part5.bin"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("output names = %q, want %q", names, want)
+	}
+	for _, name := range names {
+		if _, err := safeJoin(dir, name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := map[string]string{
+		"Movie.mkv":           "Movie.mkv",
+		"../../etc/passwd":    "passwd",
+		`C:\Windows\evil.exe`: "evil.exe",
+		"..":                  "",
+		"...":                 "",
+		"dir/..":              "",
+		"a\x00b\x1fc.mkv":     "abc.mkv",
+		"  spaced.mkv ":       "spaced.mkv",
+		"/":                   "",
+	}
+	for name, want := range tests {
+		if got := safeFilename(name); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSafeJoinSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink(filepath.Join(outside, "missing"), filepath.Join(dir, "broken.mkv"))
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	for _, name := range []string{"out/file.mkv", "out", "broken.mkv", "../file.mkv"} {
+		if path, err := safeJoin(dir, name); err == nil {
+			t.Errorf("safeJoin(%q) = %s, want an error", name, path)
+		}
+	}
+	for _, name := range []string{"file.mkv", "sub/file.mkv", "sub/new/file.mkv"} {
+		if _, err := safeJoin(dir, name); err != nil {
+			t.Errorf("safeJoin(%q): %v", name, err)
+		}
+	}
+}
+
+// The volumes of a multi-part RAR are renamed after the download, whose name
+// can't move them out of the download directory
+func TestPostProcessRARVolumesWithUnsafeDownloadName(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "download")
+	os.Mkdir(dir, 0755)
+
+	video := bytes.Repeat([]byte("video "), 500)
+	writeTestFile(t, filepath.Join(dir, "a1b2.part1.rar"), rar5Volume([]rar5File{
+		{name: "Movie.mkv", data: video[:1000], crc: crc32.ChecksumIEEE(video[:1000]), splitAfter: true},
+	}, true))
+	writeTestFile(t, filepath.Join(dir, "a1b2.part2.rar"), rar5Volume([]rar5File{
+		{name: "Movie.mkv", data: video[1000:], crc: crc32.ChecksumIEEE(video), splitBefore: true},
+	}, false))
+
+	fd := newPostProcessor(&Download{Name: "../../escape"})
+	if err := fd.PostProcess(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "Movie.mkv")); err != nil || !bytes.Equal(got, video) {
+		t.Errorf("extracted %d bytes, %v", len(got), err)
+	}
+	if !logged(fd.download, "Renamed volume 1: escape.part01.rar") {
+		t.Errorf("volumes not renamed inside the download: %v", fd.download.Logs)
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("files written next to the download directory: %v", entries)
+	}
+}
+
+// A par2 file naming its files with a path renames them inside the download
+func TestDeobfuscateUnsafePar2Name(t *testing.T) {
+	dir := t.TempDir()
+	episode := bytes.Repeat([]byte{0x1A, 0x45, 0xDF, 0xA3}, 5000)
+	files := []string{
+		writeTestFile(t, filepath.Join(dir, "0a1b2c3d4e5f60718293a4b5c6d7e8f9.bin"), episode),
+		writeTestFile(t, filepath.Join(dir, "d41d8cd98f00b204e9800998ecf8427e.bin"), par2FileDescPacket("../../Show.S01E03.mkv", episode)),
+	}
+
+	fd := newPostProcessor(&Download{Name: "Show.S01"})
+	result := fd.deobfuscate(files, dir, &renameLog{})
+	if result[0] != filepath.Join(dir, "Show.S01E03.mkv") {
+		t.Errorf("episode renamed to %s", result[0])
+	}
+}
+
+func TestListedPaths(t *testing.T) {
+	unrar := "\nUNRAR 6.24 freeware\n\nExtracting from /dl/a.part01.rar\n\n" +
+		"Creating    /dl/Sub                                                   OK\n" +
+		"Extracting  /dl/Sub/Movie.mkv                                \b\b\b\b  4%\b\b\b\b\b  OK \n" +
+		"Extracting  /etc/evil                                                 OK\r\nAll OK\n"
+	got := listedPaths([]byte(unrar), "/dl")
+	want := []string{"/dl/Sub", "/dl/Sub/Movie.mkv", "/etc/evil"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("unrar listing = %q, want %q", got, want)
+	}
+
+	sevenZip := "7-Zip 23.01\n\nExtracting archive: /dl/a.7z\n- Movie.mkv\n- ../evil\n\nEverything is Ok\n"
+	got = listedPaths([]byte(sevenZip), "/dl")
+	want = []string{"/dl/Movie.mkv", "/evil"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("7z listing = %q, want %q", got, want)
+	}
+}
+
+// Whatever an extractor reports writing outside the download directory, and
+// links in it pointing outside, are quarantined; the rest is left alone
+func TestQuarantineEscapes(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	escaped := writeTestFile(t, filepath.Join(outside, "evil.sh"), []byte("#!/bin/sh"))
+	movie := writeTestFile(t, filepath.Join(dir, "Movie.mkv"), []byte("movie"))
+	if err := os.Symlink(filepath.Join(outside, "evil.sh"), filepath.Join(dir, "Linked.mkv")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink("Movie.mkv", filepath.Join(dir, "Alias.mkv"))
+
+	listing := "Extracting  " + movie + "   OK\nExtracting  " + escaped + "   OK\n"
+	fd := newPostProcessor(&Download{Name: "Movie"})
+	quarantined := fd.quarantineEscapes(dir, listedPaths([]byte(listing), dir))
+
+	if len(quarantined) != 2 {
+		t.Fatalf("quarantined %v", quarantined)
+	}
+	if _, err := os.Stat(escaped); !os.IsNotExist(err) {
+		t.Error("file outside the download directory not quarantined")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "Linked.mkv")); !os.IsNotExist(err) {
+		t.Error("link pointing outside not quarantined")
+	}
+	for _, name := range []string{"Movie.mkv", "Alias.mkv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if media, _ := findAllMediaFiles(dir); len(media) != 2 {
+		t.Errorf("media found = %v", media)
+	}
+	if !logged(fd.download, "Quarantined") {
+		t.Errorf("quarantine not logged: %v", fd.download.Logs)
+	}
+}
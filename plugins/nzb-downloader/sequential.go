@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A sequential download fetches the segments of its main file, the largest
+// file of the NZB, before any other file and strictly in order, so the start
+// of the file can be played while the rest downloads. Only a few segments of
+// the main file are out at once, which keeps the connections working just
+// ahead of what is already written.
+
+// sequentialWindowPerConnection is how many segments of the main file a
+// sequential download has out per connection
+const sequentialWindowPerConnection = 2
+
+// errMainFileQueued stops reading the NZB once the main file is queued
+var errMainFileQueued = errors.New("main file queued")
+
+// sequentialWindow is how many segments of the main file are queued or being
+// downloaded at once, enough to keep every connection busy
+func sequentialWindow(connections int) int {
+	return max(connections*sequentialWindowPerConnection, 4)
+}
+
+// isMediaFile reports whether a file is one of mediaExtensions
+func isMediaFile(name string) bool {
+	return slices.Contains(mediaExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// checkSequential returns why an NZB can't be downloaded sequentially, if it
+// can't. Releases that are packed or need repairing can only be played once
+// they are extracted, so the largest file must be the media itself.
+func checkSequential(summary *NZBSummary) error {
+	if summary.Files == 0 {
+		return fmt.Errorf("sequential download needs an NZB with files")
+	}
+	if !isMediaFile(summary.LargestFileName) {
+		return fmt.Errorf("sequential download needs the largest file of the NZB to be a media file, but it is %q; releases that must be extracted or repaired can't be played while downloading",
+			summary.LargestFileName)
+	}
+	return nil
+}
+
+// orderedSegments returns the segments of a file sorted by their number, as
+// NZBs don't always list them in order
+func orderedSegments(file *NZBFile) []NZBSegment {
+	segments := slices.Clone(file.Segments)
+	slices.SortStableFunc(segments, func(a, b NZBSegment) int {
+		return a.Number - b.Number
+	})
+	return segments
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mediaNZB is a release posted as the media file itself, listed after a
+// smaller file and with its segments out of order
+const mediaNZB = `<?xml version="1.0" encoding="utf-8"?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+  <file poster="poster" date="1700000000" subject="&quot;movie.nfo&quot; yEnc (1/1)">
+    <segments>
+      <segment bytes="10" number="1">nfo@example</segment>
+    </segments>
+  </file>
+  <file poster="poster" date="1700000000" subject="&quot;movie.mkv&quot; yEnc (1/3)">
+    <segments>
+      <segment bytes="100" number="2">mkv2@example</segment>
+      <segment bytes="100" number="1">mkv1@example</segment>
+      <segment bytes="40" number="3">mkv3@example</segment>
+    </segments>
+  </file>
+</nzb>
+`
+
+func TestScanNZBLargestFile(t *testing.T) {
+	summary, err := ScanNZB(strings.NewReader(mediaNZB))
+	if err != nil {
+		t.Fatalf("ScanNZB() error = %v", err)
+	}
+	if summary.LargestFile != 1 || summary.LargestFileName != "movie.mkv" || summary.LargestFileBytes != 240 {
+		t.Errorf("ScanNZB() largest file = %d %q (%d bytes)", summary.LargestFile, summary.LargestFileName, summary.LargestFileBytes)
+	}
+}
+
+func TestCheckSequential(t *testing.T) {
+	media, err := ScanNZB(strings.NewReader(mediaNZB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSequential(media); err != nil {
+		t.Errorf("checkSequential() for a media release error = %v", err)
+	}
+
+	packed, err := ScanNZB(strings.NewReader(testNZB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSequential(packed); err == nil || !strings.Contains(err.Error(), `"show.rar"`) {
+		t.Errorf("checkSequential() for a packed release error = %v, want it to name show.rar", err)
+	}
+}
+
+func TestOrderedSegments(t *testing.T) {
+	var files []*NZBFile
+	if _, err := StreamNZB(strings.NewReader(mediaNZB), func(index int, file *NZBFile) error {
+		files = append(files, file)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, seg := range orderedSegments(files[1]) {
+		ids = append(ids, seg.MessageID)
+	}
+	if got := strings.Join(ids, ","); got != "mkv1@example,mkv2@example,mkv3@example" {
+		t.Errorf("orderedSegments() = %s", got)
+	}
+	if files[1].Segments[0].Number != 2 {
+		t.Error("orderedSegments() reordered the file's own segments")
+	}
+}
+
+func TestFileAssemblerContiguousBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.mkv")
+	fa, err := NewFileAssembler(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		index int
+		data  string
+		want  int64
+	}{
+		{1, "bbbb", 0}, // Nothing from the start yet
+		{0, "aaa", 7},
+		{2, "cc", 9},
+	}
+	for _, step := range steps {
+		if err := fa.WriteSegment(step.index, []byte(step.data)); err != nil {
+			t.Fatalf("WriteSegment(%d) error = %v", step.index, err)
+		}
+		if got := fa.ContiguousBytes(); got != step.want {
+			t.Errorf("after segment %d ContiguousBytes() = %d, want %d", step.index, got, step.want)
+		}
+	}
+
+	if err := fa.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "aaabbbbcc" {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
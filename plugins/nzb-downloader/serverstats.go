@@ -0,0 +1,213 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// articleOutcome is the result of requesting one article from a server
+type articleOutcome int
+
+const (
+	articleFound   articleOutcome = iota
+	articleMissing                // The server doesn't have the article (430)
+	articleErrored                // The request or decoding failed
+)
+
+// ServerCounts are the article requests made to a server over some period
+type ServerCounts struct {
+	Requested     int64   `json:"articles_requested"`
+	Found         int64   `json:"articles_found"`
+	Missing       int64   `json:"articles_missing"`
+	Errored       int64   `json:"articles_errored"`
+	Bytes         int64   `json:"bytes_downloaded"`
+	SuccessRate   float64 `json:"success_rate"` // Percentage of requested articles found
+	AvgResponseMS float64 `json:"avg_response_ms"`
+}
+
+// ServerStats are the statistics of a server since the plugin started
+type ServerStats struct {
+	ServerID        string       `json:"server_id"`
+	SinceStart      ServerCounts `json:"since_start"`
+	LastHour        ServerCounts `json:"last_hour"`
+	Connections     int64        `json:"connections"`      // Open connections
+	BusyConnections int64        `json:"busy_connections"` // Connections fetching an article
+	Utilization     float64      `json:"utilization"`      // Percentage of open connections in use
+}
+
+// ServerStatsSummary is the part of a server's statistics shown in the servers list
+type ServerStatsSummary struct {
+	Requested   int64   `json:"articles_requested"`
+	SuccessRate float64 `json:"success_rate"`
+	Bytes       int64   `json:"bytes_downloaded"`
+	Utilization float64 `json:"utilization"`
+}
+
+// articleCounters count article requests. They are updated by the download
+// workers, so they are atomics.
+type articleCounters struct {
+	requested     atomic.Int64
+	found         atomic.Int64
+	missing       atomic.Int64
+	errored       atomic.Int64
+	bytes         atomic.Int64
+	responseNanos atomic.Int64
+}
+
+// record counts one article request
+func (c *articleCounters) record(outcome articleOutcome, bytes int64, took time.Duration) {
+	c.requested.Add(1)
+	c.responseNanos.Add(int64(took))
+	switch outcome {
+	case articleFound:
+		c.found.Add(1)
+		c.bytes.Add(bytes)
+	case articleMissing:
+		c.missing.Add(1)
+	default:
+		c.errored.Add(1)
+	}
+}
+
+// reset zeroes the counters
+func (c *articleCounters) reset() {
+	c.requested.Store(0)
+	c.found.Store(0)
+	c.missing.Store(0)
+	c.errored.Store(0)
+	c.bytes.Store(0)
+	c.responseNanos.Store(0)
+}
+
+// add adds the counters to counts
+func (c *articleCounters) add(counts *ServerCounts, responseNanos *int64) {
+	counts.Requested += c.requested.Load()
+	counts.Found += c.found.Load()
+	counts.Missing += c.missing.Load()
+	counts.Errored += c.errored.Load()
+	counts.Bytes += c.bytes.Load()
+	*responseNanos += c.responseNanos.Load()
+}
+
+// counts returns the counters with their rates
+func (c *articleCounters) counts() ServerCounts {
+	var counts ServerCounts
+	var responseNanos int64
+	c.add(&counts, &responseNanos)
+	return counts.withRates(responseNanos)
+}
+
+// withRates fills in the success rate and average response time
+func (c ServerCounts) withRates(responseNanos int64) ServerCounts {
+	if c.Requested > 0 {
+		c.SuccessRate = float64(c.Found) / float64(c.Requested) * 100
+		c.AvgResponseMS = float64(responseNanos) / float64(c.Requested) / float64(time.Millisecond)
+	}
+	return c
+}
+
+// statsMinute counts the requests of one minute of the last hour
+type statsMinute struct {
+	minute atomic.Int64 // Minutes since the epoch the counters are for
+	articleCounters
+}
+
+// serverStats are the statistics of one server. The last hour is kept in a
+// ring of minutes, reused as the hour moves on.
+type serverStats struct {
+	total       articleCounters
+	hour        [60]statsMinute
+	rollMu      sync.Mutex // Serializes reusing a minute of the ring
+	connections atomic.Int64
+	busy        atomic.Int64
+}
+
+// record counts one article request
+func (s *serverStats) record(outcome articleOutcome, bytes int64, took time.Duration) {
+	s.total.record(outcome, bytes, took)
+	s.minute(time.Now()).record(outcome, bytes, took)
+}
+
+// minute returns the counters of the minute a time falls in
+func (s *serverStats) minute(t time.Time) *statsMinute {
+	minute := t.Unix() / 60
+	m := &s.hour[minute%int64(len(s.hour))]
+	if m.minute.Load() != minute {
+		s.rollMu.Lock()
+		if m.minute.Load() != minute {
+			m.reset()
+			m.minute.Store(minute)
+		}
+		s.rollMu.Unlock()
+	}
+	return m
+}
+
+// lastHour returns the counts of the last hour
+func (s *serverStats) lastHour(now time.Time) ServerCounts {
+	current := now.Unix() / 60
+	var counts ServerCounts
+	var responseNanos int64
+	for i := range s.hour {
+		if minute := s.hour[i].minute.Load(); minute > current-int64(len(s.hour)) && minute <= current {
+			s.hour[i].add(&counts, &responseNanos)
+		}
+	}
+	return counts.withRates(responseNanos)
+}
+
+// utilization returns the percentage of open connections fetching an article
+func (s *serverStats) utilization() float64 {
+	connections := s.connections.Load()
+	if connections <= 0 {
+		return 0
+	}
+	return float64(s.busy.Load()) / float64(connections) * 100
+}
+
+// snapshot returns the statistics of a server
+func (s *serverStats) snapshot(serverID string) ServerStats {
+	return ServerStats{
+		ServerID:        serverID,
+		SinceStart:      s.total.counts(),
+		LastHour:        s.lastHour(time.Now()),
+		Connections:     s.connections.Load(),
+		BusyConnections: s.busy.Load(),
+		Utilization:     s.utilization(),
+	}
+}
+
+// summary returns the statistics shown in the servers list
+func (s *serverStats) summary() ServerStatsSummary {
+	total := s.total.counts()
+	return ServerStatsSummary{
+		Requested:   total.Requested,
+		SuccessRate: total.SuccessRate,
+		Bytes:       total.Bytes,
+		Utilization: s.utilization(),
+	}
+}
+
+// serverStatsRegistry holds the statistics of each server by ID. The zero
+// value is ready to use.
+type serverStatsRegistry struct {
+	mu      sync.Mutex
+	servers map[string]*serverStats
+}
+
+// get returns the statistics of a server, creating them on first use
+func (r *serverStatsRegistry) get(serverID string) *serverStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.servers == nil {
+		r.servers = make(map[string]*serverStats)
+	}
+	s, ok := r.servers[serverID]
+	if !ok {
+		s = &serverStats{}
+		r.servers[serverID] = s
+	}
+	return s
+}
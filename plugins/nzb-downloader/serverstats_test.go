@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerStatsRecord(t *testing.T) {
+	var stats serverStats
+	stats.record(articleFound, 700, 10*time.Millisecond)
+	stats.record(articleFound, 300, 30*time.Millisecond)
+	stats.record(articleMissing, 0, 20*time.Millisecond)
+	stats.record(articleErrored, 0, 40*time.Millisecond)
+
+	want := ServerCounts{Requested: 4, Found: 2, Missing: 1, Errored: 1, Bytes: 1000, SuccessRate: 50, AvgResponseMS: 25}
+	if got := stats.total.counts(); got != want {
+		t.Errorf("since start = %+v, want %+v", got, want)
+	}
+	if got := stats.lastHour(time.Now()); got != want {
+		t.Errorf("last hour = %+v, want %+v", got, want)
+	}
+}
+
+func TestServerStatsLastHour(t *testing.T) {
+	var stats serverStats
+	now := time.Now()
+
+	// A minute of the ring left from over an hour ago isn't counted
+	stale := stats.minute(now.Add(-61 * time.Minute))
+	stale.record(articleMissing, 0, time.Millisecond)
+	stats.minute(now.Add(-30*time.Minute)).record(articleFound, 100, time.Millisecond)
+
+	if got := stats.lastHour(now); got.Requested != 1 || got.Found != 1 || got.Missing != 0 {
+		t.Errorf("last hour = %+v, want the one found article", got)
+	}
+
+	// Reusing the stale minute starts it over
+	if m := stats.minute(now.Add(-time.Minute)); m != stale || m.requested.Load() != 0 {
+		t.Errorf("reused minute has %d requests, want 0", m.requested.Load())
+	}
+}
+
+func TestServerStatsUtilization(t *testing.T) {
+	var stats serverStats
+	if got := stats.utilization(); got != 0 {
+		t.Errorf("utilization without connections = %v, want 0", got)
+	}
+
+	stats.connections.Store(4)
+	stats.busy.Store(1)
+	if got := stats.snapshot("news").Utilization; got != 25 {
+		t.Errorf("utilization = %v, want 25", got)
+	}
+}
+
+func TestServerStatsRegistry(t *testing.T) {
+	var registry serverStatsRegistry
+	if registry.get("a") != registry.get("a") || registry.get("a") == registry.get("b") {
+		t.Error("registry doesn't keep one set of statistics per server")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const (
+	// shutdownStopTimeout bounds how long the running downloads may take to
+	// stop when the host shuts down
+	shutdownStopTimeout = 20 * time.Second
+
+	// shutdownSaveTime is kept from the host's shutdown deadline for saving
+	// the download state
+	shutdownSaveTime = 5 * time.Second
+)
+
+// shutdown pauses the running downloads and saves the download state when the
+// host is stopping, before the plugin process is killed. The downloads are
+// restored paused with their progress rather than queued from the start.
+func (p *NZBDownloaderPlugin) shutdown(ctx context.Context, sdk plugins.SDKInterface) error {
+	fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Host is shutting down, pausing downloads\n")
+
+	// Stop the queue processor first, so no download starts in the meantime
+	p.downloadManager.cancel()
+
+	var stopping []*Download
+	p.downloadManager.mu.Lock()
+	for id, dl := range p.downloadManager.downloads {
+		dl.mu.Lock()
+		if dl.Status == "downloading" {
+			dl.AddLog("Download paused for shutdown")
+			if dl.cancelDownload != nil {
+				dl.cancelDownload()
+			}
+			dl.Status = "paused"
+			dl.StartedAt = nil
+			dl.clearSpeed()
+			delete(p.downloadManager.active, id)
+			stopping = append(stopping, dl)
+		}
+		dl.mu.Unlock()
+	}
+	p.downloadManager.mu.Unlock()
+
+	// Wait for the downloads to flush their files and close their connections
+	deadline := time.Now().Add(shutdownStopTimeout)
+	if hostDeadline, ok := ctx.Deadline(); ok && hostDeadline.Add(-shutdownSaveTime).Before(deadline) {
+		deadline = hostDeadline.Add(-shutdownSaveTime)
+	}
+	for _, dl := range stopping {
+		if !dl.waitStopped(time.Until(deadline)) {
+			fmt.Fprintf(os.Stderr, "[NZB-DOWNLOADER] Download %s didn't stop in time\n", dl.ID)
+			break
+		}
+	}
+
+	// Saving before the saved state was loaded would overwrite it
+	if !p.persist.loaded.Load() {
+		return nil
+	}
+	if sdk == nil {
+		var err error
+		if sdk, err = p.getSDK(); err != nil {
+			return err
+		}
+	}
+
+	p.persist.last.Store(time.Now().UnixNano())
+	if err := p.saveDownloads(ctx, sdk); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	p.syncChangedDownloads(ctx, sdk)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownPausesDownloads(t *testing.T) {
+	p, dl := newDownloadingPlugin(t, 1<<20)
+	queued := &Download{ID: "dl_2", Status: "queued"}
+	p.downloadManager.downloads[queued.ID] = queued
+	p.downloadManager.queue = append(p.downloadManager.queue, queued.ID)
+	for dl.snapshot().DownloadedBytes == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.shutdown(context.Background(), nil); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	if p.downloadManager.ctx.Err() == nil {
+		t.Error("queue processor wasn't stopped")
+	}
+	if len(p.downloadManager.active) != 0 {
+		t.Errorf("active = %v, want none", p.downloadManager.active)
+	}
+	if got := dl.snapshot(); got.Status != "paused" || got.DownloadedBytes == 0 {
+		t.Errorf("download = %s with %d bytes, want paused with its progress", got.Status, got.DownloadedBytes)
+	}
+	if status := queued.currentStatus(); status != "queued" {
+		t.Errorf("queued download = %s, want queued", status)
+	}
+}
+
+func TestFileAssemblerStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	fa, err := NewFileAssembler(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, data := range map[int]string{0: "aa", 2: "cc"} {
+		if err := fa.WriteSegment(index, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fa.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	// The segment after the gap can't be placed, so only the first is kept
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "aa" {
+		t.Errorf("file = %q, %v, want %q", data, err, "aa")
+	}
+}
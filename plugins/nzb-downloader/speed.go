@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	speedSampleInterval = time.Second
+	speedWindow         = 30 * time.Second // Time constant of the moving average
+	minSpeedSamples     = 5                // Samples needed before an ETA is reported
+)
+
+// speedMeter smooths the download speed with an exponentially weighted moving
+// average, so a second without finished segments doesn't report a speed of
+// zero and a burst of them doesn't report a spike. Samples are weighted by
+// the time since the last one, so irregular sampling averages over the same
+// window.
+type speedMeter struct {
+	lastBytes int64
+	lastTime  time.Time
+	rate      float64 // Bytes per second
+	samples   int
+}
+
+// sample records the total bytes downloaded at a time. The first sample only
+// sets the starting point.
+func (m *speedMeter) sample(bytes int64, now time.Time) {
+	if m.lastTime.IsZero() {
+		m.lastBytes, m.lastTime = bytes, now
+		return
+	}
+	elapsed := now.Sub(m.lastTime)
+	if elapsed <= 0 {
+		return
+	}
+
+	instant := float64(bytes-m.lastBytes) / elapsed.Seconds()
+	if instant < 0 {
+		instant = 0
+	}
+	if m.samples == 0 {
+		m.rate = instant
+	} else {
+		alpha := 1 - math.Exp(-elapsed.Seconds()/speedWindow.Seconds())
+		m.rate += alpha * (instant - m.rate)
+	}
+	m.samples++
+	m.lastBytes, m.lastTime = bytes, now
+}
+
+// speed returns the smoothed speed in bytes per second
+func (m *speedMeter) speed() int64 {
+	return int64(math.Round(m.rate))
+}
+
+// eta returns the seconds left to download the remaining bytes at the
+// smoothed speed, or nil until the speed is stable or while nothing is
+// downloading
+func (m *speedMeter) eta(remaining int64) *int64 {
+	if m.samples < minSpeedSamples || m.rate < 1 {
+		return nil
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	eta := int64(math.Ceil(float64(remaining) / m.rate))
+	return &eta
+}
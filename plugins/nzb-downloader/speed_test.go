@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpeedMeterSmoothing(t *testing.T) {
+	var m speedMeter
+	start := time.Unix(0, 0)
+	m.sample(0, start)
+	if m.speed() != 0 || m.eta(1000) != nil {
+		t.Fatalf("speed = %d, eta = %v after the first sample, want nothing", m.speed(), m.eta(1000))
+	}
+
+	// The first interval sets the speed
+	m.sample(1000, start.Add(time.Second))
+	if m.speed() != 1000 {
+		t.Errorf("speed = %d, want 1000", m.speed())
+	}
+
+	// A second without bytes pulls the speed down by the weight of one
+	// second of the window, not to zero
+	m.sample(1000, start.Add(2*time.Second))
+	want := 1000 * math.Exp(-1.0/30)
+	if math.Abs(float64(m.speed())-want) > 1 {
+		t.Errorf("speed after a stall = %d, want %.0f", m.speed(), want)
+	}
+
+	// A two second gap weighs as much as two one second samples
+	a, b := speedMeter{}, speedMeter{}
+	a.sample(0, start)
+	b.sample(0, start)
+	a.sample(1000, start.Add(time.Second))
+	b.sample(1000, start.Add(time.Second))
+	a.sample(5000, start.Add(3*time.Second))
+	b.sample(3000, start.Add(2*time.Second))
+	b.sample(5000, start.Add(3*time.Second))
+	if math.Abs(a.rate-b.rate) > 1 {
+		t.Errorf("rate over a gap = %.1f, over two samples = %.1f", a.rate, b.rate)
+	}
+
+	// A speed converges on a steady rate
+	var steady speedMeter
+	for i := 0; i <= 300; i++ {
+		steady.sample(int64(i)*(1<<20), start.Add(time.Duration(i)*time.Second))
+	}
+	if got := steady.speed(); got != 1<<20 {
+		t.Errorf("steady speed = %d, want %d", got, 1<<20)
+	}
+}
+
+func TestSpeedMeterETA(t *testing.T) {
+	var m speedMeter
+	start := time.Unix(0, 0)
+	m.sample(0, start)
+	for i := 1; i < minSpeedSamples; i++ {
+		m.sample(int64(i)*100, start.Add(time.Duration(i)*time.Second))
+		if eta := m.eta(1000); eta != nil {
+			t.Fatalf("eta after %d samples = %d, want none until stable", i, *eta)
+		}
+	}
+	m.sample(minSpeedSamples*100, start.Add(minSpeedSamples*time.Second))
+	if eta := m.eta(1000); eta == nil || *eta != 10 {
+		t.Errorf("eta = %v, want 10", eta)
+	}
+	if eta := m.eta(-5); eta == nil || *eta != 0 {
+		t.Errorf("eta with nothing left = %v, want 0", eta)
+	}
+
+	// Going backwards, as when a resumed download recounts its bytes, isn't
+	// a negative speed
+	m.sample(0, start.Add(10*time.Second))
+	if m.rate < 0 {
+		t.Errorf("rate = %.1f, want no negative speed", m.rate)
+	}
+
+	var stalled speedMeter
+	for i := 0; i <= minSpeedSamples; i++ {
+		stalled.sample(0, start.Add(time.Duration(i)*time.Second))
+	}
+	if eta := stalled.eta(1000); eta != nil {
+		t.Errorf("eta while stalled = %d, want none", *eta)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import "time"
+
+// A download's status, progress and NZB totals are written by its download and
+// post-processing goroutines while handlers read and change them, so they are
+// only accessed under the download's mu, through the methods below. The
+// manager lock, which guards the queue and priorities, is taken before mu.
+
+// snapshot returns a copy of a download that can be read and serialized while
+// the download runs
+func (d *Download) snapshot() *Download {
+	d.mu.Lock()
+	s := &Download{
+		ID:              d.ID,
+		Name:            d.Name,
+		Status:          d.Status,
+		Progress:        d.Progress,
+		TotalBytes:      d.TotalBytes,
+		DownloadedBytes: d.DownloadedBytes,
+		Speed:           d.Speed,
+		ETA:             d.ETA,
+		URL:             d.URL,
+		FileName:        d.FileName,
+		Priority:        d.Priority,
+		Metadata:        d.Metadata,
+		AddedAt:         d.AddedAt,
+		StartedAt:       d.StartedAt,
+		CompletedAt:     d.CompletedAt,
+		Error:           d.Error,
+		StatusDetail:    d.StatusDetail,
+		FileCount:       d.FileCount,
+		SegmentCount:    d.SegmentCount,
+		Sequential:      d.Sequential,
+		MainFile:        d.MainFile,
+		ContiguousBytes: d.ContiguousBytes,
+		Password:        d.Password,
+		ServerIDs:       d.ServerIDs,
+		DownloadDir:     d.DownloadDir,
+		WantedEpisodes:  d.WantedEpisodes,
+		SkippedFiles:    d.SkippedFiles,
+		PostedAt:        d.PostedAt,
+		Phase:           d.Phase,
+		PhaseProgress:   d.PhaseProgress,
+		Phases:          append([]PhaseTiming(nil), d.Phases...),
+	}
+	d.mu.Unlock()
+
+	d.logMu.Lock()
+	s.Logs = append([]string(nil), d.Logs...)
+	d.logMu.Unlock()
+	return s
+}
+
+// update changes the state of a download under its lock
+func (d *Download) update(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn()
+}
+
+// currentStatus returns the status of a download
+func (d *Download) currentStatus() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Status
+}
+
+// setStatus changes the status of a download
+func (d *Download) setStatus(status string) {
+	d.update(func() { d.Status = status })
+}
+
+// fail marks a download failed with an error
+func (d *Download) fail(message string) {
+	d.update(func() {
+		d.Status = "failed"
+		d.Error = message
+		d.clearSpeed()
+		d.endPhaseLocked(time.Now())
+	})
+}
+
+// setProgress records how much of a download is done
+func (d *Download) setProgress(downloaded, total int64) {
+	d.update(func() {
+		d.DownloadedBytes = downloaded
+		if total > 0 {
+			d.Progress = float64(downloaded) / float64(total) * 100
+		}
+	})
+}
+
+// setSpeed records the smoothed speed of a download in bytes per second and
+// its ETA, which is nil until the speed is stable. A download that was paused
+// meanwhile keeps its speed cleared.
+func (d *Download) setSpeed(speed int64, eta *int64) {
+	d.update(func() {
+		if d.Status != "downloading" {
+			return
+		}
+		d.Speed = speed
+		d.ETA = eta
+	})
+}
+
+// clearSpeed zeroes the speed and ETA of a download that stopped downloading,
+// so it isn't shown downloading at its last speed. The caller holds mu.
+func (d *Download) clearSpeed() {
+	d.Speed = 0
+	d.ETA = nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// simulateDownload updates a download's progress like the download goroutine
+// until it is cancelled
+func simulateDownload(ctx context.Context, dl *Download, total int64) {
+	defer dl.workers.Done()
+	for downloaded := int64(1024); ctx.Err() == nil; downloaded = downloaded%total + 1024 {
+		dl.setProgress(downloaded, total)
+		dl.setSpeed(1024, nil)
+		dl.AddLog("segment done")
+	}
+}
+
+func newDownloadingPlugin(t *testing.T, total int64) (*NZBDownloaderPlugin, *Download) {
+	t.Helper()
+	p := &NZBDownloaderPlugin{downloadManager: NewDownloadManager(1), persist: newPersister()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dl := &Download{ID: "dl_1", Name: "Show.S01E01", Status: "downloading", TotalBytes: total, cancelDownload: cancel}
+	p.downloadManager.downloads[dl.ID] = dl
+	p.downloadManager.queue = append(p.downloadManager.queue, dl.ID)
+	p.downloadManager.active[dl.ID] = true
+
+	dl.workers.Add(1)
+	go simulateDownload(ctx, dl, total)
+	return p, dl
+}
+
+func TestListWhileDownloading(t *testing.T) {
+	p, dl := newDownloadingPlugin(t, 1<<20)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				resp, err := p.handleListDownloads(context.Background(), &plugins.PluginHTTPRequest{})
+				if err != nil || resp.StatusCode != http.StatusOK {
+					t.Errorf("list = %v, %v", resp, err)
+					return
+				}
+				var body struct {
+					Downloads []Download `json:"downloads"`
+				}
+				if err := json.Unmarshal(resp.Body, &body); err != nil || len(body.Downloads) != 1 {
+					t.Errorf("list body = %s, %v", resp.Body, err)
+					return
+				}
+				if progress := body.Downloads[0].Progress; progress < 0 || progress > 100 {
+					t.Errorf("progress = %v", progress)
+				}
+				downloadPayload(dl)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPauseDuringDownload(t *testing.T) {
+	p, dl := newDownloadingPlugin(t, 1<<20)
+
+	resp, err := p.handlePauseDownload(context.Background(), &plugins.PluginHTTPRequest{}, dl.ID)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("pause = %v, %v", resp, err)
+	}
+	dl.workers.Wait()
+
+	if status := dl.currentStatus(); status != "paused" {
+		t.Errorf("status = %q, want paused", status)
+	}
+	if got := dl.snapshot(); got.Speed != 0 || got.ETA != nil {
+		t.Errorf("paused download speed = %d, eta = %v, want cleared", got.Speed, got.ETA)
+	}
+	if p.downloadManager.active[dl.ID] {
+		t.Error("paused download is still active")
+	}
+
+	resp, err = p.handleResumeDownload(context.Background(), &plugins.PluginHTTPRequest{}, dl.ID)
+	if err != nil || resp.StatusCode != http.StatusOK || dl.currentStatus() != "queued" {
+		t.Fatalf("resume = %v, %v, status %q", resp, err, dl.currentStatus())
+	}
+}
+
+func TestSetPriorityWhileSyncing(t *testing.T) {
+	p, dl := newDownloadingPlugin(t, 1<<20)
+
+	// Downloads are synced to the host without the manager lock
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				downloadPayload(dl)
+			}
+		}
+	}()
+	for priority := 1; priority <= 50; priority++ {
+		body, _ := json.Marshal(map[string]int{"priority": priority})
+		resp, err := p.handleSetPriority(context.Background(), &plugins.PluginHTTPRequest{Body: body}, dl.ID)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("set priority = %v, %v", resp, err)
+		}
+	}
+	close(stop)
+	<-done
+
+	if got := dl.snapshot().Priority; got != 50 {
+		t.Errorf("priority = %d, want 50", got)
+	}
+}
+
+func TestSetProgressWithoutTotal(t *testing.T) {
+	dl := &Download{Status: "downloading"}
+	dl.setProgress(1024, 0)
+	dl.setSpeed(512, nil)
+
+	// A download whose size is unknown must still serialize
+	if _, err := json.Marshal(dl.snapshot()); err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if dl.Progress != 0 || dl.DownloadedBytes != 1024 || dl.Speed != 512 {
+		t.Errorf("progress = %v, downloaded = %d, speed = %d", dl.Progress, dl.DownloadedBytes, dl.Speed)
+	}
+}
+
+func TestSpeedClearedWhenDownloadingStops(t *testing.T) {
+	eta := int64(60)
+	dl := &Download{Status: "downloading"}
+	dl.setSpeed(1<<20, &eta)
+	if got := dl.snapshot(); got.Speed != 1<<20 || got.ETA == nil || *got.ETA != 60 {
+		t.Fatalf("speed = %d, eta = %v", got.Speed, got.ETA)
+	}
+
+	dl.update(func() {
+		dl.Status = "processing"
+		dl.clearSpeed()
+	})
+	// A last sample of the download loop arriving late is ignored
+	dl.setSpeed(1<<20, &eta)
+	if got := dl.snapshot(); got.Speed != 0 || got.ETA != nil {
+		t.Errorf("processing download speed = %d, eta = %v, want cleared", got.Speed, got.ETA)
+	}
+
+	dl.update(func() { dl.Status = "downloading" })
+	dl.setSpeed(1<<20, &eta)
+	dl.fail("boom")
+	if got := dl.snapshot(); got.Speed != 0 || got.ETA != nil {
+		t.Errorf("failed download speed = %d, eta = %v, want cleared", got.Speed, got.ETA)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"google.golang.org/grpc"
+)
+
+const (
+	// maxNZBUploadSize caps the body of an NZB upload
+	maxNZBUploadSize = 256 << 20
+
+	// uploadMemory is how much of an uploaded NZB is held in memory, the rest
+	// is spooled to a temporary file
+	uploadMemory = 8 << 20
+)
+
+// grpcServer creates the plugin's gRPC server, accepting requests big enough
+// for an NZB upload rather than gRPC's default of 4 MB
+func grpcServer(opts []grpc.ServerOption) *grpc.Server {
+	return grpc.NewServer(append(opts, grpc.MaxRecvMsgSize(maxNZBUploadSize+1<<20))...)
+}
+
+// nzbUpload is an NZB posted as a multipart form
+type nzbUpload struct {
+	file       multipart.File
+	fileName   string
+	name       string
+	priority   int
+	sequential bool
+	metadata   map[string]interface{}
+
+	wantedEpisodes []plugins.WantedEpisode
+}
+
+// parseNZBUpload reads a multipart NZB upload: the NZB in the "file" field,
+// and the optional "name", "category", "priority" and "sequential" fields. The host also
+// sends the download's "metadata" and "wanted_episodes" as JSON. The caller
+// closes the file.
+func parseNZBUpload(form *multipart.Form) (*nzbUpload, error) {
+	files := form.File["file"]
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no NZB file in the upload")
+	}
+
+	upload := &nzbUpload{
+		fileName: files[0].Filename,
+		name:     strings.TrimSpace(formValue(form, "name")),
+	}
+
+	if priority := formValue(form, "priority"); priority != "" {
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority %q", priority)
+		}
+		upload.priority = p
+	}
+
+	if sequential := formValue(form, "sequential"); sequential != "" {
+		s, err := strconv.ParseBool(sequential)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sequential %q", sequential)
+		}
+		upload.sequential = s
+	}
+
+	if metadata := formValue(form, "metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &upload.metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %v", err)
+		}
+	}
+	if wanted := formValue(form, "wanted_episodes"); wanted != "" {
+		if err := json.Unmarshal([]byte(wanted), &upload.wantedEpisodes); err != nil {
+			return nil, fmt.Errorf("invalid wanted_episodes: %v", err)
+		}
+	}
+	if category := formValue(form, "category"); category != "" {
+		if upload.metadata == nil {
+			upload.metadata = map[string]interface{}{}
+		}
+		upload.metadata["category"] = category
+	}
+
+	file, err := files[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the uploaded NZB: %v", err)
+	}
+	upload.file = file
+	return upload, nil
+}
+
+// formValue returns the first value of a form field
+func formValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// multipartUpload is an NZB posted from a browser form
+func multipartUpload(fields string) *plugins.PluginHTTPRequest {
+	body := "--boundary\r\n" +
+		"Content-Disposition: form-data; name=\"file\"; filename=\"Show.S01E05.nzb\"\r\n" +
+		"Content-Type: application/x-nzb\r\n\r\n" +
+		testNZB + "\r\n" +
+		fields +
+		"--boundary--\r\n"
+	return &plugins.PluginHTTPRequest{
+		Method:  "POST",
+		Headers: map[string][]string{"Content-Type": {"multipart/form-data; boundary=boundary"}},
+		Body:    []byte(body),
+	}
+}
+
+// formField is a field of a multipart upload
+func formField(name, value string) string {
+	return "--boundary\r\nContent-Disposition: form-data; name=\"" + name + "\"\r\n\r\n" + value + "\r\n"
+}
+
+func TestParseNZBUpload(t *testing.T) {
+	req := multipartUpload(formField("name", " Show S01E05 ") + formField("category", "tv") + formField("priority", "3"))
+	form, err := req.MultipartForm(uploadMemory)
+	if err != nil || form == nil {
+		t.Fatalf("MultipartForm() = %v, %v", form, err)
+	}
+	defer form.RemoveAll()
+
+	upload, err := parseNZBUpload(form)
+	if err != nil {
+		t.Fatalf("parseNZBUpload() error = %v", err)
+	}
+	defer upload.file.Close()
+
+	if upload.fileName != "Show.S01E05.nzb" || upload.name != "Show S01E05" || upload.priority != 3 || upload.metadata["category"] != "tv" {
+		t.Errorf("upload = %+v", upload)
+	}
+
+	// The NZB is read from the form as it's saved
+	summary, err := SaveNZB(upload.file, filepath.Join(t.TempDir(), nzbFileName))
+	if err != nil || summary.Files != 2 || summary.Segments != 3 {
+		t.Errorf("SaveNZB() = %+v, %v", summary, err)
+	}
+}
+
+func TestParseNZBUploadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *plugins.PluginHTTPRequest
+		want string
+	}{
+		{"invalid priority", multipartUpload(formField("priority", "high")), "invalid priority"},
+		{"invalid metadata", multipartUpload(formField("metadata", "{")), "invalid metadata"},
+		{"invalid sequential", multipartUpload(formField("sequential", "soon")), "invalid sequential"},
+		{"no file", &plugins.PluginHTTPRequest{
+			Headers: map[string][]string{"Content-Type": {"multipart/form-data; boundary=boundary"}},
+			Body:    []byte(formField("name", "Show") + "--boundary--\r\n"),
+		}, "no NZB file"},
+	}
+
+	for _, tt := range tests {
+		form, err := tt.req.MultipartForm(uploadMemory)
+		if err != nil {
+			t.Fatalf("%s: MultipartForm() error = %v", tt.name, err)
+		}
+		if _, err := parseNZBUpload(form); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: parseNZBUpload() error = %v, want %q", tt.name, err, tt.want)
+		}
+		form.RemoveAll()
+	}
+}
@@ -11,6 +11,7 @@ interface NNTPServer {
   enabled: boolean;
   connections: number;
   priority: number;
+  retention_days: number;
 }
 
 interface NZBDownloaderConfigProps {
@@ -46,6 +47,7 @@ export default function NZBDownloaderConfig({ value, onChange }: NZBDownloaderCo
       enabled: true,
       connections: 10,
       priority: 0,
+      retention_days: 0,
     });
     setShowForm(true);
   };
@@ -215,6 +217,25 @@ export default function NZBDownloaderConfig({ value, onChange }: NZBDownloaderCo
                 Lower numbers = higher priority
               </p>
             </div>
+
+            <div className="space-y-2">
+              <label className="block text-sm font-medium">Retention (days)</label>
+              <input
+                type="number"
+                value={editingServer.retention_days ?? 0}
+                onChange={(e) =>
+                  setEditingServer({
+                    ...editingServer,
+                    retention_days: Math.max(0, parseInt(e.target.value) || 0),
+                  })
+                }
+                min={0}
+                className="w-full px-3 py-2 bg-background border rounded-md"
+              />
+              <p className="text-xs text-muted-foreground">
+                Older releases are skipped on this server. 0 = unknown
+              </p>
+            </div>
           </div>
 
           <div className="flex items-center space-x-6">
@@ -295,7 +316,10 @@ export default function NZBDownloaderConfig({ value, onChange }: NZBDownloaderCo
                     </div>
                     <p className="text-sm text-muted-foreground">
                       {server.host}:{server.port} • {server.connections}{" "}
-                      connections • Priority: {server.priority}
+                      connections • Priority: {server.priority} •{" "}
+                      {server.retention_days
+                        ? `${server.retention_days} days retention`
+                        : "Retention not set"}
                     </p>
                   </div>
                   <div className="flex space-x-2">
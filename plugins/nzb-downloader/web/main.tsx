@@ -4,6 +4,7 @@ import {
   ChevronUp,
   ChevronDown,
   ChevronsDown,
+  RotateCw,
   Trash2,
   X,
 } from "lucide-react";
@@ -90,6 +91,7 @@ interface Server {
   enabled: boolean;
   connections: number;
   priority: number;
+  retention_days: number;
 }
 
 interface Download {
@@ -100,13 +102,34 @@ interface Download {
   total_bytes: number;
   downloaded_bytes: number;
   speed: number;
-  eta: number;
+  eta: number | null;
   added_at: string;
   started_at?: string;
   completed_at?: string;
   error?: string;
+  status_detail?: string;
+  phase?: string;
+  phase_progress?: number;
+  phases?: PhaseTiming[];
+  priority: number;
+  queue_position?: number;
+  sequential?: boolean;
+  contiguous_bytes?: number;
+}
+
+interface PhaseTiming {
+  phase: string;
+  started_at: string;
+  finished_at?: string;
 }
 
+const phaseLabels: Record<string, string> = {
+  verifying: "Verifying",
+  repairing: "Repairing",
+  extracting: "Extracting",
+  importing: "Importing",
+};
+
 export default function NZBDownloaderPage() {
   const [servers, setServers] = useState<Server[]>([]);
   const [downloads, setDownloads] = useState<Download[]>([]);
@@ -121,6 +144,7 @@ export default function NZBDownloaderPage() {
   const [editingServer, setEditingServer] = useState<Server | null>(null);
   const [nzbUrl, setNzbUrl] = useState("");
   const [nzbFile, setNzbFile] = useState<File | null>(null);
+  const [sequential, setSequential] = useState(false);
   const [selectedDownloads, setSelectedDownloads] = useState<Set<string>>(
     new Set(),
   );
@@ -271,19 +295,21 @@ export default function NZBDownloaderPage() {
 
     setLoading(true);
     try {
-      let body: string;
+      let body: string | FormData;
+      const headers: Record<string, string> = {};
 
       if (nzbUrl) {
         // Send URL with empty name (backend will extract from URL)
-        body = JSON.stringify({ url: nzbUrl, name: "" });
+        body = JSON.stringify({ url: nzbUrl, name: "", sequential });
+        headers["Content-Type"] = "application/json";
       } else if (nzbFile) {
-        // Send NZB content with filename
-        const nzbContent = await nzbFile.text();
-        const filename = nzbFile.name.replace(/\.nzb$/i, ""); // Remove .nzb extension
-        body = JSON.stringify({
-          nzb: nzbContent,
-          name: filename,
-        });
+        // Upload the NZB file as a form, named after the file
+        body = new FormData();
+        body.append("file", nzbFile);
+        body.append("name", nzbFile.name.replace(/\.nzb$/i, ""));
+        if (sequential) {
+          body.append("sequential", "true");
+        }
       } else {
         showAlert("Missing Input", "Please provide an NZB URL or file");
         return;
@@ -291,7 +317,7 @@ export default function NZBDownloaderPage() {
 
       const response = await fetch("/api/plugins/nzb-downloader/downloads", {
         method: "POST",
-        headers: { "Content-Type": "application/json" },
+        headers,
         credentials: "include",
         body,
       });
@@ -299,6 +325,7 @@ export default function NZBDownloaderPage() {
       if (response.ok) {
         setNzbUrl("");
         setNzbFile(null);
+        setSequential(false);
         setShowAddModal(false);
         await loadDownloads();
       } else {
@@ -336,6 +363,18 @@ export default function NZBDownloaderPage() {
     return `${Math.floor(seconds / 3600)}h ${Math.floor((seconds % 3600) / 60)}m`;
   };
 
+  // How long each finished post-processing phase took, e.g. "Verifying 30s • Extracting 10m 0s"
+  const formatPhases = (phases: PhaseTiming[]): string =>
+    phases
+      .filter((p) => p.finished_at)
+      .map((p) => {
+        const seconds = Math.round(
+          (new Date(p.finished_at!).getTime() - new Date(p.started_at).getTime()) / 1000
+        );
+        return `${phaseLabels[p.phase] ?? p.phase} ${formatTime(seconds)}`;
+      })
+      .join(" • ");
+
   const getStatusColor = (status: string): string => {
     switch (status) {
       case "downloading":
@@ -397,14 +436,74 @@ export default function NZBDownloaderPage() {
     }
   };
 
+  // Extracts and imports the files of a finished download again
+  const reprocessDownload = async (downloadId: string) => {
+    try {
+      const response = await fetch(
+        `/api/plugins/nzb-downloader/downloads/${downloadId}/reprocess`,
+        {
+          method: "POST",
+          credentials: "include",
+        },
+      );
+      if (!response.ok) {
+        const data = await response.json();
+        showAlert("Reprocess Failed", data.error || "Request failed");
+      }
+    } catch (error) {
+      console.error("Failed to reprocess download:", error);
+      showAlert("Reprocess Failed", "Request failed");
+    }
+    await loadDownloads();
+  };
+
+  // Runs a bulk queue operation and reports the downloads it couldn't apply to
+  const runBulk = async (
+    title: string,
+    url: string,
+    init: RequestInit = { method: "POST" },
+  ) => {
+    try {
+      const response = await fetch(url, { credentials: "include", ...init });
+      const data = await response.json();
+      if (!response.ok) {
+        showAlert(title, data.error || "Request failed");
+        return;
+      }
+      const failed = (data.results || []).filter(
+        (r: { success: boolean }) => !r.success,
+      );
+      if (failed.length > 0) {
+        showAlert(
+          title,
+          `${failed.length} download(s) could not be updated: ${failed[0].error}`,
+        );
+      }
+    } catch (error) {
+      console.error(`${title}:`, error);
+      showAlert(title, "Request failed");
+    }
+    await loadDownloads();
+  };
+
   const deleteSelectedDownloads = () => {
     showConfirm(
       "Delete Downloads",
       `Are you sure you want to delete ${selectedDownloads.size} download(s)?`,
       async () => {
-        for (const downloadId of selectedDownloads) {
-          await deleteDownload(downloadId);
-        }
+        await runBulk(
+          "Delete Failed",
+          "/api/plugins/nzb-downloader/downloads/bulk",
+          {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({
+              action: "delete",
+              download_ids: Array.from(selectedDownloads),
+            }),
+          },
+        );
+        setSelectedDownloads(new Set());
         setConfirmModal({ ...confirmModal, isOpen: false });
       },
     );
@@ -483,6 +582,46 @@ export default function NZBDownloaderPage() {
             </h2>
           </div>
 
+          {/* Queue actions - shown when nothing is selected */}
+          {selectedDownloads.size === 0 && (
+            <div className="flex items-center space-x-2">
+              <button
+                className="px-3 py-1.5 text-sm bg-secondary text-secondary-foreground rounded-md hover:bg-secondary/90"
+                onClick={() =>
+                  runBulk(
+                    "Pause Failed",
+                    "/api/plugins/nzb-downloader/downloads/pause-all",
+                  )
+                }
+              >
+                Pause All
+              </button>
+              <button
+                className="px-3 py-1.5 text-sm bg-secondary text-secondary-foreground rounded-md hover:bg-secondary/90"
+                onClick={() =>
+                  runBulk(
+                    "Resume Failed",
+                    "/api/plugins/nzb-downloader/downloads/resume-all",
+                  )
+                }
+              >
+                Resume All
+              </button>
+              <button
+                className="px-3 py-1.5 text-sm bg-secondary text-secondary-foreground rounded-md hover:bg-secondary/90"
+                onClick={() =>
+                  runBulk(
+                    "Clear Failed",
+                    "/api/plugins/nzb-downloader/downloads?status=completed",
+                    { method: "DELETE" },
+                  )
+                }
+              >
+                Clear Completed
+              </button>
+            </div>
+          )}
+
           {/* Toolbar - shown when downloads are selected */}
           {selectedDownloads.size > 0 && (
             <div className="flex items-center space-x-2">
@@ -568,6 +707,15 @@ export default function NZBDownloaderPage() {
                                 : download.name}
                             </h3>
                             <p className="text-xs text-muted-foreground truncate">
+                              {download.queue_position
+                                ? `#${download.queue_position} in queue · `
+                                : ""}
+                              {download.priority !== 0 &&
+                                `Priority ${download.priority} · `}
+                              {download.sequential &&
+                                `Sequential, ${formatBytes(
+                                  download.contiguous_bytes || 0,
+                                )} playable · `}
                               {download.id}
                             </p>
                           </div>
@@ -579,6 +727,18 @@ export default function NZBDownloaderPage() {
                             >
                               {download.status}
                             </span>
+                            {/* Reprocess button for finished downloads */}
+                            {(download.status === "failed" ||
+                              download.status === "completed" ||
+                              download.status === "waiting_import") && (
+                              <button
+                                className="text-muted-foreground hover:text-foreground"
+                                onClick={() => reprocessDownload(download.id)}
+                                title="Reprocess files on disk"
+                              >
+                                <RotateCw className="h-4 w-4" />
+                              </button>
+                            )}
                             {/* Delete button for queued/failed downloads */}
                             {(download.status === "queued" ||
                               download.status === "failed") && (
@@ -623,12 +783,30 @@ export default function NZBDownloaderPage() {
                           </span>
                           <span>
                             {formatSpeed(download.speed)} • ETA:{" "}
-                            {formatTime(download.eta)}
+                            {download.eta === null ? "—" : formatTime(download.eta)}
                           </span>
                         </div>
                       </>
                     )}
 
+                    {download.status === "processing" && download.phase && (
+                      <>
+                        {download.phase_progress !== undefined && (
+                          <div className="w-full bg-background rounded-full h-2">
+                            <div
+                              className="bg-primary h-2 rounded-full transition-all"
+                              style={{ width: `${download.phase_progress}%` }}
+                            />
+                          </div>
+                        )}
+                        <p className="text-xs text-muted-foreground">
+                          {phaseLabels[download.phase] ?? download.phase}
+                          {download.phase_progress !== undefined &&
+                            ` (${download.phase_progress.toFixed(1)}%)`}
+                        </p>
+                      </>
+                    )}
+
                     {download.status === "completed" && (
                       <p className="text-xs text-muted-foreground">
                         Completed{" "}
@@ -636,6 +814,20 @@ export default function NZBDownloaderPage() {
                       </p>
                     )}
 
+                    {download.status !== "processing" &&
+                      download.phases &&
+                      download.phases.length > 0 && (
+                        <p className="text-xs text-muted-foreground">
+                          {formatPhases(download.phases)}
+                        </p>
+                      )}
+
+                    {download.status_detail && (
+                      <p className="text-xs text-yellow-600">
+                        {download.status_detail}
+                      </p>
+                    )}
+
                     {download.error && (
                       <p className="text-xs text-red-600">
                         Error: {download.error}
@@ -679,6 +871,23 @@ export default function NZBDownloaderPage() {
               />
             </div>
 
+            <label className="flex items-start space-x-2 text-sm">
+              <input
+                type="checkbox"
+                className="mt-1"
+                checked={sequential}
+                onChange={(e) => setSequential(e.target.checked)}
+              />
+              <span>
+                Sequential download
+                <span className="block text-xs text-muted-foreground">
+                  Download the main file from its start first, so it can be
+                  played early. Only for releases posted as the media file
+                  itself, not packed in archives.
+                </span>
+              </span>
+            </label>
+
             {servers.length === 0 && (
               <p className="text-xs text-red-600">
                 Please configure at least one NNTP server first
@@ -701,6 +910,7 @@ export default function NZBDownloaderPage() {
                   setShowAddModal(false);
                   setNzbUrl("");
                   setNzbFile(null);
+                  setSequential(false);
                 }}
               >
                 Cancel
@@ -748,6 +958,7 @@ export default function NZBDownloaderPage() {
                       enabled: true,
                       connections: 10,
                       priority: 0,
+                      retention_days: 0,
                     });
                     setShowServerForm(true);
                   }}
@@ -783,7 +994,10 @@ export default function NZBDownloaderPage() {
                         </div>
                         <p className="text-sm text-muted-foreground">
                           {server.host}:{server.port} • {server.connections}{" "}
-                          connections
+                          connections •{" "}
+                          {server.retention_days
+                            ? `${server.retention_days} days retention`
+                            : "Retention not set"}
                         </p>
                       </div>
                       <div className="flex space-x-2">
@@ -948,6 +1162,30 @@ export default function NZBDownloaderPage() {
                         }
                       />
                     </div>
+
+                    <div className="space-y-2">
+                      <label className="block text-sm font-medium">
+                        Retention (days)
+                      </label>
+                      <input
+                        type="number"
+                        min={0}
+                        className="w-full px-3 py-2 bg-background border rounded-md"
+                        value={editingServer.retention_days ?? 0}
+                        onChange={(e) =>
+                          setEditingServer({
+                            ...editingServer,
+                            retention_days: Math.max(
+                              0,
+                              parseInt(e.target.value) || 0,
+                            ),
+                          })
+                        }
+                      />
+                      <p className="text-xs text-muted-foreground">
+                        Older releases are skipped on this server. 0 = unknown
+                      </p>
+                    </div>
                   </div>
 
                   <div className="flex items-center space-x-4">
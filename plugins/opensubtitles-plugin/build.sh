@@ -0,0 +1,20 @@
+#!/bin/bash
+set -e
+
+echo "Building OpenSubtitles plugin..."
+go build -o opensubtitles-plugin .
+echo "✓ Build successful!"
+
+echo ""
+echo "Plugin ready at: $(pwd)/opensubtitles-plugin"
+echo ""
+echo "To use this plugin:"
+echo "1. Set the OpenSubtitles API key (and optionally your account) in the config table:"
+echo "   curl -X PUT 'http://localhost:8080/api/config/plugins.opensubtitles.api_key' \\"
+echo "     -H 'Authorization: Bearer YOUR_JWT_TOKEN' \\"
+echo "     -H 'Content-Type: application/json' \\"
+echo "     -d '{\"value\": \"your_opensubtitles_api_key_here\"}'"
+echo "2. Set the languages to download, e.g. \"en,es\", in plugins.opensubtitles.languages"
+echo "3. Ensure ENABLE_PLUGINS=true"
+echo "4. Set PLUGINS_DIR to the plugins directory"
+echo "5. Restart the Nimbus server"
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// userAgent identifies Nimbus to OpenSubtitles, which requires it
+	userAgent = "Nimbus v0.1.0"
+
+	// tokenLifetime is how long a login token is reused. OpenSubtitles issues
+	// tokens for 24 hours.
+	tokenLifetime = 23 * time.Hour
+
+	// maxSubtitleSize bounds a downloaded subtitle file
+	maxSubtitleSize = 10 << 20
+)
+
+// errNotFound is returned when OpenSubtitles has no such subtitle
+var errNotFound = errors.New("not found on OpenSubtitles")
+
+// credentials authenticate requests to OpenSubtitles. Searching only needs
+// the API key; downloads are counted against the user when one is set.
+type credentials struct {
+	APIKey   string
+	Username string
+	Password string
+}
+
+// osClient calls the OpenSubtitles REST API, logging in once per day when
+// a username is configured
+type osClient struct {
+	baseURL string
+	http    *http.Client
+
+	mu       sync.Mutex
+	token    string
+	tokenFor credentials // Credentials the token was issued for
+	expires  time.Time
+}
+
+func newOSClient() *osClient {
+	return &osClient{
+		baseURL: openSubtitlesBaseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// subtitleFile is a file of a subtitle, downloaded by its file ID
+type subtitleFile struct {
+	FileID   int64  `json:"file_id"`
+	FileName string `json:"file_name"`
+}
+
+// subtitle is a subtitle search result
+type subtitle struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Language        string         `json:"language"`
+		DownloadCount   int            `json:"download_count"`
+		Ratings         float64        `json:"ratings"`
+		Votes           int            `json:"votes"`
+		HearingImpaired bool           `json:"hearing_impaired"`
+		MachineMade     bool           `json:"machine_translated"`
+		AITranslated    bool           `json:"ai_translated"`
+		FromTrusted     bool           `json:"from_trusted"`
+		MoviehashMatch  bool           `json:"moviehash_match"`
+		Release         string         `json:"release"`
+		UploadDate      string         `json:"upload_date"`
+		Files           []subtitleFile `json:"files"`
+	} `json:"attributes"`
+}
+
+// searchParams are the OpenSubtitles subtitle search parameters
+type searchParams struct {
+	MovieHash    string
+	IMDBID       string // Without the "tt" prefix
+	ParentIMDBID string // Series IMDB ID of an episode
+	Season       int
+	Episode      int
+	Query        string
+	Languages    []string
+}
+
+// search finds subtitles for a video
+func (c *osClient) search(ctx context.Context, creds credentials, params searchParams) ([]subtitle, error) {
+	query := url.Values{}
+	if params.MovieHash != "" {
+		query.Set("moviehash", params.MovieHash)
+	}
+	if params.IMDBID != "" {
+		query.Set("imdb_id", params.IMDBID)
+	}
+	if params.ParentIMDBID != "" {
+		query.Set("parent_imdb_id", params.ParentIMDBID)
+	}
+	if params.Season > 0 {
+		query.Set("season_number", fmt.Sprint(params.Season))
+	}
+	if params.Episode > 0 {
+		query.Set("episode_number", fmt.Sprint(params.Episode))
+	}
+	if params.Query != "" && params.IMDBID == "" && params.ParentIMDBID == "" {
+		query.Set("query", params.Query)
+	}
+	if len(params.Languages) > 0 {
+		query.Set("languages", joinLanguages(params.Languages))
+	}
+
+	var resp struct {
+		Data []subtitle `json:"data"`
+	}
+	// OpenSubtitles wants parameters sorted, which Encode does
+	if err := c.do(ctx, creds, http.MethodGet, "/subtitles?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// download fetches the content of a subtitle file
+func (c *osClient) download(ctx context.Context, creds credentials, fileID int64) ([]byte, error) {
+	var link struct {
+		Link      string `json:"link"`
+		FileName  string `json:"file_name"`
+		Remaining int    `json:"remaining"`
+	}
+	body := map[string]interface{}{"file_id": fileID, "sub_format": "srt"}
+	if err := c.do(ctx, creds, http.MethodPost, "/download", body, &link); err != nil {
+		return nil, err
+	}
+	if link.Link == "" {
+		return nil, fmt.Errorf("OpenSubtitles returned no download link")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link.Link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("subtitle download returned HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSubtitleSize))
+}
+
+// do sends an API request, logging in first when a username is configured.
+// A rejected token is replaced once.
+func (c *osClient) do(ctx context.Context, creds credentials, method, path string, body, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		token, err := c.loginToken(ctx, creds)
+		if err != nil {
+			return err
+		}
+
+		status, respBody, err := c.send(ctx, creds, method, path, token, body)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status == http.StatusOK:
+			if err := json.Unmarshal(respBody, v); err != nil {
+				return fmt.Errorf("failed to decode OpenSubtitles response: %w", err)
+			}
+			return nil
+		case status == http.StatusUnauthorized && token != "" && attempt == 0:
+			c.clearToken()
+			continue
+		case status == http.StatusNotFound:
+			return errNotFound
+		default:
+			return fmt.Errorf("OpenSubtitles returned HTTP %d: %s", status, apiMessage(respBody))
+		}
+	}
+}
+
+// loginToken returns the token requests are sent with, logging in when there
+// is none for the credentials. Without a username no token is used.
+func (c *osClient) loginToken(ctx context.Context, creds credentials) (string, error) {
+	if creds.Username == "" {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.tokenFor == creds && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	status, respBody, err := c.send(ctx, creds, http.MethodPost, "/login", "", map[string]string{
+		"username": creds.Username,
+		"password": creds.Password,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to OpenSubtitles: %w", err)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("OpenSubtitles login failed with HTTP %d: %s", status, apiMessage(respBody))
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(respBody, &login); err != nil || login.Token == "" {
+		return "", fmt.Errorf("OpenSubtitles login returned no token")
+	}
+	c.token = login.Token
+	c.tokenFor = creds
+	c.expires = time.Now().Add(tokenLifetime)
+	return c.token, nil
+}
+
+// clearToken forgets the login token so the next request logs in again
+func (c *osClient) clearToken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+// send performs a request and returns the status and body
+func (c *osClient) send(ctx context.Context, creds credentials, method, path, token string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Api-Key", creds.APIKey)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// apiMessage extracts the message of an OpenSubtitles error response
+func apiMessage(body []byte) string {
+	var resp struct {
+		Message string   `json:"message"`
+		Errors  []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if resp.Message != "" {
+			return resp.Message
+		}
+		if len(resp.Errors) > 0 {
+			return resp.Errors[0]
+		}
+	}
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return string(body)
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/hashicorp/go-plugin"
+)
+
+const (
+	openSubtitlesBaseURL = "https://api.opensubtitles.com/api/v1"
+
+	configAPIKey          = "plugins.opensubtitles.api_key"
+	configUsername        = "plugins.opensubtitles.username"
+	configPassword        = "plugins.opensubtitles.password"
+	configLanguages       = "plugins.opensubtitles.languages"
+	configHearingImpaired = "plugins.opensubtitles.hearing_impaired"
+
+	// defaultLanguages is used when no languages are configured
+	defaultLanguages = "en"
+
+	// fetchQueueSize is how many imports may wait for subtitles. Imports past
+	// that get no subtitles until they are fetched by hand.
+	fetchQueueSize = 500
+
+	// fetchTimeout bounds fetching the subtitles of one import
+	fetchTimeout = 2 * time.Minute
+)
+
+// settings are the plugin's configuration
+type settings struct {
+	Credentials           credentials
+	Languages             []string
+	PreferHearingImpaired bool
+}
+
+// fetchJob is an imported video waiting for subtitles
+type fetchJob struct {
+	sdk   plugins.SDKInterface
+	video videoQuery
+}
+
+// OpenSubtitlesPlugin implements the MediaSuitePlugin interface
+type OpenSubtitlesPlugin struct {
+	client *osClient
+
+	queue chan fetchJob
+	start sync.Once
+}
+
+// NewOpenSubtitlesPlugin creates a new OpenSubtitles plugin instance
+func NewOpenSubtitlesPlugin() *OpenSubtitlesPlugin {
+	return &OpenSubtitlesPlugin{
+		client: newOSClient(),
+		queue:  make(chan fetchJob, fetchQueueSize),
+	}
+}
+
+// Metadata returns plugin metadata
+func (p *OpenSubtitlesPlugin) Metadata(ctx context.Context) (*plugins.PluginMetadata, error) {
+	return &plugins.PluginMetadata{
+		ID:           "opensubtitles-plugin",
+		Name:         "OpenSubtitles",
+		Version:      "0.1.0",
+		Description:  "Downloads subtitles from OpenSubtitles for imported movies and episodes",
+		Capabilities: []string{"api", "subtitles"},
+	}, nil
+}
+
+// APIRoutes returns the HTTP routes this plugin provides
+func (p *OpenSubtitlesPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	return []plugins.RouteDescriptor{
+		{
+			Method: "POST",
+			Path:   "/api/plugins/opensubtitles/search",
+			Auth:   "session",
+		},
+		{
+			Method: "POST",
+			Path:   "/api/plugins/opensubtitles/download",
+			Auth:   "session",
+		},
+	}, nil
+}
+
+// videoRequest is the body of the search and download endpoints. The video
+// the subtitles are for is the media item's own file, never a path the
+// caller gives.
+type videoRequest struct {
+	MediaItemID int64    `json:"media_item_id"`
+	Languages   []string `json:"languages,omitempty"` // Search only; defaults to the configured languages
+	FileID      int64    `json:"file_id,omitempty"`   // Download only
+	Language    string   `json:"language,omitempty"`  // Download only
+}
+
+// HandleAPI handles HTTP requests for this plugin's routes
+func (p *OpenSubtitlesPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return p.errorResponse(http.StatusInternalServerError, "SDK not available")
+	}
+	s := loadSettings(ctx, req.SDK)
+	if s.Credentials.APIKey == "" {
+		return p.errorResponse(http.StatusInternalServerError, "OpenSubtitles API key not configured. Please set 'plugins.opensubtitles.api_key' in the config table or OPENSUBTITLES_API_KEY environment variable.")
+	}
+
+	var body videoRequest
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if body.MediaItemID <= 0 {
+		return p.errorResponse(http.StatusBadRequest, "media_item_id is required")
+	}
+	path, err := videoFile(ctx, req.SDK, body.MediaItemID)
+	if err != nil {
+		return p.errorResponse(http.StatusNotFound, err.Error())
+	}
+	video := videoQuery{MediaItemID: body.MediaItemID, Path: path}
+
+	switch strings.TrimPrefix(req.Route, "/api/plugins/opensubtitles") {
+	case "/search":
+		return p.handleSearch(ctx, req.SDK, s, video, body)
+	case "/download":
+		return p.handleDownload(ctx, req.SDK, s, video, body)
+	default:
+		return p.errorResponse(http.StatusNotFound, "Not found")
+	}
+}
+
+// handleSearch lists the subtitles available for a video, best first
+func (p *OpenSubtitlesPlugin) handleSearch(ctx context.Context, sdk plugins.SDKInterface, s settings, video videoQuery, body videoRequest) (*plugins.PluginHTTPResponse, error) {
+	languages := body.Languages
+	if len(languages) == 0 {
+		languages = s.Languages
+	}
+
+	params, err := p.searchParams(ctx, sdk, video, languages)
+	if err != nil {
+		return p.errorResponse(http.StatusBadRequest, err.Error())
+	}
+	results, err := p.client.search(ctx, s.Credentials, params)
+	if err != nil {
+		return p.errorResponse(http.StatusBadGateway, err.Error())
+	}
+	return jsonResponse(map[string]interface{}{
+		"candidates": candidates(results, s.PreferHearingImpaired),
+	})
+}
+
+// handleDownload downloads a chosen subtitle next to a video
+func (p *OpenSubtitlesPlugin) handleDownload(ctx context.Context, sdk plugins.SDKInterface, s settings, video videoQuery, body videoRequest) (*plugins.PluginHTTPResponse, error) {
+	if body.FileID <= 0 || body.Language == "" {
+		return p.errorResponse(http.StatusBadRequest, "file_id and language are required")
+	}
+	if !validLanguage(body.Language) {
+		return p.errorResponse(http.StatusBadRequest, fmt.Sprintf("invalid language: %q", body.Language))
+	}
+	if _, err := os.Stat(video.Path); err != nil {
+		return p.errorResponse(http.StatusBadRequest, fmt.Sprintf("video file not found: %s", video.Path))
+	}
+
+	content, err := p.client.download(ctx, s.Credentials, body.FileID)
+	if errors.Is(err, errNotFound) {
+		return p.errorResponse(http.StatusNotFound, "Subtitle not found on OpenSubtitles")
+	}
+	if err != nil {
+		return p.errorResponse(http.StatusBadGateway, err.Error())
+	}
+
+	path, err := saveSubtitle(ctx, sdk, video.MediaItemID, video.Path, body.Language, content)
+	if err != nil {
+		return p.errorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return jsonResponse(map[string]interface{}{
+		"success":  true,
+		"path":     path,
+		"language": body.Language,
+	})
+}
+
+// UIManifest returns the UI configuration for this plugin
+func (p *OpenSubtitlesPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error) {
+	return &plugins.UIManifest{
+		NavItems: []plugins.UINavItem{},
+		Routes:   []plugins.UIRoute{},
+		ConfigSection: &plugins.ConfigSection{
+			Title:       "OpenSubtitles Settings",
+			Description: "Download subtitles for imported movies and episodes",
+			Fields: []plugins.ConfigField{
+				{
+					Key:         configAPIKey,
+					Label:       "API Key",
+					Description: "Your OpenSubtitles API consumer key",
+					Type:        "text",
+					Required:    true,
+					Placeholder: "Enter your OpenSubtitles API key",
+				},
+				{
+					Key:         configUsername,
+					Label:       "Username",
+					Description: "Your OpenSubtitles username; downloads without an account are limited",
+					Type:        "text",
+				},
+				{
+					Key:         configPassword,
+					Label:       "Password",
+					Description: "Your OpenSubtitles password",
+					Type:        "password",
+				},
+				{
+					Key:          configLanguages,
+					Label:        "Languages",
+					Description:  "Comma-separated language codes to download subtitles in, e.g. en,es,pt-BR",
+					Type:         "text",
+					DefaultValue: defaultLanguages,
+				},
+				{
+					Key:          configHearingImpaired,
+					Label:        "Prefer Hearing Impaired",
+					Description:  "Prefer subtitles for the hearing impaired when several match",
+					Type:         "boolean",
+					DefaultValue: "false",
+				},
+			},
+		},
+	}, nil
+}
+
+// HandleEvent handles system events. Subtitles are fetched in the background
+// for every imported file, since that takes longer than an event may.
+func (p *OpenSubtitlesPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
+	if evt.Type != plugins.EventImportCompleted {
+		return nil
+	}
+
+	mediaType, _ := evt.Data["media_type"].(string)
+	if strings.HasPrefix(mediaType, "music") {
+		return nil
+	}
+	mediaID, ok := evt.Data["media_item_id"].(float64)
+	if !ok {
+		return nil
+	}
+	path, _ := evt.Data["final_path"].(string)
+	if path == "" {
+		return nil
+	}
+	if evt.SDK == nil {
+		return fmt.Errorf("SDK not available")
+	}
+
+	p.start.Do(func() { go p.run() })
+	select {
+	case p.queue <- fetchJob{sdk: evt.SDK, video: videoQuery{MediaItemID: int64(mediaID), Path: path}}:
+		return nil
+	default:
+		return fmt.Errorf("subtitle queue is full, skipping %s", path)
+	}
+}
+
+// run fetches subtitles for queued imports one at a time
+func (p *OpenSubtitlesPlugin) run() {
+	for job := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		if err := p.fetchSubtitles(ctx, job.sdk, job.video); err != nil {
+			log.Printf("opensubtitles: %s: %v", job.video.Path, err)
+		}
+		cancel()
+	}
+}
+
+// fetchSubtitles downloads the best subtitle of each configured language a
+// video doesn't have a subtitle in yet
+func (p *OpenSubtitlesPlugin) fetchSubtitles(ctx context.Context, sdk plugins.SDKInterface, video videoQuery) error {
+	s := loadSettings(ctx, sdk)
+	if s.Credentials.APIKey == "" {
+		return nil
+	}
+
+	var missing []string
+	for _, lang := range s.Languages {
+		path, err := subtitlePath(video.Path, lang)
+		if err != nil {
+			log.Printf("opensubtitles: skipping language: %v", err)
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			missing = append(missing, lang)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	params, err := p.searchParams(ctx, sdk, video, missing)
+	if err != nil {
+		return err
+	}
+	results, err := p.client.search(ctx, s.Credentials, params)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for lang, best := range bestPerLanguage(candidates(results, s.PreferHearingImpaired), missing) {
+		content, err := p.client.download(ctx, s.Credentials, best.FileID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", lang, err))
+			continue
+		}
+		if _, err := saveSubtitle(ctx, sdk, video.MediaItemID, video.Path, lang, content); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", lang, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsIndexer returns false as OpenSubtitles is not an indexer plugin
+func (p *OpenSubtitlesPlugin) IsIndexer(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Search is not implemented for OpenSubtitles plugin
+func (p *OpenSubtitlesPlugin) Search(ctx context.Context, req *plugins.IndexerSearchRequest) (*plugins.IndexerSearchResponse, error) {
+	return nil, fmt.Errorf("OpenSubtitles plugin does not support search")
+}
+
+// IsDownloader returns false as OpenSubtitles is not a downloader plugin
+func (p *OpenSubtitlesPlugin) IsDownloader(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Helper functions
+
+// loadSettings reads the plugin's configuration from the Nimbus config table,
+// falling back to the OPENSUBTITLES_API_KEY, OPENSUBTITLES_USERNAME and
+// OPENSUBTITLES_PASSWORD environment variables for the credentials
+func loadSettings(ctx context.Context, sdk plugins.SDKInterface) settings {
+	s := settings{
+		Credentials: credentials{
+			APIKey:   os.Getenv("OPENSUBTITLES_API_KEY"),
+			Username: os.Getenv("OPENSUBTITLES_USERNAME"),
+			Password: os.Getenv("OPENSUBTITLES_PASSWORD"),
+		},
+		Languages: parseLanguages(defaultLanguages),
+	}
+	if sdk == nil {
+		return s
+	}
+
+	if v, err := sdk.ConfigGetString(ctx, configAPIKey); err == nil && v != "" {
+		s.Credentials.APIKey = v
+	}
+	if v, err := sdk.ConfigGetString(ctx, configUsername); err == nil && v != "" {
+		s.Credentials.Username = v
+	}
+	if v, err := sdk.ConfigGetString(ctx, configPassword); err == nil && v != "" {
+		s.Credentials.Password = v
+	}
+	if v, err := sdk.ConfigGetString(ctx, configLanguages); err == nil {
+		if languages := parseLanguages(v); len(languages) > 0 {
+			s.Languages = languages
+		}
+	}
+	if v, err := sdk.ConfigGet(ctx, configHearingImpaired); err == nil {
+		switch v := v.(type) {
+		case bool:
+			s.PreferHearingImpaired = v
+		case string:
+			s.PreferHearingImpaired = v == "true"
+		}
+	}
+	return s
+}
+
+// jsonResponse encodes data as a 200 JSON response
+func jsonResponse(data interface{}) (*plugins.PluginHTTPResponse, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func (p *OpenSubtitlesPlugin) errorResponse(statusCode int, message string) (*plugins.PluginHTTPResponse, error) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &plugins.PluginHTTPResponse{
+		StatusCode: statusCode,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func main() {
+	openSubtitlesPlugin := NewOpenSubtitlesPlugin()
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: plugins.Handshake,
+		Plugins: map[string]plugin.Plugin{
+			"media-suite": &plugins.MediaSuitePluginGRPC{
+				Impl: openSubtitlesPlugin,
+			},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// hashChunkSize is how much of the start and end of a file the OpenSubtitles
+// hash covers
+const hashChunkSize = 64 * 1024
+
+// movieHash computes the OpenSubtitles hash of a video file: its size plus the
+// sum of the first and last 64 KiB read as little-endian 64-bit integers
+func movieHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+	if size < hashChunkSize {
+		return "", fmt.Errorf("file is too small to hash: %d bytes", size)
+	}
+
+	hash := uint64(size)
+	buf := make([]byte, hashChunkSize)
+	for _, offset := range []int64{0, size - hashChunkSize} {
+		if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return "", err
+		}
+		for i := 0; i < hashChunkSize; i += 8 {
+			hash += binary.LittleEndian.Uint64(buf[i:])
+		}
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+// candidate is a subtitle offered for a video, as shown when choosing one by hand
+type candidate struct {
+	FileID          int64   `json:"file_id"`
+	FileName        string  `json:"file_name"`
+	Language        string  `json:"language"`
+	Release         string  `json:"release"`
+	DownloadCount   int     `json:"download_count"`
+	Rating          float64 `json:"rating"`
+	HearingImpaired bool    `json:"hearing_impaired"`
+	MoviehashMatch  bool    `json:"moviehash_match"`
+	FromTrusted     bool    `json:"from_trusted"`
+	MachineMade     bool    `json:"machine_translated"`
+}
+
+// candidates turns search results into candidates, best first
+func candidates(results []subtitle, preferHearingImpaired bool) []candidate {
+	list := make([]candidate, 0, len(results))
+	for _, r := range results {
+		if len(r.Attributes.Files) == 0 {
+			continue
+		}
+		a := r.Attributes
+		list = append(list, candidate{
+			FileID:          a.Files[0].FileID,
+			FileName:        a.Files[0].FileName,
+			Language:        a.Language,
+			Release:         a.Release,
+			DownloadCount:   a.DownloadCount,
+			Rating:          a.Ratings,
+			HearingImpaired: a.HearingImpaired,
+			MoviehashMatch:  a.MoviehashMatch,
+			FromTrusted:     a.FromTrusted,
+			MachineMade:     a.MachineMade || a.AITranslated,
+		})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		// A hash match was made for this exact file, so it is in sync
+		if a.MoviehashMatch != b.MoviehashMatch {
+			return a.MoviehashMatch
+		}
+		if a.MachineMade != b.MachineMade {
+			return !a.MachineMade
+		}
+		if a.HearingImpaired != b.HearingImpaired {
+			return a.HearingImpaired == preferHearingImpaired
+		}
+		if a.FromTrusted != b.FromTrusted {
+			return a.FromTrusted
+		}
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.DownloadCount > b.DownloadCount
+	})
+	return list
+}
+
+// bestPerLanguage picks the best candidate of each language
+func bestPerLanguage(list []candidate, languages []string) map[string]candidate {
+	best := make(map[string]candidate)
+	for _, c := range list {
+		for _, lang := range languages {
+			if !strings.EqualFold(c.Language, lang) {
+				continue
+			}
+			if _, ok := best[lang]; !ok {
+				best[lang] = c
+			}
+		}
+	}
+	return best
+}
+
+// languagePattern matches the ISO 639 language codes OpenSubtitles uses,
+// optionally with a region ("en", "pt-BR", "zh-CN")
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z]{2,4})?$`)
+
+// validLanguage reports whether lang is a language code, which keeps it from
+// reaching outside the video's directory once it is part of a file name
+func validLanguage(lang string) bool {
+	return languagePattern.MatchString(lang)
+}
+
+// subtitlePath returns where the subtitle of a language is saved for a video,
+// "{filename}.{lang}.srt" next to it so players pick it up
+func subtitlePath(videoPath, lang string) (string, error) {
+	if !validLanguage(lang) {
+		return "", fmt.Errorf("invalid language: %q", lang)
+	}
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	path := base + "." + strings.ToLower(lang) + ".srt"
+	if filepath.Dir(path) != filepath.Dir(videoPath) {
+		return "", fmt.Errorf("subtitle %s is outside the directory of %s", path, videoPath)
+	}
+	return path, nil
+}
+
+// saveSubtitle writes a subtitle next to its video and records it as an extra
+// file of the media item
+func saveSubtitle(ctx context.Context, sdk plugins.SDKInterface, mediaItemID int64, videoPath, lang string, content []byte) (string, error) {
+	path, err := subtitlePath(videoPath, lang)
+	if err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a player never sees a partial subtitle
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write subtitle: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write subtitle: %w", err)
+	}
+
+	if err := sdk.MediaAddExtraFile(ctx, mediaItemID, path, "subtitle"); err != nil {
+		return path, fmt.Errorf("failed to record subtitle: %w", err)
+	}
+	return path, nil
+}
+
+// videoFile returns the file of a media item subtitles belong to: a media
+// file that passed verification, or any media file otherwise
+func videoFile(ctx context.Context, sdk plugins.SDKInterface, mediaItemID int64) (string, error) {
+	files, err := sdk.MediaFiles(ctx, mediaItemID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get media files: %w", err)
+	}
+
+	var fallback string
+	for _, file := range files {
+		if file.Kind != "media" {
+			continue
+		}
+		if file.Status == "ok" {
+			return file.Path, nil
+		}
+		if fallback == "" {
+			fallback = file.Path
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("media item %d has no file", mediaItemID)
+	}
+	return fallback, nil
+}
+
+// imdbNumber strips the "tt" prefix of an IMDB ID, as OpenSubtitles wants it
+var imdbNumber = regexp.MustCompile(`^tt0*`)
+
+// videoQuery describes the video subtitles are searched for
+type videoQuery struct {
+	MediaItemID int64
+	Path        string
+}
+
+// searchParams builds the search for a media item's video. Movies are found
+// by their IMDB ID and episodes by their series' IMDB ID and numbers; the file
+// hash finds subtitles made for the exact release.
+func (p *OpenSubtitlesPlugin) searchParams(ctx context.Context, sdk plugins.SDKInterface, video videoQuery, languages []string) (searchParams, error) {
+	params := searchParams{Languages: languages}
+
+	if hash, err := movieHash(video.Path); err == nil {
+		params.MovieHash = hash
+	} else if os.IsNotExist(err) {
+		return params, fmt.Errorf("video file not found: %s", video.Path)
+	}
+
+	item, err := sdk.MediaGet(ctx, video.MediaItemID)
+	if err != nil {
+		return params, fmt.Errorf("failed to get media item: %w", err)
+	}
+	params.Query = item.Title
+
+	switch item.Kind {
+	case "tv_episode":
+		params.Season = metadataInt(item.Metadata, "season", "season_number")
+		params.Episode = metadataInt(item.Metadata, "episode", "episode_number")
+		if id := imdbID(item); id != "" {
+			params.IMDBID = id
+			break
+		}
+
+		// Episodes are searched by the series, two levels up
+		current := item
+		for depth := 0; depth < 2 && current.ParentID != nil; depth++ {
+			parent, err := sdk.MediaGet(ctx, *current.ParentID)
+			if err != nil {
+				break
+			}
+			current = parent
+			if current.Kind == "tv_season" && params.Season == 0 {
+				params.Season = metadataInt(current.Metadata, "season", "season_number")
+			}
+		}
+		if current.Kind == "tv_series" {
+			params.ParentIMDBID = imdbID(current)
+			params.Query = current.Title
+		}
+	default:
+		params.IMDBID = imdbID(item)
+	}
+	return params, nil
+}
+
+// imdbID returns the IMDB number of a media item, without the "tt" prefix
+func imdbID(item *plugins.MediaItem) string {
+	for _, m := range []map[string]interface{}{item.ExternalIDs, item.Metadata} {
+		for _, key := range []string{"imdb_id", "imdb"} {
+			if id, ok := m[key].(string); ok && id != "" {
+				return imdbNumber.ReplaceAllString(id, "")
+			}
+		}
+	}
+	return ""
+}
+
+// metadataInt reads a number from media item metadata
+func metadataInt(metadata map[string]interface{}, keys ...string) int {
+	for _, key := range keys {
+		if v, ok := metadata[key].(float64); ok {
+			return int(v)
+		}
+	}
+	return 0
+}
+
+// joinLanguages joins language codes the way OpenSubtitles expects, lowercase
+// and sorted
+func joinLanguages(languages []string) string {
+	list := make([]string, len(languages))
+	for i, lang := range languages {
+		list[i] = strings.ToLower(lang)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+// parseLanguages splits a comma-separated language list
+func parseLanguages(value string) []string {
+	var languages []string
+	for _, lang := range strings.Split(value, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
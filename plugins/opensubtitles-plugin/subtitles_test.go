@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// fakeSDK serves the config and media item the plugin reads and records the
+// extra files it adds
+type fakeSDK struct {
+	plugins.SDKInterface
+	config map[string]string
+	item   *plugins.MediaItem
+	files  []plugins.MediaFile
+	extras []string
+}
+
+func (f *fakeSDK) ConfigGetString(ctx context.Context, key string) (string, error) {
+	return f.config[key], nil
+}
+
+func (f *fakeSDK) ConfigGet(ctx context.Context, key string) (interface{}, error) {
+	return f.config[key], nil
+}
+
+func (f *fakeSDK) MediaGet(ctx context.Context, id int64) (*plugins.MediaItem, error) {
+	return f.item, nil
+}
+
+func (f *fakeSDK) MediaFiles(ctx context.Context, mediaItemID int64) ([]plugins.MediaFile, error) {
+	return f.files, nil
+}
+
+func (f *fakeSDK) MediaAddExtraFile(ctx context.Context, mediaItemID int64, path, kind string) error {
+	f.extras = append(f.extras, kind+":"+path)
+	return nil
+}
+
+func TestFetchSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "The Matrix (1999).mkv")
+	if err := os.WriteFile(video, make([]byte, 3*hashChunkSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" && r.Header.Get("Api-Key") != "key" {
+			t.Errorf("request without the API key: %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/subtitles":
+			q := r.URL.Query()
+			if q.Get("imdb_id") != "133093" || q.Get("languages") != "en,es" || q.Get("moviehash") == "" {
+				t.Errorf("search query = %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"data":[
+				{"id":"1","attributes":{"language":"en","ratings":9,"download_count":10,"hearing_impaired":true,"files":[{"file_id":11}]}},
+				{"id":"2","attributes":{"language":"en","ratings":6,"download_count":5,"files":[{"file_id":22}]}},
+				{"id":"3","attributes":{"language":"fr","ratings":10,"files":[{"file_id":33}]}}]}`)
+		case "/download":
+			fmt.Fprintf(w, `{"link":%q}`, server.URL+"/file")
+		case "/file":
+			fmt.Fprint(w, "1\n00:00:01,000 --> 00:00:02,000\nWake up, Neo.\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewOpenSubtitlesPlugin()
+	p.client.baseURL = server.URL
+	sdk := &fakeSDK{
+		config: map[string]string{configAPIKey: "key", configLanguages: "es, en"},
+		item: &plugins.MediaItem{
+			ID:          1,
+			Kind:        "movie",
+			Title:       "The Matrix",
+			ExternalIDs: map[string]interface{}{"imdb_id": "tt0133093"},
+		},
+	}
+
+	if err := p.fetchSubtitles(context.Background(), sdk, videoQuery{MediaItemID: 1, Path: video}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(dir, "The Matrix (1999).en.srt")
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("English subtitle not saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "The Matrix (1999).es.srt")); !os.IsNotExist(err) {
+		t.Errorf("Spanish subtitle saved without a result")
+	}
+	if len(sdk.extras) != 1 || sdk.extras[0] != "subtitle:"+want {
+		t.Errorf("extra files = %v", sdk.extras)
+	}
+}
+
+func TestDownloadSubtitle(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "The Matrix (1999).mkv")
+	if err := os.WriteFile(video, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			fmt.Fprintf(w, `{"link":%q}`, server.URL+"/file")
+		case "/file":
+			fmt.Fprint(w, "1\n00:00:01,000 --> 00:00:02,000\nWake up, Neo.\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewOpenSubtitlesPlugin()
+	p.client.baseURL = server.URL
+	sdk := &fakeSDK{
+		config: map[string]string{configAPIKey: "key"},
+		files: []plugins.MediaFile{
+			{ID: 1, Path: filepath.Join(dir, "sample.mkv"), Kind: "media", Status: "missing"},
+			{ID: 2, Path: video, Kind: "media", Status: "ok"},
+		},
+	}
+	download := func(body string) *plugins.PluginHTTPResponse {
+		resp, err := p.HandleAPI(context.Background(), &plugins.PluginHTTPRequest{
+			Method: "POST",
+			Route:  "/api/plugins/opensubtitles/download",
+			Body:   []byte(body),
+			SDK:    sdk,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, lang := range []string{"../../etc/cron.d/x", "en/../..", "en.srt", ""} {
+		body, _ := json.Marshal(map[string]interface{}{"media_item_id": 1, "file_id": 11, "language": lang})
+		if resp := download(string(body)); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("download with language %q: status %d, want 400", lang, resp.StatusCode)
+		}
+	}
+
+	// A path in the body is not where the subtitle goes
+	other := t.TempDir()
+	body, _ := json.Marshal(map[string]interface{}{
+		"media_item_id": 1, "file_id": 11, "language": "pt-BR", "path": filepath.Join(other, "video.mkv"),
+	})
+	if resp := download(string(body)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: status %d: %s", resp.StatusCode, resp.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "The Matrix (1999).pt-br.srt")); err != nil {
+		t.Errorf("subtitle not saved next to the media file: %v", err)
+	}
+	if entries, _ := os.ReadDir(other); len(entries) != 0 {
+		t.Errorf("subtitle saved next to the path in the request")
+	}
+
+	sdk.files = nil
+	if resp := download(`{"media_item_id": 1, "file_id": 11, "language": "en"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download for an item without a file: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSubtitlePath(t *testing.T) {
+	tests := []struct {
+		video string
+		lang  string
+		want  string
+	}{
+		{"/media/Movie (2020)/Movie.mkv", "EN", "/media/Movie (2020)/Movie.en.srt"},
+		{"/media/Movie (2020)/Movie.mkv", "zh-CN", "/media/Movie (2020)/Movie.zh-cn.srt"},
+		{"/media/Movie (2020)/Movie.mkv", "../../../etc/passwd", ""},
+		{"/media/Movie (2020)/Movie.mkv", "en/x", ""},
+		{"/media/Movie (2020)/Movie.mkv", "english", ""},
+	}
+	for _, tt := range tests {
+		got, err := subtitlePath(tt.video, tt.lang)
+		if got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("subtitlePath(%q, %q) = %q, %v, want %q", tt.video, tt.lang, got, err, tt.want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	results := make([]subtitle, 4)
+	for i := range results {
+		results[i].Attributes.Files = []subtitleFile{{FileID: int64(i + 1)}}
+	}
+	results[0].Attributes.Ratings = 10
+	results[0].Attributes.HearingImpaired = true
+	results[1].Attributes.Ratings = 5
+	results[2].Attributes.Ratings = 10
+	results[2].Attributes.MachineMade = true
+	results[3].Attributes.Ratings = 1
+	results[3].Attributes.MoviehashMatch = true
+
+	tests := []struct {
+		preferHearingImpaired bool
+		want                  []int64
+	}{
+		{false, []int64{4, 2, 1, 3}},
+		{true, []int64{4, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		list := candidates(results, tt.preferHearingImpaired)
+		for i, c := range list {
+			if c.FileID != tt.want[i] {
+				t.Errorf("candidates(%v) order = %v, want %v", tt.preferHearingImpaired, fileIDs(list), tt.want)
+				break
+			}
+		}
+	}
+}
+
+func fileIDs(list []candidate) []int64 {
+	ids := make([]int64, len(list))
+	for i, c := range list {
+		ids[i] = c.FileID
+	}
+	return ids
+}
+
+func TestMovieHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mkv")
+	content := make([]byte, 2*hashChunkSize)
+	content[0] = 1              // First chunk sums to 1
+	content[len(content)-8] = 2 // Last chunk sums to 2
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := movieHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%016x", 2*hashChunkSize+3); got != want {
+		t.Errorf("movieHash() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+#!/bin/bash
+# Build script for the Remote Torrent Client plugin
+
+set -e
+
+echo "Building Remote Torrent Client plugin..."
+
+# Build the Go binary
+go build -o remote-torrent .
+
+echo "✓ Plugin binary built: remote-torrent"
+echo ""
+echo "To install this plugin:"
+echo "  1. Create the plugin directory: mkdir -p /var/lib/nimbus/plugins/remote-torrent"
+echo "  2. Copy files:"
+echo "     - cp remote-torrent /var/lib/nimbus/plugins/remote-torrent/"
+echo "     - cp manifest.json /var/lib/nimbus/plugins/remote-torrent/"
+echo "  3. Enable plugins: export ENABLE_PLUGINS=true"
+echo "  4. Set plugins directory: export PLUGINS_DIR=/var/lib/nimbus/plugins"
+echo "  5. Restart Nimbus server"
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Supported torrent client types
+const (
+	ClientTypeQBittorrent  = "qbittorrent"
+	ClientTypeTransmission = "transmission"
+	ClientTypeDeluge       = "deluge"
+)
+
+// TorrentClient is the protocol a remote torrent client implementation must speak.
+// Torrents are always identified by their info hash.
+type TorrentClient interface {
+	// Add submits a torrent and returns its info hash when the client reports it
+	// synchronously (qBittorrent does not; those torrents are matched by tag instead)
+	Add(ctx context.Context, req AddRequest) (string, error)
+	List(ctx context.Context) ([]Torrent, error)
+	Pause(ctx context.Context, hash string) error
+	Resume(ctx context.Context, hash string) error
+	Remove(ctx context.Context, hash string, deleteFiles bool) error
+	// TestConnection verifies connectivity and credentials, returning the client version
+	TestConnection(ctx context.Context) (string, error)
+}
+
+// ClientConfig is the persisted configuration of one remote torrent client
+type ClientConfig struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"` // qbittorrent, transmission, deluge
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Category string `json:"category"` // qBittorrent category / Transmission label
+	Enabled  bool   `json:"enabled"`
+}
+
+// AddRequest describes a torrent to add to a client
+type AddRequest struct {
+	URL         string // Magnet link or .torrent URL
+	FileName    string
+	FileContent []byte // Raw .torrent file
+	Tag         string // Tag identifying the Nimbus download
+}
+
+// Torrent is a client-agnostic view of a torrent
+type Torrent struct {
+	Hash          string
+	Name          string
+	Size          int64
+	Progress      float64 // 0.0 - 1.0
+	DownloadSpeed int64   // bytes per second
+	ETA           int64   // seconds, 0 when unknown
+	Status        string  // Nimbus download status
+	ContentPath   string
+	Tags          []string
+	Error         string
+}
+
+// NewTorrentClient creates the client implementation for a configuration
+func NewTorrentClient(cfg ClientConfig) (TorrentClient, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("client %s has no URL configured", cfg.ID)
+	}
+
+	switch strings.ToLower(cfg.Type) {
+	case ClientTypeQBittorrent, "":
+		return NewQBittorrentClient(cfg.URL, cfg.Username, cfg.Password, cfg.Category), nil
+	case ClientTypeTransmission:
+		return NewTransmissionClient(cfg.URL, cfg.Username, cfg.Password, cfg.Category), nil
+	case ClientTypeDeluge:
+		return NewDelugeClient(cfg.URL, cfg.Password), nil
+	default:
+		return nil, fmt.Errorf("unsupported torrent client type: %s", cfg.Type)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// delugeNotAuthenticated is the JSON-RPC error code Deluge returns once the session cookie expires
+const delugeNotAuthenticated = 1
+
+// DelugeClient talks to a Deluge daemon through the Deluge Web UI JSON-RPC API
+type DelugeClient struct {
+	rpcURL   string
+	password string
+	http     *http.Client
+	mu       sync.Mutex
+	nextID   int
+}
+
+// delugeTorrent is a torrent as reported by core.get_torrents_status
+type delugeTorrent struct {
+	Name                string  `json:"name"`
+	TotalSize           int64   `json:"total_size"`
+	Progress            float64 `json:"progress"` // 0 - 100
+	DownloadPayloadRate float64 `json:"download_payload_rate"`
+	ETA                 float64 `json:"eta"`
+	State               string  `json:"state"`
+	SavePath            string  `json:"save_path"`
+	Message             string  `json:"message"`
+}
+
+var delugeTorrentFields = []string{
+	"name", "total_size", "progress", "download_payload_rate", "eta", "state", "save_path", "message",
+}
+
+// delugeError is a JSON-RPC error reported by Deluge
+type delugeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *delugeError) Error() string {
+	return fmt.Sprintf("Deluge error %d: %s", e.Code, e.Message)
+}
+
+// NewDelugeClient creates a client for the Deluge Web UI at baseURL. The Web UI
+// only uses a password; the daemon connection is made by the Web UI itself.
+func NewDelugeClient(baseURL, password string) *DelugeClient {
+	rpcURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(rpcURL, "/json") {
+		rpcURL += "/json"
+	}
+
+	jar, _ := cookiejar.New(nil)
+	return &DelugeClient{
+		rpcURL:   rpcURL,
+		password: password,
+		http: &http.Client{
+			Jar:     jar,
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Login authenticates against auth.login, storing the session cookie, and makes
+// sure the Web UI is connected to a daemon
+func (c *DelugeClient) Login(ctx context.Context) error {
+	var ok bool
+	if err := c.send(ctx, "auth.login", []interface{}{c.password}, &ok); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid Deluge password")
+	}
+
+	var connected bool
+	if err := c.send(ctx, "web.connected", []interface{}{}, &connected); err != nil {
+		return err
+	}
+	if connected {
+		return nil
+	}
+
+	// Connect the Web UI to the first configured daemon
+	var hosts [][]interface{}
+	if err := c.send(ctx, "web.get_hosts", []interface{}{}, &hosts); err != nil {
+		return err
+	}
+	if len(hosts) == 0 || len(hosts[0]) == 0 {
+		return fmt.Errorf("Deluge Web UI has no daemon configured")
+	}
+	hostID, _ := hosts[0][0].(string)
+	if err := c.send(ctx, "web.connect", []interface{}{hostID}, nil); err != nil {
+		return fmt.Errorf("failed to connect Deluge Web UI to daemon: %w", err)
+	}
+
+	return nil
+}
+
+// Add adds a torrent by magnet/URL or .torrent content and returns its info hash
+func (c *DelugeClient) Add(ctx context.Context, req AddRequest) (string, error) {
+	options := map[string]interface{}{}
+
+	var hash string
+	var err error
+	switch {
+	case len(req.FileContent) > 0:
+		fileName := req.FileName
+		if fileName == "" {
+			fileName = "upload.torrent"
+		}
+		err = c.call(ctx, "core.add_torrent_file", []interface{}{fileName, base64.StdEncoding.EncodeToString(req.FileContent), options}, &hash)
+	case strings.HasPrefix(req.URL, "magnet:"):
+		err = c.call(ctx, "core.add_torrent_magnet", []interface{}{req.URL, options}, &hash)
+	default:
+		err = c.call(ctx, "core.add_torrent_url", []interface{}{req.URL, options}, &hash)
+	}
+	if err != nil {
+		return "", err
+	}
+	if hash == "" {
+		return "", fmt.Errorf("Deluge rejected the torrent (already added?)")
+	}
+
+	return hash, nil
+}
+
+// List lists all torrents known to the daemon
+func (c *DelugeClient) List(ctx context.Context) ([]Torrent, error) {
+	var result map[string]delugeTorrent
+	if err := c.call(ctx, "core.get_torrents_status", []interface{}{map[string]interface{}{}, delugeTorrentFields}, &result); err != nil {
+		return nil, err
+	}
+
+	torrents := make([]Torrent, 0, len(result))
+	for hash, t := range result {
+		torrent := Torrent{
+			Hash:          hash,
+			Name:          t.Name,
+			Size:          t.TotalSize,
+			Progress:      t.Progress / 100,
+			DownloadSpeed: int64(t.DownloadPayloadRate),
+			ETA:           int64(t.ETA),
+			Status:        mapDelugeState(t.State, t.Progress),
+			ContentPath:   path.Join(t.SavePath, t.Name),
+		}
+		if torrent.Status == "failed" {
+			torrent.Error = t.Message
+		}
+		torrents = append(torrents, torrent)
+	}
+
+	return torrents, nil
+}
+
+// Pause pauses a torrent
+func (c *DelugeClient) Pause(ctx context.Context, hash string) error {
+	return c.call(ctx, "core.pause_torrent", []interface{}{[]string{hash}}, nil)
+}
+
+// Resume resumes a torrent
+func (c *DelugeClient) Resume(ctx context.Context, hash string) error {
+	return c.call(ctx, "core.resume_torrent", []interface{}{[]string{hash}}, nil)
+}
+
+// Remove removes a torrent, optionally deleting its data
+func (c *DelugeClient) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	return c.call(ctx, "core.remove_torrent", []interface{}{hash, deleteFiles}, nil)
+}
+
+// TestConnection logs in and returns the daemon version
+func (c *DelugeClient) TestConnection(ctx context.Context) (string, error) {
+	if err := c.Login(ctx); err != nil {
+		return "", err
+	}
+
+	var version string
+	if err := c.call(ctx, "daemon.info", []interface{}{}, &version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// call performs a JSON-RPC call, logging in again if the session has expired
+func (c *DelugeClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	err := c.send(ctx, method, params, result)
+	if rpcErr, ok := err.(*delugeError); ok && rpcErr.Code == delugeNotAuthenticated {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+		err = c.send(ctx, method, params, result)
+	}
+	return err
+}
+
+func (c *DelugeClient) send(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"method": method,
+		"params": params,
+		"id":     id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Deluge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Deluge response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Deluge %s returned HTTP %d", method, resp.StatusCode)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *delugeError    `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode Deluge response: %w", err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if result != nil && len(response.Result) > 0 && string(response.Result) != "null" {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("failed to decode Deluge %s result: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+// mapDelugeState converts a Deluge torrent state into a Nimbus download status
+func mapDelugeState(state string, progress float64) string {
+	switch state {
+	case "Error":
+		return "failed"
+	case "Seeding":
+		return "completed"
+	case "Paused":
+		if progress >= 100 {
+			return "completed"
+		}
+		return "paused"
+	case "Downloading":
+		return "downloading"
+	case "Moving":
+		return "processing"
+	default: // Queued, Checking, Allocating
+		return "queued"
+	}
+}
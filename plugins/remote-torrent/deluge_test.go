@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// newDelugeServer serves recorded fixtures and requires the session cookie set by auth.login
+func newDelugeServer(t *testing.T, expireAfterLogin bool) (*httptest.Server, *[]string) {
+	t.Helper()
+	calls := &[]string{}
+	session := "session-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+			ID     int           `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid RPC request: %v", err)
+			return
+		}
+		*calls = append(*calls, req.Method)
+
+		if req.Method == "auth.login" {
+			ok := len(req.Params) == 1 && req.Params[0] == "deluge"
+			if ok {
+				http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: session})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": ok, "error": nil, "id": req.ID})
+			return
+		}
+
+		cookie, err := r.Cookie("_session_id")
+		if err != nil || cookie.Value != session {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": nil,
+				"error":  map[string]interface{}{"message": "Not authenticated", "code": 1},
+				"id":     req.ID,
+			})
+			return
+		}
+
+		switch req.Method {
+		case "web.connected":
+			w.Write([]byte(`{"result": true, "error": null, "id": 2}`))
+		case "core.get_torrents_status":
+			w.Write(loadFixture(t, "deluge_get_torrents_status.json"))
+		case "core.add_torrent_magnet":
+			w.Write([]byte(`{"result": "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c", "error": null, "id": 4}`))
+		case "daemon.info":
+			w.Write([]byte(`{"result": "2.1.1", "error": null, "id": 5}`))
+		default:
+			w.Write([]byte(`{"result": null, "error": null, "id": 6}`))
+		}
+
+		// Simulate the Web UI dropping the session after the first authenticated call
+		if expireAfterLogin {
+			session = "session-2"
+			expireAfterLogin = false
+		}
+	}))
+
+	return server, calls
+}
+
+func TestDelugeList(t *testing.T) {
+	server, _ := newDelugeServer(t, false)
+	defer server.Close()
+
+	client := NewDelugeClient(server.URL, "deluge")
+	torrents, err := client.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(torrents) != 3 {
+		t.Fatalf("List() returned %d torrents, want 3", len(torrents))
+	}
+
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].Name < torrents[j].Name })
+
+	tests := []struct {
+		name         string
+		wantStatus   string
+		wantProgress float64
+		wantPath     string
+	}{
+		{"Broken.Release.2021", "failed", 0.1, "/downloads/incomplete/Broken.Release.2021"},
+		{"Some.Movie.2023.1080p.BluRay.x264", "completed", 1, "/downloads/complete/Some.Movie.2023.1080p.BluRay.x264"},
+		{"Some.Show.S01E02.720p.WEB-DL", "downloading", 0.4215, "/downloads/incomplete/Some.Show.S01E02.720p.WEB-DL"},
+	}
+
+	for i, tt := range tests {
+		got := torrents[i]
+		if got.Name != tt.name {
+			t.Fatalf("torrent %d name = %q, want %q", i, got.Name, tt.name)
+		}
+		if got.Status != tt.wantStatus {
+			t.Errorf("%s status = %q, want %q", tt.name, got.Status, tt.wantStatus)
+		}
+		if got.Progress != tt.wantProgress {
+			t.Errorf("%s progress = %v, want %v", tt.name, got.Progress, tt.wantProgress)
+		}
+		if got.ContentPath != tt.wantPath {
+			t.Errorf("%s content path = %q, want %q", tt.name, got.ContentPath, tt.wantPath)
+		}
+	}
+
+	if torrents[0].Error != "Error: No space left on device" {
+		t.Errorf("failed torrent error = %q", torrents[0].Error)
+	}
+}
+
+func TestDelugeAddMagnet(t *testing.T) {
+	server, calls := newDelugeServer(t, false)
+	defer server.Close()
+
+	client := NewDelugeClient(server.URL, "deluge")
+	hash, err := client.Add(context.Background(), AddRequest{URL: "magnet:?xt=urn:btih:0f1e2d3c"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if hash != "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c" {
+		t.Errorf("Add() hash = %q", hash)
+	}
+
+	// First attempt is rejected without a session, then the client logs in and retries
+	want := []string{"core.add_torrent_magnet", "auth.login", "web.connected", "core.add_torrent_magnet"}
+	if len(*calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", *calls, want)
+	}
+	for i := range want {
+		if (*calls)[i] != want[i] {
+			t.Errorf("calls = %v, want %v", *calls, want)
+			break
+		}
+	}
+}
+
+func TestDelugeReloginOnExpiredSession(t *testing.T) {
+	server, _ := newDelugeServer(t, true)
+	defer server.Close()
+
+	client := NewDelugeClient(server.URL, "deluge")
+	if _, err := client.TestConnection(context.Background()); err != nil {
+		t.Fatalf("TestConnection() error = %v", err)
+	}
+
+	// The session was rotated after login; List must transparently log in again
+	if _, err := client.List(context.Background()); err != nil {
+		t.Fatalf("List() after session expiry error = %v", err)
+	}
+}
+
+func TestDelugeWrongPassword(t *testing.T) {
+	server, _ := newDelugeServer(t, false)
+	defer server.Close()
+
+	client := NewDelugeClient(server.URL, "wrong")
+	if _, err := client.TestConnection(context.Background()); err == nil {
+		t.Fatal("TestConnection() succeeded with invalid password")
+	}
+}
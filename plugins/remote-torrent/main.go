@@ -0,0 +1,1436 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/hashicorp/go-plugin"
+)
+
+// RemoteTorrentPlugin implements the MediaSuitePlugin interface by proxying
+// downloads to a remote torrent client
+type RemoteTorrentPlugin struct {
+	mu        sync.RWMutex
+	downloads map[string]*Download
+	order     []string
+	configs   []ClientConfig
+	clients   map[string]TorrentClient
+	sdk       plugins.SDKInterface
+	sdkMu     sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// Configuration keys
+const (
+	pluginID        = "remote-torrent"
+	configPrefix    = "plugins.remote-torrent"
+	configClients   = configPrefix + ".clients"
+	configDownloads = configPrefix + ".downloads" // Persisted download state
+
+	// Single-client settings from before multiple clients were supported
+	legacyConfigURL      = configPrefix + ".url"
+	legacyConfigUsername = configPrefix + ".username"
+	legacyConfigPassword = configPrefix + ".password"
+	legacyConfigCategory = configPrefix + ".category"
+
+	apiPrefix = "/api/plugins/" + pluginID
+
+	// Tag prefix applied to every torrent so it can be matched back to its Nimbus download
+	downloadTagPrefix = "nimbus-"
+
+	pollInterval = 10 * time.Second
+
+	// importTimeout bounds an import, which may copy large files
+	importTimeout = 30 * time.Minute
+
+	// configSchemaVersion is the version of the shape of the stored config.
+	// Version 1 always stores clients as a JSON list, with the single-client
+	// settings of older versions moved into it.
+	configSchemaVersion = 1
+)
+
+// Download represents a download tracked by this plugin
+type Download struct {
+	ID              string                 `json:"id"`
+	ClientID        string                 `json:"client_id"` // Remote client holding the torrent
+	Name            string                 `json:"name"`
+	Status          string                 `json:"status"` // queued, downloading, processing, paused, completed, failed
+	Progress        float64                `json:"progress"`
+	TotalBytes      int64                  `json:"total_bytes"`
+	DownloadedBytes int64                  `json:"downloaded_bytes"`
+	Speed           int64                  `json:"speed"` // bytes per second
+	ETA             int64                  `json:"eta"`   // seconds
+	URL             string                 `json:"url,omitempty"`
+	FileName        string                 `json:"file_name,omitempty"`
+	Priority        int                    `json:"priority"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	Hash            string                 `json:"hash,omitempty"`         // Torrent info hash, known once the client reports it
+	ContentPath     string                 `json:"content_path,omitempty"` // Path of the torrent content on the client
+	Imported        bool                   `json:"imported"`
+	AddedAt         time.Time              `json:"added_at"`
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	Logs            []string               `json:"logs,omitempty"`
+	importing       bool                   `json:"-"` // Import currently in progress
+}
+
+// addLog appends a log line to the download. Callers must hold the plugin lock.
+func (d *Download) addLog(msg string) {
+	timestamp := time.Now().Format("15:04:05")
+	d.Logs = append(d.Logs, fmt.Sprintf("[%s] %s", timestamp, msg))
+
+	// Keep only last 50 log lines
+	if len(d.Logs) > 50 {
+		d.Logs = d.Logs[len(d.Logs)-50:]
+	}
+
+	fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] [%s] %s\n", d.Name, msg)
+}
+
+// tag returns the client tag/label identifying this download
+func (d *Download) tag() string {
+	return downloadTagPrefix + d.ID
+}
+
+// NewRemoteTorrentPlugin creates the plugin with an empty download list
+func NewRemoteTorrentPlugin() *RemoteTorrentPlugin {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RemoteTorrentPlugin{
+		downloads: make(map[string]*Download),
+		order:     []string{},
+		clients:   make(map[string]TorrentClient),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Metadata returns plugin metadata
+func (p *RemoteTorrentPlugin) Metadata(ctx context.Context) (*plugins.PluginMetadata, error) {
+	return &plugins.PluginMetadata{
+		ID:           pluginID,
+		Name:         "Remote Torrent Client",
+		Version:      "0.1.0",
+		Description:  "Send downloads to existing qBittorrent, Transmission or Deluge clients and import them when complete",
+		Capabilities: []string{"api"},
+	}, nil
+}
+
+// APIRoutes returns the HTTP routes this plugin provides
+func (p *RemoteTorrentPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	// Changing and testing clients is admin-only; any user can list the
+	// clients, with their passwords masked, and manage downloads
+	return []plugins.RouteDescriptor{
+		// Download management
+		{Method: "GET", Path: apiPrefix + "/downloads", Auth: "session"},
+		{Method: "POST", Path: apiPrefix + "/downloads", Auth: "session"},
+		{Method: "GET", Path: apiPrefix + "/downloads/{id}", Auth: "session"},
+		{Method: "DELETE", Path: apiPrefix + "/downloads/{id}", Auth: "session"},
+		{Method: "POST", Path: apiPrefix + "/downloads/{id}/pause", Auth: "session"},
+		{Method: "POST", Path: apiPrefix + "/downloads/{id}/resume", Auth: "session"},
+		{Method: "POST", Path: apiPrefix + "/downloads/{id}/retry", Auth: "session"},
+		// Client management
+		{Method: "GET", Path: apiPrefix + "/clients", Auth: "session"},
+		{Method: "POST", Path: apiPrefix + "/clients", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "PUT", Path: apiPrefix + "/clients/{id}", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "DELETE", Path: apiPrefix + "/clients/{id}", Auth: "session", Role: plugins.ScopeAdmin},
+		{Method: "POST", Path: apiPrefix + "/clients/{id}/test", Auth: "session", Role: plugins.ScopeAdmin},
+	}, nil
+}
+
+// HandleAPI handles HTTP requests for this plugin's routes
+func (p *RemoteTorrentPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK != nil {
+		p.sdkMu.Lock()
+		if p.sdk == nil {
+			p.sdk = req.SDK
+			// Load persisted downloads on first API call
+			if err := p.loadDownloads(ctx, req.SDK); err != nil {
+				fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to load persisted downloads: %v\n", err)
+			}
+		}
+		p.sdkMu.Unlock()
+
+		p.loadClients(ctx, req.SDK)
+	}
+
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern and take IDs from its placeholders
+	id := req.PathParams["id"]
+	switch req.Method + " " + strings.TrimPrefix(req.Route, apiPrefix) {
+	// Download management
+	case "GET /downloads":
+		return p.handleListDownloads(ctx, req)
+	case "POST /downloads":
+		return p.handleAddDownload(ctx, req)
+	case "GET /downloads/{id}":
+		return p.handleGetDownload(ctx, req, id)
+	case "DELETE /downloads/{id}":
+		return p.handleDeleteDownload(ctx, req, id)
+	case "POST /downloads/{id}/pause":
+		return p.handlePauseDownload(ctx, req, id)
+	case "POST /downloads/{id}/resume", "POST /downloads/{id}/retry":
+		return p.handleResumeDownload(ctx, req, id)
+
+	// Client management
+	case "GET /clients":
+		return p.handleListClients(ctx, req)
+	case "POST /clients":
+		return p.handleCreateClient(ctx, req)
+	case "PUT /clients/{id}":
+		return p.handleUpdateClient(ctx, req, id)
+	case "DELETE /clients/{id}":
+		return p.handleDeleteClient(ctx, req, id)
+	case "POST /clients/{id}/test":
+		return p.handleTestClient(ctx, req, id)
+	}
+
+	return jsonResponse(http.StatusNotFound, map[string]string{"error": "Not found"})
+}
+
+// Download Management Handlers
+
+func (p *RemoteTorrentPlugin) handleListDownloads(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	// Refresh from the clients so the list reflects live progress
+	if err := p.refresh(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to refresh torrents: %v\n", err)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	downloads := make([]*Download, 0, len(p.order))
+	for _, id := range p.order {
+		if dl, exists := p.downloads[id]; exists {
+			downloads = append(downloads, dl)
+		}
+	}
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{"downloads": downloads})
+}
+
+func (p *RemoteTorrentPlugin) handleGetDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	if err := p.refresh(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to refresh torrents: %v\n", err)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	dl, exists := p.downloads[downloadID]
+	if !exists {
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
+	}
+
+	return jsonResponse(http.StatusOK, dl)
+}
+
+func (p *RemoteTorrentPlugin) handleAddDownload(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	var input struct {
+		URL         string                 `json:"url"`
+		Name        string                 `json:"name"`
+		Priority    int                    `json:"priority"`
+		Metadata    map[string]interface{} `json:"metadata"`
+		FileContent []byte                 `json:"file_content"`
+		FileName    string                 `json:"file_name"`
+		ClientID    string                 `json:"client_id"`
+	}
+
+	form, err := req.MultipartForm(maxTorrentFileSize)
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if form != nil {
+		// Torrent file posted as a multipart form, as the host sends uploads
+		defer form.RemoveAll()
+		input.URL = formValue(form, "url")
+		input.Name = formValue(form, "name")
+		input.ClientID = formValue(form, "client_id")
+		if priority := formValue(form, "priority"); priority != "" {
+			if input.Priority, err = strconv.Atoi(priority); err != nil {
+				return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid priority"})
+			}
+		}
+		if metadata := formValue(form, "metadata"); metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &input.Metadata); err != nil {
+				return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid metadata"})
+			}
+		}
+		if files := form.File["file"]; len(files) > 0 {
+			if files[0].Size > maxTorrentFileSize {
+				return jsonResponse(http.StatusRequestEntityTooLarge, map[string]string{"error": "Torrent file is too large"})
+			}
+			if input.FileContent, err = readFormFile(files[0]); err != nil {
+				return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			input.FileName = files[0].Filename
+		}
+	} else if err := json.Unmarshal(req.Body, &input); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	}
+
+	if input.URL == "" && len(input.FileContent) == 0 {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "A magnet link, torrent URL or torrent file is required"})
+	}
+
+	clientID := input.ClientID
+	if clientID == "" {
+		clientID, _ = input.Metadata["client_id"].(string)
+	}
+	clientID, client := p.selectClient(clientID)
+	if client == nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "No enabled torrent client configured"})
+	}
+
+	// The downloader service re-submits active downloads on startup; hand back the
+	// existing download instead of adding the same torrent twice
+	if input.URL != "" {
+		p.mu.RLock()
+		for _, id := range p.order {
+			dl := p.downloads[id]
+			if dl != nil && dl.URL == input.URL && dl.Status != "failed" {
+				p.mu.RUnlock()
+				return jsonResponse(http.StatusOK, dl)
+			}
+		}
+		p.mu.RUnlock()
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(input.FileName), ".torrent")
+	}
+	if name == "" || name == "." {
+		name = fmt.Sprintf("download-%d", time.Now().Unix())
+	}
+
+	download := &Download{
+		ID:       generateID(),
+		ClientID: clientID,
+		Name:     name,
+		Status:   "queued",
+		URL:      input.URL,
+		FileName: input.FileName,
+		Priority: input.Priority,
+		Metadata: input.Metadata,
+		AddedAt:  time.Now(),
+	}
+
+	hash, err := client.Add(ctx, AddRequest{
+		URL:         input.URL,
+		FileName:    input.FileName,
+		FileContent: input.FileContent,
+		Tag:         download.tag(),
+	})
+	if err != nil {
+		return jsonResponse(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+	download.Hash = hash
+
+	p.mu.Lock()
+	download.addLog(fmt.Sprintf("Torrent added to client %s", clientID))
+	p.downloads[download.ID] = download
+	p.order = append(p.order, download.ID)
+	p.mu.Unlock()
+
+	p.persistDownloadState()
+
+	return jsonResponse(http.StatusCreated, download)
+}
+
+// maxTorrentFileSize caps an uploaded torrent file
+const maxTorrentFileSize = 10 << 20
+
+// formValue returns the first value of a form field
+func formValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// readFormFile reads an uploaded file
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the uploaded file: %v", err)
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (p *RemoteTorrentPlugin) handleDeleteDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	p.mu.Lock()
+	dl, exists := p.downloads[downloadID]
+	if !exists {
+		p.mu.Unlock()
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
+	}
+	hash := dl.Hash
+	clientID := dl.ClientID
+	p.mu.Unlock()
+
+	deleteFiles := false
+	if values, ok := req.Query["delete_files"]; ok && len(values) > 0 {
+		deleteFiles = values[0] == "true"
+	}
+
+	if hash != "" {
+		if client := p.getClient(clientID); client != nil {
+			if err := client.Remove(ctx, hash, deleteFiles); err != nil {
+				return jsonResponse(http.StatusBadGateway, map[string]string{"error": err.Error()})
+			}
+		}
+	}
+
+	p.mu.Lock()
+	delete(p.downloads, downloadID)
+	newOrder := make([]string, 0, len(p.order))
+	for _, id := range p.order {
+		if id != downloadID {
+			newOrder = append(newOrder, id)
+		}
+	}
+	p.order = newOrder
+	p.mu.Unlock()
+
+	p.persistDownloadState()
+
+	return jsonResponse(http.StatusOK, map[string]string{"message": "Download deleted successfully"})
+}
+
+func (p *RemoteTorrentPlugin) handlePauseDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	return p.controlDownload(ctx, downloadID, "paused", func(client TorrentClient, hash string) error {
+		return client.Pause(ctx, hash)
+	})
+}
+
+func (p *RemoteTorrentPlugin) handleResumeDownload(ctx context.Context, req *plugins.PluginHTTPRequest, downloadID string) (*plugins.PluginHTTPResponse, error) {
+	return p.controlDownload(ctx, downloadID, "queued", func(client TorrentClient, hash string) error {
+		return client.Resume(ctx, hash)
+	})
+}
+
+// controlDownload applies a pause/resume style action to the torrent backing a download
+func (p *RemoteTorrentPlugin) controlDownload(ctx context.Context, downloadID string, newStatus string, action func(client TorrentClient, hash string) error) (*plugins.PluginHTTPResponse, error) {
+	p.mu.RLock()
+	dl, exists := p.downloads[downloadID]
+	var hash, clientID string
+	if exists {
+		hash = dl.Hash
+		clientID = dl.ClientID
+	}
+	p.mu.RUnlock()
+
+	if !exists {
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Download not found"})
+	}
+	if hash == "" {
+		return jsonResponse(http.StatusConflict, map[string]string{"error": "Torrent has not been registered by the client yet"})
+	}
+
+	client := p.getClient(clientID)
+	if client == nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Torrent client %s is not configured", clientID)})
+	}
+
+	if err := action(client, hash); err != nil {
+		return jsonResponse(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+
+	p.mu.Lock()
+	dl.Status = newStatus
+	dl.Error = ""
+	dl.addLog(fmt.Sprintf("Download %s by user", newStatus))
+	p.mu.Unlock()
+
+	p.persistDownloadState()
+
+	return jsonResponse(http.StatusOK, map[string]string{"message": "Download updated successfully"})
+}
+
+// Client Management Handlers
+
+func (p *RemoteTorrentPlugin) handleListClients(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	p.mu.RLock()
+	clients := make([]ClientConfig, len(p.configs))
+	copy(clients, p.configs)
+	p.mu.RUnlock()
+
+	// Mask passwords
+	for i := range clients {
+		clients[i].Password = maskPassword(clients[i].Password)
+	}
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{"clients": clients})
+}
+
+func (p *RemoteTorrentPlugin) handleCreateClient(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+
+	var cfg ClientConfig
+	if err := json.Unmarshal(req.Body, &cfg); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	}
+
+	if cfg.ID == "" {
+		cfg.ID = generateID()
+	}
+	if cfg.Type == "" {
+		cfg.Type = ClientTypeQBittorrent
+	}
+	if _, err := NewTorrentClient(cfg); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	p.mu.RLock()
+	configs := append([]ClientConfig{}, p.configs...)
+	p.mu.RUnlock()
+
+	for _, existing := range configs {
+		if existing.ID == cfg.ID {
+			return jsonResponse(http.StatusConflict, map[string]string{"error": "Client ID already exists"})
+		}
+	}
+
+	configs = append(configs, cfg)
+	if err := p.saveClients(ctx, req.SDK, configs); err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	cfg.Password = maskPassword(cfg.Password)
+	return jsonResponse(http.StatusCreated, cfg)
+}
+
+func (p *RemoteTorrentPlugin) handleUpdateClient(ctx context.Context, req *plugins.PluginHTTPRequest, clientID string) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+
+	var updated ClientConfig
+	if err := json.Unmarshal(req.Body, &updated); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	}
+
+	p.mu.RLock()
+	configs := append([]ClientConfig{}, p.configs...)
+	p.mu.RUnlock()
+
+	found := false
+	for i, existing := range configs {
+		if existing.ID == clientID {
+			updated.ID = clientID // Ensure ID doesn't change
+			// The UI round-trips the masked password; keep the stored one
+			if updated.Password == maskPassword(existing.Password) {
+				updated.Password = existing.Password
+			}
+			configs[i] = updated
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	}
+	if _, err := NewTorrentClient(updated); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := p.saveClients(ctx, req.SDK, configs); err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	updated.Password = maskPassword(updated.Password)
+	return jsonResponse(http.StatusOK, updated)
+}
+
+func (p *RemoteTorrentPlugin) handleDeleteClient(ctx context.Context, req *plugins.PluginHTTPRequest, clientID string) (*plugins.PluginHTTPResponse, error) {
+	if req.SDK == nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	}
+
+	p.mu.RLock()
+	configs := make([]ClientConfig, 0, len(p.configs))
+	found := false
+	for _, cfg := range p.configs {
+		if cfg.ID == clientID {
+			found = true
+			continue
+		}
+		configs = append(configs, cfg)
+	}
+	p.mu.RUnlock()
+
+	if !found {
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	}
+
+	if err := p.saveClients(ctx, req.SDK, configs); err != nil {
+		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return jsonResponse(http.StatusOK, map[string]string{"message": "Client deleted"})
+}
+
+func (p *RemoteTorrentPlugin) handleTestClient(ctx context.Context, req *plugins.PluginHTTPRequest, clientID string) (*plugins.PluginHTTPResponse, error) {
+	p.mu.RLock()
+	var cfg *ClientConfig
+	for i := range p.configs {
+		if p.configs[i].ID == clientID {
+			c := p.configs[i]
+			cfg = &c
+			break
+		}
+	}
+	p.mu.RUnlock()
+
+	if cfg == nil {
+		return jsonResponse(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	}
+
+	// Use a fresh client so the test always performs a full login
+	client, err := NewTorrentClient(*cfg)
+	if err != nil {
+		return jsonResponse(http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	version, err := client.TestConnection(ctx)
+	if err != nil {
+		return jsonResponse(http.StatusOK, map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Connection failed: %v", err),
+		})
+	}
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Connection successful",
+		"version": version,
+	})
+}
+
+// Torrent Polling
+
+// pollTorrents periodically refreshes download state from the clients so that
+// completed torrents are imported even when nobody is watching the queue
+func (p *RemoteTorrentPlugin) pollTorrents() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+			if err := p.refresh(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Poll failed: %v\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// refresh pulls the torrent lists from every client, updates tracked downloads and
+// kicks off imports for torrents that have finished downloading
+func (p *RemoteTorrentPlugin) refresh(ctx context.Context) error {
+	p.mu.RLock()
+	tracked := len(p.order)
+	clients := make(map[string]TorrentClient, len(p.clients))
+	for id, client := range p.clients {
+		clients[id] = client
+	}
+	p.mu.RUnlock()
+	if tracked == 0 || len(clients) == 0 {
+		return nil
+	}
+
+	// Index each client's torrents by hash and by download tag. A client that
+	// fails to respond leaves its downloads untouched rather than failing them.
+	type clientTorrents struct {
+		byHash map[string]*Torrent
+		byTag  map[string]*Torrent
+	}
+	listed := make(map[string]*clientTorrents, len(clients))
+	var errs []string
+	for id, client := range clients {
+		torrents, err := client.List(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", id, err))
+			continue
+		}
+
+		index := &clientTorrents{
+			byHash: make(map[string]*Torrent, len(torrents)),
+			byTag:  make(map[string]*Torrent, len(torrents)),
+		}
+		for i := range torrents {
+			index.byHash[strings.ToLower(torrents[i].Hash)] = &torrents[i]
+			for _, tag := range torrents[i].Tags {
+				if strings.HasPrefix(tag, downloadTagPrefix) {
+					index.byTag[tag] = &torrents[i]
+				}
+			}
+		}
+		listed[id] = index
+	}
+
+	var changed []*Download
+	var toImport []*Download
+
+	p.mu.Lock()
+	for _, id := range p.order {
+		dl := p.downloads[id]
+		if dl == nil || dl.Imported || dl.importing {
+			continue
+		}
+
+		index, ok := listed[dl.ClientID]
+		if !ok {
+			continue
+		}
+
+		torrent, found := index.byHash[strings.ToLower(dl.Hash)]
+		if !found || dl.Hash == "" {
+			torrent, found = index.byTag[dl.tag()]
+		}
+		if !found {
+			// Only treat a missing torrent as removed once the client had registered it
+			if dl.Hash != "" && dl.Status != "failed" {
+				dl.Status = "failed"
+				dl.Error = fmt.Sprintf("Torrent was removed from client %s", dl.ClientID)
+				dl.addLog(dl.Error)
+				changed = append(changed, dl)
+			}
+			continue
+		}
+
+		before := *dl
+		dl.Hash = torrent.Hash
+		dl.ContentPath = torrent.ContentPath
+		dl.TotalBytes = torrent.Size
+		dl.DownloadedBytes = int64(torrent.Progress * float64(torrent.Size))
+		dl.Progress = torrent.Progress * 100
+		dl.Speed = torrent.DownloadSpeed
+		dl.ETA = torrent.ETA
+
+		status := torrent.Status
+		if status == "downloading" && dl.StartedAt == nil {
+			now := time.Now()
+			dl.StartedAt = &now
+		}
+		if status == "failed" && dl.Status != "failed" {
+			dl.Error = torrent.Error
+			if dl.Error == "" {
+				dl.Error = "Torrent client reported an error"
+			}
+			dl.addLog(dl.Error)
+		}
+
+		if status == "completed" {
+			dl.Status = "processing"
+			dl.importing = true
+			dl.Progress = 100
+			dl.Speed = 0
+			dl.ETA = 0
+			dl.addLog("Torrent download complete, importing...")
+			toImport = append(toImport, dl)
+		} else {
+			dl.Status = status
+		}
+
+		if dl.Status != before.Status || dl.Progress != before.Progress || dl.Hash != before.Hash {
+			changed = append(changed, dl)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, dl := range toImport {
+		go p.importDownload(dl)
+	}
+
+	if len(changed) > 0 {
+		p.persistDownloadState()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to list torrents: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// importDownload hands a completed torrent's content to the Nimbus importer
+func (p *RemoteTorrentPlugin) importDownload(dl *Download) {
+	p.mu.RLock()
+	contentPath := dl.ContentPath
+	metadata := dl.Metadata
+	downloadID := dl.ID
+	p.mu.RUnlock()
+
+	err := p.importContent(downloadID, contentPath, metadata)
+
+	p.mu.Lock()
+	dl.importing = false
+	if errors.Is(err, plugins.ErrFileNotStable) {
+		// The client is still writing the files; the next poll tries again
+		dl.addLog(fmt.Sprintf("Files are still being written, retrying import: %v", err))
+		p.mu.Unlock()
+		return
+	}
+	dl.Imported = true
+	if err != nil {
+		dl.Status = "failed"
+		dl.Error = fmt.Sprintf("Import failed: %v", err)
+		dl.addLog(dl.Error)
+	} else {
+		dl.Status = "completed"
+		now := time.Now()
+		dl.CompletedAt = &now
+		dl.addLog("Processing completed successfully")
+	}
+	p.mu.Unlock()
+
+	p.persistDownloadState()
+}
+
+func (p *RemoteTorrentPlugin) importContent(downloadID string, contentPath string, metadata map[string]interface{}) error {
+	if contentPath == "" {
+		return fmt.Errorf("torrent client did not report a content path")
+	}
+
+	if metadata == nil || !shouldImport(metadata) {
+		// Nothing to import into; leave the files where the client put them
+		return nil
+	}
+
+	info, err := os.Stat(contentPath)
+	if err != nil {
+		return fmt.Errorf("content path %s is not accessible from Nimbus: %w", contentPath, err)
+	}
+
+	if !info.IsDir() {
+		return p.importFile(downloadID, contentPath, metadata["media_id"])
+	}
+
+	mediaKind, _ := metadata["media_kind"].(string)
+	if mediaKind == "tv_season" {
+		return p.importSeasonPack(downloadID, contentPath, metadata["media_id"])
+	}
+
+	mainFile, err := findMainMediaFile(contentPath)
+	if err != nil {
+		return err
+	}
+
+	return p.importFile(downloadID, mainFile, metadata["media_id"])
+}
+
+// importSeasonPack imports every episode file in a season pack
+func (p *RemoteTorrentPlugin) importSeasonPack(downloadID string, dir string, seasonMediaID interface{}) error {
+	files, err := findAllMediaFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 1 {
+		// Single file marked as season pack - the media_id is the episode itself
+		return p.importFile(downloadID, files[0], seasonMediaID)
+	}
+
+	successCount := 0
+	var lastErr error
+	for _, file := range files {
+		season, episode, found := parseEpisodeFromFilename(filepath.Base(file))
+		if !found {
+			lastErr = fmt.Errorf("could not parse season/episode from %s", filepath.Base(file))
+			continue
+		}
+
+		episodeMediaID, err := p.findEpisodeMediaID(seasonMediaID, season, episode)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := p.importFile(downloadID, file, episodeMediaID); err != nil {
+			if errors.Is(err, plugins.ErrFileNotStable) {
+				return err
+			}
+			lastErr = err
+			continue
+		}
+		successCount++
+	}
+
+	if successCount == 0 {
+		return fmt.Errorf("all %d episode imports failed: %v", len(files), lastErr)
+	}
+
+	return nil
+}
+
+// UIManifest returns the UI configuration for this plugin
+func (p *RemoteTorrentPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error) {
+	return &plugins.UIManifest{
+		NavItems: []plugins.UINavItem{},
+		Routes:   []plugins.UIRoute{},
+		ConfigSection: &plugins.ConfigSection{
+			Title:         "Remote Torrent Clients",
+			Description:   "Connect Nimbus to existing qBittorrent, Transmission or Deluge instances",
+			SchemaVersion: configSchemaVersion,
+			Fields: []plugins.ConfigField{
+				{
+					Key:          configClients,
+					Label:        "Torrent Clients",
+					Description:  "Remote clients, each with a type of qbittorrent, transmission or deluge",
+					Type:         "custom",
+					DefaultValue: "[]",
+					Required:     false,
+				},
+			},
+		},
+	}, nil
+}
+
+// MigrateConfig brings config stored by older versions of the plugin up to
+// configSchemaVersion
+func (p *RemoteTorrentPlugin) MigrateConfig(ctx context.Context, fromVersion int, config map[string]interface{}) (map[string]interface{}, error) {
+	changes := make(map[string]interface{})
+	if fromVersion < 1 {
+		clients, err := migrateClients(config)
+		if err != nil {
+			return nil, err
+		}
+		if clients != nil {
+			changes[configClients] = clients
+		}
+		for _, key := range []string{legacyConfigURL, legacyConfigUsername, legacyConfigPassword, legacyConfigCategory} {
+			if _, ok := config[key]; ok {
+				changes[key] = nil
+			}
+		}
+	}
+	return changes, nil
+}
+
+// migrateClients returns the stored clients as a list, when they were stored as
+// a string holding one or as the single qBittorrent client configured before
+// multiple clients were supported. It returns nil when nothing changes.
+func migrateClients(config map[string]interface{}) ([]ClientConfig, error) {
+	switch v := config[configClients].(type) {
+	case string:
+		configs := []ClientConfig{}
+		if strings.TrimSpace(v) != "" {
+			if err := json.Unmarshal([]byte(v), &configs); err != nil {
+				return nil, fmt.Errorf("stored clients are not a JSON list: %w", err)
+			}
+		}
+		return configs, nil
+	case nil:
+	default:
+		return nil, nil
+	}
+
+	url, _ := config[legacyConfigURL].(string)
+	if url == "" {
+		return nil, nil
+	}
+	legacy := ClientConfig{
+		ID:      "default",
+		Name:    "qBittorrent",
+		Type:    ClientTypeQBittorrent,
+		URL:     url,
+		Enabled: true,
+	}
+	legacy.Username, _ = config[legacyConfigUsername].(string)
+	legacy.Password, _ = config[legacyConfigPassword].(string)
+	legacy.Category, _ = config[legacyConfigCategory].(string)
+	return []ClientConfig{legacy}, nil
+}
+
+// HandleEvent handles system events
+func (p *RemoteTorrentPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
+	return nil
+}
+
+// IsIndexer returns false as this is not an indexer plugin
+func (p *RemoteTorrentPlugin) IsIndexer(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Search is not implemented for downloader plugins
+func (p *RemoteTorrentPlugin) Search(ctx context.Context, req *plugins.IndexerSearchRequest) (*plugins.IndexerSearchResponse, error) {
+	return nil, fmt.Errorf("remote torrent plugin does not support search")
+}
+
+// IsDownloader returns true as this plugin provides downloader functionality
+func (p *RemoteTorrentPlugin) IsDownloader(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// Helper functions
+
+// loadClients reads the client configurations and rebuilds the clients when they change
+func (p *RemoteTorrentPlugin) loadClients(ctx context.Context, sdk plugins.SDKInterface) {
+	configs, err := p.getClients(ctx, sdk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to load clients: %v\n", err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if clientConfigsEqual(configs, p.configs) && len(p.clients) > 0 {
+		return
+	}
+
+	p.configs = configs
+	p.clients = make(map[string]TorrentClient, len(configs))
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			continue
+		}
+		client, err := NewTorrentClient(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Skipping client %s: %v\n", cfg.ID, err)
+			continue
+		}
+		p.clients[cfg.ID] = client
+	}
+}
+
+// getClients reads the configured clients. They are always stored as a list,
+// since the config was migrated to configSchemaVersion on load.
+func (p *RemoteTorrentPlugin) getClients(ctx context.Context, sdk plugins.SDKInterface) ([]ClientConfig, error) {
+	val, err := sdk.ConfigGet(ctx, configClients)
+	if err != nil || val == nil {
+		return []ClientConfig{}, nil
+	}
+
+	var configs []ClientConfig
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &configs); err != nil {
+		return nil, fmt.Errorf("invalid stored clients: %w", err)
+	}
+	return configs, nil
+}
+
+func (p *RemoteTorrentPlugin) saveClients(ctx context.Context, sdk plugins.SDKInterface, configs []ClientConfig) error {
+	if err := sdk.ConfigSet(ctx, configClients, configs); err != nil {
+		return err
+	}
+	p.loadClients(ctx, sdk)
+	return nil
+}
+
+// selectClient returns the requested client, or the first enabled client when no ID is given
+func (p *RemoteTorrentPlugin) selectClient(clientID string) (string, TorrentClient) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if clientID != "" {
+		return clientID, p.clients[clientID]
+	}
+
+	for _, cfg := range p.configs {
+		if client, ok := p.clients[cfg.ID]; ok {
+			return cfg.ID, client
+		}
+	}
+
+	return "", nil
+}
+
+// getClient returns the client with the given ID, or nil if it is not configured
+func (p *RemoteTorrentPlugin) getClient(clientID string) TorrentClient {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clients[clientID]
+}
+
+func clientConfigsEqual(a, b []ClientConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *RemoteTorrentPlugin) saveDownloads(ctx context.Context, sdk plugins.SDKInterface) error {
+	p.mu.RLock()
+	downloads := make([]Download, 0, len(p.order))
+	for _, id := range p.order {
+		if dl, exists := p.downloads[id]; exists {
+			downloads = append(downloads, *dl)
+		}
+	}
+	p.mu.RUnlock()
+
+	return sdk.ConfigSet(ctx, configDownloads, downloads)
+}
+
+func (p *RemoteTorrentPlugin) loadDownloads(ctx context.Context, sdk plugins.SDKInterface) error {
+	val, err := sdk.ConfigGet(ctx, configDownloads)
+	if err != nil || val == nil {
+		return nil // No saved downloads
+	}
+
+	var persisted []Download
+	switch v := val.(type) {
+	case string:
+		if err := json.Unmarshal([]byte(v), &persisted); err != nil {
+			return err
+		}
+	default:
+		jsonData, _ := json.Marshal(v)
+		if err := json.Unmarshal(jsonData, &persisted); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range persisted {
+		dl := persisted[i]
+		if _, exists := p.downloads[dl.ID]; exists {
+			continue
+		}
+		p.downloads[dl.ID] = &dl
+		p.order = append(p.order, dl.ID)
+	}
+
+	return nil
+}
+
+// persistDownloadState saves download state to the config store and syncs it to
+// the unified downloads table
+func (p *RemoteTorrentPlugin) persistDownloadState() {
+	p.sdkMu.RLock()
+	sdk := p.sdk
+	p.sdkMu.RUnlock()
+
+	if sdk == nil {
+		return
+	}
+
+	if err := p.saveDownloads(context.Background(), sdk); err != nil {
+		fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to persist downloads: %v\n", err)
+	}
+
+	go p.syncDownloadsToDatabase(sdk)
+}
+
+// getSDK returns the SDK received with the first host request, or an error before then
+func (p *RemoteTorrentPlugin) getSDK() (plugins.SDKInterface, error) {
+	p.sdkMu.RLock()
+	defer p.sdkMu.RUnlock()
+
+	if p.sdk == nil {
+		return nil, fmt.Errorf("SDK not available")
+	}
+	return p.sdk, nil
+}
+
+// syncDownloadsToDatabase syncs all downloads to the PostgreSQL database through the SDK
+func (p *RemoteTorrentPlugin) syncDownloadsToDatabase(sdk plugins.SDKInterface) {
+	p.mu.RLock()
+	payloads := make([]map[string]interface{}, 0, len(p.order))
+	for _, id := range p.order {
+		dl, exists := p.downloads[id]
+		if !exists {
+			continue
+		}
+		payloads = append(payloads, map[string]interface{}{
+			"id":               dl.ID,
+			"plugin_id":        pluginID,
+			"name":             dl.Name,
+			"status":           dl.Status,
+			"progress":         dl.Progress,
+			"total_bytes":      dl.TotalBytes,
+			"downloaded_bytes": dl.DownloadedBytes,
+			"url":              dl.URL,
+			"file_name":        dl.FileName,
+			"error_message":    dl.Error,
+			"priority":         dl.Priority,
+			"metadata":         dl.Metadata,
+			"created_at":       dl.AddedAt,
+			"started_at":       dl.StartedAt,
+			"completed_at":     dl.CompletedAt,
+		})
+	}
+	p.mu.RUnlock()
+
+	for _, payload := range payloads {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := sdk.DownloadSync(ctx, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "[REMOTE-TORRENT] Failed to sync download %s: %v\n", payload["id"], err)
+		}
+		cancel()
+	}
+}
+
+func generateID() string {
+	// Generate a random 16-character alphanumeric ID using crypto/rand
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	const idLength = 16
+
+	b := make([]byte, idLength)
+	randomBytes := make([]byte, idLength)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		// Fallback to timestamp-based ID if random generation fails
+		return fmt.Sprintf("dl-%d", time.Now().UnixNano())
+	}
+
+	for i := range b {
+		b[i] = charset[int(randomBytes[i])%len(charset)]
+	}
+
+	return string(b)
+}
+
+func maskPassword(password string) string {
+	if password == "" {
+		return ""
+	}
+	if len(password) <= 4 {
+		return strings.Repeat("*", len(password))
+	}
+	return password[:2] + strings.Repeat("*", len(password)-4) + password[len(password)-2:]
+}
+
+func jsonResponse(statusCode int, data interface{}) (*plugins.PluginHTTPResponse, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugins.PluginHTTPResponse{
+		StatusCode: statusCode,
+		Headers: map[string][]string{
+			"Content-Type": {"application/json"},
+		},
+		Body: body,
+	}, nil
+}
+
+var mediaExtensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".m2ts", ".wmv", ".mov"}
+
+func isMediaFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, mediaExt := range mediaExtensions {
+		if ext == mediaExt {
+			return true
+		}
+	}
+	return false
+}
+
+// findAllMediaFiles finds all media files below a directory (torrents often nest content)
+func findAllMediaFiles(dir string) ([]string, error) {
+	var mediaFiles []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Skip sample folders shipped with many releases
+			if path != dir && strings.EqualFold(d.Name(), "sample") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMediaFile(d.Name()) {
+			mediaFiles = append(mediaFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	if len(mediaFiles) == 0 {
+		return nil, fmt.Errorf("no media files found in directory")
+	}
+
+	return mediaFiles, nil
+}
+
+// findMainMediaFile finds the largest media file below a directory
+func findMainMediaFile(dir string) (string, error) {
+	files, err := findAllMediaFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var largestFile string
+	var largestSize int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if info.Size() > largestSize {
+			largestSize = info.Size()
+			largestFile = file
+		}
+	}
+
+	if largestFile == "" {
+		return "", fmt.Errorf("no media files found in directory")
+	}
+
+	return largestFile, nil
+}
+
+// shouldImport checks if download has enough metadata to trigger import
+func shouldImport(metadata map[string]interface{}) bool {
+	mediaID, ok := metadata["media_id"]
+	return ok && mediaID != nil
+}
+
+// parseEpisodeFromFilename extracts season and episode numbers from a filename
+// Supports patterns like: S01E01, s01e01, 1x01, etc.
+func parseEpisodeFromFilename(filename string) (season int, episode int, found bool) {
+	lowerName := strings.ToLower(filename)
+
+	re := regexp.MustCompile(`s(\d+)e(\d+)`)
+	if matches := re.FindStringSubmatch(lowerName); len(matches) == 3 {
+		fmt.Sscanf(matches[1], "%d", &season)
+		fmt.Sscanf(matches[2], "%d", &episode)
+		return season, episode, true
+	}
+
+	re = regexp.MustCompile(`(\d+)x(\d+)`)
+	if matches := re.FindStringSubmatch(lowerName); len(matches) == 3 {
+		fmt.Sscanf(matches[1], "%d", &season)
+		fmt.Sscanf(matches[2], "%d", &episode)
+		return season, episode, true
+	}
+
+	return 0, 0, false
+}
+
+// toMediaID converts a JSON-decoded media_id into an int64
+func toMediaID(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case string:
+		var id int64
+		if parsed, err := fmt.Sscanf(v, "%d", &id); err != nil || parsed != 1 {
+			return 0, fmt.Errorf("invalid media_id format: %v", v)
+		}
+		return id, nil
+	default:
+		return 0, fmt.Errorf("unsupported media_id type: %T", v)
+	}
+}
+
+// findEpisodeMediaID looks up the media_item_id of an episode of a season
+func (p *RemoteTorrentPlugin) findEpisodeMediaID(seasonMediaID interface{}, season int, episode int) (int64, error) {
+	seasonID, err := toMediaID(seasonMediaID)
+	if err != nil {
+		return 0, err
+	}
+
+	sdk, err := p.getSDK()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	episodes, err := sdk.MediaList(ctx, seasonID, "tv_episode")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query episodes: %v", err)
+	}
+
+	for _, item := range episodes {
+		if meta := item.Metadata; meta != nil {
+			itemSeason, _ := meta["season"].(float64)
+			itemEpisode, _ := meta["episode"].(float64)
+			if int(itemSeason) == season && int(itemEpisode) == episode {
+				return item.ID, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("episode S%02dE%02d not found in database", season, episode)
+}
+
+// importFile imports a single file through the SDK
+func (p *RemoteTorrentPlugin) importFile(downloadID string, sourcePath string, mediaID interface{}) error {
+	mediaItemID, err := toMediaID(mediaID)
+	if err != nil {
+		return err
+	}
+
+	sdk, err := p.getSDK()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	_, err = sdk.ImportRequest(ctx, plugins.ImportFileRequest{
+		DownloadID:   downloadID,
+		SourcePath:   sourcePath,
+		MediaItemID:  mediaItemID,
+		TransferMode: "auto", // Keep the files so the torrent can go on seeding
+	})
+	return err
+}
+
+func main() {
+	torrentPlugin := NewRemoteTorrentPlugin()
+
+	// Poll the torrent clients for progress and completed torrents
+	go torrentPlugin.pollTorrents()
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: plugins.Handshake,
+		Plugins: map[string]plugin.Plugin{
+			"media-suite": &plugins.MediaSuitePluginGRPC{
+				Impl: torrentPlugin,
+			},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
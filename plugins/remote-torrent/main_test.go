@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	p := &RemoteTorrentPlugin{}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "current",
+			config: map[string]interface{}{configClients: []interface{}{}},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "clients stored as a string",
+			config: map[string]interface{}{configClients: `[{"id":"qb","type":"qbittorrent","url":"http://qb:8080","enabled":true}]`},
+			want: map[string]interface{}{
+				configClients: []ClientConfig{{ID: "qb", Type: ClientTypeQBittorrent, URL: "http://qb:8080", Enabled: true}},
+			},
+		},
+		{
+			name: "single legacy client",
+			config: map[string]interface{}{
+				legacyConfigURL:      "http://qb:8080",
+				legacyConfigUsername: "admin",
+				legacyConfigPassword: "secret",
+			},
+			want: map[string]interface{}{
+				configClients: []ClientConfig{{
+					ID: "default", Name: "qBittorrent", Type: ClientTypeQBittorrent,
+					URL: "http://qb:8080", Username: "admin", Password: "secret", Enabled: true,
+				}},
+				legacyConfigURL:      nil,
+				legacyConfigUsername: nil,
+				legacyConfigPassword: nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.MigrateConfig(context.Background(), 0, tt.config)
+			if err != nil {
+				t.Fatalf("MigrateConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MigrateConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := p.MigrateConfig(context.Background(), 0, map[string]interface{}{configClients: "not json"}); err == nil {
+		t.Error("MigrateConfig() accepted clients that are not a JSON list")
+	}
+}
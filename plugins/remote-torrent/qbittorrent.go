@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// QBittorrentClient talks to a remote qBittorrent instance through its Web API (v2)
+type QBittorrentClient struct {
+	baseURL  string
+	username string
+	password string
+	category string
+	http     *http.Client
+}
+
+// QBTorrent is a torrent as reported by /api/v2/torrents/info
+type QBTorrent struct {
+	Hash         string  `json:"hash"`
+	Name         string  `json:"name"`
+	Size         int64   `json:"size"`
+	TotalSize    int64   `json:"total_size"`
+	Downloaded   int64   `json:"downloaded"`
+	Progress     float64 `json:"progress"` // 0.0 - 1.0
+	DlSpeed      int64   `json:"dlspeed"`
+	ETA          int64   `json:"eta"`
+	State        string  `json:"state"`
+	Category     string  `json:"category"`
+	Tags         string  `json:"tags"` // Comma separated
+	SavePath     string  `json:"save_path"`
+	ContentPath  string  `json:"content_path"`
+	AddedOn      int64   `json:"added_on"`
+	CompletionOn int64   `json:"completion_on"`
+}
+
+// NewQBittorrentClient creates a client for the qBittorrent Web UI at baseURL.
+// Torrents are added to category and listing is restricted to it.
+func NewQBittorrentClient(baseURL, username, password, category string) *QBittorrentClient {
+	jar, _ := cookiejar.New(nil)
+	return &QBittorrentClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		category: category,
+		http: &http.Client{
+			Jar:     jar,
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Login authenticates against /api/v2/auth/login and stores the SID cookie
+func (c *QBittorrentClient) Login(ctx context.Context) error {
+	form := url.Values{}
+	form.Set("username", c.username)
+	form.Set("password", c.password)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent rejects requests whose Referer/Origin does not match the host (CSRF protection)
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to qBittorrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("qBittorrent banned this client after too many failed logins")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qBittorrent login returned HTTP %d", resp.StatusCode)
+	}
+	if strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("invalid qBittorrent username or password")
+	}
+
+	return nil
+}
+
+// TestConnection logs in and returns the qBittorrent application version
+func (c *QBittorrentClient) TestConnection(ctx context.Context) (string, error) {
+	if err := c.Login(ctx); err != nil {
+		return "", err
+	}
+
+	body, err := c.get(ctx, "/api/v2/app/version", nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// Add adds a torrent by magnet/URL or by .torrent file content. qBittorrent does
+// not report the info hash, so the torrent is tagged and matched by tag later.
+func (c *QBittorrentClient) Add(ctx context.Context, req AddRequest) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	if req.URL != "" {
+		writer.WriteField("urls", req.URL)
+	}
+	if len(req.FileContent) > 0 {
+		fileName := req.FileName
+		if fileName == "" {
+			fileName = "upload.torrent"
+		}
+		part, err := writer.CreateFormFile("torrents", fileName)
+		if err != nil {
+			return "", fmt.Errorf("failed to create torrent form file: %w", err)
+		}
+		if _, err := part.Write(req.FileContent); err != nil {
+			return "", fmt.Errorf("failed to write torrent form file: %w", err)
+		}
+	}
+	if c.category != "" {
+		writer.WriteField("category", c.category)
+	}
+	tags := []string{"nimbus"}
+	if req.Tag != "" {
+		tags = append(tags, req.Tag)
+	}
+	writer.WriteField("tags", strings.Join(tags, ","))
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to build add request: %w", err)
+	}
+
+	body, err := c.do(ctx, "POST", "/api/v2/torrents/add", writer.FormDataContentType(), buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(body)) == "Fails." {
+		return "", fmt.Errorf("qBittorrent rejected the torrent")
+	}
+
+	return "", nil
+}
+
+// List lists torrents in the configured category
+func (c *QBittorrentClient) List(ctx context.Context) ([]Torrent, error) {
+	query := url.Values{}
+	if c.category != "" {
+		query.Set("category", c.category)
+	}
+
+	body, err := c.get(ctx, "/api/v2/torrents/info", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var qbTorrents []QBTorrent
+	if err := json.Unmarshal(body, &qbTorrents); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent list: %w", err)
+	}
+
+	torrents := make([]Torrent, 0, len(qbTorrents))
+	for _, t := range qbTorrents {
+		torrent := Torrent{
+			Hash:          t.Hash,
+			Name:          t.Name,
+			Size:          t.Size,
+			Progress:      t.Progress,
+			DownloadSpeed: t.DlSpeed,
+			ETA:           t.ETA,
+			Status:        mapQBittorrentState(t.State),
+			ContentPath:   t.ContentPath,
+		}
+		// qBittorrent reports 8640000 (100 days) when the ETA is unknown
+		if torrent.ETA >= 8640000 {
+			torrent.ETA = 0
+		}
+		if torrent.Status == "failed" {
+			torrent.Error = fmt.Sprintf("qBittorrent reported state %s", t.State)
+		}
+		for _, tag := range strings.Split(t.Tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				torrent.Tags = append(torrent.Tags, tag)
+			}
+		}
+		torrents = append(torrents, torrent)
+	}
+
+	return torrents, nil
+}
+
+// Pause pauses a torrent
+func (c *QBittorrentClient) Pause(ctx context.Context, hash string) error {
+	// qBittorrent 5.0 renamed pause/resume to stop/start
+	return c.postHashesWithFallback(ctx, "/api/v2/torrents/pause", "/api/v2/torrents/stop", []string{hash}, nil)
+}
+
+// Resume resumes a torrent
+func (c *QBittorrentClient) Resume(ctx context.Context, hash string) error {
+	return c.postHashesWithFallback(ctx, "/api/v2/torrents/resume", "/api/v2/torrents/start", []string{hash}, nil)
+}
+
+// Remove removes a torrent, optionally deleting its data
+func (c *QBittorrentClient) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	extra := url.Values{}
+	extra.Set("deleteFiles", fmt.Sprintf("%t", deleteFiles))
+	return c.postHashesWithFallback(ctx, "/api/v2/torrents/delete", "", []string{hash}, extra)
+}
+
+func (c *QBittorrentClient) postHashesWithFallback(ctx context.Context, path string, fallbackPath string, hashes []string, extra url.Values) error {
+	form := url.Values{}
+	form.Set("hashes", strings.Join(hashes, "|"))
+	for key, values := range extra {
+		for _, value := range values {
+			form.Add(key, value)
+		}
+	}
+
+	_, err := c.do(ctx, "POST", path, "application/x-www-form-urlencoded", []byte(form.Encode()))
+	if err != nil && fallbackPath != "" && isNotFound(err) {
+		_, err = c.do(ctx, "POST", fallbackPath, "application/x-www-form-urlencoded", []byte(form.Encode()))
+	}
+	return err
+}
+
+func (c *QBittorrentClient) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+	return c.do(ctx, "GET", path, "", nil)
+}
+
+// do performs an API request, logging in first if the session is missing or has expired
+func (c *QBittorrentClient) do(ctx context.Context, method string, path string, contentType string, body []byte) ([]byte, error) {
+	respBody, status, err := c.send(ctx, method, path, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusForbidden {
+		if err := c.Login(ctx); err != nil {
+			return nil, err
+		}
+		respBody, status, err = c.send(ctx, method, path, contentType, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if status != http.StatusOK {
+		return nil, &apiError{Path: path, StatusCode: status, Body: strings.TrimSpace(string(respBody))}
+	}
+
+	return respBody, nil
+}
+
+func (c *QBittorrentClient) send(ctx context.Context, method string, path string, contentType string, body []byte) ([]byte, int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to call qBittorrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read qBittorrent response: %w", err)
+	}
+
+	return respBody, resp.StatusCode, nil
+}
+
+// apiError is returned when qBittorrent responds with a non-200 status
+type apiError struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("qBittorrent %s returned HTTP %d: %s", e.Path, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("qBittorrent %s returned HTTP %d", e.Path, e.StatusCode)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// mapQBittorrentState converts a qBittorrent torrent state into a Nimbus download status
+func mapQBittorrentState(state string) string {
+	switch state {
+	case "error", "missingFiles":
+		return "failed"
+	case "pausedDL", "stoppedDL":
+		return "paused"
+	case "queuedDL", "checkingDL", "checkingResumeData", "metaDL", "forcedMetaDL", "allocating":
+		return "queued"
+	case "downloading", "forcedDL", "stalledDL":
+		return "downloading"
+	case "moving":
+		return "processing"
+	case "uploading", "stalledUP", "pausedUP", "stoppedUP", "queuedUP", "forcedUP", "checkingUP":
+		return "completed"
+	default:
+		return "queued"
+	}
+}
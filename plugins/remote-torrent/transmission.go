@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transmissionSessionHeader carries Transmission's CSRF token. Any request without
+// a current token is answered with 409 and a fresh token in this header.
+const transmissionSessionHeader = "X-Transmission-Session-Id"
+
+// TransmissionClient talks to a Transmission daemon through its JSON RPC interface
+type TransmissionClient struct {
+	rpcURL    string
+	username  string
+	password  string
+	label     string
+	http      *http.Client
+	sessionMu sync.Mutex
+	sessionID string
+}
+
+// transmissionTorrent is a torrent as reported by torrent-get
+type transmissionTorrent struct {
+	HashString   string   `json:"hashString"`
+	Name         string   `json:"name"`
+	TotalSize    int64    `json:"totalSize"`
+	PercentDone  float64  `json:"percentDone"` // 0.0 - 1.0
+	RateDownload int64    `json:"rateDownload"`
+	ETA          int64    `json:"eta"` // -1 not available, -2 unknown
+	Status       int      `json:"status"`
+	Error        int      `json:"error"`
+	ErrorString  string   `json:"errorString"`
+	DownloadDir  string   `json:"downloadDir"`
+	Labels       []string `json:"labels"`
+}
+
+// Transmission torrent status codes
+const (
+	transmissionStopped      = 0
+	transmissionCheckWait    = 1
+	transmissionCheck        = 2
+	transmissionDownloadWait = 3
+	transmissionDownload     = 4
+	transmissionSeedWait     = 5
+	transmissionSeed         = 6
+)
+
+var transmissionTorrentFields = []string{
+	"hashString", "name", "totalSize", "percentDone", "rateDownload", "eta",
+	"status", "error", "errorString", "downloadDir", "labels",
+}
+
+// NewTransmissionClient creates a client for the Transmission RPC endpoint. baseURL
+// may be the web address (the /transmission/rpc path is appended) or the full RPC URL.
+func NewTransmissionClient(baseURL, username, password, label string) *TransmissionClient {
+	rpcURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(rpcURL, "/rpc") {
+		rpcURL += "/transmission/rpc"
+	}
+
+	return &TransmissionClient{
+		rpcURL:   rpcURL,
+		username: username,
+		password: password,
+		label:    label,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Add adds a torrent by magnet/URL or .torrent content and returns its info hash
+func (c *TransmissionClient) Add(ctx context.Context, req AddRequest) (string, error) {
+	args := map[string]interface{}{}
+	if len(req.FileContent) > 0 {
+		args["metainfo"] = base64.StdEncoding.EncodeToString(req.FileContent)
+	} else {
+		args["filename"] = req.URL
+	}
+	labels := c.labels(req.Tag)
+	if len(labels) > 0 {
+		args["labels"] = labels
+	}
+
+	var result struct {
+		TorrentAdded     *transmissionTorrent `json:"torrent-added"`
+		TorrentDuplicate *transmissionTorrent `json:"torrent-duplicate"`
+	}
+	if err := c.call(ctx, "torrent-add", args, &result); err != nil {
+		return "", err
+	}
+
+	added := result.TorrentAdded
+	if added == nil {
+		added = result.TorrentDuplicate
+	}
+	if added == nil || added.HashString == "" {
+		return "", fmt.Errorf("Transmission did not return the added torrent")
+	}
+
+	// Daemons older than 4.0 ignore labels on torrent-add, so set them explicitly
+	if len(labels) > 0 {
+		setArgs := map[string]interface{}{
+			"ids":    []string{added.HashString},
+			"labels": labels,
+		}
+		if err := c.call(ctx, "torrent-set", setArgs, nil); err != nil {
+			return "", err
+		}
+	}
+
+	return added.HashString, nil
+}
+
+// List lists all torrents known to the daemon
+func (c *TransmissionClient) List(ctx context.Context) ([]Torrent, error) {
+	var result struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}
+	if err := c.call(ctx, "torrent-get", map[string]interface{}{"fields": transmissionTorrentFields}, &result); err != nil {
+		return nil, err
+	}
+
+	torrents := make([]Torrent, 0, len(result.Torrents))
+	for _, t := range result.Torrents {
+		if c.label != "" && !containsString(t.Labels, c.label) {
+			continue
+		}
+
+		torrent := Torrent{
+			Hash:          t.HashString,
+			Name:          t.Name,
+			Size:          t.TotalSize,
+			Progress:      t.PercentDone,
+			DownloadSpeed: t.RateDownload,
+			ETA:           t.ETA,
+			Status:        mapTransmissionStatus(t),
+			ContentPath:   path.Join(t.DownloadDir, t.Name),
+			Tags:          t.Labels,
+		}
+		if torrent.ETA < 0 {
+			torrent.ETA = 0
+		}
+		if t.Error != 0 {
+			torrent.Error = t.ErrorString
+		}
+		torrents = append(torrents, torrent)
+	}
+
+	return torrents, nil
+}
+
+// Pause stops a torrent
+func (c *TransmissionClient) Pause(ctx context.Context, hash string) error {
+	return c.call(ctx, "torrent-stop", map[string]interface{}{"ids": []string{hash}}, nil)
+}
+
+// Resume starts a torrent
+func (c *TransmissionClient) Resume(ctx context.Context, hash string) error {
+	return c.call(ctx, "torrent-start", map[string]interface{}{"ids": []string{hash}}, nil)
+}
+
+// Remove removes a torrent, optionally deleting its data
+func (c *TransmissionClient) Remove(ctx context.Context, hash string, deleteFiles bool) error {
+	args := map[string]interface{}{
+		"ids":               []string{hash},
+		"delete-local-data": deleteFiles,
+	}
+	return c.call(ctx, "torrent-remove", args, nil)
+}
+
+// TestConnection verifies the RPC endpoint and credentials, returning the daemon version
+func (c *TransmissionClient) TestConnection(ctx context.Context) (string, error) {
+	var result struct {
+		Version string `json:"version"`
+	}
+	if err := c.call(ctx, "session-get", map[string]interface{}{"fields": []string{"version"}}, &result); err != nil {
+		return "", err
+	}
+	return result.Version, nil
+}
+
+func (c *TransmissionClient) labels(tag string) []string {
+	var labels []string
+	if c.label != "" {
+		labels = append(labels, c.label)
+	}
+	if tag != "" {
+		labels = append(labels, tag)
+	}
+	return labels
+}
+
+// call performs an RPC call, refreshing the session id once if Transmission asks for it
+func (c *TransmissionClient) call(ctx context.Context, method string, args interface{}, result interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"method":    method,
+		"arguments": args,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+
+	body, status, err := c.send(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusConflict {
+		body, status, err = c.send(ctx, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch status {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("invalid Transmission username or password")
+	default:
+		return fmt.Errorf("Transmission %s returned HTTP %d", method, status)
+	}
+
+	var response struct {
+		Result    string          `json:"result"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode Transmission response: %w", err)
+	}
+	if response.Result != "success" {
+		return fmt.Errorf("Transmission %s failed: %s", method, response.Result)
+	}
+
+	if result != nil && len(response.Arguments) > 0 {
+		if err := json.Unmarshal(response.Arguments, result); err != nil {
+			return fmt.Errorf("failed to decode Transmission %s arguments: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *TransmissionClient) send(ctx context.Context, payload []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	c.sessionMu.Lock()
+	sessionID := c.sessionID
+	c.sessionMu.Unlock()
+	if sessionID != "" {
+		req.Header.Set(transmissionSessionHeader, sessionID)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to call Transmission: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		c.sessionMu.Lock()
+		c.sessionID = resp.Header.Get(transmissionSessionHeader)
+		c.sessionMu.Unlock()
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read Transmission response: %w", err)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// mapTransmissionStatus converts a Transmission torrent into a Nimbus download status
+func mapTransmissionStatus(t transmissionTorrent) string {
+	if t.Error == 3 { // Local error (e.g. missing files or disk full)
+		return "failed"
+	}
+	if t.PercentDone >= 1 {
+		return "completed"
+	}
+
+	switch t.Status {
+	case transmissionStopped:
+		return "paused"
+	case transmissionDownload:
+		return "downloading"
+	case transmissionCheckWait, transmissionCheck, transmissionDownloadWait:
+		return "queued"
+	case transmissionSeedWait, transmissionSeed:
+		return "completed"
+	default:
+		return "queued"
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
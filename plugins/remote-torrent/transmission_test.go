@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func loadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+// newTransmissionServer serves recorded fixtures and enforces the session-id handshake
+func newTransmissionServer(t *testing.T, calls *[]string) *httptest.Server {
+	t.Helper()
+	const sessionID = "test-session-id"
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transmission/rpc" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(transmissionSessionHeader) != sessionID {
+			w.Header().Set(transmissionSessionHeader, sessionID)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		var req struct {
+			Method    string                 `json:"method"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid RPC request: %v", err)
+			return
+		}
+		*calls = append(*calls, req.Method)
+
+		switch req.Method {
+		case "torrent-get":
+			w.Write(loadFixture(t, "transmission_torrent_get.json"))
+		case "torrent-add":
+			w.Write(loadFixture(t, "transmission_torrent_add.json"))
+		case "session-get":
+			w.Write([]byte(`{"arguments":{"version":"4.0.5 (a6fe2a64aa)"},"result":"success"}`))
+		default:
+			w.Write([]byte(`{"arguments":{},"result":"success"}`))
+		}
+	}))
+}
+
+func TestTransmissionList(t *testing.T) {
+	var calls []string
+	server := newTransmissionServer(t, &calls)
+	defer server.Close()
+
+	client := NewTransmissionClient(server.URL, "admin", "secret", "nimbus")
+	torrents, err := client.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	// The torrent without the nimbus label is filtered out
+	if len(torrents) != 3 {
+		t.Fatalf("List() returned %d torrents, want 3", len(torrents))
+	}
+
+	tests := []struct {
+		hash        string
+		wantStatus  string
+		wantPath    string
+		wantETA     int64
+		wantErrText bool
+	}{
+		{"a1b2c3d4e5f60718293a4b5c6d7e8f9012345678", "completed", "/downloads/complete/Some.Movie.2023.1080p.BluRay.x264", 0, false},
+		{"0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c", "downloading", "/downloads/incomplete/Some.Show.S01E02.720p.WEB-DL", 1200, false},
+		{"ffeeddccbbaa99887766554433221100ffeeddcc", "failed", "/downloads/incomplete/Broken.Release.2021", 0, true},
+	}
+
+	for i, tt := range tests {
+		got := torrents[i]
+		if got.Hash != tt.hash {
+			t.Errorf("torrent %d hash = %q, want %q", i, got.Hash, tt.hash)
+		}
+		if got.Status != tt.wantStatus {
+			t.Errorf("torrent %s status = %q, want %q", tt.hash, got.Status, tt.wantStatus)
+		}
+		if got.ContentPath != tt.wantPath {
+			t.Errorf("torrent %s content path = %q, want %q", tt.hash, got.ContentPath, tt.wantPath)
+		}
+		if got.ETA != tt.wantETA {
+			t.Errorf("torrent %s ETA = %d, want %d", tt.hash, got.ETA, tt.wantETA)
+		}
+		if (got.Error != "") != tt.wantErrText {
+			t.Errorf("torrent %s error = %q", tt.hash, got.Error)
+		}
+	}
+
+	if len(calls) != 1 {
+		t.Errorf("expected 1 RPC call after the session handshake, got %v", calls)
+	}
+}
+
+func TestTransmissionAddSetsLabels(t *testing.T) {
+	var calls []string
+	server := newTransmissionServer(t, &calls)
+	defer server.Close()
+
+	client := NewTransmissionClient(server.URL, "admin", "secret", "nimbus")
+	hash, err := client.Add(context.Background(), AddRequest{URL: "magnet:?xt=urn:btih:a1b2c3", Tag: "nimbus-abc123"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if hash != "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678" {
+		t.Errorf("Add() hash = %q", hash)
+	}
+	if len(calls) != 2 || calls[0] != "torrent-add" || calls[1] != "torrent-set" {
+		t.Errorf("Add() calls = %v, want [torrent-add torrent-set]", calls)
+	}
+}
+
+func TestTransmissionBadCredentials(t *testing.T) {
+	var calls []string
+	server := newTransmissionServer(t, &calls)
+	defer server.Close()
+
+	client := NewTransmissionClient(server.URL, "admin", "wrong", "")
+	if _, err := client.TestConnection(context.Background()); err == nil {
+		t.Fatal("TestConnection() succeeded with invalid credentials")
+	}
+}
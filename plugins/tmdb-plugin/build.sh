@@ -2,7 +2,7 @@
 set -e
 
 echo "Building TMDB plugin..."
-go build -o tmdb-plugin main.go
+go build -o tmdb-plugin .
 echo "✓ Build successful!"
 
 echo ""
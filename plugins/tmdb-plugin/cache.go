@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const (
+	configSearchTTL  = "plugins.tmdb.cache_search_ttl_minutes"
+	configDetailsTTL = "plugins.tmdb.cache_details_ttl_minutes"
+
+	defaultSearchTTL  = time.Hour
+	defaultDetailsTTL = 24 * time.Hour
+
+	// maxCacheEntries bounds the cache, which holds whole TMDB responses
+	maxCacheEntries = 5000
+
+	// TMDB allows about 40 requests every 10 seconds
+	rateLimitRequests = 40
+	rateLimitWindow   = 10 * time.Second
+)
+
+// cacheEntry is a TMDB response and when it goes stale
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// responseCache holds TMDB responses by URL, without the API key
+type responseCache struct {
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	searchTTL  time.Duration
+	detailsTTL time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries:    make(map[string]cacheEntry),
+		searchTTL:  defaultSearchTTL,
+		detailsTTL: defaultDetailsTTL,
+	}
+}
+
+// cacheKey strips the API key from a TMDB URL, so responses survive a key change
+func cacheKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	query.Del("api_key")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// get returns the cached response for a URL, if it hasn't gone stale
+func (c *responseCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put caches a response. Searches go stale sooner than details, as new
+// releases show up in them.
+func (c *responseCache) put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.detailsTTL
+	if strings.Contains(key, "/search/") {
+		ttl = c.searchTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	if len(c.entries) >= maxCacheEntries {
+		c.evictLocked()
+	}
+	c.entries[key] = cacheEntry{data: data, expires: time.Now().Add(ttl)}
+}
+
+// evictLocked removes stale entries, and then the entries closest to going
+// stale until there is room for one more
+func (c *responseCache) evictLocked() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) >= maxCacheEntries {
+		var oldest string
+		var oldestExpires time.Time
+		for key, entry := range c.entries {
+			if oldest == "" || entry.expires.Before(oldestExpires) {
+				oldest, oldestExpires = key, entry.expires
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// setTTLs changes how long new responses are cached for
+func (c *responseCache) setTTLs(search, details time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.searchTTL = search
+	c.detailsTTL = details
+}
+
+// size returns the number of cached responses
+func (c *responseCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// loadCacheSettings reads the cache TTLs from the config store. A TTL of 0
+// turns caching off for that kind of request.
+func (p *TMDBPlugin) loadCacheSettings(ctx context.Context, sdk plugins.SDKInterface) {
+	search, details := defaultSearchTTL, defaultDetailsTTL
+	if sdk != nil {
+		if minutes, ok := configMinutes(ctx, sdk, configSearchTTL); ok {
+			search = minutes
+		}
+		if minutes, ok := configMinutes(ctx, sdk, configDetailsTTL); ok {
+			details = minutes
+		}
+	}
+	p.cache.setTTLs(search, details)
+}
+
+// configMinutes reads a duration in minutes from the config store
+func configMinutes(ctx context.Context, sdk plugins.SDKInterface, key string) (time.Duration, bool) {
+	val, err := sdk.ConfigGet(ctx, key)
+	if err != nil || val == nil {
+		return 0, false
+	}
+
+	var minutes float64
+	switch v := val.(type) {
+	case float64:
+		minutes = v
+	case string:
+		if minutes, err = strconv.ParseFloat(v, 64); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if minutes < 0 {
+		return 0, false
+	}
+	return time.Duration(minutes * float64(time.Minute)), true
+}
+
+// rateLimiter spaces out requests to TMDB. Requests over the limit wait for a
+// slot instead of failing.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	sent   []time.Time // Start times of requests within the window, oldest first
+
+	waiting   atomic.Int64 // Requests waiting for a slot now
+	throttled atomic.Int64 // Requests that had to wait
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window}
+}
+
+// wait blocks until a request may be sent, or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	counted := false
+	defer func() {
+		if counted {
+			l.waiting.Add(-1)
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		for len(l.sent) > 0 && now.Sub(l.sent[0]) >= l.window {
+			l.sent = l.sent[1:]
+		}
+		if len(l.sent) < l.limit {
+			l.sent = append(l.sent, now)
+			l.mu.Unlock()
+			return nil
+		}
+		delay := l.sent[0].Add(l.window).Sub(now)
+		l.mu.Unlock()
+
+		if !counted {
+			counted = true
+			l.waiting.Add(1)
+			l.throttled.Add(1)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// bypassCacheKey marks requests that should skip the cache
+type bypassCacheKey struct{}
+
+// withCacheBypass makes requests made with ctx go to TMDB, refreshing the cache
+func withCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func bypassesCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// handleStatus reports cache and rate limiter counters
+func (p *TMDBPlugin) handleStatus(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	p.loadCacheSettings(ctx, req.SDK)
+
+	hits, misses := p.cache.hits.Load(), p.cache.misses.Load()
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+
+	p.cache.mu.Lock()
+	searchTTL, detailsTTL := p.cache.searchTTL, p.cache.detailsTTL
+	p.cache.mu.Unlock()
+
+	return jsonResponse(map[string]interface{}{
+		"api_key_configured": getAPIKey(ctx, req.SDK) != "",
+		"cache": map[string]interface{}{
+			"entries":             p.cache.size(),
+			"hits":                hits,
+			"misses":              misses,
+			"hit_rate":            hitRate,
+			"search_ttl_minutes":  searchTTL.Minutes(),
+			"details_ttl_minutes": detailsTTL.Minutes(),
+		},
+		"rate_limit": map[string]interface{}{
+			"requests":       rateLimitRequests,
+			"window_seconds": rateLimitWindow.Seconds(),
+			"waiting":        p.limiter.waiting.Load(),
+			"throttled":      p.limiter.throttled.Load(),
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	c := newResponseCache()
+	c.setTTLs(0, time.Hour)
+
+	search := cacheKey(tmdbAPIBaseURL + "/search/movie?api_key=secret&query=Alien")
+	details := cacheKey(tmdbAPIBaseURL + "/movie/348?api_key=secret")
+	if details != cacheKey(tmdbAPIBaseURL+"/movie/348?api_key=other") {
+		t.Errorf("cacheKey() depends on the API key: %s", details)
+	}
+
+	c.put(search, []byte("results"))
+	c.put(details, []byte("movie"))
+	if _, ok := c.get(search); ok {
+		t.Error("search cached with a TTL of 0")
+	}
+	if data, ok := c.get(details); !ok || string(data) != "movie" {
+		t.Errorf("get(details) = %q, %v", data, ok)
+	}
+}
+
+func TestRateLimiterQueues(t *testing.T) {
+	l := newRateLimiter(2, 50*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("third request sent after %v, want it to wait for the window", elapsed)
+	}
+	if l.throttled.Load() != 1 || l.waiting.Load() != 0 {
+		t.Errorf("throttled = %d, waiting = %d", l.throttled.Load(), l.waiting.Load())
+	}
+
+	l = newRateLimiter(1, time.Minute)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait() with a cancelled context and no free slot succeeded")
+	}
+}
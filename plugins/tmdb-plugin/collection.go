@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// collectionKeys are cleared when a new match isn't part of a collection, so
+// a movie matched again leaves its old collection
+var collectionKeys = []string{"collection"}
+
+// extractCollection reads the collection a TMDB movie belongs to, or nil when
+// it doesn't belong to one
+func extractCollection(tmdbData map[string]interface{}) map[string]interface{} {
+	belongsTo, ok := tmdbData["belongs_to_collection"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	id, ok := belongsTo["id"].(float64)
+	if !ok || id <= 0 {
+		return nil
+	}
+
+	collection := map[string]interface{}{
+		"tmdb_id": fmt.Sprintf("%.0f", id),
+	}
+	if name, ok := belongsTo["name"].(string); ok && name != "" {
+		collection["name"] = name
+	}
+	if posterPath, ok := belongsTo["poster_path"].(string); ok && posterPath != "" {
+		collection["poster_url"] = tmdbImageBaseURL + posterPath
+	}
+	if backdropPath, ok := belongsTo["backdrop_path"].(string); ok && backdropPath != "" {
+		collection["backdrop_url"] = tmdbImageBaseURL + backdropPath
+	}
+	return collection
+}
+
+// addCollectionDetails adds the overview and the movies of a movie's
+// collection to its metadata. The collection is kept without them when its
+// details can't be fetched.
+func (p *TMDBPlugin) addCollectionDetails(ctx context.Context, apiKey string, metadata map[string]interface{}) {
+	collection, ok := metadata["collection"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	collectionURL := fmt.Sprintf("%s/collection/%s?api_key=%s", tmdbAPIBaseURL, collection["tmdb_id"], apiKey)
+	details, err := p.fetchDetails(ctx, collectionURL)
+	if err != nil {
+		return
+	}
+	mergeCollectionDetails(collection, details)
+}
+
+// mergeCollectionDetails merges the details of a TMDB collection into the
+// collection of a movie's metadata. Its movies are listed under "parts" in
+// release order.
+func mergeCollectionDetails(collection, details map[string]interface{}) {
+	if overview, ok := details["overview"].(string); ok && overview != "" {
+		collection["overview"] = overview
+	}
+	if _, ok := collection["poster_url"]; !ok {
+		if posterPath, ok := details["poster_path"].(string); ok && posterPath != "" {
+			collection["poster_url"] = tmdbImageBaseURL + posterPath
+		}
+	}
+
+	rawParts, ok := details["parts"].([]interface{})
+	if !ok {
+		return
+	}
+	parts := make([]map[string]interface{}, 0, len(rawParts))
+	for _, raw := range rawParts {
+		movie, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, ok := movie["id"].(float64)
+		if !ok || id <= 0 {
+			continue
+		}
+		part := map[string]interface{}{
+			"tmdb_id": fmt.Sprintf("%.0f", id),
+		}
+		if title, ok := movie["title"].(string); ok {
+			part["title"] = title
+		}
+		if releaseDate, ok := movie["release_date"].(string); ok && releaseDate != "" {
+			part["release_date"] = releaseDate
+			var year int
+			if _, err := fmt.Sscanf(releaseDate, "%4d", &year); err == nil {
+				part["year"] = year
+			}
+		}
+		if posterPath, ok := movie["poster_path"].(string); ok && posterPath != "" {
+			part["poster_url"] = tmdbImageBaseURL + posterPath
+		}
+		parts = append(parts, part)
+	}
+
+	// Unreleased movies have no date and go last
+	sort.SliceStable(parts, func(i, j int) bool {
+		a, _ := parts[i]["release_date"].(string)
+		b, _ := parts[j]["release_date"].(string)
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+	collection["parts"] = parts
+}
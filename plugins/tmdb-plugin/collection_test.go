@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractMetadataCollection(t *testing.T) {
+	details := map[string]interface{}{
+		"title": "Alien",
+		"belongs_to_collection": map[string]interface{}{
+			"id":          float64(8091),
+			"name":        "Alien Collection",
+			"poster_path": "/alien.jpg",
+		},
+	}
+
+	metadata := extractMetadata(details, "movie", "348")
+	want := map[string]interface{}{
+		"tmdb_id":    "8091",
+		"name":       "Alien Collection",
+		"poster_url": tmdbImageBaseURL + "/alien.jpg",
+	}
+	if !reflect.DeepEqual(metadata["collection"], want) {
+		t.Errorf("collection = %v, want %v", metadata["collection"], want)
+	}
+
+	delete(details, "belongs_to_collection")
+	if collection, ok := extractMetadata(details, "movie", "348")["collection"]; ok {
+		t.Errorf("collection = %v, want none for a movie outside a collection", collection)
+	}
+	details["belongs_to_collection"] = nil
+	if collection, ok := extractMetadata(details, "movie", "348")["collection"]; ok {
+		t.Errorf("collection = %v, want none for a null collection", collection)
+	}
+}
+
+func TestMergeCollectionDetails(t *testing.T) {
+	collection := map[string]interface{}{"tmdb_id": "8091", "name": "Alien Collection"}
+	mergeCollectionDetails(collection, map[string]interface{}{
+		"overview":    "A space horror franchise.",
+		"poster_path": "/alien.jpg",
+		"parts": []interface{}{
+			map[string]interface{}{"id": float64(679), "title": "Aliens", "release_date": "1986-07-18"},
+			map[string]interface{}{"id": float64(999), "title": "Alien: Untitled", "release_date": ""},
+			map[string]interface{}{"id": float64(348), "title": "Alien", "release_date": "1979-05-25", "poster_path": "/a.jpg"},
+			"not a movie",
+		},
+	})
+
+	if collection["overview"] != "A space horror franchise." || collection["poster_url"] != tmdbImageBaseURL+"/alien.jpg" {
+		t.Errorf("collection = %v, want the overview and poster", collection)
+	}
+	parts, _ := collection["parts"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"tmdb_id": "348", "title": "Alien", "release_date": "1979-05-25", "year": 1979, "poster_url": tmdbImageBaseURL + "/a.jpg"},
+		{"tmdb_id": "679", "title": "Aliens", "release_date": "1986-07-18", "year": 1986},
+		{"tmdb_id": "999", "title": "Alien: Untitled"},
+	}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+}
@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/blakestevenson/nimbus/internal/plugins"
 	"github.com/hashicorp/go-plugin"
@@ -19,12 +24,26 @@ const (
 	configKey        = "plugins.tmdb.api_key"
 )
 
+// errNoResults is returned when TMDB has no match for a lookup
+var errNoResults = errors.New("no results found")
+
 // TMDBPlugin implements the MediaSuitePlugin interface
-type TMDBPlugin struct{}
+type TMDBPlugin struct {
+	client  *http.Client
+	cache   *responseCache
+	limiter *rateLimiter
+
+	mu     sync.Mutex
+	region string // Country whose release dates are preferred
+}
 
 // NewTMDBPlugin creates a new TMDB plugin instance
 func NewTMDBPlugin() *TMDBPlugin {
-	return &TMDBPlugin{}
+	return &TMDBPlugin{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		cache:   newResponseCache(),
+		limiter: newRateLimiter(rateLimitRequests, rateLimitWindow),
+	}
 }
 
 // Metadata returns plugin metadata
@@ -89,47 +108,51 @@ func (p *TMDBPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor,
 			Auth:   "none", // Allow internal scanner calls without auth
 			Tag:    "",
 		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tmdb/status",
+			Auth:   "session",
+			Tag:    "",
+		},
 	}, nil
 }
 
 // HandleAPI handles HTTP requests for this plugin's routes
 func (p *TMDBPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	// Get API key from config via SDK or environment variable
-	var apiKey string
-	var err error
-
-	if req.SDK != nil {
-		// Try to get from config table via SDK
-		apiKey, err = req.SDK.ConfigGetString(ctx, configKey)
-		if err != nil || apiKey == "" {
-			// Fall back to environment variable
-			apiKey = os.Getenv("TMDB_API_KEY")
-		}
-	} else {
-		// No SDK available, use environment variable
-		apiKey = os.Getenv("TMDB_API_KEY")
+	if req.Route == "/api/plugins/tmdb/status" {
+		return p.handleStatus(ctx, req)
 	}
 
+	p.loadCacheSettings(ctx, req.SDK)
+	p.loadReleaseRegion(ctx, req.SDK)
+	// ?refresh=true skips the cache, for manual refreshes
+	if refresh, _ := strconv.ParseBool(p.getQueryParam(req, "refresh")); refresh {
+		ctx = withCacheBypass(ctx)
+	}
+
+	apiKey := getAPIKey(ctx, req.SDK)
 	if apiKey == "" {
 		return p.errorResponse(http.StatusInternalServerError, "TMDB API key not configured. Please set 'plugins.tmdb.api_key' in the config table or TMDB_API_KEY environment variable.")
 	}
 
-	switch {
-	case req.Path == "/api/plugins/tmdb/search/movie":
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern; handlers read IDs from its placeholders
+	switch strings.TrimPrefix(req.Route, "/api/plugins/tmdb") {
+	case "/search/movie":
 		return p.handleSearchMovie(ctx, req, apiKey)
-	case req.Path == "/api/plugins/tmdb/search/tv":
+	case "/search/tv":
 		return p.handleSearchTV(ctx, req, apiKey)
-	case strings.Contains(req.Path, "/season/") && strings.Contains(req.Path, "/episode/"):
+	case "/tv/{id}/season/{season}/episode/{episode}":
 		return p.handleGetEpisode(ctx, req, apiKey)
-	case strings.Contains(req.Path, "/season/") && !strings.Contains(req.Path, "/episode/"):
+	case "/tv/{id}/season/{season}":
 		return p.handleGetSeason(ctx, req, apiKey)
-	case strings.HasPrefix(req.Path, "/api/plugins/tmdb/movie/"):
+	case "/movie/{id}":
 		return p.handleGetMovie(ctx, req, apiKey)
-	case strings.HasPrefix(req.Path, "/api/plugins/tmdb/tv/"):
+	case "/tv/{id}":
 		return p.handleGetTV(ctx, req, apiKey)
-	case req.Path == "/api/plugins/tmdb/enrich":
+	case "/enrich":
 		return p.handleEnrichMediaBatch(ctx, req, apiKey)
-	case strings.HasPrefix(req.Path, "/api/plugins/tmdb/enrich/"):
+	case "/enrich/{mediaId}":
 		return p.handleEnrichMedia(ctx, req, apiKey)
 	default:
 		return p.errorResponse(http.StatusNotFound, "Not found")
@@ -150,16 +173,12 @@ func (p *TMDBPlugin) handleSearchMovie(ctx context.Context, req *plugins.PluginH
 		apiURL += "&year=" + year
 	}
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search TMDB: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
 // handleSearchTV searches for TV shows on TMDB
@@ -176,122 +195,91 @@ func (p *TMDBPlugin) handleSearchTV(ctx context.Context, req *plugins.PluginHTTP
 		apiURL += "&first_air_date_year=" + year
 	}
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search TMDB: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
 // handleGetMovie gets detailed movie information
 func (p *TMDBPlugin) handleGetMovie(ctx context.Context, req *plugins.PluginHTTPRequest, apiKey string) (*plugins.PluginHTTPResponse, error) {
-	parts := strings.Split(req.Path, "/")
-	if len(parts) < 5 {
+	movieID := req.PathParams["id"]
+	if movieID == "" {
 		return p.errorResponse(http.StatusBadRequest, "Invalid movie ID")
 	}
-	movieID := parts[len(parts)-1]
 
 	apiURL := fmt.Sprintf("%s/movie/%s?api_key=%s&append_to_response=credits,images", tmdbAPIBaseURL, movieID, apiKey)
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get movie: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
 // handleGetTV gets detailed TV show information
 func (p *TMDBPlugin) handleGetTV(ctx context.Context, req *plugins.PluginHTTPRequest, apiKey string) (*plugins.PluginHTTPResponse, error) {
-	parts := strings.Split(req.Path, "/")
-	if len(parts) < 5 {
+	tvID := req.PathParams["id"]
+	if tvID == "" {
 		return p.errorResponse(http.StatusBadRequest, "Invalid TV show ID")
 	}
-	tvID := parts[len(parts)-1]
 
 	apiURL := fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=credits,images", tmdbAPIBaseURL, tvID, apiKey)
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get TV show: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
 // handleGetSeason gets detailed season information with episodes
 func (p *TMDBPlugin) handleGetSeason(ctx context.Context, req *plugins.PluginHTTPRequest, apiKey string) (*plugins.PluginHTTPResponse, error) {
-	// Parse path: /api/plugins/tmdb/tv/{id}/season/{season}
-	parts := strings.Split(req.Path, "/")
-	if len(parts) < 8 {
+	tvID, season := req.PathParams["id"], req.PathParams["season"]
+	if tvID == "" || season == "" {
 		return p.errorResponse(http.StatusBadRequest, "Invalid season path")
 	}
 
-	tvID := parts[5]
-	season := parts[7]
-
 	apiURL := fmt.Sprintf("%s/tv/%s/season/%s?api_key=%s&append_to_response=images",
 		tmdbAPIBaseURL, tvID, season, apiKey)
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get season: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
 // handleGetEpisode gets detailed episode information
 func (p *TMDBPlugin) handleGetEpisode(ctx context.Context, req *plugins.PluginHTTPRequest, apiKey string) (*plugins.PluginHTTPResponse, error) {
-	// Parse path: /api/plugins/tmdb/tv/{id}/season/{season}/episode/{episode}
-	parts := strings.Split(req.Path, "/")
-	if len(parts) < 10 {
+	tvID, season, episode := req.PathParams["id"], req.PathParams["season"], req.PathParams["episode"]
+	if tvID == "" || season == "" || episode == "" {
 		return p.errorResponse(http.StatusBadRequest, "Invalid episode path")
 	}
 
-	tvID := parts[5]
-	season := parts[7]
-	episode := parts[9]
-
 	apiURL := fmt.Sprintf("%s/tv/%s/season/%s/episode/%s?api_key=%s&append_to_response=images",
 		tmdbAPIBaseURL, tvID, season, episode, apiKey)
 
-	data, err := p.makeRequest(ctx, apiURL)
+	data, hit, err := p.fetch(ctx, apiURL)
 	if err != nil {
 		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get episode: %v", err))
 	}
 
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       data,
-	}, nil
+	return tmdbResponse(data, hit), nil
 }
 
-// handleEnrichMedia enriches a media item with TMDB metadata
+// handleEnrichMedia matches a media item to a TMDB movie or TV show by hand,
+// replacing its metadata and external IDs
 func (p *TMDBPlugin) handleEnrichMedia(ctx context.Context, req *plugins.PluginHTTPRequest, apiKey string) (*plugins.PluginHTTPResponse, error) {
-	parts := strings.Split(req.Path, "/")
-	if len(parts) < 5 {
+	mediaID, err := strconv.ParseInt(req.PathParams["mediaId"], 10, 64)
+	if err != nil {
 		return p.errorResponse(http.StatusBadRequest, "Invalid media ID")
 	}
-	mediaID := parts[len(parts)-1]
 
 	// Parse request body to get TMDB ID and type
 	var reqBody struct {
@@ -306,114 +294,19 @@ func (p *TMDBPlugin) handleEnrichMedia(ctx context.Context, req *plugins.PluginH
 	if reqBody.TMDBID == "" || reqBody.Type == "" {
 		return p.errorResponse(http.StatusBadRequest, "tmdb_id and type are required")
 	}
-
-	// Fetch metadata from TMDB including external IDs
-	var apiURL string
-	if reqBody.Type == "movie" {
-		apiURL = fmt.Sprintf("%s/movie/%s?api_key=%s&append_to_response=credits,images,external_ids", tmdbAPIBaseURL, reqBody.TMDBID, apiKey)
-	} else if reqBody.Type == "tv" {
-		apiURL = fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=credits,images,external_ids", tmdbAPIBaseURL, reqBody.TMDBID, apiKey)
-	} else {
+	if reqBody.Type != "movie" && reqBody.Type != "tv" {
 		return p.errorResponse(http.StatusBadRequest, "type must be 'movie' or 'tv'")
 	}
-
-	data, err := p.makeRequest(ctx, apiURL)
-	if err != nil {
-		return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to fetch TMDB data: %v", err))
+	if req.SDK == nil {
+		return p.errorResponse(http.StatusInternalServerError, "SDK not available")
 	}
 
-	// Parse TMDB response
-	var tmdbData map[string]interface{}
-	if err := json.Unmarshal(data, &tmdbData); err != nil {
-		return p.errorResponse(http.StatusInternalServerError, "Failed to parse TMDB response")
-	}
-
-	// Extract relevant metadata
-	metadata := map[string]interface{}{
-		"tmdb_id": reqBody.TMDBID,
-		"type":    reqBody.Type,
-	}
-
-	if overview, ok := tmdbData["overview"].(string); ok {
-		metadata["description"] = overview
-	}
-
-	if voteAverage, ok := tmdbData["vote_average"].(float64); ok {
-		metadata["rating"] = voteAverage
-	}
-
-	if voteCount, ok := tmdbData["vote_count"].(float64); ok {
-		metadata["vote_count"] = voteCount
-	}
-
-	if posterPath, ok := tmdbData["poster_path"].(string); ok && posterPath != "" {
-		metadata["poster_url"] = tmdbImageBaseURL + posterPath
-	}
-
-	if backdropPath, ok := tmdbData["backdrop_path"].(string); ok && backdropPath != "" {
-		metadata["backdrop_url"] = tmdbImageBaseURL + backdropPath
-	}
-
-	if releaseDate, ok := tmdbData["release_date"].(string); ok {
-		metadata["release_date"] = releaseDate
-	}
-
-	if firstAirDate, ok := tmdbData["first_air_date"].(string); ok {
-		metadata["first_air_date"] = firstAirDate
-	}
-
-	if genres, ok := tmdbData["genres"].([]interface{}); ok {
-		metadata["genres"] = genres
-	}
-
-	if runtime, ok := tmdbData["runtime"].(float64); ok {
-		metadata["runtime"] = int(runtime)
-	}
-
-	// Extract external IDs
-	externalIDs := make(map[string]interface{})
-	if extIDs, ok := tmdbData["external_ids"].(map[string]interface{}); ok {
-		if imdbID, ok := extIDs["imdb_id"].(string); ok && imdbID != "" {
-			externalIDs["imdb_id"] = imdbID
-		}
-		if tvdbID, ok := extIDs["tvdb_id"].(float64); ok && tvdbID > 0 {
-			externalIDs["tvdb_id"] = int(tvdbID)
-		}
-		if tvrageID, ok := extIDs["tvrage_id"].(float64); ok && tvrageID > 0 {
-			externalIDs["tvrage_id"] = int(tvrageID)
-		}
-		if facebookID, ok := extIDs["facebook_id"].(string); ok && facebookID != "" {
-			externalIDs["facebook_id"] = facebookID
-		}
-		if instagramID, ok := extIDs["instagram_id"].(string); ok && instagramID != "" {
-			externalIDs["instagram_id"] = instagramID
-		}
-		if twitterID, ok := extIDs["twitter_id"].(string); ok && twitterID != "" {
-			externalIDs["twitter_id"] = twitterID
-		}
-	}
-
-	// Build response with instructions for updating the media item
-	response := map[string]interface{}{
-		"media_id":     mediaID,
-		"metadata":     metadata,
-		"external_ids": externalIDs,
-		"message":      "Metadata fetched successfully. Update the media item's metadata and external_ids columns with this data.",
-		"sql_example": fmt.Sprintf(
-			"UPDATE media_items SET metadata = metadata || '%s'::jsonb, external_ids = '%s'::jsonb WHERE id = %s",
-			mustMarshal(metadata),
-			mustMarshal(externalIDs),
-			mediaID,
-		),
+	// A manual match always uses fresh details
+	summary, err := p.applyMatch(withCacheBypass(ctx), req.SDK, apiKey, mediaID, reqBody.TMDBID, reqBody.Type)
+	if err != nil {
+		return p.errorResponse(http.StatusBadGateway, err.Error())
 	}
-
-	body, _ := json.Marshal(response)
-
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       body,
-	}, nil
+	return jsonResponse(summary)
 }
 
 // handleEnrichMediaBatch enriches media items with TMDB metadata (for scanner)
@@ -435,253 +328,183 @@ func (p *TMDBPlugin) handleEnrichMediaBatch(ctx context.Context, req *plugins.Pl
 		return p.errorResponse(http.StatusBadRequest, "title and kind are required")
 	}
 
-	metadata := make(map[string]interface{})
-	externalIDs := make(map[string]interface{})
-
-	// Handle movies
-	if reqBody.Kind == "movie" {
-		// Search for the movie
-		searchURL := fmt.Sprintf("%s/search/movie?api_key=%s&query=%s",
-			tmdbAPIBaseURL, apiKey, url.QueryEscape(reqBody.Title))
-		if reqBody.Year > 0 {
-			searchURL += fmt.Sprintf("&year=%d", reqBody.Year)
-		}
+	metadata, externalIDs, err := p.lookupMetadata(ctx, apiKey, enrichQuery{
+		Title:   reqBody.Title,
+		Year:    reqBody.Year,
+		Kind:    reqBody.Kind,
+		Season:  reqBody.Season,
+		Episode: reqBody.Episode,
+	})
+	if errors.Is(err, errNoResults) {
+		return p.errorResponse(http.StatusNotFound, "No results found")
+	}
+	var lowConfidence *lowConfidenceError
+	if errors.As(err, &lowConfidence) {
+		// Let the caller pick from the closest results instead of guessing
+		return jsonResponse(map[string]interface{}{
+			"success":        false,
+			"low_confidence": true,
+			"score":          lowConfidence.Match.Score,
+			"candidates":     lowConfidence.Match.Candidates,
+		})
+	}
+	if err != nil {
+		return p.errorResponse(http.StatusInternalServerError, err.Error())
+	}
 
-		searchData, err := p.makeRequest(ctx, searchURL)
-		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search movie: %v", err))
-		}
+	responseData := map[string]interface{}{
+		"metadata": metadata,
+		"success":  true,
+	}
 
-		var searchResult map[string]interface{}
-		if err := json.Unmarshal(searchData, &searchResult); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse search results")
-		}
+	// Add external_ids if we have any
+	if len(externalIDs) > 0 {
+		responseData["external_ids"] = externalIDs
+	}
 
-		results, ok := searchResult["results"].([]interface{})
-		if !ok || len(results) == 0 {
-			return p.errorResponse(http.StatusNotFound, "No results found")
-		}
+	body, _ := json.Marshal(responseData)
 
-		// Get first result
-		firstResult := results[0].(map[string]interface{})
-		tmdbID := fmt.Sprintf("%.0f", firstResult["id"].(float64))
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
 
-		// Fetch full movie details
-		movieURL := fmt.Sprintf("%s/movie/%s?api_key=%s&append_to_response=credits,images,external_ids",
-			tmdbAPIBaseURL, tmdbID, apiKey)
-		movieData, err := p.makeRequest(ctx, movieURL)
-		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to fetch movie details")
-		}
+// enrichQuery identifies what to look up on TMDB
+type enrichQuery struct {
+	Title   string // Movie or series title
+	Year    int
+	Kind    string // "movie", "tv_series", "tv_season" or "tv_episode"
+	Season  int
+	Episode int
+}
 
-		var movieDetails map[string]interface{}
-		if err := json.Unmarshal(movieData, &movieDetails); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse movie details")
-		}
+// lookupMetadata searches TMDB for a movie or TV item and returns its metadata
+// and external IDs. The metadata records how the search result was picked
+// under "tmdb_match". It returns errNoResults when TMDB has no match, and a
+// *lowConfidenceError when no result matches well enough to pick one.
+func (p *TMDBPlugin) lookupMetadata(ctx context.Context, apiKey string, query enrichQuery) (map[string]interface{}, map[string]interface{}, error) {
+	metadata := make(map[string]interface{})
+	externalIDs := make(map[string]interface{})
 
-		metadata = extractMetadata(movieDetails, "movie", tmdbID)
+	switch query.Kind {
+	case "movie", "tv_series", "tv_season", "tv_episode":
+	default:
+		return metadata, externalIDs, nil
 	}
 
-	// Handle TV series
-	if reqBody.Kind == "tv_series" {
-		// Search for the TV show
-		searchURL := fmt.Sprintf("%s/search/tv?api_key=%s&query=%s",
-			tmdbAPIBaseURL, apiKey, url.QueryEscape(reqBody.Title))
+	searchType := "tv"
+	if query.Kind == "movie" {
+		searchType = "movie"
+	}
+	match, err := p.findMatch(ctx, apiKey, searchType, query.Title, query.Year)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmdbID := match.best().TMDBID
 
-		searchData, err := p.makeRequest(ctx, searchURL)
+	switch query.Kind {
+	case "movie":
+		movieURL := fmt.Sprintf("%s/movie/%s?api_key=%s&append_to_response=credits,images,external_ids,release_dates",
+			tmdbAPIBaseURL, tmdbID, apiKey)
+		movieDetails, err := p.fetchDetails(ctx, movieURL)
 		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search TV show: %v", err))
-		}
-
-		var searchResult map[string]interface{}
-		if err := json.Unmarshal(searchData, &searchResult); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse search results")
-		}
-
-		results, ok := searchResult["results"].([]interface{})
-		if !ok || len(results) == 0 {
-			return p.errorResponse(http.StatusNotFound, "No results found")
+			return nil, nil, fmt.Errorf("failed to fetch movie details: %w", err)
 		}
+		metadata = extractMetadata(movieDetails, "movie", tmdbID)
+		p.addReleaseDates(metadata, movieDetails)
+		p.addCollectionDetails(ctx, apiKey, metadata)
 
-		// Get first result
-		firstResult := results[0].(map[string]interface{})
-		tmdbID := fmt.Sprintf("%.0f", firstResult["id"].(float64))
-
-		// Fetch full TV series details
+	case "tv_series":
 		seriesURL := fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=credits,images,external_ids",
 			tmdbAPIBaseURL, tmdbID, apiKey)
-		seriesData, err := p.makeRequest(ctx, seriesURL)
+		seriesDetails, err := p.fetchDetails(ctx, seriesURL)
 		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to fetch series details")
-		}
-
-		var seriesDetails map[string]interface{}
-		if err := json.Unmarshal(seriesData, &seriesDetails); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse series details")
+			return nil, nil, fmt.Errorf("failed to fetch series details: %w", err)
 		}
-
 		metadata = extractMetadata(seriesDetails, "tv_series", tmdbID)
-	}
 
-	// Handle TV seasons
-	if reqBody.Kind == "tv_season" {
-		// For seasons, we need the series title to search
-		searchURL := fmt.Sprintf("%s/search/tv?api_key=%s&query=%s",
-			tmdbAPIBaseURL, apiKey, url.QueryEscape(reqBody.Title))
-
-		searchData, err := p.makeRequest(ctx, searchURL)
+	case "tv_season":
+		seasonURL := fmt.Sprintf("%s/tv/%s/season/%d?api_key=%s&append_to_response=images",
+			tmdbAPIBaseURL, tmdbID, query.Season, apiKey)
+		seasonDetails, err := p.fetchDetails(ctx, seasonURL)
 		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search TV show: %v", err))
+			return nil, nil, fmt.Errorf("failed to fetch season details: %w", err)
 		}
+		metadata = extractMetadata(seasonDetails, "tv_season", tmdbID)
 
-		var searchResult map[string]interface{}
-		if err := json.Unmarshal(searchData, &searchResult); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse search results")
-		}
+		// Seasons don't have their own external IDs
+		externalIDs = p.seriesExternalIDs(ctx, apiKey, tmdbID)
 
-		results, ok := searchResult["results"].([]interface{})
-		if !ok || len(results) == 0 {
-			return p.errorResponse(http.StatusNotFound, "No results found")
+	case "tv_episode":
+		episodeURL := fmt.Sprintf("%s/tv/%s/season/%d/episode/%d?api_key=%s&append_to_response=images",
+			tmdbAPIBaseURL, tmdbID, query.Season, query.Episode, apiKey)
+		episodeDetails, err := p.fetchDetails(ctx, episodeURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch episode details: %w", err)
 		}
+		metadata = extractMetadata(episodeDetails, "tv_episode", tmdbID)
 
-		// Get first result
-		firstResult := results[0].(map[string]interface{})
-		tmdbID := fmt.Sprintf("%.0f", firstResult["id"].(float64))
+		// Episodes don't have their own external IDs
+		externalIDs = p.seriesExternalIDs(ctx, apiKey, tmdbID)
+	}
 
-		// Fetch season details
-		seasonURL := fmt.Sprintf("%s/tv/%s/season/%d?api_key=%s&append_to_response=images",
-			tmdbAPIBaseURL, tmdbID, reqBody.Season, apiKey)
-		seasonData, err := p.makeRequest(ctx, seasonURL)
-		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to fetch season details")
-		}
+	metadata["tmdb_match"] = match
+	return metadata, externalIDs, nil
+}
 
-		var seasonDetails map[string]interface{}
-		if err := json.Unmarshal(seasonData, &seasonDetails); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse season details")
-		}
+// fetchDetails fetches and decodes a TMDB details response
+func (p *TMDBPlugin) fetchDetails(ctx context.Context, apiURL string) (map[string]interface{}, error) {
+	data, err := p.makeRequest(ctx, apiURL)
+	if err != nil {
+		return nil, err
+	}
 
-		metadata = extractMetadata(seasonDetails, "tv_season", tmdbID)
+	var details map[string]interface{}
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, errors.New("failed to parse response")
+	}
+	return details, nil
+}
 
-		// Fetch series-level external IDs (seasons don't have their own external IDs)
-		seriesURL := fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=external_ids",
-			tmdbAPIBaseURL, tmdbID, apiKey)
-		seriesData, err := p.makeRequest(ctx, seriesURL)
-		if err == nil {
-			var seriesDetails map[string]interface{}
-			if err := json.Unmarshal(seriesData, &seriesDetails); err == nil {
-				if extIDs, ok := seriesDetails["external_ids"].(map[string]interface{}); ok {
-					if imdbID, ok := extIDs["imdb_id"].(string); ok && imdbID != "" {
-						externalIDs["imdb_id"] = imdbID
-					}
-					if tvdbID, ok := extIDs["tvdb_id"].(float64); ok && tvdbID > 0 {
-						externalIDs["tvdb_id"] = int(tvdbID)
-					}
-					if tvrageID, ok := extIDs["tvrage_id"].(float64); ok && tvrageID > 0 {
-						externalIDs["tvrage_id"] = int(tvrageID)
-					}
-					if facebookID, ok := extIDs["facebook_id"].(string); ok && facebookID != "" {
-						externalIDs["facebook_id"] = facebookID
-					}
-					if instagramID, ok := extIDs["instagram_id"].(string); ok && instagramID != "" {
-						externalIDs["instagram_id"] = instagramID
-					}
-					if twitterID, ok := extIDs["twitter_id"].(string); ok && twitterID != "" {
-						externalIDs["twitter_id"] = twitterID
-					}
-				}
-			}
-		}
+// seriesExternalIDs returns the external IDs of a TV series, or none if they
+// can't be fetched
+func (p *TMDBPlugin) seriesExternalIDs(ctx context.Context, apiKey, tmdbID string) map[string]interface{} {
+	seriesURL := fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=external_ids",
+		tmdbAPIBaseURL, tmdbID, apiKey)
+	seriesDetails, err := p.fetchDetails(ctx, seriesURL)
+	if err != nil {
+		return make(map[string]interface{})
 	}
 
-	// Handle TV episodes
-	if reqBody.Kind == "tv_episode" {
-		// Search for the TV show
-		searchURL := fmt.Sprintf("%s/search/tv?api_key=%s&query=%s",
-			tmdbAPIBaseURL, apiKey, url.QueryEscape(reqBody.Title))
+	return parseExternalIDs(seriesDetails)
+}
 
-		searchData, err := p.makeRequest(ctx, searchURL)
-		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to search TV show: %v", err))
+// parseExternalIDs reads the external IDs appended to a TMDB details response
+func parseExternalIDs(details map[string]interface{}) map[string]interface{} {
+	externalIDs := make(map[string]interface{})
+	if extIDs, ok := details["external_ids"].(map[string]interface{}); ok {
+		if imdbID, ok := extIDs["imdb_id"].(string); ok && imdbID != "" {
+			externalIDs["imdb_id"] = imdbID
 		}
-
-		var searchResult map[string]interface{}
-		if err := json.Unmarshal(searchData, &searchResult); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse search results")
+		if tvdbID, ok := extIDs["tvdb_id"].(float64); ok && tvdbID > 0 {
+			externalIDs["tvdb_id"] = int(tvdbID)
 		}
-
-		results, ok := searchResult["results"].([]interface{})
-		if !ok || len(results) == 0 {
-			return p.errorResponse(http.StatusNotFound, "No results found")
+		if tvrageID, ok := extIDs["tvrage_id"].(float64); ok && tvrageID > 0 {
+			externalIDs["tvrage_id"] = int(tvrageID)
 		}
-
-		// Get first result
-		firstResult := results[0].(map[string]interface{})
-		tmdbID := fmt.Sprintf("%.0f", firstResult["id"].(float64))
-
-		// Fetch episode details
-		episodeURL := fmt.Sprintf("%s/tv/%s/season/%d/episode/%d?api_key=%s&append_to_response=images",
-			tmdbAPIBaseURL, tmdbID, reqBody.Season, reqBody.Episode, apiKey)
-		episodeData, err := p.makeRequest(ctx, episodeURL)
-		if err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to fetch episode details")
+		if facebookID, ok := extIDs["facebook_id"].(string); ok && facebookID != "" {
+			externalIDs["facebook_id"] = facebookID
 		}
-
-		var episodeDetails map[string]interface{}
-		if err := json.Unmarshal(episodeData, &episodeDetails); err != nil {
-			return p.errorResponse(http.StatusInternalServerError, "Failed to parse episode details")
+		if instagramID, ok := extIDs["instagram_id"].(string); ok && instagramID != "" {
+			externalIDs["instagram_id"] = instagramID
 		}
-
-		metadata = extractMetadata(episodeDetails, "tv_episode", tmdbID)
-
-		// Fetch series-level external IDs (episodes don't have their own external IDs)
-		seriesURL := fmt.Sprintf("%s/tv/%s?api_key=%s&append_to_response=external_ids",
-			tmdbAPIBaseURL, tmdbID, apiKey)
-		seriesData, err := p.makeRequest(ctx, seriesURL)
-		if err == nil {
-			var seriesDetails map[string]interface{}
-			if err := json.Unmarshal(seriesData, &seriesDetails); err == nil {
-				if extIDs, ok := seriesDetails["external_ids"].(map[string]interface{}); ok {
-					if imdbID, ok := extIDs["imdb_id"].(string); ok && imdbID != "" {
-						externalIDs["imdb_id"] = imdbID
-					}
-					if tvdbID, ok := extIDs["tvdb_id"].(float64); ok && tvdbID > 0 {
-						externalIDs["tvdb_id"] = int(tvdbID)
-					}
-					if tvrageID, ok := extIDs["tvrage_id"].(float64); ok && tvrageID > 0 {
-						externalIDs["tvrage_id"] = int(tvrageID)
-					}
-					if facebookID, ok := extIDs["facebook_id"].(string); ok && facebookID != "" {
-						externalIDs["facebook_id"] = facebookID
-					}
-					if instagramID, ok := extIDs["instagram_id"].(string); ok && instagramID != "" {
-						externalIDs["instagram_id"] = instagramID
-					}
-					if twitterID, ok := extIDs["twitter_id"].(string); ok && twitterID != "" {
-						externalIDs["twitter_id"] = twitterID
-					}
-				}
-			}
+		if twitterID, ok := extIDs["twitter_id"].(string); ok && twitterID != "" {
+			externalIDs["twitter_id"] = twitterID
 		}
 	}
-
-	responseData := map[string]interface{}{
-		"metadata": metadata,
-		"success":  true,
-	}
-
-	// Add external_ids if we have any
-	if len(externalIDs) > 0 {
-		responseData["external_ids"] = externalIDs
-	}
-
-	body, _ := json.Marshal(responseData)
-
-	return &plugins.PluginHTTPResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string][]string{"Content-Type": {"application/json"}},
-		Body:       body,
-	}, nil
+	return externalIDs
 }
 
 // extractMetadata extracts relevant metadata from TMDB response
@@ -731,6 +554,12 @@ func extractMetadata(tmdbData map[string]interface{}, mediaType string, tmdbID s
 		metadata["genres"] = genres
 	}
 
+	if mediaType == "movie" {
+		if collection := extractCollection(tmdbData); collection != nil {
+			metadata["collection"] = collection
+		}
+	}
+
 	if runtime, ok := tmdbData["runtime"].(float64); ok {
 		metadata["runtime"] = int(runtime)
 	}
@@ -797,14 +626,125 @@ func (p *TMDBPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error
 						ErrorMessage: "Invalid API key format. Must be 32 hexadecimal characters.",
 					},
 				},
+				{
+					Key:          configReleaseRegion,
+					Label:        "Release Region",
+					Description:  "Country code (e.g. US) whose cinema, digital and physical release dates are used for movies. Empty uses the earliest of any country.",
+					Type:         "text",
+					DefaultValue: "",
+					Placeholder:  "US",
+				},
+				{
+					Key:          configSearchTTL,
+					Label:        "Search Cache (minutes)",
+					Description:  "How long TMDB search results are cached. 0 turns the cache off for searches.",
+					Type:         "number",
+					DefaultValue: "60",
+				},
+				{
+					Key:          configDetailsTTL,
+					Label:        "Details Cache (minutes)",
+					Description:  "How long movie, series, season and episode details are cached. 0 turns the cache off for details.",
+					Type:         "number",
+					DefaultValue: "1440",
+				},
 			},
 		},
 	}, nil
 }
 
-// HandleEvent handles system events
+// HandleEvent handles system events. New media items are enriched with TMDB
+// metadata as soon as they are created.
 func (p *TMDBPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
-	return nil
+	switch evt.Type {
+	case plugins.EventMediaItemCreated:
+		return p.enrichCreatedItem(ctx, evt)
+	default:
+		return nil
+	}
+}
+
+// enrichCreatedItem looks up a newly created media item on TMDB and stores the
+// metadata and external IDs found on it
+func (p *TMDBPlugin) enrichCreatedItem(ctx context.Context, evt plugins.Event) error {
+	kind, _ := evt.Data["kind"].(string)
+	switch kind {
+	case "movie", "tv_series", "tv_season", "tv_episode":
+	default:
+		return nil
+	}
+
+	// The host already looked the item up with the preferred provider
+	if _, ok := evt.Data["metadata_lookup"]; ok {
+		return nil
+	}
+
+	// Items that already have a TMDB ID were matched elsewhere
+	if externalIDs, ok := evt.Data["external_ids"].(map[string]interface{}); ok {
+		if _, ok := externalIDs["tmdb"]; ok {
+			return nil
+		}
+	}
+
+	mediaID, ok := evt.Data["media_item_id"].(float64)
+	if !ok {
+		return fmt.Errorf("event has no media_item_id")
+	}
+
+	query := enrichQuery{Kind: kind}
+	query.Title, _ = evt.Data["search_title"].(string)
+	if query.Title == "" {
+		query.Title, _ = evt.Data["title"].(string)
+	}
+	if year, ok := evt.Data["year"].(float64); ok {
+		query.Year = int(year)
+	}
+	if season, ok := evt.Data["season"].(float64); ok {
+		query.Season = int(season)
+	}
+	if episode, ok := evt.Data["episode"].(float64); ok {
+		query.Episode = int(episode)
+	}
+	if query.Title == "" {
+		return nil
+	}
+	// Seasons and episodes can only be looked up with their numbers
+	if (kind == "tv_season" && query.Season == 0) || (kind == "tv_episode" && (query.Season == 0 || query.Episode == 0)) {
+		return nil
+	}
+
+	if evt.SDK == nil {
+		return fmt.Errorf("SDK not available")
+	}
+	p.loadCacheSettings(ctx, evt.SDK)
+	p.loadReleaseRegion(ctx, evt.SDK)
+
+	apiKey := getAPIKey(ctx, evt.SDK)
+	if apiKey == "" {
+		return fmt.Errorf("TMDB API key not configured")
+	}
+
+	metadata, externalIDs, err := p.lookupMetadata(ctx, apiKey, query)
+	if errors.Is(err, errNoResults) {
+		return nil
+	}
+	var lowConfidence *lowConfidenceError
+	if errors.As(err, &lowConfidence) {
+		// Keep the candidates so the item can be matched by hand
+		_, err = evt.SDK.MediaUpdateMetadata(ctx, int64(mediaID), map[string]interface{}{
+			"tmdb_match": lowConfidence.Match,
+		}, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if tmdbID, ok := metadata["tmdb_id"].(string); ok && tmdbID != "" {
+		externalIDs["tmdb"] = tmdbID
+	}
+	_, err = evt.SDK.MediaUpdateMetadata(ctx, int64(mediaID), metadata, externalIDs)
+	return err
 }
 
 // IsIndexer returns false as TMDB is not an indexer plugin
@@ -824,39 +764,118 @@ func (p *TMDBPlugin) IsDownloader(ctx context.Context) (bool, error) {
 
 // Helper functions
 
-// getAPIKey fetches the TMDB API key from the Nimbus config table
+// getAPIKey fetches the TMDB API key from the Nimbus config table, falling back
+// to the TMDB_API_KEY environment variable
+func getAPIKey(ctx context.Context, sdk plugins.SDKInterface) string {
+	if sdk != nil {
+		if apiKey, err := sdk.ConfigGetString(ctx, configKey); err == nil && apiKey != "" {
+			return apiKey
+		}
+	}
+	return os.Getenv("TMDB_API_KEY")
+}
 
+// makeRequest fetches a TMDB URL, from the cache when possible
 func (p *TMDBPlugin) makeRequest(ctx context.Context, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+	data, _, err := p.fetch(ctx, url)
+	return data, err
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// fetch returns the response for a TMDB URL and whether it came from the
+// cache. Requests to TMDB wait for the rate limiter, and are retried when TMDB
+// asks to slow down anyway.
+func (p *TMDBPlugin) fetch(ctx context.Context, url string) ([]byte, bool, error) {
+	key := cacheKey(url)
+	if !bypassesCache(ctx) {
+		if data, ok := p.cache.get(key); ok {
+			p.cache.hits.Add(1)
+			return data, true, nil
+		}
 	}
-	defer resp.Body.Close()
+	p.cache.misses.Add(1)
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("TMDB API returned status %d", resp.StatusCode)
-	}
+	for attempt := 0; ; attempt++ {
+		if err := p.limiter.wait(ctx); err != nil {
+			return nil, false, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, false, err
+		}
 
-	// Read the response body
-	buf := make([]byte, 0, 4096)
-	tmp := make([]byte, 1024)
-	for {
-		n, err := resp.Body.Read(tmp)
-		if n > 0 {
-			buf = append(buf, tmp[:n]...)
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return nil, false, err
 		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			break
+			return nil, false, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 3 {
+			if err := sleepContext(ctx, retryAfter(resp.Header.Get("Retry-After"))); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, false, fmt.Errorf("TMDB API returned status %d", resp.StatusCode)
 		}
+
+		p.cache.put(key, data)
+		return data, false, nil
+	}
+}
+
+// retryAfter reads a Retry-After header in seconds, defaulting to one second
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Second
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tmdbResponse returns a TMDB response as is, saying whether it was cached
+func tmdbResponse(data []byte, cached bool) *plugins.PluginHTTPResponse {
+	cache := "MISS"
+	if cached {
+		cache = "HIT"
 	}
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string][]string{
+			"Content-Type": {"application/json"},
+			"X-Cache":      {cache},
+		},
+		Body: data,
+	}
+}
 
-	return buf, nil
+// jsonResponse encodes data as a 200 JSON response
+func jsonResponse(data interface{}) (*plugins.PluginHTTPResponse, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
 }
 
 func (p *TMDBPlugin) getQueryParam(req *plugins.PluginHTTPRequest, key string) string {
@@ -875,11 +894,6 @@ func (p *TMDBPlugin) errorResponse(statusCode int, message string) (*plugins.Plu
 	}, nil
 }
 
-func mustMarshal(v interface{}) string {
-	data, _ := json.Marshal(v)
-	return string(data)
-}
-
 func main() {
 	tmdbPlugin := NewTMDBPlugin()
 
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minConfidence is the lowest score a search result is picked with. An
+	// exact title without a year to check scores at least this much, an exact
+	// title from another year doesn't.
+	minConfidence = 0.75
+
+	// maxCandidates is how many search results are kept for a manual match
+	maxCandidates = 5
+
+	// Weights of the parts of a match score, which add up to 1
+	titleWeight      = 0.6
+	yearWeight       = 0.3
+	popularityWeight = 0.1
+)
+
+// searchResult is a movie or TV show in TMDB search results
+type searchResult struct {
+	ID            int64   `json:"id"`
+	Title         string  `json:"title"` // Movies
+	OriginalTitle string  `json:"original_title"`
+	ReleaseDate   string  `json:"release_date"`
+	Name          string  `json:"name"` // TV shows
+	OriginalName  string  `json:"original_name"`
+	FirstAirDate  string  `json:"first_air_date"`
+	Popularity    float64 `json:"popularity"`
+	VoteCount     float64 `json:"vote_count"`
+	PosterPath    string  `json:"poster_path"`
+}
+
+func (r searchResult) titles() []string {
+	return []string{r.Title, r.OriginalTitle, r.Name, r.OriginalName}
+}
+
+func (r searchResult) year() int {
+	date := r.ReleaseDate
+	if date == "" {
+		date = r.FirstAirDate
+	}
+	if len(date) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(date[:4])
+	return year
+}
+
+// candidate is a search result as offered for a manual match
+type candidate struct {
+	TMDBID     string  `json:"tmdb_id"`
+	Title      string  `json:"title"`
+	Year       int     `json:"year,omitempty"`
+	PosterURL  string  `json:"poster_url,omitempty"`
+	Popularity float64 `json:"popularity"`
+	VoteCount  float64 `json:"vote_count"`
+	Score      float64 `json:"score"`
+}
+
+// matchResult is the best search result for a lookup and the runners-up
+type matchResult struct {
+	Score         float64     `json:"score"`
+	LowConfidence bool        `json:"low_confidence"`
+	Candidates    []candidate `json:"candidates"` // Best first
+}
+
+// best returns the picked search result, or nil when none is good enough
+func (m *matchResult) best() *candidate {
+	if m.LowConfidence || len(m.Candidates) == 0 {
+		return nil
+	}
+	return &m.Candidates[0]
+}
+
+// lowConfidenceError is returned when no search result matches well enough
+// to pick one without asking
+type lowConfidenceError struct {
+	Match *matchResult
+}
+
+func (e *lowConfidenceError) Error() string {
+	return fmt.Sprintf("no confident match, best score %.2f", e.Match.Score)
+}
+
+// matchResults scores search results against the title and year being looked
+// up. A year of 0 means it isn't known.
+func matchResults(results []searchResult, title string, year int) *matchResult {
+	var maxVotes float64
+	for _, r := range results {
+		maxVotes = math.Max(maxVotes, r.VoteCount)
+	}
+
+	want := normalizeTitle(title)
+	candidates := make([]candidate, 0, len(results))
+	for _, r := range results {
+		var titleScore float64
+		for _, t := range r.titles() {
+			if t != "" {
+				titleScore = math.Max(titleScore, titleSimilarity(want, normalizeTitle(t)))
+			}
+		}
+
+		popularity := 0.0
+		if maxVotes > 0 {
+			popularity = math.Log1p(r.VoteCount) / math.Log1p(maxVotes)
+		}
+
+		score := titleWeight*titleScore + yearWeight*yearScore(year, r.year()) + popularityWeight*popularity
+
+		c := candidate{
+			TMDBID:     strconv.FormatInt(r.ID, 10),
+			Title:      r.Title,
+			Year:       r.year(),
+			Popularity: r.Popularity,
+			VoteCount:  r.VoteCount,
+			Score:      math.Round(score*1000) / 1000,
+		}
+		if c.Title == "" {
+			c.Title = r.Name
+		}
+		if r.PosterPath != "" {
+			c.PosterURL = tmdbImageBaseURL + r.PosterPath
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+
+	match := &matchResult{Candidates: candidates, LowConfidence: true}
+	if len(candidates) > 0 {
+		match.Score = candidates[0].Score
+		match.LowConfidence = match.Score < minConfidence
+	}
+	return match
+}
+
+// yearScore compares the year being looked up with a result's year. Release
+// dates often differ by a year between countries and file names.
+func yearScore(want, got int) float64 {
+	switch {
+	case want == 0:
+		return 0.5
+	case got == 0:
+		return 0.3
+	case want == got:
+		return 1
+	case want-got == 1 || got-want == 1:
+		return 0.7
+	default:
+		return 0
+	}
+}
+
+// titleSimilarity scores two normalized titles from 0 to 1. Titles that differ
+// score by the words they share, at most 0.8.
+func titleSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	inA := make(map[string]bool)
+	for _, w := range strings.Fields(a) {
+		inA[w] = true
+	}
+	inB := make(map[string]bool)
+	for _, w := range strings.Fields(b) {
+		inB[w] = true
+	}
+
+	shared := 0
+	for w := range inB {
+		if inA[w] {
+			shared++
+		}
+	}
+	union := len(inA) + len(inB) - shared
+	if union == 0 {
+		return 0
+	}
+	return 0.8 * float64(shared) / float64(union)
+}
+
+// normalizeTitle lower-cases a title and drops punctuation and a leading
+// article, so "The Office" matches "office" and "Marvel's Agents of S.H.I.E.L.D."
+// matches "Marvels Agents of SHIELD"
+func normalizeTitle(title string) string {
+	title = strings.ReplaceAll(strings.ToLower(title), "&", " and ")
+
+	var b strings.Builder
+	for _, r := range title {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == ':' || r == '/':
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	if len(words) > 1 {
+		switch words[0] {
+		case "the", "a", "an":
+			words = words[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// findMatch searches TMDB for a movie ("movie") or TV show ("tv") and scores
+// the results. It returns errNoResults without results, and a
+// *lowConfidenceError with the candidates when none is good enough.
+func (p *TMDBPlugin) findMatch(ctx context.Context, apiKey, searchType, title string, year int) (*matchResult, error) {
+	searchURL := fmt.Sprintf("%s/search/%s?api_key=%s&query=%s",
+		tmdbAPIBaseURL, searchType, apiKey, url.QueryEscape(title))
+
+	searchData, err := p.makeRequest(ctx, searchURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", searchType, err)
+	}
+
+	var search struct {
+		Results []searchResult `json:"results"`
+	}
+	if err := json.Unmarshal(searchData, &search); err != nil {
+		return nil, fmt.Errorf("failed to parse search results")
+	}
+	if len(search.Results) == 0 {
+		return nil, errNoResults
+	}
+
+	match := matchResults(search.Results, title, year)
+	if match.best() == nil {
+		return match, &lowConfidenceError{Match: match}
+	}
+	return match, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestMatchResults(t *testing.T) {
+	office := []searchResult{
+		{ID: 2996, Name: "The Office", FirstAirDate: "2001-07-09", VoteCount: 1100},
+		{ID: 2316, Name: "The Office", FirstAirDate: "2005-03-24", VoteCount: 4200},
+		{ID: 81102, Name: "The Office Mix-Up", FirstAirDate: "2020-01-01", VoteCount: 10},
+	}
+	dune := []searchResult{
+		{ID: 841, Title: "Dune", ReleaseDate: "1984-12-14", VoteCount: 2600},
+		{ID: 438631, Title: "Dune", ReleaseDate: "2021-09-15", VoteCount: 12000},
+		{ID: 693134, Title: "Dune: Part Two", ReleaseDate: "2024-02-27", VoteCount: 6000},
+	}
+
+	tests := []struct {
+		name    string
+		results []searchResult
+		title   string
+		year    int
+		want    string // TMDB ID picked, empty for low confidence
+	}{
+		{"ambiguous title without year picks the more popular", office, "The Office", 0, "2316"},
+		{"ambiguous title with year picks that year", office, "The Office", 2001, "2996"},
+		{"punctuation and articles are ignored", office, "office", 2005, "2316"},
+		{"year off by one", dune, "Dune", 2020, "438631"},
+		{"exact year beats popularity", dune, "Dune", 1984, "841"},
+		{"missing year", dune, "Dune", 0, "438631"},
+		{"no result from a close year", dune, "Dune", 1999, ""},
+		{"title doesn't match", dune, "Arrival", 2016, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := matchResults(tt.results, tt.title, tt.year)
+			got := ""
+			if best := match.best(); best != nil {
+				got = best.TMDBID
+			}
+			if got != tt.want {
+				t.Errorf("matchResults(%q, %d) picked %q with score %.3f, want %q (candidates %+v)",
+					tt.title, tt.year, got, match.Score, tt.want, match.Candidates)
+			}
+			if tt.want == "" && (!match.LowConfidence || len(match.Candidates) == 0) {
+				t.Errorf("low confidence match = %+v, want candidates", match)
+			}
+		})
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]string{
+		"The Office":                        "office",
+		"Marvel's Agents of S.H.I.E.L.D.":   "marvels agents of shield",
+		"Law & Order: Special Victims Unit": "law and order special victims unit",
+		"The":                               "the",
+	}
+	for title, want := range tests {
+		if got := normalizeTitle(title); got != want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+const configReleaseRegion = "plugins.tmdb.release_region"
+
+// TMDB release types, as listed by /movie/{id}/release_dates
+const (
+	releaseTheatricalLimited = 2
+	releaseTheatrical        = 3
+	releaseDigital           = 4
+	releasePhysical          = 5
+)
+
+// releaseKinds maps TMDB release types onto the keys of a movie's
+// "release_dates" metadata. Premieres and TV airings don't make a movie
+// available.
+var releaseKinds = map[int]string{
+	releaseTheatricalLimited: "cinema",
+	releaseTheatrical:        "cinema",
+	releaseDigital:           "digital",
+	releasePhysical:          "physical",
+}
+
+// releaseDates reads the earliest cinema, digital and physical release dates
+// appended to a movie's details, as YYYY-MM-DD. With a region, its own dates
+// are used where it has them, and the earliest of any region otherwise. It
+// returns nil when TMDB knows no release dates.
+func releaseDates(details map[string]interface{}, region string) map[string]interface{} {
+	appended, _ := details["release_dates"].(map[string]interface{})
+	results, _ := appended["results"].([]interface{})
+
+	earliest := make(map[string]string)
+	regional := make(map[string]string)
+	for _, r := range results {
+		result, _ := r.(map[string]interface{})
+		country, _ := result["iso_3166_1"].(string)
+		dates, _ := result["release_dates"].([]interface{})
+		for _, d := range dates {
+			release, _ := d.(map[string]interface{})
+			releaseType, _ := release["type"].(float64)
+			kind, ok := releaseKinds[int(releaseType)]
+			if !ok {
+				continue
+			}
+			raw, _ := release["release_date"].(string)
+			date, ok := releaseDay(raw)
+			if !ok {
+				continue
+			}
+
+			if current, ok := earliest[kind]; !ok || date < current {
+				earliest[kind] = date
+			}
+			if region != "" && strings.EqualFold(country, region) {
+				if current, ok := regional[kind]; !ok || date < current {
+					regional[kind] = date
+				}
+			}
+		}
+	}
+	if len(earliest) == 0 {
+		return nil
+	}
+
+	dates := make(map[string]interface{}, len(earliest))
+	for kind, date := range earliest {
+		if own, ok := regional[kind]; ok {
+			date = own
+		}
+		dates[kind] = date
+	}
+	return dates
+}
+
+// releaseDay returns the day of a TMDB release time like
+// "2026-05-01T00:00:00.000Z"
+func releaseDay(raw string) (string, bool) {
+	if len(raw) < len("2006-01-02") {
+		return "", false
+	}
+	day := raw[:len("2006-01-02")]
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return "", false
+	}
+	return day, true
+}
+
+// addReleaseDates adds the release dates of a movie to its metadata
+func (p *TMDBPlugin) addReleaseDates(metadata, details map[string]interface{}) {
+	if dates := releaseDates(details, p.releaseRegion()); dates != nil {
+		metadata["release_dates"] = dates
+	}
+}
+
+// loadReleaseRegion reads the country whose release dates are preferred
+func (p *TMDBPlugin) loadReleaseRegion(ctx context.Context, sdk plugins.SDKInterface) {
+	region := ""
+	if sdk != nil {
+		if value, err := sdk.ConfigGetString(ctx, configReleaseRegion); err == nil {
+			region = strings.ToUpper(strings.TrimSpace(value))
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.region = region
+}
+
+// releaseRegion returns the country whose release dates are preferred, or ""
+// for the earliest of any country
+func (p *TMDBPlugin) releaseRegion() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.region
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestReleaseDates(t *testing.T) {
+	var details map[string]interface{}
+	err := json.Unmarshal([]byte(`{"release_dates": {"results": [
+		{"iso_3166_1": "US", "release_dates": [
+			{"type": 1, "release_date": "2026-03-01T00:00:00.000Z"},
+			{"type": 3, "release_date": "2026-05-01T00:00:00.000Z"},
+			{"type": 4, "release_date": "2026-06-15T00:00:00.000Z"}
+		]},
+		{"iso_3166_1": "GB", "release_dates": [
+			{"type": 3, "release_date": "2026-04-20T00:00:00.000Z"},
+			{"type": 5, "release_date": "2026-08-01T00:00:00.000Z"},
+			{"type": 4, "release_date": "not a date"}
+		]}
+	]}}`), &details)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		region string
+		want   map[string]interface{}
+	}{
+		{"", map[string]interface{}{"cinema": "2026-04-20", "digital": "2026-06-15", "physical": "2026-08-01"}},
+		{"us", map[string]interface{}{"cinema": "2026-05-01", "digital": "2026-06-15", "physical": "2026-08-01"}},
+	}
+	for _, tt := range tests {
+		if got := releaseDates(details, tt.region); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("releaseDates(%q) = %v, want %v", tt.region, got, tt.want)
+		}
+	}
+
+	if got := releaseDates(map[string]interface{}{}, ""); got != nil {
+		t.Errorf("releaseDates(no dates) = %v, want nil", got)
+	}
+}
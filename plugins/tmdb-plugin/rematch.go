@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// imageKeys are cleared when a new match doesn't have them, so the images of
+// the previous match don't stay behind
+var imageKeys = []string{"poster_url", "backdrop_url", "still_url"}
+
+// externalIDKeys are the external IDs a match sets, cleared when the new
+// match doesn't have them
+var externalIDKeys = []string{"imdb_id", "tvdb_id", "tvrage_id", "facebook_id", "instagram_id", "twitter_id"}
+
+// matchSummary is what matching a media item by hand changed
+type matchSummary struct {
+	MediaID         int64                  `json:"media_id"`
+	TMDBID          string                 `json:"tmdb_id"`
+	Type            string                 `json:"type"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	ExternalIDs     map[string]interface{} `json:"external_ids"`
+	SeasonsUpdated  int                    `json:"seasons_updated"`
+	EpisodesUpdated int                    `json:"episodes_updated"`
+	Warnings        []string               `json:"warnings,omitempty"`
+}
+
+// applyMatch replaces the metadata and external IDs of a media item with those
+// of a TMDB movie or TV show ("movie" or "tv"). The seasons and episodes of a
+// series are updated from their TMDB seasons.
+func (p *TMDBPlugin) applyMatch(ctx context.Context, sdk plugins.SDKInterface, apiKey string, mediaID int64, tmdbID, mediaType string) (*matchSummary, error) {
+	kind := "movie"
+	if mediaType == "tv" {
+		kind = "tv_series"
+	}
+
+	appended := "credits,images,external_ids"
+	if kind == "movie" {
+		appended += ",release_dates"
+	}
+	detailsURL := fmt.Sprintf("%s/%s/%s?api_key=%s&append_to_response=%s",
+		tmdbAPIBaseURL, mediaType, tmdbID, apiKey, appended)
+	details, err := p.fetchDetails(ctx, detailsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch TMDB data: %w", err)
+	}
+
+	metadata := matchedMetadata(details, kind, tmdbID)
+	if kind == "movie" {
+		p.addReleaseDates(metadata, details)
+		p.addCollectionDetails(ctx, apiKey, metadata)
+		clearMissing(metadata, collectionKeys)
+	}
+	externalIDs := parseExternalIDs(details)
+	clearMissing(externalIDs, externalIDKeys)
+	externalIDs["tmdb"] = tmdbID
+
+	if _, err := sdk.MediaUpdateMetadata(ctx, mediaID, metadata, externalIDs); err != nil {
+		return nil, fmt.Errorf("failed to update media item: %w", err)
+	}
+
+	summary := &matchSummary{
+		MediaID:     mediaID,
+		TMDBID:      tmdbID,
+		Type:        mediaType,
+		Metadata:    metadata,
+		ExternalIDs: externalIDs,
+	}
+	if mediaType == "tv" {
+		p.applySeasons(ctx, sdk, apiKey, mediaID, tmdbID, externalIDs, summary)
+	}
+	return summary, nil
+}
+
+// applySeasons updates the seasons and episodes of a series from its TMDB
+// seasons. Seasons that fail are skipped with a warning.
+func (p *TMDBPlugin) applySeasons(ctx context.Context, sdk plugins.SDKInterface, apiKey string, seriesID int64, tmdbID string, externalIDs map[string]interface{}, summary *matchSummary) {
+	seasons, err := sdk.MediaList(ctx, seriesID, "tv_season")
+	if err != nil {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("failed to list seasons: %v", err))
+		return
+	}
+
+	for _, season := range seasons {
+		number, ok := metadataInt(season.Metadata, "season_number")
+		if !ok {
+			continue
+		}
+
+		seasonURL := fmt.Sprintf("%s/tv/%s/season/%d?api_key=%s&append_to_response=images",
+			tmdbAPIBaseURL, tmdbID, number, apiKey)
+		details, err := p.fetchDetails(ctx, seasonURL)
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("season %d: %v", number, err))
+			continue
+		}
+
+		if _, err := sdk.MediaUpdateMetadata(ctx, season.ID, matchedMetadata(details, "tv_season", tmdbID), externalIDs); err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("season %d: %v", number, err))
+			continue
+		}
+		summary.SeasonsUpdated++
+
+		// The season response lists its episodes
+		byNumber := make(map[int]map[string]interface{})
+		if list, ok := details["episodes"].([]interface{}); ok {
+			for _, e := range list {
+				if episode, ok := e.(map[string]interface{}); ok {
+					if n, ok := metadataInt(episode, "episode_number"); ok {
+						byNumber[n] = episode
+					}
+				}
+			}
+		}
+
+		episodes, err := sdk.MediaList(ctx, season.ID, "tv_episode")
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("season %d: failed to list episodes: %v", number, err))
+			continue
+		}
+		for _, episode := range episodes {
+			n, ok := metadataInt(episode.Metadata, "episode")
+			if !ok {
+				continue
+			}
+			tmdbEpisode, ok := byNumber[n]
+			if !ok {
+				continue
+			}
+			if _, err := sdk.MediaUpdateMetadata(ctx, episode.ID, matchedMetadata(tmdbEpisode, "tv_episode", tmdbID), externalIDs); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("S%02dE%02d: %v", number, n, err))
+				continue
+			}
+			summary.EpisodesUpdated++
+		}
+	}
+}
+
+// matchedMetadata extracts the metadata of a manual match. Images the match
+// doesn't have and the candidates of an automatic match are cleared.
+func matchedMetadata(details map[string]interface{}, kind, tmdbID string) map[string]interface{} {
+	metadata := extractMetadata(details, kind, tmdbID)
+	clearMissing(metadata, imageKeys)
+	metadata["tmdb_match"] = nil
+	return metadata
+}
+
+// clearMissing sets the keys m doesn't have to nil, which removes their old
+// values when merged into a media item
+func clearMissing(m map[string]interface{}, keys []string) {
+	for _, key := range keys {
+		if _, ok := m[key]; !ok {
+			m[key] = nil
+		}
+	}
+}
+
+// metadataInt reads a whole number from JSON metadata
+func metadataInt(m map[string]interface{}, key string) (int, bool) {
+	switch v := m[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
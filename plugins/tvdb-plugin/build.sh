@@ -0,0 +1,24 @@
+#!/bin/bash
+set -e
+
+echo "Building TVDB plugin..."
+go build -o tvdb-plugin .
+echo "✓ Build successful!"
+
+echo ""
+echo "Plugin ready at: $(pwd)/tvdb-plugin"
+echo ""
+echo "To use this plugin:"
+echo "1. Set the TVDB API key (and PIN for user-supported keys) in the config table:"
+echo "   curl -X PUT 'http://localhost:8080/api/config/plugins.tvdb.api_key' \\"
+echo "     -H 'Authorization: Bearer YOUR_JWT_TOKEN' \\"
+echo "     -H 'Content-Type: application/json' \\"
+echo "     -d '{\"value\": \"your_tvdb_api_key_here\"}'"
+echo "2. Optionally prefer TVDB over TMDB for new items:"
+echo "   curl -X PUT 'http://localhost:8080/api/config/metadata.preferred_provider' \\"
+echo "     -H 'Authorization: Bearer YOUR_JWT_TOKEN' \\"
+echo "     -H 'Content-Type: application/json' \\"
+echo "     -d '{\"value\": \"tvdb\"}'"
+echo "3. Ensure ENABLE_PLUGINS=true"
+echo "4. Set PLUGINS_DIR to the plugins directory"
+echo "5. Restart the Nimbus server"
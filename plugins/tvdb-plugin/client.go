@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before it expires a token is replaced. TVDB
+// tokens are valid for a month.
+const tokenRefreshMargin = 24 * time.Hour
+
+// errNotFound is returned when TVDB has no such record
+var errNotFound = errors.New("not found on TVDB")
+
+// credentials are what a TVDB token is requested with
+type credentials struct {
+	APIKey string
+	PIN    string // Only for user-supported keys
+}
+
+// tvdbClient calls the TVDB v4 API, logging in with the configured key and
+// logging in again when the token is about to expire or is rejected
+type tvdbClient struct {
+	baseURL string
+	http    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	creds   credentials // Credentials the token was issued for
+}
+
+func newTVDBClient() *tvdbClient {
+	return &tvdbClient{
+		baseURL: tvdbAPIBaseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get fetches a TVDB endpoint and decodes the "data" of its response into v
+func (c *tvdbClient) get(ctx context.Context, creds credentials, path string, query url.Values, v interface{}) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := c.ensureToken(ctx, creds)
+		if err != nil {
+			return err
+		}
+
+		u := c.baseURL + path
+		if len(query) > 0 {
+			u += "?" + query.Encode()
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return decodeData(body, v)
+		case http.StatusUnauthorized:
+			// The token was revoked or the key changed, log in again once
+			c.invalidate(token)
+			continue
+		case http.StatusNotFound:
+			return errNotFound
+		default:
+			return fmt.Errorf("TVDB API returned status %d", resp.StatusCode)
+		}
+	}
+	return errors.New("TVDB rejected the API key")
+}
+
+// ensureToken returns a valid token, logging in when there is none, it is
+// about to expire, or the credentials changed
+func (c *tvdbClient) ensureToken(ctx context.Context, creds credentials) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.creds == creds && time.Until(c.expires) > tokenRefreshMargin {
+		return c.token, nil
+	}
+
+	token, err := c.login(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.creds = creds
+	c.expires = tokenExpiry(token)
+	return token, nil
+}
+
+// invalidate drops a token TVDB rejected, unless it was already replaced
+func (c *tvdbClient) invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// login requests a token for an API key and PIN
+func (c *tvdbClient) login(ctx context.Context, creds credentials) (string, error) {
+	payload := map[string]string{"apikey": creds.APIKey}
+	if creds.PIN != "" {
+		payload["pin"] = creds.PIN
+	}
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to TVDB: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to TVDB: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("TVDB login failed with status %d, check the API key and PIN", resp.StatusCode)
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := decodeData(data, &result); err != nil || result.Token == "" {
+		return "", errors.New("TVDB login returned no token")
+	}
+	return result.Token, nil
+}
+
+// decodeData decodes the "data" of a TVDB response envelope
+func decodeData(body []byte, v interface{}) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return errors.New("failed to parse TVDB response")
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, v); err != nil {
+		return errors.New("failed to parse TVDB response")
+	}
+	return nil
+}
+
+// tokenExpiry reads the expiry of a TVDB JWT. Tokens that can't be read are
+// assumed to last a day past the refresh margin.
+func tokenExpiry(token string) time.Time {
+	fallback := time.Now().Add(tokenRefreshMargin + 24*time.Hour)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
+}
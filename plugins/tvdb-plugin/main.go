@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+	"github.com/hashicorp/go-plugin"
+)
+
+const (
+	tvdbAPIBaseURL = "https://api4.thetvdb.com/v4"
+	configAPIKey   = "plugins.tvdb.api_key"
+	configPIN      = "plugins.tvdb.pin"
+)
+
+// TVDBPlugin implements the MediaSuitePlugin interface
+type TVDBPlugin struct {
+	client *tvdbClient
+}
+
+// NewTVDBPlugin creates a new TVDB plugin instance
+func NewTVDBPlugin() *TVDBPlugin {
+	return &TVDBPlugin{
+		client: newTVDBClient(),
+	}
+}
+
+// Metadata returns plugin metadata
+func (p *TVDBPlugin) Metadata(ctx context.Context) (*plugins.PluginMetadata, error) {
+	return &plugins.PluginMetadata{
+		ID:           "tvdb-plugin",
+		Name:         "TheTVDB",
+		Version:      "0.1.0",
+		Description:  "Fetches TV series, season, episode and movie metadata from TheTVDB",
+		Capabilities: []string{"api"},
+	}, nil
+}
+
+// APIRoutes returns the HTTP routes this plugin provides
+func (p *TVDBPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	return []plugins.RouteDescriptor{
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/search/tv",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/search/movie",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/movie/{id}",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/tv/{id}",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/tv/{id}/season/{season}",
+			Auth:   "session",
+		},
+		{
+			Method: "GET",
+			Path:   "/api/plugins/tvdb/tv/{id}/season/{season}/episode/{episode}",
+			Auth:   "session",
+		},
+		{
+			Method: "POST",
+			Path:   "/api/plugins/tvdb/enrich",
+			Auth:   "none", // Allow internal scanner calls without auth
+		},
+	}, nil
+}
+
+// HandleAPI handles HTTP requests for this plugin's routes
+func (p *TVDBPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	creds := getCredentials(ctx, req.SDK)
+	if creds.APIKey == "" {
+		return p.errorResponse(http.StatusInternalServerError, "TVDB API key not configured. Please set 'plugins.tvdb.api_key' in the config table or TVDB_API_KEY environment variable.")
+	}
+
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern and take IDs from its placeholders
+	params := req.PathParams
+	switch strings.TrimPrefix(req.Route, "/api/plugins/tvdb") {
+	case "/search/tv":
+		return p.handleSearch(ctx, req, creds, "series")
+	case "/search/movie":
+		return p.handleSearch(ctx, req, creds, "movie")
+	case "/enrich":
+		return p.handleEnrich(ctx, req, creds)
+	case "/movie/{id}":
+		return p.handleGetMovie(ctx, creds, params["id"])
+	case "/tv/{id}":
+		return p.handleGetSeries(ctx, creds, params["id"])
+	case "/tv/{id}/season/{season}":
+		return p.handleGetSeason(ctx, creds, params["id"], params["season"])
+	case "/tv/{id}/season/{season}/episode/{episode}":
+		return p.handleGetEpisode(ctx, creds, params["id"], params["season"], params["episode"])
+	default:
+		return p.errorResponse(http.StatusNotFound, "Not found")
+	}
+}
+
+// handleSearch searches TVDB for series or movies
+func (p *TVDBPlugin) handleSearch(ctx context.Context, req *plugins.PluginHTTPRequest, creds credentials, recordType string) (*plugins.PluginHTTPResponse, error) {
+	query := p.getQueryParam(req, "query")
+	if query == "" {
+		return p.errorResponse(http.StatusBadRequest, "query parameter is required")
+	}
+	year, _ := strconv.Atoi(p.getQueryParam(req, "year"))
+
+	results, err := p.search(ctx, creds, recordType, query, year)
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	return jsonResponse(map[string]interface{}{"results": results})
+}
+
+// handleGetMovie returns a movie with its metadata
+func (p *TVDBPlugin) handleGetMovie(ctx context.Context, creds credentials, id string) (*plugins.PluginHTTPResponse, error) {
+	m, err := p.getMovie(ctx, creds, id)
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	return jsonResponse(map[string]interface{}{
+		"movie":        m,
+		"metadata":     movieMetadata(m),
+		"external_ids": remoteExternalIDs(m.ID, m.RemoteIDs),
+	})
+}
+
+// handleGetSeries returns a series with its metadata
+func (p *TVDBPlugin) handleGetSeries(ctx context.Context, creds credentials, id string) (*plugins.PluginHTTPResponse, error) {
+	s, err := p.getSeries(ctx, creds, id)
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	return jsonResponse(map[string]interface{}{
+		"series":       s,
+		"metadata":     seriesMetadata(s),
+		"external_ids": remoteExternalIDs(s.ID, s.RemoteIDs),
+	})
+}
+
+// handleGetSeason returns the episodes of a season
+func (p *TVDBPlugin) handleGetSeason(ctx context.Context, creds credentials, id, seasonStr string) (*plugins.PluginHTTPResponse, error) {
+	season, err := strconv.Atoi(seasonStr)
+	if err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid season number")
+	}
+	s, err := p.getSeries(ctx, creds, id)
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	episodes, err := p.getEpisodes(ctx, creds, s.ID, season)
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	return jsonResponse(map[string]interface{}{
+		"series_id":     s.ID,
+		"season_number": season,
+		"episodes":      episodes,
+	})
+}
+
+// handleGetEpisode returns an episode with its metadata
+func (p *TVDBPlugin) handleGetEpisode(ctx context.Context, creds credentials, id, seasonStr, episodeStr string) (*plugins.PluginHTTPResponse, error) {
+	season, err := strconv.Atoi(seasonStr)
+	if err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid season number")
+	}
+	number, err := strconv.Atoi(episodeStr)
+	if err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid episode number")
+	}
+	metadata, _, err := p.lookupMetadata(ctx, creds, enrichQuery{
+		Kind:        "tv_episode",
+		Season:      season,
+		Episode:     number,
+		ExternalIDs: map[string]interface{}{"tvdb_id": id},
+	})
+	if err != nil {
+		return p.tvdbError(err)
+	}
+	return jsonResponse(map[string]interface{}{"metadata": metadata})
+}
+
+// handleEnrich looks up metadata for a media item (for scanner). It returns
+// the same metadata and external_ids as the TMDB plugin's enrich endpoint.
+func (p *TVDBPlugin) handleEnrich(ctx context.Context, req *plugins.PluginHTTPRequest, creds credentials) (*plugins.PluginHTTPResponse, error) {
+	var reqBody struct {
+		Title       string                 `json:"title"`
+		Year        int                    `json:"year,omitempty"`
+		Kind        string                 `json:"kind"` // "movie", "tv_series", "tv_season" or "tv_episode"
+		Season      int                    `json:"season,omitempty"`
+		Episode     int                    `json:"episode,omitempty"`
+		ExternalIDs map[string]interface{} `json:"external_ids,omitempty"`
+	}
+	if err := json.Unmarshal(req.Body, &reqBody); err != nil {
+		return p.errorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if reqBody.Kind == "" || (reqBody.Title == "" && storedTVDBID(reqBody.ExternalIDs) == "") {
+		return p.errorResponse(http.StatusBadRequest, "kind and title or a tvdb_id are required")
+	}
+
+	metadata, externalIDs, err := p.lookupMetadata(ctx, creds, enrichQuery{
+		Title:       reqBody.Title,
+		Year:        reqBody.Year,
+		Kind:        reqBody.Kind,
+		Season:      reqBody.Season,
+		Episode:     reqBody.Episode,
+		ExternalIDs: reqBody.ExternalIDs,
+	})
+	if errors.Is(err, errNotFound) {
+		return p.errorResponse(http.StatusNotFound, "No results found")
+	}
+	if err != nil {
+		return p.errorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	return jsonResponse(map[string]interface{}{
+		"success":      true,
+		"metadata":     metadata,
+		"external_ids": externalIDs,
+	})
+}
+
+// UIManifest returns the UI configuration for this plugin
+func (p *TVDBPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error) {
+	return &plugins.UIManifest{
+		NavItems: []plugins.UINavItem{},
+		Routes:   []plugins.UIRoute{},
+		ConfigSection: &plugins.ConfigSection{
+			Title:       "TVDB Settings",
+			Description: "Configure TheTVDB v4 API integration",
+			Fields: []plugins.ConfigField{
+				{
+					Key:         configAPIKey,
+					Label:       "API Key",
+					Description: "Your TheTVDB v4 API key",
+					Type:        "text",
+					Required:    true,
+					Placeholder: "Enter your TVDB API key",
+				},
+				{
+					Key:         configPIN,
+					Label:       "Subscriber PIN",
+					Description: "The PIN of your TVDB subscription, only needed for user-supported API keys",
+					Type:        "password",
+				},
+			},
+		},
+	}, nil
+}
+
+// HandleEvent handles system events. Lookups are requested by the host
+// through the enrich endpoint, so no events are handled.
+func (p *TVDBPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
+	return nil
+}
+
+// IsIndexer returns false as TVDB is not an indexer plugin
+func (p *TVDBPlugin) IsIndexer(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Search is not implemented for TVDB plugin
+func (p *TVDBPlugin) Search(ctx context.Context, req *plugins.IndexerSearchRequest) (*plugins.IndexerSearchResponse, error) {
+	return nil, fmt.Errorf("TVDB plugin does not support search")
+}
+
+// IsDownloader returns false as TVDB is not a downloader plugin
+func (p *TVDBPlugin) IsDownloader(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Helper functions
+
+// getCredentials fetches the TVDB API key and PIN from the Nimbus config
+// table, falling back to the TVDB_API_KEY and TVDB_PIN environment variables
+func getCredentials(ctx context.Context, sdk plugins.SDKInterface) credentials {
+	creds := credentials{
+		APIKey: os.Getenv("TVDB_API_KEY"),
+		PIN:    os.Getenv("TVDB_PIN"),
+	}
+	if sdk != nil {
+		if apiKey, err := sdk.ConfigGetString(ctx, configAPIKey); err == nil && apiKey != "" {
+			creds.APIKey = apiKey
+		}
+		if pin, err := sdk.ConfigGetString(ctx, configPIN); err == nil && pin != "" {
+			creds.PIN = pin
+		}
+	}
+	return creds
+}
+
+// tvdbError turns a TVDB error into a response
+func (p *TVDBPlugin) tvdbError(err error) (*plugins.PluginHTTPResponse, error) {
+	if errors.Is(err, errNotFound) {
+		return p.errorResponse(http.StatusNotFound, "Not found on TVDB")
+	}
+	return p.errorResponse(http.StatusBadGateway, err.Error())
+}
+
+// jsonResponse encodes data as a 200 JSON response
+func jsonResponse(data interface{}) (*plugins.PluginHTTPResponse, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.PluginHTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func (p *TVDBPlugin) getQueryParam(req *plugins.PluginHTTPRequest, key string) string {
+	if values, ok := req.Query[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (p *TVDBPlugin) errorResponse(statusCode int, message string) (*plugins.PluginHTTPResponse, error) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &plugins.PluginHTTPResponse{
+		StatusCode: statusCode,
+		Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+func main() {
+	tvdbPlugin := NewTVDBPlugin()
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: plugins.Handshake,
+		Plugins: map[string]plugin.Plugin{
+			"media-suite": &plugins.MediaSuitePluginGRPC{
+				Impl: tvdbPlugin,
+			},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TVDB artwork types used for posters and backdrops
+const (
+	artworkSeriesPoster     = 2
+	artworkSeriesBackground = 3
+	artworkSeasonPoster     = 7
+	artworkMoviePoster      = 14
+	artworkMovieBackground  = 15
+)
+
+// searchResult is a series or movie in TVDB search results
+type searchResult struct {
+	TVDBID   string `json:"tvdb_id"`
+	Name     string `json:"name"`
+	Year     string `json:"year"`
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	Overview string `json:"overview"`
+}
+
+// remoteID is an ID of a TVDB record on another site
+type remoteID struct {
+	ID         string `json:"id"`
+	SourceName string `json:"sourceName"`
+}
+
+// artwork is an image of a TVDB record
+type artwork struct {
+	Image string  `json:"image"`
+	Type  int     `json:"type"`
+	Score float64 `json:"score"`
+}
+
+// genre is a TVDB genre
+type genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// translations holds the translated overviews of a record, requested with meta=translations
+type translations struct {
+	OverviewTranslations []struct {
+		Language string `json:"language"`
+		Overview string `json:"overview"`
+	} `json:"overviewTranslations"`
+}
+
+// seasonRef is a season listed on a series
+type seasonRef struct {
+	ID     int64  `json:"id"`
+	Number int    `json:"number"`
+	Image  string `json:"image"`
+	Type   struct {
+		Type string `json:"type"`
+	} `json:"type"`
+}
+
+// series is an extended TVDB series record
+type series struct {
+	ID             int64        `json:"id"`
+	Name           string       `json:"name"`
+	Overview       string       `json:"overview"`
+	Image          string       `json:"image"`
+	FirstAired     string       `json:"firstAired"`
+	Year           string       `json:"year"`
+	AverageRuntime int          `json:"averageRuntime"`
+	Genres         []genre      `json:"genres"`
+	RemoteIDs      []remoteID   `json:"remoteIds"`
+	Artworks       []artwork    `json:"artworks"`
+	Seasons        []seasonRef  `json:"seasons"`
+	Translations   translations `json:"translations"`
+}
+
+// movie is an extended TVDB movie record
+type movie struct {
+	ID           int64        `json:"id"`
+	Name         string       `json:"name"`
+	Overview     string       `json:"overview"`
+	Image        string       `json:"image"`
+	Year         string       `json:"year"`
+	Runtime      int          `json:"runtime"`
+	Genres       []genre      `json:"genres"`
+	RemoteIDs    []remoteID   `json:"remoteIds"`
+	Artworks     []artwork    `json:"artworks"`
+	Translations translations `json:"translations"`
+	FirstRelease struct {
+		Date string `json:"date"`
+	} `json:"first_release"`
+}
+
+// episode is a TVDB episode
+type episode struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	Overview       string `json:"overview"`
+	Aired          string `json:"aired"`
+	Runtime        int    `json:"runtime"`
+	Image          string `json:"image"`
+	SeasonNumber   int    `json:"seasonNumber"`
+	Number         int    `json:"number"`
+	AbsoluteNumber int    `json:"absoluteNumber"`
+}
+
+// enrichQuery identifies what to look up on TVDB
+type enrichQuery struct {
+	Title       string // Movie or series title
+	Year        int
+	Kind        string // "movie", "tv_series", "tv_season" or "tv_episode"
+	Season      int
+	Episode     int
+	ExternalIDs map[string]interface{} // IDs already known, of the series for seasons and episodes
+}
+
+// lookupMetadata finds a movie or TV item on TVDB and returns its metadata
+// and external IDs. A TVDB ID in the external IDs is used as is; otherwise
+// the title is searched. It returns errNotFound when TVDB has no match.
+func (p *TVDBPlugin) lookupMetadata(ctx context.Context, creds credentials, query enrichQuery) (map[string]interface{}, map[string]interface{}, error) {
+	switch query.Kind {
+	case "movie":
+		id, err := p.resolveID(ctx, creds, "movie", query)
+		if err != nil {
+			return nil, nil, err
+		}
+		m, err := p.getMovie(ctx, creds, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return movieMetadata(m), remoteExternalIDs(m.ID, m.RemoteIDs), nil
+
+	case "tv_series", "tv_season", "tv_episode":
+		id, err := p.resolveID(ctx, creds, "series", query)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := p.getSeries(ctx, creds, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		externalIDs := remoteExternalIDs(s.ID, s.RemoteIDs)
+
+		switch query.Kind {
+		case "tv_series":
+			return seriesMetadata(s), externalIDs, nil
+		case "tv_season":
+			for _, season := range s.Seasons {
+				if season.Type.Type == "official" && season.Number == query.Season {
+					return seasonMetadata(s, season), externalIDs, nil
+				}
+			}
+			return nil, nil, errNotFound
+		default:
+			episodes, err := p.getEpisodes(ctx, creds, s.ID, query.Season)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, e := range episodes {
+				if e.Number == query.Episode {
+					return episodeMetadata(s, e), externalIDs, nil
+				}
+			}
+			return nil, nil, errNotFound
+		}
+
+	default:
+		return nil, nil, errNotFound
+	}
+}
+
+// resolveID returns the TVDB ID of the series or movie being looked up,
+// preferring one already stored over a title search
+func (p *TVDBPlugin) resolveID(ctx context.Context, creds credentials, recordType string, query enrichQuery) (string, error) {
+	if id := storedTVDBID(query.ExternalIDs); id != "" {
+		return id, nil
+	}
+	if query.Title == "" {
+		return "", errNotFound
+	}
+
+	results, err := p.search(ctx, creds, recordType, query.Title, query.Year)
+	if err != nil {
+		return "", err
+	}
+	best := bestResult(results, query.Title, query.Year)
+	if best == nil {
+		return "", errNotFound
+	}
+	return best.TVDBID, nil
+}
+
+// storedTVDBID reads a TVDB ID from external IDs, which TMDB stores as a
+// number under tvdb_id
+func storedTVDBID(externalIDs map[string]interface{}) string {
+	for _, key := range []string{"tvdb_id", "tvdb"} {
+		switch v := externalIDs[key].(type) {
+		case float64:
+			if v > 0 {
+				return strconv.FormatInt(int64(v), 10)
+			}
+		case int:
+			if v > 0 {
+				return strconv.Itoa(v)
+			}
+		case string:
+			if v != "" && v != "0" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// search searches TVDB for series or movies
+func (p *TVDBPlugin) search(ctx context.Context, creds credentials, recordType, title string, year int) ([]searchResult, error) {
+	query := url.Values{"query": {title}, "type": {recordType}}
+	if year > 0 {
+		query.Set("year", strconv.Itoa(year))
+	}
+	var results []searchResult
+	if err := p.client.get(ctx, creds, "/search", query, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// bestResult picks the search result whose name and year match the lookup.
+// Without an exact name, the first result TVDB ranked is taken when its year
+// fits.
+func bestResult(results []searchResult, title string, year int) *searchResult {
+	want := normalizeTitle(title)
+	yearFits := func(r searchResult) bool {
+		if year == 0 || r.Year == "" {
+			return true
+		}
+		y, _ := strconv.Atoi(r.Year)
+		return y >= year-1 && y <= year+1
+	}
+
+	for i, r := range results {
+		if normalizeTitle(r.Name) == want && yearFits(r) {
+			return &results[i]
+		}
+	}
+	for i, r := range results {
+		if yearFits(r) {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// normalizeTitle lower-cases a title and drops punctuation and a leading article
+func normalizeTitle(title string) string {
+	title = strings.ReplaceAll(strings.ToLower(title), "&", " and ")
+
+	var b strings.Builder
+	for _, r := range title {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == ':' || r == '/':
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	if len(words) > 1 {
+		switch words[0] {
+		case "the", "a", "an":
+			words = words[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// getSeries fetches an extended series record
+func (p *TVDBPlugin) getSeries(ctx context.Context, creds credentials, id string) (*series, error) {
+	var s series
+	query := url.Values{"meta": {"translations"}}
+	if err := p.client.get(ctx, creds, "/series/"+url.PathEscape(id)+"/extended", query, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// getMovie fetches an extended movie record
+func (p *TVDBPlugin) getMovie(ctx context.Context, creds credentials, id string) (*movie, error) {
+	var m movie
+	query := url.Values{"meta": {"translations"}}
+	if err := p.client.get(ctx, creds, "/movies/"+url.PathEscape(id)+"/extended", query, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// getEpisodes fetches the episodes of a season in aired order
+func (p *TVDBPlugin) getEpisodes(ctx context.Context, creds credentials, seriesID int64, season int) ([]episode, error) {
+	var all []episode
+	// Seasons rarely span more than one page of 500 episodes, but long
+	// running daily shows do
+	for page := 0; page < 10; page++ {
+		var result struct {
+			Episodes []episode `json:"episodes"`
+		}
+		query := url.Values{"season": {strconv.Itoa(season)}, "page": {strconv.Itoa(page)}}
+		if err := p.client.get(ctx, creds, fmt.Sprintf("/series/%d/episodes/default", seriesID), query, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Episodes...)
+		if len(result.Episodes) < 500 {
+			break
+		}
+	}
+	return all, nil
+}
+
+// remoteExternalIDs returns the external IDs of a TVDB record, keyed like the
+// TMDB plugin's
+func remoteExternalIDs(tvdbID int64, remoteIDs []remoteID) map[string]interface{} {
+	externalIDs := map[string]interface{}{"tvdb_id": tvdbID}
+	for _, r := range remoteIDs {
+		if r.ID == "" {
+			continue
+		}
+		switch r.SourceName {
+		case "IMDB":
+			externalIDs["imdb_id"] = r.ID
+		case "TheMovieDB.com":
+			externalIDs["tmdb"] = r.ID
+		case "TV.com":
+			externalIDs["tvcom_id"] = r.ID
+		}
+	}
+	return externalIDs
+}
+
+// seriesMetadata extracts the metadata of a series
+func seriesMetadata(s *series) map[string]interface{} {
+	metadata := baseMetadata(s.ID, "tv_series", s.Overview, s.Translations, s.Genres, s.RemoteIDs)
+	setString(metadata, "poster_url", s.Image)
+	setString(metadata, "backdrop_url", bestArtwork(s.Artworks, artworkSeriesBackground))
+	if metadata["poster_url"] == nil {
+		setString(metadata, "poster_url", bestArtwork(s.Artworks, artworkSeriesPoster))
+	}
+	setString(metadata, "first_air_date", s.FirstAired)
+	if s.AverageRuntime > 0 {
+		metadata["runtime"] = s.AverageRuntime
+	}
+	return metadata
+}
+
+// seasonMetadata extracts the metadata of a season of a series
+func seasonMetadata(s *series, season seasonRef) map[string]interface{} {
+	metadata := baseMetadata(s.ID, "tv_season", "", translations{}, nil, nil)
+	metadata["season_number"] = season.Number
+	metadata["tvdb_season_id"] = season.ID
+	setString(metadata, "poster_url", season.Image)
+	if metadata["poster_url"] == nil {
+		setString(metadata, "poster_url", bestArtwork(s.Artworks, artworkSeasonPoster))
+	}
+	return metadata
+}
+
+// episodeMetadata extracts the metadata of an episode
+func episodeMetadata(s *series, e episode) map[string]interface{} {
+	metadata := baseMetadata(s.ID, "tv_episode", e.Overview, translations{}, nil, nil)
+	metadata["tvdb_episode_id"] = e.ID
+	setString(metadata, "episode_name", e.Name)
+	setString(metadata, "still_url", e.Image)
+	setString(metadata, "air_date", e.Aired)
+	if e.Runtime > 0 {
+		metadata["runtime"] = e.Runtime
+	}
+	metadata["season"] = e.SeasonNumber
+	metadata["season_number"] = e.SeasonNumber
+	metadata["episode"] = e.Number
+	metadata["episode_number"] = e.Number
+	if e.AbsoluteNumber > 0 {
+		metadata["absolute_number"] = e.AbsoluteNumber
+	}
+	return metadata
+}
+
+// movieMetadata extracts the metadata of a movie
+func movieMetadata(m *movie) map[string]interface{} {
+	metadata := baseMetadata(m.ID, "movie", m.Overview, m.Translations, m.Genres, m.RemoteIDs)
+	setString(metadata, "poster_url", m.Image)
+	if metadata["poster_url"] == nil {
+		setString(metadata, "poster_url", bestArtwork(m.Artworks, artworkMoviePoster))
+	}
+	setString(metadata, "backdrop_url", bestArtwork(m.Artworks, artworkMovieBackground))
+	setString(metadata, "release_date", m.FirstRelease.Date)
+	if m.Runtime > 0 {
+		metadata["runtime"] = m.Runtime
+	}
+	return metadata
+}
+
+// baseMetadata holds the fields every kind of item has, in the shape the
+// TMDB plugin stores them
+func baseMetadata(tvdbID int64, kind, overview string, t translations, genres []genre, remoteIDs []remoteID) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"tvdb_id":           strconv.FormatInt(tvdbID, 10),
+		"type":              kind,
+		"metadata_provider": "tvdb",
+	}
+
+	if overview == "" {
+		for _, tr := range t.OverviewTranslations {
+			if tr.Language == "eng" {
+				overview = tr.Overview
+			}
+		}
+	}
+	setString(metadata, "description", overview)
+
+	if len(genres) > 0 {
+		list := make([]interface{}, 0, len(genres))
+		for _, g := range genres {
+			list = append(list, map[string]interface{}{"id": g.ID, "name": g.Name})
+		}
+		metadata["genres"] = list
+	}
+
+	// External IDs are also kept in metadata, as the TMDB plugin does
+	for _, r := range remoteIDs {
+		if r.SourceName == "IMDB" && r.ID != "" {
+			metadata["imdb_id"] = r.ID
+		}
+	}
+	return metadata
+}
+
+// bestArtwork returns the highest scored artwork of a type
+func bestArtwork(artworks []artwork, artworkType int) string {
+	var best *artwork
+	for i, a := range artworks {
+		if a.Type == artworkType && a.Image != "" && (best == nil || a.Score > best.Score) {
+			best = &artworks[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Image
+}
+
+// setString sets a metadata key unless the value is empty
+func setString(metadata map[string]interface{}, key, value string) {
+	if value != "" {
+		metadata[key] = value
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeToken returns a JWT that expires at exp
+func fakeToken(exp time.Time) string {
+	claims, _ := json.Marshal(map[string]int64{"exp": exp.Unix()})
+	return "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+func TestLookupEpisodeByStoredID(t *testing.T) {
+	token := fakeToken(time.Now().Add(30 * 24 * time.Hour))
+	logins := 0
+	rejectNext := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			logins++
+			fmt.Fprintf(w, `{"data":{"token":%q}}`, token)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token || rejectNext {
+			rejectNext = false
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/series/81189/extended":
+			fmt.Fprint(w, `{"data":{"id":81189,"name":"Breaking Bad","image":"https://artworks.thetvdb.com/poster.jpg",
+				"remoteIds":[{"id":"tt0903747","sourceName":"IMDB"},{"id":"1396","sourceName":"TheMovieDB.com"}],
+				"seasons":[{"id":30272,"number":1,"type":{"type":"official"}}]}}`)
+		case "/series/81189/episodes/default":
+			if r.URL.Query().Get("season") != "1" {
+				t.Errorf("episodes requested for season %s", r.URL.Query().Get("season"))
+			}
+			fmt.Fprint(w, `{"data":{"episodes":[{"id":349232,"name":"Pilot","aired":"2008-01-20","seasonNumber":1,"number":1,"absoluteNumber":1},
+				{"id":349235,"name":"Cat's in the Bag...","seasonNumber":1,"number":2,"absoluteNumber":2}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewTVDBPlugin()
+	p.client.baseURL = server.URL
+	creds := credentials{APIKey: "key"}
+
+	query := enrichQuery{
+		Title:       "Something Else Entirely",
+		Kind:        "tv_episode",
+		Season:      1,
+		Episode:     2,
+		ExternalIDs: map[string]interface{}{"tvdb_id": float64(81189)},
+	}
+	metadata, externalIDs, err := p.lookupMetadata(context.Background(), creds, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["episode_name"] != "Cat's in the Bag..." || metadata["absolute_number"] != 2 || metadata["tvdb_id"] != "81189" {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if externalIDs["imdb_id"] != "tt0903747" || externalIDs["tmdb"] != "1396" || externalIDs["tvdb_id"] != int64(81189) {
+		t.Errorf("external IDs = %v", externalIDs)
+	}
+
+	// A rejected token is replaced once
+	rejectNext = true
+	query.Kind = "tv_season"
+	if metadata, _, err = p.lookupMetadata(context.Background(), creds, query); err != nil {
+		t.Fatal(err)
+	}
+	if metadata["season_number"] != 1 {
+		t.Errorf("season metadata = %v", metadata)
+	}
+	if logins != 2 {
+		t.Errorf("logged in %d times, want 2", logins)
+	}
+
+	query.Episode = 9
+	query.Kind = "tv_episode"
+	if _, _, err := p.lookupMetadata(context.Background(), creds, query); err != errNotFound {
+		t.Errorf("missing episode error = %v, want errNotFound", err)
+	}
+}
+
+func TestBestResult(t *testing.T) {
+	results := []searchResult{
+		{TVDBID: "1", Name: "The Office (US)", Year: "2005"},
+		{TVDBID: "2", Name: "The Office", Year: "2001"},
+	}
+	tests := []struct {
+		title string
+		year  int
+		want  string
+	}{
+		{"The Office", 0, "2"},
+		{"The Office", 2005, "1"},
+		{"The Office", 1990, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if r := bestResult(results, tt.title, tt.year); r != nil {
+			got = r.TVDBID
+		}
+		if got != tt.want {
+			t.Errorf("bestResult(%q, %d) = %q, want %q", tt.title, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	exp := time.Now().Add(720 * time.Hour).Truncate(time.Second)
+	if got := tokenExpiry(fakeToken(exp)); !got.Equal(exp) {
+		t.Errorf("tokenExpiry() = %v, want %v", got, exp)
+	}
+	if got := tokenExpiry("not-a-jwt"); time.Until(got) <= tokenRefreshMargin {
+		t.Errorf("tokenExpiry() of an unreadable token = %v, want it kept past the refresh margin", got)
+	}
+}
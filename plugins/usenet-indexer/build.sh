@@ -6,7 +6,7 @@ set -e
 echo "Building Usenet Indexer plugin..."
 
 # Build the Go binary
-go build -o usenet-indexer main.go newznab.go
+go build -o usenet-indexer .
 
 echo "✓ Plugin binary built: usenet-indexer"
 echo ""
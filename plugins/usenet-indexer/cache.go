@@ -0,0 +1,149 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Automatic searches and manual browsing often repeat the same search within
+// minutes. searchCache keeps the parsed releases of recent searches per indexer
+// so those repeats don't spend API requests. Entries expire after the
+// configured TTL, and the least recently used entries are evicted once the
+// cache is full.
+
+const (
+	configCacheTTL = configPrefix + ".cache_ttl_minutes"
+
+	defaultCacheTTLMinutes = 10
+	maxCacheEntries        = 500
+)
+
+type cacheEntry struct {
+	key      string
+	releases []Release
+	cachedAt time.Time
+}
+
+type searchCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List // Most recently used at the front
+	maxEntries int
+	now        func() time.Time
+}
+
+func newSearchCache(maxEntries int) *searchCache {
+	return &searchCache{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns the releases cached under key if they are younger than ttl
+func (c *searchCache) Get(key string, ttl time.Duration) ([]Release, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.now().Sub(entry.cachedAt) >= ttl {
+		c.remove(elem)
+		return nil, time.Time{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return append([]Release(nil), entry.releases...), entry.cachedAt, true
+}
+
+// Put caches releases under key, evicting the least recently used entry when
+// the cache is full
+func (c *searchCache) Put(key string, releases []Release) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{
+		key:      key,
+		releases: append([]Release(nil), releases...),
+		cachedAt: c.now(),
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of cached entries
+func (c *searchCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *searchCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// cacheKey identifies a search on an indexer. Parameters that don't change the
+// results are normalized: categories are sorted and the query is lowercased.
+// Limit and offset are left out, so paging through results reuses one entry.
+func cacheKey(indexerID, searchType string, params SearchParams) string {
+	categories := make([]string, 0, len(params.Categories))
+	for _, category := range params.Categories {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	parts := []string{
+		indexerID,
+		searchType,
+		"q=" + strings.ToLower(strings.Join(strings.Fields(params.Query), " ")),
+		"cat=" + strings.Join(categories, ","),
+		"tvdb=" + params.TVDBID,
+		"rid=" + params.TVRageID,
+		"imdb=" + strings.ToLower(params.IMDBID),
+		"s=" + strconv.Itoa(params.Season),
+		"e=" + strconv.Itoa(params.Episode),
+	}
+	return strings.Join(parts, "|")
+}
+
+// cacheTTL returns the configured cache TTL; zero disables caching
+func (p *UsenetIndexerPlugin) cacheTTL(ctx context.Context, sdk plugins.SDKInterface) time.Duration {
+	minutes := defaultCacheTTLMinutes
+	if val, err := sdk.ConfigGet(ctx, configCacheTTL); err == nil && val != nil {
+		switch v := val.(type) {
+		case float64:
+			minutes = int(v)
+		case string:
+			if m, err := strconv.Atoi(v); err == nil {
+				minutes = m
+			}
+		}
+	}
+	if minutes < 0 {
+		minutes = 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
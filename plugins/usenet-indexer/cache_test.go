@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheKeyNormalization(t *testing.T) {
+	base := SearchParams{
+		Query:      "The Rookie",
+		Categories: []string{"5040", "5030"},
+		TVDBID:     "350665",
+		Season:     2,
+		Episode:    3,
+		Limit:      100,
+	}
+	key := cacheKey("geek", "tvsearch", base)
+
+	same := []SearchParams{
+		// Category order doesn't matter
+		{Query: "The Rookie", Categories: []string{"5030", "5040"}, TVDBID: "350665", Season: 2, Episode: 3, Limit: 100},
+		// Limit and offset are excluded
+		{Query: "The Rookie", Categories: []string{"5040", "5030"}, TVDBID: "350665", Season: 2, Episode: 3, Limit: 50, Offset: 100},
+		// Case and extra whitespace in the query don't matter
+		{Query: "  the   rookie ", Categories: []string{"5030", " 5040"}, TVDBID: "350665", Season: 2, Episode: 3},
+	}
+	for i, params := range same {
+		if got := cacheKey("geek", "tvsearch", params); got != key {
+			t.Errorf("case %d: cacheKey() = %q, want %q", i, got, key)
+		}
+	}
+
+	different := []struct {
+		indexerID  string
+		searchType string
+		params     SearchParams
+	}{
+		{"other", "tvsearch", base},
+		{"geek", "search", base},
+		{"geek", "tvsearch", SearchParams{Query: "The Rookie", Categories: []string{"5030", "5040"}, TVDBID: "350665", Season: 2, Episode: 4}},
+		{"geek", "tvsearch", SearchParams{Query: "The Rookie", Categories: []string{"5030"}, TVDBID: "350665", Season: 2, Episode: 3}},
+	}
+	for i, tc := range different {
+		if got := cacheKey(tc.indexerID, tc.searchType, tc.params); got == key {
+			t.Errorf("case %d: cacheKey() = %q, want a different key", i, got)
+		}
+	}
+}
+
+func TestSearchCacheExpiryAndEviction(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newSearchCache(2)
+	cache.now = func() time.Time { return now }
+
+	cache.Put("a", []Release{{GUID: "1"}})
+	cache.Put("b", []Release{{GUID: "2"}})
+
+	releases, cachedAt, ok := cache.Get("a", 10*time.Minute)
+	if !ok || len(releases) != 1 || !cachedAt.Equal(now) {
+		t.Fatalf("Get(a) = %v, %v, %v, want the cached release", releases, cachedAt, ok)
+	}
+
+	// "a" was used most recently, so "b" is evicted
+	cache.Put("c", nil)
+	if _, _, ok := cache.Get("b", 10*time.Minute); ok {
+		t.Error("Get(b) hit, want it evicted")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, _, ok := cache.Get("a", 10*time.Minute); ok {
+		t.Error("Get(a) hit after the TTL, want a miss")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d after expiry, want 1", cache.Len())
+	}
+}
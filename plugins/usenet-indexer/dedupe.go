@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Indexers give the same release their own GUIDs, so a release carried by
+// three indexers would be listed three times. Releases with the same title,
+// once normalized, and the same size give or take sizeTolerance are listed
+// once, from the indexer with the best priority, with the others in
+// also_available_on to download from when it fails.
+
+// sizeTolerance is how far apart, as a fraction of the larger, the sizes of
+// the same release on different indexers can be. Indexers count the size of
+// an NZB slightly differently.
+const sizeTolerance = 0.01
+
+// normalizeReleaseTitle lowercases a release title and collapses the dots,
+// dashes, underscores and spaces indexers separate its words with
+func normalizeReleaseTitle(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// sameSize reports whether two sizes are the same release's. Unknown sizes
+// match nothing.
+func sameSize(a, b int64) bool {
+	if a <= 0 || b <= 0 {
+		return false
+	}
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) <= sizeTolerance*float64(max(a, b))
+}
+
+// releaseGroup is the releases of different indexers taken for the same
+type releaseGroup struct {
+	releases []Release
+	indexers map[string]bool
+}
+
+// mergeCrossIndexer lists the releases several indexers carry once, in the
+// place of the first of them. The release of the indexer with the best
+// priority is kept and the others become its also_available_on, in order of
+// priority. Releases of the same indexer, and usenet and torrent releases,
+// are never merged.
+func mergeCrossIndexer(releases []Release, indexers []IndexerConfig) []Release {
+	priority := make(map[string]int, len(indexers))
+	for _, indexer := range indexers {
+		priority[indexer.ID] = indexer.Priority
+	}
+
+	var groups []*releaseGroup
+	byTitle := make(map[string][]*releaseGroup)
+	for _, release := range releases {
+		key := release.Protocol + "\x00" + normalizeReleaseTitle(release.Title)
+		var group *releaseGroup
+		for _, candidate := range byTitle[key] {
+			if !candidate.indexers[release.IndexerID] && sameSize(candidate.releases[0].Size, release.Size) {
+				group = candidate
+				break
+			}
+		}
+		if group == nil {
+			group = &releaseGroup{indexers: map[string]bool{}}
+			groups = append(groups, group)
+			byTitle[key] = append(byTitle[key], group)
+		}
+		group.releases = append(group.releases, release)
+		group.indexers[release.IndexerID] = true
+	}
+
+	merged := make([]Release, 0, len(groups))
+	for _, group := range groups {
+		if len(group.releases) == 1 {
+			merged = append(merged, group.releases[0])
+			continue
+		}
+
+		// Lower priority values come first, then the order they were found in
+		members := append([]Release{}, group.releases...)
+		sort.SliceStable(members, func(i, j int) bool {
+			return priority[members[i].IndexerID] < priority[members[j].IndexerID]
+		})
+
+		canonical := members[0]
+		canonical.AlsoAvailableOn = nil
+		for _, other := range members[1:] {
+			canonical.AlsoAvailableOn = append(canonical.AlsoAvailableOn, plugins.ReleaseSource{
+				IndexerID:   other.IndexerID,
+				IndexerName: other.IndexerName,
+				GUID:        other.GUID,
+				DownloadURL: other.DownloadURL,
+			})
+		}
+		merged = append(merged, canonical)
+	}
+	return merged
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeReleaseTitle(t *testing.T) {
+	for _, title := range []string{
+		"Show.S01E01.1080p.WEB-DL.x264-GRP",
+		"show s01e01 1080p web dl x264 grp",
+		"Show_S01E01_1080p_WEB-DL_x264-GRP",
+		" Show..S01E01 - 1080p.WEB.DL.x264.GRP ",
+	} {
+		if got := normalizeReleaseTitle(title); got != "show s01e01 1080p web dl x264 grp" {
+			t.Errorf("normalizeReleaseTitle(%q) = %q", title, got)
+		}
+	}
+}
+
+func TestMergeCrossIndexer(t *testing.T) {
+	indexers := []IndexerConfig{
+		{ID: "a", Name: "Alpha", Priority: 3},
+		{ID: "b", Name: "Beta", Priority: 1},
+		{ID: "c", Name: "Gamma", Priority: 2},
+	}
+	release := func(indexer, guid, title string, size int64) Release {
+		return Release{GUID: guid, Title: title, Size: size, IndexerID: indexer, IndexerName: indexer + "-name",
+			DownloadURL: "https://" + indexer + "/get/" + guid, Protocol: "usenet"}
+	}
+	releases := []Release{
+		release("a", "a1", "Show.S01E01.1080p.WEB-DL-GRP", 1000000),
+		release("b", "b1", "show s01e01 1080p web dl grp", 1005000),
+		release("c", "c1", "Show_S01E01_1080p_WEB_DL_GRP", 995000),
+		// Too different in size to be the same release
+		release("c", "c2", "Show.S01E01.1080p.WEB-DL-GRP", 2000000),
+		// A second copy on the same indexer stays separate
+		release("a", "a2", "Show.S01E01.1080p.WEB-DL-GRP", 1000000),
+		release("a", "a3", "Other.Show.S01E01", 1000000),
+	}
+
+	merged := mergeCrossIndexer(releases, indexers)
+	if len(merged) != 4 {
+		t.Fatalf("merged into %d releases: %+v", len(merged), merged)
+	}
+
+	first := merged[0]
+	if first.GUID != "b1" {
+		t.Errorf("canonical release = %s, want the best priority indexer's", first.GUID)
+	}
+	if len(first.AlsoAvailableOn) != 2 || first.AlsoAvailableOn[0].IndexerID != "c" || first.AlsoAvailableOn[1].IndexerID != "a" {
+		t.Fatalf("also_available_on = %+v, want c then a", first.AlsoAvailableOn)
+	}
+	if source := first.AlsoAvailableOn[1]; source.GUID != "a1" || source.DownloadURL != "https://a/get/a1" || source.IndexerName != "a-name" {
+		t.Errorf("alternate source = %+v", source)
+	}
+
+	for i, guid := range []string{"c2", "a2", "a3"} {
+		if merged[i+1].GUID != guid || merged[i+1].AlsoAvailableOn != nil {
+			t.Errorf("release %d = %s %+v, want %s alone", i+1, merged[i+1].GUID, merged[i+1].AlsoAvailableOn, guid)
+		}
+	}
+}
+
+func TestMergeCrossIndexerKeepsProtocolsApart(t *testing.T) {
+	releases := []Release{
+		{GUID: "1", Title: "Movie.2024.1080p", Size: 5000, IndexerID: "a", Protocol: "usenet"},
+		{GUID: "2", Title: "Movie.2024.1080p", Size: 5000, IndexerID: "b", Protocol: "torrent"},
+		{GUID: "3", Title: "Movie.2024.1080p", Size: 0, IndexerID: "c", Protocol: "usenet"},
+	}
+	if merged := mergeCrossIndexer(releases, nil); len(merged) != 3 {
+		t.Errorf("merged = %+v, want all three", merged)
+	}
+}
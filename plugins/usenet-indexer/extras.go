@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Some private indexers want more than an API key: a second token as a query
+// parameter, a header on every request, or an API somewhere other than /api.
+// Extra values that are secret are kept in the secret store like API keys and
+// masked in responses.
+
+const configExtraSecrets = configPrefix + ".extra_secrets" // Secret: indexer ID -> extra key -> value
+
+const defaultAPIPath = "/api"
+
+// ExtraValue is a query parameter or header sent with every request to an
+// indexer
+type ExtraValue struct {
+	Value string `json:"value"`
+
+	// Secret masks the value in responses like the API key. When unset it is
+	// guessed from the name.
+	Secret *bool `json:"secret,omitempty"`
+}
+
+// secretWords mark the names of extra values that are secret unless flagged
+// otherwise
+var secretWords = []string{"key", "token", "secret", "pass", "auth", "cookie", "session", "sig"}
+
+// isSecret reports whether the value of an extra parameter or header is secret
+func (v ExtraValue) isSecret(name string) bool {
+	if v.Secret != nil {
+		return *v.Secret
+	}
+	name = strings.ToLower(name)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// extraKey names an extra value in the secret store
+func extraKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// normalizeExtras checks the API path and extra values of an indexer, adding
+// the leading slash a path may lack and canonicalizing header names
+func normalizeExtras(indexer *IndexerConfig) error {
+	if path := strings.TrimSpace(indexer.APIPath); path != "" {
+		if strings.ContainsAny(path, "?#") {
+			return fmt.Errorf("API path must not contain a query")
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		indexer.APIPath = path
+	}
+
+	params := make(map[string]ExtraValue, len(indexer.ExtraParams))
+	for name, value := range indexer.ExtraParams {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("extra parameter names must not be empty")
+		}
+		params[name] = value
+	}
+	indexer.ExtraParams = params
+
+	headers := make(map[string]ExtraValue, len(indexer.ExtraHeaders))
+	for name, value := range indexer.ExtraHeaders {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+			return fmt.Errorf("invalid extra header name %q", name)
+		}
+		if strings.ContainsAny(value.Value, "\r\n") {
+			return fmt.Errorf("extra header %s must be a single line", name)
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	indexer.ExtraHeaders = headers
+	return nil
+}
+
+// maskExtras masks the secret extra values of an indexer about to be returned
+func maskExtras(indexer *IndexerConfig) {
+	indexer.ExtraParams = maskedValues(indexer.ExtraParams)
+	indexer.ExtraHeaders = maskedValues(indexer.ExtraHeaders)
+}
+
+func maskedValues(values map[string]ExtraValue) map[string]ExtraValue {
+	if len(values) == 0 {
+		return values
+	}
+	masked := make(map[string]ExtraValue, len(values))
+	for name, value := range values {
+		if value.isSecret(name) {
+			value.Value = maskAPIKey(value.Value)
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// keepMaskedExtras puts back the secret extra values an update sent masked,
+// as returned by the API
+func keepMaskedExtras(updated *IndexerConfig, existing IndexerConfig) {
+	keepMasked(updated.ExtraParams, existing.ExtraParams)
+	keepMasked(updated.ExtraHeaders, existing.ExtraHeaders)
+}
+
+func keepMasked(updated, existing map[string]ExtraValue) {
+	for name, value := range updated {
+		old, ok := existing[name]
+		if ok && value.isSecret(name) && strings.Contains(value.Value, "*") {
+			value.Value = old.Value
+			updated[name] = value
+		}
+	}
+}
+
+// splitExtraSecrets moves the secret extra values of an indexer out of it,
+// returning them keyed by extraKey
+func splitExtraSecrets(indexer *IndexerConfig) map[string]string {
+	secrets := make(map[string]string)
+	split := func(kind string, values map[string]ExtraValue) map[string]ExtraValue {
+		if len(values) == 0 {
+			return values
+		}
+		stored := make(map[string]ExtraValue, len(values))
+		for name, value := range values {
+			if value.isSecret(name) {
+				secrets[extraKey(kind, name)] = value.Value
+				value.Value = ""
+			}
+			stored[name] = value
+		}
+		return stored
+	}
+	indexer.ExtraParams = split("param", indexer.ExtraParams)
+	indexer.ExtraHeaders = split("header", indexer.ExtraHeaders)
+	return secrets
+}
+
+// fillExtraSecrets puts the secret extra values of an indexer back into it
+func fillExtraSecrets(indexer *IndexerConfig, secrets map[string]string) {
+	fill := func(kind string, values map[string]ExtraValue) {
+		for name, value := range values {
+			if secret, ok := secrets[extraKey(kind, name)]; ok && value.isSecret(name) {
+				value.Value = secret
+				values[name] = value
+			}
+		}
+	}
+	fill("param", indexer.ExtraParams)
+	fill("header", indexer.ExtraHeaders)
+}
+
+// getExtraSecrets loads the secret extra values of all indexers. Like
+// getAPIKeys, an unreadable secret is an error so saving never drops values.
+func (p *UsenetIndexerPlugin) getExtraSecrets(ctx context.Context, sdk plugins.SDKInterface) (map[string]map[string]string, error) {
+	val, err := sdk.ConfigGetSecret(ctx, configExtraSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load indexer extra values: %w", err)
+	}
+
+	secrets := make(map[string]map[string]string)
+	if val == nil {
+		return secrets, nil
+	}
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid indexer extra values: %w", err)
+	}
+	return secrets, nil
+}
+
+// extraParams returns the extra query parameters of an indexer
+func (indexer IndexerConfig) extraParams() url.Values {
+	if len(indexer.ExtraParams) == 0 {
+		return nil
+	}
+	params := make(url.Values, len(indexer.ExtraParams))
+	for name, value := range indexer.ExtraParams {
+		params.Set(name, value.Value)
+	}
+	return params
+}
+
+// extraHeaders returns the extra headers of an indexer
+func (indexer IndexerConfig) extraHeaders() http.Header {
+	if len(indexer.ExtraHeaders) == 0 {
+		return nil
+	}
+	headers := make(http.Header, len(indexer.ExtraHeaders))
+	for name, value := range indexer.ExtraHeaders {
+		headers.Set(name, value.Value)
+	}
+	return headers
+}
@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestExtraValueIsSecret(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name  string
+		value ExtraValue
+		want  bool
+	}{
+		{"uid", ExtraValue{}, false},
+		{"X-Api-Token", ExtraValue{}, true},
+		{"Cookie", ExtraValue{}, true},
+		{"uid", ExtraValue{Secret: &yes}, true},
+		{"passthrough", ExtraValue{Secret: &no}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.value.isSecret(tt.name); got != tt.want {
+			t.Errorf("isSecret(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeExtras(t *testing.T) {
+	indexer := IndexerConfig{
+		APIPath:      "newznab/api",
+		ExtraParams:  map[string]ExtraValue{" uid ": {Value: "42"}},
+		ExtraHeaders: map[string]ExtraValue{"x-api-token": {Value: "abc"}},
+	}
+	if err := normalizeExtras(&indexer); err != nil {
+		t.Fatal(err)
+	}
+	if indexer.APIPath != "/newznab/api" {
+		t.Errorf("APIPath = %q", indexer.APIPath)
+	}
+	if _, ok := indexer.ExtraParams["uid"]; !ok {
+		t.Errorf("ExtraParams = %v", indexer.ExtraParams)
+	}
+	if _, ok := indexer.ExtraHeaders["X-Api-Token"]; !ok {
+		t.Errorf("ExtraHeaders = %v", indexer.ExtraHeaders)
+	}
+
+	invalid := []IndexerConfig{
+		{APIPath: "/api?t=caps"},
+		{ExtraParams: map[string]ExtraValue{" ": {Value: "x"}}},
+		{ExtraHeaders: map[string]ExtraValue{"Bad Header": {Value: "x"}}},
+		{ExtraHeaders: map[string]ExtraValue{"X-Token": {Value: "a\r\nb"}}},
+	}
+	for _, indexer := range invalid {
+		if err := normalizeExtras(&indexer); err == nil {
+			t.Errorf("normalizeExtras(%+v) succeeded", indexer)
+		}
+	}
+}
+
+func TestExtraSecretsRoundTrip(t *testing.T) {
+	indexer := IndexerConfig{
+		ExtraParams:  map[string]ExtraValue{"uid": {Value: "42"}, "token": {Value: "secret-token"}},
+		ExtraHeaders: map[string]ExtraValue{"Cookie": {Value: "session=abcdef"}},
+	}
+
+	stored := indexer
+	secrets := splitExtraSecrets(&stored)
+	if stored.ExtraParams["token"].Value != "" || stored.ExtraHeaders["Cookie"].Value != "" {
+		t.Fatalf("secret values left in stored config: %+v", stored)
+	}
+	if stored.ExtraParams["uid"].Value != "42" {
+		t.Errorf("plain value moved out of stored config")
+	}
+	if indexer.ExtraParams["token"].Value != "secret-token" {
+		t.Errorf("splitting changed the original config")
+	}
+
+	fillExtraSecrets(&stored, secrets)
+	if stored.ExtraParams["token"].Value != "secret-token" || stored.ExtraHeaders["Cookie"].Value != "session=abcdef" {
+		t.Errorf("secrets not filled back: %+v", stored)
+	}
+
+	masked := indexer
+	maskExtras(&masked)
+	if masked.ExtraParams["token"].Value == "secret-token" || masked.ExtraParams["uid"].Value != "42" {
+		t.Errorf("masked = %+v", masked.ExtraParams)
+	}
+
+	keepMaskedExtras(&masked, indexer)
+	if masked.ExtraParams["token"].Value != "secret-token" || masked.ExtraHeaders["Cookie"].Value != "session=abcdef" {
+		t.Errorf("masked values not kept: %+v", masked)
+	}
+}
+
+func TestIndexerExtraHeaders(t *testing.T) {
+	indexer := IndexerConfig{ExtraHeaders: map[string]ExtraValue{"X-Api-Token": {Value: "abc"}}}
+	if got := indexer.extraHeaders().Get("X-Api-Token"); got != "abc" {
+		t.Errorf("extraHeaders() = %q", got)
+	}
+	if headers := (IndexerConfig{}).extraHeaders(); headers != nil {
+		t.Errorf("extraHeaders() of no headers is not nil")
+	}
+}
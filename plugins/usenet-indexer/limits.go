@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// Many indexers only allow a fixed number of API hits per day. UsageTracker
+// counts the requests made to each indexer over a rolling 24 hour window, in
+// hourly buckets so the stored state stays small, and refuses requests once an
+// indexer's limit is used up. The counts are persisted in the config store so
+// a restart doesn't hand out a fresh allowance.
+
+const (
+	configUsage = configPrefix + ".api_usage"
+
+	usageWindow = 24 * time.Hour
+)
+
+// ErrRequestLimitReached is returned when an indexer's API limit is used up,
+// either by our own count or because the indexer said so
+var ErrRequestLimitReached = errors.New("request limit reached")
+
+// IndexerUsage is the request accounting of a single indexer
+type IndexerUsage struct {
+	Hours         map[int64]int `json:"hours"`                     // Requests per hour (Unix hour) within the window
+	Limit         int           `json:"limit,omitempty"`           // API limit reported by the indexer
+	LimitedUntil  *time.Time    `json:"limited_until,omitempty"`   // Set when the indexer rejected us for hitting its limit
+	CapsCheckedAt *time.Time    `json:"caps_checked_at,omitempty"` // Last time the caps were read for the limit
+}
+
+// UsageStatus is the current usage of an indexer, as shown in the UI
+type UsageStatus struct {
+	Used         int        `json:"used"`
+	Limit        int        `json:"limit,omitempty"` // 0 when unlimited or unknown
+	LimitedUntil *time.Time `json:"limited_until,omitempty"`
+}
+
+// SkippedIndexer is an indexer left out of a search
+type SkippedIndexer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// UsageTracker tracks API requests per indexer
+type UsageTracker struct {
+	mu     sync.Mutex
+	usage  map[string]*IndexerUsage
+	loaded bool
+	dirty  bool
+	now    func() time.Time
+}
+
+// NewUsageTracker creates an empty usage tracker
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{
+		usage: make(map[string]*IndexerUsage),
+		now:   time.Now,
+	}
+}
+
+// Load reads the persisted usage the first time it is called
+func (t *UsageTracker) Load(ctx context.Context, sdk plugins.SDKInterface) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	val, err := sdk.ConfigGet(ctx, configUsage)
+	if err != nil || val == nil {
+		return
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	var stored map[string]*IndexerUsage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return
+	}
+	for id, usage := range stored {
+		if usage.Hours == nil {
+			usage.Hours = make(map[int64]int)
+		}
+		t.usage[id] = usage
+	}
+}
+
+// Save persists the usage if it changed since the last save
+func (t *UsageTracker) Save(ctx context.Context, sdk plugins.SDKInterface) error {
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	for _, usage := range t.usage {
+		t.prune(usage)
+	}
+	data, err := json.Marshal(t.usage)
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return sdk.ConfigSet(ctx, configUsage, json.RawMessage(data))
+}
+
+// Acquire counts a request to an indexer, or returns ErrRequestLimitReached
+// when the indexer has no requests left in the window. limit overrides the
+// limit reported by the indexer when it is above zero.
+func (t *UsageTracker) Acquire(indexerID string, limit int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	now := t.now()
+
+	if usage.LimitedUntil != nil {
+		if now.Before(*usage.LimitedUntil) {
+			return fmt.Errorf("%w until %s", ErrRequestLimitReached, usage.LimitedUntil.Format(time.RFC3339))
+		}
+		usage.LimitedUntil = nil
+	}
+
+	if limit <= 0 {
+		limit = usage.Limit
+	}
+	if used := t.used(usage); limit > 0 && used >= limit {
+		return fmt.Errorf("%w (%d/%d requests in the last 24h)", ErrRequestLimitReached, used, limit)
+	}
+
+	usage.Hours[now.Unix()/3600]++
+	t.dirty = true
+	return nil
+}
+
+// Sync reconciles our count with the usage an indexer reports, which also
+// counts requests made by other applications using the same API key
+func (t *UsageTracker) Sync(indexerID string, current, max int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	if max > 0 && usage.Limit != max {
+		usage.Limit = max
+		t.dirty = true
+	}
+	if used := t.used(usage); current > used {
+		usage.Hours[t.now().Unix()/3600] += current - used
+		t.dirty = true
+	}
+}
+
+// SetCapsLimit records the API limit read from an indexer's caps
+func (t *UsageTracker) SetCapsLimit(indexerID string, max int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	now := t.now()
+	usage.CapsCheckedAt = &now
+	if max > 0 {
+		usage.Limit = max
+	}
+	t.dirty = true
+}
+
+// NeedsCaps reports whether an indexer's caps haven't been read in the window
+func (t *UsageTracker) NeedsCaps(indexerID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	return usage.CapsCheckedAt == nil || t.now().Sub(*usage.CapsCheckedAt) > usageWindow
+}
+
+// MarkLimited skips an indexer for the rest of the window after it rejected a
+// request for hitting its limit
+func (t *UsageTracker) MarkLimited(indexerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	t.prune(usage)
+
+	// The limit frees up as the oldest requests leave the window
+	until := t.now().Add(usageWindow)
+	if len(usage.Hours) > 0 {
+		hours := make([]int64, 0, len(usage.Hours))
+		for hour := range usage.Hours {
+			hours = append(hours, hour)
+		}
+		sort.Slice(hours, func(i, j int) bool { return hours[i] < hours[j] })
+		until = time.Unix((hours[0]+1)*3600, 0).Add(usageWindow)
+	}
+	usage.LimitedUntil = &until
+	t.dirty = true
+}
+
+// Status returns the current usage of an indexer
+func (t *UsageTracker) Status(indexerID string, limit int) UsageStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(indexerID)
+	if limit <= 0 {
+		limit = usage.Limit
+	}
+	status := UsageStatus{Used: t.used(usage), Limit: limit}
+	if usage.LimitedUntil != nil && t.now().Before(*usage.LimitedUntil) {
+		status.LimitedUntil = usage.LimitedUntil
+	}
+	return status
+}
+
+// Remove forgets the usage of a deleted indexer
+func (t *UsageTracker) Remove(indexerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.usage[indexerID]; ok {
+		delete(t.usage, indexerID)
+		t.dirty = true
+	}
+}
+
+func (t *UsageTracker) get(indexerID string) *IndexerUsage {
+	usage, ok := t.usage[indexerID]
+	if !ok {
+		usage = &IndexerUsage{Hours: make(map[int64]int)}
+		t.usage[indexerID] = usage
+	}
+	return usage
+}
+
+// used counts the requests in the window
+func (t *UsageTracker) used(usage *IndexerUsage) int {
+	t.prune(usage)
+	total := 0
+	for _, count := range usage.Hours {
+		total += count
+	}
+	return total
+}
+
+// prune drops hourly buckets that have left the window
+func (t *UsageTracker) prune(usage *IndexerUsage) {
+	oldest := t.now().Add(-usageWindow).Unix() / 3600
+	for hour := range usage.Hours {
+		if hour <= oldest {
+			delete(usage.Hours, hour)
+		}
+	}
+}
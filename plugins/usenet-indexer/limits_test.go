@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUsageTrackerLimit(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	tracker := NewUsageTracker()
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := tracker.Acquire("geek", 3); err != nil {
+			t.Fatalf("Acquire() #%d error = %v", i+1, err)
+		}
+	}
+	if err := tracker.Acquire("geek", 3); !errors.Is(err, ErrRequestLimitReached) {
+		t.Fatalf("Acquire() over the limit error = %v, want ErrRequestLimitReached", err)
+	}
+	if status := tracker.Status("geek", 3); status.Used != 3 || status.Limit != 3 {
+		t.Errorf("Status() = %+v, want 3/3", status)
+	}
+
+	// Requests leave the window after 24 hours
+	now = now.Add(25 * time.Hour)
+	if err := tracker.Acquire("geek", 3); err != nil {
+		t.Errorf("Acquire() after the window error = %v", err)
+	}
+}
+
+func TestUsageTrackerSyncAndMarkLimited(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	tracker := NewUsageTracker()
+	tracker.now = func() time.Time { return now }
+
+	// The indexer's own count includes requests made by other applications
+	tracker.Sync("geek", 87, 100)
+	if status := tracker.Status("geek", 0); status.Used != 87 || status.Limit != 100 {
+		t.Errorf("Status() = %+v, want 87/100", status)
+	}
+
+	tracker.MarkLimited("geek")
+	if err := tracker.Acquire("geek", 0); !errors.Is(err, ErrRequestLimitReached) {
+		t.Fatalf("Acquire() while limited error = %v, want ErrRequestLimitReached", err)
+	}
+	status := tracker.Status("geek", 0)
+	if want := time.Date(2024, 6, 2, 13, 0, 0, 0, time.UTC); status.LimitedUntil == nil || !status.LimitedUntil.Equal(want) {
+		t.Errorf("LimitedUntil = %v, want %v", status.LimitedUntil, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	nzErr := parseError([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<error code="500" description="Request limit reached"/>`))
+	if nzErr == nil {
+		t.Fatal("parseError() = nil, want an error")
+	}
+	if !errors.Is(nzErr, ErrRequestLimitReached) {
+		t.Errorf("parseError() = %v, want it to match ErrRequestLimitReached", nzErr)
+	}
+
+	if nzErr := parseError([]byte(`<?xml version="1.0"?><rss><channel></channel></rss>`)); nzErr != nil {
+		t.Errorf("parseError() on results = %v, want nil", nzErr)
+	}
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/blakestevenson/nimbus/internal/plugins"
@@ -17,12 +18,21 @@ import (
 )
 
 // UsenetIndexerPlugin implements the MediaSuitePlugin interface
-type UsenetIndexerPlugin struct{}
+type UsenetIndexerPlugin struct {
+	usage *UsageTracker
+	cache *searchCache
+}
 
 // Configuration keys
 const (
 	configPrefix   = "plugins.usenet-indexer"
 	configIndexers = configPrefix + ".indexers"
+	configAPIKeys  = configPrefix + ".api_keys" // Secret: indexer ID -> API key
+
+	// configSchemaVersion is the version of the shape of the stored config.
+	// Version 1 always stores indexers as a JSON list, where older versions
+	// sometimes stored a string holding one.
+	configSchemaVersion = 1
 )
 
 // IndexerConfig represents a single indexer configuration
@@ -35,6 +45,18 @@ type IndexerConfig struct {
 	Priority        int      `json:"priority"`
 	TVCategories    []string `json:"tv_categories"`
 	MovieCategories []string `json:"movie_categories"`
+	APILimit        int      `json:"api_limit,omitempty"` // Requests per 24h, overrides the limit the indexer reports
+	Protocol        string   `json:"protocol,omitempty"`  // "newznab" (default) or "torznab"
+
+	APIPath      string                `json:"api_path,omitempty"`      // Path of the API below the URL, default /api
+	ExtraParams  map[string]ExtraValue `json:"extra_params,omitempty"`  // Query parameters added to every request
+	ExtraHeaders map[string]ExtraValue `json:"extra_headers,omitempty"` // Headers sent with every request
+}
+
+// indexerView is an indexer as returned by the API, with its current usage
+type indexerView struct {
+	IndexerConfig
+	Usage UsageStatus `json:"usage"`
 }
 
 // Metadata returns plugin metadata
@@ -50,36 +72,42 @@ func (p *UsenetIndexerPlugin) Metadata(ctx context.Context) (*plugins.PluginMeta
 
 // APIRoutes returns the HTTP routes this plugin provides
 func (p *UsenetIndexerPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDescriptor, error) {
+	// Indexer management is admin-only; any user can search
 	return []plugins.RouteDescriptor{
 		// Indexer management
 		{
 			Method: "GET",
 			Path:   "/api/plugins/usenet-indexer/indexers",
 			Auth:   "session",
+			Role:   plugins.ScopeAdmin,
 			Tag:    "",
 		},
 		{
 			Method: "POST",
 			Path:   "/api/plugins/usenet-indexer/indexers",
 			Auth:   "session",
+			Role:   plugins.ScopeAdmin,
 			Tag:    "",
 		},
 		{
 			Method: "PUT",
 			Path:   "/api/plugins/usenet-indexer/indexers/{id}",
 			Auth:   "session",
+			Role:   plugins.ScopeAdmin,
 			Tag:    "",
 		},
 		{
 			Method: "DELETE",
 			Path:   "/api/plugins/usenet-indexer/indexers/{id}",
 			Auth:   "session",
+			Role:   plugins.ScopeAdmin,
 			Tag:    "",
 		},
 		{
 			Method: "POST",
 			Path:   "/api/plugins/usenet-indexer/indexers/{id}/test",
 			Auth:   "session",
+			Role:   plugins.ScopeAdmin,
 			Tag:    "",
 		},
 		// Search endpoints
@@ -112,42 +140,38 @@ func (p *UsenetIndexerPlugin) APIRoutes(ctx context.Context) ([]plugins.RouteDes
 
 // HandleAPI handles HTTP requests for this plugin's routes
 func (p *UsenetIndexerPlugin) HandleAPI(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	// Handle indexer management endpoints
-	if strings.HasPrefix(req.Path, "/api/plugins/usenet-indexer/indexers") {
-		if req.Path == "/api/plugins/usenet-indexer/indexers" {
-			if req.Method == "GET" {
-				return p.handleListIndexers(ctx, req)
-			}
-			return p.handleCreateIndexer(ctx, req)
-		}
+	// The host matched the request against APIRoutes, so dispatch on the
+	// route pattern and take IDs from its placeholders
+	route := strings.TrimPrefix(req.Route, "/api/plugins/usenet-indexer")
 
-		// Extract indexer ID from path
-		parts := strings.Split(req.Path, "/")
-		if len(parts) >= 6 {
-			indexerID := parts[5]
-
-			if len(parts) == 7 && parts[6] == "test" {
-				return p.handleTestIndexer(ctx, req, indexerID)
-			}
-
-			if req.Method == "PUT" {
-				return p.handleUpdateIndexer(ctx, req, indexerID)
-			}
-			if req.Method == "DELETE" {
-				return p.handleDeleteIndexer(ctx, req, indexerID)
-			}
+	// Handle indexer management endpoints
+	if route == "/indexers" || strings.HasPrefix(route, "/indexers/") {
+		if resp := plugins.RequireScope(req, plugins.ScopeAdmin); resp != nil {
+			return resp, nil
 		}
 	}
 
+	indexerID := req.PathParams["id"]
+	switch req.Method + " " + route {
+	case "GET /indexers":
+		return p.handleListIndexers(ctx, req)
+	case "POST /indexers":
+		return p.handleCreateIndexer(ctx, req)
+	case "PUT /indexers/{id}":
+		return p.handleUpdateIndexer(ctx, req, indexerID)
+	case "DELETE /indexers/{id}":
+		return p.handleDeleteIndexer(ctx, req, indexerID)
+	case "POST /indexers/{id}/test":
+		return p.handleTestIndexer(ctx, req, indexerID)
+
 	// Handle search endpoints
-	switch req.Path {
-	case "/api/plugins/usenet-indexer/search":
+	case "GET /search":
 		return p.handleSearch(ctx, req)
-	case "/api/plugins/usenet-indexer/search/tv":
+	case "GET /search/tv":
 		return p.handleSearchTV(ctx, req)
-	case "/api/plugins/usenet-indexer/search/movie":
+	case "GET /search/movie":
 		return p.handleSearchMovie(ctx, req)
-	case "/api/plugins/usenet-indexer/rss":
+	case "GET /rss":
 		return p.handleRSS(ctx, req)
 	default:
 		return &plugins.PluginHTTPResponse{
@@ -175,12 +199,20 @@ func (p *UsenetIndexerPlugin) handleListIndexers(ctx context.Context, req *plugi
 		indexers = []IndexerConfig{}
 	}
 
+	p.usage.Load(ctx, req.SDK)
+
 	// Mask API keys
-	for i := range indexers {
-		indexers[i].APIKey = maskAPIKey(indexers[i].APIKey)
+	views := make([]indexerView, 0, len(indexers))
+	for _, indexer := range indexers {
+		indexer.APIKey = maskAPIKey(indexer.APIKey)
+		maskExtras(&indexer)
+		views = append(views, indexerView{
+			IndexerConfig: indexer,
+			Usage:         p.usage.Status(indexer.ID, indexer.APILimit),
+		})
 	}
 
-	return jsonResponse(http.StatusOK, map[string]interface{}{"indexers": indexers})
+	return jsonResponse(http.StatusOK, map[string]interface{}{"indexers": views})
 }
 
 func (p *UsenetIndexerPlugin) handleCreateIndexer(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
@@ -203,6 +235,12 @@ func (p *UsenetIndexerPlugin) handleCreateIndexer(ctx context.Context, req *plug
 	if indexer.APIKey == "" {
 		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "API key is required"})
 	}
+	if !validProtocol(indexer.Protocol) {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Protocol must be newznab or torznab"})
+	}
+	if err := normalizeExtras(&indexer); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	// Generate ID if not provided
 	if indexer.ID == "" {
@@ -234,6 +272,7 @@ func (p *UsenetIndexerPlugin) handleCreateIndexer(ctx context.Context, req *plug
 
 	// Mask API key in response
 	indexer.APIKey = maskAPIKey(indexer.APIKey)
+	maskExtras(&indexer)
 	return jsonResponse(http.StatusCreated, indexer)
 }
 
@@ -246,6 +285,12 @@ func (p *UsenetIndexerPlugin) handleUpdateIndexer(ctx context.Context, req *plug
 	if err := json.Unmarshal(req.Body, &updatedIndexer); err != nil {
 		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
 	}
+	if !validProtocol(updatedIndexer.Protocol) {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "Protocol must be newznab or torznab"})
+	}
+	if err := normalizeExtras(&updatedIndexer); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	indexers, err := p.getIndexers(ctx, req.SDK)
 	if err != nil {
@@ -259,6 +304,7 @@ func (p *UsenetIndexerPlugin) handleUpdateIndexer(ctx context.Context, req *plug
 			if strings.Contains(updatedIndexer.APIKey, "*") {
 				updatedIndexer.APIKey = indexer.APIKey
 			}
+			keepMaskedExtras(&updatedIndexer, indexer)
 
 			updatedIndexer.ID = indexerID // Ensure ID doesn't change
 			indexers[i] = updatedIndexer
@@ -277,6 +323,7 @@ func (p *UsenetIndexerPlugin) handleUpdateIndexer(ctx context.Context, req *plug
 
 	// Mask API key in response
 	updatedIndexer.APIKey = maskAPIKey(updatedIndexer.APIKey)
+	maskExtras(&updatedIndexer)
 	return jsonResponse(http.StatusOK, updatedIndexer)
 }
 
@@ -308,6 +355,10 @@ func (p *UsenetIndexerPlugin) handleDeleteIndexer(ctx context.Context, req *plug
 		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
+	p.usage.Load(ctx, req.SDK)
+	p.usage.Remove(indexerID)
+	p.saveUsage(ctx, req.SDK)
+
 	return jsonResponse(http.StatusOK, map[string]string{"message": "Indexer deleted"})
 }
 
@@ -334,53 +385,40 @@ func (p *UsenetIndexerPlugin) handleTestIndexer(ctx context.Context, req *plugin
 	}
 
 	// Test connection using the Newznab client
-	client := NewNewznabClient(indexer.URL, indexer.APIKey)
-	if err := client.TestConnection(); err != nil {
+	p.usage.Load(ctx, req.SDK)
+	if err := p.refreshCaps(ctx, p.newClient(*indexer)); err != nil {
 		return jsonResponse(http.StatusOK, map[string]interface{}{
 			"success": false,
 			"error":   fmt.Sprintf("Connection failed: %v", err),
 		})
 	}
+	p.saveUsage(ctx, req.SDK)
 
 	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Connection successful",
+		"success":  true,
+		"message":  "Connection successful",
+		"protocol": p.newClient(*indexer).Protocol,
+		"usage":    p.usage.Status(indexer.ID, indexer.APILimit),
 	})
 }
 
 // Search Handlers
 
 func (p *UsenetIndexerPlugin) handleSearch(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	if req.SDK == nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
-	}
-
-	indexers, err := p.getEnabledIndexers(ctx, req.SDK)
-	if err != nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-
-	if len(indexers) == 0 {
-		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "No enabled indexers configured"})
-	}
-
-	params := p.parseSearchParams(req.Query)
-
-	results, err := p.searchMultipleIndexers(ctx, indexers, params, func(client *NewznabClient, params SearchParams) ([]Release, error) {
-		return client.Search(params)
-	})
+	return p.runSearch(ctx, req, generalSearch)
+}
 
-	if err != nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
+func (p *UsenetIndexerPlugin) handleSearchTV(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	return p.runSearch(ctx, req, tvSearch)
+}
 
-	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"releases": results,
-		"count":    len(results),
-	})
+func (p *UsenetIndexerPlugin) handleSearchMovie(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+	return p.runSearch(ctx, req, movieSearch)
 }
 
-func (p *UsenetIndexerPlugin) handleSearchTV(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
+// runSearch runs a search of the given kind across all enabled indexers. The
+// fresh=1 query parameter bypasses the result cache.
+func (p *UsenetIndexerPlugin) runSearch(ctx context.Context, req *plugins.PluginHTTPRequest, kind searchKind) (*plugins.PluginHTTPResponse, error) {
 	if req.SDK == nil {
 		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
 	}
@@ -395,49 +433,31 @@ func (p *UsenetIndexerPlugin) handleSearchTV(ctx context.Context, req *plugins.P
 	}
 
 	params := p.parseSearchParams(req.Query)
-
-	results, err := p.searchMultipleIndexers(ctx, indexers, params, func(client *NewznabClient, params SearchParams) ([]Release, error) {
-		return client.SearchTV(params)
-	})
-
-	if err != nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-
-	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"releases": results,
-		"count":    len(results),
-	})
-}
-
-func (p *UsenetIndexerPlugin) handleSearchMovie(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
-	if req.SDK == nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": "SDK not available"})
+	fresh := false
+	if f := req.Query["fresh"]; len(f) > 0 {
+		fresh = f[0] == "1" || f[0] == "true"
 	}
 
-	indexers, err := p.getEnabledIndexers(ctx, req.SDK)
+	result, err := p.searchMultipleIndexers(ctx, req.SDK, indexers, params, kind, fresh)
 	if err != nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return jsonResponse(http.StatusInternalServerError, map[string]interface{}{
+			"error":              err.Error(),
+			"skipped_indexers":   result.Skipped,
+			"timed_out_indexers": result.TimedOut,
+		})
 	}
 
-	if len(indexers) == 0 {
-		return jsonResponse(http.StatusBadRequest, map[string]string{"error": "No enabled indexers configured"})
+	response := map[string]interface{}{
+		"releases":           result.Releases,
+		"count":              len(result.Releases),
+		"skipped_indexers":   result.Skipped,
+		"timed_out_indexers": result.TimedOut,
+		"cached":             result.CachedAt != nil,
 	}
-
-	params := p.parseSearchParams(req.Query)
-
-	results, err := p.searchMultipleIndexers(ctx, indexers, params, func(client *NewznabClient, params SearchParams) ([]Release, error) {
-		return client.SearchMovie(params)
-	})
-
-	if err != nil {
-		return jsonResponse(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if result.CachedAt != nil {
+		response["cached_at"] = result.CachedAt
 	}
-
-	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"releases": results,
-		"count":    len(results),
-	})
+	return jsonResponse(http.StatusOK, response)
 }
 
 func (p *UsenetIndexerPlugin) handleRSS(ctx context.Context, req *plugins.PluginHTTPRequest) (*plugins.PluginHTTPResponse, error) {
@@ -467,41 +487,54 @@ func (p *UsenetIndexerPlugin) handleRSS(ctx context.Context, req *plugins.Plugin
 		}
 	}
 
-	// Aggregate RSS feeds from all enabled indexers
-	type indexerResult struct {
-		releases []Release
-		err      error
-	}
+	p.usage.Load(ctx, req.SDK)
+	defer p.saveUsage(ctx, req.SDK)
+	timeouts := p.searchTimeouts(ctx, req.SDK)
 
-	resultChan := make(chan indexerResult, len(indexers))
-	var wg sync.WaitGroup
-
-	for _, indexer := range indexers {
-		wg.Add(1)
-		go func(idx IndexerConfig) {
-			defer wg.Done()
-
-			client := NewNewznabClient(idx.URL, idx.APIKey)
-			releases, err := client.GetRSSFeed(categories, limit)
-
-			resultChan <- indexerResult{releases: releases, err: err}
-		}(indexer)
-	}
-
-	// Wait for all requests to complete
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
+	// Aggregate RSS feeds from all enabled indexers, up to the deadline
+	feedCtx, cancel := context.WithTimeout(ctx, timeouts.deadline)
+	defer cancel()
+	calls, timedOut := queryIndexers(feedCtx, indexers, timeouts.indexer, func(callCtx context.Context, idx IndexerConfig) ([]Release, error) {
+		return p.newClient(idx).GetRSSFeed(callCtx, categories, limit)
+	})
 
-	// Collect results
+	// Collect results. Each indexer's outcome is reported so callers can track
+	// the feeds separately.
 	allReleases := []Release{}
-	for result := range resultChan {
-		if result.err != nil {
-			fmt.Fprintf(os.Stderr, "RSS feed error from indexer: %v\n", result.err)
+	skipped := []SkippedIndexer{}
+	feeds := []rssIndexerResult{}
+	for _, indexer := range timedOut {
+		fmt.Fprintf(os.Stderr, "RSS feed of indexer %s timed out: %s\n", indexer.Name, indexer.Reason)
+		feeds = append(feeds, rssIndexerResult{ID: indexer.ID, Name: indexer.Name, Error: indexer.Reason, TimedOut: true})
+	}
+	for _, call := range calls {
+		feed := rssIndexerResult{ID: call.indexer.ID, Name: call.indexer.Name}
+		if errors.Is(call.err, ErrRequestLimitReached) {
+			skipped = append(skipped, SkippedIndexer{ID: call.indexer.ID, Name: call.indexer.Name, Reason: call.err.Error()})
+			feed.Error = call.err.Error()
+			feeds = append(feeds, feed)
 			continue
 		}
-		allReleases = append(allReleases, result.releases...)
+		if call.err != nil {
+			fmt.Fprintf(os.Stderr, "RSS feed error from indexer: %v\n", call.err)
+			feed.Error = call.err.Error()
+			feeds = append(feeds, feed)
+			continue
+		}
+
+		releases := call.value
+		for i := range releases {
+			if releases[i].Attributes == nil {
+				releases[i].Attributes = map[string]string{}
+			}
+			releases[i].Attributes["indexer"] = call.indexer.Name
+			releases[i].Attributes["indexer_id"] = call.indexer.ID
+			releases[i].IndexerID = call.indexer.ID
+			releases[i].IndexerName = call.indexer.Name
+		}
+		feed.Count = len(releases)
+		feeds = append(feeds, feed)
+		allReleases = append(allReleases, releases...)
 	}
 
 	// Sort by publish date (newest first)
@@ -515,19 +548,32 @@ func (p *UsenetIndexerPlugin) handleRSS(ctx context.Context, req *plugins.Plugin
 	}
 
 	return jsonResponse(http.StatusOK, map[string]interface{}{
-		"releases": allReleases,
-		"count":    len(allReleases),
+		"releases":           allReleases,
+		"count":              len(allReleases),
+		"skipped_indexers":   skipped,
+		"timed_out_indexers": timedOut,
+		"indexers":           feeds,
 	})
 }
 
+// rssIndexerResult is the outcome of fetching one indexer's RSS feed
+type rssIndexerResult struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Error    string `json:"error,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
 // UIManifest returns the UI configuration for this plugin
 func (p *UsenetIndexerPlugin) UIManifest(ctx context.Context) (*plugins.UIManifest, error) {
 	return &plugins.UIManifest{
 		NavItems: []plugins.UINavItem{},
 		Routes:   []plugins.UIRoute{},
 		ConfigSection: &plugins.ConfigSection{
-			Title:       "Usenet Indexer Settings",
-			Description: "Configure Newznab-compatible Usenet indexers for searching releases",
+			Title:         "Usenet Indexer Settings",
+			Description:   "Configure Newznab-compatible Usenet indexers for searching releases",
+			SchemaVersion: configSchemaVersion,
 			Fields: []plugins.ConfigField{
 				{
 					Key:          configIndexers,
@@ -537,11 +583,53 @@ func (p *UsenetIndexerPlugin) UIManifest(ctx context.Context) (*plugins.UIManife
 					DefaultValue: "[]",
 					Required:     false,
 				},
+				{
+					Key:          configCacheTTL,
+					Label:        "Search Cache (minutes)",
+					Description:  "How long search results are reused before indexers are queried again (0 disables caching)",
+					Type:         "number",
+					DefaultValue: strconv.Itoa(defaultCacheTTLMinutes),
+					Required:     false,
+				},
+				{
+					Key:          configSearchDeadline,
+					Label:        "Search Deadline (seconds)",
+					Description:  "How long a search waits for all indexers before returning the results that arrived",
+					Type:         "number",
+					DefaultValue: strconv.Itoa(defaultSearchDeadlineSeconds),
+					Required:     false,
+				},
+				{
+					Key:          configIndexerTimeout,
+					Label:        "Indexer Timeout (seconds)",
+					Description:  "How long a single indexer may take to answer before it is left out of the results",
+					Type:         "number",
+					DefaultValue: strconv.Itoa(defaultIndexerTimeoutSeconds),
+					Required:     false,
+				},
 			},
 		},
 	}, nil
 }
 
+// MigrateConfig brings config stored by older versions of the plugin up to
+// configSchemaVersion
+func (p *UsenetIndexerPlugin) MigrateConfig(ctx context.Context, fromVersion int, config map[string]interface{}) (map[string]interface{}, error) {
+	changes := make(map[string]interface{})
+	if fromVersion < 1 {
+		if raw, ok := config[configIndexers].(string); ok {
+			indexers := []interface{}{}
+			if strings.TrimSpace(raw) != "" {
+				if err := json.Unmarshal([]byte(raw), &indexers); err != nil {
+					return nil, fmt.Errorf("stored indexers are not a JSON list: %w", err)
+				}
+			}
+			changes[configIndexers] = indexers
+		}
+	}
+	return changes, nil
+}
+
 // HandleEvent handles system events (not implemented)
 func (p *UsenetIndexerPlugin) HandleEvent(ctx context.Context, evt plugins.Event) error {
 	return nil
@@ -554,15 +642,69 @@ func (p *UsenetIndexerPlugin) IsIndexer(ctx context.Context) (bool, error) {
 
 // Search implements the unified indexer search interface
 func (p *UsenetIndexerPlugin) Search(ctx context.Context, req *plugins.IndexerSearchRequest) (*plugins.IndexerSearchResponse, error) {
-	// Note: This method doesn't have direct SDK access like HandleAPI does
-	// For now, we return an error indicating this should be called via the HTTP API
-	// In the future, we could pass SDK through the RPC call
+	if req.SDK == nil {
+		return nil, fmt.Errorf("SDK not available")
+	}
+
+	indexers, err := p.getEnabledIndexers(ctx, req.SDK)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexers) == 0 {
+		return nil, fmt.Errorf("no enabled indexers configured")
+	}
+
+	kind := generalSearch
+	switch req.Type {
+	case "tv":
+		kind = tvSearch
+	case "movie":
+		kind = movieSearch
+	}
+
+	params := SearchParams{
+		Query:      req.Query,
+		Categories: req.Categories,
+		TVDBID:     req.TVDBID,
+		TVRageID:   req.TVRageID,
+		IMDBID:     req.IMDBID,
+		Season:     req.Season,
+		Episode:    req.Episode,
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+		MinSeeders: req.MinimumSeeders,
+	}
+
+	result, err := p.searchMultipleIndexers(ctx, req.SDK, indexers, params, kind, req.Fresh)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]plugins.IndexerRelease, len(result.Releases))
+	for i, release := range result.Releases {
+		releases[i] = plugins.IndexerRelease{
+			GUID:            release.GUID,
+			Title:           release.Title,
+			Link:            release.Link,
+			Comments:        release.Comments,
+			PublishDate:     release.PublishDate,
+			Category:        release.Category,
+			Size:            release.Size,
+			DownloadURL:     release.DownloadURL,
+			Description:     release.Description,
+			Attributes:      release.Attributes,
+			IndexerID:       release.IndexerID,
+			IndexerName:     release.IndexerName,
+			AlsoAvailableOn: release.AlsoAvailableOn,
+		}
+	}
+
 	return &plugins.IndexerSearchResponse{
-		Releases:    []plugins.IndexerRelease{},
-		Total:       0,
+		Releases:    releases,
+		Total:       len(releases),
 		IndexerID:   "usenet-indexer",
 		IndexerName: "Usenet Indexer",
-	}, fmt.Errorf("direct RPC search requires SDK access - please use HTTP API endpoints instead")
+	}, nil
 }
 
 // IsDownloader returns false as this is not a downloader plugin
@@ -582,23 +724,129 @@ func (p *UsenetIndexerPlugin) getIndexers(ctx context.Context, sdk plugins.SDKIn
 		return []IndexerConfig{}, nil
 	}
 
+	// Stored indexers are always a list, since the config was migrated to
+	// configSchemaVersion on load
 	var indexers []IndexerConfig
-	switch v := val.(type) {
-	case []interface{}:
-		jsonData, _ := json.Marshal(v)
-		json.Unmarshal(jsonData, &indexers)
-	case string:
-		json.Unmarshal([]byte(v), &indexers)
-	default:
-		jsonData, _ := json.Marshal(v)
-		json.Unmarshal(jsonData, &indexers)
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &indexers); err != nil {
+		return nil, fmt.Errorf("invalid stored indexers: %w", err)
+	}
+
+	apiKeys, err := p.getAPIKeys(ctx, sdk)
+	if err != nil {
+		return nil, err
+	}
+	extraSecrets, err := p.getExtraSecrets(ctx, sdk)
+	if err != nil {
+		return nil, err
+	}
+	for i := range indexers {
+		fillExtraSecrets(&indexers[i], extraSecrets[indexers[i].ID])
+	}
+
+	// Indexers saved before API keys became secrets still carry them in
+	// plaintext; move those into the secret store once
+	migrate := false
+	for i := range indexers {
+		if indexers[i].APIKey != "" {
+			if _, ok := apiKeys[indexers[i].ID]; !ok {
+				apiKeys[indexers[i].ID] = indexers[i].APIKey
+			}
+			migrate = true
+		}
+		indexers[i].APIKey = apiKeys[indexers[i].ID]
+	}
+	if migrate {
+		if err := p.saveIndexers(ctx, sdk, indexers); err != nil {
+			return nil, fmt.Errorf("failed to migrate indexer API keys: %w", err)
+		}
 	}
 
 	return indexers, nil
 }
 
+// getAPIKeys loads the indexer API keys. An unreadable secret is an error
+// rather than an empty map, so that saving never drops keys.
+func (p *UsenetIndexerPlugin) getAPIKeys(ctx context.Context, sdk plugins.SDKInterface) (map[string]string, error) {
+	val, err := sdk.ConfigGetSecret(ctx, configAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load indexer API keys: %w", err)
+	}
+
+	apiKeys := make(map[string]string)
+	if val == nil {
+		return apiKeys, nil
+	}
+	jsonData, _ := json.Marshal(val)
+	if err := json.Unmarshal(jsonData, &apiKeys); err != nil {
+		return nil, fmt.Errorf("invalid indexer API keys: %w", err)
+	}
+	return apiKeys, nil
+}
+
+// saveIndexers stores the indexer list with API keys and secret extra values
+// split out into secrets
 func (p *UsenetIndexerPlugin) saveIndexers(ctx context.Context, sdk plugins.SDKInterface, indexers []IndexerConfig) error {
-	return sdk.ConfigSet(ctx, configIndexers, indexers)
+	apiKeys := make(map[string]string)
+	extraSecrets := make(map[string]map[string]string)
+	stored := make([]IndexerConfig, len(indexers))
+	for i, indexer := range indexers {
+		if indexer.APIKey != "" {
+			apiKeys[indexer.ID] = indexer.APIKey
+		}
+		indexer.APIKey = ""
+		if secrets := splitExtraSecrets(&indexer); len(secrets) > 0 {
+			extraSecrets[indexer.ID] = secrets
+		}
+		stored[i] = indexer
+	}
+
+	// Write the secrets first so a failure never leaves indexers without keys
+	if err := sdk.ConfigSetSecret(ctx, configAPIKeys, apiKeys); err != nil {
+		return fmt.Errorf("failed to save indexer API keys: %w", err)
+	}
+	if err := sdk.ConfigSetSecret(ctx, configExtraSecrets, extraSecrets); err != nil {
+		return fmt.Errorf("failed to save indexer extra values: %w", err)
+	}
+	return sdk.ConfigSet(ctx, configIndexers, stored)
+}
+
+// newClient creates a Newznab client for an indexer that counts its requests
+func (p *UsenetIndexerPlugin) newClient(indexer IndexerConfig) *NewznabClient {
+	client := NewNewznabClient(indexer.URL, indexer.APIKey)
+	client.IndexerID = indexer.ID
+	client.APILimit = indexer.APILimit
+	client.Usage = p.usage
+	client.Protocol = indexer.Protocol
+	if client.Protocol == "" {
+		client.Protocol = ProtocolNewznab
+	}
+	client.APIPath = indexer.APIPath
+	client.ExtraParams = indexer.extraParams()
+	client.ExtraHeaders = indexer.extraHeaders()
+	return client
+}
+
+// refreshCaps reads an indexer's caps and records the API limit it reports
+func (p *UsenetIndexerPlugin) refreshCaps(ctx context.Context, client *NewznabClient) error {
+	caps, err := client.Caps(ctx)
+	if err != nil {
+		return err
+	}
+	limit := 0
+	if caps.APILimits != nil {
+		limit = caps.APILimits.APIMax
+		p.usage.Sync(client.IndexerID, caps.APILimits.APICurrent, caps.APILimits.APIMax)
+	}
+	p.usage.SetCapsLimit(client.IndexerID, limit)
+	return nil
+}
+
+// saveUsage persists the API usage counts; failing to do so only loses counts
+func (p *UsenetIndexerPlugin) saveUsage(ctx context.Context, sdk plugins.SDKInterface) {
+	if err := p.usage.Save(ctx, sdk); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save indexer API usage: %v\n", err)
+	}
 }
 
 func (p *UsenetIndexerPlugin) getEnabledIndexers(ctx context.Context, sdk plugins.SDKInterface) ([]IndexerConfig, error) {
@@ -622,147 +870,189 @@ func (p *UsenetIndexerPlugin) getEnabledIndexers(ctx context.Context, sdk plugin
 	return enabledIndexers, nil
 }
 
+// searchKind is a type of Newznab search
+type searchKind struct {
+	name       string                       // Newznab search function, part of the cache key
+	categories func(IndexerConfig) []string // The indexer's categories to use when none are given
+	search     func(*NewznabClient, context.Context, SearchParams) ([]Release, error)
+}
+
+var (
+	generalSearch = searchKind{
+		name:   "search",
+		search: (*NewznabClient).Search,
+	}
+	tvSearch = searchKind{
+		name:       "tvsearch",
+		categories: func(idx IndexerConfig) []string { return idx.TVCategories },
+		search:     (*NewznabClient).SearchTV,
+	}
+	movieSearch = searchKind{
+		name:       "movie",
+		categories: func(idx IndexerConfig) []string { return idx.MovieCategories },
+		search:     (*NewznabClient).SearchMovie,
+	}
+)
+
+// searchResult is the aggregated result of searching several indexers
+type searchResult struct {
+	Releases []Release
+	Skipped  []SkippedIndexer // Indexers that are out of API requests
+	TimedOut []SkippedIndexer // Indexers that didn't answer in time, so results may be missing
+	CachedAt *time.Time       // Set when every indexer was answered from the cache, to the oldest entry
+}
+
+// searchIndexer searches a single indexer, answering from the cache when it
+// has a recent enough result. cachedAt is nil for live results.
+func (p *UsenetIndexerPlugin) searchIndexer(ctx context.Context, idx IndexerConfig, params SearchParams, kind searchKind, ttl time.Duration, fresh bool) (releases []Release, cachedAt *time.Time, err error) {
+	key := cacheKey(idx.ID, kind.name, params)
+	if ttl > 0 && !fresh {
+		if releases, at, ok := p.cache.Get(key, ttl); ok {
+			return releases, &at, nil
+		}
+	}
+
+	client := p.newClient(idx)
+	if p.usage.NeedsCaps(idx.ID) {
+		if err := p.refreshCaps(ctx, client); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read caps of indexer %s: %v\n", idx.Name, err)
+		}
+	}
+
+	releases, err = kind.search(client, ctx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Tag releases with indexer name
+	for i := range releases {
+		releases[i].Attributes["indexer"] = idx.Name
+		releases[i].Attributes["indexer_id"] = idx.ID
+		releases[i].IndexerID = idx.ID
+		releases[i].IndexerName = idx.Name
+	}
+
+	if ttl > 0 {
+		p.cache.Put(key, releases)
+	}
+	return releases, nil, nil
+}
+
 // searchMultipleIndexers searches across multiple indexers in parallel and aggregates results
+// Indexers that are out of API requests are skipped and returned separately,
+// as are indexers that don't answer before their timeout or the search deadline.
 func (p *UsenetIndexerPlugin) searchMultipleIndexers(
 	ctx context.Context,
+	sdk plugins.SDKInterface,
 	indexers []IndexerConfig,
 	params SearchParams,
-	searchFunc func(*NewznabClient, SearchParams) ([]Release, error),
-) ([]Release, error) {
+	kind searchKind,
+	fresh bool,
+) (*searchResult, error) {
+	// indexerResult is a page of releases, and when it was cached
 	type indexerResult struct {
-		indexerName string
-		releases    []Release
-		err         error
+		releases []Release
+		cachedAt *time.Time
 	}
 
-	resultChan := make(chan indexerResult, len(indexers))
-	var wg sync.WaitGroup
+	p.usage.Load(ctx, sdk)
+	defer p.saveUsage(ctx, sdk)
+	ttl := p.cacheTTL(ctx, sdk)
+	timeouts := p.searchTimeouts(ctx, sdk)
 
-	for _, indexer := range indexers {
-		wg.Add(1)
-		go func(idx IndexerConfig) {
-			defer wg.Done()
-
-			// Create a copy of params for this indexer
-			indexerParams := params
-
-			// Use indexer-specific categories if none specified in request
-			if len(indexerParams.Categories) == 0 {
-				// Determine which categories to use based on search type
-				// For TV searches, use TVCategories; for movie searches, use MovieCategories
-				// For general searches, don't specify categories
-				if strings.Contains(fmt.Sprintf("%v", searchFunc), "SearchTV") {
-					indexerParams.Categories = idx.TVCategories
-				} else if strings.Contains(fmt.Sprintf("%v", searchFunc), "SearchMovie") {
-					indexerParams.Categories = idx.MovieCategories
-				}
-			}
+	searchCtx, cancel := context.WithTimeout(ctx, timeouts.deadline)
+	defer cancel()
 
-			client := NewNewznabClient(idx.URL, idx.APIKey)
-			releases, err := searchFunc(client, indexerParams)
+	result := &searchResult{Skipped: []SkippedIndexer{}, TimedOut: []SkippedIndexer{}}
+	allCached := true
+	var lastError error
+	collect := func(call indexerCall[indexerResult]) bool {
+		if call.value.cachedAt == nil {
+			allCached = false
+		} else if result.CachedAt == nil || call.value.cachedAt.Before(*result.CachedAt) {
+			result.CachedAt = call.value.cachedAt
+		}
+		if errors.Is(call.err, ErrRequestLimitReached) {
+			result.Skipped = append(result.Skipped, SkippedIndexer{ID: call.indexer.ID, Name: call.indexer.Name, Reason: call.err.Error()})
+		}
+		return call.err == nil
+	}
+	collectTimedOut := func(timedOut []SkippedIndexer) {
+		for _, indexer := range timedOut {
+			fmt.Fprintf(os.Stderr, "Search of indexer %s timed out: %s\n", indexer.Name, indexer.Reason)
+			lastError = fmt.Errorf("indexer %s: %s", indexer.Name, indexer.Reason)
+			allCached = false
+		}
+		result.TimedOut = append(result.TimedOut, timedOut...)
+	}
 
-			// Tag releases with indexer name
-			for i := range releases {
-				releases[i].Attributes["indexer"] = idx.Name
-				releases[i].Attributes["indexer_id"] = idx.ID
-				releases[i].IndexerID = idx.ID
-				releases[i].IndexerName = idx.Name
-			}
+	calls, timedOut := queryIndexers(searchCtx, indexers, timeouts.indexer, func(callCtx context.Context, idx IndexerConfig) (indexerResult, error) {
+		// Create a copy of params for this indexer
+		indexerParams := params
 
-			resultChan <- indexerResult{
-				indexerName: idx.Name,
-				releases:    releases,
-				err:         err,
-			}
-		}(indexer)
-	}
+		// Use indexer-specific categories if none specified in request
+		// For general searches, don't specify categories
+		if len(indexerParams.Categories) == 0 && kind.categories != nil {
+			indexerParams.Categories = kind.categories(idx)
+		}
 
-	// Wait for all requests to complete
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
+		releases, cachedAt, err := p.searchIndexer(callCtx, idx, indexerParams, kind, ttl, fresh)
+		return indexerResult{releases: releases, cachedAt: cachedAt}, err
+	})
+	collectTimedOut(timedOut)
 
 	// Collect results and track indexers that returned nothing
 	allReleases := []Release{}
 	indexersNeedingFallback := []IndexerConfig{}
-	var lastError error
 
-	for result := range resultChan {
-		if result.err != nil {
-			fmt.Fprintf(os.Stderr, "Search error from indexer %s: %v\n", result.indexerName, result.err)
-			lastError = result.err
+	for _, call := range calls {
+		if !collect(call) {
+			fmt.Fprintf(os.Stderr, "Search error from indexer %s: %v\n", call.indexer.Name, call.err)
+			lastError = call.err
 			continue
 		}
 
-		if len(result.releases) > 0 {
-			allReleases = append(allReleases, result.releases...)
+		if len(call.value.releases) > 0 {
+			allReleases = append(allReleases, call.value.releases...)
 		} else {
 			// This indexer returned 0 results - may need fallback
-			for _, idx := range indexers {
-				if idx.Name == result.indexerName {
-					indexersNeedingFallback = append(indexersNeedingFallback, idx)
-					break
-				}
-			}
+			indexersNeedingFallback = append(indexersNeedingFallback, call.indexer)
 		}
 	}
 
-	// If some indexers returned no results with tvdbid, retry with query-based search
-	if len(indexersNeedingFallback) > 0 && params.TVDBID != "" && params.Query != "" && (params.Season > 0 || params.Episode > 0) {
+	// If some indexers returned no results with tvdbid, retry with query-based
+	// search, in the time left before the deadline
+	if len(indexersNeedingFallback) > 0 && params.TVDBID != "" && params.Query != "" && (params.Season > 0 || params.Episode > 0) && searchCtx.Err() == nil {
 		// Retry those indexers without tvdbid (will use query parameter)
 		fallbackParams := params
 		fallbackParams.TVDBID = ""
 
-		fallbackResultChan := make(chan indexerResult, len(indexersNeedingFallback))
-		var fallbackWg sync.WaitGroup
-
-		for _, indexer := range indexersNeedingFallback {
-			fallbackWg.Add(1)
-			go func(idx IndexerConfig) {
-				defer fallbackWg.Done()
-
-				client := NewNewznabClient(idx.URL, idx.APIKey)
-				releases, err := searchFunc(client, fallbackParams)
-
-				// Tag releases with indexer name
-				for i := range releases {
-					releases[i].Attributes["indexer"] = idx.Name
-					releases[i].Attributes["indexer_id"] = idx.ID
-					releases[i].IndexerID = idx.ID
-					releases[i].IndexerName = idx.Name
-				}
-
-				fallbackResultChan <- indexerResult{
-					indexerName: idx.Name,
-					releases:    releases,
-					err:         err,
-				}
-			}(indexer)
-		}
-
-		// Wait for fallback searches
-		go func() {
-			fallbackWg.Wait()
-			close(fallbackResultChan)
-		}()
+		calls, timedOut := queryIndexers(searchCtx, indexersNeedingFallback, timeouts.indexer, func(callCtx context.Context, idx IndexerConfig) (indexerResult, error) {
+			releases, cachedAt, err := p.searchIndexer(callCtx, idx, fallbackParams, kind, ttl, fresh)
+			return indexerResult{releases: releases, cachedAt: cachedAt}, err
+		})
+		collectTimedOut(timedOut)
 
 		// Collect fallback results and filter by series name
-		for result := range fallbackResultChan {
-			if result.err != nil {
+		for _, call := range calls {
+			if !collect(call) {
 				continue
 			}
-			if len(result.releases) > 0 {
+			if len(call.value.releases) > 0 {
 				// Filter releases to match series name exactly
-				filtered := filterBySeriesName(result.releases, params.Query)
+				filtered := filterBySeriesName(call.value.releases, params.Query)
 				allReleases = append(allReleases, filtered...)
 			}
 		}
 	}
 
+	if !allCached {
+		result.CachedAt = nil
+	}
+
 	// If all indexers failed, return the last error
 	if len(allReleases) == 0 && lastError != nil {
-		return nil, fmt.Errorf("all indexers failed, last error: %w", lastError)
+		return result, fmt.Errorf("all indexers failed, last error: %w", lastError)
 	}
 
 	// Sort results by publish date (newest first)
@@ -780,7 +1070,12 @@ func (p *UsenetIndexerPlugin) searchMultipleIndexers(
 		}
 	}
 
-	return uniqueReleases, nil
+	// Then list releases several indexers carry once
+	uniqueReleases = mergeCrossIndexer(uniqueReleases, indexers)
+
+	// Filtered after the cache so searches with different minimums share entries
+	result.Releases = filterMinSeeders(uniqueReleases, params.MinSeeders)
+	return result, nil
 }
 
 func (p *UsenetIndexerPlugin) parseSearchParams(query map[string][]string) SearchParams {
@@ -821,10 +1116,20 @@ func (p *UsenetIndexerPlugin) parseSearchParams(query map[string][]string) Searc
 			params.Offset = o
 		}
 	}
+	if minSeeders := query["minseeders"]; len(minSeeders) > 0 {
+		if m, err := strconv.Atoi(minSeeders[0]); err == nil {
+			params.MinSeeders = m
+		}
+	}
 
 	return params
 }
 
+// validProtocol reports whether an indexer protocol is supported
+func validProtocol(protocol string) bool {
+	return protocol == "" || protocol == ProtocolNewznab || protocol == ProtocolTorznab
+}
+
 func generateID(name string) string {
 	id := strings.ToLower(name)
 	id = strings.ReplaceAll(id, " ", "-")
@@ -873,11 +1178,12 @@ func filterBySeriesName(releases []Release, seriesName string) []Release {
 	filtered := []Release{}
 
 	// Normalize series name for comparison (lowercase, replace spaces with dots)
-	normalizedSeries := strings.ToLower(strings.ReplaceAll(seriesName, " ", "."))
+	normalizedSeries := normalizeReleaseName(seriesName)
 
 	for _, release := range releases {
-		// Normalize release title
-		normalizedTitle := strings.ToLower(release.Title)
+		// Normalize release title, dropping a release group in brackets in front
+		// of it as anime releases have
+		normalizedTitle := normalizeReleaseName(leadingTagsPattern.ReplaceAllString(release.Title, ""))
 
 		// Check if the release title starts with the series name
 		if strings.HasPrefix(normalizedTitle, normalizedSeries) {
@@ -889,10 +1195,12 @@ func filterBySeriesName(releases []Release, seriesName string) []Release {
 			// - Year in parentheses like (2018)
 			// - Season marker like .S01 or .s01
 			// - Direct season marker like S01 (no dot)
+			// - A dash before an anime episode number like " - 125"
 			if len(afterSeries) == 0 ||
 				afterSeries[0] == '(' || // Year
 				(len(afterSeries) >= 2 && afterSeries[0] == '.' && (afterSeries[1] == 's' || afterSeries[1] == 'S')) || // .S01
-				(afterSeries[0] == 's' || afterSeries[0] == 'S') { // S01
+				(afterSeries[0] == 's' || afterSeries[0] == 'S') || // S01
+				strings.HasPrefix(afterSeries, ".-") { // - 125
 				filtered = append(filtered, release)
 			}
 		}
@@ -901,9 +1209,22 @@ func filterBySeriesName(releases []Release, seriesName string) []Release {
 	return filtered
 }
 
+// leadingTagsPattern matches tags in brackets in front of a release title, like
+// the "[SubsPlease]" of "[SubsPlease] Show - 125 (1080p)"
+var leadingTagsPattern = regexp.MustCompile(`^(?:\s*\[[^\]]*\])+\s*`)
+
+// normalizeReleaseName lowercases a name and separates its words with dots
+func normalizeReleaseName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", ".", "_", ".").Replace(name)
+}
+
 func main() {
 	// Create plugin instance
-	usenetPlugin := &UsenetIndexerPlugin{}
+	usenetPlugin := &UsenetIndexerPlugin{
+		usage: NewUsageTracker(),
+		cache: newSearchCache(maxCacheEntries),
+	}
 
 	// Serve the plugin using go-plugin
 	plugin.Serve(&plugin.ServeConfig{
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
 )
 
 // NewznabClient represents a Newznab API client
@@ -16,8 +21,37 @@ type NewznabClient struct {
 	BaseURL string
 	APIKey  string
 	Client  *http.Client
+
+	// Optional API usage accounting; requests are refused once the indexer's
+	// limit (APILimit, or the limit it reports) is used up
+	IndexerID string
+	APILimit  int
+	Usage     *UsageTracker
+
+	// Protocol is ProtocolNewznab or ProtocolTorznab. Torznab is the same API
+	// returning torrents, with seeders, peers and magnet links as attributes.
+	Protocol string
+
+	// APIPath is where the API is below BaseURL, /api when empty. ExtraParams
+	// are added to every request, without replacing the request's own
+	// parameters, and ExtraHeaders sent with it.
+	APIPath      string
+	ExtraParams  url.Values
+	ExtraHeaders http.Header
 }
 
+// Indexer protocols
+const (
+	ProtocolNewznab = "newznab"
+	ProtocolTorznab = "torznab"
+)
+
+// Release protocols, as understood by the host when picking a downloader
+const (
+	releaseProtocolUsenet  = "usenet"
+	releaseProtocolTorrent = "torrent"
+)
+
 // NewznabResponse represents the XML response from Newznab API
 type NewznabResponse struct {
 	XMLName xml.Name       `xml:"rss"`
@@ -26,9 +60,47 @@ type NewznabResponse struct {
 
 // NewznabChannel represents the channel element in Newznab response
 type NewznabChannel struct {
-	Title       string        `xml:"title"`
-	Description string        `xml:"description"`
-	Items       []NewznabItem `xml:"item"`
+	Title       string            `xml:"title"`
+	Description string            `xml:"description"`
+	APILimits   *NewznabAPILimits `xml:"apilimits"`
+	Items       []NewznabItem     `xml:"item"`
+}
+
+// NewznabAPILimits is the usage some indexers report with every response
+type NewznabAPILimits struct {
+	APICurrent int `xml:"apicurrent,attr"`
+	APIMax     int `xml:"apimax,attr"`
+}
+
+// NewznabCaps represents the capabilities (t=caps) response
+type NewznabCaps struct {
+	XMLName xml.Name `xml:"caps"`
+	Limits  struct {
+		Max     int `xml:"max,attr"`
+		Default int `xml:"default,attr"`
+	} `xml:"limits"`
+	APILimits *NewznabAPILimits `xml:"apilimits"`
+}
+
+// NewznabError is an error returned by the Newznab API in place of results
+type NewznabError struct {
+	Code        int    `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+}
+
+// Newznab error code for an exhausted API limit
+const newznabRequestLimitReached = 500
+
+func (e *NewznabError) Error() string {
+	return fmt.Sprintf("indexer error %d: %s", e.Code, e.Description)
+}
+
+// Unwrap lets callers match an exhausted limit with errors.Is
+func (e *NewznabError) Unwrap() error {
+	if e.Code == newznabRequestLimitReached {
+		return ErrRequestLimitReached
+	}
+	return nil
 }
 
 // NewznabItem represents a single release/item in the feed
@@ -68,6 +140,7 @@ type SearchParams struct {
 	Episode    int      // Episode number (0 means not specified)
 	Limit      int      // Max results (default 100)
 	Offset     int      // Offset for pagination
+	MinSeeders int      // Drop torrent results with fewer seeders (0 = no minimum)
 }
 
 // Release represents a normalized release from Newznab
@@ -85,6 +158,16 @@ type Release struct {
 	Attributes  map[string]string `json:"attributes"`
 	IndexerID   string            `json:"indexer_id,omitempty"`   // Added for IndexerRelease compatibility
 	IndexerName string            `json:"indexer_name,omitempty"` // Added for IndexerRelease compatibility
+	Protocol    string            `json:"protocol"`               // "usenet" or "torrent", also in Attributes
+
+	// Torrent details, only set for Torznab results
+	Seeders   *int   `json:"seeders,omitempty"`
+	Peers     *int   `json:"peers,omitempty"`
+	InfoHash  string `json:"info_hash,omitempty"`
+	MagnetURL string `json:"magnet_url,omitempty"`
+
+	// Other indexers carrying the same release, best first
+	AlsoAvailableOn []plugins.ReleaseSource `json:"also_available_on,omitempty"`
 }
 
 // NewNewznabClient creates a new Newznab client
@@ -99,13 +182,11 @@ func NewNewznabClient(baseURL, apiKey string) *NewznabClient {
 }
 
 // Search performs a search on the Newznab indexer
-func (c *NewznabClient) Search(params SearchParams) ([]Release, error) {
+func (c *NewznabClient) Search(ctx context.Context, params SearchParams) ([]Release, error) {
 	if params.Limit == 0 {
 		params.Limit = 100
 	}
 
-	apiURL := fmt.Sprintf("%s/api", c.BaseURL)
-
 	// Build query parameters
 	queryParams := url.Values{}
 	queryParams.Set("t", "search")
@@ -121,29 +202,15 @@ func (c *NewznabClient) Search(params SearchParams) ([]Release, error) {
 		queryParams.Set("cat", strings.Join(params.Categories, ","))
 	}
 
-	// Make the request
-	resp, err := c.Client.Get(apiURL + "?" + queryParams.Encode())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return c.parseResponse(resp.Body)
+	return c.query(ctx, queryParams)
 }
 
 // SearchTV performs a TV show search on the Newznab indexer
-func (c *NewznabClient) SearchTV(params SearchParams) ([]Release, error) {
+func (c *NewznabClient) SearchTV(ctx context.Context, params SearchParams) ([]Release, error) {
 	if params.Limit == 0 {
 		params.Limit = 100
 	}
 
-	apiURL := fmt.Sprintf("%s/api", c.BaseURL)
-
 	// Build query parameters
 	queryParams := url.Values{}
 	queryParams.Set("t", "tvsearch")
@@ -180,29 +247,15 @@ func (c *NewznabClient) SearchTV(params SearchParams) ([]Release, error) {
 		queryParams.Set("ep", strconv.Itoa(params.Episode))
 	}
 
-	// Make the request
-	resp, err := c.Client.Get(apiURL + "?" + queryParams.Encode())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return c.parseResponse(resp.Body)
+	return c.query(ctx, queryParams)
 }
 
 // SearchMovie performs a movie search on the Newznab indexer
-func (c *NewznabClient) SearchMovie(params SearchParams) ([]Release, error) {
+func (c *NewznabClient) SearchMovie(ctx context.Context, params SearchParams) ([]Release, error) {
 	if params.Limit == 0 {
 		params.Limit = 100
 	}
 
-	apiURL := fmt.Sprintf("%s/api", c.BaseURL)
-
 	// Build query parameters
 	queryParams := url.Values{}
 	queryParams.Set("t", "movie")
@@ -223,29 +276,15 @@ func (c *NewznabClient) SearchMovie(params SearchParams) ([]Release, error) {
 		queryParams.Set("cat", strings.Join(params.Categories, ","))
 	}
 
-	// Make the request
-	resp, err := c.Client.Get(apiURL + "?" + queryParams.Encode())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return c.parseResponse(resp.Body)
+	return c.query(ctx, queryParams)
 }
 
 // GetRSSFeed gets the latest releases from RSS feed
-func (c *NewznabClient) GetRSSFeed(categories []string, limit int) ([]Release, error) {
+func (c *NewznabClient) GetRSSFeed(ctx context.Context, categories []string, limit int) ([]Release, error) {
 	if limit == 0 {
 		limit = 100
 	}
 
-	apiURL := fmt.Sprintf("%s/api", c.BaseURL)
-
 	// Build query parameters
 	queryParams := url.Values{}
 	queryParams.Set("t", "search")
@@ -256,40 +295,120 @@ func (c *NewznabClient) GetRSSFeed(categories []string, limit int) ([]Release, e
 		queryParams.Set("cat", strings.Join(categories, ","))
 	}
 
-	// Make the request
-	resp, err := c.Client.Get(apiURL + "?" + queryParams.Encode())
+	return c.query(ctx, queryParams)
+}
+
+// TestConnection tests the connection to the Newznab indexer
+func (c *NewznabClient) TestConnection(ctx context.Context) error {
+	_, err := c.Caps(ctx)
+	return err
+}
+
+// Caps fetches the capabilities of the indexer. Caps requests don't count
+// against API limits, so they bypass the usage accounting.
+func (c *NewznabClient) Caps(ctx context.Context) (*NewznabCaps, error) {
+	queryParams := url.Values{}
+	queryParams.Set("t", "caps")
+	queryParams.Set("apikey", c.APIKey)
+
+	body, err := c.get(ctx, queryParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, fmt.Errorf("connection failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	var caps NewznabCaps
+	if err := xml.Unmarshal(body, &caps); err != nil {
+		return nil, fmt.Errorf("failed to decode caps: %w", err)
+	}
+	return &caps, nil
+}
+
+// query makes a counted API request and parses the releases it returns
+func (c *NewznabClient) query(ctx context.Context, queryParams url.Values) ([]Release, error) {
+	if c.Usage != nil {
+		if err := c.Usage.Acquire(c.IndexerID, c.APILimit); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := c.get(ctx, queryParams)
+	if err != nil {
+		if c.Usage != nil && errors.Is(err, ErrRequestLimitReached) {
+			c.Usage.MarkLimited(c.IndexerID)
+		}
+		return nil, err
 	}
 
-	return c.parseResponse(resp.Body)
+	return c.parseResponse(bytes.NewReader(body))
 }
 
-// TestConnection tests the connection to the Newznab indexer
-func (c *NewznabClient) TestConnection() error {
-	apiURL := fmt.Sprintf("%s/api", c.BaseURL)
+// get makes an API request and returns the response body, turning Newznab
+// error responses into a *NewznabError. The request is abandoned when ctx
+// ends.
+func (c *NewznabClient) get(ctx context.Context, queryParams url.Values) ([]byte, error) {
+	apiPath := c.APIPath
+	if apiPath == "" {
+		apiPath = defaultAPIPath
+	}
+	apiURL := c.BaseURL + apiPath
 
-	queryParams := url.Values{}
-	queryParams.Set("t", "caps")
-	queryParams.Set("apikey", c.APIKey)
+	for name, values := range c.ExtraParams {
+		if _, ok := queryParams[name]; !ok {
+			queryParams[name] = values
+		}
+	}
 
-	resp, err := c.Client.Get(apiURL + "?" + queryParams.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"?"+queryParams.Encode(), nil)
 	if err != nil {
-		return fmt.Errorf("connection failed: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range c.ExtraHeaders {
+		req.Header[name] = values
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: API returned status %d", ErrRequestLimitReached, resp.StatusCode)
+	}
+	if nzErr := parseError(body); nzErr != nil {
+		return nil, nzErr
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	return body, nil
+}
+
+// parseError returns the error of a Newznab error response, or nil when the
+// response is something else
+func parseError(body []byte) *NewznabError {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "error" {
+				return nil
+			}
+			var nzErr NewznabError
+			if err := decoder.DecodeElement(&nzErr, &start); err != nil {
+				return nil
+			}
+			return &nzErr
+		}
+	}
 }
 
 // parseResponse parses the Newznab XML response
@@ -301,6 +420,10 @@ func (c *NewznabClient) parseResponse(reader io.Reader) ([]Release, error) {
 		return nil, fmt.Errorf("failed to decode XML: %w", err)
 	}
 
+	if limits := response.Channel.APILimits; limits != nil && c.Usage != nil {
+		c.Usage.Sync(c.IndexerID, limits.APICurrent, limits.APIMax)
+	}
+
 	releases := make([]Release, 0, len(response.Channel.Items))
 
 	for _, item := range response.Channel.Items {
@@ -334,8 +457,53 @@ func (c *NewznabClient) parseResponse(reader io.Reader) ([]Release, error) {
 			release.Attributes[attr.Name] = attr.Value
 		}
 
+		release.Protocol = releaseProtocolUsenet
+		if c.Protocol == ProtocolTorznab {
+			parseTorznabAttributes(&release)
+		}
+		release.Attributes["protocol"] = release.Protocol
+
 		releases = append(releases, release)
 	}
 
 	return releases, nil
 }
+
+// parseTorznabAttributes fills in the torrent details of a Torznab release
+func parseTorznabAttributes(release *Release) {
+	release.Protocol = releaseProtocolTorrent
+
+	if seeders, err := strconv.Atoi(release.Attributes["seeders"]); err == nil {
+		release.Seeders = &seeders
+	}
+	if peers, err := strconv.Atoi(release.Attributes["peers"]); err == nil {
+		release.Peers = &peers
+	}
+	release.InfoHash = release.Attributes["infohash"]
+	release.MagnetURL = release.Attributes["magneturl"]
+
+	// Some trackers only offer magnet links
+	if release.DownloadURL == "" {
+		release.DownloadURL = release.MagnetURL
+	}
+	if release.Size == 0 {
+		release.Size, _ = strconv.ParseInt(release.Attributes["size"], 10, 64)
+	}
+}
+
+// filterMinSeeders drops torrent releases with fewer seeders than minimum.
+// Releases that don't report seeders, and usenet releases, are kept.
+func filterMinSeeders(releases []Release, minimum int) []Release {
+	if minimum <= 0 {
+		return releases
+	}
+
+	filtered := make([]Release, 0, len(releases))
+	for _, release := range releases {
+		if release.Protocol == releaseProtocolTorrent && release.Seeders != nil && *release.Seeders < minimum {
+			continue
+		}
+		filtered = append(filtered, release)
+	}
+	return filtered
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const torznabResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torznab="http://torznab.com/schemas/2015/feed">
+<channel>
+	<title>Jackett</title>
+	<item>
+		<title>Show.S01E01.1080p.WEB.h264-GROUP</title>
+		<guid>https://tracker.example/details/1</guid>
+		<link>https://tracker.example/download/1.torrent</link>
+		<pubDate>Sat, 01 Jun 2024 12:00:00 +0000</pubDate>
+		<enclosure url="https://tracker.example/download/1.torrent" length="1500000000" type="application/x-bittorrent"/>
+		<torznab:attr name="seeders" value="42"/>
+		<torznab:attr name="peers" value="50"/>
+		<torznab:attr name="infohash" value="0123456789abcdef0123456789abcdef01234567"/>
+		<torznab:attr name="magneturl" value="magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"/>
+	</item>
+	<item>
+		<title>Show.S01E01.720p.HDTV.x264-OTHER</title>
+		<guid>https://tracker.example/details/2</guid>
+		<torznab:attr name="seeders" value="1"/>
+		<torznab:attr name="size" value="800000000"/>
+		<torznab:attr name="magneturl" value="magnet:?xt=urn:btih:fedcba"/>
+	</item>
+</channel>
+</rss>`
+
+func TestParseTorznabResponse(t *testing.T) {
+	client := NewNewznabClient("https://jackett.example", "key")
+	client.Protocol = ProtocolTorznab
+
+	releases, err := client.parseResponse(strings.NewReader(torznabResponse))
+	if err != nil {
+		t.Fatalf("parseResponse() error = %v", err)
+	}
+	if len(releases) != 2 {
+		t.Fatalf("parseResponse() returned %d releases, want 2", len(releases))
+	}
+
+	first := releases[0]
+	if first.Protocol != "torrent" || first.Attributes["protocol"] != "torrent" {
+		t.Errorf("Protocol = %q (attribute %q), want torrent", first.Protocol, first.Attributes["protocol"])
+	}
+	if first.Seeders == nil || *first.Seeders != 42 || first.Peers == nil || *first.Peers != 50 {
+		t.Errorf("Seeders/Peers = %v/%v, want 42/50", first.Seeders, first.Peers)
+	}
+	if first.InfoHash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("InfoHash = %q", first.InfoHash)
+	}
+
+	// Magnet-only results fall back to the magnet link and size attribute
+	second := releases[1]
+	if second.DownloadURL != "magnet:?xt=urn:btih:fedcba" || second.Size != 800000000 {
+		t.Errorf("second release = %q, %d, want the magnet link and size attribute", second.DownloadURL, second.Size)
+	}
+
+	filtered := filterMinSeeders(releases, 5)
+	if len(filtered) != 1 || filtered[0].GUID != first.GUID {
+		t.Errorf("filterMinSeeders() = %v, want only the well seeded release", filtered)
+	}
+}
+
+func TestParseNewznabResponseProtocol(t *testing.T) {
+	client := NewNewznabClient("https://indexer.example", "key")
+
+	releases, err := client.parseResponse(strings.NewReader(`<rss><channel><item><title>Movie.2024.1080p</title><guid>1</guid></item></channel></rss>`))
+	if err != nil {
+		t.Fatalf("parseResponse() error = %v", err)
+	}
+	if len(releases) != 1 || releases[0].Protocol != "usenet" || releases[0].Seeders != nil {
+		t.Errorf("parseResponse() = %+v, want a usenet release without seeders", releases)
+	}
+
+	// Usenet results are never dropped for lacking seeders
+	if filtered := filterMinSeeders(releases, 10); len(filtered) != 1 {
+		t.Errorf("filterMinSeeders() dropped a usenet release")
+	}
+}
+
+func TestNewznabClientExtras(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`<rss version="2.0"><channel></channel></rss>`))
+	}))
+	defer server.Close()
+
+	client := NewNewznabClient(server.URL, "key")
+	client.APIPath = "/newznab/api"
+	client.ExtraParams = url.Values{"uid": {"42"}, "apikey": {"other"}, "t": {"other"}}
+	client.ExtraHeaders = http.Header{"X-Api-Token": {"abc"}}
+
+	if _, err := client.Search(context.Background(), SearchParams{Query: "show"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/newznab/api" {
+		t.Errorf("path = %q, want /newznab/api", got.URL.Path)
+	}
+	query := got.URL.Query()
+	if query.Get("uid") != "42" || query.Get("apikey") != "key" || query.Get("t") != "search" {
+		t.Errorf("query = %v", query)
+	}
+	if got.Header.Get("X-Api-Token") != "abc" {
+		t.Errorf("X-Api-Token = %q", got.Header.Get("X-Api-Token"))
+	}
+}
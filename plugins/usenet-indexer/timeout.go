@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// A search of several indexers returns the results that arrived by its
+// deadline, so one slow indexer can't hold up the others. Each indexer also
+// has its own, shorter timeout, after which its request is abandoned.
+
+const (
+	configSearchDeadline = configPrefix + ".search_deadline_seconds"
+	configIndexerTimeout = configPrefix + ".indexer_timeout_seconds"
+
+	defaultSearchDeadlineSeconds = 20
+	defaultIndexerTimeoutSeconds = 10
+)
+
+// errIndexerTimeout is the error of an indexer that didn't answer in time
+var errIndexerTimeout = errors.New("indexer timed out")
+
+// searchTimeouts bounds a search of several indexers
+type searchTimeouts struct {
+	deadline time.Duration // The whole search, including fallback searches
+	indexer  time.Duration // A single request to an indexer
+}
+
+// searchTimeouts returns the configured search timeouts
+func (p *UsenetIndexerPlugin) searchTimeouts(ctx context.Context, sdk plugins.SDKInterface) searchTimeouts {
+	return searchTimeouts{
+		deadline: configSeconds(ctx, sdk, configSearchDeadline, defaultSearchDeadlineSeconds),
+		indexer:  configSeconds(ctx, sdk, configIndexerTimeout, defaultIndexerTimeoutSeconds),
+	}
+}
+
+// configSeconds reads a duration in seconds from the config store, falling
+// back to the default when it is unset or not positive
+func configSeconds(ctx context.Context, sdk plugins.SDKInterface, key string, defaultSeconds int) time.Duration {
+	seconds := defaultSeconds
+	if val, err := sdk.ConfigGet(ctx, key); err == nil && val != nil {
+		switch v := val.(type) {
+		case float64:
+			seconds = int(v)
+		case string:
+			if s, err := strconv.Atoi(v); err == nil {
+				seconds = s
+			}
+		}
+	}
+	if seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// indexerCall is the answer of one indexer to a request made by queryIndexers
+type indexerCall[T any] struct {
+	index   int
+	indexer IndexerConfig
+	value   T
+	err     error
+}
+
+// queryIndexers calls query for every indexer in parallel, each with its own
+// timeout, and returns the answers that arrive before ctx ends, in the order
+// they arrived. Indexers that time out, or haven't answered when ctx ends, are
+// returned separately.
+//
+// Cancelling ctx abandons the requests still running, which then finish on
+// their own without blocking.
+func queryIndexers[T any](ctx context.Context, indexers []IndexerConfig, timeout time.Duration, query func(context.Context, IndexerConfig) (T, error)) ([]indexerCall[T], []SkippedIndexer) {
+	// Buffered for every indexer, so requests answering after the deadline
+	// never block
+	results := make(chan indexerCall[T], len(indexers))
+	for i, indexer := range indexers {
+		go func(i int, idx IndexerConfig) {
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			value, err := query(callCtx, idx)
+			if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				err = fmt.Errorf("%w: no answer within %s", errIndexerTimeout, timeout)
+			}
+			results <- indexerCall[T]{index: i, indexer: idx, value: value, err: err}
+		}(i, indexer)
+	}
+
+	var calls []indexerCall[T]
+	timedOut := []SkippedIndexer{}
+	answered := make([]bool, len(indexers))
+	for received := 0; received < len(indexers); received++ {
+		select {
+		case call := <-results:
+			answered[call.index] = true
+			if errors.Is(call.err, errIndexerTimeout) {
+				timedOut = append(timedOut, SkippedIndexer{ID: call.indexer.ID, Name: call.indexer.Name, Reason: call.err.Error()})
+				continue
+			}
+			calls = append(calls, call)
+		case <-ctx.Done():
+			for i, idx := range indexers {
+				if !answered[i] {
+					timedOut = append(timedOut, SkippedIndexer{ID: idx.ID, Name: idx.Name, Reason: "no answer before the search deadline"})
+				}
+			}
+			return calls, timedOut
+		}
+	}
+	return calls, timedOut
+}
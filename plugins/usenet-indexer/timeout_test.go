@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blakestevenson/nimbus/internal/plugins"
+)
+
+// configSDK is an SDK with only an in-memory config store
+type configSDK struct {
+	plugins.SDKInterface
+	config map[string]interface{}
+}
+
+func (s *configSDK) ConfigGet(ctx context.Context, key string) (interface{}, error) {
+	return s.config[key], nil
+}
+
+func (s *configSDK) ConfigSet(ctx context.Context, key string, value interface{}) error {
+	s.config[key] = value
+	return nil
+}
+
+func (s *configSDK) ConfigGetSecret(ctx context.Context, key string) (interface{}, error) {
+	return nil, nil
+}
+
+// releaseFeed is a Newznab response with a single release
+func releaseFeed(title string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><item>
+	<title>%s</title>
+	<guid>%s</guid>
+	<pubDate>Sat, 01 Jun 2024 12:00:00 +0000</pubDate>
+</item></channel></rss>`, title, title)
+}
+
+// newIndexerServer starts a fake indexer answering searches with a release. A
+// slow indexer only answers once the request is abandoned or the test ends.
+func newIndexerServer(t *testing.T, title string, slow bool) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("t") == "caps" {
+			fmt.Fprint(w, `<caps></caps>`)
+			return
+		}
+		if slow {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-done:
+			}
+		}
+		fmt.Fprint(w, releaseFeed(title))
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+	return server
+}
+
+// runningQueries counts the goroutines of queryIndexers still running
+func runningQueries() int {
+	buf := make([]byte, 1<<20)
+	return strings.Count(string(buf[:runtime.Stack(buf, true)]), "main.queryIndexers[")
+}
+
+// waitForQueries waits for the goroutines of queryIndexers to finish
+func waitForQueries(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runningQueries() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d indexer requests still running after the search returned", runningQueries())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTimeoutTestPlugin(t *testing.T, deadlineSeconds, indexerTimeoutSeconds int) (*UsenetIndexerPlugin, *configSDK, []IndexerConfig) {
+	t.Helper()
+	fast := newIndexerServer(t, "Fast.Release.1080p", false)
+	slow := newIndexerServer(t, "Slow.Release.1080p", true)
+
+	p := &UsenetIndexerPlugin{usage: NewUsageTracker(), cache: newSearchCache(maxCacheEntries)}
+	sdk := &configSDK{config: map[string]interface{}{
+		configCacheTTL:       float64(0),
+		configSearchDeadline: float64(deadlineSeconds),
+		configIndexerTimeout: float64(indexerTimeoutSeconds),
+	}}
+	indexers := []IndexerConfig{
+		{ID: "fast", Name: "Fast", URL: fast.URL, Enabled: true},
+		{ID: "slow", Name: "Slow", URL: slow.URL, Enabled: true},
+	}
+	return p, sdk, indexers
+}
+
+func TestSearchIndexerTimeout(t *testing.T) {
+	p, sdk, indexers := newTimeoutTestPlugin(t, 20, 1)
+
+	start := time.Now()
+	result, err := p.searchMultipleIndexers(context.Background(), sdk, indexers, SearchParams{Query: "release"}, generalSearch, false)
+	if err != nil {
+		t.Fatalf("searchMultipleIndexers() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("searchMultipleIndexers() took %s, want it to stop waiting at the indexer timeout", elapsed)
+	}
+	if len(result.Releases) != 1 || result.Releases[0].Title != "Fast.Release.1080p" {
+		t.Errorf("Releases = %+v, want the fast indexer's release", result.Releases)
+	}
+	if len(result.TimedOut) != 1 || result.TimedOut[0].ID != "slow" || !strings.Contains(result.TimedOut[0].Reason, "no answer within 1s") {
+		t.Errorf("TimedOut = %+v, want the slow indexer", result.TimedOut)
+	}
+	waitForQueries(t)
+}
+
+func TestSearchDeadline(t *testing.T) {
+	p, sdk, indexers := newTimeoutTestPlugin(t, 1, 30)
+
+	start := time.Now()
+	result, err := p.searchMultipleIndexers(context.Background(), sdk, indexers, SearchParams{Query: "release"}, generalSearch, false)
+	if err != nil {
+		t.Fatalf("searchMultipleIndexers() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("searchMultipleIndexers() took %s, want it to return at the deadline", elapsed)
+	}
+	if len(result.Releases) != 1 {
+		t.Errorf("Releases = %+v, want the fast indexer's release", result.Releases)
+	}
+	if len(result.TimedOut) != 1 || result.TimedOut[0].ID != "slow" || !strings.Contains(result.TimedOut[0].Reason, "deadline") {
+		t.Errorf("TimedOut = %+v, want the slow indexer past the deadline", result.TimedOut)
+	}
+	waitForQueries(t)
+}
+
+func TestRSSDeadline(t *testing.T) {
+	p, sdk, indexers := newTimeoutTestPlugin(t, 1, 30)
+	stored := make([]interface{}, len(indexers))
+	for i, indexer := range indexers {
+		stored[i] = map[string]interface{}{"id": indexer.ID, "name": indexer.Name, "url": indexer.URL, "enabled": true}
+	}
+	sdk.config[configIndexers] = stored
+
+	resp, err := p.handleRSS(context.Background(), &plugins.PluginHTTPRequest{SDK: sdk, Query: map[string][]string{}})
+	if err != nil {
+		t.Fatalf("handleRSS() error = %v", err)
+	}
+	var body struct {
+		Releases []Release          `json:"releases"`
+		TimedOut []SkippedIndexer   `json:"timed_out_indexers"`
+		Indexers []rssIndexerResult `json:"indexers"`
+	}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Releases) != 1 || len(body.TimedOut) != 1 || body.TimedOut[0].ID != "slow" {
+		t.Errorf("handleRSS() = %s, want the fast feed and the slow indexer timed out", resp.Body)
+	}
+	waitForQueries(t)
+}
@@ -71,6 +71,11 @@ function ConfirmModal({
   );
 }
 
+interface ExtraValue {
+  value: string;
+  secret?: boolean;
+}
+
 interface Indexer {
   id: string;
   name: string;
@@ -80,6 +85,16 @@ interface Indexer {
   priority: number;
   tv_categories: string[];
   movie_categories: string[];
+  api_limit?: number;
+  protocol?: "newznab" | "torznab";
+  api_path?: string;
+  extra_params?: Record<string, ExtraValue>;
+  extra_headers?: Record<string, ExtraValue>;
+  usage?: {
+    used: number;
+    limit?: number;
+    limited_until?: string;
+  };
 }
 
 interface Release {
@@ -279,6 +294,7 @@ export default function UsenetIndexerPage() {
               priority: 0,
               tv_categories: [],
               movie_categories: [],
+              protocol: "newznab",
             });
             setShowIndexerForm(true);
           }}
@@ -305,6 +321,11 @@ export default function UsenetIndexerPage() {
                 <div className="flex-1">
                   <div className="flex items-center space-x-2">
                     <h4 className="font-medium">{indexer.name}</h4>
+                    {indexer.protocol === "torznab" && (
+                      <span className="text-xs px-2 py-1 bg-blue-500/10 text-blue-600 rounded">
+                        Torznab
+                      </span>
+                    )}
                     {!indexer.enabled && (
                       <span className="text-xs px-2 py-1 bg-red-500/10 text-red-600 rounded">
                         Disabled
@@ -312,6 +333,16 @@ export default function UsenetIndexerPage() {
                     )}
                   </div>
                   <p className="text-sm text-muted-foreground">{indexer.url}</p>
+                  {indexer.usage && (
+                    <p className="text-xs text-muted-foreground">
+                      {indexer.usage.limit
+                        ? `${indexer.usage.used}/${indexer.usage.limit} calls used`
+                        : `${indexer.usage.used} calls`}{" "}
+                      in the last 24h
+                      {indexer.usage.limited_until &&
+                        ` · limit reached until ${new Date(indexer.usage.limited_until).toLocaleString()}`}
+                    </p>
+                  )}
                 </div>
                 <div className="flex space-x-2">
                   <button
@@ -396,6 +427,23 @@ export default function UsenetIndexerPage() {
                 />
               </div>
 
+              <div className="space-y-2">
+                <label className="block text-sm font-medium">Protocol</label>
+                <select
+                  className="w-full px-3 py-2 bg-background border rounded-md"
+                  value={editingIndexer.protocol || "newznab"}
+                  onChange={(e) =>
+                    setEditingIndexer({
+                      ...editingIndexer,
+                      protocol: e.target.value as Indexer["protocol"],
+                    })
+                  }
+                >
+                  <option value="newznab">Newznab (Usenet)</option>
+                  <option value="torznab">Torznab (Prowlarr/Jackett)</option>
+                </select>
+              </div>
+
               <div className="space-y-2">
                 <label className="block text-sm font-medium">API URL</label>
                 <input
@@ -491,6 +539,43 @@ export default function UsenetIndexerPage() {
                   Higher priority indexers are searched first
                 </p>
               </div>
+
+              <div className="space-y-2">
+                <label className="block text-sm font-medium">API Limit</label>
+                <input
+                  type="number"
+                  className="w-full px-3 py-2 bg-background border rounded-md"
+                  value={editingIndexer.api_limit || ""}
+                  onChange={(e) =>
+                    setEditingIndexer({
+                      ...editingIndexer,
+                      api_limit: parseInt(e.target.value) || 0,
+                    })
+                  }
+                />
+                <p className="text-xs text-muted-foreground">
+                  API calls allowed per day (empty = use the limit the indexer reports)
+                </p>
+              </div>
+
+              <div className="space-y-2">
+                <label className="block text-sm font-medium">API Path</label>
+                <input
+                  type="text"
+                  className="w-full px-3 py-2 bg-background border rounded-md"
+                  value={editingIndexer.api_path || ""}
+                  onChange={(e) =>
+                    setEditingIndexer({
+                      ...editingIndexer,
+                      api_path: e.target.value,
+                    })
+                  }
+                  placeholder="/api"
+                />
+                <p className="text-xs text-muted-foreground">
+                  Where the API lives below the URL (empty = /api)
+                </p>
+              </div>
             </div>
 
             <div className="flex items-center space-x-2">